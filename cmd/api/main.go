@@ -1,30 +1,77 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/PakornBank/learn-go/internal/app"
 	"github.com/PakornBank/learn-go/internal/config"
-	"github.com/PakornBank/learn-go/internal/database"
-	"github.com/PakornBank/learn-go/internal/router"
-	"github.com/gin-gonic/gin"
 )
 
 func main() {
-	config, err := config.LoadConfig()
+	configPath := flag.String("config", "", "path to a YAML config file (default $CONFIG_FILE)")
+	checkConfig := flag.Bool("check-config", false, "validate the configuration, print it with secrets masked and exit")
+	generateSecret := flag.Bool("generate-secret", false, "print a random secret to use as JWT_SECRET and exit")
+	flag.Parse()
+
+	if *generateSecret {
+		secret, err := config.GenerateSecret()
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(secret)
+		return
+	}
+
+	cfg, err := config.LoadConfigFile(*configPath)
 	if err != nil {
-		log.Fatal("Failed to load config:", err)
+		fatalConfig(err)
+	}
+	if *checkConfig {
+		fmt.Print(cfg)
+		return
 	}
 
-	db, err := database.NewDataBase(config)
+	server, err := app.New(cfg, *configPath)
 	if err != nil {
-		log.Fatal("Failed to initialize database:", err)
+		log.Fatal(err)
 	}
 
-	r := gin.Default()
-	router.NewRouter(r, db, config).SetupRoutes()
+	// SIGINT or SIGTERM drains the server: requests in flight finish within
+	// SHUTDOWN_TIMEOUT while new ones are turned away with a 503.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err = server.Run(ctx)
+	stop()
+	if err == nil {
+		log.Printf("Shutting down, waiting up to %s for requests in flight\n", cfg.ShutdownTimeout)
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if shutdownErr := server.Shutdown(shutdownCtx); err == nil {
+		err = shutdownErr
+	}
+	if err != nil {
+		log.Fatal("Server failed:", err)
+	}
+}
+
+// fatalConfig logs err, listing each problem on its own line when it joins
+// several, as Config.Validate does, and exits.
+func fatalConfig(err error) {
+	var joined interface{ Unwrap() []error }
+	if !errors.As(err, &joined) {
+		log.Fatal("Failed to load config:", err)
+	}
 
-	log.Printf("Server running on port %s\n", config.ServerPort)
-	if err := r.Run(":" + config.ServerPort); err != nil {
-		log.Fatal("Failed to start server:", err)
+	log.Print("Failed to load config:")
+	for _, problem := range joined.Unwrap() {
+		log.Print("  - ", problem)
 	}
+	os.Exit(1)
 }
@@ -0,0 +1,181 @@
+// Package apierror writes the error responses of every handler and
+// middleware, so that all of them share one format. Errors are written as
+// {"error": message}, with an optional "code", unless the server is
+// configured for or the client asks for RFC 7807 problem details.
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/render"
+	"github.com/go-playground/validator/v10"
+)
+
+// ProblemContentType is the media type of RFC 7807 problem details. Clients
+// that list it in their Accept header receive errors in that format.
+const ProblemContentType = "application/problem+json"
+
+// problemFormatKey is the Gin context key Negotiate stores the server default in.
+const problemFormatKey = "problem_format"
+
+// Problem is an RFC 7807 problem details object. Code and Errors are
+// extension members, and Extensions holds any others, which are encoded
+// alongside the standard members without overriding them.
+type Problem struct {
+	Type       string         `json:"type"`
+	Title      string         `json:"title"`
+	Status     int            `json:"status"`
+	Detail     string         `json:"detail,omitempty"`
+	Instance   string         `json:"instance,omitempty"`
+	Code       string         `json:"code,omitempty"`
+	Errors     []FieldError   `json:"errors,omitempty"`
+	Extensions map[string]any `json:"-"`
+}
+
+// MarshalJSON encodes p with the members of Extensions added to its own.
+func (p Problem) MarshalJSON() ([]byte, error) {
+	type problem Problem
+	data, err := json.Marshal(problem(p))
+	if err != nil || len(p.Extensions) == 0 {
+		return data, err
+	}
+
+	var members map[string]any
+	if err := json.Unmarshal(data, &members); err != nil {
+		return nil, err
+	}
+	for name, value := range p.Extensions {
+		if _, ok := members[name]; !ok {
+			members[name] = value
+		}
+	}
+	return json.Marshal(members)
+}
+
+// FieldError describes one field of a request that failed validation.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Negotiate is a middleware function for the Gin framework that sets the
+// error format of the server. When problemByDefault is true, errors are
+// written as problem details even for clients that do not ask for them.
+// It should be registered before any middleware that may write an error.
+func Negotiate(problemByDefault bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(problemFormatKey, problemByDefault)
+		c.Next()
+	}
+}
+
+// Respond writes an error response with status and message.
+func Respond(c *gin.Context, status int, message string) {
+	write(c, status, message, "", nil, nil)
+}
+
+// RespondCode writes an error response with status and message that also
+// carries code, a stable identifier clients can switch on.
+func RespondCode(c *gin.Context, status int, code, message string) {
+	write(c, status, message, code, nil, nil)
+}
+
+// RespondExtensions writes an error response with status, code and message
+// that also carries extensions, further members telling clients how to
+// recover, such as the values a path parameter accepts, in either format.
+// The code may be empty.
+func RespondExtensions(c *gin.Context, status int, code, message string, extensions map[string]any) {
+	write(c, status, message, code, nil, extensions)
+}
+
+// RespondInvalid writes a 400 Bad Request response for a request body that
+// could not be bound or failed validation. Problem details list every
+// failed field in the "errors" extension.
+func RespondInvalid(c *gin.Context, err error) {
+	write(c, http.StatusBadRequest, err.Error(), "", fieldErrors(err), nil)
+}
+
+// RespondFields writes an error response with status, code and message that
+// lists the invalid fields of a request under "errors", in either format. It
+// suits query parameters, which are not checked by the binding validator.
+func RespondFields(c *gin.Context, status int, code, message string, fields []FieldError) {
+	if !wantsProblem(c) {
+		c.JSON(status, gin.H{"error": message, "code": code, "errors": fields})
+		return
+	}
+	write(c, status, message, code, fields, nil)
+}
+
+func write(c *gin.Context, status int, message, code string, fields []FieldError, extensions map[string]any) {
+	if !wantsProblem(c) {
+		body := gin.H{}
+		for name, value := range extensions {
+			body[name] = value
+		}
+		body["error"] = message
+		if code != "" {
+			body["code"] = code
+		}
+		c.JSON(status, body)
+		return
+	}
+
+	c.Render(status, problemRender{Problem{
+		Type:       "about:blank",
+		Title:      http.StatusText(status),
+		Status:     status,
+		Detail:     message,
+		Instance:   c.GetString("request_id"),
+		Code:       code,
+		Errors:     fields,
+		Extensions: extensions,
+	}})
+}
+
+// wantsProblem reports whether the error response to c should be problem
+// details, either because the client accepts them or because Negotiate made
+// them the server default.
+func wantsProblem(c *gin.Context) bool {
+	if strings.Contains(c.GetHeader("Accept"), ProblemContentType) {
+		return true
+	}
+	return c.GetBool(problemFormatKey)
+}
+
+// fieldErrors returns the fields that failed validation in err, or nil if err
+// is not a validation error, for example because the body is not valid JSON.
+func fieldErrors(err error) []FieldError {
+	var invalid validator.ValidationErrors
+	if !errors.As(err, &invalid) {
+		return nil
+	}
+
+	fields := make([]FieldError, 0, len(invalid))
+	for _, fe := range invalid {
+		message := fmt.Sprintf("failed the %q rule", fe.Tag())
+		if fe.Param() != "" {
+			message = fmt.Sprintf("failed the %q rule with %q", fe.Tag(), fe.Param())
+		}
+		fields = append(fields, FieldError{Field: fe.Field(), Message: message})
+	}
+	return fields
+}
+
+// problemRender renders a Problem as JSON with the problem details media type.
+type problemRender struct {
+	problem Problem
+}
+
+func (r problemRender) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	return render.JSON{Data: r.problem}.Render(w)
+}
+
+func (r problemRender) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", ProblemContentType)
+}
@@ -0,0 +1,245 @@
+package apierror
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testInput struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required,min=8"`
+}
+
+func setupTest(problemByDefault bool) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("request_id", "test-request-id")
+	}, Negotiate(problemByDefault))
+	router.GET("/plain", func(c *gin.Context) {
+		Respond(c, http.StatusNotFound, "user not found")
+	})
+	router.GET("/code", func(c *gin.Context) {
+		RespondCode(c, http.StatusForbidden, "REAUTH_REQUIRED", "recent authentication required")
+	})
+	router.GET("/fields", func(c *gin.Context) {
+		RespondFields(c, http.StatusUnprocessableEntity, "INVALID_PAGINATION", "invalid pagination", []FieldError{
+			{Field: "page", Message: "must be at least 1"},
+		})
+	})
+	router.GET("/extensions", func(c *gin.Context) {
+		RespondExtensions(c, http.StatusNotFound, "", "unknown template", map[string]any{
+			"templates": []string{"verification", "welcome"},
+			"status":    "ignored",
+		})
+	})
+	router.POST("/bind", func(c *gin.Context) {
+		var input testInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			RespondInvalid(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+	return router
+}
+
+func TestRespond_JSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		wantCode int
+		wantBody map[string]interface{}
+	}{
+		{
+			name:     "message",
+			path:     "/plain",
+			wantCode: http.StatusNotFound,
+			wantBody: map[string]interface{}{"error": "user not found"},
+		},
+		{
+			name:     "message and code",
+			path:     "/code",
+			wantCode: http.StatusForbidden,
+			wantBody: map[string]interface{}{"error": "recent authentication required", "code": "REAUTH_REQUIRED"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			setupTest(false).ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+			var body map[string]interface{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, tt.wantBody, body)
+		})
+	}
+}
+
+func TestRespond_Problem(t *testing.T) {
+	tests := []struct {
+		name             string
+		problemByDefault bool
+		accept           string
+		path             string
+		wantProblem      Problem
+	}{
+		{
+			name:             "server default",
+			problemByDefault: true,
+			path:             "/plain",
+			wantProblem: Problem{
+				Type: "about:blank", Title: "Not Found", Status: http.StatusNotFound,
+				Detail: "user not found", Instance: "test-request-id",
+			},
+		},
+		{
+			name:   "requested by the client",
+			accept: "application/problem+json, application/json;q=0.5",
+			path:   "/code",
+			wantProblem: Problem{
+				Type: "about:blank", Title: "Forbidden", Status: http.StatusForbidden,
+				Detail: "recent authentication required", Instance: "test-request-id", Code: "REAUTH_REQUIRED",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			setupTest(tt.problemByDefault).ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantProblem.Status, w.Code)
+			assert.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+			var problem Problem
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+			assert.Equal(t, tt.wantProblem, problem)
+		})
+	}
+}
+
+func TestRespondInvalid(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		problem     bool
+		wantFields  []FieldError
+		errContains string
+	}{
+		{
+			name:        "validation errors",
+			body:        `{"email":"not-an-email","password":"short"}`,
+			errContains: "Error:Field validation for 'Email' failed",
+		},
+		{
+			name:    "validation errors as problem details",
+			body:    `{"email":"not-an-email","password":"short"}`,
+			problem: true,
+			wantFields: []FieldError{
+				{Field: "Email", Message: `failed the "email" rule`},
+				{Field: "Password", Message: `failed the "min" rule with "8"`},
+			},
+			errContains: "Error:Field validation for 'Email' failed",
+		},
+		{
+			name:        "malformed body as problem details",
+			body:        `{"email":`,
+			problem:     true,
+			errContains: "unexpected EOF",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/bind", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			setupTest(tt.problem).ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			if !tt.problem {
+				var body map[string]interface{}
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+				assert.Contains(t, body["error"], tt.errContains)
+				assert.NotContains(t, body, "errors")
+				return
+			}
+
+			var problem Problem
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+			assert.Contains(t, problem.Detail, tt.errContains)
+			assert.Equal(t, tt.wantFields, problem.Errors)
+		})
+	}
+}
+
+func TestRespondFields(t *testing.T) {
+	t.Run("JSON", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		setupTest(false).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fields", nil))
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.JSONEq(t, `{
+			"error": "invalid pagination",
+			"code": "INVALID_PAGINATION",
+			"errors": [{"field": "page", "message": "must be at least 1"}]
+		}`, w.Body.String())
+	})
+
+	t.Run("problem details", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		setupTest(true).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fields", nil))
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+		var problem Problem
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+		assert.Equal(t, "INVALID_PAGINATION", problem.Code)
+		assert.Equal(t, "invalid pagination", problem.Detail)
+		assert.Equal(t, []FieldError{{Field: "page", Message: "must be at least 1"}}, problem.Errors)
+	})
+}
+
+func TestRespondExtensions(t *testing.T) {
+	t.Run("JSON", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		setupTest(false).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/extensions", nil))
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.JSONEq(t, `{
+			"error": "unknown template",
+			"status": "ignored",
+			"templates": ["verification", "welcome"]
+		}`, w.Body.String())
+	})
+
+	t.Run("problem details", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		setupTest(true).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/extensions", nil))
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+		assert.JSONEq(t, `{
+			"type": "about:blank",
+			"title": "Not Found",
+			"status": 404,
+			"detail": "unknown template",
+			"instance": "test-request-id",
+			"templates": ["verification", "welcome"]
+		}`, w.Body.String(), "extensions do not override the standard members")
+	})
+}
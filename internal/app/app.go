@@ -0,0 +1,261 @@
+// Package app is the composition root of the server. It builds every
+// component once, from the database connection to the routes, and starts and
+// stops them together, so cmd/api only has to load the configuration.
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/apierror"
+	"github.com/PakornBank/learn-go/internal/config"
+	"github.com/PakornBank/learn-go/internal/database"
+	"github.com/PakornBank/learn-go/internal/errreport"
+	"github.com/PakornBank/learn-go/internal/eventbus"
+	"github.com/PakornBank/learn-go/internal/grpcserver"
+	"github.com/PakornBank/learn-go/internal/jobs"
+	"github.com/PakornBank/learn-go/internal/logger"
+	"github.com/PakornBank/learn-go/internal/middleware"
+	"github.com/PakornBank/learn-go/internal/preflight"
+	"github.com/PakornBank/learn-go/internal/router"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/grpc"
+	"gorm.io/gorm"
+)
+
+// reporterFlushTimeout bounds how long Shutdown waits for reported errors to
+// be sent.
+const reporterFlushTimeout = 2 * time.Second
+
+// App is the server with everything it runs on.
+type App struct {
+	// startedAt is when the App was built, which diagnostics count uptime from.
+	startedAt time.Time
+	config    *config.Config
+	live      *config.Live
+	reporter  errreport.ErrorReporter
+	db        *gorm.DB
+	replica   *gorm.DB
+	deps      router.Dependencies
+	engine    *gin.Engine
+	server    *http.Server
+	grpc      *grpc.Server
+	// preflight is what the startup checks of the dependencies found.
+	preflight preflight.Report
+
+	redis       *redis.Client
+	locker      jobs.Locker
+	bus         *eventbus.NATSPublisher
+	loginEvents *service.LoginEventWriter
+	newDevices  *service.NewDeviceNotifier
+}
+
+// New builds the server configured by cfg, which was loaded from configPath,
+// and makes its logger the default. Once the database is migrated, it checks
+// the dependencies of the server and logs what it found in a startup summary:
+// it fails if a required dependency, such as the database, cannot be used,
+// and runs without the features of the optional ones that cannot, such as
+// Redis or the SMTP server. It then gives the deployment its first admin if
+// ADMIN_EMAIL is set. configPath is where SIGHUP reloads the runtime settings
+// from; it may be empty.
+func New(cfg *config.Config, configPath string) (*App, error) {
+	live := config.NewLive(cfg, configPath)
+	appLogger := logger.New(os.Stdout, live.LogLevel(), cfg.IsProduction())
+	slog.SetDefault(appLogger)
+	slog.Info("configuration loaded", "config", cfg)
+
+	reporter, err := errreport.New(cfg.SentryDSN, cfg.Env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize error reporter: %w", err)
+	}
+
+	db, err := database.NewDataBase(cfg, appLogger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+
+	replica, err := database.NewReplica(cfg, appLogger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+
+	var redisClient *redis.Client
+	if cfg.RedisAddr != "" {
+		redisClient = redis.NewClient(&redis.Options{Addr: cfg.RedisAddr})
+	}
+	report := preflight.Run(context.Background(), preflightChecks(cfg, db, replica, redisClient))
+	report.Log(context.Background(), appLogger, "env", cfg.Env, "port", cfg.ServerPort, "grpc_port", cfg.GRPCPort)
+	if err := report.Err(); err != nil {
+		return nil, fmt.Errorf("preflight checks failed: %w", err)
+	}
+	if report.Failed(preflight.CheckReplica) {
+		if sqlDB, err := replica.DB(); err == nil {
+			sqlDB.Close()
+		}
+		replica = nil
+	}
+	if report.Failed(preflight.CheckRedis) {
+		redisClient.Close()
+		redisClient = nil
+	}
+
+	a := newApp(cfg, live, reporter, db, replica, redisClient, report)
+	if err := a.bootstrapAdmin(context.Background()); err != nil {
+		return nil, fmt.Errorf("failed to bootstrap admin: %w", err)
+	}
+	return a, nil
+}
+
+// bootstrapAdmin makes the account at ADMIN_EMAIL an admin, creating it if
+// need be, while the deployment has no admin; see AdminBootstrapService.
+// Nothing is done without ADMIN_EMAIL.
+func (a *App) bootstrapAdmin(ctx context.Context) error {
+	if a.config.AdminEmail == "" {
+		return nil
+	}
+	bootstrap := service.NewAdminBootstrapService(a.deps.Users, a.deps.AuthService)
+	_, err := bootstrap.Bootstrap(ctx, a.config.AdminEmail, a.config.AdminPassword)
+	return err
+}
+
+// newApp builds the server on an open database and, if they are not nil, its
+// read replica and Redis, with the features of the dependencies that failed
+// their checks in report disabled.
+func newApp(cfg *config.Config, live *config.Live, reporter errreport.ErrorReporter, db, replica *gorm.DB, redisClient *redis.Client, report preflight.Report) *App {
+	a := &App{
+		startedAt: time.Now(),
+		config:    cfg,
+		live:      live,
+		reporter:  reporter,
+		db:        db,
+		replica:   replica,
+		preflight: report,
+		redis:     redisClient,
+	}
+	a.wire()
+
+	if cfg.IsProduction() {
+		gin.SetMode(gin.ReleaseMode)
+	}
+	a.engine = gin.New()
+	// Only the proxies in TRUSTED_PROXIES, which Validate checked, may set the
+	// client IP with X-Forwarded-For; by default it is the peer's address.
+	_ = a.engine.SetTrustedProxies(cfg.TrustedProxies)
+	a.engine.Use(
+		middleware.AccessLog(gin.DefaultWriter),
+		middleware.RequestID(),
+		apierror.Negotiate(cfg.ProblemDetails()),
+		middleware.Recovery(reporter),
+		middleware.ReportErrors(reporter),
+	)
+	router.NewRouter(a.engine, a.deps).SetupRoutes()
+	a.server = &http.Server{Addr: ":" + cfg.ServerPort, Handler: a.engine}
+
+	if cfg.GRPCPort != "" {
+		a.grpc = grpcserver.NewGRPCServer(grpcserver.NewServer(a.deps.AuthService),
+			grpc.UnaryInterceptor(grpcserver.RequireServiceSecret(cfg.IntrospectionSecret)),
+		)
+	}
+	return a
+}
+
+// Handler returns the HTTP handler serving the API, for serving it without
+// Run, as tests do.
+func (a *App) Handler() http.Handler {
+	return a.engine
+}
+
+// Run starts the background jobs and serves HTTP, and gRPC when GRPC_PORT is
+// set, until ctx is done or a server fails. It returns the failure, or nil
+// once ctx is done; either way Shutdown must be called afterwards. Until ctx
+// is done, SIGHUP reloads the runtime settings.
+func (a *App) Run(ctx context.Context) error {
+	a.deps.Jobs.Start(context.Background())
+	go a.live.ReloadOnSignal(ctx, syscall.SIGHUP)
+
+	serveErr := make(chan error, 2)
+	if a.grpc != nil {
+		lis, err := net.Listen("tcp", ":"+a.config.GRPCPort)
+		if err != nil {
+			return fmt.Errorf("failed to listen for gRPC: %w", err)
+		}
+		go func() {
+			log.Printf("gRPC server running on port %s\n", a.config.GRPCPort)
+			if err := a.grpc.Serve(lis); err != nil {
+				serveErr <- fmt.Errorf("gRPC server: %w", err)
+			}
+		}()
+	}
+	go func() {
+		log.Printf("Server running on port %s\n", a.config.ServerPort)
+		if err := a.server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			serveErr <- err
+		}
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+// Shutdown drains the server: from then on every request but the metrics is
+// answered with a 503 and the code SHUTTING_DOWN, while the requests in
+// flight get until ctx is done to finish. It then stops the background jobs,
+// flushes background work such as queued login events, and closes the
+// connections the server holds. It returns the error of draining HTTP.
+func (a *App) Shutdown(ctx context.Context) error {
+	a.deps.Drain.Start()
+	err := a.server.Shutdown(ctx)
+	if a.grpc != nil {
+		a.stopGRPC(ctx)
+	}
+
+	a.deps.Jobs.Stop()
+	if a.bus != nil {
+		a.bus.Close()
+	}
+	a.loginEvents.Close()
+	a.newDevices.Close()
+	a.deps.Audit.Close()
+	if a.redis != nil {
+		a.redis.Close()
+	}
+	a.reporter.Flush(reporterFlushTimeout)
+	if sqlDB, dbErr := a.db.DB(); dbErr == nil {
+		sqlDB.Close()
+	}
+	if a.replica != nil {
+		if sqlDB, dbErr := a.replica.DB(); dbErr == nil {
+			sqlDB.Close()
+		}
+	}
+	return err
+}
+
+// stopGRPC lets the gRPC calls in flight finish until ctx is done, then
+// cancels the rest.
+func (a *App) stopGRPC(ctx context.Context) {
+	stopped := make(chan struct{})
+	go func() {
+		a.grpc.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		a.grpc.Stop()
+	}
+}
@@ -0,0 +1,194 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/config"
+	"github.com/PakornBank/learn-go/internal/errreport"
+	"github.com/PakornBank/learn-go/internal/preflight"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/gin-gonic/gin"
+	promtest "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestApp(t *testing.T) *App {
+	t.Helper()
+	return newTestAppWithPreflight(t, preflight.Report{})
+}
+
+// newTestAppWithPreflight returns an App built as if the startup checks of
+// its dependencies had found report.
+func newTestAppWithPreflight(t *testing.T, report preflight.Report) *App {
+	t.Helper()
+	return newTestAppIn(t, config.EnvTest, report)
+}
+
+// newTestAppIn returns an App built for the environment env as if the
+// startup checks of its dependencies had found report.
+func newTestAppIn(t *testing.T, env string, report preflight.Report) *App {
+	t.Helper()
+	t.Setenv("APP_ENV", env)
+	t.Setenv("JWT_SECRET", "test-secret-that-is-long-enough-for-validation")
+	t.Setenv("DATABASE_URL", "postgres://localhost/test")
+	t.Setenv("AVATAR_DIR", t.TempDir())
+	cfg, err := config.LoadConfig()
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	_, db, _ := testutil.DbMock(t)
+	return newApp(cfg, config.NewLive(cfg, ""), errreport.Noop{}, db, nil, nil, report)
+}
+
+func TestApp_ServesUntilShutdown(t *testing.T) {
+	a := newTestApp(t)
+
+	w := httptest.NewRecorder()
+	a.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status":"ok"}`, w.Body.String())
+
+	require.NoError(t, a.Shutdown(context.Background()))
+
+	w = httptest.NewRecorder()
+	a.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.JSONEq(t, `{"code":"SHUTTING_DOWN","error":"server is shutting down, try again later"}`, w.Body.String())
+}
+
+func TestApp_SharesDependencies(t *testing.T) {
+	a := newTestApp(t)
+
+	assert.NotNil(t, a.deps.AuthService)
+	assert.NotNil(t, a.deps.Users)
+	assert.Nil(t, a.grpc, "gRPC is served only when GRPC_PORT is set")
+	assert.Len(t, a.deps.Jobs.Statuses(), 6, "account-purge, email-queue, role-grant-expiry, user-count, signup-anomaly and outbox")
+	require.NoError(t, a.Shutdown(context.Background()))
+}
+
+func TestApp_RegistersDiagnostics(t *testing.T) {
+	a := newTestApp(t)
+
+	report := a.deps.Diagnostics.Report(context.Background())
+
+	for _, name := range []string{"database", "user_cache", "jobs", "outbox", "email_queue"} {
+		if assert.Contains(t, report.Subsystems, name) {
+			assert.Equal(t, service.DiagnosticOK, report.Subsystems[name].Status, name)
+		}
+	}
+	assert.Equal(t, databaseDiagnostics{OpenConnections: 1, Idle: 1}, report.Subsystems["database"].Details)
+	assert.Equal(t, backlogDiagnostics{}, report.Subsystems["outbox"].Details, "nothing polled yet")
+	assert.Len(t, report.Subsystems["jobs"].Details, 6)
+	require.NoError(t, a.Shutdown(context.Background()))
+}
+
+func TestApp_RunsWithoutDegradedDependencies(t *testing.T) {
+	a := newTestAppWithPreflight(t, preflight.Report{Results: []preflight.Result{
+		{Name: preflight.CheckDatabase, Required: true},
+		{Name: preflight.CheckSMTP, Err: errors.New("connection refused")},
+	}})
+
+	w := httptest.NewRecorder()
+	a.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status":"degraded","degraded":["smtp"]}`, w.Body.String())
+
+	for _, status := range a.deps.Jobs.Statuses() {
+		assert.NotEqual(t, "email-queue", status.Name, "emails stay queued without SMTP")
+	}
+	require.NoError(t, a.Shutdown(context.Background()))
+}
+
+func TestApp_ServesDevRoutesOnlyInDevelopment(t *testing.T) {
+	for env, expectedCode := range map[string]int{
+		config.EnvDevelopment: http.StatusOK,
+		config.EnvTest:        http.StatusNotFound,
+	} {
+		t.Run(env, func(t *testing.T) {
+			a := newTestAppIn(t, env, preflight.Report{})
+
+			w := httptest.NewRecorder()
+			a.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/dev/emails/verification", nil))
+			assert.Equal(t, expectedCode, w.Code)
+			require.NoError(t, a.Shutdown(context.Background()))
+		})
+	}
+}
+
+func TestApp_TrustsOnlyConfiguredProxies(t *testing.T) {
+	tests := []struct {
+		name    string
+		proxies string
+		wantIP  string
+	}{
+		{name: "no proxies by default", wantIP: "192.0.2.1"},
+		{name: "trusted proxy", proxies: "192.0.2.0/24", wantIP: "203.0.113.7"},
+		{name: "other proxy", proxies: "10.0.0.0/8", wantIP: "192.0.2.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TRUSTED_PROXIES", tt.proxies)
+			a := newTestApp(t)
+			var clientIP string
+			a.engine.GET("/client-ip", func(c *gin.Context) {
+				clientIP = c.ClientIP()
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/client-ip", nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			req.Header.Set("X-Forwarded-For", "203.0.113.7")
+			a.Handler().ServeHTTP(httptest.NewRecorder(), req)
+
+			assert.Equal(t, tt.wantIP, clientIP)
+			require.NoError(t, a.Shutdown(context.Background()))
+		})
+	}
+}
+
+func TestApp_DoesNotLimitEventStreams(t *testing.T) {
+	t.Setenv("CONCURRENCY_LIMIT", "1")
+	a := newTestApp(t)
+	release := make(chan struct{})
+	a.engine.GET("/busy", func(c *gin.Context) {
+		<-release
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		a.Handler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/busy", nil))
+	}()
+	require.Eventually(t, a.deps.Concurrency.Saturated, time.Second, time.Millisecond)
+
+	for path, expectedCode := range map[string]int{
+		"/api/auth/events":     http.StatusUnauthorized,
+		"/api/auth/events/sse": http.StatusUnauthorized,
+		"/api/auth/profile":    http.StatusServiceUnavailable,
+	} {
+		w := httptest.NewRecorder()
+		a.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, expectedCode, w.Code, path)
+	}
+
+	close(release)
+	<-done
+	require.NoError(t, a.Shutdown(context.Background()))
+}
+
+func TestNewWeakSecretGauge(t *testing.T) {
+	for secret, want := range map[string]float64{
+		"s3cr3t!!": 1,
+		"test-secret-that-is-long-enough-for-validation": 0,
+	} {
+		gauge := newWeakSecretGauge(&config.Config{JWTSecret: secret})
+		assert.Equal(t, want, promtest.ToFloat64(gauge), secret)
+	}
+}
@@ -0,0 +1,95 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+)
+
+// databaseDiagnostics is the state of the connection pool.
+type databaseDiagnostics struct {
+	OpenConnections    int   `json:"open_connections"`
+	InUse              int   `json:"in_use"`
+	Idle               int   `json:"idle"`
+	MaxOpenConnections int   `json:"max_open_connections"`
+	WaitCount          int64 `json:"wait_count"`
+	WaitDurationMS     int64 `json:"wait_duration_ms"`
+}
+
+// userCacheDiagnostics counts the lookups the user cache served.
+type userCacheDiagnostics struct {
+	repository.UserCacheStats
+	HitRatio float64 `json:"hit_ratio"`
+}
+
+// jobDiagnostics is when a background job last ran, and how.
+type jobDiagnostics struct {
+	Running   bool       `json:"running"`
+	LastRunAt *time.Time `json:"last_run_at"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+// backlogDiagnostics is what the outbox poller or the email worker left to
+// process at the end of its last run. LastRunAt is null until a run fetched.
+type backlogDiagnostics struct {
+	Pending   int        `json:"pending"`
+	More      bool       `json:"more"`
+	LastRunAt *time.Time `json:"last_run_at"`
+}
+
+// registerDiagnostics registers a provider for each subsystem with
+// a.deps.Diagnostics. Every provider reads what its subsystem holds in
+// memory, so the report never waits on the database.
+func (a *App) registerDiagnostics() {
+	deps := a.deps
+	deps.Diagnostics.Register("database", service.DiagnosticFunc(func(context.Context) (any, error) {
+		sqlDB, err := a.db.DB()
+		if err != nil {
+			return nil, err
+		}
+		stats := sqlDB.Stats()
+		return databaseDiagnostics{
+			OpenConnections:    stats.OpenConnections,
+			InUse:              stats.InUse,
+			Idle:               stats.Idle,
+			MaxOpenConnections: stats.MaxOpenConnections,
+			WaitCount:          stats.WaitCount,
+			WaitDurationMS:     stats.WaitDuration.Milliseconds(),
+		}, nil
+	}))
+	if cached, ok := deps.Users.(*repository.CachedUserRepository); ok {
+		deps.Diagnostics.Register("user_cache", service.DiagnosticFunc(func(context.Context) (any, error) {
+			stats := cached.Stats()
+			return userCacheDiagnostics{UserCacheStats: stats, HitRatio: stats.HitRatio()}, nil
+		}))
+	}
+	deps.Diagnostics.Register("jobs", service.DiagnosticFunc(func(context.Context) (any, error) {
+		statuses := deps.Jobs.Statuses()
+		jobs := make(map[string]jobDiagnostics, len(statuses))
+		for _, status := range statuses {
+			job := jobDiagnostics{Running: status.Running, LastError: status.LastError}
+			if !status.LastRun.IsZero() {
+				lastRun := status.LastRun
+				job.LastRunAt = &lastRun
+			}
+			jobs[status.Name] = job
+		}
+		return jobs, nil
+	}))
+	deps.Diagnostics.Register("outbox", service.DiagnosticFunc(func(context.Context) (any, error) {
+		backlog, ok := deps.Outbox.Backlog()
+		if !ok {
+			return backlogDiagnostics{}, nil
+		}
+		return backlogDiagnostics{Pending: backlog.Pending, More: backlog.More, LastRunAt: &backlog.PolledAt}, nil
+	}))
+	deps.Diagnostics.Register("email_queue", service.DiagnosticFunc(func(context.Context) (any, error) {
+		backlog, ok := deps.EmailWorker.Backlog()
+		if !ok {
+			return backlogDiagnostics{}, nil
+		}
+		return backlogDiagnostics{Pending: backlog.Pending, More: backlog.More, LastRunAt: &backlog.RanAt}, nil
+	}))
+}
@@ -0,0 +1,57 @@
+package app
+
+import (
+	"context"
+	"net"
+
+	"github.com/PakornBank/learn-go/internal/config"
+	"github.com/PakornBank/learn-go/internal/database"
+	"github.com/PakornBank/learn-go/internal/preflight"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// preflightChecks returns the checks of the dependencies configured by cfg
+// that the server runs on: the database db, its replica if not nil, the JWT
+// secret, Redis if redisClient is not nil and the SMTP server if SMTP_HOST
+// is set.
+func preflightChecks(cfg *config.Config, db, replica *gorm.DB, redisClient *redis.Client) []preflight.Check {
+	checks := []preflight.Check{
+		preflight.Database(gormPinger{db}),
+		preflight.Migrated(gormSchema{db}, database.Models()...),
+		preflight.JWTSecret(cfg.JWTSecret),
+	}
+	if replica != nil {
+		checks = append(checks, preflight.Replica(gormPinger{replica}))
+	}
+	if redisClient != nil {
+		checks = append(checks, preflight.Redis(redisClient))
+	}
+	if cfg.SMTPHost != "" {
+		var dialer net.Dialer
+		checks = append(checks, preflight.SMTP(net.JoinHostPort(cfg.SMTPHost, cfg.SMTPPort), dialer.DialContext))
+	}
+	return checks
+}
+
+// gormPinger is a preflight.Pinger reaching the database of a gorm.DB.
+type gormPinger struct {
+	db *gorm.DB
+}
+
+func (p gormPinger) PingContext(ctx context.Context) error {
+	sqlDB, err := p.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// gormSchema is the preflight.Schema of the database of a gorm.DB.
+type gormSchema struct {
+	db *gorm.DB
+}
+
+func (s gormSchema) HasTable(ctx context.Context, model any) bool {
+	return s.db.WithContext(ctx).Migrator().HasTable(model)
+}
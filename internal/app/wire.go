@@ -0,0 +1,295 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/cache"
+	"github.com/PakornBank/learn-go/internal/config"
+	"github.com/PakornBank/learn-go/internal/database"
+	"github.com/PakornBank/learn-go/internal/disposable"
+	"github.com/PakornBank/learn-go/internal/emailqueue"
+	"github.com/PakornBank/learn-go/internal/eventbus"
+	"github.com/PakornBank/learn-go/internal/events"
+	"github.com/PakornBank/learn-go/internal/featureflag"
+	"github.com/PakornBank/learn-go/internal/handler"
+	"github.com/PakornBank/learn-go/internal/hibp"
+	"github.com/PakornBank/learn-go/internal/jobs"
+	"github.com/PakornBank/learn-go/internal/mailer"
+	"github.com/PakornBank/learn-go/internal/middleware"
+	"github.com/PakornBank/learn-go/internal/outbox"
+	"github.com/PakornBank/learn-go/internal/password"
+	"github.com/PakornBank/learn-go/internal/preflight"
+	"github.com/PakornBank/learn-go/internal/ratelimit"
+	"github.com/PakornBank/learn-go/internal/redact"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/router"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/PakornBank/learn-go/internal/storage"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// wire builds the repositories and services behind the routes into a.deps,
+// and registers the background jobs they run.
+func (a *App) wire() {
+	cfg, db := a.config, a.db
+	handler.InitialsAvatars = cfg.AvatarFallback == config.AvatarFallbackInitials
+	deps := &a.deps
+	*deps = router.Dependencies{
+		Config:        cfg,
+		Clock:         service.SystemClock{},
+		Live:          a.live,
+		LoginEvents:   repository.NewLoginEventRepository(db, cfg.DBQueryTimeout),
+		RefreshTokens: repository.NewRefreshTokenRepository(db, cfg.DBQueryTimeout),
+		LoginAlerts:   repository.NewLoginAlertRepository(db, cfg.DBQueryTimeout),
+		EmailChanges:  repository.NewEmailChangeRepository(db, cfg.DBQueryTimeout),
+		AuditLog:      repository.NewAuditRepository(db, cfg.DBQueryTimeout),
+		EmailQueue:    repository.NewEmailQueueRepository(db, cfg.DBQueryTimeout),
+		Recoveries:    repository.NewRecoveryRepository(db, cfg.DBQueryTimeout),
+		Announcements: repository.NewAnnouncementRepository(db, cfg.DBQueryTimeout),
+		SecurityLog:   repository.NewSecurityEventRepository(db, cfg.DBQueryTimeout),
+		Identities:    repository.NewIdentityRepository(db, cfg.DBQueryTimeout),
+		Emails:        mailer.NewTemplates(cfg.AppBaseURL),
+		Maintenance:   &middleware.MaintenanceMode{},
+		Drain:         &middleware.DrainMode{},
+		Unavailable: middleware.NewUnavailable(map[string]time.Duration{
+			middleware.CauseShuttingDown: cfg.RetryAfterShuttingDown,
+			middleware.CauseMaintenance:  cfg.RetryAfterMaintenance,
+			middleware.CauseOverloaded:   cfg.RetryAfterOverloaded,
+		}),
+		Events:      events.NewHub(events.DefaultBufferSize, events.DefaultReplaySize),
+		Jobs:        jobs.NewScheduler(),
+		Metrics:     prometheus.NewRegistry(),
+		Passwords:   password.NewPool(bcrypt.DefaultCost, cfg.PasswordHashWorkers),
+		Diagnostics: service.NewDiagnosticsService(a.startedAt),
+		Preflight:   a.preflight,
+	}
+	deps.Metrics.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		deps.Passwords,
+		newWeakSecretGauge(cfg),
+	)
+	// The registry is new, so registering the metrics of the limiter, of
+	// the claims compatibility window and of the algorithm pin cannot clash.
+	deps.Concurrency, _ = middleware.NewConcurrencyLimiter(cfg.ConcurrencyLimit, cfg.ConcurrencyRouteLimits, cfg.ConcurrencyQueueTimeout, deps.Metrics)
+	deps.ClaimsCompat, _ = middleware.NewClaimsCompat(cfg.LegacyClaimsCutoff, deps.Metrics)
+	deps.AlgorithmPin, _ = middleware.NewAlgorithmPin(cfg.JWTAlgorithm, deps.Metrics)
+	a.loginEvents = service.NewLoginEventWriter(deps.LoginEvents, service.DefaultLoginEventBuffer)
+	deps.Audit = service.NewAuditWriter(deps.AuditLog, service.DefaultAuditBuffer, service.DefaultAuditEnqueueTimeout)
+
+	// Services queue their emails, which a background job then sends.
+	deps.Mailer = emailqueue.NewQueue(deps.EmailQueue)
+	deps.EmailWorker = emailqueue.NewWorker(deps.EmailQueue, mailer.New(mailer.SMTPConfig{
+		Host:     cfg.SMTPHost,
+		Port:     cfg.SMTPPort,
+		User:     cfg.SMTPUser,
+		Password: cfg.SMTPPassword,
+		From:     cfg.SMTPFrom,
+	}, slog.Default()), cfg.EmailMaxAttempts, cfg.EmailRetryBackoff)
+	if sqlDB, err := db.DB(); err != nil {
+		slog.Error("failed to get the database connection pool, background jobs run on every replica", "error", err)
+	} else {
+		a.locker = jobs.NewAdvisoryLocker(sqlDB)
+	}
+	deps.Users = a.newUserRepository()
+	deps.RateLimiter = a.newRateLimiter()
+	blocklist, err := disposable.New(cfg.DisposableDomainsFile)
+	if err != nil {
+		slog.Error("failed to load extra disposable email domains, using the built-in list", "path", cfg.DisposableDomainsFile, "error", err)
+	}
+	deps.Blocklist = blocklist
+	flags, err := featureflag.Load(cfg.FeatureFlagsFile)
+	if err != nil {
+		slog.Error("failed to load feature flags, turning every flag off", "path", cfg.FeatureFlagsFile, "error", err)
+		flags, _ = featureflag.NewStatic(nil)
+	}
+	deps.Flags = flags
+	// AUDIT_REDACT_FIELDS was checked by Validate.
+	deps.Redactor, _ = redact.New(cfg.AuditRedactFields)
+
+	a.newDevices = service.NewNewDeviceNotifier(
+		deps.RefreshTokens,
+		deps.LoginAlerts,
+		deps.Mailer,
+		deps.Emails,
+		service.DefaultNewDeviceBuffer,
+		deps.Clock,
+	)
+	authOpts := []service.AuthOption{
+		service.WithClock(deps.Clock),
+		service.WithPasswordHasher(deps.Passwords),
+		service.WithLoginRecorder(a.loginEvents),
+		service.WithLoginNotifier(a.newDevices),
+		service.WithEmailBlocklist(deps.Blocklist),
+		service.WithRegistrationSwitch(func() bool { return a.live.Dynamic().RegistrationEnabled }),
+		service.WithEmailDomainAllowlist(func() []string { return a.live.Dynamic().RegistrationEmailDomains }),
+		service.WithEventPublisher(deps.Events),
+	}
+	if cfg.DBLoginReadsPrimary {
+		authOpts = append(authOpts, service.WithPrimaryLoginReads())
+	}
+	if cfg.BreachCheckEnabled {
+		authOpts = append(authOpts, service.WithBreachChecker(hibp.NewClient(cfg.BreachCheckTimeout), cfg.BreachCheckMaxCount))
+	}
+	deps.AuthService = service.NewAuthService(deps.Users, deps.RefreshTokens, cfg, authOpts...)
+	deps.Metrics.MustRegister(deps.AuthService)
+	deps.Recovery = service.NewRecoveryService(deps.Users, deps.Recoveries, deps.Mailer, deps.Emails, deps.AuthService, deps.Clock)
+	deps.Avatars = storage.NewLocal(cfg.AvatarDir, cfg.AvatarRoute)
+	deps.Deletion = service.NewAccountDeletionService(deps.Users, deps.RefreshTokens, deps.Avatars, cfg.AccountDeletionGrace)
+	a.registerJob("account-purge", cfg.AccountPurgeInterval, deps.Deletion.RunPurge)
+	// Emails stay queued while the SMTP server that failed its check at
+	// startup is not retried, rather than using up their attempts.
+	if !a.preflight.Failed(preflight.CheckSMTP) {
+		a.registerJob("email-queue", cfg.EmailQueueInterval, deps.EmailWorker.Run)
+	}
+	deps.RoleGrants = service.NewRoleGrantService(repository.NewRoleGrantRepository(db, cfg.DBQueryTimeout), deps.Users, deps.Audit, deps.Clock)
+	a.registerJob("role-grant-expiry", cfg.RoleGrantExpiryInterval, deps.RoleGrants.ExpireDue)
+	deps.UserStats = service.NewUserStatsService(deps.Users)
+	deps.Imports = service.NewUserImportService(deps.Users, deps.Mailer, deps.Emails, deps.AuthService, cfg.JWTSecret)
+	// Every replica exposes the metric, so every replica refreshes it.
+	deps.Jobs.Register("user-count", cfg.UserCountInterval, a.newUserCountJob())
+	if cfg.SignupAnomalyMultiplier > 0 {
+		detector := service.NewSignupAnomalyDetector(deps.Users, a.newAnomalyNotifier(), cfg.SignupAnomalyMultiplier)
+		a.registerJob("signup-anomaly", service.SignupWindow, detector.Run)
+	}
+	deps.Outbox = outbox.NewPoller(repository.NewOutboxRepository(db, cfg.DBQueryTimeout), a.newOutboxSink(), cfg.OutboxPollInterval, cfg.OutboxRetention, cfg.OutboxMaxAttempts, cfg.OutboxRetryBackoff)
+	a.registerJob("outbox", deps.Outbox.Interval(), deps.Outbox.Run)
+	a.registerDiagnostics()
+}
+
+// registerJob adds a background job called name that runs fn every interval
+// on one replica at a time.
+func (a *App) registerJob(name string, interval time.Duration, fn jobs.Func) {
+	if a.locker != nil {
+		fn = jobs.Singleton(a.locker, name, fn)
+	}
+	a.deps.Jobs.Register(name, interval, fn)
+}
+
+// newRateLimiter returns the rate limiter selected by RATE_LIMIT_STORE, with
+// the current limits of a.live. Limits are counted in memory when Redis
+// failed its check at startup.
+func (a *App) newRateLimiter() middleware.RateLimiter {
+	limits := func() (int, time.Duration) {
+		dynamic := a.live.Dynamic()
+		return dynamic.RateLimitRequests, dynamic.RateLimitWindow
+	}
+	if a.config.RateLimitStore == config.RateLimitStoreRedis && a.redis != nil {
+		return ratelimit.NewRedis(a.redis, limits)
+	}
+	return ratelimit.NewMemory(limits)
+}
+
+// newUserCountJob registers the users_total metric and returns a job that
+// sets it to the number of users.
+func (a *App) newUserCountJob() jobs.Func {
+	total := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "users_total",
+		Help: "Registered users.",
+	})
+	a.deps.Metrics.MustRegister(total)
+
+	return func(ctx context.Context) error {
+		count, err := a.deps.UserStats.TotalUsers(ctx)
+		if err != nil {
+			return err
+		}
+		total.Set(float64(count))
+		return nil
+	}
+}
+
+// newWeakSecretGauge returns the config_weak_secret metric, which is 1 if
+// the JWT_SECRET of cfg is weak, see config.Config.JWTSecretWeakness, and 0
+// otherwise, so that weak secrets can be alerted on.
+func newWeakSecretGauge(cfg *config.Config) prometheus.Gauge {
+	weak := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "config_weak_secret",
+		Help: "Whether JWT_SECRET is shorter than 32 bytes or too predictable.",
+	})
+	if cfg.JWTSecretWeakness() != "" {
+		weak.Set(1)
+	}
+	return weak
+}
+
+// newAnomalyNotifier returns where signup anomalies are reported: the
+// webhook at SIGNUP_ANOMALY_WEBHOOK_URL, else the log.
+func (a *App) newAnomalyNotifier() service.AnomalyNotifier {
+	if a.config.SignupAnomalyWebhookURL != "" {
+		return service.NewWebhookAnomalyNotifier(a.config.SignupAnomalyWebhookURL)
+	}
+	return service.NewLogAnomalyNotifier(slog.Default())
+}
+
+// newOutboxSink returns the destination of outbox events: NATS when NATS_URL
+// is configured, else the webhook at OUTBOX_WEBHOOK_URL, else the log. If NATS
+// cannot be set up, events stay in the outbox until it can.
+func (a *App) newOutboxSink() outbox.Sink {
+	switch {
+	case a.config.NATSURL != "":
+		bus, err := eventbus.NewNATSPublisher(a.config.NATSURL)
+		if err != nil {
+			slog.Error("failed to set up NATS, outbox events are kept until a restart fixes it", "error", err)
+			return outbox.NewBusSink(unavailableBus{err: err})
+		}
+		a.bus = bus
+		return outbox.NewBusSink(bus)
+	case a.config.OutboxWebhookURL != "":
+		return outbox.NewWebhookSink(a.config.OutboxWebhookURL)
+	default:
+		return outbox.NewLogSink(slog.Default())
+	}
+}
+
+// unavailableBus is an eventbus.EventPublisher that fails every publish with err.
+type unavailableBus struct {
+	err error
+}
+
+func (b unavailableBus) Publish(context.Context, eventbus.Message) error {
+	return b.err
+}
+
+// newUserRepository returns the user repository, cached in Redis when
+// REDIS_ADDR is configured and otherwise in process unless USER_CACHE_SIZE is
+// 0. Every service shares it, so a write through any of them invalidates what
+// the others would read. With stale-while-revalidate, users are kept for
+// USER_CACHE_MAX_STALENESS rather than for their TTL. Read-only queries run
+// on the DB_REPLICA_URL replica when one is configured.
+//
+// The in-process cache is only safe with a single replica: a write
+// invalidates the entry on the replica that made it alone, so the others keep
+// serving the old user, including the token version that revokes tokens,
+// until it expires. Deployments with several replicas must set REDIS_ADDR or
+// USER_CACHE_SIZE=0.
+func (a *App) newUserRepository() router.UserRepository {
+	resolver := database.NewResolver(a.db, a.replica)
+	repo := repository.NewUserRepository(a.db, a.config.DBQueryTimeout, repository.WithReadResolver(resolver))
+	ttl := a.config.UserCacheTTLInUse()
+	retention := ttl
+	var opts []repository.CachedUserOption
+	if a.config.UserCacheMode == config.UserCacheModeStaleWhileRevalidate {
+		retention = a.config.UserCacheMaxStaleness
+		opts = append(opts, repository.WithStaleWhileRevalidate(ttl, retention))
+	}
+
+	var c repository.UserCache
+	switch {
+	case a.redis != nil:
+		c = repository.NewEncodedUserCache(cache.NewRedis(a.redis), retention)
+	case a.config.UserCacheSize > 0:
+		slog.Warn("caching users in process, which is only safe with a single replica; set REDIS_ADDR or USER_CACHE_SIZE=0 when running several",
+			"size", a.config.UserCacheSize, "retention", retention)
+		c = repository.NewLRUUserCache(a.config.UserCacheSize, retention)
+	default:
+		return repo
+	}
+	cached := repository.NewCachedUserRepository(repo, c, opts...)
+	a.deps.Metrics.MustRegister(cached)
+	return cached
+}
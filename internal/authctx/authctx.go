@@ -0,0 +1,73 @@
+// Package authctx carries the identity of the authenticated user through a
+// request, from the authentication middleware that reads it from the access
+// token to the handlers and services that act on it.
+package authctx
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Identity is who a request was authenticated as.
+type Identity struct {
+	UserID uuid.UUID
+	Email  string
+	Role   string
+	// Scopes are the scopes the access token grants.
+	Scopes []string
+	// AuthTime is when the user last entered their password, or the zero
+	// time if the token does not prove a recent password entry.
+	AuthTime time.Time
+	// ActorID is the admin acting as the user with an impersonation token,
+	// or uuid.Nil if the user is acting themselves.
+	ActorID uuid.UUID
+	// ExpiresAt is when the access token expires.
+	ExpiresAt time.Time
+	// TokenVersion is the user's token version when the access token was
+	// issued. Signing out everywhere increments it, revoking older tokens.
+	TokenVersion int
+}
+
+// Impersonated reports whether an admin is acting as the user.
+func (id Identity) Impersonated() bool {
+	return id.ActorID != uuid.Nil
+}
+
+// HasScope reports whether the access token grants scope.
+func (id Identity) HasScope(scope string) bool {
+	for _, granted := range id.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type userKey struct{}
+
+// WithUser returns a copy of ctx carrying id.
+func WithUser(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, userKey{}, id)
+}
+
+// UserFrom returns the Identity carried by ctx, reporting false if the
+// request was not authenticated.
+func UserFrom(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(userKey{}).(Identity)
+	return id, ok
+}
+
+// SetUser makes id the identity of the request of c, where User and, through
+// the request context, UserFrom find it.
+func SetUser(c *gin.Context, id Identity) {
+	c.Request = c.Request.WithContext(WithUser(c.Request.Context(), id))
+}
+
+// User returns the identity of the request of c, reporting false if the
+// request was not authenticated.
+func User(c *gin.Context) (Identity, bool) {
+	return UserFrom(c.Request.Context())
+}
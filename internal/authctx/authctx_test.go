@@ -0,0 +1,52 @@
+package authctx
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserFrom(t *testing.T) {
+	identity := Identity{UserID: uuid.New(), Email: "test@example.com", Role: "user", Scopes: []string{"profile:read"}}
+
+	_, ok := UserFrom(context.Background())
+	assert.False(t, ok, "an unauthenticated context carries no identity")
+
+	got, ok := UserFrom(WithUser(context.Background(), identity))
+	assert.True(t, ok)
+	assert.Equal(t, identity, got)
+}
+
+func TestSetUser(t *testing.T) {
+	identity := Identity{UserID: uuid.New(), Email: "test@example.com"}
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+
+	_, ok := User(c)
+	assert.False(t, ok)
+
+	SetUser(c, identity)
+
+	got, ok := User(c)
+	assert.True(t, ok)
+	assert.Equal(t, identity, got)
+	got, ok = UserFrom(c.Request.Context())
+	assert.True(t, ok, "services called with the request context see the identity")
+	assert.Equal(t, identity, got)
+}
+
+func TestIdentity(t *testing.T) {
+	user := Identity{UserID: uuid.New(), Scopes: []string{"profile:read", "profile:write"}}
+
+	assert.True(t, user.HasScope("profile:write"))
+	assert.False(t, user.HasScope("users:admin"))
+	assert.False(t, user.Impersonated())
+
+	user.ActorID = uuid.New()
+	assert.True(t, user.Impersonated())
+}
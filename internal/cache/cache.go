@@ -0,0 +1,21 @@
+// Package cache provides a small key-value cache abstraction with Redis and
+// in-memory implementations.
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Cache stores byte values under string keys with a time to live.
+type Cache interface {
+	// Get returns the value stored under key. The boolean is false if the key
+	// does not exist or has expired.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+
+	// Set stores value under key for ttl.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+
+	// Delete removes the given keys. Missing keys are ignored.
+	Delete(ctx context.Context, keys ...string) error
+}
@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemory_SetGetDelete(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+
+	_, ok, err := m.Get(ctx, "missing")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, m.Set(ctx, "key", []byte("value"), time.Minute))
+	got, ok, err := m.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("value"), got)
+
+	require.NoError(t, m.Delete(ctx, "key", "missing"))
+	_, ok, err = m.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestMemory_Expiry(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	m := NewMemory()
+	m.now = func() time.Time { return now }
+
+	require.NoError(t, m.Set(ctx, "key", []byte("value"), time.Minute))
+
+	now = now.Add(59 * time.Second)
+	_, ok, _ := m.Get(ctx, "key")
+	assert.True(t, ok)
+
+	now = now.Add(time.Second)
+	_, ok, _ = m.Get(ctx, "key")
+	assert.False(t, ok)
+}
+
+func TestMemory_ValuesAreCopied(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+
+	value := []byte("value")
+	require.NoError(t, m.Set(ctx, "key", value, time.Minute))
+	value[0] = 'X'
+
+	got, _, _ := m.Get(ctx, "key")
+	got[1] = 'X'
+
+	again, _, _ := m.Get(ctx, "key")
+	assert.Equal(t, []byte("value"), again)
+}
@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupRedisTest(t *testing.T) (*miniredis.Miniredis, *Redis) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return server, NewRedis(client)
+}
+
+func TestRedis_SetGetDelete(t *testing.T) {
+	ctx := context.Background()
+	_, r := setupRedisTest(t)
+
+	_, ok, err := r.Get(ctx, "missing")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, r.Set(ctx, "key", []byte("value"), time.Minute))
+	got, ok, err := r.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("value"), got)
+
+	require.NoError(t, r.Delete(ctx, "key", "missing"))
+	_, ok, err = r.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.NoError(t, r.Delete(ctx))
+}
+
+func TestRedis_Expiry(t *testing.T) {
+	ctx := context.Background()
+	server, r := setupRedisTest(t)
+
+	require.NoError(t, r.Set(ctx, "key", []byte("value"), time.Minute))
+	assert.Equal(t, time.Minute, server.TTL("key"))
+
+	server.FastForward(time.Minute)
+	_, ok, err := r.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestRedis_ServerUnavailable(t *testing.T) {
+	ctx := context.Background()
+	server, r := setupRedisTest(t)
+	server.Close()
+
+	_, ok, err := r.Get(ctx, "key")
+	assert.Error(t, err)
+	assert.False(t, ok)
+	assert.Error(t, r.Set(ctx, "key", []byte("value"), time.Minute))
+}
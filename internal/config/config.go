@@ -2,75 +2,1161 @@
 package config
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"log/slog"
+	"net"
+	"net/mail"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/PakornBank/learn-go/internal/logger"
+	"github.com/PakornBank/learn-go/internal/password"
+	"github.com/PakornBank/learn-go/internal/redact"
 	"github.com/joho/godotenv"
 )
 
+// Application environments selectable with APP_ENV.
+const (
+	EnvDevelopment = "development"
+	EnvTest        = "test"
+	EnvProduction  = "production"
+)
+
+// defaultJWTSecret is the placeholder JWT_SECRET, which is treated as unset.
+const defaultJWTSecret = "your-secret-key"
+
+// maxRecommendedTokenExpiry is the TOKEN_EXPIRY above which a warning is
+// logged: access tokens cannot be revoked individually, so long-lived ones
+// stay usable long after they leak.
+const maxRecommendedTokenExpiry = 7 * 24 * time.Hour
+
+// minProductionJWTSecretLength is the shortest JWT_SECRET accepted in production.
+const minProductionJWTSecretLength = 32
+
+// Rate limiter stores selectable with RATE_LIMIT_STORE.
+const (
+	RateLimitStoreMemory = "memory"
+	RateLimitStoreRedis  = "redis"
+)
+
+// User cache modes selectable with USER_CACHE_MODE.
+const (
+	UserCacheModeStrict               = "strict"
+	UserCacheModeStaleWhileRevalidate = "stale-while-revalidate"
+)
+
+// Avatar fallbacks selectable with AVATAR_FALLBACK.
+const (
+	AvatarFallbackGravatar = "gravatar"
+	AvatarFallbackInitials = "initials"
+)
+
+// sslModes are the values DB_SSLMODE accepts, as defined by libpq.
+var sslModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// jwtAlgorithms are the values JWT_ALGORITHM accepts, the HMAC algorithms
+// JWT_SECRET can sign with.
+var jwtAlgorithms = []string{"HS256", "HS384", "HS512"}
+
+// Password expiry modes selectable with PASSWORD_EXPIRY_MODE.
+const (
+	PasswordExpiryFlag     = "flag"
+	PasswordExpiryRestrict = "restrict"
+	PasswordExpiryStrict   = "strict"
+)
+
+// Error response formats selectable with ERROR_FORMAT.
+const (
+	ErrorFormatJSON    = "json"
+	ErrorFormatProblem = "problem"
+)
+
 // Config holds the configuration values for the application.
-// It includes database connection details, server port, JWT secret, and token expiry duration.
+// It includes database connection details, server port, JWT secret, and access and refresh token expiry durations.
+// The yaml tag of each field is its key in the config file, which is the
+// lowercase name of the environment variable that sets it. Fields tagged
+// secret are masked when the Config is printed or logged; see String.
 type Config struct {
-	DBHost         string
-	DBUser         string
-	DBPassword     string
-	DBName         string
-	DBPort         string
-	ServerPort     string
-	JWTSecret      string
-	TokenExpiryDur time.Duration
+	Env            string        `yaml:"app_env"`
+	DatabaseURL    string        `yaml:"database_url" secret:"url"`
+	DBHost         string        `yaml:"db_host"`
+	DBUser         string        `yaml:"db_user"`
+	DBPassword     string        `yaml:"db_password" secret:"true"`
+	DBName         string        `yaml:"db_name"`
+	DBPort         string        `yaml:"db_port"`
+	DBSSLMode      string        `yaml:"db_sslmode"`
+	DBQueryTimeout time.Duration `yaml:"db_query_timeout"`
+	DBSlowQuery    time.Duration `yaml:"db_slow_query_ms"`
+
+	DBReplicaURL        string `yaml:"db_replica_url" secret:"url"`
+	DBLoginReadsPrimary bool   `yaml:"db_login_reads_primary"`
+
+	ServerPort    string        `yaml:"server_port"`
+	GRPCPort      string        `yaml:"grpc_port"`
+	ErrorFormat   string        `yaml:"error_format"`
+	JWTSecret     string        `yaml:"jwt_secret" secret:"true"`
+	JWTAlgorithm  string        `yaml:"jwt_algorithm"`
+	TokenExpiry   time.Duration `yaml:"token_expiry"`
+	RefreshExpiry time.Duration `yaml:"refresh_token_expiry"`
+	ReauthMaxAge  time.Duration `yaml:"reauth_max_age"`
+
+	ImpersonationExpiry time.Duration `yaml:"impersonation_expiry"`
+	JWTClockSkew        time.Duration `yaml:"jwt_clock_skew"`
+
+	LegacyClaimsCutoff time.Time `yaml:"legacy_claims_cutoff"`
+
+	IntrospectionSecret string `yaml:"introspection_secret" secret:"true"`
+
+	AuditRedactFields []string `yaml:"audit_redact_fields"`
+
+	TrustedProxies []string `yaml:"trusted_proxies"`
+
+	DisposableDomainsFile string `yaml:"disposable_email_domains_file"`
+
+	FeatureFlagsFile string `yaml:"feature_flags_file"`
+
+	BreachCheckEnabled  bool          `yaml:"hibp_enabled"`
+	BreachCheckMaxCount int           `yaml:"hibp_max_breach_count"`
+	BreachCheckTimeout  time.Duration `yaml:"hibp_timeout"`
+
+	PasswordHashWorkers int `yaml:"password_hash_workers"`
+
+	AdminEmail    string `yaml:"admin_email"`
+	AdminPassword string `yaml:"admin_password" secret:"true"`
+
+	SignupAnomalyMultiplier float64 `yaml:"signup_anomaly_multiplier"`
+	SignupAnomalyWebhookURL string  `yaml:"signup_anomaly_webhook_url" secret:"url"`
+
+	OutboxWebhookURL   string        `yaml:"outbox_webhook_url" secret:"url"`
+	OutboxPollInterval time.Duration `yaml:"outbox_poll_interval"`
+	OutboxRetention    time.Duration `yaml:"outbox_retention"`
+	OutboxMaxAttempts  int           `yaml:"outbox_max_attempts"`
+	OutboxRetryBackoff time.Duration `yaml:"outbox_retry_backoff"`
+
+	NATSURL string `yaml:"nats_url" secret:"url"`
+
+	RedisAddr string        `yaml:"redis_addr"`
+	CacheTTL  time.Duration `yaml:"cache_ttl"`
+
+	UserCacheSize         int           `yaml:"user_cache_size"`
+	UserCacheTTL          time.Duration `yaml:"user_cache_ttl"`
+	UserCacheMode         string        `yaml:"user_cache_mode"`
+	UserCacheMaxStaleness time.Duration `yaml:"user_cache_max_staleness"`
+
+	RateLimitStore string `yaml:"rate_limit_store"`
+
+	SMTPHost     string `yaml:"smtp_host"`
+	SMTPPort     string `yaml:"smtp_port"`
+	SMTPUser     string `yaml:"smtp_user"`
+	SMTPPassword string `yaml:"smtp_password" secret:"true"`
+	SMTPFrom     string `yaml:"smtp_from"`
+	AppBaseURL   string `yaml:"app_base_url"`
+
+	EmailQueueInterval time.Duration `yaml:"email_queue_interval"`
+	EmailMaxAttempts   int           `yaml:"email_max_attempts"`
+	EmailRetryBackoff  time.Duration `yaml:"email_retry_backoff"`
+
+	SentryDSN string `yaml:"sentry_dsn" secret:"true"`
+
+	AccountDeletionGrace time.Duration `yaml:"account_deletion_grace_period"`
+	AccountPurgeInterval time.Duration `yaml:"account_purge_interval"`
+
+	RoleGrantExpiryInterval time.Duration `yaml:"role_grant_expiry_interval"`
+
+	AvatarDir          string `yaml:"avatar_dir"`
+	AvatarRoute        string `yaml:"avatar_route"`
+	AvatarMaxDimension int    `yaml:"avatar_max_dimension"`
+	AvatarFallback     string `yaml:"avatar_fallback"`
+
+	ConcurrencyLimit        int            `yaml:"concurrency_limit"`
+	ConcurrencyRouteLimits  map[string]int `yaml:"concurrency_route_limits"`
+	ConcurrencyQueueTimeout time.Duration  `yaml:"concurrency_queue_timeout"`
+
+	ShutdownTimeout        time.Duration `yaml:"shutdown_timeout"`
+	RetryAfterShuttingDown time.Duration `yaml:"retry_after_shutting_down"`
+	RetryAfterMaintenance  time.Duration `yaml:"retry_after_maintenance"`
+	RetryAfterOverloaded   time.Duration `yaml:"retry_after_overloaded"`
+
+	UserCountInterval time.Duration `yaml:"user_count_interval"`
+
+	TOSRequired bool `yaml:"tos_required"`
+	TOSVersion  int  `yaml:"tos_version"`
+
+	PasswordMaxAge     time.Duration `yaml:"password_max_age"`
+	PasswordExpiryMode string        `yaml:"password_expiry_mode"`
+
+	LoginFailureDelay  time.Duration `yaml:"login_failure_delay"`
+	LoginFailureJitter time.Duration `yaml:"login_failure_jitter"`
+
+	Dynamic `yaml:",inline"`
+
+	// jwtSecretGenerated reports whether JWTSecret is an ephemeral
+	// development secret rather than a configured one.
+	jwtSecretGenerated bool
+}
+
+// Dynamic holds the settings that can be changed without a restart: they are
+// reread from the environment and config file on SIGHUP, see Live, and
+// read again on every request rather than copied at startup.
+type Dynamic struct {
+	LogLevel            string        `yaml:"log_level"`
+	RegistrationEnabled bool          `yaml:"registration_enabled"`
+	RateLimitRequests   int           `yaml:"rate_limit_requests"`
+	RateLimitWindow     time.Duration `yaml:"rate_limit_window"`
+
+	RegistrationEmailDomains []string `yaml:"registration_email_domains"`
 }
 
 // LoadConfig loads the configuration from environment variables and returns a Config struct.
-// It first attempts to load environment variables from a .env file using godotenv.
-// If the .env file does not exist, it continues without error.
-// If the .env file exists but cannot be loaded, it returns an error.
+// It first loads environment variables from the dotenv file of the environment,
+// such as .env.production, and then from .env. Variables already set in the
+// process environment are never overridden, and the profile file takes
+// precedence over .env. Missing files are skipped; if a file exists but cannot
+// be loaded, it returns an error.
+//
+// When CONFIG_FILE names a YAML config file, its values are used for the
+// variables that are still unset; see LoadConfigFile.
 //
 // The following environment variables are used to populate the Config struct:
 //
+//   - APP_ENV: Application environment, "development", "test" or "production"; it is read
+//     from the process environment or .env and selects the dotenv file (default: "development")
+//
+//   - LOG_LEVEL: Minimum log level, one of debug, info, warn or error (default: "info")
+//
+//   - DATABASE_URL: Connection string of the database, as a postgres:// URL or libpq key/value
+//     string; when set, it is used instead of the DB_* variables below, and DATABASE_URL_FILE
+//     names a file to read it from instead (default: "")
+//
 //   - DB_HOST: Database host (default: "localhost")
 //
 //   - DB_USER: Database user (default: "postgres")
 //
-//   - DB_PASSWORD: Database password (default: "")
+//   - DB_PASSWORD: Database password; DB_PASSWORD_FILE names a file to read it from instead (default: "")
 //
 //   - DB_NAME: Database name (default: "go_auth_db")
 //
 //   - DB_PORT: Database port (default: "5432")
 //
+//   - DB_SSLMODE: Postgres sslmode of the database connection, one of disable, allow, prefer,
+//     require, verify-ca or verify-full (default: "require" in production, otherwise "disable")
+//
+//   - DB_QUERY_TIMEOUT: Maximum duration of a single database query (default: "5s")
+//
+//   - DB_SLOW_QUERY_MS: Queries slower than this many milliseconds are logged as warnings (default: "200")
+//
+//   - DB_REPLICA_URL: Connection string of a read replica, which read-only user queries such as
+//     profile and admin reads then run on; DB_REPLICA_URL_FILE names a file to read it from
+//     instead, and every query runs on the primary when empty (default: "")
+//
+//   - DB_LOGIN_READS_PRIMARY: Whether logins look users up on the primary even with a read
+//     replica, so that a password changed moments ago is never checked against a stale hash (default: "true")
+//
 //   - SERVER_PORT: Server port (default: "8080")
 //
-//   - JWT_SECRET: JWT secret key (default: "your-secret-key")
+//   - GRPC_PORT: Port of the gRPC server; the gRPC server is disabled when empty (default: "")
+//
+//   - ERROR_FORMAT: Format of error responses, "json" for {"error": ...} or "problem" for
+//     RFC 7807 problem details; clients may ask for problem details either way (default: "json")
+//
+//   - JWT_SECRET: JWT secret key; JWT_SECRET_FILE names a file to read it from instead, and
+//     in development an ephemeral secret is generated when unset, and a secret that is shorter
+//     than 32 bytes or too predictable is warned about; see JWTSecretWeakness (default: "your-secret-key")
+//
+//   - JWT_ALGORITHM: Algorithm access tokens are signed with, "HS256", "HS384" or "HS512"; tokens
+//     signed with any other, including "none", are rejected (default: "HS256")
+//
+//   - TOKEN_EXPIRY: Lifetime of access tokens; a warning is logged above 7 days (default: "24h")
+//
+//   - REFRESH_TOKEN_EXPIRY: Lifetime of refresh tokens (default: "168h")
+//
+//   - REAUTH_MAX_AGE: How long after entering their password a user may perform sensitive
+//     operations, such as deleting their account, without re-authenticating (default: "5m")
+//
+//   - IMPERSONATION_EXPIRY: Lifetime of the tokens admins obtain to act as another user (default: "15m")
+//
+//   - JWT_CLOCK_SKEW: How far the clocks of clients and replicas may drift apart; access tokens
+//     are accepted this long after they expire and before their nbf or iat (default: "30s")
+//
+//   - JWT_LEGACY_CLAIMS_CUTOFF: An RFC 3339 timestamp. Access tokens issued before it that lack the
+//     role, ver or scopes claims get their defaults, and those issued since are rejected; when empty,
+//     every token missing them gets the defaults (default: "")
+//
+//   - INTROSPECTION_SECRET: Shared key internal services present to introspect tokens and to
+//     call the gRPC server; the introspection endpoint is disabled when empty (default: "")
+//
+//   - AUDIT_REDACT_FIELDS: Comma-separated names of the JSON fields masked in audited admin request
+//     bodies; "*" matches any characters, and case is ignored (default: "password,token,*_secret")
+//
+//   - REGISTRATION_ENABLED: Whether new users may sign up; registration answers 503 when false (default: "true")
+//
+//   - REGISTRATION_EMAIL_DOMAINS: Comma-separated email domains new users must sign up with, case
+//     ignored; "example.com" allows that domain only, and ".example.com" also its subdomains. Empty
+//     allows every domain (default: "")
+//
+//   - DISPOSABLE_EMAIL_DOMAINS_FILE: File listing disposable email domains, one per line, rejected at
+//     registration in addition to the built-in list (default: "")
+//
+//   - FEATURE_FLAGS_FILE: YAML file with the feature flag rules; every flag is off when empty (default: "")
+//
+//   - HIBP_ENABLED: Whether new passwords are checked against the Have I Been Pwned breach corpus (default: "false")
+//
+//   - HIBP_MAX_BREACH_COUNT: Passwords seen in more breaches than this are rejected (default: "0")
+//
+//   - HIBP_TIMEOUT: How long to wait for the breach check before accepting the password (default: "2s")
+//
+//   - PASSWORD_HASH_WORKERS: Passwords hashed or checked at once; further ones wait, so that a burst of
+//     signups or logins cannot take every core; empty means half of GOMAXPROCS, at least 1 (default: "")
+//
+//   - ADMIN_EMAIL: Email of the account made an admin on startup while no user is one, which is
+//     created if it does not exist; nothing is done when empty (default: "")
+//
+//   - ADMIN_PASSWORD: Password of the account created for ADMIN_EMAIL, subject to the password
+//     policy; ADMIN_PASSWORD_FILE names a file to read it from instead (default: "")
+//
+//   - SIGNUP_ANOMALY_MULTIPLIER: How many times the usual registration rate the registrations of the
+//     last 5 minutes must exceed to raise a signup anomaly alert; 0 disables the check (default: "3")
+//
+//   - SIGNUP_ANOMALY_WEBHOOK_URL: URL signup anomaly alerts are POSTed to; alerts are only logged when
+//     empty (default: "")
+//
+//   - OUTBOX_WEBHOOK_URL: URL outbox events are POSTed to; events are only logged when empty (default: "")
+//
+//   - OUTBOX_POLL_INTERVAL: How often unpublished outbox events are dispatched (default: "5s")
+//
+//   - OUTBOX_RETENTION: How long published outbox events are kept before being deleted (default: "168h")
+//
+//   - OUTBOX_MAX_ATTEMPTS: Failed attempts after which an outbox event is dead-lettered (default: "10")
+//
+//   - OUTBOX_RETRY_BACKOFF: Delay before a failed outbox event is retried, doubling after each further failure (default: "5s")
+//
+//   - NATS_URL: NATS server outbox events are published to, taking precedence over OUTBOX_WEBHOOK_URL (default: "")
+//
+//   - REDIS_ADDR: Address of the Redis server used to cache users; caching is disabled when empty (default: "")
+//
+//   - CACHE_TTL: How long cached users are kept (default: "5m")
+//
+//   - USER_CACHE_SIZE: Users kept in the in-process cache used when REDIS_ADDR is empty; the least
+//     recently used are evicted first, and 0 disables it (default: "1000"). The in-process cache
+//     is for single-replica deployments only; several replicas need REDIS_ADDR or 0
+//
+//   - USER_CACHE_TTL: How long users are kept in the in-process cache (default: "30s")
+//
+//   - USER_CACHE_MODE: "strict" to reload users once their cache TTL passes, or "stale-while-revalidate"
+//     to keep serving them while they are reloaded in the background (default: "strict")
+//
+//   - USER_CACHE_MAX_STALENESS: With stale-while-revalidate, how long after being cached a user may
+//     still be served; older users are reloaded before responding (default: "5m")
+//
+//   - RATE_LIMIT_STORE: Where rate limits are tracked, "memory" or "redis";
+//     "redis" shares limits between replicas and requires REDIS_ADDR (default: "memory")
+//
+//   - RATE_LIMIT_REQUESTS: Requests a client may make to each public auth route per window (default: "10")
+//
+//   - RATE_LIMIT_WINDOW: Length of the rate limit window (default: "1m")
+//
+//   - TRUSTED_PROXIES: Comma-separated IPs and CIDR ranges of the proxies whose X-Forwarded-For
+//     header sets the client IP, such as for rate limits; no proxy is trusted when empty (default: "")
+//
+//   - SMTP_HOST: SMTP server used to send email; emails are only logged when empty (default: "")
+//
+//   - SMTP_PORT: SMTP server port (default: "587")
+//
+//   - SMTP_USER: SMTP username; no authentication is used when empty (default: "")
+//
+//   - SMTP_PASSWORD: SMTP password (default: "")
+//
+//   - SMTP_FROM: Sender address of outgoing email (default: "no-reply@localhost")
+//
+//   - APP_BASE_URL: Base URL that links in emails point to (default: "http://localhost:8080")
+//
+//   - EMAIL_QUEUE_INTERVAL: How often queued emails are sent (default: "10s")
+//
+//   - EMAIL_MAX_ATTEMPTS: Failed attempts after which an email is dead-lettered (default: "5")
+//
+//   - EMAIL_RETRY_BACKOFF: Delay before a failed email is retried, doubling after each further failure (default: "30s")
+//
+//   - SENTRY_DSN: Sentry DSN unexpected server errors are reported to; reporting is disabled when empty (default: "")
+//
+//   - ACCOUNT_DELETION_GRACE_PERIOD: How long an account marked for deletion is kept, during which
+//     logging in cancels the deletion (default: "336h")
+//
+//   - ACCOUNT_PURGE_INTERVAL: How often accounts past their deletion grace period are purged (default: "1h")
+//
+//   - ROLE_GRANT_EXPIRY_INTERVAL: How often expired temporary role grants are ended and recorded in the
+//     audit log (default: "1m")
+//
+//   - AVATAR_DIR: Directory uploaded avatars are stored in (default: "uploads/avatars")
+//
+//   - AVATAR_ROUTE: URL path uploaded avatars are served from (default: "/avatars")
+//
+//   - AVATAR_MAX_DIMENSION: Avatars wider or taller than this many pixels are scaled down (default: "512")
+//
+//   - AVATAR_FALLBACK: What profiles show for users who have not uploaded an avatar, "gravatar" for
+//     their Gravatar or "initials" for the initials and color to draw one with (default: "gravatar")
+//
+//   - CONCURRENCY_LIMIT: Requests served at once across all routes; further requests queue, and 0
+//     removes the limit (default: "0")
+//
+//   - CONCURRENCY_ROUTE_LIMITS: Comma-separated limits of single routes, each the method and path of the
+//     route and its limit, such as "POST /api/auth/login=20" (default: "")
+//
+//   - CONCURRENCY_QUEUE_TIMEOUT: How long a request waits for a free slot before it is rejected with a
+//     503 (default: "100ms")
+//
+//   - SHUTDOWN_TIMEOUT: How long requests in flight may take to finish once the server is asked to shut
+//     down (default: "30s")
+//
+//   - RETRY_AFTER_SHUTTING_DOWN: The Retry-After of requests turned away while the server shuts down
+//     (default: "5s")
+//
+//   - RETRY_AFTER_MAINTENANCE: The Retry-After of requests turned away during maintenance (default: "5m")
+//
+//   - RETRY_AFTER_OVERLOADED: The Retry-After of requests shed by the concurrency limit (default: "1s")
+//
+//   - USER_COUNT_INTERVAL: How often the users_total metric is refreshed (default: "1m")
+//
+//   - TOS_REQUIRED: Whether users must accept the terms of service to register and to use protected
+//     routes (default: "false")
+//
+//   - TOS_VERSION: The current version of the terms of service. Users who accepted an older version
+//     must accept it again (default: "1")
+//
+//   - PASSWORD_MAX_AGE: How long a password may be used before it must be changed, such as "2160h"
+//     for 90 days; "0" disables password expiry (default: "0")
+//
+//   - PASSWORD_EXPIRY_MODE: What happens once a password expires: "flag" to flag the login response
+//     with password_expired, "restrict" to also keep the user from every protected route but the
+//     password change, or "strict" to also refuse the login itself, leaving the user to reset their
+//     password (default: "flag")
+//
+//   - LOGIN_FAILURE_DELAY: How long a login with wrong credentials waits before failing, to slow down
+//     password guessing; "0" disables the delay (default: "500ms")
+//
+//   - LOGIN_FAILURE_JITTER: How much LOGIN_FAILURE_DELAY varies either way, at random, on each
+//     failure (default: "100ms")
 //
 // If the JWT_SECRET environment variable is not set (i.e., it is "your-secret-key"),
-// the function returns an error indicating that the JWT secret must be set.
+// the function returns an error indicating that the JWT secret must be set, except
+// in development, where it logs a warning and uses a random secret that changes on
+// every start.
+// If APP_ENV is unknown, or JWT_SECRET_FILE, DB_PASSWORD_FILE or
+// DATABASE_URL_FILE is set but the file cannot be read, the function returns an error.
+// If DB_REPLICA_URL_FILE is set but the file cannot be read, or DB_LOGIN_READS_PRIMARY is not a
+// boolean, the function returns an error, as it does if ADMIN_PASSWORD_FILE is set but cannot be read.
+// If JWT_LEGACY_CLAIMS_CUTOFF is set but is not an RFC 3339 timestamp, the function returns an error.
+// If JWT_CLOCK_SKEW is not a non-negative duration, the function returns an error.
+// If DB_QUERY_TIMEOUT, TOKEN_EXPIRY, REFRESH_TOKEN_EXPIRY, REAUTH_MAX_AGE, IMPERSONATION_EXPIRY, OUTBOX_POLL_INTERVAL, OUTBOX_RETENTION, OUTBOX_RETRY_BACKOFF, CACHE_TTL,
+// USER_CACHE_TTL, USER_CACHE_MAX_STALENESS, // RATE_LIMIT_WINDOW, EMAIL_QUEUE_INTERVAL, EMAIL_RETRY_BACKOFF, ACCOUNT_DELETION_GRACE_PERIOD, ACCOUNT_PURGE_INTERVAL, ROLE_GRANT_EXPIRY_INTERVAL or
+// CONCURRENCY_QUEUE_TIMEOUT, SHUTDOWN_TIMEOUT, RETRY_AFTER_SHUTTING_DOWN, RETRY_AFTER_MAINTENANCE,
+// RETRY_AFTER_OVERLOADED or USER_COUNT_INTERVAL is not a valid positive duration, DB_SLOW_QUERY_MS is not a
+// non-negative integer, RATE_LIMIT_REQUESTS, OUTBOX_MAX_ATTEMPTS or EMAIL_MAX_ATTEMPTS is not a positive integer,
+// REGISTRATION_ENABLED or HIBP_ENABLED is not a boolean, HIBP_MAX_BREACH_COUNT
+// is not a non-negative integer, HIBP_TIMEOUT is not a positive duration,
+// PASSWORD_HASH_WORKERS is not a positive integer, SIGNUP_ANOMALY_MULTIPLIER is neither 0 nor a
+// number greater than 1, TOS_REQUIRED is not a boolean, TOS_VERSION is not a
+// positive integer, PASSWORD_MAX_AGE, LOGIN_FAILURE_DELAY or LOGIN_FAILURE_JITTER is not a non-negative duration,
+// AVATAR_MAX_DIMENSION is not a positive integer, AVATAR_ROUTE does not start
+// with "/", AVATAR_FALLBACK is not gravatar or initials, CONCURRENCY_LIMIT is not a non-negative integer or
+// CONCURRENCY_ROUTE_LIMITS is malformed, or USER_CACHE_SIZE is not a non-negative integer, the
+// function returns an error.
+// Finally, the Config is checked with Validate, and all of the problems it
+// finds are returned together.
 //
 // Returns a pointer to a Config struct and an error, if any.
 func LoadConfig() (*Config, error) {
-	if err := godotenv.Load(); err != nil && !os.IsNotExist(err) {
-		return nil, fmt.Errorf("error loading .env file: %v", err)
+	return LoadConfigFile("")
+}
+
+// LoadConfigFile is LoadConfig with the YAML config file at path layered
+// between the environment and the defaults: environment variables, including
+// those from dotenv files, override the file, and defaults fill in whatever
+// neither sets. Each key of the file is the lowercase name of an environment
+// variable, such as db_host for DB_HOST. Unknown keys are logged as a warning.
+// If path is empty, the file named by CONFIG_FILE is used, if any.
+// Variables set from dotenv or config files by an earlier call are cleared
+// first, so that calling it again, as Live.Reload does, rereads the files.
+func LoadConfigFile(path string) (*Config, error) {
+	unsetFromFiles()
+	env, err := loadDotenv()
+	if err != nil {
+		return nil, err
+	}
+
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path != "" {
+		if err := loadFile(path); err != nil {
+			return nil, err
+		}
+		env = getEnv("APP_ENV", env)
+	}
+	switch env {
+	case EnvDevelopment, EnvTest, EnvProduction:
+	default:
+		return nil, errors.New("invalid APP_ENV: must be development, test or production")
+	}
+
+	defaultSSLMode := "disable"
+	if env == EnvProduction {
+		defaultSSLMode = "require"
+	}
+
+	databaseURL, err := getSecret("DATABASE_URL", "")
+	if err != nil {
+		return nil, err
+	}
+
+	dbPassword, err := getSecret("DB_PASSWORD", "")
+	if err != nil {
+		return nil, err
+	}
+
+	jwtSecret, err := getSecret("JWT_SECRET", defaultJWTSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	dbQueryTimeout, err := getDuration("DB_QUERY_TIMEOUT", "5s")
+	if err != nil {
+		return nil, err
+	}
+
+	dbSlowQueryMS, err := strconv.Atoi(getEnv("DB_SLOW_QUERY_MS", "200"))
+	if err != nil || dbSlowQueryMS < 0 {
+		return nil, errors.New("invalid DB_SLOW_QUERY_MS: must be a non-negative integer")
+	}
+
+	dbReplicaURL, err := getSecret("DB_REPLICA_URL", "")
+	if err != nil {
+		return nil, err
+	}
+
+	dbLoginReadsPrimary, err := strconv.ParseBool(getEnv("DB_LOGIN_READS_PRIMARY", "true"))
+	if err != nil {
+		return nil, errors.New("invalid DB_LOGIN_READS_PRIMARY: must be a boolean")
+	}
+
+	tokenExpiry, err := getDuration("TOKEN_EXPIRY", "24h")
+	if err != nil {
+		return nil, err
+	}
+	if tokenExpiry > maxRecommendedTokenExpiry {
+		slog.Warn("TOKEN_EXPIRY is longer than 7 days; leaked access tokens stay valid until they expire", "token_expiry", tokenExpiry)
+	}
+
+	refreshExpiry, err := getDuration("REFRESH_TOKEN_EXPIRY", "168h")
+	if err != nil {
+		return nil, err
+	}
+
+	reauthMaxAge, err := getDuration("REAUTH_MAX_AGE", "5m")
+	if err != nil {
+		return nil, err
+	}
+
+	impersonationExpiry, err := getDuration("IMPERSONATION_EXPIRY", "15m")
+	if err != nil {
+		return nil, err
+	}
+
+	jwtClockSkew, err := time.ParseDuration(getEnv("JWT_CLOCK_SKEW", "30s"))
+	if err != nil || jwtClockSkew < 0 {
+		return nil, errors.New("invalid JWT_CLOCK_SKEW: must be a non-negative duration")
+	}
+
+	legacyClaimsCutoff, err := getTime("JWT_LEGACY_CLAIMS_CUTOFF")
+	if err != nil {
+		return nil, err
+	}
+
+	registrationEnabled, err := strconv.ParseBool(getEnv("REGISTRATION_ENABLED", "true"))
+	if err != nil {
+		return nil, errors.New("invalid REGISTRATION_ENABLED: must be a boolean")
+	}
+
+	breachCheckEnabled, err := strconv.ParseBool(getEnv("HIBP_ENABLED", "false"))
+	if err != nil {
+		return nil, errors.New("invalid HIBP_ENABLED: must be a boolean")
+	}
+
+	breachCheckMaxCount, err := strconv.Atoi(getEnv("HIBP_MAX_BREACH_COUNT", "0"))
+	if err != nil || breachCheckMaxCount < 0 {
+		return nil, errors.New("invalid HIBP_MAX_BREACH_COUNT: must be a non-negative integer")
+	}
+
+	breachCheckTimeout, err := getDuration("HIBP_TIMEOUT", "2s")
+	if err != nil {
+		return nil, err
+	}
+
+	passwordHashWorkers := password.DefaultPoolSize()
+	if value := getEnv("PASSWORD_HASH_WORKERS", ""); value != "" {
+		passwordHashWorkers, err = strconv.Atoi(value)
+		if err != nil || passwordHashWorkers <= 0 {
+			return nil, errors.New("invalid PASSWORD_HASH_WORKERS: must be a positive integer")
+		}
+	}
+
+	adminPassword, err := getSecret("ADMIN_PASSWORD", "")
+	if err != nil {
+		return nil, err
+	}
+
+	signupAnomalyMultiplier, err := strconv.ParseFloat(getEnv("SIGNUP_ANOMALY_MULTIPLIER", "3"), 64)
+	if err != nil || (signupAnomalyMultiplier != 0 && !(signupAnomalyMultiplier > 1)) {
+		return nil, errors.New("invalid SIGNUP_ANOMALY_MULTIPLIER: must be 0 or a number greater than 1")
+	}
+
+	signupAnomalyWebhookURL, err := getSecret("SIGNUP_ANOMALY_WEBHOOK_URL", "")
+	if err != nil {
+		return nil, err
+	}
+
+	outboxPollInterval, err := getDuration("OUTBOX_POLL_INTERVAL", "5s")
+	if err != nil {
+		return nil, err
+	}
+
+	outboxRetention, err := getDuration("OUTBOX_RETENTION", "168h")
+	if err != nil {
+		return nil, err
+	}
+
+	outboxMaxAttempts, err := strconv.Atoi(getEnv("OUTBOX_MAX_ATTEMPTS", "10"))
+	if err != nil || outboxMaxAttempts <= 0 {
+		return nil, errors.New("invalid OUTBOX_MAX_ATTEMPTS: must be a positive integer")
+	}
+
+	outboxRetryBackoff, err := getDuration("OUTBOX_RETRY_BACKOFF", "5s")
+	if err != nil {
+		return nil, err
+	}
+
+	cacheTTL, err := getDuration("CACHE_TTL", "5m")
+	if err != nil {
+		return nil, err
+	}
+
+	userCacheSize, err := strconv.Atoi(getEnv("USER_CACHE_SIZE", "1000"))
+	if err != nil || userCacheSize < 0 {
+		return nil, errors.New("invalid USER_CACHE_SIZE: must be a non-negative integer")
+	}
+
+	userCacheTTL, err := getDuration("USER_CACHE_TTL", "30s")
+	if err != nil {
+		return nil, err
+	}
+
+	userCacheMaxStaleness, err := getDuration("USER_CACHE_MAX_STALENESS", "5m")
+	if err != nil {
+		return nil, err
+	}
+
+	rateLimitRequests, err := strconv.Atoi(getEnv("RATE_LIMIT_REQUESTS", "10"))
+	if err != nil || rateLimitRequests <= 0 {
+		return nil, errors.New("invalid RATE_LIMIT_REQUESTS: must be a positive integer")
+	}
+
+	rateLimitWindow, err := getDuration("RATE_LIMIT_WINDOW", "1m")
+	if err != nil {
+		return nil, err
+	}
+
+	emailQueueInterval, err := getDuration("EMAIL_QUEUE_INTERVAL", "10s")
+	if err != nil {
+		return nil, err
+	}
+
+	emailMaxAttempts, err := strconv.Atoi(getEnv("EMAIL_MAX_ATTEMPTS", "5"))
+	if err != nil || emailMaxAttempts <= 0 {
+		return nil, errors.New("invalid EMAIL_MAX_ATTEMPTS: must be a positive integer")
+	}
+
+	emailRetryBackoff, err := getDuration("EMAIL_RETRY_BACKOFF", "30s")
+	if err != nil {
+		return nil, err
+	}
+
+	accountDeletionGrace, err := getDuration("ACCOUNT_DELETION_GRACE_PERIOD", "336h")
+	if err != nil {
+		return nil, err
+	}
+
+	accountPurgeInterval, err := getDuration("ACCOUNT_PURGE_INTERVAL", "1h")
+	if err != nil {
+		return nil, err
+	}
+
+	roleGrantExpiryInterval, err := getDuration("ROLE_GRANT_EXPIRY_INTERVAL", "1m")
+	if err != nil {
+		return nil, err
+	}
+
+	avatarRoute := getEnv("AVATAR_ROUTE", "/avatars")
+	if !strings.HasPrefix(avatarRoute, "/") || avatarRoute == "/" {
+		return nil, errors.New("invalid AVATAR_ROUTE: must be a path starting with /")
+	}
+
+	avatarMaxDimension, err := strconv.Atoi(getEnv("AVATAR_MAX_DIMENSION", "512"))
+	if err != nil || avatarMaxDimension <= 0 {
+		return nil, errors.New("invalid AVATAR_MAX_DIMENSION: must be a positive integer")
+	}
+
+	avatarFallback := getEnv("AVATAR_FALLBACK", AvatarFallbackGravatar)
+	if avatarFallback != AvatarFallbackGravatar && avatarFallback != AvatarFallbackInitials {
+		return nil, errors.New("invalid AVATAR_FALLBACK: must be gravatar or initials")
+	}
+
+	concurrencyLimit, err := strconv.Atoi(getEnv("CONCURRENCY_LIMIT", "0"))
+	if err != nil || concurrencyLimit < 0 {
+		return nil, errors.New("invalid CONCURRENCY_LIMIT: must be a non-negative integer")
+	}
+
+	concurrencyRouteLimits, err := getRouteLimits("CONCURRENCY_ROUTE_LIMITS")
+	if err != nil {
+		return nil, err
+	}
+
+	concurrencyQueueTimeout, err := getDuration("CONCURRENCY_QUEUE_TIMEOUT", "100ms")
+	if err != nil {
+		return nil, err
+	}
+
+	shutdownTimeout, err := getDuration("SHUTDOWN_TIMEOUT", "30s")
+	if err != nil {
+		return nil, err
+	}
+
+	retryAfterShuttingDown, err := getDuration("RETRY_AFTER_SHUTTING_DOWN", "5s")
+	if err != nil {
+		return nil, err
+	}
+
+	retryAfterMaintenance, err := getDuration("RETRY_AFTER_MAINTENANCE", "5m")
+	if err != nil {
+		return nil, err
+	}
+
+	retryAfterOverloaded, err := getDuration("RETRY_AFTER_OVERLOADED", "1s")
+	if err != nil {
+		return nil, err
+	}
+
+	userCountInterval, err := getDuration("USER_COUNT_INTERVAL", "1m")
+	if err != nil {
+		return nil, err
+	}
+
+	tosRequired, err := strconv.ParseBool(getEnv("TOS_REQUIRED", "false"))
+	if err != nil {
+		return nil, errors.New("invalid TOS_REQUIRED: must be a boolean")
+	}
+
+	tosVersion, err := strconv.Atoi(getEnv("TOS_VERSION", "1"))
+	if err != nil || tosVersion <= 0 {
+		return nil, errors.New("invalid TOS_VERSION: must be a positive integer")
+	}
+
+	passwordMaxAge, err := time.ParseDuration(getEnv("PASSWORD_MAX_AGE", "0"))
+	if err != nil || passwordMaxAge < 0 {
+		return nil, errors.New("invalid PASSWORD_MAX_AGE: must be a non-negative duration")
+	}
+
+	loginFailureDelay, err := time.ParseDuration(getEnv("LOGIN_FAILURE_DELAY", "500ms"))
+	if err != nil || loginFailureDelay < 0 {
+		return nil, errors.New("invalid LOGIN_FAILURE_DELAY: must be a non-negative duration")
+	}
+
+	loginFailureJitter, err := time.ParseDuration(getEnv("LOGIN_FAILURE_JITTER", "100ms"))
+	if err != nil || loginFailureJitter < 0 {
+		return nil, errors.New("invalid LOGIN_FAILURE_JITTER: must be a non-negative duration")
 	}
 
 	config := &Config{
+		Env:            env,
+		DatabaseURL:    databaseURL,
 		DBHost:         getEnv("DB_HOST", "localhost"),
 		DBUser:         getEnv("DB_USER", "postgres"),
-		DBPassword:     getEnv("DB_PASSWORD", ""),
+		DBPassword:     dbPassword,
 		DBName:         getEnv("DB_NAME", "go_auth_db"),
 		DBPort:         getEnv("DB_PORT", "5432"),
-		ServerPort:     getEnv("SERVER_PORT", "8080"),
-		JWTSecret:      getEnv("JWT_SECRET", "your-secret-key"),
-		TokenExpiryDur: 24 * time.Hour,
+		DBSSLMode:      getEnv("DB_SSLMODE", defaultSSLMode),
+		DBQueryTimeout: dbQueryTimeout,
+		DBSlowQuery:    time.Duration(dbSlowQueryMS) * time.Millisecond,
+
+		DBReplicaURL:        dbReplicaURL,
+		DBLoginReadsPrimary: dbLoginReadsPrimary,
+
+		ServerPort:    getEnv("SERVER_PORT", "8080"),
+		GRPCPort:      getEnv("GRPC_PORT", ""),
+		ErrorFormat:   getEnv("ERROR_FORMAT", ErrorFormatJSON),
+		JWTSecret:     jwtSecret,
+		JWTAlgorithm:  getEnv("JWT_ALGORITHM", "HS256"),
+		TokenExpiry:   tokenExpiry,
+		RefreshExpiry: refreshExpiry,
+		ReauthMaxAge:  reauthMaxAge,
+
+		ImpersonationExpiry: impersonationExpiry,
+		JWTClockSkew:        jwtClockSkew,
+
+		LegacyClaimsCutoff: legacyClaimsCutoff,
+
+		IntrospectionSecret: getEnv("INTROSPECTION_SECRET", ""),
+
+		AuditRedactFields: getList("AUDIT_REDACT_FIELDS", strings.Join(redact.DefaultFields, ",")),
+
+		TrustedProxies: getList("TRUSTED_PROXIES", ""),
+
+		DisposableDomainsFile: getEnv("DISPOSABLE_EMAIL_DOMAINS_FILE", ""),
+
+		FeatureFlagsFile: getEnv("FEATURE_FLAGS_FILE", ""),
+
+		BreachCheckEnabled:  breachCheckEnabled,
+		BreachCheckMaxCount: breachCheckMaxCount,
+		BreachCheckTimeout:  breachCheckTimeout,
+
+		PasswordHashWorkers: passwordHashWorkers,
+
+		AdminEmail:    getEnv("ADMIN_EMAIL", ""),
+		AdminPassword: adminPassword,
+
+		SignupAnomalyMultiplier: signupAnomalyMultiplier,
+		SignupAnomalyWebhookURL: signupAnomalyWebhookURL,
+
+		OutboxWebhookURL:   getEnv("OUTBOX_WEBHOOK_URL", ""),
+		OutboxPollInterval: outboxPollInterval,
+		OutboxRetention:    outboxRetention,
+		OutboxMaxAttempts:  outboxMaxAttempts,
+		OutboxRetryBackoff: outboxRetryBackoff,
+
+		NATSURL: getEnv("NATS_URL", ""),
+
+		RedisAddr: getEnv("REDIS_ADDR", ""),
+		CacheTTL:  cacheTTL,
+
+		UserCacheSize:         userCacheSize,
+		UserCacheTTL:          userCacheTTL,
+		UserCacheMode:         getEnv("USER_CACHE_MODE", UserCacheModeStrict),
+		UserCacheMaxStaleness: userCacheMaxStaleness,
+
+		RateLimitStore: getEnv("RATE_LIMIT_STORE", RateLimitStoreMemory),
+
+		SMTPHost:     getEnv("SMTP_HOST", ""),
+		SMTPPort:     getEnv("SMTP_PORT", "587"),
+		SMTPUser:     getEnv("SMTP_USER", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:     getEnv("SMTP_FROM", "no-reply@localhost"),
+		AppBaseURL:   getEnv("APP_BASE_URL", "http://localhost:8080"),
+
+		EmailQueueInterval: emailQueueInterval,
+		EmailMaxAttempts:   emailMaxAttempts,
+		EmailRetryBackoff:  emailRetryBackoff,
+
+		SentryDSN: getEnv("SENTRY_DSN", ""),
+
+		AccountDeletionGrace: accountDeletionGrace,
+		AccountPurgeInterval: accountPurgeInterval,
+
+		RoleGrantExpiryInterval: roleGrantExpiryInterval,
+
+		AvatarDir:          getEnv("AVATAR_DIR", "uploads/avatars"),
+		AvatarRoute:        strings.TrimSuffix(avatarRoute, "/"),
+		AvatarMaxDimension: avatarMaxDimension,
+		AvatarFallback:     avatarFallback,
+
+		ConcurrencyLimit:        concurrencyLimit,
+		ConcurrencyRouteLimits:  concurrencyRouteLimits,
+		ConcurrencyQueueTimeout: concurrencyQueueTimeout,
+
+		ShutdownTimeout:        shutdownTimeout,
+		RetryAfterShuttingDown: retryAfterShuttingDown,
+		RetryAfterMaintenance:  retryAfterMaintenance,
+		RetryAfterOverloaded:   retryAfterOverloaded,
+
+		UserCountInterval: userCountInterval,
+
+		TOSRequired: tosRequired,
+		TOSVersion:  tosVersion,
+
+		PasswordMaxAge:     passwordMaxAge,
+		PasswordExpiryMode: getEnv("PASSWORD_EXPIRY_MODE", PasswordExpiryFlag),
+
+		LoginFailureDelay:  loginFailureDelay,
+		LoginFailureJitter: loginFailureJitter,
+
+		Dynamic: Dynamic{
+			LogLevel:            getEnv("LOG_LEVEL", "info"),
+			RegistrationEnabled: registrationEnabled,
+			RateLimitRequests:   rateLimitRequests,
+			RateLimitWindow:     rateLimitWindow,
+
+			RegistrationEmailDomains: normalizeDomains(getList("REGISTRATION_EMAIL_DOMAINS", "")),
+		},
 	}
 
-	if config.JWTSecret == "your-secret-key" {
-		return nil, errors.New("jwt secret must be set in environment")
+	if config.JWTSecret == defaultJWTSecret {
+		if env != EnvDevelopment {
+			return nil, errors.New("jwt secret must be set in environment")
+		}
+		secret, err := ephemeralSecret()
+		if err != nil {
+			return nil, err
+		}
+		slog.Warn("JWT_SECRET is not set; using an ephemeral secret for development. " +
+			"Every token is invalidated when the server restarts. Never run like this in production.")
+		config.JWTSecret = secret
+		config.jwtSecretGenerated = true
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if weakness := config.JWTSecretWeakness(); weakness != "" {
+		slog.Warn("JWT_SECRET is weak; generate a strong one with -generate-secret",
+			"reason", weakness, "length", len(config.JWTSecret))
 	}
 
 	return config, nil
 }
 
+// Validate checks that the ports of c are numbers between 1 and 65535,
+// DB_NAME is set, DB_SSLMODE and JWT_ALGORITHM are known, the token expiries are positive,
+// LOG_LEVEL is known, RATE_LIMIT_STORE, USER_CACHE_MODE, PASSWORD_EXPIRY_MODE
+// and ERROR_FORMAT are known, LOGIN_FAILURE_JITTER does not exceed
+// LOGIN_FAILURE_DELAY, USER_CACHE_MAX_STALENESS exceeds the cache TTL with
+// stale-while-revalidate, TRUSTED_PROXIES lists IPs and CIDR ranges,
+// REGISTRATION_EMAIL_DOMAINS lists domains, INTROSPECTION_SECRET is set
+// along with GRPC_PORT, ADMIN_EMAIL
+// is an email address set along with ADMIN_PASSWORD and, in
+// production, that JWT_SECRET is at least 32 characters, DB_PASSWORD is set and
+// DB_SSLMODE is not "disable". The DB_* checks are skipped when DATABASE_URL
+// is set, since it replaces those variables. Rather than stopping at the first problem, it collects all of
+// them and returns them joined with errors.Join, one per line, so that they
+// can all be fixed at once. It returns nil if c is valid.
+func (c *Config) Validate() error {
+	var problems []error
+
+	for _, port := range []struct{ key, value string }{
+		{"SERVER_PORT", c.ServerPort},
+		{"DB_PORT", c.DBPort},
+		{"GRPC_PORT", c.GRPCPort},
+		{"SMTP_PORT", c.SMTPPort},
+	} {
+		if port.key == "GRPC_PORT" && port.value == "" || port.key == "DB_PORT" && c.DatabaseURL != "" {
+			continue
+		}
+		if n, err := strconv.Atoi(port.value); err != nil || n < 1 || n > 65535 {
+			problems = append(problems, fmt.Errorf("invalid %s: must be a port number between 1 and 65535, got %q", port.key, port.value))
+		}
+	}
+
+	if c.DatabaseURL == "" {
+		if c.DBName == "" {
+			problems = append(problems, errors.New("invalid DB_NAME: must not be empty"))
+		}
+		if !slices.Contains(sslModes, c.DBSSLMode) {
+			problems = append(problems, fmt.Errorf("invalid DB_SSLMODE: must be one of %s, got %q", strings.Join(sslModes, ", "), c.DBSSLMode))
+		}
+	}
+
+	if !slices.Contains(jwtAlgorithms, c.JWTAlgorithm) {
+		problems = append(problems, fmt.Errorf("invalid JWT_ALGORITHM: must be one of %s, got %q", strings.Join(jwtAlgorithms, ", "), c.JWTAlgorithm))
+	}
+
+	if c.TokenExpiry <= 0 {
+		problems = append(problems, errors.New("invalid TOKEN_EXPIRY: must be a positive duration"))
+	}
+	if c.RefreshExpiry <= 0 {
+		problems = append(problems, errors.New("invalid REFRESH_TOKEN_EXPIRY: must be a positive duration"))
+	}
+
+	if _, err := logger.ParseLevel(c.LogLevel); err != nil {
+		problems = append(problems, fmt.Errorf("invalid LOG_LEVEL: must be debug, info, warn or error, got %q", c.LogLevel))
+	}
+
+	switch c.RateLimitStore {
+	case RateLimitStoreMemory:
+	case RateLimitStoreRedis:
+		if c.RedisAddr == "" {
+			problems = append(problems, errors.New("invalid RATE_LIMIT_STORE: redis requires REDIS_ADDR"))
+		}
+	default:
+		problems = append(problems, errors.New("invalid RATE_LIMIT_STORE: must be memory or redis"))
+	}
+
+	switch c.UserCacheMode {
+	case UserCacheModeStrict:
+	case UserCacheModeStaleWhileRevalidate:
+		if ttl := c.UserCacheTTLInUse(); c.UserCacheMaxStaleness <= ttl {
+			problems = append(problems, fmt.Errorf("invalid USER_CACHE_MAX_STALENESS: must be longer than the cache TTL of %s", ttl))
+		}
+	default:
+		problems = append(problems, errors.New("invalid USER_CACHE_MODE: must be strict or stale-while-revalidate"))
+	}
+
+	if c.GRPCPort != "" && c.IntrospectionSecret == "" {
+		problems = append(problems, errors.New("invalid INTROSPECTION_SECRET: must be set when GRPC_PORT is set"))
+	}
+
+	if _, err := redact.New(c.AuditRedactFields); err != nil {
+		problems = append(problems, fmt.Errorf("invalid AUDIT_REDACT_FIELDS: %w", err))
+	}
+
+	for _, proxy := range c.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			problems = append(problems, fmt.Errorf("invalid TRUSTED_PROXIES: %q is not an IP or a CIDR range", proxy))
+		}
+	}
+
+	for _, domain := range c.RegistrationEmailDomains {
+		if name := strings.TrimPrefix(domain, "."); name == "" || strings.ContainsAny(name, "@ \t") || strings.HasPrefix(name, ".") {
+			problems = append(problems, fmt.Errorf("invalid REGISTRATION_EMAIL_DOMAINS: %q is not a domain", domain))
+		}
+	}
+
+	if (c.AdminEmail == "") != (c.AdminPassword == "") {
+		problems = append(problems, errors.New("invalid ADMIN_EMAIL: must be set along with ADMIN_PASSWORD"))
+	} else if c.AdminEmail != "" {
+		if address, err := mail.ParseAddress(c.AdminEmail); err != nil || address.Address != c.AdminEmail {
+			problems = append(problems, fmt.Errorf("invalid ADMIN_EMAIL: %q is not an email address", c.AdminEmail))
+		}
+	}
+
+	switch c.PasswordExpiryMode {
+	case PasswordExpiryFlag, PasswordExpiryRestrict, PasswordExpiryStrict:
+	default:
+		problems = append(problems, errors.New("invalid PASSWORD_EXPIRY_MODE: must be flag, restrict or strict"))
+	}
+
+	if c.LoginFailureJitter > c.LoginFailureDelay {
+		problems = append(problems, errors.New("invalid LOGIN_FAILURE_JITTER: must not exceed LOGIN_FAILURE_DELAY"))
+	}
+
+	if c.ErrorFormat != ErrorFormatJSON && c.ErrorFormat != ErrorFormatProblem {
+		problems = append(problems, errors.New("invalid ERROR_FORMAT: must be json or problem"))
+	}
+
+	if c.IsProduction() {
+		problems = append(problems, c.validateProduction()...)
+	}
+
+	return errors.Join(problems...)
+}
+
+// validateProduction returns the problems that only production rejects.
+func (c *Config) validateProduction() []error {
+	var problems []error
+	if len(c.JWTSecret) < minProductionJWTSecretLength {
+		problems = append(problems, fmt.Errorf("invalid JWT_SECRET: must be at least %d characters in production", minProductionJWTSecretLength))
+	}
+	if c.DatabaseURL != "" {
+		return problems
+	}
+	if c.DBPassword == "" {
+		problems = append(problems, errors.New("invalid DB_PASSWORD: must be set in production"))
+	}
+	if c.DBSSLMode == "disable" {
+		problems = append(problems, errors.New("invalid DB_SSLMODE: must not be disable in production"))
+	}
+	return problems
+}
+
+// loadDotenv sets the variables of the dotenv file of the environment, such as
+// .env.production, and of .env that are not already set in the process
+// environment, and returns the environment. The environment is APP_ENV from
+// the process environment or, failing that, from .env.
+func loadDotenv() (string, error) {
+	base, err := readDotenv(".env")
+	if err != nil {
+		return "", err
+	}
+
+	env, ok := os.LookupEnv("APP_ENV")
+	if !ok {
+		env = base["APP_ENV"]
+	}
+	if env == "" {
+		env = EnvDevelopment
+	}
+
+	profile, err := readDotenv(".env." + env)
+	if err != nil {
+		return "", err
+	}
+
+	for _, vars := range []map[string]string{profile, base} {
+		for key, value := range vars {
+			setFromFile(key, value)
+		}
+	}
+	return env, nil
+}
+
+// fromFiles records the variables set by setFromFile, with their values.
+var fromFiles = struct {
+	sync.Mutex
+	vars map[string]string
+}{vars: map[string]string{}}
+
+// setFromFile sets the environment variable key to value, read from a dotenv
+// or config file, unless it is already set, and records it so that
+// unsetFromFiles can undo it.
+func setFromFile(key, value string) {
+	if _, set := os.LookupEnv(key); set {
+		return
+	}
+	os.Setenv(key, value)
+
+	fromFiles.Lock()
+	defer fromFiles.Unlock()
+	fromFiles.vars[key] = value
+}
+
+// unsetFromFiles unsets the variables set by setFromFile that still hold the
+// value it set, so that reloading the configuration rereads the files instead
+// of seeing their old values as if they came from the process environment.
+func unsetFromFiles() {
+	fromFiles.Lock()
+	defer fromFiles.Unlock()
+	for key, value := range fromFiles.vars {
+		if current, set := os.LookupEnv(key); set && current == value {
+			os.Unsetenv(key)
+		}
+		delete(fromFiles.vars, key)
+	}
+}
+
+// readDotenv reads the variables of the dotenv file name, or none if the file
+// does not exist.
+func readDotenv(name string) (map[string]string, error) {
+	vars, err := godotenv.Read(name)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error loading %s file: %v", name, err)
+	}
+	return vars, nil
+}
+
+// ephemeralSecret returns a random JWT secret for development.
+func ephemeralSecret() (string, error) {
+	b := make([]byte, minProductionJWTSecretLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate JWT secret: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
 // getEnv retrieves the value of the environment variable named by the key.
 // If the variable is present in the environment, the function returns its value.
 // Otherwise, it returns the specified defaultValue.
@@ -90,13 +1176,152 @@ func getEnv(key, defaultValue string) string {
 	return value
 }
 
+// getList retrieves the comma-separated list in the environment variable named
+// by key, or in defaultValue if it is not set. Surrounding whitespace and
+// empty items are dropped.
+func getList(key, defaultValue string) []string {
+	var items []string
+	for _, item := range strings.Split(getEnv(key, defaultValue), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// normalizeDomains lowercases domains and strips the trailing dot of fully
+// qualified names, as email domains are compared.
+func normalizeDomains(domains []string) []string {
+	for i, domain := range domains {
+		domains[i] = strings.TrimSuffix(strings.ToLower(domain), ".")
+	}
+	return domains
+}
+
+// getRouteLimits retrieves the comma-separated route limits in the
+// environment variable named by key, each written as "METHOD /path=limit",
+// keyed by "METHOD /path". It returns nil if the variable is empty, and an
+// error if an item is malformed or its limit is not a positive integer.
+func getRouteLimits(key string) (map[string]int, error) {
+	items := getList(key, "")
+	if len(items) == 0 {
+		return nil, nil
+	}
+
+	limits := make(map[string]int, len(items))
+	for _, item := range items {
+		route, value, ok := strings.Cut(item, "=")
+		method, path, hasPath := strings.Cut(strings.TrimSpace(route), " ")
+		path = strings.TrimSpace(path)
+		if !ok || !hasPath || method == "" || !strings.HasPrefix(path, "/") {
+			return nil, fmt.Errorf("invalid %s: %q must be a method, a path and a limit, such as \"POST /api/auth/login=20\"", key, item)
+		}
+		limit, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("invalid %s: the limit of %q must be a positive integer", key, route)
+		}
+		limits[strings.ToUpper(method)+" "+path] = limit
+	}
+	return limits, nil
+}
+
+// getSecret retrieves a secret from the environment variable named by key or,
+// when the variable key+"_FILE" is set, from the file it names, as Docker and
+// Kubernetes secrets are mounted. The file takes precedence over the variable,
+// and a trailing newline in it is ignored. If neither is set, it returns
+// defaultValue. It returns an error naming the path if the file cannot be read.
+func getSecret(key, defaultValue string) (string, error) {
+	path, ok := os.LookupEnv(key + "_FILE")
+	if !ok {
+		return getEnv(key, defaultValue), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s_FILE: cannot read %s: %w", key, path, err)
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r"), nil
+}
+
+// getDuration reads the environment variable named by key as a time.Duration,
+// falling back to defaultValue when it is not set. It returns an error if the
+// value cannot be parsed or is not positive.
+func getDuration(key, defaultValue string) (time.Duration, error) {
+	d, err := time.ParseDuration(getEnv(key, defaultValue))
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid %s: must be a positive duration", key)
+	}
+	return d, nil
+}
+
+// getTime reads the environment variable named by key as an RFC 3339
+// timestamp, returning the zero time when it is not set. It returns an error
+// if the value cannot be parsed.
+func getTime(key string) (time.Time, error) {
+	value := getEnv(key, "")
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s: must be an RFC 3339 timestamp", key)
+	}
+	return t, nil
+}
+
+// IsDevelopment reports whether the application is running in the development environment.
+func (c *Config) IsDevelopment() bool {
+	return c.Env == EnvDevelopment
+}
+
+// IsProduction reports whether the application is running in the production environment.
+func (c *Config) IsProduction() bool {
+	return c.Env == EnvProduction
+}
+
+// ProblemDetails reports whether error responses default to RFC 7807 problem details.
+func (c *Config) ProblemDetails() bool {
+	return c.ErrorFormat == ErrorFormatProblem
+}
+
+// RestrictExpiredPasswords reports whether users whose password expired are
+// kept from protected routes until they change it, which PASSWORD_EXPIRY_MODE
+// restrict and strict do when PASSWORD_MAX_AGE is set.
+func (c *Config) RestrictExpiredPasswords() bool {
+	return c.PasswordMaxAge > 0 && c.PasswordExpiryMode != PasswordExpiryFlag
+}
+
+// UserCacheTTLInUse returns how long cached users stay fresh: CACHE_TTL when
+// they are cached in Redis, and USER_CACHE_TTL when they are cached in process.
+func (c *Config) UserCacheTTLInUse() time.Duration {
+	if c.RedisAddr != "" {
+		return c.CacheTTL
+	}
+	return c.UserCacheTTL
+}
+
 // DBURL constructs and returns the database connection URL string
 // based on the configuration fields of the Config struct.
-// The returned URL includes the host, user, password, database name,
-// port, and disables SSL mode.
+// When DatabaseURL is set, it is returned unchanged. Otherwise the returned
+// libpq key/value string includes the host, user, password, database name,
+// port and SSL mode, each quoted as needed.
 func (c *Config) DBURL() string {
+	if c.DatabaseURL != "" {
+		return c.DatabaseURL
+	}
 	return fmt.Sprintf(
-		"host=%s user=%s password=%s dbname=%s port=%s sslmode=disable",
-		c.DBHost, c.DBUser, c.DBPassword, c.DBName, c.DBPort,
+		"host=%s user=%s password=%s dbname=%s port=%s sslmode=%s",
+		dsnValue(c.DBHost), dsnValue(c.DBUser), dsnValue(c.DBPassword),
+		dsnValue(c.DBName), dsnValue(c.DBPort), dsnValue(c.DBSSLMode),
 	)
 }
+
+// dsnValue returns value as it must appear in a libpq key/value connection
+// string: empty values and values containing whitespace, "=", quotes or
+// backslashes are single-quoted, with quotes and backslashes escaped.
+func dsnValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t\n\r\v\f='\\") {
+		return value
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"log/slog"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// redacted replaces secret values in the output of String and LogValue.
+const redacted = "***"
+
+// String returns every setting of c as NAME=value lines, in the order of
+// the Config fields with those of Dynamic last, with secrets masked as "***" so that the result is safe
+// to print. Empty secrets are shown as empty, to tell them apart from set
+// ones. URLs tagged secret:"url" only have their password masked.
+func (c *Config) String() string {
+	var b strings.Builder
+	for _, attr := range c.attrs() {
+		b.WriteString(strings.ToUpper(attr.Key))
+		b.WriteByte('=')
+		b.WriteString(attr.Value.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// LogValue implements slog.LogValuer, logging c as a group with one
+// attribute per setting, masked like String.
+func (c *Config) LogValue() slog.Value {
+	return slog.GroupValue(c.attrs()...)
+}
+
+// attrs returns one attribute per setting of c, keyed by its yaml tag, with
+// secrets masked.
+func (c *Config) attrs() []slog.Attr {
+	v := reflect.ValueOf(*c)
+	fields := settingFields()
+	attrs := make([]slog.Attr, 0, len(fields))
+	for _, field := range fields {
+		value := v.FieldByIndex(field.Index).Interface()
+		switch field.Tag.Get("secret") {
+		case "true":
+			if value != "" {
+				value = redacted
+			}
+		case "url":
+			value = redactURL(value.(string))
+		}
+		attrs = append(attrs, slog.Any(field.Tag.Get("yaml"), value))
+	}
+	return attrs
+}
+
+// redactURL masks the password of raw, a URL or a libpq key/value string,
+// whether it is in the user info or the password query parameter.
+// Values that cannot be parsed as a URL are masked entirely, since they may
+// hold a password anywhere.
+func redactURL(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" || u.Opaque != "" {
+		return redacted
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+	}
+	if query := u.Query(); query.Has("password") {
+		query.Set("password", redacted)
+		u.RawQuery = query.Encode()
+	}
+	return strings.ReplaceAll(u.String(), url.QueryEscape(redacted), redacted)
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// loadFile applies the YAML config file at path. Each key is the lowercase
+// name of an environment variable, and the file sets that variable unless it
+// is already set, so environment variables override the file and defaults
+// fill in whatever neither sets. Keys that do not name a config field are
+// logged as a warning, since they are most likely typos.
+func loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error loading config file %s: %w", path, err)
+	}
+
+	var values map[string]interface{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("error loading config file %s: %w", path, err)
+	}
+
+	known := fileKeys()
+	var unknown []string
+	for key, value := range values {
+		if !known[key] {
+			unknown = append(unknown, key)
+			continue
+		}
+
+		var str string
+		switch value.(type) {
+		case nil:
+		case map[string]interface{}, []interface{}:
+			return fmt.Errorf("error loading config file %s: %s must be a single value", path, key)
+		default:
+			str = fmt.Sprint(value)
+		}
+
+		setFromFile(strings.ToUpper(key), str)
+	}
+
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		slog.Warn("ignoring unknown keys in config file", "path", path, "keys", unknown)
+	}
+	return nil
+}
+
+// fileKeys returns the keys a config file may set, taken from the yaml tags
+// of Config.
+func fileKeys() map[string]bool {
+	fields := settingFields()
+	keys := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		keys[field.Tag.Get("yaml")] = true
+	}
+	return keys
+}
+
+// settingFields returns the fields of Config that hold a setting, including
+// those promoted from Dynamic, in the order they are declared.
+func settingFields() []reflect.StructField {
+	var fields []reflect.StructField
+	for _, field := range reflect.VisibleFields(reflect.TypeOf(Config{})) {
+		if field.Anonymous || !field.IsExported() {
+			continue
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
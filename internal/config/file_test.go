@@ -0,0 +1,132 @@
+package config
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeConfigFile writes content to a YAML config file in a temporary directory
+// and returns its path.
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadConfigFile_Precedence(t *testing.T) {
+	path := writeConfigFile(t, `
+jwt_secret: file-secret
+db_host: file-host
+db_name: file-db
+db_query_timeout: 2s
+rate_limit_requests: 20
+hibp_enabled: true
+`)
+
+	os.Clearenv()
+	os.Setenv("DB_NAME", "env-db")
+	os.Setenv("RATE_LIMIT_REQUESTS", "30")
+
+	got, err := LoadConfigFile(path)
+	require.NoError(t, err)
+
+	// Defaults fill what neither sets.
+	assert.Equal(t, "postgres", got.DBUser)
+	assert.Equal(t, time.Minute, got.RateLimitWindow)
+	// The file overrides defaults.
+	assert.Equal(t, "file-secret", got.JWTSecret)
+	assert.Equal(t, "file-host", got.DBHost)
+	assert.Equal(t, 2*time.Second, got.DBQueryTimeout)
+	assert.True(t, got.BreachCheckEnabled)
+	// The environment overrides the file.
+	assert.Equal(t, "env-db", got.DBName)
+	assert.Equal(t, 30, got.RateLimitRequests)
+}
+
+func TestLoadConfigFile_FromEnvironment(t *testing.T) {
+	path := writeConfigFile(t, "jwt_secret: file-secret\napp_env: test\n")
+
+	os.Clearenv()
+	os.Setenv("CONFIG_FILE", path)
+
+	got, err := LoadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "file-secret", got.JWTSecret)
+	assert.Equal(t, EnvTest, got.Env)
+}
+
+func TestLoadConfigFile_UnknownKeysWarn(t *testing.T) {
+	var logs bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+
+	path := writeConfigFile(t, "jwt_secret: file-secret\ndb_hots: typo\njwt_expiry: 1h\n")
+	os.Clearenv()
+
+	_, err := LoadConfigFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, logs.String(), "ignoring unknown keys in config file")
+	assert.Contains(t, logs.String(), "keys=\"[db_hots jwt_expiry]\"")
+	_, set := os.LookupEnv("DB_HOTS")
+	assert.False(t, set)
+}
+
+func TestLoadConfigFile_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        func(*testing.T) string
+		errContains string
+	}{
+		{
+			name:        "missing file",
+			path:        func(t *testing.T) string { return filepath.Join(t.TempDir(), "missing.yaml") },
+			errContains: "error loading config file",
+		},
+		{
+			name:        "malformed YAML",
+			path:        func(t *testing.T) string { return writeConfigFile(t, "jwt_secret: [unclosed\n") },
+			errContains: "error loading config file",
+		},
+		{
+			name:        "nested value",
+			path:        func(t *testing.T) string { return writeConfigFile(t, "db_host:\n  primary: localhost\n") },
+			errContains: "db_host must be a single value",
+		},
+		{
+			name:        "invalid value",
+			path:        func(t *testing.T) string { return writeConfigFile(t, "jwt_secret: s\ncache_ttl: soon\n") },
+			errContains: "invalid CACHE_TTL",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+
+			_, err := LoadConfigFile(tt.path(t))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errContains)
+		})
+	}
+}
+
+func TestFileKeys(t *testing.T) {
+	keys := fileKeys()
+
+	assert.True(t, keys["db_host"])
+	assert.True(t, keys["refresh_token_expiry"])
+	// Dynamic settings can be set from the file like the others.
+	assert.True(t, keys["log_level"])
+	assert.True(t, keys["rate_limit_window"])
+	assert.False(t, keys[""], "embedded and unexported fields are not settings")
+	assert.Len(t, keys, len(settingFields()))
+}
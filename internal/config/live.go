@@ -0,0 +1,138 @@
+package config
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/PakornBank/learn-go/internal/logger"
+)
+
+// Live holds the Dynamic settings of the running server. Middlewares and
+// services read them on every request through Dynamic, and Reload replaces
+// them atomically, so they can change without a restart. Settings outside
+// Dynamic keep the values the server started with.
+type Live struct {
+	path     string
+	static   *Config
+	dynamic  atomic.Pointer[Dynamic]
+	logLevel *slog.LevelVar
+
+	// mu serializes reloads, which modify the process environment.
+	mu sync.Mutex
+}
+
+// NewLive creates a Live holding the dynamic settings of config, which was
+// loaded from the config file at path, or from CONFIG_FILE if path is empty.
+func NewLive(config *Config, path string) *Live {
+	l := &Live{
+		path:     path,
+		static:   config,
+		logLevel: new(slog.LevelVar),
+	}
+	dynamic := config.Dynamic
+	l.dynamic.Store(&dynamic)
+	// LOG_LEVEL was checked by Validate; an unknown one leaves the level at INFO.
+	if level, err := logger.ParseLevel(dynamic.LogLevel); err == nil {
+		l.logLevel.Set(level)
+	}
+	return l
+}
+
+// Dynamic returns the current dynamic settings. The result must not be modified.
+func (l *Live) Dynamic() *Dynamic {
+	return l.dynamic.Load()
+}
+
+// LogLevel returns the level of the application logger. Reload sets it when
+// LOG_LEVEL changes, and it may also be changed directly, for example by the
+// admin log level endpoint.
+func (l *Live) LogLevel() *slog.LevelVar {
+	return l.logLevel
+}
+
+// Reload loads the configuration again from the environment, dotenv files and
+// config file, and replaces the dynamic settings with the new values. If the
+// new configuration is invalid, it keeps the current settings, logs why and
+// returns the error. Changes to settings outside Dynamic, such as DB_HOST or
+// SERVER_PORT, are logged as a warning since they only apply after a restart.
+func (l *Live) Reload() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	next, err := LoadConfigFile(l.path)
+	if err != nil {
+		slog.Error("configuration reload rejected, keeping the current settings", "error", err)
+		return err
+	}
+
+	if changed := staticChanges(l.static, next); len(changed) > 0 {
+		slog.Warn("configuration reload changed settings that only apply after a restart", "keys", changed)
+	}
+
+	previous := l.dynamic.Load()
+	dynamic := next.Dynamic
+	l.dynamic.Store(&dynamic)
+	if dynamic.LogLevel != previous.LogLevel {
+		level, _ := logger.ParseLevel(dynamic.LogLevel)
+		l.logLevel.Set(level)
+	}
+
+	slog.Info("configuration reloaded", "dynamic", dynamic)
+	return nil
+}
+
+// ReloadOnSignal calls Reload whenever the process receives one of signals,
+// typically SIGHUP, until ctx is done. Failed reloads are logged by Reload
+// and otherwise ignored.
+func (l *Live) ReloadOnSignal(ctx context.Context, signals ...os.Signal) {
+	received := make(chan os.Signal, 1)
+	signal.Notify(received, signals...)
+	defer signal.Stop(received)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-received:
+			slog.Info("reloading configuration", "signal", sig.String())
+			_ = l.Reload()
+		}
+	}
+}
+
+// LogValue implements slog.LogValuer, logging d as a group with one
+// attribute per setting.
+func (d Dynamic) LogValue() slog.Value {
+	v := reflect.ValueOf(d)
+	attrs := make([]slog.Attr, 0, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		attrs = append(attrs, slog.Any(v.Type().Field(i).Tag.Get("yaml"), v.Field(i).Interface()))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// staticChanges returns the names of the environment variables of the
+// settings outside Dynamic that differ between running and next. A generated
+// development JWT secret counts as unchanged as long as none is configured.
+func staticChanges(running, next *Config) []string {
+	current, updated := reflect.ValueOf(*running), reflect.ValueOf(*next)
+	var changed []string
+	for _, field := range settingFields() {
+		if len(field.Index) > 1 {
+			continue // promoted from Dynamic
+		}
+		if field.Name == "JWTSecret" && running.jwtSecretGenerated && next.jwtSecretGenerated {
+			continue
+		}
+		if !reflect.DeepEqual(current.FieldByIndex(field.Index).Interface(), updated.FieldByIndex(field.Index).Interface()) {
+			changed = append(changed, strings.ToUpper(field.Tag.Get("yaml")))
+		}
+	}
+	return changed
+}
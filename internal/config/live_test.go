@@ -0,0 +1,175 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/ratelimit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// captureLogs makes the default logger write to the returned buffer for the
+// rest of the test.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var logs bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+	return &logs
+}
+
+// setupLiveTest loads the configuration from env and returns a Live holding it.
+func setupLiveTest(t *testing.T, path string, env map[string]string) *Live {
+	t.Helper()
+	os.Clearenv()
+	for k, v := range env {
+		os.Setenv(k, v)
+	}
+
+	config, err := LoadConfigFile(path)
+	require.NoError(t, err)
+	return NewLive(config, path)
+}
+
+func TestLive_ReloadAppliesRateLimits(t *testing.T) {
+	captureLogs(t)
+	live := setupLiveTest(t, "", map[string]string{"JWT_SECRET": "test-secret", "RATE_LIMIT_REQUESTS": "1"})
+	limiter := ratelimit.NewMemory(func() (int, time.Duration) {
+		return live.Dynamic().RateLimitRequests, live.Dynamic().RateLimitWindow
+	})
+	ctx := context.Background()
+
+	first, _ := limiter.Allow(ctx, "login:10.0.0.1")
+	assert.True(t, first.Allowed)
+	denied, _ := limiter.Allow(ctx, "login:10.0.0.1")
+	assert.False(t, denied.Allowed)
+
+	os.Setenv("RATE_LIMIT_REQUESTS", "3")
+	require.NoError(t, live.Reload())
+
+	assert.Equal(t, 3, live.Dynamic().RateLimitRequests)
+	allowed, _ := limiter.Allow(ctx, "login:10.0.0.1")
+	assert.True(t, allowed.Allowed)
+	assert.Equal(t, 1, allowed.Remaining)
+}
+
+func TestLive_ReloadRereadsConfigFile(t *testing.T) {
+	captureLogs(t)
+	path := writeConfigFile(t, "jwt_secret: file-secret\nregistration_enabled: true\nrate_limit_window: 1m\n")
+	live := setupLiveTest(t, path, nil)
+	require.True(t, live.Dynamic().RegistrationEnabled)
+
+	require.NoError(t, os.WriteFile(path, []byte("jwt_secret: file-secret\nregistration_enabled: false\nrate_limit_window: 30s\nregistration_email_domains: corp.example.com\n"), 0o600))
+	require.NoError(t, live.Reload())
+
+	assert.False(t, live.Dynamic().RegistrationEnabled)
+	assert.Equal(t, 30*time.Second, live.Dynamic().RateLimitWindow)
+	assert.Equal(t, []string{"corp.example.com"}, live.Dynamic().RegistrationEmailDomains)
+}
+
+func TestLive_ReloadRejectsInvalidConfig(t *testing.T) {
+	logs := captureLogs(t)
+	live := setupLiveTest(t, "", map[string]string{"JWT_SECRET": "test-secret", "RATE_LIMIT_REQUESTS": "5"})
+	before := live.Dynamic()
+
+	os.Setenv("RATE_LIMIT_REQUESTS", "10")
+	os.Setenv("LOG_LEVEL", "verbose")
+	os.Setenv("SERVER_PORT", "http")
+	err := live.Reload()
+
+	require.Error(t, err)
+	assert.Same(t, before, live.Dynamic(), "the current settings are kept")
+	assert.Equal(t, 5, live.Dynamic().RateLimitRequests)
+	assert.Contains(t, logs.String(), "configuration reload rejected, keeping the current settings")
+	assert.Contains(t, logs.String(), "invalid LOG_LEVEL")
+	assert.Contains(t, logs.String(), "invalid SERVER_PORT")
+}
+
+func TestLive_ReloadWarnsAboutStaticChanges(t *testing.T) {
+	logs := captureLogs(t)
+	live := setupLiveTest(t, "", map[string]string{"JWT_SECRET": "test-secret", "DB_HOST": "db-1"})
+
+	os.Setenv("DB_HOST", "db-2")
+	os.Setenv("SERVER_PORT", "9090")
+	os.Setenv("REGISTRATION_ENABLED", "false")
+	require.NoError(t, live.Reload())
+
+	assert.Contains(t, logs.String(), "configuration reload changed settings that only apply after a restart")
+	assert.Contains(t, logs.String(), "keys=\"[DB_HOST SERVER_PORT]\"")
+	assert.False(t, live.Dynamic().RegistrationEnabled, "dynamic settings still apply")
+
+	// The running values stay the reference until the server restarts.
+	logs.Reset()
+	require.NoError(t, live.Reload())
+	assert.Contains(t, logs.String(), "keys=\"[DB_HOST SERVER_PORT]\"")
+}
+
+func TestLive_ReloadIgnoresGeneratedJWTSecret(t *testing.T) {
+	logs := captureLogs(t)
+	live := setupLiveTest(t, "", nil)
+
+	require.NoError(t, live.Reload())
+	assert.NotContains(t, logs.String(), "only apply after a restart")
+}
+
+func TestLive_ReloadLogLevel(t *testing.T) {
+	captureLogs(t)
+	live := setupLiveTest(t, "", map[string]string{"JWT_SECRET": "test-secret", "LOG_LEVEL": "info"})
+	assert.Equal(t, slog.LevelInfo, live.LogLevel().Level())
+
+	os.Setenv("LOG_LEVEL", "debug")
+	require.NoError(t, live.Reload())
+	assert.Equal(t, slog.LevelDebug, live.LogLevel().Level())
+
+	// A level changed at runtime survives reloads that leave LOG_LEVEL alone.
+	live.LogLevel().Set(slog.LevelWarn)
+	os.Setenv("RATE_LIMIT_REQUESTS", "20")
+	require.NoError(t, live.Reload())
+	assert.Equal(t, slog.LevelWarn, live.LogLevel().Level())
+}
+
+func TestLive_ReloadOnSignal(t *testing.T) {
+	captureLogs(t)
+	live := setupLiveTest(t, "", map[string]string{"JWT_SECRET": "test-secret", "RATE_LIMIT_REQUESTS": "10"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		live.ReloadOnSignal(ctx, syscall.SIGHUP)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	os.Setenv("RATE_LIMIT_REQUESTS", "2")
+	process, err := os.FindProcess(os.Getpid())
+	require.NoError(t, err)
+	// The signal may arrive before ReloadOnSignal is listening, so keep sending it.
+	assert.Eventually(t, func() bool {
+		require.NoError(t, process.Signal(syscall.SIGHUP))
+		return live.Dynamic().RateLimitRequests == 2
+	}, 2*time.Second, 20*time.Millisecond)
+}
+
+func TestDynamic_LogValue(t *testing.T) {
+	var logs bytes.Buffer
+	slog.New(slog.NewTextHandler(&logs, nil)).Info("reloaded", "dynamic", Dynamic{
+		LogLevel:          "warn",
+		RateLimitRequests: 3,
+		RateLimitWindow:   time.Minute,
+	})
+
+	assert.Contains(t, logs.String(), "dynamic.log_level=warn")
+	assert.Contains(t, logs.String(), "dynamic.registration_enabled=false")
+	assert.Contains(t, logs.String(), "dynamic.rate_limit_requests=3")
+	assert.Contains(t, logs.String(), "dynamic.rate_limit_window=1m0s")
+}
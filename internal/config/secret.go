@@ -0,0 +1,60 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"math"
+)
+
+// generatedSecretLength is the number of random bytes in a GenerateSecret
+// secret.
+const generatedSecretLength = 48
+
+// minJWTSecretEntropy is the estimated entropy, in bits, below which a
+// JWT_SECRET is weak however long it is. The estimate undercounts random
+// secrets, so it is set below the 128 bits of a 32-character hex secret.
+const minJWTSecretEntropy = 96
+
+// GenerateSecret returns a cryptographically random secret of 48 bytes,
+// base64 encoded, for use as JWT_SECRET.
+func GenerateSecret() (string, error) {
+	b := make([]byte, generatedSecretLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate secret: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// JWTSecretWeakness returns why JWTSecret is weak, being shorter than 32
+// bytes or too predictable, such as a word repeated to length, or an empty
+// string if it is not. Production rejects short secrets, see Validate; other
+// weak ones are only warned about, see LoadConfig.
+func (c *Config) JWTSecretWeakness() string {
+	if len(c.JWTSecret) < minProductionJWTSecretLength {
+		return fmt.Sprintf("shorter than %d bytes", minProductionJWTSecretLength)
+	}
+	if bits := secretEntropy(c.JWTSecret); bits < minJWTSecretEntropy {
+		return fmt.Sprintf("estimated entropy of %.0f bits is below %d", bits, minJWTSecretEntropy)
+	}
+	return ""
+}
+
+// secretEntropy estimates the entropy of secret in bits from how often each
+// of its bytes occurs, times its length.
+func secretEntropy(secret string) float64 {
+	var counts [256]int
+	for i := 0; i < len(secret); i++ {
+		counts[secret[i]]++
+	}
+
+	var perByte float64
+	for _, n := range counts {
+		if n == 0 {
+			continue
+		}
+		p := float64(n) / float64(len(secret))
+		perByte -= p * math.Log2(p)
+	}
+	return perByte * float64(len(secret))
+}
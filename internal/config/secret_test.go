@@ -0,0 +1,80 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+
+	assert.Len(t, secret, 64, "48 bytes in base64")
+	assert.Regexp(t, regexp.MustCompile(`^[A-Za-z0-9+/]+$`), secret)
+	decoded, err := base64.StdEncoding.DecodeString(secret)
+	require.NoError(t, err)
+	assert.Len(t, decoded, 48)
+
+	other, err := GenerateSecret()
+	require.NoError(t, err)
+	assert.NotEqual(t, secret, other)
+	assert.Empty(t, (&Config{JWTSecret: secret}).JWTSecretWeakness())
+}
+
+func TestConfig_JWTSecretWeakness(t *testing.T) {
+	tests := []struct {
+		name         string
+		secret       string
+		wantContains string
+	}{
+		{name: "eight characters", secret: "s3cr3t!!", wantContains: "shorter than 32 bytes"},
+		{name: "one byte short", secret: productionSecret[:31], wantContains: "shorter than 32 bytes"},
+		{name: "32 hex characters", secret: productionSecret},
+		{name: "repeated character", secret: strings.Repeat("a", 64), wantContains: "estimated entropy of 0 bits is below 96"},
+		{name: "repeated word", secret: strings.Repeat("password", 4), wantContains: "estimated entropy"},
+		{name: "ephemeral development secret", secret: strings.Repeat("0123456789abcdef", 4)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			weakness := (&Config{JWTSecret: tt.secret}).JWTSecretWeakness()
+			if tt.wantContains == "" {
+				assert.Empty(t, weakness)
+			} else {
+				assert.Contains(t, weakness, tt.wantContains)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_WeakJWTSecretWarns(t *testing.T) {
+	var logs bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+
+	os.Clearenv()
+	os.Setenv("APP_ENV", EnvTest)
+	os.Setenv("JWT_SECRET", "s3cr3t!!")
+
+	got, err := LoadConfig()
+	require.NoError(t, err, "weak secrets are only rejected in production")
+	assert.Equal(t, "s3cr3t!!", got.JWTSecret)
+	assert.Contains(t, logs.String(), "JWT_SECRET is weak")
+	assert.Contains(t, logs.String(), `reason="shorter than 32 bytes"`)
+	assert.NotContains(t, logs.String(), "s3cr3t!!", "the secret is not logged")
+
+	logs.Reset()
+	os.Setenv("JWT_SECRET", productionSecret)
+	_, err = LoadConfig()
+	require.NoError(t, err)
+	assert.NotContains(t, logs.String(), "JWT_SECRET is weak")
+}
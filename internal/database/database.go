@@ -3,6 +3,7 @@ package database
 
 import (
 	"fmt"
+	"log/slog"
 
 	"github.com/PakornBank/learn-go/internal/config"
 	"github.com/PakornBank/learn-go/internal/model"
@@ -12,23 +13,49 @@ import (
 
 // NewDataBase initializes a new database connection using the provided configuration.
 // It connects to a PostgreSQL database using the DBURL from the config and performs
-// auto-migration for the User model.
+// auto-migration for the Models. Database logs are written to log through a
+// GormLogger, with bound parameters elided from the SQL in production.
 //
 // Parameters:
 //   - config: A pointer to a config.Config struct containing the database configuration.
+//   - log: The application logger that database logs are written to.
 //
 // Returns:
 //   - *gorm.DB: A pointer to the initialized gorm.DB instance.
 //   - error: An error if the connection or migration fails, otherwise nil.
-func NewDataBase(config *config.Config) (*gorm.DB, error) {
-	db, err := gorm.Open(postgres.Open(config.DBURL()), &gorm.Config{})
+func NewDataBase(config *config.Config, log *slog.Logger) (*gorm.DB, error) {
+	db, err := gorm.Open(postgres.Open(config.DBURL()), &gorm.Config{
+		Logger: NewGormLogger(log, config.DBSlowQuery, config.IsProduction()),
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
-	if err := db.AutoMigrate(&model.User{}); err != nil {
+	if err := Migrate(db, Models()...); err != nil {
 		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
 
 	return db, nil
 }
+
+// Models returns a new value of every model NewDataBase migrates the tables of.
+func Models() []any {
+	return []any{&model.User{}, &model.RefreshToken{}, &model.LoginEvent{}, &model.OutboxEvent{}, &model.EmailChangeRequest{}, &model.AuditEntry{}, &model.QueuedEmail{}, &model.LoginAlert{}, &model.RecoveryRequest{}, &model.Announcement{}, &model.Identity{}, &model.RoleGrant{}}
+}
+
+// NewReplica opens the read replica at the DBReplicaURL of config, logging to
+// log like NewDataBase. The replica follows the schema of the primary, so it
+// is not migrated. It returns nil and no error when no replica is configured.
+func NewReplica(config *config.Config, log *slog.Logger) (*gorm.DB, error) {
+	if config.DBReplicaURL == "" {
+		return nil, nil
+	}
+
+	db, err := gorm.Open(postgres.Open(config.DBReplicaURL), &gorm.Config{
+		Logger: NewGormLogger(log, config.DBSlowQuery, config.IsProduction()),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to read replica: %w", err)
+	}
+	return db, nil
+}
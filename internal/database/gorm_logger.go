@@ -0,0 +1,137 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/logger"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+var explainedPlaceholder = regexp.MustCompile(`\$(\d+)\$`)
+
+// GormLogger implements gorm's logger.Interface on top of slog so that
+// database logs share the application's structured format.
+//
+// Failed queries are logged at ERROR, queries slower than the slow-query
+// threshold at WARN, and every other query at DEBUG. Each record carries the
+// request ID from the query's context when there is one. When parameterized
+// is set, bound parameters are elided from the logged SQL so that user data
+// never ends up in the logs.
+type GormLogger struct {
+	log           *slog.Logger
+	level         gormlogger.LogLevel
+	slowThreshold time.Duration
+	parameterized bool
+}
+
+// NewGormLogger creates a GormLogger writing to log.
+//
+// Parameters:
+//   - log: The logger that records are written to.
+//   - slowThreshold: Queries taking longer than this are logged as slow. Zero disables slow-query warnings.
+//   - parameterized: Whether to elide bound parameters from the logged SQL.
+//
+// Returns:
+//   - *GormLogger: A logger ready to be passed to gorm.Config.
+func NewGormLogger(log *slog.Logger, slowThreshold time.Duration, parameterized bool) *GormLogger {
+	return &GormLogger{
+		log:           log,
+		level:         gormlogger.Info,
+		slowThreshold: slowThreshold,
+		parameterized: parameterized,
+	}
+}
+
+// LogMode returns a copy of the logger that only emits records at or above level.
+func (l *GormLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	clone := *l
+	clone.level = level
+	return &clone
+}
+
+// Info logs a formatted message from gorm at INFO.
+func (l *GormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Info {
+		l.log.InfoContext(ctx, fmt.Sprintf(msg, args...), l.requestAttrs(ctx)...)
+	}
+}
+
+// Warn logs a formatted message from gorm at WARN.
+func (l *GormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Warn {
+		l.log.WarnContext(ctx, fmt.Sprintf(msg, args...), l.requestAttrs(ctx)...)
+	}
+}
+
+// Error logs a formatted message from gorm at ERROR.
+func (l *GormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Error {
+		l.log.ErrorContext(ctx, fmt.Sprintf(msg, args...), l.requestAttrs(ctx)...)
+	}
+}
+
+// Trace logs a single executed statement along with its duration and the number
+// of rows affected. Record-not-found errors are not treated as failures since
+// they are an expected outcome of lookups. The SQL is only rendered when the
+// slog handler accepts the record's level, so lowering the application log
+// level at runtime, for example to DEBUG, applies to queries as well.
+func (l *GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	if l.level <= gormlogger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	var (
+		level slog.Level
+		msg   string
+		extra []any
+	)
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && l.level >= gormlogger.Error:
+		level, msg, extra = slog.LevelError, "query failed", []any{slog.String("error", err.Error())}
+	case l.slowThreshold > 0 && elapsed > l.slowThreshold && l.level >= gormlogger.Warn:
+		level, msg, extra = slog.LevelWarn, "slow query", []any{slog.Duration("threshold", l.slowThreshold)}
+	case l.level >= gormlogger.Info:
+		level, msg = slog.LevelDebug, "query"
+	default:
+		return
+	}
+	if !l.log.Enabled(ctx, level) {
+		return
+	}
+
+	sql, rows := fc()
+	if l.parameterized {
+		// Without parameters, the postgres dialector leaves its internal "$1$"
+		// markers in the explained SQL; restore them to regular placeholders.
+		sql = explainedPlaceholder.ReplaceAllString(sql, "$$$1")
+	}
+	attrs := append(l.requestAttrs(ctx),
+		slog.String("sql", sql),
+		slog.Int64("rows", rows),
+		slog.Duration("elapsed", elapsed),
+	)
+	l.log.Log(ctx, level, msg, append(attrs, extra...)...)
+}
+
+// ParamsFilter implements gorm.ParamsFilter. When the logger is parameterized it
+// drops the bound parameters so the logged SQL keeps its placeholders.
+func (l *GormLogger) ParamsFilter(_ context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	if l.parameterized {
+		return sql, nil
+	}
+	return sql, params
+}
+
+func (l *GormLogger) requestAttrs(ctx context.Context) []any {
+	if requestID := logger.RequestIDFromContext(ctx); requestID != "" {
+		return []any{slog.String("request_id", requestID)}
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PakornBank/learn-go/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// captureHandler is a slog.Handler that records every log record for assertions.
+type captureHandler struct {
+	mu      sync.Mutex
+	records []slog.Record
+}
+
+func (h *captureHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h *captureHandler) Handle(_ context.Context, record slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.records = append(h.records, record)
+	return nil
+}
+
+func (h *captureHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+
+func (h *captureHandler) WithGroup(string) slog.Handler { return h }
+
+func (h *captureHandler) attrs(record slog.Record) map[string]string {
+	attrs := map[string]string{}
+	record.Attrs(func(attr slog.Attr) bool {
+		attrs[attr.Key] = attr.Value.String()
+		return true
+	})
+	return attrs
+}
+
+type user struct {
+	ID    int
+	Email string
+}
+
+func setupLoggerTest(t *testing.T, slowThreshold time.Duration, parameterized bool) (*gorm.DB, sqlmock.Sqlmock, *captureHandler) {
+	sqlDB, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	handler := &captureHandler{}
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger: NewGormLogger(slog.New(handler), slowThreshold, parameterized),
+	})
+	require.NoError(t, err)
+
+	return db, sqlMock, handler
+}
+
+func TestGormLogger_Trace(t *testing.T) {
+	ctx := logger.WithRequestID(context.Background(), "req-123")
+
+	tests := []struct {
+		name          string
+		slowThreshold time.Duration
+		parameterized bool
+		mockFn        func(sqlmock.Sqlmock)
+		wantLevel     slog.Level
+		wantMsg       string
+		wantSQL       string
+		wantRows      string
+		wantErr       string
+	}{
+		{
+			name:          "fast query logged at debug",
+			slowThreshold: time.Hour,
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectQuery(`SELECT`).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).AddRow(1, "a@example.com"))
+			},
+			wantLevel: slog.LevelDebug,
+			wantMsg:   "query",
+			wantSQL:   `SELECT * FROM "users" WHERE email = 'a@example.com'`,
+			wantRows:  "1",
+		},
+		{
+			name:          "slow query logged at warn",
+			slowThreshold: time.Millisecond,
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectQuery(`SELECT`).
+					WillDelayFor(10 * time.Millisecond).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).AddRow(1, "a@example.com").AddRow(2, "b@example.com"))
+			},
+			wantLevel: slog.LevelWarn,
+			wantMsg:   "slow query",
+			wantSQL:   `SELECT * FROM "users" WHERE email = 'a@example.com'`,
+			wantRows:  "2",
+		},
+		{
+			name:          "failed query logged at error",
+			slowThreshold: time.Hour,
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectQuery(`SELECT`).WillReturnError(errors.New("connection refused"))
+			},
+			wantLevel: slog.LevelError,
+			wantMsg:   "query failed",
+			wantSQL:   `SELECT * FROM "users" WHERE email = 'a@example.com'`,
+			wantRows:  "0",
+			wantErr:   "connection refused",
+		},
+		{
+			name:          "parameters elided when parameterized",
+			slowThreshold: time.Hour,
+			parameterized: true,
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectQuery(`SELECT`).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).AddRow(1, "a@example.com"))
+			},
+			wantLevel: slog.LevelDebug,
+			wantMsg:   "query",
+			wantSQL:   `SELECT * FROM "users" WHERE email = $1`,
+			wantRows:  "1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, sqlMock, handler := setupLoggerTest(t, tt.slowThreshold, tt.parameterized)
+			tt.mockFn(sqlMock)
+
+			var users []user
+			db.WithContext(ctx).Where("email = ?", "a@example.com").Find(&users)
+
+			require.Len(t, handler.records, 1)
+			record := handler.records[0]
+			attrs := handler.attrs(record)
+
+			assert.Equal(t, tt.wantLevel, record.Level)
+			assert.Equal(t, tt.wantMsg, record.Message)
+			assert.Equal(t, tt.wantSQL, attrs["sql"])
+			assert.Equal(t, tt.wantRows, attrs["rows"])
+			assert.Equal(t, "req-123", attrs["request_id"])
+			if tt.wantErr != "" {
+				assert.Equal(t, tt.wantErr, attrs["error"])
+			}
+		})
+	}
+}
+
+func TestGormLogger_RecordNotFoundIsNotAnError(t *testing.T) {
+	db, sqlMock, handler := setupLoggerTest(t, time.Hour, false)
+	sqlMock.ExpectQuery(`SELECT`).WillReturnRows(sqlmock.NewRows([]string{"id", "email"}))
+
+	var found user
+	err := db.First(&found).Error
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	require.Len(t, handler.records, 1)
+	assert.Equal(t, slog.LevelDebug, handler.records[0].Level)
+}
+
+func TestGormLogger_LogMode(t *testing.T) {
+	handler := &captureHandler{}
+	base := NewGormLogger(slog.New(handler), time.Hour, false)
+
+	silent := base.LogMode(gormlogger.Silent)
+	silent.Trace(context.Background(), time.Now(), func() (string, int64) { return "SELECT 1", 0 }, nil)
+	silent.Error(context.Background(), "ignored %s", "message")
+	assert.Empty(t, handler.records)
+
+	base.Warn(context.Background(), "warn %d", 1)
+	require.Len(t, handler.records, 1)
+	assert.Equal(t, "warn 1", handler.records[0].Message)
+	assert.Equal(t, slog.LevelWarn, handler.records[0].Level)
+}
+
+func TestGormLogger_FollowsLevelVar(t *testing.T) {
+	var level slog.LevelVar
+	level.Set(slog.LevelInfo)
+	handler := &captureHandler{}
+	gormLog := NewGormLogger(slog.New(leveledHandler{handler, &level}), time.Hour, false)
+
+	rendered := 0
+	query := func() (string, int64) {
+		rendered++
+		return "SELECT 1", 1
+	}
+
+	gormLog.Trace(context.Background(), time.Now(), query, nil)
+	assert.Empty(t, handler.records)
+	assert.Zero(t, rendered, "SQL should not be rendered when DEBUG is disabled")
+
+	level.Set(slog.LevelDebug)
+	gormLog.Trace(context.Background(), time.Now(), query, nil)
+	require.Len(t, handler.records, 1)
+	assert.Equal(t, "query", handler.records[0].Message)
+
+	level.Set(slog.LevelInfo)
+	gormLog.Trace(context.Background(), time.Now(), query, nil)
+	assert.Len(t, handler.records, 1)
+	assert.Equal(t, 1, rendered)
+}
+
+// leveledHandler filters the records of a captureHandler by a level that can
+// change at runtime, as the application logger does.
+type leveledHandler struct {
+	*captureHandler
+	level slog.Leveler
+}
+
+func (h leveledHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
@@ -0,0 +1,79 @@
+package database
+
+import (
+	"reflect"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// postgresUUIDDefault is the column default the models give their UUID
+// primary keys, which only PostgreSQL can evaluate.
+const postgresUUIDDefault = "gen_random_uuid()"
+
+// generateUUIDCallback is the name of the create callback that stands in for
+// postgresUUIDDefault on other databases.
+const generateUUIDCallback = "database:generate_uuid"
+
+// IsPostgres reports whether db is connected to PostgreSQL, the only database
+// the application is deployed on. Other dialects are used by tests.
+func IsPostgres(db *gorm.DB) bool {
+	return db.Dialector.Name() == "postgres"
+}
+
+// Migrate creates or updates the tables of models. On PostgreSQL it is
+// db.AutoMigrate. On other databases the PostgreSQL-only column defaults are
+// left out of the tables, and the UUID primary keys they would have generated
+// are generated when a row is created instead.
+func Migrate(db *gorm.DB, models ...any) error {
+	if IsPostgres(db) {
+		return db.AutoMigrate(models...)
+	}
+
+	for _, m := range models {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(m); err != nil {
+			return err
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DefaultValue == postgresUUIDDefault {
+				field.DefaultValue = ""
+				field.DefaultValueInterface = nil
+				field.HasDefaultValue = false
+			}
+		}
+	}
+	if db.Callback().Create().Get(generateUUIDCallback) == nil {
+		if err := db.Callback().Create().Before("gorm:create").Register(generateUUIDCallback, generateUUIDs); err != nil {
+			return err
+		}
+	}
+	return db.AutoMigrate(models...)
+}
+
+// generateUUIDs sets a new UUID as the primary key of every row being created
+// without one.
+func generateUUIDs(db *gorm.DB) {
+	if db.Statement.Schema == nil {
+		return
+	}
+	field := db.Statement.Schema.PrioritizedPrimaryField
+	if field == nil || field.FieldType != reflect.TypeOf(uuid.UUID{}) {
+		return
+	}
+
+	ctx := db.Statement.Context
+	setID := func(row reflect.Value) {
+		if _, zero := field.ValueOf(ctx, row); zero {
+			db.AddError(field.Set(ctx, row, uuid.New()))
+		}
+	}
+	switch rv := db.Statement.ReflectValue; rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			setID(reflect.Indirect(rv.Index(i)))
+		}
+	case reflect.Struct:
+		setID(rv)
+	}
+}
@@ -0,0 +1,70 @@
+package database
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// primaryKey is the context key set by WithPrimary.
+type primaryKey struct{}
+
+// WithPrimary returns a copy of ctx under which Resolver.Reader returns the
+// primary, for reads that must see every write committed before them, such
+// as checking a password at login.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// PrimaryRequired reports whether ctx was returned by WithPrimary.
+func PrimaryRequired(ctx context.Context) bool {
+	required, _ := ctx.Value(primaryKey{}).(bool)
+	return required
+}
+
+// Resolver routes the queries of a repository between the primary database
+// and an optional read replica: writes always run on the primary, and
+// read-only queries on the replica unless their context asks for the primary
+// with WithPrimary. Reads run as prepared statements, which are kept per
+// connection and reused.
+//
+// Replicas apply the writes of the primary asynchronously, usually within
+// milliseconds but as much as seconds behind under load. Reads routed to a
+// replica may therefore miss a write made just before them, including the
+// caller's own, so only reads that can tolerate that lag, such as showing a
+// profile or listing users for an admin, should go to it. Anything that
+// decides on security or is written back must use the primary.
+type Resolver struct {
+	primary      *gorm.DB
+	primaryReads *gorm.DB
+	replicaReads *gorm.DB
+}
+
+// NewResolver creates a Resolver over primary and replica. replica may be
+// nil, in which case reads run on the primary as well.
+func NewResolver(primary, replica *gorm.DB) *Resolver {
+	r := &Resolver{
+		primary:      primary,
+		primaryReads: primary.Session(&gorm.Session{PrepareStmt: true}),
+	}
+	r.replicaReads = r.primaryReads
+	if replica != nil {
+		r.replicaReads = replica.Session(&gorm.Session{PrepareStmt: true})
+	}
+	return r
+}
+
+// Writer returns the primary, for queries that write.
+func (r *Resolver) Writer() *gorm.DB {
+	return r.primary
+}
+
+// Reader returns the connection read-only queries under ctx run on: the
+// replica, if there is one and ctx does not ask for the primary with
+// WithPrimary, and otherwise the primary.
+func (r *Resolver) Reader(ctx context.Context) *gorm.DB {
+	if PrimaryRequired(ctx) {
+		return r.primaryReads
+	}
+	return r.replicaReads
+}
@@ -0,0 +1,85 @@
+// Package device describes the clients sessions are started from, for
+// showing users where they are logged in.
+package device
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/mssola/useragent"
+)
+
+// Unknown is the label of clients whose User-Agent says nothing usable.
+const Unknown = "Unknown device"
+
+// maxUserAgent bounds the part of a User-Agent header that is parsed, since
+// clients can send arbitrarily long ones.
+const maxUserAgent = 512
+
+// validName matches browser names worth showing. The parser reports any
+// token it does not recognize as the browser, so garbage headers would
+// otherwise end up in the label.
+var validName = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9 ._-]{0,31}$`)
+
+// osNames maps operating system names as the parser reports them to the
+// names users know them by.
+var osNames = map[string]string{
+	"Mac OS X":  "macOS",
+	"iPhone OS": "iOS",
+	"iPad OS":   "iPadOS",
+}
+
+// Label returns a short description of the client that sent userAgent, such
+// as "Chrome on macOS", or just the client name when the operating system is
+// unknown. Empty and unrecognizable User-Agents are labeled Unknown.
+func Label(userAgent string) string {
+	if len(userAgent) > maxUserAgent {
+		userAgent = userAgent[:maxUserAgent]
+	}
+	userAgent = strings.TrimSpace(userAgent)
+	if userAgent == "" {
+		return Unknown
+	}
+
+	ua := useragent.New(userAgent)
+	browser, _ := ua.Browser()
+	if !validName.MatchString(browser) {
+		return Unknown
+	}
+
+	system := ua.OSInfo().Name
+	if name, ok := osNames[system]; ok {
+		system = name
+	}
+	if !validName.MatchString(system) {
+		return browser
+	}
+	return browser + " on " + system
+}
+
+// TruncateIP returns ip with its host part zeroed, keeping the /24 network
+// of IPv4 addresses and the /48 network of IPv6 ones, which is enough to
+// recognize a network without storing who exactly connected. It returns an
+// empty string if ip is not an IP address.
+func TruncateIP(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return parsed.Mask(net.CIDRMask(48, 128)).String()
+}
+
+// Fingerprint returns a hash identifying the device and network a request
+// comes from, for recognizing logins from a device the user used before. It
+// covers the Label of userAgent rather than the header itself, so browser
+// updates do not make a device look new, and the TruncateIP network of ip.
+func Fingerprint(userAgent, ip string) string {
+	sum := sha256.Sum256([]byte(Label(userAgent) + "\n" + TruncateIP(ip)))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,106 @@
+package device
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLabel(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		want      string
+	}{
+		{
+			name:      "chrome on macOS",
+			userAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			want:      "Chrome on macOS",
+		},
+		{
+			name:      "edge on windows",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.0.0",
+			want:      "Edge on Windows",
+		},
+		{
+			name:      "safari on iOS",
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Mobile/15E148 Safari/604.1",
+			want:      "Safari on iOS",
+		},
+		{
+			name:      "firefox on linux",
+			userAgent: "Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0",
+			want:      "Firefox on Linux",
+		},
+		{
+			name:      "chrome on android",
+			userAgent: "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.6099.43 Mobile Safari/537.36",
+			want:      "Chrome on Android",
+		},
+		{
+			name:      "client without an operating system",
+			userAgent: "curl/8.4.0",
+			want:      "curl",
+		},
+		{
+			name:      "empty",
+			userAgent: "",
+			want:      Unknown,
+		},
+		{
+			name:      "whitespace",
+			userAgent: "   ",
+			want:      Unknown,
+		},
+		{
+			name:      "garbage",
+			userAgent: "\x00\xff(((;;;",
+			want:      Unknown,
+		},
+		{
+			name:      "overlong token",
+			userAgent: strings.Repeat("a", 10000),
+			want:      Unknown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Label(tt.userAgent))
+		})
+	}
+}
+
+func TestTruncateIP(t *testing.T) {
+	tests := []struct {
+		name string
+		ip   string
+		want string
+	}{
+		{name: "ipv4", ip: "203.0.113.42", want: "203.0.113.0"},
+		{name: "ipv6", ip: "2001:db8:1234:5678::1", want: "2001:db8:1234::"},
+		{name: "ipv4 mapped ipv6", ip: "::ffff:203.0.113.42", want: "203.0.113.0"},
+		{name: "empty", ip: "", want: ""},
+		{name: "not an ip", ip: "localhost", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, TruncateIP(tt.ip))
+		})
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	const chrome120 = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+	const chrome121 = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/121.0.0.0 Safari/537.36"
+	const firefox = "Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0"
+
+	fingerprint := Fingerprint(chrome120, "203.0.113.42")
+	assert.Len(t, fingerprint, 64)
+	assert.Equal(t, fingerprint, Fingerprint(chrome121, "203.0.113.7"), "browser updates and hosts on the same network match")
+	assert.NotEqual(t, fingerprint, Fingerprint(firefox, "203.0.113.42"), "other browsers differ")
+	assert.NotEqual(t, fingerprint, Fingerprint(chrome120, "198.51.100.42"), "other networks differ")
+	assert.Equal(t, Fingerprint("", ""), Fingerprint("\x00\xff(((;;;", "not an ip"), "unusable headers and addresses match each other")
+}
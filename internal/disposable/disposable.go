@@ -0,0 +1,110 @@
+// Package disposable recognizes email addresses at disposable, throwaway
+// email providers.
+package disposable
+
+import (
+	"bufio"
+	_ "embed"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+//go:embed domains.txt
+var embeddedDomains string
+
+// Blocklist is a set of disposable email domains. It always contains the
+// embedded list and, optionally, the domains listed in an extra file that can
+// be reloaded at runtime. It is safe for concurrent use.
+type Blocklist struct {
+	extraPath string
+
+	mu      sync.RWMutex
+	domains map[string]struct{}
+}
+
+// New creates a Blocklist from the embedded list and the file at extraPath,
+// which is skipped when empty. If the extra file cannot be read, the returned
+// Blocklist holds the embedded list only, alongside the error.
+func New(extraPath string) (*Blocklist, error) {
+	b := &Blocklist{extraPath: extraPath}
+	if err := b.Reload(); err != nil {
+		b.domains = parse(strings.NewReader(embeddedDomains), nil)
+		return b, err
+	}
+	return b, nil
+}
+
+// Reload re-reads the extra file and replaces the blocked domains. On error
+// the current domains are kept.
+func (b *Blocklist) Reload() error {
+	domains := parse(strings.NewReader(embeddedDomains), nil)
+
+	if b.extraPath != "" {
+		f, err := os.Open(b.extraPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		domains = parse(f, domains)
+	}
+
+	b.mu.Lock()
+	b.domains = domains
+	b.mu.Unlock()
+	return nil
+}
+
+// Len returns the number of blocked domains.
+func (b *Blocklist) Len() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.domains)
+}
+
+// Blocked reports whether the domain of email, or any domain it is a
+// subdomain of, is on the list.
+func (b *Blocklist) Blocked(email string) bool {
+	domain := Normalize(email[strings.LastIndex(email, "@")+1:])
+	if domain == "" {
+		return false
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for {
+		if _, ok := b.domains[domain]; ok {
+			return true
+		}
+		_, parent, ok := strings.Cut(domain, ".")
+		if !ok {
+			return false
+		}
+		domain = parent
+	}
+}
+
+// Normalize lowercases domain and strips surrounding whitespace and the
+// trailing dot of a fully qualified name.
+func Normalize(domain string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+}
+
+// parse adds the domains listed in r, one per line, to domains, which is
+// allocated when nil. Blank lines and lines starting with '#' are ignored.
+func parse(r io.Reader, domains map[string]struct{}) map[string]struct{} {
+	if domains == nil {
+		domains = make(map[string]struct{})
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		domains[Normalize(line)] = struct{}{}
+	}
+	return domains
+}
@@ -0,0 +1,75 @@
+package disposable
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlocklist_Blocked(t *testing.T) {
+	b, err := New("")
+	require.NoError(t, err)
+
+	tests := []struct {
+		email string
+		want  bool
+	}{
+		{email: "user@mailinator.com", want: true},
+		{email: "user@MAILINATOR.COM", want: true},
+		{email: "user@mailinator.com.", want: true},
+		{email: "user@inbox.mailinator.com", want: true},
+		{email: "user@a.b.yopmail.com", want: true},
+		{email: "user@example.com", want: false},
+		{email: "user@notmailinator.com", want: false},
+		{email: "user@mailinator.com.example.com", want: false},
+		{email: "user@", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.email, func(t *testing.T) {
+			assert.Equal(t, tt.want, b.Blocked(tt.email))
+		})
+	}
+}
+
+func TestBlocklist_ExtraFileAndReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "extra.txt")
+	require.NoError(t, os.WriteFile(path, []byte("# extra domains\n\nThrowaway.Example.\n"), 0o600))
+
+	b, err := New(path)
+	require.NoError(t, err)
+	embedded := b.Len()
+
+	assert.True(t, b.Blocked("user@throwaway.example"))
+	assert.True(t, b.Blocked("user@mailinator.com"))
+	assert.False(t, b.Blocked("user@burner.example"))
+
+	require.NoError(t, os.WriteFile(path, []byte("throwaway.example\nburner.example\n"), 0o600))
+	require.NoError(t, b.Reload())
+
+	assert.True(t, b.Blocked("user@burner.example"))
+	assert.Equal(t, embedded+1, b.Len())
+}
+
+func TestBlocklist_ReloadKeepsDomainsOnError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "extra.txt")
+	require.NoError(t, os.WriteFile(path, []byte("throwaway.example\n"), 0o600))
+	b, err := New(path)
+	require.NoError(t, err)
+
+	require.NoError(t, os.Remove(path))
+
+	assert.Error(t, b.Reload())
+	assert.True(t, b.Blocked("user@throwaway.example"))
+}
+
+func TestNew_MissingExtraFile(t *testing.T) {
+	b, err := New(filepath.Join(t.TempDir(), "missing.txt"))
+
+	assert.Error(t, err)
+	require.NotNil(t, b)
+	assert.True(t, b.Blocked("user@mailinator.com"))
+}
@@ -0,0 +1,201 @@
+// Package emailqueue sends email in the background, off the request path.
+//
+// A Queue is a mailer.Mailer that stores messages in the email_queue table
+// instead of sending them, so a request does not wait for, or fail because
+// of, the SMTP server. A Worker, run as a background job, later sends the
+// queued messages. A message that fails is retried with exponential backoff,
+// and after MaxAttempts failures it is dead-lettered: it stays in the table
+// but is only retried when an admin asks for it.
+package emailqueue
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/google/uuid"
+)
+
+// Defaults for a Worker.
+const (
+	// DefaultBatchSize is the number of emails a Worker sends per run.
+	DefaultBatchSize = 50
+	// MaxBackoff caps the delay before an email is retried.
+	MaxBackoff = 6 * time.Hour
+)
+
+// Store is the persistence a Queue and a Worker need.
+type Store interface {
+	Enqueue(ctx context.Context, email *model.QueuedEmail) error
+	FetchDue(ctx context.Context, now time.Time, limit int) ([]model.QueuedEmail, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	MarkFailed(ctx context.Context, id uuid.UUID, reason string, nextAttemptAt time.Time) error
+	MarkDead(ctx context.Context, id uuid.UUID, reason string) error
+}
+
+// Queue is a mailer.Mailer that queues messages for a Worker to send.
+type Queue struct {
+	store Store
+	now   func() time.Time
+}
+
+// NewQueue creates a Queue storing messages in store.
+func NewQueue(store Store) *Queue {
+	return &Queue{store: store, now: time.Now}
+}
+
+// Send queues a message to be sent as soon as a Worker runs. It only fails
+// if the message cannot be stored.
+func (q *Queue) Send(ctx context.Context, to, subject, htmlBody, textBody string) error {
+	return q.store.Enqueue(ctx, &model.QueuedEmail{
+		Recipient:     to,
+		Subject:       subject,
+		HTMLBody:      htmlBody,
+		TextBody:      textBody,
+		Status:        model.EmailPending,
+		NextAttemptAt: q.now(),
+	})
+}
+
+// Sender delivers a message; it is implemented by mailer.Mailer.
+type Sender interface {
+	Send(ctx context.Context, to, subject, htmlBody, textBody string) error
+}
+
+// Stats counts the outcomes of the attempts a Worker made since it was created.
+type Stats struct {
+	// Sent is the number of emails sent.
+	Sent uint64 `json:"sent"`
+	// Failed is the number of failed attempts, including those that dead-lettered an email.
+	Failed uint64 `json:"failed"`
+	// DeadLettered is the number of emails that failed MaxAttempts times.
+	DeadLettered uint64 `json:"dead_lettered"`
+}
+
+// Backlog is what a Worker left to send at the end of its last run. It is
+// recorded by the run, so reading it does not query the store.
+type Backlog struct {
+	// Pending is the number of due emails the last run fetched but did not
+	// send; those that failed are retried later.
+	Pending int `json:"pending"`
+	// More reports whether the last run fetched a full batch, in which case
+	// more emails may be due than Pending counts.
+	More bool `json:"more"`
+	// RanAt is when the last run ended.
+	RanAt time.Time `json:"ran_at"`
+}
+
+// Worker sends queued emails with a Sender, retrying failures with
+// exponential backoff.
+type Worker struct {
+	store       Store
+	sender      Sender
+	maxAttempts int
+	backoff     time.Duration
+	batchSize   int
+	now         func() time.Time
+
+	sent, failed, deadLettered atomic.Uint64
+	backlog                    atomic.Pointer[Backlog]
+}
+
+// NewWorker creates a Worker that sends the emails of store with sender. An
+// email that fails is retried after backoff, doubling after each further
+// failure up to MaxBackoff, and dead-lettered once it failed maxAttempts times.
+func NewWorker(store Store, sender Sender, maxAttempts int, backoff time.Duration) *Worker {
+	return &Worker{
+		store:       store,
+		sender:      sender,
+		maxAttempts: maxAttempts,
+		backoff:     backoff,
+		batchSize:   DefaultBatchSize,
+		now:         time.Now,
+	}
+}
+
+// Run sends one batch of due emails, as a job run periodically. It stops at
+// the first error from the store; failures to send are recorded on the
+// emails instead.
+func (w *Worker) Run(ctx context.Context) error {
+	emails, err := w.store.FetchDue(ctx, w.now(), w.batchSize)
+	if err != nil {
+		return err
+	}
+
+	sent := 0
+	defer func() {
+		w.backlog.Store(&Backlog{Pending: len(emails) - sent, More: len(emails) == w.batchSize, RanAt: w.now()})
+	}()
+	for _, email := range emails {
+		ok, err := w.send(ctx, email)
+		if ok {
+			sent++
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// send attempts to send email and records the outcome in the store. It
+// reports whether the email was sent.
+func (w *Worker) send(ctx context.Context, email model.QueuedEmail) (bool, error) {
+	err := w.sender.Send(ctx, email.Recipient, email.Subject, email.HTMLBody, email.TextBody)
+	if err == nil {
+		w.sent.Add(1)
+		return true, w.store.Delete(ctx, email.ID)
+	}
+	if errors.Is(err, context.Canceled) && ctx.Err() != nil {
+		// Shutting down; the email is still due at the next run.
+		return false, err
+	}
+
+	w.failed.Add(1)
+	attempts := email.Attempts + 1
+	if attempts >= w.maxAttempts {
+		w.deadLettered.Add(1)
+		slog.ErrorContext(ctx, "email dead-lettered", "email_id", email.ID, "attempts", attempts, "error", err)
+		return false, w.store.MarkDead(ctx, email.ID, err.Error())
+	}
+
+	retryIn := w.Backoff(attempts)
+	slog.WarnContext(ctx, "failed to send email, retrying", "email_id", email.ID, "attempts", attempts, "retry_in", retryIn, "error", err)
+	return false, w.store.MarkFailed(ctx, email.ID, err.Error(), w.now().Add(retryIn))
+}
+
+// Backoff returns how long to wait before retrying an email that failed
+// attempts times: the base backoff, doubled for each attempt after the first,
+// up to MaxBackoff.
+func (w *Worker) Backoff(attempts int) time.Duration {
+	delay := w.backoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= MaxBackoff {
+			return MaxBackoff
+		}
+	}
+	return min(delay, MaxBackoff)
+}
+
+// Stats returns the counts of the attempts the Worker made so far.
+func (w *Worker) Stats() Stats {
+	return Stats{
+		Sent:         w.sent.Load(),
+		Failed:       w.failed.Load(),
+		DeadLettered: w.deadLettered.Load(),
+	}
+}
+
+// Backlog returns what the last run left to send, and false if no run has
+// fetched emails yet.
+func (w *Worker) Backlog() (Backlog, bool) {
+	backlog := w.backlog.Load()
+	if backlog == nil {
+		return Backlog{}, false
+	}
+	return *backlog, true
+}
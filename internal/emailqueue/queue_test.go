@@ -0,0 +1,244 @@
+package emailqueue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore is an in-memory Store standing in for the email_queue table.
+type memoryStore struct {
+	mu       sync.Mutex
+	emails   []*model.QueuedEmail
+	fetchErr error
+}
+
+func (s *memoryStore) Enqueue(_ context.Context, email *model.QueuedEmail) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	email.ID = uuid.New()
+	copied := *email
+	s.emails = append(s.emails, &copied)
+	return nil
+}
+
+func (s *memoryStore) FetchDue(_ context.Context, now time.Time, limit int) ([]model.QueuedEmail, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fetchErr != nil {
+		return nil, s.fetchErr
+	}
+	var out []model.QueuedEmail
+	for _, email := range s.emails {
+		if email.Status == model.EmailPending && !email.NextAttemptAt.After(now) && len(out) < limit {
+			out = append(out, *email)
+		}
+	}
+	return out, nil
+}
+
+func (s *memoryStore) Delete(_ context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, email := range s.emails {
+		if email.ID == id {
+			s.emails = append(s.emails[:i], s.emails[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) MarkFailed(_ context.Context, id uuid.UUID, reason string, nextAttemptAt time.Time) error {
+	return s.update(id, func(email *model.QueuedEmail) {
+		email.Attempts++
+		email.LastError = reason
+		email.NextAttemptAt = nextAttemptAt
+	})
+}
+
+func (s *memoryStore) MarkDead(_ context.Context, id uuid.UUID, reason string) error {
+	return s.update(id, func(email *model.QueuedEmail) {
+		email.Attempts++
+		email.LastError = reason
+		email.Status = model.EmailDead
+	})
+}
+
+func (s *memoryStore) update(id uuid.UUID, fn func(*model.QueuedEmail)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, email := range s.emails {
+		if email.ID == id {
+			fn(email)
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) only(t *testing.T) model.QueuedEmail {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	require.Len(t, s.emails, 1)
+	return *s.emails[0]
+}
+
+// failingMailer fails the first failures calls to Send and records the
+// recipients of those that succeed.
+type failingMailer struct {
+	failures int
+	calls    int
+	sentTo   []string
+}
+
+func (m *failingMailer) Send(_ context.Context, to, _, _, _ string) error {
+	m.calls++
+	if m.calls <= m.failures {
+		return errors.New("smtp: 421 service not available")
+	}
+	m.sentTo = append(m.sentTo, to)
+	return nil
+}
+
+// setupWorkerTest returns a Worker with a 1 minute backoff and 4 attempts,
+// whose clock is the returned pointer, and a Queue sharing its store.
+func setupWorkerTest(sender Sender) (*Worker, *Queue, *memoryStore, *time.Time) {
+	store := &memoryStore{}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	worker := NewWorker(store, sender, 4, time.Minute)
+	worker.now = clock
+	queue := NewQueue(store)
+	queue.now = clock
+	return worker, queue, store, &now
+}
+
+func TestQueue_Send(t *testing.T) {
+	mailer := &failingMailer{}
+	_, queue, store, now := setupWorkerTest(mailer)
+
+	err := queue.Send(context.Background(), "test@example.com", "Verify", "<p>hi</p>", "hi")
+
+	require.NoError(t, err)
+	email := store.only(t)
+	assert.Equal(t, "test@example.com", email.Recipient)
+	assert.Equal(t, "Verify", email.Subject)
+	assert.Equal(t, "<p>hi</p>", email.HTMLBody)
+	assert.Equal(t, "hi", email.TextBody)
+	assert.Equal(t, model.EmailPending, email.Status)
+	assert.Equal(t, *now, email.NextAttemptAt)
+	assert.Zero(t, mailer.calls, "nothing is sent until the worker runs")
+}
+
+func TestWorker_RunSendsQueuedEmails(t *testing.T) {
+	mailer := &failingMailer{}
+	worker, queue, store, _ := setupWorkerTest(mailer)
+	ctx := context.Background()
+	require.NoError(t, queue.Send(ctx, "a@example.com", "s", "h", "t"))
+	require.NoError(t, queue.Send(ctx, "b@example.com", "s", "h", "t"))
+
+	require.NoError(t, worker.Run(ctx))
+
+	assert.Equal(t, []string{"a@example.com", "b@example.com"}, mailer.sentTo)
+	assert.Empty(t, store.emails, "sent emails are deleted")
+	assert.Equal(t, Stats{Sent: 2}, worker.Stats())
+}
+
+func TestWorker_Backlog(t *testing.T) {
+	mailer := &failingMailer{failures: 1}
+	worker, queue, _, now := setupWorkerTest(mailer)
+	worker.batchSize = 2
+	ctx := context.Background()
+
+	_, ok := worker.Backlog()
+	assert.False(t, ok, "no run has fetched emails yet")
+
+	for _, to := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		require.NoError(t, queue.Send(ctx, to, "s", "h", "t"))
+	}
+	require.NoError(t, worker.Run(ctx))
+
+	backlog, ok := worker.Backlog()
+	require.True(t, ok)
+	assert.Equal(t, Backlog{Pending: 1, More: true, RanAt: *now}, backlog, "the failed email is left, and the batch was full")
+
+	require.NoError(t, worker.Run(ctx))
+
+	backlog, _ = worker.Backlog()
+	assert.Equal(t, Backlog{Pending: 0, More: false, RanAt: *now}, backlog)
+}
+
+func TestWorker_RunRetriesWithBackoff(t *testing.T) {
+	mailer := &failingMailer{failures: 2}
+	worker, queue, store, now := setupWorkerTest(mailer)
+	ctx := context.Background()
+	require.NoError(t, queue.Send(ctx, "test@example.com", "s", "h", "t"))
+
+	require.NoError(t, worker.Run(ctx))
+	email := store.only(t)
+	assert.Equal(t, 1, email.Attempts)
+	assert.Equal(t, "smtp: 421 service not available", email.LastError)
+	assert.Equal(t, now.Add(time.Minute), email.NextAttemptAt)
+
+	// Not due yet.
+	*now = now.Add(59 * time.Second)
+	require.NoError(t, worker.Run(ctx))
+	assert.Equal(t, 1, mailer.calls)
+
+	*now = now.Add(time.Second)
+	require.NoError(t, worker.Run(ctx))
+	email = store.only(t)
+	assert.Equal(t, 2, email.Attempts)
+	assert.Equal(t, now.Add(2*time.Minute), email.NextAttemptAt, "the backoff doubles")
+
+	*now = email.NextAttemptAt
+	require.NoError(t, worker.Run(ctx))
+	assert.Equal(t, []string{"test@example.com"}, mailer.sentTo)
+	assert.Empty(t, store.emails)
+	assert.Equal(t, Stats{Sent: 1, Failed: 2}, worker.Stats())
+}
+
+func TestWorker_RunDeadLetters(t *testing.T) {
+	mailer := &failingMailer{failures: 100}
+	worker, queue, store, now := setupWorkerTest(mailer)
+	ctx := context.Background()
+	require.NoError(t, queue.Send(ctx, "test@example.com", "s", "h", "t"))
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, worker.Run(ctx))
+		*now = now.Add(time.Hour)
+	}
+
+	email := store.only(t)
+	assert.Equal(t, model.EmailDead, email.Status)
+	assert.Equal(t, 4, email.Attempts)
+	assert.Equal(t, 4, mailer.calls, "dead letters are not retried")
+	assert.Equal(t, Stats{Failed: 4, DeadLettered: 1}, worker.Stats())
+}
+
+func TestWorker_RunStoreError(t *testing.T) {
+	worker, _, store, _ := setupWorkerTest(&failingMailer{})
+	store.fetchErr = errors.New("database unavailable")
+
+	err := worker.Run(context.Background())
+
+	assert.EqualError(t, err, "database unavailable")
+}
+
+func TestWorker_Backoff(t *testing.T) {
+	worker := NewWorker(&memoryStore{}, &failingMailer{}, 20, 30*time.Second)
+
+	assert.Equal(t, 30*time.Second, worker.Backoff(1))
+	assert.Equal(t, time.Minute, worker.Backoff(2))
+	assert.Equal(t, 4*time.Minute, worker.Backoff(4))
+	assert.Equal(t, MaxBackoff, worker.Backoff(15))
+}
@@ -0,0 +1,44 @@
+// Package errreport sends unexpected server errors to an error tracking service
+// so they are noticed instead of only being written to the log.
+package errreport
+
+import (
+	"context"
+	"time"
+)
+
+// Event describes the request an error occurred in. It deliberately carries
+// identifiers only, never the request body, so that passwords and other user
+// input are not sent to the error tracker.
+type Event struct {
+	RequestID string
+	Route     string
+	UserID    string
+}
+
+// ErrorReporter records unexpected errors.
+type ErrorReporter interface {
+	// Report records err along with the request it occurred in.
+	Report(ctx context.Context, err error, event Event)
+
+	// Flush waits up to timeout for reports that are still being sent.
+	Flush(timeout time.Duration)
+}
+
+// Noop is an ErrorReporter that discards every error. It is used when no
+// error tracking service is configured.
+type Noop struct{}
+
+// Report discards err.
+func (Noop) Report(context.Context, error, Event) {}
+
+// Flush returns immediately.
+func (Noop) Flush(time.Duration) {}
+
+// New returns a Sentry reporter for dsn, or Noop when dsn is empty.
+func New(dsn, environment string) (ErrorReporter, error) {
+	if dsn == "" {
+		return Noop{}, nil
+	}
+	return NewSentry(dsn, environment)
+}
@@ -0,0 +1,49 @@
+package errreport
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// Sentry is an ErrorReporter that sends errors to Sentry.
+type Sentry struct {
+	hub *sentry.Hub
+}
+
+// NewSentry creates a Sentry reporter sending to dsn, tagging every error with environment.
+func NewSentry(dsn, environment string) (*Sentry, error) {
+	return newSentry(sentry.ClientOptions{Dsn: dsn, Environment: environment})
+}
+
+func newSentry(options sentry.ClientOptions) (*Sentry, error) {
+	client, err := sentry.NewClient(options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sentry client: %w", err)
+	}
+	return &Sentry{hub: sentry.NewHub(client, sentry.NewScope())}, nil
+}
+
+// Report sends err to Sentry, tagged with the request ID and route and
+// attributed to the user when one is known.
+func (s *Sentry) Report(_ context.Context, err error, event Event) {
+	s.hub.WithScope(func(scope *sentry.Scope) {
+		if event.RequestID != "" {
+			scope.SetTag("request_id", event.RequestID)
+		}
+		if event.Route != "" {
+			scope.SetTag("route", event.Route)
+		}
+		if event.UserID != "" {
+			scope.SetUser(sentry.User{ID: event.UserID})
+		}
+		s.hub.CaptureException(err)
+	})
+}
+
+// Flush waits up to timeout for queued errors to be sent to Sentry.
+func (s *Sentry) Flush(timeout time.Duration) {
+	s.hub.Flush(timeout)
+}
@@ -0,0 +1,75 @@
+package errreport
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingTransport struct {
+	mu     sync.Mutex
+	events []*sentry.Event
+}
+
+func (t *recordingTransport) Configure(sentry.ClientOptions) {}
+
+func (t *recordingTransport) Flush(time.Duration) bool { return true }
+
+func (t *recordingTransport) SendEvent(event *sentry.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, event)
+}
+
+func TestNew(t *testing.T) {
+	reporter, err := New("", "development")
+	require.NoError(t, err)
+	assert.Equal(t, Noop{}, reporter)
+
+	reporter, err = New("https://public@sentry.example.com/1", "production")
+	require.NoError(t, err)
+	assert.IsType(t, &Sentry{}, reporter)
+
+	_, err = New("not a dsn", "production")
+	assert.Error(t, err)
+}
+
+func TestSentry_Report(t *testing.T) {
+	transport := &recordingTransport{}
+	reporter, err := newSentry(sentry.ClientOptions{
+		Dsn:         "https://public@sentry.example.com/1",
+		Environment: "production",
+		Transport:   transport,
+	})
+	require.NoError(t, err)
+
+	reporter.Report(context.Background(), errors.New("database is on fire"), Event{
+		RequestID: "req-1",
+		Route:     "/api/profile",
+		UserID:    "user-1",
+	})
+	reporter.Report(context.Background(), errors.New("anonymous failure"), Event{Route: "/api/login"})
+	reporter.Flush(time.Second)
+
+	require.Len(t, transport.events, 2)
+
+	first := transport.events[0]
+	require.NotEmpty(t, first.Exception)
+	assert.Equal(t, "database is on fire", first.Exception[len(first.Exception)-1].Value)
+	assert.Equal(t, "production", first.Environment)
+	assert.Equal(t, "req-1", first.Tags["request_id"])
+	assert.Equal(t, "/api/profile", first.Tags["route"])
+	assert.Equal(t, "user-1", first.User.ID)
+	assert.Nil(t, first.Request)
+
+	second := transport.events[1]
+	assert.Equal(t, "/api/login", second.Tags["route"])
+	assert.NotContains(t, second.Tags, "request_id")
+	assert.Empty(t, second.User.ID)
+}
@@ -0,0 +1,85 @@
+// Package eventbus publishes user lifecycle events, such as registrations, to
+// a message bus for other systems to consume.
+//
+// Every event is sent as a JSON Message on the subject SubjectPrefix followed
+// by its type, for example "events.user.registered". The envelope carries a
+// SchemaVersion that is incremented whenever its fields change incompatibly.
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// SchemaVersion is the version of the Message envelope.
+const SchemaVersion = 1
+
+// SubjectPrefix is prepended to the event type to build the subject of a message.
+const SubjectPrefix = "events"
+
+// Message is an event as published on the bus.
+type Message struct {
+	// SchemaVersion is the version of this envelope, SchemaVersion when published.
+	SchemaVersion int `json:"schema_version"`
+	// ID identifies the event; a redelivered event keeps its ID.
+	ID string `json:"id"`
+	// Type is the kind of event, such as "user.registered".
+	Type string `json:"type"`
+	// OccurredAt is when the change the event describes was committed.
+	OccurredAt time.Time `json:"occurred_at"`
+	// Data is the JSON body of the event, specific to its type.
+	Data json.RawMessage `json:"data"`
+}
+
+// Subject returns the subject msg is published on.
+func (msg Message) Subject() string {
+	return SubjectPrefix + "." + msg.Type
+}
+
+// EventPublisher publishes events to a message bus.
+type EventPublisher interface {
+	// Publish sends msg and returns once the bus accepted it, or an error
+	// if it did not, in which case the caller should retry later.
+	Publish(ctx context.Context, msg Message) error
+}
+
+// MemoryPublisher is an EventPublisher that keeps the messages it is given,
+// for tests.
+type MemoryPublisher struct {
+	mu       sync.Mutex
+	messages []Message
+	err      error
+}
+
+// NewMemoryPublisher creates an empty MemoryPublisher.
+func NewMemoryPublisher() *MemoryPublisher {
+	return &MemoryPublisher{}
+}
+
+// Publish records msg, or returns the error set by Fail without recording it.
+func (p *MemoryPublisher) Publish(_ context.Context, msg Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	p.messages = append(p.messages, msg)
+	return nil
+}
+
+// Fail makes the following calls to Publish return err, or succeed again if
+// err is nil.
+func (p *MemoryPublisher) Fail(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.err = err
+}
+
+// Messages returns the messages published so far, oldest first.
+func (p *MemoryPublisher) Messages() []Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Message(nil), p.messages...)
+}
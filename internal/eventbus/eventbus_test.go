@@ -0,0 +1,27 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessage_Subject(t *testing.T) {
+	assert.Equal(t, "events.user.registered", Message{Type: "user.registered"}.Subject())
+}
+
+func TestMemoryPublisher(t *testing.T) {
+	publisher := NewMemoryPublisher()
+	ctx := context.Background()
+
+	require.NoError(t, publisher.Publish(ctx, Message{ID: "1"}))
+	publisher.Fail(errors.New("bus down"))
+	assert.EqualError(t, publisher.Publish(ctx, Message{ID: "2"}), "bus down")
+	publisher.Fail(nil)
+	require.NoError(t, publisher.Publish(ctx, Message{ID: "3"}))
+
+	assert.Equal(t, []Message{{ID: "1"}, {ID: "3"}}, publisher.Messages())
+}
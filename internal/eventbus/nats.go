@@ -0,0 +1,61 @@
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// publishTimeout bounds how long Publish waits for the server when ctx has no
+// earlier deadline.
+const publishTimeout = 5 * time.Second
+
+// NATSPublisher is an EventPublisher backed by a NATS server.
+type NATSPublisher struct {
+	conn *nats.Conn
+}
+
+// NewNATSPublisher connects to the NATS server at url. An unreachable server
+// is not an error: the connection is retried in the background, and
+// publishing fails until it succeeds.
+func NewNATSPublisher(url string) (*NATSPublisher, error) {
+	conn, err := nats.Connect(url,
+		nats.Name("learn-go"),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	return &NATSPublisher{conn: conn}, nil
+}
+
+// Publish sends msg on its subject and waits for the server to acknowledge
+// it. The event ID is sent in the Nats-Msg-Id header, so a JetStream stream
+// capturing the subject discards redeliveries.
+func (p *NATSPublisher) Publish(ctx context.Context, msg Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	natsMsg := nats.NewMsg(msg.Subject())
+	natsMsg.Header.Set(nats.MsgIdHdr, msg.ID)
+	natsMsg.Data = data
+	if err := p.conn.PublishMsg(natsMsg); err != nil {
+		return err
+	}
+	// Publishing only buffers the message; a flush round trip confirms the
+	// server received it.
+	ctx, cancel := context.WithTimeout(ctx, publishTimeout)
+	defer cancel()
+	return p.conn.FlushWithContext(ctx)
+}
+
+// Close sends the messages still buffered and closes the connection.
+func (p *NATSPublisher) Close() error {
+	return p.conn.Drain()
+}
@@ -0,0 +1,56 @@
+//go:build integration
+
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNATSPublisher_Publish needs a NATS server, by default the one started by
+//
+//	docker run --rm -p 4222:4222 nats
+//
+// and runs with go test -tags integration ./internal/eventbus. Set NATS_URL
+// to use another server.
+func TestNATSPublisher_Publish(t *testing.T) {
+	url := os.Getenv("NATS_URL")
+	if url == "" {
+		url = nats.DefaultURL
+	}
+
+	subscriber, err := nats.Connect(url)
+	require.NoError(t, err)
+	defer subscriber.Close()
+	received, err := subscriber.SubscribeSync(SubjectPrefix + ".>")
+	require.NoError(t, err)
+	require.NoError(t, subscriber.Flush())
+
+	publisher, err := NewNATSPublisher(url)
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	msg := Message{
+		SchemaVersion: SchemaVersion,
+		ID:            "8f14e45f-ceea-467f-a8b6-6d1e3a1f0c2d",
+		Type:          "user.registered",
+		OccurredAt:    time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+		Data:          json.RawMessage(`{"email":"test@example.com"}`),
+	}
+	require.NoError(t, publisher.Publish(context.Background(), msg))
+
+	got, err := received.NextMsg(5 * time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "events.user.registered", got.Subject)
+	assert.Equal(t, msg.ID, got.Header.Get(nats.MsgIdHdr))
+	var decoded Message
+	require.NoError(t, json.Unmarshal(got.Data, &decoded))
+	assert.Equal(t, msg, decoded)
+}
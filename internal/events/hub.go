@@ -0,0 +1,203 @@
+// Package events distributes notifications about a user's account, such as a
+// session being revoked, to the connections the user currently has open, so
+// that other tabs and devices can react immediately.
+package events
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// The types of events published about a user.
+const (
+	// TypeProfileUpdated means the user's profile changed.
+	TypeProfileUpdated = "profile.updated"
+	// TypeSessionCreated means the user logged in, starting a new session.
+	TypeSessionCreated = "session.created"
+	// TypeSessionRevoked means one or all of the user's sessions were revoked.
+	TypeSessionRevoked = "session.revoked"
+)
+
+const (
+	// DefaultBufferSize is the number of events a subscriber may fall behind
+	// by before it is dropped.
+	DefaultBufferSize = 16
+	// DefaultReplaySize is the number of recent events, across all users, kept
+	// for subscribers resuming after a disconnect.
+	DefaultReplaySize = 256
+)
+
+// Event is a notification about a user's account.
+type Event struct {
+	// ID identifies the event among those published by the same Hub. IDs
+	// increase with every event, so the last one a subscriber received tells
+	// where to resume.
+	ID   uint64 `json:"id"`
+	Type string `json:"type"`
+	// Data describes the event further, for example the "session_id" of a
+	// revoked session. Its keys depend on Type.
+	Data map[string]string `json:"data,omitempty"`
+	Time time.Time         `json:"time"`
+}
+
+// Publisher delivers events about a user to their subscribers.
+type Publisher interface {
+	Publish(userID string, event Event)
+}
+
+// Discard is a Publisher that drops every event.
+var Discard Publisher = discard{}
+
+type discard struct{}
+
+func (discard) Publish(string, Event) {}
+
+// Hub is an in-process Publisher that fans events out to the subscribers of
+// each user. Publishing never blocks: a subscriber whose buffer is full is
+// dropped, and its Events channel closed, rather than holding up the others.
+// The most recent events are kept in a ring buffer so that a subscriber that
+// disconnects briefly can Resume without losing any.
+type Hub struct {
+	bufferSize int
+
+	mu          sync.Mutex
+	subscribers map[string]map[*Subscription]struct{}
+	lastID      uint64
+	recent      []published
+	// next is the index in recent that the next event overwrites once it is full.
+	next int
+}
+
+// published is an event kept for replay with the user it was published about.
+type published struct {
+	userID string
+	event  Event
+}
+
+// NewHub creates a Hub whose subscribers may fall behind by bufferSize events
+// and which keeps the last replaySize events for Resume.
+func NewHub(bufferSize, replaySize int) *Hub {
+	return &Hub{
+		bufferSize:  bufferSize,
+		subscribers: make(map[string]map[*Subscription]struct{}),
+		recent:      make([]published, 0, replaySize),
+	}
+}
+
+// Subscription receives the events published about one user after it was
+// created. It must be closed when no longer needed.
+type Subscription struct {
+	hub    *Hub
+	userID string
+	events chan Event
+}
+
+// Subscribe starts delivering the events published about the user with userID.
+func (h *Hub) Subscribe(userID string) *Subscription {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.subscribe(userID)
+}
+
+// Resume starts delivering the events published about the user with userID,
+// like Subscribe, and also returns the recent events about them with an ID
+// greater than lastID, oldest first. Together they hold every event after
+// lastID exactly once, unless some were pushed out of the replay buffer in the
+// meantime. A lastID the Hub never issued, such as one from before a restart,
+// replays nothing.
+func (h *Hub) Resume(userID string, lastID uint64) (*Subscription, []Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var missed []Event
+	if lastID <= h.lastID {
+		for i := range h.recent {
+			p := h.recent[(h.next+i)%len(h.recent)]
+			if p.userID == userID && p.event.ID > lastID {
+				missed = append(missed, p.event)
+			}
+		}
+	}
+	return h.subscribe(userID), missed
+}
+
+// subscribe adds a subscription for the user with userID. h.mu must be held.
+func (h *Hub) subscribe(userID string) *Subscription {
+	sub := &Subscription{hub: h, userID: userID, events: make(chan Event, h.bufferSize)}
+	if h.subscribers[userID] == nil {
+		h.subscribers[userID] = make(map[*Subscription]struct{})
+	}
+	h.subscribers[userID][sub] = struct{}{}
+	return sub
+}
+
+// Publish delivers event to every subscriber of the user with userID and
+// keeps it for Resume. It assigns the event the next ID and stamps it with
+// the current time if it has none.
+func (h *Hub) Publish(userID string, event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastID++
+	event.ID = h.lastID
+	h.remember(published{userID: userID, event: event})
+	for sub := range h.subscribers[userID] {
+		select {
+		case sub.events <- event:
+		default:
+			slog.Warn("dropping slow event subscriber", "user_id", userID, "event", event.Type)
+			h.remove(sub)
+		}
+	}
+}
+
+// Subscribers returns the number of open subscriptions of the user with userID.
+func (h *Hub) Subscribers(userID string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subscribers[userID])
+}
+
+// remember keeps p for Resume, overwriting the oldest event once the replay
+// buffer is full. h.mu must be held.
+func (h *Hub) remember(p published) {
+	if cap(h.recent) == 0 {
+		return
+	}
+	if len(h.recent) < cap(h.recent) {
+		h.recent = append(h.recent, p)
+		return
+	}
+	h.recent[h.next] = p
+	h.next = (h.next + 1) % len(h.recent)
+}
+
+// remove ends sub if it has not ended yet. h.mu must be held.
+func (h *Hub) remove(sub *Subscription) {
+	subs := h.subscribers[sub.userID]
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(h.subscribers, sub.userID)
+	}
+	close(sub.events)
+}
+
+// Events returns the channel the subscription's events are delivered on. It
+// is closed when the subscription is closed or was dropped for falling behind.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Close stops the delivery of events. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
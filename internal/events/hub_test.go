@@ -0,0 +1,117 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHub_PublishReachesTheUsersSubscribers(t *testing.T) {
+	hub := NewHub(DefaultBufferSize, DefaultReplaySize)
+	first := hub.Subscribe("user-1")
+	second := hub.Subscribe("user-1")
+	other := hub.Subscribe("user-2")
+	t.Cleanup(func() {
+		first.Close()
+		second.Close()
+		other.Close()
+	})
+
+	hub.Publish("user-1", Event{Type: TypeSessionRevoked, Data: map[string]string{"session_id": "s-1"}})
+
+	for _, sub := range []*Subscription{first, second} {
+		require.Len(t, sub.Events(), 1)
+		event := <-sub.Events()
+		assert.Equal(t, TypeSessionRevoked, event.Type)
+		assert.Equal(t, uint64(1), event.ID)
+		assert.Equal(t, "s-1", event.Data["session_id"])
+		assert.False(t, event.Time.IsZero(), "the event is stamped")
+	}
+	assert.Empty(t, other.Events())
+}
+
+func TestHub_DropsSlowSubscribers(t *testing.T) {
+	hub := NewHub(2, DefaultReplaySize)
+	slow := hub.Subscribe("user-1")
+	fast := hub.Subscribe("user-1")
+	defer fast.Close()
+
+	for i := 0; i < 3; i++ {
+		hub.Publish("user-1", Event{Type: TypeProfileUpdated})
+		<-fast.Events()
+	}
+
+	// The slow subscriber gets the buffered events, then sees the channel closed.
+	var received int
+	for range slow.Events() {
+		received++
+	}
+	assert.Equal(t, 2, received)
+	assert.Equal(t, 1, hub.Subscribers("user-1"))
+	slow.Close() // closing a dropped subscription is harmless
+}
+
+func TestSubscription_Close(t *testing.T) {
+	hub := NewHub(DefaultBufferSize, DefaultReplaySize)
+	sub := hub.Subscribe("user-1")
+	assert.Equal(t, 1, hub.Subscribers("user-1"))
+
+	sub.Close()
+	sub.Close()
+
+	assert.Equal(t, 0, hub.Subscribers("user-1"))
+	_, open := <-sub.Events()
+	assert.False(t, open)
+	hub.Publish("user-1", Event{Type: TypeProfileUpdated})
+}
+
+func TestHub_Resume(t *testing.T) {
+	hub := NewHub(DefaultBufferSize, 3)
+	for _, userID := range []string{"user-1", "user-2", "user-1", "user-1", "user-1"} {
+		hub.Publish(userID, Event{Type: TypeProfileUpdated})
+	}
+
+	tests := []struct {
+		name    string
+		lastID  uint64
+		wantIDs []uint64
+	}{
+		{name: "replays the user's events after lastID", lastID: 3, wantIDs: []uint64{4, 5}},
+		{name: "events pushed out of the buffer are lost", lastID: 0, wantIDs: []uint64{3, 4, 5}},
+		{name: "up to date", lastID: 5, wantIDs: nil},
+		{name: "unknown ID", lastID: 42, wantIDs: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, missed := hub.Resume("user-1", tt.lastID)
+			defer sub.Close()
+
+			var ids []uint64
+			for _, event := range missed {
+				ids = append(ids, event.ID)
+			}
+			assert.Equal(t, tt.wantIDs, ids)
+		})
+	}
+}
+
+func TestHub_ResumeContinuesLive(t *testing.T) {
+	hub := NewHub(DefaultBufferSize, DefaultReplaySize)
+	hub.Publish("user-1", Event{Type: TypeSessionCreated})
+
+	sub, missed := hub.Resume("user-1", 0)
+	defer sub.Close()
+	hub.Publish("user-1", Event{Type: TypeSessionRevoked})
+
+	require.Len(t, missed, 1)
+	assert.Equal(t, uint64(1), missed[0].ID)
+	event := <-sub.Events()
+	assert.Equal(t, uint64(2), event.ID)
+	assert.Equal(t, TypeSessionRevoked, event.Type)
+}
+
+func TestDiscard(t *testing.T) {
+	assert.NotPanics(t, func() { Discard.Publish("user-1", Event{Type: TypeProfileUpdated}) })
+}
@@ -0,0 +1,175 @@
+// Package featureflag decides which features are turned on for a request, so
+// that features can be rolled out to a share of users before everyone gets
+// them.
+package featureflag
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Flags evaluates feature flags for the current request.
+type Flags interface {
+	// Enabled reports whether the flag called name is on. Unknown flags are off.
+	Enabled(ctx context.Context, name string) bool
+}
+
+// Rule decides who a flag is on for. The zero Rule is off for everyone.
+type Rule struct {
+	// Enabled turns the flag on for everyone.
+	Enabled bool `yaml:"enabled"`
+	// Percentage turns the flag on for that percentage of signed-in users,
+	// from 0 to 100. Each user is placed in a bucket by hashing their ID
+	// with the flag name, so they keep the same result from request to
+	// request and a larger percentage only adds users.
+	Percentage int `yaml:"percentage"`
+}
+
+// Static is a fixed set of flag rules, typically loaded from a config file.
+// It is safe for concurrent use.
+type Static struct {
+	rules map[string]Rule
+}
+
+// NewStatic creates a Static with rules keyed by flag name. It returns an
+// error if a percentage is out of range.
+func NewStatic(rules map[string]Rule) (*Static, error) {
+	for name, rule := range rules {
+		if rule.Percentage < 0 || rule.Percentage > 100 {
+			return nil, fmt.Errorf("invalid percentage for flag %q: must be between 0 and 100", name)
+		}
+	}
+	return &Static{rules: rules}, nil
+}
+
+// Load creates a Static from the YAML file at path, which maps each flag
+// name to its Rule:
+//
+//	passkeys:
+//	  percentage: 10
+//	strict_validation:
+//	  enabled: true
+//
+// An empty path returns a Static with no flags.
+func Load(path string) (*Static, error) {
+	if path == "" {
+		return &Static{}, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error loading feature flags file: %w", err)
+	}
+
+	var rules map[string]Rule
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&rules); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("error loading feature flags file: %w", err)
+	}
+	return NewStatic(rules)
+}
+
+// Names returns the names of the configured flags in alphabetical order.
+func (s *Static) Names() []string {
+	names := make([]string, 0, len(s.rules))
+	for name := range s.rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ForUser returns the flags as evaluated for the user with userID, or for an
+// anonymous request when userID is empty. Percentage rollouts are off for
+// anonymous requests.
+func (s *Static) ForUser(userID string) Flags {
+	return userFlags{static: s, userID: userID}
+}
+
+// Evaluate returns whether each configured flag is on for the user with
+// userID, as ForUser evaluates them.
+func (s *Static) Evaluate(userID string) map[string]bool {
+	evaluated := make(map[string]bool, len(s.rules))
+	for name := range s.rules {
+		evaluated[name] = s.enabled(userID, name)
+	}
+	return evaluated
+}
+
+// enabled reports whether the flag called name is on for the user with userID.
+func (s *Static) enabled(userID, name string) bool {
+	rule, ok := s.rules[name]
+	switch {
+	case !ok:
+		return false
+	case rule.Enabled:
+		return true
+	case userID == "":
+		return false
+	default:
+		return bucket(userID, name) < rule.Percentage
+	}
+}
+
+// bucket places the user with userID in one of 100 buckets for the flag
+// called name. Hashing the name too keeps rollouts of different flags from
+// always picking the same users.
+func bucket(userID, name string) int {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write([]byte(userID))
+	return int(h.Sum32() % 100)
+}
+
+// userFlags are the flags of a Static evaluated for one user.
+type userFlags struct {
+	static *Static
+	userID string
+}
+
+func (f userFlags) Enabled(_ context.Context, name string) bool {
+	return f.static.enabled(f.userID, name)
+}
+
+// Off is a Flags with every flag off.
+var Off Flags = off{}
+
+type off struct{}
+
+func (off) Enabled(context.Context, string) bool { return false }
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying flags, which Enabled consults.
+func NewContext(ctx context.Context, flags Flags) context.Context {
+	return context.WithValue(ctx, contextKey{}, flags)
+}
+
+// FromContext returns the flags stored in ctx by NewContext, or Off if there
+// are none.
+func FromContext(ctx context.Context) Flags {
+	if flags, ok := ctx.Value(contextKey{}).(Flags); ok {
+		return flags
+	}
+	return Off
+}
+
+// Enabled reports whether the flag called name is on for the request of ctx.
+// It is how handlers and services check a flag:
+//
+//	if featureflag.Enabled(ctx, "passkeys") {
+//		// ...
+//	}
+func Enabled(ctx context.Context, name string) bool {
+	return FromContext(ctx).Enabled(ctx, name)
+}
@@ -0,0 +1,142 @@
+package featureflag
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatic_Enabled(t *testing.T) {
+	flags, err := NewStatic(map[string]Rule{
+		"everyone": {Enabled: true},
+		"nobody":   {},
+		"all":      {Percentage: 100},
+		"none":     {Percentage: 0},
+	})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		userID string
+		want   bool
+	}{
+		{name: "everyone", userID: "user-1", want: true},
+		{name: "everyone", userID: "", want: true},
+		{name: "nobody", userID: "user-1", want: false},
+		{name: "all", userID: "user-1", want: true},
+		{name: "all", userID: "", want: false},
+		{name: "none", userID: "user-1", want: false},
+		{name: "unknown", userID: "user-1", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s for %q", tt.name, tt.userID), func(t *testing.T) {
+			assert.Equal(t, tt.want, flags.ForUser(tt.userID).Enabled(ctx, tt.name))
+		})
+	}
+}
+
+func TestStatic_PercentageBucketing(t *testing.T) {
+	flags, err := NewStatic(map[string]Rule{"rollout": {Percentage: 30}})
+	require.NoError(t, err)
+	wider, err := NewStatic(map[string]Rule{"rollout": {Percentage: 60}})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	var enabled int
+	for i := 0; i < 1000; i++ {
+		userID := fmt.Sprintf("user-%d", i)
+		first := flags.ForUser(userID).Enabled(ctx, "rollout")
+		for j := 0; j < 3; j++ {
+			require.Equal(t, first, flags.ForUser(userID).Enabled(ctx, "rollout"), "the same user always gets the same result")
+		}
+		if first {
+			enabled++
+			assert.True(t, wider.ForUser(userID).Enabled(ctx, "rollout"), "raising the percentage keeps the users already in")
+		}
+	}
+	assert.InDelta(t, 300, enabled, 60)
+}
+
+func TestStatic_Evaluate(t *testing.T) {
+	flags, err := NewStatic(map[string]Rule{"on": {Enabled: true}, "off": {}})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]bool{"on": true, "off": false}, flags.Evaluate("user-1"))
+	assert.Equal(t, []string{"off", "on"}, flags.Names())
+}
+
+func TestNewStatic_InvalidPercentage(t *testing.T) {
+	_, err := NewStatic(map[string]Rule{"rollout": {Percentage: 101}})
+	assert.ErrorContains(t, err, `invalid percentage for flag "rollout"`)
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		want        map[string]bool
+		errContains string
+	}{
+		{
+			name:    "rules",
+			content: "passkeys:\n  enabled: true\nstrict_validation:\n  percentage: 0\n",
+			want:    map[string]bool{"passkeys": true, "strict_validation": false},
+		},
+		{
+			name:    "empty file",
+			content: "",
+			want:    map[string]bool{},
+		},
+		{
+			name:        "unknown field",
+			content:     "passkeys:\n  enable: true\n",
+			errContains: "error loading feature flags file",
+		},
+		{
+			name:        "invalid percentage",
+			content:     "passkeys:\n  percentage: 150\n",
+			errContains: "invalid percentage",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "flags.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(tt.content), 0o600))
+
+			flags, err := Load(path)
+			if tt.errContains != "" {
+				assert.ErrorContains(t, err, tt.errContains)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, flags.Evaluate("user-1"))
+		})
+	}
+}
+
+func TestLoad_NoFile(t *testing.T) {
+	flags, err := Load("")
+	require.NoError(t, err)
+	assert.Empty(t, flags.Names())
+
+	_, err = Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.ErrorContains(t, err, "error loading feature flags file")
+}
+
+func TestContext(t *testing.T) {
+	flags, err := NewStatic(map[string]Rule{"passkeys": {Enabled: true}})
+	require.NoError(t, err)
+
+	ctx := NewContext(context.Background(), flags.ForUser("user-1"))
+	assert.True(t, Enabled(ctx, "passkeys"))
+	assert.False(t, Enabled(ctx, "unknown"))
+	assert.False(t, Enabled(context.Background(), "passkeys"), "flags are off without an evaluator")
+}
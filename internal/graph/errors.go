@@ -0,0 +1,41 @@
+package graph
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// newError returns the GraphQL error for a failure the REST API answers with
+// status, code and message. Its extensions carry the status and the code, or,
+// for failures without one, the name of the status, such as "BAD_REQUEST".
+func newError(ctx context.Context, status int, code, message string) *gqlerror.Error {
+	if code == "" {
+		code = statusCode(status)
+	}
+	return &gqlerror.Error{
+		Path:    graphql.GetPath(ctx),
+		Message: message,
+		Extensions: map[string]interface{}{
+			"code":   code,
+			"status": status,
+		},
+	}
+}
+
+// statusCode returns the name of an HTTP status as a machine code, such as
+// "NOT_FOUND" for 404.
+func statusCode(status int) string {
+	return strings.ToUpper(strings.ReplaceAll(http.StatusText(status), " ", "_"))
+}
+
+// reportError attaches err to the Gin context of the request, so the error
+// reporting middleware reports it as it does for the REST routes.
+func reportError(ctx context.Context, err error) {
+	if c := ginContext(ctx); c != nil {
+		c.Error(err)
+	}
+}
@@ -0,0 +1,101 @@
+// Package graph serves the GraphQL API, a second transport for the register,
+// login and profile operations of the REST API, for clients standardized on
+// GraphQL. It reuses the same service, and reports failures with the status
+// and machine code the REST API uses for them.
+package graph
+
+//go:generate go run github.com/99designs/gqlgen generate --config gqlgen.yml
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/PakornBank/learn-go/internal/authctx"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+)
+
+// Service defines the methods that the resolvers need from the authentication service.
+type Service interface {
+	// Register registers a new user with the given input and returns the created user or an error.
+	// ctx: The context for the request.
+	// input: The input data required for user registration.
+	Register(ctx context.Context, input service.RegisterInput) (*model.User, error)
+
+	// Login authenticates a user with the given input and returns an access and refresh token pair or an error.
+	// ctx: The context for the request.
+	// input: The input data required for user login.
+	Login(ctx context.Context, input service.LoginInput) (*service.TokenPair, error)
+
+	// GetUserByID retrieves a user by their ID and returns the user or an error.
+	// ctx: The context for the request.
+	// id: The ID of the user to retrieve.
+	GetUserByID(ctx context.Context, id string) (*model.User, error)
+
+	// CheckTOS returns service.ErrTOSReacceptanceNeeded if the user has not accepted the current terms of service.
+	// ctx: The context for the request.
+	// userID: The ID of the user to check.
+	CheckTOS(ctx context.Context, userID string) error
+
+	// CheckPasswordExpiry returns service.ErrPasswordExpired if the user's password has expired.
+	// ctx: The context for the request.
+	// userID: The ID of the user to check.
+	CheckPasswordExpiry(ctx context.Context, userID string) error
+}
+
+// Gates selects the checks the me field makes of the signed in user before
+// reading their profile, those the middleware of the REST protected routes
+// make. The token must carry the profile:read scope in any case.
+type Gates struct {
+	// TOS requires the user to have accepted the current terms of service,
+	// as middleware.RequireTOS does.
+	TOS bool
+	// UnexpiredPassword requires the user's password not to have expired, as
+	// middleware.RequireUnexpiredPassword does.
+	UnexpiredPassword bool
+}
+
+// Resolver is the root resolver of the schema.
+type Resolver struct {
+	service Service
+	gates   Gates
+}
+
+// checkProfileAccess returns the error the REST profile route answers for
+// identity, if any: the same statuses and codes, in the same order, as its
+// middleware.
+func (r *Resolver) checkProfileAccess(ctx context.Context, identity authctx.Identity) error {
+	userID := identity.UserID.String()
+	if r.gates.TOS {
+		err := r.service.CheckTOS(ctx, userID)
+		switch {
+		case err == nil:
+		case errors.Is(err, service.ErrTOSReacceptanceNeeded):
+			return newError(ctx, http.StatusPreconditionRequired, "TOS_REACCEPTANCE_REQUIRED", service.ErrTOSReacceptanceNeeded.Error())
+		case errors.Is(err, repository.ErrTimeout):
+			return newError(ctx, http.StatusGatewayTimeout, "", err.Error())
+		default:
+			reportError(ctx, err)
+			return newError(ctx, http.StatusInternalServerError, "", "failed to check terms of service")
+		}
+	}
+	if r.gates.UnexpiredPassword {
+		err := r.service.CheckPasswordExpiry(ctx, userID)
+		switch {
+		case err == nil:
+		case errors.Is(err, service.ErrPasswordExpired):
+			return newError(ctx, http.StatusForbidden, "PASSWORD_EXPIRED", service.ErrPasswordExpired.Error())
+		case errors.Is(err, repository.ErrTimeout):
+			return newError(ctx, http.StatusGatewayTimeout, "", err.Error())
+		default:
+			reportError(ctx, err)
+			return newError(ctx, http.StatusInternalServerError, "", "failed to check password expiry")
+		}
+	}
+	if !identity.HasScope(service.ScopeProfileRead) {
+		return newError(ctx, http.StatusForbidden, "INSUFFICIENT_SCOPE", "token lacks the "+service.ScopeProfileRead+" scope")
+	}
+	return nil
+}
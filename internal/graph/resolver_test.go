@@ -0,0 +1,652 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/authctx"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockService struct {
+	mock.Mock
+}
+
+func (ms *MockService) Register(ctx context.Context, in service.RegisterInput) (*model.User, error) {
+	args := ms.Called(ctx, in)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+func (ms *MockService) Login(ctx context.Context, in service.LoginInput) (*service.TokenPair, error) {
+	args := ms.Called(ctx, in)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.TokenPair), args.Error(1)
+}
+
+func (ms *MockService) GetUserByID(ctx context.Context, id string) (*model.User, error) {
+	args := ms.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+func (ms *MockService) CheckTOS(ctx context.Context, userID string) error {
+	return ms.Called(ctx, userID).Error(0)
+}
+
+func (ms *MockService) CheckPasswordExpiry(ctx context.Context, userID string) error {
+	return ms.Called(ctx, userID).Error(0)
+}
+
+// response is the body of a GraphQL response.
+type response struct {
+	Data   map[string]json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+// setupGraphQLTest returns a router serving the GraphQL API with
+// introspection as given, behind setupAuth, which stands in for the
+// authentication middleware.
+func setupGraphQLTest(setupAuth func(*gin.Context), introspection bool) (*gin.Engine, *MockService) {
+	return setupGatedGraphQLTest(setupAuth, Gates{}, introspection)
+}
+
+// setupGatedGraphQLTest is setupGraphQLTest with the checks of gates.
+func setupGatedGraphQLTest(setupAuth func(*gin.Context), gates Gates, introspection bool) (*gin.Engine, *MockService) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockService)
+	router := gin.New()
+	router.POST("/api/graphql", func(c *gin.Context) {
+		setupAuth(c)
+		c.Next()
+	}, NewHandler(mockService, gates, introspection))
+
+	return router, mockService
+}
+
+// execute posts query with variables to router and decodes the response.
+func execute(t *testing.T, router *gin.Engine, query string, variables map[string]interface{}) (*httptest.ResponseRecorder, response) {
+	t.Helper()
+	body, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/graphql", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "test-agent")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var res response
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	return w, res
+}
+
+func anonymous(*gin.Context) {}
+
+const registerMutation = `mutation Register($input: RegisterInput!) {
+	register(input: $input) { id email fullName username role }
+}`
+
+func TestMutation_Register(t *testing.T) {
+	validInput := map[string]interface{}{
+		"email":    "test@example.com",
+		"password": "password123",
+		"fullName": "Test User",
+	}
+	serviceInput := service.RegisterInput{Email: "test@example.com", Password: "password123", FullName: "Test User"}
+	user := &model.User{ID: uuid.New(), Email: "test@example.com", FullName: "Test User", Role: model.RoleUser}
+
+	withField := func(key string, value interface{}) map[string]interface{} {
+		input := map[string]interface{}{}
+		for k, v := range validInput {
+			input[k] = v
+		}
+		input[key] = value
+		return input
+	}
+
+	tests := []struct {
+		name        string
+		input       map[string]interface{}
+		mockFn      func(*MockService)
+		wantCode    string
+		wantStatus  float64
+		errContains string
+	}{
+		{
+			name:  "successful registration",
+			input: validInput,
+			mockFn: func(ms *MockService) {
+				ms.On("Register", mock.Anything, serviceInput).Return(user, nil)
+			},
+		},
+		{
+			name:  "terms of service accepted",
+			input: withField("acceptedTos", true),
+			mockFn: func(ms *MockService) {
+				accepted := serviceInput
+				accepted.AcceptedTOS = true
+				ms.On("Register", mock.Anything, accepted).Return(user, nil)
+			},
+		},
+		{
+			name: "locale and timezone",
+			input: func() map[string]interface{} {
+				input := withField("locale", "th-TH")
+				input["timezone"] = "Asia/Bangkok"
+				return input
+			}(),
+			mockFn: func(ms *MockService) {
+				localized := serviceInput
+				localized.Locale, localized.Timezone = "th-TH", "Asia/Bangkok"
+				ms.On("Register", mock.Anything, localized).Return(user, nil)
+			},
+		},
+		{
+			name:        "invalid locale",
+			input:       withField("locale", "not a locale"),
+			wantCode:    "BAD_REQUEST",
+			wantStatus:  http.StatusBadRequest,
+			errContains: "Locale",
+		},
+		{
+			name:        "invalid timezone",
+			input:       withField("timezone", "Mars/Olympus_Mons"),
+			wantCode:    "BAD_REQUEST",
+			wantStatus:  http.StatusBadRequest,
+			errContains: "Timezone",
+		},
+		{
+			name:  "terms of service not accepted",
+			input: validInput,
+			mockFn: func(ms *MockService) {
+				ms.On("Register", mock.Anything, serviceInput).Return(nil, service.ErrTOSNotAccepted)
+			},
+			wantCode:    "TOS_NOT_ACCEPTED",
+			wantStatus:  http.StatusBadRequest,
+			errContains: service.ErrTOSNotAccepted.Error(),
+		},
+		{
+			name:  "auth_service error",
+			input: validInput,
+			mockFn: func(ms *MockService) {
+				ms.On("Register", mock.Anything, serviceInput).Return(nil, errors.New("database error"))
+			},
+			wantCode:    "INTERNAL_SERVER_ERROR",
+			wantStatus:  http.StatusInternalServerError,
+			errContains: "failed to register",
+		},
+		{
+			name:  "email taken",
+			input: validInput,
+			mockFn: func(ms *MockService) {
+				ms.On("Register", mock.Anything, serviceInput).Return(nil, service.ErrEmailTaken)
+			},
+			wantCode:    "EMAIL_TAKEN",
+			wantStatus:  http.StatusBadRequest,
+			errContains: service.ErrEmailTaken.Error(),
+		},
+		{
+			name:  "account recoverable",
+			input: validInput,
+			mockFn: func(ms *MockService) {
+				ms.On("Register", mock.Anything, serviceInput).Return(nil, service.ErrAccountRecoverable)
+			},
+			wantCode:    "ACCOUNT_RECOVERABLE",
+			wantStatus:  http.StatusBadRequest,
+			errContains: service.ErrAccountRecoverable.Error(),
+		},
+		{
+			name:  "database timeout",
+			input: validInput,
+			mockFn: func(ms *MockService) {
+				ms.On("Register", mock.Anything, serviceInput).Return(nil, repository.ErrTimeout)
+			},
+			wantCode:    "GATEWAY_TIMEOUT",
+			wantStatus:  http.StatusGatewayTimeout,
+			errContains: repository.ErrTimeout.Error(),
+		},
+		{
+			name:  "registration disabled",
+			input: validInput,
+			mockFn: func(ms *MockService) {
+				ms.On("Register", mock.Anything, serviceInput).Return(nil, service.ErrRegistrationDisabled)
+			},
+			wantCode:    "REGISTRATION_DISABLED",
+			wantStatus:  http.StatusServiceUnavailable,
+			errContains: service.ErrRegistrationDisabled.Error(),
+		},
+		{
+			name:  "disposable email",
+			input: validInput,
+			mockFn: func(ms *MockService) {
+				ms.On("Register", mock.Anything, serviceInput).Return(nil, service.ErrDisposableEmail)
+			},
+			wantCode:    "DISPOSABLE_EMAIL",
+			wantStatus:  http.StatusBadRequest,
+			errContains: service.ErrDisposableEmail.Error(),
+		},
+		{
+			name:        "invalid email",
+			input:       withField("email", "not-an-email"),
+			wantCode:    "BAD_REQUEST",
+			wantStatus:  http.StatusBadRequest,
+			errContains: "Email",
+		},
+		{
+			name:        "invalid password",
+			input:       withField("password", "short"),
+			wantCode:    "BAD_REQUEST",
+			wantStatus:  http.StatusBadRequest,
+			errContains: "Password",
+		},
+		{
+			name:        "invalid full name",
+			input:       withField("fullName", ""),
+			wantCode:    "BAD_REQUEST",
+			wantStatus:  http.StatusBadRequest,
+			errContains: "FullName",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mockService := setupGraphQLTest(anonymous, false)
+			if tt.mockFn != nil {
+				tt.mockFn(mockService)
+			}
+
+			w, res := execute(t, router, registerMutation, map[string]interface{}{"input": tt.input})
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			if tt.wantCode == "" {
+				require.Empty(t, res.Errors)
+				assert.JSONEq(t, `{"id": "`+user.ID.String()+`", "email": "test@example.com", "fullName": "Test User", "username": null, "role": "user"}`, string(res.Data["register"]))
+			} else {
+				require.Len(t, res.Errors, 1)
+				assert.Contains(t, res.Errors[0].Message, tt.errContains)
+				assert.Equal(t, tt.wantCode, res.Errors[0].Extensions["code"])
+				assert.Equal(t, tt.wantStatus, res.Errors[0].Extensions["status"])
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+const loginMutation = `mutation Login($input: LoginInput!) {
+	login(input: $input) { token refreshToken passwordExpired }
+}`
+
+func TestMutation_Login(t *testing.T) {
+	validInput := map[string]interface{}{"identifier": "test@example.com", "password": "password123"}
+	serviceInput := service.LoginInput{Identifier: "test@example.com", Password: "password123", IPAddress: "192.0.2.1", UserAgent: "test-agent"}
+
+	tests := []struct {
+		name        string
+		input       map[string]interface{}
+		mockFn      func(*MockService)
+		wantCode    string
+		wantStatus  float64
+		errContains string
+	}{
+		{
+			name:  "successful login",
+			input: validInput,
+			mockFn: func(ms *MockService) {
+				ms.On("Login", mock.Anything, serviceInput).Return(&service.TokenPair{AccessToken: "access", RefreshToken: "refresh"}, nil)
+			},
+		},
+		{
+			name:  "invalid credentials",
+			input: validInput,
+			mockFn: func(ms *MockService) {
+				ms.On("Login", mock.Anything, serviceInput).Return(nil, service.ErrInvalidCredentials)
+			},
+			wantCode:    "INVALID_CREDENTIALS",
+			wantStatus:  http.StatusBadRequest,
+			errContains: service.ErrInvalidCredentials.Error(),
+		},
+		{
+			name:  "expired password refused",
+			input: validInput,
+			mockFn: func(ms *MockService) {
+				ms.On("Login", mock.Anything, serviceInput).Return(nil, service.ErrPasswordExpired)
+			},
+			wantCode:    "PASSWORD_EXPIRED",
+			wantStatus:  http.StatusForbidden,
+			errContains: service.ErrPasswordExpired.Error(),
+		},
+		{
+			name:  "auth_service error",
+			input: validInput,
+			mockFn: func(ms *MockService) {
+				ms.On("Login", mock.Anything, serviceInput).Return(nil, errors.New("database error"))
+			},
+			wantCode:    "INTERNAL_SERVER_ERROR",
+			wantStatus:  http.StatusInternalServerError,
+			errContains: "failed to log in",
+		},
+		{
+			name:  "database unavailable",
+			input: validInput,
+			mockFn: func(ms *MockService) {
+				ms.On("Login", mock.Anything, serviceInput).Return(nil, repository.ErrConn)
+			},
+			wantCode:    "SERVICE_UNAVAILABLE",
+			wantStatus:  http.StatusServiceUnavailable,
+			errContains: repository.ErrConn.Error(),
+		},
+		{
+			name:  "database timeout",
+			input: validInput,
+			mockFn: func(ms *MockService) {
+				ms.On("Login", mock.Anything, serviceInput).Return(nil, repository.ErrTimeout)
+			},
+			wantCode:    "GATEWAY_TIMEOUT",
+			wantStatus:  http.StatusGatewayTimeout,
+			errContains: repository.ErrTimeout.Error(),
+		},
+		{
+			name:        "missing identifier",
+			input:       map[string]interface{}{"identifier": "", "password": "password123"},
+			wantCode:    "BAD_REQUEST",
+			wantStatus:  http.StatusBadRequest,
+			errContains: "Identifier",
+		},
+		{
+			name:        "missing password",
+			input:       map[string]interface{}{"identifier": "test@example.com", "password": ""},
+			wantCode:    "BAD_REQUEST",
+			wantStatus:  http.StatusBadRequest,
+			errContains: "Password",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mockService := setupGraphQLTest(anonymous, false)
+			if tt.mockFn != nil {
+				tt.mockFn(mockService)
+			}
+
+			w, res := execute(t, router, loginMutation, map[string]interface{}{"input": tt.input})
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			if tt.wantCode == "" {
+				require.Empty(t, res.Errors)
+				assert.JSONEq(t, `{"token": "access", "refreshToken": "refresh", "passwordExpired": false}`, string(res.Data["login"]))
+			} else {
+				require.Len(t, res.Errors, 1)
+				assert.Contains(t, res.Errors[0].Message, tt.errContains)
+				assert.Equal(t, tt.wantCode, res.Errors[0].Extensions["code"])
+				assert.Equal(t, tt.wantStatus, res.Errors[0].Extensions["status"])
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestQuery_Me(t *testing.T) {
+	userID := uuid.New()
+	createdAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	user := &model.User{ID: userID, Email: "test@example.com", FullName: "Test User", Role: model.RoleUser, Locale: "th-TH", Timezone: "Asia/Bangkok", CreatedAt: createdAt}
+	authenticated := func(c *gin.Context) {
+		authctx.SetUser(c, authctx.Identity{UserID: userID, Scopes: []string{service.ScopeProfileRead}})
+	}
+
+	tests := []struct {
+		name        string
+		setupAuth   func(*gin.Context)
+		mockFn      func(*MockService)
+		wantCode    string
+		wantStatus  float64
+		errContains string
+	}{
+		{
+			name:      "successful profile retrieval",
+			setupAuth: authenticated,
+			mockFn: func(ms *MockService) {
+				ms.On("GetUserByID", mock.Anything, userID.String()).Return(user, nil)
+			},
+		},
+		{
+			name:      "user not found",
+			setupAuth: authenticated,
+			mockFn: func(ms *MockService) {
+				ms.On("GetUserByID", mock.Anything, userID.String()).Return(nil, service.ErrUserNotFound)
+			},
+			wantCode:    "NOT_FOUND",
+			wantStatus:  http.StatusNotFound,
+			errContains: service.ErrUserNotFound.Error(),
+		},
+		{
+			name:      "auth_service error",
+			setupAuth: authenticated,
+			mockFn: func(ms *MockService) {
+				ms.On("GetUserByID", mock.Anything, userID.String()).Return(nil, errors.New("database error"))
+			},
+			wantCode:    "INTERNAL_SERVER_ERROR",
+			wantStatus:  http.StatusInternalServerError,
+			errContains: "failed to get profile",
+		},
+		{
+			name:      "database unavailable",
+			setupAuth: authenticated,
+			mockFn: func(ms *MockService) {
+				ms.On("GetUserByID", mock.Anything, userID.String()).Return(nil, repository.ErrConn)
+			},
+			wantCode:    "SERVICE_UNAVAILABLE",
+			wantStatus:  http.StatusServiceUnavailable,
+			errContains: repository.ErrConn.Error(),
+		},
+		{
+			name:      "database timeout",
+			setupAuth: authenticated,
+			mockFn: func(ms *MockService) {
+				ms.On("GetUserByID", mock.Anything, userID.String()).Return(nil, repository.ErrTimeout)
+			},
+			wantCode:    "GATEWAY_TIMEOUT",
+			wantStatus:  http.StatusGatewayTimeout,
+			errContains: repository.ErrTimeout.Error(),
+		},
+		{
+			name:        "no user_id in context",
+			setupAuth:   anonymous,
+			wantCode:    "UNAUTHORIZED",
+			wantStatus:  http.StatusUnauthorized,
+			errContains: "unauthorized",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mockService := setupGraphQLTest(tt.setupAuth, false)
+			if tt.mockFn != nil {
+				tt.mockFn(mockService)
+			}
+
+			w, res := execute(t, router, `{ me { id email locale timezone createdAt } }`, nil)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			if tt.wantCode == "" {
+				require.Empty(t, res.Errors)
+				assert.JSONEq(t, `{"id": "`+userID.String()+`", "email": "test@example.com", "locale": "th-TH", "timezone": "Asia/Bangkok", "createdAt": "2024-01-01T12:00:00Z"}`, string(res.Data["me"]))
+			} else {
+				require.Len(t, res.Errors, 1)
+				assert.Contains(t, res.Errors[0].Message, tt.errContains)
+				assert.Equal(t, tt.wantCode, res.Errors[0].Extensions["code"])
+				assert.Equal(t, tt.wantStatus, res.Errors[0].Extensions["status"])
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestQuery_MeGates(t *testing.T) {
+	userID := uuid.New()
+	user := &model.User{ID: userID, Email: "test@example.com"}
+	withScopes := func(scopes ...string) func(*gin.Context) {
+		return func(c *gin.Context) {
+			authctx.SetUser(c, authctx.Identity{UserID: userID, Scopes: scopes})
+		}
+	}
+	allGates := Gates{TOS: true, UnexpiredPassword: true}
+
+	tests := []struct {
+		name        string
+		setupAuth   func(*gin.Context)
+		gates       Gates
+		mockFn      func(*MockService)
+		wantCode    string
+		wantStatus  float64
+		errContains string
+	}{
+		{
+			name:      "all gates pass",
+			setupAuth: withScopes(service.ScopeProfileRead),
+			gates:     allGates,
+			mockFn: func(ms *MockService) {
+				ms.On("CheckTOS", mock.Anything, userID.String()).Return(nil)
+				ms.On("CheckPasswordExpiry", mock.Anything, userID.String()).Return(nil)
+				ms.On("GetUserByID", mock.Anything, userID.String()).Return(user, nil)
+			},
+		},
+		{
+			name:        "token without profile:read",
+			setupAuth:   withScopes(service.ScopeProfileWrite),
+			wantCode:    "INSUFFICIENT_SCOPE",
+			wantStatus:  http.StatusForbidden,
+			errContains: "token lacks the profile:read scope",
+		},
+		{
+			name:      "terms of service not accepted",
+			setupAuth: withScopes(service.ScopeProfileRead),
+			gates:     allGates,
+			mockFn: func(ms *MockService) {
+				ms.On("CheckTOS", mock.Anything, userID.String()).Return(service.ErrTOSReacceptanceNeeded)
+			},
+			wantCode:    "TOS_REACCEPTANCE_REQUIRED",
+			wantStatus:  http.StatusPreconditionRequired,
+			errContains: service.ErrTOSReacceptanceNeeded.Error(),
+		},
+		{
+			name:      "terms of service check timed out",
+			setupAuth: withScopes(service.ScopeProfileRead),
+			gates:     Gates{TOS: true},
+			mockFn: func(ms *MockService) {
+				ms.On("CheckTOS", mock.Anything, userID.String()).Return(repository.ErrTimeout)
+			},
+			wantCode:    "GATEWAY_TIMEOUT",
+			wantStatus:  http.StatusGatewayTimeout,
+			errContains: repository.ErrTimeout.Error(),
+		},
+		{
+			name:      "password expired",
+			setupAuth: withScopes(service.ScopeProfileRead),
+			gates:     allGates,
+			mockFn: func(ms *MockService) {
+				ms.On("CheckTOS", mock.Anything, userID.String()).Return(nil)
+				ms.On("CheckPasswordExpiry", mock.Anything, userID.String()).Return(service.ErrPasswordExpired)
+			},
+			wantCode:    "PASSWORD_EXPIRED",
+			wantStatus:  http.StatusForbidden,
+			errContains: service.ErrPasswordExpired.Error(),
+		},
+		{
+			name:      "password expiry check failed",
+			setupAuth: withScopes(service.ScopeProfileRead),
+			gates:     Gates{UnexpiredPassword: true},
+			mockFn: func(ms *MockService) {
+				ms.On("CheckPasswordExpiry", mock.Anything, userID.String()).Return(errors.New("database error"))
+			},
+			wantCode:    "INTERNAL_SERVER_ERROR",
+			wantStatus:  http.StatusInternalServerError,
+			errContains: "failed to check password expiry",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mockService := setupGatedGraphQLTest(tt.setupAuth, tt.gates, false)
+			if tt.mockFn != nil {
+				tt.mockFn(mockService)
+			}
+
+			w, res := execute(t, router, `{ me { id } }`, nil)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			if tt.wantCode == "" {
+				require.Empty(t, res.Errors)
+				assert.JSONEq(t, `{"id": "`+userID.String()+`"}`, string(res.Data["me"]))
+			} else {
+				require.Len(t, res.Errors, 1)
+				assert.Contains(t, res.Errors[0].Message, tt.errContains)
+				assert.Equal(t, tt.wantCode, res.Errors[0].Extensions["code"])
+				assert.Equal(t, tt.wantStatus, res.Errors[0].Extensions["status"])
+				mockService.AssertNotCalled(t, "GetUserByID", mock.Anything, mock.Anything)
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestNewHandler_Introspection(t *testing.T) {
+	const query = `{ __schema { queryType { name } } }`
+
+	router, _ := setupGraphQLTest(anonymous, true)
+	_, res := execute(t, router, query, nil)
+	assert.Empty(t, res.Errors)
+	assert.JSONEq(t, `{"queryType": {"name": "Query"}}`, string(res.Data["__schema"]))
+
+	router, _ = setupGraphQLTest(anonymous, false)
+	_, res = execute(t, router, query, nil)
+	require.Len(t, res.Errors, 1)
+	assert.Contains(t, res.Errors[0].Message, "introspection disabled")
+}
+
+func TestNewHandler_Limits(t *testing.T) {
+	router, mockService := setupGraphQLTest(anonymous, true)
+
+	// Introspection types nest arbitrarily deep, so they exceed the depth limit.
+	deep := `{ __schema { types { fields { type { ofType { ofType { ofType { ofType { name } } } } } } } } }`
+	_, res := execute(t, router, deep, nil)
+	require.Len(t, res.Errors, 1)
+	assert.Contains(t, res.Errors[0].Message, "exceeds the limit of 8")
+	assert.Equal(t, "DEPTH_LIMIT_EXCEEDED", res.Errors[0].Extensions["code"])
+
+	// Aliases make a shallow operation arbitrarily complex.
+	var wide strings.Builder
+	wide.WriteString("{")
+	for i := 0; i < MaxComplexity; i++ {
+		wide.WriteString(" m" + string(rune('a'+i%26)) + string(rune('a'+i/26)) + ": me { id }")
+	}
+	wide.WriteString(" }")
+	_, res = execute(t, router, wide.String(), nil)
+	require.Len(t, res.Errors, 1)
+	assert.Contains(t, res.Errors[0].Message, "exceeds the limit of 100")
+	mockService.AssertNotCalled(t, "GetUserByID", mock.Anything, mock.Anything)
+}
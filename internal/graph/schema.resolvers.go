@@ -0,0 +1,147 @@
+package graph
+
+// The resolvers of schema.graphqls. gqlgen only created this file: after
+// adding a field to the schema, add its resolver here by hand, with the
+// signature of the resolver interface in generated.go.
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/PakornBank/learn-go/internal/authctx"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// Register is the resolver for the register field. It fails as the REST
+// register route does, with the same statuses and codes.
+func (r *mutationResolver) Register(ctx context.Context, input RegisterInput) (*model.User, error) {
+	registerInput := service.RegisterInput{
+		Email:    input.Email,
+		Password: input.Password,
+		FullName: input.FullName,
+	}
+	if input.Username != nil {
+		registerInput.Username = *input.Username
+	}
+	if input.AcceptedTos != nil {
+		registerInput.AcceptedTOS = *input.AcceptedTos
+	}
+	if input.Locale != nil {
+		registerInput.Locale = *input.Locale
+	}
+	if input.Timezone != nil {
+		registerInput.Timezone = *input.Timezone
+	}
+	if err := binding.Validator.ValidateStruct(registerInput); err != nil {
+		return nil, newError(ctx, http.StatusBadRequest, "", err.Error())
+	}
+	if c := ginContext(ctx); c != nil {
+		registerInput.AcceptLanguage = c.GetHeader("Accept-Language")
+	}
+
+	user, err := r.service.Register(ctx, registerInput)
+	switch {
+	case err == nil:
+		return user, nil
+	case errors.Is(err, service.ErrRegistrationDisabled):
+		return nil, newError(ctx, http.StatusServiceUnavailable, "REGISTRATION_DISABLED", service.ErrRegistrationDisabled.Error())
+	case errors.Is(err, service.ErrDisposableEmail):
+		return nil, newError(ctx, http.StatusBadRequest, "DISPOSABLE_EMAIL", service.ErrDisposableEmail.Error())
+	case errors.Is(err, service.ErrEmailDomainNotAllowed):
+		return nil, newError(ctx, http.StatusBadRequest, "EMAIL_DOMAIN_NOT_ALLOWED", service.ErrEmailDomainNotAllowed.Error())
+	case errors.Is(err, service.ErrTOSNotAccepted):
+		return nil, newError(ctx, http.StatusBadRequest, "TOS_NOT_ACCEPTED", service.ErrTOSNotAccepted.Error())
+	case errors.Is(err, service.ErrAccountRecoverable):
+		return nil, newError(ctx, http.StatusBadRequest, "ACCOUNT_RECOVERABLE", service.ErrAccountRecoverable.Error())
+	case errors.Is(err, service.ErrEmailTaken):
+		return nil, newError(ctx, http.StatusBadRequest, "EMAIL_TAKEN", service.ErrEmailTaken.Error())
+	case errors.Is(err, service.ErrUsernameTaken),
+		errors.Is(err, service.ErrInvalidUsername),
+		errors.Is(err, service.ErrUsernameReserved),
+		errors.Is(err, service.ErrInvalidLocale),
+		errors.Is(err, service.ErrInvalidTimezone),
+		errors.Is(err, service.ErrPasswordBreached):
+		return nil, newError(ctx, http.StatusBadRequest, "", err.Error())
+	case errors.Is(err, repository.ErrTimeout):
+		return nil, newError(ctx, http.StatusGatewayTimeout, "", err.Error())
+	case errors.Is(err, repository.ErrConn):
+		reportError(ctx, err)
+		return nil, newError(ctx, http.StatusServiceUnavailable, "", err.Error())
+	default:
+		reportError(ctx, err)
+		return nil, newError(ctx, http.StatusInternalServerError, "", "failed to register")
+	}
+}
+
+// Login is the resolver for the login field. It fails as the REST login
+// route does, with the same statuses and codes.
+func (r *mutationResolver) Login(ctx context.Context, input LoginInput) (*AuthPayload, error) {
+	loginInput := service.LoginInput{Identifier: input.Identifier, Password: input.Password}
+	if err := binding.Validator.ValidateStruct(loginInput); err != nil {
+		return nil, newError(ctx, http.StatusBadRequest, "", err.Error())
+	}
+	if c := ginContext(ctx); c != nil {
+		loginInput.IPAddress = c.ClientIP()
+		loginInput.UserAgent = c.Request.UserAgent()
+	}
+
+	tokens, err := r.service.Login(ctx, loginInput)
+	switch {
+	case err == nil:
+		return &AuthPayload{Token: tokens.AccessToken, RefreshToken: tokens.RefreshToken, PasswordExpired: tokens.PasswordExpired}, nil
+	case errors.Is(err, service.ErrInvalidCredentials):
+		return nil, newError(ctx, http.StatusBadRequest, "INVALID_CREDENTIALS", service.ErrInvalidCredentials.Error())
+	case errors.Is(err, service.ErrPasswordExpired):
+		return nil, newError(ctx, http.StatusForbidden, "PASSWORD_EXPIRED", service.ErrPasswordExpired.Error())
+	case errors.Is(err, repository.ErrTimeout):
+		return nil, newError(ctx, http.StatusGatewayTimeout, "", err.Error())
+	case errors.Is(err, repository.ErrConn):
+		reportError(ctx, err)
+		return nil, newError(ctx, http.StatusServiceUnavailable, "", err.Error())
+	default:
+		reportError(ctx, err)
+		return nil, newError(ctx, http.StatusInternalServerError, "", "failed to log in")
+	}
+}
+
+// Me is the resolver for the me field. It reads the identity that the
+// authentication middleware sets on the request, and fails as the REST profile route does,
+// with the same statuses and codes, including those of its middleware.
+func (r *queryResolver) Me(ctx context.Context) (*model.User, error) {
+	identity, ok := authctx.UserFrom(ctx)
+	if !ok {
+		return nil, newError(ctx, http.StatusUnauthorized, "", "unauthorized")
+	}
+	if err := r.checkProfileAccess(ctx, identity); err != nil {
+		return nil, err
+	}
+
+	user, err := r.service.GetUserByID(ctx, identity.UserID.String())
+	switch {
+	case err == nil:
+		return user, nil
+	case errors.Is(err, service.ErrUserNotFound):
+		return nil, newError(ctx, http.StatusNotFound, "", service.ErrUserNotFound.Error())
+	case errors.Is(err, repository.ErrTimeout):
+		return nil, newError(ctx, http.StatusGatewayTimeout, "", err.Error())
+	case errors.Is(err, repository.ErrConn):
+		reportError(ctx, err)
+		return nil, newError(ctx, http.StatusServiceUnavailable, "", err.Error())
+	default:
+		reportError(ctx, err)
+		return nil, newError(ctx, http.StatusInternalServerError, "", "failed to get profile")
+	}
+}
+
+// Mutation returns MutationResolver implementation.
+func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
+
+// Query returns QueryResolver implementation.
+func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
+
+type mutationResolver struct{ *Resolver }
+type queryResolver struct{ *Resolver }
@@ -0,0 +1,108 @@
+package graph
+
+import (
+	"context"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/gin-gonic/gin"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Limits on the operations the server executes, so that a single request
+// cannot make it do unbounded work.
+const (
+	// MaxDepth is the deepest nesting of fields an operation may select.
+	MaxDepth = 8
+	// MaxComplexity is the highest complexity an operation may have, where
+	// each selected field counts 1.
+	MaxComplexity = 100
+)
+
+// ginContextKey is the context key NewHandler stores the Gin context under.
+type ginContextKey struct{}
+
+// NewHandler returns a Gin handler serving the GraphQL API over POST, with
+// its operations delegated to s. Operations nested deeper than MaxDepth or
+// more complex than MaxComplexity are rejected, and introspection is only
+// allowed when introspection is true, since production should not publish
+// its schema.
+//
+// The resolvers read the identity set by the authentication middleware from
+// the request context, so the route should use middleware.OptionalAuth:
+// operations other than me do not need a token. Since the route cannot then
+// use the middleware gating the REST profile route, me makes those checks
+// itself, as selected by gates.
+func NewHandler(s Service, gates Gates, introspection bool) gin.HandlerFunc {
+	srv := handler.New(NewExecutableSchema(Config{Resolvers: &Resolver{service: s, gates: gates}}))
+	srv.AddTransport(transport.POST{})
+	srv.Use(extension.FixedComplexityLimit(MaxComplexity))
+	srv.Use(depthLimit{max: MaxDepth})
+	if introspection {
+		srv.Use(extension.Introspection{})
+	}
+
+	return func(c *gin.Context) {
+		ctx := context.WithValue(c.Request.Context(), ginContextKey{}, c)
+		srv.ServeHTTP(c.Writer, c.Request.WithContext(ctx))
+	}
+}
+
+// ginContext returns the Gin context of the request being resolved, or nil
+// outside of a request served by NewHandler.
+func ginContext(ctx context.Context) *gin.Context {
+	c, _ := ctx.Value(ginContextKey{}).(*gin.Context)
+	return c
+}
+
+// depthLimit is a handler extension that rejects operations selecting fields
+// nested deeper than max.
+type depthLimit struct {
+	max int
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationContextMutator
+} = depthLimit{}
+
+func (depthLimit) ExtensionName() string {
+	return "DepthLimit"
+}
+
+func (depthLimit) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (d depthLimit) MutateOperationContext(ctx context.Context, rc *graphql.OperationContext) *gqlerror.Error {
+	if depth := selectionDepth(rc.Operation.SelectionSet); depth > d.max {
+		err := gqlerror.Errorf("operation has depth %d, which exceeds the limit of %d", depth, d.max)
+		err.Extensions = map[string]interface{}{"code": "DEPTH_LIMIT_EXCEEDED"}
+		return err
+	}
+	return nil
+}
+
+// selectionDepth returns how deeply fields are nested in set, looking through
+// fragments. Fragment cycles are rejected by validation before this runs.
+func selectionDepth(set ast.SelectionSet) int {
+	depth := 0
+	for _, selection := range set {
+		var d int
+		switch selection := selection.(type) {
+		case *ast.Field:
+			d = 1 + selectionDepth(selection.SelectionSet)
+		case *ast.InlineFragment:
+			d = selectionDepth(selection.SelectionSet)
+		case *ast.FragmentSpread:
+			if selection.Definition != nil {
+				d = selectionDepth(selection.Definition.SelectionSet)
+			}
+		}
+		depth = max(depth, d)
+	}
+	return depth
+}
@@ -0,0 +1,201 @@
+// Package grpcserver exposes the authentication service over gRPC as a thin
+// adapter over the same service used by the HTTP handlers.
+package grpcserver
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	authv1 "github.com/PakornBank/learn-go/proto/auth/v1"
+	"github.com/go-playground/validator/v10"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Service defines the methods that the gRPC server must be able to call.
+type Service interface {
+	Register(ctx context.Context, input service.RegisterInput) (*model.User, error)
+	Login(ctx context.Context, input service.LoginInput) (*service.TokenPair, error)
+	Introspect(ctx context.Context, token string) (*service.Introspection, error)
+	GetUserByID(ctx context.Context, id string) (*model.User, error)
+}
+
+// Server implements authv1.AuthServiceServer.
+type Server struct {
+	authv1.UnimplementedAuthServiceServer
+	service  Service
+	validate *validator.Validate
+}
+
+// NewServer creates a Server that delegates to s.
+func NewServer(s Service) *Server {
+	// Inputs carry the same "binding" tags Gin validates over HTTP, so gRPC
+	// requests are held to the same rules.
+	validate := validator.New()
+	validate.SetTagName("binding")
+
+	return &Server{service: s, validate: validate}
+}
+
+// Register creates a new user.
+func (s *Server) Register(ctx context.Context, req *authv1.RegisterRequest) (*authv1.RegisterResponse, error) {
+	input := service.RegisterInput{
+		Email:    req.GetEmail(),
+		Password: req.GetPassword(),
+		FullName: req.GetFullName(),
+	}
+	if err := s.validate.Struct(input); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	user, err := s.service.Register(ctx, input)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &authv1.RegisterResponse{User: toProtoUser(user)}, nil
+}
+
+// Login authenticates a user. The client address and user agent are taken
+// from the connection so login attempts are recorded like HTTP ones.
+func (s *Server) Login(ctx context.Context, req *authv1.LoginRequest) (*authv1.LoginResponse, error) {
+	input := service.LoginInput{
+		Email:    req.GetEmail(),
+		Password: req.GetPassword(),
+	}
+	if err := s.validate.Struct(input); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if p, ok := peer.FromContext(ctx); ok {
+		input.IPAddress = p.Addr.String()
+		if host, _, err := net.SplitHostPort(input.IPAddress); err == nil {
+			input.IPAddress = host
+		}
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ua := md.Get("user-agent"); len(ua) > 0 {
+			input.UserAgent = ua[0]
+		}
+	}
+
+	tokens, err := s.service.Login(ctx, input)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &authv1.LoginResponse{AccessToken: tokens.AccessToken, RefreshToken: tokens.RefreshToken}, nil
+}
+
+// ValidateToken reports whether an access token is active. An inactive token
+// is not an error; the response simply has Active set to false.
+func (s *Server) ValidateToken(ctx context.Context, req *authv1.ValidateTokenRequest) (*authv1.ValidateTokenResponse, error) {
+	if req.GetToken() == "" {
+		return nil, status.Error(codes.InvalidArgument, "token is required")
+	}
+
+	result, err := s.service.Introspect(ctx, req.GetToken())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &authv1.ValidateTokenResponse{
+		Active:    result.Active,
+		Subject:   result.Subject,
+		Email:     result.Email,
+		ExpiresAt: result.ExpiresAt,
+		IssuedAt:  result.IssuedAt,
+	}, nil
+}
+
+// GetUser looks up a user by ID.
+func (s *Server) GetUser(ctx context.Context, req *authv1.GetUserRequest) (*authv1.GetUserResponse, error) {
+	user, err := s.service.GetUserByID(ctx, req.GetId())
+	if errors.Is(err, service.ErrUserNotFound) {
+		return nil, status.Error(codes.NotFound, service.ErrUserNotFound.Error())
+	}
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &authv1.GetUserResponse{User: toProtoUser(user)}, nil
+}
+
+// ServiceSecretMetadata is the metadata key internal services present the
+// shared secret with, the gRPC counterpart of middleware.ServiceSecretHeader.
+const ServiceSecretMetadata = "x-service-secret"
+
+// RequireServiceSecret returns an interceptor that rejects calls that do not
+// present secret in ServiceSecretMetadata with codes.Unauthenticated. Every
+// call is rejected when secret is empty.
+func RequireServiceSecret(secret string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		var provided string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(ServiceSecretMetadata); len(values) > 0 {
+				provided = values[0]
+			}
+		}
+		if secret == "" || provided == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(secret)) != 1 {
+			return nil, status.Error(codes.Unauthenticated, "invalid service secret")
+		}
+		return handler(ctx, req)
+	}
+}
+
+// NewGRPCServer creates a grpc.Server with s registered as the AuthService.
+// Pass RequireServiceSecret with grpc.UnaryInterceptor in opts to restrict it
+// to internal services.
+func NewGRPCServer(s *Server, opts ...grpc.ServerOption) *grpc.Server {
+	server := grpc.NewServer(opts...)
+	authv1.RegisterAuthServiceServer(server, s)
+	return server
+}
+
+// toStatus maps service errors to canonical gRPC status codes.
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, service.ErrTOSNotAccepted):
+		return status.Error(codes.FailedPrecondition, service.ErrTOSNotAccepted.Error())
+	case errors.Is(err, service.ErrAccountRecoverable):
+		return status.Error(codes.AlreadyExists, service.ErrAccountRecoverable.Error())
+	case errors.Is(err, service.ErrEmailTaken):
+		return status.Error(codes.AlreadyExists, service.ErrEmailTaken.Error())
+	case errors.Is(err, service.ErrInvalidCredentials):
+		return status.Error(codes.Unauthenticated, service.ErrInvalidCredentials.Error())
+	case errors.Is(err, service.ErrPasswordExpired):
+		return status.Error(codes.FailedPrecondition, service.ErrPasswordExpired.Error())
+	case errors.Is(err, service.ErrPasswordBreached), errors.Is(err, service.ErrDisposableEmail), errors.Is(err, service.ErrEmailDomainNotAllowed):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrRegistrationDisabled), errors.Is(err, repository.ErrConn):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, repository.ErrTimeout):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	default:
+		return status.Error(codes.Internal, "internal error")
+	}
+}
+
+func toProtoUser(user *model.User) *authv1.User {
+	pb := &authv1.User{
+		Id:        user.ID.String(),
+		Email:     user.Email,
+		FullName:  user.FullName,
+		Role:      user.Role,
+		CreatedAt: timestamppb.New(user.CreatedAt),
+		UpdatedAt: timestamppb.New(user.UpdatedAt),
+	}
+	if user.LastLoginAt != nil {
+		pb.LastLoginAt = timestamppb.New(*user.LastLoginAt)
+	}
+	return pb
+}
@@ -0,0 +1,419 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	authv1 "github.com/PakornBank/learn-go/proto/auth/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type MockService struct {
+	mock.Mock
+}
+
+func (m *MockService) Register(ctx context.Context, input service.RegisterInput) (*model.User, error) {
+	args := m.Called(ctx, input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+func (m *MockService) Login(ctx context.Context, input service.LoginInput) (*service.TokenPair, error) {
+	args := m.Called(ctx, input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.TokenPair), args.Error(1)
+}
+
+func (m *MockService) Introspect(ctx context.Context, token string) (*service.Introspection, error) {
+	args := m.Called(ctx, token)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.Introspection), args.Error(1)
+}
+
+func (m *MockService) GetUserByID(ctx context.Context, id string) (*model.User, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+const testServiceSecret = "test-service-secret"
+
+// setupTest serves a Server backed by a MockService over an in-memory
+// connection and returns a client connected to it, which presents the
+// service secret the server requires.
+func setupTest(t *testing.T) (authv1.AuthServiceClient, *MockService) {
+	mockService := new(MockService)
+	lis := bufconn.Listen(1024 * 1024)
+	server := NewGRPCServer(NewServer(mockService), grpc.UnaryInterceptor(RequireServiceSecret(testServiceSecret)))
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			ctx = metadata.AppendToOutgoingContext(ctx, ServiceSecretMetadata, testServiceSecret)
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return authv1.NewAuthServiceClient(conn), mockService
+}
+
+func TestRequireServiceSecret(t *testing.T) {
+	tests := []struct {
+		name     string
+		secret   string
+		provided []string
+		wantCode codes.Code
+	}{
+		{name: "matching secret", secret: "secret", provided: []string{"secret"}, wantCode: codes.OK},
+		{name: "wrong secret", secret: "secret", provided: []string{"wrong"}, wantCode: codes.Unauthenticated},
+		{name: "missing secret", secret: "secret", wantCode: codes.Unauthenticated},
+		{name: "no secret configured", provided: []string{""}, wantCode: codes.Unauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.provided != nil {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(ServiceSecretMetadata, tt.provided[0]))
+			}
+			called := false
+			handler := func(context.Context, any) (any, error) {
+				called = true
+				return "ok", nil
+			}
+
+			_, err := RequireServiceSecret(tt.secret)(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			assert.Equal(t, tt.wantCode == codes.OK, called)
+		})
+	}
+}
+
+func TestServer_Register(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+
+	tests := []struct {
+		name     string
+		req      *authv1.RegisterRequest
+		mockFn   func(*MockService)
+		wantCode codes.Code
+	}{
+		{
+			name: "successful registration",
+			req:  &authv1.RegisterRequest{Email: mockUser.Email, Password: "password", FullName: mockUser.FullName},
+			mockFn: func(ms *MockService) {
+				ms.On("Register", mock.Anything, service.RegisterInput{Email: mockUser.Email, Password: "password", FullName: mockUser.FullName}).
+					Return(&mockUser, nil)
+			},
+			wantCode: codes.OK,
+		},
+		{
+			name:     "invalid input",
+			req:      &authv1.RegisterRequest{Email: "not-an-email", Password: "short", FullName: mockUser.FullName},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name: "email already registered",
+			req:  &authv1.RegisterRequest{Email: mockUser.Email, Password: "password", FullName: mockUser.FullName},
+			mockFn: func(ms *MockService) {
+				ms.On("Register", mock.Anything, mock.Anything).Return(nil, service.ErrEmailTaken)
+			},
+			wantCode: codes.AlreadyExists,
+		},
+		{
+			name: "terms of service required",
+			req:  &authv1.RegisterRequest{Email: mockUser.Email, Password: "password", FullName: mockUser.FullName},
+			mockFn: func(ms *MockService) {
+				ms.On("Register", mock.Anything, mock.Anything).Return(nil, service.ErrTOSNotAccepted)
+			},
+			wantCode: codes.FailedPrecondition,
+		},
+		{
+			name: "account scheduled for deletion",
+			req:  &authv1.RegisterRequest{Email: mockUser.Email, Password: "password", FullName: mockUser.FullName},
+			mockFn: func(ms *MockService) {
+				ms.On("Register", mock.Anything, mock.Anything).Return(nil, service.ErrAccountRecoverable)
+			},
+			wantCode: codes.AlreadyExists,
+		},
+		{
+			name: "breached password",
+			req:  &authv1.RegisterRequest{Email: mockUser.Email, Password: "password", FullName: mockUser.FullName},
+			mockFn: func(ms *MockService) {
+				ms.On("Register", mock.Anything, mock.Anything).Return(nil, service.ErrPasswordBreached)
+			},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name: "registration disabled",
+			req:  &authv1.RegisterRequest{Email: mockUser.Email, Password: "password", FullName: mockUser.FullName},
+			mockFn: func(ms *MockService) {
+				ms.On("Register", mock.Anything, mock.Anything).Return(nil, service.ErrRegistrationDisabled)
+			},
+			wantCode: codes.Unavailable,
+		},
+		{
+			name: "database timeout",
+			req:  &authv1.RegisterRequest{Email: mockUser.Email, Password: "password", FullName: mockUser.FullName},
+			mockFn: func(ms *MockService) {
+				ms.On("Register", mock.Anything, mock.Anything).Return(nil, repository.ErrTimeout)
+			},
+			wantCode: codes.DeadlineExceeded,
+		},
+		{
+			name: "unexpected error",
+			req:  &authv1.RegisterRequest{Email: mockUser.Email, Password: "password", FullName: mockUser.FullName},
+			mockFn: func(ms *MockService) {
+				ms.On("Register", mock.Anything, mock.Anything).Return(nil, errors.New("boom"))
+			},
+			wantCode: codes.Internal,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, mockService := setupTest(t)
+			if tt.mockFn != nil {
+				tt.mockFn(mockService)
+			}
+
+			res, err := client.Register(context.Background(), tt.req)
+
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			if tt.wantCode == codes.OK {
+				assert.Equal(t, mockUser.ID.String(), res.GetUser().GetId())
+				assert.Equal(t, mockUser.Email, res.GetUser().GetEmail())
+				assert.Equal(t, mockUser.Role, res.GetUser().GetRole())
+				assert.Nil(t, res.GetUser().GetLastLoginAt())
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestServer_Login(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      *authv1.LoginRequest
+		mockFn   func(*MockService)
+		wantCode codes.Code
+	}{
+		{
+			name: "successful login",
+			req:  &authv1.LoginRequest{Email: "test@example.com", Password: "password"},
+			mockFn: func(ms *MockService) {
+				ms.On("Login", mock.Anything, mock.MatchedBy(func(input service.LoginInput) bool {
+					return input.Email == "test@example.com" && input.Password == "password" &&
+						input.IPAddress != "" && input.UserAgent != ""
+				})).Return(&service.TokenPair{AccessToken: "access", RefreshToken: "refresh"}, nil)
+			},
+			wantCode: codes.OK,
+		},
+		{
+			name:     "missing password",
+			req:      &authv1.LoginRequest{Email: "test@example.com"},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name: "invalid credentials",
+			req:  &authv1.LoginRequest{Email: "test@example.com", Password: "wrong"},
+			mockFn: func(ms *MockService) {
+				ms.On("Login", mock.Anything, mock.Anything).Return(nil, service.ErrInvalidCredentials)
+			},
+			wantCode: codes.Unauthenticated,
+		},
+		{
+			name: "expired password refused",
+			req:  &authv1.LoginRequest{Email: "test@example.com", Password: "password"},
+			mockFn: func(ms *MockService) {
+				ms.On("Login", mock.Anything, mock.Anything).Return(nil, service.ErrPasswordExpired)
+			},
+			wantCode: codes.FailedPrecondition,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, mockService := setupTest(t)
+			if tt.mockFn != nil {
+				tt.mockFn(mockService)
+			}
+
+			ctx := metadata.AppendToOutgoingContext(context.Background(), "user-agent", "test-agent")
+			res, err := client.Login(ctx, tt.req)
+
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			if tt.wantCode == codes.OK {
+				assert.Equal(t, "access", res.GetAccessToken())
+				assert.Equal(t, "refresh", res.GetRefreshToken())
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestServer_LoginIPAddress(t *testing.T) {
+	mockService := new(MockService)
+	mockService.On("Login", mock.Anything, mock.MatchedBy(func(input service.LoginInput) bool {
+		return input.IPAddress == "203.0.113.7"
+	})).Return(&service.TokenPair{AccessToken: "access", RefreshToken: "refresh"}, nil)
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 51234}})
+
+	_, err := NewServer(mockService).Login(ctx, &authv1.LoginRequest{Email: "test@example.com", Password: "password"})
+
+	require.NoError(t, err)
+	mockService.AssertExpectations(t)
+}
+
+func TestServer_ValidateToken(t *testing.T) {
+	tests := []struct {
+		name       string
+		token      string
+		mockFn     func(*MockService)
+		wantCode   codes.Code
+		wantActive bool
+	}{
+		{
+			name:  "active token",
+			token: "valid",
+			mockFn: func(ms *MockService) {
+				ms.On("Introspect", mock.Anything, "valid").Return(&service.Introspection{
+					Active: true, Subject: "user-id", Email: "test@example.com", ExpiresAt: 200, IssuedAt: 100,
+				}, nil)
+			},
+			wantCode:   codes.OK,
+			wantActive: true,
+		},
+		{
+			name:  "inactive token",
+			token: "expired",
+			mockFn: func(ms *MockService) {
+				ms.On("Introspect", mock.Anything, "expired").Return(&service.Introspection{Active: false}, nil)
+			},
+			wantCode: codes.OK,
+		},
+		{
+			name:     "missing token",
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:  "revocation lookup failure",
+			token: "valid",
+			mockFn: func(ms *MockService) {
+				ms.On("Introspect", mock.Anything, "valid").Return(nil, errors.New("boom"))
+			},
+			wantCode: codes.Internal,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, mockService := setupTest(t)
+			if tt.mockFn != nil {
+				tt.mockFn(mockService)
+			}
+
+			res, err := client.ValidateToken(context.Background(), &authv1.ValidateTokenRequest{Token: tt.token})
+
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			if tt.wantCode == codes.OK {
+				assert.Equal(t, tt.wantActive, res.GetActive())
+				if tt.wantActive {
+					assert.Equal(t, "user-id", res.GetSubject())
+					assert.Equal(t, int64(200), res.GetExpiresAt())
+				}
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestServer_GetUser(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	lastLoginAt := time.Now()
+	mockUser.LastLoginAt = &lastLoginAt
+
+	tests := []struct {
+		name     string
+		mockFn   func(*MockService)
+		wantCode codes.Code
+	}{
+		{
+			name: "user found",
+			mockFn: func(ms *MockService) {
+				ms.On("GetUserByID", mock.Anything, mockUser.ID.String()).Return(&mockUser, nil)
+			},
+			wantCode: codes.OK,
+		},
+		{
+			name: "user not found",
+			mockFn: func(ms *MockService) {
+				ms.On("GetUserByID", mock.Anything, mockUser.ID.String()).Return(nil, fmt.Errorf("%w: %w", service.ErrUserNotFound, repository.ErrNotFound))
+			},
+			wantCode: codes.NotFound,
+		},
+		{
+			name: "database timeout",
+			mockFn: func(ms *MockService) {
+				ms.On("GetUserByID", mock.Anything, mockUser.ID.String()).Return(nil, repository.ErrTimeout)
+			},
+			wantCode: codes.DeadlineExceeded,
+		},
+		{
+			name: "database unavailable",
+			mockFn: func(ms *MockService) {
+				ms.On("GetUserByID", mock.Anything, mockUser.ID.String()).Return(nil, repository.ErrConn)
+			},
+			wantCode: codes.Unavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, mockService := setupTest(t)
+			tt.mockFn(mockService)
+
+			res, err := client.GetUser(context.Background(), &authv1.GetUserRequest{Id: mockUser.ID.String()})
+
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			if tt.wantCode == codes.OK {
+				assert.Equal(t, mockUser.Email, res.GetUser().GetEmail())
+				assert.True(t, lastLoginAt.Equal(res.GetUser().GetLastLoginAt().AsTime()))
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/apierror"
+	"github.com/PakornBank/learn-go/internal/authctx"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// DeletionModeErase is the only deletion mode currently supported: the account
+// and its related data are erased once the grace period has passed.
+const DeletionModeErase = "erase"
+
+// AccountDeletionService defines the methods that an account handler must implement.
+type AccountDeletionService interface {
+	// RequestErasure marks a user's account for erasure and returns when it will be purged.
+	// ctx: The context for the request.
+	// userID: The ID of the user deleting their account.
+	RequestErasure(ctx context.Context, userID string) (time.Time, error)
+
+	// Restore cancels the scheduled deletion of a user's account.
+	// ctx: The context for the request.
+	// userID: The ID of the user whose account to restore.
+	Restore(ctx context.Context, userID string) error
+}
+
+// AccountHandler handles HTTP requests for deleting accounts and restoring deleted ones.
+type AccountHandler struct {
+	service AccountDeletionService
+}
+
+// NewAccountHandler creates a new instance of AccountHandler with the provided service.
+func NewAccountHandler(s AccountDeletionService) *AccountHandler {
+	return &AccountHandler{service: s}
+}
+
+// DeleteProfile handles the authenticated user's request to delete their account.
+// The mode query parameter must be "erase". All of the user's sessions are
+// revoked immediately and the response, with a 202 status code, tells when the
+// account will be purged; logging in again before then cancels the deletion.
+// An unsupported mode results in a 400 status code and a database timeout in a 504.
+func (h *AccountHandler) DeleteProfile(c *gin.Context) {
+	identity, ok := authctx.User(c)
+	if !ok {
+		apierror.Respond(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	if mode := c.Query("mode"); mode != DeletionModeErase {
+		apierror.Respond(c, http.StatusBadRequest, "mode must be erase")
+		return
+	}
+
+	purgeAt, err := h.service.RequestErasure(c.Request.Context(), identity.UserID.String())
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidUserID):
+			apierror.Respond(c, http.StatusBadRequest, err.Error())
+		case errors.Is(err, repository.ErrTimeout):
+			apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+		default:
+			c.Error(err)
+			apierror.Respond(c, http.StatusInternalServerError, "failed to delete account")
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":  "account scheduled for deletion",
+		"purge_at": purgeAt,
+	})
+}
+
+// RestoreAccount handles an admin's request to restore the account with the
+// ID in the path, cancelling its scheduled deletion, and responds with a 204
+// status code. Its sessions, revoked when the deletion was requested, are not
+// restored. An invalid ID results in a 400 status code, an account that is
+// not scheduled for deletion or was already purged in a 404, and a database
+// timeout in a 504.
+func (h *AccountHandler) RestoreAccount(c *gin.Context) {
+	err := h.service.Restore(c.Request.Context(), c.Param("id"))
+	switch {
+	case err == nil:
+		c.Status(http.StatusNoContent)
+	case errors.Is(err, service.ErrInvalidUserID):
+		apierror.Respond(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, service.ErrNotDeleted):
+		apierror.Respond(c, http.StatusNotFound, service.ErrNotDeleted.Error())
+	case errors.Is(err, repository.ErrTimeout):
+		apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+	default:
+		c.Error(err)
+		apierror.Respond(c, http.StatusInternalServerError, "failed to restore account")
+	}
+}
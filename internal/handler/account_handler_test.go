@@ -0,0 +1,234 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockAccountDeletionService struct {
+	mock.Mock
+}
+
+func (ms *MockAccountDeletionService) RequestErasure(ctx context.Context, userID string) (time.Time, error) {
+	args := ms.Called(ctx, userID)
+	return args.Get(0).(time.Time), args.Error(1)
+}
+
+func (ms *MockAccountDeletionService) Restore(ctx context.Context, userID string) error {
+	args := ms.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func setupAccountTest(errs *[]error, middleware gin.HandlerFunc) (*gin.Engine, *MockAccountDeletionService) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockAccountDeletionService)
+	handler := NewAccountHandler(mockService)
+
+	router := gin.New()
+	router.DELETE("/api/auth/profile", collectErrors(errs), middleware, handler.DeleteProfile)
+	router.POST("/api/admin/users/:id/restore", collectErrors(errs), handler.RestoreAccount)
+
+	return router, mockService
+}
+
+func TestNewAccountHandler(t *testing.T) {
+	service := new(MockAccountDeletionService)
+	handler := NewAccountHandler(service)
+
+	assert.NotNil(t, handler)
+	assert.Equal(t, service, handler.service)
+}
+
+func TestAccountHandler_DeleteProfile(t *testing.T) {
+	const userID = "5b0c8f3e-2a1d-4c6b-9e7f-3d2a1b0c9e8f"
+	purgeAt := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	authenticated := authenticatedAs(userID)
+
+	tests := []struct {
+		name         string
+		middleware   gin.HandlerFunc
+		query        string
+		mockFn       func(*MockAccountDeletionService)
+		wantCode     int
+		wantAttached bool
+		errContains  string
+	}{
+		{
+			name:       "erasure scheduled",
+			middleware: authenticated,
+			query:      "?mode=erase",
+			mockFn: func(ms *MockAccountDeletionService) {
+				ms.On("RequestErasure", mock.Anything, userID).Return(purgeAt, nil)
+			},
+			wantCode: http.StatusAccepted,
+		},
+		{
+			name:        "not authenticated",
+			middleware:  func(c *gin.Context) {},
+			query:       "?mode=erase",
+			wantCode:    http.StatusUnauthorized,
+			errContains: "unauthorized",
+		},
+		{
+			name:        "missing mode",
+			middleware:  authenticated,
+			wantCode:    http.StatusBadRequest,
+			errContains: "mode must be erase",
+		},
+		{
+			name:        "unsupported mode",
+			middleware:  authenticated,
+			query:       "?mode=soft",
+			wantCode:    http.StatusBadRequest,
+			errContains: "mode must be erase",
+		},
+		{
+			name:       "invalid user id",
+			middleware: authenticated,
+			query:      "?mode=erase",
+			mockFn: func(ms *MockAccountDeletionService) {
+				ms.On("RequestErasure", mock.Anything, userID).Return(time.Time{}, service.ErrInvalidUserID)
+			},
+			wantCode:    http.StatusBadRequest,
+			errContains: service.ErrInvalidUserID.Error(),
+		},
+		{
+			name:       "database timeout",
+			middleware: authenticated,
+			query:      "?mode=erase",
+			mockFn: func(ms *MockAccountDeletionService) {
+				ms.On("RequestErasure", mock.Anything, userID).Return(time.Time{}, repository.ErrTimeout)
+			},
+			wantCode:    http.StatusGatewayTimeout,
+			errContains: repository.ErrTimeout.Error(),
+		},
+		{
+			name:       "database error",
+			middleware: authenticated,
+			query:      "?mode=erase",
+			mockFn: func(ms *MockAccountDeletionService) {
+				ms.On("RequestErasure", mock.Anything, userID).Return(time.Time{}, errors.New("connection reset"))
+			},
+			wantCode:     http.StatusInternalServerError,
+			wantAttached: true,
+			errContains:  "failed to delete account",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attached []error
+			router, mockService := setupAccountTest(&attached, tt.middleware)
+			if tt.mockFn != nil {
+				tt.mockFn(mockService)
+			}
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodDelete, "/api/auth/profile"+tt.query, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Equal(t, tt.wantAttached, len(attached) > 0)
+
+			var response map[string]interface{}
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			assert.NoError(t, err)
+
+			if tt.errContains != "" {
+				assert.Contains(t, response["error"], tt.errContains)
+			} else {
+				assert.Equal(t, "account scheduled for deletion", response["message"])
+				assert.Equal(t, purgeAt.Format(time.RFC3339), response["purge_at"])
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAccountHandler_RestoreAccount(t *testing.T) {
+	const userID = "8d7f5a9e-0b8e-4a57-9b1e-2f4b6c1d3e5a"
+
+	tests := []struct {
+		name         string
+		mockFn       func(*MockAccountDeletionService)
+		wantCode     int
+		wantAttached bool
+		wantBody     string
+	}{
+		{
+			name: "account restored",
+			mockFn: func(ms *MockAccountDeletionService) {
+				ms.On("Restore", mock.Anything, userID).Return(nil)
+			},
+			wantCode: http.StatusNoContent,
+		},
+		{
+			name: "invalid user id",
+			mockFn: func(ms *MockAccountDeletionService) {
+				ms.On("Restore", mock.Anything, userID).Return(service.ErrInvalidUserID)
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"error":"` + service.ErrInvalidUserID.Error() + `"}`,
+		},
+		{
+			name: "not deleted or already purged",
+			mockFn: func(ms *MockAccountDeletionService) {
+				ms.On("Restore", mock.Anything, userID).
+					Return(fmt.Errorf("%w: %w", service.ErrNotDeleted, repository.ErrNotFound))
+			},
+			wantCode: http.StatusNotFound,
+			wantBody: `{"error":"` + service.ErrNotDeleted.Error() + `"}`,
+		},
+		{
+			name: "database timeout",
+			mockFn: func(ms *MockAccountDeletionService) {
+				ms.On("Restore", mock.Anything, userID).Return(repository.ErrTimeout)
+			},
+			wantCode: http.StatusGatewayTimeout,
+			wantBody: `{"error":"` + repository.ErrTimeout.Error() + `"}`,
+		},
+		{
+			name: "database error",
+			mockFn: func(ms *MockAccountDeletionService) {
+				ms.On("Restore", mock.Anything, userID).Return(errors.New("connection reset"))
+			},
+			wantCode:     http.StatusInternalServerError,
+			wantAttached: true,
+			wantBody:     `{"error":"failed to restore account"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attached []error
+			router, mockService := setupAccountTest(&attached, func(c *gin.Context) {})
+			tt.mockFn(mockService)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/api/admin/users/"+userID+"/restore", nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Equal(t, tt.wantAttached, len(attached) > 0)
+			if tt.wantBody == "" {
+				assert.Empty(t, w.Body.String())
+			} else {
+				assert.JSONEq(t, tt.wantBody, w.Body.String())
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/apierror"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// AdminService defines the methods that an admin handler must implement.
+type AdminService interface {
+	// ListUsers returns the users on a page and the total number of users.
+	// ctx: The context for the request.
+	// page: The page to return, sorted by one of the fields of userPagination.
+	ListUsers(ctx context.Context, page service.PageRequest) ([]model.User, int64, error)
+
+	// GetUser returns a user along with the activity on their account.
+	// ctx: The context for the request.
+	// id: The ID of the user.
+	GetUser(ctx context.Context, id string) (*service.UserDetail, error)
+}
+
+// userPagination is the paging of the admin user list, newest first by default.
+var userPagination = Pagination{
+	SortFields:  []string{"created_at", "email", "username", "last_login_at"},
+	DefaultSort: "-created_at",
+}
+
+// UserDetailResponse is a user as shown to admins looking into their
+// account: the public representation of the user followed by the activity
+// on it. The API has no two-factor authentication or email verification
+// yet, so TwoFactorEnabled and EmailVerified are always false; they are
+// included so that clients need not change once it does.
+type UserDetailResponse struct {
+	UserResponse
+	SessionCount     int64                   `json:"session_count"`
+	FailedAttempts   int64                   `json:"failed_attempts"`
+	TwoFactorEnabled bool                    `json:"two_factor_enabled"`
+	EmailVerified    bool                    `json:"email_verified"`
+	RecentEvents     []ActivityEventResponse `json:"recent_events"`
+}
+
+// ActivityEventResponse is a recent login attempt or audited request in a
+// UserDetailResponse. Kind is "login" or "audit", and only the fields of
+// that kind are present.
+type ActivityEventResponse struct {
+	Kind      string    `json:"kind"`
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Success   *bool     `json:"success,omitempty"`
+	IPAddress *string   `json:"ip_address,omitempty"`
+	UserAgent *string   `json:"user_agent,omitempty"`
+	Method    *string   `json:"method,omitempty"`
+	Path      *string   `json:"path,omitempty"`
+	Status    *int      `json:"status,omitempty"`
+}
+
+// fromDetail maps a user and their activity to the representation admins see.
+func fromDetail(d *service.UserDetail) UserDetailResponse {
+	events := make([]ActivityEventResponse, len(d.Activity.RecentEvents))
+	for i, e := range d.Activity.RecentEvents {
+		events[i] = ActivityEventResponse(e)
+	}
+	return UserDetailResponse{
+		UserResponse:   FromModel(d.User),
+		SessionCount:   d.Activity.SessionCount,
+		FailedAttempts: d.Activity.FailedAttempts,
+		RecentEvents:   events,
+	}
+}
+
+// AdminHandler handles HTTP requests for administrative endpoints.
+type AdminHandler struct {
+	service AdminService
+}
+
+// NewAdminHandler creates a new instance of AdminHandler with the provided service.
+func NewAdminHandler(s AdminService) *AdminHandler {
+	return &AdminHandler{service: s}
+}
+
+// ListUsers handles the request to list users a page at a time. It accepts
+// the optional "page", "per_page" and "sort" query parameters of Pagination,
+// where sort is one of created_at, email, username or last_login_at, and
+// responds with the users in a Page envelope. Invalid pagination results in a
+// 422 status code, and a database timeout in a 504.
+func (h *AdminHandler) ListUsers(c *gin.Context) {
+	page, ok := userPagination.Bind(c)
+	if !ok {
+		return
+	}
+
+	users, total, err := h.service.ListUsers(c.Request.Context(), page)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrTimeout):
+			apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+		default:
+			c.Error(err)
+			apierror.Respond(c, http.StatusInternalServerError, "failed to list users")
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, NewPage(users, total, page))
+}
+
+// GetUser handles the request for the detail of the user identified by the
+// "id" path parameter: their profile, number of active sessions, failed
+// logins since the last successful one, and five most recent login attempts
+// and audited requests. A malformed ID results in a 400 status code, an
+// unknown user in a 404, and a database timeout in a 504.
+func (h *AdminHandler) GetUser(c *gin.Context) {
+	detail, err := h.service.GetUser(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidUserID):
+			apierror.Respond(c, http.StatusBadRequest, err.Error())
+		case errors.Is(err, service.ErrUserNotFound):
+			apierror.Respond(c, http.StatusNotFound, service.ErrUserNotFound.Error())
+		case errors.Is(err, repository.ErrTimeout):
+			apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+		default:
+			c.Error(err)
+			apierror.Respond(c, http.StatusInternalServerError, "failed to get user")
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, fromDetail(detail))
+}
@@ -0,0 +1,243 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockAdminService struct {
+	mock.Mock
+}
+
+func (ms *MockAdminService) ListUsers(ctx context.Context, page service.PageRequest) ([]model.User, int64, error) {
+	args := ms.Called(ctx, page)
+	if args.Get(0) == nil {
+		return nil, args.Get(1).(int64), args.Error(2)
+	}
+	return args.Get(0).([]model.User), args.Get(1).(int64), args.Error(2)
+}
+
+func (ms *MockAdminService) GetUser(ctx context.Context, id string) (*service.UserDetail, error) {
+	args := ms.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.UserDetail), args.Error(1)
+}
+
+func setupAdminTest() (*gin.Engine, *MockAdminService) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockAdminService)
+	handler := NewAdminHandler(mockService)
+
+	router := gin.New()
+	group := router.Group("/api/admin")
+	{
+		group.GET("/users", handler.ListUsers)
+		group.GET("/users/:id", handler.GetUser)
+	}
+
+	return router, mockService
+}
+
+func TestNewAdminHandler(t *testing.T) {
+	service := new(MockAdminService)
+	handler := NewAdminHandler(service)
+
+	assert.NotNil(t, handler)
+	assert.Equal(t, service, handler.service)
+}
+
+func TestAdminHandler_ListUsers(t *testing.T) {
+	user := testutil.NewMockUser()
+	defaultPage := service.PageRequest{Page: 1, PerPage: service.DefaultListLimit, Sort: "created_at", Desc: true}
+
+	tests := []struct {
+		name        string
+		query       string
+		mockFn      func(*MockAdminService)
+		wantCode    int
+		wantBody    map[string]interface{}
+		wantFields  []string
+		errContains string
+	}{
+		{
+			name: "default page, newest first",
+			mockFn: func(ms *MockAdminService) {
+				ms.On("ListUsers", mock.Anything, defaultPage).Return([]model.User{user}, int64(41), nil)
+			},
+			wantCode: http.StatusOK,
+			wantBody: map[string]interface{}{"page": 1.0, "per_page": 20.0, "total": 41.0, "total_pages": 3.0},
+		},
+		{
+			name:  "custom page and sort",
+			query: "?page=2&per_page=5&sort=email",
+			mockFn: func(ms *MockAdminService) {
+				ms.On("ListUsers", mock.Anything, service.PageRequest{Page: 2, PerPage: 5, Sort: "email"}).
+					Return(nil, int64(3), nil)
+			},
+			wantCode: http.StatusOK,
+			wantBody: map[string]interface{}{"page": 2.0, "per_page": 5.0, "total": 3.0, "total_pages": 1.0},
+		},
+		{
+			name:       "invalid page and sort",
+			query:      "?page=0&sort=password_hash",
+			wantCode:   http.StatusUnprocessableEntity,
+			wantFields: []string{"page", "sort"},
+		},
+		{
+			name:  "database timeout",
+			query: "?page=1",
+			mockFn: func(ms *MockAdminService) {
+				ms.On("ListUsers", mock.Anything, defaultPage).Return(nil, int64(0), repository.ErrTimeout)
+			},
+			wantCode:    http.StatusGatewayTimeout,
+			errContains: repository.ErrTimeout.Error(),
+		},
+		{
+			name: "admin_service error",
+			mockFn: func(ms *MockAdminService) {
+				ms.On("ListUsers", mock.Anything, defaultPage).Return(nil, int64(0), errors.New("admin_service error"))
+			},
+			wantCode:    http.StatusInternalServerError,
+			errContains: "failed to list users",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mockService := setupAdminTest()
+			if tt.mockFn != nil {
+				tt.mockFn(mockService)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/admin/users"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+
+			var res map[string]interface{}
+			err := json.Unmarshal(w.Body.Bytes(), &res)
+			assert.NoError(t, err)
+
+			switch {
+			case tt.wantCode == http.StatusOK:
+				assert.IsType(t, []interface{}{}, res["data"], "data is always a list")
+				for key, want := range tt.wantBody {
+					assert.Equal(t, want, res[key], key)
+				}
+			case tt.wantFields != nil:
+				assert.Equal(t, "INVALID_PAGINATION", res["code"])
+				var fields []string
+				for _, field := range res["errors"].([]interface{}) {
+					fields = append(fields, field.(map[string]interface{})["field"].(string))
+				}
+				assert.Equal(t, tt.wantFields, fields)
+			default:
+				assert.Contains(t, res["error"], tt.errContains)
+			}
+
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAdminHandler_GetUser(t *testing.T) {
+	user := testutil.NewMockUser()
+	success, ip, path, status := false, "203.0.113.7", "/api/auth/logout", http.StatusOK
+	method := http.MethodPost
+	detail := &service.UserDetail{
+		User: &user,
+		Activity: &repository.UserActivity{
+			SessionCount:   2,
+			FailedAttempts: 3,
+			RecentEvents: []repository.ActivityEvent{
+				{Kind: repository.ActivityAudit, ID: uuid.New(), CreatedAt: time.Now(), Method: &method, Path: &path, Status: &status},
+				{Kind: repository.ActivityLogin, ID: uuid.New(), CreatedAt: time.Now(), Success: &success, IPAddress: &ip},
+			},
+		},
+	}
+
+	t.Run("user with activity", func(t *testing.T) {
+		router, mockService := setupAdminTest()
+		mockService.On("GetUser", mock.Anything, user.ID.String()).Return(detail, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/admin/users/"+user.ID.String(), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var res map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		assert.Equal(t, user.Email, res["email"])
+		assert.Equal(t, 2.0, res["session_count"])
+		assert.Equal(t, 3.0, res["failed_attempts"])
+		assert.Equal(t, false, res["two_factor_enabled"])
+		assert.Equal(t, false, res["email_verified"])
+		assert.Contains(t, res, "last_login_at")
+		assert.NotContains(t, res, "password_hash")
+		events := res["recent_events"].([]interface{})
+		require.Len(t, events, 2)
+		assert.Equal(t, map[string]interface{}{"kind": "audit", "method": "POST", "path": path, "status": 200.0},
+			withoutKeys(events[0].(map[string]interface{}), "id", "created_at"))
+		assert.Equal(t, map[string]interface{}{"kind": "login", "success": false, "ip_address": ip},
+			withoutKeys(events[1].(map[string]interface{}), "id", "created_at"))
+	})
+
+	tests := []struct {
+		name     string
+		id       string
+		err      error
+		wantCode int
+		wantErr  string
+	}{
+		{name: "malformed ID", id: "not-a-uuid", err: service.ErrInvalidUserID, wantCode: http.StatusBadRequest, wantErr: service.ErrInvalidUserID.Error()},
+		{name: "unknown user", id: uuid.NewString(), err: service.ErrUserNotFound, wantCode: http.StatusNotFound, wantErr: service.ErrUserNotFound.Error()},
+		{name: "database timeout", id: uuid.NewString(), err: repository.ErrTimeout, wantCode: http.StatusGatewayTimeout, wantErr: repository.ErrTimeout.Error()},
+		{name: "unexpected error", id: uuid.NewString(), err: errors.New("boom"), wantCode: http.StatusInternalServerError, wantErr: "failed to get user"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mockService := setupAdminTest()
+			mockService.On("GetUser", mock.Anything, tt.id).Return(nil, tt.err)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/admin/users/"+tt.id, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			var res map[string]interface{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+			assert.Equal(t, tt.wantErr, res["error"])
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+// withoutKeys returns m without the given keys, for comparing the parts of a
+// response that do not change between runs.
+func withoutKeys(m map[string]interface{}, keys ...string) map[string]interface{} {
+	for _, key := range keys {
+		delete(m, key)
+	}
+	return m
+}
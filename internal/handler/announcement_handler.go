@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/apierror"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// AnnouncementService defines the methods that an announcement handler must implement.
+type AnnouncementService interface {
+	// Active returns the announcements within their window, the most severe first.
+	// ctx: The context for the request.
+	Active(ctx context.Context) ([]model.Announcement, error)
+
+	// List returns a page of every announcement and the total number of them.
+	// ctx: The context for the request.
+	// page: The page to return, sorted by start time.
+	List(ctx context.Context, page service.PageRequest) ([]model.Announcement, int64, error)
+
+	// Create schedules an announcement.
+	// ctx: The context for the request.
+	// input: The message, severity and window of the announcement.
+	Create(ctx context.Context, input service.AnnouncementInput) (*model.Announcement, error)
+
+	// Update replaces the message, severity and window of an announcement.
+	// ctx: The context for the request.
+	// id: The ID of the announcement.
+	// input: The new message, severity and window.
+	Update(ctx context.Context, id string, input service.AnnouncementInput) (*model.Announcement, error)
+
+	// Delete removes an announcement.
+	// ctx: The context for the request.
+	// id: The ID of the announcement.
+	Delete(ctx context.Context, id string) error
+}
+
+// AnnouncementResponse describes an active announcement to users.
+type AnnouncementResponse struct {
+	ID       string    `json:"id"`
+	Message  string    `json:"message"`
+	Severity string    `json:"severity"`
+	StartsAt time.Time `json:"starts_at"`
+	EndsAt   time.Time `json:"ends_at"`
+}
+
+// announcementPagination is the paging of the announcement list, latest start first by default.
+var announcementPagination = Pagination{
+	SortFields:  []string{"starts_at"},
+	DefaultSort: "-starts_at",
+}
+
+// AnnouncementHandler handles HTTP requests for the announcements shown in
+// the app, such as maintenance notices.
+type AnnouncementHandler struct {
+	service AnnouncementService
+}
+
+// NewAnnouncementHandler creates a new instance of AnnouncementHandler with the provided service.
+func NewAnnouncementHandler(s AnnouncementService) *AnnouncementHandler {
+	return &AnnouncementHandler{service: s}
+}
+
+// ListActive handles the public request for the announcements to show now,
+// the most severe first. The response may be cached for a minute, so a
+// change reaches every client within that time.
+func (h *AnnouncementHandler) ListActive(c *gin.Context) {
+	announcements, err := h.service.Active(c.Request.Context())
+	if err != nil {
+		h.respondError(c, err, "failed to list announcements")
+		return
+	}
+
+	data := make([]AnnouncementResponse, len(announcements))
+	for i, announcement := range announcements {
+		data[i] = AnnouncementResponse{
+			ID:       announcement.ID.String(),
+			Message:  announcement.Message,
+			Severity: announcement.Severity,
+			StartsAt: announcement.StartsAt,
+			EndsAt:   announcement.EndsAt,
+		}
+	}
+
+	c.Header("Cache-Control", "max-age=60")
+	c.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+// ListAnnouncements handles an admin's request to list every announcement,
+// past, active and scheduled, a page at a time. It accepts the "page",
+// "per_page" and "sort" query parameters of Pagination, where sort is
+// starts_at, and responds with the announcements in a Page envelope. Invalid
+// pagination results in a 422 status code, and a database timeout in a 504.
+func (h *AnnouncementHandler) ListAnnouncements(c *gin.Context) {
+	page, ok := announcementPagination.Bind(c)
+	if !ok {
+		return
+	}
+
+	announcements, total, err := h.service.List(c.Request.Context(), page)
+	if err != nil {
+		h.respondError(c, err, "failed to list announcements")
+		return
+	}
+
+	c.JSON(http.StatusOK, NewPage(announcements, total, page))
+}
+
+// CreateAnnouncement handles an admin's request to schedule an announcement.
+// It expects a JSON payload with the message, a severity of info, warning or
+// critical, and the starts_at and ends_at of its window, and responds with a
+// 201 status code and the announcement. A window that ends before it starts
+// results in a 400 status code.
+func (h *AnnouncementHandler) CreateAnnouncement(c *gin.Context) {
+	var input service.AnnouncementInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.RespondInvalid(c, err)
+		return
+	}
+
+	announcement, err := h.service.Create(c.Request.Context(), input)
+	if err != nil {
+		h.respondError(c, err, "failed to create announcement")
+		return
+	}
+
+	c.JSON(http.StatusCreated, announcement)
+}
+
+// UpdateAnnouncement handles an admin's request to replace the announcement
+// identified by the "id" path parameter. It expects the same payload as
+// CreateAnnouncement and responds with the updated announcement. An unknown
+// announcement results in a 404 status code.
+func (h *AnnouncementHandler) UpdateAnnouncement(c *gin.Context) {
+	var input service.AnnouncementInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.RespondInvalid(c, err)
+		return
+	}
+
+	announcement, err := h.service.Update(c.Request.Context(), c.Param("id"), input)
+	if err != nil {
+		h.respondError(c, err, "failed to update announcement")
+		return
+	}
+
+	c.JSON(http.StatusOK, announcement)
+}
+
+// DeleteAnnouncement handles an admin's request to remove the announcement
+// identified by the "id" path parameter, responding with a 204 status code.
+// An unknown announcement results in a 404 status code.
+func (h *AnnouncementHandler) DeleteAnnouncement(c *gin.Context) {
+	if err := h.service.Delete(c.Request.Context(), c.Param("id")); err != nil {
+		h.respondError(c, err, "failed to delete announcement")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// respondError writes the response for an error returned by the service,
+// using fallback as the message of unexpected errors.
+func (h *AnnouncementHandler) respondError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, service.ErrInvalidAnnouncementID),
+		errors.Is(err, service.ErrInvalidAnnouncementWindow):
+		apierror.Respond(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, service.ErrAnnouncementNotFound):
+		apierror.Respond(c, http.StatusNotFound, service.ErrAnnouncementNotFound.Error())
+	case errors.Is(err, repository.ErrTimeout):
+		apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+	default:
+		c.Error(err)
+		apierror.Respond(c, http.StatusInternalServerError, fallback)
+	}
+}
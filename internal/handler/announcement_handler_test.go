@@ -0,0 +1,287 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockAnnouncementService struct {
+	mock.Mock
+}
+
+func (ms *MockAnnouncementService) Active(ctx context.Context) ([]model.Announcement, error) {
+	args := ms.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.Announcement), args.Error(1)
+}
+
+func (ms *MockAnnouncementService) List(ctx context.Context, page service.PageRequest) ([]model.Announcement, int64, error) {
+	args := ms.Called(ctx, page)
+	if args.Get(0) == nil {
+		return nil, 0, args.Error(2)
+	}
+	return args.Get(0).([]model.Announcement), args.Get(1).(int64), args.Error(2)
+}
+
+func (ms *MockAnnouncementService) Create(ctx context.Context, input service.AnnouncementInput) (*model.Announcement, error) {
+	args := ms.Called(ctx, input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Announcement), args.Error(1)
+}
+
+func (ms *MockAnnouncementService) Update(ctx context.Context, id string, input service.AnnouncementInput) (*model.Announcement, error) {
+	args := ms.Called(ctx, id, input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Announcement), args.Error(1)
+}
+
+func (ms *MockAnnouncementService) Delete(ctx context.Context, id string) error {
+	args := ms.Called(ctx, id)
+	return args.Error(0)
+}
+
+func setupAnnouncementTest(t *testing.T) (*testutil.APITester, *MockAnnouncementService) {
+	mockService := new(MockAnnouncementService)
+	handler := NewAnnouncementHandler(mockService)
+
+	api := testutil.NewAPITester(t)
+	api.GET("/api/announcements", handler.ListActive)
+	admin := api.Group("/api/admin/announcements")
+	{
+		admin.GET("", handler.ListAnnouncements)
+		admin.POST("", handler.CreateAnnouncement)
+		admin.PUT("/:id", handler.UpdateAnnouncement)
+		admin.DELETE("/:id", handler.DeleteAnnouncement)
+	}
+	return api, mockService
+}
+
+func TestAnnouncementHandler_ListActive(t *testing.T) {
+	startsAt := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	announcement := model.Announcement{
+		ID:        uuid.MustParse("0d4f6a8c-1e3b-4d5f-9a7c-2b4d6f8a0c1e"),
+		Message:   "Maintenance tonight",
+		Severity:  model.SeverityWarning,
+		StartsAt:  startsAt,
+		EndsAt:    startsAt.Add(2 * time.Hour),
+		CreatedAt: startsAt.Add(-time.Hour),
+	}
+
+	t.Run("active announcements", func(t *testing.T) {
+		api, mockService := setupAnnouncementTest(t)
+		mockService.On("Active", mock.Anything).Return([]model.Announcement{announcement}, nil)
+
+		res := api.Do(http.MethodGet, "/api/announcements", nil).
+			AssertStatus(http.StatusOK).
+			AssertJSON(`{"data":[{
+				"id":"0d4f6a8c-1e3b-4d5f-9a7c-2b4d6f8a0c1e",
+				"message":"Maintenance tonight",
+				"severity":"warning",
+				"starts_at":"2026-10-17T12:00:00Z",
+				"ends_at":"2026-10-17T14:00:00Z"
+			}]}`)
+
+		assert.Equal(t, "max-age=60", res.Header().Get("Cache-Control"))
+	})
+
+	t.Run("no active announcements", func(t *testing.T) {
+		api, mockService := setupAnnouncementTest(t)
+		mockService.On("Active", mock.Anything).Return(nil, nil)
+
+		api.Do(http.MethodGet, "/api/announcements", nil).
+			AssertStatus(http.StatusOK).
+			AssertJSON(`{"data":[]}`)
+	})
+
+	t.Run("failures are not cached", func(t *testing.T) {
+		api, mockService := setupAnnouncementTest(t)
+		mockService.On("Active", mock.Anything).Return(nil, repository.ErrTimeout)
+
+		res := api.Do(http.MethodGet, "/api/announcements", nil).
+			AssertStatus(http.StatusGatewayTimeout)
+
+		assert.Empty(t, res.Header().Get("Cache-Control"))
+	})
+}
+
+func TestAnnouncementHandler_ListAnnouncements(t *testing.T) {
+	t.Run("latest start first by default", func(t *testing.T) {
+		api, mockService := setupAnnouncementTest(t)
+		page := service.PageRequest{Page: 1, PerPage: service.DefaultListLimit, Sort: "starts_at", Desc: true}
+		mockService.On("List", mock.Anything, page).Return([]model.Announcement{{ID: uuid.New()}}, int64(1), nil)
+
+		body := api.Do(http.MethodGet, "/api/admin/announcements", nil).
+			AssertStatus(http.StatusOK).
+			JSON()
+
+		assert.Len(t, body["data"], 1)
+		assert.Equal(t, float64(1), body["total"])
+	})
+
+	t.Run("invalid sort", func(t *testing.T) {
+		api, _ := setupAnnouncementTest(t)
+
+		api.Do(http.MethodGet, "/api/admin/announcements?sort=message", nil).
+			AssertStatus(http.StatusUnprocessableEntity)
+	})
+}
+
+func TestAnnouncementHandler_CreateAnnouncement(t *testing.T) {
+	input := service.AnnouncementInput{
+		Message:  "Maintenance tonight",
+		Severity: model.SeverityCritical,
+		StartsAt: time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC),
+		EndsAt:   time.Date(2026, 10, 17, 14, 0, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name         string
+		body         any
+		mockFn       func(*MockAnnouncementService)
+		wantCode     int
+		wantAttached bool
+		errContains  string
+	}{
+		{
+			name: "created",
+			body: input,
+			mockFn: func(ms *MockAnnouncementService) {
+				ms.On("Create", mock.Anything, input).Return(&model.Announcement{ID: uuid.New(), Message: input.Message}, nil)
+			},
+			wantCode: http.StatusCreated,
+		},
+		{
+			name: "ends before it starts",
+			body: input,
+			mockFn: func(ms *MockAnnouncementService) {
+				ms.On("Create", mock.Anything, input).Return(nil, service.ErrInvalidAnnouncementWindow)
+			},
+			wantCode:    http.StatusBadRequest,
+			errContains: service.ErrInvalidAnnouncementWindow.Error(),
+		},
+		{
+			name:     "unknown severity",
+			body:     map[string]string{"message": "m", "severity": "urgent", "starts_at": "2026-10-17T12:00:00Z", "ends_at": "2026-10-17T14:00:00Z"},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "missing window",
+			body:     map[string]string{"message": "m", "severity": "info"},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name: "service error",
+			body: input,
+			mockFn: func(ms *MockAnnouncementService) {
+				ms.On("Create", mock.Anything, input).Return(nil, errors.New("connection reset"))
+			},
+			wantCode:     http.StatusInternalServerError,
+			wantAttached: true,
+			errContains:  "failed to create announcement",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attached []error
+			mockService := new(MockAnnouncementService)
+			if tt.mockFn != nil {
+				tt.mockFn(mockService)
+			}
+			api := testutil.NewAPITester(t, collectErrors(&attached))
+			api.POST("/api/admin/announcements", NewAnnouncementHandler(mockService).CreateAnnouncement)
+
+			res := api.Do(http.MethodPost, "/api/admin/announcements", tt.body).
+				AssertStatus(tt.wantCode)
+
+			assert.Equal(t, tt.wantAttached, len(attached) > 0)
+			if tt.errContains != "" {
+				assert.Contains(t, res.JSON()["error"], tt.errContains)
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAnnouncementHandler_UpdateAnnouncement(t *testing.T) {
+	id := uuid.NewString()
+	input := service.AnnouncementInput{
+		Message:  "Maintenance moved",
+		Severity: model.SeverityInfo,
+		StartsAt: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC),
+		EndsAt:   time.Date(2026, 10, 18, 14, 0, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name     string
+		err      error
+		wantCode int
+	}{
+		{name: "updated", wantCode: http.StatusOK},
+		{name: "not found", err: service.ErrAnnouncementNotFound, wantCode: http.StatusNotFound},
+		{name: "invalid id", err: service.ErrInvalidAnnouncementID, wantCode: http.StatusBadRequest},
+		{name: "database timeout", err: repository.ErrTimeout, wantCode: http.StatusGatewayTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api, mockService := setupAnnouncementTest(t)
+			if tt.err != nil {
+				mockService.On("Update", mock.Anything, id, input).Return(nil, tt.err)
+			} else {
+				mockService.On("Update", mock.Anything, id, input).Return(&model.Announcement{Message: input.Message}, nil)
+			}
+
+			res := api.Do(http.MethodPut, "/api/admin/announcements/"+id, input).
+				AssertStatus(tt.wantCode)
+
+			if tt.err == nil {
+				assert.Equal(t, input.Message, res.JSON()["message"])
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAnnouncementHandler_DeleteAnnouncement(t *testing.T) {
+	id := uuid.NewString()
+
+	tests := []struct {
+		name     string
+		err      error
+		wantCode int
+	}{
+		{name: "deleted", wantCode: http.StatusNoContent},
+		{name: "not found", err: service.ErrAnnouncementNotFound, wantCode: http.StatusNotFound},
+		{name: "invalid id", err: service.ErrInvalidAnnouncementID, wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api, mockService := setupAnnouncementTest(t)
+			mockService.On("Delete", mock.Anything, id).Return(tt.err)
+
+			api.Do(http.MethodDelete, "/api/admin/announcements/"+id, nil).
+				AssertStatus(tt.wantCode)
+
+			mockService.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/apierror"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// AuditLogService defines the methods that an audit log handler must implement.
+type AuditLogService interface {
+	// List returns a page of the audit entries selected by query, newest
+	// first, along with the cursor for the next page.
+	// ctx: The context for the request.
+	// query: The actor and time range of the entries to list.
+	// cursor: The opaque cursor returned by a previous call, or empty to start.
+	// limit: The maximum number of entries to return.
+	List(ctx context.Context, query service.AuditQuery, cursor string, limit int) ([]model.AuditEntry, string, error)
+}
+
+// AuditLogHandler handles HTTP requests for the audit log.
+type AuditLogHandler struct {
+	service AuditLogService
+}
+
+// NewAuditLogHandler creates a new instance of AuditLogHandler with the provided service.
+func NewAuditLogHandler(s AuditLogService) *AuditLogHandler {
+	return &AuditLogHandler{service: s}
+}
+
+// ListAudit handles the request to list audit entries with cursor-based
+// pagination. It accepts the optional "actor" query parameter, the ID of the
+// user whose requests to list, and "from" and "to", RFC 3339 timestamps
+// bounding when the requests were made, along with "cursor" and "limit". It
+// responds in the same envelope as other paginated endpoints. An invalid
+// parameter results in a 400 status code, and a database timeout in a 504.
+func (h *AuditLogHandler) ListAudit(c *gin.Context) {
+	limit, ok := parseLimit(c)
+	if !ok {
+		return
+	}
+
+	query := service.AuditQuery{ActorID: c.Query("actor")}
+	for _, bound := range []struct {
+		param string
+		value *time.Time
+	}{
+		{"from", &query.From},
+		{"to", &query.To},
+	} {
+		raw := c.Query(bound.param)
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			apierror.Respond(c, http.StatusBadRequest, bound.param+" must be an RFC 3339 timestamp")
+			return
+		}
+		*bound.value = t
+	}
+
+	entries, nextCursor, err := h.service.List(c.Request.Context(), query, c.Query("cursor"), limit)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidLimit),
+			errors.Is(err, service.ErrInvalidUserID),
+			errors.Is(err, service.ErrInvalidTimeRange),
+			errors.Is(err, repository.ErrInvalidCursor):
+			apierror.Respond(c, http.StatusBadRequest, err.Error())
+		case errors.Is(err, repository.ErrTimeout):
+			apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+		default:
+			c.Error(err)
+			apierror.Respond(c, http.StatusInternalServerError, "failed to list audit log")
+		}
+		return
+	}
+
+	if entries == nil {
+		entries = []model.AuditEntry{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": entries, "next_cursor": nextCursor})
+}
@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockAuditLogService struct {
+	mock.Mock
+}
+
+func (ms *MockAuditLogService) List(ctx context.Context, query service.AuditQuery, cursor string, limit int) ([]model.AuditEntry, string, error) {
+	args := ms.Called(ctx, query, cursor, limit)
+	if args.Get(0) == nil {
+		return nil, args.String(1), args.Error(2)
+	}
+	return args.Get(0).([]model.AuditEntry), args.String(1), args.Error(2)
+}
+
+func TestNewAuditLogHandler(t *testing.T) {
+	service := new(MockAuditLogService)
+	handler := NewAuditLogHandler(service)
+
+	assert.NotNil(t, handler)
+	assert.Equal(t, service, handler.service)
+}
+
+func TestAuditLogHandler_ListAudit(t *testing.T) {
+	actorID := uuid.New()
+	entry := model.AuditEntry{ID: uuid.New(), ActorID: actorID, Method: "GET", Path: "/api/admin/users", Status: 200}
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		query          string
+		mockFn         func(*MockAuditLogService)
+		wantCode       int
+		wantLen        int
+		wantNextCursor string
+		errContains    string
+	}{
+		{
+			name:  "filtered listing",
+			query: "?actor=" + actorID.String() + "&from=2024-01-01T00:00:00Z&to=2024-01-02T00:00:00Z&cursor=abc&limit=5",
+			mockFn: func(ms *MockAuditLogService) {
+				query := service.AuditQuery{ActorID: actorID.String(), From: from, To: to}
+				ms.On("List", mock.Anything, query, "abc", 5).Return([]model.AuditEntry{entry}, "next", nil)
+			},
+			wantCode:       http.StatusOK,
+			wantLen:        1,
+			wantNextCursor: "next",
+		},
+		{
+			name: "empty page",
+			mockFn: func(ms *MockAuditLogService) {
+				ms.On("List", mock.Anything, service.AuditQuery{}, "", service.DefaultListLimit).Return(nil, "", nil)
+			},
+			wantCode: http.StatusOK,
+			wantLen:  0,
+		},
+		{
+			name:        "malformed from",
+			query:       "?from=yesterday",
+			wantCode:    http.StatusBadRequest,
+			errContains: "from must be an RFC 3339 timestamp",
+		},
+		{
+			name:        "malformed to",
+			query:       "?to=2024-01-02",
+			wantCode:    http.StatusBadRequest,
+			errContains: "to must be an RFC 3339 timestamp",
+		},
+		{
+			name:  "invalid actor",
+			query: "?actor=admin",
+			mockFn: func(ms *MockAuditLogService) {
+				ms.On("List", mock.Anything, service.AuditQuery{ActorID: "admin"}, "", service.DefaultListLimit).
+					Return(nil, "", service.ErrInvalidUserID)
+			},
+			wantCode:    http.StatusBadRequest,
+			errContains: service.ErrInvalidUserID.Error(),
+		},
+		{
+			name: "database timeout",
+			mockFn: func(ms *MockAuditLogService) {
+				ms.On("List", mock.Anything, service.AuditQuery{}, "", service.DefaultListLimit).
+					Return(nil, "", repository.ErrTimeout)
+			},
+			wantCode:    http.StatusGatewayTimeout,
+			errContains: repository.ErrTimeout.Error(),
+		},
+		{
+			name: "service error",
+			mockFn: func(ms *MockAuditLogService) {
+				ms.On("List", mock.Anything, service.AuditQuery{}, "", service.DefaultListLimit).
+					Return(nil, "", errors.New("service error"))
+			},
+			wantCode:    http.StatusInternalServerError,
+			errContains: "failed to list audit log",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			mockService := new(MockAuditLogService)
+			if tt.mockFn != nil {
+				tt.mockFn(mockService)
+			}
+			router := gin.New()
+			router.GET("/api/admin/audit", NewAuditLogHandler(mockService).ListAudit)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/admin/audit"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+
+			var res map[string]interface{}
+			err := json.Unmarshal(w.Body.Bytes(), &res)
+			assert.NoError(t, err)
+
+			if tt.wantCode == http.StatusOK {
+				assert.Len(t, res["data"], tt.wantLen)
+				assert.Equal(t, tt.wantNextCursor, res["next_cursor"])
+			} else {
+				assert.Contains(t, res["error"], tt.errContains)
+			}
+
+			mockService.AssertExpectations(t)
+		})
+	}
+}
@@ -3,9 +3,16 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/PakornBank/learn-go/internal/apierror"
+	"github.com/PakornBank/learn-go/internal/authctx"
 	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
 	"github.com/PakornBank/learn-go/internal/service"
 	"github.com/gin-gonic/gin"
 )
@@ -18,10 +25,61 @@ type Service interface {
 	// input: The input data required for user registration.
 	Register(ctx context.Context, input service.RegisterInput) (*model.User, error)
 
-	// Login authenticates a user with the given input and returns a token or an error.
+	// Login authenticates a user with the given input and returns an access and refresh token pair or an error.
 	// ctx: The context for the request.
 	// input: The input data required for user login.
-	Login(ctx context.Context, input service.LoginInput) (string, error)
+	Login(ctx context.Context, input service.LoginInput) (*service.TokenPair, error)
+
+	// Refresh exchanges a refresh token for a new token pair or returns an error.
+	// ctx: The context for the request.
+	// input: The refresh token to exchange.
+	Refresh(ctx context.Context, input service.RefreshInput) (*service.TokenPair, error)
+
+	// Introspect reports whether an access token is active and who it belongs to.
+	// ctx: The context for the request.
+	// token: The access token to inspect.
+	Introspect(ctx context.Context, token string) (*service.Introspection, error)
+
+	// ChangePassword replaces a user's password after verifying their current one.
+	// ctx: The context for the request.
+	// userID: The ID of the user changing their password.
+	// input: The current and the new password.
+	ChangePassword(ctx context.Context, userID string, input service.ChangePasswordInput) error
+
+	// Logout ends the session of a refresh token.
+	// ctx: The context for the request.
+	// input: The refresh token of the session to end.
+	Logout(ctx context.Context, input service.RefreshInput) error
+
+	// LogoutAll signs the user out of every device.
+	// ctx: The context for the request.
+	// userID: The ID of the user signing out.
+	LogoutAll(ctx context.Context, userID string) error
+
+	// AcceptTOS records the user's acceptance of the current terms of service.
+	// ctx: The context for the request.
+	// userID: The ID of the user accepting the terms.
+	AcceptTOS(ctx context.Context, userID string) (*service.TOSAcceptance, error)
+
+	// Reauth checks the user's password again and returns an elevated access token.
+	// ctx: The context for the request.
+	// userID: The ID of the user re-authenticating.
+	// granted: The scopes of the token the request was authenticated with.
+	// input: The user's current password.
+	Reauth(ctx context.Context, userID string, granted []string, input service.ReauthInput) (string, error)
+
+	// IssueScopedToken creates an access token limited to the requested scopes.
+	// ctx: The context for the request.
+	// userID: The ID of the user the token is for.
+	// granted: The scopes of the token the request was authenticated with.
+	// input: The requested scopes.
+	IssueScopedToken(ctx context.Context, userID string, granted []string, input service.ScopedTokenInput) (string, error)
+
+	// UpdateProfile changes the settings of a user's profile and returns the updated user.
+	// ctx: The context for the request.
+	// userID: The ID of the user whose profile is updated.
+	// input: The settings to change.
+	UpdateProfile(ctx context.Context, userID string, input service.UpdateProfileInput) (*model.User, error)
 
 	// GetUserByID retrieves a user by their ID and returns the user or an error.
 	// ctx: The context for the request.
@@ -49,63 +107,484 @@ func NewAuthHandler(s Service) *AuthHandler {
 
 // Register handles the user registration process.
 // It binds the JSON input to the RegisterInput struct and calls the service's Register method.
-// If the input is invalid or the registration fails, it responds with a 400 status code and an error message.
-// On successful registration, it responds with a 201 status code and the created user.
+// If the input is invalid, the email address or username is taken, or the password
+// has been breached, it responds with a 400 status code and an error message, with
+// the code EMAIL_TAKEN when the email address is registered already. When the
+// email belongs to an account scheduled for deletion, the error has the code
+// ACCOUNT_RECOVERABLE, since that account can be recovered instead, and when the terms of
+// service must be accepted but accepted_tos is not true, it has the code TOS_NOT_ACCEPTED.
+// A locale or timezone that is not a BCP 47 language tag or IANA time zone name also
+// results in a 400 status code; when no locale is given, it is taken from the
+// Accept-Language header.
+// If the database does not respond in time, it responds with a 504 status code,
+// and if it cannot be reached, with a 503 status code. Other failures result in a 500.
+// On successful registration, it responds with a 201 status code and the created user as a UserResponse.
 func (h *AuthHandler) Register(c *gin.Context) {
 	var input service.RegisterInput
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.RespondInvalid(c, err)
 		return
 	}
+	input.AcceptLanguage = c.GetHeader("Accept-Language")
 
 	user, err := h.service.Register(c.Request.Context(), input)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+	switch {
+	case err == nil:
+		c.JSON(http.StatusCreated, FromModel(user))
+	case errors.Is(err, service.ErrRegistrationDisabled):
+		apierror.RespondCode(c, http.StatusServiceUnavailable, "REGISTRATION_DISABLED", service.ErrRegistrationDisabled.Error())
+	case errors.Is(err, service.ErrDisposableEmail):
+		apierror.RespondCode(c, http.StatusBadRequest, "DISPOSABLE_EMAIL", service.ErrDisposableEmail.Error())
+	case errors.Is(err, service.ErrEmailDomainNotAllowed):
+		apierror.RespondCode(c, http.StatusBadRequest, "EMAIL_DOMAIN_NOT_ALLOWED", service.ErrEmailDomainNotAllowed.Error())
+	case errors.Is(err, service.ErrTOSNotAccepted):
+		apierror.RespondCode(c, http.StatusBadRequest, "TOS_NOT_ACCEPTED", service.ErrTOSNotAccepted.Error())
+	case errors.Is(err, service.ErrAccountRecoverable):
+		apierror.RespondCode(c, http.StatusBadRequest, "ACCOUNT_RECOVERABLE", service.ErrAccountRecoverable.Error())
+	case errors.Is(err, service.ErrEmailTaken):
+		apierror.RespondCode(c, http.StatusBadRequest, "EMAIL_TAKEN", service.ErrEmailTaken.Error())
+	case errors.Is(err, service.ErrUsernameTaken),
+		errors.Is(err, service.ErrInvalidUsername),
+		errors.Is(err, service.ErrUsernameReserved),
+		errors.Is(err, service.ErrInvalidLocale),
+		errors.Is(err, service.ErrInvalidTimezone),
+		errors.Is(err, service.ErrPasswordBreached):
+		apierror.Respond(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, repository.ErrTimeout):
+		apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+	case errors.Is(err, repository.ErrConn):
+		c.Error(err)
+		apierror.Respond(c, http.StatusServiceUnavailable, err.Error())
+	default:
+		c.Error(err)
+		apierror.Respond(c, http.StatusInternalServerError, "failed to register")
 	}
+}
 
-	c.JSON(http.StatusCreated, user)
+// LoginResponse is the body of a successful login asked for with
+// include=user: the tokens, how many seconds the access token lasts and who
+// logged in, so that clients need neither decode the token nor fetch the
+// profile.
+type LoginResponse struct {
+	Token           string       `json:"token"`
+	RefreshToken    string       `json:"refresh_token,omitempty"`
+	TokenType       string       `json:"token_type"`
+	ExpiresIn       int64        `json:"expires_in"`
+	User            UserResponse `json:"user"`
+	PasswordExpired bool         `json:"password_expired,omitempty"`
 }
 
 // Login handles the user login process.
-// It expects a JSON payload with login credentials, binds it to a LoginInput struct,
+// It expects a JSON payload with an identifier, either an email address or a
+// username, and a password, binds it to a LoginInput struct,
 // and attempts to authenticate the user using the AuthService.
-// If successful, it returns a JSON response with an authentication token.
-// If there is an error during binding or authentication, it returns a JSON response with the error message.
+// If successful, it returns a JSON response with an access token and a refresh token,
+// or a LoginResponse when the include query parameter, a comma-separated list,
+// contains "user". With the refresh=cookie query parameter, the refresh token is
+// set in the refresh cookie instead of the body; see RefreshCookieName.
+// When the password has expired, either response has password_expired set to
+// true, telling the client to have the user change it, unless expired
+// passwords are refused, in which case it responds with a 403 status code and
+// the code PASSWORD_EXPIRED and a password_change_token, an access token
+// that can only be used to change the password.
+// If there is an error during binding or the credentials are wrong, it returns a
+// JSON response with the error message and a 400 status code, with the code
+// INVALID_CREDENTIALS for wrong credentials.
+// If the database does not respond in time, it responds with a 504 status code,
+// and if it cannot be reached, with a 503 status code.
 func (h *AuthHandler) Login(c *gin.Context) {
 	var input service.LoginInput
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.RespondInvalid(c, err)
+		return
+	}
+	input.IPAddress = c.ClientIP()
+	input.UserAgent = c.Request.UserAgent()
+
+	tokens, err := h.service.Login(c.Request.Context(), input)
+	var refreshToken string
+	var expired *service.PasswordExpiredError
+	if err == nil {
+		refreshToken = h.returnRefreshToken(c, tokens, wantsRefreshCookie(c))
+	}
+	switch {
+	case err == nil && slices.Contains(strings.Split(c.Query("include"), ","), "user"):
+		c.JSON(http.StatusOK, LoginResponse{
+			Token:        tokens.AccessToken,
+			RefreshToken: refreshToken,
+			TokenType:    "Bearer",
+			ExpiresIn:    int64(tokens.ExpiresIn / time.Second),
+			User:         FromModel(tokens.User),
+
+			PasswordExpired: tokens.PasswordExpired,
+		})
+	case err == nil:
+		body := tokenBody(tokens.AccessToken, refreshToken)
+		if tokens.PasswordExpired {
+			body["password_expired"] = true
+		}
+		c.JSON(http.StatusOK, body)
+	case errors.Is(err, service.ErrInvalidCredentials):
+		apierror.RespondCode(c, http.StatusBadRequest, "INVALID_CREDENTIALS", service.ErrInvalidCredentials.Error())
+	case errors.As(err, &expired):
+		apierror.RespondExtensions(c, http.StatusForbidden, "PASSWORD_EXPIRED", service.ErrPasswordExpired.Error(),
+			map[string]any{"password_change_token": expired.PasswordChangeToken})
+	case errors.Is(err, repository.ErrTimeout):
+		apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+	case errors.Is(err, repository.ErrConn):
+		c.Error(err)
+		apierror.Respond(c, http.StatusServiceUnavailable, err.Error())
+	default:
+		c.Error(err)
+		apierror.Respond(c, http.StatusInternalServerError, "failed to log in")
+	}
+}
+
+// Refresh handles the exchange of a refresh token for a new token pair.
+// It expects a JSON payload with the refresh token. Refresh tokens are single-use,
+// so the response carries a new refresh token that replaces the one presented.
+// If the token is unknown, expired, or revoked, it responds with a 401 status code.
+// If the token was already used, every token issued from the same login is revoked
+// and it responds with a 401 status code and the "TOKEN_REUSE_DETECTED" code,
+// meaning the user must log in again.
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	input, fromCookie, err := bindRefreshToken(c)
+	if err != nil {
+		apierror.RespondInvalid(c, err)
 		return
 	}
 
-	token, err := h.service.Login(c.Request.Context(), input)
+	tokens, err := h.service.Refresh(c.Request.Context(), input)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		if fromCookie && (errors.Is(err, service.ErrTokenReuseDetected) || errors.Is(err, service.ErrInvalidRefreshToken)) {
+			clearRefreshCookie(c)
+		}
+		switch {
+		case errors.Is(err, service.ErrTokenReuseDetected):
+			apierror.RespondCode(c, http.StatusUnauthorized, "TOKEN_REUSE_DETECTED", err.Error())
+		case errors.Is(err, service.ErrInvalidRefreshToken):
+			apierror.Respond(c, http.StatusUnauthorized, service.ErrInvalidRefreshToken.Error())
+		case errors.Is(err, repository.ErrTimeout):
+			apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+		case errors.Is(err, repository.ErrConn):
+			c.Error(err)
+			apierror.Respond(c, http.StatusServiceUnavailable, err.Error())
+		default:
+			c.Error(err)
+			apierror.Respond(c, http.StatusInternalServerError, "failed to refresh token")
+		}
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"token": token})
+	c.JSON(http.StatusOK, tokenBody(tokens.AccessToken, h.returnRefreshToken(c, tokens, fromCookie)))
+}
+
+// returnRefreshToken sets the refresh token of tokens in the refresh cookie
+// if inCookie, and returns the refresh token the response body should carry:
+// none in that case, and otherwise the token itself.
+func (h *AuthHandler) returnRefreshToken(c *gin.Context, tokens *service.TokenPair, inCookie bool) string {
+	if !inCookie {
+		return tokens.RefreshToken
+	}
+	setRefreshCookie(c, tokens.RefreshToken, tokens.RefreshExpiresIn)
+	return ""
+}
+
+// tokenBody returns the body of a login or refresh response, without a
+// refresh token if it was set in the refresh cookie instead.
+func tokenBody(accessToken, refreshToken string) gin.H {
+	if refreshToken == "" {
+		return gin.H{"token": accessToken}
+	}
+	return gin.H{"token": accessToken, "refresh_token": refreshToken}
+}
+
+// Logout handles the request to end the session of a refresh token, taken
+// from the refresh cookie or the JSON body as in Refresh. It revokes every
+// token of the session, clears the refresh cookie and responds with a 204
+// status code, also when the session had already ended. An unknown refresh
+// token results in a 401 status code.
+func (h *AuthHandler) Logout(c *gin.Context) {
+	input, fromCookie, err := bindRefreshToken(c)
+	if err != nil {
+		apierror.RespondInvalid(c, err)
+		return
+	}
+	if fromCookie {
+		clearRefreshCookie(c)
+	}
+
+	err = h.service.Logout(c.Request.Context(), input)
+	switch {
+	case err == nil:
+		c.Status(http.StatusNoContent)
+	case errors.Is(err, service.ErrInvalidRefreshToken):
+		apierror.Respond(c, http.StatusUnauthorized, service.ErrInvalidRefreshToken.Error())
+	case errors.Is(err, repository.ErrTimeout):
+		apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+	default:
+		c.Error(err)
+		apierror.Respond(c, http.StatusInternalServerError, "failed to log out")
+	}
 }
 
 // GetProfile handles the request to retrieve the profile of the authenticated user.
-// It expects the user ID to be stored in the context with the key "user_id".
-// If the user ID is not found in the context, it responds with an unauthorized status.
-// If the user ID is found, it attempts to retrieve the user profile from the service.
+// It expects the identity of the user to be set on the request by the authentication middleware.
+// If the request has no identity, it responds with an unauthorized status.
+// Otherwise, it attempts to retrieve the profile of the identified user from the service.
 // If the user profile is not found, it responds with a not found status.
-// If the user profile is successfully retrieved, it responds with the user profile in JSON format.
+// If the database does not respond in time, it responds with a gateway timeout status,
+// and if it cannot be reached, with a service unavailable status.
+// If the user profile is successfully retrieved, it responds with the user profile as a UserResponse.
 func (h *AuthHandler) GetProfile(c *gin.Context) {
-	id, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+	identity, ok := authctx.User(c)
+	if !ok {
+		apierror.Respond(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	user, err := h.service.GetUserByID(c.Request.Context(), identity.UserID.String())
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, FromModel(user))
+	case errors.Is(err, service.ErrUserNotFound):
+		apierror.Respond(c, http.StatusNotFound, service.ErrUserNotFound.Error())
+	case errors.Is(err, repository.ErrTimeout):
+		apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+	case errors.Is(err, repository.ErrConn):
+		c.Error(err)
+		apierror.Respond(c, http.StatusServiceUnavailable, err.Error())
+	default:
+		c.Error(err)
+		apierror.Respond(c, http.StatusInternalServerError, "failed to get profile")
+	}
+}
+
+// UpdateProfile handles the request to change the authenticated user's profile
+// settings. It expects a JSON payload with a locale, a BCP 47 language tag, and
+// a timezone, an IANA time zone name, both optional, and responds with the
+// updated profile. Invalid values result in a 400 status code listing the
+// fields that failed validation, and a database timeout in a 504.
+func (h *AuthHandler) UpdateProfile(c *gin.Context) {
+	identity, ok := authctx.User(c)
+	if !ok {
+		apierror.Respond(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var input service.UpdateProfileInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.RespondInvalid(c, err)
 		return
 	}
 
-	user, err := h.service.GetUserByID(c.Request.Context(), id.(string))
+	user, err := h.service.UpdateProfile(c.Request.Context(), identity.UserID.String(), input)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, FromModel(user))
+	case errors.Is(err, service.ErrInvalidUserID),
+		errors.Is(err, service.ErrInvalidLocale),
+		errors.Is(err, service.ErrInvalidTimezone):
+		apierror.Respond(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, service.ErrUserNotFound):
+		apierror.Respond(c, http.StatusNotFound, service.ErrUserNotFound.Error())
+	case errors.Is(err, repository.ErrTimeout):
+		apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+	default:
+		c.Error(err)
+		apierror.Respond(c, http.StatusInternalServerError, "failed to update profile")
+	}
+}
+
+// ChangePassword handles the authenticated user's request to change their password.
+// It expects a JSON payload with the current and the new password and responds
+// with a 200 status code once the password has been replaced. A wrong current
+// password or a new password found in a data breach results in a 400 status
+// code, and a database timeout in a 504.
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	identity, ok := authctx.User(c)
+	if !ok {
+		apierror.Respond(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var input service.ChangePasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.RespondInvalid(c, err)
+		return
+	}
+
+	err := h.service.ChangePassword(c.Request.Context(), identity.UserID.String(), input)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"message": "password changed"})
+	case errors.Is(err, service.ErrInvalidCredentials):
+		apierror.Respond(c, http.StatusBadRequest, service.ErrInvalidCredentials.Error())
+	case errors.Is(err, service.ErrPasswordBreached):
+		apierror.Respond(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, repository.ErrTimeout):
+		apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+	default:
+		c.Error(err)
+		apierror.Respond(c, http.StatusInternalServerError, "failed to change password")
+	}
+}
+
+// LogoutAll handles the authenticated user's request to sign out of every
+// device. It responds with a 200 status code once every access and refresh
+// token issued to the user so far, including the one presented, is revoked,
+// and clears the refresh cookie.
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	identity, ok := authctx.User(c)
+	if !ok {
+		apierror.Respond(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	err := h.service.LogoutAll(c.Request.Context(), identity.UserID.String())
+	switch {
+	case err == nil:
+		clearRefreshCookie(c)
+		c.JSON(http.StatusOK, gin.H{"message": "signed out of all devices"})
+	case errors.Is(err, service.ErrInvalidUserID):
+		apierror.Respond(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, repository.ErrTimeout):
+		apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+	default:
+		c.Error(err)
+		apierror.Respond(c, http.StatusInternalServerError, "failed to sign out")
+	}
+}
+
+// AcceptTOS handles the authenticated user's acceptance of the current terms
+// of service, which lifts the 428 status code protected routes respond with
+// after a new version is published. It responds with a 200 status code and the
+// accepted version and time, which are kept as proof of consent.
+func (h *AuthHandler) AcceptTOS(c *gin.Context) {
+	identity, ok := authctx.User(c)
+	if !ok {
+		apierror.Respond(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	acceptance, err := h.service.AcceptTOS(c.Request.Context(), identity.UserID.String())
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"version": acceptance.Version, "accepted_at": acceptance.AcceptedAt})
+	case errors.Is(err, service.ErrInvalidUserID):
+		apierror.Respond(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, repository.ErrTimeout):
+		apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+	default:
+		c.Error(err)
+		apierror.Respond(c, http.StatusInternalServerError, "failed to accept terms of service")
+	}
+}
+
+// Reauth handles the authenticated user's confirmation of their password
+// before a sensitive operation. It expects a JSON payload with the current
+// password and responds with a 200 status code and an elevated, short-lived
+// access token that routes requiring recent authentication accept. A wrong
+// password results in a 401 status code.
+func (h *AuthHandler) Reauth(c *gin.Context) {
+	identity, ok := authctx.User(c)
+	if !ok {
+		apierror.Respond(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var input service.ReauthInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.RespondInvalid(c, err)
+		return
+	}
+
+	token, err := h.service.Reauth(c.Request.Context(), identity.UserID.String(), identity.Scopes, input)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"token": token})
+	case errors.Is(err, service.ErrInvalidCredentials):
+		apierror.Respond(c, http.StatusUnauthorized, service.ErrInvalidCredentials.Error())
+	case errors.Is(err, repository.ErrTimeout):
+		apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+	default:
+		c.Error(err)
+		apierror.Respond(c, http.StatusInternalServerError, "failed to re-authenticate")
+	}
+}
+
+// IssueToken handles the authenticated user's request for an access token
+// limited to some of their scopes, for use by scripts or other services.
+// It expects a JSON payload with the requested scopes and responds with a 201
+// status code and the token. Unknown scopes result in a 400 status code, and
+// scopes the presented token does not carry itself in a 403.
+func (h *AuthHandler) IssueToken(c *gin.Context) {
+	identity, ok := authctx.User(c)
+	if !ok {
+		apierror.Respond(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var input service.ScopedTokenInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.RespondInvalid(c, err)
+		return
+	}
+
+	token, err := h.service.IssueScopedToken(c.Request.Context(), identity.UserID.String(), identity.Scopes, input)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusCreated, gin.H{"token": token})
+	case errors.Is(err, service.ErrUnknownScope), errors.Is(err, service.ErrNoScopes):
+		apierror.Respond(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, service.ErrScopeNotGranted):
+		apierror.RespondCode(c, http.StatusForbidden, "INSUFFICIENT_SCOPE", err.Error())
+	case errors.Is(err, repository.ErrTimeout):
+		apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+	default:
+		c.Error(err)
+		apierror.Respond(c, http.StatusInternalServerError, "failed to issue token")
+	}
+}
+
+// Introspect handles token introspection requests from internal services.
+// As RFC 7662 section 2.1 requires, it expects the access token in the
+// "token" parameter of a form-encoded POST body, so that tokens stay out of
+// URLs and the logs recording them. The route is therefore
+// POST /api/auth/token/introspect rather than a GET with the token in the
+// query. It responds with an RFC 7662 shaped JSON object. For an active token
+// the object contains "active": true along with "sub", "email", "exp" and
+// "iat". For a token that is expired, revoked, or malformed it contains only
+// "active": false, without revealing why. A missing token results in a 400
+// status code.
+func (h *AuthHandler) Introspect(c *gin.Context) {
+	token := c.PostForm("token")
+	if token == "" {
+		apierror.Respond(c, http.StatusBadRequest, "token is required")
+		return
+	}
+
+	result, err := h.service.Introspect(c.Request.Context(), token)
+	if errors.Is(err, repository.ErrTimeout) {
+		apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		c.Error(err)
+		apierror.Respond(c, http.StatusInternalServerError, "failed to introspect token")
+		return
+	}
+
+	if !result.Active {
+		c.JSON(http.StatusOK, gin.H{"active": false})
 		return
 	}
 
-	c.JSON(http.StatusOK, user)
+	c.JSON(http.StatusOK, gin.H{
+		"active": true,
+		"sub":    result.Subject,
+		"email":  result.Email,
+		"exp":    result.ExpiresAt,
+		"iat":    result.IssuedAt,
+	})
 }
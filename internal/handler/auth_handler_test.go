@@ -1,19 +1,21 @@
 package handler
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
-	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/PakornBank/learn-go/internal/apierror"
+	"github.com/PakornBank/learn-go/internal/authctx"
 	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
 	"github.com/PakornBank/learn-go/internal/service"
 	"github.com/PakornBank/learn-go/internal/testutil"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -30,9 +32,69 @@ func (ms *MockService) Register(ctx context.Context, in service.RegisterInput) (
 	return args.Get(0).(*model.User), args.Error(1)
 }
 
-func (ms *MockService) Login(ctx context.Context, in service.LoginInput) (string, error) {
+func (ms *MockService) Login(ctx context.Context, in service.LoginInput) (*service.TokenPair, error) {
 	args := ms.Called(ctx, in)
-	return args.Get(0).(string), args.Error(1)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.TokenPair), args.Error(1)
+}
+
+func (ms *MockService) Refresh(ctx context.Context, in service.RefreshInput) (*service.TokenPair, error) {
+	args := ms.Called(ctx, in)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.TokenPair), args.Error(1)
+}
+
+func (ms *MockService) Introspect(ctx context.Context, token string) (*service.Introspection, error) {
+	args := ms.Called(ctx, token)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.Introspection), args.Error(1)
+}
+
+func (ms *MockService) ChangePassword(ctx context.Context, userID string, in service.ChangePasswordInput) error {
+	args := ms.Called(ctx, userID, in)
+	return args.Error(0)
+}
+
+func (ms *MockService) Logout(ctx context.Context, in service.RefreshInput) error {
+	args := ms.Called(ctx, in)
+	return args.Error(0)
+}
+
+func (ms *MockService) LogoutAll(ctx context.Context, userID string) error {
+	args := ms.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (ms *MockService) AcceptTOS(ctx context.Context, userID string) (*service.TOSAcceptance, error) {
+	args := ms.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.TOSAcceptance), args.Error(1)
+}
+
+func (ms *MockService) UpdateProfile(ctx context.Context, userID string, input service.UpdateProfileInput) (*model.User, error) {
+	args := ms.Called(ctx, userID, input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+func (ms *MockService) Reauth(ctx context.Context, userID string, granted []string, in service.ReauthInput) (string, error) {
+	args := ms.Called(ctx, userID, granted, in)
+	return args.String(0), args.Error(1)
+}
+
+func (ms *MockService) IssueScopedToken(ctx context.Context, userID string, granted []string, in service.ScopedTokenInput) (string, error) {
+	args := ms.Called(ctx, userID, granted, in)
+	return args.String(0), args.Error(1)
 }
 
 func (ms *MockService) GetUserByID(ctx context.Context, id string) (*model.User, error) {
@@ -43,24 +105,47 @@ func (ms *MockService) GetUserByID(ctx context.Context, id string) (*model.User,
 	return args.Get(0).(*model.User), args.Error(1)
 }
 
-func setupTest(middleware gin.HandlerFunc) (*gin.Engine, *MockService) {
-	gin.SetMode(gin.TestMode)
-
+func setupTest(t *testing.T, middleware ...gin.HandlerFunc) (*testutil.APITester, *MockService) {
 	mockservice := new(MockService)
 	handler := NewAuthHandler(mockservice)
 
-	router := gin.New()
-	group := router.Group("/api")
-	if middleware != nil {
-		group.Use(middleware)
-	}
+	api := testutil.NewAPITester(t, middleware...)
+	group := api.Group("/api")
 	{
 		group.POST("/register", handler.Register)
 		group.POST("/login", handler.Login)
+		group.POST("/refresh", handler.Refresh)
+		group.DELETE("/refresh", handler.Logout)
 		group.GET("/profile", handler.GetProfile)
+		group.PATCH("/profile", handler.UpdateProfile)
+		group.PUT("/password", handler.ChangePassword)
+		group.POST("/logout-all", handler.LogoutAll)
+		group.POST("/tos/accept", handler.AcceptTOS)
+		group.POST("/reauth", handler.Reauth)
+		group.POST("/tokens", handler.IssueToken)
+		group.POST("/token/introspect", handler.Introspect)
+	}
+
+	return api, mockservice
+}
+
+// collectErrors returns a middleware that appends the errors handlers attach
+// for error reporting to errs.
+func collectErrors(errs *[]error) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		for _, err := range c.Errors {
+			*errs = append(*errs, err.Err)
+		}
 	}
+}
 
-	return router, mockservice
+// authenticatedAs returns a middleware that authenticates requests as the
+// user with the ID userID, as AuthMiddleware does.
+func authenticatedAs(userID string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authctx.SetUser(c, authctx.Identity{UserID: uuid.MustParse(userID)})
+	}
 }
 
 func TestNewAuthHandler(t *testing.T) {
@@ -79,7 +164,10 @@ func TestAuthHandler_Register(t *testing.T) {
 		input       service.RegisterInput
 		mockFn      func(*MockService)
 		wantCode    int
+		wantErrCode string
 		errContains string
+		// golden is the file under testdata the response must match exactly.
+		golden string
 	}{
 		{
 			name: "successful registration",
@@ -111,8 +199,107 @@ func TestAuthHandler_Register(t *testing.T) {
 						in.Password == "password"
 				})).Return(nil, errors.New("auth_service error"))
 			},
+			wantCode:    http.StatusInternalServerError,
+			errContains: "failed to register",
+		},
+		{
+			name: "email taken",
+			input: service.RegisterInput{
+				Email:    user.Email,
+				Password: "password",
+				FullName: user.FullName,
+			},
+			mockFn: func(ms *MockService) {
+				ms.On("Register", mock.Anything, mock.Anything).
+					Return(nil, fmt.Errorf("%w: %w", service.ErrEmailTaken, repository.ErrDuplicate))
+			},
+			wantCode:    http.StatusBadRequest,
+			errContains: service.ErrEmailTaken.Error(),
+			golden:      "auth/register_email_taken.json",
+		},
+		{
+			name: "account recoverable",
+			input: service.RegisterInput{
+				Email:    user.Email,
+				Password: "password",
+				FullName: user.FullName,
+			},
+			mockFn: func(ms *MockService) {
+				ms.On("Register", mock.Anything, mock.Anything).Return(nil, service.ErrAccountRecoverable)
+			},
+			wantCode:    http.StatusBadRequest,
+			wantErrCode: "ACCOUNT_RECOVERABLE",
+			errContains: service.ErrAccountRecoverable.Error(),
+			golden:      "auth/register_account_recoverable.json",
+		},
+		{
+			name: "terms of service not accepted",
+			input: service.RegisterInput{
+				Email:    user.Email,
+				Password: "password",
+				FullName: user.FullName,
+			},
+			mockFn: func(ms *MockService) {
+				ms.On("Register", mock.Anything, mock.Anything).Return(nil, service.ErrTOSNotAccepted)
+			},
+			wantCode:    http.StatusBadRequest,
+			wantErrCode: "TOS_NOT_ACCEPTED",
+			errContains: service.ErrTOSNotAccepted.Error(),
+		},
+		{
+			name: "database timeout",
+			input: service.RegisterInput{
+				Email:    user.Email,
+				Password: "password",
+				FullName: user.FullName,
+			},
+			mockFn: func(ms *MockService) {
+				ms.On("Register", mock.Anything, mock.Anything).Return(nil, repository.ErrTimeout)
+			},
+			wantCode:    http.StatusGatewayTimeout,
+			errContains: repository.ErrTimeout.Error(),
+		},
+		{
+			name: "registration disabled",
+			input: service.RegisterInput{
+				Email:    user.Email,
+				Password: "password",
+				FullName: user.FullName,
+			},
+			mockFn: func(ms *MockService) {
+				ms.On("Register", mock.Anything, mock.Anything).Return(nil, service.ErrRegistrationDisabled)
+			},
+			wantCode:    http.StatusServiceUnavailable,
+			wantErrCode: "REGISTRATION_DISABLED",
+			errContains: service.ErrRegistrationDisabled.Error(),
+		},
+		{
+			name: "disposable email",
+			input: service.RegisterInput{
+				Email:    "user@mailinator.com",
+				Password: "password",
+				FullName: user.FullName,
+			},
+			mockFn: func(ms *MockService) {
+				ms.On("Register", mock.Anything, mock.Anything).Return(nil, service.ErrDisposableEmail)
+			},
+			wantCode:    http.StatusBadRequest,
+			wantErrCode: "DISPOSABLE_EMAIL",
+			errContains: service.ErrDisposableEmail.Error(),
+		},
+		{
+			name: "email domain not allowed",
+			input: service.RegisterInput{
+				Email:    "user@gmail.com",
+				Password: "password",
+				FullName: user.FullName,
+			},
+			mockFn: func(ms *MockService) {
+				ms.On("Register", mock.Anything, mock.Anything).Return(nil, service.ErrEmailDomainNotAllowed)
+			},
 			wantCode:    http.StatusBadRequest,
-			errContains: "auth_service error",
+			wantErrCode: "EMAIL_DOMAIN_NOT_ALLOWED",
+			errContains: service.ErrEmailDomainNotAllowed.Error(),
 		},
 		{
 			name: "invalid email",
@@ -123,6 +310,7 @@ func TestAuthHandler_Register(t *testing.T) {
 			},
 			wantCode:    http.StatusBadRequest,
 			errContains: "Error:Field validation for 'Email' failed",
+			golden:      "auth/register_invalid_email.json",
 		},
 		{
 			name: "invalid password",
@@ -144,29 +332,43 @@ func TestAuthHandler_Register(t *testing.T) {
 			wantCode:    http.StatusBadRequest,
 			errContains: "Error:Field validation for 'FullName' failed",
 		},
+		{
+			name: "invalid locale",
+			input: service.RegisterInput{
+				Email:    user.Email,
+				Password: "password",
+				FullName: user.FullName,
+				Locale:   "not a locale",
+			},
+			wantCode:    http.StatusBadRequest,
+			errContains: "Error:Field validation for 'Locale' failed on the 'bcp47_language_tag' tag",
+		},
+		{
+			name: "invalid timezone",
+			input: service.RegisterInput{
+				Email:    user.Email,
+				Password: "password",
+				FullName: user.FullName,
+				Timezone: "Mars/Olympus_Mons",
+			},
+			wantCode:    http.StatusBadRequest,
+			errContains: "Error:Field validation for 'Timezone' failed on the 'timezone' tag",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			router, mockService := setupTest(nil)
+			api, mockService := setupTest(t)
 			if tt.mockFn != nil {
 				tt.mockFn(mockService)
 			}
 
-			body, _ := json.Marshal(tt.input)
-			req := httptest.NewRequest(http.MethodPost, "/api/register", bytes.NewBuffer(body))
-			req.Header.Set("Content-Type", "application/json")
-			w := httptest.NewRecorder()
-
-			router.ServeHTTP(w, req)
-
-			assert.Equal(t, tt.wantCode, w.Code)
-
-			var res map[string]interface{}
-			err := json.Unmarshal(w.Body.Bytes(), &res)
-			assert.NoError(t, err)
+			w := api.Do(http.MethodPost, "/api/register", tt.input).AssertStatus(tt.wantCode)
+			if tt.golden != "" {
+				w.AssertGolden(tt.golden)
+			}
 
-			assert.NotNil(t, res)
+			res := w.JSON()
 
 			if tt.wantCode == http.StatusCreated {
 				assert.Equal(t, user.ID.String(), res["id"])
@@ -178,25 +380,59 @@ func TestAuthHandler_Register(t *testing.T) {
 			} else {
 				assert.Contains(t, res["error"], tt.errContains)
 			}
+			if tt.wantErrCode != "" {
+				assert.Equal(t, tt.wantErrCode, res["code"])
+			}
 
 			mockService.AssertExpectations(t)
 		})
 	}
 }
 
+func TestAuthHandler_RegisterAcceptLanguage(t *testing.T) {
+	user := testutil.NewMockUser()
+	api, mockService := setupTest(t)
+	mockService.On("Register", mock.Anything, mock.MatchedBy(func(in service.RegisterInput) bool {
+		return in.AcceptLanguage == "th-TH,th;q=0.9" && in.Locale == "" && in.Timezone == "Asia/Bangkok"
+	})).Return(&user, nil)
+
+	body := `{"email":"test@example.com","password":"password","full_name":"Test User","timezone":"Asia/Bangkok"}`
+	api.Do(http.MethodPost, "/api/register", body, testutil.WithHeader("Accept-Language", "th-TH,th;q=0.9")).
+		AssertStatus(http.StatusCreated)
+	mockService.AssertExpectations(t)
+}
+
 func TestAuthHandler_Login(t *testing.T) {
 	const (
-		testToken    = "test-token"
-		testEmail    = "test@example.com"
-		testPassword = "password"
+		testToken        = "test-token"
+		testRefreshToken = "test-refresh-token"
+		testEmail        = "test@example.com"
+		testPassword     = "password"
 	)
 
+	tokens := &service.TokenPair{
+		AccessToken:  testToken,
+		RefreshToken: testRefreshToken,
+		ExpiresIn:    15 * time.Minute,
+		User:         goldenUser(false),
+	}
+	expired := *tokens
+	expired.PasswordExpired = true
+
 	tests := []struct {
-		name        string
-		input       service.LoginInput
-		mockFn      func(*MockService)
-		wantCode    int
-		errContains string
+		name string
+		// query is appended to the path of the login endpoint.
+		query               string
+		input               service.LoginInput
+		mockFn              func(*MockService)
+		wantCode            int
+		wantPasswordExpired bool
+		errContains         string
+		// wantError is the exact error message, when the response must not
+		// reveal more than it.
+		wantError string
+		// golden is the file under testdata the response must match exactly.
+		golden string
 	}{
 		{
 			name: "successful login",
@@ -207,12 +443,105 @@ func TestAuthHandler_Login(t *testing.T) {
 			mockFn: func(ms *MockService) {
 				ms.On("Login", mock.Anything, mock.MatchedBy(func(input service.LoginInput) bool {
 					return input.Email == testEmail && input.Password == testPassword
-				})).Return(testToken, nil)
+				})).Return(tokens, nil)
 			},
 			wantCode: http.StatusOK,
+			golden:   "auth/login.json",
 		},
 		{
-			name: "auth_service error",
+			name:  "successful login including the user",
+			query: "?include=user",
+			input: service.LoginInput{
+				Email:    testEmail,
+				Password: testPassword,
+			},
+			mockFn: func(ms *MockService) {
+				ms.On("Login", mock.Anything, mock.Anything).Return(tokens, nil)
+			},
+			wantCode: http.StatusOK,
+			golden:   "auth/login_include_user.json",
+		},
+		{
+			name:  "user among other includes",
+			query: "?include=avatar,user",
+			input: service.LoginInput{
+				Email:    testEmail,
+				Password: testPassword,
+			},
+			mockFn: func(ms *MockService) {
+				ms.On("Login", mock.Anything, mock.Anything).Return(tokens, nil)
+			},
+			wantCode: http.StatusOK,
+			golden:   "auth/login_include_user.json",
+		},
+		{
+			name:  "unknown include",
+			query: "?include=users",
+			input: service.LoginInput{
+				Email:    testEmail,
+				Password: testPassword,
+			},
+			mockFn: func(ms *MockService) {
+				ms.On("Login", mock.Anything, mock.Anything).Return(tokens, nil)
+			},
+			wantCode: http.StatusOK,
+			golden:   "auth/login.json",
+		},
+		{
+			name:  "invalid credentials including the user",
+			query: "?include=user",
+			input: service.LoginInput{
+				Email:    testEmail,
+				Password: testPassword,
+			},
+			mockFn: func(ms *MockService) {
+				ms.On("Login", mock.Anything, mock.Anything).Return(nil, service.ErrInvalidCredentials)
+			},
+			wantCode:    http.StatusBadRequest,
+			errContains: service.ErrInvalidCredentials.Error(),
+			golden:      "auth/login_invalid_credentials.json",
+		},
+		{
+			name: "expired password",
+			input: service.LoginInput{
+				Email:    testEmail,
+				Password: testPassword,
+			},
+			mockFn: func(ms *MockService) {
+				ms.On("Login", mock.Anything, mock.Anything).Return(&expired, nil)
+			},
+			wantCode:            http.StatusOK,
+			wantPasswordExpired: true,
+			golden:              "auth/login_password_expired.json",
+		},
+		{
+			name:  "expired password including the user",
+			query: "?include=user",
+			input: service.LoginInput{
+				Email:    testEmail,
+				Password: testPassword,
+			},
+			mockFn: func(ms *MockService) {
+				ms.On("Login", mock.Anything, mock.Anything).Return(&expired, nil)
+			},
+			wantCode:            http.StatusOK,
+			wantPasswordExpired: true,
+		},
+		{
+			name: "expired password refused",
+			input: service.LoginInput{
+				Email:    testEmail,
+				Password: testPassword,
+			},
+			mockFn: func(ms *MockService) {
+				ms.On("Login", mock.Anything, mock.Anything).Return(nil, &service.PasswordExpiredError{PasswordChangeToken: "change-token"})
+			},
+			wantCode:    http.StatusForbidden,
+			errContains: service.ErrPasswordExpired.Error(),
+			golden:      "auth/login_password_expired_refused.json",
+		},
+		{
+			name: "invalid credentials",
 			input: service.LoginInput{
 				Email:    testEmail,
 				Password: testPassword,
@@ -220,15 +549,87 @@ func TestAuthHandler_Login(t *testing.T) {
 			mockFn: func(ms *MockService) {
 				ms.On("Login", mock.Anything, mock.MatchedBy(func(input service.LoginInput) bool {
 					return input.Email == testEmail && input.Password == testPassword
-				})).Return("", errors.New("auth_service error"))
+				})).Return(nil, service.ErrInvalidCredentials)
+			},
+			wantCode:    http.StatusBadRequest,
+			errContains: service.ErrInvalidCredentials.Error(),
+			golden:      "auth/login_invalid_credentials.json",
+		},
+		{
+			name: "unknown email does not expose the cause",
+			input: service.LoginInput{
+				Email:    testEmail,
+				Password: testPassword,
+			},
+			mockFn: func(ms *MockService) {
+				ms.On("Login", mock.Anything, mock.Anything).
+					Return(nil, fmt.Errorf("%w: %w", service.ErrInvalidCredentials, repository.ErrNotFound))
+			},
+			wantCode:  http.StatusBadRequest,
+			wantError: service.ErrInvalidCredentials.Error(),
+			golden:    "auth/login_invalid_credentials.json",
+		},
+		{
+			name: "auth_service error",
+			input: service.LoginInput{
+				Email:    testEmail,
+				Password: testPassword,
+			},
+			mockFn: func(ms *MockService) {
+				ms.On("Login", mock.Anything, mock.Anything).Return(nil, errors.New("auth_service error"))
+			},
+			wantCode:    http.StatusInternalServerError,
+			errContains: "failed to log in",
+		},
+		{
+			name: "database unavailable",
+			input: service.LoginInput{
+				Email:    testEmail,
+				Password: testPassword,
+			},
+			mockFn: func(ms *MockService) {
+				ms.On("Login", mock.Anything, mock.Anything).Return(nil, repository.ErrConn)
+			},
+			wantCode:    http.StatusServiceUnavailable,
+			errContains: repository.ErrConn.Error(),
+		},
+		{
+			name: "database timeout",
+			input: service.LoginInput{
+				Email:    testEmail,
+				Password: testPassword,
+			},
+			mockFn: func(ms *MockService) {
+				ms.On("Login", mock.Anything, mock.Anything).Return(nil, repository.ErrTimeout)
+			},
+			wantCode:    http.StatusGatewayTimeout,
+			errContains: repository.ErrTimeout.Error(),
+		},
+		{
+			name: "successful login with username",
+			input: service.LoginInput{
+				Identifier: "tester",
+				Password:   testPassword,
+			},
+			mockFn: func(ms *MockService) {
+				ms.On("Login", mock.Anything, mock.MatchedBy(func(input service.LoginInput) bool {
+					return input.Identifier == "tester" && input.Email == "" && input.Password == testPassword
+				})).Return(&service.TokenPair{AccessToken: testToken, RefreshToken: testRefreshToken}, nil)
+			},
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "missing identifier",
+			input: service.LoginInput{
+				Password: testPassword,
 			},
 			wantCode:    http.StatusBadRequest,
-			errContains: "auth_service error",
+			errContains: "Error:Field validation for 'Identifier' failed",
 		},
 		{
 			name: "invalid email",
 			input: service.LoginInput{
-				Email:    "",
+				Email:    "not-an-email",
 				Password: testPassword,
 			},
 			wantCode:    http.StatusBadRequest,
@@ -242,44 +643,162 @@ func TestAuthHandler_Login(t *testing.T) {
 			},
 			wantCode:    http.StatusBadRequest,
 			errContains: "Error:Field validation for 'Password' failed",
+			golden:      "auth/login_invalid_password.json",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			router, mockService := setupTest(nil)
+			api, mockService := setupTest(t)
 			if tt.mockFn != nil {
 				tt.mockFn(mockService)
 			}
 
-			body, _ := json.Marshal(tt.input)
-			req := httptest.NewRequest(http.MethodPost, "/api/login", bytes.NewBuffer(body))
-			req.Header.Set("Content-Type", "application/json")
-			w := httptest.NewRecorder()
-
-			router.ServeHTTP(w, req)
-
-			assert.Equal(t, tt.wantCode, w.Code)
-
-			var res map[string]interface{}
-			err := json.Unmarshal(w.Body.Bytes(), &res)
-			assert.NoError(t, err)
+			w := api.Do(http.MethodPost, "/api/login"+tt.query, tt.input).AssertStatus(tt.wantCode)
+			if tt.golden != "" {
+				w.AssertGolden(tt.golden)
+			}
 
-			assert.NotNil(t, res)
+			res := w.JSON()
 
 			if tt.wantCode == http.StatusOK {
 				assert.Equal(t, testToken, res["token"])
+				assert.Equal(t, testRefreshToken, res["refresh_token"])
+				assert.Equal(t, tt.wantPasswordExpired, res["password_expired"] == true)
 			} else {
 				assert.Contains(t, res["error"], tt.errContains)
 			}
+			if tt.wantError != "" {
+				assert.Equal(t, tt.wantError, res["error"])
+			}
 
 			mockService.AssertExpectations(t)
 		})
 	}
 }
 
-func TestAuthHandler_GetProfile(t *testing.T) {
-	user := testutil.NewMockUser()
+func TestAuthHandler_Login_ProblemDetails(t *testing.T) {
+	api, mockService := setupTest(t, apierror.Negotiate(true))
+
+	w := api.Do(http.MethodPost, "/api/login", service.LoginInput{Email: "not-an-email"}).
+		AssertStatus(http.StatusBadRequest)
+
+	assert.Equal(t, apierror.ProblemContentType, w.Header().Get("Content-Type"))
+	var problem apierror.Problem
+	w.Decode(&problem)
+	assert.Equal(t, http.StatusBadRequest, problem.Status)
+	var fields []string
+	for _, fe := range problem.Errors {
+		fields = append(fields, fe.Field)
+	}
+	assert.Equal(t, []string{"Email", "Password"}, fields)
+	mockService.AssertExpectations(t)
+}
+
+func TestAuthHandler_Refresh(t *testing.T) {
+	const (
+		testToken        = "test-token"
+		testRefreshToken = "test-refresh-token"
+		newRefreshToken  = "new-refresh-token"
+	)
+
+	tests := []struct {
+		name         string
+		input        service.RefreshInput
+		mockFn       func(*MockService)
+		wantCode     int
+		wantErrCode  string
+		errContains  string
+		wantAttached bool
+	}{
+		{
+			name:  "successful refresh",
+			input: service.RefreshInput{RefreshToken: testRefreshToken},
+			mockFn: func(ms *MockService) {
+				ms.On("Refresh", mock.Anything, service.RefreshInput{RefreshToken: testRefreshToken}).
+					Return(&service.TokenPair{AccessToken: testToken, RefreshToken: newRefreshToken}, nil)
+			},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:  "reuse detected",
+			input: service.RefreshInput{RefreshToken: testRefreshToken},
+			mockFn: func(ms *MockService) {
+				ms.On("Refresh", mock.Anything, mock.Anything).Return(nil, service.ErrTokenReuseDetected)
+			},
+			wantCode:    http.StatusUnauthorized,
+			wantErrCode: "TOKEN_REUSE_DETECTED",
+			errContains: service.ErrTokenReuseDetected.Error(),
+		},
+		{
+			name:  "invalid refresh token",
+			input: service.RefreshInput{RefreshToken: testRefreshToken},
+			mockFn: func(ms *MockService) {
+				ms.On("Refresh", mock.Anything, mock.Anything).Return(nil, service.ErrInvalidRefreshToken)
+			},
+			wantCode:    http.StatusUnauthorized,
+			errContains: service.ErrInvalidRefreshToken.Error(),
+		},
+		{
+			name:  "database timeout",
+			input: service.RefreshInput{RefreshToken: testRefreshToken},
+			mockFn: func(ms *MockService) {
+				ms.On("Refresh", mock.Anything, mock.Anything).Return(nil, repository.ErrTimeout)
+			},
+			wantCode:    http.StatusGatewayTimeout,
+			errContains: repository.ErrTimeout.Error(),
+		},
+		{
+			name:  "auth_service error",
+			input: service.RefreshInput{RefreshToken: testRefreshToken},
+			mockFn: func(ms *MockService) {
+				ms.On("Refresh", mock.Anything, mock.Anything).Return(nil, errors.New("auth_service error"))
+			},
+			wantCode:     http.StatusInternalServerError,
+			errContains:  "failed to refresh token",
+			wantAttached: true,
+		},
+		{
+			name:        "missing refresh token",
+			input:       service.RefreshInput{},
+			wantCode:    http.StatusBadRequest,
+			errContains: "Error:Field validation for 'RefreshToken' failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attached []error
+			api, mockService := setupTest(t, collectErrors(&attached))
+			if tt.mockFn != nil {
+				tt.mockFn(mockService)
+			}
+
+			res := api.Do(http.MethodPost, "/api/refresh", tt.input).AssertStatus(tt.wantCode).JSON()
+
+			if tt.wantCode == http.StatusOK {
+				assert.Equal(t, testToken, res["token"])
+				assert.Equal(t, newRefreshToken, res["refresh_token"])
+			} else {
+				assert.Contains(t, res["error"], tt.errContains)
+				if tt.wantErrCode != "" {
+					assert.Equal(t, tt.wantErrCode, res["code"])
+				}
+			}
+
+			if tt.wantAttached {
+				assert.Len(t, attached, 1)
+			} else {
+				assert.Empty(t, attached)
+			}
+
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthHandler_GetProfile(t *testing.T) {
+	user := testutil.NewMockUser()
 
 	tests := []struct {
 		name        string
@@ -287,12 +806,12 @@ func TestAuthHandler_GetProfile(t *testing.T) {
 		mockFn      func(*MockService)
 		wantCode    int
 		errContains string
+		// golden is the file under testdata the response must match exactly.
+		golden string
 	}{
 		{
-			name: "successful profile retrieval",
-			middleware: func(c *gin.Context) {
-				c.Set("user_id", user.ID.String())
-			},
+			name:       "successful profile retrieval",
+			middleware: authenticatedAs(user.ID.String()),
 			mockFn: func(ms *MockService) {
 				ms.On("GetUserByID", mock.Anything, user.ID.String()).
 					Return(&user, nil)
@@ -300,42 +819,69 @@ func TestAuthHandler_GetProfile(t *testing.T) {
 			wantCode: http.StatusOK,
 		},
 		{
-			name: "auth_service error",
-			middleware: func(c *gin.Context) {
-				c.Set("user_id", user.ID.String())
+			name:       "user not found",
+			middleware: authenticatedAs(user.ID.String()),
+			mockFn: func(ms *MockService) {
+				ms.On("GetUserByID", mock.Anything, user.ID.String()).
+					Return(nil, fmt.Errorf("%w: %w", service.ErrUserNotFound, repository.ErrNotFound))
 			},
+			wantCode:    http.StatusNotFound,
+			errContains: service.ErrUserNotFound.Error(),
+			golden:      "auth/profile_not_found.json",
+		},
+		{
+			name:       "auth_service error",
+			middleware: authenticatedAs(user.ID.String()),
 			mockFn: func(ms *MockService) {
 				ms.On("GetUserByID", mock.Anything, user.ID.String()).
 					Return(nil, errors.New("auth_service error"))
 			},
-			wantCode:    http.StatusNotFound,
-			errContains: "auth_service error",
+			wantCode:    http.StatusInternalServerError,
+			errContains: "failed to get profile",
+		},
+		{
+			name:       "database unavailable",
+			middleware: authenticatedAs(user.ID.String()),
+			mockFn: func(ms *MockService) {
+				ms.On("GetUserByID", mock.Anything, user.ID.String()).
+					Return(nil, repository.ErrConn)
+			},
+			wantCode:    http.StatusServiceUnavailable,
+			errContains: repository.ErrConn.Error(),
 		},
 		{
-			name:        "no user_id in context",
+			name:       "database timeout",
+			middleware: authenticatedAs(user.ID.String()),
+			mockFn: func(ms *MockService) {
+				ms.On("GetUserByID", mock.Anything, user.ID.String()).
+					Return(nil, repository.ErrTimeout)
+			},
+			wantCode:    http.StatusGatewayTimeout,
+			errContains: repository.ErrTimeout.Error(),
+		},
+		{
+			name:        "unauthenticated request",
 			wantCode:    http.StatusUnauthorized,
 			errContains: "unauthorized",
+			golden:      "auth/profile_unauthorized.json",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			router, mockService := setupTest(tt.middleware)
+			api, mockService := setupTest(t, tt.middleware)
 			if tt.mockFn != nil {
 				tt.mockFn(mockService)
 			}
 
-			req := httptest.NewRequest(http.MethodGet, "/api/profile", nil)
-			w := httptest.NewRecorder()
-
-			router.ServeHTTP(w, req)
-
-			assert.Equal(t, tt.wantCode, w.Code)
+			w := api.Do(http.MethodGet, "/api/profile", nil).AssertStatus(tt.wantCode)
+			if tt.golden != "" {
+				w.AssertGolden(tt.golden)
+			}
 
 			if tt.wantCode == http.StatusOK {
 				var res model.User
-				err := json.Unmarshal(w.Body.Bytes(), &res)
-				assert.NoError(t, err)
+				w.Decode(&res)
 
 				assert.Equal(t, user.ID, res.ID)
 				assert.Equal(t, user.Email, res.Email)
@@ -344,13 +890,669 @@ func TestAuthHandler_GetProfile(t *testing.T) {
 				assert.Equal(t, user.UpdatedAt.Format(time.RFC3339Nano), res.UpdatedAt.Format(time.RFC3339Nano))
 				assert.Empty(t, res.PasswordHash)
 			} else {
-				var res map[string]interface{}
-				err := json.Unmarshal(w.Body.Bytes(), &res)
-				assert.NoError(t, err)
+				assert.Contains(t, w.JSON()["error"], tt.errContains)
+			}
+
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthHandler_UpdateProfile(t *testing.T) {
+	const userID = "5b0c8f3e-2a1d-4c6b-9e7f-3d2a1b0c9e8f"
+	authenticated := authenticatedAs(userID)
+	locale, timezone := "th-TH", "Asia/Bangkok"
+	input := service.UpdateProfileInput{Locale: &locale, Timezone: &timezone}
+	updated := testutil.NewMockUser()
+	updated.Locale, updated.Timezone = locale, timezone
+
+	tests := []struct {
+		name         string
+		middleware   gin.HandlerFunc
+		body         string
+		mockFn       func(*MockService)
+		wantCode     int
+		wantAttached bool
+		errContains  string
+	}{
+		{
+			name:       "updated",
+			middleware: authenticated,
+			body:       `{"locale": "th-TH", "timezone": "Asia/Bangkok"}`,
+			mockFn: func(ms *MockService) {
+				ms.On("UpdateProfile", mock.Anything, userID, input).Return(&updated, nil)
+			},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:        "not authenticated",
+			middleware:  func(c *gin.Context) {},
+			body:        `{"locale": "th-TH"}`,
+			wantCode:    http.StatusUnauthorized,
+			errContains: "unauthorized",
+		},
+		{
+			name:        "invalid locale",
+			middleware:  authenticated,
+			body:        `{"locale": "not a locale"}`,
+			wantCode:    http.StatusBadRequest,
+			errContains: "Error:Field validation for 'Locale' failed on the 'bcp47_language_tag' tag",
+		},
+		{
+			name:        "invalid timezone",
+			middleware:  authenticated,
+			body:        `{"timezone": "Local"}`,
+			wantCode:    http.StatusBadRequest,
+			errContains: "Error:Field validation for 'Timezone' failed on the 'timezone' tag",
+		},
+		{
+			name:       "user not found",
+			middleware: authenticated,
+			body:       `{"locale": "th-TH", "timezone": "Asia/Bangkok"}`,
+			mockFn: func(ms *MockService) {
+				ms.On("UpdateProfile", mock.Anything, userID, input).Return(nil, service.ErrUserNotFound)
+			},
+			wantCode:    http.StatusNotFound,
+			errContains: service.ErrUserNotFound.Error(),
+		},
+		{
+			name:       "database timeout",
+			middleware: authenticated,
+			body:       `{"locale": "th-TH", "timezone": "Asia/Bangkok"}`,
+			mockFn: func(ms *MockService) {
+				ms.On("UpdateProfile", mock.Anything, userID, input).Return(nil, repository.ErrTimeout)
+			},
+			wantCode:    http.StatusGatewayTimeout,
+			errContains: repository.ErrTimeout.Error(),
+		},
+		{
+			name:       "unexpected error",
+			middleware: authenticated,
+			body:       `{"locale": "th-TH", "timezone": "Asia/Bangkok"}`,
+			mockFn: func(ms *MockService) {
+				ms.On("UpdateProfile", mock.Anything, userID, input).Return(nil, errors.New("connection reset"))
+			},
+			wantCode:     http.StatusInternalServerError,
+			wantAttached: true,
+			errContains:  "failed to update profile",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attached []error
+			api, mockService := setupTest(t, tt.middleware, collectErrors(&attached))
+			if tt.mockFn != nil {
+				tt.mockFn(mockService)
+			}
+
+			res := api.Do(http.MethodPatch, "/api/profile", tt.body).AssertStatus(tt.wantCode).JSON()
+			assert.Equal(t, tt.wantAttached, len(attached) > 0)
+			if tt.errContains != "" {
+				assert.Contains(t, res["error"], tt.errContains)
+			} else {
+				assert.Equal(t, "th-TH", res["locale"])
+				assert.Equal(t, "Asia/Bangkok", res["timezone"])
+			}
+
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthHandler_UpdateProfile_ProblemDetails(t *testing.T) {
+	api, mockService := setupTest(t, authenticatedAs("5b0c8f3e-2a1d-4c6b-9e7f-3d2a1b0c9e8f"))
+
+	w := api.Do(http.MethodPatch, "/api/profile", `{"locale": "??", "timezone": "Nowhere/City"}`,
+		testutil.WithHeader("Accept", apierror.ProblemContentType)).
+		AssertStatus(http.StatusBadRequest)
+
+	var problem apierror.Problem
+	w.Decode(&problem)
+	assert.Equal(t, []apierror.FieldError{
+		{Field: "Locale", Message: `failed the "bcp47_language_tag" rule`},
+		{Field: "Timezone", Message: `failed the "timezone" rule`},
+	}, problem.Errors)
+	mockService.AssertExpectations(t)
+}
+
+func TestAuthHandler_Introspect(t *testing.T) {
+	user := testutil.NewMockUser()
+	inactive := &service.Introspection{Active: false}
+
+	tests := []struct {
+		name        string
+		form        string
+		mockFn      func(*MockService)
+		wantCode    int
+		wantBody    map[string]interface{}
+		errContains string
+	}{
+		{
+			name: "valid token",
+			form: "token=valid",
+			mockFn: func(ms *MockService) {
+				ms.On("Introspect", mock.Anything, "valid").Return(&service.Introspection{
+					Active:    true,
+					Subject:   user.ID.String(),
+					Email:     user.Email,
+					ExpiresAt: 1700003600,
+					IssuedAt:  1700000000,
+				}, nil)
+			},
+			wantCode: http.StatusOK,
+			wantBody: map[string]interface{}{
+				"active": true,
+				"sub":    user.ID.String(),
+				"email":  user.Email,
+				"exp":    float64(1700003600),
+				"iat":    float64(1700000000),
+			},
+		},
+		{
+			name: "expired token",
+			form: "token=expired",
+			mockFn: func(ms *MockService) {
+				ms.On("Introspect", mock.Anything, "expired").Return(inactive, nil)
+			},
+			wantCode: http.StatusOK,
+			wantBody: map[string]interface{}{"active": false},
+		},
+		{
+			name: "revoked token",
+			form: "token=revoked",
+			mockFn: func(ms *MockService) {
+				ms.On("Introspect", mock.Anything, "revoked").Return(inactive, nil)
+			},
+			wantCode: http.StatusOK,
+			wantBody: map[string]interface{}{"active": false},
+		},
+		{
+			name: "malformed token",
+			form: "token=garbage",
+			mockFn: func(ms *MockService) {
+				ms.On("Introspect", mock.Anything, "garbage").Return(inactive, nil)
+			},
+			wantCode: http.StatusOK,
+			wantBody: map[string]interface{}{"active": false},
+		},
+		{
+			name:        "missing token",
+			wantCode:    http.StatusBadRequest,
+			errContains: "token is required",
+		},
+		{
+			name: "auth_service error",
+			form: "token=valid",
+			mockFn: func(ms *MockService) {
+				ms.On("Introspect", mock.Anything, "valid").Return(nil, errors.New("auth_service error"))
+			},
+			wantCode:    http.StatusInternalServerError,
+			errContains: "failed to introspect token",
+		},
+	}
+
+	// formEncoded sends the body as an RFC 7662 introspection request.
+	formEncoded := testutil.WithHeader("Content-Type", "application/x-www-form-urlencoded")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api, mockService := setupTest(t)
+			if tt.mockFn != nil {
+				tt.mockFn(mockService)
+			}
+
+			res := api.Do(http.MethodPost, "/api/token/introspect", tt.form, formEncoded).AssertStatus(tt.wantCode).JSON()
+
+			if tt.wantCode == http.StatusOK {
+				assert.Equal(t, tt.wantBody, res)
+			} else {
+				assert.Contains(t, res["error"], tt.errContains)
+			}
+
+			mockService.AssertExpectations(t)
+		})
+	}
 
+	t.Run("token in the query", func(t *testing.T) {
+		api, mockService := setupTest(t)
+
+		res := api.Do(http.MethodPost, "/api/token/introspect?token=valid", "", formEncoded).AssertStatus(http.StatusBadRequest).JSON()
+
+		assert.Contains(t, res["error"], "token is required")
+		mockService.AssertNotCalled(t, "Introspect", mock.Anything, mock.Anything)
+	})
+}
+
+func TestAuthHandler_ChangePassword(t *testing.T) {
+	const userID = "5b0c8f3e-2a1d-4c6b-9e7f-3d2a1b0c9e8f"
+	input := service.ChangePasswordInput{CurrentPassword: "password", NewPassword: "new-password"}
+	authenticated := authenticatedAs(userID)
+
+	tests := []struct {
+		name         string
+		middleware   gin.HandlerFunc
+		input        service.ChangePasswordInput
+		mockFn       func(*MockService)
+		wantCode     int
+		wantAttached bool
+		errContains  string
+	}{
+		{
+			name:       "password changed",
+			middleware: authenticated,
+			input:      input,
+			mockFn: func(ms *MockService) {
+				ms.On("ChangePassword", mock.Anything, userID, input).Return(nil)
+			},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:        "not authenticated",
+			middleware:  func(c *gin.Context) {},
+			input:       input,
+			wantCode:    http.StatusUnauthorized,
+			errContains: "unauthorized",
+		},
+		{
+			name:        "new password too short",
+			middleware:  authenticated,
+			input:       service.ChangePasswordInput{CurrentPassword: "password", NewPassword: "short"},
+			wantCode:    http.StatusBadRequest,
+			errContains: "Error:Field validation for 'NewPassword' failed",
+		},
+		{
+			name:       "wrong current password",
+			middleware: authenticated,
+			input:      input,
+			mockFn: func(ms *MockService) {
+				ms.On("ChangePassword", mock.Anything, userID, input).Return(service.ErrInvalidCredentials)
+			},
+			wantCode:    http.StatusBadRequest,
+			errContains: service.ErrInvalidCredentials.Error(),
+		},
+		{
+			name:       "breached new password",
+			middleware: authenticated,
+			input:      input,
+			mockFn: func(ms *MockService) {
+				ms.On("ChangePassword", mock.Anything, userID, input).Return(service.ErrPasswordBreached)
+			},
+			wantCode:    http.StatusBadRequest,
+			errContains: service.ErrPasswordBreached.Error(),
+		},
+		{
+			name:       "database timeout",
+			middleware: authenticated,
+			input:      input,
+			mockFn: func(ms *MockService) {
+				ms.On("ChangePassword", mock.Anything, userID, input).Return(repository.ErrTimeout)
+			},
+			wantCode:    http.StatusGatewayTimeout,
+			errContains: repository.ErrTimeout.Error(),
+		},
+		{
+			name:       "unexpected error",
+			middleware: authenticated,
+			input:      input,
+			mockFn: func(ms *MockService) {
+				ms.On("ChangePassword", mock.Anything, userID, input).Return(errors.New("connection reset"))
+			},
+			wantCode:     http.StatusInternalServerError,
+			wantAttached: true,
+			errContains:  "failed to change password",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attached []error
+			api, mockService := setupTest(t, tt.middleware, collectErrors(&attached))
+			if tt.mockFn != nil {
+				tt.mockFn(mockService)
+			}
+
+			res := api.Do(http.MethodPut, "/api/password", tt.input).AssertStatus(tt.wantCode).JSON()
+			assert.Equal(t, tt.wantAttached, len(attached) > 0)
+			if tt.errContains != "" {
 				assert.Contains(t, res["error"], tt.errContains)
+			} else {
+				assert.Equal(t, "password changed", res["message"])
+			}
+
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthHandler_LogoutAll(t *testing.T) {
+	const userID = "5b0c8f3e-2a1d-4c6b-9e7f-3d2a1b0c9e8f"
+	authenticated := authenticatedAs(userID)
+
+	tests := []struct {
+		name         string
+		middleware   gin.HandlerFunc
+		mockFn       func(*MockService)
+		wantCode     int
+		wantAttached bool
+		wantBody     string
+	}{
+		{
+			name:       "signed out",
+			middleware: authenticated,
+			mockFn: func(ms *MockService) {
+				ms.On("LogoutAll", mock.Anything, userID).Return(nil)
+			},
+			wantCode: http.StatusOK,
+			wantBody: `{"message":"signed out of all devices"}`,
+		},
+		{
+			name:       "not authenticated",
+			middleware: func(c *gin.Context) {},
+			wantCode:   http.StatusUnauthorized,
+			wantBody:   `{"error":"unauthorized"}`,
+		},
+		{
+			name:       "invalid user ID",
+			middleware: authenticated,
+			mockFn: func(ms *MockService) {
+				ms.On("LogoutAll", mock.Anything, userID).Return(service.ErrInvalidUserID)
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"error":"` + service.ErrInvalidUserID.Error() + `"}`,
+		},
+		{
+			name:       "database timeout",
+			middleware: authenticated,
+			mockFn: func(ms *MockService) {
+				ms.On("LogoutAll", mock.Anything, userID).Return(repository.ErrTimeout)
+			},
+			wantCode: http.StatusGatewayTimeout,
+			wantBody: `{"error":"` + repository.ErrTimeout.Error() + `"}`,
+		},
+		{
+			name:       "unexpected error",
+			middleware: authenticated,
+			mockFn: func(ms *MockService) {
+				ms.On("LogoutAll", mock.Anything, userID).Return(errors.New("connection reset"))
+			},
+			wantCode:     http.StatusInternalServerError,
+			wantAttached: true,
+			wantBody:     `{"error":"failed to sign out"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attached []error
+			api, mockService := setupTest(t, tt.middleware, collectErrors(&attached))
+			if tt.mockFn != nil {
+				tt.mockFn(mockService)
+			}
+
+			api.Do(http.MethodPost, "/api/logout-all", nil).AssertStatus(tt.wantCode).AssertJSON(tt.wantBody)
+			assert.Equal(t, tt.wantAttached, len(attached) > 0)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthHandler_Reauth(t *testing.T) {
+	const userID = "5b0c8f3e-2a1d-4c6b-9e7f-3d2a1b0c9e8f"
+	granted := []string{service.ScopeProfileRead, service.ScopeProfileWrite}
+	input := service.ReauthInput{Password: "password123"}
+	authenticated := func(c *gin.Context) {
+		authctx.SetUser(c, authctx.Identity{UserID: uuid.MustParse(userID), Scopes: granted})
+	}
+
+	tests := []struct {
+		name         string
+		middleware   gin.HandlerFunc
+		body         string
+		mockFn       func(*MockService)
+		wantCode     int
+		wantAttached bool
+		wantBody     string
+	}{
+		{
+			name:       "correct password",
+			middleware: authenticated,
+			body:       `{"password": "password123"}`,
+			mockFn: func(ms *MockService) {
+				ms.On("Reauth", mock.Anything, userID, granted, input).Return("elevated-token", nil)
+			},
+			wantCode: http.StatusOK,
+			wantBody: `{"token":"elevated-token"}`,
+		},
+		{
+			name:       "not authenticated",
+			middleware: func(c *gin.Context) {},
+			body:       `{"password": "password123"}`,
+			wantCode:   http.StatusUnauthorized,
+			wantBody:   `{"error":"unauthorized"}`,
+		},
+		{
+			name:       "missing password",
+			middleware: authenticated,
+			body:       `{}`,
+			wantCode:   http.StatusBadRequest,
+			wantBody:   `{"error":"Key: 'ReauthInput.Password' Error:Field validation for 'Password' failed on the 'required' tag"}`,
+		},
+		{
+			name:       "wrong password",
+			middleware: authenticated,
+			body:       `{"password": "password123"}`,
+			mockFn: func(ms *MockService) {
+				ms.On("Reauth", mock.Anything, userID, granted, input).Return("", service.ErrInvalidCredentials)
+			},
+			wantCode: http.StatusUnauthorized,
+			wantBody: `{"error":"invalid credentials"}`,
+		},
+		{
+			name:       "database timeout",
+			middleware: authenticated,
+			body:       `{"password": "password123"}`,
+			mockFn: func(ms *MockService) {
+				ms.On("Reauth", mock.Anything, userID, granted, input).Return("", repository.ErrTimeout)
+			},
+			wantCode: http.StatusGatewayTimeout,
+			wantBody: `{"error":"` + repository.ErrTimeout.Error() + `"}`,
+		},
+		{
+			name:       "unexpected error",
+			middleware: authenticated,
+			body:       `{"password": "password123"}`,
+			mockFn: func(ms *MockService) {
+				ms.On("Reauth", mock.Anything, userID, granted, input).Return("", errors.New("connection reset"))
+			},
+			wantCode:     http.StatusInternalServerError,
+			wantAttached: true,
+			wantBody:     `{"error":"failed to re-authenticate"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attached []error
+			api, mockService := setupTest(t, tt.middleware, collectErrors(&attached))
+			if tt.mockFn != nil {
+				tt.mockFn(mockService)
+			}
+
+			api.Do(http.MethodPost, "/api/reauth", tt.body).AssertStatus(tt.wantCode).AssertJSON(tt.wantBody)
+			assert.Equal(t, tt.wantAttached, len(attached) > 0)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthHandler_IssueToken(t *testing.T) {
+	const userID = "5b0c8f3e-2a1d-4c6b-9e7f-3d2a1b0c9e8f"
+	granted := []string{service.ScopeProfileRead, service.ScopeProfileWrite}
+	input := service.ScopedTokenInput{Scopes: []string{service.ScopeProfileRead}}
+	authenticated := func(c *gin.Context) {
+		authctx.SetUser(c, authctx.Identity{UserID: uuid.MustParse(userID), Scopes: granted})
+	}
+
+	tests := []struct {
+		name         string
+		middleware   gin.HandlerFunc
+		body         string
+		mockFn       func(*MockService)
+		wantCode     int
+		wantAttached bool
+		wantBody     string
+	}{
+		{
+			name:       "issued",
+			middleware: authenticated,
+			body:       `{"scopes": ["profile:read"]}`,
+			mockFn: func(ms *MockService) {
+				ms.On("IssueScopedToken", mock.Anything, userID, granted, input).Return("scoped-token", nil)
+			},
+			wantCode: http.StatusCreated,
+			wantBody: `{"token":"scoped-token"}`,
+		},
+		{
+			name:       "not authenticated",
+			middleware: func(c *gin.Context) {},
+			body:       `{"scopes": ["profile:read"]}`,
+			wantCode:   http.StatusUnauthorized,
+			wantBody:   `{"error":"unauthorized"}`,
+		},
+		{
+			name:       "missing scopes",
+			middleware: authenticated,
+			body:       `{}`,
+			wantCode:   http.StatusBadRequest,
+			wantBody:   `{"error":"Key: 'ScopedTokenInput.Scopes' Error:Field validation for 'Scopes' failed on the 'required' tag"}`,
+		},
+		{
+			name:       "unknown scope",
+			middleware: authenticated,
+			body:       `{"scopes": ["profile:read"]}`,
+			mockFn: func(ms *MockService) {
+				ms.On("IssueScopedToken", mock.Anything, userID, granted, input).Return("", fmt.Errorf("%w: billing:read", service.ErrUnknownScope))
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"error":"unknown scope: billing:read"}`,
+		},
+		{
+			name:       "scope not granted",
+			middleware: authenticated,
+			body:       `{"scopes": ["profile:read"]}`,
+			mockFn: func(ms *MockService) {
+				ms.On("IssueScopedToken", mock.Anything, userID, granted, input).Return("", fmt.Errorf("%w: users:admin", service.ErrScopeNotGranted))
+			},
+			wantCode: http.StatusForbidden,
+			wantBody: `{"error":"scope not granted: users:admin","code":"INSUFFICIENT_SCOPE"}`,
+		},
+		{
+			name:       "database timeout",
+			middleware: authenticated,
+			body:       `{"scopes": ["profile:read"]}`,
+			mockFn: func(ms *MockService) {
+				ms.On("IssueScopedToken", mock.Anything, userID, granted, input).Return("", repository.ErrTimeout)
+			},
+			wantCode: http.StatusGatewayTimeout,
+			wantBody: `{"error":"` + repository.ErrTimeout.Error() + `"}`,
+		},
+		{
+			name:       "unexpected error",
+			middleware: authenticated,
+			body:       `{"scopes": ["profile:read"]}`,
+			mockFn: func(ms *MockService) {
+				ms.On("IssueScopedToken", mock.Anything, userID, granted, input).Return("", errors.New("connection reset"))
+			},
+			wantCode:     http.StatusInternalServerError,
+			wantAttached: true,
+			wantBody:     `{"error":"failed to issue token"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attached []error
+			api, mockService := setupTest(t, tt.middleware, collectErrors(&attached))
+			if tt.mockFn != nil {
+				tt.mockFn(mockService)
+			}
+
+			api.Do(http.MethodPost, "/api/tokens", tt.body).AssertStatus(tt.wantCode).AssertJSON(tt.wantBody)
+			assert.Equal(t, tt.wantAttached, len(attached) > 0)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthHandler_AcceptTOS(t *testing.T) {
+	const userID = "5b0c8f3e-2a1d-4c6b-9e7f-3d2a1b0c9e8f"
+	authenticated := authenticatedAs(userID)
+	acceptedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		middleware   gin.HandlerFunc
+		mockFn       func(*MockService)
+		wantCode     int
+		wantAttached bool
+		wantBody     string
+	}{
+		{
+			name:       "accepted",
+			middleware: authenticated,
+			mockFn: func(ms *MockService) {
+				ms.On("AcceptTOS", mock.Anything, userID).Return(&service.TOSAcceptance{Version: 2, AcceptedAt: acceptedAt}, nil)
+			},
+			wantCode: http.StatusOK,
+			wantBody: `{"version":2,"accepted_at":"2024-03-01T12:00:00Z"}`,
+		},
+		{
+			name:       "not authenticated",
+			middleware: func(c *gin.Context) {},
+			wantCode:   http.StatusUnauthorized,
+			wantBody:   `{"error":"unauthorized"}`,
+		},
+		{
+			name:       "invalid user ID",
+			middleware: authenticated,
+			mockFn: func(ms *MockService) {
+				ms.On("AcceptTOS", mock.Anything, userID).Return(nil, service.ErrInvalidUserID)
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"error":"` + service.ErrInvalidUserID.Error() + `"}`,
+		},
+		{
+			name:       "database timeout",
+			middleware: authenticated,
+			mockFn: func(ms *MockService) {
+				ms.On("AcceptTOS", mock.Anything, userID).Return(nil, repository.ErrTimeout)
+			},
+			wantCode: http.StatusGatewayTimeout,
+			wantBody: `{"error":"` + repository.ErrTimeout.Error() + `"}`,
+		},
+		{
+			name:       "unexpected error",
+			middleware: authenticated,
+			mockFn: func(ms *MockService) {
+				ms.On("AcceptTOS", mock.Anything, userID).Return(nil, errors.New("connection reset"))
+			},
+			wantCode:     http.StatusInternalServerError,
+			wantAttached: true,
+			wantBody:     `{"error":"failed to accept terms of service"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attached []error
+			api, mockService := setupTest(t, tt.middleware, collectErrors(&attached))
+			if tt.mockFn != nil {
+				tt.mockFn(mockService)
 			}
 
+			api.Do(http.MethodPost, "/api/tos/accept", nil).AssertStatus(tt.wantCode).AssertJSON(tt.wantBody)
+			assert.Equal(t, tt.wantAttached, len(attached) > 0)
 			mockService.AssertExpectations(t)
 		})
 	}
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/PakornBank/learn-go/internal/apierror"
+	"github.com/PakornBank/learn-go/internal/authctx"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// avatarFormField is the multipart form field carrying the uploaded image.
+const avatarFormField = "avatar"
+
+// maxAvatarRequestBytes bounds the whole upload request, leaving room for the
+// multipart framing around an image of the maximum size.
+const maxAvatarRequestBytes = service.MaxAvatarBytes + 64<<10
+
+// AvatarService defines the methods that an avatar handler must implement.
+type AvatarService interface {
+	// Upload replaces a user's avatar with the given image and returns the updated user.
+	// ctx: The context for the request.
+	// userID: The ID of the user whose avatar is replaced.
+	// data: The uploaded PNG or JPEG image.
+	Upload(ctx context.Context, userID string, data []byte) (*model.User, error)
+}
+
+// AvatarHandler handles HTTP requests for the authenticated user's avatar.
+type AvatarHandler struct {
+	service AvatarService
+}
+
+// NewAvatarHandler creates a new instance of AvatarHandler with the provided service.
+func NewAvatarHandler(s AvatarService) *AvatarHandler {
+	return &AvatarHandler{service: s}
+}
+
+// UploadAvatar handles the request to replace the authenticated user's avatar.
+// It expects a multipart form with the image in the "avatar" field and
+// responds with the updated user. An image over 2MB results in a 413 status
+// code, and anything other than a PNG or JPEG in a 415.
+func (h *AvatarHandler) UploadAvatar(c *gin.Context) {
+	identity, ok := authctx.User(c)
+	if !ok {
+		apierror.Respond(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxAvatarRequestBytes)
+	header, err := c.FormFile(avatarFormField)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			apierror.Respond(c, http.StatusRequestEntityTooLarge, service.ErrAvatarTooLarge.Error())
+			return
+		}
+		apierror.Respond(c, http.StatusBadRequest, "avatar file is required")
+		return
+	}
+	if header.Size > service.MaxAvatarBytes {
+		apierror.Respond(c, http.StatusRequestEntityTooLarge, service.ErrAvatarTooLarge.Error())
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.Error(err)
+		apierror.Respond(c, http.StatusInternalServerError, "failed to read avatar")
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, service.MaxAvatarBytes+1))
+	if err != nil {
+		c.Error(err)
+		apierror.Respond(c, http.StatusInternalServerError, "failed to read avatar")
+		return
+	}
+
+	user, err := h.service.Upload(c.Request.Context(), identity.UserID.String(), data)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrAvatarTooLarge):
+			apierror.Respond(c, http.StatusRequestEntityTooLarge, err.Error())
+		case errors.Is(err, service.ErrUnsupportedImage):
+			apierror.Respond(c, http.StatusUnsupportedMediaType, err.Error())
+		case errors.Is(err, service.ErrInvalidUserID):
+			apierror.Respond(c, http.StatusBadRequest, err.Error())
+		case errors.Is(err, repository.ErrTimeout):
+			apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+		default:
+			c.Error(err)
+			apierror.Respond(c, http.StatusInternalServerError, "failed to upload avatar")
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, FromModel(user))
+}
@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockAvatarService struct {
+	mock.Mock
+}
+
+func (ms *MockAvatarService) Upload(ctx context.Context, userID string, data []byte) (*model.User, error) {
+	args := ms.Called(ctx, userID, data)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+// avatarForm returns a multipart body with data in the given form field.
+func avatarForm(t *testing.T, field string, data []byte) (*bytes.Buffer, string) {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile(field, "avatar.png")
+	require.NoError(t, err)
+	_, err = part.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	return &body, writer.FormDataContentType()
+}
+
+func TestNewAvatarHandler(t *testing.T) {
+	service := new(MockAvatarService)
+	handler := NewAvatarHandler(service)
+
+	assert.NotNil(t, handler)
+	assert.Equal(t, service, handler.service)
+}
+
+func TestAvatarHandler_UploadAvatar(t *testing.T) {
+	const userID = "5b0c8f3e-2a1d-4c6b-9e7f-3d2a1b0c9e8f"
+	authenticated := authenticatedAs(userID)
+	image := []byte("\x89PNG\r\n\x1a\nimage")
+	user := testutil.NewMockUser()
+	avatarURL := "/avatars/new.png"
+	user.AvatarURL = &avatarURL
+
+	tests := []struct {
+		name         string
+		middleware   gin.HandlerFunc
+		field        string
+		data         []byte
+		mockFn       func(*MockAvatarService)
+		wantCode     int
+		wantAttached bool
+		wantBody     string
+	}{
+		{
+			name:       "uploaded",
+			middleware: authenticated,
+			field:      avatarFormField,
+			data:       image,
+			mockFn: func(ms *MockAvatarService) {
+				ms.On("Upload", mock.Anything, userID, image).Return(&user, nil)
+			},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:       "not authenticated",
+			middleware: func(c *gin.Context) {},
+			field:      avatarFormField,
+			data:       image,
+			wantCode:   http.StatusUnauthorized,
+			wantBody:   `{"error":"unauthorized"}`,
+		},
+		{
+			name:       "missing file",
+			middleware: authenticated,
+			field:      "picture",
+			data:       image,
+			wantCode:   http.StatusBadRequest,
+			wantBody:   `{"error":"avatar file is required"}`,
+		},
+		{
+			name:       "file too large",
+			middleware: authenticated,
+			field:      avatarFormField,
+			data:       make([]byte, service.MaxAvatarBytes+1),
+			wantCode:   http.StatusRequestEntityTooLarge,
+			wantBody:   `{"error":"` + service.ErrAvatarTooLarge.Error() + `"}`,
+		},
+		{
+			name:       "request too large",
+			middleware: authenticated,
+			field:      avatarFormField,
+			data:       make([]byte, maxAvatarRequestBytes+1),
+			wantCode:   http.StatusRequestEntityTooLarge,
+			wantBody:   `{"error":"` + service.ErrAvatarTooLarge.Error() + `"}`,
+		},
+		{
+			name:       "unsupported image",
+			middleware: authenticated,
+			field:      avatarFormField,
+			data:       []byte("GIF89a"),
+			mockFn: func(ms *MockAvatarService) {
+				ms.On("Upload", mock.Anything, userID, []byte("GIF89a")).Return(nil, service.ErrUnsupportedImage)
+			},
+			wantCode: http.StatusUnsupportedMediaType,
+			wantBody: `{"error":"` + service.ErrUnsupportedImage.Error() + `"}`,
+		},
+		{
+			name:       "database timeout",
+			middleware: authenticated,
+			field:      avatarFormField,
+			data:       image,
+			mockFn: func(ms *MockAvatarService) {
+				ms.On("Upload", mock.Anything, userID, image).Return(nil, repository.ErrTimeout)
+			},
+			wantCode: http.StatusGatewayTimeout,
+			wantBody: `{"error":"` + repository.ErrTimeout.Error() + `"}`,
+		},
+		{
+			name:       "storage error",
+			middleware: authenticated,
+			field:      avatarFormField,
+			data:       image,
+			mockFn: func(ms *MockAvatarService) {
+				ms.On("Upload", mock.Anything, userID, image).Return(nil, errors.New("disk full"))
+			},
+			wantCode:     http.StatusInternalServerError,
+			wantAttached: true,
+			wantBody:     `{"error":"failed to upload avatar"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			mockService := new(MockAvatarService)
+			if tt.mockFn != nil {
+				tt.mockFn(mockService)
+			}
+			var attached []error
+			router := gin.New()
+			router.POST("/api/auth/profile/avatar", collectErrors(&attached), tt.middleware, NewAvatarHandler(mockService).UploadAvatar)
+
+			body, contentType := avatarForm(t, tt.field, tt.data)
+			req := httptest.NewRequest(http.MethodPost, "/api/auth/profile/avatar", body)
+			req.Header.Set("Content-Type", contentType)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Equal(t, tt.wantAttached, len(attached) > 0)
+			if tt.wantBody != "" {
+				assert.JSONEq(t, tt.wantBody, w.Body.String())
+			} else {
+				assert.Contains(t, w.Body.String(), `"avatar_url":"/avatars/new.png"`)
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/PakornBank/learn-go/internal/apierror"
+	"github.com/PakornBank/learn-go/internal/mailer"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// EmailPreviewer defines the methods that a dev email handler must implement
+// to render emails.
+type EmailPreviewer interface {
+	// Preview renders an email template with placeholder tokens.
+	// name: The name of the template to render.
+	// data: The recipient the email is addressed to.
+	Preview(name string, data mailer.PreviewData) (*mailer.Message, error)
+}
+
+// PreviewUserFinder defines the methods that a dev email handler must
+// implement to address previews to real users.
+type PreviewUserFinder interface {
+	// FindByID returns the user with the given ID.
+	// ctx: The context for the request.
+	// id: The ID of the user to find.
+	FindByID(ctx context.Context, id string) (*model.User, error)
+}
+
+// DevEmailHandler lets developers preview the application's emails. It only
+// serves requests while gin is not in release mode, whatever routes it is
+// registered on.
+type DevEmailHandler struct {
+	emails EmailPreviewer
+	users  PreviewUserFinder
+}
+
+// NewDevEmailHandler creates a new instance of DevEmailHandler rendering
+// emails with emails, addressed to users found through users.
+func NewDevEmailHandler(emails EmailPreviewer, users PreviewUserFinder) *DevEmailHandler {
+	return &DevEmailHandler{emails: emails, users: users}
+}
+
+// PreviewEmail handles the request for the email template named by the
+// "template" path parameter. It renders the template for the user of the
+// optional "user_id" query parameter, or for sample data without one, and
+// responds with its HTML part, or with its plaintext part when "format" is
+// "text". An unknown template results in a 404 status code listing the
+// available ones under "templates", and an unknown user in a 404. In release
+// mode it responds 404 to every request.
+func (h *DevEmailHandler) PreviewEmail(c *gin.Context) {
+	if gin.Mode() == gin.ReleaseMode {
+		apierror.Respond(c, http.StatusNotFound, "not found")
+		return
+	}
+
+	format := c.DefaultQuery("format", "html")
+	if format != "html" && format != "text" {
+		apierror.Respond(c, http.StatusBadRequest, "format must be html or text")
+		return
+	}
+
+	data := mailer.SamplePreviewData
+	if id := c.Query("user_id"); id != "" {
+		user, err := h.users.FindByID(c.Request.Context(), id)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrNotFound):
+				apierror.Respond(c, http.StatusNotFound, "user not found")
+			case errors.Is(err, repository.ErrTimeout):
+				apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+			default:
+				c.Error(err)
+				apierror.Respond(c, http.StatusInternalServerError, "failed to find user")
+			}
+			return
+		}
+		data = mailer.PreviewData{Name: user.FullName, Email: user.Email}
+	}
+
+	msg, err := h.emails.Preview(c.Param("template"), data)
+	if err != nil {
+		if errors.Is(err, mailer.ErrUnknownTemplate) {
+			apierror.RespondExtensions(c, http.StatusNotFound, "", err.Error(), map[string]any{"templates": mailer.PreviewNames()})
+			return
+		}
+		c.Error(err)
+		apierror.Respond(c, http.StatusInternalServerError, "failed to render email")
+		return
+	}
+
+	if format == "text" {
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(msg.Text))
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(msg.HTML))
+}
@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PakornBank/learn-go/internal/apierror"
+	"github.com/PakornBank/learn-go/internal/mailer"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockPreviewUserFinder struct {
+	mock.Mock
+}
+
+func (m *MockPreviewUserFinder) FindByID(ctx context.Context, id string) (*model.User, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+func setupDevEmailTest() (*gin.Engine, *MockPreviewUserFinder) {
+	gin.SetMode(gin.TestMode)
+
+	users := new(MockPreviewUserFinder)
+	handler := NewDevEmailHandler(mailer.NewTemplates("https://app.example.com"), users)
+
+	router := gin.New()
+	router.GET("/api/dev/emails/:template", handler.PreviewEmail)
+
+	return router, users
+}
+
+func TestNewDevEmailHandler(t *testing.T) {
+	emails := mailer.NewTemplates("https://app.example.com")
+	users := new(MockPreviewUserFinder)
+	handler := NewDevEmailHandler(emails, users)
+
+	assert.NotNil(t, handler)
+	assert.Equal(t, emails, handler.emails)
+	assert.Equal(t, users, handler.users)
+}
+
+func TestDevEmailHandler_PreviewEmail(t *testing.T) {
+	tests := []struct {
+		name         string
+		path         string
+		accept       string
+		mockFn       func(*MockPreviewUserFinder)
+		expectedCode int
+		checkBody    func(*testing.T, *httptest.ResponseRecorder)
+	}{
+		{
+			name:         "sample data as html",
+			path:         "/api/dev/emails/verification",
+			expectedCode: http.StatusOK,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+				assert.Contains(t, w.Body.String(), "Hi "+mailer.SamplePreviewData.Name+",")
+				assert.Contains(t, w.Body.String(), `href="https://app.example.com/verify-email?token=`)
+			},
+		},
+		{
+			name:         "sample data as text",
+			path:         "/api/dev/emails/verification?format=text",
+			expectedCode: http.StatusOK,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+				assert.Contains(t, w.Body.String(), "https://app.example.com/verify-email?token=")
+				assert.NotContains(t, w.Body.String(), "<html")
+			},
+		},
+		{
+			name: "real user",
+			path: "/api/dev/emails/recovery_requested?user_id=user-1",
+			mockFn: func(m *MockPreviewUserFinder) {
+				m.On("FindByID", mock.Anything, "user-1").
+					Return(&model.User{FullName: "John Smith", Email: "john@example.com"}, nil)
+			},
+			expectedCode: http.StatusOK,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Contains(t, w.Body.String(), "john@example.com")
+			},
+		},
+		{
+			name: "unknown user",
+			path: "/api/dev/emails/verification?user_id=missing",
+			mockFn: func(m *MockPreviewUserFinder) {
+				m.On("FindByID", mock.Anything, "missing").Return(nil, repository.ErrNotFound)
+			},
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name: "user lookup fails",
+			path: "/api/dev/emails/verification?user_id=user-1",
+			mockFn: func(m *MockPreviewUserFinder) {
+				m.On("FindByID", mock.Anything, "user-1").Return(nil, errors.New("db down"))
+			},
+			expectedCode: http.StatusInternalServerError,
+		},
+		{
+			name:         "unknown template lists the available ones",
+			path:         "/api/dev/emails/nope",
+			expectedCode: http.StatusNotFound,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var body struct {
+					Error     string   `json:"error"`
+					Templates []string `json:"templates"`
+				}
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+				assert.Equal(t, mailer.ErrUnknownTemplate.Error(), body.Error)
+				assert.Equal(t, mailer.PreviewNames(), body.Templates)
+			},
+		},
+		{
+			name:         "unknown template as problem details",
+			path:         "/api/dev/emails/nope",
+			accept:       apierror.ProblemContentType,
+			expectedCode: http.StatusNotFound,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Equal(t, apierror.ProblemContentType, w.Header().Get("Content-Type"))
+				var body struct {
+					Detail    string   `json:"detail"`
+					Templates []string `json:"templates"`
+				}
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+				assert.Equal(t, mailer.ErrUnknownTemplate.Error(), body.Detail)
+				assert.Equal(t, mailer.PreviewNames(), body.Templates)
+			},
+		},
+		{
+			name:         "invalid format",
+			path:         "/api/dev/emails/verification?format=pdf",
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, users := setupDevEmailTest()
+			if tt.mockFn != nil {
+				tt.mockFn(users)
+			}
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			if tt.checkBody != nil {
+				tt.checkBody(t, w)
+			}
+			users.AssertExpectations(t)
+		})
+	}
+}
+
+func TestDevEmailHandler_PreviewEmailInReleaseMode(t *testing.T) {
+	router, users := setupDevEmailTest()
+	gin.SetMode(gin.ReleaseMode)
+	defer gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/api/dev/emails/verification", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	users.AssertExpectations(t)
+}
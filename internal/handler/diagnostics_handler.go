@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// DiagnosticsReporter defines the methods that a diagnostics handler must
+// implement. It is satisfied by *service.DiagnosticsService.
+type DiagnosticsReporter interface {
+	// Report returns the diagnostics of this replica.
+	Report(ctx context.Context) service.DiagnosticsReport
+}
+
+// DiagnosticsHandler handles HTTP requests for the self-diagnostics of the server.
+type DiagnosticsHandler struct {
+	reporter DiagnosticsReporter
+}
+
+// NewDiagnosticsHandler creates a new instance of DiagnosticsHandler with the provided reporter.
+func NewDiagnosticsHandler(reporter DiagnosticsReporter) *DiagnosticsHandler {
+	return &DiagnosticsHandler{reporter: reporter}
+}
+
+// GetDiagnostics handles the request for the diagnostics of this replica:
+// its uptime, Go version, goroutine count and heap in use, and the state of
+// each subsystem by name. A subsystem that failed to report has the status
+// "error" and the error instead of its details; the response is still 200.
+func (h *DiagnosticsHandler) GetDiagnostics(c *gin.Context) {
+	c.JSON(http.StatusOK, h.reporter.Report(c.Request.Context()))
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeDiagnosticsReporter service.DiagnosticsReport
+
+func (r fakeDiagnosticsReporter) Report(context.Context) service.DiagnosticsReport {
+	return service.DiagnosticsReport(r)
+}
+
+func TestNewDiagnosticsHandler(t *testing.T) {
+	reporter := fakeDiagnosticsReporter{}
+	handler := NewDiagnosticsHandler(reporter)
+
+	assert.NotNil(t, handler)
+	assert.Equal(t, reporter, handler.reporter)
+}
+
+func TestDiagnosticsHandler_GetDiagnostics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/admin/diagnostics", NewDiagnosticsHandler(fakeDiagnosticsReporter{
+		StartedAt:      time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+		UptimeSeconds:  5400,
+		GoVersion:      "go1.21.5",
+		Goroutines:     42,
+		HeapInUseBytes: 8 << 20,
+		Subsystems: map[string]service.SubsystemDiagnostics{
+			"database": {Status: service.DiagnosticOK, Details: map[string]int{"open_connections": 3, "max_open_connections": 10}},
+			"outbox":   {Status: service.DiagnosticError, Error: "no poll yet"},
+		},
+	}).GetDiagnostics)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/diagnostics", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"started_at": "2024-01-01T12:00:00Z",
+		"uptime_seconds": 5400,
+		"go_version": "go1.21.5",
+		"goroutines": 42,
+		"heap_in_use_bytes": 8388608,
+		"subsystems": {
+			"database": {"status": "ok", "details": {"open_connections": 3, "max_open_connections": 10}},
+			"outbox": {"status": "error", "error": "no poll yet"}
+		}
+	}`, w.Body.String())
+}
@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/PakornBank/learn-go/internal/apierror"
+	"github.com/gin-gonic/gin"
+)
+
+// EmailBlocklist defines the methods that an email blocklist handler must implement.
+type EmailBlocklist interface {
+	// Reload re-reads the blocked domains, keeping the current ones on error.
+	Reload() error
+
+	// Len returns the number of blocked domains.
+	Len() int
+}
+
+// EmailBlocklistHandler handles HTTP requests for managing the disposable email blocklist.
+type EmailBlocklistHandler struct {
+	blocklist EmailBlocklist
+}
+
+// NewEmailBlocklistHandler creates a new instance of EmailBlocklistHandler with the provided blocklist.
+func NewEmailBlocklistHandler(b EmailBlocklist) *EmailBlocklistHandler {
+	return &EmailBlocklistHandler{blocklist: b}
+}
+
+// Reload handles the request to reload the blocklist from disk, so domains
+// added to the extra list take effect without a redeploy. It responds with the
+// number of blocked domains. If the list cannot be read, it responds with a 500
+// status code and the previous domains stay in effect.
+func (h *EmailBlocklistHandler) Reload(c *gin.Context) {
+	if err := h.blocklist.Reload(); err != nil {
+		c.Error(err)
+		apierror.Respond(c, http.StatusInternalServerError, "failed to reload email blocklist")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"domains": h.blocklist.Len()})
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeEmailBlocklist struct {
+	domains int
+	err     error
+}
+
+func (f *fakeEmailBlocklist) Reload() error { return f.err }
+
+func (f *fakeEmailBlocklist) Len() int { return f.domains }
+
+func TestNewEmailBlocklistHandler(t *testing.T) {
+	blocklist := &fakeEmailBlocklist{}
+	handler := NewEmailBlocklistHandler(blocklist)
+
+	assert.NotNil(t, handler)
+	assert.Equal(t, blocklist, handler.blocklist)
+}
+
+func TestEmailBlocklistHandler_Reload(t *testing.T) {
+	tests := []struct {
+		name         string
+		blocklist    *fakeEmailBlocklist
+		wantCode     int
+		wantAttached bool
+		wantBody     map[string]interface{}
+	}{
+		{
+			name:      "reloaded",
+			blocklist: &fakeEmailBlocklist{domains: 42},
+			wantCode:  http.StatusOK,
+			wantBody:  map[string]interface{}{"domains": float64(42)},
+		},
+		{
+			name:         "read failure",
+			blocklist:    &fakeEmailBlocklist{domains: 42, err: errors.New("no such file")},
+			wantCode:     http.StatusInternalServerError,
+			wantAttached: true,
+			wantBody:     map[string]interface{}{"error": "failed to reload email blocklist"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			var attached []error
+			router := gin.New()
+			router.POST("/api/admin/email-blocklist/reload", collectErrors(&attached), NewEmailBlocklistHandler(tt.blocklist).Reload)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/admin/email-blocklist/reload", nil))
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Equal(t, tt.wantAttached, len(attached) > 0)
+			var res map[string]interface{}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+			assert.Equal(t, tt.wantBody, res)
+		})
+	}
+}
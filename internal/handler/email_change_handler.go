@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/apierror"
+	"github.com/PakornBank/learn-go/internal/authctx"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// EmailChangeService defines the methods that an email change handler must implement.
+type EmailChangeService interface {
+	// Request starts changing a user's email and mails a confirmation link to the new address.
+	// ctx: The context for the request.
+	// userID: The ID of the user changing their email.
+	// input: The new email and the user's current password.
+	Request(ctx context.Context, userID string, input service.EmailChangeInput) error
+
+	// Confirm applies a requested email change and returns the updated user.
+	// ctx: The context for the request.
+	// input: The confirmation token from the emailed link.
+	Confirm(ctx context.Context, input service.ConfirmEmailChangeInput) (*model.User, error)
+}
+
+// EmailChangeHandler handles HTTP requests for changing a user's email address.
+type EmailChangeHandler struct {
+	service EmailChangeService
+	audit   AuditRecorder
+}
+
+// NewEmailChangeHandler creates a new instance of EmailChangeHandler with the
+// provided service, recording confirmed changes to audit.
+func NewEmailChangeHandler(s EmailChangeService, audit AuditRecorder) *EmailChangeHandler {
+	return &EmailChangeHandler{service: s, audit: audit}
+}
+
+// RequestChange handles the authenticated user's request to change their email.
+// It expects a JSON payload with the new email and the current password, and
+// responds with a 202 status code once the confirmation link has been sent to
+// the new address. The user keeps their current email until the change is confirmed.
+// A wrong password or an unchanged email results in a 400 status code, an email
+// that belongs to another user in a 409, and a database timeout in a 504.
+func (h *EmailChangeHandler) RequestChange(c *gin.Context) {
+	identity, ok := authctx.User(c)
+	if !ok {
+		apierror.Respond(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var input service.EmailChangeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.RespondInvalid(c, err)
+		return
+	}
+
+	if err := h.service.Request(c.Request.Context(), identity.UserID.String(), input); err != nil {
+		h.respondError(c, err, "failed to request email change")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "confirmation email sent"})
+}
+
+// ConfirmChange handles the confirmation of an email change. It expects a JSON
+// payload with the token from the confirmation link and responds with the
+// updated user. An unknown or expired token results in a 400 status code, and
+// an email taken by another user since the change was requested in a 409.
+// The confirmed change is recorded in the audit log as made by the user, since
+// the link is followed without signing in.
+func (h *EmailChangeHandler) ConfirmChange(c *gin.Context) {
+	start := time.Now()
+
+	var input service.ConfirmEmailChangeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.RespondInvalid(c, err)
+		return
+	}
+
+	user, err := h.service.Confirm(c.Request.Context(), input)
+	if err != nil {
+		h.respondError(c, err, "failed to confirm email change")
+		return
+	}
+
+	c.JSON(http.StatusOK, FromModel(user))
+	h.audit.Record(&model.AuditEntry{
+		ActorID:   user.ID,
+		Method:    c.Request.Method,
+		Path:      c.Request.URL.Path,
+		Status:    c.Writer.Status(),
+		IPAddress: c.ClientIP(),
+		LatencyMS: time.Since(start).Milliseconds(),
+		CreatedAt: start,
+	})
+}
+
+// respondError writes the response for an error returned by the service,
+// using fallback as the message of unexpected errors.
+func (h *EmailChangeHandler) respondError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, service.ErrInvalidCredentials):
+		apierror.Respond(c, http.StatusBadRequest, service.ErrInvalidCredentials.Error())
+	case errors.Is(err, service.ErrInvalidEmailChangeToken):
+		apierror.Respond(c, http.StatusBadRequest, service.ErrInvalidEmailChangeToken.Error())
+	case errors.Is(err, service.ErrSameEmail):
+		apierror.Respond(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, service.ErrEmailTaken):
+		apierror.Respond(c, http.StatusConflict, service.ErrEmailTaken.Error())
+	case errors.Is(err, repository.ErrTimeout):
+		apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+	default:
+		c.Error(err)
+		apierror.Respond(c, http.StatusInternalServerError, fallback)
+	}
+}
@@ -0,0 +1,253 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockEmailChangeService struct {
+	mock.Mock
+}
+
+func (ms *MockEmailChangeService) Request(ctx context.Context, userID string, input service.EmailChangeInput) error {
+	args := ms.Called(ctx, userID, input)
+	return args.Error(0)
+}
+
+func (ms *MockEmailChangeService) Confirm(ctx context.Context, input service.ConfirmEmailChangeInput) (*model.User, error) {
+	args := ms.Called(ctx, input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+func setupEmailChangeTest(middleware gin.HandlerFunc) (*gin.Engine, *MockEmailChangeService, *recordedAudit) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockEmailChangeService)
+	audit := &recordedAudit{}
+	handler := NewEmailChangeHandler(mockService, audit)
+
+	router := gin.New()
+	router.POST("/api/auth/email-change", middleware, handler.RequestChange)
+	router.POST("/api/auth/email-change/confirm", handler.ConfirmChange)
+
+	return router, mockService, audit
+}
+
+func TestNewEmailChangeHandler(t *testing.T) {
+	service := new(MockEmailChangeService)
+	handler := NewEmailChangeHandler(service, &recordedAudit{})
+
+	assert.NotNil(t, handler)
+	assert.Equal(t, service, handler.service)
+}
+
+func TestEmailChangeHandler_RequestChange(t *testing.T) {
+	const userID = "5b0c8f3e-2a1d-4c6b-9e7f-3d2a1b0c9e8f"
+	input := service.EmailChangeInput{NewEmail: "new@example.com", Password: "password"}
+	authenticated := authenticatedAs(userID)
+
+	tests := []struct {
+		name        string
+		middleware  gin.HandlerFunc
+		body        interface{}
+		mockFn      func(*MockEmailChangeService)
+		wantCode    int
+		errContains string
+	}{
+		{
+			name:       "change requested",
+			middleware: authenticated,
+			body:       input,
+			mockFn: func(ms *MockEmailChangeService) {
+				ms.On("Request", mock.Anything, userID, input).Return(nil)
+			},
+			wantCode: http.StatusAccepted,
+		},
+		{
+			name:        "not authenticated",
+			middleware:  func(c *gin.Context) {},
+			body:        input,
+			wantCode:    http.StatusUnauthorized,
+			errContains: "unauthorized",
+		},
+		{
+			name:        "invalid email",
+			middleware:  authenticated,
+			body:        service.EmailChangeInput{NewEmail: "not-an-email", Password: "password"},
+			wantCode:    http.StatusBadRequest,
+			errContains: "Error:Field validation for 'NewEmail' failed",
+		},
+		{
+			name:       "wrong password",
+			middleware: authenticated,
+			body:       input,
+			mockFn: func(ms *MockEmailChangeService) {
+				ms.On("Request", mock.Anything, userID, input).Return(service.ErrInvalidCredentials)
+			},
+			wantCode:    http.StatusBadRequest,
+			errContains: service.ErrInvalidCredentials.Error(),
+		},
+		{
+			name:       "email taken",
+			middleware: authenticated,
+			body:       input,
+			mockFn: func(ms *MockEmailChangeService) {
+				ms.On("Request", mock.Anything, userID, input).Return(service.ErrEmailTaken)
+			},
+			wantCode:    http.StatusConflict,
+			errContains: service.ErrEmailTaken.Error(),
+		},
+		{
+			name:       "database timeout",
+			middleware: authenticated,
+			body:       input,
+			mockFn: func(ms *MockEmailChangeService) {
+				ms.On("Request", mock.Anything, userID, input).Return(repository.ErrTimeout)
+			},
+			wantCode:    http.StatusGatewayTimeout,
+			errContains: repository.ErrTimeout.Error(),
+		},
+		{
+			name:       "mail failure",
+			middleware: authenticated,
+			body:       input,
+			mockFn: func(ms *MockEmailChangeService) {
+				ms.On("Request", mock.Anything, userID, input).Return(errors.New("smtp unavailable"))
+			},
+			wantCode:    http.StatusInternalServerError,
+			errContains: "failed to request email change",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mockService, _ := setupEmailChangeTest(tt.middleware)
+			if tt.mockFn != nil {
+				tt.mockFn(mockService)
+			}
+
+			body, _ := json.Marshal(tt.body)
+			req := httptest.NewRequest(http.MethodPost, "/api/auth/email-change", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+
+			var res map[string]interface{}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+			if tt.errContains != "" {
+				assert.Contains(t, res["error"], tt.errContains)
+			}
+
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestEmailChangeHandler_ConfirmChange(t *testing.T) {
+	user := testutil.NewMockUser(testutil.WithEmail("new@example.com"))
+	input := service.ConfirmEmailChangeInput{Token: "confirmation-token"}
+
+	tests := []struct {
+		name        string
+		body        interface{}
+		mockFn      func(*MockEmailChangeService)
+		wantCode    int
+		errContains string
+	}{
+		{
+			name: "change confirmed",
+			body: input,
+			mockFn: func(ms *MockEmailChangeService) {
+				ms.On("Confirm", mock.Anything, input).Return(&user, nil)
+			},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:        "missing token",
+			body:        service.ConfirmEmailChangeInput{},
+			wantCode:    http.StatusBadRequest,
+			errContains: "Error:Field validation for 'Token' failed",
+		},
+		{
+			name: "invalid token",
+			body: input,
+			mockFn: func(ms *MockEmailChangeService) {
+				ms.On("Confirm", mock.Anything, input).Return(nil, service.ErrInvalidEmailChangeToken)
+			},
+			wantCode:    http.StatusBadRequest,
+			errContains: service.ErrInvalidEmailChangeToken.Error(),
+		},
+		{
+			name: "email taken since the request",
+			body: input,
+			mockFn: func(ms *MockEmailChangeService) {
+				ms.On("Confirm", mock.Anything, input).Return(nil, service.ErrEmailTaken)
+			},
+			wantCode:    http.StatusConflict,
+			errContains: service.ErrEmailTaken.Error(),
+		},
+		{
+			name: "unexpected error",
+			body: input,
+			mockFn: func(ms *MockEmailChangeService) {
+				ms.On("Confirm", mock.Anything, input).Return(nil, errors.New("connection reset"))
+			},
+			wantCode:    http.StatusInternalServerError,
+			errContains: "failed to confirm email change",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mockService, audit := setupEmailChangeTest(func(c *gin.Context) {})
+			if tt.mockFn != nil {
+				tt.mockFn(mockService)
+			}
+
+			body, _ := json.Marshal(tt.body)
+			req := httptest.NewRequest(http.MethodPost, "/api/auth/email-change/confirm", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+
+			var res map[string]interface{}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+			if tt.wantCode == http.StatusOK {
+				assert.Equal(t, user.Email, res["email"])
+				assert.Equal(t, user.ID.String(), res["id"])
+				require.Len(t, audit.entries, 1, "the confirmed change is audited")
+				assert.Equal(t, user.ID, audit.entries[0].ActorID)
+				assert.Equal(t, "/api/auth/email-change/confirm", audit.entries[0].Path)
+				assert.Equal(t, "192.0.2.1", audit.entries[0].IPAddress)
+			} else {
+				assert.Contains(t, res["error"], tt.errContains)
+				assert.Empty(t, audit.entries)
+			}
+
+			mockService.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/PakornBank/learn-go/internal/apierror"
+	"github.com/PakornBank/learn-go/internal/emailqueue"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// DeadLetterService defines the methods that an email queue handler must implement.
+type DeadLetterService interface {
+	// List returns a page of dead-lettered emails, newest first, along with
+	// the cursor for the next page.
+	// ctx: The context for the request.
+	// cursor: The opaque cursor returned by a previous call, or empty to start.
+	// limit: The maximum number of emails to return.
+	List(ctx context.Context, cursor string, limit int) ([]model.QueuedEmail, string, error)
+
+	// Retry queues a dead-lettered email to be sent again.
+	// ctx: The context for the request.
+	// id: The ID of the email to retry.
+	Retry(ctx context.Context, id string) error
+}
+
+// EmailStats reports the outcomes of the email worker's attempts. It is
+// satisfied by *emailqueue.Worker.
+type EmailStats interface {
+	// Stats returns the counts of emails sent, failed attempts and dead letters.
+	Stats() emailqueue.Stats
+}
+
+// EmailQueueHandler handles HTTP requests for managing the email queue.
+type EmailQueueHandler struct {
+	service DeadLetterService
+	stats   EmailStats
+}
+
+// NewEmailQueueHandler creates a new instance of EmailQueueHandler with the provided service and stats.
+func NewEmailQueueHandler(s DeadLetterService, stats EmailStats) *EmailQueueHandler {
+	return &EmailQueueHandler{service: s, stats: stats}
+}
+
+// ListDeadLetters handles the request to list the emails that failed too many
+// times to be retried automatically, with cursor-based pagination. It responds
+// in the same envelope as other paginated endpoints; email bodies are left out
+// since they carry single-use tokens. An invalid parameter results in a 400
+// status code, and a database timeout in a 504.
+func (h *EmailQueueHandler) ListDeadLetters(c *gin.Context) {
+	limit, ok := parseLimit(c)
+	if !ok {
+		return
+	}
+
+	emails, nextCursor, err := h.service.List(c.Request.Context(), c.Query("cursor"), limit)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidLimit),
+			errors.Is(err, repository.ErrInvalidCursor):
+			apierror.Respond(c, http.StatusBadRequest, err.Error())
+		case errors.Is(err, repository.ErrTimeout):
+			apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+		default:
+			c.Error(err)
+			apierror.Respond(c, http.StatusInternalServerError, "failed to list dead-lettered emails")
+		}
+		return
+	}
+
+	if emails == nil {
+		emails = []model.QueuedEmail{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": emails, "next_cursor": nextCursor})
+}
+
+// RetryDeadLetter handles the request to send the dead-lettered email
+// identified by the "id" path parameter again and responds with a 202 status
+// code once it is queued. An invalid ID results in a 400 status code, and an
+// email that is not dead-lettered in a 404.
+func (h *EmailQueueHandler) RetryDeadLetter(c *gin.Context) {
+	err := h.service.Retry(c.Request.Context(), c.Param("id"))
+	switch {
+	case err == nil:
+		c.JSON(http.StatusAccepted, gin.H{"message": "email queued for retry"})
+	case errors.Is(err, service.ErrInvalidEmailID):
+		apierror.Respond(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, service.ErrDeadLetterNotFound):
+		apierror.Respond(c, http.StatusNotFound, service.ErrDeadLetterNotFound.Error())
+	case errors.Is(err, repository.ErrTimeout):
+		apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+	default:
+		c.Error(err)
+		apierror.Respond(c, http.StatusInternalServerError, "failed to retry email")
+	}
+}
+
+// GetStats handles the request for the counts of emails sent, failed attempts
+// and dead letters since this replica started.
+func (h *EmailQueueHandler) GetStats(c *gin.Context) {
+	c.JSON(http.StatusOK, h.stats.Stats())
+}
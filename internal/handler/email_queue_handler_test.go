@@ -0,0 +1,217 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PakornBank/learn-go/internal/emailqueue"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockDeadLetterService struct {
+	mock.Mock
+}
+
+func (ms *MockDeadLetterService) List(ctx context.Context, cursor string, limit int) ([]model.QueuedEmail, string, error) {
+	args := ms.Called(ctx, cursor, limit)
+	if args.Get(0) == nil {
+		return nil, args.String(1), args.Error(2)
+	}
+	return args.Get(0).([]model.QueuedEmail), args.String(1), args.Error(2)
+}
+
+func (ms *MockDeadLetterService) Retry(ctx context.Context, id string) error {
+	args := ms.Called(ctx, id)
+	return args.Error(0)
+}
+
+type fakeEmailStats emailqueue.Stats
+
+func (s fakeEmailStats) Stats() emailqueue.Stats {
+	return emailqueue.Stats(s)
+}
+
+func setupEmailQueueTest() (*gin.Engine, *MockDeadLetterService) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockDeadLetterService)
+	handler := NewEmailQueueHandler(mockService, fakeEmailStats{Sent: 7, Failed: 3, DeadLettered: 1})
+
+	router := gin.New()
+	router.GET("/api/admin/emails/dead-letters", handler.ListDeadLetters)
+	router.POST("/api/admin/emails/dead-letters/:id/retry", handler.RetryDeadLetter)
+	router.GET("/api/admin/emails/stats", handler.GetStats)
+
+	return router, mockService
+}
+
+func TestNewEmailQueueHandler(t *testing.T) {
+	service := new(MockDeadLetterService)
+	stats := fakeEmailStats{}
+	handler := NewEmailQueueHandler(service, stats)
+
+	assert.NotNil(t, handler)
+	assert.Equal(t, service, handler.service)
+	assert.Equal(t, stats, handler.stats)
+}
+
+func TestEmailQueueHandler_ListDeadLetters(t *testing.T) {
+	email := model.QueuedEmail{ID: uuid.New(), Recipient: "test@example.com", Subject: "Verify", TextBody: "secret link", Status: model.EmailDead}
+
+	tests := []struct {
+		name           string
+		query          string
+		mockFn         func(*MockDeadLetterService)
+		wantCode       int
+		wantLen        int
+		wantNextCursor string
+		errContains    string
+	}{
+		{
+			name: "successful listing",
+			mockFn: func(ms *MockDeadLetterService) {
+				ms.On("List", mock.Anything, "", service.DefaultListLimit).Return([]model.QueuedEmail{email}, "next", nil)
+			},
+			wantCode:       http.StatusOK,
+			wantLen:        1,
+			wantNextCursor: "next",
+		},
+		{
+			name:  "empty page",
+			query: "?cursor=abc&limit=5",
+			mockFn: func(ms *MockDeadLetterService) {
+				ms.On("List", mock.Anything, "abc", 5).Return(nil, "", nil)
+			},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:  "invalid cursor",
+			query: "?cursor=abc",
+			mockFn: func(ms *MockDeadLetterService) {
+				ms.On("List", mock.Anything, "abc", service.DefaultListLimit).Return(nil, "", repository.ErrInvalidCursor)
+			},
+			wantCode:    http.StatusBadRequest,
+			errContains: repository.ErrInvalidCursor.Error(),
+		},
+		{
+			name: "database timeout",
+			mockFn: func(ms *MockDeadLetterService) {
+				ms.On("List", mock.Anything, "", service.DefaultListLimit).Return(nil, "", repository.ErrTimeout)
+			},
+			wantCode:    http.StatusGatewayTimeout,
+			errContains: repository.ErrTimeout.Error(),
+		},
+		{
+			name: "service error",
+			mockFn: func(ms *MockDeadLetterService) {
+				ms.On("List", mock.Anything, "", service.DefaultListLimit).Return(nil, "", errors.New("service error"))
+			},
+			wantCode:    http.StatusInternalServerError,
+			errContains: "failed to list dead-lettered emails",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mockService := setupEmailQueueTest()
+			tt.mockFn(mockService)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/emails/dead-letters"+tt.query, nil))
+
+			assert.Equal(t, tt.wantCode, w.Code)
+
+			var res map[string]interface{}
+			err := json.Unmarshal(w.Body.Bytes(), &res)
+			assert.NoError(t, err)
+
+			if tt.wantCode == http.StatusOK {
+				assert.Len(t, res["data"], tt.wantLen)
+				assert.Equal(t, tt.wantNextCursor, res["next_cursor"])
+				assert.NotContains(t, w.Body.String(), "secret link", "bodies are not exposed")
+			} else {
+				assert.Contains(t, res["error"], tt.errContains)
+			}
+
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestEmailQueueHandler_RetryDeadLetter(t *testing.T) {
+	emailID := uuid.New().String()
+
+	tests := []struct {
+		name        string
+		mockErr     error
+		wantCode    int
+		errContains string
+	}{
+		{
+			name:     "queued for retry",
+			wantCode: http.StatusAccepted,
+		},
+		{
+			name:        "invalid id",
+			mockErr:     service.ErrInvalidEmailID,
+			wantCode:    http.StatusBadRequest,
+			errContains: service.ErrInvalidEmailID.Error(),
+		},
+		{
+			name:        "not a dead letter",
+			mockErr:     service.ErrDeadLetterNotFound,
+			wantCode:    http.StatusNotFound,
+			errContains: service.ErrDeadLetterNotFound.Error(),
+		},
+		{
+			name:        "database timeout",
+			mockErr:     repository.ErrTimeout,
+			wantCode:    http.StatusGatewayTimeout,
+			errContains: repository.ErrTimeout.Error(),
+		},
+		{
+			name:        "service error",
+			mockErr:     errors.New("service error"),
+			wantCode:    http.StatusInternalServerError,
+			errContains: "failed to retry email",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mockService := setupEmailQueueTest()
+			mockService.On("Retry", mock.Anything, emailID).Return(tt.mockErr)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/admin/emails/dead-letters/"+emailID+"/retry", nil))
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.errContains != "" {
+				var res map[string]interface{}
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+				assert.Contains(t, res["error"], tt.errContains)
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestEmailQueueHandler_GetStats(t *testing.T) {
+	router, _ := setupEmailQueueTest()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/emails/stats", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"sent": 7, "failed": 3, "dead_lettered": 1}`, w.Body.String())
+}
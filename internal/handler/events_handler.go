@@ -0,0 +1,303 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/apierror"
+	"github.com/PakornBank/learn-go/internal/authctx"
+	"github.com/PakornBank/learn-go/internal/events"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// eventsWriteWait is how long sending a message to a client may take.
+	eventsWriteWait = 10 * time.Second
+	// eventsPongWait is how long a client may take to answer a ping before
+	// its connection is considered dead.
+	eventsPongWait = 60 * time.Second
+	// eventsReadLimit is the largest message a client may send. Clients are
+	// not expected to send anything but control messages.
+	eventsReadLimit = 512
+	// eventsKeepaliveInterval is how often an idle Server-Sent Events stream
+	// gets a comment, which keeps proxies from closing it.
+	eventsKeepaliveInterval = 15 * time.Second
+	// eventsRevalidateInterval is how often a stream checks that the access
+	// token it was opened with has not been revoked.
+	eventsRevalidateInterval = time.Minute
+)
+
+// EventSubscriber defines the methods that an events handler must implement.
+// It is satisfied by *events.Hub.
+type EventSubscriber interface {
+	// Subscribe starts delivering the events published about a user.
+	// userID: The ID of the user whose events are delivered.
+	Subscribe(userID string) *events.Subscription
+	// Resume starts delivering the events published about a user and returns
+	// the recent ones that came after the event with lastID.
+	// userID: The ID of the user whose events are delivered.
+	// lastID: The ID of the last event the client received.
+	Resume(userID string, lastID uint64) (*events.Subscription, []events.Event)
+}
+
+// TokenVersionChecker defines the methods that an events handler uses to
+// notice revoked access tokens. It is satisfied by *service.AuthService.
+type TokenVersionChecker interface {
+	// CheckTokenVersion returns service.ErrTokenRevoked if version is not the
+	// current token version of the user with userID.
+	CheckTokenVersion(ctx context.Context, userID string, version int) error
+}
+
+// EventsHandler streams the events about the authenticated user's account,
+// such as sessions being revoked, to their open connections.
+type EventsHandler struct {
+	hub                EventSubscriber
+	versions           TokenVersionChecker
+	upgrader           websocket.Upgrader
+	pingInterval       time.Duration
+	pongWait           time.Duration
+	keepaliveInterval  time.Duration
+	revalidateInterval time.Duration
+}
+
+// NewEventsHandler creates a new instance of EventsHandler streaming the
+// events of hub for as long as versions accepts the access token of the stream.
+func NewEventsHandler(hub EventSubscriber, versions TokenVersionChecker) *EventsHandler {
+	return &EventsHandler{
+		hub:                hub,
+		versions:           versions,
+		pingInterval:       eventsPongWait * 9 / 10,
+		pongWait:           eventsPongWait,
+		keepaliveInterval:  eventsKeepaliveInterval,
+		revalidateInterval: eventsRevalidateInterval,
+	}
+}
+
+// Stream handles the authenticated user's request for their events by
+// upgrading the connection to a WebSocket and sending each event as a JSON
+// text message. The connection is pinged periodically and closed when the
+// client stops answering. A client that falls too far behind is disconnected
+// with the 1013 "try again later" close code and should reconnect. When the
+// access token expires or is revoked, the connection is closed with the 1008
+// "policy violation" close code and the client should reconnect with a new
+// token.
+func (h *EventsHandler) Stream(c *gin.Context) {
+	identity, ok := authctx.User(c)
+	if !ok {
+		apierror.Respond(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade has already responded with an HTTP error.
+		return
+	}
+	defer conn.Close()
+
+	sub := h.hub.Subscribe(identity.UserID.String())
+	defer sub.Close()
+
+	disconnected := make(chan struct{})
+	go h.readUntilClosed(conn, disconnected)
+
+	watch := h.watchToken(identity)
+	defer watch.stop()
+
+	ping := time.NewTicker(h.pingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case <-disconnected:
+			return
+		case event, ok := <-sub.Events():
+			if !ok {
+				writeClose(conn, websocket.CloseTryAgainLater, "too slow to receive events")
+				return
+			}
+			_ = conn.SetWriteDeadline(time.Now().Add(eventsWriteWait))
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+			if event.Type == events.TypeSessionRevoked && h.tokenRevoked(c.Request.Context(), identity) {
+				writeClose(conn, websocket.ClosePolicyViolation, service.ErrTokenRevoked.Error())
+				return
+			}
+		case <-watch.expired:
+			writeClose(conn, websocket.ClosePolicyViolation, "token has expired")
+			return
+		case <-watch.revalidate:
+			if h.tokenRevoked(c.Request.Context(), identity) {
+				writeClose(conn, websocket.ClosePolicyViolation, service.ErrTokenRevoked.Error())
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(eventsWriteWait)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// writeClose sends a close message with code and reason to the client of conn.
+func writeClose(conn *websocket.Conn, code int, reason string) {
+	message := websocket.FormatCloseMessage(code, reason)
+	_ = conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(eventsWriteWait))
+}
+
+// readUntilClosed reads from conn, which processes pongs and close messages,
+// until the client disconnects or stops answering pings, and then closes
+// disconnected. Messages the client sends are discarded.
+func (h *EventsHandler) readUntilClosed(conn *websocket.Conn, disconnected chan<- struct{}) {
+	defer close(disconnected)
+
+	conn.SetReadLimit(eventsReadLimit)
+	_ = conn.SetReadDeadline(time.Now().Add(h.pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(h.pongWait))
+	})
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+// StreamSSE handles the authenticated user's request for their events as a
+// text/event-stream. Each event is sent with its ID and its JSON encoding as
+// data. A client reconnecting with the Last-Event-ID header first receives
+// the recent events it missed. The stream ends when the client disconnects,
+// or when it falls too far behind, in which case the client may reconnect
+// and resume. It also ends when the access token expires or is revoked, in
+// which case the client should resume with a new token.
+func (h *EventsHandler) StreamSSE(c *gin.Context) {
+	identity, ok := authctx.User(c)
+	if !ok {
+		apierror.Respond(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	// A new stream starts with the next event; only a reconnecting client,
+	// which sends the ID of the last event it received, gets a replay.
+	var sub *events.Subscription
+	var missed []events.Event
+	if header := c.GetHeader("Last-Event-ID"); header != "" {
+		lastID, err := strconv.ParseUint(header, 10, 64)
+		if err != nil {
+			apierror.Respond(c, http.StatusBadRequest, "invalid Last-Event-ID")
+			return
+		}
+		sub, missed = h.hub.Resume(identity.UserID.String(), lastID)
+	} else {
+		sub = h.hub.Subscribe(identity.UserID.String())
+	}
+	defer sub.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	for _, event := range missed {
+		if err := writeServerSentEvent(c.Writer, event); err != nil {
+			return
+		}
+	}
+
+	watch := h.watchToken(identity)
+	defer watch.stop()
+
+	keepalive := time.NewTicker(h.keepaliveInterval)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			if err := writeServerSentEvent(c.Writer, event); err != nil {
+				return
+			}
+			if event.Type == events.TypeSessionRevoked && h.tokenRevoked(c.Request.Context(), identity) {
+				return
+			}
+		case <-watch.expired:
+			return
+		case <-watch.revalidate:
+			if h.tokenRevoked(c.Request.Context(), identity) {
+				return
+			}
+		case <-keepalive.C:
+			if _, err := io.WriteString(c.Writer, ": keepalive\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+// tokenWatch tells a stream when to stop trusting the access token it was
+// opened with.
+type tokenWatch struct {
+	// expired receives when the token expires. It is nil, and never
+	// receives, for a token without an expiry.
+	expired <-chan time.Time
+	// revalidate receives when the token should be checked for revocation.
+	revalidate <-chan time.Time
+	stop       func()
+}
+
+// watchToken starts watching the access token identity was authenticated
+// with. The returned watch must be stopped when the stream ends.
+func (h *EventsHandler) watchToken(identity authctx.Identity) tokenWatch {
+	revalidate := time.NewTicker(h.revalidateInterval)
+	watch := tokenWatch{revalidate: revalidate.C, stop: revalidate.Stop}
+	if !identity.ExpiresAt.IsZero() {
+		expiry := time.NewTimer(time.Until(identity.ExpiresAt))
+		watch.expired = expiry.C
+		watch.stop = func() {
+			revalidate.Stop()
+			expiry.Stop()
+		}
+	}
+	return watch
+}
+
+// tokenRevoked reports whether the access token identity was authenticated
+// with has been revoked. A token that cannot be checked, for example because
+// the database is unavailable, is assumed to be still valid, so that a
+// transient failure does not disconnect every stream.
+func (h *EventsHandler) tokenRevoked(ctx context.Context, identity authctx.Identity) bool {
+	err := h.versions.CheckTokenVersion(ctx, identity.UserID.String(), identity.TokenVersion)
+	if err != nil && !errors.Is(err, service.ErrTokenRevoked) {
+		slog.WarnContext(ctx, "failed to check the token of an events stream", "user_id", identity.UserID, "error", err)
+		return false
+	}
+	return err != nil
+}
+
+// writeServerSentEvent writes event to w in the text/event-stream format and
+// flushes it to the client.
+func writeServerSentEvent(w gin.ResponseWriter, event events.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "id: %d\ndata: %s\n\n", event.ID, data); err != nil {
+		return err
+	}
+	w.Flush()
+	return nil
+}
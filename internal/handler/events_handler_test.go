@@ -0,0 +1,480 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/authctx"
+	"github.com/PakornBank/learn-go/internal/events"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// streamUserID is the user the events streams of the tests are opened as.
+const streamUserID = "5b0c8f3e-2a1d-4c6b-9e7f-3d2a1b0c9e8f"
+
+// tokenVersions is a TokenVersionChecker that accepts every token until
+// revoked is set.
+type tokenVersions struct {
+	revoked atomic.Bool
+}
+
+func (v *tokenVersions) CheckTokenVersion(ctx context.Context, userID string, version int) error {
+	if v.revoked.Load() {
+		return service.ErrTokenRevoked
+	}
+	return nil
+}
+
+// authenticatedWith returns a middleware that authenticates requests with
+// identity, as AuthMiddleware does.
+func authenticatedWith(identity authctx.Identity) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authctx.SetUser(c, identity)
+	}
+}
+
+// setupEventsTest starts a server streaming the events of hub to the user
+// streamUserID and returns the WebSocket URL of the stream.
+func setupEventsTest(t *testing.T, handler *EventsHandler) string {
+	t.Helper()
+	return setupEventsTestAs(t, handler, authctx.Identity{UserID: uuid.MustParse(streamUserID)})
+}
+
+// setupEventsTestAs is like setupEventsTest, but opens the stream with identity.
+func setupEventsTestAs(t *testing.T, handler *EventsHandler, identity authctx.Identity) string {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/events", authenticatedWith(identity), handler.Stream)
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http") + "/events"
+}
+
+// dialEvents connects to the events stream at url.
+func dialEvents(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+	conn, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestNewEventsHandler(t *testing.T) {
+	hub := events.NewHub(events.DefaultBufferSize, events.DefaultReplaySize)
+	versions := new(tokenVersions)
+	handler := NewEventsHandler(hub, versions)
+
+	assert.NotNil(t, handler)
+	assert.Equal(t, hub, handler.hub)
+	assert.Equal(t, versions, handler.versions)
+	assert.Less(t, handler.pingInterval, handler.pongWait)
+}
+
+func TestEventsHandler_Stream(t *testing.T) {
+	hub := events.NewHub(events.DefaultBufferSize, events.DefaultReplaySize)
+	conn := dialEvents(t, setupEventsTest(t, NewEventsHandler(hub, new(tokenVersions))))
+	require.Eventually(t, func() bool { return hub.Subscribers(streamUserID) == 1 }, time.Second, 10*time.Millisecond)
+
+	hub.Publish("user-2", events.Event{Type: events.TypeSessionCreated})
+	hub.Publish(streamUserID, events.Event{Type: events.TypeSessionRevoked, Data: map[string]string{"session_id": "family-1"}})
+
+	var got events.Event
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	require.NoError(t, conn.ReadJSON(&got))
+	assert.Equal(t, events.TypeSessionRevoked, got.Type, "events about other users are not sent")
+	assert.Equal(t, "family-1", got.Data["session_id"])
+}
+
+func TestEventsHandler_StreamPings(t *testing.T) {
+	hub := events.NewHub(events.DefaultBufferSize, events.DefaultReplaySize)
+	handler := NewEventsHandler(hub, new(tokenVersions))
+	handler.pingInterval = 10 * time.Millisecond
+	conn := dialEvents(t, setupEventsTest(t, handler))
+
+	pinged := make(chan struct{}, 1)
+	conn.SetPingHandler(func(string) error {
+		select {
+		case pinged <- struct{}{}:
+		default:
+		}
+		return nil
+	})
+	// Control messages are only processed while reading.
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-pinged:
+	case <-time.After(time.Second):
+		t.Fatal("no ping received")
+	}
+}
+
+func TestEventsHandler_StreamDropsSlowConsumer(t *testing.T) {
+	hub := events.NewHub(1, events.DefaultReplaySize)
+	conn := dialEvents(t, setupEventsTest(t, NewEventsHandler(hub, new(tokenVersions))))
+	require.Eventually(t, func() bool { return hub.Subscribers(streamUserID) == 1 }, time.Second, 10*time.Millisecond)
+
+	// The client reads nothing, so the events pile up until the hub gives up.
+	payload := strings.Repeat("x", 64*1024)
+	require.Eventually(t, func() bool {
+		hub.Publish(streamUserID, events.Event{Type: events.TypeProfileUpdated, Data: map[string]string{"field": payload}})
+		return hub.Subscribers(streamUserID) == 0
+	}, 5*time.Second, time.Millisecond)
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	for {
+		_, _, err := conn.ReadMessage()
+		if err != nil {
+			assert.True(t, websocket.IsCloseError(err, websocket.CloseTryAgainLater), "unexpected error: %v", err)
+			break
+		}
+	}
+}
+
+func TestEventsHandler_StreamCleansUpOnDisconnect(t *testing.T) {
+	hub := events.NewHub(events.DefaultBufferSize, events.DefaultReplaySize)
+	conn := dialEvents(t, setupEventsTest(t, NewEventsHandler(hub, new(tokenVersions))))
+	require.Eventually(t, func() bool { return hub.Subscribers(streamUserID) == 1 }, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, conn.Close())
+
+	assert.Eventually(t, func() bool { return hub.Subscribers(streamUserID) == 0 }, time.Second, 10*time.Millisecond)
+}
+
+// readUntilClose reads from conn until the server closes it, returning the
+// close error.
+func readUntilClose(t *testing.T, conn *websocket.Conn) *websocket.CloseError {
+	t.Helper()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			var closeErr *websocket.CloseError
+			require.ErrorAs(t, err, &closeErr)
+			return closeErr
+		}
+	}
+}
+
+func TestEventsHandler_StreamClosesWhenTokenLapses(t *testing.T) {
+	userID := uuid.MustParse(streamUserID)
+	tests := []struct {
+		name       string
+		identity   authctx.Identity
+		revalidate time.Duration
+		lapse      func(*events.Hub, *tokenVersions)
+		wantReason string
+	}{
+		{
+			name:       "token expires",
+			identity:   authctx.Identity{UserID: userID, ExpiresAt: time.Now().Add(50 * time.Millisecond)},
+			revalidate: time.Hour,
+			lapse:      func(*events.Hub, *tokenVersions) {},
+			wantReason: "token has expired",
+		},
+		{
+			name:       "sessions revoked",
+			identity:   authctx.Identity{UserID: userID},
+			revalidate: time.Hour,
+			lapse: func(hub *events.Hub, versions *tokenVersions) {
+				versions.revoked.Store(true)
+				hub.Publish(streamUserID, events.Event{Type: events.TypeSessionRevoked})
+			},
+			wantReason: service.ErrTokenRevoked.Error(),
+		},
+		{
+			name:       "token version changes without an event",
+			identity:   authctx.Identity{UserID: userID},
+			revalidate: 10 * time.Millisecond,
+			lapse: func(_ *events.Hub, versions *tokenVersions) {
+				versions.revoked.Store(true)
+			},
+			wantReason: service.ErrTokenRevoked.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := events.NewHub(events.DefaultBufferSize, events.DefaultReplaySize)
+			versions := new(tokenVersions)
+			handler := NewEventsHandler(hub, versions)
+			handler.revalidateInterval = tt.revalidate
+			conn := dialEvents(t, setupEventsTestAs(t, handler, tt.identity))
+			require.Eventually(t, func() bool { return hub.Subscribers(streamUserID) == 1 }, time.Second, 10*time.Millisecond)
+
+			tt.lapse(hub, versions)
+
+			closeErr := readUntilClose(t, conn)
+			assert.Equal(t, websocket.ClosePolicyViolation, closeErr.Code)
+			assert.Equal(t, tt.wantReason, closeErr.Text)
+			assert.Eventually(t, func() bool { return hub.Subscribers(streamUserID) == 0 }, time.Second, 10*time.Millisecond)
+		})
+	}
+}
+
+func TestEventsHandler_StreamUnauthenticated(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/events", NewEventsHandler(events.NewHub(events.DefaultBufferSize, events.DefaultReplaySize), new(tokenVersions)).Stream)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/events", nil))
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+// pipeResponseWriter is a streaming http.ResponseWriter whose body is read
+// from the other end of a pipe as the handler writes it.
+type pipeResponseWriter struct {
+	*io.PipeWriter
+	header http.Header
+	code   int
+}
+
+func (w *pipeResponseWriter) Header() http.Header  { return w.header }
+func (w *pipeResponseWriter) WriteHeader(code int) { w.code = code }
+func (w *pipeResponseWriter) Flush()               {}
+
+// sseStream is a running Server-Sent Events request.
+type sseStream struct {
+	writer *pipeResponseWriter
+	body   *bufio.Reader
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// startSSE requests the events stream of streamUserID from handler, resuming
+// after lastEventID unless it is empty, and returns the stream once its
+// headers have been written.
+func startSSE(t *testing.T, handler *EventsHandler, lastEventID string) *sseStream {
+	t.Helper()
+	return startSSEAs(t, handler, authctx.Identity{UserID: uuid.MustParse(streamUserID)}, lastEventID)
+}
+
+// startSSEAs is like startSSE, but opens the stream with identity.
+func startSSEAs(t *testing.T, handler *EventsHandler, identity authctx.Identity, lastEventID string) *sseStream {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/events/sse", authenticatedWith(identity), handler.StreamSSE)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/events/sse", nil).WithContext(ctx)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	reader, writer := io.Pipe()
+	stream := &sseStream{
+		writer: &pipeResponseWriter{PipeWriter: writer, header: make(http.Header)},
+		body:   bufio.NewReader(reader),
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go func() {
+		defer close(stream.done)
+		router.ServeHTTP(stream.writer, req)
+		writer.Close()
+	}()
+	t.Cleanup(func() {
+		cancel()
+		// Unblock a handler stuck writing, then wait for it to return.
+		_, _ = io.Copy(io.Discard, reader)
+		<-stream.done
+	})
+
+	return stream
+}
+
+// next reads the next message of the stream, returning its lines.
+func (s *sseStream) next(t *testing.T) []string {
+	t.Helper()
+	var lines []string
+	for {
+		line, err := s.body.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return lines
+		}
+		lines = append(lines, line)
+	}
+}
+
+// nextEvent reads the next event of the stream, skipping comments.
+func (s *sseStream) nextEvent(t *testing.T) (string, events.Event) {
+	t.Helper()
+	for {
+		lines := s.next(t)
+		if strings.HasPrefix(lines[0], ":") {
+			continue
+		}
+		require.Len(t, lines, 2)
+		var event events.Event
+		require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(lines[1], "data: ")), &event))
+		return strings.TrimPrefix(lines[0], "id: "), event
+	}
+}
+
+func TestEventsHandler_StreamSSE(t *testing.T) {
+	hub := events.NewHub(events.DefaultBufferSize, events.DefaultReplaySize)
+	stream := startSSE(t, NewEventsHandler(hub, new(tokenVersions)), "")
+	require.Eventually(t, func() bool { return hub.Subscribers(streamUserID) == 1 }, time.Second, 10*time.Millisecond)
+
+	hub.Publish("user-2", events.Event{Type: events.TypeSessionCreated})
+	hub.Publish(streamUserID, events.Event{Type: events.TypeSessionRevoked, Data: map[string]string{"session_id": "family-1"}})
+
+	id, event := stream.nextEvent(t)
+	assert.Equal(t, http.StatusOK, stream.writer.code)
+	assert.Equal(t, "text/event-stream", stream.writer.header.Get("Content-Type"))
+	assert.Equal(t, "2", id, "events about other users are not sent")
+	assert.Equal(t, events.TypeSessionRevoked, event.Type)
+	assert.Equal(t, "family-1", event.Data["session_id"])
+}
+
+func TestEventsHandler_StreamSSEReplay(t *testing.T) {
+	tests := []struct {
+		name        string
+		lastEventID string
+		wantIDs     []string
+	}{
+		{name: "new stream", lastEventID: "", wantIDs: []string{"4"}},
+		{name: "resumes after the last event", lastEventID: "1", wantIDs: []string{"3", "4"}},
+		{name: "up to date", lastEventID: "3", wantIDs: []string{"4"}},
+		{name: "unknown ID", lastEventID: "99", wantIDs: []string{"4"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := events.NewHub(events.DefaultBufferSize, events.DefaultReplaySize)
+			hub.Publish(streamUserID, events.Event{Type: events.TypeSessionCreated})
+			hub.Publish("user-2", events.Event{Type: events.TypeSessionCreated})
+			hub.Publish(streamUserID, events.Event{Type: events.TypeProfileUpdated})
+
+			stream := startSSE(t, NewEventsHandler(hub, new(tokenVersions)), tt.lastEventID)
+			require.Eventually(t, func() bool { return hub.Subscribers(streamUserID) == 1 }, time.Second, 10*time.Millisecond)
+			// The live event follows the replayed ones without a gap or duplicate.
+			hub.Publish(streamUserID, events.Event{Type: events.TypeSessionRevoked})
+
+			var ids []string
+			for len(ids) < len(tt.wantIDs) {
+				id, _ := stream.nextEvent(t)
+				ids = append(ids, id)
+			}
+			assert.Equal(t, tt.wantIDs, ids)
+		})
+	}
+}
+
+func TestEventsHandler_StreamSSEKeepalive(t *testing.T) {
+	handler := NewEventsHandler(events.NewHub(events.DefaultBufferSize, events.DefaultReplaySize), new(tokenVersions))
+	handler.keepaliveInterval = 10 * time.Millisecond
+	stream := startSSE(t, handler, "")
+
+	assert.Equal(t, []string{": keepalive"}, stream.next(t))
+}
+
+func TestEventsHandler_StreamSSEStopsWhenCancelled(t *testing.T) {
+	hub := events.NewHub(events.DefaultBufferSize, events.DefaultReplaySize)
+	stream := startSSE(t, NewEventsHandler(hub, new(tokenVersions)), "")
+	require.Eventually(t, func() bool { return hub.Subscribers(streamUserID) == 1 }, time.Second, 10*time.Millisecond)
+
+	stream.cancel()
+
+	select {
+	case <-stream.done:
+	case <-time.After(time.Second):
+		t.Fatal("the handler did not return")
+	}
+	assert.Equal(t, 0, hub.Subscribers(streamUserID))
+}
+
+func TestEventsHandler_StreamSSEStopsWhenTokenLapses(t *testing.T) {
+	userID := uuid.MustParse(streamUserID)
+	tests := []struct {
+		name       string
+		identity   authctx.Identity
+		revalidate time.Duration
+		lapse      func(*events.Hub, *tokenVersions)
+	}{
+		{
+			name:       "token expires",
+			identity:   authctx.Identity{UserID: userID, ExpiresAt: time.Now().Add(50 * time.Millisecond)},
+			revalidate: time.Hour,
+			lapse:      func(*events.Hub, *tokenVersions) {},
+		},
+		{
+			name:       "sessions revoked",
+			identity:   authctx.Identity{UserID: userID},
+			revalidate: time.Hour,
+			lapse: func(hub *events.Hub, versions *tokenVersions) {
+				versions.revoked.Store(true)
+				hub.Publish(streamUserID, events.Event{Type: events.TypeSessionRevoked})
+			},
+		},
+		{
+			name:       "token version changes without an event",
+			identity:   authctx.Identity{UserID: userID},
+			revalidate: 10 * time.Millisecond,
+			lapse: func(_ *events.Hub, versions *tokenVersions) {
+				versions.revoked.Store(true)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := events.NewHub(events.DefaultBufferSize, events.DefaultReplaySize)
+			versions := new(tokenVersions)
+			handler := NewEventsHandler(hub, versions)
+			handler.revalidateInterval = tt.revalidate
+			stream := startSSEAs(t, handler, tt.identity, "")
+			require.Eventually(t, func() bool { return hub.Subscribers(streamUserID) == 1 }, time.Second, 10*time.Millisecond)
+
+			tt.lapse(hub, versions)
+			// Drain the stream so that the handler is never stuck writing.
+			go func() { _, _ = io.Copy(io.Discard, stream.body) }()
+
+			select {
+			case <-stream.done:
+			case <-time.After(time.Second):
+				t.Fatal("the handler did not return")
+			}
+			assert.Equal(t, 0, hub.Subscribers(streamUserID))
+		})
+	}
+}
+
+func TestEventsHandler_StreamSSEInvalidLastEventID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/events/sse", authenticatedAs(streamUserID),
+		NewEventsHandler(events.NewHub(events.DefaultBufferSize, events.DefaultReplaySize), new(tokenVersions)).StreamSSE)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/events/sse", nil)
+	req.Header.Set("Last-Event-ID", "latest")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/PakornBank/learn-go/internal/apierror"
+	"github.com/PakornBank/learn-go/internal/authctx"
+	"github.com/gin-gonic/gin"
+)
+
+// FlagEvaluator defines the methods that a feature flag handler must implement.
+// It is satisfied by *featureflag.Static.
+type FlagEvaluator interface {
+	// Evaluate returns whether each configured flag is on for a user.
+	// userID: The ID of the user to evaluate the flags for.
+	Evaluate(userID string) map[string]bool
+}
+
+// FeatureFlagHandler handles HTTP requests for the feature flags of the authenticated user.
+type FeatureFlagHandler struct {
+	flags FlagEvaluator
+}
+
+// NewFeatureFlagHandler creates a new instance of FeatureFlagHandler with the provided flags.
+func NewFeatureFlagHandler(flags FlagEvaluator) *FeatureFlagHandler {
+	return &FeatureFlagHandler{flags: flags}
+}
+
+// GetFlags handles the request for the authenticated user's feature flags.
+// It responds with every configured flag and whether it is on for the user,
+// so that clients can branch the same way the server does.
+func (h *FeatureFlagHandler) GetFlags(c *gin.Context) {
+	identity, ok := authctx.User(c)
+	if !ok {
+		apierror.Respond(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"flags": h.flags.Evaluate(identity.UserID.String())})
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PakornBank/learn-go/internal/featureflag"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewFeatureFlagHandler(t *testing.T) {
+	flags, err := featureflag.NewStatic(nil)
+	require.NoError(t, err)
+	handler := NewFeatureFlagHandler(flags)
+
+	assert.NotNil(t, handler)
+	assert.Equal(t, flags, handler.flags)
+}
+
+func TestFeatureFlagHandler_GetFlags(t *testing.T) {
+	flags, err := featureflag.NewStatic(map[string]featureflag.Rule{
+		"passkeys":          {Enabled: true},
+		"strict_validation": {},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		middleware gin.HandlerFunc
+		wantCode   int
+		wantBody   string
+	}{
+		{
+			name:       "authenticated",
+			middleware: authenticatedAs("5b0c8f3e-2a1d-4c6b-9e7f-3d2a1b0c9e8f"),
+			wantCode:   http.StatusOK,
+			wantBody:   `{"flags":{"passkeys":true,"strict_validation":false}}`,
+		},
+		{
+			name:       "unauthenticated",
+			middleware: func(c *gin.Context) {},
+			wantCode:   http.StatusUnauthorized,
+			wantBody:   `{"error":"unauthorized"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.GET("/flags", tt.middleware, NewFeatureFlagHandler(flags).GetFlags)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/flags", nil))
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.JSONEq(t, tt.wantBody, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Health returns a handler reporting that the process is up and serving
+// requests. It responds with a 200 status code even while some requests are
+// turned away, for example during maintenance, so that load balancers keep
+// routing to the instance, but the body then reports the cause returned by
+// degraded, such as "MAINTENANCE". Once the server starts shutting down, the
+// drain middleware answers the health check with a 503 instead.
+//
+// Parameters:
+//   - degraded: Returns the cause for which requests are being turned away,
+//     or an empty string if every request is served.
+//
+// Returns:
+//   - gin.HandlerFunc: The health check handler.
+func Health(degraded func() string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if cause := degraded(); cause != "" {
+			c.JSON(http.StatusOK, gin.H{"status": "degraded", "cause": cause})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	}
+}
+
+// Ready returns a handler reporting whether the instance is ready for
+// traffic. It runs only once the dependencies required at startup were
+// reached, so it always responds with a 200 status code, but the body lists
+// the features the instance runs without because an optional dependency, such
+// as Redis or the SMTP server, failed its startup check. Like Health, it is
+// answered with a 503 by the drain middleware once the server starts
+// shutting down.
+//
+// Parameters:
+//   - degraded: Returns the names of the dependencies that failed their
+//     startup check, or none if every feature is enabled.
+//
+// Returns:
+//   - gin.HandlerFunc: The readiness check handler.
+func Ready(degraded func() []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if names := degraded(); len(names) > 0 {
+			c.JSON(http.StatusOK, gin.H{"status": "degraded", "degraded": names})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "ready"})
+	}
+}
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealth(t *testing.T) {
+	tests := []struct {
+		name     string
+		cause    string
+		wantBody string
+	}{
+		{name: "serving", cause: "", wantBody: `{"status":"ok"}`},
+		{name: "maintenance", cause: "MAINTENANCE", wantBody: `{"status":"degraded","cause":"MAINTENANCE"}`},
+		{name: "overloaded", cause: "OVERLOADED", wantBody: `{"status":"degraded","cause":"OVERLOADED"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.GET("/healthz", Health(func() string { return tt.cause }))
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.JSONEq(t, tt.wantBody, w.Body.String())
+		})
+	}
+}
+
+func TestReady(t *testing.T) {
+	tests := []struct {
+		name     string
+		degraded []string
+		wantBody string
+	}{
+		{name: "every dependency reached", wantBody: `{"status":"ready"}`},
+		{name: "optional dependencies failed", degraded: []string{"redis", "smtp"}, wantBody: `{"status":"degraded","degraded":["redis","smtp"]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.GET("/readyz", Ready(func() []string { return tt.degraded }))
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.JSONEq(t, tt.wantBody, w.Body.String())
+		})
+	}
+}
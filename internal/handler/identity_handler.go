@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/PakornBank/learn-go/internal/apierror"
+	"github.com/PakornBank/learn-go/internal/authctx"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// LinkNonceCookieName is the cookie that ties the state of an identity link
+// to the browser that started it. It is HttpOnly, Secure and SameSite=Lax,
+// so that it comes back with the provider's redirect to the callback, and
+// only sent to LinkNonceCookiePath.
+const LinkNonceCookieName = "identity_link_nonce"
+
+// LinkNonceCookiePath is the path of the identity routes, which the link
+// nonce cookie is scoped to.
+const LinkNonceCookiePath = "/api/auth/identities"
+
+// IdentityService defines the methods that an identity handler must implement.
+type IdentityService interface {
+	// List returns the identities linked to a user.
+	// ctx: The context for the request.
+	// userID: The ID of the user whose identities to list.
+	List(ctx context.Context, userID string) ([]model.Identity, error)
+
+	// StartLink returns the URL of a provider's consent page and the nonce
+	// the link is bound to.
+	// userID: The ID of the user linking an account.
+	// provider: The name of the provider.
+	StartLink(userID, provider string) (string, string, error)
+
+	// CompleteLink links the provider account that granted a code and returns its identity.
+	// ctx: The context for the request.
+	// provider: The name of the provider.
+	// input: The code and state the provider redirected with, and the nonce.
+	CompleteLink(ctx context.Context, provider string, input service.CompleteLinkInput) (*model.Identity, error)
+
+	// Unlink removes the identity of a provider from a user.
+	// ctx: The context for the request.
+	// userID: The ID of the user unlinking an account.
+	// provider: The name of the provider.
+	Unlink(ctx context.Context, userID, provider string) error
+}
+
+// IdentityHandler handles HTTP requests for the OAuth identities linked to users.
+type IdentityHandler struct {
+	service IdentityService
+}
+
+// NewIdentityHandler creates a new instance of IdentityHandler with the provided service.
+func NewIdentityHandler(s IdentityService) *IdentityHandler {
+	return &IdentityHandler{service: s}
+}
+
+// ListIdentities handles the request for the authenticated user's linked identities.
+func (h *IdentityHandler) ListIdentities(c *gin.Context) {
+	identity, ok := authctx.User(c)
+	if !ok {
+		apierror.Respond(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	identities, err := h.service.List(c.Request.Context(), identity.UserID.String())
+	if err != nil {
+		h.respondError(c, err, "failed to list identities")
+		return
+	}
+	if identities == nil {
+		identities = []model.Identity{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": identities})
+}
+
+// StartLink handles the authenticated user's request to link an account of
+// the provider named by the "provider" path parameter. It responds with the
+// URL of the provider's consent page for the client to send the user to, and
+// sets the link nonce cookie. An unknown provider results in a 404 status code.
+func (h *IdentityHandler) StartLink(c *gin.Context) {
+	identity, ok := authctx.User(c)
+	if !ok {
+		apierror.Respond(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	authURL, nonce, err := h.service.StartLink(identity.UserID.String(), c.Param("provider"))
+	if err != nil {
+		h.respondError(c, err, "failed to start linking")
+		return
+	}
+
+	http.SetCookie(c.Writer, linkNonceCookie(nonce, int(service.IdentityLinkExpiry.Seconds())))
+	c.JSON(http.StatusOK, gin.H{"authorization_url": authURL})
+}
+
+// CompleteLink handles the provider's redirect back once the user consented.
+// It expects the "code" and "state" query parameters and the link nonce
+// cookie, and responds with the linked identity. The link is authorized by
+// the state rather than an access token, which the redirect does not carry.
+// An invalid or expired state results in a 400 status code, an account linked
+// to another user or another account of the provider already linked in a 409,
+// and a code the provider rejects in a 502.
+func (h *IdentityHandler) CompleteLink(c *gin.Context) {
+	var input service.CompleteLinkInput
+	if err := c.ShouldBindQuery(&input); err != nil {
+		apierror.RespondInvalid(c, err)
+		return
+	}
+	input.Nonce, _ = c.Cookie(LinkNonceCookieName)
+
+	linked, err := h.service.CompleteLink(c.Request.Context(), c.Param("provider"), input)
+	if err != nil {
+		h.respondError(c, err, "failed to link identity")
+		return
+	}
+
+	http.SetCookie(c.Writer, linkNonceCookie("", -1))
+	c.JSON(http.StatusOK, linked)
+}
+
+// Unlink handles the authenticated user's request to unlink their account of
+// the provider named by the "provider" path parameter, and responds with a
+// 204 status code. A provider the user has not linked results in a 404, and
+// unlinking the last identity of a user without a password in a 409.
+func (h *IdentityHandler) Unlink(c *gin.Context) {
+	identity, ok := authctx.User(c)
+	if !ok {
+		apierror.Respond(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	if err := h.service.Unlink(c.Request.Context(), identity.UserID.String(), c.Param("provider")); err != nil {
+		h.respondError(c, err, "failed to unlink identity")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// respondError writes the response for an error returned by the service,
+// using fallback as the message of unexpected errors.
+func (h *IdentityHandler) respondError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, service.ErrUnknownProvider):
+		apierror.Respond(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, repository.ErrNotFound):
+		apierror.Respond(c, http.StatusNotFound, "identity not found")
+	case errors.Is(err, service.ErrInvalidLinkState),
+		errors.Is(err, service.ErrInvalidUserID):
+		apierror.Respond(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, service.ErrIdentityTaken):
+		apierror.RespondCode(c, http.StatusConflict, "IDENTITY_TAKEN", service.ErrIdentityTaken.Error())
+	case errors.Is(err, service.ErrProviderAlreadyLinked):
+		apierror.RespondCode(c, http.StatusConflict, "PROVIDER_ALREADY_LINKED", err.Error())
+	case errors.Is(err, repository.ErrLastSignInMethod):
+		apierror.RespondCode(c, http.StatusConflict, "LAST_SIGN_IN_METHOD", repository.ErrLastSignInMethod.Error())
+	case errors.Is(err, service.ErrProviderExchange):
+		c.Error(err)
+		apierror.Respond(c, http.StatusBadGateway, service.ErrProviderExchange.Error())
+	case errors.Is(err, repository.ErrTimeout):
+		apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+	default:
+		c.Error(err)
+		apierror.Respond(c, http.StatusInternalServerError, fallback)
+	}
+}
+
+func linkNonceCookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     LinkNonceCookieName,
+		Value:    value,
+		Path:     LinkNonceCookiePath,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
@@ -0,0 +1,236 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockIdentityService struct {
+	mock.Mock
+}
+
+func (ms *MockIdentityService) List(ctx context.Context, userID string) ([]model.Identity, error) {
+	args := ms.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.Identity), args.Error(1)
+}
+
+func (ms *MockIdentityService) StartLink(userID, provider string) (string, string, error) {
+	args := ms.Called(userID, provider)
+	return args.String(0), args.String(1), args.Error(2)
+}
+
+func (ms *MockIdentityService) CompleteLink(ctx context.Context, provider string, input service.CompleteLinkInput) (*model.Identity, error) {
+	args := ms.Called(ctx, provider, input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Identity), args.Error(1)
+}
+
+func (ms *MockIdentityService) Unlink(ctx context.Context, userID, provider string) error {
+	return ms.Called(ctx, userID, provider).Error(0)
+}
+
+func setupIdentityTest(middleware gin.HandlerFunc) (*gin.Engine, *MockIdentityService) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockIdentityService)
+	handler := NewIdentityHandler(mockService)
+
+	router := gin.New()
+	router.GET("/api/auth/identities", middleware, handler.ListIdentities)
+	router.POST("/api/auth/identities/:provider/link", middleware, handler.StartLink)
+	router.GET("/api/auth/identities/:provider/callback", handler.CompleteLink)
+	router.DELETE("/api/auth/identities/:provider", middleware, handler.Unlink)
+
+	return router, mockService
+}
+
+func TestNewIdentityHandler(t *testing.T) {
+	service := new(MockIdentityService)
+	handler := NewIdentityHandler(service)
+
+	assert.NotNil(t, handler)
+	assert.Equal(t, service, handler.service)
+}
+
+func TestIdentityHandler_ListIdentities(t *testing.T) {
+	userID := uuid.New()
+	router, mockService := setupIdentityTest(authenticatedAs(userID.String()))
+	mockService.On("List", mock.Anything, userID.String()).
+		Return([]model.Identity{{ID: uuid.New(), UserID: userID, Provider: "github", ProviderUserID: "42"}}, nil)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/auth/identities", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var res struct {
+		Data []map[string]interface{} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	require.Len(t, res.Data, 1)
+	assert.Equal(t, "github", res.Data[0]["provider"])
+	assert.NotContains(t, res.Data[0], "user_id")
+	mockService.AssertExpectations(t)
+}
+
+func TestIdentityHandler_StartLink(t *testing.T) {
+	userID := uuid.New()
+
+	t.Run("redirect URL and nonce cookie", func(t *testing.T) {
+		router, mockService := setupIdentityTest(authenticatedAs(userID.String()))
+		mockService.On("StartLink", userID.String(), "github").Return("https://provider.example/authorize?state=s", "nonce", nil)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/auth/identities/github/link", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"authorization_url": "https://provider.example/authorize?state=s"}`, w.Body.String())
+		cookies := w.Result().Cookies()
+		require.Len(t, cookies, 1)
+		assert.Equal(t, LinkNonceCookieName, cookies[0].Name)
+		assert.Equal(t, "nonce", cookies[0].Value)
+		assert.Equal(t, LinkNonceCookiePath, cookies[0].Path)
+		assert.True(t, cookies[0].HttpOnly)
+		assert.Equal(t, http.SameSiteLaxMode, cookies[0].SameSite)
+	})
+
+	t.Run("unknown provider", func(t *testing.T) {
+		router, mockService := setupIdentityTest(authenticatedAs(userID.String()))
+		mockService.On("StartLink", userID.String(), "myspace").Return("", "", service.ErrUnknownProvider)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/auth/identities/myspace/link", nil))
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Empty(t, w.Result().Cookies())
+	})
+}
+
+func TestIdentityHandler_CompleteLink(t *testing.T) {
+	identity := &model.Identity{ID: uuid.New(), Provider: "github", ProviderUserID: "42"}
+	input := service.CompleteLinkInput{Code: "code", State: "state", Nonce: "nonce"}
+
+	tests := []struct {
+		name     string
+		query    string
+		mockFn   func(*MockIdentityService)
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:  "linked",
+			query: "?code=code&state=state",
+			mockFn: func(ms *MockIdentityService) {
+				ms.On("CompleteLink", mock.Anything, "github", input).Return(identity, nil)
+			},
+			wantCode: http.StatusOK,
+			wantBody: `"provider_user_id":"42"`,
+		},
+		{
+			name:     "missing code",
+			query:    "?state=state",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:  "invalid state",
+			query: "?code=code&state=state",
+			mockFn: func(ms *MockIdentityService) {
+				ms.On("CompleteLink", mock.Anything, "github", input).Return(nil, service.ErrInvalidLinkState)
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: service.ErrInvalidLinkState.Error(),
+		},
+		{
+			name:  "account linked to another user",
+			query: "?code=code&state=state",
+			mockFn: func(ms *MockIdentityService) {
+				ms.On("CompleteLink", mock.Anything, "github", input).Return(nil, service.ErrIdentityTaken)
+			},
+			wantCode: http.StatusConflict,
+			wantBody: "IDENTITY_TAKEN",
+		},
+		{
+			name:  "another account of the provider linked",
+			query: "?code=code&state=state",
+			mockFn: func(ms *MockIdentityService) {
+				ms.On("CompleteLink", mock.Anything, "github", input).Return(nil, service.ErrProviderAlreadyLinked)
+			},
+			wantCode: http.StatusConflict,
+			wantBody: "PROVIDER_ALREADY_LINKED",
+		},
+		{
+			name:  "code rejected by the provider",
+			query: "?code=code&state=state",
+			mockFn: func(ms *MockIdentityService) {
+				ms.On("CompleteLink", mock.Anything, "github", input).Return(nil, errors.Join(service.ErrProviderExchange, errors.New("invalid_grant")))
+			},
+			wantCode: http.StatusBadGateway,
+			wantBody: service.ErrProviderExchange.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mockService := setupIdentityTest(func(c *gin.Context) {})
+			if tt.mockFn != nil {
+				tt.mockFn(mockService)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/auth/identities/github/callback"+tt.query, nil)
+			req.AddCookie(&http.Cookie{Name: LinkNonceCookieName, Value: "nonce"})
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Contains(t, w.Body.String(), tt.wantBody)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestIdentityHandler_Unlink(t *testing.T) {
+	userID := uuid.New()
+
+	tests := []struct {
+		name     string
+		err      error
+		wantCode int
+		wantBody string
+	}{
+		{name: "unlinked", wantCode: http.StatusNoContent},
+		{name: "last sign-in method", err: repository.ErrLastSignInMethod, wantCode: http.StatusConflict, wantBody: "LAST_SIGN_IN_METHOD"},
+		{name: "not linked", err: repository.ErrNotFound, wantCode: http.StatusNotFound, wantBody: "identity not found"},
+		{name: "database timeout", err: repository.ErrTimeout, wantCode: http.StatusGatewayTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mockService := setupIdentityTest(authenticatedAs(userID.String()))
+			mockService.On("Unlink", mock.Anything, userID.String(), "github").Return(tt.err)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/auth/identities/github", nil))
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Contains(t, w.Body.String(), tt.wantBody)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/PakornBank/learn-go/internal/apierror"
+	"github.com/PakornBank/learn-go/internal/authctx"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// ImpersonationService defines the methods that an impersonation handler must implement.
+type ImpersonationService interface {
+	// Impersonate returns a short-lived access token that lets an admin act as a user.
+	// ctx: The context for the request.
+	// actorID: The ID of the admin.
+	// userID: The ID of the user to act as.
+	Impersonate(ctx context.Context, actorID, userID string) (string, error)
+}
+
+// ImpersonationHandler handles HTTP requests of admins who need to act as another user.
+type ImpersonationHandler struct {
+	service ImpersonationService
+}
+
+// NewImpersonationHandler creates a new instance of ImpersonationHandler with the provided service.
+func NewImpersonationHandler(s ImpersonationService) *ImpersonationHandler {
+	return &ImpersonationHandler{service: s}
+}
+
+// Impersonate handles an admin's request to act as the user identified by the
+// "id" path parameter and responds with a 201 status code and a short-lived
+// access token for that user. Requests made with the token are audited and
+// cannot reach admin or destructive routes. An invalid user ID results in a
+// 400 status code, and an unknown user in a 404.
+func (h *ImpersonationHandler) Impersonate(c *gin.Context) {
+	identity, ok := authctx.User(c)
+	if !ok {
+		apierror.Respond(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	token, err := h.service.Impersonate(c.Request.Context(), identity.UserID.String(), c.Param("id"))
+	switch {
+	case err == nil:
+		c.JSON(http.StatusCreated, gin.H{"token": token})
+	case errors.Is(err, service.ErrInvalidUserID):
+		apierror.Respond(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, service.ErrUserNotFound):
+		apierror.Respond(c, http.StatusNotFound, service.ErrUserNotFound.Error())
+	case errors.Is(err, repository.ErrTimeout):
+		apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+	default:
+		c.Error(err)
+		apierror.Respond(c, http.StatusInternalServerError, "failed to impersonate user")
+	}
+}
@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockImpersonationService struct {
+	mock.Mock
+}
+
+func (ms *MockImpersonationService) Impersonate(ctx context.Context, actorID, userID string) (string, error) {
+	args := ms.Called(ctx, actorID, userID)
+	return args.String(0), args.Error(1)
+}
+
+func TestNewImpersonationHandler(t *testing.T) {
+	service := new(MockImpersonationService)
+	handler := NewImpersonationHandler(service)
+
+	assert.NotNil(t, handler)
+	assert.Equal(t, service, handler.service)
+}
+
+func TestImpersonationHandler_Impersonate(t *testing.T) {
+	const (
+		adminID = "9e2d4c1a-6b3f-4a8e-8d7c-5f1e2a3b4c6d"
+		userID  = "5b0c8f3e-2a1d-4c6b-9e7f-3d2a1b0c9e8f"
+	)
+	authenticated := authenticatedAs(adminID)
+
+	tests := []struct {
+		name         string
+		middleware   gin.HandlerFunc
+		mockFn       func(*MockImpersonationService)
+		wantCode     int
+		wantAttached bool
+		wantBody     string
+	}{
+		{
+			name:       "issued",
+			middleware: authenticated,
+			mockFn: func(ms *MockImpersonationService) {
+				ms.On("Impersonate", mock.Anything, adminID, userID).Return("impersonation-token", nil)
+			},
+			wantCode: http.StatusCreated,
+			wantBody: `{"token":"impersonation-token"}`,
+		},
+		{
+			name:       "not authenticated",
+			middleware: func(c *gin.Context) {},
+			wantCode:   http.StatusUnauthorized,
+			wantBody:   `{"error":"unauthorized"}`,
+		},
+		{
+			name:       "invalid user ID",
+			middleware: authenticated,
+			mockFn: func(ms *MockImpersonationService) {
+				ms.On("Impersonate", mock.Anything, adminID, userID).Return("", service.ErrInvalidUserID)
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"error":"` + service.ErrInvalidUserID.Error() + `"}`,
+		},
+		{
+			name:       "user not found",
+			middleware: authenticated,
+			mockFn: func(ms *MockImpersonationService) {
+				ms.On("Impersonate", mock.Anything, adminID, userID).Return("", service.ErrUserNotFound)
+			},
+			wantCode: http.StatusNotFound,
+			wantBody: `{"error":"user not found"}`,
+		},
+		{
+			name:       "database timeout",
+			middleware: authenticated,
+			mockFn: func(ms *MockImpersonationService) {
+				ms.On("Impersonate", mock.Anything, adminID, userID).Return("", repository.ErrTimeout)
+			},
+			wantCode: http.StatusGatewayTimeout,
+			wantBody: `{"error":"` + repository.ErrTimeout.Error() + `"}`,
+		},
+		{
+			name:       "unexpected error",
+			middleware: authenticated,
+			mockFn: func(ms *MockImpersonationService) {
+				ms.On("Impersonate", mock.Anything, adminID, userID).Return("", errors.New("connection reset"))
+			},
+			wantCode:     http.StatusInternalServerError,
+			wantAttached: true,
+			wantBody:     `{"error":"failed to impersonate user"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			mockService := new(MockImpersonationService)
+			if tt.mockFn != nil {
+				tt.mockFn(mockService)
+			}
+			var attached []error
+			router := gin.New()
+			router.POST("/api/admin/users/:id/impersonate", collectErrors(&attached), tt.middleware, NewImpersonationHandler(mockService).Impersonate)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/admin/users/"+userID+"/impersonate", nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Equal(t, tt.wantAttached, len(attached) > 0)
+			assert.JSONEq(t, tt.wantBody, w.Body.String())
+			mockService.AssertExpectations(t)
+		})
+	}
+}
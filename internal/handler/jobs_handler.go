@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/jobs"
+	"github.com/gin-gonic/gin"
+)
+
+// JobScheduler defines the methods that a jobs handler must implement.
+// It is satisfied by *jobs.Scheduler.
+type JobScheduler interface {
+	// Statuses returns the status of every background job.
+	Statuses() []jobs.Status
+}
+
+// JobStatusResponse describes a background job in responses.
+type JobStatusResponse struct {
+	Name           string     `json:"name"`
+	Interval       string     `json:"interval"`
+	Running        bool       `json:"running"`
+	Runs           int        `json:"runs"`
+	Failures       int        `json:"failures"`
+	Skips          int        `json:"skips"`
+	LastRunAt      *time.Time `json:"last_run_at"`
+	LastDurationMS int64      `json:"last_duration_ms"`
+	LastError      string     `json:"last_error,omitempty"`
+}
+
+// JobsHandler handles HTTP requests for inspecting background jobs.
+type JobsHandler struct {
+	scheduler JobScheduler
+}
+
+// NewJobsHandler creates a new instance of JobsHandler with the provided scheduler.
+func NewJobsHandler(scheduler JobScheduler) *JobsHandler {
+	return &JobsHandler{scheduler: scheduler}
+}
+
+// ListJobs handles the request for the status of the background jobs of this
+// replica. It responds with each job's interval, run counts, and the time,
+// duration and error of its last run, which is null until the first run ends.
+func (h *JobsHandler) ListJobs(c *gin.Context) {
+	statuses := h.scheduler.Statuses()
+	data := make([]JobStatusResponse, len(statuses))
+	for i, status := range statuses {
+		data[i] = JobStatusResponse{
+			Name:           status.Name,
+			Interval:       status.Interval.String(),
+			Running:        status.Running,
+			Runs:           status.Runs,
+			Failures:       status.Failures,
+			Skips:          status.Skips,
+			LastDurationMS: status.LastDuration.Milliseconds(),
+			LastError:      status.LastError,
+		}
+		if !status.LastRun.IsZero() {
+			lastRun := status.LastRun
+			data[i].LastRunAt = &lastRun
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": data})
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/jobs"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeJobScheduler []jobs.Status
+
+func (s fakeJobScheduler) Statuses() []jobs.Status {
+	return s
+}
+
+func TestNewJobsHandler(t *testing.T) {
+	scheduler := fakeJobScheduler{}
+	handler := NewJobsHandler(scheduler)
+
+	assert.NotNil(t, handler)
+	assert.Equal(t, scheduler, handler.scheduler)
+}
+
+func TestJobsHandler_ListJobs(t *testing.T) {
+	lastRun := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	scheduler := fakeJobScheduler{
+		{
+			Name:         "account-purge",
+			Interval:     time.Hour,
+			Runs:         3,
+			Failures:     1,
+			LastRun:      lastRun,
+			LastDuration: 1500 * time.Millisecond,
+			LastError:    "connection reset",
+		},
+		{Name: "outbox", Interval: 5 * time.Second, Running: true, Skips: 2},
+	}
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/admin/jobs", NewJobsHandler(scheduler).ListJobs)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/jobs", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"data": [
+		{"name": "account-purge", "interval": "1h0m0s", "running": false, "runs": 3, "failures": 1, "skips": 0,
+		 "last_run_at": "2024-01-01T12:00:00Z", "last_duration_ms": 1500, "last_error": "connection reset"},
+		{"name": "outbox", "interval": "5s", "running": true, "runs": 0, "failures": 0, "skips": 2,
+		 "last_run_at": null, "last_duration_ms": 0}
+	]}`, w.Body.String())
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/PakornBank/learn-go/internal/apierror"
+	"github.com/PakornBank/learn-go/internal/authctx"
+	"github.com/PakornBank/learn-go/internal/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// LogLevel defines the methods that a log level handler must implement.
+// It is satisfied by *slog.LevelVar.
+type LogLevel interface {
+	// Level returns the current minimum log level.
+	Level() slog.Level
+
+	// Set changes the minimum log level.
+	// level: The new minimum level.
+	Set(level slog.Level)
+}
+
+// LogLevelInput is the body of a request to change the log level.
+type LogLevelInput struct {
+	Level string `json:"level" binding:"required"`
+}
+
+// LogLevelHandler handles HTTP requests for reading and changing the log level.
+type LogLevelHandler struct {
+	level LogLevel
+}
+
+// NewLogLevelHandler creates a new instance of LogLevelHandler with the provided level.
+func NewLogLevelHandler(level LogLevel) *LogLevelHandler {
+	return &LogLevelHandler{level: level}
+}
+
+// GetLogLevel handles the request for the current log level.
+// It responds with the level name, such as "info".
+func (h *LogLevelHandler) GetLogLevel(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"level": logger.LevelName(h.level.Level())})
+}
+
+// SetLogLevel handles the request to change the log level.
+// It expects a JSON payload with a "level" of debug, info, warn or error and
+// responds with the resulting level. The change applies immediately to every
+// logger, including database logs, and lasts until it is changed again or the
+// process restarts.
+func (h *LogLevelHandler) SetLogLevel(c *gin.Context) {
+	var input LogLevelInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.RespondInvalid(c, err)
+		return
+	}
+
+	level, err := logger.ParseLevel(input.Level)
+	if err != nil {
+		apierror.Respond(c, http.StatusBadRequest, "level must be debug, info, warn or error")
+		return
+	}
+
+	previous := h.level.Level()
+	h.level.Set(level)
+	admin, _ := authctx.User(c)
+	slog.InfoContext(c.Request.Context(), "log level changed",
+		"from", logger.LevelName(previous), "to", logger.LevelName(level), "user_id", admin.UserID)
+
+	c.JSON(http.StatusOK, gin.H{"level": logger.LevelName(level)})
+}
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupLogLevelTest(level *slog.LevelVar) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	handler := NewLogLevelHandler(level)
+	router := gin.New()
+	router.GET("/api/admin/log-level", handler.GetLogLevel)
+	router.PUT("/api/admin/log-level", handler.SetLogLevel)
+	return router
+}
+
+func putLogLevel(router *gin.Engine, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPut, "/api/admin/log-level", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestNewLogLevelHandler(t *testing.T) {
+	level := new(slog.LevelVar)
+	handler := NewLogLevelHandler(level)
+
+	assert.NotNil(t, handler)
+	assert.Equal(t, level, handler.level)
+}
+
+func TestLogLevelHandler_GetLogLevel(t *testing.T) {
+	level := new(slog.LevelVar)
+	level.Set(slog.LevelWarn)
+
+	w := httptest.NewRecorder()
+	setupLogLevelTest(level).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/log-level", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"level": "warn"}`, w.Body.String())
+}
+
+func TestLogLevelHandler_SetLogLevel(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		wantCode  int
+		wantLevel slog.Level
+		wantName  string
+	}{
+		{name: "debug", body: `{"level": "debug"}`, wantCode: http.StatusOK, wantLevel: slog.LevelDebug, wantName: "debug"},
+		{name: "warn", body: `{"level": "warn"}`, wantCode: http.StatusOK, wantLevel: slog.LevelWarn, wantName: "warn"},
+		{name: "case-insensitive", body: `{"level": "ERROR"}`, wantCode: http.StatusOK, wantLevel: slog.LevelError, wantName: "error"},
+		{name: "unknown level", body: `{"level": "verbose"}`, wantCode: http.StatusBadRequest, wantLevel: slog.LevelInfo},
+		{name: "missing level", body: `{}`, wantCode: http.StatusBadRequest, wantLevel: slog.LevelInfo},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			level := new(slog.LevelVar)
+
+			w := putLogLevel(setupLogLevelTest(level), tt.body)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Equal(t, tt.wantLevel, level.Level())
+			if tt.wantCode == http.StatusOK {
+				var res map[string]interface{}
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+				assert.Equal(t, tt.wantName, res["level"])
+			}
+		})
+	}
+}
+
+func TestLogLevelHandler_TogglesDebugLogs(t *testing.T) {
+	level := new(slog.LevelVar)
+	var logs bytes.Buffer
+	log := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: level}))
+	router := setupLogLevelTest(level)
+
+	log.Debug("before")
+	assert.NotContains(t, logs.String(), "before")
+
+	require.Equal(t, http.StatusOK, putLogLevel(router, `{"level": "debug"}`).Code)
+	log.Debug("while debugging")
+	assert.Contains(t, logs.String(), "while debugging")
+
+	require.Equal(t, http.StatusOK, putLogLevel(router, `{"level": "info"}`).Code)
+	log.Debug("after")
+	assert.NotContains(t, logs.String(), "after")
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/PakornBank/learn-go/internal/apierror"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// LoginAlertService defines the methods that a login alert handler must implement.
+type LoginAlertService interface {
+	// Report revokes the session of a login the user says was not them and replaces their password.
+	// ctx: The context for the request.
+	// input: The token from the new device email and the new password.
+	Report(ctx context.Context, input service.ReportLoginInput) error
+}
+
+// LoginAlertHandler handles HTTP requests from the links in new device emails.
+type LoginAlertHandler struct {
+	service LoginAlertService
+}
+
+// NewLoginAlertHandler creates a new instance of LoginAlertHandler with the provided service.
+func NewLoginAlertHandler(s LoginAlertService) *LoginAlertHandler {
+	return &LoginAlertHandler{service: s}
+}
+
+// ReportLogin handles the "this wasn't me" link of a new device email. It
+// expects a JSON payload with the token from the link and a new password, and
+// signs the reported device and every other session out. An unknown or
+// expired token, or a breached password, results in a 400 status code.
+func (h *LoginAlertHandler) ReportLogin(c *gin.Context) {
+	var input service.ReportLoginInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.RespondInvalid(c, err)
+		return
+	}
+
+	if err := h.service.Report(c.Request.Context(), input); err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidLoginAlertToken):
+			apierror.Respond(c, http.StatusBadRequest, service.ErrInvalidLoginAlertToken.Error())
+		case errors.Is(err, service.ErrPasswordBreached):
+			apierror.Respond(c, http.StatusBadRequest, err.Error())
+		case errors.Is(err, repository.ErrTimeout):
+			apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+		default:
+			c.Error(err)
+			apierror.Respond(c, http.StatusInternalServerError, "failed to report login")
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "signed out of all devices and password changed"})
+}
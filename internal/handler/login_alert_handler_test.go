@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockLoginAlertService struct {
+	mock.Mock
+}
+
+func (ms *MockLoginAlertService) Report(ctx context.Context, input service.ReportLoginInput) error {
+	args := ms.Called(ctx, input)
+	return args.Error(0)
+}
+
+func TestNewLoginAlertHandler(t *testing.T) {
+	service := new(MockLoginAlertService)
+	handler := NewLoginAlertHandler(service)
+
+	assert.NotNil(t, handler)
+	assert.Equal(t, service, handler.service)
+}
+
+func TestLoginAlertHandler_ReportLogin(t *testing.T) {
+	const body = `{"token": "report-token", "new_password": "new-password"}`
+	input := service.ReportLoginInput{Token: "report-token", NewPassword: "new-password"}
+
+	tests := []struct {
+		name         string
+		body         string
+		mockFn       func(*MockLoginAlertService)
+		wantCode     int
+		wantAttached bool
+		wantBody     string
+	}{
+		{
+			name: "reported",
+			body: body,
+			mockFn: func(ms *MockLoginAlertService) {
+				ms.On("Report", mock.Anything, input).Return(nil)
+			},
+			wantCode: http.StatusOK,
+			wantBody: `{"message":"signed out of all devices and password changed"}`,
+		},
+		{
+			name:     "missing token",
+			body:     `{"new_password": "new-password"}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "short password",
+			body:     `{"token": "report-token", "new_password": "short"}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name: "invalid token",
+			body: body,
+			mockFn: func(ms *MockLoginAlertService) {
+				ms.On("Report", mock.Anything, input).Return(service.ErrInvalidLoginAlertToken)
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"error":"` + service.ErrInvalidLoginAlertToken.Error() + `"}`,
+		},
+		{
+			name: "breached password",
+			body: body,
+			mockFn: func(ms *MockLoginAlertService) {
+				ms.On("Report", mock.Anything, input).Return(service.ErrPasswordBreached)
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"error":"` + service.ErrPasswordBreached.Error() + `"}`,
+		},
+		{
+			name: "database timeout",
+			body: body,
+			mockFn: func(ms *MockLoginAlertService) {
+				ms.On("Report", mock.Anything, input).Return(repository.ErrTimeout)
+			},
+			wantCode: http.StatusGatewayTimeout,
+			wantBody: `{"error":"` + repository.ErrTimeout.Error() + `"}`,
+		},
+		{
+			name: "database error",
+			body: body,
+			mockFn: func(ms *MockLoginAlertService) {
+				ms.On("Report", mock.Anything, input).Return(errors.New("connection reset"))
+			},
+			wantCode:     http.StatusInternalServerError,
+			wantAttached: true,
+			wantBody:     `{"error":"failed to report login"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			mockService := new(MockLoginAlertService)
+			if tt.mockFn != nil {
+				tt.mockFn(mockService)
+			}
+			var attached []error
+			router := gin.New()
+			router.POST("/api/auth/login-alerts/report", collectErrors(&attached), NewLoginAlertHandler(mockService).ReportLogin)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/auth/login-alerts/report", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Equal(t, tt.wantAttached, len(attached) > 0)
+			if tt.wantBody != "" {
+				assert.JSONEq(t, tt.wantBody, w.Body.String())
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
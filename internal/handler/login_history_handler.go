@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/PakornBank/learn-go/internal/apierror"
+	"github.com/PakornBank/learn-go/internal/authctx"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// LoginHistoryService defines the methods that a login history handler must implement.
+type LoginHistoryService interface {
+	// ListForUser returns a page of a user's login events, newest first,
+	// along with the cursor for the next page.
+	// ctx: The context for the request.
+	// userID: The ID of the user whose events to list.
+	// cursor: The opaque cursor returned by a previous call, or empty to start.
+	// limit: The maximum number of events to return.
+	ListForUser(ctx context.Context, userID string, cursor string, limit int) ([]model.LoginEvent, string, error)
+}
+
+// LoginHistoryHandler handles HTTP requests for login history.
+type LoginHistoryHandler struct {
+	service LoginHistoryService
+}
+
+// NewLoginHistoryHandler creates a new instance of LoginHistoryHandler with the provided service.
+func NewLoginHistoryHandler(s LoginHistoryService) *LoginHistoryHandler {
+	return &LoginHistoryHandler{service: s}
+}
+
+// GetOwnHistory handles the request for the authenticated user's own login history.
+// It reads the user ID set by the authentication middleware.
+func (h *LoginHistoryHandler) GetOwnHistory(c *gin.Context) {
+	identity, ok := authctx.User(c)
+	if !ok {
+		apierror.Respond(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	h.respond(c, identity.UserID.String())
+}
+
+// GetUserHistory handles the request for the login history of the user
+// identified by the "id" path parameter. It is intended for administrators.
+func (h *LoginHistoryHandler) GetUserHistory(c *gin.Context) {
+	h.respond(c, c.Param("id"))
+}
+
+// respond lists the login events of userID using the "cursor" and "limit" query
+// parameters and writes them in the same envelope as other paginated endpoints.
+// An invalid user ID, cursor, or limit results in a 400 status code, and a
+// database timeout in a 504.
+func (h *LoginHistoryHandler) respond(c *gin.Context, userID string) {
+	limit, ok := parseLimit(c)
+	if !ok {
+		return
+	}
+
+	events, nextCursor, err := h.service.ListForUser(c.Request.Context(), userID, c.Query("cursor"), limit)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidLimit),
+			errors.Is(err, service.ErrInvalidUserID),
+			errors.Is(err, repository.ErrInvalidCursor):
+			apierror.Respond(c, http.StatusBadRequest, err.Error())
+		case errors.Is(err, repository.ErrTimeout):
+			apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+		default:
+			c.Error(err)
+			apierror.Respond(c, http.StatusInternalServerError, "failed to list login history")
+		}
+		return
+	}
+
+	if events == nil {
+		events = []model.LoginEvent{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": events, "next_cursor": nextCursor})
+}
@@ -0,0 +1,212 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockLoginHistoryService struct {
+	mock.Mock
+}
+
+func (ms *MockLoginHistoryService) ListForUser(ctx context.Context, userID string, cursor string, limit int) ([]model.LoginEvent, string, error) {
+	args := ms.Called(ctx, userID, cursor, limit)
+	if args.Get(0) == nil {
+		return nil, args.String(1), args.Error(2)
+	}
+	return args.Get(0).([]model.LoginEvent), args.String(1), args.Error(2)
+}
+
+func setupLoginHistoryTest(middleware gin.HandlerFunc) (*gin.Engine, *MockLoginHistoryService) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockLoginHistoryService)
+	handler := NewLoginHistoryHandler(mockService)
+
+	router := gin.New()
+	router.GET("/api/auth/login-history", middleware, handler.GetOwnHistory)
+	router.GET("/api/admin/users/:id/login-history", handler.GetUserHistory)
+
+	return router, mockService
+}
+
+func TestNewLoginHistoryHandler(t *testing.T) {
+	service := new(MockLoginHistoryService)
+	handler := NewLoginHistoryHandler(service)
+
+	assert.NotNil(t, handler)
+	assert.Equal(t, service, handler.service)
+}
+
+func TestLoginHistoryHandler_GetOwnHistory(t *testing.T) {
+	userID := uuid.New()
+	event := model.LoginEvent{ID: uuid.New(), UserID: &userID, Email: "test@example.com", Success: true}
+
+	tests := []struct {
+		name           string
+		query          string
+		setupAuth      func(*gin.Context)
+		mockFn         func(*MockLoginHistoryService)
+		wantCode       int
+		wantLen        int
+		wantNextCursor string
+		errContains    string
+	}{
+		{
+			name:      "successful listing",
+			setupAuth: authenticatedAs(userID.String()),
+			mockFn: func(ms *MockLoginHistoryService) {
+				ms.On("ListForUser", mock.Anything, userID.String(), "", service.DefaultListLimit).
+					Return([]model.LoginEvent{event}, "next", nil)
+			},
+			wantCode:       http.StatusOK,
+			wantLen:        1,
+			wantNextCursor: "next",
+		},
+		{
+			name:      "empty page",
+			query:     "?cursor=abc&limit=5",
+			setupAuth: authenticatedAs(userID.String()),
+			mockFn: func(ms *MockLoginHistoryService) {
+				ms.On("ListForUser", mock.Anything, userID.String(), "abc", 5).Return(nil, "", nil)
+			},
+			wantCode: http.StatusOK,
+			wantLen:  0,
+		},
+		{
+			name:        "unauthorized",
+			setupAuth:   func(c *gin.Context) {},
+			wantCode:    http.StatusUnauthorized,
+			errContains: "unauthorized",
+		},
+		{
+			name:        "non-numeric limit",
+			query:       "?limit=ten",
+			setupAuth:   authenticatedAs(userID.String()),
+			wantCode:    http.StatusBadRequest,
+			errContains: service.ErrInvalidLimit.Error(),
+		},
+		{
+			name:      "database timeout",
+			setupAuth: authenticatedAs(userID.String()),
+			mockFn: func(ms *MockLoginHistoryService) {
+				ms.On("ListForUser", mock.Anything, userID.String(), "", service.DefaultListLimit).
+					Return(nil, "", repository.ErrTimeout)
+			},
+			wantCode:    http.StatusGatewayTimeout,
+			errContains: repository.ErrTimeout.Error(),
+		},
+		{
+			name:      "service error",
+			setupAuth: authenticatedAs(userID.String()),
+			mockFn: func(ms *MockLoginHistoryService) {
+				ms.On("ListForUser", mock.Anything, userID.String(), "", service.DefaultListLimit).
+					Return(nil, "", errors.New("service error"))
+			},
+			wantCode:    http.StatusInternalServerError,
+			errContains: "failed to list login history",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mockService := setupLoginHistoryTest(func(c *gin.Context) {
+				tt.setupAuth(c)
+				c.Next()
+			})
+			if tt.mockFn != nil {
+				tt.mockFn(mockService)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/auth/login-history"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+
+			var res map[string]interface{}
+			err := json.Unmarshal(w.Body.Bytes(), &res)
+			assert.NoError(t, err)
+
+			if tt.wantCode == http.StatusOK {
+				assert.Len(t, res["data"], tt.wantLen)
+				assert.Equal(t, tt.wantNextCursor, res["next_cursor"])
+			} else {
+				assert.Contains(t, res["error"], tt.errContains)
+			}
+
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestLoginHistoryHandler_GetUserHistory(t *testing.T) {
+	userID := uuid.New()
+
+	tests := []struct {
+		name        string
+		id          string
+		mockFn      func(*MockLoginHistoryService)
+		wantCode    int
+		errContains string
+	}{
+		{
+			name: "successful listing",
+			id:   userID.String(),
+			mockFn: func(ms *MockLoginHistoryService) {
+				ms.On("ListForUser", mock.Anything, userID.String(), "", service.DefaultListLimit).
+					Return([]model.LoginEvent{{ID: uuid.New(), UserID: &userID}}, "", nil)
+			},
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "invalid user id",
+			id:   "not-a-uuid",
+			mockFn: func(ms *MockLoginHistoryService) {
+				ms.On("ListForUser", mock.Anything, "not-a-uuid", "", service.DefaultListLimit).
+					Return(nil, "", service.ErrInvalidUserID)
+			},
+			wantCode:    http.StatusBadRequest,
+			errContains: service.ErrInvalidUserID.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mockService := setupLoginHistoryTest(func(c *gin.Context) { c.Next() })
+			tt.mockFn(mockService)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/admin/users/"+tt.id+"/login-history", nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+
+			var res map[string]interface{}
+			err := json.Unmarshal(w.Body.Bytes(), &res)
+			assert.NoError(t, err)
+
+			if tt.wantCode == http.StatusOK {
+				assert.Len(t, res["data"], 1)
+			} else {
+				assert.Contains(t, res["error"], tt.errContains)
+			}
+
+			mockService.AssertExpectations(t)
+		})
+	}
+}
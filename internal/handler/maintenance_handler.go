@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/PakornBank/learn-go/internal/apierror"
+	"github.com/PakornBank/learn-go/internal/authctx"
+	"github.com/gin-gonic/gin"
+)
+
+// MaintenanceSwitch defines the methods that a maintenance handler must implement.
+type MaintenanceSwitch interface {
+	// Active reports whether maintenance mode is on.
+	Active() bool
+
+	// SetActive turns maintenance mode on or off.
+	// active: Whether requests should be rejected.
+	SetActive(active bool)
+}
+
+// MaintenanceInput is the body of a request to toggle maintenance mode.
+// Enabled is a pointer so that an explicit false can be told apart from a missing field.
+type MaintenanceInput struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
+
+// MaintenanceHandler handles HTTP requests for toggling maintenance mode.
+type MaintenanceHandler struct {
+	maintenance MaintenanceSwitch
+}
+
+// NewMaintenanceHandler creates a new instance of MaintenanceHandler with the provided switch.
+func NewMaintenanceHandler(m MaintenanceSwitch) *MaintenanceHandler {
+	return &MaintenanceHandler{maintenance: m}
+}
+
+// SetMaintenance handles the request to turn maintenance mode on or off.
+// It expects a JSON payload with an "enabled" boolean and responds with the
+// resulting state. The change applies immediately and lasts until it is
+// toggled again or the process restarts.
+func (h *MaintenanceHandler) SetMaintenance(c *gin.Context) {
+	var input MaintenanceInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.RespondInvalid(c, err)
+		return
+	}
+
+	h.maintenance.SetActive(*input.Enabled)
+	admin, _ := authctx.User(c)
+	slog.InfoContext(c.Request.Context(), "maintenance mode changed", "enabled", *input.Enabled, "user_id", admin.UserID)
+
+	c.JSON(http.StatusOK, gin.H{"enabled": h.maintenance.Active()})
+}
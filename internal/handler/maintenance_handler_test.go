@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PakornBank/learn-go/internal/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewMaintenanceHandler(t *testing.T) {
+	mode := &middleware.MaintenanceMode{}
+	handler := NewMaintenanceHandler(mode)
+
+	assert.NotNil(t, handler)
+	assert.Equal(t, mode, handler.maintenance)
+}
+
+func TestMaintenanceHandler_SetMaintenance(t *testing.T) {
+	tests := []struct {
+		name       string
+		initial    bool
+		body       string
+		wantCode   int
+		wantActive bool
+	}{
+		{name: "enable", initial: false, body: `{"enabled": true}`, wantCode: http.StatusOK, wantActive: true},
+		{name: "disable", initial: true, body: `{"enabled": false}`, wantCode: http.StatusOK, wantActive: false},
+		{name: "missing field", initial: true, body: `{}`, wantCode: http.StatusBadRequest, wantActive: true},
+		{name: "not a boolean", initial: false, body: `{"enabled": "yes"}`, wantCode: http.StatusBadRequest, wantActive: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			mode := &middleware.MaintenanceMode{}
+			mode.SetActive(tt.initial)
+			router := gin.New()
+			router.POST("/api/admin/maintenance", NewMaintenanceHandler(mode).SetMaintenance)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/admin/maintenance", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Equal(t, tt.wantActive, mode.Active())
+			if tt.wantCode == http.StatusOK {
+				var res map[string]interface{}
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+				assert.Equal(t, tt.wantActive, res["enabled"])
+			}
+		})
+	}
+}
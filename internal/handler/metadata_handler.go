@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/PakornBank/learn-go/internal/apierror"
+	"github.com/PakornBank/learn-go/internal/authctx"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
+)
+
+// MetadataService defines the methods that a metadata handler must implement.
+type MetadataService interface {
+	// Update merges patch into a user's metadata and returns the result.
+	// ctx: The context for the request.
+	// userID: The ID of the user whose metadata is updated.
+	// patch: The keys to set, or to remove when their value is null.
+	Update(ctx context.Context, userID string, patch map[string]json.RawMessage) (datatypes.JSON, error)
+}
+
+// MetadataHandler handles HTTP requests for the authenticated user's metadata.
+type MetadataHandler struct {
+	service MetadataService
+}
+
+// NewMetadataHandler creates a new instance of MetadataHandler with the provided service.
+func NewMetadataHandler(s MetadataService) *MetadataHandler {
+	return &MetadataHandler{service: s}
+}
+
+// UpdateMetadata handles the request to change the authenticated user's metadata.
+// It expects a JSON object whose keys are merged into the stored metadata, a
+// null value removing the key, and responds with the merged metadata. Values
+// that are not scalars or arrays of scalars, or a result above the size limits,
+// result in a 400 status code, and a database timeout in a 504.
+func (h *MetadataHandler) UpdateMetadata(c *gin.Context) {
+	identity, ok := authctx.User(c)
+	if !ok {
+		apierror.Respond(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var patch map[string]json.RawMessage
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, "metadata must be a JSON object")
+		return
+	}
+
+	metadata, err := h.service.Update(c.Request.Context(), identity.UserID.String(), patch)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidUserID),
+			errors.Is(err, service.ErrInvalidMetadataValue),
+			errors.Is(err, service.ErrTooManyMetadataKeys),
+			errors.Is(err, service.ErrMetadataTooLarge):
+			apierror.Respond(c, http.StatusBadRequest, err.Error())
+		case errors.Is(err, repository.ErrTimeout):
+			apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+		default:
+			c.Error(err)
+			apierror.Respond(c, http.StatusInternalServerError, "failed to update metadata")
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"metadata": json.RawMessage(metadata)})
+}
@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/datatypes"
+)
+
+type MockMetadataService struct {
+	mock.Mock
+}
+
+func (ms *MockMetadataService) Update(ctx context.Context, userID string, patch map[string]json.RawMessage) (datatypes.JSON, error) {
+	args := ms.Called(ctx, userID, patch)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(datatypes.JSON), args.Error(1)
+}
+
+func TestNewMetadataHandler(t *testing.T) {
+	service := new(MockMetadataService)
+	handler := NewMetadataHandler(service)
+
+	assert.NotNil(t, handler)
+	assert.Equal(t, service, handler.service)
+}
+
+func TestMetadataHandler_UpdateMetadata(t *testing.T) {
+	const userID = "5b0c8f3e-2a1d-4c6b-9e7f-3d2a1b0c9e8f"
+	authenticated := authenticatedAs(userID)
+	patch := map[string]json.RawMessage{"theme": json.RawMessage(`"dark"`), "locale": json.RawMessage(`null`)}
+
+	tests := []struct {
+		name         string
+		middleware   gin.HandlerFunc
+		body         string
+		mockFn       func(*MockMetadataService)
+		wantCode     int
+		wantAttached bool
+		wantBody     string
+	}{
+		{
+			name:       "merged",
+			middleware: authenticated,
+			body:       `{"theme": "dark", "locale": null}`,
+			mockFn: func(ms *MockMetadataService) {
+				ms.On("Update", mock.Anything, userID, patch).Return(datatypes.JSON(`{"beta":true,"theme":"dark"}`), nil)
+			},
+			wantCode: http.StatusOK,
+			wantBody: `{"metadata":{"beta":true,"theme":"dark"}}`,
+		},
+		{
+			name:       "not authenticated",
+			middleware: func(c *gin.Context) {},
+			body:       `{"theme": "dark"}`,
+			wantCode:   http.StatusUnauthorized,
+			wantBody:   `{"error":"unauthorized"}`,
+		},
+		{
+			name:       "not an object",
+			middleware: authenticated,
+			body:       `["theme"]`,
+			wantCode:   http.StatusBadRequest,
+			wantBody:   `{"error":"metadata must be a JSON object"}`,
+		},
+		{
+			name:       "invalid value",
+			middleware: authenticated,
+			body:       `{"theme": "dark", "locale": null}`,
+			mockFn: func(ms *MockMetadataService) {
+				ms.On("Update", mock.Anything, userID, patch).Return(nil, service.ErrInvalidMetadataValue)
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"error":"` + service.ErrInvalidMetadataValue.Error() + `"}`,
+		},
+		{
+			name:       "too large",
+			middleware: authenticated,
+			body:       `{"theme": "dark", "locale": null}`,
+			mockFn: func(ms *MockMetadataService) {
+				ms.On("Update", mock.Anything, userID, patch).Return(nil, service.ErrMetadataTooLarge)
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"error":"` + service.ErrMetadataTooLarge.Error() + `"}`,
+		},
+		{
+			name:       "database timeout",
+			middleware: authenticated,
+			body:       `{"theme": "dark", "locale": null}`,
+			mockFn: func(ms *MockMetadataService) {
+				ms.On("Update", mock.Anything, userID, patch).Return(nil, repository.ErrTimeout)
+			},
+			wantCode: http.StatusGatewayTimeout,
+			wantBody: `{"error":"` + repository.ErrTimeout.Error() + `"}`,
+		},
+		{
+			name:       "database error",
+			middleware: authenticated,
+			body:       `{"theme": "dark", "locale": null}`,
+			mockFn: func(ms *MockMetadataService) {
+				ms.On("Update", mock.Anything, userID, patch).Return(nil, errors.New("connection reset"))
+			},
+			wantCode:     http.StatusInternalServerError,
+			wantAttached: true,
+			wantBody:     `{"error":"failed to update metadata"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			mockService := new(MockMetadataService)
+			if tt.mockFn != nil {
+				tt.mockFn(mockService)
+			}
+			var attached []error
+			router := gin.New()
+			router.PATCH("/api/auth/profile/metadata", collectErrors(&attached), tt.middleware, NewMetadataHandler(mockService).UpdateMetadata)
+
+			req := httptest.NewRequest(http.MethodPatch, "/api/auth/profile/metadata", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Equal(t, tt.wantAttached, len(attached) > 0)
+			assert.JSONEq(t, tt.wantBody, w.Body.String())
+			mockService.AssertExpectations(t)
+		})
+	}
+}
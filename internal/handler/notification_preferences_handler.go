@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/PakornBank/learn-go/internal/apierror"
+	"github.com/PakornBank/learn-go/internal/authctx"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// NotificationPreferencesService defines the methods that a notification preferences handler must implement.
+type NotificationPreferencesService interface {
+	// Get returns a user's notification preferences.
+	// ctx: The context for the request.
+	// userID: The ID of the user whose preferences are returned.
+	Get(ctx context.Context, userID string) (*model.NotificationPreferences, error)
+
+	// Update replaces a user's notification preferences and returns them.
+	// ctx: The context for the request.
+	// userID: The ID of the user whose preferences are replaced.
+	// preferences: The new preferences, which must keep security alerts on.
+	Update(ctx context.Context, userID string, preferences model.NotificationPreferences) (*model.NotificationPreferences, error)
+
+	// Unsubscribe turns the newsletter off for the user an unsubscribe token was issued to.
+	// ctx: The context for the request.
+	// token: The token from the unsubscribe link.
+	Unsubscribe(ctx context.Context, token string) error
+}
+
+// NotificationPreferencesHandler handles HTTP requests for the kinds of email users receive.
+type NotificationPreferencesHandler struct {
+	service NotificationPreferencesService
+}
+
+// NewNotificationPreferencesHandler creates a new instance of NotificationPreferencesHandler with the provided service.
+func NewNotificationPreferencesHandler(s NotificationPreferencesService) *NotificationPreferencesHandler {
+	return &NotificationPreferencesHandler{service: s}
+}
+
+// unsubscribeInput is the payload of an unsubscribe request.
+type unsubscribeInput struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// GetPreferences handles the request for the authenticated user's notification
+// preferences. A database timeout results in a 504 status code.
+func (h *NotificationPreferencesHandler) GetPreferences(c *gin.Context) {
+	identity, ok := authctx.User(c)
+	if !ok {
+		apierror.Respond(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	preferences, err := h.service.Get(c.Request.Context(), identity.UserID.String())
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrUserNotFound):
+			apierror.Respond(c, http.StatusNotFound, service.ErrUserNotFound.Error())
+		case errors.Is(err, repository.ErrTimeout):
+			apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+		default:
+			c.Error(err)
+			apierror.Respond(c, http.StatusInternalServerError, "failed to get notification preferences")
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, preferences)
+}
+
+// UpdatePreferences handles the request to replace the authenticated user's
+// notification preferences. It expects a JSON payload with every preference
+// and responds with the stored preferences. Turning security alerts off
+// results in a 400 status code with the "SECURITY_ALERTS_REQUIRED" code.
+func (h *NotificationPreferencesHandler) UpdatePreferences(c *gin.Context) {
+	identity, ok := authctx.User(c)
+	if !ok {
+		apierror.Respond(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var input model.NotificationPreferences
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.RespondInvalid(c, err)
+		return
+	}
+
+	preferences, err := h.service.Update(c.Request.Context(), identity.UserID.String(), input)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrSecurityAlertsRequired):
+			apierror.RespondCode(c, http.StatusBadRequest, "SECURITY_ALERTS_REQUIRED", err.Error())
+		case errors.Is(err, service.ErrInvalidUserID):
+			apierror.Respond(c, http.StatusBadRequest, err.Error())
+		case errors.Is(err, service.ErrUserNotFound):
+			apierror.Respond(c, http.StatusNotFound, service.ErrUserNotFound.Error())
+		case errors.Is(err, repository.ErrTimeout):
+			apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+		default:
+			c.Error(err)
+			apierror.Respond(c, http.StatusInternalServerError, "failed to update notification preferences")
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, preferences)
+}
+
+// Unsubscribe handles the unsubscribe link of a newsletter email, which works
+// without logging in. It expects a JSON payload with the token from the link
+// and turns the newsletter off for the user it was issued to. A token that
+// was not issued by the service results in a 400 status code.
+func (h *NotificationPreferencesHandler) Unsubscribe(c *gin.Context) {
+	var input unsubscribeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.RespondInvalid(c, err)
+		return
+	}
+
+	if err := h.service.Unsubscribe(c.Request.Context(), input.Token); err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidUnsubscribeToken):
+			apierror.Respond(c, http.StatusBadRequest, err.Error())
+		case errors.Is(err, repository.ErrTimeout):
+			apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+		default:
+			c.Error(err)
+			apierror.Respond(c, http.StatusInternalServerError, "failed to unsubscribe")
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "unsubscribed from the newsletter"})
+}
@@ -0,0 +1,324 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/PakornBank/learn-go/internal/authctx"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockNotificationPreferencesService struct {
+	mock.Mock
+}
+
+func (ms *MockNotificationPreferencesService) Get(ctx context.Context, userID string) (*model.NotificationPreferences, error) {
+	args := ms.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.NotificationPreferences), args.Error(1)
+}
+
+func (ms *MockNotificationPreferencesService) Update(ctx context.Context, userID string, preferences model.NotificationPreferences) (*model.NotificationPreferences, error) {
+	args := ms.Called(ctx, userID, preferences)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.NotificationPreferences), args.Error(1)
+}
+
+func (ms *MockNotificationPreferencesService) Unsubscribe(ctx context.Context, token string) error {
+	args := ms.Called(ctx, token)
+	return args.Error(0)
+}
+
+func TestNewNotificationPreferencesHandler(t *testing.T) {
+	service := new(MockNotificationPreferencesService)
+	handler := NewNotificationPreferencesHandler(service)
+
+	assert.NotNil(t, handler)
+	assert.Equal(t, service, handler.service)
+}
+
+// serveNotificationPreferences serves a request to the notification
+// preferences routes, authenticated as userID unless it is empty, and returns
+// the response and the errors attached to it.
+func serveNotificationPreferences(t *testing.T, mockService *MockNotificationPreferencesService, userID, method, path, body string) (*httptest.ResponseRecorder, []error) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	handler := NewNotificationPreferencesHandler(mockService)
+	var attached []error
+	authenticated := func(c *gin.Context) {
+		if userID != "" {
+			authctx.SetUser(c, authctx.Identity{UserID: uuid.MustParse(userID)})
+		}
+	}
+	router := gin.New()
+	router.Use(collectErrors(&attached))
+	router.GET("/api/auth/preferences/notifications", authenticated, handler.GetPreferences)
+	router.PUT("/api/auth/preferences/notifications", authenticated, handler.UpdatePreferences)
+	router.POST("/api/auth/notifications/unsubscribe", handler.Unsubscribe)
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w, attached
+}
+
+func TestNotificationPreferencesHandler_GetPreferences(t *testing.T) {
+	const userID = "5b0c8f3e-2a1d-4c6b-9e7f-3d2a1b0c9e8f"
+
+	tests := []struct {
+		name         string
+		userID       string
+		mockFn       func(*MockNotificationPreferencesService)
+		wantCode     int
+		wantAttached bool
+		wantBody     string
+	}{
+		{
+			name:   "found",
+			userID: userID,
+			mockFn: func(ms *MockNotificationPreferencesService) {
+				ms.On("Get", mock.Anything, userID).Return(&model.NotificationPreferences{SecurityAlerts: true, Newsletter: true}, nil)
+			},
+			wantCode: http.StatusOK,
+			wantBody: `{"security_alerts":true,"product_updates":false,"newsletter":true}`,
+		},
+		{
+			name:     "not authenticated",
+			wantCode: http.StatusUnauthorized,
+			wantBody: `{"error":"unauthorized"}`,
+		},
+		{
+			name:   "user not found",
+			userID: userID,
+			mockFn: func(ms *MockNotificationPreferencesService) {
+				ms.On("Get", mock.Anything, userID).Return(nil, service.ErrUserNotFound)
+			},
+			wantCode: http.StatusNotFound,
+			wantBody: `{"error":"` + service.ErrUserNotFound.Error() + `"}`,
+		},
+		{
+			name:   "database timeout",
+			userID: userID,
+			mockFn: func(ms *MockNotificationPreferencesService) {
+				ms.On("Get", mock.Anything, userID).Return(nil, repository.ErrTimeout)
+			},
+			wantCode: http.StatusGatewayTimeout,
+			wantBody: `{"error":"` + repository.ErrTimeout.Error() + `"}`,
+		},
+		{
+			name:   "database error",
+			userID: userID,
+			mockFn: func(ms *MockNotificationPreferencesService) {
+				ms.On("Get", mock.Anything, userID).Return(nil, errors.New("connection reset"))
+			},
+			wantCode:     http.StatusInternalServerError,
+			wantAttached: true,
+			wantBody:     `{"error":"failed to get notification preferences"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockNotificationPreferencesService)
+			if tt.mockFn != nil {
+				tt.mockFn(mockService)
+			}
+
+			w, attached := serveNotificationPreferences(t, mockService, tt.userID, http.MethodGet, "/api/auth/preferences/notifications", "")
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Equal(t, tt.wantAttached, len(attached) > 0)
+			assert.JSONEq(t, tt.wantBody, w.Body.String())
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestNotificationPreferencesHandler_UpdatePreferences(t *testing.T) {
+	const userID = "5b0c8f3e-2a1d-4c6b-9e7f-3d2a1b0c9e8f"
+	const body = `{"security_alerts": true, "product_updates": true, "newsletter": false}`
+	input := model.NotificationPreferences{SecurityAlerts: true, ProductUpdates: true}
+
+	tests := []struct {
+		name         string
+		userID       string
+		body         string
+		mockFn       func(*MockNotificationPreferencesService)
+		wantCode     int
+		wantAttached bool
+		wantBody     string
+	}{
+		{
+			name:   "updated",
+			userID: userID,
+			body:   body,
+			mockFn: func(ms *MockNotificationPreferencesService) {
+				ms.On("Update", mock.Anything, userID, input).Return(&input, nil)
+			},
+			wantCode: http.StatusOK,
+			wantBody: `{"security_alerts":true,"product_updates":true,"newsletter":false}`,
+		},
+		{
+			name:     "not authenticated",
+			body:     body,
+			wantCode: http.StatusUnauthorized,
+			wantBody: `{"error":"unauthorized"}`,
+		},
+		{
+			name:     "malformed body",
+			userID:   userID,
+			body:     `{"newsletter": "yes"}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:   "security alerts turned off",
+			userID: userID,
+			body:   `{"security_alerts": false, "product_updates": true, "newsletter": false}`,
+			mockFn: func(ms *MockNotificationPreferencesService) {
+				ms.On("Update", mock.Anything, userID, model.NotificationPreferences{ProductUpdates: true}).
+					Return(nil, service.ErrSecurityAlertsRequired)
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"error":"` + service.ErrSecurityAlertsRequired.Error() + `","code":"SECURITY_ALERTS_REQUIRED"}`,
+		},
+		{
+			name:   "user not found",
+			userID: userID,
+			body:   body,
+			mockFn: func(ms *MockNotificationPreferencesService) {
+				ms.On("Update", mock.Anything, userID, input).Return(nil, service.ErrUserNotFound)
+			},
+			wantCode: http.StatusNotFound,
+			wantBody: `{"error":"` + service.ErrUserNotFound.Error() + `"}`,
+		},
+		{
+			name:   "database timeout",
+			userID: userID,
+			body:   body,
+			mockFn: func(ms *MockNotificationPreferencesService) {
+				ms.On("Update", mock.Anything, userID, input).Return(nil, repository.ErrTimeout)
+			},
+			wantCode: http.StatusGatewayTimeout,
+			wantBody: `{"error":"` + repository.ErrTimeout.Error() + `"}`,
+		},
+		{
+			name:   "database error",
+			userID: userID,
+			body:   body,
+			mockFn: func(ms *MockNotificationPreferencesService) {
+				ms.On("Update", mock.Anything, userID, input).Return(nil, errors.New("connection reset"))
+			},
+			wantCode:     http.StatusInternalServerError,
+			wantAttached: true,
+			wantBody:     `{"error":"failed to update notification preferences"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockNotificationPreferencesService)
+			if tt.mockFn != nil {
+				tt.mockFn(mockService)
+			}
+
+			w, attached := serveNotificationPreferences(t, mockService, tt.userID, http.MethodPut, "/api/auth/preferences/notifications", tt.body)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Equal(t, tt.wantAttached, len(attached) > 0)
+			if tt.wantBody != "" {
+				assert.JSONEq(t, tt.wantBody, w.Body.String())
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestNotificationPreferencesHandler_Unsubscribe(t *testing.T) {
+	const body = `{"token": "unsubscribe-token"}`
+
+	tests := []struct {
+		name         string
+		body         string
+		mockFn       func(*MockNotificationPreferencesService)
+		wantCode     int
+		wantAttached bool
+		wantBody     string
+	}{
+		{
+			name: "unsubscribed",
+			body: body,
+			mockFn: func(ms *MockNotificationPreferencesService) {
+				ms.On("Unsubscribe", mock.Anything, "unsubscribe-token").Return(nil)
+			},
+			wantCode: http.StatusOK,
+			wantBody: `{"message":"unsubscribed from the newsletter"}`,
+		},
+		{
+			name:     "missing token",
+			body:     `{}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name: "invalid token",
+			body: body,
+			mockFn: func(ms *MockNotificationPreferencesService) {
+				ms.On("Unsubscribe", mock.Anything, "unsubscribe-token").Return(service.ErrInvalidUnsubscribeToken)
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"error":"` + service.ErrInvalidUnsubscribeToken.Error() + `"}`,
+		},
+		{
+			name: "database timeout",
+			body: body,
+			mockFn: func(ms *MockNotificationPreferencesService) {
+				ms.On("Unsubscribe", mock.Anything, "unsubscribe-token").Return(repository.ErrTimeout)
+			},
+			wantCode: http.StatusGatewayTimeout,
+			wantBody: `{"error":"` + repository.ErrTimeout.Error() + `"}`,
+		},
+		{
+			name: "database error",
+			body: body,
+			mockFn: func(ms *MockNotificationPreferencesService) {
+				ms.On("Unsubscribe", mock.Anything, "unsubscribe-token").Return(errors.New("connection reset"))
+			},
+			wantCode:     http.StatusInternalServerError,
+			wantAttached: true,
+			wantBody:     `{"error":"failed to unsubscribe"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockNotificationPreferencesService)
+			if tt.mockFn != nil {
+				tt.mockFn(mockService)
+			}
+
+			// No user is authenticated: the link works without logging in.
+			w, attached := serveNotificationPreferences(t, mockService, "", http.MethodPost, "/api/auth/notifications/unsubscribe", tt.body)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Equal(t, tt.wantAttached, len(attached) > 0)
+			if tt.wantBody != "" {
+				assert.JSONEq(t, tt.wantBody, w.Body.String())
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/PakornBank/learn-go/internal/outbox"
+	"github.com/gin-gonic/gin"
+)
+
+// OutboxStats reports the outcomes of the outbox poller's attempts. It is
+// satisfied by *outbox.Poller.
+type OutboxStats interface {
+	// Stats returns the counts of events published and failed attempts.
+	Stats() outbox.Stats
+}
+
+// OutboxHandler handles HTTP requests for inspecting the outbox.
+type OutboxHandler struct {
+	stats OutboxStats
+}
+
+// NewOutboxHandler creates a new instance of OutboxHandler with the provided stats.
+func NewOutboxHandler(stats OutboxStats) *OutboxHandler {
+	return &OutboxHandler{stats: stats}
+}
+
+// GetStats handles the request for the counts of outbox events published and
+// failed attempts to publish them since this replica started. Failed events
+// are retried at the next poll.
+func (h *OutboxHandler) GetStats(c *gin.Context) {
+	c.JSON(http.StatusOK, h.stats.Stats())
+}
@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PakornBank/learn-go/internal/outbox"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeOutboxStats outbox.Stats
+
+func (s fakeOutboxStats) Stats() outbox.Stats {
+	return outbox.Stats(s)
+}
+
+func TestNewOutboxHandler(t *testing.T) {
+	stats := fakeOutboxStats{}
+	handler := NewOutboxHandler(stats)
+
+	assert.NotNil(t, handler)
+	assert.Equal(t, stats, handler.stats)
+}
+
+func TestOutboxHandler_GetStats(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/admin/outbox/stats", NewOutboxHandler(fakeOutboxStats{Published: 12, Failed: 3, DeadLettered: 1}).GetStats)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/outbox/stats", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"published": 12, "failed": 3, "dead_lettered": 1}`, w.Body.String())
+}
@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/PakornBank/learn-go/internal/apierror"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// parseLimit reads the optional "limit" query parameter, defaulting to
+// service.DefaultListLimit. If the value is not an integer it responds with
+// a 400 status code and returns false. Range checks are left to the service.
+func parseLimit(c *gin.Context) (int, bool) {
+	raw := c.Query("limit")
+	if raw == "" {
+		return service.DefaultListLimit, true
+	}
+
+	limit, err := strconv.Atoi(raw)
+	if err != nil {
+		apierror.Respond(c, http.StatusBadRequest, service.ErrInvalidLimit.Error())
+		return 0, false
+	}
+	return limit, true
+}
+
+// Pagination is how a list endpoint is paged and sorted, from the "page",
+// "per_page" and "sort" query parameters. sort is one of SortFields,
+// prefixed with "-" to sort in descending order.
+type Pagination struct {
+	// SortFields whitelists the fields the list can be sorted by; no other
+	// value of sort ever reaches a query.
+	SortFields []string
+	// DefaultSort is the sort of requests without one, in the form of the
+	// query parameter.
+	DefaultSort string
+}
+
+// Bind reads the page the request asks for. page defaults to 1 and per_page
+// to service.DefaultListLimit, and a per_page above service.MaxListLimit is
+// clamped to it. If page or per_page is not an integer or is below 1, or sort
+// is not whitelisted, it responds with a 422 status code and the code
+// INVALID_PAGINATION, listing each invalid parameter under "errors", and
+// returns false.
+func (p Pagination) Bind(c *gin.Context) (service.PageRequest, bool) {
+	var fields []apierror.FieldError
+	page, ok := queryInt(c, "page", 1)
+	if !ok || page < 1 {
+		fields = append(fields, apierror.FieldError{Field: "page", Message: "must be an integer of at least 1"})
+	}
+	perPage, ok := queryInt(c, "per_page", service.DefaultListLimit)
+	if !ok || perPage < 1 {
+		fields = append(fields, apierror.FieldError{Field: "per_page", Message: fmt.Sprintf("must be an integer between 1 and %d", service.MaxListLimit)})
+	}
+	perPage = min(perPage, service.MaxListLimit)
+
+	sort, desc := strings.CutPrefix(c.DefaultQuery("sort", p.DefaultSort), "-")
+	if !slices.Contains(p.SortFields, sort) {
+		fields = append(fields, apierror.FieldError{Field: "sort", Message: "must be one of " + strings.Join(p.SortFields, ", ") + ", optionally prefixed with -"})
+	}
+
+	if len(fields) > 0 {
+		apierror.RespondFields(c, http.StatusUnprocessableEntity, "INVALID_PAGINATION", "invalid pagination", fields)
+		return service.PageRequest{}, false
+	}
+	return service.PageRequest{Page: page, PerPage: perPage, Sort: sort, Desc: desc}, true
+}
+
+// queryInt reads the integer query parameter key, or def if it is absent. It
+// returns false if the parameter is not an integer.
+func queryInt(c *gin.Context, key string, def int) (int, bool) {
+	raw, ok := c.GetQuery(key)
+	if !ok {
+		return def, true
+	}
+	n, err := strconv.Atoi(raw)
+	return n, err == nil
+}
+
+// Page is the response envelope of a page of a list.
+type Page[T any] struct {
+	Data       []T   `json:"data"`
+	Page       int   `json:"page"`
+	PerPage    int   `json:"per_page"`
+	Total      int64 `json:"total"`
+	TotalPages int   `json:"total_pages"`
+}
+
+// NewPage returns the envelope of items, the page p of a list of total items.
+// Nil items are returned as an empty list.
+func NewPage[T any](items []T, total int64, p service.PageRequest) Page[T] {
+	if items == nil {
+		items = []T{}
+	}
+	return Page[T]{
+		Data:       items,
+		Page:       p.Page,
+		PerPage:    p.PerPage,
+		Total:      total,
+		TotalPages: int((total + int64(p.PerPage) - 1) / int64(p.PerPage)),
+	}
+}
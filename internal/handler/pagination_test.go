@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPagination_Bind(t *testing.T) {
+	pagination := Pagination{SortFields: []string{"created_at", "email"}, DefaultSort: "-created_at"}
+
+	tests := []struct {
+		name     string
+		query    string
+		wantPage service.PageRequest
+		wantBody string
+	}{
+		{
+			name:     "defaults",
+			wantPage: service.PageRequest{Page: 1, PerPage: service.DefaultListLimit, Sort: "created_at", Desc: true},
+		},
+		{
+			name:     "explicit values",
+			query:    "?page=3&per_page=50&sort=email",
+			wantPage: service.PageRequest{Page: 3, PerPage: 50, Sort: "email"},
+		},
+		{
+			name:     "descending sort",
+			query:    "?sort=-email",
+			wantPage: service.PageRequest{Page: 1, PerPage: service.DefaultListLimit, Sort: "email", Desc: true},
+		},
+		{
+			name:     "per page clamped to the maximum",
+			query:    "?per_page=1000",
+			wantPage: service.PageRequest{Page: 1, PerPage: service.MaxListLimit, Sort: "created_at", Desc: true},
+		},
+		{
+			name:  "page below 1",
+			query: "?page=0",
+			wantBody: `{"error":"invalid pagination","code":"INVALID_PAGINATION","errors":[
+				{"field":"page","message":"must be an integer of at least 1"}]}`,
+		},
+		{
+			name:  "non-numeric per page",
+			query: "?per_page=ten",
+			wantBody: `{"error":"invalid pagination","code":"INVALID_PAGINATION","errors":[
+				{"field":"per_page","message":"must be an integer between 1 and 100"}]}`,
+		},
+		{
+			name:  "per page below 1",
+			query: "?per_page=-5",
+			wantBody: `{"error":"invalid pagination","code":"INVALID_PAGINATION","errors":[
+				{"field":"per_page","message":"must be an integer between 1 and 100"}]}`,
+		},
+		{
+			name:  "sort column outside the whitelist",
+			query: "?sort=password_hash",
+			wantBody: `{"error":"invalid pagination","code":"INVALID_PAGINATION","errors":[
+				{"field":"sort","message":"must be one of created_at, email, optionally prefixed with -"}]}`,
+		},
+		{
+			name:  "sort injection attempt",
+			query: "?sort=created_at%3B%20DROP%20TABLE%20users",
+			wantBody: `{"error":"invalid pagination","code":"INVALID_PAGINATION","errors":[
+				{"field":"sort","message":"must be one of created_at, email, optionally prefixed with -"}]}`,
+		},
+		{
+			name:  "every parameter invalid",
+			query: "?page=x&per_page=0&sort=id",
+			wantBody: `{"error":"invalid pagination","code":"INVALID_PAGINATION","errors":[
+				{"field":"page","message":"must be an integer of at least 1"},
+				{"field":"per_page","message":"must be an integer between 1 and 100"},
+				{"field":"sort","message":"must be one of created_at, email, optionally prefixed with -"}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			var got service.PageRequest
+			router := gin.New()
+			router.GET("/items", func(c *gin.Context) {
+				page, ok := pagination.Bind(c)
+				if !ok {
+					return
+				}
+				got = page
+				c.Status(http.StatusNoContent)
+			})
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/items"+tt.query, nil))
+
+			if tt.wantBody != "" {
+				assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+				assert.JSONEq(t, tt.wantBody, w.Body.String())
+				return
+			}
+			assert.Equal(t, http.StatusNoContent, w.Code)
+			assert.Equal(t, tt.wantPage, got)
+		})
+	}
+}
+
+func TestNewPage(t *testing.T) {
+	users := []model.User{{Email: "a@example.com"}, {Email: "b@example.com"}}
+
+	page := NewPage(users, 45, service.PageRequest{Page: 3, PerPage: 20})
+	assert.Equal(t, Page[model.User]{Data: users, Page: 3, PerPage: 20, Total: 45, TotalPages: 3}, page)
+
+	empty := NewPage[model.User](nil, 0, service.PageRequest{Page: 1, PerPage: 20})
+	assert.Equal(t, []model.User{}, empty.Data)
+	assert.Zero(t, empty.TotalPages)
+
+	beyond := NewPage[model.User](nil, 45, service.PageRequest{Page: 9, PerPage: 20})
+	assert.Empty(t, beyond.Data, "a page past the last one is empty")
+	assert.Equal(t, 3, beyond.TotalPages)
+}
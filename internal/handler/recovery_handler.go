@@ -0,0 +1,236 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/apierror"
+	"github.com/PakornBank/learn-go/internal/authctx"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RecoveryService defines the methods that a recovery handler must implement.
+type RecoveryService interface {
+	// Submit files a recovery request and notifies admins, returning nil if the email has no account.
+	// ctx: The context for the request.
+	// input: The claimed email and the evidence of owning the account.
+	Submit(ctx context.Context, input service.RecoveryRequestInput) (*model.RecoveryRequest, error)
+
+	// Complete recovers the account of an approved request with the token from its link.
+	// ctx: The context for the request.
+	// input: The token from the recovery link and the new password.
+	Complete(ctx context.Context, input service.CompleteRecoveryInput) (*model.RecoveryRequest, error)
+
+	// List returns a page of recovery requests and the total number of them.
+	// ctx: The context for the request.
+	// status: The status to list, or empty for every status.
+	// page: The page to return, sorted by creation time.
+	List(ctx context.Context, status string, page service.PageRequest) ([]model.RecoveryRequest, int64, error)
+
+	// Approve approves a pending request and mails a recovery link to the new email.
+	// ctx: The context for the request.
+	// adminID: The ID of the approving admin.
+	// id: The ID of the request.
+	// input: The email address the account moves to.
+	Approve(ctx context.Context, adminID, id string, input service.ApproveRecoveryInput) (*model.RecoveryRequest, error)
+
+	// Deny denies a pending request.
+	// ctx: The context for the request.
+	// adminID: The ID of the denying admin.
+	// id: The ID of the request.
+	Deny(ctx context.Context, adminID, id string) (*model.RecoveryRequest, error)
+}
+
+// AuditRecorder records audit entries. It is satisfied by *service.AuditWriter.
+type AuditRecorder interface {
+	Record(entry *model.AuditEntry)
+}
+
+// recoveryPagination is the paging of the recovery request list, newest first by default.
+var recoveryPagination = Pagination{
+	SortFields:  []string{"created_at"},
+	DefaultSort: "-created_at",
+}
+
+// RecoveryHandler handles HTTP requests for recovering accounts whose owners
+// lost access to both their password and their mailbox.
+type RecoveryHandler struct {
+	service RecoveryService
+	audit   AuditRecorder
+}
+
+// NewRecoveryHandler creates a new instance of RecoveryHandler with the
+// provided service, recording the requests of users who are not signed in
+// to audit.
+func NewRecoveryHandler(s RecoveryService, audit AuditRecorder) *RecoveryHandler {
+	return &RecoveryHandler{service: s, audit: audit}
+}
+
+// SubmitRequest handles a request to recover an account. It expects a JSON
+// payload with the account's email and free-text evidence of owning it, and
+// responds with a 202 status code whether or not the email has an account,
+// so that the endpoint cannot be used to find out. A filed request is
+// recorded in the audit log with the account as its subject.
+func (h *RecoveryHandler) SubmitRequest(c *gin.Context) {
+	start := time.Now()
+
+	var input service.RecoveryRequestInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.RespondInvalid(c, err)
+		return
+	}
+
+	request, err := h.service.Submit(c.Request.Context(), input)
+	if err != nil {
+		h.respondError(c, err, "failed to request account recovery")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "recovery request received, an admin will review it"})
+	if request != nil {
+		h.record(c, start, uuid.Nil, &request.UserID)
+	}
+}
+
+// CompleteRecovery handles the link of an approved recovery request. It
+// expects a JSON payload with the token from the link and a new password,
+// after which the account signs in with the approved email and that password.
+// An unknown, used or expired token, or a breached password, results in a 400
+// status code, and an approved email taken by another account since in a 409.
+// The recovery is recorded in the audit log as made by the account's user.
+func (h *RecoveryHandler) CompleteRecovery(c *gin.Context) {
+	start := time.Now()
+
+	var input service.CompleteRecoveryInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.RespondInvalid(c, err)
+		return
+	}
+
+	request, err := h.service.Complete(c.Request.Context(), input)
+	if err != nil {
+		h.respondError(c, err, "failed to recover account")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "account recovered, sign in with the new email and password"})
+	h.record(c, start, request.UserID, nil)
+}
+
+// ListRequests handles an admin's request to list recovery requests a page
+// at a time. It accepts the optional "status" query parameter, one of
+// pending, approved, denied or completed, and the "page", "per_page" and
+// "sort" query parameters of Pagination, where sort is created_at, and
+// responds with the requests in a Page envelope. An unknown status results in
+// a 400 status code, invalid pagination in a 422, and a database timeout in a 504.
+func (h *RecoveryHandler) ListRequests(c *gin.Context) {
+	page, ok := recoveryPagination.Bind(c)
+	if !ok {
+		return
+	}
+
+	requests, total, err := h.service.List(c.Request.Context(), c.Query("status"), page)
+	if err != nil {
+		h.respondError(c, err, "failed to list recovery requests")
+		return
+	}
+
+	c.JSON(http.StatusOK, NewPage(requests, total, page))
+}
+
+// ApproveRequest handles an admin's approval of the recovery request
+// identified by the "id" path parameter. It expects a JSON payload with the
+// email address the account moves to, mails the recovery link there and
+// responds with the approved request. Admins approving the recovery of their
+// own account get a 403 status code, a request that is not pending a 409, an
+// email taken by another account a 409, and an unknown request a 404.
+func (h *RecoveryHandler) ApproveRequest(c *gin.Context) {
+	identity, ok := authctx.User(c)
+	if !ok {
+		apierror.Respond(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var input service.ApproveRecoveryInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.RespondInvalid(c, err)
+		return
+	}
+
+	request, err := h.service.Approve(c.Request.Context(), identity.UserID.String(), c.Param("id"), input)
+	if err != nil {
+		h.respondError(c, err, "failed to approve recovery request")
+		return
+	}
+
+	c.JSON(http.StatusOK, request)
+}
+
+// DenyRequest handles an admin's denial of the recovery request identified by
+// the "id" path parameter and responds with the denied request. A request
+// that is not pending results in a 409 status code, and an unknown request in a 404.
+func (h *RecoveryHandler) DenyRequest(c *gin.Context) {
+	identity, ok := authctx.User(c)
+	if !ok {
+		apierror.Respond(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	request, err := h.service.Deny(c.Request.Context(), identity.UserID.String(), c.Param("id"))
+	if err != nil {
+		h.respondError(c, err, "failed to deny recovery request")
+		return
+	}
+
+	c.JSON(http.StatusOK, request)
+}
+
+// record adds an audit entry for the request, made by actorID about
+// subjectID, once it has been handled. The routes of users who are not
+// signed in are not covered by the audit middleware, so they record their
+// state changes here.
+func (h *RecoveryHandler) record(c *gin.Context, start time.Time, actorID uuid.UUID, subjectID *uuid.UUID) {
+	h.audit.Record(&model.AuditEntry{
+		ActorID:   actorID,
+		SubjectID: subjectID,
+		Method:    c.Request.Method,
+		Path:      c.Request.URL.Path,
+		Status:    c.Writer.Status(),
+		IPAddress: c.ClientIP(),
+		LatencyMS: time.Since(start).Milliseconds(),
+		CreatedAt: start,
+	})
+}
+
+// respondError writes the response for an error returned by the service,
+// using fallback as the message of unexpected errors.
+func (h *RecoveryHandler) respondError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, service.ErrInvalidRecoveryToken):
+		apierror.Respond(c, http.StatusBadRequest, service.ErrInvalidRecoveryToken.Error())
+	case errors.Is(err, service.ErrInvalidRecoveryID),
+		errors.Is(err, service.ErrInvalidRecoveryStatus),
+		errors.Is(err, service.ErrSameEmail),
+		errors.Is(err, service.ErrPasswordBreached):
+		apierror.Respond(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, service.ErrRecoverySelfApproval):
+		apierror.RespondCode(c, http.StatusForbidden, "SELF_APPROVAL", err.Error())
+	case errors.Is(err, service.ErrRecoveryRequestMissing):
+		apierror.Respond(c, http.StatusNotFound, service.ErrRecoveryRequestMissing.Error())
+	case errors.Is(err, service.ErrRecoveryStateConflict):
+		apierror.RespondCode(c, http.StatusConflict, "RECOVERY_STATE_CONFLICT", service.ErrRecoveryStateConflict.Error())
+	case errors.Is(err, service.ErrEmailTaken):
+		apierror.Respond(c, http.StatusConflict, service.ErrEmailTaken.Error())
+	case errors.Is(err, repository.ErrTimeout):
+		apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+	default:
+		c.Error(err)
+		apierror.Respond(c, http.StatusInternalServerError, fallback)
+	}
+}
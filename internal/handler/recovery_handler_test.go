@@ -0,0 +1,287 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockRecoveryService struct {
+	mock.Mock
+}
+
+func (ms *MockRecoveryService) Submit(ctx context.Context, input service.RecoveryRequestInput) (*model.RecoveryRequest, error) {
+	args := ms.Called(ctx, input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.RecoveryRequest), args.Error(1)
+}
+
+func (ms *MockRecoveryService) Complete(ctx context.Context, input service.CompleteRecoveryInput) (*model.RecoveryRequest, error) {
+	args := ms.Called(ctx, input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.RecoveryRequest), args.Error(1)
+}
+
+func (ms *MockRecoveryService) List(ctx context.Context, status string, page service.PageRequest) ([]model.RecoveryRequest, int64, error) {
+	args := ms.Called(ctx, status, page)
+	if args.Get(0) == nil {
+		return nil, args.Get(1).(int64), args.Error(2)
+	}
+	return args.Get(0).([]model.RecoveryRequest), args.Get(1).(int64), args.Error(2)
+}
+
+func (ms *MockRecoveryService) Approve(ctx context.Context, adminID, id string, input service.ApproveRecoveryInput) (*model.RecoveryRequest, error) {
+	args := ms.Called(ctx, adminID, id, input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.RecoveryRequest), args.Error(1)
+}
+
+func (ms *MockRecoveryService) Deny(ctx context.Context, adminID, id string) (*model.RecoveryRequest, error) {
+	args := ms.Called(ctx, adminID, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.RecoveryRequest), args.Error(1)
+}
+
+type recordedAudit struct {
+	entries []*model.AuditEntry
+}
+
+func (r *recordedAudit) Record(entry *model.AuditEntry) {
+	r.entries = append(r.entries, entry)
+}
+
+const recoveryAdminID = "9e2d4c1a-6b3f-4a8e-8d7c-5f1e2a3b4c6d"
+
+func setupRecoveryTest() (*gin.Engine, *MockRecoveryService, *recordedAudit) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockRecoveryService)
+	audit := &recordedAudit{}
+	handler := NewRecoveryHandler(mockService, audit)
+
+	router := gin.New()
+	router.POST("/api/auth/recovery/request", handler.SubmitRequest)
+	router.POST("/api/auth/recovery/complete", handler.CompleteRecovery)
+	admin := router.Group("/api/admin", authenticatedAs(recoveryAdminID))
+	admin.GET("/recovery-requests", handler.ListRequests)
+	admin.POST("/recovery-requests/:id/approve", handler.ApproveRequest)
+	admin.POST("/recovery-requests/:id/deny", handler.DenyRequest)
+
+	return router, mockService, audit
+}
+
+func postRecoveryJSON(router *gin.Engine, path string, body interface{}) *httptest.ResponseRecorder {
+	jsonBody, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRecoveryHandler_SubmitRequest(t *testing.T) {
+	input := service.RecoveryRequestInput{Email: "test@example.com", Evidence: "I signed up in 2020"}
+	userID := uuid.New()
+
+	t.Run("known account is audited", func(t *testing.T) {
+		router, mockService, audit := setupRecoveryTest()
+		mockService.On("Submit", mock.Anything, input).Return(&model.RecoveryRequest{ID: uuid.New(), UserID: userID, Status: model.RecoveryPending}, nil)
+
+		w := postRecoveryJSON(router, "/api/auth/recovery/request", input)
+
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		require.Len(t, audit.entries, 1)
+		assert.Equal(t, uuid.Nil, audit.entries[0].ActorID)
+		assert.Equal(t, &userID, audit.entries[0].SubjectID)
+		assert.Equal(t, http.StatusAccepted, audit.entries[0].Status)
+	})
+
+	t.Run("unknown account answers the same", func(t *testing.T) {
+		router, mockService, audit := setupRecoveryTest()
+		mockService.On("Submit", mock.Anything, input).Return(nil, nil)
+
+		w := postRecoveryJSON(router, "/api/auth/recovery/request", input)
+
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		assert.Empty(t, audit.entries)
+	})
+
+	t.Run("missing evidence", func(t *testing.T) {
+		router, _, _ := setupRecoveryTest()
+
+		w := postRecoveryJSON(router, "/api/auth/recovery/request", map[string]string{"email": "test@example.com"})
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestRecoveryHandler_CompleteRecovery(t *testing.T) {
+	input := service.CompleteRecoveryInput{Token: "token", NewPassword: "new-password"}
+	userID := uuid.New()
+
+	tests := []struct {
+		name      string
+		mockFn    func(*MockRecoveryService)
+		wantCode  int
+		wantAudit bool
+	}{
+		{
+			name: "account recovered",
+			mockFn: func(ms *MockRecoveryService) {
+				ms.On("Complete", mock.Anything, input).Return(&model.RecoveryRequest{UserID: userID, Status: model.RecoveryCompleted}, nil)
+			},
+			wantCode:  http.StatusOK,
+			wantAudit: true,
+		},
+		{
+			name: "invalid token",
+			mockFn: func(ms *MockRecoveryService) {
+				ms.On("Complete", mock.Anything, input).Return(nil, service.ErrInvalidRecoveryToken)
+			},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name: "email taken since approval",
+			mockFn: func(ms *MockRecoveryService) {
+				ms.On("Complete", mock.Anything, input).Return(nil, service.ErrEmailTaken)
+			},
+			wantCode: http.StatusConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mockService, audit := setupRecoveryTest()
+			tt.mockFn(mockService)
+
+			w := postRecoveryJSON(router, "/api/auth/recovery/complete", input)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantAudit {
+				require.Len(t, audit.entries, 1)
+				assert.Equal(t, userID, audit.entries[0].ActorID)
+			} else {
+				assert.Empty(t, audit.entries)
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRecoveryHandler_ListRequests(t *testing.T) {
+	page := service.PageRequest{Page: 1, PerPage: service.DefaultListLimit, Sort: "created_at", Desc: true}
+
+	t.Run("pending requests", func(t *testing.T) {
+		router, mockService, _ := setupRecoveryTest()
+		mockService.On("List", mock.Anything, model.RecoveryPending, page).
+			Return([]model.RecoveryRequest{{ID: uuid.New(), Status: model.RecoveryPending}}, int64(1), nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/admin/recovery-requests?status=pending", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var res map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		assert.Len(t, res["data"], 1)
+		assert.Equal(t, 1.0, res["total"])
+	})
+
+	t.Run("unknown status", func(t *testing.T) {
+		router, mockService, _ := setupRecoveryTest()
+		mockService.On("List", mock.Anything, "archived", page).Return(nil, int64(0), service.ErrInvalidRecoveryStatus)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/admin/recovery-requests?status=archived", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestRecoveryHandler_ApproveRequest(t *testing.T) {
+	id := uuid.NewString()
+	input := service.ApproveRecoveryInput{NewEmail: "new@example.com"}
+
+	tests := []struct {
+		name     string
+		err      error
+		wantCode int
+		wantErr  string
+	}{
+		{name: "approved", wantCode: http.StatusOK},
+		{name: "own account", err: service.ErrRecoverySelfApproval, wantCode: http.StatusForbidden, wantErr: "SELF_APPROVAL"},
+		{name: "not pending", err: service.ErrRecoveryStateConflict, wantCode: http.StatusConflict, wantErr: "RECOVERY_STATE_CONFLICT"},
+		{name: "unknown request", err: service.ErrRecoveryRequestMissing, wantCode: http.StatusNotFound},
+		{name: "email taken", err: service.ErrEmailTaken, wantCode: http.StatusConflict},
+		{name: "database timeout", err: repository.ErrTimeout, wantCode: http.StatusGatewayTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mockService, audit := setupRecoveryTest()
+			if tt.err != nil {
+				mockService.On("Approve", mock.Anything, recoveryAdminID, id, input).Return(nil, tt.err)
+			} else {
+				mockService.On("Approve", mock.Anything, recoveryAdminID, id, input).
+					Return(&model.RecoveryRequest{Status: model.RecoveryApproved, NewEmail: &input.NewEmail}, nil)
+			}
+
+			w := postRecoveryJSON(router, "/api/admin/recovery-requests/"+id+"/approve", input)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			var res map[string]interface{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+			if tt.err == nil {
+				assert.Equal(t, model.RecoveryApproved, res["status"])
+				assert.NotContains(t, res, "token_hash")
+			} else if tt.wantErr != "" {
+				assert.Equal(t, tt.wantErr, res["code"])
+			}
+			assert.Empty(t, audit.entries, "admin routes are audited by middleware")
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRecoveryHandler_DenyRequest(t *testing.T) {
+	id := uuid.NewString()
+
+	t.Run("denied", func(t *testing.T) {
+		router, mockService, _ := setupRecoveryTest()
+		mockService.On("Deny", mock.Anything, recoveryAdminID, id).Return(&model.RecoveryRequest{Status: model.RecoveryDenied}, nil)
+
+		w := postRecoveryJSON(router, "/api/admin/recovery-requests/"+id+"/deny", nil)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("invalid ID", func(t *testing.T) {
+		router, mockService, _ := setupRecoveryTest()
+		mockService.On("Deny", mock.Anything, recoveryAdminID, "bad").Return(nil, service.ErrInvalidRecoveryID)
+
+		w := postRecoveryJSON(router, "/api/admin/recovery-requests/bad/deny", nil)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// RefreshCookieName is the cookie that holds the refresh token of clients
+// that log in with refresh=cookie, typically browsers. The cookie is
+// HttpOnly, so scripts cannot read it, Secure and SameSite=Strict, and only
+// sent to RefreshCookiePath, so it does not travel with every request. The
+// access token is returned in the body as usual, for the client to keep in
+// memory.
+const RefreshCookieName = "refresh_token"
+
+// RefreshCookiePath is the path of the refresh endpoint, which the refresh
+// cookie is scoped to.
+const RefreshCookiePath = "/api/auth/refresh"
+
+// wantsRefreshCookie reports whether the login request asks for the refresh
+// token in a cookie rather than in the body.
+func wantsRefreshCookie(c *gin.Context) bool {
+	return c.Query("refresh") == "cookie"
+}
+
+// setRefreshCookie sets the refresh cookie to token, expiring with it after maxAge.
+func setRefreshCookie(c *gin.Context, token string, maxAge time.Duration) {
+	http.SetCookie(c.Writer, refreshCookie(token, int(maxAge/time.Second)))
+}
+
+// clearRefreshCookie tells the client to delete the refresh cookie.
+func clearRefreshCookie(c *gin.Context) {
+	http.SetCookie(c.Writer, refreshCookie("", -1))
+}
+
+func refreshCookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     RefreshCookieName,
+		Value:    value,
+		Path:     RefreshCookiePath,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	}
+}
+
+// bindRefreshToken returns the refresh token of the request: the one in the
+// refresh cookie if the request has one, and otherwise the one in the JSON
+// body, which mobile and other clients without cookies send. fromCookie
+// reports which it is, so that the rotated token is returned the same way.
+func bindRefreshToken(c *gin.Context) (input service.RefreshInput, fromCookie bool, err error) {
+	if token, err := c.Cookie(RefreshCookieName); err == nil && token != "" {
+		return service.RefreshInput{RefreshToken: token}, true, nil
+	}
+	err = c.ShouldBindJSON(&input)
+	return input, false, err
+}
@@ -0,0 +1,197 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// refreshCookieOf returns the refresh cookie the response sets, failing the
+// test if it sets none.
+func refreshCookieOf(t *testing.T, res *testutil.APIResponse) *http.Cookie {
+	t.Helper()
+	for _, cookie := range res.Result().Cookies() {
+		if cookie.Name == RefreshCookieName {
+			return cookie
+		}
+	}
+	require.Fail(t, "no refresh cookie set")
+	return nil
+}
+
+// assertRefreshCookie checks that cookie holds value, expires after maxAge
+// seconds, or is deleted if maxAge is negative, and has the attributes that
+// keep it from scripts and other paths.
+func assertRefreshCookie(t *testing.T, cookie *http.Cookie, value string, maxAge int) {
+	t.Helper()
+	assert.Equal(t, value, cookie.Value)
+	assert.Equal(t, maxAge, cookie.MaxAge)
+	assert.Equal(t, "/api/auth/refresh", cookie.Path)
+	assert.True(t, cookie.HttpOnly)
+	assert.True(t, cookie.Secure)
+	assert.Equal(t, http.SameSiteStrictMode, cookie.SameSite)
+}
+
+func withRefreshCookie(token string) testutil.RequestOption {
+	return testutil.WithHeader("Cookie", RefreshCookieName+"="+token)
+}
+
+func TestAuthHandler_LoginRefreshCookie(t *testing.T) {
+	tokens := &service.TokenPair{
+		AccessToken:      "test-token",
+		RefreshToken:     "test-refresh-token",
+		ExpiresIn:        15 * time.Minute,
+		RefreshExpiresIn: 7 * 24 * time.Hour,
+		User:             goldenUser(false),
+	}
+	input := service.LoginInput{Email: "test@example.com", Password: "password"}
+
+	t.Run("cookie mode", func(t *testing.T) {
+		api, mockService := setupTest(t)
+		mockService.On("Login", mock.Anything, mock.Anything).Return(tokens, nil)
+
+		res := api.Do(http.MethodPost, "/api/login?refresh=cookie", input).AssertStatus(http.StatusOK)
+
+		res.AssertJSON(`{"token":"test-token"}`)
+		assertRefreshCookie(t, refreshCookieOf(t, res), "test-refresh-token", 7*24*60*60)
+	})
+
+	t.Run("cookie mode including the user", func(t *testing.T) {
+		api, mockService := setupTest(t)
+		mockService.On("Login", mock.Anything, mock.Anything).Return(tokens, nil)
+
+		res := api.Do(http.MethodPost, "/api/login?refresh=cookie&include=user", input).AssertStatus(http.StatusOK)
+
+		assert.NotContains(t, res.JSON(), "refresh_token")
+		assert.Equal(t, "test-refresh-token", refreshCookieOf(t, res).Value)
+	})
+
+	t.Run("body mode", func(t *testing.T) {
+		api, mockService := setupTest(t)
+		mockService.On("Login", mock.Anything, mock.Anything).Return(tokens, nil)
+
+		res := api.Do(http.MethodPost, "/api/login", input).AssertStatus(http.StatusOK)
+
+		assert.Equal(t, "test-refresh-token", res.JSON()["refresh_token"])
+		assert.Empty(t, res.Result().Cookies())
+	})
+}
+
+func TestAuthHandler_RefreshCookie(t *testing.T) {
+	rotated := &service.TokenPair{
+		AccessToken:      "new-token",
+		RefreshToken:     "new-refresh-token",
+		RefreshExpiresIn: 7 * 24 * time.Hour,
+	}
+
+	t.Run("rotates the cookie", func(t *testing.T) {
+		api, mockService := setupTest(t)
+		mockService.On("Refresh", mock.Anything, service.RefreshInput{RefreshToken: "old-refresh-token"}).Return(rotated, nil)
+
+		res := api.Do(http.MethodPost, "/api/refresh", nil, withRefreshCookie("old-refresh-token")).AssertStatus(http.StatusOK)
+
+		res.AssertJSON(`{"token":"new-token"}`)
+		assertRefreshCookie(t, refreshCookieOf(t, res), "new-refresh-token", 7*24*60*60)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("prefers the cookie to the body", func(t *testing.T) {
+		api, mockService := setupTest(t)
+		mockService.On("Refresh", mock.Anything, service.RefreshInput{RefreshToken: "old-refresh-token"}).Return(rotated, nil)
+
+		api.Do(http.MethodPost, "/api/refresh", service.RefreshInput{RefreshToken: "other"}, withRefreshCookie("old-refresh-token")).
+			AssertStatus(http.StatusOK)
+
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("body mode sets no cookie", func(t *testing.T) {
+		api, mockService := setupTest(t)
+		mockService.On("Refresh", mock.Anything, mock.Anything).Return(rotated, nil)
+
+		res := api.Do(http.MethodPost, "/api/refresh", service.RefreshInput{RefreshToken: "old-refresh-token"}).
+			AssertStatus(http.StatusOK)
+
+		res.AssertJSON(`{"token":"new-token","refresh_token":"new-refresh-token"}`)
+		assert.Empty(t, res.Result().Cookies())
+	})
+
+	for _, err := range []error{service.ErrInvalidRefreshToken, service.ErrTokenReuseDetected} {
+		t.Run("clears a rejected cookie: "+err.Error(), func(t *testing.T) {
+			api, mockService := setupTest(t)
+			mockService.On("Refresh", mock.Anything, mock.Anything).Return(nil, err)
+
+			res := api.Do(http.MethodPost, "/api/refresh", nil, withRefreshCookie("old-refresh-token")).
+				AssertStatus(http.StatusUnauthorized)
+
+			assertRefreshCookie(t, refreshCookieOf(t, res), "", -1)
+		})
+	}
+
+	t.Run("keeps the cookie on a timeout", func(t *testing.T) {
+		api, mockService := setupTest(t)
+		mockService.On("Refresh", mock.Anything, mock.Anything).Return(nil, repository.ErrTimeout)
+
+		res := api.Do(http.MethodPost, "/api/refresh", nil, withRefreshCookie("old-refresh-token")).
+			AssertStatus(http.StatusGatewayTimeout)
+
+		assert.Empty(t, res.Result().Cookies())
+	})
+}
+
+func TestAuthHandler_Logout(t *testing.T) {
+	t.Run("cookie mode", func(t *testing.T) {
+		api, mockService := setupTest(t)
+		mockService.On("Logout", mock.Anything, service.RefreshInput{RefreshToken: "test-refresh-token"}).Return(nil)
+
+		res := api.Do(http.MethodDelete, "/api/refresh", nil, withRefreshCookie("test-refresh-token")).
+			AssertStatus(http.StatusNoContent)
+
+		assertRefreshCookie(t, refreshCookieOf(t, res), "", -1)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("body mode", func(t *testing.T) {
+		api, mockService := setupTest(t)
+		mockService.On("Logout", mock.Anything, service.RefreshInput{RefreshToken: "test-refresh-token"}).Return(nil)
+
+		res := api.Do(http.MethodDelete, "/api/refresh", service.RefreshInput{RefreshToken: "test-refresh-token"}).
+			AssertStatus(http.StatusNoContent)
+
+		assert.Empty(t, res.Result().Cookies())
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("unknown token", func(t *testing.T) {
+		api, mockService := setupTest(t)
+		mockService.On("Logout", mock.Anything, mock.Anything).Return(service.ErrInvalidRefreshToken)
+
+		res := api.Do(http.MethodDelete, "/api/refresh", nil, withRefreshCookie("unknown")).
+			AssertStatus(http.StatusUnauthorized)
+
+		assert.Equal(t, service.ErrInvalidRefreshToken.Error(), res.JSON()["error"])
+		assertRefreshCookie(t, refreshCookieOf(t, res), "", -1)
+	})
+
+	t.Run("missing token", func(t *testing.T) {
+		api, _ := setupTest(t)
+
+		api.Do(http.MethodDelete, "/api/refresh", service.RefreshInput{}).AssertStatus(http.StatusBadRequest)
+	})
+}
+
+func TestAuthHandler_LogoutAllClearsRefreshCookie(t *testing.T) {
+	api, mockService := setupTest(t, authenticatedAs("5b0c8f3e-2a1d-4c6b-9e7f-3d2a1b0c9e8f"))
+	mockService.On("LogoutAll", mock.Anything, "5b0c8f3e-2a1d-4c6b-9e7f-3d2a1b0c9e8f").Return(nil)
+
+	res := api.Do(http.MethodPost, "/api/logout-all", nil).AssertStatus(http.StatusOK)
+
+	assertRefreshCookie(t, refreshCookieOf(t, res), "", -1)
+}
@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/PakornBank/learn-go/internal/apierror"
+	"github.com/PakornBank/learn-go/internal/authctx"
+	"github.com/PakornBank/learn-go/internal/middleware"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RoleGrantService defines the methods that a role grant handler must implement.
+type RoleGrantService interface {
+	// Create grants a role to a user until the grant expires.
+	// ctx: The context for the request.
+	// actorID: The ID of the admin granting the role.
+	// input: The user, role, expiry and reason of the grant.
+	Create(ctx context.Context, actorID string, input service.RoleGrantInput) (*model.RoleGrant, error)
+
+	// Active returns the grants that have neither expired nor been revoked.
+	// ctx: The context for the request.
+	Active(ctx context.Context) ([]model.RoleGrant, error)
+
+	// Revoke ends a grant before it expires.
+	// ctx: The context for the request.
+	// actorID: The ID of the admin revoking the grant.
+	// id: The ID of the grant.
+	Revoke(ctx context.Context, actorID, id string) (*model.RoleGrant, error)
+}
+
+// RoleGrantHandler handles HTTP requests for temporary role grants.
+type RoleGrantHandler struct {
+	service RoleGrantService
+}
+
+// NewRoleGrantHandler creates a new instance of RoleGrantHandler with the provided service.
+func NewRoleGrantHandler(s RoleGrantService) *RoleGrantHandler {
+	return &RoleGrantHandler{service: s}
+}
+
+// CreateGrant handles an admin's request to give a user a role until a given
+// time, such as admin access for an afternoon. It expects a JSON payload with
+// the user_id, the role, the expires_at of the grant and the reason for it,
+// and responds with a 201 status code and the grant. The user is the subject
+// of the request's audit entry. An unknown role, a malformed user ID or an
+// expiry in the past or more than 7 days away results in a 400 status code,
+// an unknown user in a 404, and a user who already holds the role in a 409.
+// Granting a role the admin does not hold themselves results in a 403 with
+// the ROLE_NOT_HELD code, and a grant outlasting the admin's own grant of the
+// role in a 403 with the GRANT_OUTLASTS_ACTOR code.
+func (h *RoleGrantHandler) CreateGrant(c *gin.Context) {
+	identity, ok := authctx.User(c)
+	if !ok {
+		apierror.Respond(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var input service.RoleGrantInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.RespondInvalid(c, err)
+		return
+	}
+	if id, err := uuid.Parse(input.UserID); err == nil {
+		middleware.SetAuditSubject(c, id)
+	}
+
+	grant, err := h.service.Create(c.Request.Context(), identity.UserID.String(), input)
+	if err != nil {
+		h.respondError(c, err, "failed to create grant")
+		return
+	}
+
+	c.JSON(http.StatusCreated, grant)
+}
+
+// ListGrants handles an admin's request for the grants that are active now,
+// the first to expire first.
+func (h *RoleGrantHandler) ListGrants(c *gin.Context) {
+	grants, err := h.service.Active(c.Request.Context())
+	if err != nil {
+		h.respondError(c, err, "failed to list grants")
+		return
+	}
+	if grants == nil {
+		grants = []model.RoleGrant{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": grants})
+}
+
+// RevokeGrant handles an admin's request to end the grant identified by the
+// "id" path parameter before it expires, and responds with the revoked grant.
+// The user the role was granted to is the subject of the request's audit
+// entry. A malformed ID results in a 400 status code, and a grant that does
+// not exist, has expired or was already revoked in a 404.
+func (h *RoleGrantHandler) RevokeGrant(c *gin.Context) {
+	identity, ok := authctx.User(c)
+	if !ok {
+		apierror.Respond(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	grant, err := h.service.Revoke(c.Request.Context(), identity.UserID.String(), c.Param("id"))
+	if err != nil {
+		h.respondError(c, err, "failed to revoke grant")
+		return
+	}
+	middleware.SetAuditSubject(c, grant.UserID)
+
+	c.JSON(http.StatusOK, grant)
+}
+
+// respondError writes the response for an error returned by the service,
+// using fallback as the message of unexpected errors.
+func (h *RoleGrantHandler) respondError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, service.ErrUnknownRole),
+		errors.Is(err, service.ErrInvalidUserID),
+		errors.Is(err, service.ErrInvalidRoleGrantID),
+		errors.Is(err, service.ErrInvalidGrantExpiry):
+		apierror.Respond(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, service.ErrRoleNotHeld):
+		apierror.RespondCode(c, http.StatusForbidden, "ROLE_NOT_HELD", err.Error())
+	case errors.Is(err, service.ErrGrantOutlastsActor):
+		apierror.RespondCode(c, http.StatusForbidden, "GRANT_OUTLASTS_ACTOR", err.Error())
+	case errors.Is(err, service.ErrUserNotFound):
+		apierror.Respond(c, http.StatusNotFound, service.ErrUserNotFound.Error())
+	case errors.Is(err, service.ErrRoleGrantNotFound):
+		apierror.Respond(c, http.StatusNotFound, service.ErrRoleGrantNotFound.Error())
+	case errors.Is(err, service.ErrRoleAlreadyHeld):
+		apierror.Respond(c, http.StatusConflict, err.Error())
+	case errors.Is(err, repository.ErrTimeout):
+		apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+	default:
+		c.Error(err)
+		apierror.Respond(c, http.StatusInternalServerError, fallback)
+	}
+}
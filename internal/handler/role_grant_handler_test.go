@@ -0,0 +1,305 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/middleware"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/redact"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockRoleGrantService struct {
+	mock.Mock
+}
+
+func (ms *MockRoleGrantService) Create(ctx context.Context, actorID string, input service.RoleGrantInput) (*model.RoleGrant, error) {
+	args := ms.Called(ctx, actorID, input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.RoleGrant), args.Error(1)
+}
+
+func (ms *MockRoleGrantService) Active(ctx context.Context) ([]model.RoleGrant, error) {
+	args := ms.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.RoleGrant), args.Error(1)
+}
+
+func (ms *MockRoleGrantService) Revoke(ctx context.Context, actorID, id string) (*model.RoleGrant, error) {
+	args := ms.Called(ctx, actorID, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.RoleGrant), args.Error(1)
+}
+
+func setupRoleGrantTest(t *testing.T, adminID string) (*gin.Engine, *MockRoleGrantService, *recordedAudit) {
+	gin.SetMode(gin.TestMode)
+	redactor, err := redact.New(redact.DefaultFields)
+	require.NoError(t, err)
+
+	mockService := new(MockRoleGrantService)
+	audit := &recordedAudit{}
+	handler := NewRoleGrantHandler(mockService)
+
+	router := gin.New()
+	admin := router.Group("/api/admin/grants", authenticatedAs(adminID), middleware.AuditRequests(audit, redactor))
+	{
+		admin.POST("", handler.CreateGrant)
+		admin.GET("", handler.ListGrants)
+		admin.DELETE("/:id", handler.RevokeGrant)
+	}
+
+	return router, mockService, audit
+}
+
+func TestNewRoleGrantHandler(t *testing.T) {
+	service := new(MockRoleGrantService)
+	handler := NewRoleGrantHandler(service)
+
+	assert.NotNil(t, handler)
+	assert.Equal(t, service, handler.service)
+}
+
+func TestRoleGrantHandler_CreateGrant(t *testing.T) {
+	adminID := uuid.New()
+	userID := uuid.New()
+	expiresAt := time.Date(2026, 10, 17, 17, 0, 0, 0, time.UTC)
+	input := service.RoleGrantInput{UserID: userID.String(), Role: model.RoleAdmin, ExpiresAt: expiresAt, Reason: "incident 42"}
+	grant := &model.RoleGrant{ID: uuid.New(), UserID: userID, Role: model.RoleAdmin, Reason: "incident 42", GrantedBy: adminID, ExpiresAt: expiresAt}
+	created, err := json.Marshal(grant)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		body         string
+		mockFn       func(*MockRoleGrantService)
+		expectedCode int
+		expectedBody string
+		wantSubject  *uuid.UUID
+	}{
+		{
+			name: "granted",
+			body: `{"user_id":"` + userID.String() + `","role":"admin","expires_at":"2026-10-17T17:00:00Z","reason":"incident 42"}`,
+			mockFn: func(ms *MockRoleGrantService) {
+				ms.On("Create", mock.Anything, adminID.String(), input).Return(grant, nil)
+			},
+			expectedCode: http.StatusCreated,
+			expectedBody: string(created),
+			wantSubject:  &userID,
+		},
+		{
+			name:         "missing reason",
+			body:         `{"user_id":"` + userID.String() + `","role":"admin","expires_at":"2026-10-17T17:00:00Z"}`,
+			mockFn:       func(ms *MockRoleGrantService) {},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name: "role the admin does not hold",
+			body: `{"user_id":"` + userID.String() + `","role":"admin","expires_at":"2026-10-17T17:00:00Z","reason":"incident 42"}`,
+			mockFn: func(ms *MockRoleGrantService) {
+				ms.On("Create", mock.Anything, adminID.String(), input).Return(nil, service.ErrRoleNotHeld)
+			},
+			expectedCode: http.StatusForbidden,
+			expectedBody: `{"code":"ROLE_NOT_HELD","error":"cannot grant a role you do not hold"}`,
+			wantSubject:  &userID,
+		},
+		{
+			name: "outlasting the admin's own grant",
+			body: `{"user_id":"` + userID.String() + `","role":"admin","expires_at":"2026-10-17T17:00:00Z","reason":"incident 42"}`,
+			mockFn: func(ms *MockRoleGrantService) {
+				ms.On("Create", mock.Anything, adminID.String(), input).Return(nil, service.ErrGrantOutlastsActor)
+			},
+			expectedCode: http.StatusForbidden,
+			expectedBody: `{"code":"GRANT_OUTLASTS_ACTOR","error":"grant cannot outlast your own grant of the role"}`,
+			wantSubject:  &userID,
+		},
+		{
+			name: "invalid expiry",
+			body: `{"user_id":"` + userID.String() + `","role":"admin","expires_at":"2026-10-17T17:00:00Z","reason":"incident 42"}`,
+			mockFn: func(ms *MockRoleGrantService) {
+				ms.On("Create", mock.Anything, adminID.String(), input).Return(nil, service.ErrInvalidGrantExpiry)
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"expires_at must be in the future and at most 7 days away"}`,
+			wantSubject:  &userID,
+		},
+		{
+			name: "user already holds the role",
+			body: `{"user_id":"` + userID.String() + `","role":"admin","expires_at":"2026-10-17T17:00:00Z","reason":"incident 42"}`,
+			mockFn: func(ms *MockRoleGrantService) {
+				ms.On("Create", mock.Anything, adminID.String(), input).Return(nil, service.ErrRoleAlreadyHeld)
+			},
+			expectedCode: http.StatusConflict,
+			expectedBody: `{"error":"user already holds the role"}`,
+			wantSubject:  &userID,
+		},
+		{
+			name: "user not found",
+			body: `{"user_id":"` + userID.String() + `","role":"admin","expires_at":"2026-10-17T17:00:00Z","reason":"incident 42"}`,
+			mockFn: func(ms *MockRoleGrantService) {
+				ms.On("Create", mock.Anything, adminID.String(), input).
+					Return(nil, fmt.Errorf("%w: %w", service.ErrUserNotFound, repository.ErrNotFound))
+			},
+			expectedCode: http.StatusNotFound,
+			expectedBody: `{"error":"user not found"}`,
+			wantSubject:  &userID,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mockService, audit := setupRoleGrantTest(t, adminID.String())
+			tt.mockFn(mockService)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/api/admin/grants", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			if tt.expectedBody != "" {
+				assert.JSONEq(t, tt.expectedBody, w.Body.String())
+			}
+			require.Len(t, audit.entries, 1)
+			assert.Equal(t, adminID, audit.entries[0].ActorID)
+			assert.Equal(t, tt.wantSubject, audit.entries[0].SubjectID)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRoleGrantHandler_ListGrants(t *testing.T) {
+	adminID := uuid.New()
+
+	t.Run("active grants", func(t *testing.T) {
+		router, mockService, _ := setupRoleGrantTest(t, adminID.String())
+		grants := []model.RoleGrant{{ID: uuid.New(), UserID: uuid.New(), Role: model.RoleAdmin, GrantedBy: adminID}}
+		mockService.On("Active", mock.Anything).Return(grants, nil)
+		want, err := json.Marshal(gin.H{"data": grants})
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/admin/grants", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, string(want), w.Body.String())
+	})
+
+	t.Run("no active grants", func(t *testing.T) {
+		router, mockService, _ := setupRoleGrantTest(t, adminID.String())
+		mockService.On("Active", mock.Anything).Return(nil, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/admin/grants", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"data":[]}`, w.Body.String())
+	})
+
+	t.Run("database timeout", func(t *testing.T) {
+		router, mockService, _ := setupRoleGrantTest(t, adminID.String())
+		mockService.On("Active", mock.Anything).Return(nil, repository.ErrTimeout)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/admin/grants", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	})
+}
+
+func TestRoleGrantHandler_RevokeGrant(t *testing.T) {
+	adminID := uuid.New()
+	userID := uuid.New()
+	grantID := uuid.New()
+	revokedAt := time.Date(2026, 10, 17, 14, 0, 0, 0, time.UTC)
+	grant := &model.RoleGrant{ID: grantID, UserID: userID, Role: model.RoleAdmin, RevokedAt: &revokedAt, RevokedBy: &adminID, EndedAt: &revokedAt}
+	revoked, err := json.Marshal(grant)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		id           string
+		mockFn       func(*MockRoleGrantService)
+		expectedCode int
+		expectedBody string
+		wantSubject  *uuid.UUID
+	}{
+		{
+			name: "revoked",
+			id:   grantID.String(),
+			mockFn: func(ms *MockRoleGrantService) {
+				ms.On("Revoke", mock.Anything, adminID.String(), grantID.String()).Return(grant, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: string(revoked),
+			wantSubject:  &userID,
+		},
+		{
+			name: "grant no longer active",
+			id:   grantID.String(),
+			mockFn: func(ms *MockRoleGrantService) {
+				ms.On("Revoke", mock.Anything, adminID.String(), grantID.String()).
+					Return(nil, fmt.Errorf("%w: %w", service.ErrRoleGrantNotFound, repository.ErrNotFound))
+			},
+			expectedCode: http.StatusNotFound,
+			expectedBody: `{"error":"grant not found"}`,
+		},
+		{
+			name: "malformed ID",
+			id:   "not-a-uuid",
+			mockFn: func(ms *MockRoleGrantService) {
+				ms.On("Revoke", mock.Anything, adminID.String(), "not-a-uuid").Return(nil, service.ErrInvalidRoleGrantID)
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"invalid grant id"}`,
+		},
+		{
+			name: "unexpected error",
+			id:   grantID.String(),
+			mockFn: func(ms *MockRoleGrantService) {
+				ms.On("Revoke", mock.Anything, adminID.String(), grantID.String()).Return(nil, errors.New("boom"))
+			},
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: `{"error":"failed to revoke grant"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mockService, audit := setupRoleGrantTest(t, adminID.String())
+			tt.mockFn(mockService)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodDelete, "/api/admin/grants/"+tt.id, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			assert.JSONEq(t, tt.expectedBody, w.Body.String())
+			require.Len(t, audit.entries, 1)
+			assert.Equal(t, tt.wantSubject, audit.entries[0].SubjectID)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/apierror"
+	"github.com/PakornBank/learn-go/internal/authctx"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// SecurityEventService defines the methods that a security event handler must implement.
+type SecurityEventService interface {
+	// ListForUser returns a page of a user's security events, newest first,
+	// along with the cursor for the next page.
+	// ctx: The context for the request.
+	// userID: The ID of the user whose events to list.
+	// since: The time after which the events occurred, or zero for all of them.
+	// cursor: The opaque cursor returned by a previous call, or empty to start.
+	// limit: The maximum number of events to return.
+	ListForUser(ctx context.Context, userID string, since time.Time, cursor string, limit int) ([]model.SecurityEvent, string, error)
+}
+
+// SecurityEventHandler handles HTTP requests for security events.
+type SecurityEventHandler struct {
+	service SecurityEventService
+}
+
+// NewSecurityEventHandler creates a new instance of SecurityEventHandler with the provided service.
+func NewSecurityEventHandler(s SecurityEventService) *SecurityEventHandler {
+	return &SecurityEventHandler{service: s}
+}
+
+// ListOwnEvents handles the request for the authenticated user's own security
+// events. It accepts the optional "since" query parameter, an RFC 3339
+// timestamp after which the events occurred, along with "cursor" and "limit",
+// and responds in the same envelope as other paginated endpoints. An invalid
+// parameter results in a 400 status code, and a database timeout in a 504.
+func (h *SecurityEventHandler) ListOwnEvents(c *gin.Context) {
+	identity, ok := authctx.User(c)
+	if !ok {
+		apierror.Respond(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	limit, ok := parseLimit(c)
+	if !ok {
+		return
+	}
+
+	var since time.Time
+	if raw := c.Query("since"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			apierror.Respond(c, http.StatusBadRequest, "since must be an RFC 3339 timestamp")
+			return
+		}
+		since = t
+	}
+
+	events, nextCursor, err := h.service.ListForUser(c.Request.Context(), identity.UserID.String(), since, c.Query("cursor"), limit)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidLimit),
+			errors.Is(err, service.ErrInvalidUserID),
+			errors.Is(err, repository.ErrInvalidCursor):
+			apierror.Respond(c, http.StatusBadRequest, err.Error())
+		case errors.Is(err, repository.ErrTimeout):
+			apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+		default:
+			c.Error(err)
+			apierror.Respond(c, http.StatusInternalServerError, "failed to list security events")
+		}
+		return
+	}
+
+	if events == nil {
+		events = []model.SecurityEvent{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": events, "next_cursor": nextCursor})
+}
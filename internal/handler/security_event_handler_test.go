@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockSecurityEventService struct {
+	mock.Mock
+}
+
+func (ms *MockSecurityEventService) ListForUser(ctx context.Context, userID string, since time.Time, cursor string, limit int) ([]model.SecurityEvent, string, error) {
+	args := ms.Called(ctx, userID, since, cursor, limit)
+	if args.Get(0) == nil {
+		return nil, args.String(1), args.Error(2)
+	}
+	return args.Get(0).([]model.SecurityEvent), args.String(1), args.Error(2)
+}
+
+func setupSecurityEventTest(middleware gin.HandlerFunc) (*gin.Engine, *MockSecurityEventService) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockSecurityEventService)
+	handler := NewSecurityEventHandler(mockService)
+
+	router := gin.New()
+	router.GET("/api/auth/security-events", middleware, handler.ListOwnEvents)
+
+	return router, mockService
+}
+
+func TestNewSecurityEventHandler(t *testing.T) {
+	service := new(MockSecurityEventService)
+	handler := NewSecurityEventHandler(service)
+
+	assert.NotNil(t, handler)
+	assert.Equal(t, service, handler.service)
+}
+
+func TestSecurityEventHandler_ListOwnEvents(t *testing.T) {
+	userID := uuid.New()
+	since := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	event := model.SecurityEvent{
+		ID:         uuid.New(),
+		Type:       model.SecurityEventNewDeviceLogin,
+		OccurredAt: since.Add(time.Hour),
+		IPAddress:  "192.0.2.0",
+		Metadata:   []byte(`{"device":"Chrome on macOS"}`),
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		setupAuth      func(*gin.Context)
+		mockFn         func(*MockSecurityEventService)
+		wantCode       int
+		wantLen        int
+		wantNextCursor string
+		errContains    string
+	}{
+		{
+			name:      "successful listing",
+			setupAuth: authenticatedAs(userID.String()),
+			mockFn: func(ms *MockSecurityEventService) {
+				ms.On("ListForUser", mock.Anything, userID.String(), time.Time{}, "", service.DefaultListLimit).
+					Return([]model.SecurityEvent{event}, "next", nil)
+			},
+			wantCode:       http.StatusOK,
+			wantLen:        1,
+			wantNextCursor: "next",
+		},
+		{
+			name:      "empty page since a time",
+			query:     "?since=2026-10-01T12:00:00Z&cursor=abc&limit=5",
+			setupAuth: authenticatedAs(userID.String()),
+			mockFn: func(ms *MockSecurityEventService) {
+				ms.On("ListForUser", mock.Anything, userID.String(), since, "abc", 5).Return(nil, "", nil)
+			},
+			wantCode: http.StatusOK,
+			wantLen:  0,
+		},
+		{
+			name:        "unauthorized",
+			setupAuth:   func(c *gin.Context) {},
+			wantCode:    http.StatusUnauthorized,
+			errContains: "unauthorized",
+		},
+		{
+			name:        "invalid since",
+			query:       "?since=yesterday",
+			setupAuth:   authenticatedAs(userID.String()),
+			wantCode:    http.StatusBadRequest,
+			errContains: "since must be an RFC 3339 timestamp",
+		},
+		{
+			name:        "non-numeric limit",
+			query:       "?limit=ten",
+			setupAuth:   authenticatedAs(userID.String()),
+			wantCode:    http.StatusBadRequest,
+			errContains: service.ErrInvalidLimit.Error(),
+		},
+		{
+			name:      "invalid cursor",
+			query:     "?cursor=abc",
+			setupAuth: authenticatedAs(userID.String()),
+			mockFn: func(ms *MockSecurityEventService) {
+				ms.On("ListForUser", mock.Anything, userID.String(), time.Time{}, "abc", service.DefaultListLimit).
+					Return(nil, "", repository.ErrInvalidCursor)
+			},
+			wantCode:    http.StatusBadRequest,
+			errContains: repository.ErrInvalidCursor.Error(),
+		},
+		{
+			name:      "database timeout",
+			setupAuth: authenticatedAs(userID.String()),
+			mockFn: func(ms *MockSecurityEventService) {
+				ms.On("ListForUser", mock.Anything, userID.String(), time.Time{}, "", service.DefaultListLimit).
+					Return(nil, "", repository.ErrTimeout)
+			},
+			wantCode:    http.StatusGatewayTimeout,
+			errContains: repository.ErrTimeout.Error(),
+		},
+		{
+			name:      "service error",
+			setupAuth: authenticatedAs(userID.String()),
+			mockFn: func(ms *MockSecurityEventService) {
+				ms.On("ListForUser", mock.Anything, userID.String(), time.Time{}, "", service.DefaultListLimit).
+					Return(nil, "", errors.New("service error"))
+			},
+			wantCode:    http.StatusInternalServerError,
+			errContains: "failed to list security events",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mockService := setupSecurityEventTest(func(c *gin.Context) {
+				tt.setupAuth(c)
+				c.Next()
+			})
+			if tt.mockFn != nil {
+				tt.mockFn(mockService)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/auth/security-events"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+
+			var res map[string]interface{}
+			err := json.Unmarshal(w.Body.Bytes(), &res)
+			assert.NoError(t, err)
+
+			if tt.wantCode == http.StatusOK {
+				assert.Len(t, res["data"], tt.wantLen)
+				assert.Equal(t, tt.wantNextCursor, res["next_cursor"])
+			} else {
+				assert.Contains(t, res["error"], tt.errContains)
+			}
+
+			mockService.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/apierror"
+	"github.com/PakornBank/learn-go/internal/authctx"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// SessionService defines the methods that a session handler must implement.
+type SessionService interface {
+	// List returns the current refresh token of each of a user's active sessions.
+	// ctx: The context for the request.
+	// userID: The ID of the user whose sessions to list.
+	List(ctx context.Context, userID string) ([]model.RefreshToken, error)
+
+	// Rename gives one of a user's sessions a name.
+	// ctx: The context for the request.
+	// userID: The ID of the user the session belongs to.
+	// sessionID: The ID of the session to rename.
+	// input: The new name.
+	Rename(ctx context.Context, userID, sessionID string, input service.RenameSessionInput) error
+}
+
+// SessionResponse describes a session in responses. Name is the name the
+// user gave the session, or its device label if they did not rename it.
+type SessionResponse struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	Device       string    `json:"device"`
+	IPAddress    string    `json:"ip_address"`
+	Location     string    `json:"location"`
+	LastActiveAt time.Time `json:"last_active_at"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// SessionHandler handles HTTP requests for the authenticated user's sessions.
+type SessionHandler struct {
+	service SessionService
+}
+
+// NewSessionHandler creates a new instance of SessionHandler with the provided service.
+func NewSessionHandler(s SessionService) *SessionHandler {
+	return &SessionHandler{service: s}
+}
+
+// ListSessions handles the request for the authenticated user's active
+// sessions, most recently used first. Each is identified by the ID sent as
+// session_id in session events. A database timeout results in a 504.
+func (h *SessionHandler) ListSessions(c *gin.Context) {
+	identity, ok := authctx.User(c)
+	if !ok {
+		apierror.Respond(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	sessions, err := h.service.List(c.Request.Context(), identity.UserID.String())
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidUserID):
+			apierror.Respond(c, http.StatusBadRequest, err.Error())
+		case errors.Is(err, repository.ErrTimeout):
+			apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+		default:
+			c.Error(err)
+			apierror.Respond(c, http.StatusInternalServerError, "failed to list sessions")
+		}
+		return
+	}
+
+	data := make([]SessionResponse, len(sessions))
+	for i, session := range sessions {
+		data[i] = SessionResponse{
+			ID:           session.FamilyID.String(),
+			Name:         session.Name,
+			Device:       session.DeviceLabel,
+			IPAddress:    session.IPAddress,
+			Location:     session.Location,
+			LastActiveAt: session.CreatedAt,
+			ExpiresAt:    session.ExpiresAt,
+		}
+		if data[i].Name == "" {
+			data[i].Name = session.DeviceLabel
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+// RenameSession handles the request to name the authenticated user's session
+// identified by the "id" path parameter. An invalid ID or name results in a
+// 400 status code, and a session that does not exist or was revoked in a 404.
+func (h *SessionHandler) RenameSession(c *gin.Context) {
+	identity, ok := authctx.User(c)
+	if !ok {
+		apierror.Respond(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var input service.RenameSessionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.RespondInvalid(c, err)
+		return
+	}
+
+	err := h.service.Rename(c.Request.Context(), identity.UserID.String(), c.Param("id"), input)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidUserID),
+			errors.Is(err, service.ErrInvalidSessionID),
+			errors.Is(err, service.ErrInvalidSessionName):
+			apierror.Respond(c, http.StatusBadRequest, err.Error())
+		case errors.Is(err, service.ErrSessionNotFound):
+			apierror.Respond(c, http.StatusNotFound, service.ErrSessionNotFound.Error())
+		case errors.Is(err, repository.ErrTimeout):
+			apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+		default:
+			c.Error(err)
+			apierror.Respond(c, http.StatusInternalServerError, "failed to rename session")
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "session renamed"})
+}
@@ -0,0 +1,256 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockSessionService struct {
+	mock.Mock
+}
+
+func (ms *MockSessionService) List(ctx context.Context, userID string) ([]model.RefreshToken, error) {
+	args := ms.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.RefreshToken), args.Error(1)
+}
+
+func (ms *MockSessionService) Rename(ctx context.Context, userID, sessionID string, input service.RenameSessionInput) error {
+	args := ms.Called(ctx, userID, sessionID, input)
+	return args.Error(0)
+}
+
+func TestNewSessionHandler(t *testing.T) {
+	service := new(MockSessionService)
+	handler := NewSessionHandler(service)
+
+	assert.NotNil(t, handler)
+	assert.Equal(t, service, handler.service)
+}
+
+func TestSessionHandler_ListSessions(t *testing.T) {
+	const userID = "5b0c8f3e-2a1d-4c6b-9e7f-3d2a1b0c9e8f"
+	authenticated := authenticatedAs(userID)
+	lastActive := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	expires := lastActive.Add(7 * 24 * time.Hour)
+	renamed := model.RefreshToken{
+		ID:          uuid.New(),
+		FamilyID:    uuid.MustParse("11111111-1111-1111-1111-111111111111"),
+		DeviceLabel: "Chrome on macOS",
+		Name:        "Work laptop",
+		IPAddress:   "203.0.113.0",
+		CreatedAt:   lastActive,
+		ExpiresAt:   expires,
+	}
+	unnamed := model.RefreshToken{
+		ID:          uuid.New(),
+		FamilyID:    uuid.MustParse("22222222-2222-2222-2222-222222222222"),
+		DeviceLabel: "Unknown device",
+		CreatedAt:   lastActive,
+		ExpiresAt:   expires,
+	}
+
+	tests := []struct {
+		name         string
+		middleware   gin.HandlerFunc
+		mockFn       func(*MockSessionService)
+		wantCode     int
+		wantAttached bool
+		wantBody     string
+	}{
+		{
+			name:       "sessions",
+			middleware: authenticated,
+			mockFn: func(ms *MockSessionService) {
+				ms.On("List", mock.Anything, userID).Return([]model.RefreshToken{renamed, unnamed}, nil)
+			},
+			wantCode: http.StatusOK,
+			wantBody: `{"data": [
+				{"id": "11111111-1111-1111-1111-111111111111", "name": "Work laptop", "device": "Chrome on macOS",
+				 "ip_address": "203.0.113.0", "location": "", "last_active_at": "2024-01-01T12:00:00Z", "expires_at": "2024-01-08T12:00:00Z"},
+				{"id": "22222222-2222-2222-2222-222222222222", "name": "Unknown device", "device": "Unknown device",
+				 "ip_address": "", "location": "", "last_active_at": "2024-01-01T12:00:00Z", "expires_at": "2024-01-08T12:00:00Z"}
+			]}`,
+		},
+		{
+			name:       "no sessions",
+			middleware: authenticated,
+			mockFn: func(ms *MockSessionService) {
+				ms.On("List", mock.Anything, userID).Return(nil, nil)
+			},
+			wantCode: http.StatusOK,
+			wantBody: `{"data": []}`,
+		},
+		{
+			name:       "not authenticated",
+			middleware: func(c *gin.Context) {},
+			wantCode:   http.StatusUnauthorized,
+			wantBody:   `{"error":"unauthorized"}`,
+		},
+		{
+			name:       "database timeout",
+			middleware: authenticated,
+			mockFn: func(ms *MockSessionService) {
+				ms.On("List", mock.Anything, userID).Return(nil, repository.ErrTimeout)
+			},
+			wantCode: http.StatusGatewayTimeout,
+			wantBody: `{"error":"` + repository.ErrTimeout.Error() + `"}`,
+		},
+		{
+			name:       "database error",
+			middleware: authenticated,
+			mockFn: func(ms *MockSessionService) {
+				ms.On("List", mock.Anything, userID).Return(nil, errors.New("connection reset"))
+			},
+			wantCode:     http.StatusInternalServerError,
+			wantAttached: true,
+			wantBody:     `{"error":"failed to list sessions"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			mockService := new(MockSessionService)
+			if tt.mockFn != nil {
+				tt.mockFn(mockService)
+			}
+			var attached []error
+			router := gin.New()
+			router.GET("/api/auth/sessions", collectErrors(&attached), tt.middleware, NewSessionHandler(mockService).ListSessions)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/auth/sessions", nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Equal(t, tt.wantAttached, len(attached) > 0)
+			assert.JSONEq(t, tt.wantBody, w.Body.String())
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestSessionHandler_RenameSession(t *testing.T) {
+	const userID = "5b0c8f3e-2a1d-4c6b-9e7f-3d2a1b0c9e8f"
+	const sessionID = "11111111-1111-1111-1111-111111111111"
+	authenticated := authenticatedAs(userID)
+	input := service.RenameSessionInput{Name: "Work laptop"}
+
+	tests := []struct {
+		name         string
+		middleware   gin.HandlerFunc
+		body         string
+		mockFn       func(*MockSessionService)
+		wantCode     int
+		wantAttached bool
+		wantBody     string
+	}{
+		{
+			name:       "renamed",
+			middleware: authenticated,
+			body:       `{"name": "Work laptop"}`,
+			mockFn: func(ms *MockSessionService) {
+				ms.On("Rename", mock.Anything, userID, sessionID, input).Return(nil)
+			},
+			wantCode: http.StatusOK,
+			wantBody: `{"message":"session renamed"}`,
+		},
+		{
+			name:       "not authenticated",
+			middleware: func(c *gin.Context) {},
+			body:       `{"name": "Work laptop"}`,
+			wantCode:   http.StatusUnauthorized,
+			wantBody:   `{"error":"unauthorized"}`,
+		},
+		{
+			name:       "missing name",
+			middleware: authenticated,
+			body:       `{}`,
+			wantCode:   http.StatusBadRequest,
+		},
+		{
+			name:       "invalid name",
+			middleware: authenticated,
+			body:       `{"name": "Work laptop"}`,
+			mockFn: func(ms *MockSessionService) {
+				ms.On("Rename", mock.Anything, userID, sessionID, input).Return(service.ErrInvalidSessionName)
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"error":"` + service.ErrInvalidSessionName.Error() + `"}`,
+		},
+		{
+			name:       "session not found",
+			middleware: authenticated,
+			body:       `{"name": "Work laptop"}`,
+			mockFn: func(ms *MockSessionService) {
+				ms.On("Rename", mock.Anything, userID, sessionID, input).Return(service.ErrSessionNotFound)
+			},
+			wantCode: http.StatusNotFound,
+			wantBody: `{"error":"` + service.ErrSessionNotFound.Error() + `"}`,
+		},
+		{
+			name:       "database timeout",
+			middleware: authenticated,
+			body:       `{"name": "Work laptop"}`,
+			mockFn: func(ms *MockSessionService) {
+				ms.On("Rename", mock.Anything, userID, sessionID, input).Return(repository.ErrTimeout)
+			},
+			wantCode: http.StatusGatewayTimeout,
+			wantBody: `{"error":"` + repository.ErrTimeout.Error() + `"}`,
+		},
+		{
+			name:       "database error",
+			middleware: authenticated,
+			body:       `{"name": "Work laptop"}`,
+			mockFn: func(ms *MockSessionService) {
+				ms.On("Rename", mock.Anything, userID, sessionID, input).Return(errors.New("connection reset"))
+			},
+			wantCode:     http.StatusInternalServerError,
+			wantAttached: true,
+			wantBody:     `{"error":"failed to rename session"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			mockService := new(MockSessionService)
+			if tt.mockFn != nil {
+				tt.mockFn(mockService)
+			}
+			var attached []error
+			router := gin.New()
+			router.PATCH("/api/auth/sessions/:id", collectErrors(&attached), tt.middleware, NewSessionHandler(mockService).RenameSession)
+
+			req := httptest.NewRequest(http.MethodPatch, "/api/auth/sessions/"+sessionID, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Equal(t, tt.wantAttached, len(attached) > 0)
+			if tt.wantBody != "" {
+				assert.JSONEq(t, tt.wantBody, w.Body.String())
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
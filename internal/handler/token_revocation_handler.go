@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/PakornBank/learn-go/internal/apierror"
+	"github.com/PakornBank/learn-go/internal/middleware"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// TokenRevocationService defines the methods that a token revocation handler must implement.
+type TokenRevocationService interface {
+	// RevokeAll revokes every access and refresh token of a user and returns
+	// what was revoked.
+	// ctx: The context for the request.
+	// id: The ID of the user whose tokens to revoke.
+	RevokeAll(ctx context.Context, id string) (*repository.TokenRevocation, error)
+}
+
+// TokenRevocationHandler handles HTTP requests for revoking a user's tokens.
+type TokenRevocationHandler struct {
+	service TokenRevocationService
+}
+
+// NewTokenRevocationHandler creates a new instance of TokenRevocationHandler with the provided service.
+func NewTokenRevocationHandler(s TokenRevocationService) *TokenRevocationHandler {
+	return &TokenRevocationHandler{service: s}
+}
+
+// RevokeTokens handles an admin's request to sign the user identified by the
+// "id" path parameter out everywhere, such as after they report their account
+// compromised. It works for users whose deletion was requested too, and
+// responds with the user's new token version and the number of sessions,
+// refresh tokens and cache entries revoked. The user is the subject of the
+// request's audit entry. A malformed ID results in a 400 status code, an
+// unknown user in a 404, and a database timeout in a 504. Tokens revoked in
+// the database without the user's cache entry being cleared result in a 500
+// with the REVOCATION_INCOMPLETE code, as access tokens may be accepted until
+// the entry expires; retrying the request clears it.
+func (h *TokenRevocationHandler) RevokeTokens(c *gin.Context) {
+	if id, err := uuid.Parse(c.Param("id")); err == nil {
+		middleware.SetAuditSubject(c, id)
+	}
+
+	revocation, err := h.service.RevokeAll(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidUserID):
+			apierror.Respond(c, http.StatusBadRequest, err.Error())
+		case errors.Is(err, service.ErrUserNotFound):
+			apierror.Respond(c, http.StatusNotFound, service.ErrUserNotFound.Error())
+		case errors.Is(err, repository.ErrTimeout):
+			apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+		case errors.Is(err, repository.ErrCacheNotCleared):
+			c.Error(err)
+			apierror.RespondCode(c, http.StatusInternalServerError, "REVOCATION_INCOMPLETE", repository.ErrCacheNotCleared.Error())
+		default:
+			c.Error(err)
+			apierror.Respond(c, http.StatusInternalServerError, "failed to revoke tokens")
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, revocation)
+}
@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PakornBank/learn-go/internal/middleware"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockTokenRevocationService struct {
+	mock.Mock
+}
+
+func (ms *MockTokenRevocationService) RevokeAll(ctx context.Context, id string) (*repository.TokenRevocation, error) {
+	args := ms.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.TokenRevocation), args.Error(1)
+}
+
+func setupTokenRevocationTest(adminID string) (*gin.Engine, *MockTokenRevocationService, *recordedAudit) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockTokenRevocationService)
+	audit := &recordedAudit{}
+	handler := NewTokenRevocationHandler(mockService)
+
+	router := gin.New()
+	router.POST("/api/admin/users/:id/revoke-tokens",
+		authenticatedAs(adminID),
+		middleware.AuditRequests(audit, nil),
+		handler.RevokeTokens,
+	)
+
+	return router, mockService, audit
+}
+
+func TestNewTokenRevocationHandler(t *testing.T) {
+	service := new(MockTokenRevocationService)
+	handler := NewTokenRevocationHandler(service)
+
+	assert.NotNil(t, handler)
+	assert.Equal(t, service, handler.service)
+}
+
+func TestTokenRevocationHandler_RevokeTokens(t *testing.T) {
+	adminID := uuid.New()
+	userID := uuid.New()
+
+	tests := []struct {
+		name         string
+		id           string
+		mockFn       func(*MockTokenRevocationService)
+		expectedCode int
+		expectedBody string
+		wantSubject  *uuid.UUID
+	}{
+		{
+			name: "revoked",
+			id:   userID.String(),
+			mockFn: func(ms *MockTokenRevocationService) {
+				ms.On("RevokeAll", mock.Anything, userID.String()).
+					Return(&repository.TokenRevocation{TokenVersion: 4, Sessions: 2, RefreshTokens: 3, CacheEntries: 1}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"token_version":4,"sessions":2,"refresh_tokens":3,"cache_entries":1}`,
+			wantSubject:  &userID,
+		},
+		{
+			name: "cache entry not cleared",
+			id:   userID.String(),
+			mockFn: func(ms *MockTokenRevocationService) {
+				ms.On("RevokeAll", mock.Anything, userID.String()).
+					Return(&repository.TokenRevocation{TokenVersion: 4}, fmt.Errorf("%w: %w", repository.ErrCacheNotCleared, errors.New("redis down")))
+			},
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: `{"code":"REVOCATION_INCOMPLETE","error":"tokens revoked but the user cache entry was not cleared"}`,
+			wantSubject:  &userID,
+		},
+		{
+			name: "user not found",
+			id:   userID.String(),
+			mockFn: func(ms *MockTokenRevocationService) {
+				ms.On("RevokeAll", mock.Anything, userID.String()).Return(nil, service.ErrUserNotFound)
+			},
+			expectedCode: http.StatusNotFound,
+			expectedBody: `{"error":"user not found"}`,
+			wantSubject:  &userID,
+		},
+		{
+			name: "malformed ID",
+			id:   "not-a-uuid",
+			mockFn: func(ms *MockTokenRevocationService) {
+				ms.On("RevokeAll", mock.Anything, "not-a-uuid").Return(nil, service.ErrInvalidUserID)
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"invalid user id"}`,
+		},
+		{
+			name: "database timeout",
+			id:   userID.String(),
+			mockFn: func(ms *MockTokenRevocationService) {
+				ms.On("RevokeAll", mock.Anything, userID.String()).Return(nil, repository.ErrTimeout)
+			},
+			expectedCode: http.StatusGatewayTimeout,
+			expectedBody: `{"error":"database query timed out"}`,
+			wantSubject:  &userID,
+		},
+		{
+			name: "unexpected error",
+			id:   userID.String(),
+			mockFn: func(ms *MockTokenRevocationService) {
+				ms.On("RevokeAll", mock.Anything, userID.String()).Return(nil, errors.New("boom"))
+			},
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: `{"error":"failed to revoke tokens"}`,
+			wantSubject:  &userID,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mockService, audit := setupTokenRevocationTest(adminID.String())
+			tt.mockFn(mockService)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/api/admin/users/"+tt.id+"/revoke-tokens", nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			assert.JSONEq(t, tt.expectedBody, w.Body.String())
+			require.Len(t, audit.entries, 1)
+			assert.Equal(t, adminID, audit.entries[0].ActorID)
+			assert.Equal(t, tt.wantSubject, audit.entries[0].SubjectID)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
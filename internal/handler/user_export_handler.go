@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/apierror"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// The formats users can be exported in.
+const (
+	ExportFormatCSV  = "csv"
+	ExportFormatJSON = "json"
+)
+
+// exportColumns are the columns of a CSV export. The password hash is never
+// exported, whatever the format.
+var exportColumns = []string{
+	"id", "email", "full_name", "role", "username", "avatar_url",
+	"locale", "timezone", "last_login_at", "created_at", "updated_at",
+}
+
+// UserExportService defines the methods that a user export handler must implement.
+type UserExportService interface {
+	// Export calls fn with batches of the users created after createdAfter, oldest first.
+	// ctx: The context for the request.
+	// createdAfter: The time the users were created after, or zero for every user.
+	// fn: Called with each batch; an error stops the export.
+	Export(ctx context.Context, createdAfter time.Time, fn func([]model.User) error) error
+}
+
+// UserExportHandler handles HTTP requests for exporting users in bulk.
+type UserExportHandler struct {
+	service UserExportService
+}
+
+// NewUserExportHandler creates a new instance of UserExportHandler with the provided service.
+func NewUserExportHandler(s UserExportService) *UserExportHandler {
+	return &UserExportHandler{service: s}
+}
+
+// Export handles the request to download every user as a file. It accepts
+// the optional "format" query parameter, csv (the default) or json, and
+// "created_after", an RFC 3339 timestamp the users were created after. Users
+// are written as they are read, batch by batch, and the export stops when the
+// client disconnects. An invalid parameter results in a 400 status code, and
+// a database timeout before anything was written in a 504. Once the file has
+// started, an error can only cut it short.
+func (h *UserExportHandler) Export(c *gin.Context) {
+	format := c.DefaultQuery("format", ExportFormatCSV)
+	var w exportWriter
+	switch format {
+	case ExportFormatCSV:
+		w = &csvExportWriter{csv: csv.NewWriter(c.Writer)}
+	case ExportFormatJSON:
+		w = &jsonExportWriter{w: c.Writer}
+	default:
+		apierror.Respond(c, http.StatusBadRequest, "format must be csv or json")
+		return
+	}
+
+	var createdAfter time.Time
+	if raw := c.Query("created_after"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			apierror.Respond(c, http.StatusBadRequest, "created_after must be an RFC 3339 timestamp")
+			return
+		}
+		createdAfter = t
+	}
+
+	// The response starts with the first batch, so that an error reading it
+	// can still be reported with a proper status code.
+	started := false
+	start := func() error {
+		started = true
+		c.Header("Content-Type", w.contentType())
+		c.Header("Content-Disposition", `attachment; filename="users.`+format+`"`)
+		c.Status(http.StatusOK)
+		return w.begin()
+	}
+	err := h.service.Export(c.Request.Context(), createdAfter, func(users []model.User) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		if err := w.write(users); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	})
+	if err == nil && !started {
+		err = start()
+	}
+	if err == nil {
+		err = w.end()
+	}
+	if err == nil {
+		return
+	}
+
+	if started {
+		// The client gave up, or the status was already sent; all that is left
+		// is to stop writing.
+		if !errors.Is(err, context.Canceled) {
+			c.Error(err)
+		}
+		c.Abort()
+		return
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		c.Abort()
+	case errors.Is(err, repository.ErrTimeout):
+		apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+	default:
+		c.Error(err)
+		apierror.Respond(c, http.StatusInternalServerError, "failed to export users")
+	}
+}
+
+// exportWriter writes users in the format of an export, a batch at a time.
+type exportWriter interface {
+	contentType() string
+	begin() error
+	write(users []model.User) error
+	end() error
+}
+
+// csvExportWriter writes users as CSV rows under a header row. Fields holding
+// commas, quotes or line breaks are quoted as RFC 4180 requires.
+type csvExportWriter struct {
+	csv *csv.Writer
+}
+
+func (w *csvExportWriter) contentType() string { return "text/csv; charset=utf-8" }
+
+func (w *csvExportWriter) begin() error {
+	return w.csv.Write(exportColumns)
+}
+
+func (w *csvExportWriter) write(users []model.User) error {
+	for i := range users {
+		u := &users[i]
+		record := []string{
+			u.ID.String(), u.Email, u.FullName, u.Role, stringOrEmpty(u.Username), stringOrEmpty(u.AvatarURL),
+			u.Locale, u.Timezone, timeOrEmpty(u.LastLoginAt), exportTime(u.CreatedAt), exportTime(u.UpdatedAt),
+		}
+		if err := w.csv.Write(record); err != nil {
+			return err
+		}
+	}
+	w.csv.Flush()
+	return w.csv.Error()
+}
+
+func (w *csvExportWriter) end() error {
+	w.csv.Flush()
+	return w.csv.Error()
+}
+
+func stringOrEmpty(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func timeOrEmpty(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return exportTime(*t)
+}
+
+func exportTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// jsonExportWriter writes users as a JSON array of the same objects the other
+// endpoints respond with.
+type jsonExportWriter struct {
+	w       io.Writer
+	written bool
+}
+
+func (w *jsonExportWriter) contentType() string { return "application/json; charset=utf-8" }
+
+func (w *jsonExportWriter) begin() error {
+	_, err := io.WriteString(w.w, "[")
+	return err
+}
+
+func (w *jsonExportWriter) write(users []model.User) error {
+	for i := range users {
+		data, err := json.Marshal(FromModel(&users[i]))
+		if err != nil {
+			return err
+		}
+		if w.written {
+			data = append([]byte(","), data...)
+		}
+		if _, err := w.w.Write(data); err != nil {
+			return err
+		}
+		w.written = true
+	}
+	return nil
+}
+
+func (w *jsonExportWriter) end() error {
+	_, err := io.WriteString(w.w, "]")
+	return err
+}
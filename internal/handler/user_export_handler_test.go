@@ -0,0 +1,242 @@
+package handler
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockUserExportService struct {
+	mock.Mock
+}
+
+// Export records the call and, unless an error is configured, passes the
+// configured batches to fn, checking ctx between them like the repository.
+func (ms *MockUserExportService) Export(ctx context.Context, createdAfter time.Time, fn func([]model.User) error) error {
+	args := ms.Called(ctx, createdAfter)
+	if err := args.Error(1); err != nil {
+		return err
+	}
+	for _, batch := range args.Get(0).([][]model.User) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestNewUserExportHandler(t *testing.T) {
+	service := new(MockUserExportService)
+	handler := NewUserExportHandler(service)
+
+	assert.NotNil(t, handler)
+	assert.Equal(t, service, handler.service)
+}
+
+func exportUsers() (model.User, model.User) {
+	first := testutil.NewMockUser(testutil.WithUsername("ted"), testutil.WithCreatedAt(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)))
+	first.FullName = `O'Brien, "Ted"` + "\nJr."
+	second := testutil.NewMockUser(testutil.WithEmail("second@example.com"), testutil.WithCreatedAt(first.CreatedAt.Add(time.Hour)))
+	return first, second
+}
+
+func TestUserExportHandler_ExportCSV(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	first, second := exportUsers()
+	mockService := new(MockUserExportService)
+	mockService.On("Export", mock.Anything, time.Time{}).Return([][]model.User{{first}, {second}}, nil)
+	router := gin.New()
+	router.GET("/api/admin/users/export", NewUserExportHandler(mockService).Export)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/users/export", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="users.csv"`, w.Header().Get("Content-Disposition"))
+	assert.NotContains(t, w.Body.String(), first.PasswordHash)
+	assert.Contains(t, w.Body.String(), `"O'Brien, ""Ted""`+"\nJr.\"")
+
+	records, err := csv.NewReader(strings.NewReader(w.Body.String())).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		exportColumns,
+		{first.ID.String(), first.Email, first.FullName, first.Role, "ted", "", first.Locale, first.Timezone, "", "2024-01-02T03:04:05Z", "2024-01-02T03:04:05Z"},
+		{second.ID.String(), second.Email, second.FullName, second.Role, "", "", second.Locale, second.Timezone, "", "2024-01-02T04:04:05Z", "2024-01-02T04:04:05Z"},
+	}, records)
+	mockService.AssertExpectations(t)
+}
+
+func TestUserExportHandler_ExportJSON(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	first, second := exportUsers()
+	createdAfter := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mockService := new(MockUserExportService)
+	mockService.On("Export", mock.Anything, createdAfter).Return([][]model.User{{first, second}}, nil)
+	router := gin.New()
+	router.GET("/api/admin/users/export", NewUserExportHandler(mockService).Export)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/users/export?format=json&created_after=2024-01-01T00:00:00Z", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="users.json"`, w.Header().Get("Content-Disposition"))
+	assert.NotContains(t, w.Body.String(), "password")
+	assert.JSONEq(t, `[
+		{"id":"`+first.ID.String()+`","email":"`+first.Email+`","full_name":"O'Brien, \"Ted\"\nJr.","role":"user",
+		 "last_login_at":null,"created_at":"2024-01-02T03:04:05Z","updated_at":"2024-01-02T03:04:05Z",
+		 "metadata":{},"avatar_url":"`+gravatar(first.Email)+`","username":"ted","locale":"`+first.Locale+`","timezone":"`+first.Timezone+`"},
+		{"id":"`+second.ID.String()+`","email":"second@example.com","full_name":"`+second.FullName+`","role":"user",
+		 "last_login_at":null,"created_at":"2024-01-02T04:04:05Z","updated_at":"2024-01-02T04:04:05Z",
+		 "metadata":{},"avatar_url":"`+gravatar(second.Email)+`","username":null,"locale":"`+second.Locale+`","timezone":"`+second.Timezone+`"}
+	]`, w.Body.String())
+	mockService.AssertExpectations(t)
+}
+
+func TestUserExportHandler_ExportEmpty(t *testing.T) {
+	tests := []struct {
+		format   string
+		wantBody string
+	}{
+		{format: "csv", wantBody: strings.Join(exportColumns, ",") + "\n"},
+		{format: "json", wantBody: "[]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			mockService := new(MockUserExportService)
+			mockService.On("Export", mock.Anything, time.Time{}).Return([][]model.User{}, nil)
+			router := gin.New()
+			router.GET("/api/admin/users/export", NewUserExportHandler(mockService).Export)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/admin/users/export?format="+tt.format, nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tt.wantBody, w.Body.String())
+		})
+	}
+}
+
+func TestUserExportHandler_ExportErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		query        string
+		mockFn       func(*MockUserExportService)
+		wantCode     int
+		wantAttached bool
+		wantBody     string
+	}{
+		{
+			name:     "unknown format",
+			query:    "?format=xml",
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"error":"format must be csv or json"}`,
+		},
+		{
+			name:     "invalid created_after",
+			query:    "?created_after=yesterday",
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"error":"created_after must be an RFC 3339 timestamp"}`,
+		},
+		{
+			name: "database timeout",
+			mockFn: func(ms *MockUserExportService) {
+				ms.On("Export", mock.Anything, time.Time{}).Return(nil, repository.ErrTimeout)
+			},
+			wantCode: http.StatusGatewayTimeout,
+			wantBody: `{"error":"` + repository.ErrTimeout.Error() + `"}`,
+		},
+		{
+			name: "database error",
+			mockFn: func(ms *MockUserExportService) {
+				ms.On("Export", mock.Anything, time.Time{}).Return(nil, errors.New("connection reset"))
+			},
+			wantCode:     http.StatusInternalServerError,
+			wantAttached: true,
+			wantBody:     `{"error":"failed to export users"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			mockService := new(MockUserExportService)
+			if tt.mockFn != nil {
+				tt.mockFn(mockService)
+			}
+			var attached []error
+			router := gin.New()
+			router.GET("/api/admin/users/export", collectErrors(&attached), NewUserExportHandler(mockService).Export)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/admin/users/export"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Equal(t, tt.wantAttached, len(attached) > 0)
+			assert.Empty(t, w.Header().Get("Content-Disposition"))
+			assert.JSONEq(t, tt.wantBody, w.Body.String())
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+// cancellingRecorder cancels the request once the first batch is flushed, as
+// a client disconnecting mid-download does.
+type cancellingRecorder struct {
+	*httptest.ResponseRecorder
+	cancel context.CancelFunc
+}
+
+func (r *cancellingRecorder) Flush() {
+	r.ResponseRecorder.Flush()
+	r.cancel()
+}
+
+func TestUserExportHandler_ExportCancelled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	first, second := exportUsers()
+	mockService := new(MockUserExportService)
+	mockService.On("Export", mock.Anything, time.Time{}).Return([][]model.User{{first}, {second}}, nil)
+	var attached []error
+	router := gin.New()
+	router.GET("/api/admin/users/export", collectErrors(&attached), NewUserExportHandler(mockService).Export)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/users/export", nil).WithContext(ctx)
+	w := &cancellingRecorder{ResponseRecorder: httptest.NewRecorder(), cancel: cancel}
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), first.Email)
+	assert.NotContains(t, w.Body.String(), second.Email)
+	assert.Empty(t, attached)
+}
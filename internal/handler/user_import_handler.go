@@ -0,0 +1,195 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/PakornBank/learn-go/internal/apierror"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// importFormField is the multipart form field carrying an uploaded CSV file.
+const importFormField = "file"
+
+// maxImportRequestBytes bounds an import request. The file is streamed rather
+// than buffered, so the bound only stops runaway uploads.
+const maxImportRequestBytes = 64 << 20
+
+// UserImportService defines the methods that a user import handler must implement.
+type UserImportService interface {
+	// Import creates the users listed in a CSV file and reports the outcome of every row.
+	// ctx: The context for the request.
+	// r: The CSV file, read as the import goes.
+	// opts: Whether to only validate the file, and whether to invite users without a password.
+	Import(ctx context.Context, r io.Reader, opts service.ImportOptions) (*service.ImportReport, error)
+
+	// AcceptInvite sets the first password of an imported user.
+	// ctx: The context for the request.
+	// input: The token from the invite email and the chosen password.
+	AcceptInvite(ctx context.Context, input service.AcceptInviteInput) error
+}
+
+// UserImportHandler handles HTTP requests for importing users in bulk and for
+// the links in the invite emails sent to them.
+type UserImportHandler struct {
+	service UserImportService
+}
+
+// NewUserImportHandler creates a new instance of UserImportHandler with the provided service.
+func NewUserImportHandler(s UserImportService) *UserImportHandler {
+	return &UserImportHandler{service: s}
+}
+
+// importRowResponse is the outcome of one row in an import response.
+type importRowResponse struct {
+	Row     int    `json:"row"`
+	Email   string `json:"email,omitempty"`
+	Status  string `json:"status"`
+	Reason  string `json:"reason,omitempty"`
+	Invited bool   `json:"invited,omitempty"`
+}
+
+type importResponse struct {
+	DryRun           bool                `json:"dry_run"`
+	Created          int                 `json:"created"`
+	SkippedDuplicate int                 `json:"skipped_duplicate"`
+	Invalid          int                 `json:"invalid"`
+	Rows             []importRowResponse `json:"rows"`
+}
+
+// Import handles the request to create users from a CSV file. The file is
+// either the request body, sent as text/csv, or the "file" field of a
+// multipart form. With the dry_run query parameter set to true nothing is
+// stored, and with invite set to true rows without a password hash are
+// accepted and their users emailed a link to choose one. It responds with
+// the outcome of every row. A file without the required columns results in
+// a 400 status code, and one over 64MB in a 413.
+func (h *UserImportHandler) Import(c *gin.Context) {
+	var opts service.ImportOptions
+	for _, param := range []struct {
+		name  string
+		value *bool
+	}{
+		{name: "dry_run", value: &opts.DryRun},
+		{name: "invite", value: &opts.Invite},
+	} {
+		raw := c.Query(param.name)
+		if raw == "" {
+			continue
+		}
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			apierror.Respond(c, http.StatusBadRequest, "invalid "+param.name+": must be true or false")
+			return
+		}
+		*param.value = value
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportRequestBytes)
+	file, err := importFile(c.Request)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	report, err := h.service.Import(c.Request.Context(), file, opts)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	response := importResponse{
+		DryRun:           report.DryRun,
+		Created:          report.Created,
+		SkippedDuplicate: report.SkippedDuplicate,
+		Invalid:          report.Invalid,
+		Rows:             make([]importRowResponse, len(report.Rows)),
+	}
+	for i, row := range report.Rows {
+		response.Rows[i] = importRowResponse(row)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// errImportFileMissing is returned by importFile for a multipart form
+// without the file field.
+var errImportFileMissing = errors.New("import file is required")
+
+// importFile returns the CSV file of an import request: the file field of a
+// multipart form, or else the body itself.
+func importFile(req *http.Request) (io.Reader, error) {
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return req.Body, nil
+	}
+
+	reader, err := req.MultipartReader()
+	if err != nil {
+		return nil, errImportFileMissing
+	}
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil, errImportFileMissing
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == importFormField {
+			return part, nil
+		}
+	}
+}
+
+func (h *UserImportHandler) respondError(c *gin.Context, err error) {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		apierror.Respond(c, http.StatusRequestEntityTooLarge, "import file must not exceed 64MB")
+	case errors.Is(err, errImportFileMissing):
+		apierror.Respond(c, http.StatusBadRequest, errImportFileMissing.Error())
+	case errors.Is(err, service.ErrInvalidImportHeader):
+		apierror.Respond(c, http.StatusBadRequest, service.ErrInvalidImportHeader.Error())
+	case errors.Is(err, repository.ErrTimeout):
+		apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+	default:
+		c.Error(err)
+		apierror.Respond(c, http.StatusInternalServerError, "failed to import users")
+	}
+}
+
+// AcceptInvite handles the link of an invite email. It expects a JSON payload
+// with the token from the link and the password the user chose. An unknown,
+// expired or already used token, or a breached password, results in a 400
+// status code.
+func (h *UserImportHandler) AcceptInvite(c *gin.Context) {
+	var input service.AcceptInviteInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.RespondInvalid(c, err)
+		return
+	}
+
+	if err := h.service.AcceptInvite(c.Request.Context(), input); err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidInviteToken):
+			apierror.Respond(c, http.StatusBadRequest, service.ErrInvalidInviteToken.Error())
+		case errors.Is(err, service.ErrPasswordTooShort),
+			errors.Is(err, service.ErrPasswordBreached):
+			apierror.Respond(c, http.StatusBadRequest, err.Error())
+		case errors.Is(err, repository.ErrTimeout):
+			apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+		default:
+			c.Error(err)
+			apierror.Respond(c, http.StatusInternalServerError, "failed to accept invite")
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "password set, you can now log in"})
+}
@@ -0,0 +1,310 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockUserImportService struct {
+	mock.Mock
+}
+
+// Import reads the whole file before recording the call, so tests can match on its content.
+func (ms *MockUserImportService) Import(ctx context.Context, r io.Reader, opts service.ImportOptions) (*service.ImportReport, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	args := ms.Called(ctx, string(data), opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.ImportReport), args.Error(1)
+}
+
+func (ms *MockUserImportService) AcceptInvite(ctx context.Context, input service.AcceptInviteInput) error {
+	args := ms.Called(ctx, input)
+	return args.Error(0)
+}
+
+func TestNewUserImportHandler(t *testing.T) {
+	service := new(MockUserImportService)
+	handler := NewUserImportHandler(service)
+
+	assert.NotNil(t, handler)
+	assert.Equal(t, service, handler.service)
+}
+
+func multipartImport(t *testing.T, field, content string) (string, *bytes.Buffer) {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	require.NoError(t, writer.WriteField("note", "ignored"))
+	part, err := writer.CreateFormFile(field, "users.csv")
+	require.NoError(t, err)
+	_, err = part.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	return writer.FormDataContentType(), &body
+}
+
+func TestUserImportHandler_Import(t *testing.T) {
+	const csv = "email,full_name\nada@example.com,Ada\n"
+	report := &service.ImportReport{
+		Created: 1,
+		Invalid: 1,
+		Rows: []service.ImportRowResult{
+			{Row: 2, Email: "ada@example.com", Status: service.ImportCreated, Invited: true},
+			{Row: 3, Status: service.ImportInvalid, Reason: "wrong number of fields"},
+		},
+	}
+	const reportBody = `{
+		"dry_run": false,
+		"created": 1,
+		"skipped_duplicate": 0,
+		"invalid": 1,
+		"rows": [
+			{"row": 2, "email": "ada@example.com", "status": "created", "invited": true},
+			{"row": 3, "status": "invalid", "reason": "wrong number of fields"}
+		]
+	}`
+
+	tests := []struct {
+		name         string
+		query        string
+		contentType  string
+		body         func(t *testing.T) (string, io.Reader)
+		mockFn       func(*MockUserImportService)
+		wantCode     int
+		wantAttached bool
+		wantBody     string
+	}{
+		{
+			name: "csv body",
+			mockFn: func(ms *MockUserImportService) {
+				ms.On("Import", mock.Anything, csv, service.ImportOptions{}).Return(report, nil)
+			},
+			wantCode: http.StatusOK,
+			wantBody: reportBody,
+		},
+		{
+			name: "multipart upload",
+			body: func(t *testing.T) (string, io.Reader) {
+				return multipartImport(t, "file", csv)
+			},
+			mockFn: func(ms *MockUserImportService) {
+				ms.On("Import", mock.Anything, csv, service.ImportOptions{}).Return(report, nil)
+			},
+			wantCode: http.StatusOK,
+			wantBody: reportBody,
+		},
+		{
+			name:  "dry run with invites",
+			query: "?dry_run=true&invite=1",
+			mockFn: func(ms *MockUserImportService) {
+				ms.On("Import", mock.Anything, csv, service.ImportOptions{DryRun: true, Invite: true}).
+					Return(&service.ImportReport{DryRun: true, Rows: []service.ImportRowResult{}}, nil)
+			},
+			wantCode: http.StatusOK,
+			wantBody: `{"dry_run":true,"created":0,"skipped_duplicate":0,"invalid":0,"rows":[]}`,
+		},
+		{
+			name:     "invalid dry_run",
+			query:    "?dry_run=maybe",
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"error":"invalid dry_run: must be true or false"}`,
+		},
+		{
+			name: "multipart without file",
+			body: func(t *testing.T) (string, io.Reader) {
+				return multipartImport(t, "attachment", csv)
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"error":"import file is required"}`,
+		},
+		{
+			name: "invalid header",
+			mockFn: func(ms *MockUserImportService) {
+				ms.On("Import", mock.Anything, csv, service.ImportOptions{}).Return(nil, service.ErrInvalidImportHeader)
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"error":"` + service.ErrInvalidImportHeader.Error() + `"}`,
+		},
+		{
+			name: "database timeout",
+			mockFn: func(ms *MockUserImportService) {
+				ms.On("Import", mock.Anything, csv, service.ImportOptions{}).Return(nil, repository.ErrTimeout)
+			},
+			wantCode: http.StatusGatewayTimeout,
+			wantBody: `{"error":"` + repository.ErrTimeout.Error() + `"}`,
+		},
+		{
+			name: "database error",
+			mockFn: func(ms *MockUserImportService) {
+				ms.On("Import", mock.Anything, csv, service.ImportOptions{}).Return(nil, errors.New("connection reset"))
+			},
+			wantCode:     http.StatusInternalServerError,
+			wantAttached: true,
+			wantBody:     `{"error":"failed to import users"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			mockService := new(MockUserImportService)
+			if tt.mockFn != nil {
+				tt.mockFn(mockService)
+			}
+			var attached []error
+			router := gin.New()
+			router.POST("/api/admin/users/import", collectErrors(&attached), NewUserImportHandler(mockService).Import)
+
+			contentType, body := "text/csv", io.Reader(strings.NewReader(csv))
+			if tt.body != nil {
+				contentType, body = tt.body(t)
+			}
+			req := httptest.NewRequest(http.MethodPost, "/api/admin/users/import"+tt.query, body)
+			req.Header.Set("Content-Type", contentType)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Equal(t, tt.wantAttached, len(attached) > 0)
+			assert.JSONEq(t, tt.wantBody, w.Body.String())
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUserImportHandler_ImportTooLarge(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockService := new(MockUserImportService)
+	router := gin.New()
+	router.POST("/api/admin/users/import", NewUserImportHandler(mockService).Import)
+
+	body := io.MultiReader(strings.NewReader("email,full_name\n"), io.LimitReader(zeroReader{}, maxImportRequestBytes))
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/users/import", body)
+	req.Header.Set("Content-Type", "text/csv")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.JSONEq(t, `{"error":"import file must not exceed 64MB"}`, w.Body.String())
+}
+
+// zeroReader reads an endless stream of zero bytes.
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+func TestUserImportHandler_AcceptInvite(t *testing.T) {
+	const body = `{"token": "invite-token", "password": "new-password"}`
+	input := service.AcceptInviteInput{Token: "invite-token", Password: "new-password"}
+
+	tests := []struct {
+		name         string
+		body         string
+		mockFn       func(*MockUserImportService)
+		wantCode     int
+		wantAttached bool
+		wantBody     string
+	}{
+		{
+			name: "accepted",
+			body: body,
+			mockFn: func(ms *MockUserImportService) {
+				ms.On("AcceptInvite", mock.Anything, input).Return(nil)
+			},
+			wantCode: http.StatusOK,
+			wantBody: `{"message":"password set, you can now log in"}`,
+		},
+		{
+			name:     "short password",
+			body:     `{"token": "invite-token", "password": "short"}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name: "invalid token",
+			body: body,
+			mockFn: func(ms *MockUserImportService) {
+				ms.On("AcceptInvite", mock.Anything, input).Return(service.ErrInvalidInviteToken)
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"error":"` + service.ErrInvalidInviteToken.Error() + `"}`,
+		},
+		{
+			name: "breached password",
+			body: body,
+			mockFn: func(ms *MockUserImportService) {
+				ms.On("AcceptInvite", mock.Anything, input).Return(service.ErrPasswordBreached)
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"error":"` + service.ErrPasswordBreached.Error() + `"}`,
+		},
+		{
+			name: "database timeout",
+			body: body,
+			mockFn: func(ms *MockUserImportService) {
+				ms.On("AcceptInvite", mock.Anything, input).Return(repository.ErrTimeout)
+			},
+			wantCode: http.StatusGatewayTimeout,
+			wantBody: `{"error":"` + repository.ErrTimeout.Error() + `"}`,
+		},
+		{
+			name: "database error",
+			body: body,
+			mockFn: func(ms *MockUserImportService) {
+				ms.On("AcceptInvite", mock.Anything, input).Return(errors.New("connection reset"))
+			},
+			wantCode:     http.StatusInternalServerError,
+			wantAttached: true,
+			wantBody:     `{"error":"failed to accept invite"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			mockService := new(MockUserImportService)
+			if tt.mockFn != nil {
+				tt.mockFn(mockService)
+			}
+			var attached []error
+			router := gin.New()
+			router.POST("/api/auth/invites/accept", collectErrors(&attached), NewUserImportHandler(mockService).AcceptInvite)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/auth/invites/accept", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Equal(t, tt.wantAttached, len(attached) > 0)
+			if tt.wantBody != "" {
+				assert.JSONEq(t, tt.wantBody, w.Body.String())
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/google/uuid"
+)
+
+// UserResponse is the public representation of a user returned by the API.
+// Handlers respond with it instead of model.User, so columns added to the
+// users table are not exposed until they are added here deliberately.
+// The field names and order match the JSON model.User used to produce, which
+// existing clients depend on; fields added since come after them.
+type UserResponse struct {
+	ID          uuid.UUID  `json:"id"`
+	Email       string     `json:"email"`
+	FullName    string     `json:"full_name"`
+	Role        string     `json:"role"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	Metadata  json.RawMessage `json:"metadata"`
+	AvatarURL Avatar          `json:"avatar_url"`
+	Username  *string         `json:"username"`
+	Locale    string          `json:"locale"`
+	Timezone  string          `json:"timezone"`
+}
+
+// FromModel maps a user to its public representation.
+func FromModel(u *model.User) UserResponse {
+	return UserResponse{
+		ID:          u.ID,
+		Email:       u.Email,
+		FullName:    u.FullName,
+		Role:        u.Role,
+		LastLoginAt: u.LastLoginAt,
+		CreatedAt:   u.CreatedAt,
+		UpdatedAt:   u.UpdatedAt,
+		Metadata:    metadataOrEmpty(u),
+		AvatarURL:   avatarOf(u),
+		Username:    u.Username,
+		Locale:      u.Locale,
+		Timezone:    u.Timezone,
+	}
+}
+
+// InitialsAvatars makes users who have not uploaded an avatar get the
+// initials and color to draw one with instead of their Gravatar. It is set
+// once at startup from the AVATAR_FALLBACK setting.
+var InitialsAvatars bool
+
+// gravatarURL is the Gravatar image URL of an email hash. d=404 makes
+// Gravatar answer 404 for emails without one, so clients can fall back to
+// drawing initials themselves.
+const gravatarURL = "https://www.gravatar.com/avatar/%s?d=404"
+
+// avatarColors are the colors of initials avatars. Users keep their color
+// only as long as this list keeps its order, so colors are only ever appended.
+var avatarColors = []string{
+	"#dc2626", "#ea580c", "#d97706", "#65a30d", "#16a34a", "#0d9488",
+	"#0891b2", "#2563eb", "#4f46e5", "#7c3aed", "#c026d3", "#db2777",
+}
+
+// Avatar is the avatar of a user in the API. It is encoded as the URL of
+// an image if there is one, and otherwise as an object with the initials
+// and background color to draw one with.
+type Avatar struct {
+	URL      string
+	Initials string
+	Color    string
+}
+
+// MarshalJSON encodes the avatar as its URL if it has one, and as
+// {"initials":..,"color":..} otherwise.
+func (a Avatar) MarshalJSON() ([]byte, error) {
+	if a.URL != "" {
+		return json.Marshal(a.URL)
+	}
+	return json.Marshal(struct {
+		Initials string `json:"initials"`
+		Color    string `json:"color"`
+	}{a.Initials, a.Color})
+}
+
+// avatarOf returns the avatar of a user: their uploaded image if they have
+// one, otherwise their Gravatar, or their initials if InitialsAvatars is set.
+// It is derived on every call, so nothing about it is stored.
+func avatarOf(u *model.User) Avatar {
+	switch {
+	case u.AvatarURL != nil:
+		return Avatar{URL: *u.AvatarURL}
+	case InitialsAvatars:
+		return Avatar{Initials: initials(u.FullName, u.Email), Color: avatarColor(u.FullName)}
+	default:
+		return Avatar{URL: gravatar(u.Email)}
+	}
+}
+
+// gravatar returns the Gravatar URL of email, which Gravatar identifies by
+// the MD5 hash of the trimmed, lowercased address.
+func gravatar(email string) string {
+	sum := md5.Sum([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return fmt.Sprintf(gravatarURL, hex.EncodeToString(sum[:]))
+}
+
+// initials returns the uppercased first letters of the first and last words
+// of name, or of email if name is blank.
+func initials(name, email string) string {
+	words := strings.Fields(name)
+	if len(words) == 0 {
+		words = []string{strings.TrimSpace(email)}
+	}
+	first, _ := utf8.DecodeRuneInString(words[0])
+	if first == utf8.RuneError {
+		return ""
+	}
+	result := string(unicode.ToUpper(first))
+	if len(words) > 1 {
+		last, _ := utf8.DecodeRuneInString(words[len(words)-1])
+		result += string(unicode.ToUpper(last))
+	}
+	return result
+}
+
+// avatarColor picks the color of an initials avatar from the FNV-1a hash of
+// the full name, so the same name always gets the same color.
+func avatarColor(name string) string {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return avatarColors[h.Sum32()%uint32(len(avatarColors))]
+}
+
+// metadataOrEmpty returns the user's metadata, or an empty object if they
+// have none, so clients never have to handle null.
+func metadataOrEmpty(u *model.User) json.RawMessage {
+	if len(u.Metadata) == 0 || string(u.Metadata) == "null" {
+		return json.RawMessage(`{}`)
+	}
+	return json.RawMessage(u.Metadata)
+}
@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// goldenUser returns a user with fixed values, so responses rendered from it
+// are stable. The timestamps use a non-UTC zone and sub-second precision so
+// that their formatting is part of what the golden files lock in.
+func goldenUser(lastLogin bool) *model.User {
+	zone := time.FixedZone("ICT", 7*60*60)
+	user := &model.User{
+		ID:           uuid.MustParse("6f1c2a8e-3b7d-4c55-9a0e-2f4d8b1e7c90"),
+		Email:        "golden@example.com",
+		PasswordHash: "$2a$10$hashedpassword",
+		FullName:     "Golden <User> & Co",
+		Role:         model.RoleUser,
+		Locale:       "en",
+		Timezone:     "UTC",
+		CreatedAt:    time.Date(2024, 1, 2, 3, 4, 5, 123456789, zone),
+		UpdatedAt:    time.Date(2024, 2, 3, 4, 5, 6, 0, time.UTC),
+	}
+	if lastLogin {
+		lastLoginAt := time.Date(2024, 3, 4, 5, 6, 7, 890000000, time.UTC)
+		user.LastLoginAt = &lastLoginAt
+		user.Metadata = []byte(`{"locale":"th","tags":["a","b"],"theme":"dark"}`)
+		avatarURL := "/avatars/6f1c2a8e-3b7d-4c55-9a0e-2f4d8b1e7c90-0123456789abcdef.png"
+		user.AvatarURL = &avatarURL
+		username := "golden_user"
+		user.Username = &username
+		user.Locale = "th-TH"
+		user.Timezone = "Asia/Bangkok"
+	}
+	return user
+}
+
+func TestFromModel(t *testing.T) {
+	user := goldenUser(true)
+
+	got := FromModel(user)
+
+	assert.Equal(t, UserResponse{
+		ID:          user.ID,
+		Email:       user.Email,
+		FullName:    user.FullName,
+		Role:        user.Role,
+		LastLoginAt: user.LastLoginAt,
+		CreatedAt:   user.CreatedAt,
+		UpdatedAt:   user.UpdatedAt,
+		Metadata:    json.RawMessage(user.Metadata),
+		AvatarURL:   Avatar{URL: *user.AvatarURL},
+		Username:    user.Username,
+		Locale:      "th-TH",
+		Timezone:    "Asia/Bangkok",
+	}, got)
+}
+
+func TestFromModel_EmptyMetadata(t *testing.T) {
+	for _, metadata := range []string{"", "null"} {
+		user := goldenUser(false)
+		user.Metadata = []byte(metadata)
+
+		assert.JSONEq(t, `{}`, string(FromModel(user).Metadata))
+	}
+}
+
+func TestFromModel_Avatar(t *testing.T) {
+	tests := []struct {
+		name     string
+		initials bool
+		upload   string
+		fullName string
+		email    string
+		want     string
+	}{
+		{name: "uploaded", upload: "/avatars/me.png", fullName: "Test User", email: "test@example.com", want: `"/avatars/me.png"`},
+		{name: "uploaded with initials", initials: true, upload: "/avatars/me.png", fullName: "Test User", want: `"/avatars/me.png"`},
+		{
+			name:     "gravatar of normalized email",
+			fullName: "Test User",
+			email:    "  Test@Example.COM ",
+			want:     `"https://www.gravatar.com/avatar/55502f40dc8b7c769880b10874abc9d0?d=404"`,
+		},
+		{name: "initials", initials: true, fullName: "Test User", want: `{"initials":"TU","color":"#4f46e5"}`},
+		{name: "initials of first and last name", initials: true, fullName: "Ada King Lovelace", want: `{"initials":"AL","color":"#7c3aed"}`},
+		{name: "initials of one name", initials: true, fullName: "golden", want: `{"initials":"G","color":"#4f46e5"}`},
+		{name: "initials of non-Latin name", initials: true, fullName: "สมชาย ใจดี", want: `{"initials":"สใ","color":"#7c3aed"}`},
+		{name: "initials of email without name", initials: true, email: "test@example.com", want: `{"initials":"T","color":"#ea580c"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			InitialsAvatars = tt.initials
+			defer func() { InitialsAvatars = false }()
+			user := goldenUser(false)
+			user.FullName, user.Email = tt.fullName, tt.email
+			if tt.upload != "" {
+				user.AvatarURL = &tt.upload
+			}
+
+			got, err := json.Marshal(FromModel(user).AvatarURL)
+
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestUserResponse_Golden(t *testing.T) {
+	tests := []struct {
+		name   string
+		golden string
+		user   *model.User
+	}{
+		{name: "never logged in", golden: "user_response.json", user: goldenUser(false)},
+		{name: "logged in", golden: "user_response_last_login.json", user: goldenUser(true)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Run("register", func(t *testing.T) {
+				api, mockService := setupTest(t)
+				mockService.On("Register", mock.Anything, mock.Anything).Return(tt.user, nil)
+
+				body := `{"email":"golden@example.com","password":"password","full_name":"Golden User"}`
+				api.Do(http.MethodPost, "/api/register", body).
+					AssertStatus(http.StatusCreated).
+					AssertGolden(tt.golden)
+			})
+
+			t.Run("profile", func(t *testing.T) {
+				api, mockService := setupTest(t, authenticatedAs(tt.user.ID.String()))
+				mockService.On("GetUserByID", mock.Anything, tt.user.ID.String()).Return(tt.user, nil)
+
+				api.Do(http.MethodGet, "/api/profile", nil).
+					AssertStatus(http.StatusOK).
+					AssertGolden(tt.golden)
+			})
+
+			t.Run("keeps model encoding", func(t *testing.T) {
+				fromModel, err := json.Marshal(tt.user)
+				require.NoError(t, err)
+				fromResponse, err := json.Marshal(FromModel(tt.user))
+				require.NoError(t, err)
+
+				// Every field model.User used to produce keeps its encoding;
+				// fields added to the response since are ignored.
+				assert.True(t, strings.HasPrefix(string(fromResponse), strings.TrimSuffix(string(fromModel), "}")+","),
+					"response %s does not start with the model encoding %s", fromResponse, fromModel)
+			})
+		})
+	}
+}
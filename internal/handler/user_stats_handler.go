@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/apierror"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// UserStatsService defines the methods that a user stats handler must implement.
+type UserStatsService interface {
+	// Stats returns the total number of users and the recent signups.
+	// ctx: The context for the request.
+	Stats(ctx context.Context) (*service.UserStats, error)
+}
+
+// DailySignups is the number of users who signed up on a UTC day, written as YYYY-MM-DD.
+type DailySignups struct {
+	Date  string `json:"date"`
+	Count int64  `json:"count"`
+}
+
+// UserStatsResponse describes the user stats in responses.
+type UserStatsResponse struct {
+	TotalUsers   int64          `json:"total_users"`
+	Signups      int64          `json:"signups"`
+	DailySignups []DailySignups `json:"daily_signups"`
+}
+
+// UserStatsHandler handles HTTP requests for user statistics.
+type UserStatsHandler struct {
+	service UserStatsService
+}
+
+// NewUserStatsHandler creates a new instance of UserStatsHandler with the provided service.
+func NewUserStatsHandler(s UserStatsService) *UserStatsHandler {
+	return &UserStatsHandler{service: s}
+}
+
+// GetStats handles the request for the total number of users and the signups
+// of the last 30 days, in total and per day, oldest first. Days without
+// signups are included with a count of zero, so the series has an entry for
+// every day. A database timeout results in a 504.
+func (h *UserStatsHandler) GetStats(c *gin.Context) {
+	stats, err := h.service.Stats(c.Request.Context())
+	if err != nil {
+		if errors.Is(err, repository.ErrTimeout) {
+			apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+			return
+		}
+		c.Error(err)
+		apierror.Respond(c, http.StatusInternalServerError, "failed to get user stats")
+		return
+	}
+
+	c.JSON(http.StatusOK, UserStatsResponse{
+		TotalUsers:   stats.TotalUsers,
+		Signups:      stats.Signups,
+		DailySignups: dailySeries(stats),
+	})
+}
+
+// dailySeries returns the daily signups of stats with an entry for every day
+// from stats.From until stats.To, filling the days without signups with zero.
+func dailySeries(stats *service.UserStats) []DailySignups {
+	counts := make(map[string]int64, len(stats.DailySignups))
+	for _, daily := range stats.DailySignups {
+		counts[daily.Day.UTC().Format(time.DateOnly)] = daily.Count
+	}
+
+	series := []DailySignups{}
+	for day := stats.From.UTC(); day.Before(stats.To); day = day.AddDate(0, 0, 1) {
+		date := day.Format(time.DateOnly)
+		series = append(series, DailySignups{Date: date, Count: counts[date]})
+	}
+	return series
+}
@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockUserStatsService struct {
+	mock.Mock
+}
+
+func (ms *MockUserStatsService) Stats(ctx context.Context) (*service.UserStats, error) {
+	args := ms.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.UserStats), args.Error(1)
+}
+
+func TestNewUserStatsHandler(t *testing.T) {
+	service := new(MockUserStatsService)
+	handler := NewUserStatsHandler(service)
+
+	assert.NotNil(t, handler)
+	assert.Equal(t, service, handler.service)
+}
+
+// serveUserStats serves a GET /api/admin/stats request with stats returned
+// by the service and returns the response and the errors attached to it.
+func serveUserStats(t *testing.T, mockFn func(*MockUserStatsService)) (*httptest.ResponseRecorder, []error) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	mockService := new(MockUserStatsService)
+	mockFn(mockService)
+	var attached []error
+	router := gin.New()
+	router.GET("/api/admin/stats", collectErrors(&attached), NewUserStatsHandler(mockService).GetStats)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/stats", nil))
+	mockService.AssertExpectations(t)
+	return w, attached
+}
+
+func TestUserStatsHandler_GetStats(t *testing.T) {
+	to := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	from := to.AddDate(0, 0, -service.SignupSeriesDays)
+	stats := &service.UserStats{
+		TotalUsers: 120,
+		Signups:    6,
+		DailySignups: []repository.DailyCount{
+			{Day: from, Count: 2},
+			{Day: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), Count: 1},
+			{Day: to.AddDate(0, 0, -1), Count: 3},
+		},
+		From: from,
+		To:   to,
+	}
+
+	w, attached := serveUserStats(t, func(ms *MockUserStatsService) {
+		ms.On("Stats", mock.Anything).Return(stats, nil)
+	})
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, attached)
+	var res UserStatsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, int64(120), res.TotalUsers)
+	assert.Equal(t, int64(6), res.Signups)
+
+	require.Len(t, res.DailySignups, service.SignupSeriesDays, "one entry per day")
+	assert.Equal(t, DailySignups{Date: "2026-09-18", Count: 2}, res.DailySignups[0])
+	assert.Equal(t, DailySignups{Date: "2026-09-19", Count: 0}, res.DailySignups[1], "days without signups are zero")
+	assert.Equal(t, DailySignups{Date: "2026-10-01", Count: 1}, res.DailySignups[13])
+	assert.Equal(t, DailySignups{Date: "2026-10-17", Count: 3}, res.DailySignups[29])
+	var total int64
+	for _, daily := range res.DailySignups {
+		total += daily.Count
+	}
+	assert.Equal(t, int64(6), total)
+}
+
+func TestUserStatsHandler_GetStatsNoSignups(t *testing.T) {
+	to := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	stats := &service.UserStats{From: to.AddDate(0, 0, -service.SignupSeriesDays), To: to}
+
+	w, _ := serveUserStats(t, func(ms *MockUserStatsService) {
+		ms.On("Stats", mock.Anything).Return(stats, nil)
+	})
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var res UserStatsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	require.Len(t, res.DailySignups, service.SignupSeriesDays)
+	for _, daily := range res.DailySignups {
+		assert.Zero(t, daily.Count)
+	}
+}
+
+func TestUserStatsHandler_GetStatsErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		wantCode     int
+		wantAttached bool
+		wantBody     string
+	}{
+		{
+			name:     "database timeout",
+			err:      repository.ErrTimeout,
+			wantCode: http.StatusGatewayTimeout,
+			wantBody: `{"error":"` + repository.ErrTimeout.Error() + `"}`,
+		},
+		{
+			name:         "database error",
+			err:          errors.New("connection reset"),
+			wantCode:     http.StatusInternalServerError,
+			wantAttached: true,
+			wantBody:     `{"error":"failed to get user stats"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, attached := serveUserStats(t, func(ms *MockUserStatsService) {
+				ms.On("Stats", mock.Anything).Return(nil, tt.err)
+			})
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Equal(t, tt.wantAttached, len(attached) > 0)
+			assert.JSONEq(t, tt.wantBody, w.Body.String())
+		})
+	}
+}
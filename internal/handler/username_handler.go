@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/PakornBank/learn-go/internal/apierror"
+	"github.com/PakornBank/learn-go/internal/authctx"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// UsernameService defines the methods that a username handler must implement.
+type UsernameService interface {
+	// Set gives a user without a username the requested one and returns the updated user.
+	// ctx: The context for the request.
+	// userID: The ID of the user choosing a username.
+	// input: The requested username.
+	Set(ctx context.Context, userID string, input service.SetUsernameInput) (*model.User, error)
+}
+
+// UsernameHandler handles HTTP requests for the authenticated user's username.
+type UsernameHandler struct {
+	service UsernameService
+}
+
+// NewUsernameHandler creates a new instance of UsernameHandler with the provided service.
+func NewUsernameHandler(s UsernameService) *UsernameHandler {
+	return &UsernameHandler{service: s}
+}
+
+// SetUsername handles the request to choose a username for the authenticated
+// user, who must not have one yet, and responds with the updated user.
+// A malformed or reserved username results in a 400 status code, and one that
+// is taken, or a user who already has a username, in a 409.
+func (h *UsernameHandler) SetUsername(c *gin.Context) {
+	identity, ok := authctx.User(c)
+	if !ok {
+		apierror.Respond(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var input service.SetUsernameInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.RespondInvalid(c, err)
+		return
+	}
+
+	user, err := h.service.Set(c.Request.Context(), identity.UserID.String(), input)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidUserID),
+			errors.Is(err, service.ErrInvalidUsername),
+			errors.Is(err, service.ErrUsernameReserved):
+			apierror.Respond(c, http.StatusBadRequest, err.Error())
+		case errors.Is(err, service.ErrUsernameTaken),
+			errors.Is(err, service.ErrUsernameAlreadySet):
+			apierror.Respond(c, http.StatusConflict, err.Error())
+		case errors.Is(err, repository.ErrTimeout):
+			apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+		default:
+			c.Error(err)
+			apierror.Respond(c, http.StatusInternalServerError, "failed to set username")
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, FromModel(user))
+}
@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockUsernameService struct {
+	mock.Mock
+}
+
+func (ms *MockUsernameService) Set(ctx context.Context, userID string, input service.SetUsernameInput) (*model.User, error) {
+	args := ms.Called(ctx, userID, input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+func TestNewUsernameHandler(t *testing.T) {
+	service := new(MockUsernameService)
+	handler := NewUsernameHandler(service)
+
+	assert.NotNil(t, handler)
+	assert.Equal(t, service, handler.service)
+}
+
+func TestUsernameHandler_SetUsername(t *testing.T) {
+	const userID = "5b0c8f3e-2a1d-4c6b-9e7f-3d2a1b0c9e8f"
+	authenticated := authenticatedAs(userID)
+	input := service.SetUsernameInput{Username: "tester"}
+	user := testutil.NewMockUser(testutil.WithUsername("tester"))
+
+	tests := []struct {
+		name         string
+		middleware   gin.HandlerFunc
+		body         string
+		mockFn       func(*MockUsernameService)
+		wantCode     int
+		wantAttached bool
+		wantBody     string
+	}{
+		{
+			name:       "set",
+			middleware: authenticated,
+			body:       `{"username": "tester"}`,
+			mockFn: func(ms *MockUsernameService) {
+				ms.On("Set", mock.Anything, userID, input).Return(&user, nil)
+			},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:       "not authenticated",
+			middleware: func(c *gin.Context) {},
+			body:       `{"username": "tester"}`,
+			wantCode:   http.StatusUnauthorized,
+			wantBody:   `{"error":"unauthorized"}`,
+		},
+		{
+			name:       "reserved",
+			middleware: authenticated,
+			body:       `{"username": "tester"}`,
+			mockFn: func(ms *MockUsernameService) {
+				ms.On("Set", mock.Anything, userID, input).Return(nil, service.ErrUsernameReserved)
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"error":"` + service.ErrUsernameReserved.Error() + `"}`,
+		},
+		{
+			name:       "taken",
+			middleware: authenticated,
+			body:       `{"username": "tester"}`,
+			mockFn: func(ms *MockUsernameService) {
+				ms.On("Set", mock.Anything, userID, input).Return(nil, service.ErrUsernameTaken)
+			},
+			wantCode: http.StatusConflict,
+			wantBody: `{"error":"` + service.ErrUsernameTaken.Error() + `"}`,
+		},
+		{
+			name:       "already set",
+			middleware: authenticated,
+			body:       `{"username": "tester"}`,
+			mockFn: func(ms *MockUsernameService) {
+				ms.On("Set", mock.Anything, userID, input).Return(nil, service.ErrUsernameAlreadySet)
+			},
+			wantCode: http.StatusConflict,
+			wantBody: `{"error":"` + service.ErrUsernameAlreadySet.Error() + `"}`,
+		},
+		{
+			name:       "database timeout",
+			middleware: authenticated,
+			body:       `{"username": "tester"}`,
+			mockFn: func(ms *MockUsernameService) {
+				ms.On("Set", mock.Anything, userID, input).Return(nil, repository.ErrTimeout)
+			},
+			wantCode: http.StatusGatewayTimeout,
+			wantBody: `{"error":"` + repository.ErrTimeout.Error() + `"}`,
+		},
+		{
+			name:       "database error",
+			middleware: authenticated,
+			body:       `{"username": "tester"}`,
+			mockFn: func(ms *MockUsernameService) {
+				ms.On("Set", mock.Anything, userID, input).Return(nil, errors.New("connection reset"))
+			},
+			wantCode:     http.StatusInternalServerError,
+			wantAttached: true,
+			wantBody:     `{"error":"failed to set username"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			mockService := new(MockUsernameService)
+			if tt.mockFn != nil {
+				tt.mockFn(mockService)
+			}
+			var attached []error
+			router := gin.New()
+			router.PATCH("/api/auth/profile/username", collectErrors(&attached), tt.middleware, NewUsernameHandler(mockService).SetUsername)
+
+			req := httptest.NewRequest(http.MethodPatch, "/api/auth/profile/username", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Equal(t, tt.wantAttached, len(attached) > 0)
+			if tt.wantBody != "" {
+				assert.JSONEq(t, tt.wantBody, w.Body.String())
+			} else {
+				assert.Contains(t, w.Body.String(), `"username":"tester"`)
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
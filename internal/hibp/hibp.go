@@ -0,0 +1,75 @@
+// Package hibp checks passwords against the Have I Been Pwned breached
+// password corpus using its k-anonymity range API, so that neither the
+// password nor its full hash ever leaves the process.
+package hibp
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultBaseURL is the range endpoint of the public Pwned Passwords API.
+const DefaultBaseURL = "https://api.pwnedpasswords.com/range/"
+
+// Client queries the Pwned Passwords range API.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewClient creates a Client whose requests give up after timeout.
+func NewClient(timeout time.Duration) *Client {
+	return &Client{
+		baseURL:    DefaultBaseURL,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// BreachCount returns how many times password appears in known breaches, or
+// zero if it does not. Only the first five hex characters of the password's
+// SHA-1 hash are sent; the rest of the hash is matched locally against the
+// returned suffixes. Responses are padded with decoy entries so their size
+// does not reveal the prefix.
+func (c *Client) BreachCount(ctx context.Context, password string) (int, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+prefix, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Add-Padding", "true")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("pwned passwords returned status %d", resp.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		candidate, count, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !ok || !strings.EqualFold(candidate, suffix) {
+			continue
+		}
+		n, err := strconv.Atoi(count)
+		if err != nil {
+			return 0, fmt.Errorf("invalid breach count %q", count)
+		}
+		return n, nil
+	}
+
+	return 0, scanner.Err()
+}
@@ -0,0 +1,103 @@
+package hibp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The SHA-1 of "password" is 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8.
+const (
+	passwordPrefix = "5BAA6"
+	passwordSuffix = "1E4C9B93F3F0682250B6CF8331B7EE68FD8"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc, timeout time.Duration) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client := NewClient(timeout)
+	client.baseURL = server.URL + "/range/"
+	return client
+}
+
+func TestClient_BreachCount(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		status    int
+		wantCount int
+		wantErr   bool
+	}{
+		{
+			name:      "hit",
+			body:      fmt.Sprintf("0018A45C4D1DEF81644B54AB7F969B88D65:1\r\n%s:3861493\r\n00D4F6E8FA6EECAD2A3AA415EEC418D38EC:2\r\n", passwordSuffix),
+			status:    http.StatusOK,
+			wantCount: 3861493,
+		},
+		{
+			name:      "miss",
+			body:      "0018A45C4D1DEF81644B54AB7F969B88D65:1\r\n00D4F6E8FA6EECAD2A3AA415EEC418D38EC:2\r\n",
+			status:    http.StatusOK,
+			wantCount: 0,
+		},
+		{
+			name:      "padding entry",
+			body:      passwordSuffix + ":0\r\n",
+			status:    http.StatusOK,
+			wantCount: 0,
+		},
+		{
+			name:    "unexpected status",
+			status:  http.StatusServiceUnavailable,
+			wantErr: true,
+		},
+		{
+			name:    "malformed count",
+			body:    passwordSuffix + ":many\r\n",
+			status:  http.StatusOK,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/range/"+passwordPrefix, r.URL.Path)
+				assert.Equal(t, "true", r.Header.Get("Add-Padding"))
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}, time.Second)
+
+			count, err := client.BreachCount(context.Background(), "password")
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantCount, count)
+		})
+	}
+}
+
+func TestClient_BreachCountTimeout(t *testing.T) {
+	release := make(chan struct{})
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}, 20*time.Millisecond)
+	defer close(release)
+
+	start := time.Now()
+	_, err := client.BreachCount(context.Background(), "password")
+
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+}
@@ -0,0 +1,80 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"log/slog"
+)
+
+// ErrLocked is returned by a job wrapped with Singleton when another replica
+// holds its lock. The Scheduler counts such runs as skipped, not failed.
+var ErrLocked = errors.New("job is locked by another replica")
+
+// Locker grants exclusive locks shared by every replica of the server.
+type Locker interface {
+	// TryLock acquires the lock called name without waiting. It returns
+	// false if another holder has it, and otherwise a function that
+	// releases it.
+	TryLock(ctx context.Context, name string) (release func(), acquired bool, err error)
+}
+
+// Singleton wraps fn so that, while it runs on one replica, the same job is
+// skipped on the others. It returns ErrLocked when the lock called name is
+// held elsewhere.
+func Singleton(locker Locker, name string, fn Func) Func {
+	return func(ctx context.Context) error {
+		release, acquired, err := locker.TryLock(ctx, name)
+		if err != nil {
+			return err
+		}
+		if !acquired {
+			return ErrLocked
+		}
+		defer release()
+		return fn(ctx)
+	}
+}
+
+// AdvisoryLocker is a Locker backed by PostgreSQL session-level advisory
+// locks. Each held lock keeps a database connection checked out until it is
+// released, so a lock is freed even if its holder crashes.
+type AdvisoryLocker struct {
+	db *sql.DB
+}
+
+// NewAdvisoryLocker creates an AdvisoryLocker taking its locks on db.
+func NewAdvisoryLocker(db *sql.DB) *AdvisoryLocker {
+	return &AdvisoryLocker{db: db}
+}
+
+// TryLock acquires the advisory lock keyed by the hash of name.
+func (l *AdvisoryLocker) TryLock(ctx context.Context, name string) (func(), bool, error) {
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock(hashtext($1))", name).Scan(&acquired); err != nil {
+		conn.Close()
+		return nil, false, err
+	}
+	if !acquired {
+		conn.Close()
+		return nil, false, nil
+	}
+
+	release := func() {
+		// The job's context may be done by now; unlock regardless.
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock(hashtext($1))", name); err != nil {
+			slog.Warn("failed to release advisory lock, discarding its connection", "lock", name, "error", err)
+			// Closing the session is the only other way to release the lock;
+			// a connection returned to the pool would keep it held.
+			_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+		conn.Close()
+	}
+	return release, true, nil
+}
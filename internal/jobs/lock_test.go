@@ -0,0 +1,98 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLocker is a Locker holding locks in memory.
+type fakeLocker struct {
+	mu   sync.Mutex
+	held map[string]bool
+}
+
+func (l *fakeLocker) TryLock(_ context.Context, name string) (func(), bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.held[name] {
+		return nil, false, nil
+	}
+	l.held[name] = true
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		delete(l.held, name)
+	}, true, nil
+}
+
+func TestSingleton(t *testing.T) {
+	locker := &fakeLocker{held: map[string]bool{}}
+	var inner error
+	job := Singleton(locker, "purge", func(ctx context.Context) error {
+		// While the job runs, other replicas are locked out.
+		inner = Singleton(locker, "purge", func(context.Context) error { return nil })(ctx)
+		return nil
+	})
+
+	require.NoError(t, job(context.Background()))
+	assert.ErrorIs(t, inner, ErrLocked)
+	assert.Empty(t, locker.held, "the lock is released after the run")
+}
+
+func TestAdvisoryLocker_TryLock(t *testing.T) {
+	tests := []struct {
+		name         string
+		mockFn       func(sqlmock.Sqlmock)
+		wantAcquired bool
+		wantErr      error
+	}{
+		{
+			name: "acquired",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectQuery(`SELECT pg_try_advisory_lock\(hashtext\(\$1\)\)`).WithArgs("purge").
+					WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(true))
+				sqlMock.ExpectExec(`SELECT pg_advisory_unlock\(hashtext\(\$1\)\)`).WithArgs("purge").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			wantAcquired: true,
+		},
+		{
+			name: "held by another replica",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectQuery(`SELECT pg_try_advisory_lock`).WithArgs("purge").
+					WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(false))
+			},
+		},
+		{
+			name: "database error",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectQuery(`SELECT pg_try_advisory_lock`).WillReturnError(sql.ErrConnDone)
+			},
+			wantErr: sql.ErrConnDone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, sqlMock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+			tt.mockFn(sqlMock)
+
+			release, acquired, err := NewAdvisoryLocker(db).TryLock(context.Background(), "purge")
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.wantAcquired, acquired)
+			if acquired {
+				release()
+			}
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
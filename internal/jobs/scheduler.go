@@ -0,0 +1,219 @@
+// Package jobs runs periodic maintenance work, such as purging deleted
+// accounts, in the background of the API server.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// DefaultJitter is the fraction of a job's interval by which its runs are
+// delayed at random, so that replicas started together do not run their
+// jobs at the same moment.
+const DefaultJitter = 0.1
+
+// Func is the work of a job. An error is logged and reported in the job's
+// Status; the job runs again at the next interval either way.
+type Func func(ctx context.Context) error
+
+// Clock tells the time and waits. It is replaced in tests to drive the
+// Scheduler without waiting for real time to pass.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Status describes the runs of a job so far.
+type Status struct {
+	Name     string
+	Interval time.Duration
+	// Running reports whether the job is running now.
+	Running bool
+	// Runs is the number of completed runs, failed ones included, and
+	// Failures the number of those that returned an error or panicked.
+	Runs     int
+	Failures int
+	// Skips is the number of runs skipped because another replica held the
+	// job's lock; see Singleton.
+	Skips int
+	// LastRun is when the last completed run started, or the zero time if
+	// none has completed yet.
+	LastRun      time.Time
+	LastDuration time.Duration
+	// LastError is the error of the last completed run, or empty if it succeeded.
+	LastError string
+}
+
+type job struct {
+	fn     Func
+	status Status
+}
+
+// Scheduler runs registered jobs, each on its own goroutine, at their
+// interval. A panicking job is recovered and counted as a failure.
+type Scheduler struct {
+	clock  Clock
+	jitter float64
+	random func() float64
+
+	mu   sync.Mutex
+	jobs []*job
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// Option configures a Scheduler.
+type Option func(*Scheduler)
+
+// WithClock makes the Scheduler tell the time and wait with clock.
+func WithClock(clock Clock) Option {
+	return func(s *Scheduler) {
+		s.clock = clock
+	}
+}
+
+// WithJitter sets the fraction of each job's interval by which its runs are
+// delayed at random, DefaultJitter by default. Zero runs jobs on time.
+func WithJitter(fraction float64) Option {
+	return func(s *Scheduler) {
+		s.jitter = fraction
+	}
+}
+
+// NewScheduler creates a Scheduler without jobs.
+func NewScheduler(opts ...Option) *Scheduler {
+	s := &Scheduler{
+		clock:  realClock{},
+		jitter: DefaultJitter,
+		random: rand.Float64,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Register adds a job called name that runs fn every interval. It must be
+// called before Start, and it panics if a job called name already exists or
+// interval is not positive.
+func (s *Scheduler) Register(name string, interval time.Duration, fn Func) {
+	if interval <= 0 {
+		panic(fmt.Sprintf("jobs: non-positive interval for job %q", name))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		if j.status.Name == name {
+			panic(fmt.Sprintf("jobs: job %q registered twice", name))
+		}
+	}
+	s.jobs = append(s.jobs, &job{fn: fn, status: Status{Name: name, Interval: interval}})
+}
+
+// Start runs every registered job in the background until ctx is cancelled
+// or Stop is called. The first run of each job comes after a random delay of
+// up to its jitter, and the following ones an interval, plus jitter, after
+// the previous run ends.
+func (s *Scheduler) Start(ctx context.Context) {
+	ctx, s.cancel = context.WithCancel(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(ctx, j)
+	}
+}
+
+// Stop cancels the context of running jobs and waits for them to return.
+func (s *Scheduler) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+}
+
+// Statuses returns the status of every job, in the order they were registered.
+func (s *Scheduler) Statuses() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]Status, len(s.jobs))
+	for i, j := range s.jobs {
+		statuses[i] = j.status
+	}
+	return statuses
+}
+
+// loop runs j until ctx is done.
+func (s *Scheduler) loop(ctx context.Context, j *job) {
+	defer s.wg.Done()
+
+	delay := s.jittered(0, j.status.Interval)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.clock.After(delay):
+		}
+		s.run(ctx, j)
+		delay = s.jittered(j.status.Interval, j.status.Interval)
+	}
+}
+
+// jittered returns base plus a random delay of up to the jitter fraction of interval.
+func (s *Scheduler) jittered(base, interval time.Duration) time.Duration {
+	return base + time.Duration(s.random()*s.jitter*float64(interval))
+}
+
+// run runs j once and records the outcome in its status.
+func (s *Scheduler) run(ctx context.Context, j *job) {
+	s.mu.Lock()
+	j.status.Running = true
+	s.mu.Unlock()
+
+	start := s.clock.Now()
+	err := call(ctx, j.status.Name, j.fn)
+	duration := s.clock.Now().Sub(start)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j.status.Running = false
+	if errors.Is(err, ErrLocked) {
+		j.status.Skips++
+		return
+	}
+	j.status.Runs++
+	j.status.LastRun = start
+	j.status.LastDuration = duration
+	j.status.LastError = ""
+	if err != nil {
+		j.status.Failures++
+		j.status.LastError = err.Error()
+		slog.ErrorContext(ctx, "job failed", "job", j.status.Name, "error", err)
+	}
+}
+
+// call runs fn, turning a panic into an error.
+func call(ctx context.Context, name string, fn Func) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.ErrorContext(ctx, "job panicked", "job", name, "panic", r, "stack", string(debug.Stack()))
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return fn(ctx)
+}
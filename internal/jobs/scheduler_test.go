@@ -0,0 +1,220 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is a Clock whose time only moves when Advance is called.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, fakeWaiter{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves the time forward by d, firing the waits that end by then.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			pending = append(pending, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	c.waiters = pending
+}
+
+// waiting returns the number of waits in progress.
+func (c *fakeClock) waiting() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+// startScheduler starts s and stops it at the end of the test.
+func startScheduler(t *testing.T, s *Scheduler) {
+	t.Helper()
+	s.Start(context.Background())
+	t.Cleanup(s.Stop)
+}
+
+// waitForStatus waits until the only job of s has status matching cond.
+func waitForStatus(t *testing.T, s *Scheduler, cond func(Status) bool) Status {
+	t.Helper()
+	var status Status
+	require.Eventually(t, func() bool {
+		status = s.Statuses()[0]
+		return cond(status)
+	}, time.Second, time.Millisecond)
+	return status
+}
+
+func TestScheduler_RunsAtInterval(t *testing.T) {
+	clock := newFakeClock()
+	s := NewScheduler(WithClock(clock), WithJitter(0))
+	s.Register("cleanup", time.Minute, func(context.Context) error { return nil })
+	startScheduler(t, s)
+
+	status := waitForStatus(t, s, func(s Status) bool { return s.Runs == 1 })
+	assert.Equal(t, clock.Now(), status.LastRun, "the first run is immediate without jitter")
+
+	require.Eventually(t, func() bool { return clock.waiting() == 1 }, time.Second, time.Millisecond)
+	clock.Advance(time.Minute - time.Second)
+	assert.Equal(t, 1, s.Statuses()[0].Runs, "the interval has not passed")
+
+	clock.Advance(time.Second)
+	status = waitForStatus(t, s, func(s Status) bool { return s.Runs == 2 })
+	assert.Equal(t, clock.Now(), status.LastRun)
+	assert.Equal(t, "cleanup", status.Name)
+	assert.Equal(t, time.Minute, status.Interval)
+	assert.Zero(t, status.Failures)
+}
+
+func TestScheduler_RecordsFailures(t *testing.T) {
+	tests := []struct {
+		name          string
+		fail          func() error
+		wantLastError string
+	}{
+		{
+			name:          "error",
+			fail:          func() error { return errors.New("database unavailable") },
+			wantLastError: "database unavailable",
+		},
+		{
+			name:          "panic",
+			fail:          func() error { panic("nil map") },
+			wantLastError: "job panicked: nil map",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			s := NewScheduler(WithClock(clock), WithJitter(0))
+			var calls int
+			s.Register("flaky", time.Minute, func(context.Context) error {
+				calls++
+				if calls == 1 {
+					return tt.fail()
+				}
+				return nil
+			})
+			startScheduler(t, s)
+
+			status := waitForStatus(t, s, func(s Status) bool { return s.Runs == 1 })
+			assert.Equal(t, 1, status.Failures)
+			assert.Equal(t, tt.wantLastError, status.LastError)
+
+			// The job keeps running after a failure, and a success clears the error.
+			require.Eventually(t, func() bool { return clock.waiting() == 1 }, time.Second, time.Millisecond)
+			clock.Advance(time.Minute)
+			status = waitForStatus(t, s, func(s Status) bool { return s.Runs == 2 })
+			assert.Equal(t, 1, status.Failures)
+			assert.Empty(t, status.LastError)
+		})
+	}
+}
+
+func TestScheduler_Jitter(t *testing.T) {
+	clock := newFakeClock()
+	s := NewScheduler(WithClock(clock), WithJitter(0.2))
+	s.random = func() float64 { return 0.5 }
+	s.Register("cleanup", 10*time.Second, func(context.Context) error { return nil })
+	startScheduler(t, s)
+
+	// The first run waits up to 20% of the interval, here 1s.
+	require.Eventually(t, func() bool { return clock.waiting() == 1 }, time.Second, time.Millisecond)
+	clock.Advance(999 * time.Millisecond)
+	assert.Zero(t, s.Statuses()[0].Runs)
+	clock.Advance(time.Millisecond)
+	waitForStatus(t, s, func(s Status) bool { return s.Runs == 1 })
+
+	// The following runs wait the interval plus the jitter, here 11s.
+	require.Eventually(t, func() bool { return clock.waiting() == 1 }, time.Second, time.Millisecond)
+	clock.Advance(10 * time.Second)
+	assert.Equal(t, 1, s.Statuses()[0].Runs)
+	clock.Advance(time.Second)
+	waitForStatus(t, s, func(s Status) bool { return s.Runs == 2 })
+}
+
+func TestScheduler_StopCancelsRunningJobs(t *testing.T) {
+	s := NewScheduler(WithClock(newFakeClock()), WithJitter(0))
+	started := make(chan struct{})
+	s.Register("long", time.Minute, func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	s.Start(context.Background())
+	<-started
+	assert.True(t, s.Statuses()[0].Running)
+
+	s.Stop()
+
+	status := s.Statuses()[0]
+	assert.False(t, status.Running)
+	assert.Equal(t, context.Canceled.Error(), status.LastError)
+}
+
+func TestScheduler_SkipsLockedRuns(t *testing.T) {
+	s := NewScheduler(WithClock(newFakeClock()), WithJitter(0))
+	locker := &fakeLocker{held: map[string]bool{"purge": true}}
+	ran := false
+	s.Register("purge", time.Minute, Singleton(locker, "purge", func(context.Context) error {
+		ran = true
+		return nil
+	}))
+	startScheduler(t, s)
+
+	status := waitForStatus(t, s, func(s Status) bool { return s.Skips == 1 })
+	assert.Zero(t, status.Runs)
+	assert.Empty(t, status.LastError)
+	assert.False(t, ran)
+}
+
+func TestScheduler_Register(t *testing.T) {
+	s := NewScheduler()
+	s.Register("cleanup", time.Minute, func(context.Context) error { return nil })
+
+	assert.Panics(t, func() { s.Register("cleanup", time.Hour, func(context.Context) error { return nil }) })
+	assert.Panics(t, func() { s.Register("never", 0, func(context.Context) error { return nil }) })
+	assert.Len(t, s.Statuses(), 1)
+}
@@ -0,0 +1,62 @@
+// Package logger provides structured logging for the application built on log/slog,
+// along with helpers for carrying request-scoped values such as the request ID
+// through a context.Context.
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+type contextKey struct{}
+
+var requestIDKey = contextKey{}
+
+// New creates a slog.Logger writing to w at the given level.
+// When json is true, records are written as JSON objects, which is what log
+// collectors in production expect; otherwise the human-readable text format is used.
+func New(w io.Writer, level slog.Leveler, json bool) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level}
+	if json {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+// ParseLevel converts a level name (debug, info, warn or error, case-insensitive)
+// into a slog.Level. It returns an error for any other value.
+func ParseLevel(level string) (slog.Level, error) {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("invalid log level %q", level)
+	}
+}
+
+// LevelName returns the name of level as accepted by ParseLevel, such as
+// "debug" for slog.LevelDebug.
+func LevelName(level slog.Level) string {
+	return strings.ToLower(level.String())
+}
+
+// WithRequestID returns a copy of ctx carrying the given request ID.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestIDFromContext returns the request ID stored in ctx by WithRequestID,
+// or an empty string if there is none.
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
@@ -0,0 +1,80 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("json format", func(t *testing.T) {
+		var buf bytes.Buffer
+		log := New(&buf, slog.LevelInfo, true)
+
+		log.Debug("hidden")
+		log.Info("shown", "key", "value")
+
+		var record map[string]interface{}
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+		assert.Equal(t, "shown", record["msg"])
+		assert.Equal(t, "value", record["key"])
+	})
+
+	t.Run("text format", func(t *testing.T) {
+		var buf bytes.Buffer
+		log := New(&buf, slog.LevelWarn, false)
+
+		log.Info("hidden")
+		log.Warn("shown")
+
+		assert.NotContains(t, buf.String(), "hidden")
+		assert.Contains(t, buf.String(), "msg=shown")
+	})
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		name    string
+		level   string
+		want    slog.Level
+		wantErr bool
+	}{
+		{name: "debug", level: "debug", want: slog.LevelDebug},
+		{name: "info", level: "info", want: slog.LevelInfo},
+		{name: "warn", level: "WARN", want: slog.LevelWarn},
+		{name: "error", level: "error", want: slog.LevelError},
+		{name: "invalid", level: "verbose", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLevel(tt.level)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLevelName(t *testing.T) {
+	for _, name := range []string{"debug", "info", "warn", "error"} {
+		level, err := ParseLevel(name)
+		require.NoError(t, err)
+		assert.Equal(t, name, LevelName(level))
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	assert.Empty(t, RequestIDFromContext(context.Background()))
+
+	ctx := WithRequestID(context.Background(), "req-123")
+	assert.Equal(t, "req-123", RequestIDFromContext(ctx))
+}
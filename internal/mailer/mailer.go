@@ -0,0 +1,45 @@
+// Package mailer sends transactional email such as address verification and
+// password reset messages.
+package mailer
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Mailer delivers a single email message.
+type Mailer interface {
+	// Send delivers a message with both an HTML and a plain text body to a single recipient.
+	Send(ctx context.Context, to, subject, htmlBody, textBody string) error
+}
+
+// LogMailer is a Mailer that only logs the messages it is given. It is used
+// when no SMTP server is configured, so development environments can see what
+// would have been sent.
+type LogMailer struct {
+	logger *slog.Logger
+}
+
+// NewLogMailer creates a LogMailer that writes to logger.
+func NewLogMailer(logger *slog.Logger) *LogMailer {
+	return &LogMailer{logger: logger}
+}
+
+// Send logs the recipient, subject and text body. It never fails.
+func (m *LogMailer) Send(ctx context.Context, to, subject, _, textBody string) error {
+	m.logger.InfoContext(ctx, "email not sent, SMTP is not configured",
+		"to", to,
+		"subject", subject,
+		"body", textBody,
+	)
+	return nil
+}
+
+// New returns an SMTPMailer for config, or a LogMailer writing to logger when
+// no SMTP host is configured.
+func New(config SMTPConfig, logger *slog.Logger) Mailer {
+	if config.Host == "" {
+		return NewLogMailer(logger)
+	}
+	return NewSMTPMailer(config)
+}
@@ -0,0 +1,28 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	assert.IsType(t, &LogMailer{}, New(SMTPConfig{}, logger))
+	assert.IsType(t, &SMTPMailer{}, New(SMTPConfig{Host: "smtp.example.com", Port: "587"}, logger))
+}
+
+func TestLogMailer_Send(t *testing.T) {
+	var buf bytes.Buffer
+	m := NewLogMailer(slog.New(slog.NewTextHandler(&buf, nil)))
+
+	err := m.Send(context.Background(), "user@example.com", "Hello", "<p>Hi</p>", "Hi")
+
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), "user@example.com")
+	assert.Contains(t, buf.String(), "Hello")
+}
@@ -0,0 +1,60 @@
+package mailer
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Category is the kind of an email, named after the notification preference
+// that decides whether a user receives it.
+type Category string
+
+// Categories of email.
+const (
+	// CategorySecurity is for emails about the security of an account. They
+	// are always sent.
+	CategorySecurity Category = "security_alerts"
+	// CategoryProductUpdates is for announcements of new features and changes.
+	CategoryProductUpdates Category = "product_updates"
+	// CategoryNewsletter is for the periodic newsletter.
+	CategoryNewsletter Category = "newsletter"
+)
+
+// PreferenceChecker looks up the notification preferences of recipients.
+type PreferenceChecker interface {
+	// Allows reports whether the owner of the address to agreed to receive
+	// emails of category.
+	Allows(ctx context.Context, to string, category Category) (bool, error)
+}
+
+// PreferenceMailer sends emails other than security alerts only to the
+// recipients whose notification preferences allow them. Every email that is
+// not about the security of an account must be sent through it.
+type PreferenceMailer struct {
+	mailer      Mailer
+	preferences PreferenceChecker
+}
+
+// NewPreferenceMailer creates a PreferenceMailer that sends, or queues, the
+// emails allowed by preferences with m.
+func NewPreferenceMailer(m Mailer, preferences PreferenceChecker) *PreferenceMailer {
+	return &PreferenceMailer{mailer: m, preferences: preferences}
+}
+
+// Send passes a message of category to the underlying Mailer unless the
+// recipient turned that category off, in which case the message is dropped
+// and nil is returned. Security alerts are sent without looking the
+// recipient's preferences up.
+func (m *PreferenceMailer) Send(ctx context.Context, category Category, to string, msg *Message) error {
+	if category != CategorySecurity {
+		allowed, err := m.preferences.Allows(ctx, to, category)
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			slog.DebugContext(ctx, "email not sent, recipient opted out", "category", category)
+			return nil
+		}
+	}
+	return m.mailer.Send(ctx, to, msg.Subject, msg.HTML, msg.Text)
+}
@@ -0,0 +1,68 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingMailer is a Mailer that records the recipients it was given.
+type recordingMailer struct {
+	sent []string
+}
+
+func (m *recordingMailer) Send(_ context.Context, to, _, _, _ string) error {
+	m.sent = append(m.sent, to)
+	return nil
+}
+
+// staticPreferences is a PreferenceChecker allowing the categories in allowed.
+type staticPreferences struct {
+	allowed map[Category]bool
+	err     error
+	calls   int
+}
+
+func (p *staticPreferences) Allows(_ context.Context, _ string, category Category) (bool, error) {
+	p.calls++
+	if p.err != nil {
+		return false, p.err
+	}
+	return p.allowed[category], nil
+}
+
+func TestPreferenceMailer_Send(t *testing.T) {
+	msg := &Message{Subject: "Hello", HTML: "<p>Hi</p>", Text: "Hi"}
+	lookupErr := errors.New("connection reset")
+
+	tests := []struct {
+		name        string
+		category    Category
+		allowed     map[Category]bool
+		err         error
+		wantSent    bool
+		wantLookups int
+		wantErr     error
+	}{
+		{name: "security alerts skip the lookup", category: CategorySecurity, wantSent: true},
+		{name: "newsletter allowed", category: CategoryNewsletter, allowed: map[Category]bool{CategoryNewsletter: true}, wantSent: true, wantLookups: 1},
+		{name: "newsletter turned off", category: CategoryNewsletter, allowed: map[Category]bool{CategoryProductUpdates: true}, wantLookups: 1},
+		{name: "product updates turned off", category: CategoryProductUpdates, wantLookups: 1},
+		{name: "lookup fails", category: CategoryProductUpdates, err: lookupErr, wantLookups: 1, wantErr: lookupErr},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sender := &recordingMailer{}
+			preferences := &staticPreferences{allowed: tt.allowed, err: tt.err}
+
+			err := NewPreferenceMailer(sender, preferences).Send(context.Background(), tt.category, "user@example.com", msg)
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.wantSent, len(sender.sent) == 1)
+			assert.Equal(t, tt.wantLookups, preferences.calls)
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package mailer
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// ErrUnknownTemplate is returned by Preview for a name no template has.
+var ErrUnknownTemplate = errors.New("unknown email template")
+
+// previewToken stands in for the token of the links in previewed emails.
+const previewToken = "preview-token"
+
+// PreviewData is who a previewed email is addressed to, either a real user or
+// sample data.
+type PreviewData struct {
+	Name  string
+	Email string
+}
+
+// SamplePreviewData is used to preview an email when no user is given.
+var SamplePreviewData = PreviewData{Name: "Jane Doe", Email: "jane@example.com"}
+
+// previews renders every email template with placeholder values for what the
+// recipient does not provide, keyed by the name of its template files.
+var previews = map[string]func(t *Templates, data PreviewData) (*Message, error){
+	"verification": func(t *Templates, data PreviewData) (*Message, error) {
+		return t.Verification(data.Name, previewToken)
+	},
+	"password_reset": func(t *Templates, data PreviewData) (*Message, error) {
+		return t.PasswordReset(data.Name, previewToken)
+	},
+	"email_change": func(t *Templates, data PreviewData) (*Message, error) {
+		return t.EmailChange(data.Name, previewToken)
+	},
+	"invite": func(t *Templates, data PreviewData) (*Message, error) {
+		return t.Invite(data.Name, previewToken)
+	},
+	"new_device": func(t *Templates, data PreviewData) (*Message, error) {
+		login := LoginDetails{
+			Time:      time.Date(2024, time.January, 2, 15, 4, 5, 0, time.UTC),
+			Device:    "Firefox on Linux",
+			IPAddress: "203.0.113.0",
+		}
+		return t.NewDeviceLogin(data.Name, login, previewToken)
+	},
+	"recovery_requested": func(t *Templates, data PreviewData) (*Message, error) {
+		return t.RecoveryRequested("Admin", data.Email, "00000000-0000-0000-0000-000000000000")
+	},
+	"account_recovery": func(t *Templates, data PreviewData) (*Message, error) {
+		return t.AccountRecovery(data.Name, previewToken)
+	},
+}
+
+// Preview renders the email template called name for data, with placeholder
+// tokens in its links. It returns ErrUnknownTemplate for any other name.
+func (t *Templates) Preview(name string, data PreviewData) (*Message, error) {
+	render, ok := previews[name]
+	if !ok {
+		return nil, ErrUnknownTemplate
+	}
+	return render(t, data)
+}
+
+// PreviewNames returns the names of the templates Preview renders, sorted.
+func PreviewNames() []string {
+	names := make([]string, 0, len(previews))
+	for name := range previews {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,58 @@
+package mailer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTemplates_PreviewEveryTemplate renders every embedded template, so a
+// template without a preview or using a field it is not given fails here.
+func TestTemplates_PreviewEveryTemplate(t *testing.T) {
+	var names []string
+	for _, tmpl := range htmlTemplates.Templates() {
+		if name, ok := strings.CutSuffix(tmpl.Name(), ".html"); ok {
+			names = append(names, name)
+		}
+	}
+	for _, tmpl := range textTemplates.Templates() {
+		name, ok := strings.CutSuffix(tmpl.Name(), ".txt")
+		require.True(t, ok)
+		require.NotNil(t, htmlTemplates.Lookup(name+".html"), "%s has no HTML part", name)
+	}
+	assert.ElementsMatch(t, names, PreviewNames())
+
+	templates := NewTemplates("https://app.example.com")
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			msg, err := templates.Preview(name, SamplePreviewData)
+
+			require.NoError(t, err)
+			assert.NotEmpty(t, msg.Subject)
+			assert.Contains(t, msg.HTML, "https://app.example.com/")
+			assert.Contains(t, msg.Text, "https://app.example.com/")
+			assert.NotContains(t, msg.HTML, "{{")
+			assert.NotContains(t, msg.Text, "{{")
+		})
+	}
+}
+
+func TestTemplates_PreviewUsesData(t *testing.T) {
+	templates := NewTemplates("https://app.example.com")
+
+	msg, err := templates.Preview("verification", PreviewData{Name: "John Smith", Email: "john@example.com"})
+	require.NoError(t, err)
+	assert.Contains(t, msg.HTML, "Hi John Smith,")
+
+	msg, err = templates.Preview("recovery_requested", PreviewData{Name: "John Smith", Email: "john@example.com"})
+	require.NoError(t, err)
+	assert.Contains(t, msg.Text, "john@example.com")
+}
+
+func TestTemplates_PreviewUnknown(t *testing.T) {
+	_, err := NewTemplates("https://app.example.com").Preview("nope", SamplePreviewData)
+
+	assert.ErrorIs(t, err, ErrUnknownTemplate)
+}
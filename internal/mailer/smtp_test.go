@@ -0,0 +1,69 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"net/smtp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSMTPMailer_Send(t *testing.T) {
+	var gotAddr, gotFrom string
+	var gotTo []string
+	var gotMsg []byte
+	var gotAuth smtp.Auth
+
+	m := NewSMTPMailer(SMTPConfig{Host: "smtp.example.com", Port: "587", User: "mailer", Password: "secret", From: "auth@example.com"})
+	m.sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		gotAddr, gotAuth, gotFrom, gotTo, gotMsg = addr, a, from, to, msg
+		return nil
+	}
+
+	err := m.Send(context.Background(), "user@example.com", "Verify your email address", "<p>Hi</p>", "Hi")
+
+	require.NoError(t, err)
+	assert.Equal(t, "smtp.example.com:587", gotAddr)
+	assert.NotNil(t, gotAuth)
+	assert.Equal(t, "auth@example.com", gotFrom)
+	assert.Equal(t, []string{"user@example.com"}, gotTo)
+
+	msg := string(gotMsg)
+	assert.Contains(t, msg, "To: user@example.com\r\n")
+	assert.Contains(t, msg, "Subject: Verify your email address\r\n")
+	assert.Contains(t, msg, "Content-Type: multipart/alternative")
+	assert.Contains(t, msg, "Content-Type: text/plain; charset=utf-8")
+	assert.Contains(t, msg, "Content-Type: text/html; charset=utf-8")
+	assert.Contains(t, msg, "<p>Hi</p>")
+}
+
+func TestSMTPMailer_SendWithoutAuth(t *testing.T) {
+	var gotAuth smtp.Auth = smtp.PlainAuth("", "x", "y", "z")
+	m := NewSMTPMailer(SMTPConfig{Host: "localhost", Port: "25", From: "auth@example.com"})
+	m.sendMail = func(_ string, a smtp.Auth, _ string, _ []string, _ []byte) error {
+		gotAuth = a
+		return nil
+	}
+
+	require.NoError(t, m.Send(context.Background(), "user@example.com", "Hello", "<p>Hi</p>", "Hi"))
+	assert.Nil(t, gotAuth)
+}
+
+func TestSMTPMailer_SendErrors(t *testing.T) {
+	m := NewSMTPMailer(SMTPConfig{Host: "smtp.example.com", Port: "587", From: "auth@example.com"})
+	m.sendMail = func(string, smtp.Auth, string, []string, []byte) error {
+		return errors.New("connection refused")
+	}
+
+	err := m.Send(context.Background(), "user@example.com", "Hello", "", "")
+	assert.ErrorContains(t, err, "connection refused")
+
+	err = m.Send(context.Background(), "user@example.com\r\nBcc: victim@example.com", "Hello", "", "")
+	assert.ErrorContains(t, err, "invalid header value")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, m.Send(ctx, "user@example.com", "Hello", "", ""), context.Canceled)
+}
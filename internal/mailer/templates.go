@@ -0,0 +1,137 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	"net/url"
+	texttemplate "text/template"
+	"time"
+)
+
+//go:embed templates/*
+var templateFS embed.FS
+
+var (
+	htmlTemplates = htmltemplate.Must(htmltemplate.ParseFS(templateFS, "templates/*.html"))
+	textTemplates = texttemplate.Must(texttemplate.ParseFS(templateFS, "templates/*.txt"))
+)
+
+// Message is a rendered email ready to be passed to a Mailer.
+type Message struct {
+	Subject string
+	HTML    string
+	Text    string
+}
+
+// templateData is what every email template is rendered with. Login is only
+// set for emails about a login, and Account for emails about another
+// person's account.
+type templateData struct {
+	Name    string
+	Link    string
+	Login   *LoginDetails
+	Account string
+}
+
+// LoginDetails describes a login in the emails about it.
+type LoginDetails struct {
+	Time      time.Time
+	Device    string
+	IPAddress string
+}
+
+// Templates renders the application's emails. Links in the emails point at
+// paths under a base URL, normally the public address of the frontend.
+type Templates struct {
+	baseURL string
+}
+
+// NewTemplates creates Templates whose links start with baseURL.
+func NewTemplates(baseURL string) *Templates {
+	return &Templates{baseURL: baseURL}
+}
+
+// Verification renders the email asking name to confirm their address with token.
+func (t *Templates) Verification(name, token string) (*Message, error) {
+	return t.render("verification", "Verify your email address", "/verify-email", token, templateData{Name: name})
+}
+
+// PasswordReset renders the email letting name reset their password with token.
+func (t *Templates) PasswordReset(name, token string) (*Message, error) {
+	return t.render("password_reset", "Reset your password", "/reset-password", token, templateData{Name: name})
+}
+
+// EmailChange renders the email sent to a new address, asking name to confirm
+// the change of their account's email address with token.
+func (t *Templates) EmailChange(name, token string) (*Message, error) {
+	return t.render("email_change", "Confirm your new email address", "/confirm-email-change", token, templateData{Name: name})
+}
+
+// Invite renders the email telling name that an account was created for
+// them, with a link to choose its password with token.
+func (t *Templates) Invite(name, token string) (*Message, error) {
+	return t.render("invite", "You have been invited", "/accept-invite", token, templateData{Name: name})
+}
+
+// NewDeviceLogin renders the email telling name about a login from a new
+// device, with a link to report the login with token if it was not them.
+func (t *Templates) NewDeviceLogin(name string, login LoginDetails, token string) (*Message, error) {
+	return t.render("new_device", "New login to your account", "/report-login", token, templateData{Name: name, Login: &login})
+}
+
+// RecoveryRequested renders the email telling the admin name that someone
+// asked to recover the account of email, with a link to review request id.
+func (t *Templates) RecoveryRequested(name, email, id string) (*Message, error) {
+	link, err := url.JoinPath(t.baseURL, "/admin/recovery-requests", id)
+	if err != nil {
+		return nil, err
+	}
+	return t.renderLink("recovery_requested", "Account recovery requested", templateData{Name: name, Link: link, Account: email})
+}
+
+// AccountRecovery renders the email sent to the new address of an approved
+// recovery request, letting name take back their account with token.
+func (t *Templates) AccountRecovery(name, token string) (*Message, error) {
+	return t.render("account_recovery", "Recover your account", "/recover-account", token, templateData{Name: name})
+}
+
+// UnsubscribeLink returns the link that turns the newsletter off for the user
+// of the unsubscribe token, to be included in every newsletter email.
+func (t *Templates) UnsubscribeLink(token string) (string, error) {
+	return t.link("/unsubscribe", token)
+}
+
+// render renders the email templates called name with data, whose Link is
+// set to the URL of path carrying token.
+func (t *Templates) render(name, subject, path, token string, data templateData) (*Message, error) {
+	link, err := t.link(path, token)
+	if err != nil {
+		return nil, err
+	}
+	data.Link = link
+	return t.renderLink(name, subject, data)
+}
+
+// renderLink renders the email templates called name with data, whose Link
+// is already set.
+func (t *Templates) renderLink(name, subject string, data templateData) (*Message, error) {
+	var html, text bytes.Buffer
+	if err := htmlTemplates.ExecuteTemplate(&html, name+".html", data); err != nil {
+		return nil, err
+	}
+	if err := textTemplates.ExecuteTemplate(&text, name+".txt", data); err != nil {
+		return nil, err
+	}
+
+	return &Message{Subject: subject, HTML: html.String(), Text: text.String()}, nil
+}
+
+// link returns the URL of path carrying token.
+func (t *Templates) link(path, token string) (string, error) {
+	link, err := url.JoinPath(t.baseURL, path)
+	if err != nil {
+		return "", err
+	}
+	return link + "?" + url.Values{"token": {token}}.Encode(), nil
+}
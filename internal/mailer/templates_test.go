@@ -0,0 +1,110 @@
+package mailer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplates_Verification(t *testing.T) {
+	msg, err := NewTemplates("https://app.example.com").Verification("Jane Doe", "abc123")
+
+	require.NoError(t, err)
+	assert.Equal(t, "Verify your email address", msg.Subject)
+	assert.Contains(t, msg.HTML, "Hi Jane Doe,")
+	assert.Contains(t, msg.HTML, `href="https://app.example.com/verify-email?token=abc123"`)
+	assert.Contains(t, msg.Text, "Hi Jane Doe,")
+	assert.Contains(t, msg.Text, "https://app.example.com/verify-email?token=abc123")
+	assert.NotContains(t, msg.HTML, "{{")
+	assert.NotContains(t, msg.Text, "{{")
+}
+
+func TestTemplates_PasswordReset(t *testing.T) {
+	msg, err := NewTemplates("https://app.example.com/").PasswordReset("Jane Doe", "abc123")
+
+	require.NoError(t, err)
+	assert.Equal(t, "Reset your password", msg.Subject)
+	assert.Contains(t, msg.HTML, `href="https://app.example.com/reset-password?token=abc123"`)
+	assert.Contains(t, msg.Text, "https://app.example.com/reset-password?token=abc123")
+}
+
+func TestTemplates_EmailChange(t *testing.T) {
+	msg, err := NewTemplates("https://app.example.com").EmailChange("Jane Doe", "abc123")
+
+	require.NoError(t, err)
+	assert.Equal(t, "Confirm your new email address", msg.Subject)
+	assert.Contains(t, msg.HTML, "Hi Jane Doe,")
+	assert.Contains(t, msg.HTML, `href="https://app.example.com/confirm-email-change?token=abc123"`)
+	assert.Contains(t, msg.Text, "https://app.example.com/confirm-email-change?token=abc123")
+}
+
+func TestTemplates_Invite(t *testing.T) {
+	msg, err := NewTemplates("https://app.example.com").Invite("Jane Doe", "abc123")
+
+	require.NoError(t, err)
+	assert.Equal(t, "You have been invited", msg.Subject)
+	assert.Contains(t, msg.HTML, "Hi Jane Doe,")
+	assert.Contains(t, msg.HTML, `href="https://app.example.com/accept-invite?token=abc123"`)
+	assert.Contains(t, msg.Text, "https://app.example.com/accept-invite?token=abc123")
+}
+
+func TestTemplates_RecoveryRequested(t *testing.T) {
+	msg, err := NewTemplates("https://app.example.com").RecoveryRequested("Admin", "jane@example.com", "req-1")
+
+	require.NoError(t, err)
+	assert.Equal(t, "Account recovery requested", msg.Subject)
+	assert.Contains(t, msg.HTML, "Hi Admin,")
+	assert.Contains(t, msg.HTML, "jane@example.com")
+	assert.Contains(t, msg.HTML, `href="https://app.example.com/admin/recovery-requests/req-1"`)
+	assert.Contains(t, msg.Text, "https://app.example.com/admin/recovery-requests/req-1")
+	assert.NotContains(t, msg.Text, "token=")
+}
+
+func TestTemplates_AccountRecovery(t *testing.T) {
+	msg, err := NewTemplates("https://app.example.com").AccountRecovery("Jane Doe", "abc123")
+
+	require.NoError(t, err)
+	assert.Equal(t, "Recover your account", msg.Subject)
+	assert.Contains(t, msg.HTML, "Hi Jane Doe,")
+	assert.Contains(t, msg.HTML, `href="https://app.example.com/recover-account?token=abc123"`)
+	assert.Contains(t, msg.Text, "https://app.example.com/recover-account?token=abc123")
+}
+
+func TestTemplates_EscapesUserInput(t *testing.T) {
+	msg, err := NewTemplates("https://app.example.com").Verification(`<script>alert("x")</script>`, "a&b=c")
+
+	require.NoError(t, err)
+	assert.NotContains(t, msg.HTML, "<script>")
+	assert.Contains(t, msg.HTML, "&lt;script&gt;")
+	assert.Contains(t, msg.HTML, "token=a%26b%3Dc")
+}
+
+func TestTemplates_NewDeviceLogin(t *testing.T) {
+	login := LoginDetails{
+		Time:      time.Date(2024, 1, 2, 15, 4, 0, 0, time.FixedZone("ICT", 7*60*60)),
+		Device:    "Firefox on Linux",
+		IPAddress: "203.0.113.0",
+	}
+	msg, err := NewTemplates("https://app.example.com").NewDeviceLogin("Jane Doe", login, "abc123")
+
+	require.NoError(t, err)
+	assert.Equal(t, "New login to your account", msg.Subject)
+	for _, body := range []string{msg.HTML, msg.Text} {
+		assert.Contains(t, body, "Hi Jane Doe,")
+		assert.Contains(t, body, "2 Jan 2024 08:04 UTC")
+		assert.Contains(t, body, "Firefox on Linux")
+		assert.Contains(t, body, "203.0.113.0")
+		assert.Contains(t, body, "https://app.example.com/report-login?token=abc123")
+	}
+	assert.NotContains(t, msg.HTML, "{{")
+	assert.NotContains(t, msg.Text, "{{")
+}
+
+func TestTemplates_UnsubscribeLink(t *testing.T) {
+	link, err := NewTemplates("https://app.example.com/").UnsubscribeLink("a+b/c")
+
+	require.NoError(t, err)
+	assert.Equal(t, "https://app.example.com/unsubscribe?token=a%2Bb%2Fc", link)
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessLog_RedactsQueryToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var logs bytes.Buffer
+	router := gin.New()
+	router.Use(AccessLog(&logs))
+	router.GET("/events", AuthMiddleware(testSecret, &tokenVersions{}, nil, WithQueryToken()), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	token, _ := tokens.Token()
+	req := httptest.NewRequest(http.MethodGet, "/events?since=5&access_token="+token, nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, logs.String(), `"/events?since=5"`)
+	assert.NotContains(t, logs.String(), token)
+	assert.NotContains(t, logs.String(), "access_token")
+}
+
+func TestRedactAccessToken(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "/events", want: "/events"},
+		{path: "/events?since=5", want: "/events?since=5"},
+		{path: "/events?access_token=secret", want: "/events"},
+		{path: "/events?a=1&access_token=secret&b=2", want: "/events?a=1&b=2"},
+		{path: "/events?access%5Ftoken=secret", want: "/events"},
+		{path: "/events?access_token", want: "/events"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			assert.Equal(t, tt.want, redactAccessToken(tt.path))
+		})
+	}
+}
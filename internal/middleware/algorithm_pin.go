@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// defaultAlgorithm is the algorithm access tokens are verified with when no
+// AlgorithmPin is given, the one AuthService signs them with by default.
+const defaultAlgorithm = "HS256"
+
+// AlgorithmPin is the one signing algorithm AuthMiddleware accepts access
+// tokens signed with. A token whose header names any other, such as "none" or
+// an RSA algorithm meant to be verified with the HMAC secret as its public
+// key, is rejected before its signature is checked, and counted in the
+// auth_token_alg_mismatch_total metric, since such tokens are not issued by
+// this service and point to forgery attempts. A nil AlgorithmPin pins HS256
+// without counting. It is safe for concurrent use.
+type AlgorithmPin struct {
+	alg        string
+	mismatches prometheus.Counter
+}
+
+// NewAlgorithmPin creates an AlgorithmPin accepting only tokens signed with
+// alg, such as "HS256", and registers its metric with registerer. It returns
+// an error if the metric cannot be registered.
+func NewAlgorithmPin(alg string, registerer prometheus.Registerer) (*AlgorithmPin, error) {
+	p := &AlgorithmPin{
+		alg: alg,
+		mismatches: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "auth_token_alg_mismatch_total",
+			Help: "Access tokens rejected for naming a signing algorithm other than the pinned one.",
+		}),
+	}
+	if err := registerer.Register(p.mismatches); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// parserOption returns the option restricting a jwt.Parser to the pinned
+// algorithm.
+func (p *AlgorithmPin) parserOption() jwt.ParserOption {
+	return jwt.WithValidMethods([]string{p.algorithm()})
+}
+
+// algorithm returns the pinned algorithm.
+func (p *AlgorithmPin) algorithm() string {
+	if p == nil {
+		return defaultAlgorithm
+	}
+	return p.alg
+}
+
+// countMismatch counts token, which failed to parse, if its header names an
+// algorithm other than the pinned one. A token whose header could not be
+// decoded is not counted, being malformed rather than forged.
+func (p *AlgorithmPin) countMismatch(token *jwt.Token) {
+	if p == nil || token == nil {
+		return
+	}
+	if alg, ok := token.Header["alg"].(string); ok && alg != p.alg {
+		p.mismatches.Inc()
+	}
+}
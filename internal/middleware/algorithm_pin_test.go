@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	promtest "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withHeader replaces the header of token with header, leaving its payload
+// and signature as they were.
+func withHeader(token, header string) string {
+	parts := strings.SplitN(token, ".", 2)
+	return base64.RawURLEncoding.EncodeToString([]byte(header)) + "." + parts[1]
+}
+
+func TestAuthMiddleware_AlgorithmPin(t *testing.T) {
+	signed := func(opts ...testutil.TokenOption) string {
+		token, _ := tokens.Token(opts...)
+		return token
+	}
+	valid := signed()
+
+	tests := []struct {
+		name           string
+		alg            string
+		token          string
+		wantCode       int
+		wantMismatches float64
+	}{
+		{name: "pinned algorithm", alg: "HS256", token: valid, wantCode: http.StatusOK},
+		{name: "unsigned token", alg: "HS256", token: signed(testutil.WithAlg(jwt.SigningMethodNone)), wantCode: http.StatusUnauthorized, wantMismatches: 1},
+		{name: "RS256 header over an HMAC signature with the secret", alg: "HS256", token: signed(testutil.WithHeaderAlg("RS256")), wantCode: http.StatusUnauthorized, wantMismatches: 1},
+		{name: "tampered header alg", alg: "HS256", token: withHeader(valid, `{"alg":"HS512","typ":"JWT"}`), wantCode: http.StatusUnauthorized, wantMismatches: 1},
+		{name: "another HMAC algorithm with the secret", alg: "HS256", token: signed(testutil.WithAlg(jwt.SigningMethodHS512)), wantCode: http.StatusUnauthorized, wantMismatches: 1},
+		{name: "unknown algorithm", alg: "HS256", token: withHeader(valid, `{"alg":"XS256","typ":"JWT"}`), wantCode: http.StatusUnauthorized, wantMismatches: 1},
+		{name: "wrong signature", alg: "HS256", token: signed(testutil.WithSigningKey("other-secret")), wantCode: http.StatusUnauthorized},
+		{name: "malformed header", alg: "HS256", token: "not-base64!." + strings.SplitN(valid, ".", 2)[1], wantCode: http.StatusUnauthorized},
+		{name: "HS256 when HS512 is pinned", alg: "HS512", token: valid, wantCode: http.StatusUnauthorized, wantMismatches: 1},
+		{name: "HS512 when pinned", alg: "HS512", token: signed(testutil.WithAlg(jwt.SigningMethodHS512)), wantCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pin, err := NewAlgorithmPin(tt.alg, prometheus.NewRegistry())
+			require.NoError(t, err)
+			router := gin.New()
+			router.GET("/test", AuthMiddleware(testSecret, &tokenVersions{}, nil, WithAlgorithmPin(pin)), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			req.Header.Set("Authorization", bearerPrefix+tt.token)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantCode == http.StatusUnauthorized {
+				assert.Contains(t, w.Body.String(), "TOKEN_INVALID")
+			}
+			assert.Equal(t, tt.wantMismatches, promtest.ToFloat64(pin.mismatches))
+		})
+	}
+}
+
+func TestAlgorithmPin_Nil(t *testing.T) {
+	router := gin.New()
+	router.GET("/test", AuthMiddleware(testSecret, &tokenVersions{}, nil, WithAlgorithmPin(nil)), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	for alg, wantCode := range map[jwt.SigningMethod]int{
+		jwt.SigningMethodHS256: http.StatusOK,
+		jwt.SigningMethodHS512: http.StatusUnauthorized,
+		jwt.SigningMethodNone:  http.StatusUnauthorized,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.Header.Set("Authorization", tokens.Bearer(testutil.WithAlg(alg)))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, wantCode, w.Code, "a nil AlgorithmPin accepts only HS256, got %s", alg.Alg())
+	}
+}
@@ -0,0 +1,184 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/authctx"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// AuditRecorder records audit entries. Implementations may block the caller
+// briefly, but must not fail the request.
+type AuditRecorder interface {
+	Record(entry *model.AuditEntry)
+}
+
+// maxAuditedBodySize is the size of the largest request body AuditRequests
+// records. Larger bodies are still passed on to the handler in full.
+const maxAuditedBodySize = 64 << 10
+
+// BodyRedactor masks sensitive fields in a JSON request body. It is satisfied
+// by *redact.Redactor.
+type BodyRedactor interface {
+	JSON(document []byte) ([]byte, error)
+}
+
+// auditSubjectKey is the gin context key of the user a request is about, set
+// by SetAuditSubject.
+const auditSubjectKey = "audit_subject"
+
+// SetAuditSubject records that the request of c is about the user with the
+// given ID, whom AuditRequests then records as the subject of its entry. It
+// lets handlers acting on another account, such as an admin revoking its
+// tokens, have the entry show up among that account's activity.
+func SetAuditSubject(c *gin.Context, id uuid.UUID) {
+	c.Set(auditSubjectKey, id)
+}
+
+// AuditImpersonation returns a middleware that records an audit entry for
+// every request made with an impersonation token, as recognized by
+// AuthMiddleware, once the request has been handled. Requests rejected by
+// later middleware, such as ForbidImpersonation, are recorded too. Requests
+// made by users acting as themselves are not recorded.
+//
+// It must be registered after AuthMiddleware.
+func AuditImpersonation(recorder AuditRecorder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, _ := authctx.User(c)
+		if !user.Impersonated() {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		c.Next()
+
+		entry := &model.AuditEntry{
+			ActorID:   user.ActorID,
+			SubjectID: &user.UserID,
+			Method:    c.Request.Method,
+			Path:      c.Request.URL.Path,
+			Status:    c.Writer.Status(),
+			IPAddress: c.ClientIP(),
+			LatencyMS: time.Since(start).Milliseconds(),
+			CreatedAt: start,
+		}
+		recorder.Record(entry)
+	}
+}
+
+// AuditOwnRequests returns a middleware that records an audit entry, without
+// the request body, for every request a user makes as themselves once it has
+// been handled. It is meant for the few routes that change how a user's
+// account is secured, such as the password change, whose entries then show up
+// among the user's security events. Requests made with an impersonation token
+// are left to AuditImpersonation, and those without an authenticated user are
+// not recorded.
+//
+// It must be registered after AuthMiddleware.
+func AuditOwnRequests(recorder AuditRecorder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := authctx.User(c)
+		if !ok || user.Impersonated() {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		c.Next()
+
+		recorder.Record(&model.AuditEntry{
+			ActorID:   user.UserID,
+			Method:    c.Request.Method,
+			Path:      c.Request.URL.Path,
+			Status:    c.Writer.Status(),
+			IPAddress: c.ClientIP(),
+			LatencyMS: time.Since(start).Milliseconds(),
+			CreatedAt: start,
+		})
+	}
+}
+
+// AuditRequests returns a middleware that records an audit entry for every
+// request, once it has been handled, including requests rejected by later
+// middleware. The actor is the authenticated user, or the impersonating user
+// for requests made with an impersonation token, in which case the
+// impersonated user is the subject. Otherwise the subject is the user the
+// handler named with SetAuditSubject, if any. Requests without an
+// authenticated user are not recorded.
+//
+// JSON request bodies of up to 64 KiB are recorded with their sensitive
+// fields masked by redactor. Other bodies, and bodies that are not valid
+// JSON and so cannot be redacted reliably, are not recorded.
+//
+// It must be registered after AuthMiddleware, and it replaces
+// AuditImpersonation on the routes it is applied to.
+func AuditRequests(recorder AuditRecorder, redactor BodyRedactor) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := authctx.User(c)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		body := auditedBody(c, redactor)
+		start := time.Now()
+		c.Next()
+
+		entry := &model.AuditEntry{
+			ActorID:     user.UserID,
+			Method:      c.Request.Method,
+			Path:        c.Request.URL.Path,
+			Status:      c.Writer.Status(),
+			IPAddress:   c.ClientIP(),
+			LatencyMS:   time.Since(start).Milliseconds(),
+			RequestBody: body,
+			CreatedAt:   start,
+		}
+		if user.Impersonated() {
+			entry.ActorID = user.ActorID
+			entry.SubjectID = &user.UserID
+		} else if subject, ok := c.Get(auditSubjectKey); ok {
+			subjectID := subject.(uuid.UUID)
+			entry.SubjectID = &subjectID
+		}
+		recorder.Record(entry)
+	}
+}
+
+// auditedBody returns the redacted JSON body of the request, or nil if it is
+// not to be recorded. The body is left in place for the handler to read.
+func auditedBody(c *gin.Context, redactor BodyRedactor) *string {
+	if c.Request.Body == nil || !isJSON(c.ContentType()) {
+		return nil
+	}
+
+	head, err := io.ReadAll(io.LimitReader(c.Request.Body, maxAuditedBodySize+1))
+	c.Request.Body = readCloser{io.MultiReader(bytes.NewReader(head), c.Request.Body), c.Request.Body}
+	if err != nil || len(head) == 0 || len(head) > maxAuditedBodySize {
+		return nil
+	}
+
+	redacted, err := redactor.JSON(head)
+	if err != nil {
+		return nil
+	}
+	body := string(redacted)
+	return &body
+}
+
+// isJSON reports whether contentType, without parameters, is a JSON media type.
+func isJSON(contentType string) bool {
+	return contentType == "application/json" || strings.HasSuffix(contentType, "+json")
+}
+
+// readCloser reads from Reader and closes Closer.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,277 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/authctx"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/redact"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeAuditRecorder struct {
+	entries []*model.AuditEntry
+}
+
+func (r *fakeAuditRecorder) Record(entry *model.AuditEntry) {
+	r.entries = append(r.entries, entry)
+}
+
+func TestAuditImpersonation(t *testing.T) {
+	adminID := uuid.New()
+	userID := uuid.New()
+
+	tests := []struct {
+		name       string
+		act        map[string]string
+		method     string
+		path       string
+		wantCode   int
+		wantAudits int
+	}{
+		{
+			name:       "impersonated read",
+			act:        map[string]string{"sub": adminID.String()},
+			method:     http.MethodGet,
+			path:       "/api/auth/profile",
+			wantCode:   http.StatusOK,
+			wantAudits: 1,
+		},
+		{
+			name:       "impersonated destructive request is rejected and still audited",
+			act:        map[string]string{"sub": adminID.String()},
+			method:     http.MethodDelete,
+			path:       "/api/auth/profile",
+			wantCode:   http.StatusForbidden,
+			wantAudits: 1,
+		},
+		{
+			name:     "user acting as themselves",
+			method:   http.MethodGet,
+			path:     "/api/auth/profile",
+			wantCode: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			recorder := &fakeAuditRecorder{}
+			router := gin.New()
+			router.Use(AuthMiddleware(testSecret, &tokenVersions{}, nil), AuditImpersonation(recorder))
+			ok := func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{}) }
+			router.GET("/api/auth/profile", ok)
+			router.DELETE("/api/auth/profile", ForbidImpersonation(), ok)
+
+			opts := []testutil.TokenOption{testutil.WithClaim("user_id", userID.String())}
+			if tt.act != nil {
+				opts = append(opts, testutil.WithClaim("act", tt.act))
+			}
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("Authorization", tokens.Bearer(opts...))
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			require.Len(t, recorder.entries, tt.wantAudits)
+			if tt.wantAudits == 0 {
+				return
+			}
+			entry := recorder.entries[0]
+			assert.Equal(t, adminID, entry.ActorID)
+			require.NotNil(t, entry.SubjectID)
+			assert.Equal(t, userID, *entry.SubjectID)
+			assert.Equal(t, tt.method, entry.Method)
+			assert.Equal(t, tt.path, entry.Path)
+			assert.Equal(t, tt.wantCode, entry.Status)
+			assert.Equal(t, "192.0.2.1", entry.IPAddress)
+			assert.WithinDuration(t, time.Now(), entry.CreatedAt, time.Second)
+		})
+	}
+}
+
+func TestAuditOwnRequests(t *testing.T) {
+	adminID := uuid.New()
+	userID := uuid.New()
+
+	tests := []struct {
+		name      string
+		setupAuth func(*gin.Context)
+		wantAudit bool
+	}{
+		{
+			name:      "user acting as themselves",
+			setupAuth: func(c *gin.Context) { authctx.SetUser(c, authctx.Identity{UserID: userID}) },
+			wantAudit: true,
+		},
+		{
+			name: "impersonated request",
+			setupAuth: func(c *gin.Context) {
+				authctx.SetUser(c, authctx.Identity{UserID: userID, ActorID: adminID})
+			},
+		},
+		{
+			name:      "unauthenticated request",
+			setupAuth: func(c *gin.Context) {},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			recorder := &fakeAuditRecorder{}
+			router := gin.New()
+			router.Use(tt.setupAuth, AuditOwnRequests(recorder))
+			router.PUT("/api/auth/password", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			req := httptest.NewRequest(http.MethodPut, "/api/auth/password", strings.NewReader(`{"new_password": "hunter2"}`))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(httptest.NewRecorder(), req)
+
+			if !tt.wantAudit {
+				assert.Empty(t, recorder.entries)
+				return
+			}
+			require.Len(t, recorder.entries, 1)
+			entry := recorder.entries[0]
+			assert.Equal(t, userID, entry.ActorID)
+			assert.Nil(t, entry.SubjectID)
+			assert.Equal(t, http.MethodPut, entry.Method)
+			assert.Equal(t, "/api/auth/password", entry.Path)
+			assert.Equal(t, http.StatusOK, entry.Status)
+			assert.Equal(t, "192.0.2.1", entry.IPAddress)
+			assert.Nil(t, entry.RequestBody, "the body is never recorded")
+		})
+	}
+}
+
+func TestAuditRequests(t *testing.T) {
+	adminID := uuid.New()
+	userID := uuid.New()
+	redactor, err := redact.New(redact.DefaultFields)
+	require.NoError(t, err)
+	largeBody := `{"note": "` + strings.Repeat("x", maxAuditedBodySize) + `"}`
+
+	tests := []struct {
+		name        string
+		setupAuth   func(*gin.Context)
+		contentType string
+		body        string
+		wantAudit   bool
+		wantActor   uuid.UUID
+		wantSubject *uuid.UUID
+		wantBody    string
+	}{
+		{
+			name:        "admin request with redacted body",
+			setupAuth:   func(c *gin.Context) { authctx.SetUser(c, authctx.Identity{UserID: adminID}) },
+			contentType: "application/json",
+			body:        `{"email": "a@example.com", "password": "hunter2", "nested": [{"client_secret": "s"}]}`,
+			wantAudit:   true,
+			wantActor:   adminID,
+			wantBody:    `{"email": "a@example.com", "password": "[REDACTED]", "nested": [{"client_secret": "[REDACTED]"}]}`,
+		},
+		{
+			name: "impersonated request",
+			setupAuth: func(c *gin.Context) {
+				authctx.SetUser(c, authctx.Identity{UserID: userID, ActorID: adminID})
+			},
+			wantAudit:   true,
+			wantActor:   adminID,
+			wantSubject: &userID,
+		},
+		{
+			name: "request about another user",
+			setupAuth: func(c *gin.Context) {
+				authctx.SetUser(c, authctx.Identity{UserID: adminID})
+				SetAuditSubject(c, userID)
+			},
+			wantAudit:   true,
+			wantActor:   adminID,
+			wantSubject: &userID,
+		},
+		{
+			name:        "body that is not JSON",
+			setupAuth:   func(c *gin.Context) { authctx.SetUser(c, authctx.Identity{UserID: adminID}) },
+			contentType: "text/plain",
+			body:        "password=hunter2",
+			wantAudit:   true,
+			wantActor:   adminID,
+		},
+		{
+			name:        "malformed JSON body",
+			setupAuth:   func(c *gin.Context) { authctx.SetUser(c, authctx.Identity{UserID: adminID}) },
+			contentType: "application/json",
+			body:        `{"password": "hunter2"`,
+			wantAudit:   true,
+			wantActor:   adminID,
+		},
+		{
+			name:        "body too large to record",
+			setupAuth:   func(c *gin.Context) { authctx.SetUser(c, authctx.Identity{UserID: adminID}) },
+			contentType: "application/json",
+			body:        largeBody,
+			wantAudit:   true,
+			wantActor:   adminID,
+		},
+		{
+			name:      "unauthenticated request",
+			setupAuth: func(c *gin.Context) {},
+			body:      `{"password": "hunter2"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			recorder := &fakeAuditRecorder{}
+			router := gin.New()
+			router.Use(tt.setupAuth, AuditRequests(recorder, redactor))
+			var received string
+			router.POST("/api/admin/users", func(c *gin.Context) {
+				body, err := io.ReadAll(c.Request.Body)
+				require.NoError(t, err)
+				received = string(body)
+				c.Status(http.StatusCreated)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/api/admin/users", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.body, received, "the handler reads the whole body")
+			if !tt.wantAudit {
+				assert.Empty(t, recorder.entries)
+				return
+			}
+			require.Len(t, recorder.entries, 1)
+			entry := recorder.entries[0]
+			assert.Equal(t, tt.wantActor, entry.ActorID)
+			assert.Equal(t, tt.wantSubject, entry.SubjectID)
+			assert.Equal(t, http.MethodPost, entry.Method)
+			assert.Equal(t, "/api/admin/users", entry.Path)
+			assert.Equal(t, http.StatusCreated, entry.Status)
+			assert.Equal(t, "192.0.2.1", entry.IPAddress)
+			if tt.wantBody == "" {
+				assert.Nil(t, entry.RequestBody)
+			} else {
+				require.NotNil(t, entry.RequestBody)
+				assert.JSONEq(t, tt.wantBody, *entry.RequestBody)
+			}
+		})
+	}
+}
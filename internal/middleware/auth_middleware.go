@@ -6,21 +6,95 @@
 package middleware
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/PakornBank/learn-go/internal/apierror"
+	"github.com/PakornBank/learn-go/internal/authctx"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
 )
 
+// TokenVersionChecker checks the "ver" claim of an access token against the
+// current token version of its user, which changes when the user signs out
+// everywhere.
+type TokenVersionChecker interface {
+	// CheckTokenVersion returns service.ErrTokenRevoked if version is not the
+	// current token version of the user with userID.
+	CheckTokenVersion(ctx context.Context, userID string, version int) error
+}
+
+// accessTokenParam is the query parameter routes using WithQueryToken accept
+// the access token in.
+const accessTokenParam = "access_token"
+
+// AuthOption configures AuthMiddleware and OptionalAuth for one route.
+type AuthOption func(*authOptions)
+
+type authOptions struct {
+	queryToken bool
+	clock      service.Clock
+	clockSkew  time.Duration
+	algorithm  *AlgorithmPin
+}
+
+// WithQueryToken lets the route take the access token from the
+// "access_token" query parameter when the request has no "Authorization"
+// header, for browser EventSource and WebSocket clients that cannot set
+// headers. The header always wins when both are present. The parameter is
+// removed from the request URL either way, so later handlers never see it;
+// AccessLog keeps it out of the access log.
+func WithQueryToken() AuthOption {
+	return func(o *authOptions) {
+		o.queryToken = true
+	}
+}
+
+// WithClock makes the route validate the expiry of tokens at the time told by
+// clock rather than the system clock.
+func WithClock(clock service.Clock) AuthOption {
+	return func(o *authOptions) {
+		o.clock = clock
+	}
+}
+
+// WithClockSkew makes the route accept tokens up to skew after they expire
+// and before their "nbf" or "iat" claims, for issuers and clients whose
+// clocks drift apart. Without it, no skew is tolerated.
+func WithClockSkew(skew time.Duration) AuthOption {
+	return func(o *authOptions) {
+		o.clockSkew = skew
+	}
+}
+
+// WithAlgorithmPin makes the route accept only tokens signed with the
+// algorithm of pin, counting those naming another. Without it, only HS256 is
+// accepted.
+func WithAlgorithmPin(pin *AlgorithmPin) AuthOption {
+	return func(o *authOptions) {
+		o.algorithm = pin
+	}
+}
+
 // AuthMiddleware is a middleware function for the Gin framework that handles
 // JWT authentication. It expects a JWT token in the "Authorization" header
 // in the format "Bearer <token>". The token is validated using the provided
-// jwtSecret. If the token is valid, the user ID and email from the token
-// claims are set in the Gin context.
+// jwtSecret. If the token is valid, the identity it proves, an
+// authctx.Identity, is set on the request, where handlers read it with
+// authctx.User.
 //
 // Parameters:
 //   - jwtSecret: The secret key used to validate the JWT token.
+//   - versions: Checks that the token was issued after its user last signed out everywhere.
+//   - compat: Decides whether tokens lacking the role, ver or scopes claims are accepted, or nil to accept them all.
+//   - opts: Route options, such as WithQueryToken or WithClockSkew.
 //
 // Returns:
 //   - gin.HandlerFunc: A Gin middleware handler function.
@@ -28,55 +102,345 @@ import (
 // The middleware performs the following checks:
 //  1. Ensures the "Authorization" header is present.
 //  2. Ensures the "Authorization" header is in the format "Bearer <token>".
-//  3. Parses and validates the JWT token using the provided secret.
-//  4. Extracts the "user_id" and "email" claims from the token and sets them
-//     in the Gin context.
+//  3. Parses and validates the JWT token using the provided secret, rejecting
+//     any algorithm but the pinned one, and checks its "exp", "nbf" and "iat"
+//     claims with service.CheckTokenTimes.
+//  4. Extracts the "user_id" and "email" claims from the token, rejecting
+//     a "user_id" that is not a UUID.
+//  5. Checks with compat that a token lacking the "role", "ver" or "scopes"
+//     claims was issued before they were required.
+//  6. Extracts the "role" claim, defaulting to model.RoleUser for tokens
+//     issued before roles existed.
+//  7. Extracts the "scopes" claim, defaulting to every scope of the role for
+//     tokens issued before scopes existed.
+//  8. Extracts the optional "auth_time" claim, when the user last entered
+//     their password, for RequireRecentAuth.
+//  9. Checks the "ver" claim, treated as 0 for tokens issued before token
+//     versions existed, against the user's current token version.
+//  10. Extracts the optional "act" claim of impersonation tokens, naming the
+//     admin acting as the user.
+//  11. Sets the identity made of these claims on the request.
 //
 // If any of these checks fail, the middleware responds with a 401 Unauthorized
-// status and an appropriate error message, and aborts the request.
-func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
+// status and an appropriate error message, and aborts the request. An expired
+// token gets the "TOKEN_EXPIRED" code, telling the client to refresh it, and
+// any other bad token the "TOKEN_INVALID" code. As RFC 6750 requires, 401
+// responses carry a WWW-Authenticate header, with error="invalid_token" when a
+// token was presented. If the token version cannot be looked up, it responds
+// with a 504 Gateway Timeout or a 500 Internal Server Error status instead.
+func AuthMiddleware(jwtSecret string, versions TokenVersionChecker, compat *ClaimsCompat, opts ...AuthOption) gin.HandlerFunc {
+	options := newAuthOptions(opts)
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
+		authHeader := options.authorization(c)
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "authorization header required"})
+			c.Header("WWW-Authenticate", "Bearer")
+			apierror.Respond(c, http.StatusUnauthorized, "authorization header required")
 			c.Abort()
 			return
 		}
 
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid authorization header format"})
-			c.Abort()
+		if authenticate(c, authHeader, jwtSecret, versions, compat, options) {
+			c.Next()
+		}
+	}
+}
+
+// OptionalAuth is a middleware function for the Gin framework for routes that
+// serve anonymous users but personalize their response for signed in ones.
+// Without an "Authorization" header the request continues anonymously, with
+// no identity for authctx.User to find. A presented token is validated like
+// AuthMiddleware does and, if valid, its identity is set on the request.
+// A token that cannot be used is rejected in the same way as by
+// AuthMiddleware, rather than ignored, so clients notice broken tokens.
+func OptionalAuth(jwtSecret string, versions TokenVersionChecker, compat *ClaimsCompat, opts ...AuthOption) gin.HandlerFunc {
+	options := newAuthOptions(opts)
+	return func(c *gin.Context) {
+		authHeader := options.authorization(c)
+		if authHeader == "" {
+			c.Next()
 			return
 		}
 
-		token, err := jwt.Parse(parts[1], func(token *jwt.Token) (interface{}, error) {
-			return []byte(jwtSecret), nil
-		})
+		if authenticate(c, authHeader, jwtSecret, versions, compat, options) {
+			c.Next()
+		}
+	}
+}
 
-		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
-			c.Abort()
-			return
+func newAuthOptions(opts []AuthOption) authOptions {
+	options := authOptions{clock: service.SystemClock{}}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// authorization returns the "Authorization" header of the request in c or,
+// if it has none and the route accepts query tokens, a bearer authorization
+// built from the "access_token" query parameter, which is removed from the
+// request URL.
+func (o authOptions) authorization(c *gin.Context) string {
+	header := c.GetHeader("Authorization")
+	if !o.queryToken {
+		return header
+	}
+
+	query := c.Request.URL.Query()
+	if !query.Has(accessTokenParam) {
+		return header
+	}
+	token := query.Get(accessTokenParam)
+	query.Del(accessTokenParam)
+	c.Request.URL.RawQuery = query.Encode()
+
+	if header == "" && token != "" {
+		return "Bearer " + token
+	}
+	return header
+}
+
+// authenticate validates the bearer token in authHeader as described on
+// AuthMiddleware and sets its identity on the request. If the token cannot
+// be used, it writes the error response, aborts the request and returns false.
+func authenticate(c *gin.Context, authHeader, jwtSecret string, versions TokenVersionChecker, compat *ClaimsCompat, options authOptions) bool {
+	tokenString, ok := bearerToken(authHeader)
+	if !ok {
+		respondInvalidToken(c, "TOKEN_INVALID", "invalid authorization header format")
+		return false
+	}
+
+	parser := jwt.NewParser(jwt.WithoutClaimsValidation(), options.algorithm.parserOption())
+	token, err := parser.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return []byte(jwtSecret), nil
+	})
+	if err != nil || !token.Valid {
+		options.algorithm.countMismatch(token)
+		respondInvalidToken(c, "TOKEN_INVALID", "invalid token")
+		return false
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		respondInvalidToken(c, "TOKEN_INVALID", "invalid token claims")
+		return false
+	}
+
+	err = service.CheckTokenTimes(claims, options.clock.Now(), options.clockSkew)
+	if errors.Is(err, jwt.ErrTokenExpired) {
+		respondInvalidToken(c, "TOKEN_EXPIRED", "token has expired")
+		return false
+	}
+	if err != nil {
+		respondInvalidToken(c, "TOKEN_INVALID", "invalid token")
+		return false
+	}
+
+	rawUserID, _ := claims["user_id"].(string)
+	email, _ := claims["email"].(string)
+	userID, err := uuid.Parse(rawUserID)
+	if err != nil || email == "" {
+		respondInvalidToken(c, "TOKEN_INVALID", "invalid token claims")
+		return false
+	}
+
+	if !compat.allow(claims) {
+		respondInvalidToken(c, "TOKEN_INVALID", "token lacks required claims")
+		return false
+	}
+
+	role, _ := claims["role"].(string)
+	if role == "" {
+		role = model.RoleUser
+	}
+
+	scopes, ok := scopesClaim(claims, role)
+	if !ok {
+		respondInvalidToken(c, "TOKEN_INVALID", "invalid token claims")
+		return false
+	}
+
+	actorID, ok := actorClaim(claims)
+	if !ok {
+		respondInvalidToken(c, "TOKEN_INVALID", "invalid token claims")
+		return false
+	}
+
+	version, _ := claims["ver"].(float64)
+	if err := versions.CheckTokenVersion(c.Request.Context(), userID.String(), int(version)); err != nil {
+		switch {
+		case errors.Is(err, service.ErrTokenRevoked):
+			respondInvalidToken(c, "TOKEN_INVALID", service.ErrTokenRevoked.Error())
+			return false
+		case errors.Is(err, repository.ErrTimeout):
+			apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+		default:
+			c.Error(err)
+			apierror.Respond(c, http.StatusInternalServerError, "failed to validate token")
 		}
+		c.Abort()
+		return false
+	}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
+	identity := authctx.Identity{
+		UserID:       userID,
+		Email:        email,
+		Role:         role,
+		Scopes:       scopes,
+		ActorID:      actorID,
+		TokenVersion: int(version),
+	}
+	if exp, ok := claims["exp"].(float64); ok {
+		identity.ExpiresAt = time.Unix(int64(exp), 0)
+	}
+	if authTime, ok := claims["auth_time"].(float64); ok {
+		identity.AuthTime = time.Unix(int64(authTime), 0)
+	}
+	authctx.SetUser(c, identity)
+	return true
+}
+
+// bearerToken returns the token of an "Authorization" header in the format
+// "Bearer <token>", reporting false if the header is in any other format.
+func bearerToken(authHeader string) (string, bool) {
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// respondInvalidToken writes a 401 Unauthorized response with code and
+// message for a presented token that cannot be used, and aborts the request.
+func respondInvalidToken(c *gin.Context, code, message string) {
+	c.Header("WWW-Authenticate", `Bearer error="invalid_token", error_description="`+message+`"`)
+	apierror.RespondCode(c, http.StatusUnauthorized, code, message)
+	c.Abort()
+}
+
+// scopesClaim returns the scopes listed in the token's "scopes" claim, or
+// every scope of role if the token has no such claim. It reports false if the
+// claim is present but not a list of strings.
+func scopesClaim(claims jwt.MapClaims, role string) ([]string, bool) {
+	raw, present := claims["scopes"]
+	if !present {
+		return service.ScopesForRole(role), true
+	}
+
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	scopes := make([]string, 0, len(list))
+	for _, item := range list {
+		scope, ok := item.(string)
 		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token claims"})
+			return nil, false
+		}
+		scopes = append(scopes, scope)
+	}
+	return scopes, true
+}
+
+// actorClaim returns the ID of the admin named in the token's "act" claim, or
+// uuid.Nil if the token has no such claim. It reports false if the claim is
+// present but does not name an actor by ID.
+func actorClaim(claims jwt.MapClaims) (uuid.UUID, bool) {
+	raw, present := claims["act"]
+	if !present {
+		return uuid.Nil, true
+	}
+
+	act, ok := raw.(map[string]interface{})
+	if !ok {
+		return uuid.Nil, false
+	}
+	sub, _ := act["sub"].(string)
+	actorID, err := uuid.Parse(sub)
+	if err != nil {
+		return uuid.Nil, false
+	}
+	return actorID, true
+}
+
+// RequireRole is a middleware function for the Gin framework that only lets
+// the request through when the authenticated user's role, as set by
+// AuthMiddleware, is one of the given roles. Otherwise it responds with a
+// 403 Forbidden status and the "FORBIDDEN" code, and aborts the request.
+//
+// It must be registered after AuthMiddleware.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, _ := authctx.User(c)
+		for _, allowed := range roles {
+			if user.Role == allowed {
+				c.Next()
+				return
+			}
+		}
+
+		apierror.RespondCode(c, http.StatusForbidden, "FORBIDDEN", "forbidden")
+		c.Abort()
+	}
+}
+
+// RequireScope is a middleware function for the Gin framework that only lets
+// the request through when the access token, as read by AuthMiddleware,
+// carries the given scope. Otherwise it responds with a 403 Forbidden status
+// and the "INSUFFICIENT_SCOPE" code, and aborts the request. The response
+// names the missing scope in a WWW-Authenticate header, as in RFC 6750.
+//
+// It must be registered after AuthMiddleware.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if user, _ := authctx.User(c); user.HasScope(scope) {
+			c.Next()
+			return
+		}
+
+		c.Header("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+scope+`"`)
+		apierror.RespondCode(c, http.StatusForbidden, "INSUFFICIENT_SCOPE", "token lacks the "+scope+" scope")
+		c.Abort()
+	}
+}
+
+// RequireRecentAuth is a middleware function for the Gin framework that guards
+// sensitive operations. It only lets the request through when the user entered
+// their password, by logging in or re-authenticating, at most maxAge ago.
+// Otherwise it responds with a 403 Forbidden status and the "REAUTH_REQUIRED"
+// code, and aborts the request; the client should re-authenticate and retry
+// with the elevated token. Of opts, only WithClock applies: it tells the time
+// the age is measured at.
+//
+// It must be registered after AuthMiddleware.
+func RequireRecentAuth(maxAge time.Duration, opts ...AuthOption) gin.HandlerFunc {
+	clock := newAuthOptions(opts).clock
+	return func(c *gin.Context) {
+		user, _ := authctx.User(c)
+		if user.AuthTime.IsZero() || clock.Now().Sub(user.AuthTime) > maxAge {
+			apierror.RespondCode(c, http.StatusForbidden, "REAUTH_REQUIRED", "recent authentication required")
 			c.Abort()
 			return
 		}
 
-		userID, hasUserID := claims["user_id"]
-		email, hasEmail := claims["email"]
-		if !hasUserID || userID == "" || !hasEmail || email == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token claims"})
+		c.Next()
+	}
+}
+
+// ForbidImpersonation is a middleware function for the Gin framework that
+// keeps impersonation tokens, as recognized by AuthMiddleware, from admin and
+// destructive routes. It responds with a 403 Forbidden status and the
+// "IMPERSONATION_FORBIDDEN" code, and aborts the request, when the token was
+// issued to an admin acting as the user.
+//
+// It must be registered after AuthMiddleware.
+func ForbidImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if user, _ := authctx.User(c); user.Impersonated() {
+			apierror.RespondCode(c, http.StatusForbidden, "IMPERSONATION_FORBIDDEN", "not allowed while impersonating a user")
 			c.Abort()
 			return
 		}
 
-		c.Set("user_id", userID)
-		c.Set("email", email)
 		c.Next()
 	}
 }
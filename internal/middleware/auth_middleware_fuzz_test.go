@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/PakornBank/learn-go/internal/authctx"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+// fuzzRouter is a router behind AuthMiddleware whose only route responds with
+// 200. It records a violation if a request gets through without both
+// "user_id" and "email" set to non-empty strings, or is rejected with any of
+// them set.
+type fuzzRouter struct {
+	*gin.Engine
+	violation string
+}
+
+func newFuzzRouter() *fuzzRouter {
+	gin.SetMode(gin.TestMode)
+	r := &fuzzRouter{Engine: gin.New()}
+	r.Use(func(c *gin.Context) {
+		c.Next()
+		if user, ok := authctx.User(c); c.IsAborted() && ok {
+			r.violation = fmt.Sprintf("rejected request has the identity %+v set", user)
+		}
+	}, AuthMiddleware(testSecret, &tokenVersions{}, nil, WithClock(testutil.NewFakeClock(testNow))))
+	r.GET("/test", func(c *gin.Context) {
+		if user, _ := authctx.User(c); user.UserID == uuid.Nil || user.Email == "" {
+			r.violation = fmt.Sprintf("accepted request has user_id %q and email %q", user.UserID, user.Email)
+		}
+		c.Status(http.StatusOK)
+	})
+	return r
+}
+
+// serve sends a request with authHeader and fails t unless it was accepted or
+// rejected as unauthorized without a violation.
+func (r *fuzzRouter) serve(t *testing.T, authHeader string) int {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header["Authorization"] = []string{authHeader}
+	w := httptest.NewRecorder()
+	r.violation = ""
+
+	r.ServeHTTP(w, req)
+
+	if r.violation != "" {
+		t.Fatalf("authorization %q: %s", authHeader, r.violation)
+	}
+	if w.Code != http.StatusOK && w.Code != http.StatusUnauthorized {
+		t.Fatalf("authorization %q got status %d", authHeader, w.Code)
+	}
+	return w.Code
+}
+
+func FuzzAuthHeaderParsing(f *testing.F) {
+	tokens := tokens.At(testutil.NewFakeClock(testNow))
+	valid, _ := tokens.Token()
+	for _, seed := range []string{
+		bearerPrefix + valid,
+		tokens.Bearer(testutil.WithExpiry(0)),
+		tokens.Bearer(testutil.WithSigningKey("another-secret")),
+		tokens.Bearer(testutil.WithAlg(jwt.SigningMethodNone)),
+		tokens.Bearer(testutil.WithClaim("user_id", nil)),
+		bearerPrefix + "invalid-token",
+		valid,
+		"",
+		"Bearer",
+		"Bearer ",
+		"bearer " + valid,
+		"Bearer  " + valid,
+		bearerPrefix + valid + " extra",
+	} {
+		f.Add(seed)
+	}
+	router := newFuzzRouter()
+
+	f.Fuzz(func(t *testing.T, authHeader string) {
+		token, ok := bearerToken(authHeader)
+		if ok != (authHeader == bearerPrefix+token && !strings.Contains(token, " ")) {
+			t.Fatalf("bearerToken(%q) = %q, %v", authHeader, token, ok)
+		}
+
+		code := router.serve(t, authHeader)
+		if !ok && code != http.StatusUnauthorized {
+			t.Fatalf("malformed authorization %q got status %d", authHeader, code)
+		}
+	})
+}
+
+// signPayload returns a token with payload as its claims, signed with
+// testSecret whether or not payload is a valid set of claims.
+func signPayload(t testing.TB, payload []byte) string {
+	t.Helper()
+
+	encoding := base64.RawURLEncoding
+	signingString := encoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." + encoding.EncodeToString(payload)
+	signature, err := jwt.SigningMethodHS256.Sign(signingString, []byte(testSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signingString + "." + signature
+}
+
+func FuzzTokenValidation(f *testing.F) {
+	tokens := tokens.At(testutil.NewFakeClock(testNow))
+	for _, opts := range [][]testutil.TokenOption{
+		nil,
+		{testutil.WithClaim("scopes", []string{"profile:read"})},
+		{testutil.WithClaim("scopes", "profile:read")},
+		{testutil.WithExpiry(0)},
+		{testutil.WithClaim("user_id", nil)},
+		{testutil.WithClaim("email", nil)},
+		{testutil.WithClaim("email", 1)},
+		{testutil.WithClaim("ver", 2)},
+		{testutil.WithClaim("act", map[string]string{"sub": "8a6e0804-2bd0-4672-b79d-d97027f9071a"})},
+		{testutil.WithClaim("act", "admin")},
+	} {
+		_, claims := tokens.Token(opts...)
+		payload, err := json.Marshal(claims)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(payload)
+	}
+	f.Add([]byte(`not json`))
+	f.Add([]byte(`[]`))
+	router := newFuzzRouter()
+
+	f.Fuzz(func(t *testing.T, payload []byte) {
+		router.serve(t, bearerPrefix+signPayload(t, payload))
+	})
+}
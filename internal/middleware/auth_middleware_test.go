@@ -1,14 +1,21 @@
 package middleware
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/PakornBank/learn-go/internal/authctx"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/PakornBank/learn-go/internal/testutil"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -17,132 +24,202 @@ const (
 	bearerPrefix = "Bearer "
 )
 
-func setupTest() *gin.Engine {
+// tokenVersions is a TokenVersionChecker under which every user is at token
+// version current, or which fails with err if it is set. It records the
+// users it was asked about in checked.
+type tokenVersions struct {
+	current int
+	err     error
+	checked []string
+}
+
+func (v *tokenVersions) CheckTokenVersion(_ context.Context, userID string, version int) error {
+	v.checked = append(v.checked, userID)
+	if v.err != nil {
+		return v.err
+	}
+	if version != v.current {
+		return service.ErrTokenRevoked
+	}
+	return nil
+}
+
+func setupTest(opts ...AuthOption) *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
-	router.Use(AuthMiddleware(testSecret))
+	router.Use(AuthMiddleware(testSecret, &tokenVersions{}, nil, opts...))
 	router.GET("/test", func(c *gin.Context) {
+		user, _ := authctx.User(c)
 		c.JSON(http.StatusOK, gin.H{
-			"user_id": c.MustGet("user_id"),
-			"email":   c.MustGet("email"),
+			"user_id": user.UserID,
+			"email":   user.Email,
+			"role":    user.Role,
+			"scopes":  user.Scopes,
 		})
 	})
 	return router
 }
 
-func generateTestToken(userID string, email string, expiry time.Duration) string {
-	claims := jwt.MapClaims{
-		"user_id": userID,
-		"email":   email,
-		"exp":     time.Now().Add(expiry).Unix(),
-	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, _ := token.SignedString([]byte(testSecret))
-	return signedToken
-}
+var tokens = testutil.NewTokenFactory(testSecret)
 
-func TestAuthMiddleware(t *testing.T) {
-	const (
-		testID    = "test-user-id"
-		testEmail = "test@email.com"
-	)
+// testNow is the time the fake clocks of the tests start at.
+var testNow = time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
 
+func TestAuthMiddleware(t *testing.T) {
 	tests := []struct {
 		name               string
-		generateAuthHeader func() string
-		wantCode           int
-		errContains        string
+		generateAuthHeader func(tokens *testutil.TokenFactory) string
+		// advance is how far the clock moves after the token is minted.
+		advance     time.Duration
+		wantCode    int
+		wantScopes  []interface{}
+		errContains string
+		wantErrCode string
+		// wantAuthenticate is the expected WWW-Authenticate header.
+		wantAuthenticate string
 	}{
 		{
-			name: "valid token",
-			generateAuthHeader: func() string {
-				return bearerPrefix + generateTestToken(testID, testEmail, time.Hour)
+			name: "valid token without scopes claim gets the role's scopes",
+			generateAuthHeader: func(tokens *testutil.TokenFactory) string {
+				return tokens.Bearer()
 			},
-			wantCode: http.StatusOK,
+			wantCode:   http.StatusOK,
+			wantScopes: []interface{}{"profile:read", "profile:write"},
+		},
+		{
+			name: "valid token with scopes claim",
+			generateAuthHeader: func(tokens *testutil.TokenFactory) string {
+				return tokens.Bearer(testutil.WithClaim("scopes", []string{"profile:read"}))
+			},
+			wantCode:   http.StatusOK,
+			wantScopes: []interface{}{"profile:read"},
+		},
+		{
+			name: "malformed scopes claim",
+			generateAuthHeader: func(tokens *testutil.TokenFactory) string {
+				return tokens.Bearer(testutil.WithClaim("scopes", "profile:read"))
+			},
+			wantCode:         http.StatusUnauthorized,
+			errContains:      "invalid token claims",
+			wantErrCode:      "TOKEN_INVALID",
+			wantAuthenticate: `Bearer error="invalid_token", error_description="invalid token claims"`,
 		},
 		{
 			name: "expired token",
-			generateAuthHeader: func() string {
-				return bearerPrefix + generateTestToken(testID, testEmail, -time.Hour)
+			generateAuthHeader: func(tokens *testutil.TokenFactory) string {
+				return tokens.Bearer()
 			},
-			wantCode:    http.StatusUnauthorized,
-			errContains: "invalid token",
+			advance:          time.Hour + time.Second,
+			wantCode:         http.StatusUnauthorized,
+			errContains:      "token has expired",
+			wantErrCode:      "TOKEN_EXPIRED",
+			wantAuthenticate: `Bearer error="invalid_token", error_description="token has expired"`,
+		},
+		{
+			name: "token signed with another secret",
+			generateAuthHeader: func(tokens *testutil.TokenFactory) string {
+				return tokens.Bearer(testutil.WithSigningKey("another-secret"))
+			},
+			wantCode:         http.StatusUnauthorized,
+			errContains:      "invalid token",
+			wantErrCode:      "TOKEN_INVALID",
+			wantAuthenticate: `Bearer error="invalid_token", error_description="invalid token"`,
 		},
 		{
 			name: "invalid token",
-			generateAuthHeader: func() string {
+			generateAuthHeader: func(tokens *testutil.TokenFactory) string {
 				return bearerPrefix + "invalid-token"
 			},
-			wantCode:    http.StatusUnauthorized,
-			errContains: "invalid token",
+			wantCode:         http.StatusUnauthorized,
+			errContains:      "invalid token",
+			wantErrCode:      "TOKEN_INVALID",
+			wantAuthenticate: `Bearer error="invalid_token", error_description="invalid token"`,
 		},
 		{
 			name: "empty authorization header",
-			generateAuthHeader: func() string {
+			generateAuthHeader: func(tokens *testutil.TokenFactory) string {
 				return ""
 			},
-			wantCode:    http.StatusUnauthorized,
-			errContains: "authorization header required",
+			wantCode:         http.StatusUnauthorized,
+			errContains:      "authorization header required",
+			wantAuthenticate: "Bearer",
 		},
 		{
 			name: "missing Bearer prifix",
-			generateAuthHeader: func() string {
-				return generateTestToken(testID, testEmail, time.Hour)
+			generateAuthHeader: func(tokens *testutil.TokenFactory) string {
+				token, _ := tokens.Token()
+				return token
 			},
-			wantCode:    http.StatusUnauthorized,
-			errContains: "invalid authorization header format",
+			wantCode:         http.StatusUnauthorized,
+			errContains:      "invalid authorization header format",
+			wantErrCode:      "TOKEN_INVALID",
+			wantAuthenticate: `Bearer error="invalid_token", error_description="invalid authorization header format"`,
 		},
 		{
 			name: "wrong signing method",
-			generateAuthHeader: func() string {
-				token := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{
-					"user_id": testID,
-					"email":   testEmail,
-					"exp":     time.Now().Add(time.Hour).Unix(),
-				})
-				signedToken, _ := token.SignedString(jwt.UnsafeAllowNoneSignatureType)
-				return bearerPrefix + signedToken
+			generateAuthHeader: func(tokens *testutil.TokenFactory) string {
+				return tokens.Bearer(testutil.WithAlg(jwt.SigningMethodNone))
 			},
-			wantCode:    http.StatusUnauthorized,
-			errContains: "invalid token",
+			wantCode:         http.StatusUnauthorized,
+			errContains:      "invalid token",
+			wantErrCode:      "TOKEN_INVALID",
+			wantAuthenticate: `Bearer error="invalid_token", error_description="invalid token"`,
 		},
 		{
 			name: "invalid token claims",
-			generateAuthHeader: func() string {
-				claims := jwt.MapClaims{
-					"id": testID,
-				}
-				token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-				signedToken, _ := token.SignedString([]byte(testSecret))
-				return bearerPrefix + signedToken
+			generateAuthHeader: func(tokens *testutil.TokenFactory) string {
+				return tokens.Bearer(
+					testutil.WithClaim("id", testutil.TokenUserID),
+					testutil.WithClaim("user_id", nil),
+					testutil.WithClaim("email", nil),
+				)
 			},
-			wantCode:    http.StatusUnauthorized,
-			errContains: "invalid token claims",
+			wantCode:         http.StatusUnauthorized,
+			errContains:      "invalid token claims",
+			wantErrCode:      "TOKEN_INVALID",
+			wantAuthenticate: `Bearer error="invalid_token", error_description="invalid token claims"`,
 		},
 		{
 			name: "missing user_id claim",
-			generateAuthHeader: func() string {
-				return bearerPrefix + generateTestToken("", testEmail, time.Hour)
+			generateAuthHeader: func(tokens *testutil.TokenFactory) string {
+				return tokens.Bearer(testutil.WithClaim("user_id", nil))
 			},
-			wantCode:    http.StatusUnauthorized,
-			errContains: "invalid token claims",
+			wantCode:         http.StatusUnauthorized,
+			errContains:      "invalid token claims",
+			wantErrCode:      "TOKEN_INVALID",
+			wantAuthenticate: `Bearer error="invalid_token", error_description="invalid token claims"`,
+		},
+		{
+			name: "user_id claim that is not a UUID",
+			generateAuthHeader: func(tokens *testutil.TokenFactory) string {
+				return tokens.Bearer(testutil.WithClaim("user_id", "not-a-uuid"))
+			},
+			wantCode:         http.StatusUnauthorized,
+			errContains:      "invalid token claims",
+			wantErrCode:      "TOKEN_INVALID",
+			wantAuthenticate: `Bearer error="invalid_token", error_description="invalid token claims"`,
 		},
 		{
 			name: "missing email claim",
-			generateAuthHeader: func() string {
-				return bearerPrefix + generateTestToken(testID, "", time.Hour)
+			generateAuthHeader: func(tokens *testutil.TokenFactory) string {
+				return tokens.Bearer(testutil.WithClaim("email", nil))
 			},
-			wantCode:    http.StatusUnauthorized,
-			errContains: "invalid token claims",
+			wantCode:         http.StatusUnauthorized,
+			errContains:      "invalid token claims",
+			wantErrCode:      "TOKEN_INVALID",
+			wantAuthenticate: `Bearer error="invalid_token", error_description="invalid token claims"`,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			router := setupTest()
+			clock := testutil.NewFakeClock(testNow)
+			router := setupTest(WithClock(clock))
+			authHeader := tt.generateAuthHeader(tokens.At(clock))
+			clock.Advance(tt.advance)
 
 			req := httptest.NewRequest(http.MethodGet, "/test", nil)
-			if authHeader := tt.generateAuthHeader(); authHeader != "" {
+			if authHeader != "" {
 				req.Header.Set("Authorization", authHeader)
 			}
 			w := httptest.NewRecorder()
@@ -156,10 +233,597 @@ func TestAuthMiddleware(t *testing.T) {
 			assert.NoError(t, err)
 
 			if tt.wantCode == http.StatusOK {
-				assert.Equal(t, testID, res["user_id"])
-				assert.Equal(t, testEmail, res["email"])
+				assert.Equal(t, testutil.TokenUserID, res["user_id"])
+				assert.Equal(t, testutil.TokenEmail, res["email"])
+				assert.Equal(t, "user", res["role"])
+				assert.Equal(t, tt.wantScopes, res["scopes"])
 			} else {
 				assert.Contains(t, res["error"], tt.errContains)
+				assert.Equal(t, tt.wantAuthenticate, w.Header().Get("WWW-Authenticate"))
+			}
+			if tt.wantErrCode != "" {
+				assert.Equal(t, tt.wantErrCode, res["code"])
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware_ClockSkew(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        []testutil.TokenOption
+		skew        time.Duration
+		advance     time.Duration
+		wantCode    int
+		wantErrCode string
+	}{
+		{name: "expiring exactly now", advance: time.Hour, wantCode: http.StatusUnauthorized, wantErrCode: "TOKEN_EXPIRED"},
+		{name: "expiring exactly now within skew", skew: 30 * time.Second, advance: time.Hour, wantCode: http.StatusOK},
+		{name: "expired within skew", skew: 30 * time.Second, advance: time.Hour + 29*time.Second, wantCode: http.StatusOK},
+		{name: "expired beyond skew", skew: 30 * time.Second, advance: time.Hour + 30*time.Second, wantCode: http.StatusUnauthorized, wantErrCode: "TOKEN_EXPIRED"},
+		{name: "not before in 1s", opts: []testutil.TokenOption{testutil.WithNotBefore(time.Second)}, wantCode: http.StatusUnauthorized, wantErrCode: "TOKEN_INVALID"},
+		{name: "not before in 1s within skew", opts: []testutil.TokenOption{testutil.WithNotBefore(time.Second)}, skew: time.Second, wantCode: http.StatusOK},
+		{name: "issued by a clock 1s ahead", opts: []testutil.TokenOption{testutil.WithClaim("iat", testNow.Add(time.Second).Unix())}, wantCode: http.StatusUnauthorized, wantErrCode: "TOKEN_INVALID"},
+		{name: "issued by a clock 1s ahead within skew", opts: []testutil.TokenOption{testutil.WithClaim("iat", testNow.Add(time.Second).Unix())}, skew: time.Second, wantCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := testutil.NewFakeClock(testNow)
+			router := setupTest(WithClock(clock), WithClockSkew(tt.skew))
+			authHeader := tokens.At(clock).Bearer(tt.opts...)
+			clock.Advance(tt.advance)
+
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			req.Header.Set("Authorization", authHeader)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantErrCode != "" {
+				var res map[string]interface{}
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+				assert.Equal(t, tt.wantErrCode, res["code"])
+			}
+		})
+	}
+}
+
+func TestOptionalAuth(t *testing.T) {
+	tokens := tokens.At(testutil.NewFakeClock(testNow))
+
+	tests := []struct {
+		name       string
+		authHeader string
+		// now is how long after testNow the request is made.
+		now         time.Duration
+		wantCode    int
+		wantUserID  interface{}
+		wantErrCode string
+	}{
+		{
+			name:     "no header continues anonymously",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:       "valid header sets the user",
+			authHeader: tokens.Bearer(),
+			wantCode:   http.StatusOK,
+			wantUserID: testutil.TokenUserID,
+		},
+		{
+			name:        "expired header is rejected",
+			authHeader:  tokens.Bearer(),
+			now:         time.Hour + time.Second,
+			wantCode:    http.StatusUnauthorized,
+			wantErrCode: "TOKEN_EXPIRED",
+		},
+		{
+			name:        "garbage header is rejected",
+			authHeader:  bearerPrefix + "invalid-token",
+			wantCode:    http.StatusUnauthorized,
+			wantErrCode: "TOKEN_INVALID",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Use(OptionalAuth(testSecret, &tokenVersions{}, nil, WithClock(testutil.NewFakeClock(testNow.Add(tt.now)))))
+			router.GET("/test", func(c *gin.Context) {
+				var userID interface{}
+				if user, ok := authctx.User(c); ok {
+					userID = user.UserID.String()
+				}
+				c.JSON(http.StatusOK, gin.H{"user_id": userID})
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			if tt.authHeader != "" {
+				req.Header.Set("Authorization", tt.authHeader)
+			}
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			var res map[string]interface{}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+			if tt.wantCode == http.StatusOK {
+				assert.Equal(t, tt.wantUserID, res["user_id"])
+			} else {
+				assert.Equal(t, tt.wantErrCode, res["code"])
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware_QueryToken(t *testing.T) {
+	const (
+		headerUserID = "1d3b5f7a-9c2e-4b6d-8f0a-2c4e6a8b0d1f"
+		queryUserID  = "7e9a1c3b-5d2f-4a8c-9b6e-0f1d3b5a7c9e"
+	)
+	headerToken, _ := tokens.Token(testutil.WithClaim("user_id", headerUserID), testutil.WithClaim("email", "header@email.com"))
+	queryToken, _ := tokens.Token(testutil.WithClaim("user_id", queryUserID), testutil.WithClaim("email", "query@email.com"))
+
+	tests := []struct {
+		name       string
+		opts       []AuthOption
+		authHeader string
+		query      string
+		wantCode   int
+		wantUserID string
+	}{
+		{
+			name:       "query token when the route allows it",
+			opts:       []AuthOption{WithQueryToken()},
+			query:      "access_token=" + queryToken + "&since=5",
+			wantCode:   http.StatusOK,
+			wantUserID: queryUserID,
+		},
+		{
+			name:       "header wins over query token",
+			opts:       []AuthOption{WithQueryToken()},
+			authHeader: bearerPrefix + headerToken,
+			query:      "access_token=" + queryToken + "&since=5",
+			wantCode:   http.StatusOK,
+			wantUserID: headerUserID,
+		},
+		{
+			name:       "header wins over a broken query token",
+			opts:       []AuthOption{WithQueryToken()},
+			authHeader: bearerPrefix + headerToken,
+			query:      "access_token=garbage&since=5",
+			wantCode:   http.StatusOK,
+			wantUserID: headerUserID,
+		},
+		{
+			name:     "query token is ignored by default",
+			query:    "access_token=" + queryToken,
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "invalid query token",
+			opts:     []AuthOption{WithQueryToken()},
+			query:    "access_token=not-a-valid-token",
+			wantCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Use(AuthMiddleware(testSecret, &tokenVersions{}, nil, tt.opts...))
+			router.GET("/events", func(c *gin.Context) {
+				user, _ := authctx.User(c)
+				c.JSON(http.StatusOK, gin.H{"user_id": user.UserID.String(), "query": c.Request.URL.RawQuery})
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/events?"+tt.query, nil)
+			if tt.authHeader != "" {
+				req.Header.Set("Authorization", tt.authHeader)
+			}
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantCode == http.StatusOK {
+				assert.JSONEq(t, `{"user_id":"`+tt.wantUserID+`","query":"since=5"}`, w.Body.String())
+			} else {
+				assert.NotContains(t, w.Body.String(), "not-a-valid-token")
+				assert.NotContains(t, w.Body.String(), queryToken)
+			}
+		})
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	tests := []struct {
+		name     string
+		role     string
+		wantCode int
+	}{
+		{name: "allowed role", role: "admin", wantCode: http.StatusOK},
+		{name: "disallowed role", role: "user", wantCode: http.StatusForbidden},
+		{name: "missing role", role: "", wantCode: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Use(func(c *gin.Context) {
+				if tt.role != "" {
+					authctx.SetUser(c, authctx.Identity{UserID: uuid.New(), Role: tt.role})
+				}
+			})
+			router.Use(RequireRole("admin"))
+			router.GET("/test", func(c *gin.Context) {
+				c.JSON(http.StatusOK, gin.H{})
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantCode == http.StatusForbidden {
+				var res map[string]interface{}
+				err := json.Unmarshal(w.Body.Bytes(), &res)
+				assert.NoError(t, err)
+				assert.Equal(t, "forbidden", res["error"])
+				assert.Equal(t, "FORBIDDEN", res["code"])
+			}
+		})
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []testutil.TokenOption
+		wantCode int
+	}{
+		{
+			name:     "token with the scope",
+			opts:     []testutil.TokenOption{testutil.WithClaim("scopes", []string{"profile:read", "users:admin"})},
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "token without the scope",
+			opts: []testutil.TokenOption{
+				testutil.WithClaim("role", "admin"),
+				testutil.WithClaim("scopes", []string{"profile:read"}),
+			},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "legacy admin token gets the admin scopes",
+			opts:     []testutil.TokenOption{testutil.WithClaim("role", "admin")},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "legacy user token",
+			wantCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Use(AuthMiddleware(testSecret, &tokenVersions{}, nil), RequireScope("users:admin"))
+			router.GET("/test", func(c *gin.Context) {
+				c.JSON(http.StatusOK, gin.H{})
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			req.Header.Set("Authorization", tokens.Bearer(tt.opts...))
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantCode == http.StatusForbidden {
+				assert.JSONEq(t, `{"error":"token lacks the users:admin scope","code":"INSUFFICIENT_SCOPE"}`, w.Body.String())
+				assert.Equal(t, `Bearer error="insufficient_scope", scope="users:admin"`, w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestRequireRecentAuth(t *testing.T) {
+	const maxAge = 5 * time.Minute
+	tokens := tokens.At(testutil.NewFakeClock(testNow))
+
+	tests := []struct {
+		name string
+		opts []testutil.TokenOption
+		// now is how long after testNow the request is made.
+		now      time.Duration
+		wantCode int
+	}{
+		{
+			name:     "recent password entry",
+			opts:     []testutil.TokenOption{testutil.WithClaim("auth_time", testNow.Unix())},
+			now:      time.Minute,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "at the max age",
+			opts:     []testutil.TokenOption{testutil.WithClaim("auth_time", testNow.Unix())},
+			now:      maxAge,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "elevation expired",
+			opts:     []testutil.TokenOption{testutil.WithClaim("auth_time", testNow.Unix())},
+			now:      maxAge + time.Second,
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "no password entry, e.g. a refreshed token",
+			wantCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := WithClock(testutil.NewFakeClock(testNow.Add(tt.now)))
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Use(AuthMiddleware(testSecret, &tokenVersions{}, nil, clock))
+			ok := func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{}) }
+			router.DELETE("/sensitive", RequireRecentAuth(maxAge, clock), ok)
+			router.GET("/ordinary", ok)
+
+			token := tokens.Bearer(tt.opts...)
+
+			req := httptest.NewRequest(http.MethodDelete, "/sensitive", nil)
+			req.Header.Set("Authorization", token)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantCode == http.StatusForbidden {
+				assert.JSONEq(t, `{"error":"recent authentication required","code":"REAUTH_REQUIRED"}`, w.Body.String())
+			}
+
+			req = httptest.NewRequest(http.MethodGet, "/ordinary", nil)
+			req.Header.Set("Authorization", token)
+			w = httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code, "ordinary routes do not need recent authentication")
+		})
+	}
+}
+
+func TestAuthMiddleware_TokenVersion(t *testing.T) {
+	tokenWithVersion := func(version int) string {
+		return tokens.Bearer(testutil.WithClaim("ver", version))
+	}
+
+	tests := []struct {
+		name         string
+		token        string
+		versions     *tokenVersions
+		wantCode     int
+		wantAttached bool
+		wantBody     string
+	}{
+		{
+			name:     "current version",
+			token:    tokenWithVersion(2),
+			versions: &tokenVersions{current: 2},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "legacy token without version",
+			token:    tokens.Bearer(),
+			versions: &tokenVersions{current: 0},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "signed out everywhere since",
+			token:    tokenWithVersion(2),
+			versions: &tokenVersions{current: 3},
+			wantCode: http.StatusUnauthorized,
+			wantBody: `{"error":"token has been revoked","code":"TOKEN_INVALID"}`,
+		},
+		{
+			name:     "lookup timeout",
+			token:    tokenWithVersion(2),
+			versions: &tokenVersions{err: repository.ErrTimeout},
+			wantCode: http.StatusGatewayTimeout,
+			wantBody: `{"error":"` + repository.ErrTimeout.Error() + `"}`,
+		},
+		{
+			name:         "lookup failure",
+			token:        tokenWithVersion(2),
+			versions:     &tokenVersions{err: errors.New("connection reset")},
+			wantCode:     http.StatusInternalServerError,
+			wantAttached: true,
+			wantBody:     `{"error":"failed to validate token"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			var attached int
+			router := gin.New()
+			router.Use(func(c *gin.Context) {
+				c.Next()
+				attached = len(c.Errors)
+			}, AuthMiddleware(testSecret, tt.versions, nil))
+			router.GET("/test", func(c *gin.Context) {
+				c.JSON(http.StatusOK, gin.H{})
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			req.Header.Set("Authorization", tt.token)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Equal(t, tt.wantAttached, attached > 0)
+			if tt.wantBody != "" {
+				assert.JSONEq(t, tt.wantBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware_OldTokensDieAfterVersionBump(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	versions := &tokenVersions{current: 0}
+	router := gin.New()
+	router.Use(AuthMiddleware(testSecret, versions, nil))
+	router.GET("/test", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{})
+	})
+	request := func(version int) int {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.Header.Set("Authorization", tokens.Bearer(testutil.WithClaim("ver", version)))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, request(0))
+
+	versions.current++
+
+	assert.Equal(t, http.StatusUnauthorized, request(0), "tokens from before the bump are rejected")
+	assert.Equal(t, http.StatusOK, request(1), "tokens from a new login are accepted")
+}
+
+func TestAuthMiddleware_MalformedUserID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	versions := &tokenVersions{}
+	router := gin.New()
+	router.Use(AuthMiddleware(testSecret, versions, nil))
+	router.GET("/test", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{})
+	})
+	request := func(userID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.Header.Set("Authorization", tokens.Bearer(testutil.WithClaim("user_id", userID)))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, userID := range []string{"not-a-uuid", "42", testutil.TokenUserID + "x"} {
+		w := request(userID)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code, userID)
+		assert.JSONEq(t, `{"error":"invalid token claims","code":"TOKEN_INVALID"}`, w.Body.String(), userID)
+	}
+	assert.Empty(t, versions.checked, "tokens of malformed user IDs never reach the user repository")
+
+	assert.Equal(t, http.StatusOK, request(testutil.TokenUserID).Code)
+	assert.Equal(t, []string{testutil.TokenUserID}, versions.checked)
+}
+
+func TestAuthMiddleware_Actor(t *testing.T) {
+	const adminID = "8a6e0804-2bd0-4672-b79d-d97027f9071a"
+
+	tests := []struct {
+		name      string
+		act       interface{}
+		wantCode  int
+		wantActor interface{}
+	}{
+		{
+			name:     "no actor",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:      "impersonation token",
+			act:       map[string]string{"sub": adminID},
+			wantCode:  http.StatusOK,
+			wantActor: adminID,
+		},
+		{
+			name:     "actor is not an object",
+			act:      adminID,
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "actor without ID",
+			act:      map[string]string{"name": "admin"},
+			wantCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Use(AuthMiddleware(testSecret, &tokenVersions{}, nil))
+			router.GET("/test", func(c *gin.Context) {
+				var actor interface{}
+				if user, _ := authctx.User(c); user.Impersonated() {
+					actor = user.ActorID.String()
+				}
+				c.JSON(http.StatusOK, gin.H{"actor_id": actor})
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			req.Header.Set("Authorization", tokens.Bearer(testutil.WithClaim("act", tt.act)))
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantCode == http.StatusOK {
+				var res map[string]interface{}
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+				assert.Equal(t, tt.wantActor, res["actor_id"])
+			} else {
+				assert.JSONEq(t, `{"error":"invalid token claims","code":"TOKEN_INVALID"}`, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestForbidImpersonation(t *testing.T) {
+	tests := []struct {
+		name     string
+		setActor bool
+		wantCode int
+	}{
+		{name: "user acting as themselves", wantCode: http.StatusOK},
+		{name: "admin impersonating the user", setActor: true, wantCode: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Use(func(c *gin.Context) {
+				if tt.setActor {
+					authctx.SetUser(c, authctx.Identity{UserID: uuid.New(), ActorID: uuid.New()})
+				}
+			}, ForbidImpersonation())
+			router.DELETE("/test", func(c *gin.Context) {
+				c.JSON(http.StatusOK, gin.H{})
+			})
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/test", nil))
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantCode == http.StatusForbidden {
+				assert.JSONEq(t, `{"error":"not allowed while impersonating a user","code":"IMPERSONATION_FORBIDDEN"}`, w.Body.String())
 			}
 		})
 	}
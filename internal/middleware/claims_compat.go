@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// legacyClaims are the claims added to access tokens over time, which older
+// tokens lack.
+var legacyClaims = []string{"role", "ver", "scopes"}
+
+// ClaimsCompat decides whether AuthMiddleware accepts an access token that
+// lacks claims added to access tokens since it was issued, and counts those
+// tokens in the auth_legacy_tokens_total metric, so that operators can tell
+// when none are in use any more. Tokens issued before the cutoff get the
+// defaults of the missing claims, and those issued since are rejected. A nil
+// ClaimsCompat, or one without a cutoff, accepts every such token. It is safe
+// for concurrent use.
+type ClaimsCompat struct {
+	cutoff time.Time
+	legacy *prometheus.CounterVec
+}
+
+// NewClaimsCompat creates a ClaimsCompat that rejects tokens lacking the
+// role, ver or scopes claims when they were issued at or after cutoff, or
+// none if cutoff is zero, and registers its metric with registerer. It
+// returns an error if the metric cannot be registered.
+func NewClaimsCompat(cutoff time.Time, registerer prometheus.Registerer) (*ClaimsCompat, error) {
+	c := &ClaimsCompat{
+		cutoff: cutoff,
+		legacy: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "auth_legacy_tokens_total",
+			Help: "Access tokens presented without the role, ver or scopes claims, by whether they were accepted.",
+		}, []string{"outcome"}),
+	}
+	if err := registerer.Register(c.legacy); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// allow reports whether a token with claims may be used. Tokens that have
+// every claim in legacyClaims are always allowed. Tokens lacking any are
+// counted, and allowed if they were issued before the cutoff; a token without
+// an "iat" claim cannot show that it was, so it is only allowed without a cutoff.
+func (c *ClaimsCompat) allow(claims jwt.MapClaims) bool {
+	if !isLegacy(claims) {
+		return true
+	}
+	if c == nil {
+		return true
+	}
+
+	allowed := true
+	if !c.cutoff.IsZero() {
+		iat, ok := claims["iat"].(float64)
+		allowed = ok && time.Unix(int64(iat), 0).Before(c.cutoff)
+	}
+	outcome := "rejected"
+	if allowed {
+		outcome = "accepted"
+	}
+	c.legacy.WithLabelValues(outcome).Inc()
+	return allowed
+}
+
+// isLegacy reports whether claims lack any of legacyClaims.
+func isLegacy(claims jwt.MapClaims) bool {
+	for _, name := range legacyClaims {
+		if _, present := claims[name]; !present {
+			return true
+		}
+	}
+	return false
+}
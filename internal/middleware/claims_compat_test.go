@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/authctx"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	promtest "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthMiddleware_ClaimsCompat(t *testing.T) {
+	cutoff := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	before := testutil.WithClaim("iat", cutoff.Add(-time.Second).Unix())
+	after := testutil.WithClaim("iat", cutoff.Unix())
+	complete := []testutil.TokenOption{
+		testutil.WithClaim("role", model.RoleUser),
+		testutil.WithClaim("ver", 0),
+		testutil.WithClaim("scopes", service.ScopesForRole(model.RoleUser)),
+	}
+
+	tests := []struct {
+		name         string
+		cutoff       time.Time
+		opts         []testutil.TokenOption
+		wantCode     int
+		wantAccepted float64
+		wantRejected float64
+	}{
+		{name: "legacy token issued before the cutoff", cutoff: cutoff, opts: []testutil.TokenOption{before}, wantCode: http.StatusOK, wantAccepted: 1},
+		{name: "legacy token issued at the cutoff", cutoff: cutoff, opts: []testutil.TokenOption{after}, wantCode: http.StatusUnauthorized, wantRejected: 1},
+		{
+			name:         "token lacking only scopes after the cutoff",
+			cutoff:       cutoff,
+			opts:         append(append([]testutil.TokenOption{after}, complete...), testutil.WithClaim("scopes", nil)),
+			wantCode:     http.StatusUnauthorized,
+			wantRejected: 1,
+		},
+		{name: "legacy token without iat", cutoff: cutoff, opts: []testutil.TokenOption{testutil.WithClaim("iat", nil)}, wantCode: http.StatusUnauthorized, wantRejected: 1},
+		{name: "complete token after the cutoff", cutoff: cutoff, opts: append([]testutil.TokenOption{after}, complete...), wantCode: http.StatusOK},
+		{name: "complete token before the cutoff", cutoff: cutoff, opts: append([]testutil.TokenOption{before}, complete...), wantCode: http.StatusOK},
+		{name: "legacy token without a cutoff", opts: []testutil.TokenOption{after}, wantCode: http.StatusOK, wantAccepted: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compat, err := NewClaimsCompat(tt.cutoff, prometheus.NewRegistry())
+			require.NoError(t, err)
+			router := gin.New()
+			router.GET("/test", AuthMiddleware(testSecret, &tokenVersions{}, compat), func(c *gin.Context) {
+				user, _ := authctx.User(c)
+				c.JSON(http.StatusOK, gin.H{"role": user.Role, "scopes": user.Scopes})
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			req.Header.Set("Authorization", tokens.Bearer(tt.opts...))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantCode == http.StatusOK {
+				assert.JSONEq(t, `{"role":"user","scopes":["profile:read","profile:write"]}`, w.Body.String())
+			} else {
+				assert.Contains(t, w.Body.String(), "token lacks required claims")
+			}
+			assert.Equal(t, tt.wantAccepted, promtest.ToFloat64(compat.legacy.WithLabelValues("accepted")))
+			assert.Equal(t, tt.wantRejected, promtest.ToFloat64(compat.legacy.WithLabelValues("rejected")))
+		})
+	}
+}
+
+func TestClaimsCompat_Nil(t *testing.T) {
+	var compat *ClaimsCompat
+
+	_, claims := tokens.Token(testutil.WithClaim("iat", nil))
+
+	assert.True(t, compat.allow(claims), "a nil ClaimsCompat accepts every token")
+}
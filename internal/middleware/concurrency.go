@@ -0,0 +1,145 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/semaphore"
+)
+
+// ConcurrencyLimiter caps the requests served at once, across all routes and
+// for single routes, and counts them in Prometheus metrics. It is safe for
+// concurrent use.
+type ConcurrencyLimiter struct {
+	global       *semaphore.Weighted
+	routes       map[string]*semaphore.Weighted
+	queueTimeout time.Duration
+	inFlight     prometheus.Gauge
+	rejected     *prometheus.CounterVec
+}
+
+// NewConcurrencyLimiter creates a ConcurrencyLimiter and registers its
+// metrics, http_requests_in_flight and http_requests_rejected_total, with
+// registerer.
+//
+// Parameters:
+//   - limit: The requests served at once across all routes, or 0 for no limit.
+//   - routes: The limits of single routes, keyed by method and path, such as
+//     "POST /api/auth/login". They apply on top of limit.
+//   - queueTimeout: How long a request waits for a free slot before it is rejected.
+//   - registerer: Where the metrics are registered.
+//
+// Returns:
+//   - *ConcurrencyLimiter: The limiter.
+//   - error: An error if the metrics cannot be registered.
+func NewConcurrencyLimiter(limit int, routes map[string]int, queueTimeout time.Duration, registerer prometheus.Registerer) (*ConcurrencyLimiter, error) {
+	l := &ConcurrencyLimiter{
+		routes:       make(map[string]*semaphore.Weighted, len(routes)),
+		queueTimeout: queueTimeout,
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "http_requests_in_flight",
+			Help: "Requests currently being served.",
+		}),
+		rejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_rejected_total",
+			Help: "Requests rejected because too many were being served.",
+		}, []string{"route"}),
+	}
+	if limit > 0 {
+		l.global = semaphore.NewWeighted(int64(limit))
+	}
+	for route, routeLimit := range routes {
+		l.routes[route] = semaphore.NewWeighted(int64(routeLimit))
+	}
+
+	for _, collector := range []prometheus.Collector{l.inFlight, l.rejected} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// Saturated reports whether every slot of the limit across all routes is
+// taken, so that a request arriving now would have to queue.
+func (l *ConcurrencyLimiter) Saturated() bool {
+	if l.global == nil {
+		return false
+	}
+	if !l.global.TryAcquire(1) {
+		return true
+	}
+	l.global.Release(1)
+	return false
+}
+
+// acquire waits until a slot of every semaphore in sems is free, or until ctx
+// is done. It returns a function releasing the slots it took, or false if it
+// could not take all of them.
+func acquire(ctx context.Context, sems ...*semaphore.Weighted) (func(), bool) {
+	var held []*semaphore.Weighted
+	release := func() {
+		for _, sem := range held {
+			sem.Release(1)
+		}
+	}
+	for _, sem := range sems {
+		if sem == nil {
+			continue
+		}
+		if err := sem.Acquire(ctx, 1); err != nil {
+			release()
+			return nil, false
+		}
+		held = append(held, sem)
+	}
+	return release, true
+}
+
+// ConcurrencyLimit is a middleware function for the Gin framework that sheds
+// load: it lets a request through once the limiter has a free slot for its
+// route, waiting up to the limiter's queue timeout. Requests still waiting
+// then get a 503 Service Unavailable status with the "OVERLOADED" error code
+// from unavailable. The route slot is taken before the global one,
+// so that requests queued behind a busy route do not hold up the others.
+//
+// Parameters:
+//   - limiter: The limiter holding the slots.
+//   - unavailable: Writes the responses of rejected requests.
+//   - exempt: Route paths, as returned by gin.Context.FullPath, that are never
+//     limited, such as the health check.
+//
+// Returns:
+//   - gin.HandlerFunc: A Gin middleware handler function.
+func ConcurrencyLimit(limiter *ConcurrencyLimiter, unavailable *Unavailable, exempt ...string) gin.HandlerFunc {
+	exempted := make(map[string]bool, len(exempt))
+	for _, path := range exempt {
+		exempted[path] = true
+	}
+
+	return func(c *gin.Context) {
+		if exempted[c.FullPath()] {
+			c.Next()
+			return
+		}
+
+		route := c.Request.Method + " " + c.FullPath()
+		ctx, cancel := context.WithTimeout(c.Request.Context(), limiter.queueTimeout)
+		release, ok := acquire(ctx, limiter.routes[route], limiter.global)
+		cancel()
+		if !ok {
+			limiter.rejected.WithLabelValues(route).Inc()
+			unavailable.Respond(c, CauseOverloaded)
+			return
+		}
+
+		limiter.inFlight.Inc()
+		defer func() {
+			limiter.inFlight.Dec()
+			release()
+		}()
+		c.Next()
+	}
+}
@@ -0,0 +1,189 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// concurrencyProbe is a handler that records how many requests it serves at
+// once, holding each one for delay.
+type concurrencyProbe struct {
+	delay   time.Duration
+	current atomic.Int64
+	peak    atomic.Int64
+}
+
+func (p *concurrencyProbe) handle(c *gin.Context) {
+	n := p.current.Add(1)
+	defer p.current.Add(-1)
+	for {
+		peak := p.peak.Load()
+		if n <= peak || p.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	time.Sleep(p.delay)
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+func setupConcurrencyTest(t *testing.T, limit int, routes map[string]int, queueTimeout time.Duration) (*gin.Engine, *ConcurrencyLimiter) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	limiter, err := NewConcurrencyLimiter(limit, routes, queueTimeout, prometheus.NewRegistry())
+	require.NoError(t, err)
+
+	router := gin.New()
+	router.Use(ConcurrencyLimit(limiter, NewUnavailable(nil), "/healthz"))
+	return router, limiter
+}
+
+// serveParallel sends n GET requests to path at once and returns their responses.
+func serveParallel(router http.Handler, path string, n int) []*httptest.ResponseRecorder {
+	responses := make([]*httptest.ResponseRecorder, n)
+	var wg sync.WaitGroup
+	for i := range responses {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			responses[i] = w
+		}(i)
+	}
+	wg.Wait()
+	return responses
+}
+
+func TestNewConcurrencyLimiter_RegisterError(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	_, err := NewConcurrencyLimiter(1, nil, time.Millisecond, registry)
+	require.NoError(t, err)
+
+	_, err = NewConcurrencyLimiter(1, nil, time.Millisecond, registry)
+	assert.Error(t, err, "the metrics are already registered")
+}
+
+func TestConcurrencyLimit_CapHoldsUnderLoad(t *testing.T) {
+	router, limiter := setupConcurrencyTest(t, 10, nil, 5*time.Millisecond)
+	probe := &concurrencyProbe{delay: 20 * time.Millisecond}
+	router.GET("/test", probe.handle)
+
+	responses := serveParallel(router, "/test", 200)
+
+	var served, rejected int
+	for _, w := range responses {
+		switch w.Code {
+		case http.StatusOK:
+			served++
+		case http.StatusServiceUnavailable:
+			rejected++
+			assert.Equal(t, "1", w.Header().Get("Retry-After"))
+			var res map[string]interface{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+			assert.Equal(t, "OVERLOADED", res["code"])
+		default:
+			t.Fatalf("unexpected status %d", w.Code)
+		}
+	}
+	assert.LessOrEqual(t, probe.peak.Load(), int64(10), "no more than the limit are served at once")
+	assert.GreaterOrEqual(t, served, 10)
+	assert.Positive(t, rejected)
+	assert.Equal(t, float64(rejected), testutil.ToFloat64(limiter.rejected.WithLabelValues("GET /test")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(limiter.inFlight), "every slot is released")
+}
+
+func TestConcurrencyLimit_RouteLimit(t *testing.T) {
+	router, _ := setupConcurrencyTest(t, 0, map[string]int{"GET /slow": 2}, 5*time.Millisecond)
+	slow := &concurrencyProbe{delay: 20 * time.Millisecond}
+	fast := &concurrencyProbe{delay: 20 * time.Millisecond}
+	router.GET("/slow", slow.handle)
+	router.GET("/fast", fast.handle)
+
+	var wg sync.WaitGroup
+	var fastResponses []*httptest.ResponseRecorder
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		fastResponses = serveParallel(router, "/fast", 20)
+	}()
+	serveParallel(router, "/slow", 20)
+	wg.Wait()
+
+	assert.LessOrEqual(t, slow.peak.Load(), int64(2))
+	for _, w := range fastResponses {
+		assert.Equal(t, http.StatusOK, w.Code, "routes without a limit are not limited")
+	}
+}
+
+func TestConcurrencyLimit_QueuedRequestProceeds(t *testing.T) {
+	router, _ := setupConcurrencyTest(t, 1, nil, time.Second)
+	probe := &concurrencyProbe{delay: 10 * time.Millisecond}
+	router.GET("/test", probe.handle)
+
+	for _, w := range serveParallel(router, "/test", 5) {
+		assert.Equal(t, http.StatusOK, w.Code, "requests wait for a free slot within the queue timeout")
+	}
+	assert.Equal(t, int64(1), probe.peak.Load())
+}
+
+func TestConcurrencyLimit_ExemptRoute(t *testing.T) {
+	router, limiter := setupConcurrencyTest(t, 1, nil, time.Millisecond)
+	probe := &concurrencyProbe{delay: 20 * time.Millisecond}
+	router.GET("/healthz", probe.handle)
+
+	for _, w := range serveParallel(router, "/healthz", 10) {
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	assert.Equal(t, float64(0), testutil.ToFloat64(limiter.rejected.WithLabelValues("GET /healthz")))
+}
+
+func TestConcurrencyLimit_InFlightMetric(t *testing.T) {
+	router, limiter := setupConcurrencyTest(t, 0, nil, time.Millisecond)
+	started, release := make(chan struct{}), make(chan struct{})
+	router.GET("/test", func(c *gin.Context) {
+		close(started)
+		<-release
+		c.JSON(http.StatusOK, gin.H{})
+	})
+
+	done := make(chan struct{})
+	go func() {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/test", nil))
+		close(done)
+	}()
+
+	<-started
+	assert.Equal(t, float64(1), testutil.ToFloat64(limiter.inFlight))
+	close(release)
+	<-done
+	assert.Equal(t, float64(0), testutil.ToFloat64(limiter.inFlight))
+}
+
+func TestConcurrencyLimiter_Saturated(t *testing.T) {
+	unlimited, err := NewConcurrencyLimiter(0, nil, time.Millisecond, prometheus.NewRegistry())
+	require.NoError(t, err)
+	assert.False(t, unlimited.Saturated())
+
+	limiter, err := NewConcurrencyLimiter(1, nil, time.Millisecond, prometheus.NewRegistry())
+	require.NoError(t, err)
+	assert.False(t, limiter.Saturated())
+
+	release, ok := acquire(context.Background(), limiter.global)
+	require.True(t, ok)
+	assert.True(t, limiter.Saturated())
+
+	release()
+	assert.False(t, limiter.Saturated())
+}
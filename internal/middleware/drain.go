@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DrainMode records that the server is shutting down. Once it starts
+// draining it never stops, since the process is about to exit. The zero value
+// is not draining, and it is safe for concurrent use.
+type DrainMode struct {
+	draining atomic.Bool
+}
+
+// Draining reports whether the server is shutting down.
+func (d *DrainMode) Draining() bool {
+	return d.draining.Load()
+}
+
+// Start marks the server as shutting down.
+func (d *DrainMode) Start() {
+	d.draining.Store(true)
+}
+
+// Drain is a middleware function for the Gin framework that turns new
+// requests away once the server starts shutting down, while the requests
+// already being served finish. Rejected requests get a 503 Service
+// Unavailable status with the "SHUTTING_DOWN" error code from unavailable.
+// The health check is not exempt, so load balancers stop routing to the
+// instance as soon as it drains.
+//
+// Parameters:
+//   - mode: The switch consulted on every request.
+//   - unavailable: Writes the responses of rejected requests.
+//   - exempt: Route paths, as returned by gin.Context.FullPath, that stay
+//     reachable while draining, such as the metrics endpoint.
+//
+// Returns:
+//   - gin.HandlerFunc: A Gin middleware handler function.
+func Drain(mode *DrainMode, unavailable *Unavailable, exempt ...string) gin.HandlerFunc {
+	exempted := make(map[string]bool, len(exempt))
+	for _, path := range exempt {
+		exempted[path] = true
+	}
+
+	return func(c *gin.Context) {
+		if mode.Draining() && !exempted[c.FullPath()] {
+			unavailable.Respond(c, CauseShuttingDown)
+			return
+		}
+
+		c.Next()
+	}
+}
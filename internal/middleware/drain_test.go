@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDrainMode(t *testing.T) {
+	var mode DrainMode
+	assert.False(t, mode.Draining())
+
+	mode.Start()
+	assert.True(t, mode.Draining())
+}
+
+func TestDrain(t *testing.T) {
+	tests := []struct {
+		name     string
+		draining bool
+		path     string
+		wantCode int
+	}{
+		{name: "serving", draining: false, path: "/api/profile", wantCode: http.StatusOK},
+		{name: "draining", draining: true, path: "/api/profile", wantCode: http.StatusServiceUnavailable},
+		{name: "draining health check", draining: true, path: "/healthz", wantCode: http.StatusServiceUnavailable},
+		{name: "draining exempt route", draining: true, path: "/metrics", wantCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			var mode DrainMode
+			if tt.draining {
+				mode.Start()
+			}
+
+			ok := func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{}) }
+			router := gin.New()
+			router.Use(Drain(&mode, NewUnavailable(map[string]time.Duration{CauseShuttingDown: 5 * time.Second}), "/metrics"))
+			router.GET("/healthz", ok)
+			router.GET("/metrics", ok)
+			router.GET("/api/profile", ok)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantCode == http.StatusServiceUnavailable {
+				assert.Equal(t, "5", w.Header().Get("Retry-After"))
+				assert.JSONEq(t, `{"code":"SHUTTING_DOWN","error":"server is shutting down, try again later"}`, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/PakornBank/learn-go/internal/apierror"
+	"github.com/PakornBank/learn-go/internal/authctx"
+	"github.com/PakornBank/learn-go/internal/errreport"
+	"github.com/gin-gonic/gin"
+)
+
+// Recovery is a middleware function for the Gin framework that recovers from
+// panics in later handlers. The panic is logged with its stack trace, sent to
+// reporter, and the client receives a 500 Internal Server Error response.
+// It replaces gin.Recovery and should be registered after RequestID so that
+// reports carry the request ID.
+//
+// Parameters:
+//   - reporter: The error reporter panics are sent to.
+//
+// Returns:
+//   - gin.HandlerFunc: A Gin middleware handler function.
+func Recovery(reporter errreport.ErrorReporter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+
+			err, ok := rec.(error)
+			if !ok {
+				err = fmt.Errorf("%v", rec)
+			}
+			err = fmt.Errorf("panic: %w", err)
+
+			slog.ErrorContext(c.Request.Context(), "panic recovered", "error", err, "stack", string(debug.Stack()))
+			reporter.Report(c.Request.Context(), err, reportEvent(c))
+			apierror.Respond(c, http.StatusInternalServerError, "internal server error")
+			c.Abort()
+		}()
+		c.Next()
+	}
+}
+
+// ReportErrors is a middleware function for the Gin framework that sends the
+// errors handlers attach with c.Error to reporter, but only when the response
+// has a 5xx status. Client errors such as failed validation are expected and
+// never reported, even when a handler attached an error to them.
+//
+// Parameters:
+//   - reporter: The error reporter errors are sent to.
+//
+// Returns:
+//   - gin.HandlerFunc: A Gin middleware handler function.
+func ReportErrors(reporter errreport.ErrorReporter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if c.Writer.Status() < http.StatusInternalServerError {
+			return
+		}
+		for _, err := range c.Errors {
+			reporter.Report(c.Request.Context(), err.Err, reportEvent(c))
+		}
+	}
+}
+
+// reportEvent describes the request in c for an error report. It carries only
+// identifiers, never the request body.
+func reportEvent(c *gin.Context) errreport.Event {
+	event := errreport.Event{
+		RequestID: c.GetString("request_id"),
+		Route:     c.FullPath(),
+	}
+	if user, ok := authctx.User(c); ok {
+		event.UserID = user.UserID.String()
+	}
+	return event
+}
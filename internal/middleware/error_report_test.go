@@ -0,0 +1,141 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/authctx"
+	"github.com/PakornBank/learn-go/internal/errreport"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type reportedError struct {
+	err   error
+	event errreport.Event
+}
+
+type fakeReporter struct {
+	reports []reportedError
+}
+
+func (r *fakeReporter) Report(_ context.Context, err error, event errreport.Event) {
+	r.reports = append(r.reports, reportedError{err: err, event: event})
+}
+
+func (r *fakeReporter) Flush(time.Duration) {}
+
+func setupErrorReportTest(reporter errreport.ErrorReporter, handler gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestID(), Recovery(reporter), ReportErrors(reporter))
+	router.POST("/users/:id", func(c *gin.Context) {
+		authctx.SetUser(c, authctx.Identity{UserID: uuid.MustParse("5b0c8f3e-2a1d-4c6b-9e7f-3d2a1b0c9e8f")})
+		c.Next()
+	}, handler)
+	return router
+}
+
+func TestReportErrors(t *testing.T) {
+	errUnexpected := errors.New("connection reset")
+
+	tests := []struct {
+		name        string
+		handler     gin.HandlerFunc
+		wantCode    int
+		wantReports []error
+	}{
+		{
+			name: "server error is reported",
+			handler: func(c *gin.Context) {
+				_ = c.Error(errUnexpected)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed"})
+			},
+			wantCode:    http.StatusInternalServerError,
+			wantReports: []error{errUnexpected},
+		},
+		{
+			name: "validation error is not reported",
+			handler: func(c *gin.Context) {
+				_ = c.Error(errors.New("email is required"))
+				c.JSON(http.StatusBadRequest, gin.H{"error": "email is required"})
+			},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name: "server error without attached error is not reported",
+			handler: func(c *gin.Context) {
+				c.JSON(http.StatusGatewayTimeout, gin.H{"error": "timeout"})
+			},
+			wantCode: http.StatusGatewayTimeout,
+		},
+		{
+			name: "success is not reported",
+			handler: func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			},
+			wantCode: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reporter := &fakeReporter{}
+			router := setupErrorReportTest(reporter, tt.handler)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/users/42", nil)
+			req.Header.Set(RequestIDHeader, "req-1")
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			require.Len(t, reporter.reports, len(tt.wantReports))
+			for i, want := range tt.wantReports {
+				assert.Equal(t, want, reporter.reports[i].err)
+				assert.Equal(t, errreport.Event{RequestID: "req-1", Route: "/users/:id", UserID: "5b0c8f3e-2a1d-4c6b-9e7f-3d2a1b0c9e8f"}, reporter.reports[i].event)
+			}
+		})
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	t.Run("panic is reported", func(t *testing.T) {
+		reporter := &fakeReporter{}
+		router := setupErrorReportTest(reporter, func(c *gin.Context) {
+			panic("nil map write")
+		})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/users/42", nil)
+		req.Header.Set(RequestIDHeader, "req-1")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.JSONEq(t, `{"error":"internal server error"}`, w.Body.String())
+		require.Len(t, reporter.reports, 1)
+		assert.EqualError(t, reporter.reports[0].err, "panic: nil map write")
+		assert.Equal(t, errreport.Event{RequestID: "req-1", Route: "/users/:id", UserID: "5b0c8f3e-2a1d-4c6b-9e7f-3d2a1b0c9e8f"}, reporter.reports[0].event)
+	})
+
+	t.Run("panic with error keeps it wrapped", func(t *testing.T) {
+		errBoom := errors.New("boom")
+		reporter := &fakeReporter{}
+		router := setupErrorReportTest(reporter, func(c *gin.Context) {
+			panic(errBoom)
+		})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/users/42", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		require.Len(t, reporter.reports, 1)
+		assert.ErrorIs(t, reporter.reports[0].err, errBoom)
+	})
+}
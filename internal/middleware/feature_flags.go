@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"github.com/PakornBank/learn-go/internal/authctx"
+	"github.com/PakornBank/learn-go/internal/featureflag"
+	"github.com/gin-gonic/gin"
+)
+
+// FlagSource provides the feature flags of a user. It is satisfied by
+// *featureflag.Static.
+type FlagSource interface {
+	ForUser(userID string) featureflag.Flags
+}
+
+// FeatureFlags is a middleware function for the Gin framework that stores the
+// feature flags of the requesting user in the request's context.Context, where
+// handlers and services check them with featureflag.Enabled. The flags are
+// evaluated for the user authctx.User finds, so the middleware must come after
+// the authentication middleware; before it, or on public routes, requests are
+// evaluated as anonymous.
+func FeatureFlags(source FlagSource) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var userID string
+		if user, ok := authctx.User(c); ok {
+			userID = user.UserID.String()
+		}
+		flags := source.ForUser(userID)
+		c.Request = c.Request.WithContext(featureflag.NewContext(c.Request.Context(), flags))
+		c.Next()
+	}
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PakornBank/learn-go/internal/authctx"
+	"github.com/PakornBank/learn-go/internal/featureflag"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeatureFlags(t *testing.T) {
+	flags, err := featureflag.NewStatic(map[string]featureflag.Rule{
+		"everyone":  {Enabled: true},
+		"signed_in": {Percentage: 100},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		userID       string
+		wantEveryone bool
+		wantSignedIn bool
+	}{
+		{name: "authenticated", userID: "5b0c8f3e-2a1d-4c6b-9e7f-3d2a1b0c9e8f", wantEveryone: true, wantSignedIn: true},
+		{name: "anonymous", wantEveryone: true, wantSignedIn: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Use(func(c *gin.Context) {
+				if tt.userID != "" {
+					authctx.SetUser(c, authctx.Identity{UserID: uuid.MustParse(tt.userID)})
+				}
+			}, FeatureFlags(flags))
+			router.GET("/test", func(c *gin.Context) {
+				ctx := c.Request.Context()
+				assert.Equal(t, tt.wantEveryone, featureflag.Enabled(ctx, "everyone"))
+				assert.Equal(t, tt.wantSignedIn, featureflag.Enabled(ctx, "signed_in"))
+				assert.False(t, featureflag.Enabled(ctx, "unknown"))
+				c.Status(http.StatusNoContent)
+			})
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test", nil))
+
+			assert.Equal(t, http.StatusNoContent, w.Code)
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaintenanceMode is a process-wide maintenance switch that can be flipped at
+// runtime. The zero value is inactive, and it is safe for concurrent use.
+type MaintenanceMode struct {
+	active atomic.Bool
+}
+
+// Active reports whether maintenance mode is on.
+func (m *MaintenanceMode) Active() bool {
+	return m.active.Load()
+}
+
+// SetActive turns maintenance mode on or off.
+func (m *MaintenanceMode) SetActive(active bool) {
+	m.active.Store(active)
+}
+
+// Maintenance is a middleware function for the Gin framework that rejects
+// requests while maintenance mode is active. Rejected requests get a 503
+// Service Unavailable status with the "MAINTENANCE" error code from unavailable.
+//
+// Parameters:
+//   - mode: The switch consulted on every request.
+//   - unavailable: Writes the responses of rejected requests.
+//   - exempt: Route paths, as returned by gin.Context.FullPath, that stay
+//     reachable during maintenance, such as the endpoint that turns it off.
+//
+// Returns:
+//   - gin.HandlerFunc: A Gin middleware handler function.
+func Maintenance(mode *MaintenanceMode, unavailable *Unavailable, exempt ...string) gin.HandlerFunc {
+	exempted := make(map[string]bool, len(exempt))
+	for _, path := range exempt {
+		exempted[path] = true
+	}
+
+	return func(c *gin.Context) {
+		if mode.Active() && !exempted[c.FullPath()] {
+			unavailable.Respond(c, CauseMaintenance)
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaintenanceMode(t *testing.T) {
+	var mode MaintenanceMode
+	assert.False(t, mode.Active())
+
+	mode.SetActive(true)
+	assert.True(t, mode.Active())
+
+	mode.SetActive(false)
+	assert.False(t, mode.Active())
+}
+
+func TestMaintenance(t *testing.T) {
+	tests := []struct {
+		name     string
+		active   bool
+		path     string
+		wantCode int
+	}{
+		{name: "inactive", active: false, path: "/api/profile", wantCode: http.StatusOK},
+		{name: "active", active: true, path: "/api/profile", wantCode: http.StatusServiceUnavailable},
+		{name: "active exempt route", active: true, path: "/api/admin/maintenance", wantCode: http.StatusOK},
+		{name: "active health check", active: true, path: "/healthz", wantCode: http.StatusOK},
+		{name: "active unknown route", active: true, path: "/api/unknown", wantCode: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			var mode MaintenanceMode
+			mode.SetActive(tt.active)
+
+			ok := func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{}) }
+			router := gin.New()
+			router.GET("/healthz", ok)
+			api := router.Group("/api")
+			api.Use(Maintenance(&mode, NewUnavailable(nil), "/api/admin/maintenance"))
+			api.GET("/profile", ok)
+			api.GET("/admin/maintenance", ok)
+			api.GET("/unknown", ok)
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantCode == http.StatusServiceUnavailable {
+				var res map[string]interface{}
+				err := json.Unmarshal(w.Body.Bytes(), &res)
+				assert.NoError(t, err)
+				assert.Equal(t, "MAINTENANCE", res["code"])
+			}
+		})
+	}
+}
+
+func TestMaintenanceTogglesWithoutRestart(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var mode MaintenanceMode
+	router := gin.New()
+	router.Use(Maintenance(&mode, NewUnavailable(nil)))
+	router.GET("/test", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{}) })
+
+	serve := func() int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test", nil))
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve())
+	mode.SetActive(true)
+	assert.Equal(t, http.StatusServiceUnavailable, serve())
+	mode.SetActive(false)
+	assert.Equal(t, http.StatusOK, serve())
+}
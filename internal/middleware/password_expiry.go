@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/PakornBank/learn-go/internal/apierror"
+	"github.com/PakornBank/learn-go/internal/authctx"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// PasswordExpiryChecker checks that a user's password has not expired.
+type PasswordExpiryChecker interface {
+	// CheckPasswordExpiry returns service.ErrPasswordExpired if the password
+	// of the user with userID is older than the maximum password age.
+	CheckPasswordExpiry(ctx context.Context, userID string) error
+}
+
+// RequireUnexpiredPassword is a middleware function for the Gin framework
+// that keeps users whose password has expired from protected routes. They get
+// a 403 Forbidden status with the "PASSWORD_EXPIRED" code until they change
+// their password. The check is made on every request rather than read from
+// the token, so a changed password lets the user through at once.
+//
+// Parameters:
+//   - checker: Looks up when the user last changed their password.
+//   - exempt: Route paths, as returned by gin.Context.FullPath, that stay
+//     reachable, such as the endpoint that changes the password.
+//
+// Returns:
+//   - gin.HandlerFunc: A Gin middleware handler function.
+//
+// If the user cannot be looked up, it responds with a 504 Gateway Timeout or a
+// 500 Internal Server Error status instead. It must be registered after
+// AuthMiddleware.
+func RequireUnexpiredPassword(checker PasswordExpiryChecker, exempt ...string) gin.HandlerFunc {
+	exempted := make(map[string]bool, len(exempt))
+	for _, path := range exempt {
+		exempted[path] = true
+	}
+
+	return func(c *gin.Context) {
+		if exempted[c.FullPath()] {
+			c.Next()
+			return
+		}
+
+		user, _ := authctx.User(c)
+		err := checker.CheckPasswordExpiry(c.Request.Context(), user.UserID.String())
+		switch {
+		case err == nil:
+			c.Next()
+			return
+		case errors.Is(err, service.ErrPasswordExpired):
+			apierror.RespondCode(c, http.StatusForbidden, "PASSWORD_EXPIRED", service.ErrPasswordExpired.Error())
+		case errors.Is(err, repository.ErrTimeout):
+			apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+		default:
+			c.Error(err)
+			apierror.Respond(c, http.StatusInternalServerError, "failed to check password expiry")
+		}
+		c.Abort()
+	}
+}
@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PakornBank/learn-go/internal/authctx"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// expiredPasswords is a PasswordExpiryChecker reporting the users in expired
+// as having an expired password.
+type expiredPasswords struct {
+	expired map[string]bool
+	err     error
+}
+
+func (p *expiredPasswords) CheckPasswordExpiry(_ context.Context, userID string) error {
+	if p.err != nil {
+		return p.err
+	}
+	if p.expired[userID] {
+		return service.ErrPasswordExpired
+	}
+	return nil
+}
+
+const passwordChangePath = "/api/auth/password"
+
+func setupPasswordExpiryTest(checker PasswordExpiryChecker, errs *[]string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	ok := func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{}) }
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Next()
+		*errs = append(*errs, c.Errors.Errors()...)
+	})
+	protected := router.Group("/api/auth")
+	protected.Use(
+		func(c *gin.Context) {
+			authctx.SetUser(c, authctx.Identity{UserID: uuid.MustParse(c.GetHeader("X-User"))})
+		},
+		RequireUnexpiredPassword(checker, passwordChangePath),
+	)
+	protected.GET("/profile", ok)
+	protected.PUT("/password", ok)
+	return router
+}
+
+func TestRequireUnexpiredPassword(t *testing.T) {
+	const userID = "5b0c8f3e-2a1d-4c6b-9e7f-3d2a1b0c9e8f"
+
+	tests := []struct {
+		name         string
+		checker      *expiredPasswords
+		method       string
+		path         string
+		wantCode     int
+		wantErrCode  string
+		wantAttached bool
+	}{
+		{
+			name:     "password current",
+			checker:  &expiredPasswords{},
+			method:   http.MethodGet,
+			path:     "/api/auth/profile",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:        "password expired",
+			checker:     &expiredPasswords{expired: map[string]bool{userID: true}},
+			method:      http.MethodGet,
+			path:        "/api/auth/profile",
+			wantCode:    http.StatusForbidden,
+			wantErrCode: "PASSWORD_EXPIRED",
+		},
+		{
+			name:     "password change is exempt",
+			checker:  &expiredPasswords{expired: map[string]bool{userID: true}},
+			method:   http.MethodPut,
+			path:     passwordChangePath,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "database timeout",
+			checker:  &expiredPasswords{err: repository.ErrTimeout},
+			method:   http.MethodGet,
+			path:     "/api/auth/profile",
+			wantCode: http.StatusGatewayTimeout,
+		},
+		{
+			name:         "database error",
+			checker:      &expiredPasswords{err: errors.New("connection reset")},
+			method:       http.MethodGet,
+			path:         "/api/auth/profile",
+			wantCode:     http.StatusInternalServerError,
+			wantAttached: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attached []string
+			router := setupPasswordExpiryTest(tt.checker, &attached)
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("X-User", userID)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Equal(t, tt.wantAttached, len(attached) > 0)
+			if tt.wantErrCode != "" {
+				var res map[string]interface{}
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+				assert.Equal(t, tt.wantErrCode, res["code"])
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/PakornBank/learn-go/internal/apierror"
+	"github.com/PakornBank/learn-go/internal/ratelimit"
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimiter decides whether a request identified by key may proceed.
+type RateLimiter interface {
+	Allow(ctx context.Context, key string) (ratelimit.Decision, error)
+}
+
+// RateLimit is a middleware function for the Gin framework that limits how
+// often a client may call a route. Requests are counted per route and client
+// IP, so each route has its own budget. When the limit is exceeded, the
+// middleware responds with a 429 Too Many Requests status, sets the
+// "Retry-After" header, and aborts the request.
+//
+// If the limiter fails, for example because Redis is unreachable, the request
+// is allowed and a warning is logged: an outage of the limiter must not take
+// the API down with it.
+//
+// Parameters:
+//   - limiter: The rate limiter to consult.
+//   - route: The name of the route, used as part of the key.
+//
+// Returns:
+//   - gin.HandlerFunc: A Gin middleware handler function.
+func RateLimit(limiter RateLimiter, route string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := route + ":" + c.ClientIP()
+
+		decision, err := limiter.Allow(c.Request.Context(), key)
+		if err != nil {
+			slog.WarnContext(c.Request.Context(), "rate limiter unavailable, allowing request", "key", key, "error", err)
+			c.Next()
+			return
+		}
+
+		if !decision.Allowed {
+			retryAfter := int(math.Ceil(decision.RetryAfter.Seconds()))
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			apierror.Respond(c, http.StatusTooManyRequests, "too many requests")
+			c.Abort()
+			return
+		}
+
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+		c.Next()
+	}
+}
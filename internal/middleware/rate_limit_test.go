@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/ratelimit"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type stubRateLimiter struct {
+	decision ratelimit.Decision
+	err      error
+	keys     []string
+}
+
+func (l *stubRateLimiter) Allow(_ context.Context, key string) (ratelimit.Decision, error) {
+	l.keys = append(l.keys, key)
+	return l.decision, l.err
+}
+
+func TestRateLimit(t *testing.T) {
+	tests := []struct {
+		name           string
+		limiter        *stubRateLimiter
+		wantCode       int
+		wantRetryAfter string
+		wantRemaining  string
+	}{
+		{
+			name:          "allowed",
+			limiter:       &stubRateLimiter{decision: ratelimit.Decision{Allowed: true, Remaining: 4}},
+			wantCode:      http.StatusOK,
+			wantRemaining: "4",
+		},
+		{
+			name:           "limit exceeded",
+			limiter:        &stubRateLimiter{decision: ratelimit.Decision{RetryAfter: 1500 * time.Millisecond}},
+			wantCode:       http.StatusTooManyRequests,
+			wantRetryAfter: "2",
+		},
+		{
+			name:     "limiter unavailable fails open",
+			limiter:  &stubRateLimiter{err: errors.New("connection refused")},
+			wantCode: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.POST("/login", RateLimit(tt.limiter, "login"), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/login", nil)
+			req.RemoteAddr = "10.0.0.1:12345"
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Equal(t, tt.wantRetryAfter, w.Header().Get("Retry-After"))
+			assert.Equal(t, tt.wantRemaining, w.Header().Get("X-RateLimit-Remaining"))
+			assert.Equal(t, []string{"login:10.0.0.1"}, tt.limiter.keys)
+		})
+	}
+}
+
+func TestRateLimit_WithMemoryLimiter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	limiter := ratelimit.NewMemory(ratelimit.Fixed(1, time.Minute))
+	router.POST("/login", RateLimit(limiter, "login"), func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.POST("/register", RateLimit(limiter, "register"), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	send := func(path string) int {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.RemoteAddr = "10.0.0.1:12345"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, send("/login"))
+	assert.Equal(t, http.StatusTooManyRequests, send("/login"))
+	assert.Equal(t, http.StatusOK, send("/register"), "routes have separate budgets")
+}
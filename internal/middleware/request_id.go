@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"github.com/PakornBank/learn-go/internal/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader is the header used to receive and return the request ID.
+const RequestIDHeader = "X-Request-ID"
+
+// RequestID is a middleware function for the Gin framework that assigns every
+// request an ID. It reuses the ID from the incoming "X-Request-ID" header when
+// present, otherwise it generates a new one. The ID is echoed back in the
+// response header, set in the Gin context as "request_id", and stored in the
+// request's context.Context so that loggers further down the stack, such as
+// the database logger, can include it.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if requestID == "" {
+			requestID = uuid.NewString()
+		}
+
+		c.Set("request_id", requestID)
+		c.Header(RequestIDHeader, requestID)
+		c.Request = c.Request.WithContext(logger.WithRequestID(c.Request.Context(), requestID))
+		c.Next()
+	}
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PakornBank/learn-go/internal/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name          string
+		headerValue   string
+		wantRequestID string
+	}{
+		{
+			name:          "reuses incoming request ID",
+			headerValue:   "incoming-id",
+			wantRequestID: "incoming-id",
+		},
+		{
+			name: "generates request ID when absent",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Use(RequestID())
+			router.GET("/test", func(c *gin.Context) {
+				c.JSON(http.StatusOK, gin.H{
+					"gin":     c.GetString("request_id"),
+					"context": logger.RequestIDFromContext(c.Request.Context()),
+				})
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			if tt.headerValue != "" {
+				req.Header.Set(RequestIDHeader, tt.headerValue)
+			}
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			var res map[string]string
+			err := json.Unmarshal(w.Body.Bytes(), &res)
+			assert.NoError(t, err)
+
+			requestID := w.Header().Get(RequestIDHeader)
+			if tt.wantRequestID != "" {
+				assert.Equal(t, tt.wantRequestID, requestID)
+			} else {
+				_, err := uuid.Parse(requestID)
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, requestID, res["gin"])
+			assert.Equal(t, requestID, res["context"])
+		})
+	}
+}
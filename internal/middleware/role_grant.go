@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/PakornBank/learn-go/internal/apierror"
+	"github.com/PakornBank/learn-go/internal/authctx"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// RoleChecker checks the roles users hold beyond the one in their access
+// token, such as through a temporary grant.
+type RoleChecker interface {
+	// HoldsRole reports whether the user with userID holds role now.
+	HoldsRole(ctx context.Context, userID, role string) (bool, error)
+}
+
+// RequireRoleOrGrant is a middleware function for the Gin framework that lets
+// the request through like RequireRole, and also when checker finds that the
+// user holds one of the given roles otherwise, such as through a temporary
+// grant. The role in the token is checked first, so users holding it are let
+// through without a lookup. A user let through by checker acts with the role
+// they hold for the rest of the request, with the scopes it adds to those of
+// their own role, see service.GrantedScopes, so that RequireScope registered
+// after it lets them through too. Once the grant ends, their next request is
+// forbidden again. Only tokens carrying every scope of the user's own role,
+// such as those of a password login, are elevated; a token narrowed to some
+// scopes, such as one issued for a script, stays narrow and is forbidden
+// without a lookup.
+//
+// Parameters:
+//   - checker: Looks up the roles held beyond the one in the token.
+//   - roles: The roles allowed through.
+//
+// Returns:
+//   - gin.HandlerFunc: A Gin middleware handler function.
+//
+// Users holding none of roles get a 403 Forbidden status and the "FORBIDDEN"
+// code. If checker fails, it responds with a 504 Gateway Timeout or a 500
+// Internal Server Error status instead. It must be registered after
+// AuthMiddleware.
+func RequireRoleOrGrant(checker RoleChecker, roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, _ := authctx.User(c)
+		if slices.Contains(roles, user.Role) {
+			c.Next()
+			return
+		}
+
+		for _, role := range roles {
+			if !hasEveryScope(user, service.ScopesForRole(user.Role)) {
+				break
+			}
+
+			held, err := checker.HoldsRole(c.Request.Context(), user.UserID.String(), role)
+			switch {
+			case errors.Is(err, repository.ErrTimeout):
+				apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+				c.Abort()
+				return
+			case err != nil:
+				c.Error(err)
+				apierror.Respond(c, http.StatusInternalServerError, "failed to check role grants")
+				c.Abort()
+				return
+			case held:
+				elevated := user
+				elevated.Scopes = slices.Clone(user.Scopes)
+				for _, scope := range service.GrantedScopes(user.Role, role) {
+					if !slices.Contains(elevated.Scopes, scope) {
+						elevated.Scopes = append(elevated.Scopes, scope)
+					}
+				}
+				elevated.Role = role
+				authctx.SetUser(c, elevated)
+				c.Next()
+				return
+			}
+		}
+
+		apierror.RespondCode(c, http.StatusForbidden, "FORBIDDEN", "forbidden")
+		c.Abort()
+	}
+}
+
+// hasEveryScope reports whether the token of user carries every one of scopes.
+func hasEveryScope(user authctx.Identity, scopes []string) bool {
+	for _, scope := range scopes {
+		if !user.HasScope(scope) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PakornBank/learn-go/internal/authctx"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// grantedRoles is a RoleChecker reporting the roles in held as held by every
+// user, and counting its lookups.
+type grantedRoles struct {
+	held    map[string]bool
+	err     error
+	lookups int
+}
+
+func (g *grantedRoles) HoldsRole(_ context.Context, _, role string) (bool, error) {
+	g.lookups++
+	return g.held[role], g.err
+}
+
+func TestRequireRoleOrGrant(t *testing.T) {
+	tests := []struct {
+		name        string
+		role        string
+		checker     *grantedRoles
+		wantCode    int
+		wantErrCode string
+		wantLookups int
+		// wantIdentity is the role and scopes the handler sees.
+		wantRole     string
+		wantScopes   []string
+		wantAttached bool
+	}{
+		{
+			name:       "role in the token",
+			role:       "admin",
+			checker:    &grantedRoles{},
+			wantCode:   http.StatusOK,
+			wantRole:   "admin",
+			wantScopes: []string{"profile:read", "profile:write", "users:admin"},
+		},
+		{
+			name:        "active grant",
+			role:        "user",
+			checker:     &grantedRoles{held: map[string]bool{"admin": true}},
+			wantCode:    http.StatusOK,
+			wantLookups: 1,
+			wantRole:    "admin",
+			wantScopes:  []string{"profile:read", "profile:write", "users:admin"},
+		},
+		{
+			name:        "no grant",
+			role:        "user",
+			checker:     &grantedRoles{},
+			wantCode:    http.StatusForbidden,
+			wantErrCode: "FORBIDDEN",
+			wantLookups: 1,
+		},
+		{
+			name:        "lookup timed out",
+			role:        "user",
+			checker:     &grantedRoles{err: repository.ErrTimeout},
+			wantCode:    http.StatusGatewayTimeout,
+			wantLookups: 1,
+		},
+		{
+			name:         "lookup failed",
+			role:         "user",
+			checker:      &grantedRoles{err: errors.New("connection refused")},
+			wantCode:     http.StatusInternalServerError,
+			wantLookups:  1,
+			wantAttached: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			var seen authctx.Identity
+			var attached []string
+			router := gin.New()
+			router.Use(func(c *gin.Context) {
+				scopes := []string{"profile:read", "profile:write"}
+				if tt.role == "admin" {
+					scopes = append(scopes, "users:admin")
+				}
+				authctx.SetUser(c, authctx.Identity{UserID: uuid.New(), Role: tt.role, Scopes: scopes})
+				c.Next()
+				attached = c.Errors.Errors()
+			})
+			router.Use(RequireRoleOrGrant(tt.checker, "admin"), RequireScope("users:admin"))
+			router.GET("/test", func(c *gin.Context) {
+				seen, _ = authctx.User(c)
+				c.JSON(http.StatusOK, gin.H{})
+			})
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test", nil))
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Equal(t, tt.wantLookups, tt.checker.lookups)
+			assert.Equal(t, tt.wantAttached, len(attached) > 0)
+			if tt.wantCode == http.StatusOK {
+				assert.Equal(t, tt.wantRole, seen.Role)
+				assert.Equal(t, tt.wantScopes, seen.Scopes)
+			}
+			if tt.wantErrCode != "" {
+				var res map[string]interface{}
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+				assert.Equal(t, tt.wantErrCode, res["code"])
+			}
+		})
+	}
+}
+
+func TestRequireRoleOrGrant_KeepsNarrowedTokensNarrow(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	checker := &grantedRoles{held: map[string]bool{"admin": true}}
+	reached := false
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		authctx.SetUser(c, authctx.Identity{UserID: uuid.New(), Role: "user", Scopes: []string{"profile:read"}})
+	})
+	router.Use(RequireRoleOrGrant(checker, "admin"), RequireScope("users:admin"))
+	router.GET("/test", func(c *gin.Context) {
+		reached = true
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test", nil))
+
+	assert.Equal(t, http.StatusForbidden, w.Code, "a profile:read token of a grantee does not gain users:admin")
+	assert.False(t, reached)
+	assert.Zero(t, checker.lookups, "narrowed tokens are not elevated, so their grants are not looked up")
+}
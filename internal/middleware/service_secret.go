@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/PakornBank/learn-go/internal/apierror"
+	"github.com/gin-gonic/gin"
+)
+
+// ServiceSecretHeader is the header internal services use to present the shared secret.
+const ServiceSecretHeader = "X-Service-Secret"
+
+// RequireServiceSecret is a middleware function for the Gin framework that
+// restricts an endpoint to internal services holding the shared secret.
+// The secret is expected in the "X-Service-Secret" header and is compared in
+// constant time. If it is missing or does not match, the middleware responds
+// with a 401 Unauthorized status and aborts the request.
+//
+// Parameters:
+//   - secret: The shared secret, which must not be empty.
+//
+// Returns:
+//   - gin.HandlerFunc: A Gin middleware handler function.
+func RequireServiceSecret(secret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		provided := c.GetHeader(ServiceSecretHeader)
+		if provided == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(secret)) != 1 {
+			apierror.Respond(c, http.StatusUnauthorized, "invalid service secret")
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
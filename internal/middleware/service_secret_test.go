@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireServiceSecret(t *testing.T) {
+	tests := []struct {
+		name     string
+		secret   string
+		wantCode int
+	}{
+		{name: "matching secret", secret: testSecret, wantCode: http.StatusOK},
+		{name: "wrong secret", secret: "wrong-secret", wantCode: http.StatusUnauthorized},
+		{name: "missing secret", secret: "", wantCode: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Use(RequireServiceSecret(testSecret))
+			router.GET("/test", func(c *gin.Context) {
+				c.JSON(http.StatusOK, gin.H{})
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			if tt.secret != "" {
+				req.Header.Set(ServiceSecretHeader, tt.secret)
+			}
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantCode == http.StatusUnauthorized {
+				var res map[string]interface{}
+				err := json.Unmarshal(w.Body.Bytes(), &res)
+				assert.NoError(t, err)
+				assert.Equal(t, "invalid service secret", res["error"])
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/PakornBank/learn-go/internal/apierror"
+	"github.com/PakornBank/learn-go/internal/authctx"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// TOSChecker checks that a user accepted the current terms of service.
+type TOSChecker interface {
+	// CheckTOS returns service.ErrTOSReacceptanceNeeded if the user with
+	// userID has not accepted the current version of the terms of service.
+	CheckTOS(ctx context.Context, userID string) error
+}
+
+// RequireTOS is a middleware function for the Gin framework that keeps users
+// who have not accepted the current terms of service, typically because a
+// new version was published, from protected routes. They get a 428
+// Precondition Required status with the "TOS_REACCEPTANCE_REQUIRED" code
+// until they accept it.
+//
+// Parameters:
+//   - checker: Looks up the version of the terms the user accepted.
+//   - exempt: Route paths, as returned by gin.Context.FullPath, that stay
+//     reachable, such as the endpoint that accepts the terms.
+//
+// Returns:
+//   - gin.HandlerFunc: A Gin middleware handler function.
+//
+// If the user cannot be looked up, it responds with a 504 Gateway Timeout or a
+// 500 Internal Server Error status instead. It must be registered after
+// AuthMiddleware.
+func RequireTOS(checker TOSChecker, exempt ...string) gin.HandlerFunc {
+	exempted := make(map[string]bool, len(exempt))
+	for _, path := range exempt {
+		exempted[path] = true
+	}
+
+	return func(c *gin.Context) {
+		if exempted[c.FullPath()] {
+			c.Next()
+			return
+		}
+
+		user, _ := authctx.User(c)
+		err := checker.CheckTOS(c.Request.Context(), user.UserID.String())
+		switch {
+		case err == nil:
+			c.Next()
+			return
+		case errors.Is(err, service.ErrTOSReacceptanceNeeded):
+			apierror.RespondCode(c, http.StatusPreconditionRequired, "TOS_REACCEPTANCE_REQUIRED", service.ErrTOSReacceptanceNeeded.Error())
+		case errors.Is(err, repository.ErrTimeout):
+			apierror.Respond(c, http.StatusGatewayTimeout, err.Error())
+		default:
+			c.Error(err)
+			apierror.Respond(c, http.StatusInternalServerError, "failed to check terms of service")
+		}
+		c.Abort()
+	}
+}
@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PakornBank/learn-go/internal/authctx"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tosVersions is a TOSChecker comparing the versions users accepted with
+// the current one, the way AuthService does.
+type tosVersions struct {
+	current  int
+	accepted map[string]int
+	err      error
+}
+
+func (v *tosVersions) CheckTOS(_ context.Context, userID string) error {
+	if v.err != nil {
+		return v.err
+	}
+	if v.accepted[userID] < v.current {
+		return service.ErrTOSReacceptanceNeeded
+	}
+	return nil
+}
+
+const tosAcceptPath = "/api/auth/tos/accept"
+
+func setupTOSTest(checker TOSChecker, errs *[]string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	ok := func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{}) }
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Next()
+		*errs = append(*errs, c.Errors.Errors()...)
+	})
+	protected := router.Group("/api/auth")
+	protected.Use(
+		func(c *gin.Context) {
+			authctx.SetUser(c, authctx.Identity{UserID: uuid.MustParse(c.GetHeader("X-User"))})
+		},
+		RequireTOS(checker, tosAcceptPath),
+	)
+	protected.GET("/profile", ok)
+	protected.POST("/tos/accept", ok)
+	return router
+}
+
+func TestRequireTOS(t *testing.T) {
+	tests := []struct {
+		name         string
+		checker      *tosVersions
+		method       string
+		path         string
+		wantCode     int
+		wantErrCode  string
+		wantAttached bool
+	}{
+		{
+			name:     "current version accepted",
+			checker:  &tosVersions{current: 2, accepted: map[string]int{"5b0c8f3e-2a1d-4c6b-9e7f-3d2a1b0c9e8f": 2}},
+			method:   http.MethodGet,
+			path:     "/api/auth/profile",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:        "older version accepted",
+			checker:     &tosVersions{current: 2, accepted: map[string]int{"5b0c8f3e-2a1d-4c6b-9e7f-3d2a1b0c9e8f": 1}},
+			method:      http.MethodGet,
+			path:        "/api/auth/profile",
+			wantCode:    http.StatusPreconditionRequired,
+			wantErrCode: "TOS_REACCEPTANCE_REQUIRED",
+		},
+		{
+			name:     "accept route is exempt",
+			checker:  &tosVersions{current: 2, accepted: map[string]int{"5b0c8f3e-2a1d-4c6b-9e7f-3d2a1b0c9e8f": 1}},
+			method:   http.MethodPost,
+			path:     tosAcceptPath,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "database timeout",
+			checker:  &tosVersions{err: repository.ErrTimeout},
+			method:   http.MethodGet,
+			path:     "/api/auth/profile",
+			wantCode: http.StatusGatewayTimeout,
+		},
+		{
+			name:         "database error",
+			checker:      &tosVersions{err: errors.New("connection reset")},
+			method:       http.MethodGet,
+			path:         "/api/auth/profile",
+			wantCode:     http.StatusInternalServerError,
+			wantAttached: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attached []string
+			router := setupTOSTest(tt.checker, &attached)
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("X-User", "5b0c8f3e-2a1d-4c6b-9e7f-3d2a1b0c9e8f")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Equal(t, tt.wantAttached, len(attached) > 0)
+			if tt.wantErrCode != "" {
+				var res map[string]interface{}
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+				assert.Equal(t, tt.wantErrCode, res["code"])
+			}
+		})
+	}
+}
+
+func TestRequireTOS_VersionBump(t *testing.T) {
+	checker := &tosVersions{current: 1, accepted: map[string]int{"5b0c8f3e-2a1d-4c6b-9e7f-3d2a1b0c9e8f": 1}}
+	var attached []string
+	router := setupTOSTest(checker, &attached)
+	serve := func(method, path string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("X-User", "5b0c8f3e-2a1d-4c6b-9e7f-3d2a1b0c9e8f")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/api/auth/profile"))
+
+	checker.current = 2
+	assert.Equal(t, http.StatusPreconditionRequired, serve(http.MethodGet, "/api/auth/profile"),
+		"a new version must be accepted again")
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost, tosAcceptPath))
+
+	checker.accepted["5b0c8f3e-2a1d-4c6b-9e7f-3d2a1b0c9e8f"] = 2
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/api/auth/profile"))
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/apierror"
+	"github.com/gin-gonic/gin"
+)
+
+// Causes of a 503 Service Unavailable response, sent as its error code.
+const (
+	CauseShuttingDown = "SHUTTING_DOWN"
+	CauseMaintenance  = "MAINTENANCE"
+	CauseOverloaded   = "OVERLOADED"
+)
+
+// unavailableMessages are the error messages of each cause.
+var unavailableMessages = map[string]string{
+	CauseShuttingDown: "server is shutting down, try again later",
+	CauseMaintenance:  "service is under maintenance",
+	CauseOverloaded:   "server is overloaded, try again later",
+}
+
+// Unavailable writes the 503 Service Unavailable responses of every
+// middleware that turns requests away, so clients get the same signal
+// whatever the cause: the cause as the error code and a "Retry-After"
+// header telling them when to try again. It is safe for concurrent use.
+type Unavailable struct {
+	retryAfter map[string]string
+}
+
+// NewUnavailable creates an Unavailable that asks clients to retry after
+// retryAfter of the cause, keyed by cause, rounded up to whole seconds.
+// Causes without a delay get a "Retry-After" of 1 second.
+func NewUnavailable(retryAfter map[string]time.Duration) *Unavailable {
+	u := &Unavailable{retryAfter: make(map[string]string, len(retryAfter))}
+	for cause, d := range retryAfter {
+		u.retryAfter[cause] = strconv.Itoa(max(1, int(math.Ceil(d.Seconds()))))
+	}
+	return u
+}
+
+// Respond rejects the request with a 503 status for cause and aborts it. If
+// part of the response was already written, for example because a handler
+// failed partway through streaming it, the response is left as it is and the
+// request is only aborted. Requests turned away because the server is
+// shutting down also get "Connection: close", so that clients reconnect,
+// likely to another instance, instead of reusing the connection.
+func (u *Unavailable) Respond(c *gin.Context, cause string) {
+	c.Abort()
+	if c.Writer.Written() {
+		return
+	}
+
+	retryAfter, ok := u.retryAfter[cause]
+	if !ok {
+		retryAfter = "1"
+	}
+	c.Header("Retry-After", retryAfter)
+	if cause == CauseShuttingDown {
+		c.Header("Connection", "close")
+	}
+	apierror.RespondCode(c, http.StatusServiceUnavailable, cause, unavailableMessages[cause])
+}
@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnavailable_Respond(t *testing.T) {
+	unavailable := NewUnavailable(map[string]time.Duration{
+		CauseShuttingDown: 5 * time.Second,
+		CauseMaintenance:  10 * time.Minute,
+		CauseOverloaded:   1500 * time.Millisecond,
+	})
+
+	tests := []struct {
+		name           string
+		unavailable    *Unavailable
+		cause          string
+		wantRetryAfter string
+		wantConnection string
+		wantBody       string
+	}{
+		{
+			name:           "shutting down",
+			unavailable:    unavailable,
+			cause:          CauseShuttingDown,
+			wantRetryAfter: "5",
+			wantConnection: "close",
+			wantBody:       `{"code":"SHUTTING_DOWN","error":"server is shutting down, try again later"}`,
+		},
+		{
+			name:           "maintenance",
+			unavailable:    unavailable,
+			cause:          CauseMaintenance,
+			wantRetryAfter: "600",
+			wantBody:       `{"code":"MAINTENANCE","error":"service is under maintenance"}`,
+		},
+		{
+			name:           "overloaded rounds up",
+			unavailable:    unavailable,
+			cause:          CauseOverloaded,
+			wantRetryAfter: "2",
+			wantBody:       `{"code":"OVERLOADED","error":"server is overloaded, try again later"}`,
+		},
+		{
+			name:           "no delay configured",
+			unavailable:    NewUnavailable(nil),
+			cause:          CauseMaintenance,
+			wantRetryAfter: "1",
+			wantBody:       `{"code":"MAINTENANCE","error":"service is under maintenance"}`,
+		},
+		{
+			name:           "delay below a second",
+			unavailable:    NewUnavailable(map[string]time.Duration{CauseOverloaded: time.Millisecond}),
+			cause:          CauseOverloaded,
+			wantRetryAfter: "1",
+			wantBody:       `{"code":"OVERLOADED","error":"server is overloaded, try again later"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			handlerRan := false
+			router := gin.New()
+			router.GET("/test", func(c *gin.Context) {
+				tt.unavailable.Respond(c, tt.cause)
+			}, func(c *gin.Context) {
+				handlerRan = true
+			})
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test", nil))
+
+			assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+			assert.Equal(t, tt.wantRetryAfter, w.Header().Get("Retry-After"))
+			assert.Equal(t, tt.wantConnection, w.Header().Get("Connection"))
+			assert.JSONEq(t, tt.wantBody, w.Body.String())
+			assert.False(t, handlerRan, "the request was not aborted")
+		})
+	}
+}
+
+func TestUnavailable_RespondAfterResponseStarted(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	unavailable := NewUnavailable(nil)
+	router := gin.New()
+	router.GET("/test", func(c *gin.Context) {
+		c.String(http.StatusOK, "partial")
+		unavailable.Respond(c, CauseShuttingDown)
+		assert.True(t, c.IsAborted())
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "partial", w.Body.String())
+	assert.Empty(t, w.Header().Get("Retry-After"))
+	assert.Empty(t, w.Header().Get("Connection"))
+}
@@ -0,0 +1,36 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Severities of an announcement, from the least to the most urgent.
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Announcement represents a notice shown to every user of the app, such as
+// upcoming maintenance, while the time is within its window. Admins manage
+// announcements at runtime, without a deploy.
+//
+// Fields:
+//   - ID: A unique identifier for the announcement, generated automatically.
+//   - Message: The text shown to users.
+//   - Severity: How urgent the notice is, one of SeverityInfo, SeverityWarning or SeverityCritical.
+//   - StartsAt: The time from which the announcement is shown.
+//   - EndsAt: The time from which the announcement is no longer shown, after StartsAt.
+//   - CreatedAt: The timestamp when the announcement was created.
+//   - UpdatedAt: The timestamp when the announcement was last changed.
+type Announcement struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	Message   string    `gorm:"type:text;not null" json:"message"`
+	Severity  string    `gorm:"type:varchar(16);not null" json:"severity"`
+	StartsAt  time.Time `gorm:"not null;index" json:"starts_at"`
+	EndsAt    time.Time `gorm:"not null;index" json:"ends_at"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,38 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditEntry records a request made by an actor on behalf of, or about, a user.
+//
+// Fields:
+//   - ID: A unique identifier for the entry, generated automatically.
+//   - ActorID: The ID of the user who made the request, such as an admin impersonating another user, or uuid.Nil if they were not signed in, as when requesting account recovery, or the entry was recorded by a background job.
+//   - SubjectID: The ID of the user the request was made as, or nil if it was not made as another user.
+//   - Method: The HTTP method of the request, or EXPIRE for a role grant ended by the expiry job.
+//   - Path: The URL path of the request.
+//   - Status: The HTTP status code of the response.
+//   - IPAddress: The client IP address the request came from.
+//   - LatencyMS: How long the request took to handle, in milliseconds.
+//   - RequestBody: The JSON body of the request with sensitive fields redacted, or nil if it had none or it was not recorded.
+//   - CreatedAt: The timestamp of the request.
+type AuditEntry struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	ActorID     uuid.UUID  `gorm:"type:uuid;not null;index:idx_audit_log_actor_created,priority:1" json:"actor_id"`
+	SubjectID   *uuid.UUID `gorm:"type:uuid;index" json:"subject_id"`
+	Method      string     `gorm:"type:varchar(16);not null" json:"method"`
+	Path        string     `gorm:"type:text;not null" json:"path"`
+	Status      int        `gorm:"not null" json:"status"`
+	IPAddress   string     `gorm:"type:varchar(45);not null;default:''" json:"ip_address"`
+	LatencyMS   int64      `gorm:"not null" json:"latency_ms"`
+	RequestBody *string    `gorm:"type:text" json:"request_body,omitempty"`
+	CreatedAt   time.Time  `gorm:"index:idx_audit_log_actor_created,priority:2;index" json:"created_at"`
+}
+
+// TableName stores audit entries in the "audit_log" table.
+func (AuditEntry) TableName() string {
+	return "audit_log"
+}
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EmailChangeRequest represents a pending change of a user's email address.
+// The change only takes effect once the confirmation token sent to the new
+// address is presented; until then the user keeps logging in with the old one.
+// A user has at most one pending request, and requesting another change
+// replaces it. Only a hash of the token is stored.
+//
+// Fields:
+//   - ID: A unique identifier for the request, generated automatically.
+//   - UserID: The ID of the user whose email is being changed; unique, so each user has at most one request.
+//   - NewEmail: The address the user wants to change to.
+//   - TokenHash: The SHA-256 hash of the confirmation token, hex encoded.
+//   - ExpiresAt: The time after which the confirmation token can no longer be used.
+//   - CreatedAt: The timestamp when the change was requested.
+type EmailChangeRequest struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex"`
+	NewEmail  string    `gorm:"type:varchar(255);not null"`
+	TokenHash string    `gorm:"type:varchar(64);uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+}
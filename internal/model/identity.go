@@ -0,0 +1,27 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Identity represents an account at an OAuth provider linked to a user. A
+// provider account can be linked to only one user, and a user can link only
+// one account of each provider.
+//
+// Fields:
+//   - ID: A unique identifier for the identity, generated automatically.
+//   - UserID: The ID of the user the provider account is linked to.
+//   - Provider: The name of the provider, such as "google".
+//   - ProviderUserID: The ID the provider gives the account.
+//   - EmailAtLinkTime: The email of the provider account when it was linked, which may have changed since.
+//   - CreatedAt: The timestamp when the account was linked.
+type Identity struct {
+	ID              uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID          uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_identities_user_provider,priority:1" json:"-"`
+	Provider        string    `gorm:"type:varchar(32);not null;uniqueIndex:idx_identities_user_provider,priority:2;uniqueIndex:idx_identities_provider_account,priority:1" json:"provider"`
+	ProviderUserID  string    `gorm:"type:varchar(255);not null;uniqueIndex:idx_identities_provider_account,priority:2" json:"provider_user_id"`
+	EmailAtLinkTime string    `gorm:"type:varchar(255);not null;default:''" json:"email_at_link_time"`
+	CreatedAt       time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
@@ -0,0 +1,28 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LoginAlert represents the email sent to a user about a login from a device
+// they had not used before. The email carries a token that lets the user
+// report the login if it was not them, which revokes the session it started
+// and makes them choose a new password. Only a hash of the token is stored.
+//
+// Fields:
+//   - ID: A unique identifier for the alert, generated automatically.
+//   - UserID: The ID of the user who was alerted.
+//   - FamilyID: The ID of the refresh token family of the session the login started.
+//   - TokenHash: The SHA-256 hash of the report token, hex encoded.
+//   - ExpiresAt: The time after which the report token can no longer be used.
+//   - CreatedAt: The timestamp when the alert was sent.
+type LoginAlert struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index"`
+	FamilyID  uuid.UUID `gorm:"type:uuid;not null"`
+	TokenHash string    `gorm:"type:varchar(64);uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+}
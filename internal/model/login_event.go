@@ -0,0 +1,27 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LoginEvent represents a single login attempt, successful or not.
+//
+// Fields:
+//   - ID: A unique identifier for the event, generated automatically.
+//   - UserID: The ID of the user the attempt was for, or nil if the identifier did not match any user.
+//   - Email: The email address, or the username, the attempt was made with.
+//   - Success: Whether the attempt succeeded.
+//   - IPAddress: The client IP address the attempt came from.
+//   - UserAgent: The User-Agent header sent with the attempt.
+//   - CreatedAt: The timestamp of the attempt.
+type LoginEvent struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID    *uuid.UUID `gorm:"type:uuid;index:idx_login_events_user_created,priority:1" json:"user_id"`
+	Email     string     `gorm:"type:varchar(255);not null" json:"email"`
+	Success   bool       `gorm:"not null" json:"success"`
+	IPAddress string     `gorm:"type:varchar(45)" json:"ip_address"`
+	UserAgent string     `gorm:"type:text" json:"user_agent"`
+	CreatedAt time.Time  `gorm:"index:idx_login_events_user_created,priority:2" json:"created_at"`
+}
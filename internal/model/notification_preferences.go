@@ -0,0 +1,49 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// NotificationPreferences are the kinds of email a user agreed to receive.
+//
+// Fields:
+//   - SecurityAlerts: Emails about the security of the account, such as logins from new devices.
+//     They cannot be turned off, so this is always true.
+//   - ProductUpdates: Announcements of new features and changes to the service.
+//   - Newsletter: The periodic newsletter, which can also be turned off from the link in every issue.
+type NotificationPreferences struct {
+	SecurityAlerts bool `json:"security_alerts"`
+	ProductUpdates bool `json:"product_updates"`
+	Newsletter     bool `json:"newsletter"`
+}
+
+// DefaultNotificationPreferences returns the preferences of a user who never
+// changed them: security alerts only.
+func DefaultNotificationPreferences() NotificationPreferences {
+	return NotificationPreferences{SecurityAlerts: true}
+}
+
+// Value stores the preferences as a JSON object.
+func (p NotificationPreferences) Value() (driver.Value, error) {
+	encoded, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return string(encoded), nil
+}
+
+// Scan reads preferences stored by Value.
+func (p *NotificationPreferences) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into NotificationPreferences", value)
+	}
+	return json.Unmarshal(data, p)
+}
@@ -0,0 +1,40 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Outbox event types.
+const (
+	EventUserRegistered = "user.registered"
+)
+
+// OutboxEvent represents an event waiting to be published to external systems.
+// It is written in the same transaction as the change it describes, so an event
+// exists if and only if the change was committed, and is published afterwards
+// by a background poller.
+//
+// Fields:
+//   - ID: A unique identifier for the event, generated automatically.
+//   - EventType: The kind of event, such as EventUserRegistered.
+//   - Payload: The JSON-encoded event body.
+//   - Attempts: The number of failed attempts to publish the event.
+//   - LastError: The error from the most recent failed attempt, if any.
+//   - NextAttemptAt: The earliest time the event is published, or retried after a failure.
+//   - PublishedAt: The timestamp the event was published, or nil if it has not been yet.
+//   - DeadAt: The timestamp the event was dead-lettered after failing too many times, or nil.
+//   - CreatedAt: The timestamp when the event was created.
+type OutboxEvent struct {
+	ID            uuid.UUID       `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	EventType     string          `gorm:"type:varchar(64);not null" json:"event_type"`
+	Payload       json.RawMessage `gorm:"type:jsonb;not null" json:"payload"`
+	Attempts      int             `gorm:"not null;default:0" json:"attempts"`
+	LastError     string          `gorm:"type:text" json:"last_error,omitempty"`
+	NextAttemptAt time.Time       `gorm:"not null;default:CURRENT_TIMESTAMP;index" json:"next_attempt_at"`
+	PublishedAt   *time.Time      `gorm:"index" json:"published_at"`
+	DeadAt        *time.Time      `json:"dead_at,omitempty"`
+	CreatedAt     time.Time       `gorm:"index" json:"created_at"`
+}
@@ -0,0 +1,51 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Queued email statuses.
+const (
+	// EmailPending is the status of an email waiting to be sent or retried.
+	EmailPending = "pending"
+	// EmailDead is the status of an email that failed too many times and is
+	// only retried when an admin asks for it.
+	EmailDead = "dead"
+)
+
+// QueuedEmail represents an email waiting to be sent by the background email
+// worker. Emails are deleted once sent, since their bodies carry single-use
+// tokens such as verification links.
+//
+// Fields:
+//   - ID: A unique identifier for the email, generated automatically.
+//   - Recipient: The address the email is sent to.
+//   - Subject: The subject of the email.
+//   - HTMLBody: The HTML body of the email.
+//   - TextBody: The plain text body of the email.
+//   - Status: EmailPending or EmailDead.
+//   - Attempts: The number of failed attempts to send the email.
+//   - LastError: The error from the most recent failed attempt, if any.
+//   - NextAttemptAt: The earliest time the email is sent, or retried after a failure.
+//   - CreatedAt: The timestamp when the email was queued.
+//   - UpdatedAt: The timestamp when the email was last updated.
+type QueuedEmail struct {
+	ID            uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	Recipient     string    `gorm:"type:varchar(255);not null" json:"recipient"`
+	Subject       string    `gorm:"type:text;not null" json:"subject"`
+	HTMLBody      string    `gorm:"type:text;not null" json:"-"`
+	TextBody      string    `gorm:"type:text;not null" json:"-"`
+	Status        string    `gorm:"type:varchar(16);not null;default:pending;index:idx_email_queue_status_next,priority:1" json:"status"`
+	Attempts      int       `gorm:"not null;default:0" json:"attempts"`
+	LastError     string    `gorm:"type:text" json:"last_error,omitempty"`
+	NextAttemptAt time.Time `gorm:"not null;index:idx_email_queue_status_next,priority:2" json:"next_attempt_at"`
+	CreatedAt     time.Time `gorm:"index" json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// TableName stores queued emails in the "email_queue" table.
+func (QueuedEmail) TableName() string {
+	return "email_queue"
+}
@@ -0,0 +1,53 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// States of a recovery request. A request starts pending, is then approved or
+// denied by an admin, and an approved request is completed once its recovery
+// link is used.
+const (
+	RecoveryPending   = "pending"
+	RecoveryApproved  = "approved"
+	RecoveryDenied    = "denied"
+	RecoveryCompleted = "completed"
+)
+
+// RecoveryRequest represents a request to recover an account by someone who
+// has lost access to both its password and its mailbox. An admin reviews the
+// evidence and, if satisfied, approves the request with a new email address,
+// to which a one-time recovery link is sent. Only a hash of the link's token
+// is stored.
+//
+// Fields:
+//   - ID: A unique identifier for the request, generated automatically.
+//   - UserID: The ID of the account the request claims.
+//   - Email: The email address the requester claimed the account by.
+//   - Evidence: Free text the requester offered to prove they own the account.
+//   - Status: The state of the request, one of RecoveryPending, RecoveryApproved, RecoveryDenied or RecoveryCompleted.
+//   - ReviewerID: The ID of the admin who approved or denied the request, or nil while it is pending.
+//   - ReviewedAt: The time the request was approved or denied, or nil while it is pending.
+//   - NewEmail: The address the account moves to, set by the approving admin.
+//   - TokenHash: The SHA-256 hash of the recovery token, hex encoded, or nil when no link is usable.
+//   - ExpiresAt: The time after which the recovery link can no longer be used, or nil before approval.
+//   - CompletedAt: The time the recovery link was used, or nil if it has not been.
+//   - CreatedAt: The timestamp when the request was made.
+//   - UpdatedAt: The timestamp when the request last changed state.
+type RecoveryRequest struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	Email       string     `gorm:"type:varchar(255);not null" json:"email"`
+	Evidence    string     `gorm:"type:text;not null" json:"evidence"`
+	Status      string     `gorm:"type:varchar(16);not null;default:pending;index" json:"status"`
+	ReviewerID  *uuid.UUID `gorm:"type:uuid" json:"reviewer_id"`
+	ReviewedAt  *time.Time `json:"reviewed_at"`
+	NewEmail    *string    `gorm:"type:varchar(255)" json:"new_email"`
+	TokenHash   *string    `gorm:"type:varchar(64);uniqueIndex" json:"-"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+	CreatedAt   time.Time  `gorm:"index" json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
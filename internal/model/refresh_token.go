@@ -0,0 +1,48 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RefreshToken represents an issued refresh token.
+// Only a hash of the token is stored; the token itself is handed to the client once.
+//
+// Every login starts a new token family, and each refresh rotates the presented
+// token into a child in the same family. Presenting a token that was already
+// rotated means it was replayed, so the whole family is revoked.
+//
+// Fields:
+//   - ID: A unique identifier for the token, generated automatically.
+//   - UserID: The ID of the user the token was issued to.
+//   - FamilyID: The ID shared by every token descending from the same login.
+//   - ParentID: The ID of the token this one was rotated from, or nil for the first token of a family.
+//   - TokenHash: The SHA-256 hash of the token, hex encoded.
+//   - ExpiresAt: The time after which the token can no longer be used.
+//   - RotatedAt: The time the token was exchanged for a child, or nil if it is still current.
+//   - RevokedAt: The time the token's family was revoked, or nil if it is still valid.
+//   - DeviceLabel: A description of the client that logged in, such as "Chrome on macOS".
+//   - Name: The name the user gave the session, or empty if they did not rename it.
+//   - IPAddress: The network the login came from, with the host part of the address zeroed.
+//   - Location: The approximate location of IPAddress. It stays empty until a geolocation source is configured.
+//   - DeviceHash: The device.Fingerprint of the login, for recognizing devices the user logged in from before.
+//   - CreatedAt: The timestamp when the token was issued.
+type RefreshToken struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;index"`
+	FamilyID  uuid.UUID  `gorm:"type:uuid;not null;index"`
+	ParentID  *uuid.UUID `gorm:"type:uuid"`
+	TokenHash string     `gorm:"type:varchar(64);uniqueIndex;not null"`
+	ExpiresAt time.Time  `gorm:"not null"`
+	RotatedAt *time.Time
+	RevokedAt *time.Time
+
+	DeviceLabel string `gorm:"type:varchar(64);not null;default:''"`
+	Name        string `gorm:"type:varchar(64);not null;default:''"`
+	IPAddress   string `gorm:"type:varchar(45);not null;default:''"`
+	Location    string `gorm:"type:varchar(100);not null;default:''"`
+	DeviceHash  string `gorm:"type:varchar(64);not null;default:'';index"`
+
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+}
@@ -0,0 +1,41 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RoleGrant gives a user a role on top of their own for a limited time, such
+// as admin access for an afternoon. A grant is active from its creation until
+// it expires or is revoked, whichever comes first.
+//
+// Fields:
+//   - ID: A unique identifier for the grant, generated automatically.
+//   - UserID: The ID of the user the role is granted to.
+//   - Role: The role granted, such as RoleAdmin.
+//   - Reason: Why the role was granted, for the audit trail.
+//   - GrantedBy: The ID of the admin who granted the role.
+//   - ExpiresAt: The time from which the grant is no longer active.
+//   - RevokedAt: The time an admin ended the grant early, or nil if it was not revoked.
+//   - RevokedBy: The ID of the admin who revoked the grant, or nil if it was not revoked.
+//   - EndedAt: The time the grant was revoked, or was found expired and the user demoted, or nil while it is still active.
+//   - CreatedAt: The timestamp when the grant was created.
+type RoleGrant struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;index:idx_role_grants_user_role,priority:1" json:"user_id"`
+	Role      string     `gorm:"type:varchar(16);not null;index:idx_role_grants_user_role,priority:2" json:"role"`
+	Reason    string     `gorm:"type:text;not null" json:"reason"`
+	GrantedBy uuid.UUID  `gorm:"type:uuid;not null" json:"granted_by"`
+	ExpiresAt time.Time  `gorm:"not null;index" json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	RevokedBy *uuid.UUID `gorm:"type:uuid" json:"revoked_by,omitempty"`
+	EndedAt   *time.Time `gorm:"index" json:"ended_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// ActiveAt reports whether the grant gives its role at t: it has not ended
+// and t is before it expires.
+func (g *RoleGrant) ActiveAt(t time.Time) bool {
+	return g.EndedAt == nil && t.Before(g.ExpiresAt)
+}
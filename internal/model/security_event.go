@@ -0,0 +1,36 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+// Types of SecurityEvent.
+const (
+	SecurityEventPasswordChanged  = "password_changed"
+	SecurityEventEmailChanged     = "email_changed"
+	SecurityEventAccountRecovered = "account_recovered"
+	SecurityEventNewDeviceLogin   = "new_device_login"
+	SecurityEventSessionRevoked   = "session_revoked"
+)
+
+// SecurityEvent is a change to how a user's account is secured, or a sign of
+// someone else using it, as shown to the user in their security events feed.
+// It is not stored in a table of its own but read from the audit log, login
+// alerts and refresh tokens.
+//
+// Fields:
+//   - ID: The ID of the record the event was read from, or of the session's token family for session_revoked.
+//   - Type: What happened, one of the SecurityEvent* constants.
+//   - OccurredAt: When it happened.
+//   - IPAddress: The client IP address it came from, or empty if it is not known.
+//   - Metadata: Details that depend on Type, such as the device of a new_device_login.
+type SecurityEvent struct {
+	ID         uuid.UUID      `json:"id"`
+	Type       string         `json:"type"`
+	OccurredAt time.Time      `json:"occurred_at"`
+	IPAddress  string         `json:"ip_address"`
+	Metadata   datatypes.JSON `json:"metadata"`
+}
@@ -5,6 +5,13 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+// Roles that can be assigned to a user.
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
 )
 
 // User represents a user in the system.
@@ -15,13 +22,78 @@ import (
 //   - Email: The user's email address, which must be unique and not null.
 //   - PasswordHash: A hashed version of the user's password, which is required and not exposed in JSON responses.
 //   - FullName: The user's full name, which is required.
+//   - Role: The user's role, either RoleUser or RoleAdmin, defaulting to RoleUser.
+//   - LastLoginAt: The timestamp of the user's last successful login, or nil if they never logged in.
+//   - DeletionRequestedAt: The time the user asked for their account to be erased, or nil if they did not.
+//     The account is purged once the grace period has passed, unless the user logs in again first.
+//   - Metadata: A small JSON object of client preferences such as theme or locale, or nil if none were set.
 //   - CreatedAt: The timestamp when the user was created, with a default value of the current timestamp.
 //   - UpdatedAt: The timestamp when the user was last updated, with a default value of the current timestamp.
+//   - AvatarURL: The URL of the user's uploaded avatar image, or nil if they have not uploaded one.
+//   - Username: An optional handle the user can log in with instead of their email, stored in lowercase
+//     so that uniqueness is case-insensitive, or nil if they have not chosen one.
+//   - TokenVersion: A counter embedded in every access token issued to the user. Incrementing it
+//     invalidates all of the user's outstanding access tokens at once.
+//   - TOSAcceptedVersion: The latest version of the terms of service the user accepted, or 0 if none.
+//   - TOSAcceptedAt: When the user accepted TOSAcceptedVersion, kept as proof of consent, or nil if never.
+//   - NotificationPreferences: The kinds of email the user agreed to receive, or nil if they never
+//     changed them, in which case DefaultNotificationPreferences apply.
+//   - Locale: The BCP 47 language tag, such as "en-US", that clients render text and timestamps for.
+//   - Timezone: The IANA time zone name, such as "Asia/Bangkok", that clients render timestamps in.
+//   - PasswordChangedAt: When the password was last set, by registering or changing or resetting it,
+//     from which its expiry is counted. Users who predate it count from when the column was added.
 type User struct {
-	ID           uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id" validate:"required"`
-	Email        string    `gorm:"type:varchar(255);uniqueIndex;not null" json:"email" validate:"required,email"`
-	PasswordHash string    `gorm:"type:varchar(255);not null" json:"-" validate:"required"`
-	FullName     string    `gorm:"type:varchar(255);not null" json:"full_name" validate:"required"`
-	CreatedAt    time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
-	UpdatedAt    time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+	ID                      uuid.UUID                `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id" validate:"required"`
+	Email                   string                   `gorm:"type:varchar(255);uniqueIndex;not null" json:"email" validate:"required,email"`
+	PasswordHash            string                   `gorm:"type:varchar(255);not null" json:"-" validate:"required"`
+	FullName                string                   `gorm:"type:varchar(255);not null" json:"full_name" validate:"required"`
+	Role                    string                   `gorm:"type:varchar(32);not null;default:user" json:"role"`
+	LastLoginAt             *time.Time               `json:"last_login_at"`
+	DeletionRequestedAt     *time.Time               `gorm:"index" json:"-"`
+	Metadata                datatypes.JSON           `gorm:"type:jsonb" json:"-"`
+	CreatedAt               time.Time                `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt               time.Time                `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+	AvatarURL               *string                  `gorm:"type:varchar(512)" json:"-"`
+	Username                *string                  `gorm:"type:varchar(30);uniqueIndex" json:"-"`
+	TokenVersion            int                      `gorm:"not null;default:0" json:"-"`
+	TOSAcceptedVersion      int                      `gorm:"not null;default:0" json:"-"`
+	TOSAcceptedAt           *time.Time               `json:"-"`
+	NotificationPreferences *NotificationPreferences `gorm:"type:jsonb" json:"-"`
+	Locale                  string                   `gorm:"type:varchar(35);not null;default:en" json:"-"`
+	Timezone                string                   `gorm:"type:varchar(64);not null;default:UTC" json:"-"`
+	PasswordChangedAt       time.Time                `gorm:"not null;default:CURRENT_TIMESTAMP" json:"-"`
+}
+
+// Clone returns a deep copy of the user, so the copy can be modified without
+// affecting the original.
+func (u *User) Clone() *User {
+	clone := *u
+	if u.LastLoginAt != nil {
+		lastLoginAt := *u.LastLoginAt
+		clone.LastLoginAt = &lastLoginAt
+	}
+	if u.DeletionRequestedAt != nil {
+		deletionRequestedAt := *u.DeletionRequestedAt
+		clone.DeletionRequestedAt = &deletionRequestedAt
+	}
+	if u.AvatarURL != nil {
+		avatarURL := *u.AvatarURL
+		clone.AvatarURL = &avatarURL
+	}
+	if u.Username != nil {
+		username := *u.Username
+		clone.Username = &username
+	}
+	if u.TOSAcceptedAt != nil {
+		tosAcceptedAt := *u.TOSAcceptedAt
+		clone.TOSAcceptedAt = &tosAcceptedAt
+	}
+	if u.NotificationPreferences != nil {
+		preferences := *u.NotificationPreferences
+		clone.NotificationPreferences = &preferences
+	}
+	if u.Metadata != nil {
+		clone.Metadata = append(datatypes.JSON(nil), u.Metadata...)
+	}
+	return &clone
 }
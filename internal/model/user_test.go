@@ -8,6 +8,7 @@ import (
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 const (
@@ -164,7 +165,83 @@ func TestUser_JSONSerialization(t *testing.T) {
 		assert.Equal(t, user.ID, unmarshaled.ID)
 		assert.Equal(t, user.Email, unmarshaled.Email)
 		assert.Equal(t, user.FullName, unmarshaled.FullName)
-		assert.Equal(t, user.CreatedAt.Truncate(time.Microsecond), unmarshaled.CreatedAt)
-		assert.Equal(t, user.UpdatedAt.Truncate(time.Microsecond), unmarshaled.UpdatedAt)
+		assert.True(t, user.CreatedAt.Equal(unmarshaled.CreatedAt))
+		assert.True(t, user.UpdatedAt.Equal(unmarshaled.UpdatedAt))
 	})
+
+	t.Run("last login is null until first login", func(t *testing.T) {
+		jsonData, err := json.Marshal(user)
+		assert.NoError(t, err)
+
+		var raw map[string]interface{}
+		err = json.Unmarshal(jsonData, &raw)
+		assert.NoError(t, err)
+
+		value, exists := raw["last_login_at"]
+		assert.True(t, exists)
+		assert.Nil(t, value)
+	})
+
+	t.Run("last login is serialized once set", func(t *testing.T) {
+		loginAt := time.Now()
+		loggedIn := user
+		loggedIn.LastLoginAt = &loginAt
+
+		jsonData, err := json.Marshal(loggedIn)
+		assert.NoError(t, err)
+
+		var unmarshaled User
+		err = json.Unmarshal(jsonData, &unmarshaled)
+		assert.NoError(t, err)
+
+		assert.NotNil(t, unmarshaled.LastLoginAt)
+		assert.True(t, loginAt.Equal(*unmarshaled.LastLoginAt))
+	})
+}
+
+func TestUser_Clone(t *testing.T) {
+	lastLoginAt := time.Now()
+	avatarURL := "/avatars/old.png"
+	username := "tester"
+	tosAcceptedAt := lastLoginAt.Add(-time.Hour)
+	preferences := NotificationPreferences{SecurityAlerts: true, Newsletter: true}
+	user := &User{ID: uuid.New(), Email: "test@example.com", LastLoginAt: &lastLoginAt, Metadata: []byte(`{"theme":"dark"}`), AvatarURL: &avatarURL, Username: &username, TOSAcceptedVersion: 1, TOSAcceptedAt: &tosAcceptedAt, NotificationPreferences: &preferences}
+
+	clone := user.Clone()
+
+	assert.Equal(t, user, clone)
+	assert.NotSame(t, user, clone)
+	assert.NotSame(t, user.LastLoginAt, clone.LastLoginAt)
+
+	clone.Email = "changed@example.com"
+	*clone.LastLoginAt = lastLoginAt.Add(time.Hour)
+	clone.Metadata[2] = 'T'
+	*clone.AvatarURL = "/avatars/new.png"
+	*clone.Username = "changed"
+	*clone.TOSAcceptedAt = lastLoginAt
+	clone.NotificationPreferences.Newsletter = false
+	assert.Equal(t, "test@example.com", user.Email)
+	assert.True(t, user.LastLoginAt.Equal(lastLoginAt))
+	assert.JSONEq(t, `{"theme":"dark"}`, string(user.Metadata))
+	assert.Equal(t, "/avatars/old.png", *user.AvatarURL)
+	assert.Equal(t, "tester", *user.Username)
+	assert.True(t, user.TOSAcceptedAt.Equal(tosAcceptedAt))
+	assert.True(t, user.NotificationPreferences.Newsletter)
+}
+
+func TestNotificationPreferences_ValueScan(t *testing.T) {
+	preferences := NotificationPreferences{SecurityAlerts: true, ProductUpdates: true}
+
+	value, err := preferences.Value()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"security_alerts":true,"product_updates":true,"newsletter":false}`, value.(string))
+
+	for _, stored := range []interface{}{value, []byte(value.(string))} {
+		var scanned NotificationPreferences
+		require.NoError(t, scanned.Scan(stored))
+		assert.Equal(t, preferences, scanned)
+	}
+
+	var scanned NotificationPreferences
+	assert.Error(t, scanned.Scan(42))
 }
@@ -0,0 +1,208 @@
+// Package outbox publishes events recorded in the transactional outbox.
+//
+// Events are written to the outbox_events table in the same transaction as the
+// change they describe. A Poller later reads unpublished events, hands them to a
+// Sink and marks them as published. Because an event is only marked after the
+// sink accepts it, a crash between publishing and marking causes the event to be
+// published again: delivery is at least once, and consumers must tolerate duplicates.
+//
+// An event the sink rejects is retried with exponential backoff, so it does not
+// hold up the events behind it, and after MaxAttempts failures it is
+// dead-lettered: it stays in the table but is no longer published.
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/google/uuid"
+)
+
+// Defaults for a Poller.
+const (
+	// DefaultBatchSize is the number of events a Poller dispatches per poll.
+	DefaultBatchSize = 100
+	// MaxBackoff caps the delay before an event is retried.
+	MaxBackoff = 6 * time.Hour
+)
+
+// Store is the persistence a Poller needs.
+type Store interface {
+	FetchUnpublished(ctx context.Context, now time.Time, limit int) ([]model.OutboxEvent, error)
+	MarkPublished(ctx context.Context, id uuid.UUID, at time.Time) error
+	MarkFailed(ctx context.Context, id uuid.UUID, reason string, nextAttemptAt time.Time) error
+	MarkDead(ctx context.Context, id uuid.UUID, reason string, at time.Time) error
+	DeletePublishedBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Sink delivers an event to an external system.
+type Sink interface {
+	Publish(ctx context.Context, event model.OutboxEvent) error
+}
+
+// Stats counts the outcomes of the attempts a Poller made since it was created.
+type Stats struct {
+	// Published is the number of events the sink accepted.
+	Published uint64 `json:"published"`
+	// Failed is the number of attempts the sink rejected, including those that
+	// dead-lettered an event.
+	Failed uint64 `json:"failed"`
+	// DeadLettered is the number of events that failed MaxAttempts times.
+	DeadLettered uint64 `json:"dead_lettered"`
+}
+
+// Backlog is what a Poller left to publish at the end of its last poll. It is
+// recorded by the poll, so reading it does not query the store.
+type Backlog struct {
+	// Pending is the number of due events the last poll fetched but did not
+	// publish; those that failed are retried later.
+	Pending int `json:"pending"`
+	// More reports whether the last poll fetched a full batch, in which case
+	// more events may be waiting than Pending counts.
+	More bool `json:"more"`
+	// PolledAt is when the last poll ended.
+	PolledAt time.Time `json:"polled_at"`
+}
+
+// Poller periodically dispatches unpublished outbox events to a Sink and
+// deletes published events older than the retention window.
+type Poller struct {
+	store       Store
+	sink        Sink
+	interval    time.Duration
+	retention   time.Duration
+	maxAttempts int
+	backoff     time.Duration
+	batchSize   int
+	now         func() time.Time
+
+	published, failed, deadLettered atomic.Uint64
+	backlog                         atomic.Pointer[Backlog]
+}
+
+// NewPoller creates a Poller that polls store every interval and keeps
+// published events for retention before deleting them. An event the sink
+// rejects is retried after backoff, doubling after each further failure up to
+// MaxBackoff, and dead-lettered once it failed maxAttempts times.
+func NewPoller(store Store, sink Sink, interval, retention time.Duration, maxAttempts int, backoff time.Duration) *Poller {
+	return &Poller{
+		store:       store,
+		sink:        sink,
+		interval:    interval,
+		retention:   retention,
+		maxAttempts: maxAttempts,
+		backoff:     backoff,
+		batchSize:   DefaultBatchSize,
+		now:         time.Now,
+	}
+}
+
+// Interval returns how often the Poller should run.
+func (p *Poller) Interval() time.Duration {
+	return p.interval
+}
+
+// Run dispatches one batch of unpublished events and then cleans up old ones,
+// as a job run every Interval. Both are attempted even if the first fails,
+// and their errors are returned together.
+func (p *Poller) Run(ctx context.Context) error {
+	var errs []error
+	if _, err := p.PollOnce(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to poll outbox: %w", err))
+	}
+	if _, err := p.Cleanup(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to clean up outbox: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// PollOnce dispatches one batch of due events and returns how many were published.
+// An event the sink rejects is marked as failed and retried after a backoff,
+// or dead-lettered once it failed maxAttempts times.
+func (p *Poller) PollOnce(ctx context.Context) (int, error) {
+	events, err := p.store.FetchUnpublished(ctx, p.now(), p.batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	defer func() {
+		p.backlog.Store(&Backlog{Pending: len(events) - published, More: len(events) == p.batchSize, PolledAt: p.now()})
+	}()
+	for _, event := range events {
+		if err := p.sink.Publish(ctx, event); err != nil {
+			if err := p.fail(ctx, event, err); err != nil {
+				return published, err
+			}
+			continue
+		}
+
+		p.published.Add(1)
+		if err := p.store.MarkPublished(ctx, event.ID, p.now()); err != nil {
+			return published, err
+		}
+		published++
+	}
+
+	return published, nil
+}
+
+// fail records that the sink rejected event with err, scheduling a retry or
+// dead-lettering the event.
+func (p *Poller) fail(ctx context.Context, event model.OutboxEvent, err error) error {
+	p.failed.Add(1)
+	attempts := event.Attempts + 1
+	if attempts >= p.maxAttempts {
+		p.deadLettered.Add(1)
+		slog.ErrorContext(ctx, "outbox event dead-lettered", "event_id", event.ID, "event_type", event.EventType, "attempts", attempts, "error", err)
+		return p.store.MarkDead(ctx, event.ID, err.Error(), p.now())
+	}
+
+	retryIn := p.Backoff(attempts)
+	slog.WarnContext(ctx, "failed to publish outbox event, retrying", "event_id", event.ID, "event_type", event.EventType, "attempts", attempts, "retry_in", retryIn, "error", err)
+	return p.store.MarkFailed(ctx, event.ID, err.Error(), p.now().Add(retryIn))
+}
+
+// Backoff returns how long to wait before retrying an event that failed
+// attempts times: the base backoff, doubled for each attempt after the first,
+// up to MaxBackoff.
+func (p *Poller) Backoff(attempts int) time.Duration {
+	delay := p.backoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= MaxBackoff {
+			return MaxBackoff
+		}
+	}
+	return min(delay, MaxBackoff)
+}
+
+// Stats returns the counts of the attempts the Poller made so far.
+func (p *Poller) Stats() Stats {
+	return Stats{
+		Published:    p.published.Load(),
+		Failed:       p.failed.Load(),
+		DeadLettered: p.deadLettered.Load(),
+	}
+}
+
+// Backlog returns what the last poll left to publish, and false if no poll
+// has fetched events yet.
+func (p *Poller) Backlog() (Backlog, bool) {
+	backlog := p.backlog.Load()
+	if backlog == nil {
+		return Backlog{}, false
+	}
+	return *backlog, true
+}
+
+// Cleanup deletes events published longer ago than the retention window and
+// returns how many were deleted.
+func (p *Poller) Cleanup(ctx context.Context) (int64, error) {
+	return p.store.DeletePublishedBefore(ctx, p.now().Add(-p.retention))
+}
@@ -0,0 +1,327 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore is an in-memory Store standing in for the outbox_events table.
+type memoryStore struct {
+	mu             sync.Mutex
+	events         []*model.OutboxEvent
+	markPublishErr error
+}
+
+func (s *memoryStore) insert(eventType string) *model.OutboxEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	event := &model.OutboxEvent{ID: uuid.New(), EventType: eventType, Payload: []byte(`{}`), NextAttemptAt: now, CreatedAt: now}
+	s.events = append(s.events, event)
+	return event
+}
+
+func (s *memoryStore) FetchUnpublished(_ context.Context, now time.Time, limit int) ([]model.OutboxEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []model.OutboxEvent
+	for _, event := range s.events {
+		due := event.PublishedAt == nil && event.DeadAt == nil && !event.NextAttemptAt.After(now)
+		if due && len(out) < limit {
+			out = append(out, *event)
+		}
+	}
+	return out, nil
+}
+
+func (s *memoryStore) MarkPublished(_ context.Context, id uuid.UUID, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.markPublishErr != nil {
+		return s.markPublishErr
+	}
+	for _, event := range s.events {
+		if event.ID == id {
+			event.PublishedAt = &at
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) MarkFailed(_ context.Context, id uuid.UUID, reason string, nextAttemptAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, event := range s.events {
+		if event.ID == id {
+			event.Attempts++
+			event.LastError = reason
+			event.NextAttemptAt = nextAttemptAt
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) MarkDead(_ context.Context, id uuid.UUID, reason string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, event := range s.events {
+		if event.ID == id {
+			event.Attempts++
+			event.LastError = reason
+			event.DeadAt = &at
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) DeletePublishedBefore(_ context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var kept []*model.OutboxEvent
+	var deleted int64
+	for _, event := range s.events {
+		if event.PublishedAt != nil && event.PublishedAt.Before(before) {
+			deleted++
+			continue
+		}
+		kept = append(kept, event)
+	}
+	s.events = kept
+	return deleted, nil
+}
+
+type recordingSink struct {
+	mu        sync.Mutex
+	published []uuid.UUID
+	err       error
+}
+
+func (s *recordingSink) Publish(_ context.Context, event model.OutboxEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.published = append(s.published, event.ID)
+	return nil
+}
+
+func TestPoller_PublishesEventsLeftByCrashedProcess(t *testing.T) {
+	store := &memoryStore{}
+	// The registration transaction committed, then the process died before
+	// anything was published: only the rows exist.
+	first := store.insert(model.EventUserRegistered)
+	second := store.insert(model.EventUserRegistered)
+
+	sink := &recordingSink{}
+	poller := NewPoller(store, sink, time.Second, time.Hour, 3, time.Minute)
+
+	published, err := poller.PollOnce(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, published)
+	assert.Equal(t, []uuid.UUID{first.ID, second.ID}, sink.published)
+	assert.NotNil(t, first.PublishedAt)
+	assert.NotNil(t, second.PublishedAt)
+
+	published, err = poller.PollOnce(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, published)
+}
+
+func TestPoller_RedeliversWhenMarkingFails(t *testing.T) {
+	store := &memoryStore{markPublishErr: errors.New("connection lost")}
+	event := store.insert(model.EventUserRegistered)
+	sink := &recordingSink{}
+	poller := NewPoller(store, sink, time.Second, time.Hour, 3, time.Minute)
+
+	_, err := poller.PollOnce(context.Background())
+	require.Error(t, err)
+
+	store.markPublishErr = nil
+	published, err := poller.PollOnce(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, published)
+	assert.Equal(t, []uuid.UUID{event.ID, event.ID}, sink.published)
+}
+
+func TestPoller_SinkFailureKeepsEventForRetry(t *testing.T) {
+	store := &memoryStore{}
+	event := store.insert(model.EventUserRegistered)
+	sink := &recordingSink{err: errors.New("webhook down")}
+	poller := NewPoller(store, sink, time.Second, time.Hour, 3, time.Minute)
+
+	published, err := poller.PollOnce(context.Background())
+
+	require.NoError(t, err)
+	assert.Zero(t, published)
+	assert.Nil(t, event.PublishedAt)
+	assert.Equal(t, 1, event.Attempts)
+	assert.Equal(t, "webhook down", event.LastError)
+	assert.True(t, event.NextAttemptAt.After(time.Now()), "the retry is backed off")
+
+	sink.err = nil
+	poller.now = func() time.Time { return event.NextAttemptAt }
+	published, err = poller.PollOnce(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, published)
+	assert.NotNil(t, event.PublishedAt)
+	assert.Equal(t, Stats{Published: 1, Failed: 1}, poller.Stats())
+}
+
+func TestPoller_Cleanup(t *testing.T) {
+	store := &memoryStore{}
+	old := store.insert(model.EventUserRegistered)
+	recent := store.insert(model.EventUserRegistered)
+	pending := store.insert(model.EventUserRegistered)
+
+	oldPublishedAt := time.Now().Add(-2 * time.Hour)
+	recentPublishedAt := time.Now()
+	old.PublishedAt = &oldPublishedAt
+	recent.PublishedAt = &recentPublishedAt
+
+	poller := NewPoller(store, &recordingSink{}, time.Second, time.Hour, 3, time.Minute)
+	deleted, err := poller.Cleanup(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+	assert.Equal(t, []*model.OutboxEvent{recent, pending}, store.events)
+}
+
+func TestPoller_Run(t *testing.T) {
+	store := &memoryStore{}
+	store.insert(model.EventUserRegistered)
+	old := store.insert(model.EventUserRegistered)
+	oldPublishedAt := time.Now().Add(-2 * time.Hour)
+	old.PublishedAt = &oldPublishedAt
+	sink := &recordingSink{}
+	poller := NewPoller(store, sink, time.Second, time.Hour, 3, time.Minute)
+
+	require.NoError(t, poller.Run(context.Background()))
+
+	assert.Len(t, sink.published, 1)
+	assert.Len(t, store.events, 1, "old published events are cleaned up")
+	assert.NotNil(t, store.events[0].PublishedAt)
+	assert.Equal(t, time.Second, poller.Interval())
+}
+
+func TestPoller_RunReportsErrors(t *testing.T) {
+	store := &memoryStore{}
+	store.insert(model.EventUserRegistered)
+	errStore := errors.New("connection reset")
+	store.markPublishErr = errStore
+	poller := NewPoller(store, &recordingSink{}, time.Second, time.Hour, 3, time.Minute)
+
+	err := poller.Run(context.Background())
+
+	assert.ErrorIs(t, err, errStore)
+	assert.ErrorContains(t, err, "failed to poll outbox")
+}
+
+func TestPoller_Backlog(t *testing.T) {
+	store := &memoryStore{}
+	for i := 0; i < 3; i++ {
+		store.insert(model.EventUserRegistered)
+	}
+	sink := &recordingSink{err: errors.New("webhook down")}
+	poller := NewPoller(store, sink, time.Second, time.Hour, 3, time.Minute)
+	poller.batchSize = 2
+
+	_, ok := poller.Backlog()
+	assert.False(t, ok, "no poll has fetched events yet")
+
+	_, err := poller.PollOnce(context.Background())
+	require.NoError(t, err)
+
+	backlog, ok := poller.Backlog()
+	require.True(t, ok)
+	assert.Equal(t, 2, backlog.Pending)
+	assert.True(t, backlog.More, "the batch was full")
+	assert.False(t, backlog.PolledAt.IsZero())
+
+	sink.err = nil
+	poller.batchSize = DefaultBatchSize
+	_, err = poller.PollOnce(context.Background())
+	require.NoError(t, err)
+
+	backlog, _ = poller.Backlog()
+	assert.Zero(t, backlog.Pending)
+	assert.False(t, backlog.More)
+}
+
+// setupRetryTest returns a Poller with a 1 minute backoff and 3 attempts,
+// whose clock is the returned pointer, and the event it is to publish.
+func setupRetryTest(sink Sink) (*Poller, *model.OutboxEvent, *time.Time) {
+	store := &memoryStore{}
+	event := store.insert(model.EventUserRegistered)
+	now := event.NextAttemptAt
+
+	poller := NewPoller(store, sink, time.Second, time.Hour, 3, time.Minute)
+	poller.now = func() time.Time { return now }
+	return poller, event, &now
+}
+
+func TestPoller_RetriesWithBackoff(t *testing.T) {
+	sink := &recordingSink{err: errors.New("webhook down")}
+	poller, event, now := setupRetryTest(sink)
+	ctx := context.Background()
+
+	_, err := poller.PollOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(time.Minute), event.NextAttemptAt)
+
+	// Not due yet: the failing event does not hold up the others.
+	*now = now.Add(59 * time.Second)
+	_, err = poller.PollOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, event.Attempts)
+
+	*now = now.Add(time.Second)
+	_, err = poller.PollOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, event.Attempts)
+	assert.Equal(t, now.Add(2*time.Minute), event.NextAttemptAt, "the backoff doubles")
+
+	sink.err = nil
+	*now = event.NextAttemptAt
+	published, err := poller.PollOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, published)
+	assert.Equal(t, Stats{Published: 1, Failed: 2}, poller.Stats())
+}
+
+func TestPoller_DeadLetters(t *testing.T) {
+	poller, event, now := setupRetryTest(&recordingSink{err: errors.New("webhook down")})
+	ctx := context.Background()
+
+	for i := 0; i < 10; i++ {
+		_, err := poller.PollOnce(ctx)
+		require.NoError(t, err)
+		*now = now.Add(time.Hour)
+	}
+
+	require.NotNil(t, event.DeadAt)
+	assert.Nil(t, event.PublishedAt)
+	assert.Equal(t, 3, event.Attempts, "dead letters are not retried")
+	assert.Equal(t, "webhook down", event.LastError)
+	assert.Equal(t, Stats{Failed: 3, DeadLettered: 1}, poller.Stats())
+}
+
+func TestPoller_Backoff(t *testing.T) {
+	poller := NewPoller(&memoryStore{}, &recordingSink{}, time.Second, time.Hour, 20, 30*time.Second)
+
+	assert.Equal(t, 30*time.Second, poller.Backoff(1))
+	assert.Equal(t, time.Minute, poller.Backoff(2))
+	assert.Equal(t, 4*time.Minute, poller.Backoff(4))
+	assert.Equal(t, MaxBackoff, poller.Backoff(15))
+}
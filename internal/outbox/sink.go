@@ -0,0 +1,96 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/eventbus"
+	"github.com/PakornBank/learn-go/internal/model"
+)
+
+// LogSink publishes events by logging them. It is used when no external
+// destination is configured.
+type LogSink struct {
+	logger *slog.Logger
+}
+
+// NewLogSink creates a LogSink that writes to logger.
+func NewLogSink(logger *slog.Logger) *LogSink {
+	return &LogSink{logger: logger}
+}
+
+// Publish logs the event at info level.
+func (s *LogSink) Publish(ctx context.Context, event model.OutboxEvent) error {
+	s.logger.InfoContext(ctx, "outbox event",
+		"event_id", event.ID,
+		"event_type", event.EventType,
+		"payload", string(event.Payload),
+	)
+	return nil
+}
+
+// WebhookSink publishes events by POSTing them as JSON to a URL.
+// Any response other than 2xx is treated as a failure.
+type WebhookSink struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookSink creates a WebhookSink that posts to url with a 10 second timeout.
+func NewWebhookSink(url string) *WebhookSink {
+	return &WebhookSink{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Publish POSTs the event to the webhook URL. The event ID is sent in the
+// Idempotency-Key header so receivers can discard redeliveries.
+func (s *WebhookSink) Publish(ctx context.Context, event model.OutboxEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", event.ID.String())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// BusSink publishes events to a message bus such as NATS.
+type BusSink struct {
+	publisher eventbus.EventPublisher
+}
+
+// NewBusSink creates a BusSink that publishes with publisher.
+func NewBusSink(publisher eventbus.EventPublisher) *BusSink {
+	return &BusSink{publisher: publisher}
+}
+
+// Publish sends the event in the eventbus.Message envelope, on the subject of
+// its type.
+func (s *BusSink) Publish(ctx context.Context, event model.OutboxEvent) error {
+	return s.publisher.Publish(ctx, eventbus.Message{
+		SchemaVersion: eventbus.SchemaVersion,
+		ID:            event.ID.String(),
+		Type:          event.EventType,
+		OccurredAt:    event.CreatedAt,
+		Data:          event.Payload,
+	})
+}
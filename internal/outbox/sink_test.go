@@ -0,0 +1,94 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/eventbus"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogSink_Publish(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewLogSink(slog.New(slog.NewJSONHandler(&buf, nil)))
+	event := model.OutboxEvent{ID: uuid.New(), EventType: model.EventUserRegistered, Payload: []byte(`{"email":"test@example.com"}`)}
+
+	err := sink.Publish(context.Background(), event)
+
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), event.ID.String())
+	assert.Contains(t, buf.String(), model.EventUserRegistered)
+}
+
+func TestWebhookSink_Publish(t *testing.T) {
+	event := model.OutboxEvent{ID: uuid.New(), EventType: model.EventUserRegistered, Payload: []byte(`{"email":"test@example.com"}`)}
+
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{name: "accepted", status: http.StatusNoContent},
+		{name: "rejected", status: http.StatusInternalServerError, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var received model.OutboxEvent
+			var idempotencyKey string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				idempotencyKey = r.Header.Get("Idempotency-Key")
+				_ = json.NewDecoder(r.Body).Decode(&received)
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			err := NewWebhookSink(server.URL).Publish(context.Background(), event)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, event.ID.String(), idempotencyKey)
+			assert.Equal(t, event.ID, received.ID)
+			assert.JSONEq(t, string(event.Payload), string(received.Payload))
+		})
+	}
+}
+
+func TestBusSink_Publish(t *testing.T) {
+	event := model.OutboxEvent{
+		ID:        uuid.New(),
+		EventType: model.EventUserRegistered,
+		Payload:   []byte(`{"email":"test@example.com"}`),
+		CreatedAt: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+	publisher := eventbus.NewMemoryPublisher()
+
+	require.NoError(t, NewBusSink(publisher).Publish(context.Background(), event))
+
+	messages := publisher.Messages()
+	require.Len(t, messages, 1)
+	assert.Equal(t, eventbus.Message{
+		SchemaVersion: eventbus.SchemaVersion,
+		ID:            event.ID.String(),
+		Type:          model.EventUserRegistered,
+		OccurredAt:    event.CreatedAt,
+		Data:          event.Payload,
+	}, messages[0])
+	assert.Equal(t, "events.user.registered", messages[0].Subject())
+
+	publisher.Fail(errors.New("nats: connection closed"))
+	assert.EqualError(t, NewBusSink(publisher).Publish(context.Background(), event), "nats: connection closed")
+}
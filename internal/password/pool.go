@@ -0,0 +1,95 @@
+// Package password hashes passwords and checks them against their hashes with
+// bcrypt. Since bcrypt is deliberately slow, a burst of signups or logins can
+// keep every core busy; Pool bounds how many run at once so that the rest of
+// the server keeps answering.
+package password
+
+import (
+	"context"
+	"runtime"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/sync/semaphore"
+)
+
+// DefaultPoolSize returns the default number of bcrypt operations a Pool runs
+// at once: half the cores Go may use, and at least one.
+func DefaultPoolSize() int {
+	return max(1, runtime.GOMAXPROCS(0)/2)
+}
+
+// Pool hashes and compares passwords with bcrypt, running at most a fixed
+// number of operations at once. Callers beyond that wait their turn, or give
+// up when their context is done. It implements prometheus.Collector, exposing
+// how many operations are waiting and running. It is safe for concurrent use.
+type Pool struct {
+	cost    int
+	workers *semaphore.Weighted
+	queued  prometheus.Gauge
+	running prometheus.Gauge
+}
+
+// NewPool creates a Pool hashing passwords at bcrypt cost and running at most
+// size operations at once.
+func NewPool(cost, size int) *Pool {
+	return &Pool{
+		cost:    cost,
+		workers: semaphore.NewWeighted(int64(size)),
+		queued: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "password_hash_queue_depth",
+			Help: "Password hash and compare operations waiting for a worker.",
+		}),
+		running: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "password_hash_in_progress",
+			Help: "Password hash and compare operations running.",
+		}),
+	}
+}
+
+// Hash returns the bcrypt hash of password. It returns the error of ctx if
+// ctx is done before a worker is free.
+func (p *Pool) Hash(ctx context.Context, password string) (string, error) {
+	var hash []byte
+	err := p.run(ctx, func() (err error) {
+		hash, err = bcrypt.GenerateFromPassword([]byte(password), p.cost)
+		return err
+	})
+	return string(hash), err
+}
+
+// Compare returns nil if password matches hash, and
+// bcrypt.ErrMismatchedHashAndPassword if it does not. It returns the error of
+// ctx if ctx is done before a worker is free.
+func (p *Pool) Compare(ctx context.Context, hash, password string) error {
+	return p.run(ctx, func() error {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	})
+}
+
+// run calls fn once a worker is free.
+func (p *Pool) run(ctx context.Context, fn func() error) error {
+	p.queued.Inc()
+	err := p.workers.Acquire(ctx, 1)
+	p.queued.Dec()
+	if err != nil {
+		return err
+	}
+	defer p.workers.Release(1)
+
+	p.running.Inc()
+	defer p.running.Dec()
+	return fn()
+}
+
+// Describe implements prometheus.Collector.
+func (p *Pool) Describe(ch chan<- *prometheus.Desc) {
+	p.queued.Describe(ch)
+	p.running.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (p *Pool) Collect(ch chan<- prometheus.Metric) {
+	p.queued.Collect(ch)
+	p.running.Collect(ch)
+}
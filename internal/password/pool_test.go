@@ -0,0 +1,181 @@
+package password
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestDefaultPoolSize(t *testing.T) {
+	assert.GreaterOrEqual(t, DefaultPoolSize(), 1)
+	assert.LessOrEqual(t, DefaultPoolSize(), max(1, runtime.GOMAXPROCS(0)))
+}
+
+func TestPool_HashAndCompare(t *testing.T) {
+	pool := NewPool(bcrypt.MinCost, 1)
+	ctx := context.Background()
+
+	hash, err := pool.Hash(ctx, "password")
+	require.NoError(t, err)
+	cost, err := bcrypt.Cost([]byte(hash))
+	require.NoError(t, err)
+	assert.Equal(t, bcrypt.MinCost, cost)
+
+	assert.NoError(t, pool.Compare(ctx, hash, "password"))
+	assert.ErrorIs(t, pool.Compare(ctx, hash, "wrong-password"), bcrypt.ErrMismatchedHashAndPassword)
+	assert.Error(t, pool.Compare(ctx, "not-a-hash", "password"))
+}
+
+func TestPool_BoundsConcurrency(t *testing.T) {
+	pool := NewPool(bcrypt.MinCost, 2)
+	var current, peak atomic.Int64
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := pool.run(context.Background(), func() error {
+				n := current.Add(1)
+				defer current.Add(-1)
+				for {
+					p := peak.Load()
+					if n <= p || peak.CompareAndSwap(p, n) {
+						break
+					}
+				}
+				time.Sleep(5 * time.Millisecond)
+				return nil
+			})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(2), peak.Load())
+}
+
+func TestPool_WaitingCallerGivesUp(t *testing.T) {
+	pool := NewPool(bcrypt.MinCost, 1)
+	started, release := make(chan struct{}), make(chan struct{})
+	go func() {
+		_ = pool.run(context.Background(), func() error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+
+	ctx, cancel := context.WithCancel(context.Background())
+	waiting := make(chan error, 1)
+	go func() {
+		_, err := pool.Hash(ctx, "password")
+		waiting <- err
+	}()
+
+	assert.Eventually(t, func() bool { return testutil.ToFloat64(pool.queued) == 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, float64(1), testutil.ToFloat64(pool.running))
+	cancel()
+	assert.ErrorIs(t, <-waiting, context.Canceled)
+	assert.Equal(t, float64(0), testutil.ToFloat64(pool.queued))
+
+	close(release)
+	assert.Eventually(t, func() bool { return testutil.ToFloat64(pool.running) == 0 }, time.Second, time.Millisecond)
+}
+
+func TestPool_Collector(t *testing.T) {
+	pool := NewPool(bcrypt.MinCost, 1)
+
+	assert.Equal(t, 2, testutil.CollectAndCount(pool, "password_hash_queue_depth", "password_hash_in_progress"))
+}
+
+// BenchmarkBcryptCost measures how long hashing a password takes at bcrypt
+// costs 10 to 14, to pick a cost with data: each step doubles the time, and
+// login latency and the capacity of the Pool follow it. A hash should take
+// long enough to slow down offline guessing, yet leave the login p99 within
+// budget. The ms/hash metric is the figure to compare.
+func BenchmarkBcryptCost(b *testing.B) {
+	for cost := 10; cost <= 14; cost++ {
+		b.Run(fmt.Sprintf("cost=%d", cost), func(b *testing.B) {
+			pool := NewPool(cost, 1)
+			ctx := context.Background()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := pool.Hash(ctx, "password"); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(b.Elapsed().Seconds()*1000/float64(b.N), "ms/hash")
+		})
+	}
+}
+
+// BenchmarkPool_Flood measures the latency of a cheap request, standing in
+// for the non-auth endpoints, while registrations flood the server with
+// bcrypt hashes. Compare the p99-ns of the pool to that of the unbounded run:
+// bounded to DefaultPoolSize workers, the flood leaves cores free and the
+// latency stays close to that of an idle server.
+func BenchmarkPool_Flood(b *testing.B) {
+	flooders := 4 * runtime.GOMAXPROCS(0)
+	for _, bench := range []struct {
+		name string
+		size int
+	}{
+		{name: "idle", size: 0},
+		{name: "unbounded", size: flooders},
+		{name: "pool", size: DefaultPoolSize()},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			ctx, cancel := context.WithCancel(context.Background())
+			var wg sync.WaitGroup
+			if bench.size > 0 {
+				pool := NewPool(bcrypt.DefaultCost, bench.size)
+				for i := 0; i < flooders; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						for ctx.Err() == nil {
+							_, _ = pool.Hash(ctx, "password")
+						}
+					}()
+				}
+				// Let the flood saturate the pool.
+				time.Sleep(100 * time.Millisecond)
+			}
+
+			payload := make([]byte, 4096)
+			latencies := make([]time.Duration, b.N)
+			b.ResetTimer()
+			for i := range latencies {
+				// Like net/http, serve each request on a goroutine of its own,
+				// so that the time it waits to be scheduled counts.
+				start := time.Now()
+				served := make(chan struct{})
+				go func() {
+					sha256.Sum256(payload)
+					close(served)
+				}()
+				<-served
+				latencies[i] = time.Since(start)
+			}
+			b.StopTimer()
+			cancel()
+			wg.Wait()
+
+			slices.Sort(latencies)
+			b.ReportMetric(float64(latencies[len(latencies)*99/100].Nanoseconds()), "p99-ns")
+		})
+	}
+}
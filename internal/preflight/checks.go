@@ -0,0 +1,121 @@
+package preflight
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/redis/go-redis/v9"
+)
+
+// The names of the checks built by this package.
+const (
+	CheckDatabase = "database"
+	CheckReplica  = "replica"
+	CheckSchema   = "schema"
+	CheckJWT      = "jwt"
+	CheckRedis    = "redis"
+	CheckSMTP     = "smtp"
+)
+
+// Pinger is a database that can be reached, such as a *sql.DB.
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// Database returns a required check that db can be reached.
+func Database(db Pinger) Check {
+	return Check{Name: CheckDatabase, Required: true, Run: db.PingContext}
+}
+
+// Replica returns an optional check that the read replica db can be reached.
+// Without it, reads go to the primary.
+func Replica(db Pinger) Check {
+	return Check{Name: CheckReplica, Run: db.PingContext}
+}
+
+// Schema is the database schema, as far as Migrated checks it.
+type Schema interface {
+	// HasTable reports whether the table of model exists.
+	HasTable(ctx context.Context, model any) bool
+}
+
+// Migrated returns a required check that schema has the table of every
+// model, failing if the database is missing migrations the code relies on.
+func Migrated(schema Schema, models ...any) Check {
+	return Check{Name: CheckSchema, Required: true, Run: func(ctx context.Context) error {
+		var missing []string
+		for _, model := range models {
+			if !schema.HasTable(ctx, model) {
+				missing = append(missing, fmt.Sprintf("%T", model))
+			}
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("database is not migrated, missing the tables of %v", missing)
+		}
+		return nil
+	}}
+}
+
+// JWTSecret returns a required check that access tokens can be signed with
+// secret and the signature verified again.
+func JWTSecret(secret string) Check {
+	return Check{Name: CheckJWT, Required: true, Run: func(context.Context) error {
+		if secret == "" {
+			return errors.New("JWT secret is empty")
+		}
+		key := []byte(secret)
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "preflight"}).SignedString(key)
+		if err != nil {
+			return err
+		}
+		_, err = jwt.Parse(signed, func(*jwt.Token) (interface{}, error) { return key, nil },
+			jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+		return err
+	}}
+}
+
+// RedisPinger is a Redis client, such as a *redis.Client.
+type RedisPinger interface {
+	Ping(ctx context.Context) *redis.StatusCmd
+}
+
+// Redis returns an optional check that client can reach its server. Without
+// it, users are cached in process and rate limits are counted in memory.
+func Redis(client RedisPinger) Check {
+	return Check{Name: CheckRedis, Run: func(ctx context.Context) error {
+		return client.Ping(ctx).Err()
+	}}
+}
+
+// DialFunc connects to addr on network, like net.Dialer.DialContext.
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// SMTP returns an optional check that the SMTP server at addr, a host and
+// port, greets a connection made with dial. Without it, emails stay queued
+// until a restart finds the server.
+func SMTP(addr string, dial DialFunc) Check {
+	return Check{Name: CheckSMTP, Run: func(ctx context.Context) error {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return err
+		}
+		conn, err := dial(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		if deadline, ok := ctx.Deadline(); ok {
+			conn.SetDeadline(deadline)
+		}
+
+		client, err := smtp.NewClient(conn, host)
+		if err != nil {
+			return err
+		}
+		return client.Quit()
+	}}
+}
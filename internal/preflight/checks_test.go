@@ -0,0 +1,123 @@
+package preflight
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakePinger struct {
+	err error
+}
+
+func (p fakePinger) PingContext(context.Context) error {
+	return p.err
+}
+
+func TestDatabase(t *testing.T) {
+	down := errors.New("connection refused")
+
+	assert.NoError(t, Database(fakePinger{}).Run(context.Background()))
+	assert.ErrorIs(t, Database(fakePinger{err: down}).Run(context.Background()), down)
+	assert.True(t, Database(fakePinger{}).Required)
+	assert.False(t, Replica(fakePinger{}).Required, "reads fall back to the primary")
+	assert.ErrorIs(t, Replica(fakePinger{err: down}).Run(context.Background()), down)
+}
+
+type user struct{}
+type session struct{}
+
+// fakeSchema has the tables of the models in tables.
+type fakeSchema struct {
+	tables []any
+}
+
+func (s fakeSchema) HasTable(_ context.Context, model any) bool {
+	for _, table := range s.tables {
+		if fmt.Sprintf("%T", table) == fmt.Sprintf("%T", model) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestMigrated(t *testing.T) {
+	models := []any{&user{}, &session{}}
+
+	assert.NoError(t, Migrated(fakeSchema{tables: models}, models...).Run(context.Background()))
+
+	err := Migrated(fakeSchema{tables: []any{&user{}}}, models...).Run(context.Background())
+	assert.EqualError(t, err, "database is not migrated, missing the tables of [*preflight.session]")
+}
+
+func TestJWTSecret(t *testing.T) {
+	assert.NoError(t, JWTSecret("test-secret").Run(context.Background()))
+	assert.EqualError(t, JWTSecret("").Run(context.Background()), "JWT secret is empty")
+}
+
+func TestRedis(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	assert.NoError(t, Redis(client).Run(context.Background()))
+	assert.False(t, Redis(client).Required)
+
+	server.Close()
+	assert.Error(t, Redis(client).Run(context.Background()))
+}
+
+// fakeSMTP returns a DialFunc connecting to an SMTP server that greets with
+// greeting and accepts every command, along with whether it was asked to
+// quit.
+func fakeSMTP(greeting string) (DialFunc, <-chan bool) {
+	quit := make(chan bool, 1)
+	dial := func(context.Context, string, string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go func() {
+			defer server.Close()
+			fmt.Fprintf(server, "%s\r\n", greeting)
+			commands := bufio.NewReader(server)
+			for {
+				line, err := commands.ReadString('\n')
+				if err != nil {
+					quit <- false
+					return
+				}
+				if line == "QUIT\r\n" {
+					fmt.Fprint(server, "221 bye\r\n")
+					quit <- true
+					return
+				}
+				fmt.Fprint(server, "250 ok\r\n")
+			}
+		}()
+		return client, nil
+	}
+	return dial, quit
+}
+
+func TestSMTP(t *testing.T) {
+	dial, quit := fakeSMTP("220 smtp.example.com ESMTP")
+
+	assert.NoError(t, SMTP("smtp.example.com:587", dial).Run(context.Background()))
+	assert.True(t, <-quit, "the check says goodbye")
+	assert.False(t, SMTP("smtp.example.com:587", dial).Required)
+}
+
+func TestSMTP_Fails(t *testing.T) {
+	down := errors.New("connection refused")
+	refuse := func(context.Context, string, string) (net.Conn, error) { return nil, down }
+	unavailable, _ := fakeSMTP("554 no service")
+
+	assert.ErrorIs(t, SMTP("smtp.example.com:587", refuse).Run(context.Background()), down)
+	assert.Error(t, SMTP("smtp.example.com:587", unavailable).Run(context.Background()))
+	assert.Error(t, SMTP("smtp.example.com", refuse).Run(context.Background()), "the address has no port")
+}
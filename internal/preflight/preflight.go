@@ -0,0 +1,145 @@
+// Package preflight checks the dependencies of the server, such as the
+// database and the SMTP server, before it accepts traffic.
+package preflight
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout bounds a Check that sets no Timeout of its own.
+const DefaultTimeout = 5 * time.Second
+
+// Check verifies one dependency of the server.
+type Check struct {
+	// Name identifies the dependency in the startup summary and in /readyz.
+	Name string
+	// Required checks abort startup when they fail. The server runs without
+	// the feature an optional check backs when it fails, and reports it as
+	// degraded instead.
+	Required bool
+	// Timeout bounds Run, or DefaultTimeout if it is zero.
+	Timeout time.Duration
+	// Run returns an error if the dependency cannot be used. It must return
+	// once ctx is done.
+	Run func(ctx context.Context) error
+}
+
+// Result is the outcome of a Check.
+type Result struct {
+	Name     string
+	Required bool
+	Duration time.Duration
+	// Err is why the check failed, or nil if it passed.
+	Err error
+}
+
+// Report holds the Results of running a slice of Checks, in the order of the
+// checks. The zero Report is one of no checks, all of which passed.
+type Report struct {
+	Results []Result
+}
+
+// Run runs checks concurrently, each bounded by its timeout, and returns
+// their Results once all of them are done.
+func Run(ctx context.Context, checks []Check) Report {
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			results[i] = run(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+	return Report{Results: results}
+}
+
+// run runs check with its timeout. A check that overruns it fails with the
+// error of ctx even if its Run ignores ctx and returns nil late.
+func run(ctx context.Context, check Check) Result {
+	timeout := check.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check.Run(ctx)
+	if err == nil {
+		err = ctx.Err()
+	}
+	return Result{
+		Name:     check.Name,
+		Required: check.Required,
+		Duration: time.Since(start),
+		Err:      err,
+	}
+}
+
+// Err returns the failures of the required checks joined together, or nil if
+// they all passed.
+func (r Report) Err() error {
+	var errs []error
+	for _, result := range r.Results {
+		if result.Required && result.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", result.Name, result.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Degraded returns the names of the optional checks that failed, whose
+// features the server runs without.
+func (r Report) Degraded() []string {
+	var names []string
+	for _, result := range r.Results {
+		if !result.Required && result.Err != nil {
+			names = append(names, result.Name)
+		}
+	}
+	return names
+}
+
+// Failed reports whether the check called name failed. A check that was not
+// run did not fail.
+func (r Report) Failed(name string) bool {
+	i := slices.IndexFunc(r.Results, func(result Result) bool { return result.Name == name })
+	return i >= 0 && r.Results[i].Err != nil
+}
+
+// Log writes the report to logger as a single startup summary, after attrs
+// describing the server, such as its environment and port. Each check is a
+// group of its status, "ok", "degraded" or "failed", its duration and any
+// error. The summary is logged at error level if a required check failed, at
+// warn level if an optional one did, and at info level otherwise.
+func (r Report) Log(ctx context.Context, logger *slog.Logger, attrs ...any) {
+	level := slog.LevelInfo
+	checks := make([]any, 0, len(r.Results))
+	for _, result := range r.Results {
+		status := "ok"
+		switch {
+		case result.Err == nil:
+		case result.Required:
+			status = "failed"
+			level = slog.LevelError
+		default:
+			status = "degraded"
+			level = max(level, slog.LevelWarn)
+		}
+
+		group := []any{"status", status, "duration", result.Duration}
+		if result.Err != nil {
+			group = append(group, "error", result.Err)
+		}
+		checks = append(checks, slog.Group(result.Name, group...))
+	}
+	logger.Log(ctx, level, "starting server", append(attrs, slog.Group("preflight", checks...))...)
+}
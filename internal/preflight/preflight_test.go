@@ -0,0 +1,146 @@
+package preflight
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// passing and failing return checks called name that pass or fail with err.
+func passing(name string, required bool) Check {
+	return Check{Name: name, Required: required, Run: func(context.Context) error { return nil }}
+}
+
+func failing(name string, required bool, err error) Check {
+	return Check{Name: name, Required: required, Run: func(context.Context) error { return err }}
+}
+
+func TestRun(t *testing.T) {
+	down := errors.New("connection refused")
+
+	report := Run(context.Background(), []Check{
+		passing("database", true),
+		failing("jwt", true, down),
+		failing("smtp", false, down),
+		passing("redis", false),
+	})
+
+	require.Len(t, report.Results, 4)
+	for i, name := range []string{"database", "jwt", "smtp", "redis"} {
+		assert.Equal(t, name, report.Results[i].Name, "results keep the order of the checks")
+	}
+	assert.EqualError(t, report.Err(), "jwt: connection refused")
+	assert.ErrorIs(t, report.Err(), down)
+	assert.Equal(t, []string{"smtp"}, report.Degraded())
+	assert.True(t, report.Failed("smtp"))
+	assert.False(t, report.Failed("redis"))
+	assert.False(t, report.Failed("replica"), "a check that was not run did not fail")
+}
+
+func TestRun_AllPassing(t *testing.T) {
+	report := Run(context.Background(), []Check{passing("database", true), passing("redis", false)})
+
+	assert.NoError(t, report.Err())
+	assert.Empty(t, report.Degraded())
+}
+
+func TestRun_Timeout(t *testing.T) {
+	report := Run(context.Background(), []Check{
+		{Name: "blocks", Timeout: 10 * time.Millisecond, Run: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}},
+		{Name: "ignores ctx", Timeout: 10 * time.Millisecond, Run: func(context.Context) error {
+			time.Sleep(20 * time.Millisecond)
+			return nil
+		}},
+	})
+
+	for _, result := range report.Results {
+		assert.ErrorIs(t, result.Err, context.DeadlineExceeded, result.Name)
+	}
+	assert.Equal(t, []string{"blocks", "ignores ctx"}, report.Degraded())
+}
+
+func TestRun_ChecksRunConcurrently(t *testing.T) {
+	started := make(chan struct{})
+	wait := func(ctx context.Context) error {
+		select {
+		case started <- struct{}{}:
+		case <-started:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		return nil
+	}
+
+	report := Run(context.Background(), []Check{
+		{Name: "a", Timeout: time.Second, Run: wait},
+		{Name: "b", Timeout: time.Second, Run: wait},
+	})
+
+	assert.Empty(t, report.Degraded(), "each check waits for the other to start")
+}
+
+func TestReport_Log(t *testing.T) {
+	tests := []struct {
+		name      string
+		results   []Result
+		wantLevel string
+		wantState map[string]string
+	}{
+		{
+			name:      "all passed",
+			results:   []Result{{Name: "database", Required: true}, {Name: "redis"}},
+			wantLevel: "INFO",
+			wantState: map[string]string{"database": "ok", "redis": "ok"},
+		},
+		{
+			name:      "optional failed",
+			results:   []Result{{Name: "database", Required: true}, {Name: "smtp", Err: errors.New("refused")}},
+			wantLevel: "WARN",
+			wantState: map[string]string{"database": "ok", "smtp": "degraded"},
+		},
+		{
+			name:      "required failed",
+			results:   []Result{{Name: "smtp", Err: errors.New("refused")}, {Name: "jwt", Required: true, Err: errors.New("empty")}},
+			wantLevel: "ERROR",
+			wantState: map[string]string{"smtp": "degraded", "jwt": "failed"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+			Report{Results: tt.results}.Log(context.Background(), logger, "env", "production")
+
+			var entry struct {
+				Level     string
+				Msg       string
+				Env       string
+				Preflight map[string]struct {
+					Status string
+					Error  string
+				}
+			}
+			require.NoError(t, json.Unmarshal(buf.Bytes(), &entry), "the summary is a single entry")
+			assert.Equal(t, tt.wantLevel, entry.Level)
+			assert.Equal(t, "starting server", entry.Msg)
+			assert.Equal(t, "production", entry.Env)
+			require.Len(t, entry.Preflight, len(tt.wantState))
+			for name, status := range tt.wantState {
+				assert.Equal(t, status, entry.Preflight[name].Status, name)
+				assert.Equal(t, status != "ok", entry.Preflight[name].Error != "", name)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory is a sliding-window rate limiter that keeps request timestamps in
+// process memory. Limits are not shared between replicas; use Redis when
+// running more than one instance.
+type Memory struct {
+	limits Limits
+	now    func() time.Time
+
+	mu        sync.Mutex
+	requests  map[string][]time.Time
+	lastSweep time.Time
+}
+
+// NewMemory creates a Memory limiter allowing the requests per key that limits returns.
+func NewMemory(limits Limits) *Memory {
+	return &Memory{
+		limits:   limits,
+		now:      time.Now,
+		requests: make(map[string][]time.Time),
+	}
+}
+
+// Allow records a request for key if it is within the limit. It never returns an error.
+func (m *Memory) Allow(_ context.Context, key string) (Decision, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	limit, window := m.limits()
+	now := m.now()
+	cutoff := now.Add(-window)
+	m.sweep(now, cutoff, window)
+
+	recent := pruneBefore(m.requests[key], cutoff)
+	if len(recent) >= limit {
+		m.requests[key] = recent
+		return Decision{RetryAfter: recent[len(recent)-limit].Add(window).Sub(now)}, nil
+	}
+
+	m.requests[key] = append(recent, now)
+	return Decision{Allowed: true, Remaining: limit - len(recent) - 1}, nil
+}
+
+// sweep drops keys with no requests inside the window, at most once per window,
+// so that clients that stop sending requests do not accumulate forever.
+func (m *Memory) sweep(now, cutoff time.Time, window time.Duration) {
+	if now.Sub(m.lastSweep) < window {
+		return
+	}
+	m.lastSweep = now
+
+	for key, timestamps := range m.requests {
+		if len(pruneBefore(timestamps, cutoff)) == 0 {
+			delete(m.requests, key)
+		}
+	}
+}
+
+// pruneBefore returns the timestamps after cutoff. timestamps must be sorted.
+func pruneBefore(timestamps []time.Time, cutoff time.Time) []time.Time {
+	i := 0
+	for i < len(timestamps) && !timestamps[i].After(cutoff) {
+		i++
+	}
+	return timestamps[i:]
+}
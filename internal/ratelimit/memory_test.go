@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemory_Allow(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	m := NewMemory(Fixed(2, time.Minute))
+	m.now = func() time.Time { return now }
+
+	first, _ := m.Allow(ctx, "login:10.0.0.1")
+	assert.Equal(t, Decision{Allowed: true, Remaining: 1}, first)
+
+	now = now.Add(10 * time.Second)
+	second, _ := m.Allow(ctx, "login:10.0.0.1")
+	assert.Equal(t, Decision{Allowed: true, Remaining: 0}, second)
+
+	now = now.Add(10 * time.Second)
+	denied, _ := m.Allow(ctx, "login:10.0.0.1")
+	assert.False(t, denied.Allowed)
+	assert.Equal(t, 40*time.Second, denied.RetryAfter)
+
+	other, _ := m.Allow(ctx, "login:10.0.0.2")
+	assert.True(t, other.Allowed, "keys are limited independently")
+
+	// The first request leaves the window, freeing one slot.
+	now = now.Add(40 * time.Second)
+	allowed, _ := m.Allow(ctx, "login:10.0.0.1")
+	assert.True(t, allowed.Allowed)
+}
+
+func TestMemory_SweepsIdleKeys(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	m := NewMemory(Fixed(1, time.Minute))
+	m.now = func() time.Time { return now }
+
+	m.Allow(ctx, "a")
+	m.Allow(ctx, "b")
+	assert.Len(t, m.requests, 2)
+
+	now = now.Add(2 * time.Minute)
+	m.Allow(ctx, "c")
+	assert.Len(t, m.requests, 1)
+}
+
+func TestMemory_LimitsChange(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	limit := 3
+	m := NewMemory(func() (int, time.Duration) { return limit, time.Minute })
+	m.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		allowed, _ := m.Allow(ctx, "login:10.0.0.1")
+		assert.True(t, allowed.Allowed)
+		now = now.Add(10 * time.Second)
+	}
+
+	// Lowering the limit denies requests until all of the earlier ones
+	// have left the window, which the last one does 50 seconds from now.
+	limit = 1
+	denied, _ := m.Allow(ctx, "login:10.0.0.1")
+	assert.False(t, denied.Allowed)
+	assert.Equal(t, 50*time.Second, denied.RetryAfter)
+
+	// Raising it allows them again right away.
+	limit = 5
+	allowed, _ := m.Allow(ctx, "login:10.0.0.1")
+	assert.Equal(t, Decision{Allowed: true, Remaining: 1}, allowed)
+}
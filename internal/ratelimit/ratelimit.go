@@ -0,0 +1,29 @@
+// Package ratelimit provides sliding-window rate limiters backed by process
+// memory or by Redis. Both allow at most a number of requests per key within
+// any window of the configured length.
+package ratelimit
+
+import "time"
+
+// Limits returns how many requests are allowed per key within each window of
+// the returned length. Limiters call it on every request, so the limits can
+// change while the server runs.
+type Limits func() (limit int, window time.Duration)
+
+// Fixed returns Limits that always allow limit requests per window.
+func Fixed(limit int, window time.Duration) Limits {
+	return func() (int, time.Duration) {
+		return limit, window
+	}
+}
+
+// Decision is the outcome of a rate limit check.
+type Decision struct {
+	// Allowed reports whether the request may proceed.
+	Allowed bool
+	// Remaining is the number of further requests allowed in the current window.
+	Remaining int
+	// RetryAfter is how long to wait before a request will be allowed again.
+	// It is zero when Allowed is true.
+	RetryAfter time.Duration
+}
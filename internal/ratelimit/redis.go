@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// slidingWindowScript keeps one sorted-set member per request, scored by its
+// time in milliseconds. It drops members older than the window, then either
+// records the request or reports how long until enough members leave the
+// window for a request to fit, since the limit may have been lowered. The key
+// expires once the window has passed without requests.
+//
+// KEYS[1] = key, ARGV[1] = now (ms), ARGV[2] = window (ms), ARGV[3] = limit, ARGV[4] = member
+// Returns {allowed (0|1), remaining, retry after (ms)}.
+var slidingWindowScript = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+local count = redis.call('ZCARD', key)
+
+if count < limit then
+	redis.call('ZADD', key, now, ARGV[4])
+	redis.call('PEXPIRE', key, window)
+	return {1, limit - count - 1, 0}
+end
+
+local freeing = redis.call('ZRANGE', key, count - limit, count - limit, 'WITHSCORES')
+return {0, 0, tonumber(freeing[2]) + window - now}
+`)
+
+// Redis is a sliding-window rate limiter that keeps its state in Redis, so the
+// limit is shared by every replica using the same server.
+type Redis struct {
+	client *redis.Client
+	limits Limits
+	prefix string
+	now    func() time.Time
+}
+
+// NewRedis creates a Redis limiter allowing the requests per key that limits returns.
+// The caller owns client and is responsible for closing it.
+func NewRedis(client *redis.Client, limits Limits) *Redis {
+	return &Redis{
+		client: client,
+		limits: limits,
+		prefix: "ratelimit:",
+		now:    time.Now,
+	}
+}
+
+// Allow records a request for key if it is within the limit.
+// It returns an error if Redis cannot be reached.
+func (r *Redis) Allow(ctx context.Context, key string) (Decision, error) {
+	member, err := randomMember()
+	if err != nil {
+		return Decision{}, err
+	}
+
+	limit, window := r.limits()
+	res, err := slidingWindowScript.Run(ctx, r.client,
+		[]string{r.prefix + key},
+		r.now().UnixMilli(), window.Milliseconds(), limit, member,
+	).Int64Slice()
+	if err != nil {
+		return Decision{}, err
+	}
+
+	return Decision{
+		Allowed:    res[0] == 1,
+		Remaining:  int(res[1]),
+		RetryAfter: time.Duration(res[2]) * time.Millisecond,
+	}, nil
+}
+
+// randomMember returns a unique sorted-set member, so requests recorded in the
+// same millisecond are counted separately.
+func randomMember() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,117 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupRedisTest(t *testing.T, limit int, window time.Duration) (*miniredis.Miniredis, *Redis, *time.Time) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	now := time.Now()
+	r := NewRedis(client, Fixed(limit, window))
+	r.now = func() time.Time { return now }
+	return server, r, &now
+}
+
+func TestRedis_Allow(t *testing.T) {
+	ctx := context.Background()
+	_, r, now := setupRedisTest(t, 2, time.Minute)
+
+	first, err := r.Allow(ctx, "login:10.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, Decision{Allowed: true, Remaining: 1}, first)
+
+	*now = now.Add(10 * time.Second)
+	second, err := r.Allow(ctx, "login:10.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, Decision{Allowed: true, Remaining: 0}, second)
+
+	*now = now.Add(10 * time.Second)
+	denied, err := r.Allow(ctx, "login:10.0.0.1")
+	require.NoError(t, err)
+	assert.False(t, denied.Allowed)
+	assert.Equal(t, 40*time.Second, denied.RetryAfter)
+
+	other, err := r.Allow(ctx, "login:10.0.0.2")
+	require.NoError(t, err)
+	assert.True(t, other.Allowed, "keys are limited independently")
+
+	*now = now.Add(40 * time.Second)
+	allowed, err := r.Allow(ctx, "login:10.0.0.1")
+	require.NoError(t, err)
+	assert.True(t, allowed.Allowed)
+}
+
+func TestRedis_WindowExpires(t *testing.T) {
+	ctx := context.Background()
+	server, r, now := setupRedisTest(t, 1, time.Minute)
+
+	_, err := r.Allow(ctx, "login:10.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, server.TTL("ratelimit:login:10.0.0.1"))
+
+	server.FastForward(time.Minute)
+	assert.False(t, server.Exists("ratelimit:login:10.0.0.1"))
+
+	*now = now.Add(time.Minute)
+	allowed, err := r.Allow(ctx, "login:10.0.0.1")
+	require.NoError(t, err)
+	assert.True(t, allowed.Allowed)
+}
+
+func TestRedis_SameMillisecondRequestsCountSeparately(t *testing.T) {
+	ctx := context.Background()
+	_, r, _ := setupRedisTest(t, 2, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		decision, err := r.Allow(ctx, "key")
+		require.NoError(t, err)
+		assert.True(t, decision.Allowed)
+	}
+
+	decision, err := r.Allow(ctx, "key")
+	require.NoError(t, err)
+	assert.False(t, decision.Allowed)
+}
+
+func TestRedis_ServerUnavailable(t *testing.T) {
+	server, r, _ := setupRedisTest(t, 1, time.Minute)
+	server.Close()
+
+	_, err := r.Allow(context.Background(), "key")
+	assert.Error(t, err)
+}
+
+func TestRedis_LimitsChange(t *testing.T) {
+	ctx := context.Background()
+	_, r, now := setupRedisTest(t, 3, time.Minute)
+	limit := 3
+	r.limits = func() (int, time.Duration) { return limit, time.Minute }
+
+	for i := 0; i < 3; i++ {
+		allowed, err := r.Allow(ctx, "login:10.0.0.1")
+		require.NoError(t, err)
+		assert.True(t, allowed.Allowed)
+		*now = now.Add(10 * time.Second)
+	}
+
+	limit = 1
+	denied, err := r.Allow(ctx, "login:10.0.0.1")
+	require.NoError(t, err)
+	assert.False(t, denied.Allowed)
+	assert.Equal(t, 50*time.Second, denied.RetryAfter)
+
+	limit = 5
+	allowed, err := r.Allow(ctx, "login:10.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, Decision{Allowed: true, Remaining: 1}, allowed)
+}
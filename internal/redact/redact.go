@@ -0,0 +1,80 @@
+// Package redact masks sensitive fields, such as passwords, in JSON documents
+// before they are stored or logged.
+package redact
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Placeholder replaces the value of every redacted field.
+const Placeholder = "[REDACTED]"
+
+// DefaultFields are the field patterns redacted when none are configured.
+var DefaultFields = []string{"password", "token", "*_secret"}
+
+// Redactor replaces the values of sensitive fields in JSON documents with
+// Placeholder. A field is sensitive when its name matches one of the
+// Redactor's patterns, which use the syntax of path.Match and ignore case, so
+// "*_secret" matches "client_secret" and "API_SECRET". It is safe for
+// concurrent use.
+type Redactor struct {
+	patterns []string
+}
+
+// New creates a Redactor for fields matching any of patterns. It returns an
+// error if a pattern is malformed.
+func New(patterns []string) (*Redactor, error) {
+	lowered := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		lowered[i] = strings.ToLower(pattern)
+		if _, err := path.Match(lowered[i], ""); err != nil {
+			return nil, fmt.Errorf("invalid field pattern %q: %w", pattern, err)
+		}
+	}
+	return &Redactor{patterns: lowered}, nil
+}
+
+// JSON returns document with the value of every sensitive field replaced,
+// however deeply it is nested in objects and arrays. The value is replaced
+// whole, even when it is an object or array itself. It returns an error if
+// document is not valid JSON.
+func (r *Redactor) JSON(document []byte) ([]byte, error) {
+	var value any
+	if err := json.Unmarshal(document, &value); err != nil {
+		return nil, err
+	}
+	return json.Marshal(r.value(value))
+}
+
+// value returns v, a decoded JSON value, with its sensitive fields replaced.
+func (r *Redactor) value(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, field := range v {
+			if r.sensitive(key) {
+				v[key] = Placeholder
+			} else {
+				v[key] = r.value(field)
+			}
+		}
+	case []any:
+		for i, element := range v {
+			v[i] = r.value(element)
+		}
+	}
+	return v
+}
+
+// sensitive reports whether the field called name must be redacted.
+func (r *Redactor) sensitive(name string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range r.patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package redact
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactor_JSON(t *testing.T) {
+	redactor, err := New(DefaultFields)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		document string
+		want     string
+	}{
+		{
+			name:     "top-level fields",
+			document: `{"email": "a@example.com", "password": "hunter2", "token": "abc"}`,
+			want:     `{"email": "a@example.com", "password": "[REDACTED]", "token": "[REDACTED]"}`,
+		},
+		{
+			name:     "wildcard pattern",
+			document: `{"client_secret": "s", "secretive": "kept", "secret": "kept"}`,
+			want:     `{"client_secret": "[REDACTED]", "secretive": "kept", "secret": "kept"}`,
+		},
+		{
+			name:     "case-insensitive names",
+			document: `{"Password": "p", "API_SECRET": "s"}`,
+			want:     `{"Password": "[REDACTED]", "API_SECRET": "[REDACTED]"}`,
+		},
+		{
+			name:     "nested objects",
+			document: `{"user": {"email": "a@example.com", "credentials": {"password": "p"}}}`,
+			want:     `{"user": {"email": "a@example.com", "credentials": {"password": "[REDACTED]"}}}`,
+		},
+		{
+			name:     "objects in arrays",
+			document: `{"users": [{"password": "p1"}, {"password": "p2", "role": "admin"}], "tags": ["password"]}`,
+			want:     `{"users": [{"password": "[REDACTED]"}, {"password": "[REDACTED]", "role": "admin"}], "tags": ["password"]}`,
+		},
+		{
+			name:     "top-level array",
+			document: `[{"token": "t"}, 1, null]`,
+			want:     `[{"token": "[REDACTED]"}, 1, null]`,
+		},
+		{
+			name:     "non-string values are replaced whole",
+			document: `{"password": {"old": "a", "new": "b"}, "token": 42, "app_secret": null}`,
+			want:     `{"password": "[REDACTED]", "token": "[REDACTED]", "app_secret": "[REDACTED]"}`,
+		},
+		{
+			name:     "nothing sensitive",
+			document: `{"enabled": true, "count": 3}`,
+			want:     `{"enabled": true, "count": 3}`,
+		},
+		{
+			name:     "scalar document",
+			document: `"password"`,
+			want:     `"password"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := redactor.JSON([]byte(tt.document))
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestRedactor_JSONInvalid(t *testing.T) {
+	redactor, err := New(DefaultFields)
+	require.NoError(t, err)
+
+	_, err = redactor.JSON([]byte(`{"password": `))
+	assert.Error(t, err)
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		wantErr  bool
+	}{
+		{name: "defaults", patterns: DefaultFields},
+		{name: "none", patterns: nil},
+		{name: "character class", patterns: []string{"pin_[0-9]"}},
+		{name: "malformed pattern", patterns: []string{"[password"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.patterns)
+			if tt.wantErr {
+				assert.ErrorContains(t, err, "invalid field pattern")
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/PakornBank/learn-go/internal/database"
+	"github.com/PakornBank/learn-go/internal/model"
+	"gorm.io/gorm"
+)
+
+// adminBootstrapLock names the advisory lock BootstrapAdmin holds, shared by
+// every replica of the server.
+const adminBootstrapLock = "admin-bootstrap"
+
+// AdminBootstrap tells what BootstrapAdmin did.
+type AdminBootstrap int
+
+const (
+	// AdminExists means a user already had the admin role, so nothing changed.
+	AdminExists AdminBootstrap = iota
+	// AdminPromoted means the user with the bootstrap email was made an admin.
+	AdminPromoted
+	// AdminCreated means the bootstrap user was created as an admin.
+	AdminCreated
+)
+
+// BootstrapAdmin makes sure there is an admin, for a fresh deployment to be
+// administered at all. If no user has the admin role, it gives it to the user
+// with the email of user, keeping their password and name, or, if there is no
+// such user, creates user as an admin. The ID and role of user are set to
+// those of the admin in either case. If an admin already exists, it does
+// nothing and returns AdminExists.
+//
+// The check and the change run in one transaction. On PostgreSQL it holds an
+// advisory lock, so that replicas starting at the same time wait for each
+// other and only the first makes an admin. Other databases, used by tests,
+// have no advisory locks and run a plain transaction.
+// It returns an error if the operation fails, or ErrTimeout if it exceeds the query timeout.
+func (r *UserRepository) BootstrapAdmin(ctx context.Context, user *model.User) (AdminBootstrap, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	outcome := AdminExists
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if database.IsPostgres(tx) {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", adminBootstrapLock).Error; err != nil {
+				return err
+			}
+		}
+
+		var admins int64
+		if err := tx.Model(&model.User{}).Where("role = ?", model.RoleAdmin).Count(&admins).Error; err != nil {
+			return err
+		}
+		if admins > 0 {
+			return nil
+		}
+
+		var existing model.User
+		err := tx.Where("email = ?", user.Email).First(&existing).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			user.Role = model.RoleAdmin
+			outcome = AdminCreated
+			return tx.Create(user).Error
+		case err != nil:
+			return err
+		}
+
+		if err := tx.Model(&existing).Update("role", model.RoleAdmin).Error; err != nil {
+			return err
+		}
+		user.ID, user.Role = existing.ID, model.RoleAdmin
+		outcome = AdminPromoted
+		return nil
+	})
+	if err != nil {
+		return AdminExists, translateError(ctx, err)
+	}
+
+	return outcome, nil
+}
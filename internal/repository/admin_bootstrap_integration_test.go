@@ -0,0 +1,86 @@
+//go:build integration
+
+package repository_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// TestUserRepository_BootstrapAdminOnce checks that the first admin is created or
+// promoted once, even by replicas starting together, on every one of the
+// testDatabases, see TestUserRepository_Contract.
+func TestUserRepository_BootstrapAdminOnce(t *testing.T) {
+	models := []any{&model.User{}}
+	forEachDatabase(t, models, func(t *testing.T, db testDatabase, conn *gorm.DB) {
+		ctx := context.Background()
+		repo := repository.NewUserRepository(conn, 5*time.Second)
+		newAdmin := func() *model.User {
+			return &model.User{Email: "admin@example.com", PasswordHash: "hash", FullName: "Admin"}
+		}
+
+		t.Run("creates the admin once", func(t *testing.T) {
+			db.reset(t, conn, models...)
+
+			admin := newAdmin()
+			outcome, err := repo.BootstrapAdmin(ctx, admin)
+			require.NoError(t, err)
+			assert.Equal(t, repository.AdminCreated, outcome)
+			assert.Equal(t, model.RoleAdmin, admin.Role)
+
+			outcome, err = repo.BootstrapAdmin(ctx, newAdmin())
+			require.NoError(t, err)
+			assert.Equal(t, repository.AdminExists, outcome)
+		})
+
+		t.Run("promotes an existing user", func(t *testing.T) {
+			db.reset(t, conn, models...)
+			existing := &model.User{Email: "admin@example.com", PasswordHash: "old-hash", FullName: "Existing", Role: model.RoleUser}
+			require.NoError(t, repo.Create(ctx, existing))
+
+			admin := newAdmin()
+			outcome, err := repo.BootstrapAdmin(ctx, admin)
+			require.NoError(t, err)
+			assert.Equal(t, repository.AdminPromoted, outcome)
+			assert.Equal(t, existing.ID, admin.ID)
+
+			found, err := repo.FindByIDWithPassword(ctx, existing.ID.String())
+			require.NoError(t, err)
+			assert.Equal(t, model.RoleAdmin, found.Role)
+			assert.Equal(t, "old-hash", found.PasswordHash, "the user keeps their password")
+		})
+
+		t.Run("replicas starting together", func(t *testing.T) {
+			db.reset(t, conn, models...)
+
+			const replicas = 5
+			outcomes := make([]repository.AdminBootstrap, replicas)
+			var wg sync.WaitGroup
+			for i := 0; i < replicas; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					var err error
+					outcomes[i], err = repo.BootstrapAdmin(ctx, newAdmin())
+					assert.NoError(t, err)
+				}(i)
+			}
+			wg.Wait()
+
+			assert.ElementsMatch(t, []repository.AdminBootstrap{
+				repository.AdminCreated, repository.AdminExists, repository.AdminExists, repository.AdminExists, repository.AdminExists,
+			}, outcomes)
+			admins, err := repo.FindByRole(ctx, model.RoleAdmin)
+			require.NoError(t, err)
+			assert.Len(t, admins, 1)
+		})
+	})
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserRepository_BootstrapAdmin(t *testing.T) {
+	existingID, createdID := uuid.New(), uuid.New()
+
+	// expectLocked expects the transaction to take the lock and count admins.
+	expectLocked := func(sqlMock sqlmock.Sqlmock, admins int) {
+		sqlMock.ExpectBegin()
+		sqlMock.ExpectExec(`SELECT pg_advisory_xact_lock\(hashtext\(\$1\)\)`).
+			WithArgs(adminBootstrapLock).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		sqlMock.ExpectQuery(`SELECT count\(\*\) FROM "users" WHERE role = \$1`).
+			WithArgs(model.RoleAdmin).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(admins))
+	}
+
+	tests := []struct {
+		name        string
+		mockFn      func(sqlmock.Sqlmock)
+		wantOutcome AdminBootstrap
+		wantID      uuid.UUID
+		wantErr     error
+	}{
+		{
+			name: "admin exists",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				expectLocked(sqlMock, 1)
+				sqlMock.ExpectCommit()
+			},
+			wantOutcome: AdminExists,
+		},
+		{
+			name: "promotes the existing user",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				expectLocked(sqlMock, 0)
+				sqlMock.ExpectQuery(`SELECT \* FROM "users" WHERE email = \$1`).
+					WithArgs("admin@example.com", 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "email", "role"}).AddRow(existingID, "admin@example.com", model.RoleUser))
+				sqlMock.ExpectExec(`UPDATE "users" SET "role"=\$1,"updated_at"=\$2 WHERE "id" = \$3`).
+					WithArgs(model.RoleAdmin, sqlmock.AnyArg(), existingID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				sqlMock.ExpectCommit()
+			},
+			wantOutcome: AdminPromoted,
+			wantID:      existingID,
+		},
+		{
+			name: "creates the user",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				expectLocked(sqlMock, 0)
+				sqlMock.ExpectQuery(`SELECT \* FROM "users" WHERE email = \$1`).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}))
+				sqlMock.ExpectQuery(`INSERT INTO "users"`).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(createdID))
+				sqlMock.ExpectCommit()
+			},
+			wantOutcome: AdminCreated,
+			wantID:      createdID,
+		},
+		{
+			name: "database error",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectExec(`SELECT pg_advisory_xact_lock`).WillReturnError(sql.ErrConnDone)
+				sqlMock.ExpectRollback()
+			},
+			wantErr: sql.ErrConnDone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, _, sqlMock, userRepo := setupTest(t)
+			defer sqlDB.Close()
+			tt.mockFn(sqlMock)
+			user := &model.User{Email: "admin@example.com", PasswordHash: "hash", FullName: "Administrator"}
+
+			outcome, err := userRepo.BootstrapAdmin(context.Background(), user)
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.wantOutcome, outcome)
+			if tt.wantID != uuid.Nil {
+				assert.Equal(t, tt.wantID, user.ID)
+				assert.Equal(t, model.RoleAdmin, user.Role)
+			}
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AnnouncementRepository provides access to the announcements shown to
+// users. Every query it runs is bounded by queryTimeout in addition to any
+// deadline on the caller's context.
+type AnnouncementRepository struct {
+	db           *gorm.DB
+	queryTimeout time.Duration
+}
+
+// NewAnnouncementRepository creates an AnnouncementRepository that bounds each query by queryTimeout.
+func NewAnnouncementRepository(db *gorm.DB, queryTimeout time.Duration) *AnnouncementRepository {
+	return &AnnouncementRepository{db: db, queryTimeout: queryTimeout}
+}
+
+// Create inserts a new announcement.
+// It returns an error if the operation fails, or ErrTimeout if it exceeds the query timeout.
+func (r *AnnouncementRepository) Create(ctx context.Context, announcement *model.Announcement) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	return translateError(ctx, r.db.WithContext(ctx).Create(announcement).Error)
+}
+
+// FindByID retrieves an announcement by its ID.
+// If the announcement is not found or any other error occurs, it returns nil and the error.
+// If the query exceeds its timeout, the error is ErrTimeout.
+func (r *AnnouncementRepository) FindByID(ctx context.Context, id uuid.UUID) (*model.Announcement, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var announcement model.Announcement
+
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&announcement).Error; err != nil {
+		return nil, translateError(ctx, err)
+	}
+
+	return &announcement, nil
+}
+
+// ListActive retrieves the announcements whose window contains at: those
+// starting at or before it and ending after it, latest start first.
+// If the query exceeds its timeout, the error is ErrTimeout.
+func (r *AnnouncementRepository) ListActive(ctx context.Context, at time.Time) ([]model.Announcement, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var announcements []model.Announcement
+	err := r.db.WithContext(ctx).
+		Where("starts_at <= ? AND ends_at > ?", at, at).
+		Order("starts_at DESC, id DESC").
+		Find(&announcements).Error
+	if err != nil {
+		return nil, translateError(ctx, err)
+	}
+	return announcements, nil
+}
+
+// ListPage retrieves up to limit announcements after skipping offset,
+// whether or not they are active, ordered by start time, latest first if
+// desc, along with the total number of announcements.
+func (r *AnnouncementRepository) ListPage(ctx context.Context, offset, limit int, desc bool) ([]model.Announcement, int64, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := r.db.WithContext(ctx).Model(&model.Announcement{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, translateError(ctx, err)
+	}
+	if int64(offset) >= total {
+		return []model.Announcement{}, total, nil
+	}
+
+	order := "starts_at ASC, id ASC"
+	if desc {
+		order = "starts_at DESC, id DESC"
+	}
+
+	var announcements []model.Announcement
+	if err := query.Order(order).Offset(offset).Limit(limit).Find(&announcements).Error; err != nil {
+		return nil, 0, translateError(ctx, err)
+	}
+	return announcements, total, nil
+}
+
+// Update saves the message, severity and window of announcement. It returns
+// ErrNotFound if the announcement does not exist.
+// It returns an error if the operation fails, or ErrTimeout if it exceeds the query timeout.
+func (r *AnnouncementRepository) Update(ctx context.Context, announcement *model.Announcement) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	announcement.UpdatedAt = time.Now()
+	result := r.db.WithContext(ctx).
+		Model(&model.Announcement{}).
+		Where("id = ?", announcement.ID).
+		Updates(map[string]interface{}{
+			"message":    announcement.Message,
+			"severity":   announcement.Severity,
+			"starts_at":  announcement.StartsAt,
+			"ends_at":    announcement.EndsAt,
+			"updated_at": announcement.UpdatedAt,
+		})
+	if result.Error != nil {
+		return translateError(ctx, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Delete removes the announcement with the given ID. It returns ErrNotFound
+// if the announcement does not exist.
+// It returns an error if the operation fails, or ErrTimeout if it exceeds the query timeout.
+func (r *AnnouncementRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	result := r.db.WithContext(ctx).Delete(&model.Announcement{}, "id = ?", id)
+	if result.Error != nil {
+		return translateError(ctx, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupAnnouncementTest(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *AnnouncementRepository) {
+	sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+	return sqlDB, sqlMock, NewAnnouncementRepository(gormDB, testQueryTimeout)
+}
+
+func TestAnnouncementRepository_Create(t *testing.T) {
+	sqlDB, sqlMock, repo := setupAnnouncementTest(t)
+	defer sqlDB.Close()
+	now := time.Now()
+	announcement := &model.Announcement{Message: "Maintenance tonight", Severity: model.SeverityWarning, StartsAt: now, EndsAt: now.Add(time.Hour)}
+	id := uuid.New()
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(`INSERT INTO "announcements"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(id))
+	sqlMock.ExpectCommit()
+
+	err := repo.Create(context.Background(), announcement)
+
+	assert.NoError(t, err)
+	assert.Equal(t, id, announcement.ID)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestAnnouncementRepository_FindByID(t *testing.T) {
+	t.Run("announcement found", func(t *testing.T) {
+		sqlDB, sqlMock, repo := setupAnnouncementTest(t)
+		defer sqlDB.Close()
+		id := uuid.New()
+		sqlMock.ExpectQuery(`SELECT \* FROM "announcements" WHERE id = \$1 (.+) LIMIT \$2`).
+			WithArgs(id, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "severity"}).AddRow(id, model.SeverityInfo))
+
+		got, err := repo.FindByID(context.Background(), id)
+
+		require.NoError(t, err)
+		assert.Equal(t, id, got.ID)
+		assert.Equal(t, model.SeverityInfo, got.Severity)
+	})
+
+	t.Run("announcement not found", func(t *testing.T) {
+		sqlDB, sqlMock, repo := setupAnnouncementTest(t)
+		defer sqlDB.Close()
+		sqlMock.ExpectQuery(`SELECT \* FROM "announcements"`).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		got, err := repo.FindByID(context.Background(), uuid.New())
+
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.Nil(t, got)
+	})
+}
+
+func TestAnnouncementRepository_ListActive(t *testing.T) {
+	t.Run("announcements within their window", func(t *testing.T) {
+		sqlDB, sqlMock, repo := setupAnnouncementTest(t)
+		defer sqlDB.Close()
+		at := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+		sqlMock.ExpectQuery(`SELECT \* FROM "announcements" WHERE starts_at <= \$1 AND ends_at > \$2 ORDER BY starts_at DESC, id DESC`).
+			WithArgs(at, at).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()).AddRow(uuid.New()))
+
+		got, err := repo.ListActive(context.Background(), at)
+
+		require.NoError(t, err)
+		assert.Len(t, got, 2)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("database unavailable", func(t *testing.T) {
+		sqlDB, sqlMock, repo := setupAnnouncementTest(t)
+		defer sqlDB.Close()
+		sqlMock.ExpectQuery(`SELECT \* FROM "announcements"`).
+			WillReturnError(sql.ErrConnDone)
+
+		got, err := repo.ListActive(context.Background(), time.Now())
+
+		assert.ErrorIs(t, err, ErrConn)
+		assert.Nil(t, got)
+	})
+}
+
+func TestAnnouncementRepository_ListPage(t *testing.T) {
+	t.Run("latest start first", func(t *testing.T) {
+		sqlDB, sqlMock, repo := setupAnnouncementTest(t)
+		defer sqlDB.Close()
+		sqlMock.ExpectQuery(`SELECT count\(\*\) FROM "announcements"`).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+		sqlMock.ExpectQuery(`SELECT \* FROM "announcements" ORDER BY starts_at DESC, id DESC LIMIT \$1 OFFSET \$2`).
+			WithArgs(2, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+
+		got, total, err := repo.ListPage(context.Background(), 2, 2, true)
+
+		require.NoError(t, err)
+		assert.Len(t, got, 1)
+		assert.Equal(t, int64(3), total)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("past the last page", func(t *testing.T) {
+		sqlDB, sqlMock, repo := setupAnnouncementTest(t)
+		defer sqlDB.Close()
+		sqlMock.ExpectQuery(`SELECT count\(\*\) FROM "announcements"`).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+		got, total, err := repo.ListPage(context.Background(), 20, 20, false)
+
+		require.NoError(t, err)
+		assert.NotNil(t, got)
+		assert.Empty(t, got)
+		assert.Equal(t, int64(1), total)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+}
+
+func TestAnnouncementRepository_Update(t *testing.T) {
+	now := time.Now()
+	announcement := &model.Announcement{ID: uuid.New(), Message: "Maintenance moved", Severity: model.SeverityCritical, StartsAt: now, EndsAt: now.Add(time.Hour)}
+
+	tests := []struct {
+		name     string
+		affected int64
+		wantErr  error
+	}{
+		{name: "announcement exists", affected: 1},
+		{name: "announcement deleted", affected: 0, wantErr: ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, sqlMock, repo := setupAnnouncementTest(t)
+			defer sqlDB.Close()
+			sqlMock.ExpectBegin()
+			sqlMock.ExpectExec(`UPDATE "announcements" SET "ends_at"=\$1,"message"=\$2,"severity"=\$3,"starts_at"=\$4,"updated_at"=\$5 WHERE id = \$6`).
+				WithArgs(announcement.EndsAt, announcement.Message, announcement.Severity, announcement.StartsAt, sqlmock.AnyArg(), announcement.ID).
+				WillReturnResult(sqlmock.NewResult(0, tt.affected))
+			sqlMock.ExpectCommit()
+
+			err := repo.Update(context.Background(), announcement)
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestAnnouncementRepository_Delete(t *testing.T) {
+	tests := []struct {
+		name     string
+		affected int64
+		err      error
+		wantErr  error
+	}{
+		{name: "announcement exists", affected: 1},
+		{name: "announcement not found", affected: 0, wantErr: ErrNotFound},
+		{name: "database error", err: errors.New("disk full"), wantErr: errors.New("disk full")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, sqlMock, repo := setupAnnouncementTest(t)
+			defer sqlDB.Close()
+			id := uuid.New()
+			sqlMock.ExpectBegin()
+			exec := sqlMock.ExpectExec(`DELETE FROM "announcements" WHERE id = \$1`).WithArgs(id)
+			if tt.err != nil {
+				exec.WillReturnError(tt.err)
+				sqlMock.ExpectRollback()
+			} else {
+				exec.WillReturnResult(sqlmock.NewResult(0, tt.affected))
+				sqlMock.ExpectCommit()
+			}
+
+			err := repo.Delete(context.Background(), id)
+
+			if tt.wantErr != nil {
+				assert.EqualError(t, err, tt.wantErr.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AuditRepository provides access to the audit log. Every query it runs is
+// bounded by queryTimeout in addition to any deadline on the caller's context.
+type AuditRepository struct {
+	db           *gorm.DB
+	queryTimeout time.Duration
+}
+
+// NewAuditRepository creates an AuditRepository that bounds each query by queryTimeout.
+func NewAuditRepository(db *gorm.DB, queryTimeout time.Duration) *AuditRepository {
+	return &AuditRepository{db: db, queryTimeout: queryTimeout}
+}
+
+// Create inserts a new entry into the audit log.
+// It returns an error if the operation fails, or ErrTimeout if it exceeds the query timeout.
+func (r *AuditRepository) Create(ctx context.Context, entry *model.AuditEntry) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	return translateError(ctx, r.db.WithContext(ctx).Create(entry).Error)
+}
+
+// AuditFilter narrows the entries returned by AuditRepository.List. Zero
+// fields do not filter.
+type AuditFilter struct {
+	// ActorID keeps the entries of requests made by this user.
+	ActorID *uuid.UUID
+	// From keeps the entries created at or after this time.
+	From time.Time
+	// To keeps the entries created before this time.
+	To time.Time
+}
+
+// List retrieves up to limit audit entries matching filter, newest first,
+// starting after the position encoded in cursor. An empty cursor starts from
+// the most recent entry.
+//
+// It returns the entries along with the cursor for the next page, which is
+// empty when there are no more rows. If the cursor cannot be decoded,
+// ErrInvalidCursor is returned without querying the database.
+func (r *AuditRepository) List(ctx context.Context, filter AuditFilter, cursor string, limit int) ([]model.AuditEntry, string, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := r.db.WithContext(ctx).
+		Order("created_at DESC, id DESC").
+		Limit(limit + 1)
+
+	if filter.ActorID != nil {
+		query = query.Where("actor_id = ?", *filter.ActorID)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at < ?", filter.To)
+	}
+	if cursor != "" {
+		createdAt, id, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		query = query.Where("(created_at, id) < (?, ?)", createdAt, id)
+	}
+
+	var entries []model.AuditEntry
+	if err := query.Find(&entries).Error; err != nil {
+		return nil, "", translateError(ctx, err)
+	}
+
+	if len(entries) <= limit {
+		return entries, "", nil
+	}
+
+	entries = entries[:limit]
+	last := entries[len(entries)-1]
+	return entries, encodeCursor(last.CreatedAt, last.ID), nil
+}
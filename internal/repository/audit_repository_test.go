@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAuditRepository(t *testing.T) {
+	_, gormDB, _ := testutil.DbMock(t)
+	auditRepo := NewAuditRepository(gormDB, testQueryTimeout)
+	assert.Equal(t, gormDB, auditRepo.db)
+	assert.Equal(t, testQueryTimeout, auditRepo.queryTimeout)
+}
+
+func TestAuditRepository_Create(t *testing.T) {
+	actorID := uuid.New()
+	subjectID := uuid.New()
+	entryID := uuid.New()
+	createdAt := time.Now()
+	body := `{"level":"debug"}`
+
+	tests := []struct {
+		name    string
+		mockFn  func(sqlmock.Sqlmock)
+		wantErr error
+	}{
+		{
+			name: "success",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectQuery(`INSERT INTO "audit_log"`).
+					WithArgs(actorID, subjectID, "PUT", "/api/admin/log-level", 200, "192.0.2.1", int64(12), &body, createdAt).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(entryID))
+				sqlMock.ExpectCommit()
+			},
+		},
+		{
+			name: "database error",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectQuery(`INSERT INTO "audit_log"`).WillReturnError(sql.ErrConnDone)
+				sqlMock.ExpectRollback()
+			},
+			wantErr: sql.ErrConnDone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+			defer sqlDB.Close()
+			tt.mockFn(sqlMock)
+
+			entry := &model.AuditEntry{
+				ActorID:     actorID,
+				SubjectID:   &subjectID,
+				Method:      "PUT",
+				Path:        "/api/admin/log-level",
+				Status:      200,
+				IPAddress:   "192.0.2.1",
+				LatencyMS:   12,
+				RequestBody: &body,
+				CreatedAt:   createdAt,
+			}
+			err := NewAuditRepository(gormDB, testQueryTimeout).Create(context.Background(), entry)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, entryID, entry.ID)
+			}
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestAuditRepository_List(t *testing.T) {
+	actorID := uuid.New()
+	newer := model.AuditEntry{ID: uuid.New(), ActorID: actorID, Method: "GET", Path: "/api/admin/users", Status: 200, CreatedAt: time.Now()}
+	older := model.AuditEntry{ID: uuid.New(), ActorID: actorID, Method: "GET", Path: "/api/admin/users", Status: 200, CreatedAt: newer.CreatedAt.Add(-time.Minute)}
+	columns := []string{"id", "actor_id", "method", "path", "status", "latency_ms", "created_at"}
+	addRow := func(rows *sqlmock.Rows, entry model.AuditEntry) *sqlmock.Rows {
+		return rows.AddRow(entry.ID, entry.ActorID, entry.Method, entry.Path, entry.Status, entry.LatencyMS, entry.CreatedAt)
+	}
+	from := newer.CreatedAt.Add(-time.Hour)
+	to := newer.CreatedAt.Add(time.Hour)
+
+	tests := []struct {
+		name           string
+		filter         AuditFilter
+		cursor         string
+		limit          int
+		mockFn         func(sqlmock.Sqlmock)
+		wantLen        int
+		wantNextCursor string
+		wantErr        error
+	}{
+		{
+			name:  "first page with more results",
+			limit: 1,
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectQuery(`SELECT \* FROM "audit_log" ORDER BY created_at DESC, id DESC LIMIT \$1`).
+					WithArgs(2).
+					WillReturnRows(addRow(addRow(sqlmock.NewRows(columns), newer), older))
+			},
+			wantLen:        1,
+			wantNextCursor: encodeCursor(newer.CreatedAt, newer.ID),
+		},
+		{
+			name:   "filtered page after cursor",
+			filter: AuditFilter{ActorID: &actorID, From: from, To: to},
+			cursor: encodeCursor(newer.CreatedAt, newer.ID),
+			limit:  1,
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectQuery(`SELECT \* FROM "audit_log" WHERE actor_id = \$1 AND created_at >= \$2 AND created_at < \$3 AND \(created_at, id\) < \(\$4, \$5\) ORDER BY created_at DESC, id DESC LIMIT \$6`).
+					WithArgs(actorID, from, to, sqlmock.AnyArg(), newer.ID, 2).
+					WillReturnRows(addRow(sqlmock.NewRows(columns), older))
+			},
+			wantLen: 1,
+		},
+		{
+			name:    "invalid cursor",
+			cursor:  "not-a-cursor",
+			limit:   1,
+			mockFn:  func(sqlMock sqlmock.Sqlmock) {},
+			wantErr: ErrInvalidCursor,
+		},
+		{
+			name:  "database error",
+			limit: 1,
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectQuery(`SELECT \* FROM "audit_log"`).WillReturnError(sql.ErrConnDone)
+			},
+			wantErr: sql.ErrConnDone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+			defer sqlDB.Close()
+			tt.mockFn(sqlMock)
+
+			got, nextCursor, err := NewAuditRepository(gormDB, testQueryTimeout).List(context.Background(), tt.filter, tt.cursor, tt.limit)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, got)
+			} else {
+				assert.NoError(t, err)
+				assert.Len(t, got, tt.wantLen)
+				assert.Equal(t, tt.wantNextCursor, nextCursor)
+			}
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,382 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/datatypes"
+)
+
+// CachedUserRepository is a UserRepository whose FindByID results are cached.
+// Users are cached, and returned by FindByID, without their password hash, so
+// that credentials never reach a shared cache such as Redis; password checks
+// use FindByIDWithPassword, which reads the database. Methods that change a
+// user invalidate its cache entry after the database write succeeds. Cache
+// failures are logged and fall back to the database rather than failing the
+// call, except in RevokeAllTokens, which returns ErrCacheNotCleared when the
+// entry of the user is not cleared.
+//
+// It implements prometheus.Collector, counting lookups served fresh from the
+// cache, served stale from the cache and missed.
+type CachedUserRepository struct {
+	*UserRepository
+	cache UserCache
+
+	// ttl and maxStaleness are zero unless stale-while-revalidate is enabled.
+	ttl          time.Duration
+	maxStaleness time.Duration
+	now          func() time.Time
+	load         func(ctx context.Context, id string) (*model.User, error)
+
+	// refreshing holds the users being refreshed in the background, and
+	// whether they were invalidated since, in which case the refreshed user
+	// may predate the change and is not cached.
+	mu         sync.Mutex
+	refreshing map[string]bool
+	refreshes  sync.WaitGroup
+
+	lookups                 *prometheus.CounterVec
+	hits, staleHits, misses atomic.Uint64
+}
+
+// UserCacheStats counts the lookups a CachedUserRepository served since it
+// was created.
+type UserCacheStats struct {
+	Hits      uint64 `json:"hits"`
+	StaleHits uint64 `json:"stale_hits"`
+	Misses    uint64 `json:"misses"`
+}
+
+// HitRatio returns the share of lookups served from the cache, fresh or
+// stale, or 0 before the first lookup.
+func (s UserCacheStats) HitRatio() float64 {
+	total := s.Hits + s.StaleHits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits+s.StaleHits) / float64(total)
+}
+
+// CachedUserOption configures a CachedUserRepository.
+type CachedUserOption func(*CachedUserRepository)
+
+// WithStaleWhileRevalidate makes FindByID serve users cached for longer than
+// ttl, but not longer than maxStaleness, without waiting for the database. It
+// refreshes such a user in the background, once at a time per user. Users
+// cached for maxStaleness or longer are reloaded before FindByID returns. The
+// cache must keep users for at least maxStaleness for them to be served stale.
+func WithStaleWhileRevalidate(ttl, maxStaleness time.Duration) CachedUserOption {
+	return func(r *CachedUserRepository) {
+		r.ttl = ttl
+		r.maxStaleness = maxStaleness
+	}
+}
+
+// NewCachedUserRepository wraps repo so that users found by ID are kept in c.
+// Without options, every user c returns is served as is. Users are loaded
+// from the primary, so that a lagging replica never puts back in c a user
+// that was just invalidated.
+func NewCachedUserRepository(repo *UserRepository, c UserCache, opts ...CachedUserOption) *CachedUserRepository {
+	r := &CachedUserRepository{
+		UserRepository: repo,
+		cache:          c,
+		now:            time.Now,
+		load:           repo.findByIDOnPrimary,
+		refreshing:     make(map[string]bool),
+		lookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "user_cache_lookups_total",
+			Help: "Users looked up by ID, by whether the cache served them fresh (hit), stale (stale_hit) or not at all (miss).",
+		}, []string{"result"}),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// FindByID returns the cached user if present, otherwise loads it from the
+// database and caches it. Lookup errors, including not found, are not cached.
+// With stale-while-revalidate, a stale user is returned at once and refreshed
+// in the background.
+func (r *CachedUserRepository) FindByID(ctx context.Context, id string) (*model.User, error) {
+	if entry, ok, err := r.cache.Get(ctx, id); err != nil {
+		slog.WarnContext(ctx, "user cache read failed", "user_id", id, "error", err)
+	} else if ok {
+		switch age := r.now().Sub(entry.CachedAt); {
+		case r.maxStaleness == 0 || age < r.ttl:
+			r.lookups.WithLabelValues("hit").Inc()
+			r.hits.Add(1)
+			return entry.User, nil
+		case age < r.maxStaleness:
+			r.lookups.WithLabelValues("stale_hit").Inc()
+			r.staleHits.Add(1)
+			r.refreshInBackground(ctx, id)
+			return entry.User, nil
+		}
+	}
+
+	r.lookups.WithLabelValues("miss").Inc()
+	r.misses.Add(1)
+	user, err := r.load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	user.PasswordHash = ""
+
+	if err := r.cache.Set(ctx, user); err != nil {
+		slog.WarnContext(ctx, "user cache write failed", "user_id", id, "error", err)
+	}
+
+	return user, nil
+}
+
+// refreshInBackground reloads the user with the given ID into the cache,
+// unless a refresh of that user is already running. The refresh outlives the
+// request, so it is not cancelled with ctx. A user that no longer exists is
+// removed from the cache; other errors keep the stale user until it is
+// refreshed again or grows too old to serve.
+func (r *CachedUserRepository) refreshInBackground(ctx context.Context, id string) {
+	r.mu.Lock()
+	if _, ok := r.refreshing[id]; ok {
+		r.mu.Unlock()
+		return
+	}
+	r.refreshing[id] = false
+	r.refreshes.Add(1)
+	r.mu.Unlock()
+
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		defer r.refreshes.Done()
+		defer func() {
+			r.mu.Lock()
+			delete(r.refreshing, id)
+			r.mu.Unlock()
+		}()
+
+		user, err := r.load(ctx, id)
+		switch {
+		case errors.Is(err, ErrNotFound):
+			r.invalidate(ctx, id)
+		case err != nil:
+			slog.WarnContext(ctx, "user cache refresh failed", "user_id", id, "error", err)
+		default:
+			user.PasswordHash = ""
+			// Holding the lock while caching keeps an invalidation from
+			// slipping in between the check and the write.
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			if r.refreshing[id] {
+				return
+			}
+			if err := r.cache.Set(ctx, user); err != nil {
+				slog.WarnContext(ctx, "user cache write failed", "user_id", id, "error", err)
+			}
+		}
+	}()
+}
+
+// Stats returns the counts of the lookups served so far.
+func (r *CachedUserRepository) Stats() UserCacheStats {
+	return UserCacheStats{Hits: r.hits.Load(), StaleHits: r.staleHits.Load(), Misses: r.misses.Load()}
+}
+
+// Describe implements prometheus.Collector.
+func (r *CachedUserRepository) Describe(ch chan<- *prometheus.Desc) {
+	r.lookups.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (r *CachedUserRepository) Collect(ch chan<- prometheus.Metric) {
+	r.lookups.Collect(ch)
+}
+
+// UpdateLastLogin updates the user's last login time and invalidates their cache entry.
+func (r *CachedUserRepository) UpdateLastLogin(ctx context.Context, id uuid.UUID, at time.Time) error {
+	if err := r.UserRepository.UpdateLastLogin(ctx, id, at); err != nil {
+		return err
+	}
+	r.invalidate(ctx, id.String())
+	return nil
+}
+
+// UpdateEmail changes the user's email address and invalidates their cache entry.
+func (r *CachedUserRepository) UpdateEmail(ctx context.Context, id uuid.UUID, email string) error {
+	if err := r.UserRepository.UpdateEmail(ctx, id, email); err != nil {
+		return err
+	}
+	r.invalidate(ctx, id.String())
+	return nil
+}
+
+// UpdatePassword replaces the user's password hash and invalidates their cache entry.
+func (r *CachedUserRepository) UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string, changedAt time.Time) error {
+	if err := r.UserRepository.UpdatePassword(ctx, id, passwordHash, changedAt); err != nil {
+		return err
+	}
+	r.invalidate(ctx, id.String())
+	return nil
+}
+
+// UpdateAvatarURL sets the user's avatar URL and invalidates their cache entry.
+func (r *CachedUserRepository) UpdateAvatarURL(ctx context.Context, id uuid.UUID, url string) error {
+	if err := r.UserRepository.UpdateAvatarURL(ctx, id, url); err != nil {
+		return err
+	}
+	r.invalidate(ctx, id.String())
+	return nil
+}
+
+// IncrementTokenVersion increments the user's token version and invalidates
+// their cache entry, so the new version takes effect on the next request.
+func (r *CachedUserRepository) IncrementTokenVersion(ctx context.Context, id uuid.UUID) error {
+	if err := r.UserRepository.IncrementTokenVersion(ctx, id); err != nil {
+		return err
+	}
+	r.invalidate(ctx, id.String())
+	return nil
+}
+
+// SetUsername sets the user's username if they have none and invalidates their cache entry.
+func (r *CachedUserRepository) SetUsername(ctx context.Context, id uuid.UUID, username string) (bool, error) {
+	set, err := r.UserRepository.SetUsername(ctx, id, username)
+	if err != nil {
+		return false, err
+	}
+	if set {
+		r.invalidate(ctx, id.String())
+	}
+	return set, nil
+}
+
+// SetInitialPassword sets the password of an invited user and invalidates their cache entry.
+func (r *CachedUserRepository) SetInitialPassword(ctx context.Context, id uuid.UUID, passwordHash string, changedAt time.Time) (bool, error) {
+	set, err := r.UserRepository.SetInitialPassword(ctx, id, passwordHash, changedAt)
+	if err != nil {
+		return false, err
+	}
+	if set {
+		r.invalidate(ctx, id.String())
+	}
+	return set, nil
+}
+
+// BootstrapAdmin makes sure there is an admin and, if it promoted a user,
+// invalidates their cache entry.
+func (r *CachedUserRepository) BootstrapAdmin(ctx context.Context, user *model.User) (AdminBootstrap, error) {
+	outcome, err := r.UserRepository.BootstrapAdmin(ctx, user)
+	if err != nil {
+		return AdminExists, err
+	}
+	if outcome == AdminPromoted {
+		r.invalidate(ctx, user.ID.String())
+	}
+	return outcome, nil
+}
+
+// MergeMetadata merges patch into the user's metadata and invalidates their cache entry.
+func (r *CachedUserRepository) MergeMetadata(ctx context.Context, id uuid.UUID, patch map[string]json.RawMessage, validate func(map[string]json.RawMessage) error) (datatypes.JSON, error) {
+	merged, err := r.UserRepository.MergeMetadata(ctx, id, patch, validate)
+	if err != nil {
+		return nil, err
+	}
+	r.invalidate(ctx, id.String())
+	return merged, nil
+}
+
+// AcceptTOS records the user's acceptance of the terms of service and invalidates their cache entry.
+func (r *CachedUserRepository) AcceptTOS(ctx context.Context, id uuid.UUID, version int, at time.Time) error {
+	if err := r.UserRepository.AcceptTOS(ctx, id, version, at); err != nil {
+		return err
+	}
+	r.invalidate(ctx, id.String())
+	return nil
+}
+
+// UpdateNotificationPreferences replaces the user's notification preferences and invalidates their cache entry.
+func (r *CachedUserRepository) UpdateNotificationPreferences(ctx context.Context, id uuid.UUID, preferences model.NotificationPreferences) error {
+	if err := r.UserRepository.UpdateNotificationPreferences(ctx, id, preferences); err != nil {
+		return err
+	}
+	r.invalidate(ctx, id.String())
+	return nil
+}
+
+// UpdateLocale changes the user's locale and time zone and invalidates their cache entry.
+func (r *CachedUserRepository) UpdateLocale(ctx context.Context, id uuid.UUID, locale, timezone string) error {
+	if err := r.UserRepository.UpdateLocale(ctx, id, locale, timezone); err != nil {
+		return err
+	}
+	r.invalidate(ctx, id.String())
+	return nil
+}
+
+// RequestDeletion records the user's deletion request and invalidates their cache entry.
+func (r *CachedUserRepository) RequestDeletion(ctx context.Context, id uuid.UUID, at time.Time) error {
+	if err := r.UserRepository.RequestDeletion(ctx, id, at); err != nil {
+		return err
+	}
+	r.invalidate(ctx, id.String())
+	return nil
+}
+
+// CancelDeletion clears the user's deletion request and invalidates their cache entry.
+func (r *CachedUserRepository) CancelDeletion(ctx context.Context, id uuid.UUID) error {
+	if err := r.UserRepository.CancelDeletion(ctx, id); err != nil {
+		return err
+	}
+	r.invalidate(ctx, id.String())
+	return nil
+}
+
+// RestoreDeleted clears the deletion request of the user and invalidates its cache entry.
+func (r *CachedUserRepository) RestoreDeleted(ctx context.Context, id uuid.UUID) error {
+	if err := r.UserRepository.RestoreDeleted(ctx, id); err != nil {
+		return err
+	}
+	r.invalidate(ctx, id.String())
+	return nil
+}
+
+// PurgeDeletionRequestedBefore erases users whose grace period has passed and
+// invalidates the cache entries of the erased users.
+func (r *CachedUserRepository) PurgeDeletionRequestedBefore(ctx context.Context, before time.Time, limit int) ([]PurgedUser, error) {
+	purged, err := r.UserRepository.PurgeDeletionRequestedBefore(ctx, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range purged {
+		r.invalidate(ctx, user.ID.String())
+	}
+	return purged, nil
+}
+
+// invalidate evicts the cache entry of the user with the given ID for the
+// writes that succeed even if it stays cached, logging a failure rather than
+// returning it. RevokeAllTokens calls evict instead, and returns
+// ErrCacheNotCleared when the entry is not cleared.
+func (r *CachedUserRepository) invalidate(ctx context.Context, id string) {
+	if err := r.evict(ctx, id); err != nil {
+		slog.WarnContext(ctx, "user cache invalidation failed", "user_id", id, "error", err)
+	}
+}
+
+// evict removes the cache entry of the user with the given ID, and keeps a
+// background refresh of them under way from caching what it read before.
+func (r *CachedUserRepository) evict(ctx context.Context, id string) error {
+	r.mu.Lock()
+	if _, ok := r.refreshing[id]; ok {
+		r.refreshing[id] = true
+	}
+	r.mu.Unlock()
+
+	return r.cache.Invalidate(ctx, id)
+}
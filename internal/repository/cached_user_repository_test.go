@@ -0,0 +1,730 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PakornBank/learn-go/internal/cache"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/alicebob/miniredis/v2"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// failingCache is a Cache whose every operation fails, as if the server were down.
+type failingCache struct{}
+
+func (failingCache) Get(context.Context, string) ([]byte, bool, error) {
+	return nil, false, errors.New("cache unavailable")
+}
+
+func (failingCache) Set(context.Context, string, []byte, time.Duration) error {
+	return errors.New("cache unavailable")
+}
+
+func (failingCache) Delete(context.Context, ...string) error {
+	return errors.New("cache unavailable")
+}
+
+func expectFindUserByID(sqlMock sqlmock.Sqlmock, user model.User) {
+	rows := sqlmock.NewRows([]string{"id", "email", "password_hash", "full_name", "role", "created_at", "updated_at"}).
+		AddRow(user.ID, user.Email, user.PasswordHash, user.FullName, user.Role, user.CreatedAt, user.UpdatedAt)
+	sqlMock.ExpectQuery(`SELECT \* FROM "users" WHERE id = \$1`).
+		WithArgs(user.ID, 1).
+		WillReturnRows(rows)
+}
+
+func TestCachedUserRepository_FindByID(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+
+	caches := map[string]func(t *testing.T) cache.Cache{
+		"memory": func(t *testing.T) cache.Cache { return cache.NewMemory() },
+		"redis": func(t *testing.T) cache.Cache {
+			client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+			t.Cleanup(func() { client.Close() })
+			return cache.NewRedis(client)
+		},
+	}
+
+	for name, newCache := range caches {
+		t.Run(name, func(t *testing.T) {
+			sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+			defer sqlDB.Close()
+			repo := NewCachedUserRepository(NewUserRepository(gormDB, testQueryTimeout), NewEncodedUserCache(newCache(t), time.Minute))
+
+			// Only the first lookup reaches the database.
+			expectFindUserByID(sqlMock, mockUser)
+
+			first, err := repo.FindByID(context.Background(), mockUser.ID.String())
+			require.NoError(t, err)
+			second, err := repo.FindByID(context.Background(), mockUser.ID.String())
+			require.NoError(t, err)
+
+			assert.Equal(t, first.ID, second.ID)
+			assert.Equal(t, mockUser.Email, second.Email)
+			assert.Empty(t, first.PasswordHash, "password hashes are never cached")
+			assert.Empty(t, second.PasswordHash)
+			assert.Equal(t, mockUser.Role, second.Role)
+			assert.True(t, mockUser.CreatedAt.Equal(second.CreatedAt))
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestCachedUserRepository_FindByIDWithPassword(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+	defer sqlDB.Close()
+	repo := NewCachedUserRepository(NewUserRepository(gormDB, testQueryTimeout), NewEncodedUserCache(cache.NewMemory(), time.Minute))
+
+	// The cached user lacks the password hash, so it is read from the database.
+	expectFindUserByID(sqlMock, mockUser)
+	expectFindUserByID(sqlMock, mockUser)
+
+	_, err := repo.FindByID(context.Background(), mockUser.ID.String())
+	require.NoError(t, err)
+	user, err := repo.FindByIDWithPassword(context.Background(), mockUser.ID.String())
+	require.NoError(t, err)
+
+	assert.Equal(t, mockUser.PasswordHash, user.PasswordHash)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCachedUserRepository_FindByIDNotFoundIsNotCached(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+	defer sqlDB.Close()
+	repo := NewCachedUserRepository(NewUserRepository(gormDB, testQueryTimeout), NewEncodedUserCache(cache.NewMemory(), time.Minute))
+
+	sqlMock.ExpectQuery(`SELECT \* FROM "users" WHERE id = \$1`).WillReturnError(gorm.ErrRecordNotFound)
+	expectFindUserByID(sqlMock, mockUser)
+
+	_, err := repo.FindByID(context.Background(), mockUser.ID.String())
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	got, err := repo.FindByID(context.Background(), mockUser.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, mockUser.ID, got.ID)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCachedUserRepository_CacheFailureFallsBackToDatabase(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+	defer sqlDB.Close()
+	repo := NewCachedUserRepository(NewUserRepository(gormDB, testQueryTimeout), NewEncodedUserCache(failingCache{}, time.Minute))
+
+	expectFindUserByID(sqlMock, mockUser)
+	got, err := repo.FindByID(context.Background(), mockUser.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, mockUser.ID, got.ID)
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(`UPDATE "users" SET "last_login_at"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+	assert.NoError(t, repo.UpdateLastLogin(context.Background(), mockUser.ID, time.Now()))
+
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCachedUserRepository_UpdateLastLoginInvalidates(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+	defer sqlDB.Close()
+	c := cache.NewMemory()
+	repo := NewCachedUserRepository(NewUserRepository(gormDB, testQueryTimeout), NewEncodedUserCache(c, time.Minute))
+
+	expectFindUserByID(sqlMock, mockUser)
+	_, err := repo.FindByID(context.Background(), mockUser.ID.String())
+	require.NoError(t, err)
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(`UPDATE "users" SET "last_login_at"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+	require.NoError(t, repo.UpdateLastLogin(context.Background(), mockUser.ID, time.Now()))
+
+	_, ok, err := c.Get(context.Background(), userCacheKey(mockUser.ID.String()))
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCachedUserRepository_UpdateEmailInvalidates(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+	defer sqlDB.Close()
+	c := cache.NewMemory()
+	repo := NewCachedUserRepository(NewUserRepository(gormDB, testQueryTimeout), NewEncodedUserCache(c, time.Minute))
+
+	expectFindUserByID(sqlMock, mockUser)
+	_, err := repo.FindByID(context.Background(), mockUser.ID.String())
+	require.NoError(t, err)
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(`UPDATE "users" SET "email"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+	require.NoError(t, repo.UpdateEmail(context.Background(), mockUser.ID, "new@example.com"))
+
+	_, ok, err := c.Get(context.Background(), userCacheKey(mockUser.ID.String()))
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCachedUserRepository_UpdatePasswordInvalidates(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+	defer sqlDB.Close()
+	c := cache.NewMemory()
+	repo := NewCachedUserRepository(NewUserRepository(gormDB, testQueryTimeout), NewEncodedUserCache(c, time.Minute))
+
+	expectFindUserByID(sqlMock, mockUser)
+	_, err := repo.FindByID(context.Background(), mockUser.ID.String())
+	require.NoError(t, err)
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(`UPDATE "users" SET "password_changed_at"=(.+),"password_hash"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+	require.NoError(t, repo.UpdatePassword(context.Background(), mockUser.ID, "new-hash", time.Now()))
+
+	_, ok, err := c.Get(context.Background(), userCacheKey(mockUser.ID.String()))
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCachedUserRepository_UpdateAvatarURLInvalidates(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+	defer sqlDB.Close()
+	c := cache.NewMemory()
+	repo := NewCachedUserRepository(NewUserRepository(gormDB, testQueryTimeout), NewEncodedUserCache(c, time.Minute))
+
+	expectFindUserByID(sqlMock, mockUser)
+	_, err := repo.FindByID(context.Background(), mockUser.ID.String())
+	require.NoError(t, err)
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(`UPDATE "users" SET "avatar_url"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+	require.NoError(t, repo.UpdateAvatarURL(context.Background(), mockUser.ID, "/avatars/new.png"))
+
+	_, ok, err := c.Get(context.Background(), userCacheKey(mockUser.ID.String()))
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCachedUserRepository_IncrementTokenVersionInvalidates(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+	defer sqlDB.Close()
+	c := cache.NewMemory()
+	repo := NewCachedUserRepository(NewUserRepository(gormDB, testQueryTimeout), NewEncodedUserCache(c, time.Minute))
+
+	expectFindUserByID(sqlMock, mockUser)
+	_, err := repo.FindByID(context.Background(), mockUser.ID.String())
+	require.NoError(t, err)
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(`UPDATE "users" SET "token_version"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+	require.NoError(t, repo.IncrementTokenVersion(context.Background(), mockUser.ID))
+
+	_, ok, err := c.Get(context.Background(), userCacheKey(mockUser.ID.String()))
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCachedUserRepository_AcceptTOSInvalidates(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+	defer sqlDB.Close()
+	c := cache.NewMemory()
+	repo := NewCachedUserRepository(NewUserRepository(gormDB, testQueryTimeout), NewEncodedUserCache(c, time.Minute))
+
+	expectFindUserByID(sqlMock, mockUser)
+	_, err := repo.FindByID(context.Background(), mockUser.ID.String())
+	require.NoError(t, err)
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(`UPDATE "users" SET "tos_accepted_at"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+	require.NoError(t, repo.AcceptTOS(context.Background(), mockUser.ID, 2, time.Now()))
+
+	_, ok, err := c.Get(context.Background(), userCacheKey(mockUser.ID.String()))
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCachedUserRepository_UpdateNotificationPreferencesInvalidates(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+	defer sqlDB.Close()
+	c := cache.NewMemory()
+	repo := NewCachedUserRepository(NewUserRepository(gormDB, testQueryTimeout), NewEncodedUserCache(c, time.Minute))
+
+	expectFindUserByID(sqlMock, mockUser)
+	_, err := repo.FindByID(context.Background(), mockUser.ID.String())
+	require.NoError(t, err)
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(`UPDATE "users" SET "notification_preferences"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+	require.NoError(t, repo.UpdateNotificationPreferences(context.Background(), mockUser.ID, model.DefaultNotificationPreferences()))
+
+	_, ok, err := c.Get(context.Background(), userCacheKey(mockUser.ID.String()))
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCachedUserRepository_SetUsernameInvalidates(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+	defer sqlDB.Close()
+	c := cache.NewMemory()
+	repo := NewCachedUserRepository(NewUserRepository(gormDB, testQueryTimeout), NewEncodedUserCache(c, time.Minute))
+
+	expectFindUserByID(sqlMock, mockUser)
+	_, err := repo.FindByID(context.Background(), mockUser.ID.String())
+	require.NoError(t, err)
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(`UPDATE "users" SET "username"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+	set, err := repo.SetUsername(context.Background(), mockUser.ID, "tester")
+	require.NoError(t, err)
+	assert.True(t, set)
+
+	_, ok, err := c.Get(context.Background(), userCacheKey(mockUser.ID.String()))
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCachedUserRepository_BootstrapAdminInvalidates(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+	defer sqlDB.Close()
+	c := cache.NewMemory()
+	repo := NewCachedUserRepository(NewUserRepository(gormDB, testQueryTimeout), NewEncodedUserCache(c, time.Minute))
+
+	expectFindUserByID(sqlMock, mockUser)
+	_, err := repo.FindByID(context.Background(), mockUser.ID.String())
+	require.NoError(t, err)
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(`SELECT pg_advisory_xact_lock`).WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectQuery(`SELECT count\(\*\)`).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	sqlMock.ExpectQuery(`SELECT \* FROM "users" WHERE email`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).AddRow(mockUser.ID, mockUser.Email))
+	sqlMock.ExpectExec(`UPDATE "users" SET "role"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+	outcome, err := repo.BootstrapAdmin(context.Background(), &model.User{Email: mockUser.Email})
+	require.NoError(t, err)
+	assert.Equal(t, AdminPromoted, outcome)
+
+	_, ok, err := c.Get(context.Background(), userCacheKey(mockUser.ID.String()))
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCachedUserRepository_MergeMetadataInvalidates(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+	defer sqlDB.Close()
+	c := cache.NewMemory()
+	repo := NewCachedUserRepository(NewUserRepository(gormDB, testQueryTimeout), NewEncodedUserCache(c, time.Minute))
+
+	expectFindUserByID(sqlMock, mockUser)
+	_, err := repo.FindByID(context.Background(), mockUser.ID.String())
+	require.NoError(t, err)
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(`SELECT "metadata" FROM "users"`).WillReturnRows(sqlmock.NewRows([]string{"metadata"}).AddRow(nil))
+	sqlMock.ExpectExec(`UPDATE "users" SET "metadata"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+	_, err = repo.MergeMetadata(context.Background(), mockUser.ID, map[string]json.RawMessage{"theme": json.RawMessage(`"dark"`)}, acceptMetadata)
+	require.NoError(t, err)
+
+	_, ok, err := c.Get(context.Background(), userCacheKey(mockUser.ID.String()))
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCachedUserRepository_KeepsMetadata(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+	defer sqlDB.Close()
+	repo := NewCachedUserRepository(NewUserRepository(gormDB, testQueryTimeout), NewEncodedUserCache(cache.NewMemory(), time.Minute))
+
+	rows := sqlmock.NewRows([]string{"id", "email", "password_hash", "full_name", "role", "metadata", "created_at", "updated_at"}).
+		AddRow(mockUser.ID, mockUser.Email, mockUser.PasswordHash, mockUser.FullName, mockUser.Role, []byte(`{"theme":"dark"}`), mockUser.CreatedAt, mockUser.UpdatedAt)
+	sqlMock.ExpectQuery(`SELECT \* FROM "users" WHERE id = \$1`).WillReturnRows(rows)
+
+	_, err := repo.FindByID(context.Background(), mockUser.ID.String())
+	require.NoError(t, err)
+	cached, err := repo.FindByID(context.Background(), mockUser.ID.String())
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{"theme":"dark"}`, string(cached.Metadata))
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCachedUserRepository_KeepsAvatarURLUsernameAndTokenVersion(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+	defer sqlDB.Close()
+	repo := NewCachedUserRepository(NewUserRepository(gormDB, testQueryTimeout), NewEncodedUserCache(cache.NewMemory(), time.Minute))
+
+	rows := sqlmock.NewRows([]string{"id", "email", "password_hash", "full_name", "role", "avatar_url", "username", "token_version", "created_at", "updated_at"}).
+		AddRow(mockUser.ID, mockUser.Email, mockUser.PasswordHash, mockUser.FullName, mockUser.Role, "/avatars/user.png", "tester", 3, mockUser.CreatedAt, mockUser.UpdatedAt)
+	sqlMock.ExpectQuery(`SELECT \* FROM "users" WHERE id = \$1`).WillReturnRows(rows)
+
+	_, err := repo.FindByID(context.Background(), mockUser.ID.String())
+	require.NoError(t, err)
+	cached, err := repo.FindByID(context.Background(), mockUser.ID.String())
+	require.NoError(t, err)
+
+	require.NotNil(t, cached.AvatarURL)
+	assert.Equal(t, "/avatars/user.png", *cached.AvatarURL)
+	require.NotNil(t, cached.Username)
+	assert.Equal(t, "tester", *cached.Username)
+	assert.Equal(t, 3, cached.TokenVersion)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCachedUserRepository_KeepsTOSAcceptance(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	acceptedAt := time.Now().Truncate(time.Second)
+	sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+	defer sqlDB.Close()
+	repo := NewCachedUserRepository(NewUserRepository(gormDB, testQueryTimeout), NewEncodedUserCache(cache.NewMemory(), time.Minute))
+
+	rows := sqlmock.NewRows([]string{"id", "email", "password_hash", "full_name", "role", "tos_accepted_version", "tos_accepted_at", "created_at", "updated_at"}).
+		AddRow(mockUser.ID, mockUser.Email, mockUser.PasswordHash, mockUser.FullName, mockUser.Role, 2, acceptedAt, mockUser.CreatedAt, mockUser.UpdatedAt)
+	sqlMock.ExpectQuery(`SELECT \* FROM "users" WHERE id = \$1`).WillReturnRows(rows)
+
+	_, err := repo.FindByID(context.Background(), mockUser.ID.String())
+	require.NoError(t, err)
+	cached, err := repo.FindByID(context.Background(), mockUser.ID.String())
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, cached.TOSAcceptedVersion)
+	require.NotNil(t, cached.TOSAcceptedAt)
+	assert.True(t, acceptedAt.Equal(*cached.TOSAcceptedAt))
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCachedUserRepository_KeepsPasswordChangedAt(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	changedAt := time.Now().Truncate(time.Second)
+	sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+	defer sqlDB.Close()
+	repo := NewCachedUserRepository(NewUserRepository(gormDB, testQueryTimeout), NewEncodedUserCache(cache.NewMemory(), time.Minute))
+
+	rows := sqlmock.NewRows([]string{"id", "email", "password_hash", "full_name", "role", "password_changed_at", "created_at", "updated_at"}).
+		AddRow(mockUser.ID, mockUser.Email, mockUser.PasswordHash, mockUser.FullName, mockUser.Role, changedAt, mockUser.CreatedAt, mockUser.UpdatedAt)
+	sqlMock.ExpectQuery(`SELECT \* FROM "users" WHERE id = \$1`).WillReturnRows(rows)
+
+	_, err := repo.FindByID(context.Background(), mockUser.ID.String())
+	require.NoError(t, err)
+	cached, err := repo.FindByID(context.Background(), mockUser.ID.String())
+	require.NoError(t, err)
+
+	assert.True(t, changedAt.Equal(cached.PasswordChangedAt))
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCachedUserRepository_KeepsNotificationPreferences(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+	defer sqlDB.Close()
+	repo := NewCachedUserRepository(NewUserRepository(gormDB, testQueryTimeout), NewEncodedUserCache(cache.NewMemory(), time.Minute))
+
+	rows := sqlmock.NewRows([]string{"id", "email", "password_hash", "full_name", "role", "notification_preferences", "created_at", "updated_at"}).
+		AddRow(mockUser.ID, mockUser.Email, mockUser.PasswordHash, mockUser.FullName, mockUser.Role,
+			`{"security_alerts":true,"product_updates":true,"newsletter":false}`, mockUser.CreatedAt, mockUser.UpdatedAt)
+	sqlMock.ExpectQuery(`SELECT \* FROM "users" WHERE id = \$1`).WillReturnRows(rows)
+
+	_, err := repo.FindByID(context.Background(), mockUser.ID.String())
+	require.NoError(t, err)
+	cached, err := repo.FindByID(context.Background(), mockUser.ID.String())
+	require.NoError(t, err)
+
+	assert.Equal(t, &model.NotificationPreferences{SecurityAlerts: true, ProductUpdates: true}, cached.NotificationPreferences)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCachedUserRepository_UpdateLocaleInvalidates(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+	defer sqlDB.Close()
+	c := cache.NewMemory()
+	repo := NewCachedUserRepository(NewUserRepository(gormDB, testQueryTimeout), NewEncodedUserCache(c, time.Minute))
+
+	expectFindUserByID(sqlMock, mockUser)
+	_, err := repo.FindByID(context.Background(), mockUser.ID.String())
+	require.NoError(t, err)
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(`UPDATE "users" SET "locale"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+	require.NoError(t, repo.UpdateLocale(context.Background(), mockUser.ID, "th-TH", "Asia/Bangkok"))
+
+	_, ok, err := c.Get(context.Background(), userCacheKey(mockUser.ID.String()))
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCachedUserRepository_KeepsLocale(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+	defer sqlDB.Close()
+	repo := NewCachedUserRepository(NewUserRepository(gormDB, testQueryTimeout), NewEncodedUserCache(cache.NewMemory(), time.Minute))
+
+	rows := sqlmock.NewRows([]string{"id", "email", "password_hash", "full_name", "role", "locale", "timezone", "created_at", "updated_at"}).
+		AddRow(mockUser.ID, mockUser.Email, mockUser.PasswordHash, mockUser.FullName, mockUser.Role, "th-TH", "Asia/Bangkok", mockUser.CreatedAt, mockUser.UpdatedAt)
+	sqlMock.ExpectQuery(`SELECT \* FROM "users" WHERE id = \$1`).WillReturnRows(rows)
+
+	_, err := repo.FindByID(context.Background(), mockUser.ID.String())
+	require.NoError(t, err)
+	cached, err := repo.FindByID(context.Background(), mockUser.ID.String())
+	require.NoError(t, err)
+
+	assert.Equal(t, "th-TH", cached.Locale)
+	assert.Equal(t, "Asia/Bangkok", cached.Timezone)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCachedUserRepository_KeepsDeletionRequest(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	requestedAt := time.Now().Truncate(time.Second)
+	sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+	defer sqlDB.Close()
+	repo := NewCachedUserRepository(NewUserRepository(gormDB, testQueryTimeout), NewEncodedUserCache(cache.NewMemory(), time.Minute))
+
+	rows := sqlmock.NewRows([]string{"id", "email", "password_hash", "full_name", "role", "deletion_requested_at", "created_at", "updated_at"}).
+		AddRow(mockUser.ID, mockUser.Email, mockUser.PasswordHash, mockUser.FullName, mockUser.Role, requestedAt, mockUser.CreatedAt, mockUser.UpdatedAt)
+	sqlMock.ExpectQuery(`SELECT \* FROM "users" WHERE id = \$1`).WillReturnRows(rows)
+
+	_, err := repo.FindByID(context.Background(), mockUser.ID.String())
+	require.NoError(t, err)
+	cached, err := repo.FindByID(context.Background(), mockUser.ID.String())
+	require.NoError(t, err)
+
+	require.NotNil(t, cached.DeletionRequestedAt)
+	assert.True(t, requestedAt.Equal(*cached.DeletionRequestedAt))
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCachedUserRepository_PurgeInvalidates(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+	defer sqlDB.Close()
+	c := cache.NewMemory()
+	repo := NewCachedUserRepository(NewUserRepository(gormDB, testQueryTimeout), NewEncodedUserCache(c, time.Minute))
+
+	expectFindUserByID(sqlMock, mockUser)
+	_, err := repo.FindByID(context.Background(), mockUser.ID.String())
+	require.NoError(t, err)
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(`SELECT "id","avatar_url" FROM "users"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(mockUser.ID))
+	sqlMock.ExpectExec(`DELETE FROM "login_events"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectExec(`DELETE FROM "refresh_tokens"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectExec(`DELETE FROM "email_change_requests"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectExec(`DELETE FROM "login_alerts"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectExec(`DELETE FROM "recovery_requests"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectExec(`DELETE FROM "identities"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectExec(`DELETE FROM "role_grants"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectExec(`DELETE FROM "users"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+	_, err = repo.PurgeDeletionRequestedBefore(context.Background(), time.Now(), 10)
+	require.NoError(t, err)
+
+	_, ok, err := c.Get(context.Background(), userCacheKey(mockUser.ID.String()))
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+// slowLoader stands in for the database behind a CachedUserRepository. It
+// counts its calls and holds each one open until release is closed.
+type slowLoader struct {
+	user    model.User
+	err     error
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func (l *slowLoader) FindByID(context.Context, string) (*model.User, error) {
+	l.calls.Add(1)
+	<-l.release
+	if l.err != nil {
+		return nil, l.err
+	}
+	user := l.user
+	return &user, nil
+}
+
+// newStaleWhileRevalidateRepository returns a repository serving users stale
+// after a minute and for up to ten, backed by loader and a cache holding
+// cached, and a function that moves its clock forward.
+func newStaleWhileRevalidateRepository(loader *slowLoader, cached model.User) (*CachedUserRepository, *LRUUserCache, func(time.Duration)) {
+	now := time.Now()
+	clock := func() time.Time { return now }
+
+	c := NewLRUUserCache(10, time.Hour)
+	c.now = clock
+	_ = c.Set(context.Background(), &cached)
+
+	repo := NewCachedUserRepository(NewUserRepository(nil, testQueryTimeout), c, WithStaleWhileRevalidate(time.Minute, 10*time.Minute))
+	repo.now = clock
+	repo.load = loader.FindByID
+	return repo, c, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestCachedUserRepository_StaleWhileRevalidate(t *testing.T) {
+	cached := testutil.NewMockUser()
+	refreshed := cached
+	refreshed.Email = "refreshed@example.com"
+	loader := &slowLoader{user: refreshed, release: make(chan struct{})}
+	repo, _, advance := newStaleWhileRevalidateRepository(loader, cached)
+	id := cached.ID.String()
+
+	user, err := repo.FindByID(context.Background(), id)
+	require.NoError(t, err)
+	assert.Equal(t, cached.Email, user.Email)
+
+	// Past the TTL, the stale user is served at once while the database is
+	// slow, and only one refresh runs however often it is looked up.
+	advance(2 * time.Minute)
+	for i := 0; i < 10; i++ {
+		user, err := repo.FindByID(context.Background(), id)
+		require.NoError(t, err)
+		assert.Equal(t, cached.Email, user.Email)
+	}
+	assert.Eventually(t, func() bool { return loader.calls.Load() == 1 }, time.Second, time.Millisecond)
+
+	close(loader.release)
+	repo.refreshes.Wait()
+
+	user, err = repo.FindByID(context.Background(), id)
+	require.NoError(t, err)
+	assert.Equal(t, refreshed.Email, user.Email)
+	assert.Equal(t, int32(1), loader.calls.Load())
+
+	assert.Equal(t, 2.0, promtestutil.ToFloat64(repo.lookups.WithLabelValues("hit")))
+	assert.Equal(t, 10.0, promtestutil.ToFloat64(repo.lookups.WithLabelValues("stale_hit")))
+	assert.Equal(t, 0.0, promtestutil.ToFloat64(repo.lookups.WithLabelValues("miss")))
+	assert.Equal(t, UserCacheStats{Hits: 2, StaleHits: 10}, repo.Stats())
+}
+
+func TestUserCacheStats_HitRatio(t *testing.T) {
+	assert.Zero(t, UserCacheStats{}.HitRatio())
+	assert.Equal(t, 0.75, UserCacheStats{Hits: 2, StaleHits: 1, Misses: 1}.HitRatio())
+	assert.Zero(t, UserCacheStats{Misses: 3}.HitRatio())
+}
+
+func TestCachedUserRepository_StaleWhileRevalidateMaxStaleness(t *testing.T) {
+	cached := testutil.NewMockUser()
+	refreshed := cached
+	refreshed.Email = "refreshed@example.com"
+	loader := &slowLoader{user: refreshed, release: make(chan struct{})}
+	close(loader.release)
+	repo, _, advance := newStaleWhileRevalidateRepository(loader, cached)
+
+	// Too stale to serve: the lookup waits for the database.
+	advance(10 * time.Minute)
+	user, err := repo.FindByID(context.Background(), cached.ID.String())
+
+	require.NoError(t, err)
+	assert.Equal(t, refreshed.Email, user.Email)
+	assert.Equal(t, int32(1), loader.calls.Load())
+	assert.Equal(t, 1.0, promtestutil.ToFloat64(repo.lookups.WithLabelValues("miss")))
+}
+
+func TestCachedUserRepository_StrictServesUntilExpiry(t *testing.T) {
+	cached := testutil.NewMockUser()
+	now := time.Now()
+	c := NewLRUUserCache(10, time.Hour)
+	c.now = func() time.Time { return now }
+	require.NoError(t, c.Set(context.Background(), &cached))
+	loader := &slowLoader{release: make(chan struct{})}
+	repo := NewCachedUserRepository(NewUserRepository(nil, testQueryTimeout), c)
+	repo.load = loader.FindByID
+
+	now = now.Add(59 * time.Minute)
+	user, err := repo.FindByID(context.Background(), cached.ID.String())
+
+	require.NoError(t, err)
+	assert.Equal(t, cached.Email, user.Email)
+	assert.Equal(t, int32(0), loader.calls.Load())
+	assert.Equal(t, 1.0, promtestutil.ToFloat64(repo.lookups.WithLabelValues("hit")))
+}
+
+func TestCachedUserRepository_RefreshInvalidatedMeanwhileIsNotCached(t *testing.T) {
+	cached := testutil.NewMockUser()
+	loader := &slowLoader{user: cached, release: make(chan struct{})}
+	repo, c, advance := newStaleWhileRevalidateRepository(loader, cached)
+	id := cached.ID.String()
+
+	advance(2 * time.Minute)
+	_, err := repo.FindByID(context.Background(), id)
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool { return loader.calls.Load() == 1 }, time.Second, time.Millisecond)
+
+	// The user changes while the refresh is reading the old row.
+	repo.invalidate(context.Background(), id)
+	close(loader.release)
+	repo.refreshes.Wait()
+
+	_, ok, err := c.Get(context.Background(), id)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestCachedUserRepository_RefreshErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantCached bool
+	}{
+		{name: "user deleted", err: ErrNotFound},
+		{name: "database timeout", err: ErrTimeout, wantCached: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cached := testutil.NewMockUser()
+			loader := &slowLoader{err: tt.err, release: make(chan struct{})}
+			close(loader.release)
+			repo, c, advance := newStaleWhileRevalidateRepository(loader, cached)
+
+			advance(2 * time.Minute)
+			_, err := repo.FindByID(context.Background(), cached.ID.String())
+			require.NoError(t, err)
+			repo.refreshes.Wait()
+
+			_, ok, err := c.Get(context.Background(), cached.ID.String())
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantCached, ok)
+		})
+	}
+}
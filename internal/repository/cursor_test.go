@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursor_RoundTrip(t *testing.T) {
+	createdAt := time.Date(2024, time.January, 2, 3, 4, 5, 123456000, time.UTC)
+	id := uuid.New()
+
+	gotCreatedAt, gotID, err := decodeCursor(encodeCursor(createdAt, id))
+	assert.NoError(t, err)
+	assert.True(t, createdAt.Equal(gotCreatedAt))
+	assert.Equal(t, id, gotID)
+}
+
+func TestDecodeCursor_Invalid(t *testing.T) {
+	encode := func(raw string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(raw))
+	}
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{name: "not base64", cursor: "!!!"},
+		{name: "missing separator", cursor: encode("2024-01-02T03:04:05Z")},
+		{name: "invalid timestamp", cursor: encode("yesterday|" + uuid.NewString())},
+		{name: "invalid id", cursor: encode("2024-01-02T03:04:05Z|not-a-uuid")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := decodeCursor(tt.cursor)
+			assert.ErrorIs(t, err, ErrInvalidCursor)
+		})
+	}
+}
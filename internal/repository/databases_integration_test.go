@@ -0,0 +1,139 @@
+//go:build integration
+
+package repository_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/PakornBank/learn-go/internal/database"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	tcpostgres "github.com/testcontainers/testcontainers-go/modules/postgres"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testDatabase is a database the integration tests run against.
+type testDatabase struct {
+	// name is the subtest the database's tests run in, so that
+	// -run 'Contract/sqlite' selects a single database.
+	name string
+	// open returns the database with the tables of models migrated and empty,
+	// or skips t if the database is not available.
+	open func(t *testing.T, models ...any) *gorm.DB
+	// reset empties the tables of models.
+	reset func(t *testing.T, db *gorm.DB, models ...any)
+}
+
+// testDatabases are PostgreSQL, which the application is deployed on, and an
+// in-memory SQLite database, which runs anywhere and catches queries that
+// only PostgreSQL understands.
+var testDatabases = []testDatabase{
+	{
+		name: "postgres",
+		open: func(t *testing.T, models ...any) *gorm.DB {
+			return openTestDatabase(t, postgres.Open(postgresURL(t)), models...)
+		},
+		reset: func(t *testing.T, db *gorm.DB, models ...any) {
+			for _, m := range models {
+				require.NoError(t, db.Exec(fmt.Sprintf(`TRUNCATE TABLE %s CASCADE`, tableName(t, db, m))).Error)
+			}
+		},
+	},
+	{
+		name: "sqlite",
+		open: func(t *testing.T, models ...any) *gorm.DB {
+			// Every connection to :memory: opens a database of its own.
+			db := openTestDatabase(t, sqlite.Open("file::memory:"), models...)
+			sqlDB, err := db.DB()
+			require.NoError(t, err)
+			sqlDB.SetMaxOpenConns(1)
+			t.Cleanup(func() { sqlDB.Close() })
+			return db
+		},
+		reset: func(t *testing.T, db *gorm.DB, models ...any) {
+			for _, m := range models {
+				require.NoError(t, db.Exec(fmt.Sprintf(`DELETE FROM %s`, tableName(t, db, m))).Error)
+			}
+		},
+	},
+}
+
+// testPostgresImage is the PostgreSQL image started when TEST_DATABASE_URL
+// is not set.
+const testPostgresImage = "postgres:15-alpine"
+
+// testPostgres is the PostgreSQL container the tests share, started by the
+// first of them to need it.
+var testPostgres struct {
+	once      sync.Once
+	container *tcpostgres.PostgresContainer
+	url       string
+	err       error
+}
+
+// postgresURL returns the URL of the PostgreSQL database to test against:
+// TEST_DATABASE_URL if it is set, or else a disposable container started
+// with testcontainers. It skips t if neither is available, such as when
+// Docker is not running.
+func postgresURL(t *testing.T) string {
+	t.Helper()
+	if url := os.Getenv("TEST_DATABASE_URL"); url != "" {
+		return url
+	}
+
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+	testPostgres.once.Do(func() {
+		ctx := context.Background()
+		testPostgres.container, testPostgres.err = tcpostgres.Run(ctx, testPostgresImage, tcpostgres.BasicWaitStrategies())
+		if testPostgres.err != nil {
+			return
+		}
+		testPostgres.url, testPostgres.err = testPostgres.container.ConnectionString(ctx, "sslmode=disable")
+	})
+	require.NoError(t, testPostgres.err, "failed to start PostgreSQL")
+	return testPostgres.url
+}
+
+// TestMain stops the PostgreSQL container, if one was started, once the
+// tests are done.
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if testPostgres.container != nil {
+		_ = testPostgres.container.Terminate(context.Background())
+	}
+	os.Exit(code)
+}
+
+// forEachDatabase runs fn in a subtest for each of the testDatabases, with
+// the tables of models migrated and emptied.
+func forEachDatabase(t *testing.T, models []any, fn func(t *testing.T, db testDatabase, conn *gorm.DB)) {
+	for _, db := range testDatabases {
+		t.Run(db.name, func(t *testing.T) {
+			conn := db.open(t, models...)
+			db.reset(t, conn, models...)
+			fn(t, db, conn)
+		})
+	}
+}
+
+func openTestDatabase(t *testing.T, dialector gorm.Dialector, models ...any) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(dialector, &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+	require.NoError(t, database.Migrate(db, models...))
+	return db
+}
+
+func tableName(t *testing.T, db *gorm.DB, model any) string {
+	t.Helper()
+	stmt := &gorm.Statement{DB: db}
+	require.NoError(t, stmt.Parse(model))
+	return stmt.Schema.Table
+}
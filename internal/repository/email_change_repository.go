@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// EmailChangeRepository provides access to pending email change requests. Every
+// query it runs is bounded by queryTimeout in addition to any deadline on the caller's context.
+type EmailChangeRepository struct {
+	db           *gorm.DB
+	queryTimeout time.Duration
+}
+
+// NewEmailChangeRepository creates an EmailChangeRepository that bounds each query by queryTimeout.
+func NewEmailChangeRepository(db *gorm.DB, queryTimeout time.Duration) *EmailChangeRepository {
+	return &EmailChangeRepository{db: db, queryTimeout: queryTimeout}
+}
+
+// Replace stores request as the user's pending email change, deleting any
+// request the user made before in the same transaction, so only the token
+// sent last can be confirmed.
+// It returns an error if the operation fails, or ErrTimeout if it exceeds the query timeout.
+func (r *EmailChangeRepository) Replace(ctx context.Context, request *model.EmailChangeRequest) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", request.UserID).Delete(&model.EmailChangeRequest{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(request).Error
+	})
+
+	return translateError(ctx, err)
+}
+
+// FindByHash retrieves a pending email change by the hash of its confirmation token.
+// If the request is not found or any other error occurs, it returns nil and the error.
+// If the query exceeds its timeout, the error is ErrTimeout.
+func (r *EmailChangeRepository) FindByHash(ctx context.Context, tokenHash string) (*model.EmailChangeRequest, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var request model.EmailChangeRequest
+
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&request).Error; err != nil {
+		return nil, translateError(ctx, err)
+	}
+
+	return &request, nil
+}
+
+// Delete removes the email change request with the given ID.
+// It returns an error if the operation fails, or ErrTimeout if it exceeds the query timeout.
+func (r *EmailChangeRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	return translateError(ctx, r.db.WithContext(ctx).Delete(&model.EmailChangeRequest{}, "id = ?", id).Error)
+}
@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func setupEmailChangeTest(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *EmailChangeRepository) {
+	sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+	changeRepo := NewEmailChangeRepository(gormDB, testQueryTimeout)
+	return sqlDB, sqlMock, changeRepo
+}
+
+func newMockEmailChangeRequest() model.EmailChangeRequest {
+	return model.EmailChangeRequest{
+		ID:        uuid.New(),
+		UserID:    uuid.New(),
+		NewEmail:  "new@example.com",
+		TokenHash: "token-hash",
+		ExpiresAt: time.Now().Add(24 * time.Hour),
+		CreatedAt: time.Now(),
+	}
+}
+
+func TestNewEmailChangeRepository(t *testing.T) {
+	_, gormDB, _ := testutil.DbMock(t)
+	changeRepo := NewEmailChangeRepository(gormDB, testQueryTimeout)
+	assert.Equal(t, gormDB, changeRepo.db)
+	assert.Equal(t, testQueryTimeout, changeRepo.queryTimeout)
+}
+
+func TestEmailChangeRepository_Replace(t *testing.T) {
+	mockRequest := newMockEmailChangeRequest()
+
+	tests := []struct {
+		name    string
+		mockFn  func(sqlmock.Sqlmock)
+		wantErr error
+	}{
+		{
+			name: "replaces pending request",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectExec(`DELETE FROM "email_change_requests" WHERE user_id = \$1`).
+					WithArgs(mockRequest.UserID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				sqlMock.ExpectQuery(`INSERT INTO "email_change_requests"`).
+					WithArgs(mockRequest.UserID, mockRequest.NewEmail, mockRequest.TokenHash, mockRequest.ExpiresAt).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(mockRequest.ID, mockRequest.CreatedAt))
+				sqlMock.ExpectCommit()
+			},
+		},
+		{
+			name: "insert fails",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectExec(`DELETE FROM "email_change_requests"`).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				sqlMock.ExpectQuery(`INSERT INTO "email_change_requests"`).
+					WillReturnError(sql.ErrConnDone)
+				sqlMock.ExpectRollback()
+			},
+			wantErr: sql.ErrConnDone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, sqlMock, changeRepo := setupEmailChangeTest(t)
+			defer sqlDB.Close()
+			tt.mockFn(sqlMock)
+
+			request := &model.EmailChangeRequest{
+				UserID:    mockRequest.UserID,
+				NewEmail:  mockRequest.NewEmail,
+				TokenHash: mockRequest.TokenHash,
+				ExpiresAt: mockRequest.ExpiresAt,
+			}
+			err := changeRepo.Replace(context.Background(), request)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, mockRequest.ID, request.ID)
+			}
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestEmailChangeRepository_FindByHash(t *testing.T) {
+	mockRequest := newMockEmailChangeRequest()
+	columns := []string{"id", "user_id", "new_email", "token_hash", "expires_at", "created_at"}
+
+	tests := []struct {
+		name        string
+		mockFn      func(sqlmock.Sqlmock)
+		wantRequest *model.EmailChangeRequest
+		wantErr     error
+	}{
+		{
+			name: "request found",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows(columns).AddRow(mockRequest.ID, mockRequest.UserID, mockRequest.NewEmail,
+					mockRequest.TokenHash, mockRequest.ExpiresAt, mockRequest.CreatedAt)
+				sqlMock.ExpectQuery(`SELECT .* FROM "email_change_requests" WHERE token_hash = \$1 (.+) LIMIT \$2`).
+					WithArgs(mockRequest.TokenHash, 1).
+					WillReturnRows(rows)
+			},
+			wantRequest: &mockRequest,
+		},
+		{
+			name: "request not found",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectQuery(`SELECT .* FROM "email_change_requests" WHERE token_hash = \$1 (.+) LIMIT \$2`).
+					WithArgs(mockRequest.TokenHash, 1).
+					WillReturnRows(sqlmock.NewRows(columns))
+			},
+			wantErr: gorm.ErrRecordNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, sqlMock, changeRepo := setupEmailChangeTest(t)
+			defer sqlDB.Close()
+			tt.mockFn(sqlMock)
+
+			got, err := changeRepo.FindByHash(context.Background(), mockRequest.TokenHash)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, got)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantRequest, got)
+			}
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestEmailChangeRepository_Delete(t *testing.T) {
+	id := uuid.New()
+
+	sqlDB, sqlMock, changeRepo := setupEmailChangeTest(t)
+	defer sqlDB.Close()
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(`DELETE FROM "email_change_requests" WHERE id = \$1`).
+		WithArgs(id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+
+	err := changeRepo.Delete(context.Background(), id)
+
+	assert.NoError(t, err)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
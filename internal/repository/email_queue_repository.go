@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// EmailQueueRepository provides access to the email queue. Every query it runs
+// is bounded by queryTimeout in addition to any deadline on the caller's context.
+type EmailQueueRepository struct {
+	db           *gorm.DB
+	queryTimeout time.Duration
+}
+
+// NewEmailQueueRepository creates an EmailQueueRepository that bounds each query by queryTimeout.
+func NewEmailQueueRepository(db *gorm.DB, queryTimeout time.Duration) *EmailQueueRepository {
+	return &EmailQueueRepository{db: db, queryTimeout: queryTimeout}
+}
+
+// Enqueue inserts a new email into the queue.
+// It returns an error if the operation fails, or ErrTimeout if it exceeds the query timeout.
+func (r *EmailQueueRepository) Enqueue(ctx context.Context, email *model.QueuedEmail) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	return translateError(ctx, r.db.WithContext(ctx).Create(email).Error)
+}
+
+// FetchDue retrieves up to limit pending emails whose next attempt is due at
+// now, the longest due first.
+// It returns an error if the operation fails, or ErrTimeout if it exceeds the query timeout.
+func (r *EmailQueueRepository) FetchDue(ctx context.Context, now time.Time, limit int) ([]model.QueuedEmail, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var emails []model.QueuedEmail
+	err := r.db.WithContext(ctx).
+		Where("status = ? AND next_attempt_at <= ?", model.EmailPending, now).
+		Order("next_attempt_at ASC").
+		Limit(limit).
+		Find(&emails).Error
+	if err != nil {
+		return nil, translateError(ctx, err)
+	}
+
+	return emails, nil
+}
+
+// Delete removes the email with the given ID from the queue, once it is sent.
+// It returns an error if the operation fails, or ErrTimeout if it exceeds the query timeout.
+func (r *EmailQueueRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	return translateError(ctx, r.db.WithContext(ctx).Delete(&model.QueuedEmail{}, "id = ?", id).Error)
+}
+
+// MarkFailed records a failed attempt to send the email with the given ID,
+// keeping it pending so it is retried at nextAttemptAt.
+// It returns an error if the operation fails, or ErrTimeout if it exceeds the query timeout.
+func (r *EmailQueueRepository) MarkFailed(ctx context.Context, id uuid.UUID, reason string, nextAttemptAt time.Time) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	err := r.db.WithContext(ctx).Model(&model.QueuedEmail{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"attempts":        gorm.Expr("attempts + 1"),
+			"last_error":      reason,
+			"next_attempt_at": nextAttemptAt,
+		}).Error
+
+	return translateError(ctx, err)
+}
+
+// MarkDead records the last failed attempt to send the email with the given
+// ID, moving it to the dead letters, which are not retried.
+// It returns an error if the operation fails, or ErrTimeout if it exceeds the query timeout.
+func (r *EmailQueueRepository) MarkDead(ctx context.Context, id uuid.UUID, reason string) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	err := r.db.WithContext(ctx).Model(&model.QueuedEmail{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":     model.EmailDead,
+			"attempts":   gorm.Expr("attempts + 1"),
+			"last_error": reason,
+		}).Error
+
+	return translateError(ctx, err)
+}
+
+// ListDead retrieves up to limit dead-lettered emails, newest first, starting
+// after the position encoded in cursor. An empty cursor starts from the most
+// recent email.
+//
+// It returns the emails along with the cursor for the next page, which is
+// empty when there are no more rows. If the cursor cannot be decoded,
+// ErrInvalidCursor is returned without querying the database.
+func (r *EmailQueueRepository) ListDead(ctx context.Context, cursor string, limit int) ([]model.QueuedEmail, string, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := r.db.WithContext(ctx).
+		Where("status = ?", model.EmailDead).
+		Order("created_at DESC, id DESC").
+		Limit(limit + 1)
+
+	if cursor != "" {
+		createdAt, id, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		query = query.Where("(created_at, id) < (?, ?)", createdAt, id)
+	}
+
+	var emails []model.QueuedEmail
+	if err := query.Find(&emails).Error; err != nil {
+		return nil, "", translateError(ctx, err)
+	}
+
+	if len(emails) <= limit {
+		return emails, "", nil
+	}
+
+	emails = emails[:limit]
+	last := emails[len(emails)-1]
+	return emails, encodeCursor(last.CreatedAt, last.ID), nil
+}
+
+// Retry moves the dead-lettered email with the given ID back to the queue,
+// with its attempts reset, to be sent at the given time.
+// It returns ErrNotFound if there is no such dead letter, an error if the
+// operation fails, or ErrTimeout if it exceeds the query timeout.
+func (r *EmailQueueRepository) Retry(ctx context.Context, id uuid.UUID, at time.Time) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	result := r.db.WithContext(ctx).Model(&model.QueuedEmail{}).
+		Where("id = ? AND status = ?", id, model.EmailDead).
+		Updates(map[string]interface{}{
+			"status":          model.EmailPending,
+			"attempts":        0,
+			"next_attempt_at": at,
+		})
+	if result.Error != nil {
+		return translateError(ctx, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
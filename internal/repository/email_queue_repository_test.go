@@ -0,0 +1,250 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupEmailQueueTest(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *EmailQueueRepository) {
+	sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+	emailQueueRepo := NewEmailQueueRepository(gormDB, testQueryTimeout)
+	return sqlDB, sqlMock, emailQueueRepo
+}
+
+func TestNewEmailQueueRepository(t *testing.T) {
+	_, gormDB, _ := testutil.DbMock(t)
+	emailQueueRepo := NewEmailQueueRepository(gormDB, testQueryTimeout)
+	assert.Equal(t, gormDB, emailQueueRepo.db)
+	assert.Equal(t, testQueryTimeout, emailQueueRepo.queryTimeout)
+}
+
+func TestEmailQueueRepository_Enqueue(t *testing.T) {
+	sqlDB, sqlMock, emailQueueRepo := setupEmailQueueTest(t)
+	defer sqlDB.Close()
+
+	email := &model.QueuedEmail{Recipient: "test@example.com", Subject: "Hello", Status: model.EmailPending, NextAttemptAt: time.Now()}
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(`INSERT INTO "email_queue"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	sqlMock.ExpectCommit()
+
+	err := emailQueueRepo.Enqueue(context.Background(), email)
+
+	assert.NoError(t, err)
+	assert.NotEqual(t, uuid.Nil, email.ID)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestEmailQueueRepository_FetchDue(t *testing.T) {
+	sqlDB, sqlMock, emailQueueRepo := setupEmailQueueTest(t)
+	defer sqlDB.Close()
+
+	emailID := uuid.New()
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"id", "recipient", "status", "attempts", "next_attempt_at"}).
+		AddRow(emailID, "test@example.com", model.EmailPending, 1, now.Add(-time.Second))
+	sqlMock.ExpectQuery(`SELECT \* FROM "email_queue" WHERE status = \$1 AND next_attempt_at <= \$2 ORDER BY next_attempt_at ASC LIMIT \$3`).
+		WithArgs(model.EmailPending, now, 10).
+		WillReturnRows(rows)
+
+	emails, err := emailQueueRepo.FetchDue(context.Background(), now, 10)
+
+	assert.NoError(t, err)
+	assert.Len(t, emails, 1)
+	assert.Equal(t, emailID, emails[0].ID)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestEmailQueueRepository_Delete(t *testing.T) {
+	sqlDB, sqlMock, emailQueueRepo := setupEmailQueueTest(t)
+	defer sqlDB.Close()
+
+	emailID := uuid.New()
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(`DELETE FROM "email_queue" WHERE id = \$1`).
+		WithArgs(emailID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+
+	err := emailQueueRepo.Delete(context.Background(), emailID)
+
+	assert.NoError(t, err)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestEmailQueueRepository_MarkFailed(t *testing.T) {
+	sqlDB, sqlMock, emailQueueRepo := setupEmailQueueTest(t)
+	defer sqlDB.Close()
+
+	emailID := uuid.New()
+	next := time.Now().Add(time.Minute)
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(`UPDATE "email_queue" SET "attempts"=attempts \+ 1,"last_error"=\$1,"next_attempt_at"=\$2,"updated_at"=\$3 WHERE id = \$4`).
+		WithArgs("connection refused", next, sqlmock.AnyArg(), emailID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+
+	err := emailQueueRepo.MarkFailed(context.Background(), emailID, "connection refused", next)
+
+	assert.NoError(t, err)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestEmailQueueRepository_MarkDead(t *testing.T) {
+	sqlDB, sqlMock, emailQueueRepo := setupEmailQueueTest(t)
+	defer sqlDB.Close()
+
+	emailID := uuid.New()
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(`UPDATE "email_queue" SET "attempts"=attempts \+ 1,"last_error"=\$1,"status"=\$2,"updated_at"=\$3 WHERE id = \$4`).
+		WithArgs("mailbox unavailable", model.EmailDead, sqlmock.AnyArg(), emailID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+
+	err := emailQueueRepo.MarkDead(context.Background(), emailID, "mailbox unavailable")
+
+	assert.NoError(t, err)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestEmailQueueRepository_ListDead(t *testing.T) {
+	newer := model.QueuedEmail{ID: uuid.New(), Recipient: "a@example.com", Status: model.EmailDead, CreatedAt: time.Now()}
+	older := model.QueuedEmail{ID: uuid.New(), Recipient: "b@example.com", Status: model.EmailDead, CreatedAt: newer.CreatedAt.Add(-time.Minute)}
+	columns := []string{"id", "recipient", "status", "created_at"}
+	addRow := func(rows *sqlmock.Rows, email model.QueuedEmail) *sqlmock.Rows {
+		return rows.AddRow(email.ID, email.Recipient, email.Status, email.CreatedAt)
+	}
+
+	tests := []struct {
+		name           string
+		cursor         string
+		limit          int
+		mockFn         func(sqlmock.Sqlmock)
+		wantLen        int
+		wantNextCursor string
+		wantErr        error
+	}{
+		{
+			name:  "first page with more results",
+			limit: 1,
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectQuery(`SELECT \* FROM "email_queue" WHERE status = \$1 ORDER BY created_at DESC, id DESC LIMIT \$2`).
+					WithArgs(model.EmailDead, 2).
+					WillReturnRows(addRow(addRow(sqlmock.NewRows(columns), newer), older))
+			},
+			wantLen:        1,
+			wantNextCursor: encodeCursor(newer.CreatedAt, newer.ID),
+		},
+		{
+			name:   "page after cursor",
+			cursor: encodeCursor(newer.CreatedAt, newer.ID),
+			limit:  1,
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectQuery(`SELECT \* FROM "email_queue" WHERE status = \$1 AND \(created_at, id\) < \(\$2, \$3\) ORDER BY created_at DESC, id DESC LIMIT \$4`).
+					WithArgs(model.EmailDead, sqlmock.AnyArg(), newer.ID, 2).
+					WillReturnRows(addRow(sqlmock.NewRows(columns), older))
+			},
+			wantLen: 1,
+		},
+		{
+			name:    "invalid cursor",
+			cursor:  "not-a-cursor",
+			limit:   1,
+			mockFn:  func(sqlMock sqlmock.Sqlmock) {},
+			wantErr: ErrInvalidCursor,
+		},
+		{
+			name:  "database error",
+			limit: 1,
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectQuery(`SELECT \* FROM "email_queue"`).WillReturnError(sql.ErrConnDone)
+			},
+			wantErr: sql.ErrConnDone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+			defer sqlDB.Close()
+			tt.mockFn(sqlMock)
+
+			got, nextCursor, err := NewEmailQueueRepository(gormDB, testQueryTimeout).ListDead(context.Background(), tt.cursor, tt.limit)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, got)
+			} else {
+				assert.NoError(t, err)
+				assert.Len(t, got, tt.wantLen)
+				assert.Equal(t, tt.wantNextCursor, nextCursor)
+			}
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestEmailQueueRepository_Retry(t *testing.T) {
+	emailID := uuid.New()
+	at := time.Now()
+
+	tests := []struct {
+		name    string
+		mockFn  func(sqlmock.Sqlmock)
+		wantErr error
+	}{
+		{
+			name: "requeues dead letter",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectExec(`UPDATE "email_queue" SET "attempts"=\$1,"next_attempt_at"=\$2,"status"=\$3,"updated_at"=\$4 WHERE id = \$5 AND status = \$6`).
+					WithArgs(0, at, model.EmailPending, sqlmock.AnyArg(), emailID, model.EmailDead).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				sqlMock.ExpectCommit()
+			},
+		},
+		{
+			name: "not a dead letter",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectExec(`UPDATE "email_queue"`).WillReturnResult(sqlmock.NewResult(0, 0))
+				sqlMock.ExpectCommit()
+			},
+			wantErr: ErrNotFound,
+		},
+		{
+			name: "database error",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectExec(`UPDATE "email_queue"`).WillReturnError(sql.ErrConnDone)
+				sqlMock.ExpectRollback()
+			},
+			wantErr: sql.ErrConnDone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, sqlMock, emailQueueRepo := setupEmailQueueTest(t)
+			defer sqlDB.Close()
+			tt.mockFn(sqlMock)
+
+			err := emailQueueRepo.Retry(context.Background(), emailID, at)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// Errors returned at the repository boundary in place of gorm and driver
+// errors, so callers can tell failures apart without knowing the database.
+var (
+	// ErrTimeout is returned when a query does not complete before its deadline,
+	// either the repository's per-query timeout or a shorter one already set on
+	// the incoming context.
+	ErrTimeout = errors.New("database query timed out")
+	// ErrNotFound is returned when a lookup matches no row.
+	ErrNotFound = errors.New("record not found")
+	// ErrDuplicate is returned when a write violates a unique constraint.
+	ErrDuplicate = errors.New("record already exists")
+	// ErrConn is returned when the database cannot be reached or the
+	// connection is lost.
+	ErrConn = errors.New("database unavailable")
+)
+
+// uniqueViolation is the Postgres SQLSTATE of a unique constraint violation.
+const uniqueViolation = "23505"
+
+// sqliteUniqueViolation starts the message of a SQLite unique constraint
+// violation, which the SQLite driver reports without a code gorm translates.
+const sqliteUniqueViolation = "UNIQUE constraint failed"
+
+// translatedError is a repository sentinel that keeps the gorm or driver
+// error it replaced. Its message is the sentinel's, so that driver details do
+// not end up in responses, while errors.Is still matches the original cause.
+type translatedError struct {
+	sentinel error
+	cause    error
+}
+
+func (e *translatedError) Error() string   { return e.sentinel.Error() }
+func (e *translatedError) Unwrap() []error { return []error{e.sentinel, e.cause} }
+
+// translateError converts a gorm or driver error into the matching repository
+// sentinel. An error caused by the query context's deadline becomes
+// ErrTimeout, whatever error the driver reported for it. Errors without a
+// matching sentinel are returned unchanged.
+func translateError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return ErrTimeout
+	}
+
+	var sentinel error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		sentinel = ErrNotFound
+	case errors.Is(err, gorm.ErrDuplicatedKey), pgCode(err) == uniqueViolation:
+		sentinel = ErrDuplicate
+	case isConnError(err):
+		sentinel = ErrConn
+	case strings.HasPrefix(err.Error(), sqliteUniqueViolation):
+		sentinel = ErrDuplicate
+	default:
+		return err
+	}
+	return &translatedError{sentinel: sentinel, cause: err}
+}
+
+// pgCode returns the SQLSTATE of a Postgres error, or an empty string if err
+// did not come from the server.
+func pgCode(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code
+	}
+	return ""
+}
+
+// isConnError reports whether err means the database could not be reached or
+// dropped the connection: a failed connect, a network error, a closed
+// connection, or a server error of the connection exception class (08) or of
+// an operator shutting the server down (57P).
+func isConnError(err error) bool {
+	var connectErr *pgconn.ConnectError
+	var netErr net.Error
+	code := pgCode(err)
+	return errors.As(err, &connectErr) ||
+		errors.As(err, &netErr) ||
+		errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, sql.ErrConnDone) ||
+		strings.HasPrefix(code, "08") ||
+		strings.HasPrefix(code, "57P")
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestTranslateError(t *testing.T) {
+	other := errors.New("syntax error")
+
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{name: "nil", err: nil, want: nil},
+		{name: "record not found", err: gorm.ErrRecordNotFound, want: ErrNotFound},
+		{name: "wrapped record not found", err: fmt.Errorf("find user: %w", gorm.ErrRecordNotFound), want: ErrNotFound},
+		{name: "unique violation", err: &pgconn.PgError{Code: "23505"}, want: ErrDuplicate},
+		{name: "gorm duplicated key", err: gorm.ErrDuplicatedKey, want: ErrDuplicate},
+		{name: "sqlite unique violation", err: errors.New("UNIQUE constraint failed: users.email"), want: ErrDuplicate},
+		{name: "failed connect", err: &pgconn.ConnectError{}, want: ErrConn},
+		{name: "network error", err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, want: ErrConn},
+		{name: "bad connection", err: driver.ErrBadConn, want: ErrConn},
+		{name: "connection closed", err: sql.ErrConnDone, want: ErrConn},
+		{name: "connection exception", err: &pgconn.PgError{Code: "08006"}, want: ErrConn},
+		{name: "server shutting down", err: &pgconn.PgError{Code: "57P01"}, want: ErrConn},
+		{name: "other server error", err: &pgconn.PgError{Code: "42601"}, want: nil},
+		{name: "other error", err: other, want: other},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := translateError(context.Background(), tt.err)
+
+			if tt.err == nil {
+				assert.NoError(t, err)
+				return
+			}
+			// The original error stays reachable for callers and logs.
+			assert.ErrorIs(t, err, tt.err)
+			if tt.want == nil {
+				for _, sentinel := range []error{ErrNotFound, ErrDuplicate, ErrConn, ErrTimeout} {
+					assert.NotErrorIs(t, err, sentinel)
+				}
+				return
+			}
+			assert.ErrorIs(t, err, tt.want)
+			assert.EqualError(t, err, tt.want.Error())
+		})
+	}
+}
+
+func TestTranslateError_DeadlineExceeded(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	<-ctx.Done()
+
+	// Whatever the driver reports once the deadline passed is a timeout.
+	for _, err := range []error{gorm.ErrRecordNotFound, sql.ErrConnDone, errors.New("canceling statement due to user request")} {
+		assert.Same(t, ErrTimeout, translateError(ctx, err))
+	}
+	assert.NoError(t, translateError(ctx, nil))
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrLastSignInMethod is returned when unlinking an identity would leave its
+// user without a password or another linked identity to sign in with.
+var ErrLastSignInMethod = errors.New("cannot unlink the last sign-in method, set a password first")
+
+// IdentityRepository provides access to the OAuth identities linked to users.
+// Every query it runs is bounded by queryTimeout in addition to any deadline on the caller's context.
+type IdentityRepository struct {
+	db           *gorm.DB
+	queryTimeout time.Duration
+}
+
+// NewIdentityRepository creates an IdentityRepository that bounds each query by queryTimeout.
+func NewIdentityRepository(db *gorm.DB, queryTimeout time.Duration) *IdentityRepository {
+	return &IdentityRepository{db: db, queryTimeout: queryTimeout}
+}
+
+// Create links identity to its user. If the provider account is already
+// linked, or the user already linked an account of the same provider, the
+// error is ErrDuplicate. If the query exceeds its timeout, the error is ErrTimeout.
+func (r *IdentityRepository) Create(ctx context.Context, identity *model.Identity) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	return translateError(ctx, r.db.WithContext(ctx).Create(identity).Error)
+}
+
+// ListByUser retrieves the identities linked to the given user, oldest first.
+// If the query exceeds its timeout, the error is ErrTimeout.
+func (r *IdentityRepository) ListByUser(ctx context.Context, userID uuid.UUID) ([]model.Identity, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var identities []model.Identity
+	err := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at, id").
+		Find(&identities).Error
+	if err != nil {
+		return nil, translateError(ctx, err)
+	}
+	return identities, nil
+}
+
+// FindByProviderAccount retrieves the identity of the given provider account,
+// whichever user it is linked to. If it is not linked, the error is
+// ErrNotFound. If the query exceeds its timeout, the error is ErrTimeout.
+func (r *IdentityRepository) FindByProviderAccount(ctx context.Context, provider, providerUserID string) (*model.Identity, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var identity model.Identity
+	err := r.db.WithContext(ctx).
+		Where("provider = ? AND provider_user_id = ?", provider, providerUserID).
+		First(&identity).Error
+	if err != nil {
+		return nil, translateError(ctx, err)
+	}
+	return &identity, nil
+}
+
+// Unlink deletes the identity of the given provider linked to the user. The
+// user's row is locked while the remaining sign-in methods are counted, so
+// that two identities unlinked at once cannot both be the last but one.
+//
+// If the user has no identity of the provider, the error is ErrNotFound. If
+// it is their last identity and they have no password, nothing is deleted and
+// the error is ErrLastSignInMethod. If a query exceeds its timeout, the error is ErrTimeout.
+func (r *IdentityRepository) Unlink(ctx context.Context, userID uuid.UUID, provider string) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user model.User
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("password_hash").
+			Where("id = ?", userID).
+			Take(&user).Error
+		if err != nil {
+			return err
+		}
+
+		result := tx.Where("user_id = ? AND provider = ?", userID, provider).Delete(&model.Identity{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if user.PasswordHash != "" {
+			return nil
+		}
+
+		var remaining int64
+		if err := tx.Model(&model.Identity{}).Where("user_id = ?", userID).Count(&remaining).Error; err != nil {
+			return err
+		}
+		if remaining == 0 {
+			return ErrLastSignInMethod
+		}
+		return nil
+	})
+
+	return translateError(ctx, err)
+}
@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupIdentityTest(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *IdentityRepository) {
+	sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+	identityRepo := NewIdentityRepository(gormDB, testQueryTimeout)
+	return sqlDB, sqlMock, identityRepo
+}
+
+func TestNewIdentityRepository(t *testing.T) {
+	_, gormDB, _ := testutil.DbMock(t)
+	identityRepo := NewIdentityRepository(gormDB, testQueryTimeout)
+	assert.Equal(t, gormDB, identityRepo.db)
+	assert.Equal(t, testQueryTimeout, identityRepo.queryTimeout)
+}
+
+func TestIdentityRepository_Create(t *testing.T) {
+	userID := uuid.New()
+	identityID := uuid.New()
+	createdAt := time.Now()
+
+	tests := []struct {
+		name    string
+		mockFn  func(sqlmock.Sqlmock)
+		wantErr error
+	}{
+		{
+			name: "success",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectQuery(`INSERT INTO "identities"`).
+					WithArgs(userID, "google", "google-123", "user@example.com", createdAt).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(identityID))
+				sqlMock.ExpectCommit()
+			},
+		},
+		{
+			name: "provider account linked to another user",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectQuery(`INSERT INTO "identities"`).WillReturnError(&pgconn.PgError{Code: uniqueViolation})
+				sqlMock.ExpectRollback()
+			},
+			wantErr: ErrDuplicate,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, sqlMock, identityRepo := setupIdentityTest(t)
+			defer sqlDB.Close()
+			tt.mockFn(sqlMock)
+
+			identity := &model.Identity{
+				UserID:          userID,
+				Provider:        "google",
+				ProviderUserID:  "google-123",
+				EmailAtLinkTime: "user@example.com",
+				CreatedAt:       createdAt,
+			}
+			err := identityRepo.Create(context.Background(), identity)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, identityID, identity.ID)
+			}
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestIdentityRepository_ListByUser(t *testing.T) {
+	sqlDB, sqlMock, identityRepo := setupIdentityTest(t)
+	defer sqlDB.Close()
+	userID := uuid.New()
+	sqlMock.ExpectQuery(`SELECT \* FROM "identities" WHERE user_id = \$1 ORDER BY created_at, id`).
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "provider", "provider_user_id", "email_at_link_time", "created_at"}).
+			AddRow(uuid.New(), userID, "github", "42", "user@example.com", time.Now()))
+
+	identities, err := identityRepo.ListByUser(context.Background(), userID)
+
+	assert.NoError(t, err)
+	assert.Len(t, identities, 1)
+	assert.Equal(t, "github", identities[0].Provider)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestIdentityRepository_FindByProviderAccount(t *testing.T) {
+	sqlDB, sqlMock, identityRepo := setupIdentityTest(t)
+	defer sqlDB.Close()
+	sqlMock.ExpectQuery(`SELECT \* FROM "identities" WHERE provider = \$1 AND provider_user_id = \$2 ORDER BY "identities"."id" LIMIT \$3`).
+		WithArgs("github", "42", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	identity, err := identityRepo.FindByProviderAccount(context.Background(), "github", "42")
+
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Nil(t, identity)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestIdentityRepository_Unlink(t *testing.T) {
+	userID := uuid.New()
+	lockUser := func(sqlMock sqlmock.Sqlmock, passwordHash string) {
+		sqlMock.ExpectBegin()
+		sqlMock.ExpectQuery(`SELECT "password_hash" FROM "users" WHERE id = \$1 .*FOR UPDATE`).
+			WithArgs(userID, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"password_hash"}).AddRow(passwordHash))
+	}
+	deleteIdentity := func(sqlMock sqlmock.Sqlmock, rows int64) {
+		sqlMock.ExpectExec(`DELETE FROM "identities" WHERE user_id = \$1 AND provider = \$2`).
+			WithArgs(userID, "github").
+			WillReturnResult(sqlmock.NewResult(0, rows))
+	}
+	countRemaining := func(sqlMock sqlmock.Sqlmock, remaining int) {
+		sqlMock.ExpectQuery(`SELECT count\(\*\) FROM "identities" WHERE user_id = \$1`).
+			WithArgs(userID).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(remaining))
+	}
+
+	tests := []struct {
+		name    string
+		mockFn  func(sqlmock.Sqlmock)
+		wantErr error
+	}{
+		{
+			name: "user with a password",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				lockUser(sqlMock, "hash")
+				deleteIdentity(sqlMock, 1)
+				sqlMock.ExpectCommit()
+			},
+		},
+		{
+			name: "user without a password keeping another identity",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				lockUser(sqlMock, "")
+				deleteIdentity(sqlMock, 1)
+				countRemaining(sqlMock, 1)
+				sqlMock.ExpectCommit()
+			},
+		},
+		{
+			name: "last sign-in method",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				lockUser(sqlMock, "")
+				deleteIdentity(sqlMock, 1)
+				countRemaining(sqlMock, 0)
+				sqlMock.ExpectRollback()
+			},
+			wantErr: ErrLastSignInMethod,
+		},
+		{
+			name: "provider not linked",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				lockUser(sqlMock, "")
+				deleteIdentity(sqlMock, 0)
+				sqlMock.ExpectRollback()
+			},
+			wantErr: ErrNotFound,
+		},
+		{
+			name: "unknown user",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectQuery(`SELECT "password_hash" FROM "users"`).WillReturnRows(sqlmock.NewRows([]string{"password_hash"}))
+				sqlMock.ExpectRollback()
+			},
+			wantErr: ErrNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, sqlMock, identityRepo := setupIdentityTest(t)
+			defer sqlDB.Close()
+			tt.mockFn(sqlMock)
+
+			err := identityRepo.Unlink(context.Background(), userID, "github")
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// LoginAlertRepository provides access to the alerts sent about logins from
+// new devices. Every query it runs is bounded by queryTimeout in addition to
+// any deadline on the caller's context.
+type LoginAlertRepository struct {
+	db           *gorm.DB
+	queryTimeout time.Duration
+}
+
+// NewLoginAlertRepository creates a LoginAlertRepository that bounds each query by queryTimeout.
+func NewLoginAlertRepository(db *gorm.DB, queryTimeout time.Duration) *LoginAlertRepository {
+	return &LoginAlertRepository{db: db, queryTimeout: queryTimeout}
+}
+
+// Create inserts a new login alert into the database.
+// It returns an error if the operation fails, or ErrTimeout if it exceeds the query timeout.
+func (r *LoginAlertRepository) Create(ctx context.Context, alert *model.LoginAlert) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	return translateError(ctx, r.db.WithContext(ctx).Create(alert).Error)
+}
+
+// FindByHash retrieves a login alert by the hash of its report token.
+// If the alert is not found or any other error occurs, it returns nil and the error.
+// If the query exceeds its timeout, the error is ErrTimeout.
+func (r *LoginAlertRepository) FindByHash(ctx context.Context, tokenHash string) (*model.LoginAlert, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var alert model.LoginAlert
+
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&alert).Error; err != nil {
+		return nil, translateError(ctx, err)
+	}
+
+	return &alert, nil
+}
+
+// Delete removes the login alert with the given ID, or returns ErrNotFound
+// if it does not exist, such as when a concurrent Delete removed it first.
+// It returns an error if the operation fails, or ErrTimeout if it exceeds the query timeout.
+func (r *LoginAlertRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	result := r.db.WithContext(ctx).Delete(&model.LoginAlert{}, "id = ?", id)
+	if result.Error != nil {
+		return translateError(ctx, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
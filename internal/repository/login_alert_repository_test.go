@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupLoginAlertTest(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *LoginAlertRepository) {
+	sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+	alertRepo := NewLoginAlertRepository(gormDB, testQueryTimeout)
+	return sqlDB, sqlMock, alertRepo
+}
+
+func newMockLoginAlert() model.LoginAlert {
+	return model.LoginAlert{
+		ID:        uuid.New(),
+		UserID:    uuid.New(),
+		FamilyID:  uuid.New(),
+		TokenHash: "token-hash",
+		ExpiresAt: time.Now().Add(7 * 24 * time.Hour),
+		CreatedAt: time.Now(),
+	}
+}
+
+func TestNewLoginAlertRepository(t *testing.T) {
+	_, gormDB, _ := testutil.DbMock(t)
+	alertRepo := NewLoginAlertRepository(gormDB, testQueryTimeout)
+	assert.Equal(t, gormDB, alertRepo.db)
+	assert.Equal(t, testQueryTimeout, alertRepo.queryTimeout)
+}
+
+func TestLoginAlertRepository_Create(t *testing.T) {
+	mockAlert := newMockLoginAlert()
+
+	sqlDB, sqlMock, alertRepo := setupLoginAlertTest(t)
+	defer sqlDB.Close()
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(`INSERT INTO "login_alerts"`).
+		WithArgs(mockAlert.UserID, mockAlert.FamilyID, mockAlert.TokenHash, mockAlert.ExpiresAt).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(mockAlert.ID, mockAlert.CreatedAt))
+	sqlMock.ExpectCommit()
+
+	alert := &model.LoginAlert{
+		UserID:    mockAlert.UserID,
+		FamilyID:  mockAlert.FamilyID,
+		TokenHash: mockAlert.TokenHash,
+		ExpiresAt: mockAlert.ExpiresAt,
+	}
+	err := alertRepo.Create(context.Background(), alert)
+
+	assert.NoError(t, err)
+	assert.Equal(t, mockAlert.ID, alert.ID)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestLoginAlertRepository_FindByHash(t *testing.T) {
+	mockAlert := newMockLoginAlert()
+	columns := []string{"id", "user_id", "family_id", "token_hash", "expires_at", "created_at"}
+
+	tests := []struct {
+		name      string
+		mockFn    func(sqlmock.Sqlmock)
+		wantAlert *model.LoginAlert
+		wantErr   error
+	}{
+		{
+			name: "alert found",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows(columns).AddRow(mockAlert.ID, mockAlert.UserID, mockAlert.FamilyID,
+					mockAlert.TokenHash, mockAlert.ExpiresAt, mockAlert.CreatedAt)
+				sqlMock.ExpectQuery(`SELECT .* FROM "login_alerts" WHERE token_hash = \$1 (.+) LIMIT \$2`).
+					WithArgs(mockAlert.TokenHash, 1).
+					WillReturnRows(rows)
+			},
+			wantAlert: &mockAlert,
+		},
+		{
+			name: "alert not found",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectQuery(`SELECT .* FROM "login_alerts" WHERE token_hash = \$1 (.+) LIMIT \$2`).
+					WithArgs(mockAlert.TokenHash, 1).
+					WillReturnRows(sqlmock.NewRows(columns))
+			},
+			wantErr: ErrNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, sqlMock, alertRepo := setupLoginAlertTest(t)
+			defer sqlDB.Close()
+			tt.mockFn(sqlMock)
+
+			got, err := alertRepo.FindByHash(context.Background(), mockAlert.TokenHash)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, got)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantAlert, got)
+			}
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestLoginAlertRepository_Delete(t *testing.T) {
+	id := uuid.New()
+
+	sqlDB, sqlMock, alertRepo := setupLoginAlertTest(t)
+	defer sqlDB.Close()
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(`DELETE FROM "login_alerts" WHERE id = \$1`).
+		WithArgs(id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+
+	err := alertRepo.Delete(context.Background(), id)
+
+	assert.NoError(t, err)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestLoginAlertRepository_Delete_NotFound(t *testing.T) {
+	id := uuid.New()
+
+	sqlDB, sqlMock, alertRepo := setupLoginAlertTest(t)
+	defer sqlDB.Close()
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(`DELETE FROM "login_alerts" WHERE id = \$1`).
+		WithArgs(id).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectCommit()
+
+	err := alertRepo.Delete(context.Background(), id)
+
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
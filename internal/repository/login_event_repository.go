@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// LoginEventRepository provides access to login event records. Every query it
+// runs is bounded by queryTimeout in addition to any deadline on the caller's context.
+type LoginEventRepository struct {
+	db           *gorm.DB
+	queryTimeout time.Duration
+}
+
+// NewLoginEventRepository creates a LoginEventRepository that bounds each query by queryTimeout.
+func NewLoginEventRepository(db *gorm.DB, queryTimeout time.Duration) *LoginEventRepository {
+	return &LoginEventRepository{db: db, queryTimeout: queryTimeout}
+}
+
+// Create inserts a new login event record into the database.
+// It returns an error if the operation fails, or ErrTimeout if it exceeds the query timeout.
+func (r *LoginEventRepository) Create(ctx context.Context, event *model.LoginEvent) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	return translateError(ctx, r.db.WithContext(ctx).Create(event).Error)
+}
+
+// ListByUser retrieves up to limit login events for the given user, newest first,
+// starting after the position encoded in cursor. An empty cursor starts from the
+// most recent event.
+//
+// It returns the events along with the cursor for the next page, which is empty
+// when there are no more rows. If the cursor cannot be decoded, ErrInvalidCursor
+// is returned without querying the database.
+func (r *LoginEventRepository) ListByUser(ctx context.Context, userID uuid.UUID, cursor string, limit int) ([]model.LoginEvent, string, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at DESC, id DESC").
+		Limit(limit + 1)
+
+	if cursor != "" {
+		createdAt, id, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		query = query.Where("(created_at, id) < (?, ?)", createdAt, id)
+	}
+
+	var events []model.LoginEvent
+	if err := query.Find(&events).Error; err != nil {
+		return nil, "", translateError(ctx, err)
+	}
+
+	if len(events) <= limit {
+		return events, "", nil
+	}
+
+	events = events[:limit]
+	last := events[len(events)-1]
+	return events, encodeCursor(last.CreatedAt, last.ID), nil
+}
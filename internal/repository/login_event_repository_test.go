@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupLoginEventTest(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *LoginEventRepository) {
+	sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+	eventRepo := NewLoginEventRepository(gormDB, testQueryTimeout)
+	return sqlDB, sqlMock, eventRepo
+}
+
+func TestNewLoginEventRepository(t *testing.T) {
+	_, gormDB, _ := testutil.DbMock(t)
+	eventRepo := NewLoginEventRepository(gormDB, testQueryTimeout)
+	assert.Equal(t, gormDB, eventRepo.db)
+	assert.Equal(t, testQueryTimeout, eventRepo.queryTimeout)
+}
+
+func TestLoginEventRepository_Create(t *testing.T) {
+	userID := uuid.New()
+	eventID := uuid.New()
+	createdAt := time.Now()
+
+	tests := []struct {
+		name   string
+		userID *uuid.UUID
+	}{
+		{name: "known user", userID: &userID},
+		{name: "unknown email", userID: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, sqlMock, eventRepo := setupLoginEventTest(t)
+			defer sqlDB.Close()
+
+			var wantUserID interface{}
+			if tt.userID != nil {
+				wantUserID = *tt.userID
+			}
+
+			sqlMock.ExpectBegin()
+			sqlMock.ExpectQuery(`INSERT INTO "login_events"`).
+				WithArgs(wantUserID, "test@example.com", false, "10.0.0.1", "test-agent", createdAt).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(eventID))
+			sqlMock.ExpectCommit()
+
+			event := &model.LoginEvent{
+				UserID:    tt.userID,
+				Email:     "test@example.com",
+				Success:   false,
+				IPAddress: "10.0.0.1",
+				UserAgent: "test-agent",
+				CreatedAt: createdAt,
+			}
+			err := eventRepo.Create(context.Background(), event)
+
+			assert.NoError(t, err)
+			assert.Equal(t, eventID, event.ID)
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestLoginEventRepository_ListByUser(t *testing.T) {
+	userID := uuid.New()
+	newer := model.LoginEvent{ID: uuid.New(), UserID: &userID, Email: "test@example.com", Success: true, CreatedAt: time.Now()}
+	older := model.LoginEvent{ID: uuid.New(), UserID: &userID, Email: "test@example.com", CreatedAt: newer.CreatedAt.Add(-time.Minute)}
+	columns := []string{"id", "user_id", "email", "success", "ip_address", "user_agent", "created_at"}
+
+	tests := []struct {
+		name           string
+		cursor         string
+		limit          int
+		mockFn         func(sqlmock.Sqlmock)
+		wantLen        int
+		wantNextCursor string
+		wantErr        bool
+		errType        error
+	}{
+		{
+			name:  "first page with more results",
+			limit: 1,
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows(columns).
+					AddRow(newer.ID, userID, newer.Email, newer.Success, "", "", newer.CreatedAt).
+					AddRow(older.ID, userID, older.Email, older.Success, "", "", older.CreatedAt)
+				sqlMock.ExpectQuery(`SELECT \* FROM "login_events" WHERE user_id = \$1 ORDER BY created_at DESC, id DESC LIMIT \$2`).
+					WithArgs(userID, 2).
+					WillReturnRows(rows)
+			},
+			wantLen:        1,
+			wantNextCursor: encodeCursor(newer.CreatedAt, newer.ID),
+		},
+		{
+			name:   "last page after cursor",
+			cursor: encodeCursor(newer.CreatedAt, newer.ID),
+			limit:  1,
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows(columns).
+					AddRow(older.ID, userID, older.Email, older.Success, "", "", older.CreatedAt)
+				sqlMock.ExpectQuery(`SELECT \* FROM "login_events" WHERE user_id = \$1 AND \(created_at, id\) < \(\$2, \$3\) ORDER BY created_at DESC, id DESC LIMIT \$4`).
+					WithArgs(userID, sqlmock.AnyArg(), newer.ID, 2).
+					WillReturnRows(rows)
+			},
+			wantLen: 1,
+		},
+		{
+			name:    "invalid cursor",
+			cursor:  "not-a-cursor",
+			limit:   1,
+			mockFn:  func(sqlMock sqlmock.Sqlmock) {},
+			wantErr: true,
+			errType: ErrInvalidCursor,
+		},
+		{
+			name:  "database error",
+			limit: 1,
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectQuery(`SELECT \* FROM "login_events"`).WillReturnError(sql.ErrConnDone)
+			},
+			wantErr: true,
+			errType: sql.ErrConnDone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, sqlMock, eventRepo := setupLoginEventTest(t)
+			defer sqlDB.Close()
+			tt.mockFn(sqlMock)
+			got, nextCursor, err := eventRepo.ListByUser(context.Background(), userID, tt.cursor, tt.limit)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.ErrorIs(t, err, tt.errType)
+				assert.Nil(t, got)
+			} else {
+				assert.NoError(t, err)
+				assert.Len(t, got, tt.wantLen)
+				assert.Equal(t, tt.wantNextCursor, nextCursor)
+			}
+
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
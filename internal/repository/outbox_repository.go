@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// OutboxRepository provides access to outbox event records. Every query it runs
+// is bounded by queryTimeout in addition to any deadline on the caller's context.
+type OutboxRepository struct {
+	db           *gorm.DB
+	queryTimeout time.Duration
+}
+
+// NewOutboxRepository creates an OutboxRepository that bounds each query by queryTimeout.
+func NewOutboxRepository(db *gorm.DB, queryTimeout time.Duration) *OutboxRepository {
+	return &OutboxRepository{db: db, queryTimeout: queryTimeout}
+}
+
+// FetchUnpublished retrieves up to limit events that have been neither published nor
+// dead-lettered and are due to be attempted at now, those due the longest first.
+// It returns an error if the operation fails, or ErrTimeout if it exceeds the query timeout.
+func (r *OutboxRepository) FetchUnpublished(ctx context.Context, now time.Time, limit int) ([]model.OutboxEvent, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var events []model.OutboxEvent
+	err := r.db.WithContext(ctx).
+		Where("published_at IS NULL AND dead_at IS NULL AND next_attempt_at <= ?", now).
+		Order("next_attempt_at ASC").
+		Limit(limit).
+		Find(&events).Error
+	if err != nil {
+		return nil, translateError(ctx, err)
+	}
+
+	return events, nil
+}
+
+// MarkPublished records that the event with the given ID was published at the given time.
+// It returns an error if the operation fails, or ErrTimeout if it exceeds the query timeout.
+func (r *OutboxRepository) MarkPublished(ctx context.Context, id uuid.UUID, at time.Time) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	err := r.db.WithContext(ctx).Model(&model.OutboxEvent{}).
+		Where("id = ?", id).
+		Update("published_at", at).Error
+
+	return translateError(ctx, err)
+}
+
+// MarkFailed records a failed attempt to publish the event with the given ID,
+// keeping it unpublished so it is retried at nextAttemptAt.
+// It returns an error if the operation fails, or ErrTimeout if it exceeds the query timeout.
+func (r *OutboxRepository) MarkFailed(ctx context.Context, id uuid.UUID, reason string, nextAttemptAt time.Time) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	err := r.db.WithContext(ctx).Model(&model.OutboxEvent{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"attempts":        gorm.Expr("attempts + 1"),
+			"last_error":      reason,
+			"next_attempt_at": nextAttemptAt,
+		}).Error
+
+	return translateError(ctx, err)
+}
+
+// MarkDead records the last failed attempt to publish the event with the given
+// ID and dead-letters it at the given time, so it is no longer fetched.
+// It returns an error if the operation fails, or ErrTimeout if it exceeds the query timeout.
+func (r *OutboxRepository) MarkDead(ctx context.Context, id uuid.UUID, reason string, at time.Time) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	err := r.db.WithContext(ctx).Model(&model.OutboxEvent{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"attempts":   gorm.Expr("attempts + 1"),
+			"last_error": reason,
+			"dead_at":    at,
+		}).Error
+
+	return translateError(ctx, err)
+}
+
+// DeletePublishedBefore deletes events that were published before the given time
+// and returns how many were deleted. Unpublished events are never deleted.
+// It returns an error if the operation fails, or ErrTimeout if it exceeds the query timeout.
+func (r *OutboxRepository) DeletePublishedBefore(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	result := r.db.WithContext(ctx).
+		Where("published_at IS NOT NULL AND published_at < ?", before).
+		Delete(&model.OutboxEvent{})
+
+	return result.RowsAffected, translateError(ctx, result.Error)
+}
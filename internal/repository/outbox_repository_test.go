@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupOutboxTest(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *OutboxRepository) {
+	sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+	outboxRepo := NewOutboxRepository(gormDB, testQueryTimeout)
+	return sqlDB, sqlMock, outboxRepo
+}
+
+func TestNewOutboxRepository(t *testing.T) {
+	_, gormDB, _ := testutil.DbMock(t)
+	outboxRepo := NewOutboxRepository(gormDB, testQueryTimeout)
+	assert.Equal(t, gormDB, outboxRepo.db)
+	assert.Equal(t, testQueryTimeout, outboxRepo.queryTimeout)
+}
+
+func TestOutboxRepository_FetchUnpublished(t *testing.T) {
+	sqlDB, sqlMock, outboxRepo := setupOutboxTest(t)
+	defer sqlDB.Close()
+
+	eventID := uuid.New()
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"id", "event_type", "payload", "attempts", "next_attempt_at", "created_at"}).
+		AddRow(eventID, "user.registered", []byte(`{}`), 0, now, now)
+	sqlMock.ExpectQuery(`SELECT \* FROM "outbox_events" WHERE published_at IS NULL AND dead_at IS NULL AND next_attempt_at <= \$1 ORDER BY next_attempt_at ASC LIMIT \$2`).
+		WithArgs(now, 10).
+		WillReturnRows(rows)
+
+	events, err := outboxRepo.FetchUnpublished(context.Background(), now, 10)
+
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	assert.Equal(t, eventID, events[0].ID)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestOutboxRepository_MarkPublished(t *testing.T) {
+	sqlDB, sqlMock, outboxRepo := setupOutboxTest(t)
+	defer sqlDB.Close()
+
+	eventID := uuid.New()
+	at := time.Now()
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(`UPDATE "outbox_events" SET "published_at"=\$1 WHERE id = \$2`).
+		WithArgs(at, eventID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+
+	err := outboxRepo.MarkPublished(context.Background(), eventID, at)
+
+	assert.NoError(t, err)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestOutboxRepository_MarkFailed(t *testing.T) {
+	sqlDB, sqlMock, outboxRepo := setupOutboxTest(t)
+	defer sqlDB.Close()
+
+	eventID := uuid.New()
+	nextAttemptAt := time.Now().Add(time.Minute)
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(`UPDATE "outbox_events" SET "attempts"=attempts \+ 1,"last_error"=\$1,"next_attempt_at"=\$2 WHERE id = \$3`).
+		WithArgs("connection refused", nextAttemptAt, eventID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+
+	err := outboxRepo.MarkFailed(context.Background(), eventID, "connection refused", nextAttemptAt)
+
+	assert.NoError(t, err)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestOutboxRepository_MarkDead(t *testing.T) {
+	sqlDB, sqlMock, outboxRepo := setupOutboxTest(t)
+	defer sqlDB.Close()
+
+	eventID := uuid.New()
+	at := time.Now()
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(`UPDATE "outbox_events" SET "attempts"=attempts \+ 1,"dead_at"=\$1,"last_error"=\$2 WHERE id = \$3`).
+		WithArgs(at, "connection refused", eventID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+
+	err := outboxRepo.MarkDead(context.Background(), eventID, "connection refused", at)
+
+	assert.NoError(t, err)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestOutboxRepository_DeletePublishedBefore(t *testing.T) {
+	tests := []struct {
+		name        string
+		mockFn      func(sqlmock.Sqlmock, time.Time)
+		wantDeleted int64
+		wantErr     error
+	}{
+		{
+			name: "deletes old published events",
+			mockFn: func(sqlMock sqlmock.Sqlmock, before time.Time) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectExec(`DELETE FROM "outbox_events" WHERE published_at IS NOT NULL AND published_at < \$1`).
+					WithArgs(before).
+					WillReturnResult(sqlmock.NewResult(0, 3))
+				sqlMock.ExpectCommit()
+			},
+			wantDeleted: 3,
+		},
+		{
+			name: "database error",
+			mockFn: func(sqlMock sqlmock.Sqlmock, before time.Time) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectExec(`DELETE FROM "outbox_events"`).WillReturnError(sql.ErrConnDone)
+				sqlMock.ExpectRollback()
+			},
+			wantErr: sql.ErrConnDone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, sqlMock, outboxRepo := setupOutboxTest(t)
+			defer sqlDB.Close()
+			before := time.Now()
+			tt.mockFn(sqlMock, before)
+
+			deleted, err := outboxRepo.DeletePublishedBefore(context.Background(), before)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantDeleted, deleted)
+			}
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
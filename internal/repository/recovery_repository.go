@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RecoveryRepository provides access to account recovery requests. Every
+// query it runs is bounded by queryTimeout in addition to any deadline on the caller's context.
+type RecoveryRepository struct {
+	db           *gorm.DB
+	queryTimeout time.Duration
+}
+
+// NewRecoveryRepository creates a RecoveryRepository that bounds each query by queryTimeout.
+func NewRecoveryRepository(db *gorm.DB, queryTimeout time.Duration) *RecoveryRepository {
+	return &RecoveryRepository{db: db, queryTimeout: queryTimeout}
+}
+
+// Create inserts a new recovery request.
+// It returns an error if the operation fails, or ErrTimeout if it exceeds the query timeout.
+func (r *RecoveryRepository) Create(ctx context.Context, request *model.RecoveryRequest) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	return translateError(ctx, r.db.WithContext(ctx).Create(request).Error)
+}
+
+// FindByID retrieves a recovery request by its ID.
+// If the request is not found or any other error occurs, it returns nil and the error.
+// If the query exceeds its timeout, the error is ErrTimeout.
+func (r *RecoveryRepository) FindByID(ctx context.Context, id uuid.UUID) (*model.RecoveryRequest, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var request model.RecoveryRequest
+
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&request).Error; err != nil {
+		return nil, translateError(ctx, err)
+	}
+
+	return &request, nil
+}
+
+// FindByHash retrieves a recovery request by the hash of its recovery token.
+// If the request is not found or any other error occurs, it returns nil and the error.
+// If the query exceeds its timeout, the error is ErrTimeout.
+func (r *RecoveryRepository) FindByHash(ctx context.Context, tokenHash string) (*model.RecoveryRequest, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var request model.RecoveryRequest
+
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&request).Error; err != nil {
+		return nil, translateError(ctx, err)
+	}
+
+	return &request, nil
+}
+
+// ListPage retrieves up to limit recovery requests after skipping offset,
+// ordered by creation time, newest first if desc, along with the total
+// number of requests. An empty status lists requests in every state; any
+// other status lists only the requests in it.
+func (r *RecoveryRepository) ListPage(ctx context.Context, status string, offset, limit int, desc bool) ([]model.RecoveryRequest, int64, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := r.db.WithContext(ctx).Model(&model.RecoveryRequest{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, translateError(ctx, err)
+	}
+	if int64(offset) >= total {
+		return []model.RecoveryRequest{}, total, nil
+	}
+
+	order := "created_at ASC, id ASC"
+	if desc {
+		order = "created_at DESC, id DESC"
+	}
+
+	var requests []model.RecoveryRequest
+	if err := query.Order(order).Offset(offset).Limit(limit).Find(&requests).Error; err != nil {
+		return nil, 0, translateError(ctx, err)
+	}
+	return requests, total, nil
+}
+
+// Transition saves the state of request, which must still be in the state
+// from, so that two reviews or two uses of one link cannot both succeed. It
+// returns ErrNotFound if the request no longer exists or has left from.
+// It returns an error if the operation fails, or ErrTimeout if it exceeds the query timeout.
+func (r *RecoveryRepository) Transition(ctx context.Context, request *model.RecoveryRequest, from string) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	result := r.db.WithContext(ctx).
+		Model(&model.RecoveryRequest{}).
+		Where("id = ? AND status = ?", request.ID, from).
+		Updates(map[string]interface{}{
+			"status":       request.Status,
+			"reviewer_id":  request.ReviewerID,
+			"reviewed_at":  request.ReviewedAt,
+			"new_email":    request.NewEmail,
+			"token_hash":   request.TokenHash,
+			"expires_at":   request.ExpiresAt,
+			"completed_at": request.CompletedAt,
+			"updated_at":   time.Now(),
+		})
+	if result.Error != nil {
+		return translateError(ctx, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
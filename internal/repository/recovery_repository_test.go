@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupRecoveryTest(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *RecoveryRepository) {
+	sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+	return sqlDB, sqlMock, NewRecoveryRepository(gormDB, testQueryTimeout)
+}
+
+func TestRecoveryRepository_Create(t *testing.T) {
+	sqlDB, sqlMock, repo := setupRecoveryTest(t)
+	defer sqlDB.Close()
+	request := &model.RecoveryRequest{UserID: uuid.New(), Email: "test@example.com", Evidence: "evidence", Status: model.RecoveryPending}
+	id := uuid.New()
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(`INSERT INTO "recovery_requests"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(id))
+	sqlMock.ExpectCommit()
+
+	err := repo.Create(context.Background(), request)
+
+	assert.NoError(t, err)
+	assert.Equal(t, id, request.ID)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestRecoveryRepository_FindByHash(t *testing.T) {
+	t.Run("request found", func(t *testing.T) {
+		sqlDB, sqlMock, repo := setupRecoveryTest(t)
+		defer sqlDB.Close()
+		id := uuid.New()
+		sqlMock.ExpectQuery(`SELECT \* FROM "recovery_requests" WHERE token_hash = \$1 (.+) LIMIT \$2`).
+			WithArgs("token-hash", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "status"}).AddRow(id, model.RecoveryApproved))
+
+		got, err := repo.FindByHash(context.Background(), "token-hash")
+
+		require.NoError(t, err)
+		assert.Equal(t, id, got.ID)
+		assert.Equal(t, model.RecoveryApproved, got.Status)
+	})
+
+	t.Run("request not found", func(t *testing.T) {
+		sqlDB, sqlMock, repo := setupRecoveryTest(t)
+		defer sqlDB.Close()
+		sqlMock.ExpectQuery(`SELECT \* FROM "recovery_requests"`).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		got, err := repo.FindByHash(context.Background(), "token-hash")
+
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.Nil(t, got)
+	})
+}
+
+func TestRecoveryRepository_ListPage(t *testing.T) {
+	t.Run("filtered by status, newest first", func(t *testing.T) {
+		sqlDB, sqlMock, repo := setupRecoveryTest(t)
+		defer sqlDB.Close()
+		sqlMock.ExpectQuery(`SELECT count\(\*\) FROM "recovery_requests" WHERE status = \$1`).
+			WithArgs(model.RecoveryPending).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+		sqlMock.ExpectQuery(`SELECT \* FROM "recovery_requests" WHERE status = \$1 ORDER BY created_at DESC, id DESC LIMIT \$2 OFFSET \$3`).
+			WithArgs(model.RecoveryPending, 2, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+
+		got, total, err := repo.ListPage(context.Background(), model.RecoveryPending, 2, 2, true)
+
+		require.NoError(t, err)
+		assert.Len(t, got, 1)
+		assert.Equal(t, int64(3), total)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("past the last page", func(t *testing.T) {
+		sqlDB, sqlMock, repo := setupRecoveryTest(t)
+		defer sqlDB.Close()
+		sqlMock.ExpectQuery(`SELECT count\(\*\) FROM "recovery_requests"$`).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+		got, total, err := repo.ListPage(context.Background(), "", 20, 20, false)
+
+		require.NoError(t, err)
+		assert.NotNil(t, got)
+		assert.Empty(t, got)
+		assert.Equal(t, int64(1), total)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+}
+
+func TestRecoveryRepository_Transition(t *testing.T) {
+	reviewerID := uuid.New()
+	now := time.Now()
+	request := &model.RecoveryRequest{ID: uuid.New(), Status: model.RecoveryDenied, ReviewerID: &reviewerID, ReviewedAt: &now}
+
+	tests := []struct {
+		name     string
+		affected int64
+		wantErr  error
+	}{
+		{name: "request still pending", affected: 1},
+		{name: "request already reviewed", affected: 0, wantErr: ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, sqlMock, repo := setupRecoveryTest(t)
+			defer sqlDB.Close()
+			sqlMock.ExpectBegin()
+			sqlMock.ExpectExec(`UPDATE "recovery_requests" SET (.+) WHERE id = \$\d+ AND status = \$\d+`).
+				WillReturnResult(sqlmock.NewResult(0, tt.affected))
+			sqlMock.ExpectCommit()
+
+			err := repo.Transition(context.Background(), request, model.RecoveryPending)
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
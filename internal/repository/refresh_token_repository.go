@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrTokenAlreadyRotated is returned by Rotate when the token was rotated by
+// someone else in the meantime, which means it was presented twice.
+var ErrTokenAlreadyRotated = errors.New("refresh token already rotated")
+
+// RefreshTokenRepository provides access to refresh token records. Every query it
+// runs is bounded by queryTimeout in addition to any deadline on the caller's context.
+type RefreshTokenRepository struct {
+	db           *gorm.DB
+	queryTimeout time.Duration
+}
+
+// NewRefreshTokenRepository creates a RefreshTokenRepository that bounds each query by queryTimeout.
+func NewRefreshTokenRepository(db *gorm.DB, queryTimeout time.Duration) *RefreshTokenRepository {
+	return &RefreshTokenRepository{db: db, queryTimeout: queryTimeout}
+}
+
+// Create inserts a new refresh token record into the database.
+// It returns an error if the operation fails, or ErrTimeout if it exceeds the query timeout.
+func (r *RefreshTokenRepository) Create(ctx context.Context, token *model.RefreshToken) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	return translateError(ctx, r.db.WithContext(ctx).Create(token).Error)
+}
+
+// FindByHash retrieves a refresh token by the hash of its value.
+// If the token is not found or any other error occurs, it returns nil and the error.
+// If the query exceeds its timeout, the error is ErrTimeout.
+func (r *RefreshTokenRepository) FindByHash(ctx context.Context, tokenHash string) (*model.RefreshToken, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var token model.RefreshToken
+
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+		return nil, translateError(ctx, err)
+	}
+
+	return &token, nil
+}
+
+// Rotate marks the token identified by oldID as rotated and inserts next as its
+// replacement, in a single transaction. The update only applies while the old
+// token has not been rotated yet, so when two requests race to rotate the same
+// token exactly one succeeds and the other gets ErrTokenAlreadyRotated.
+func (r *RefreshTokenRepository) Rotate(ctx context.Context, oldID uuid.UUID, next *model.RefreshToken) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.RefreshToken{}).
+			Where("id = ? AND rotated_at IS NULL", oldID).
+			Update("rotated_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrTokenAlreadyRotated
+		}
+
+		return tx.Create(next).Error
+	})
+
+	return translateError(ctx, err)
+}
+
+// RevokeFamily revokes every token in the given family that is not revoked yet.
+// It returns an error if the operation fails, or ErrTimeout if it exceeds the query timeout.
+func (r *RefreshTokenRepository) RevokeFamily(ctx context.Context, familyID uuid.UUID) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	err := r.db.WithContext(ctx).Model(&model.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+
+	return translateError(ctx, err)
+}
+
+// RevokeAllForUser revokes every token issued to the user that is not revoked
+// yet, ending all of the user's sessions.
+// It returns an error if the operation fails, or ErrTimeout if it exceeds the query timeout.
+func (r *RefreshTokenRepository) RevokeAllForUser(ctx context.Context, userID uuid.UUID) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	err := r.db.WithContext(ctx).Model(&model.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+
+	return translateError(ctx, err)
+}
+
+// IsFamilyRevoked reports whether the given token family has been revoked.
+// It returns an error if the operation fails, or ErrTimeout if it exceeds the query timeout.
+func (r *RefreshTokenRepository) IsFamilyRevoked(ctx context.Context, familyID uuid.UUID) (bool, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var count int64
+	err := r.db.WithContext(ctx).Model(&model.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NOT NULL", familyID).
+		Count(&count).Error
+	if err != nil {
+		return false, translateError(ctx, err)
+	}
+
+	return count > 0, nil
+}
+
+// ListActiveForUser returns the current token of each of the user's sessions
+// that is neither revoked nor expired, most recently used first.
+// It returns an error if the operation fails, or ErrTimeout if it exceeds the query timeout.
+func (r *RefreshTokenRepository) ListActiveForUser(ctx context.Context, userID uuid.UUID) ([]model.RefreshToken, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var tokens []model.RefreshToken
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND rotated_at IS NULL AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("created_at DESC, id DESC").
+		Find(&tokens).Error
+	if err != nil {
+		return nil, translateError(ctx, err)
+	}
+
+	return tokens, nil
+}
+
+// RenameFamily sets the name of every token in the user's session identified
+// by familyID. It returns ErrNotFound if the user has no such session or it
+// was revoked, or ErrTimeout if the query exceeds its timeout.
+func (r *RefreshTokenRepository) RenameFamily(ctx context.Context, userID, familyID uuid.UUID, name string) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	result := r.db.WithContext(ctx).Model(&model.RefreshToken{}).
+		Where("user_id = ? AND family_id = ? AND revoked_at IS NULL", userID, familyID).
+		Update("name", name)
+	if result.Error != nil {
+		return translateError(ctx, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// DeviceHistory reports whether the user has any session other than the one
+// identified by familyID, and whether one of those was started from the
+// device with deviceHash. Revoked and expired sessions count, since they still
+// show the device was used before.
+// It returns an error if the operation fails, or ErrTimeout if it exceeds the query timeout.
+func (r *RefreshTokenRepository) DeviceHistory(ctx context.Context, userID, familyID uuid.UUID, deviceHash string) (hasOther, knownDevice bool, err error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var counts struct {
+		Sessions int64
+		Known    int64
+	}
+	err = r.db.WithContext(ctx).Model(&model.RefreshToken{}).
+		Select("COUNT(*) AS sessions, COUNT(*) FILTER (WHERE device_hash = ?) AS known", deviceHash).
+		Where("user_id = ? AND family_id <> ?", userID, familyID).
+		Scan(&counts).Error
+	if err != nil {
+		return false, false, translateError(ctx, err)
+	}
+
+	return counts.Sessions > 0, counts.Known > 0, nil
+}
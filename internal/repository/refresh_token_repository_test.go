@@ -0,0 +1,428 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func setupRefreshTokenTest(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *RefreshTokenRepository) {
+	sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+	tokenRepo := NewRefreshTokenRepository(gormDB, testQueryTimeout)
+	return sqlDB, sqlMock, tokenRepo
+}
+
+func newMockRefreshToken() model.RefreshToken {
+	return model.RefreshToken{
+		ID:        uuid.New(),
+		UserID:    uuid.New(),
+		FamilyID:  uuid.New(),
+		TokenHash: "token-hash",
+		ExpiresAt: time.Now().Add(time.Hour),
+		CreatedAt: time.Now(),
+
+		DeviceLabel: "Chrome on macOS",
+		IPAddress:   "203.0.113.0",
+	}
+}
+
+func TestNewRefreshTokenRepository(t *testing.T) {
+	_, gormDB, _ := testutil.DbMock(t)
+	tokenRepo := NewRefreshTokenRepository(gormDB, testQueryTimeout)
+	assert.Equal(t, gormDB, tokenRepo.db)
+	assert.Equal(t, testQueryTimeout, tokenRepo.queryTimeout)
+}
+
+func TestRefreshTokenRepository_Create(t *testing.T) {
+	mockToken := newMockRefreshToken()
+
+	sqlDB, sqlMock, tokenRepo := setupRefreshTokenTest(t)
+	defer sqlDB.Close()
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(`INSERT INTO "refresh_tokens"`).
+		WithArgs(mockToken.UserID, mockToken.FamilyID, nil, mockToken.TokenHash, mockToken.ExpiresAt, nil, nil,
+			mockToken.DeviceLabel, "", mockToken.IPAddress, "", "").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(mockToken.ID, mockToken.CreatedAt))
+	sqlMock.ExpectCommit()
+
+	token := &model.RefreshToken{
+		UserID:    mockToken.UserID,
+		FamilyID:  mockToken.FamilyID,
+		TokenHash: mockToken.TokenHash,
+		ExpiresAt: mockToken.ExpiresAt,
+
+		DeviceLabel: mockToken.DeviceLabel,
+		IPAddress:   mockToken.IPAddress,
+	}
+	err := tokenRepo.Create(context.Background(), token)
+
+	assert.NoError(t, err)
+	assert.Equal(t, mockToken.ID, token.ID)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestRefreshTokenRepository_FindByHash(t *testing.T) {
+	mockToken := newMockRefreshToken()
+	columns := []string{"id", "user_id", "family_id", "token_hash", "expires_at", "device_label", "ip_address", "created_at"}
+
+	tests := []struct {
+		name      string
+		mockFn    func(sqlmock.Sqlmock)
+		wantToken *model.RefreshToken
+		wantErr   error
+	}{
+		{
+			name: "token found",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows(columns).AddRow(mockToken.ID, mockToken.UserID, mockToken.FamilyID,
+					mockToken.TokenHash, mockToken.ExpiresAt, mockToken.DeviceLabel, mockToken.IPAddress, mockToken.CreatedAt)
+				sqlMock.ExpectQuery(`SELECT .* FROM "refresh_tokens" WHERE token_hash = \$1 (.+) LIMIT \$2`).
+					WithArgs(mockToken.TokenHash, 1).
+					WillReturnRows(rows)
+			},
+			wantToken: &mockToken,
+		},
+		{
+			name: "token not found",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectQuery(`SELECT .* FROM "refresh_tokens" WHERE token_hash = \$1 (.+) LIMIT \$2`).
+					WithArgs(mockToken.TokenHash, 1).
+					WillReturnRows(sqlmock.NewRows(columns))
+			},
+			wantErr: gorm.ErrRecordNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, sqlMock, tokenRepo := setupRefreshTokenTest(t)
+			defer sqlDB.Close()
+			tt.mockFn(sqlMock)
+
+			got, err := tokenRepo.FindByHash(context.Background(), mockToken.TokenHash)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, got)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantToken, got)
+			}
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRefreshTokenRepository_Rotate(t *testing.T) {
+	current := newMockRefreshToken()
+
+	tests := []struct {
+		name    string
+		mockFn  func(sqlmock.Sqlmock)
+		wantErr error
+	}{
+		{
+			name: "successful rotation",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectExec(`UPDATE "refresh_tokens" SET "rotated_at"=\$1 WHERE id = \$2 AND rotated_at IS NULL`).
+					WithArgs(sqlmock.AnyArg(), current.ID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				sqlMock.ExpectQuery(`INSERT INTO "refresh_tokens"`).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(uuid.New(), time.Now()))
+				sqlMock.ExpectCommit()
+			},
+		},
+		{
+			name: "token already rotated",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectExec(`UPDATE "refresh_tokens" SET "rotated_at"=\$1 WHERE id = \$2 AND rotated_at IS NULL`).
+					WithArgs(sqlmock.AnyArg(), current.ID).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				sqlMock.ExpectRollback()
+			},
+			wantErr: ErrTokenAlreadyRotated,
+		},
+		{
+			name: "database error",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectExec(`UPDATE "refresh_tokens"`).WillReturnError(sql.ErrConnDone)
+				sqlMock.ExpectRollback()
+			},
+			wantErr: sql.ErrConnDone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, sqlMock, tokenRepo := setupRefreshTokenTest(t)
+			defer sqlDB.Close()
+			tt.mockFn(sqlMock)
+
+			parentID := current.ID
+			next := &model.RefreshToken{
+				UserID:    current.UserID,
+				FamilyID:  current.FamilyID,
+				ParentID:  &parentID,
+				TokenHash: "next-token-hash",
+				ExpiresAt: time.Now().Add(time.Hour),
+			}
+			err := tokenRepo.Rotate(context.Background(), current.ID, next)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRefreshTokenRepository_RevokeFamily(t *testing.T) {
+	familyID := uuid.New()
+
+	sqlDB, sqlMock, tokenRepo := setupRefreshTokenTest(t)
+	defer sqlDB.Close()
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(`UPDATE "refresh_tokens" SET "revoked_at"=\$1 WHERE family_id = \$2 AND revoked_at IS NULL`).
+		WithArgs(sqlmock.AnyArg(), familyID).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	sqlMock.ExpectCommit()
+
+	err := tokenRepo.RevokeFamily(context.Background(), familyID)
+
+	assert.NoError(t, err)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestRefreshTokenRepository_RevokeAllForUser(t *testing.T) {
+	userID := uuid.New()
+
+	sqlDB, sqlMock, tokenRepo := setupRefreshTokenTest(t)
+	defer sqlDB.Close()
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(`UPDATE "refresh_tokens" SET "revoked_at"=\$1 WHERE user_id = \$2 AND revoked_at IS NULL`).
+		WithArgs(sqlmock.AnyArg(), userID).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	sqlMock.ExpectCommit()
+
+	err := tokenRepo.RevokeAllForUser(context.Background(), userID)
+
+	assert.NoError(t, err)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestRefreshTokenRepository_IsFamilyRevoked(t *testing.T) {
+	familyID := uuid.New()
+
+	tests := []struct {
+		name    string
+		count   int
+		want    bool
+		dbErr   error
+		wantErr error
+	}{
+		{name: "revoked family", count: 2, want: true},
+		{name: "active family", count: 0, want: false},
+		{name: "database error", dbErr: sql.ErrConnDone, wantErr: sql.ErrConnDone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, sqlMock, tokenRepo := setupRefreshTokenTest(t)
+			defer sqlDB.Close()
+
+			query := sqlMock.ExpectQuery(`SELECT count\(\*\) FROM "refresh_tokens" WHERE family_id = \$1 AND revoked_at IS NOT NULL`).
+				WithArgs(familyID)
+			if tt.dbErr != nil {
+				query.WillReturnError(tt.dbErr)
+			} else {
+				query.WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(tt.count))
+			}
+
+			got, err := tokenRepo.IsFamilyRevoked(context.Background(), familyID)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, got)
+			}
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRefreshTokenRepository_ListActiveForUser(t *testing.T) {
+	mockToken := newMockRefreshToken()
+	columns := []string{"id", "user_id", "family_id", "token_hash", "expires_at", "device_label", "name", "ip_address", "location", "created_at"}
+
+	tests := []struct {
+		name    string
+		mockFn  func(sqlmock.Sqlmock)
+		wantLen int
+		wantErr error
+	}{
+		{
+			name: "active sessions",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows(columns).AddRow(mockToken.ID, mockToken.UserID, mockToken.FamilyID, mockToken.TokenHash,
+					mockToken.ExpiresAt, mockToken.DeviceLabel, "Work laptop", mockToken.IPAddress, "", mockToken.CreatedAt)
+				sqlMock.ExpectQuery(`SELECT \* FROM "refresh_tokens" WHERE user_id = \$1 AND rotated_at IS NULL AND revoked_at IS NULL AND expires_at > \$2 ORDER BY created_at DESC, id DESC`).
+					WithArgs(mockToken.UserID, sqlmock.AnyArg()).
+					WillReturnRows(rows)
+			},
+			wantLen: 1,
+		},
+		{
+			name: "no sessions",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectQuery(`SELECT \* FROM "refresh_tokens"`).WillReturnRows(sqlmock.NewRows(columns))
+			},
+		},
+		{
+			name: "database error",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectQuery(`SELECT \* FROM "refresh_tokens"`).WillReturnError(sql.ErrConnDone)
+			},
+			wantErr: sql.ErrConnDone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, sqlMock, tokenRepo := setupRefreshTokenTest(t)
+			defer sqlDB.Close()
+			tt.mockFn(sqlMock)
+
+			got, err := tokenRepo.ListActiveForUser(context.Background(), mockToken.UserID)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, got)
+			} else {
+				assert.NoError(t, err)
+				assert.Len(t, got, tt.wantLen)
+				if tt.wantLen > 0 {
+					assert.Equal(t, "Work laptop", got[0].Name)
+					assert.Equal(t, mockToken.DeviceLabel, got[0].DeviceLabel)
+				}
+			}
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRefreshTokenRepository_RenameFamily(t *testing.T) {
+	userID, familyID := uuid.New(), uuid.New()
+
+	tests := []struct {
+		name    string
+		mockFn  func(sqlmock.Sqlmock)
+		wantErr error
+	}{
+		{
+			name: "session renamed",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectExec(`UPDATE "refresh_tokens" SET "name"=\$1 WHERE user_id = \$2 AND family_id = \$3 AND revoked_at IS NULL`).
+					WithArgs("Work laptop", userID, familyID).
+					WillReturnResult(sqlmock.NewResult(0, 3))
+				sqlMock.ExpectCommit()
+			},
+		},
+		{
+			name: "session not found",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectExec(`UPDATE "refresh_tokens"`).WillReturnResult(sqlmock.NewResult(0, 0))
+				sqlMock.ExpectCommit()
+			},
+			wantErr: ErrNotFound,
+		},
+		{
+			name: "database error",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectExec(`UPDATE "refresh_tokens"`).WillReturnError(sql.ErrConnDone)
+				sqlMock.ExpectRollback()
+			},
+			wantErr: sql.ErrConnDone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, sqlMock, tokenRepo := setupRefreshTokenTest(t)
+			defer sqlDB.Close()
+			tt.mockFn(sqlMock)
+
+			err := tokenRepo.RenameFamily(context.Background(), userID, familyID, "Work laptop")
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRefreshTokenRepository_DeviceHistory(t *testing.T) {
+	userID, familyID := uuid.New(), uuid.New()
+
+	tests := []struct {
+		name            string
+		sessions, known int
+		dbErr           error
+		wantHasOther    bool
+		wantKnownDevice bool
+		wantErr         error
+	}{
+		{name: "first session", sessions: 0, known: 0},
+		{name: "new device", sessions: 3, known: 0, wantHasOther: true},
+		{name: "known device", sessions: 3, known: 2, wantHasOther: true, wantKnownDevice: true},
+		{name: "database error", dbErr: sql.ErrConnDone, wantErr: sql.ErrConnDone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, sqlMock, tokenRepo := setupRefreshTokenTest(t)
+			defer sqlDB.Close()
+
+			query := sqlMock.ExpectQuery(`SELECT COUNT\(\*\) AS sessions, COUNT\(\*\) FILTER \(WHERE device_hash = \$1\) AS known FROM "refresh_tokens" WHERE user_id = \$2 AND family_id <> \$3`).
+				WithArgs("device-hash", userID, familyID)
+			if tt.dbErr != nil {
+				query.WillReturnError(tt.dbErr)
+			} else {
+				query.WillReturnRows(sqlmock.NewRows([]string{"sessions", "known"}).AddRow(tt.sessions, tt.known))
+			}
+
+			hasOther, knownDevice, err := tokenRepo.DeviceHistory(context.Background(), userID, familyID, "device-hash")
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantHasOther, hasOther)
+				assert.Equal(t, tt.wantKnownDevice, knownDevice)
+			}
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,68 @@
+//go:build integration
+
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// TestRoleGrantRepository_Lifecycle checks that grants stop being active
+// once they expire or are revoked, and that the expired ones are ended once,
+// on every one of the testDatabases, see TestUserRepository_Contract.
+func TestRoleGrantRepository_Lifecycle(t *testing.T) {
+	forEachDatabase(t, []any{&model.RoleGrant{}}, func(t *testing.T, _ testDatabase, db *gorm.DB) {
+		ctx := context.Background()
+		repo := repository.NewRoleGrantRepository(db, 5*time.Second)
+		now := time.Now().UTC().Truncate(time.Millisecond)
+		userID, adminID := uuid.New(), uuid.New()
+		newGrant := func(expiresAt time.Time) *model.RoleGrant {
+			grant := &model.RoleGrant{UserID: userID, Role: model.RoleAdmin, Reason: "on call", GrantedBy: adminID, ExpiresAt: expiresAt}
+			require.NoError(t, repo.Create(ctx, grant))
+			return grant
+		}
+		expired := newGrant(now.Add(-time.Minute))
+		revoked := newGrant(now.Add(time.Hour))
+		active := newGrant(now.Add(2 * time.Hour))
+
+		found, err := repo.FindActive(ctx, userID, model.RoleAdmin, now)
+		require.NoError(t, err)
+		assert.Equal(t, active.ID, found.ID, "the grant expiring last")
+
+		got, err := repo.Revoke(ctx, revoked.ID, adminID, now)
+		require.NoError(t, err)
+		assert.Equal(t, &adminID, got.RevokedBy)
+		_, err = repo.Revoke(ctx, revoked.ID, adminID, now)
+		assert.ErrorIs(t, err, repository.ErrNotFound, "a grant is revoked once")
+		_, err = repo.Revoke(ctx, expired.ID, adminID, now)
+		assert.ErrorIs(t, err, repository.ErrNotFound, "an expired grant cannot be revoked")
+
+		listed, err := repo.ListActive(ctx, now)
+		require.NoError(t, err)
+		require.Len(t, listed, 1)
+		assert.Equal(t, active.ID, listed[0].ID)
+
+		ended, err := repo.ExpireDue(ctx, now, 10)
+		require.NoError(t, err)
+		require.Len(t, ended, 1, "revoked grants have already ended")
+		assert.Equal(t, expired.ID, ended[0].ID)
+		ended, err = repo.ExpireDue(ctx, now, 10)
+		require.NoError(t, err)
+		assert.Empty(t, ended)
+
+		ended, err = repo.ExpireDue(ctx, now.Add(3*time.Hour), 10)
+		require.NoError(t, err)
+		require.Len(t, ended, 1)
+		assert.Equal(t, active.ID, ended[0].ID)
+		_, err = repo.FindActive(ctx, userID, model.RoleAdmin, now)
+		assert.ErrorIs(t, err, repository.ErrNotFound, "an ended grant is not active even before it expires")
+	})
+}
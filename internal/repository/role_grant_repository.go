@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RoleGrantRepository provides access to the temporary role grants of users.
+// Every query it runs is bounded by queryTimeout in addition to any deadline
+// on the caller's context.
+type RoleGrantRepository struct {
+	db           *gorm.DB
+	queryTimeout time.Duration
+}
+
+// NewRoleGrantRepository creates a RoleGrantRepository that bounds each query by queryTimeout.
+func NewRoleGrantRepository(db *gorm.DB, queryTimeout time.Duration) *RoleGrantRepository {
+	return &RoleGrantRepository{db: db, queryTimeout: queryTimeout}
+}
+
+// Create inserts a new grant.
+// It returns an error if the operation fails, or ErrTimeout if it exceeds the query timeout.
+func (r *RoleGrantRepository) Create(ctx context.Context, grant *model.RoleGrant) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	return translateError(ctx, r.db.WithContext(ctx).Create(grant).Error)
+}
+
+// FindActive retrieves the grant of role to the user with the given ID that
+// is active at at, the one expiring last if there are several. It returns
+// ErrNotFound if the user holds no such grant.
+// If the query exceeds its timeout, the error is ErrTimeout.
+func (r *RoleGrantRepository) FindActive(ctx context.Context, userID uuid.UUID, role string, at time.Time) (*model.RoleGrant, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var grant model.RoleGrant
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND role = ? AND ended_at IS NULL AND expires_at > ?", userID, role, at).
+		Order("expires_at DESC").
+		Take(&grant).Error
+	if err != nil {
+		return nil, translateError(ctx, err)
+	}
+	return &grant, nil
+}
+
+// ListActive retrieves every grant active at at, the first to expire first.
+// If the query exceeds its timeout, the error is ErrTimeout.
+func (r *RoleGrantRepository) ListActive(ctx context.Context, at time.Time) ([]model.RoleGrant, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var grants []model.RoleGrant
+	err := r.db.WithContext(ctx).
+		Where("ended_at IS NULL AND expires_at > ?", at).
+		Order("expires_at ASC, id ASC").
+		Find(&grants).Error
+	if err != nil {
+		return nil, translateError(ctx, err)
+	}
+	return grants, nil
+}
+
+// Revoke ends the grant with the given ID at at on behalf of the admin
+// revokedBy, and returns the revoked grant. It returns ErrNotFound if there
+// is no such grant or it is no longer active at at.
+// It returns an error if the operation fails, or ErrTimeout if it exceeds the query timeout.
+func (r *RoleGrantRepository) Revoke(ctx context.Context, id, revokedBy uuid.UUID, at time.Time) (*model.RoleGrant, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var grant model.RoleGrant
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND ended_at IS NULL AND expires_at > ?", id, at).
+			Take(&grant).Error
+		if err != nil {
+			return err
+		}
+
+		grant.RevokedAt = &at
+		grant.RevokedBy = &revokedBy
+		grant.EndedAt = &at
+		return tx.Model(&model.RoleGrant{}).
+			Where("id = ?", id).
+			Updates(map[string]interface{}{
+				"revoked_at": at,
+				"revoked_by": revokedBy,
+				"ended_at":   at,
+			}).Error
+	})
+	if err != nil {
+		return nil, translateError(ctx, err)
+	}
+	return &grant, nil
+}
+
+// ExpireDue ends up to limit grants that expired at or before at but have
+// not ended yet, the first to expire first, and returns them. Concurrent
+// calls never end the same grant twice.
+// It returns an error if the operation fails, or ErrTimeout if it exceeds the query timeout.
+func (r *RoleGrantRepository) ExpireDue(ctx context.Context, at time.Time, limit int) ([]model.RoleGrant, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var grants []model.RoleGrant
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("ended_at IS NULL AND expires_at <= ?", at).
+			Order("expires_at ASC, id ASC").
+			Limit(limit).
+			Find(&grants).Error
+		if err != nil || len(grants) == 0 {
+			return err
+		}
+
+		ids := make([]uuid.UUID, len(grants))
+		for i := range grants {
+			ids[i] = grants[i].ID
+			grants[i].EndedAt = &at
+		}
+		return tx.Model(&model.RoleGrant{}).Where("id IN ?", ids).Update("ended_at", at).Error
+	})
+	if err != nil {
+		return nil, translateError(ctx, err)
+	}
+	return grants, nil
+}
@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupRoleGrantTest(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *RoleGrantRepository) {
+	sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+	return sqlDB, sqlMock, NewRoleGrantRepository(gormDB, testQueryTimeout)
+}
+
+func TestRoleGrantRepository_Create(t *testing.T) {
+	sqlDB, sqlMock, repo := setupRoleGrantTest(t)
+	defer sqlDB.Close()
+	grant := &model.RoleGrant{UserID: uuid.New(), Role: model.RoleAdmin, Reason: "incident", GrantedBy: uuid.New(), ExpiresAt: time.Now().Add(time.Hour)}
+	id := uuid.New()
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(`INSERT INTO "role_grants"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(id))
+	sqlMock.ExpectCommit()
+
+	err := repo.Create(context.Background(), grant)
+
+	assert.NoError(t, err)
+	assert.Equal(t, id, grant.ID)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestRoleGrantRepository_FindActive(t *testing.T) {
+	userID := uuid.New()
+	at := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+
+	t.Run("grant active", func(t *testing.T) {
+		sqlDB, sqlMock, repo := setupRoleGrantTest(t)
+		defer sqlDB.Close()
+		id := uuid.New()
+		sqlMock.ExpectQuery(`SELECT \* FROM "role_grants" WHERE user_id = \$1 AND role = \$2 AND ended_at IS NULL AND expires_at > \$3 ORDER BY expires_at DESC LIMIT \$4`).
+			WithArgs(userID, model.RoleAdmin, at, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "role"}).AddRow(id, userID, model.RoleAdmin))
+
+		got, err := repo.FindActive(context.Background(), userID, model.RoleAdmin, at)
+
+		require.NoError(t, err)
+		assert.Equal(t, id, got.ID)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("no active grant", func(t *testing.T) {
+		sqlDB, sqlMock, repo := setupRoleGrantTest(t)
+		defer sqlDB.Close()
+		sqlMock.ExpectQuery(`SELECT \* FROM "role_grants"`).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		got, err := repo.FindActive(context.Background(), userID, model.RoleAdmin, at)
+
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.Nil(t, got)
+	})
+}
+
+func TestRoleGrantRepository_ListActive(t *testing.T) {
+	t.Run("grants not ended or expired", func(t *testing.T) {
+		sqlDB, sqlMock, repo := setupRoleGrantTest(t)
+		defer sqlDB.Close()
+		at := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+		sqlMock.ExpectQuery(`SELECT \* FROM "role_grants" WHERE ended_at IS NULL AND expires_at > \$1 ORDER BY expires_at ASC, id ASC`).
+			WithArgs(at).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()).AddRow(uuid.New()))
+
+		got, err := repo.ListActive(context.Background(), at)
+
+		require.NoError(t, err)
+		assert.Len(t, got, 2)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("database unavailable", func(t *testing.T) {
+		sqlDB, sqlMock, repo := setupRoleGrantTest(t)
+		defer sqlDB.Close()
+		sqlMock.ExpectQuery(`SELECT \* FROM "role_grants"`).
+			WillReturnError(sql.ErrConnDone)
+
+		got, err := repo.ListActive(context.Background(), time.Now())
+
+		assert.ErrorIs(t, err, ErrConn)
+		assert.Nil(t, got)
+	})
+}
+
+func TestRoleGrantRepository_Revoke(t *testing.T) {
+	id, adminID := uuid.New(), uuid.New()
+	at := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+
+	t.Run("active grant", func(t *testing.T) {
+		sqlDB, sqlMock, repo := setupRoleGrantTest(t)
+		defer sqlDB.Close()
+		sqlMock.ExpectBegin()
+		sqlMock.ExpectQuery(`SELECT \* FROM "role_grants" WHERE id = \$1 AND ended_at IS NULL AND expires_at > \$2 LIMIT \$3 FOR UPDATE`).
+			WithArgs(id, at, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "role"}).AddRow(id, model.RoleAdmin))
+		sqlMock.ExpectExec(`UPDATE "role_grants" SET "ended_at"=\$1,"revoked_at"=\$2,"revoked_by"=\$3 WHERE id = \$4`).
+			WithArgs(at, at, adminID, id).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		sqlMock.ExpectCommit()
+
+		got, err := repo.Revoke(context.Background(), id, adminID, at)
+
+		require.NoError(t, err)
+		assert.Equal(t, id, got.ID)
+		assert.Equal(t, &adminID, got.RevokedBy)
+		assert.Equal(t, &at, got.EndedAt)
+		assert.False(t, got.ActiveAt(at))
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("grant no longer active", func(t *testing.T) {
+		sqlDB, sqlMock, repo := setupRoleGrantTest(t)
+		defer sqlDB.Close()
+		sqlMock.ExpectBegin()
+		sqlMock.ExpectQuery(`SELECT \* FROM "role_grants"`).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		sqlMock.ExpectRollback()
+
+		got, err := repo.Revoke(context.Background(), id, adminID, at)
+
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.Nil(t, got)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+}
+
+func TestRoleGrantRepository_ExpireDue(t *testing.T) {
+	at := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+
+	t.Run("expired grants", func(t *testing.T) {
+		sqlDB, sqlMock, repo := setupRoleGrantTest(t)
+		defer sqlDB.Close()
+		first, second := uuid.New(), uuid.New()
+		sqlMock.ExpectBegin()
+		sqlMock.ExpectQuery(`SELECT \* FROM "role_grants" WHERE ended_at IS NULL AND expires_at <= \$1 ORDER BY expires_at ASC, id ASC LIMIT \$2 FOR UPDATE`).
+			WithArgs(at, 10).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(first).AddRow(second))
+		sqlMock.ExpectExec(`UPDATE "role_grants" SET "ended_at"=\$1 WHERE id IN \(\$2,\$3\)`).
+			WithArgs(at, first, second).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		sqlMock.ExpectCommit()
+
+		got, err := repo.ExpireDue(context.Background(), at, 10)
+
+		require.NoError(t, err)
+		require.Len(t, got, 2)
+		assert.Equal(t, first, got[0].ID)
+		assert.Equal(t, &at, got[1].EndedAt)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("nothing due", func(t *testing.T) {
+		sqlDB, sqlMock, repo := setupRoleGrantTest(t)
+		defer sqlDB.Close()
+		sqlMock.ExpectBegin()
+		sqlMock.ExpectQuery(`SELECT \* FROM "role_grants"`).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		sqlMock.ExpectCommit()
+
+		got, err := repo.ExpireDue(context.Background(), at, 10)
+
+		require.NoError(t, err)
+		assert.Empty(t, got)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,137 @@
+//go:build integration
+
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// TestSecurityEventRepository_ListByUser checks that the security events of a
+// user are read from every table they are kept in, newest first and a page at
+// a time, without those of other users.
+func TestSecurityEventRepository_ListByUser(t *testing.T) {
+	models := []any{&model.User{}, &model.RefreshToken{}, &model.LoginAlert{}, &model.AuditEntry{}}
+	forEachDatabase(t, models, func(t *testing.T, _ testDatabase, db *gorm.DB) {
+		testSecurityEventRepositoryListByUser(t, db)
+	})
+}
+
+func testSecurityEventRepositoryListByUser(t *testing.T, db *gorm.DB) {
+	repo := repository.NewSecurityEventRepository(db, 5*time.Second)
+
+	start := time.Now().Add(-time.Hour).Truncate(time.Microsecond)
+	user := seedSecurityEvents(t, db, start)
+	other := seedSecurityEvents(t, db, start.Add(30*time.Second))
+	want := []string{
+		model.SecurityEventSessionRevoked,
+		model.SecurityEventNewDeviceLogin,
+		model.SecurityEventAccountRecovered,
+		model.SecurityEventEmailChanged,
+		model.SecurityEventPasswordChanged,
+	}
+
+	var got []model.SecurityEvent
+	cursor := ""
+	for page := 0; ; page++ {
+		require.Less(t, page, len(want), "pagination ends")
+		events, next, err := repo.ListByUser(context.Background(), user.ID, time.Time{}, cursor, 2)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(events), 2)
+		got = append(got, events...)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	require.Len(t, got, len(want))
+	for i, event := range got {
+		assert.Equal(t, want[i], event.Type, "event %d", i)
+		if i > 0 {
+			assert.True(t, event.OccurredAt.Before(got[i-1].OccurredAt), "newest first")
+		}
+	}
+	assert.Equal(t, "192.0.2.1", got[1].IPAddress, "the address of the new device")
+	assert.JSONEq(t, `{"session_id": "`+got[0].ID.String()+`", "device": "Firefox on Linux"}`, string(got[0].Metadata))
+
+	recent, _, err := repo.ListByUser(context.Background(), user.ID, got[2].OccurredAt, "", 10)
+	require.NoError(t, err)
+	require.Len(t, recent, 2, "only the events after since")
+	assert.Equal(t, want[:2], []string{recent[0].Type, recent[1].Type})
+
+	otherEvents, _, err := repo.ListByUser(context.Background(), other.ID, time.Time{}, "", 10)
+	require.NoError(t, err)
+	assert.Len(t, otherEvents, len(want))
+	for _, event := range otherEvents {
+		for _, mine := range got {
+			assert.NotEqual(t, mine.ID, event.ID, "no events of another user")
+		}
+	}
+}
+
+// seedSecurityEvents creates a user with one event of each type a minute
+// apart from start, along with records that must not be listed: audited
+// requests that failed, were not security related or were made by an admin
+// impersonating the user, a rotated token of a family that had a login alert,
+// and sessions that are active or were revoked after they expired.
+func seedSecurityEvents(t *testing.T, db *gorm.DB, start time.Time) *model.User {
+	t.Helper()
+	user := &model.User{Email: uuid.NewString() + "@example.com", PasswordHash: "hash", FullName: "Test User"}
+	require.NoError(t, db.Create(user).Error)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+	audit := func(method, path string, status int, subject *uuid.UUID, createdAt time.Time) {
+		actor := user.ID
+		if subject != nil {
+			actor = uuid.New()
+		}
+		require.NoError(t, db.Create(&model.AuditEntry{
+			ActorID: actor, SubjectID: subject, Method: method, Path: path, Status: status, IPAddress: "198.51.100.7", CreatedAt: createdAt,
+		}).Error)
+	}
+
+	audit("PUT", "/api/auth/password", 200, nil, at(1))
+	audit("POST", "/api/auth/email-change/confirm", 200, nil, at(2))
+	audit("POST", "/api/auth/recovery/complete", 200, nil, at(3))
+	audit("PUT", "/api/auth/password", 400, nil, at(1))
+	audit("GET", "/api/auth/profile", 200, nil, at(2))
+	audit("PUT", "/api/auth/password", 200, &user.ID, at(3))
+
+	family := uuid.New()
+	root := &model.RefreshToken{
+		UserID: user.ID, FamilyID: family, TokenHash: uuid.NewString(), ExpiresAt: at(60), RotatedAt: ptr(at(5)),
+		IPAddress: "192.0.2.1", DeviceLabel: "Chrome on macOS", CreatedAt: at(4),
+	}
+	require.NoError(t, db.Create(root).Error)
+	require.NoError(t, db.Create(&model.RefreshToken{
+		UserID: user.ID, FamilyID: family, ParentID: &root.ID, TokenHash: uuid.NewString(), ExpiresAt: at(60),
+		IPAddress: "203.0.113.9", CreatedAt: at(5),
+	}).Error)
+	require.NoError(t, db.Create(&model.LoginAlert{
+		UserID: user.ID, FamilyID: family, TokenHash: uuid.NewString(), ExpiresAt: at(60), CreatedAt: at(4),
+	}).Error)
+
+	require.NoError(t, db.Create(&model.RefreshToken{
+		UserID: user.ID, FamilyID: uuid.New(), TokenHash: uuid.NewString(), ExpiresAt: at(60), RevokedAt: ptr(at(6)),
+		DeviceLabel: "Firefox on Linux", CreatedAt: at(0),
+	}).Error)
+	require.NoError(t, db.Create(&model.RefreshToken{
+		UserID: user.ID, FamilyID: uuid.New(), TokenHash: uuid.NewString(), ExpiresAt: at(6), RevokedAt: ptr(at(7)), CreatedAt: at(0),
+	}).Error)
+	require.NoError(t, db.Create(&model.RefreshToken{
+		UserID: user.ID, FamilyID: uuid.New(), TokenHash: uuid.NewString(), ExpiresAt: at(60), CreatedAt: at(0),
+	}).Error)
+	return user
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
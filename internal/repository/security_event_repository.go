@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/database"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// securityEventsQuery merges the records of a user's security events into one
+// shape, so that they can be filtered, ordered and paginated together:
+//   - the password changes, confirmed email changes and account recoveries the
+//     user made themselves, from the audit log;
+//   - the logins from a new device, from the login alerts sent about them,
+//     with the address and device of the session they started;
+//   - the sessions revoked before they expired, from the current token of
+//     each revoked family.
+//
+// The metadata is built with jsonb_build_object, which securityEventsSQL
+// replaces on databases other than PostgreSQL.
+const securityEventsQuery = `SELECT id,
+		CASE path
+			WHEN '/api/auth/password' THEN 'password_changed'
+			WHEN '/api/auth/email-change/confirm' THEN 'email_changed'
+			ELSE 'account_recovered'
+		END AS type,
+		created_at AS occurred_at, ip_address, jsonb_build_object() AS metadata
+	FROM audit_log
+	WHERE actor_id = @user AND subject_id IS NULL AND status BETWEEN 200 AND 299
+		AND (method, path) IN (('PUT', '/api/auth/password'), ('POST', '/api/auth/email-change/confirm'), ('POST', '/api/auth/recovery/complete'))
+	UNION ALL
+	SELECT a.id, 'new_device_login', a.created_at, t.ip_address,
+		jsonb_build_object('session_id', t.family_id, 'device', t.device_label, 'location', t.location)
+	FROM login_alerts a JOIN refresh_tokens t ON t.family_id = a.family_id AND t.parent_id IS NULL
+	WHERE a.user_id = @user
+	UNION ALL
+	SELECT family_id, 'session_revoked', revoked_at, ip_address,
+		jsonb_build_object('session_id', family_id, 'device', device_label)
+	FROM refresh_tokens
+	WHERE user_id = @user AND rotated_at IS NULL AND revoked_at IS NOT NULL AND revoked_at < expires_at`
+
+// securityEventsSQL returns securityEventsQuery for the dialect of db. Other
+// databases, such as the SQLite the tests run on, lack jsonb and build the
+// metadata with json_object instead.
+func securityEventsSQL(db *gorm.DB) string {
+	if database.IsPostgres(db) {
+		return securityEventsQuery
+	}
+	return strings.ReplaceAll(securityEventsQuery, "jsonb_build_object", "json_object")
+}
+
+// SecurityEventRepository reads the security events of users. Every query it
+// runs is bounded by queryTimeout in addition to any deadline on the caller's context.
+type SecurityEventRepository struct {
+	db           *gorm.DB
+	queryTimeout time.Duration
+}
+
+// NewSecurityEventRepository creates a SecurityEventRepository that bounds each query by queryTimeout.
+func NewSecurityEventRepository(db *gorm.DB, queryTimeout time.Duration) *SecurityEventRepository {
+	return &SecurityEventRepository{db: db, queryTimeout: queryTimeout}
+}
+
+// ListByUser retrieves up to limit security events of the given user, newest
+// first, starting after the position encoded in cursor. An empty cursor starts
+// from the most recent event, and a non-zero since leaves out the events that
+// occurred at or before it.
+//
+// It returns the events along with the cursor for the next page, which is empty
+// when there are no more rows. If the cursor cannot be decoded, ErrInvalidCursor
+// is returned without querying the database.
+func (r *SecurityEventRepository) ListByUser(ctx context.Context, userID uuid.UUID, since time.Time, cursor string, limit int) ([]model.SecurityEvent, string, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := r.db.WithContext(ctx).
+		Table("(?) AS events", r.db.Raw(securityEventsSQL(r.db), map[string]interface{}{"user": userID})).
+		Order("occurred_at DESC, id DESC").
+		Limit(limit + 1)
+
+	if !since.IsZero() {
+		query = query.Where("occurred_at > ?", since)
+	}
+	if cursor != "" {
+		occurredAt, id, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		query = query.Where("(occurred_at, id) < (?, ?)", occurredAt, id)
+	}
+
+	var events []model.SecurityEvent
+	if err := query.Find(&events).Error; err != nil {
+		return nil, "", translateError(ctx, err)
+	}
+
+	if len(events) <= limit {
+		return events, "", nil
+	}
+
+	events = events[:limit]
+	last := events[len(events)-1]
+	return events, encodeCursor(last.OccurredAt, last.ID), nil
+}
@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupSecurityEventTest(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *SecurityEventRepository) {
+	sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+	eventRepo := NewSecurityEventRepository(gormDB, testQueryTimeout)
+	return sqlDB, sqlMock, eventRepo
+}
+
+func TestNewSecurityEventRepository(t *testing.T) {
+	_, gormDB, _ := testutil.DbMock(t)
+	eventRepo := NewSecurityEventRepository(gormDB, testQueryTimeout)
+	assert.Equal(t, gormDB, eventRepo.db)
+	assert.Equal(t, testQueryTimeout, eventRepo.queryTimeout)
+}
+
+func TestSecurityEventRepository_ListByUser(t *testing.T) {
+	userID := uuid.New()
+	newer := model.SecurityEvent{ID: uuid.New(), Type: model.SecurityEventPasswordChanged, OccurredAt: time.Now()}
+	older := model.SecurityEvent{ID: uuid.New(), Type: model.SecurityEventSessionRevoked, OccurredAt: newer.OccurredAt.Add(-time.Minute)}
+	since := newer.OccurredAt.Add(-time.Hour)
+	columns := []string{"id", "type", "occurred_at", "ip_address", "metadata"}
+	union := `SELECT \* FROM \(SELECT id,.* FROM audit_log WHERE actor_id = \$1 .* FROM login_alerts .* WHERE a.user_id = \$2 .* FROM refresh_tokens WHERE user_id = \$3 .*\) AS events `
+
+	tests := []struct {
+		name           string
+		since          time.Time
+		cursor         string
+		limit          int
+		mockFn         func(sqlmock.Sqlmock)
+		wantLen        int
+		wantNextCursor string
+		wantErr        bool
+		errType        error
+	}{
+		{
+			name:  "first page with more results",
+			limit: 1,
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows(columns).
+					AddRow(newer.ID, newer.Type, newer.OccurredAt, "192.0.2.1", []byte(`{}`)).
+					AddRow(older.ID, older.Type, older.OccurredAt, "", []byte(`{"device":"Chrome on macOS"}`))
+				sqlMock.ExpectQuery(union+`ORDER BY occurred_at DESC, id DESC LIMIT \$4`).
+					WithArgs(userID, userID, userID, 2).
+					WillReturnRows(rows)
+			},
+			wantLen:        1,
+			wantNextCursor: encodeCursor(newer.OccurredAt, newer.ID),
+		},
+		{
+			name:   "last page after cursor since a time",
+			since:  since,
+			cursor: encodeCursor(newer.OccurredAt, newer.ID),
+			limit:  1,
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows(columns).
+					AddRow(older.ID, older.Type, older.OccurredAt, "", []byte(`{}`))
+				sqlMock.ExpectQuery(union+`WHERE occurred_at > \$4 AND \(occurred_at, id\) < \(\$5, \$6\) ORDER BY occurred_at DESC, id DESC LIMIT \$7`).
+					WithArgs(userID, userID, userID, since, sqlmock.AnyArg(), newer.ID, 2).
+					WillReturnRows(rows)
+			},
+			wantLen: 1,
+		},
+		{
+			name:    "invalid cursor",
+			cursor:  "not-a-cursor",
+			limit:   1,
+			mockFn:  func(sqlMock sqlmock.Sqlmock) {},
+			wantErr: true,
+			errType: ErrInvalidCursor,
+		},
+		{
+			name:  "database error",
+			limit: 1,
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectQuery(union).WillReturnError(sql.ErrConnDone)
+			},
+			wantErr: true,
+			errType: sql.ErrConnDone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, sqlMock, eventRepo := setupSecurityEventTest(t)
+			defer sqlDB.Close()
+			tt.mockFn(sqlMock)
+
+			got, nextCursor, err := eventRepo.ListByUser(context.Background(), userID, tt.since, tt.cursor, tt.limit)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.ErrorIs(t, err, tt.errType)
+				assert.Nil(t, got)
+			} else {
+				assert.NoError(t, err)
+				assert.Len(t, got, tt.wantLen)
+				assert.Equal(t, tt.wantNextCursor, nextCursor)
+			}
+
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,13 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// withTimeout derives a context bounded by the per-query timeout.
+// Because context.WithTimeout never extends an existing deadline, the shorter of
+// the two always applies.
+func withTimeout(ctx context.Context, queryTimeout time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, queryTimeout)
+}
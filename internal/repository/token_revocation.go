@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrCacheNotCleared is returned by CachedUserRepository.RevokeAllTokens when
+// the tokens were revoked in the database but the user's cache entry could
+// not be removed, so access tokens with the old version may be accepted until
+// the entry expires.
+var ErrCacheNotCleared = errors.New("tokens revoked but the user cache entry was not cleared")
+
+// TokenRevocation counts what RevokeAllTokens revoked.
+type TokenRevocation struct {
+	// TokenVersion is the user's new token version. Every access token
+	// issued with an earlier one is rejected.
+	TokenVersion int `json:"token_version"`
+	// Sessions is the number of sessions that had a refresh token left to revoke.
+	Sessions int64 `json:"sessions"`
+	// RefreshTokens is the number of refresh tokens revoked.
+	RefreshTokens int64 `json:"refresh_tokens"`
+	// CacheEntries is the number of cached copies of the user removed.
+	CacheEntries int64 `json:"cache_entries"`
+}
+
+// RevokeAllTokens increments the token version of the user with the given ID
+// and revokes every refresh token issued to them that is not revoked yet, in
+// one transaction, so either all of them are revoked or none is. Users whose
+// deletion was requested are covered like any other.
+// It returns ErrNotFound if there is no such user, an error if the operation
+// fails, or ErrTimeout if it exceeds the query timeout.
+func (r *UserRepository) RevokeAllTokens(ctx context.Context, id uuid.UUID) (*TokenRevocation, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var revocation TokenRevocation
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user model.User
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("token_version").
+			Where("id = ?", id).
+			Take(&user).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(&model.User{}).
+			Where("id = ?", id).
+			Update("token_version", gorm.Expr("token_version + 1")).Error
+		if err != nil {
+			return err
+		}
+		revocation.TokenVersion = user.TokenVersion + 1
+
+		unrevoked := tx.Model(&model.RefreshToken{}).Where("user_id = ? AND revoked_at IS NULL", id)
+		if err := unrevoked.Session(&gorm.Session{}).Distinct("family_id").Count(&revocation.Sessions).Error; err != nil {
+			return err
+		}
+		result := unrevoked.Session(&gorm.Session{}).Update("revoked_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		revocation.RefreshTokens = result.RowsAffected
+		return nil
+	})
+	if err != nil {
+		return nil, translateError(ctx, err)
+	}
+	return &revocation, nil
+}
+
+// RevokeAllTokens revokes the user's tokens like UserRepository.RevokeAllTokens
+// and then removes their cache entry, so the new token version takes effect
+// on the next request. Unlike the other writes, a failure to remove the entry
+// fails the call, with ErrCacheNotCleared along with what was revoked.
+func (r *CachedUserRepository) RevokeAllTokens(ctx context.Context, id uuid.UUID) (*TokenRevocation, error) {
+	revocation, err := r.UserRepository.RevokeAllTokens(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	_, cached, _ := r.cache.Get(ctx, id.String())
+	if err := r.evict(ctx, id.String()); err != nil {
+		return revocation, fmt.Errorf("%w: %w", ErrCacheNotCleared, err)
+	}
+	if cached {
+		revocation.CacheEntries = 1
+	}
+	return revocation, nil
+}
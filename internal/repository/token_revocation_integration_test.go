@@ -0,0 +1,56 @@
+//go:build integration
+
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// TestUserRepository_RevokeAllTokens checks that revoking a user's tokens
+// bumps their token version and revokes their refresh tokens alone, on every
+// one of the testDatabases, see TestUserRepository_Contract.
+func TestUserRepository_RevokeAllTokens(t *testing.T) {
+	models := []any{&model.User{}, &model.RefreshToken{}}
+	forEachDatabase(t, models, func(t *testing.T, _ testDatabase, db *gorm.DB) {
+		repo := repository.NewUserRepository(db, 5*time.Second)
+		user := testutil.Persist(t, repo, testutil.WithEmail("user@example.com"), testutil.WithDeleted(time.Now()))
+		other := testutil.Persist(t, repo, testutil.WithEmail("other@example.com"))
+
+		expires := time.Now().Add(time.Hour)
+		revokedAt := time.Now().Add(-time.Minute)
+		family := uuid.New()
+		for _, token := range []model.RefreshToken{
+			{UserID: user.ID, FamilyID: family, TokenHash: uuid.NewString(), ExpiresAt: expires},
+			{UserID: user.ID, FamilyID: family, TokenHash: uuid.NewString(), ExpiresAt: expires},
+			{UserID: user.ID, FamilyID: uuid.New(), TokenHash: uuid.NewString(), ExpiresAt: expires},
+			{UserID: user.ID, FamilyID: uuid.New(), TokenHash: uuid.NewString(), ExpiresAt: expires, RevokedAt: &revokedAt},
+			{UserID: other.ID, FamilyID: uuid.New(), TokenHash: uuid.NewString(), ExpiresAt: expires},
+		} {
+			require.NoError(t, db.Create(&token).Error)
+		}
+
+		revocation, err := repo.RevokeAllTokens(context.Background(), user.ID)
+
+		require.NoError(t, err)
+		assert.Equal(t, &repository.TokenRevocation{TokenVersion: 1, Sessions: 2, RefreshTokens: 3}, revocation)
+		found, err := repo.FindByID(context.Background(), user.ID.String())
+		require.NoError(t, err)
+		assert.Equal(t, 1, found.TokenVersion)
+		var unrevoked int64
+		require.NoError(t, db.Model(&model.RefreshToken{}).Where("revoked_at IS NULL").Count(&unrevoked).Error)
+		assert.Equal(t, int64(1), unrevoked, "only the other user's token")
+
+		_, err = repo.RevokeAllTokens(context.Background(), uuid.New())
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+	})
+}
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PakornBank/learn-go/internal/cache"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// expectRevokeAllTokens expects the transaction of RevokeAllTokens up to the
+// update of the refresh tokens, which revokes tokens of sessions.
+func expectRevokeAllTokens(sqlMock sqlmock.Sqlmock, userID uuid.UUID, version int, sessions, tokens int64) {
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(`SELECT "token_version" FROM "users" WHERE id = \$1 .*FOR UPDATE`).
+		WithArgs(userID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"token_version"}).AddRow(version))
+	sqlMock.ExpectExec(`UPDATE "users" SET "token_version"=token_version \+ 1,"updated_at"=\$1 WHERE id = \$2`).
+		WithArgs(sqlmock.AnyArg(), userID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectQuery(`SELECT COUNT\(DISTINCT\("family_id"\)\) FROM "refresh_tokens" WHERE user_id = \$1 AND revoked_at IS NULL`).
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(sessions))
+	sqlMock.ExpectExec(`UPDATE "refresh_tokens" SET "revoked_at"=\$1 WHERE user_id = \$2 AND revoked_at IS NULL`).
+		WithArgs(sqlmock.AnyArg(), userID).
+		WillReturnResult(sqlmock.NewResult(0, tokens))
+}
+
+func TestUserRepository_RevokeAllTokens(t *testing.T) {
+	userID := uuid.New()
+
+	tests := []struct {
+		name    string
+		mockFn  func(sqlmock.Sqlmock)
+		want    *TokenRevocation
+		wantErr error
+	}{
+		{
+			name: "revokes everything in one transaction",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				expectRevokeAllTokens(sqlMock, userID, 3, 2, 5)
+				sqlMock.ExpectCommit()
+			},
+			want: &TokenRevocation{TokenVersion: 4, Sessions: 2, RefreshTokens: 5},
+		},
+		{
+			name: "user without sessions",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				expectRevokeAllTokens(sqlMock, userID, 0, 0, 0)
+				sqlMock.ExpectCommit()
+			},
+			want: &TokenRevocation{TokenVersion: 1},
+		},
+		{
+			name: "user not found",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectQuery(`SELECT "token_version" FROM "users"`).
+					WillReturnRows(sqlmock.NewRows([]string{"token_version"}))
+				sqlMock.ExpectRollback()
+			},
+			wantErr: ErrNotFound,
+		},
+		{
+			name: "refresh tokens fail to update",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectQuery(`SELECT "token_version" FROM "users"`).
+					WillReturnRows(sqlmock.NewRows([]string{"token_version"}).AddRow(3))
+				sqlMock.ExpectExec(`UPDATE "users" SET "token_version"`).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				sqlMock.ExpectQuery(`SELECT COUNT\(DISTINCT\("family_id"\)\) FROM "refresh_tokens"`).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+				sqlMock.ExpectExec(`UPDATE "refresh_tokens" SET "revoked_at"`).
+					WillReturnError(sql.ErrConnDone)
+				sqlMock.ExpectRollback()
+			},
+			wantErr: ErrConn,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+			defer sqlDB.Close()
+			tt.mockFn(sqlMock)
+			repo := NewUserRepository(gormDB, testQueryTimeout)
+
+			got, err := repo.RevokeAllTokens(context.Background(), userID)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, got)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.want, got)
+			}
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestCachedUserRepository_RevokeAllTokensClearsCache(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+	defer sqlDB.Close()
+	c := cache.NewMemory()
+	repo := NewCachedUserRepository(NewUserRepository(gormDB, testQueryTimeout), NewEncodedUserCache(c, time.Minute))
+
+	expectFindUserByID(sqlMock, mockUser)
+	_, err := repo.FindByID(context.Background(), mockUser.ID.String())
+	require.NoError(t, err)
+
+	expectRevokeAllTokens(sqlMock, mockUser.ID, 0, 1, 2)
+	sqlMock.ExpectCommit()
+	revocation, err := repo.RevokeAllTokens(context.Background(), mockUser.ID)
+
+	require.NoError(t, err)
+	assert.Equal(t, &TokenRevocation{TokenVersion: 1, Sessions: 1, RefreshTokens: 2, CacheEntries: 1}, revocation)
+	_, ok, err := c.Get(context.Background(), userCacheKey(mockUser.ID.String()))
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCachedUserRepository_RevokeAllTokensReportsCacheFailure(t *testing.T) {
+	userID := uuid.New()
+	sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+	defer sqlDB.Close()
+	repo := NewCachedUserRepository(NewUserRepository(gormDB, testQueryTimeout), NewEncodedUserCache(failingCache{}, time.Minute))
+
+	expectRevokeAllTokens(sqlMock, userID, 0, 1, 1)
+	sqlMock.ExpectCommit()
+	revocation, err := repo.RevokeAllTokens(context.Background(), userID)
+
+	assert.ErrorIs(t, err, ErrCacheNotCleared)
+	assert.Equal(t, &TokenRevocation{TokenVersion: 1, Sessions: 1, RefreshTokens: 1}, revocation, "what the database revoked is reported")
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
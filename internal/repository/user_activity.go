@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Kinds of ActivityEvent.
+const (
+	ActivityLogin = "login"
+	ActivityAudit = "audit"
+)
+
+// UserActivity sums up what a user has been doing, for admins looking into
+// their account.
+type UserActivity struct {
+	// SessionCount is the number of sessions that are neither revoked nor expired.
+	SessionCount int64
+	// FailedAttempts is the number of failed logins since the last successful one.
+	FailedAttempts int64
+	// RecentEvents are the user's latest login attempts and audited
+	// requests made by or about them, newest first.
+	RecentEvents []ActivityEvent
+}
+
+// ActivityEvent is a login attempt or an audited request in UserActivity.
+// Kind is ActivityLogin or ActivityAudit, and only the fields of that kind
+// are set.
+type ActivityEvent struct {
+	Kind      string
+	ID        uuid.UUID
+	CreatedAt time.Time
+
+	Success   *bool
+	IPAddress *string
+	UserAgent *string
+
+	Method *string
+	Path   *string
+	Status *int
+}
+
+// activityCountsQuery counts the active sessions and failed logins of a user
+// in one round trip. A failed login counts when no successful one came at or
+// after it.
+const activityCountsQuery = `SELECT
+	(SELECT count(*) FROM refresh_tokens
+		WHERE user_id = @user AND rotated_at IS NULL AND revoked_at IS NULL AND expires_at > @now) AS session_count,
+	(SELECT count(*) FROM login_events AS failed
+		WHERE user_id = @user AND NOT success AND NOT EXISTS (
+			SELECT 1 FROM login_events WHERE user_id = @user AND success AND created_at >= failed.created_at)) AS failed_attempts`
+
+// recentActivityQuery merges the latest login events and audit entries of a
+// user into one list, so both come back in one round trip. Each side is
+// limited in a subquery of its own, which SQLite requires of a UNION whose
+// sides are ordered.
+const recentActivityQuery = `SELECT * FROM (
+	SELECT * FROM (SELECT 'login' AS kind, id, created_at, success, ip_address, user_agent,
+		CAST(NULL AS text) AS method, CAST(NULL AS text) AS path, CAST(NULL AS integer) AS status
+	FROM login_events WHERE user_id = @user
+	ORDER BY created_at DESC, id DESC LIMIT @limit) AS logins
+	UNION ALL
+	SELECT * FROM (SELECT 'audit' AS kind, id, created_at, NULL, NULL, NULL, method, path, status
+	FROM audit_log WHERE actor_id = @user OR subject_id = @user
+	ORDER BY created_at DESC, id DESC LIMIT @limit) AS audits
+) AS events ORDER BY created_at DESC, id DESC LIMIT @limit`
+
+// Activity returns the UserActivity of the user with the given ID, with up
+// to limit recent events. It runs two queries however many sessions and
+// events the user has. A user without any activity gets zero counts and no
+// events rather than ErrNotFound.
+// If a query exceeds its timeout, the error is ErrTimeout.
+func (r *UserRepository) Activity(ctx context.Context, id uuid.UUID, limit int) (*UserActivity, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var counts struct {
+		SessionCount   int64
+		FailedAttempts int64
+	}
+	err := r.reader(ctx).
+		Raw(activityCountsQuery, map[string]interface{}{"user": id, "now": time.Now()}).
+		Scan(&counts).Error
+	if err != nil {
+		return nil, translateError(ctx, err)
+	}
+	activity := &UserActivity{SessionCount: counts.SessionCount, FailedAttempts: counts.FailedAttempts}
+
+	err = r.reader(ctx).
+		Raw(recentActivityQuery, map[string]interface{}{"user": id, "limit": limit}).
+		Scan(&activity.RecentEvents).Error
+	if err != nil {
+		return nil, translateError(ctx, err)
+	}
+	if activity.RecentEvents == nil {
+		activity.RecentEvents = []ActivityEvent{}
+	}
+	return activity, nil
+}
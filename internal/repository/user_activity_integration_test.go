@@ -0,0 +1,76 @@
+//go:build integration
+
+package repository_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// TestUserRepository_ActivityQueryCount checks that the activity of a user
+// is read in the same number of queries however much of it there is, on
+// every one of the testDatabases, see TestUserRepository_Contract.
+func TestUserRepository_ActivityQueryCount(t *testing.T) {
+	models := []any{&model.User{}, &model.RefreshToken{}, &model.LoginEvent{}, &model.AuditEntry{}}
+	forEachDatabase(t, models, func(t *testing.T, tdb testDatabase, db *gorm.DB) {
+		var queries atomic.Int64
+		count := func(*gorm.DB) { queries.Add(1) }
+		require.NoError(t, db.Callback().Query().After("gorm:query").Register("test:count_queries", count))
+		require.NoError(t, db.Callback().Row().After("gorm:row").Register("test:count_rows", count))
+		repo := repository.NewUserRepository(db, 5*time.Second)
+
+		for _, size := range []int{1, 25} {
+			tdb.reset(t, db, models...)
+			user := seedActivity(t, db, size)
+			queries.Store(0)
+
+			activity, err := repo.Activity(context.Background(), user.ID, 5)
+
+			require.NoError(t, err)
+			assert.Equal(t, int64(2), queries.Load(), "queries for %d rows of each kind", size)
+			assert.Equal(t, int64(size), activity.SessionCount)
+			assert.Equal(t, int64(size), activity.FailedAttempts, "failed logins after the last successful one")
+			assert.Len(t, activity.RecentEvents, min(5, 2*size+2))
+			for i := 1; i < len(activity.RecentEvents); i++ {
+				assert.False(t, activity.RecentEvents[i].CreatedAt.After(activity.RecentEvents[i-1].CreatedAt), "newest first")
+			}
+		}
+	})
+}
+
+// seedActivity creates a user with size active sessions, a successful login
+// followed by size failed ones, and size audited requests, along with a
+// revoked session and an earlier failed login that must not be counted.
+func seedActivity(t *testing.T, db *gorm.DB, size int) *model.User {
+	t.Helper()
+	user := &model.User{Email: uuid.NewString() + "@example.com", PasswordHash: "hash", FullName: "Test User"}
+	require.NoError(t, db.Create(user).Error)
+
+	start := time.Now().Add(-time.Hour)
+	revokedAt := start
+	require.NoError(t, db.Create(&model.RefreshToken{
+		UserID: user.ID, FamilyID: uuid.New(), TokenHash: uuid.NewString(), ExpiresAt: start.Add(24 * time.Hour), RevokedAt: &revokedAt,
+	}).Error)
+	require.NoError(t, db.Create(&model.LoginEvent{UserID: &user.ID, Email: user.Email, CreatedAt: start}).Error)
+	require.NoError(t, db.Create(&model.LoginEvent{UserID: &user.ID, Email: user.Email, Success: true, CreatedAt: start.Add(time.Minute)}).Error)
+	for i := 0; i < size; i++ {
+		at := start.Add(time.Duration(i+2) * time.Minute)
+		require.NoError(t, db.Create(&model.RefreshToken{
+			UserID: user.ID, FamilyID: uuid.New(), TokenHash: uuid.NewString(), ExpiresAt: at.Add(24 * time.Hour),
+		}).Error)
+		require.NoError(t, db.Create(&model.LoginEvent{UserID: &user.ID, Email: user.Email, CreatedAt: at}).Error)
+		require.NoError(t, db.Create(&model.AuditEntry{
+			ActorID: user.ID, Method: "GET", Path: "/api/auth/profile", Status: 200, CreatedAt: at.Add(time.Second),
+		}).Error)
+	}
+	return user
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserRepository_Activity(t *testing.T) {
+	userID := uuid.New()
+	now := time.Now()
+
+	t.Run("counts and recent events", func(t *testing.T) {
+		sqlDB, _, sqlMock, userRepo := setupTest(t)
+		defer sqlDB.Close()
+		loginID, auditID := uuid.New(), uuid.New()
+		sqlMock.ExpectQuery(`SELECT \(SELECT count\(\*\) FROM refresh_tokens (.+)\) AS session_count, \(SELECT count\(\*\) FROM login_events (.+)\) AS failed_attempts`).
+			WithArgs(userID, sqlmock.AnyArg(), userID, userID).
+			WillReturnRows(sqlmock.NewRows([]string{"session_count", "failed_attempts"}).AddRow(2, 3))
+		sqlMock.ExpectQuery(`SELECT \* FROM \((.+) UNION ALL (.+)\) AS events ORDER BY created_at DESC, id DESC LIMIT \$\d+`).
+			WithArgs(userID, 5, userID, userID, 5, 5).
+			WillReturnRows(sqlmock.NewRows([]string{"kind", "id", "created_at", "success", "ip_address", "user_agent", "method", "path", "status"}).
+				AddRow(ActivityAudit, auditID, now, nil, nil, nil, "POST", "/api/auth/logout", 200).
+				AddRow(ActivityLogin, loginID, now.Add(-time.Minute), false, "203.0.113.7", "curl/8.0", nil, nil, nil))
+
+		got, err := userRepo.Activity(context.Background(), userID, 5)
+
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), got.SessionCount)
+		assert.Equal(t, int64(3), got.FailedAttempts)
+		require.Len(t, got.RecentEvents, 2)
+		assert.Equal(t, ActivityAudit, got.RecentEvents[0].Kind)
+		assert.Equal(t, "/api/auth/logout", *got.RecentEvents[0].Path)
+		assert.Nil(t, got.RecentEvents[0].Success)
+		assert.Equal(t, loginID, got.RecentEvents[1].ID)
+		assert.False(t, *got.RecentEvents[1].Success)
+		assert.Nil(t, got.RecentEvents[1].Status)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("no activity", func(t *testing.T) {
+		sqlDB, _, sqlMock, userRepo := setupTest(t)
+		defer sqlDB.Close()
+		sqlMock.ExpectQuery(`session_count`).
+			WillReturnRows(sqlmock.NewRows([]string{"session_count", "failed_attempts"}).AddRow(0, 0))
+		sqlMock.ExpectQuery(`UNION ALL`).
+			WillReturnRows(sqlmock.NewRows([]string{"kind", "id", "created_at"}))
+
+		got, err := userRepo.Activity(context.Background(), userID, 5)
+
+		require.NoError(t, err)
+		assert.Zero(t, got.SessionCount)
+		assert.NotNil(t, got.RecentEvents)
+		assert.Empty(t, got.RecentEvents)
+	})
+
+	t.Run("database error", func(t *testing.T) {
+		sqlDB, _, sqlMock, userRepo := setupTest(t)
+		defer sqlDB.Close()
+		sqlMock.ExpectQuery(`session_count`).WillReturnError(sql.ErrConnDone)
+
+		got, err := userRepo.Activity(context.Background(), userID, 5)
+
+		assert.ErrorIs(t, err, sql.ErrConnDone)
+		assert.Nil(t, got)
+	})
+}
@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/cache"
+	"github.com/PakornBank/learn-go/internal/model"
+	"gorm.io/datatypes"
+)
+
+const userCacheKeyPrefix = "user:"
+
+// UserCache keeps users looked up by ID so that repeated lookups do not reach
+// the database. Implementations must hand out and keep their own copies, so
+// that changes a caller makes to a user never leak into the cache.
+type UserCache interface {
+	// Get returns the cached user with the given ID. The boolean is false if
+	// the user is not cached or has expired.
+	Get(ctx context.Context, id string) (UserCacheEntry, bool, error)
+
+	// Set caches user under its ID.
+	Set(ctx context.Context, user *model.User) error
+
+	// Invalidate removes the user with the given ID. Missing users are ignored.
+	Invalidate(ctx context.Context, id string) error
+}
+
+// UserCacheEntry is a user held by a UserCache and the time it was cached.
+type UserCacheEntry struct {
+	User     *model.User
+	CachedAt time.Time
+}
+
+// EncodedUserCache is a UserCache that stores users as JSON in a cache.Cache,
+// such as Redis, so that the cache can be shared between replicas.
+type EncodedUserCache struct {
+	cache cache.Cache
+	ttl   time.Duration
+	now   func() time.Time
+}
+
+// cachedUser is the cached form of a user. model.User hides internal fields
+// from JSON, so they are carried separately to keep cached users complete. The
+// password hash is left out, so that it is never stored outside the database.
+type cachedUser struct {
+	model.User
+	DeletionRequestedAt     *time.Time                     `json:"deletion_requested_at"`
+	Metadata                datatypes.JSON                 `json:"metadata"`
+	AvatarURL               *string                        `json:"avatar_url"`
+	Username                *string                        `json:"username"`
+	TokenVersion            int                            `json:"token_version"`
+	TOSAcceptedVersion      int                            `json:"tos_accepted_version"`
+	TOSAcceptedAt           *time.Time                     `json:"tos_accepted_at"`
+	NotificationPreferences *model.NotificationPreferences `json:"notification_preferences"`
+	Locale                  string                         `json:"locale"`
+	Timezone                string                         `json:"timezone"`
+	PasswordChangedAt       time.Time                      `json:"password_changed_at"`
+	CachedAt                time.Time                      `json:"cached_at"`
+}
+
+// NewEncodedUserCache creates an EncodedUserCache that keeps users in c for ttl.
+func NewEncodedUserCache(c cache.Cache, ttl time.Duration) *EncodedUserCache {
+	return &EncodedUserCache{cache: c, ttl: ttl, now: time.Now}
+}
+
+// Get returns the cached user with the given ID. A malformed entry is
+// reported as an error.
+func (c *EncodedUserCache) Get(ctx context.Context, id string) (UserCacheEntry, bool, error) {
+	data, ok, err := c.cache.Get(ctx, userCacheKey(id))
+	if err != nil || !ok {
+		return UserCacheEntry{}, false, err
+	}
+
+	var cached cachedUser
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return UserCacheEntry{}, false, err
+	}
+	user := cached.User
+	user.DeletionRequestedAt = cached.DeletionRequestedAt
+	user.Metadata = cached.Metadata
+	user.AvatarURL = cached.AvatarURL
+	user.Username = cached.Username
+	user.TokenVersion = cached.TokenVersion
+	user.TOSAcceptedVersion = cached.TOSAcceptedVersion
+	user.TOSAcceptedAt = cached.TOSAcceptedAt
+	user.NotificationPreferences = cached.NotificationPreferences
+	user.Locale = cached.Locale
+	user.Timezone = cached.Timezone
+	user.PasswordChangedAt = cached.PasswordChangedAt
+	return UserCacheEntry{User: &user, CachedAt: cached.CachedAt}, true, nil
+}
+
+// Set caches user under its ID for the cache's TTL.
+func (c *EncodedUserCache) Set(ctx context.Context, user *model.User) error {
+	data, err := json.Marshal(cachedUser{
+		User:                    *user,
+		DeletionRequestedAt:     user.DeletionRequestedAt,
+		Metadata:                user.Metadata,
+		AvatarURL:               user.AvatarURL,
+		Username:                user.Username,
+		TokenVersion:            user.TokenVersion,
+		TOSAcceptedVersion:      user.TOSAcceptedVersion,
+		TOSAcceptedAt:           user.TOSAcceptedAt,
+		NotificationPreferences: user.NotificationPreferences,
+		Locale:                  user.Locale,
+		Timezone:                user.Timezone,
+		PasswordChangedAt:       user.PasswordChangedAt,
+		CachedAt:                c.now(),
+	})
+	if err != nil {
+		return err
+	}
+	return c.cache.Set(ctx, userCacheKey(user.ID.String()), data, c.ttl)
+}
+
+// Invalidate removes the user with the given ID.
+func (c *EncodedUserCache) Invalidate(ctx context.Context, id string) error {
+	return c.cache.Delete(ctx, userCacheKey(id))
+}
+
+func userCacheKey(id string) string {
+	return userCacheKeyPrefix + id
+}
+
+type lruEntry struct {
+	id        string
+	user      *model.User
+	cachedAt  time.Time
+	expiresAt time.Time
+}
+
+// LRUUserCache is an in-process UserCache holding at most a fixed number of
+// users, evicting the least recently used first. Users are cloned on the way
+// in and out. It is not shared between replicas, so a change made through one
+// replica is only seen by the others once their entry expires.
+type LRUUserCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	order   *list.List
+	entries map[string]*list.Element
+	now     func() time.Time
+}
+
+// NewLRUUserCache creates an LRUUserCache that keeps up to size users for ttl.
+func NewLRUUserCache(size int, ttl time.Duration) *LRUUserCache {
+	return &LRUUserCache{
+		size:    size,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: make(map[string]*list.Element, size),
+		now:     time.Now,
+	}
+}
+
+// Get returns a copy of the cached user with the given ID and marks it as
+// recently used. Expired users are removed when read.
+func (c *LRUUserCache) Get(_ context.Context, id string) (UserCacheEntry, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[id]
+	if !ok {
+		return UserCacheEntry{}, false, nil
+	}
+	entry := element.Value.(*lruEntry)
+	if !c.now().Before(entry.expiresAt) {
+		c.remove(element)
+		return UserCacheEntry{}, false, nil
+	}
+
+	c.order.MoveToFront(element)
+	return UserCacheEntry{User: entry.user.Clone(), CachedAt: entry.cachedAt}, true, nil
+}
+
+// Set caches a copy of user, evicting the least recently used user if the
+// cache is full.
+func (c *LRUUserCache) Set(_ context.Context, user *model.User) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id := user.ID.String()
+	now := c.now()
+	entry := &lruEntry{id: id, user: user.Clone(), cachedAt: now, expiresAt: now.Add(c.ttl)}
+	if element, ok := c.entries[id]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return nil
+	}
+
+	c.entries[id] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+	return nil
+}
+
+// Invalidate removes the user with the given ID.
+func (c *LRUUserCache) Invalidate(_ context.Context, id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[id]; ok {
+		c.remove(element)
+	}
+	return nil
+}
+
+// Len returns the number of cached users, including expired ones not yet removed.
+func (c *LRUUserCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
+
+func (c *LRUUserCache) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*lruEntry).id)
+}
@@ -0,0 +1,357 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PakornBank/learn-go/internal/cache"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+	"gorm.io/gorm/logger"
+)
+
+func TestLRUUserCache_StoresCopies(t *testing.T) {
+	c := NewLRUUserCache(10, time.Minute)
+	user := testutil.NewMockUser(testutil.WithUsername("tester"))
+
+	require.NoError(t, c.Set(context.Background(), &user))
+	user.Email = "changed-after-set@example.com"
+	*user.Username = "changed"
+
+	entry, ok, err := c.Get(context.Background(), user.ID.String())
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, testutil.NewMockUser().Email, entry.User.Email)
+	assert.Equal(t, "tester", *entry.User.Username)
+
+	entry.User.Email = "changed-after-get@example.com"
+	*entry.User.Username = "changed"
+	again, _, _ := c.Get(context.Background(), user.ID.String())
+	assert.Equal(t, testutil.NewMockUser().Email, again.User.Email)
+	assert.Equal(t, "tester", *again.User.Username)
+}
+
+func TestLRUUserCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewLRUUserCache(2, time.Minute)
+	first, second, third := model.User{ID: uuid.New()}, model.User{ID: uuid.New()}, model.User{ID: uuid.New()}
+
+	require.NoError(t, c.Set(context.Background(), &first))
+	require.NoError(t, c.Set(context.Background(), &second))
+	// Reading first makes second the least recently used.
+	_, ok, _ := c.Get(context.Background(), first.ID.String())
+	require.True(t, ok)
+	require.NoError(t, c.Set(context.Background(), &third))
+
+	assert.Equal(t, 2, c.Len())
+	for id, want := range map[uuid.UUID]bool{first.ID: true, second.ID: false, third.ID: true} {
+		_, ok, err := c.Get(context.Background(), id.String())
+		require.NoError(t, err)
+		assert.Equal(t, want, ok, id)
+	}
+}
+
+func TestLRUUserCache_SetReplaces(t *testing.T) {
+	c := NewLRUUserCache(2, time.Minute)
+	user := model.User{ID: uuid.New(), Email: "old@example.com"}
+	require.NoError(t, c.Set(context.Background(), &user))
+	user.Email = "new@example.com"
+	require.NoError(t, c.Set(context.Background(), &user))
+
+	entry, ok, err := c.Get(context.Background(), user.ID.String())
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "new@example.com", entry.User.Email)
+	assert.Equal(t, 1, c.Len())
+}
+
+func TestLRUUserCache_Expires(t *testing.T) {
+	now := time.Now()
+	c := NewLRUUserCache(10, time.Minute)
+	c.now = func() time.Time { return now }
+	user := model.User{ID: uuid.New()}
+	require.NoError(t, c.Set(context.Background(), &user))
+
+	now = now.Add(59 * time.Second)
+	entry, ok, _ := c.Get(context.Background(), user.ID.String())
+	assert.True(t, ok)
+	assert.Equal(t, now.Add(-59*time.Second), entry.CachedAt)
+
+	now = now.Add(time.Second)
+	_, ok, _ = c.Get(context.Background(), user.ID.String())
+	assert.False(t, ok)
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestLRUUserCache_Invalidate(t *testing.T) {
+	c := NewLRUUserCache(10, time.Minute)
+	user := model.User{ID: uuid.New()}
+	require.NoError(t, c.Set(context.Background(), &user))
+
+	require.NoError(t, c.Invalidate(context.Background(), user.ID.String()))
+	require.NoError(t, c.Invalidate(context.Background(), uuid.NewString()))
+
+	_, ok, err := c.Get(context.Background(), user.ID.String())
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestEncodedUserCache_MalformedEntry(t *testing.T) {
+	id := uuid.NewString()
+	store := cache.NewMemory()
+	require.NoError(t, store.Set(context.Background(), userCacheKey(id), []byte("not json"), time.Minute))
+
+	_, ok, err := NewEncodedUserCache(store, time.Minute).Get(context.Background(), id)
+
+	assert.Error(t, err)
+	assert.False(t, ok)
+}
+
+func TestEncodedUserCache_LeavesOutPasswordHash(t *testing.T) {
+	user := testutil.NewMockUser(testutil.WithPassword("password"))
+	store := cache.NewMemory()
+
+	require.NoError(t, NewEncodedUserCache(store, time.Minute).Set(context.Background(), &user))
+
+	data, ok, err := store.Get(context.Background(), userCacheKey(user.ID.String()))
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.NotContains(t, string(data), user.PasswordHash)
+	assert.NotContains(t, string(data), "password_hash")
+}
+
+// TestCachedUserRepository_LRUInvalidatesOnEveryWrite checks that after each
+// method that changes a user, the next lookup reaches the database again.
+func TestCachedUserRepository_LRUInvalidatesOnEveryWrite(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	ctx := context.Background()
+
+	expectUpdate := func(column string) func(sqlmock.Sqlmock) {
+		return func(sqlMock sqlmock.Sqlmock) {
+			sqlMock.ExpectBegin()
+			sqlMock.ExpectExec(`UPDATE "users" SET "` + column + `"`).WillReturnResult(sqlmock.NewResult(0, 1))
+			sqlMock.ExpectCommit()
+		}
+	}
+
+	tests := []struct {
+		name   string
+		expect func(sqlmock.Sqlmock)
+		write  func(*CachedUserRepository) error
+	}{
+		{
+			name:   "last login",
+			expect: expectUpdate("last_login_at"),
+			write:  func(r *CachedUserRepository) error { return r.UpdateLastLogin(ctx, mockUser.ID, time.Now()) },
+		},
+		{
+			name:   "email",
+			expect: expectUpdate("email"),
+			write:  func(r *CachedUserRepository) error { return r.UpdateEmail(ctx, mockUser.ID, "new@example.com") },
+		},
+		{
+			name:   "password change",
+			expect: expectUpdate("password_changed_at"),
+			write:  func(r *CachedUserRepository) error { return r.UpdatePassword(ctx, mockUser.ID, "new-hash", time.Now()) },
+		},
+		{
+			name:   "invite accepted",
+			expect: expectUpdate("password_changed_at"),
+			write: func(r *CachedUserRepository) error {
+				_, err := r.SetInitialPassword(ctx, mockUser.ID, "new-hash", time.Now())
+				return err
+			},
+		},
+		{
+			name:   "avatar",
+			expect: expectUpdate("avatar_url"),
+			write:  func(r *CachedUserRepository) error { return r.UpdateAvatarURL(ctx, mockUser.ID, "/avatars/new.png") },
+		},
+		{
+			name:   "token revocation",
+			expect: expectUpdate("token_version"),
+			write:  func(r *CachedUserRepository) error { return r.IncrementTokenVersion(ctx, mockUser.ID) },
+		},
+		{
+			name:   "username",
+			expect: expectUpdate("username"),
+			write: func(r *CachedUserRepository) error {
+				_, err := r.SetUsername(ctx, mockUser.ID, "tester")
+				return err
+			},
+		},
+		{
+			name: "metadata",
+			expect: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectQuery(`SELECT "metadata" FROM "users"`).WillReturnRows(sqlmock.NewRows([]string{"metadata"}).AddRow(nil))
+				sqlMock.ExpectExec(`UPDATE "users" SET "metadata"`).WillReturnResult(sqlmock.NewResult(0, 1))
+				sqlMock.ExpectCommit()
+			},
+			write: func(r *CachedUserRepository) error {
+				_, err := r.MergeMetadata(ctx, mockUser.ID, map[string]json.RawMessage{"theme": json.RawMessage(`"dark"`)}, acceptMetadata)
+				return err
+			},
+		},
+		{
+			name:   "terms of service",
+			expect: expectUpdate("tos_accepted_at"),
+			write:  func(r *CachedUserRepository) error { return r.AcceptTOS(ctx, mockUser.ID, 2, time.Now()) },
+		},
+		{
+			name:   "notification preferences",
+			expect: expectUpdate("notification_preferences"),
+			write: func(r *CachedUserRepository) error {
+				return r.UpdateNotificationPreferences(ctx, mockUser.ID, model.DefaultNotificationPreferences())
+			},
+		},
+		{
+			name:   "locale",
+			expect: expectUpdate("locale"),
+			write:  func(r *CachedUserRepository) error { return r.UpdateLocale(ctx, mockUser.ID, "th-TH", "Asia/Bangkok") },
+		},
+		{
+			name:   "deletion request",
+			expect: expectUpdate("deletion_requested_at"),
+			write:  func(r *CachedUserRepository) error { return r.RequestDeletion(ctx, mockUser.ID, time.Now()) },
+		},
+		{
+			name:   "deletion cancelled",
+			expect: expectUpdate("deletion_requested_at"),
+			write:  func(r *CachedUserRepository) error { return r.CancelDeletion(ctx, mockUser.ID) },
+		},
+		{
+			name:   "deleted account restored",
+			expect: expectUpdate("deletion_requested_at"),
+			write:  func(r *CachedUserRepository) error { return r.RestoreDeleted(ctx, mockUser.ID) },
+		},
+		{
+			name: "account purged",
+			expect: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectQuery(`SELECT "id","avatar_url" FROM "users"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(mockUser.ID))
+				sqlMock.ExpectExec(`DELETE FROM "login_events"`).WillReturnResult(sqlmock.NewResult(0, 0))
+				sqlMock.ExpectExec(`DELETE FROM "refresh_tokens"`).WillReturnResult(sqlmock.NewResult(0, 0))
+				sqlMock.ExpectExec(`DELETE FROM "email_change_requests"`).WillReturnResult(sqlmock.NewResult(0, 0))
+				sqlMock.ExpectExec(`DELETE FROM "login_alerts"`).WillReturnResult(sqlmock.NewResult(0, 0))
+				sqlMock.ExpectExec(`DELETE FROM "recovery_requests"`).WillReturnResult(sqlmock.NewResult(0, 0))
+				sqlMock.ExpectExec(`DELETE FROM "identities"`).WillReturnResult(sqlmock.NewResult(0, 0))
+				sqlMock.ExpectExec(`DELETE FROM "role_grants"`).WillReturnResult(sqlmock.NewResult(0, 0))
+				sqlMock.ExpectExec(`DELETE FROM "users"`).WillReturnResult(sqlmock.NewResult(0, 1))
+				sqlMock.ExpectCommit()
+			},
+			write: func(r *CachedUserRepository) error {
+				_, err := r.PurgeDeletionRequestedBefore(ctx, time.Now(), 10)
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+			defer sqlDB.Close()
+			repo := NewCachedUserRepository(NewUserRepository(gormDB, testQueryTimeout), NewLRUUserCache(10, time.Minute))
+
+			// The second lookup is served from the cache.
+			expectFindUserByID(sqlMock, mockUser)
+			_, err := repo.FindByID(ctx, mockUser.ID.String())
+			require.NoError(t, err)
+			_, err = repo.FindByID(ctx, mockUser.ID.String())
+			require.NoError(t, err)
+
+			tt.expect(sqlMock)
+			require.NoError(t, tt.write(repo))
+
+			// The lookup after the write is not.
+			expectFindUserByID(sqlMock, mockUser)
+			_, err = repo.FindByID(ctx, mockUser.ID.String())
+			require.NoError(t, err)
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
+
+// countingDB returns a database whose queries are answered with the user
+// whose ID they ask for, without reaching a server, and a pointer to the
+// number of queries and updates run.
+func countingDB(b *testing.B) (*gorm.DB, *int) {
+	b.Helper()
+	sqlDB, _, err := sqlmock.New()
+	require.NoError(b, err)
+	b.Cleanup(func() { sqlDB.Close() })
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger:                 logger.Discard,
+		SkipDefaultTransaction: true,
+	})
+	require.NoError(b, err)
+
+	calls := new(int)
+	require.NoError(b, db.Callback().Query().Replace("gorm:query", func(tx *gorm.DB) {
+		*calls++
+		callbacks.BuildQuerySQL(tx)
+		user := tx.Statement.Dest.(*model.User)
+		user.ID = uuid.MustParse(tx.Statement.Vars[0].(string))
+		tx.RowsAffected = 1
+	}))
+	require.NoError(b, db.Callback().Update().Replace("gorm:update", func(tx *gorm.DB) {
+		*calls++
+		tx.RowsAffected = 1
+	}))
+	return db, calls
+}
+
+// BenchmarkCachedUserRepository_FindByID looks up 100 users in turn, as
+// GET /api/profile does, and reports how many database calls each lookup costs.
+func BenchmarkCachedUserRepository_FindByID(b *testing.B) {
+	ids := make([]uuid.UUID, 100)
+	for i := range ids {
+		ids[i] = uuid.New()
+	}
+
+	benchmarks := []struct {
+		name       string
+		cached     bool
+		writeEvery int
+	}{
+		{name: "uncached"},
+		{name: "lru", cached: true},
+		{name: "lru with a write every 10 lookups", cached: true, writeEvery: 10},
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			db, calls := countingDB(b)
+			var repo interface {
+				FindByID(ctx context.Context, id string) (*model.User, error)
+				UpdateLastLogin(ctx context.Context, id uuid.UUID, at time.Time) error
+			} = NewUserRepository(db, testQueryTimeout)
+			if bm.cached {
+				repo = NewCachedUserRepository(NewUserRepository(db, testQueryTimeout), NewLRUUserCache(len(ids), time.Minute))
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				id := ids[i%len(ids)]
+				if bm.writeEvery > 0 && i%bm.writeEvery == 0 {
+					if err := repo.UpdateLastLogin(context.Background(), id, time.Now()); err != nil {
+						b.Fatal(err)
+					}
+				}
+				if _, err := repo.FindByID(context.Background(), id.String()); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			b.ReportMetric(float64(*calls)/float64(b.N), "db-calls/op")
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+)
+
+// EachBatch calls fn with every user created after createdAfter, or every
+// user if it is zero, in batches of up to batchSize ordered by creation time.
+// Batches are read with the same keyset on (created_at, id) as ListAfter, each
+// with its own query and timeout, so only one batch is held in memory and a
+// long export is never cut short by the query timeout.
+//
+// It stops and returns the error if fn fails, and returns the error of ctx if
+// it is done before the next batch is read.
+func (r *UserRepository) EachBatch(ctx context.Context, createdAfter time.Time, batchSize int, fn func([]model.User) error) error {
+	var cursor string
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		users, next, err := r.listAfter(ctx, createdAfter, cursor, batchSize)
+		if err != nil {
+			return err
+		}
+		if len(users) > 0 {
+			if err := fn(users); err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		cursor = next
+	}
+}
@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserRepository_EachBatch(t *testing.T) {
+	first := testutil.NewMockUser()
+	second := testutil.NewMockUser(testutil.WithEmail("second@example.com"), testutil.WithCreatedAt(first.CreatedAt.Add(time.Second)))
+	third := testutil.NewMockUser(testutil.WithEmail("third@example.com"), testutil.WithCreatedAt(second.CreatedAt.Add(time.Second)))
+	createdAfter := first.CreatedAt.Add(-time.Hour)
+	columns := []string{"id", "email", "password_hash", "full_name", "role", "created_at", "updated_at"}
+	row := func(rows *sqlmock.Rows, u model.User) *sqlmock.Rows {
+		return rows.AddRow(u.ID, u.Email, u.PasswordHash, u.FullName, u.Role, u.CreatedAt, u.UpdatedAt)
+	}
+
+	tests := []struct {
+		name         string
+		createdAfter time.Time
+		mockFn       func(sqlmock.Sqlmock)
+		wantBatches  [][]string
+	}{
+		{
+			name: "every user in batches",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectQuery(`SELECT \* FROM "users" ORDER BY created_at ASC, id ASC LIMIT \$1`).
+					WithArgs(3).
+					WillReturnRows(row(row(row(sqlmock.NewRows(columns), first), second), third))
+				sqlMock.ExpectQuery(`SELECT \* FROM "users" WHERE \(created_at, id\) > \(\$1, \$2\) ORDER BY created_at ASC, id ASC LIMIT \$3`).
+					WithArgs(sqlmock.AnyArg(), second.ID, 3).
+					WillReturnRows(row(sqlmock.NewRows(columns), third))
+			},
+			wantBatches: [][]string{{first.Email, second.Email}, {third.Email}},
+		},
+		{
+			name:         "created after",
+			createdAfter: createdAfter,
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectQuery(`SELECT \* FROM "users" WHERE created_at > \$1 ORDER BY created_at ASC, id ASC LIMIT \$2`).
+					WithArgs(createdAfter, 3).
+					WillReturnRows(row(sqlmock.NewRows(columns), third))
+			},
+			wantBatches: [][]string{{third.Email}},
+		},
+		{
+			name: "no users",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectQuery(`SELECT \* FROM "users"`).WillReturnRows(sqlmock.NewRows(columns))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, _, sqlMock, userRepo := setupTest(t)
+			defer sqlDB.Close()
+			tt.mockFn(sqlMock)
+
+			var batches [][]string
+			err := userRepo.EachBatch(context.Background(), tt.createdAfter, 2, func(users []model.User) error {
+				var emails []string
+				for _, user := range users {
+					emails = append(emails, user.Email)
+				}
+				batches = append(batches, emails)
+				return nil
+			})
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantBatches, batches)
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUserRepository_EachBatchStops(t *testing.T) {
+	first := testutil.NewMockUser()
+	columns := []string{"id", "email", "password_hash", "full_name", "role", "created_at", "updated_at"}
+	fullPage := func() *sqlmock.Rows {
+		return sqlmock.NewRows(columns).
+			AddRow(first.ID, first.Email, first.PasswordHash, first.FullName, first.Role, first.CreatedAt, first.UpdatedAt).
+			AddRow(first.ID, first.Email, first.PasswordHash, first.FullName, first.Role, first.CreatedAt, first.UpdatedAt)
+	}
+	errWrite := errors.New("client went away")
+
+	t.Run("context cancelled between batches", func(t *testing.T) {
+		sqlDB, _, sqlMock, userRepo := setupTest(t)
+		defer sqlDB.Close()
+		sqlMock.ExpectQuery(`SELECT \* FROM "users"`).WillReturnRows(fullPage())
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		calls := 0
+		err := userRepo.EachBatch(ctx, time.Time{}, 1, func([]model.User) error {
+			calls++
+			cancel()
+			return nil
+		})
+
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 1, calls)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("callback fails", func(t *testing.T) {
+		sqlDB, _, sqlMock, userRepo := setupTest(t)
+		defer sqlDB.Close()
+		sqlMock.ExpectQuery(`SELECT \* FROM "users"`).WillReturnRows(fullPage())
+
+		err := userRepo.EachBatch(context.Background(), time.Time{}, 1, func([]model.User) error { return errWrite })
+
+		assert.ErrorIs(t, err, errWrite)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("database error", func(t *testing.T) {
+		sqlDB, _, sqlMock, userRepo := setupTest(t)
+		defer sqlDB.Close()
+		sqlMock.ExpectQuery(`SELECT \* FROM "users"`).WillReturnError(sql.ErrConnDone)
+
+		err := userRepo.EachBatch(context.Background(), time.Time{}, 1, func([]model.User) error { return nil })
+
+		assert.ErrorIs(t, err, sql.ErrConnDone)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/google/uuid"
+)
+
+// CreateBatch inserts users with a single statement in its own transaction, so
+// either all of them are stored or none are. Their generated fields, such as
+// the ID, are set on the given users. If any of them has an email or username
+// that is already taken, nothing is stored and the error is ErrDuplicate.
+// It returns an error if the insert fails, or ErrTimeout if it exceeds the query timeout.
+func (r *UserRepository) CreateBatch(ctx context.Context, users []*model.User) error {
+	if len(users) == 0 {
+		return nil
+	}
+
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	return translateError(ctx, r.db.WithContext(ctx).Create(users).Error)
+}
+
+// FindExistingEmails returns which of the given email addresses belong to a
+// user. Addresses are matched exactly, as FindByEmail matches them, and no
+// query is run when emails is empty.
+// If the query exceeds its timeout, the error is ErrTimeout.
+func (r *UserRepository) FindExistingEmails(ctx context.Context, emails []string) (map[string]bool, error) {
+	existing := make(map[string]bool)
+	if len(emails) == 0 {
+		return existing, nil
+	}
+
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var found []string
+	err := r.db.WithContext(ctx).Model(&model.User{}).
+		Where("email IN ?", emails).
+		Pluck("email", &found).Error
+	if err != nil {
+		return nil, translateError(ctx, err)
+	}
+
+	for _, email := range found {
+		existing[email] = true
+	}
+	return existing, nil
+}
+
+// SetInitialPassword sets the password hash of the user with the given ID if
+// they have no password yet, as is the case for invited users, recording
+// changedAt as when the password was changed, and reports whether it was set. The check and the update are a single statement, so an
+// invitation cannot be accepted twice.
+// It returns an error if the operation fails, or ErrTimeout if it exceeds the query timeout.
+func (r *UserRepository) SetInitialPassword(ctx context.Context, id uuid.UUID, passwordHash string, changedAt time.Time) (bool, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	result := r.db.WithContext(ctx).Model(&model.User{}).
+		Where("id = ? AND password_hash = ''", id).
+		Updates(map[string]interface{}{
+			"password_hash":       passwordHash,
+			"password_changed_at": changedAt,
+		})
+	if result.Error != nil {
+		return false, translateError(ctx, result.Error)
+	}
+
+	return result.RowsAffected > 0, nil
+}
@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserRepository_CreateBatch(t *testing.T) {
+	firstID, secondID := uuid.New(), uuid.New()
+	newUsers := func() []*model.User {
+		return []*model.User{
+			{Email: "first@example.com", PasswordHash: "hash", FullName: "First"},
+			{Email: "second@example.com", FullName: "Second"},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		mockFn  func(sqlmock.Sqlmock)
+		wantErr error
+	}{
+		{
+			name: "created",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectQuery(`INSERT INTO "users" .* VALUES \(.*\),\(.*\) RETURNING "id"`).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(firstID).AddRow(secondID))
+				sqlMock.ExpectCommit()
+			},
+		},
+		{
+			name: "duplicate email",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectQuery(`INSERT INTO "users"`).WillReturnError(&pgconn.PgError{Code: uniqueViolation})
+				sqlMock.ExpectRollback()
+			},
+			wantErr: ErrDuplicate,
+		},
+		{
+			name: "database error",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectQuery(`INSERT INTO "users"`).WillReturnError(sql.ErrConnDone)
+				sqlMock.ExpectRollback()
+			},
+			wantErr: sql.ErrConnDone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, _, sqlMock, userRepo := setupTest(t)
+			defer sqlDB.Close()
+			tt.mockFn(sqlMock)
+			users := newUsers()
+
+			err := userRepo.CreateBatch(context.Background(), users)
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			if tt.wantErr == nil {
+				assert.Equal(t, firstID, users[0].ID)
+				assert.Equal(t, secondID, users[1].ID)
+			}
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUserRepository_CreateBatchEmpty(t *testing.T) {
+	sqlDB, _, sqlMock, userRepo := setupTest(t)
+	defer sqlDB.Close()
+
+	assert.NoError(t, userRepo.CreateBatch(context.Background(), nil))
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestUserRepository_FindExistingEmails(t *testing.T) {
+	sqlDB, _, sqlMock, userRepo := setupTest(t)
+	defer sqlDB.Close()
+	sqlMock.ExpectQuery(`SELECT "email" FROM "users" WHERE email IN \(\$1,\$2\)`).
+		WithArgs("taken@example.com", "free@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"email"}).AddRow("taken@example.com"))
+
+	existing, err := userRepo.FindExistingEmails(context.Background(), []string{"taken@example.com", "free@example.com"})
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"taken@example.com": true}, existing)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+
+	none, err := userRepo.FindExistingEmails(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Empty(t, none)
+}
+
+func TestUserRepository_SetInitialPassword(t *testing.T) {
+	id := uuid.New()
+	changedAt := time.Now()
+	const query = `UPDATE "users" SET "password_changed_at"=\$1,"password_hash"=\$2,"updated_at"=\$3 WHERE id = \$4 AND password_hash = ''`
+
+	tests := []struct {
+		name         string
+		rowsAffected int64
+		wantSet      bool
+	}{
+		{name: "set", rowsAffected: 1, wantSet: true},
+		{name: "password already set", rowsAffected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, _, sqlMock, userRepo := setupTest(t)
+			defer sqlDB.Close()
+			sqlMock.ExpectBegin()
+			sqlMock.ExpectExec(query).
+				WithArgs(changedAt, "new-hash", sqlmock.AnyArg(), id).
+				WillReturnResult(sqlmock.NewResult(0, tt.rowsAffected))
+			sqlMock.ExpectCommit()
+
+			set, err := userRepo.SetInitialPassword(context.Background(), id, "new-hash", changedAt)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantSet, set)
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
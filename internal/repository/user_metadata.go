@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInvalidMetadata is returned when stored user metadata is not a JSON object.
+var ErrInvalidMetadata = errors.New("stored metadata is not a JSON object")
+
+// MergeMetadata merges patch into the metadata of the user with the given ID
+// and returns the merged metadata. Keys in patch replace existing keys, and a
+// key whose value is JSON null is removed.
+//
+// The user's row is locked while the merge is applied, so concurrent merges
+// never lose each other's keys. validate is called with the merged metadata
+// before it is written; if it returns an error, nothing is written and the
+// error is returned. If the user does not exist, ErrNotFound is
+// returned, and if the query exceeds its timeout, ErrTimeout.
+func (r *UserRepository) MergeMetadata(ctx context.Context, id uuid.UUID, patch map[string]json.RawMessage, validate func(map[string]json.RawMessage) error) (datatypes.JSON, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var merged datatypes.JSON
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user model.User
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("metadata").
+			Where("id = ?", id).
+			First(&user).Error
+		if err != nil {
+			return err
+		}
+
+		metadata, err := mergeMetadata(user.Metadata, patch)
+		if err != nil {
+			return err
+		}
+		if err := validate(metadata); err != nil {
+			return err
+		}
+
+		merged, err = json.Marshal(metadata)
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&model.User{}).Where("id = ?", id).Update("metadata", merged).Error
+	})
+	if err != nil {
+		return nil, translateError(ctx, err)
+	}
+
+	return merged, nil
+}
+
+// mergeMetadata applies patch to the JSON object current, which may be empty
+// or JSON null, removing the keys patch sets to null.
+func mergeMetadata(current datatypes.JSON, patch map[string]json.RawMessage) (map[string]json.RawMessage, error) {
+	var metadata map[string]json.RawMessage
+	if len(current) > 0 {
+		if err := json.Unmarshal(current, &metadata); err != nil {
+			return nil, ErrInvalidMetadata
+		}
+	}
+	if metadata == nil {
+		metadata = make(map[string]json.RawMessage, len(patch))
+	}
+
+	for key, value := range patch {
+		if string(value) == "null" {
+			delete(metadata, key)
+			continue
+		}
+		metadata[key] = value
+	}
+
+	return metadata, nil
+}
@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+func acceptMetadata(map[string]json.RawMessage) error { return nil }
+
+func TestUserRepository_MergeMetadata(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	errTooLarge := errors.New("too large")
+	selectMetadata := `SELECT "metadata" FROM "users" WHERE id = \$1 ORDER BY "users"."id" LIMIT \$2 FOR UPDATE`
+	updateMetadata := `UPDATE "users" SET "metadata"=\$1,"updated_at"=\$2 WHERE id = \$3`
+
+	tests := []struct {
+		name     string
+		patch    map[string]json.RawMessage
+		validate func(map[string]json.RawMessage) error
+		mockFn   func(sqlmock.Sqlmock)
+		want     string
+		wantErr  error
+	}{
+		{
+			name: "merges into stored metadata",
+			patch: map[string]json.RawMessage{
+				"theme": json.RawMessage(`"light"`),
+				"tags":  json.RawMessage(`["a","b"]`),
+			},
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectQuery(selectMetadata).
+					WithArgs(mockUser.ID, 1).
+					WillReturnRows(sqlmock.NewRows([]string{"metadata"}).AddRow([]byte(`{"theme":"dark","locale":"en"}`)))
+				sqlMock.ExpectExec(updateMetadata).
+					WithArgs(`{"locale":"en","tags":["a","b"],"theme":"light"}`, sqlmock.AnyArg(), mockUser.ID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				sqlMock.ExpectCommit()
+			},
+			want: `{"locale":"en","tags":["a","b"],"theme":"light"}`,
+		},
+		{
+			name:  "null deletes a key",
+			patch: map[string]json.RawMessage{"theme": json.RawMessage(`null`)},
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectQuery(selectMetadata).
+					WithArgs(mockUser.ID, 1).
+					WillReturnRows(sqlmock.NewRows([]string{"metadata"}).AddRow([]byte(`{"theme":"dark","locale":"en"}`)))
+				sqlMock.ExpectExec(updateMetadata).
+					WithArgs(`{"locale":"en"}`, sqlmock.AnyArg(), mockUser.ID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				sqlMock.ExpectCommit()
+			},
+			want: `{"locale":"en"}`,
+		},
+		{
+			name:  "no stored metadata",
+			patch: map[string]json.RawMessage{"locale": json.RawMessage(`"th"`)},
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectQuery(selectMetadata).
+					WithArgs(mockUser.ID, 1).
+					WillReturnRows(sqlmock.NewRows([]string{"metadata"}).AddRow(nil))
+				sqlMock.ExpectExec(updateMetadata).
+					WithArgs(`{"locale":"th"}`, sqlmock.AnyArg(), mockUser.ID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				sqlMock.ExpectCommit()
+			},
+			want: `{"locale":"th"}`,
+		},
+		{
+			name:     "validation failure writes nothing",
+			patch:    map[string]json.RawMessage{"theme": json.RawMessage(`"light"`)},
+			validate: func(map[string]json.RawMessage) error { return errTooLarge },
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectQuery(selectMetadata).
+					WithArgs(mockUser.ID, 1).
+					WillReturnRows(sqlmock.NewRows([]string{"metadata"}).AddRow([]byte(`{}`)))
+				sqlMock.ExpectRollback()
+			},
+			wantErr: errTooLarge,
+		},
+		{
+			name:  "user not found",
+			patch: map[string]json.RawMessage{"theme": json.RawMessage(`"light"`)},
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectQuery(selectMetadata).
+					WithArgs(mockUser.ID, 1).
+					WillReturnRows(sqlmock.NewRows([]string{"metadata"}))
+				sqlMock.ExpectRollback()
+			},
+			wantErr: gorm.ErrRecordNotFound,
+		},
+		{
+			name:  "database error",
+			patch: map[string]json.RawMessage{"theme": json.RawMessage(`"light"`)},
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectQuery(selectMetadata).
+					WithArgs(mockUser.ID, 1).
+					WillReturnError(sql.ErrConnDone)
+				sqlMock.ExpectRollback()
+			},
+			wantErr: sql.ErrConnDone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, _, sqlMock, userRepo := setupTest(t)
+			defer sqlDB.Close()
+			tt.mockFn(sqlMock)
+			validate := tt.validate
+			if validate == nil {
+				validate = acceptMetadata
+			}
+
+			merged, err := userRepo.MergeMetadata(context.Background(), mockUser.ID, tt.patch, validate)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, merged)
+			} else {
+				require.NoError(t, err)
+				assert.JSONEq(t, tt.want, string(merged))
+			}
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUserRepository_FindByIDReadsMetadata(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	sqlDB, _, sqlMock, userRepo := setupTest(t)
+	defer sqlDB.Close()
+
+	rows := sqlmock.NewRows([]string{"id", "email", "full_name", "metadata"}).
+		AddRow(mockUser.ID, mockUser.Email, mockUser.FullName, []byte(`{"theme":"dark","tags":[1,2]}`))
+	sqlMock.ExpectQuery(`SELECT \* FROM "users" WHERE id = \$1`).WillReturnRows(rows)
+
+	user, err := userRepo.FindByID(context.Background(), mockUser.ID.String())
+
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"theme":"dark","tags":[1,2]}`, string(user.Metadata))
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestMergeMetadata(t *testing.T) {
+	tests := []struct {
+		name    string
+		current datatypes.JSON
+		patch   map[string]json.RawMessage
+		want    string
+		wantErr error
+	}{
+		{
+			name:  "empty",
+			patch: map[string]json.RawMessage{"theme": json.RawMessage(`"dark"`)},
+			want:  `{"theme":"dark"}`,
+		},
+		{
+			name:    "json null",
+			current: datatypes.JSON(`null`),
+			patch:   map[string]json.RawMessage{"theme": json.RawMessage(`"dark"`)},
+			want:    `{"theme":"dark"}`,
+		},
+		{
+			name:    "replace and delete",
+			current: datatypes.JSON(`{"theme":"dark","locale":"en","beta":true}`),
+			patch: map[string]json.RawMessage{
+				"theme":  json.RawMessage(`"light"`),
+				"beta":   json.RawMessage(`null`),
+				"absent": json.RawMessage(`null`),
+			},
+			want: `{"theme":"light","locale":"en"}`,
+		},
+		{
+			name:    "arrays are replaced, not appended",
+			current: datatypes.JSON(`{"tags":["a","b"]}`),
+			patch:   map[string]json.RawMessage{"tags": json.RawMessage(`["c"]`)},
+			want:    `{"tags":["c"]}`,
+		},
+		{
+			name:    "stored value is not an object",
+			current: datatypes.JSON(`["a"]`),
+			patch:   map[string]json.RawMessage{"theme": json.RawMessage(`"dark"`)},
+			wantErr: ErrInvalidMetadata,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, err := mergeMetadata(tt.current, tt.patch)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			encoded, err := json.Marshal(merged)
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.want, string(encoded))
+		})
+	}
+}
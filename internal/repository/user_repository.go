@@ -7,36 +7,123 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"time"
 
+	"github.com/PakornBank/learn-go/internal/database"
 	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// UserRepository provides access to user records. Every query it runs is bounded
+// by queryTimeout in addition to any deadline on the caller's context.
 type UserRepository struct {
-	db *gorm.DB
+	db           *gorm.DB
+	resolver     *database.Resolver
+	queryTimeout time.Duration
 }
 
-func NewUserRepository(db *gorm.DB) *UserRepository {
-	return &UserRepository{db: db}
+// UserRepositoryOption configures a UserRepository.
+type UserRepositoryOption func(*UserRepository)
+
+// WithReadResolver makes the read-only lookups, lists and counts of the
+// repository run as prepared statements on the connection resolver picks,
+// which is a read replica if one is configured. Those reads may then lag
+// behind writes, as described on database.Resolver; callers that cannot
+// tolerate that pass a context from database.WithPrimary. Writes, and reads
+// that decide on a write such as FindExistingEmails, keep running on db.
+func WithReadResolver(resolver *database.Resolver) UserRepositoryOption {
+	return func(r *UserRepository) {
+		r.resolver = resolver
+	}
+}
+
+// NewUserRepository creates a UserRepository that bounds each query by queryTimeout.
+func NewUserRepository(db *gorm.DB, queryTimeout time.Duration, opts ...UserRepositoryOption) *UserRepository {
+	r := &UserRepository{db: db, queryTimeout: queryTimeout}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// reader returns the connection the read-only queries of ctx run on: the one
+// picked by the resolver of WithReadResolver, or db without one.
+func (r *UserRepository) reader(ctx context.Context) *gorm.DB {
+	if r.resolver == nil {
+		return r.db.WithContext(ctx)
+	}
+	return r.resolver.Reader(ctx).WithContext(ctx)
 }
 
 // Create inserts a new user record into the database.
 // It takes a context for managing request-scoped values and cancellation,
 // and a pointer to a User model which contains the user data to be inserted.
-// It returns an error if the operation fails.
+// It returns an error if the operation fails, or ErrTimeout if it exceeds the query timeout.
 func (r *UserRepository) Create(ctx context.Context, user *model.User) error {
-	return r.db.WithContext(ctx).Create(user).Error
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	return translateError(ctx, r.db.WithContext(ctx).Create(user).Error)
+}
+
+// CreateWithOutbox inserts a new user record and an outbox event describing it
+// in a single transaction, so the event is stored if and only if the user is.
+// newEvent is called after the user is inserted, with its generated fields set.
+// It returns an error if either insert fails, or ErrTimeout if the transaction
+// exceeds the query timeout.
+func (r *UserRepository) CreateWithOutbox(ctx context.Context, user *model.User, newEvent func(*model.User) (*model.OutboxEvent, error)) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+
+		event, err := newEvent(user)
+		if err != nil {
+			return err
+		}
+
+		return tx.Create(event).Error
+	})
+
+	return translateError(ctx, err)
 }
 
 // FindByEmail retrieves a user from the database by their email address.
 // It takes a context and an email string as parameters and returns a pointer to a User model and an error.
 // If the user is found, it returns the user and a nil error.
 // If the user is not found or any other error occurs, it returns nil and the error.
+// If the query exceeds its timeout, the error is ErrTimeout.
 func (r *UserRepository) FindByEmail(ctx context.Context, email string) (*model.User, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	var user model.User
 
-	if err := r.db.WithContext(ctx).Where("email = ?", email).First(&user).Error; err != nil {
-		return nil, err
+	if err := r.reader(ctx).Where("email = ?", email).First(&user).Error; err != nil {
+		return nil, translateError(ctx, err)
+	}
+
+	return &user, nil
+}
+
+// FindByUsername retrieves a user from the database by their username, which
+// must already be in the lowercase form usernames are stored in.
+// If the user is not found or any other error occurs, it returns nil and the error.
+// If the query exceeds its timeout, the error is ErrTimeout.
+func (r *UserRepository) FindByUsername(ctx context.Context, username string) (*model.User, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var user model.User
+
+	if err := r.reader(ctx).Where("username = ?", username).First(&user).Error; err != nil {
+		return nil, translateError(ctx, err)
 	}
 
 	return &user, nil
@@ -45,13 +132,438 @@ func (r *UserRepository) FindByEmail(ctx context.Context, email string) (*model.
 // FindByID retrieves a user from the database by their ID.
 // It takes a context and a user ID as parameters and returns a pointer to the User model and an error.
 // If the user is found, it returns the user and a nil error.
-// If the user is not found or any other error occurs, it returns nil and the error.
+// If the user is not found, including because id is not a valid UUID, the error is ErrNotFound.
+// If the query exceeds its timeout, the error is ErrTimeout.
 func (r *UserRepository) FindByID(ctx context.Context, id string) (*model.User, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrNotFound
+	}
+
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	var user model.User
 
-	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&user).Error; err != nil {
-		return nil, err
+	if err := r.reader(ctx).Where("id = ?", id).First(&user).Error; err != nil {
+		return nil, translateError(ctx, err)
 	}
 
 	return &user, nil
 }
+
+// FindByIDWithPassword finds the user with the given ID on the primary, for
+// checking their password. Unlike FindByID, it never serves a cached user, as
+// CachedUserRepository leaves password hashes out of its cache.
+func (r *UserRepository) FindByIDWithPassword(ctx context.Context, id string) (*model.User, error) {
+	return r.findByIDOnPrimary(ctx, id)
+}
+
+// findByIDOnPrimary finds the user with the given ID on the primary, even
+// when repo reads from a replica.
+func (r *UserRepository) findByIDOnPrimary(ctx context.Context, id string) (*model.User, error) {
+	return r.FindByID(database.WithPrimary(ctx), id)
+}
+
+// findByIDsChunkSize is the most IDs FindByIDs binds in one query, well
+// under the 65535 parameters PostgreSQL accepts per statement.
+const findByIDsChunkSize = 1000
+
+// FindByIDs retrieves the users with the given IDs, keyed by ID. IDs without
+// a user, including those that are not valid UUIDs, are absent from the map
+// rather than reported as an error. Duplicate IDs are looked up once, and the
+// IDs are queried findByIDsChunkSize at a time, so a large list takes a few
+// queries instead of one per user. No query is run when there is nothing to
+// look up.
+func (r *UserRepository) FindByIDs(ctx context.Context, ids []string) (map[uuid.UUID]*model.User, error) {
+	seen := make(map[uuid.UUID]struct{}, len(ids))
+	parsed := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		uid, err := uuid.Parse(id)
+		if err != nil {
+			continue
+		}
+		if _, ok := seen[uid]; ok {
+			continue
+		}
+		seen[uid] = struct{}{}
+		parsed = append(parsed, uid)
+	}
+
+	users := make(map[uuid.UUID]*model.User, len(parsed))
+	for start := 0; start < len(parsed); start += findByIDsChunkSize {
+		chunk := parsed[start:min(start+findByIDsChunkSize, len(parsed))]
+		if err := r.findByIDs(ctx, chunk, users); err != nil {
+			return nil, err
+		}
+	}
+
+	return users, nil
+}
+
+// findByIDs adds the users with the given IDs to users, in one query.
+func (r *UserRepository) findByIDs(ctx context.Context, ids []uuid.UUID, users map[uuid.UUID]*model.User) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var found []model.User
+	if err := r.reader(ctx).Where("id IN ?", ids).Find(&found).Error; err != nil {
+		return translateError(ctx, err)
+	}
+
+	for i := range found {
+		users[found[i].ID] = &found[i]
+	}
+	return nil
+}
+
+// FindByRole retrieves every user with the given role, oldest first. It suits
+// roles held by a handful of users, such as model.RoleAdmin.
+// It returns an error if the operation fails, or ErrTimeout if it exceeds the query timeout.
+func (r *UserRepository) FindByRole(ctx context.Context, role string) ([]model.User, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var users []model.User
+	if err := r.reader(ctx).Where("role = ?", role).Order("created_at ASC").Find(&users).Error; err != nil {
+		return nil, translateError(ctx, err)
+	}
+
+	return users, nil
+}
+
+// ListAfter retrieves up to limit users ordered by creation time, starting
+// after the position encoded in cursor. An empty cursor starts from the
+// beginning. Pagination uses a keyset on (created_at, id), so rows inserted
+// while a client is paging never cause duplicates or skipped rows.
+//
+// It returns the users along with the cursor for the next page, which is
+// empty when there are no more rows. If the cursor cannot be decoded,
+// ErrInvalidCursor is returned without querying the database.
+func (r *UserRepository) ListAfter(ctx context.Context, cursor string, limit int) ([]model.User, string, error) {
+	return r.listAfter(ctx, time.Time{}, cursor, limit)
+}
+
+// ErrInvalidSort is returned when a list is asked to be sorted by a column it
+// cannot be sorted by.
+var ErrInvalidSort = errors.New("invalid sort column")
+
+// userSortColumns are the columns ListPage can sort users by.
+var userSortColumns = map[string]bool{
+	"created_at":    true,
+	"email":         true,
+	"username":      true,
+	"last_login_at": true,
+}
+
+// ListPage retrieves up to limit users after skipping offset, ordered by the
+// column sort, descending if desc, then by ID, along with the total number of
+// users. A sort column outside the whitelist returns ErrInvalidSort without
+// querying the database, and the column is quoted in the query regardless.
+func (r *UserRepository) ListPage(ctx context.Context, offset, limit int, sort string, desc bool) ([]model.User, int64, error) {
+	if !userSortColumns[sort] {
+		return nil, 0, ErrInvalidSort
+	}
+
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var total int64
+	if err := r.reader(ctx).Model(&model.User{}).Count(&total).Error; err != nil {
+		return nil, 0, translateError(ctx, err)
+	}
+	if int64(offset) >= total {
+		return []model.User{}, total, nil
+	}
+
+	var users []model.User
+	err := r.reader(ctx).
+		Order(clause.OrderByColumn{Column: clause.Column{Name: sort}, Desc: desc}).
+		Order("id ASC").
+		Offset(offset).
+		Limit(limit).
+		Find(&users).Error
+	if err != nil {
+		return nil, 0, translateError(ctx, err)
+	}
+	return users, total, nil
+}
+
+// listAfter is ListAfter restricted to users created after createdAfter,
+// unless it is zero.
+func (r *UserRepository) listAfter(ctx context.Context, createdAfter time.Time, cursor string, limit int) ([]model.User, string, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := r.reader(ctx).Order("created_at ASC, id ASC").Limit(limit + 1)
+	if !createdAfter.IsZero() {
+		query = query.Where("created_at > ?", createdAfter)
+	}
+	if cursor != "" {
+		createdAt, id, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		query = query.Where("(created_at, id) > (?, ?)", createdAt, id)
+	}
+
+	var users []model.User
+	if err := query.Find(&users).Error; err != nil {
+		return nil, "", translateError(ctx, err)
+	}
+
+	if len(users) <= limit {
+		return users, "", nil
+	}
+
+	users = users[:limit]
+	last := users[len(users)-1]
+	return users, encodeCursor(last.CreatedAt, last.ID), nil
+}
+
+// UpdateLastLogin records at as the time of the user's last successful login.
+// Only the last_login_at column is written, so concurrent profile changes are
+// never overwritten. It returns an error if the operation fails, or ErrTimeout
+// if it exceeds the query timeout.
+func (r *UserRepository) UpdateLastLogin(ctx context.Context, id uuid.UUID, at time.Time) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	err := r.db.WithContext(ctx).Model(&model.User{}).
+		Where("id = ?", id).
+		UpdateColumn("last_login_at", at).Error
+
+	return translateError(ctx, err)
+}
+
+// UpdateEmail changes the email address of the user with the given ID.
+// It returns an error if the operation fails, including when another user
+// already has the address, or ErrTimeout if it exceeds the query timeout.
+func (r *UserRepository) UpdateEmail(ctx context.Context, id uuid.UUID, email string) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	err := r.db.WithContext(ctx).Model(&model.User{}).
+		Where("id = ?", id).
+		Update("email", email).Error
+
+	return translateError(ctx, err)
+}
+
+// UpdatePassword replaces the password hash of the user with the given ID,
+// recording changedAt as when the password was changed.
+// It returns an error if the operation fails, or ErrTimeout if it exceeds the query timeout.
+func (r *UserRepository) UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string, changedAt time.Time) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	err := r.db.WithContext(ctx).Model(&model.User{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"password_hash":       passwordHash,
+			"password_changed_at": changedAt,
+		}).Error
+
+	return translateError(ctx, err)
+}
+
+// UpdateAvatarURL sets the avatar URL of the user with the given ID.
+// It returns an error if the operation fails, or ErrTimeout if it exceeds the query timeout.
+func (r *UserRepository) UpdateAvatarURL(ctx context.Context, id uuid.UUID, url string) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	err := r.db.WithContext(ctx).Model(&model.User{}).
+		Where("id = ?", id).
+		Update("avatar_url", url).Error
+
+	return translateError(ctx, err)
+}
+
+// IncrementTokenVersion increments the token version of the user with the
+// given ID, which invalidates every access token issued to them so far.
+// It returns an error if the operation fails, or ErrTimeout if it exceeds the query timeout.
+func (r *UserRepository) IncrementTokenVersion(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	err := r.db.WithContext(ctx).Model(&model.User{}).
+		Where("id = ?", id).
+		Update("token_version", gorm.Expr("token_version + 1")).Error
+
+	return translateError(ctx, err)
+}
+
+// SetUsername sets the username of the user with the given ID if they do not
+// have one yet, and reports whether it was set. The check and the update are a
+// single statement, so concurrent requests cannot both set a username.
+// It returns an error if the operation fails, including when another user
+// already has the username, or ErrTimeout if it exceeds the query timeout.
+func (r *UserRepository) SetUsername(ctx context.Context, id uuid.UUID, username string) (bool, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	result := r.db.WithContext(ctx).Model(&model.User{}).
+		Where("id = ? AND username IS NULL", id).
+		Update("username", username)
+	if result.Error != nil {
+		return false, translateError(ctx, result.Error)
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+// AcceptTOS records that the user with the given ID accepted version of the
+// terms of service at the given time.
+// It returns an error if the operation fails, or ErrTimeout if it exceeds the query timeout.
+func (r *UserRepository) AcceptTOS(ctx context.Context, id uuid.UUID, version int, at time.Time) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	err := r.db.WithContext(ctx).Model(&model.User{}).
+		Where("id = ?", id).
+		UpdateColumns(map[string]interface{}{"tos_accepted_version": version, "tos_accepted_at": at}).Error
+
+	return translateError(ctx, err)
+}
+
+// UpdateNotificationPreferences replaces the notification preferences of the user with the given ID.
+// It returns ErrNotFound if the user does not exist, or ErrTimeout if it exceeds the query timeout.
+func (r *UserRepository) UpdateNotificationPreferences(ctx context.Context, id uuid.UUID, preferences model.NotificationPreferences) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	result := r.db.WithContext(ctx).Model(&model.User{}).
+		Where("id = ?", id).
+		UpdateColumn("notification_preferences", preferences)
+	if result.Error != nil {
+		return translateError(ctx, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// UpdateLocale changes the locale and time zone of the user with the given ID.
+// It returns ErrNotFound if the user does not exist, or ErrTimeout if it exceeds the query timeout.
+func (r *UserRepository) UpdateLocale(ctx context.Context, id uuid.UUID, locale, timezone string) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	result := r.db.WithContext(ctx).Model(&model.User{}).
+		Where("id = ?", id).
+		UpdateColumns(map[string]interface{}{"locale": locale, "timezone": timezone})
+	if result.Error != nil {
+		return translateError(ctx, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// RequestDeletion records at as the time the user asked for their account to be erased.
+// It returns an error if the operation fails, or ErrTimeout if it exceeds the query timeout.
+func (r *UserRepository) RequestDeletion(ctx context.Context, id uuid.UUID, at time.Time) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	err := r.db.WithContext(ctx).Model(&model.User{}).
+		Where("id = ?", id).
+		UpdateColumn("deletion_requested_at", at).Error
+
+	return translateError(ctx, err)
+}
+
+// CancelDeletion clears a pending deletion request of the user, if any.
+// It returns an error if the operation fails, or ErrTimeout if it exceeds the query timeout.
+func (r *UserRepository) CancelDeletion(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	err := r.db.WithContext(ctx).Model(&model.User{}).
+		Where("id = ? AND deletion_requested_at IS NOT NULL", id).
+		UpdateColumn("deletion_requested_at", nil).Error
+
+	return translateError(ctx, err)
+}
+
+// RestoreDeleted clears the deletion request of the user with the given ID, so
+// the account is no longer purged. Unlike CancelDeletion, it returns ErrNotFound
+// if there is no such request to clear, either because the user never asked
+// for deletion or because the account has already been purged.
+// It returns an error if the operation fails, or ErrTimeout if it exceeds the query timeout.
+func (r *UserRepository) RestoreDeleted(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	result := r.db.WithContext(ctx).Model(&model.User{}).
+		Where("id = ? AND deletion_requested_at IS NOT NULL", id).
+		UpdateColumn("deletion_requested_at", nil)
+	if result.Error != nil {
+		return translateError(ctx, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// PurgedUser is a user erased by PurgeDeletionRequestedBefore, with what the
+// caller still has to clean up outside the database.
+type PurgedUser struct {
+	ID uuid.UUID
+	// AvatarURL is the URL of the user's uploaded avatar, or nil if they had none.
+	AvatarURL *string
+}
+
+// PurgeDeletionRequestedBefore erases up to limit users who requested deletion
+// before the given time, together with their login events, refresh tokens,
+// pending email changes, login alerts, recovery requests and linked
+// identities, and returns the erased users. Their avatar files are not
+// stored in the database, so the caller deletes them.
+//
+// Everything runs in one transaction. The selected users are locked with
+// SELECT ... FOR UPDATE, so a concurrent CancelDeletion for one of them waits
+// until the purge commits instead of cancelling a deletion that is already
+// under way, and no related row can be left behind without its user.
+// It returns an error if the operation fails, or ErrTimeout if it exceeds the query timeout.
+func (r *UserRepository) PurgeDeletionRequestedBefore(ctx context.Context, before time.Time, limit int) ([]PurgedUser, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	purged := []PurgedUser{}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&model.User{}).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "avatar_url").
+			Where("deletion_requested_at < ?", before).
+			Order("deletion_requested_at ASC").
+			Limit(limit).
+			Find(&purged).Error
+		if err != nil || len(purged) == 0 {
+			return err
+		}
+
+		ids := make([]uuid.UUID, len(purged))
+		for i, user := range purged {
+			ids[i] = user.ID
+		}
+
+		for _, related := range []interface{}{&model.LoginEvent{}, &model.RefreshToken{}, &model.EmailChangeRequest{}, &model.LoginAlert{}, &model.RecoveryRequest{}, &model.Identity{}, &model.RoleGrant{}} {
+			if err := tx.Where("user_id IN ?", ids).Delete(related).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Where("id IN ?", ids).Delete(&model.User{}).Error
+	})
+	if err != nil {
+		return nil, translateError(ctx, err)
+	}
+
+	return purged, nil
+}
@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PakornBank/learn-go/internal/database"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupResolverTest returns a UserRepository whose resolver has a primary
+// and a replica, along with the mock of each.
+func setupResolverTest(t *testing.T) (*UserRepository, sqlmock.Sqlmock, sqlmock.Sqlmock) {
+	primaryDB, primaryGorm, primary := testutil.DbMock(t)
+	replicaDB, replicaGorm, replica := testutil.DbMock(t)
+	t.Cleanup(func() {
+		primaryDB.Close()
+		replicaDB.Close()
+	})
+	repo := NewUserRepository(primaryGorm, testQueryTimeout, WithReadResolver(database.NewResolver(primaryGorm, replicaGorm)))
+	return repo, primary, replica
+}
+
+func TestUserRepository_ReadRouting(t *testing.T) {
+	id := uuid.New()
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		queries int
+		columns []string
+		call    func(context.Context, *UserRepository)
+	}{
+		{name: "FindByEmail", queries: 1, call: func(ctx context.Context, r *UserRepository) { r.FindByEmail(ctx, "test@example.com") }},
+		{name: "FindByUsername", queries: 1, call: func(ctx context.Context, r *UserRepository) { r.FindByUsername(ctx, "test") }},
+		{name: "FindByID", queries: 1, call: func(ctx context.Context, r *UserRepository) { r.FindByID(ctx, id.String()) }},
+		{name: "FindByIDs", queries: 1, call: func(ctx context.Context, r *UserRepository) { r.FindByIDs(ctx, []string{id.String()}) }},
+		{name: "FindByRole", queries: 1, call: func(ctx context.Context, r *UserRepository) { r.FindByRole(ctx, "admin") }},
+		{name: "ListAfter", queries: 1, call: func(ctx context.Context, r *UserRepository) { r.ListAfter(ctx, "", 10) }},
+		{name: "ListPage", queries: 1, call: func(ctx context.Context, r *UserRepository) { r.ListPage(ctx, 0, 10, "created_at", true) }},
+		{name: "Count", queries: 1, call: func(ctx context.Context, r *UserRepository) { r.Count(ctx) }},
+		{name: "CountCreatedBetween", queries: 1, call: func(ctx context.Context, r *UserRepository) { r.CountCreatedBetween(ctx, now, now) }},
+		{name: "CountCreatedByDay", queries: 1, call: func(ctx context.Context, r *UserRepository) { r.CountCreatedByDay(ctx, now, now) }},
+		{name: "Activity", queries: 2, columns: []string{"session_count", "failed_attempts"}, call: func(ctx context.Context, r *UserRepository) { r.Activity(ctx, id, 5) }},
+	}
+
+	for _, tt := range tests {
+		if tt.columns == nil {
+			tt.columns = []string{"count"}
+		}
+
+		t.Run(tt.name+" reads the replica", func(t *testing.T) {
+			repo, primary, replica := setupResolverTest(t)
+			expectPreparedReads(replica, tt.queries, tt.columns)
+
+			tt.call(context.Background(), repo)
+
+			assert.NoError(t, replica.ExpectationsWereMet())
+			assert.NoError(t, primary.ExpectationsWereMet(), "no query runs on the primary")
+		})
+
+		t.Run(tt.name+" reads the primary when asked", func(t *testing.T) {
+			repo, primary, replica := setupResolverTest(t)
+			expectPreparedReads(primary, tt.queries, tt.columns)
+
+			tt.call(database.WithPrimary(context.Background()), repo)
+
+			assert.NoError(t, primary.ExpectationsWereMet())
+			assert.NoError(t, replica.ExpectationsWereMet(), "no query runs on the replica")
+		})
+	}
+}
+
+// expectPreparedReads expects n queries prepared and run on sqlMock, each
+// returning a row of zeros in columns. A count of 0 ends ListPage before it
+// lists anyone.
+func expectPreparedReads(sqlMock sqlmock.Sqlmock, n int, columns []string) {
+	values := make([]driver.Value, len(columns))
+	for i := range values {
+		values[i] = 0
+	}
+	for i := 0; i < n; i++ {
+		sqlMock.ExpectPrepare(`SELECT`).
+			ExpectQuery().
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(values...))
+	}
+}
+
+func TestUserRepository_WriteRouting(t *testing.T) {
+	id := uuid.New()
+
+	tests := []struct {
+		name string
+		call func(context.Context, *UserRepository)
+		sql  string
+	}{
+		{name: "UpdateEmail", call: func(ctx context.Context, r *UserRepository) { r.UpdateEmail(ctx, id, "new@example.com") }, sql: `UPDATE "users"`},
+		{name: "FindExistingEmails", call: func(ctx context.Context, r *UserRepository) { r.FindExistingEmails(ctx, []string{"test@example.com"}) }, sql: `SELECT`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, primary, replica := setupResolverTest(t)
+			if tt.sql == `SELECT` {
+				primary.ExpectQuery(tt.sql).WillReturnRows(sqlmock.NewRows([]string{"email"}))
+			} else {
+				primary.ExpectBegin()
+				primary.ExpectExec(tt.sql).WillReturnResult(sqlmock.NewResult(0, 1))
+				primary.ExpectCommit()
+			}
+
+			tt.call(context.Background(), repo)
+
+			assert.NoError(t, primary.ExpectationsWereMet(), "runs on the primary without preparing")
+			assert.NoError(t, replica.ExpectationsWereMet(), "no query runs on the replica")
+		})
+	}
+}
+
+func TestCachedUserRepository_LoadsFromPrimary(t *testing.T) {
+	repo, primary, replica := setupResolverTest(t)
+	cached := NewCachedUserRepository(repo, NewLRUUserCache(10, time.Minute))
+	expectPreparedReads(primary, 1, []string{"id"})
+
+	cached.FindByID(context.Background(), uuid.NewString())
+
+	assert.NoError(t, primary.ExpectationsWereMet())
+	assert.NoError(t, replica.ExpectationsWereMet(), "users are never cached from the replica")
+}
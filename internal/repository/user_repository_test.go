@@ -3,7 +3,10 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/PakornBank/learn-go/internal/model"
@@ -13,16 +16,19 @@ import (
 	"gorm.io/gorm"
 )
 
+const testQueryTimeout = 50 * time.Millisecond
+
 func setupTest(t *testing.T) (*sql.DB, *gorm.DB, sqlmock.Sqlmock, *UserRepository) {
 	sqlDB, gormDB, sqlMock := testutil.DbMock(t)
-	userRepo := NewUserRepository(gormDB)
+	userRepo := NewUserRepository(gormDB, testQueryTimeout)
 	return sqlDB, gormDB, sqlMock, userRepo
 }
 
 func TestNewUserRepository(t *testing.T) {
 	_, gormDB, _, _ := setupTest(t)
-	userRepo := NewUserRepository(gormDB)
+	userRepo := NewUserRepository(gormDB, testQueryTimeout)
 	assert.Equal(t, gormDB, userRepo.db)
+	assert.Equal(t, testQueryTimeout, userRepo.queryTimeout)
 }
 
 func TestUserRepository_Create(t *testing.T) {
@@ -47,7 +53,7 @@ func TestUserRepository_Create(t *testing.T) {
 				rows := sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).
 					AddRow(mockUser.ID, mockUser.CreatedAt, mockUser.UpdatedAt)
 				sqlMock.ExpectQuery(`INSERT INTO "users"`).
-					WithArgs(mockUser.Email, mockUser.PasswordHash, mockUser.FullName).
+					WithArgs(mockUser.Email, mockUser.PasswordHash, mockUser.FullName, model.RoleUser, nil, nil, nil, nil, 0, 0, nil, nil, "en", "UTC").
 					WillReturnRows(rows)
 				sqlMock.ExpectCommit()
 			},
@@ -63,12 +69,12 @@ func TestUserRepository_Create(t *testing.T) {
 			mockFn: func(sqlMock sqlmock.Sqlmock) {
 				sqlMock.ExpectBegin()
 				sqlMock.ExpectQuery(`INSERT INTO "users"`).
-					WithArgs(mockUser.Email, mockUser.PasswordHash, mockUser.FullName).
+					WithArgs(mockUser.Email, mockUser.PasswordHash, mockUser.FullName, model.RoleUser, nil, nil, nil, nil, 0, 0, nil, nil, "en", "UTC").
 					WillReturnError(sql.ErrConnDone)
 				sqlMock.ExpectRollback()
 			},
 			wantErr: true,
-			errType: sql.ErrConnDone,
+			errType: ErrConn,
 		},
 	}
 
@@ -80,8 +86,7 @@ func TestUserRepository_Create(t *testing.T) {
 			err := userRepo.Create(context.Background(), tt.user)
 
 			if tt.wantErr {
-				assert.Error(t, err)
-				assert.Equal(t, tt.errType, err)
+				assert.ErrorIs(t, err, tt.errType)
 			} else {
 				assert.NoError(t, err)
 				assert.NotEmpty(t, tt.user.ID)
@@ -107,8 +112,8 @@ func TestUserRepository_FindByEmail(t *testing.T) {
 		{
 			name: "user found",
 			mockFn: func(sqlMock sqlmock.Sqlmock) {
-				rows := sqlmock.NewRows([]string{"id", "email", "password_hash", "full_name", "created_at", "updated_at"}).
-					AddRow(mockUser.ID, mockUser.Email, mockUser.PasswordHash, mockUser.FullName, mockUser.CreatedAt, mockUser.UpdatedAt)
+				rows := sqlmock.NewRows([]string{"id", "email", "password_hash", "full_name", "role", "created_at", "updated_at"}).
+					AddRow(mockUser.ID, mockUser.Email, mockUser.PasswordHash, mockUser.FullName, mockUser.Role, mockUser.CreatedAt, mockUser.UpdatedAt)
 				sqlMock.ExpectQuery(`SELECT .* FROM "users" WHERE email = \$1 (.+) LIMIT \$2`).
 					WithArgs(mockUser.Email, 1).
 					WillReturnRows(rows)
@@ -119,14 +124,14 @@ func TestUserRepository_FindByEmail(t *testing.T) {
 		{
 			name: "user not found",
 			mockFn: func(sqlMock sqlmock.Sqlmock) {
-				rows := sqlmock.NewRows([]string{"id", "email", "password_hash", "full_name", "created_at", "updated_at"})
+				rows := sqlmock.NewRows([]string{"id", "email", "password_hash", "full_name", "role", "created_at", "updated_at"})
 				sqlMock.ExpectQuery(`SELECT .* FROM "users" WHERE email = \$1 (.+) LIMIT \$2`).
 					WithArgs(mockUser.Email, 1).
 					WillReturnRows(rows)
 			},
 			wantUser: nil,
 			wantErr:  true,
-			errType:  gorm.ErrRecordNotFound,
+			errType:  ErrNotFound,
 		},
 	}
 
@@ -138,8 +143,7 @@ func TestUserRepository_FindByEmail(t *testing.T) {
 			got, err := userRepo.FindByEmail(context.Background(), mockUser.Email)
 
 			if tt.wantErr {
-				assert.Error(t, err)
-				assert.Equal(t, tt.errType, err)
+				assert.ErrorIs(t, err, tt.errType)
 				assert.Nil(t, got)
 			} else {
 				assert.NoError(t, err)
@@ -151,6 +155,58 @@ func TestUserRepository_FindByEmail(t *testing.T) {
 	}
 }
 
+func TestUserRepository_FindByUsername(t *testing.T) {
+	const username = "tester"
+	mockUser := testutil.NewMockUser(testutil.WithUsername(username))
+
+	tests := []struct {
+		name     string
+		mockFn   func(sqlmock.Sqlmock)
+		wantUser *model.User
+		wantErr  error
+	}{
+		{
+			name: "user found",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"id", "email", "password_hash", "full_name", "role", "username", "created_at", "updated_at"}).
+					AddRow(mockUser.ID, mockUser.Email, mockUser.PasswordHash, mockUser.FullName, mockUser.Role, username, mockUser.CreatedAt, mockUser.UpdatedAt)
+				sqlMock.ExpectQuery(`SELECT .* FROM "users" WHERE username = \$1 (.+) LIMIT \$2`).
+					WithArgs(username, 1).
+					WillReturnRows(rows)
+			},
+			wantUser: &mockUser,
+		},
+		{
+			name: "user not found",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectQuery(`SELECT .* FROM "users" WHERE username = \$1 (.+) LIMIT \$2`).
+					WithArgs(username, 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			},
+			wantErr: gorm.ErrRecordNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, _, sqlMock, userRepo := setupTest(t)
+			defer sqlDB.Close()
+			tt.mockFn(sqlMock)
+
+			got, err := userRepo.FindByUsername(context.Background(), username)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, got)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantUser, got)
+			}
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
+
 func TestUserRepository_FindByID(t *testing.T) {
 	mockUser := testutil.NewMockUser()
 
@@ -166,8 +222,8 @@ func TestUserRepository_FindByID(t *testing.T) {
 			name: "user found",
 			id:   mockUser.ID,
 			mockFn: func(sqlMock sqlmock.Sqlmock) {
-				rows := sqlmock.NewRows([]string{"id", "email", "password_hash", "full_name", "created_at", "updated_at"}).
-					AddRow(mockUser.ID, mockUser.Email, mockUser.PasswordHash, mockUser.FullName, mockUser.CreatedAt, mockUser.UpdatedAt)
+				rows := sqlmock.NewRows([]string{"id", "email", "password_hash", "full_name", "role", "created_at", "updated_at"}).
+					AddRow(mockUser.ID, mockUser.Email, mockUser.PasswordHash, mockUser.FullName, mockUser.Role, mockUser.CreatedAt, mockUser.UpdatedAt)
 				sqlMock.ExpectQuery(`SELECT .* FROM "users" WHERE id = \$1 (.+) LIMIT \$2`).
 					WithArgs(mockUser.ID, 1).
 					WillReturnRows(rows)
@@ -179,14 +235,14 @@ func TestUserRepository_FindByID(t *testing.T) {
 			name: "user not found",
 			id:   mockUser.ID,
 			mockFn: func(sqlMock sqlmock.Sqlmock) {
-				rows := sqlmock.NewRows([]string{"id", "email", "password_hash", "full_name", "created_at", "updated_at"})
+				rows := sqlmock.NewRows([]string{"id", "email", "password_hash", "full_name", "role", "created_at", "updated_at"})
 				sqlMock.ExpectQuery(`SELECT .* FROM "users" WHERE id = \$1 (.+) LIMIT \$2`).
 					WithArgs(mockUser.ID, 1).
 					WillReturnRows(rows)
 			},
 			wantUser: nil,
 			wantErr:  true,
-			errType:  gorm.ErrRecordNotFound,
+			errType:  ErrNotFound,
 		},
 	}
 
@@ -198,8 +254,7 @@ func TestUserRepository_FindByID(t *testing.T) {
 			got, err := userRepo.FindByID(context.Background(), tt.id.String())
 
 			if tt.wantErr {
-				assert.Error(t, err)
-				assert.Equal(t, tt.errType, err)
+				assert.ErrorIs(t, err, tt.errType)
 				assert.Nil(t, got)
 			} else {
 				assert.NoError(t, err)
@@ -210,3 +265,1099 @@ func TestUserRepository_FindByID(t *testing.T) {
 		})
 	}
 }
+
+func TestUserRepository_FindByID_InvalidID(t *testing.T) {
+	sqlDB, _, sqlMock, userRepo := setupTest(t)
+	defer sqlDB.Close()
+
+	got, err := userRepo.FindByID(context.Background(), "not-a-uuid")
+
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Nil(t, got)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestUserRepository_FindByIDs(t *testing.T) {
+	first := testutil.NewMockUser()
+	second := testutil.NewMockUser(testutil.WithID(uuid.New()), testutil.WithEmail("second@example.com"))
+	missing := uuid.New()
+	columns := []string{"id", "email", "password_hash", "full_name", "role", "created_at", "updated_at"}
+
+	tests := []struct {
+		name    string
+		ids     []string
+		mockFn  func(sqlmock.Sqlmock)
+		want    map[uuid.UUID]*model.User
+		wantErr error
+	}{
+		{
+			name: "users found in one query",
+			ids:  []string{first.ID.String(), second.ID.String(), missing.String()},
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows(columns).
+					AddRow(first.ID, first.Email, first.PasswordHash, first.FullName, first.Role, first.CreatedAt, first.UpdatedAt).
+					AddRow(second.ID, second.Email, second.PasswordHash, second.FullName, second.Role, second.CreatedAt, second.UpdatedAt)
+				sqlMock.ExpectQuery(`SELECT \* FROM "users" WHERE id IN \(\$1,\$2,\$3\)$`).
+					WithArgs(first.ID, second.ID, missing).
+					WillReturnRows(rows)
+			},
+			want: map[uuid.UUID]*model.User{first.ID: &first, second.ID: &second},
+		},
+		{
+			name: "duplicate and invalid IDs are dropped",
+			ids:  []string{first.ID.String(), "not-a-uuid", first.ID.String()},
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows(columns).
+					AddRow(first.ID, first.Email, first.PasswordHash, first.FullName, first.Role, first.CreatedAt, first.UpdatedAt)
+				sqlMock.ExpectQuery(`SELECT \* FROM "users" WHERE id IN \(\$1\)$`).
+					WithArgs(first.ID).
+					WillReturnRows(rows)
+			},
+			want: map[uuid.UUID]*model.User{first.ID: &first},
+		},
+		{
+			name:   "no IDs",
+			ids:    nil,
+			mockFn: func(sqlmock.Sqlmock) {},
+			want:   map[uuid.UUID]*model.User{},
+		},
+		{
+			name:   "only invalid IDs",
+			ids:    []string{"not-a-uuid"},
+			mockFn: func(sqlmock.Sqlmock) {},
+			want:   map[uuid.UUID]*model.User{},
+		},
+		{
+			name: "database error",
+			ids:  []string{first.ID.String()},
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectQuery(`SELECT \* FROM "users" WHERE id IN`).WillReturnError(sql.ErrConnDone)
+			},
+			wantErr: sql.ErrConnDone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, _, sqlMock, userRepo := setupTest(t)
+			defer sqlDB.Close()
+			tt.mockFn(sqlMock)
+
+			got, err := userRepo.FindByIDs(context.Background(), tt.ids)
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUserRepository_FindByRole(t *testing.T) {
+	admin := testutil.NewMockUser(testutil.WithRole(model.RoleAdmin))
+
+	t.Run("admins found", func(t *testing.T) {
+		sqlDB, _, sqlMock, userRepo := setupTest(t)
+		defer sqlDB.Close()
+		sqlMock.ExpectQuery(`SELECT \* FROM "users" WHERE role = \$1 ORDER BY created_at ASC`).
+			WithArgs(model.RoleAdmin).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "email", "role"}).AddRow(admin.ID, admin.Email, admin.Role))
+
+		users, err := userRepo.FindByRole(context.Background(), model.RoleAdmin)
+
+		assert.NoError(t, err)
+		if assert.Len(t, users, 1) {
+			assert.Equal(t, admin.ID, users[0].ID)
+		}
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("database error", func(t *testing.T) {
+		sqlDB, _, sqlMock, userRepo := setupTest(t)
+		defer sqlDB.Close()
+		sqlMock.ExpectQuery(`SELECT \* FROM "users" WHERE role`).WillReturnError(sql.ErrConnDone)
+
+		users, err := userRepo.FindByRole(context.Background(), model.RoleAdmin)
+
+		assert.ErrorIs(t, err, sql.ErrConnDone)
+		assert.Nil(t, users)
+	})
+}
+
+func TestUserRepository_FindByIDs_Chunks(t *testing.T) {
+	ids := make([]string, 2*findByIDsChunkSize+1)
+	for i := range ids {
+		ids[i] = uuid.NewString()
+	}
+	last := uuid.MustParse(ids[len(ids)-1])
+	full := fmt.Sprintf(`SELECT \* FROM "users" WHERE id IN \(\$1,(\$\d+,)*\$%d\)$`, findByIDsChunkSize)
+
+	t.Run("queries the IDs a chunk at a time", func(t *testing.T) {
+		sqlDB, _, sqlMock, userRepo := setupTest(t)
+		defer sqlDB.Close()
+		columns := []string{"id", "email"}
+		sqlMock.ExpectQuery(full).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(ids[0], "first@example.com"))
+		sqlMock.ExpectQuery(full).
+			WillReturnRows(sqlmock.NewRows(columns))
+		sqlMock.ExpectQuery(`SELECT \* FROM "users" WHERE id IN \(\$1\)$`).
+			WithArgs(last).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(last, "last@example.com"))
+
+		got, err := userRepo.FindByIDs(context.Background(), ids)
+
+		assert.NoError(t, err)
+		assert.Len(t, got, 2)
+		assert.Equal(t, "first@example.com", got[uuid.MustParse(ids[0])].Email)
+		assert.Equal(t, "last@example.com", got[last].Email)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("stops at the first failed chunk", func(t *testing.T) {
+		sqlDB, _, sqlMock, userRepo := setupTest(t)
+		defer sqlDB.Close()
+		sqlMock.ExpectQuery(full).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		sqlMock.ExpectQuery(full).WillReturnError(sql.ErrConnDone)
+
+		got, err := userRepo.FindByIDs(context.Background(), ids)
+
+		assert.ErrorIs(t, err, sql.ErrConnDone)
+		assert.Nil(t, got)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+}
+
+func TestUserRepository_ListPage(t *testing.T) {
+	user := testutil.NewMockUser()
+	columns := []string{"id", "email", "password_hash", "full_name", "role", "created_at", "updated_at"}
+
+	tests := []struct {
+		name      string
+		offset    int
+		sort      string
+		desc      bool
+		mockFn    func(sqlmock.Sqlmock)
+		wantLen   int
+		wantTotal int64
+		wantErr   error
+	}{
+		{
+			name:   "sorted descending",
+			offset: 10,
+			sort:   "email",
+			desc:   true,
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectQuery(`SELECT count\(\*\) FROM "users"`).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(11))
+				sqlMock.ExpectQuery(`SELECT \* FROM "users" ORDER BY "email" DESC,id ASC LIMIT \$1 OFFSET \$2`).
+					WithArgs(10, 10).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(user.ID, user.Email, user.PasswordHash, user.FullName, user.Role, user.CreatedAt, user.UpdatedAt))
+			},
+			wantLen:   1,
+			wantTotal: 11,
+		},
+		{
+			name:   "past the last page",
+			offset: 20,
+			sort:   "created_at",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectQuery(`SELECT count\(\*\) FROM "users"`).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(11))
+			},
+			wantTotal: 11,
+		},
+		{
+			name:    "column outside the whitelist",
+			sort:    "password_hash",
+			mockFn:  func(sqlmock.Sqlmock) {},
+			wantErr: ErrInvalidSort,
+		},
+		{
+			name:    "injection attempt",
+			sort:    "created_at; DROP TABLE users",
+			mockFn:  func(sqlmock.Sqlmock) {},
+			wantErr: ErrInvalidSort,
+		},
+		{
+			name: "database error",
+			sort: "created_at",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectQuery(`SELECT count\(\*\) FROM "users"`).WillReturnError(sql.ErrConnDone)
+			},
+			wantErr: sql.ErrConnDone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, _, sqlMock, userRepo := setupTest(t)
+			defer sqlDB.Close()
+			tt.mockFn(sqlMock)
+
+			got, total, err := userRepo.ListPage(context.Background(), tt.offset, 10, tt.sort, tt.desc)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, got)
+			} else {
+				assert.NoError(t, err)
+				assert.Len(t, got, tt.wantLen)
+				assert.NotNil(t, got)
+				assert.Equal(t, tt.wantTotal, total)
+			}
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUserRepository_ListAfter(t *testing.T) {
+	first := testutil.NewMockUser()
+	second := testutil.NewMockUser(testutil.WithEmail("second@example.com"), testutil.WithCreatedAt(first.CreatedAt.Add(time.Second)))
+	columns := []string{"id", "email", "password_hash", "full_name", "role", "created_at", "updated_at"}
+
+	tests := []struct {
+		name           string
+		cursor         string
+		limit          int
+		mockFn         func(sqlmock.Sqlmock)
+		wantLen        int
+		wantNextCursor string
+		wantErr        bool
+		errType        error
+	}{
+		{
+			name:  "first page with more results",
+			limit: 1,
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows(columns).
+					AddRow(first.ID, first.Email, first.PasswordHash, first.FullName, first.Role, first.CreatedAt, first.UpdatedAt).
+					AddRow(second.ID, second.Email, second.PasswordHash, second.FullName, second.Role, second.CreatedAt, second.UpdatedAt)
+				sqlMock.ExpectQuery(`SELECT \* FROM "users" ORDER BY created_at ASC, id ASC LIMIT \$1`).
+					WithArgs(2).
+					WillReturnRows(rows)
+			},
+			wantLen:        1,
+			wantNextCursor: encodeCursor(first.CreatedAt, first.ID),
+		},
+		{
+			name:   "last page after cursor",
+			cursor: encodeCursor(first.CreatedAt, first.ID),
+			limit:  1,
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows(columns).
+					AddRow(second.ID, second.Email, second.PasswordHash, second.FullName, second.Role, second.CreatedAt, second.UpdatedAt)
+				sqlMock.ExpectQuery(`SELECT \* FROM "users" WHERE \(created_at, id\) > \(\$1, \$2\) ORDER BY created_at ASC, id ASC LIMIT \$3`).
+					WithArgs(sqlmock.AnyArg(), first.ID, 2).
+					WillReturnRows(rows)
+			},
+			wantLen: 1,
+		},
+		{
+			name:    "invalid cursor",
+			cursor:  "not-a-cursor",
+			limit:   1,
+			mockFn:  func(sqlMock sqlmock.Sqlmock) {},
+			wantErr: true,
+			errType: ErrInvalidCursor,
+		},
+		{
+			name:  "database error",
+			limit: 1,
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectQuery(`SELECT \* FROM "users"`).WillReturnError(sql.ErrConnDone)
+			},
+			wantErr: true,
+			errType: sql.ErrConnDone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, _, sqlMock, userRepo := setupTest(t)
+			defer sqlDB.Close()
+			tt.mockFn(sqlMock)
+			got, nextCursor, err := userRepo.ListAfter(context.Background(), tt.cursor, tt.limit)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.ErrorIs(t, err, tt.errType)
+				assert.Nil(t, got)
+			} else {
+				assert.NoError(t, err)
+				assert.Len(t, got, tt.wantLen)
+				assert.Equal(t, tt.wantNextCursor, nextCursor)
+			}
+
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUserRepository_QueryTimeout(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+
+	tests := []struct {
+		name           string
+		queryTimeout   time.Duration
+		callerDeadline time.Duration
+		delay          time.Duration
+		wantErr        error
+	}{
+		{
+			name:         "query exceeds repository timeout",
+			queryTimeout: 10 * time.Millisecond,
+			delay:        100 * time.Millisecond,
+			wantErr:      ErrTimeout,
+		},
+		{
+			name:           "caller deadline shorter than repository timeout",
+			queryTimeout:   time.Hour,
+			callerDeadline: 10 * time.Millisecond,
+			delay:          100 * time.Millisecond,
+			wantErr:        ErrTimeout,
+		},
+		{
+			name:           "repository timeout shorter than caller deadline",
+			queryTimeout:   10 * time.Millisecond,
+			callerDeadline: time.Hour,
+			delay:          100 * time.Millisecond,
+			wantErr:        ErrTimeout,
+		},
+		{
+			name:         "query within timeout",
+			queryTimeout: time.Hour,
+			delay:        time.Millisecond,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+			defer sqlDB.Close()
+			userRepo := NewUserRepository(gormDB, tt.queryTimeout)
+
+			rows := sqlmock.NewRows([]string{"id", "email", "password_hash", "full_name", "role", "created_at", "updated_at"}).
+				AddRow(mockUser.ID, mockUser.Email, mockUser.PasswordHash, mockUser.FullName, mockUser.Role, mockUser.CreatedAt, mockUser.UpdatedAt)
+			sqlMock.ExpectQuery(`SELECT .* FROM "users" WHERE id = \$1 (.+) LIMIT \$2`).
+				WithArgs(mockUser.ID, 1).
+				WillDelayFor(tt.delay).
+				WillReturnRows(rows)
+
+			ctx := context.Background()
+			if tt.callerDeadline > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.callerDeadline)
+				defer cancel()
+			}
+
+			got, err := userRepo.FindByID(ctx, mockUser.ID.String())
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, got)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, &mockUser, got)
+			}
+		})
+	}
+}
+
+func TestUserRepository_UpdateLastLogin(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	loginAt := time.Now()
+
+	tests := []struct {
+		name    string
+		mockFn  func(sqlmock.Sqlmock)
+		wantErr error
+	}{
+		{
+			name: "successful update",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectExec(`UPDATE "users" SET "last_login_at"=\$1 WHERE id = \$2`).
+					WithArgs(loginAt, mockUser.ID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				sqlMock.ExpectCommit()
+			},
+		},
+		{
+			name: "database error",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectExec(`UPDATE "users" SET "last_login_at"=\$1 WHERE id = \$2`).
+					WithArgs(loginAt, mockUser.ID).
+					WillReturnError(sql.ErrConnDone)
+				sqlMock.ExpectRollback()
+			},
+			wantErr: sql.ErrConnDone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, _, sqlMock, userRepo := setupTest(t)
+			defer sqlDB.Close()
+			tt.mockFn(sqlMock)
+
+			err := userRepo.UpdateLastLogin(context.Background(), mockUser.ID, loginAt)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUserRepository_UpdateEmail(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+
+	tests := []struct {
+		name    string
+		mockFn  func(sqlmock.Sqlmock)
+		wantErr error
+	}{
+		{
+			name: "successful update",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectExec(`UPDATE "users" SET "email"=\$1,"updated_at"=\$2 WHERE id = \$3`).
+					WithArgs("new@example.com", sqlmock.AnyArg(), mockUser.ID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				sqlMock.ExpectCommit()
+			},
+		},
+		{
+			name: "database error",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectExec(`UPDATE "users" SET "email"=\$1,"updated_at"=\$2 WHERE id = \$3`).
+					WithArgs("new@example.com", sqlmock.AnyArg(), mockUser.ID).
+					WillReturnError(sql.ErrConnDone)
+				sqlMock.ExpectRollback()
+			},
+			wantErr: sql.ErrConnDone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, _, sqlMock, userRepo := setupTest(t)
+			defer sqlDB.Close()
+			tt.mockFn(sqlMock)
+
+			err := userRepo.UpdateEmail(context.Background(), mockUser.ID, "new@example.com")
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUserRepository_UpdatePassword(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	changedAt := time.Now()
+
+	tests := []struct {
+		name    string
+		mockFn  func(sqlmock.Sqlmock)
+		wantErr error
+	}{
+		{
+			name: "successful update",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectExec(`UPDATE "users" SET "password_changed_at"=\$1,"password_hash"=\$2,"updated_at"=\$3 WHERE id = \$4`).
+					WithArgs(changedAt, "new-hash", sqlmock.AnyArg(), mockUser.ID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				sqlMock.ExpectCommit()
+			},
+		},
+		{
+			name: "database error",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectExec(`UPDATE "users" SET "password_changed_at"=\$1,"password_hash"=\$2,"updated_at"=\$3 WHERE id = \$4`).
+					WithArgs(changedAt, "new-hash", sqlmock.AnyArg(), mockUser.ID).
+					WillReturnError(sql.ErrConnDone)
+				sqlMock.ExpectRollback()
+			},
+			wantErr: sql.ErrConnDone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, _, sqlMock, userRepo := setupTest(t)
+			defer sqlDB.Close()
+			tt.mockFn(sqlMock)
+
+			err := userRepo.UpdatePassword(context.Background(), mockUser.ID, "new-hash", changedAt)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUserRepository_UpdateAvatarURL(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+
+	tests := []struct {
+		name    string
+		mockFn  func(sqlmock.Sqlmock)
+		wantErr error
+	}{
+		{
+			name: "successful update",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectExec(`UPDATE "users" SET "avatar_url"=\$1,"updated_at"=\$2 WHERE id = \$3`).
+					WithArgs("/avatars/new.png", sqlmock.AnyArg(), mockUser.ID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				sqlMock.ExpectCommit()
+			},
+		},
+		{
+			name: "database error",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectExec(`UPDATE "users" SET "avatar_url"=\$1,"updated_at"=\$2 WHERE id = \$3`).
+					WithArgs("/avatars/new.png", sqlmock.AnyArg(), mockUser.ID).
+					WillReturnError(sql.ErrConnDone)
+				sqlMock.ExpectRollback()
+			},
+			wantErr: sql.ErrConnDone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, _, sqlMock, userRepo := setupTest(t)
+			defer sqlDB.Close()
+			tt.mockFn(sqlMock)
+
+			err := userRepo.UpdateAvatarURL(context.Background(), mockUser.ID, "/avatars/new.png")
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUserRepository_IncrementTokenVersion(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	const query = `UPDATE "users" SET "token_version"=token_version \+ 1,"updated_at"=\$1 WHERE id = \$2`
+
+	tests := []struct {
+		name    string
+		mockFn  func(sqlmock.Sqlmock)
+		wantErr error
+	}{
+		{
+			name: "successful update",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectExec(query).
+					WithArgs(sqlmock.AnyArg(), mockUser.ID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				sqlMock.ExpectCommit()
+			},
+		},
+		{
+			name: "database error",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectExec(query).
+					WithArgs(sqlmock.AnyArg(), mockUser.ID).
+					WillReturnError(sql.ErrConnDone)
+				sqlMock.ExpectRollback()
+			},
+			wantErr: sql.ErrConnDone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, _, sqlMock, userRepo := setupTest(t)
+			defer sqlDB.Close()
+			tt.mockFn(sqlMock)
+
+			err := userRepo.IncrementTokenVersion(context.Background(), mockUser.ID)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUserRepository_SetUsername(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	const query = `UPDATE "users" SET "username"=\$1,"updated_at"=\$2 WHERE id = \$3 AND username IS NULL`
+
+	tests := []struct {
+		name    string
+		mockFn  func(sqlmock.Sqlmock)
+		wantSet bool
+		wantErr error
+	}{
+		{
+			name: "set",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectExec(query).
+					WithArgs("tester", sqlmock.AnyArg(), mockUser.ID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				sqlMock.ExpectCommit()
+			},
+			wantSet: true,
+		},
+		{
+			name: "already set",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectExec(query).
+					WithArgs("tester", sqlmock.AnyArg(), mockUser.ID).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				sqlMock.ExpectCommit()
+			},
+		},
+		{
+			name: "database error",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectExec(query).
+					WithArgs("tester", sqlmock.AnyArg(), mockUser.ID).
+					WillReturnError(sql.ErrConnDone)
+				sqlMock.ExpectRollback()
+			},
+			wantErr: sql.ErrConnDone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, _, sqlMock, userRepo := setupTest(t)
+			defer sqlDB.Close()
+			tt.mockFn(sqlMock)
+
+			set, err := userRepo.SetUsername(context.Background(), mockUser.ID, "tester")
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantSet, set)
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUserRepository_CreateWithOutbox(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	eventID := uuid.New()
+	newEvent := func(user *model.User) (*model.OutboxEvent, error) {
+		return &model.OutboxEvent{EventType: model.EventUserRegistered, Payload: []byte(`{"user_id":"` + user.ID.String() + `"}`)}, nil
+	}
+
+	tests := []struct {
+		name     string
+		newEvent func(*model.User) (*model.OutboxEvent, error)
+		mockFn   func(sqlmock.Sqlmock)
+		wantErr  error
+	}{
+		{
+			name:     "user and event committed together",
+			newEvent: newEvent,
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectQuery(`INSERT INTO "users"`).
+					WithArgs(mockUser.Email, mockUser.PasswordHash, mockUser.FullName, model.RoleUser, nil, nil, nil, nil, 0, 0, nil, nil, "en", "UTC").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).
+						AddRow(mockUser.ID, mockUser.CreatedAt, mockUser.UpdatedAt))
+				sqlMock.ExpectQuery(`INSERT INTO "outbox_events"`).
+					WithArgs(model.EventUserRegistered, []byte(`{"user_id":"`+mockUser.ID.String()+`"}`), 0, "", nil, nil, sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "next_attempt_at"}).AddRow(eventID, mockUser.CreatedAt))
+				sqlMock.ExpectCommit()
+			},
+		},
+		{
+			name:     "event insert failure rolls back the user",
+			newEvent: newEvent,
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectQuery(`INSERT INTO "users"`).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).
+						AddRow(mockUser.ID, mockUser.CreatedAt, mockUser.UpdatedAt))
+				sqlMock.ExpectQuery(`INSERT INTO "outbox_events"`).
+					WillReturnError(sql.ErrConnDone)
+				sqlMock.ExpectRollback()
+			},
+			wantErr: ErrConn,
+		},
+		{
+			name: "event build failure rolls back the user",
+			newEvent: func(*model.User) (*model.OutboxEvent, error) {
+				return nil, errors.New("build failed")
+			},
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectQuery(`INSERT INTO "users"`).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).
+						AddRow(mockUser.ID, mockUser.CreatedAt, mockUser.UpdatedAt))
+				sqlMock.ExpectRollback()
+			},
+			wantErr: errors.New("build failed"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, _, sqlMock, userRepo := setupTest(t)
+			defer sqlDB.Close()
+			tt.mockFn(sqlMock)
+
+			user := &model.User{
+				Email:        mockUser.Email,
+				PasswordHash: mockUser.PasswordHash,
+				FullName:     mockUser.FullName,
+			}
+			err := userRepo.CreateWithOutbox(context.Background(), user, tt.newEvent)
+
+			if tt.wantErr != nil {
+				assert.EqualError(t, err, tt.wantErr.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, mockUser.ID, user.ID)
+			}
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUserRepository_RequestDeletion(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	requestedAt := time.Now()
+
+	sqlDB, _, sqlMock, userRepo := setupTest(t)
+	defer sqlDB.Close()
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(`UPDATE "users" SET "deletion_requested_at"=\$1 WHERE id = \$2`).
+		WithArgs(requestedAt, mockUser.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+
+	err := userRepo.RequestDeletion(context.Background(), mockUser.ID, requestedAt)
+
+	assert.NoError(t, err)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestUserRepository_CancelDeletion(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+
+	sqlDB, _, sqlMock, userRepo := setupTest(t)
+	defer sqlDB.Close()
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(`UPDATE "users" SET "deletion_requested_at"=\$1 WHERE id = \$2 AND deletion_requested_at IS NOT NULL`).
+		WithArgs(nil, mockUser.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+
+	err := userRepo.CancelDeletion(context.Background(), mockUser.ID)
+
+	assert.NoError(t, err)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestUserRepository_AcceptTOS(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	acceptedAt := time.Now()
+
+	sqlDB, _, sqlMock, userRepo := setupTest(t)
+	defer sqlDB.Close()
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(`UPDATE "users" SET "tos_accepted_at"=\$1,"tos_accepted_version"=\$2 WHERE id = \$3`).
+		WithArgs(acceptedAt, 2, mockUser.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+
+	err := userRepo.AcceptTOS(context.Background(), mockUser.ID, 2, acceptedAt)
+
+	assert.NoError(t, err)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestUserRepository_UpdateNotificationPreferences(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	preferences := model.NotificationPreferences{SecurityAlerts: true, Newsletter: true}
+	query := `UPDATE "users" SET "notification_preferences"=\$1 WHERE id = \$2`
+	stored := `{"security_alerts":true,"product_updates":false,"newsletter":true}`
+
+	tests := []struct {
+		name    string
+		mockFn  func(sqlmock.Sqlmock)
+		wantErr error
+	}{
+		{
+			name: "preferences replaced",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectExec(query).
+					WithArgs(stored, mockUser.ID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				sqlMock.ExpectCommit()
+			},
+		},
+		{
+			name: "user not found",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectExec(query).
+					WithArgs(stored, mockUser.ID).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				sqlMock.ExpectCommit()
+			},
+			wantErr: ErrNotFound,
+		},
+		{
+			name: "database error",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectExec(query).
+					WithArgs(stored, mockUser.ID).
+					WillReturnError(gorm.ErrInvalidDB)
+				sqlMock.ExpectRollback()
+			},
+			wantErr: gorm.ErrInvalidDB,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, _, sqlMock, userRepo := setupTest(t)
+			defer sqlDB.Close()
+			tt.mockFn(sqlMock)
+
+			err := userRepo.UpdateNotificationPreferences(context.Background(), mockUser.ID, preferences)
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUserRepository_UpdateLocale(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	query := `UPDATE "users" SET "locale"=\$1,"timezone"=\$2 WHERE id = \$3`
+
+	tests := []struct {
+		name    string
+		mockFn  func(sqlmock.Sqlmock)
+		wantErr error
+	}{
+		{
+			name: "locale changed",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectExec(query).
+					WithArgs("th-TH", "Asia/Bangkok", mockUser.ID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				sqlMock.ExpectCommit()
+			},
+		},
+		{
+			name: "user not found",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectExec(query).
+					WithArgs("th-TH", "Asia/Bangkok", mockUser.ID).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				sqlMock.ExpectCommit()
+			},
+			wantErr: ErrNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, _, sqlMock, userRepo := setupTest(t)
+			defer sqlDB.Close()
+			tt.mockFn(sqlMock)
+
+			err := userRepo.UpdateLocale(context.Background(), mockUser.ID, "th-TH", "Asia/Bangkok")
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUserRepository_RestoreDeleted(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	query := `UPDATE "users" SET "deletion_requested_at"=\$1 WHERE id = \$2 AND deletion_requested_at IS NOT NULL`
+
+	tests := []struct {
+		name    string
+		mockFn  func(sqlmock.Sqlmock)
+		wantErr error
+	}{
+		{
+			name: "deletion request cleared",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectExec(query).
+					WithArgs(nil, mockUser.ID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				sqlMock.ExpectCommit()
+			},
+		},
+		{
+			name: "no deletion request or user purged",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectExec(query).
+					WithArgs(nil, mockUser.ID).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				sqlMock.ExpectCommit()
+			},
+			wantErr: ErrNotFound,
+		},
+		{
+			name: "database error",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectExec(query).WillReturnError(sql.ErrConnDone)
+				sqlMock.ExpectRollback()
+			},
+			wantErr: sql.ErrConnDone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, _, sqlMock, userRepo := setupTest(t)
+			defer sqlDB.Close()
+			tt.mockFn(sqlMock)
+
+			err := userRepo.RestoreDeleted(context.Background(), mockUser.ID)
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUserRepository_PurgeDeletionRequestedBefore(t *testing.T) {
+	before := time.Now().Add(-14 * 24 * time.Hour)
+	first, second := uuid.New(), uuid.New()
+	avatarURL := "/avatars/" + first.String() + ".png"
+
+	tests := []struct {
+		name       string
+		mockFn     func(sqlmock.Sqlmock)
+		wantPurged []PurgedUser
+		wantErr    error
+	}{
+		{
+			name: "purges users and related rows",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectQuery(`SELECT "id","avatar_url" FROM "users" WHERE deletion_requested_at < \$1 ORDER BY deletion_requested_at ASC LIMIT \$2 FOR UPDATE`).
+					WithArgs(before, 10).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "avatar_url"}).AddRow(first, avatarURL).AddRow(second, nil))
+				sqlMock.ExpectExec(`DELETE FROM "login_events" WHERE user_id IN \(\$1,\$2\)`).
+					WithArgs(first, second).
+					WillReturnResult(sqlmock.NewResult(0, 5))
+				sqlMock.ExpectExec(`DELETE FROM "refresh_tokens" WHERE user_id IN \(\$1,\$2\)`).
+					WithArgs(first, second).
+					WillReturnResult(sqlmock.NewResult(0, 3))
+				sqlMock.ExpectExec(`DELETE FROM "email_change_requests" WHERE user_id IN \(\$1,\$2\)`).
+					WithArgs(first, second).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				sqlMock.ExpectExec(`DELETE FROM "login_alerts" WHERE user_id IN \(\$1,\$2\)`).
+					WithArgs(first, second).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				sqlMock.ExpectExec(`DELETE FROM "recovery_requests" WHERE user_id IN \(\$1,\$2\)`).
+					WithArgs(first, second).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				sqlMock.ExpectExec(`DELETE FROM "identities" WHERE user_id IN \(\$1,\$2\)`).
+					WithArgs(first, second).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				sqlMock.ExpectExec(`DELETE FROM "role_grants" WHERE user_id IN \(\$1,\$2\)`).
+					WithArgs(first, second).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				sqlMock.ExpectExec(`DELETE FROM "users" WHERE id IN \(\$1,\$2\)`).
+					WithArgs(first, second).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+				sqlMock.ExpectCommit()
+			},
+			wantPurged: []PurgedUser{{ID: first, AvatarURL: &avatarURL}, {ID: second}},
+		},
+		{
+			name: "nothing to purge",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectQuery(`SELECT "id","avatar_url" FROM "users"`).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "avatar_url"}))
+				sqlMock.ExpectCommit()
+			},
+			wantPurged: []PurgedUser{},
+		},
+		{
+			name: "delete fails and rolls back",
+			mockFn: func(sqlMock sqlmock.Sqlmock) {
+				sqlMock.ExpectBegin()
+				sqlMock.ExpectQuery(`SELECT "id","avatar_url" FROM "users"`).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(first))
+				sqlMock.ExpectExec(`DELETE FROM "login_events"`).
+					WillReturnError(sql.ErrConnDone)
+				sqlMock.ExpectRollback()
+			},
+			wantErr: sql.ErrConnDone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, _, sqlMock, userRepo := setupTest(t)
+			defer sqlDB.Close()
+			tt.mockFn(sqlMock)
+
+			purged, err := userRepo.PurgeDeletionRequestedBefore(context.Background(), before, 10)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, purged)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantPurged, purged)
+			}
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/database"
+	"github.com/PakornBank/learn-go/internal/model"
+)
+
+// DailyCount is the number of rows created on a day.
+type DailyCount struct {
+	// Day is midnight UTC at the start of the day.
+	Day   time.Time
+	Count int64
+}
+
+// Count returns the number of users.
+// If the query exceeds its timeout, the error is ErrTimeout.
+func (r *UserRepository) Count(ctx context.Context) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var count int64
+	if err := r.reader(ctx).Model(&model.User{}).Count(&count).Error; err != nil {
+		return 0, translateError(ctx, err)
+	}
+	return count, nil
+}
+
+// CountCreatedBetween returns the number of users created at or after from
+// and before to.
+// If the query exceeds its timeout, the error is ErrTimeout.
+func (r *UserRepository) CountCreatedBetween(ctx context.Context, from, to time.Time) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var count int64
+	err := r.reader(ctx).Model(&model.User{}).
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Count(&count).Error
+	if err != nil {
+		return 0, translateError(ctx, err)
+	}
+	return count, nil
+}
+
+// CountCreatedByDay returns the number of users created on each UTC day at or
+// after from and before to, in a single grouped query, ordered by day. Days
+// on which no user was created are left out.
+// If the query exceeds its timeout, the error is ErrTimeout.
+func (r *UserRepository) CountCreatedByDay(ctx context.Context, from, to time.Time) ([]DailyCount, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	if !database.IsPostgres(r.db) {
+		return r.countCreatedByDayPortable(ctx, from, to)
+	}
+
+	var counts []DailyCount
+	err := r.reader(ctx).Model(&model.User{}).
+		Select("date_trunc('day', created_at AT TIME ZONE 'UTC') AS day, count(*) AS count").
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Group("day").
+		Order("day").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, translateError(ctx, err)
+	}
+
+	// The day is a timestamp without time zone, which the driver reads as UTC.
+	for i := range counts {
+		counts[i].Day = counts[i].Day.UTC()
+	}
+	return counts, nil
+}
+
+// countCreatedByDayPortable is CountCreatedByDay for databases without
+// date_trunc, which group by the UTC date as text and parse it here.
+func (r *UserRepository) countCreatedByDayPortable(ctx context.Context, from, to time.Time) ([]DailyCount, error) {
+	var rows []struct {
+		Day   string
+		Count int64
+	}
+	err := r.reader(ctx).Model(&model.User{}).
+		Select("date(created_at) AS day, count(*) AS count").
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Group("day").
+		Order("day").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, translateError(ctx, err)
+	}
+
+	counts := make([]DailyCount, len(rows))
+	for i, row := range rows {
+		day, err := time.Parse(time.DateOnly, row.Day)
+		if err != nil {
+			return nil, err
+		}
+		counts[i] = DailyCount{Day: day, Count: row.Count}
+	}
+	return counts, nil
+}
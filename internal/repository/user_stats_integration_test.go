@@ -0,0 +1,47 @@
+//go:build integration
+
+package repository_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// TestUserRepository_CountCreatedByUTCDay checks that users are counted by the
+// UTC day they were created on, on every one of the testDatabases, see
+// TestUserRepository_Contract.
+func TestUserRepository_CountCreatedByUTCDay(t *testing.T) {
+	models := []any{&model.User{}}
+	forEachDatabase(t, models, func(t *testing.T, _ testDatabase, db *gorm.DB) {
+		repo := repository.NewUserRepository(db, 5*time.Second)
+		day := time.Date(2024, time.March, 10, 0, 0, 0, 0, time.UTC)
+		bangkok := time.FixedZone("ICT", 7*60*60)
+		for i, createdAt := range []time.Time{
+			day.Add(-time.Minute),
+			day.Add(time.Hour),
+			// 2024-03-11 03:00 in Bangkok is still March 10 in UTC.
+			time.Date(2024, time.March, 11, 3, 0, 0, 0, bangkok),
+			day.Add(24 * time.Hour),
+			day.Add(48 * time.Hour),
+		} {
+			testutil.Persist(t, repo, testutil.WithEmail(fmt.Sprintf("user%d@example.com", i)), testutil.WithCreatedAt(createdAt))
+		}
+
+		counts, err := repo.CountCreatedByDay(context.Background(), day, day.Add(48*time.Hour))
+
+		require.NoError(t, err)
+		assert.Equal(t, []repository.DailyCount{
+			{Day: day, Count: 2},
+			{Day: day.Add(24 * time.Hour), Count: 1},
+		}, counts)
+	})
+}
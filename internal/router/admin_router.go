@@ -0,0 +1,86 @@
+package router
+
+import (
+	"github.com/PakornBank/learn-go/internal/handler"
+	"github.com/PakornBank/learn-go/internal/middleware"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/service"
+)
+
+// maintenancePath is the route that toggles maintenance mode, which stays
+// reachable while maintenance is active so it can be turned off again.
+const maintenancePath = "/api/admin/maintenance"
+
+func (r *Router) setupAdminRoutes() {
+	historyHandler := handler.NewLoginHistoryHandler(service.NewLoginHistoryService(
+		r.LoginEvents,
+	))
+	maintenanceHandler := handler.NewMaintenanceHandler(r.Maintenance)
+	blocklistHandler := handler.NewEmailBlocklistHandler(r.Blocklist)
+	logLevelHandler := handler.NewLogLevelHandler(r.Live.LogLevel())
+	impersonationHandler := handler.NewImpersonationHandler(r.AuthService)
+	auditHandler := handler.NewAuditLogHandler(service.NewAuditLogService(
+		r.AuditLog,
+	))
+	jobsHandler := handler.NewJobsHandler(r.Jobs)
+	outboxHandler := handler.NewOutboxHandler(r.Outbox)
+	emailQueueHandler := handler.NewEmailQueueHandler(service.NewDeadLetterService(r.EmailQueue, r.Clock), r.EmailWorker)
+	statsHandler := handler.NewUserStatsHandler(r.UserStats)
+	diagnosticsHandler := handler.NewDiagnosticsHandler(r.Diagnostics)
+	accountHandler := handler.NewAccountHandler(r.Deletion)
+	importHandler := handler.NewUserImportHandler(r.Imports)
+	recoveryHandler := handler.NewRecoveryHandler(r.Recovery, r.Audit)
+	exportHandler := handler.NewUserExportHandler(service.NewUserExportService(r.Users))
+	announcementHandler := handler.NewAnnouncementHandler(service.NewAnnouncementService(
+		r.Announcements,
+		r.Clock,
+	))
+	revocationHandler := handler.NewTokenRevocationHandler(service.NewTokenRevocationService(r.Users, r.Events))
+	grantHandler := handler.NewRoleGrantHandler(r.RoleGrants)
+	handler := handler.NewAdminHandler(service.NewAdminService(r.Users))
+
+	group := r.group.Group("/admin")
+	group.Use(
+		r.requireAuth(),
+		middleware.FeatureFlags(r.Flags),
+		middleware.AuditRequests(r.Audit, r.Redactor),
+		middleware.ForbidImpersonation(),
+		middleware.RequireRoleOrGrant(r.RoleGrants, model.RoleAdmin),
+		middleware.RequireScope(service.ScopeUsersAdmin),
+	)
+	if r.Config.RestrictExpiredPasswords() {
+		group.Use(middleware.RequireUnexpiredPassword(r.AuthService))
+	}
+	{
+		group.GET("/users", handler.ListUsers)
+		group.GET("/users/:id", handler.GetUser)
+		group.POST("/users/import", importHandler.Import)
+		group.GET("/users/export", exportHandler.Export)
+		group.GET("/stats", statsHandler.GetStats)
+		group.GET("/diagnostics", diagnosticsHandler.GetDiagnostics)
+		group.GET("/users/:id/login-history", historyHandler.GetUserHistory)
+		group.POST("/users/:id/restore", accountHandler.RestoreAccount)
+		group.POST("/users/:id/revoke-tokens", revocationHandler.RevokeTokens)
+		group.GET("/grants", grantHandler.ListGrants)
+		group.POST("/grants", grantHandler.CreateGrant)
+		group.DELETE("/grants/:id", grantHandler.RevokeGrant)
+		group.POST("/users/:id/impersonate", middleware.RequireRecentAuth(r.Config.ReauthMaxAge, middleware.WithClock(r.Clock)), impersonationHandler.Impersonate)
+		group.GET("/recovery-requests", recoveryHandler.ListRequests)
+		group.POST("/recovery-requests/:id/approve", middleware.RequireRecentAuth(r.Config.ReauthMaxAge, middleware.WithClock(r.Clock)), recoveryHandler.ApproveRequest)
+		group.POST("/recovery-requests/:id/deny", recoveryHandler.DenyRequest)
+		group.POST("/maintenance", maintenanceHandler.SetMaintenance)
+		group.GET("/announcements", announcementHandler.ListAnnouncements)
+		group.POST("/announcements", announcementHandler.CreateAnnouncement)
+		group.PUT("/announcements/:id", announcementHandler.UpdateAnnouncement)
+		group.DELETE("/announcements/:id", announcementHandler.DeleteAnnouncement)
+		group.POST("/email-blocklist/reload", blocklistHandler.Reload)
+		group.GET("/log-level", logLevelHandler.GetLogLevel)
+		group.PUT("/log-level", logLevelHandler.SetLogLevel)
+		group.GET("/audit", auditHandler.ListAudit)
+		group.GET("/jobs", jobsHandler.ListJobs)
+		group.GET("/emails/dead-letters", emailQueueHandler.ListDeadLetters)
+		group.POST("/emails/dead-letters/:id/retry", emailQueueHandler.RetryDeadLetter)
+		group.GET("/emails/stats", emailQueueHandler.GetStats)
+		group.GET("/outbox/stats", outboxHandler.GetStats)
+	}
+}
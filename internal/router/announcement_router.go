@@ -0,0 +1,19 @@
+package router
+
+import (
+	"github.com/PakornBank/learn-go/internal/handler"
+	"github.com/PakornBank/learn-go/internal/service"
+)
+
+// announcementsPath is the public list of active announcements, which stays
+// reachable during maintenance so the app can tell users about it.
+const announcementsPath = "/api/announcements"
+
+func (r *Router) setupAnnouncementRoutes() {
+	handler := handler.NewAnnouncementHandler(service.NewAnnouncementService(
+		r.Announcements,
+		r.Clock,
+	))
+
+	r.group.GET("/announcements", handler.ListActive)
+}
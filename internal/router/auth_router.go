@@ -3,22 +3,125 @@ package router
 import (
 	"github.com/PakornBank/learn-go/internal/handler"
 	"github.com/PakornBank/learn-go/internal/middleware"
-	"github.com/PakornBank/learn-go/internal/repository"
 	"github.com/PakornBank/learn-go/internal/service"
 )
 
+// tosAcceptPath is the route that accepts the terms of service, which stays
+// reachable for users who must accept a new version before anything else.
+const tosAcceptPath = "/api/auth/tos/accept"
+
+// passwordChangePath is the route that changes the password, which stays
+// reachable for users who must change an expired password before anything else.
+const passwordChangePath = "/api/auth/password"
+
+// Event stream routes, which stay open for as long as the client listens and
+// so are not counted against the concurrency limit: each stream would
+// otherwise hold a slot until it closes, and a few idle listeners could shed
+// every other request.
+const (
+	eventsPath    = "/api/auth/events"
+	eventsSSEPath = "/api/auth/events/sse"
+)
+
 func (r *Router) setupAuthRoutes() {
-	handler := handler.NewAuthHandler(service.NewAuthService(repository.NewUserRepository(r.db), r.config))
+	historyHandler := handler.NewLoginHistoryHandler(service.NewLoginHistoryService(
+		r.LoginEvents,
+	))
+	sessionHandler := handler.NewSessionHandler(service.NewSessionService(
+		r.RefreshTokens,
+	))
+	loginAlertHandler := handler.NewLoginAlertHandler(service.NewLoginAlertService(
+		r.LoginAlerts,
+		r.RefreshTokens,
+		r.AuthService,
+		r.Clock,
+	))
+	emailChangeHandler := handler.NewEmailChangeHandler(service.NewEmailChangeService(
+		r.Users,
+		r.EmailChanges,
+		r.Mailer,
+		r.Emails,
+		r.Events,
+		r.Passwords,
+		r.Clock,
+	), r.Audit)
+	securityEventHandler := handler.NewSecurityEventHandler(service.NewSecurityEventService(r.SecurityLog))
+	identityHandler := handler.NewIdentityHandler(service.NewIdentityService(r.Identities, r.IdentityProviders, r.Config.JWTSecret, r.Clock))
+	accountHandler := handler.NewAccountHandler(r.Deletion)
+	metadataHandler := handler.NewMetadataHandler(service.NewMetadataService(r.Users, r.Events))
+	avatarHandler := handler.NewAvatarHandler(service.NewAvatarService(
+		r.Users,
+		r.Avatars,
+		r.Config.AvatarMaxDimension,
+		r.Events,
+	))
+	usernameHandler := handler.NewUsernameHandler(service.NewUsernameService(r.Users, r.Events))
+	preferencesHandler := handler.NewNotificationPreferencesHandler(service.NewNotificationPreferencesService(r.Users, r.Config.JWTSecret))
+	eventsHandler := handler.NewEventsHandler(r.Events, r.AuthService)
+	importHandler := handler.NewUserImportHandler(r.Imports)
+	recoveryHandler := handler.NewRecoveryHandler(r.Recovery, r.Audit)
+	handler := handler.NewAuthHandler(r.AuthService)
 
 	group := r.group.Group("/auth")
 	{
-		group.POST("/register", handler.Register)
-		group.POST("/login", handler.Login)
+		group.POST("/register", middleware.RateLimit(r.RateLimiter, "register"), handler.Register)
+		group.POST("/login", middleware.RateLimit(r.RateLimiter, "login"), handler.Login)
+		group.POST("/refresh", middleware.RateLimit(r.RateLimiter, "refresh"), handler.Refresh)
+		group.DELETE("/refresh", middleware.RateLimit(r.RateLimiter, "logout"), handler.Logout)
+		group.POST("/email-change/confirm", middleware.RateLimit(r.RateLimiter, "email-change-confirm"), emailChangeHandler.ConfirmChange)
+		group.POST("/login-alerts/report", middleware.RateLimit(r.RateLimiter, "login-alert-report"), loginAlertHandler.ReportLogin)
+		group.POST("/notifications/unsubscribe", middleware.RateLimit(r.RateLimiter, "unsubscribe"), preferencesHandler.Unsubscribe)
+		group.POST("/invites/accept", middleware.RateLimit(r.RateLimiter, "invite-accept"), importHandler.AcceptInvite)
+		group.POST("/recovery/request", middleware.RateLimit(r.RateLimiter, "recovery-request"), recoveryHandler.SubmitRequest)
+		group.POST("/recovery/complete", middleware.RateLimit(r.RateLimiter, "recovery-complete"), recoveryHandler.CompleteRecovery)
+		group.GET("/identities/:provider/callback", middleware.RateLimit(r.RateLimiter, "identity-link-callback"), identityHandler.CompleteLink)
+	}
+
+	if r.Config.IntrospectionSecret != "" {
+		group.POST("/token/introspect", middleware.RequireServiceSecret(r.Config.IntrospectionSecret), handler.Introspect)
+	}
+
+	// Browsers cannot set headers on WebSocket or EventSource requests, so the
+	// events streams also accept the access token in the query.
+	// The streams are gated like the protected routes, so they cannot be used
+	// to keep reading account events while the terms or a password change are
+	// pending.
+	streams := group.Group("/events")
+	r.protect(streams, middleware.WithQueryToken())
+	streams.Use(middleware.RequireScope(service.ScopeProfileRead))
+	{
+		streams.GET("", eventsHandler.Stream)
+		streams.GET("/sse", eventsHandler.StreamSSE)
 	}
 
 	protected := group.Group("")
-	protected.Use(middleware.AuthMiddleware(r.config.JWTSecret))
+	r.protect(protected)
 	{
-		protected.GET("/profile", handler.GetProfile)
+		read := middleware.RequireScope(service.ScopeProfileRead)
+		write := middleware.RequireScope(service.ScopeProfileWrite)
+		recentAuth := middleware.RequireRecentAuth(r.Config.ReauthMaxAge, middleware.WithClock(r.Clock))
+		notImpersonated := middleware.ForbidImpersonation()
+
+		protected.GET("/profile", read, handler.GetProfile)
+		protected.PATCH("/profile", write, handler.UpdateProfile)
+		protected.DELETE("/profile", notImpersonated, write, recentAuth, accountHandler.DeleteProfile)
+		protected.PATCH("/profile/metadata", write, metadataHandler.UpdateMetadata)
+		protected.POST("/profile/avatar", write, avatarHandler.UploadAvatar)
+		protected.PATCH("/profile/username", notImpersonated, write, usernameHandler.SetUsername)
+		protected.PUT("/password", notImpersonated, write, middleware.AuditOwnRequests(r.Audit), handler.ChangePassword)
+		protected.GET("/preferences/notifications", read, preferencesHandler.GetPreferences)
+		protected.PUT("/preferences/notifications", write, preferencesHandler.UpdatePreferences)
+		protected.GET("/login-history", read, historyHandler.GetOwnHistory)
+		protected.GET("/sessions", read, sessionHandler.ListSessions)
+		protected.GET("/security-events", read, securityEventHandler.ListOwnEvents)
+		protected.GET("/identities", read, identityHandler.ListIdentities)
+		protected.POST("/identities/:provider/link", notImpersonated, write, recentAuth, identityHandler.StartLink)
+		protected.DELETE("/identities/:provider", notImpersonated, write, identityHandler.Unlink)
+		protected.PATCH("/sessions/:id", write, sessionHandler.RenameSession)
+		protected.POST("/email-change", notImpersonated, write, recentAuth, emailChangeHandler.RequestChange)
+		protected.POST("/logout-all", notImpersonated, handler.LogoutAll)
+		protected.POST("/tos/accept", notImpersonated, handler.AcceptTOS)
+		protected.POST("/reauth", notImpersonated, middleware.RateLimit(r.RateLimiter, "reauth"), handler.Reauth)
+		protected.POST("/tokens", notImpersonated, recentAuth, handler.IssueToken)
 	}
 }
@@ -0,0 +1,21 @@
+package router
+
+import (
+	"github.com/PakornBank/learn-go/internal/handler"
+	"github.com/gin-gonic/gin"
+)
+
+// setupDevRoutes registers the routes that help while developing, only in the
+// development environment. Being in production or in gin's release mode
+// stops them from being registered even if the environment says otherwise,
+// and the handlers refuse requests in release mode on their own as well.
+func (r *Router) setupDevRoutes() {
+	if !r.Config.IsDevelopment() || r.Config.IsProduction() || gin.Mode() == gin.ReleaseMode {
+		return
+	}
+
+	emails := handler.NewDevEmailHandler(r.Emails, r.Users)
+
+	dev := r.group.Group("/dev")
+	dev.GET("/emails/:template", emails.PreviewEmail)
+}
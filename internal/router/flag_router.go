@@ -0,0 +1,15 @@
+package router
+
+import (
+	"github.com/PakornBank/learn-go/internal/handler"
+	"github.com/PakornBank/learn-go/internal/middleware"
+	"github.com/PakornBank/learn-go/internal/service"
+)
+
+func (r *Router) setupFlagRoutes() {
+	handler := handler.NewFeatureFlagHandler(r.Flags)
+
+	flags := r.group.Group("/flags")
+	r.protect(flags)
+	flags.GET("", middleware.RequireScope(service.ScopeProfileRead), handler.GetFlags)
+}
@@ -0,0 +1,23 @@
+package router
+
+import (
+	"github.com/PakornBank/learn-go/internal/graph"
+	"github.com/PakornBank/learn-go/internal/middleware"
+)
+
+// setupGraphQLRoutes serves the GraphQL API. Register and login need no
+// token, so the gates of the REST profile route other than auditing
+// impersonation are made by the me resolver; see graph.Gates.
+func (r *Router) setupGraphQLRoutes() {
+	gates := graph.Gates{
+		TOS:               r.Config.TOSRequired,
+		UnexpiredPassword: r.Config.RestrictExpiredPasswords(),
+	}
+	r.group.POST("/graphql",
+		middleware.RateLimit(r.RateLimiter, "graphql"),
+		r.optionalAuth(),
+		middleware.FeatureFlags(r.Flags),
+		middleware.AuditImpersonation(r.Audit),
+		graph.NewHandler(r.AuthService, gates, !r.Config.IsProduction()),
+	)
+}
@@ -2,24 +2,200 @@ package router
 
 import (
 	"github.com/PakornBank/learn-go/internal/config"
+	"github.com/PakornBank/learn-go/internal/disposable"
+	"github.com/PakornBank/learn-go/internal/emailqueue"
+	"github.com/PakornBank/learn-go/internal/events"
+	"github.com/PakornBank/learn-go/internal/featureflag"
+	"github.com/PakornBank/learn-go/internal/handler"
+	"github.com/PakornBank/learn-go/internal/jobs"
+	"github.com/PakornBank/learn-go/internal/mailer"
+	"github.com/PakornBank/learn-go/internal/middleware"
+	"github.com/PakornBank/learn-go/internal/outbox"
+	"github.com/PakornBank/learn-go/internal/password"
+	"github.com/PakornBank/learn-go/internal/preflight"
+	"github.com/PakornBank/learn-go/internal/redact"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/service"
+	"github.com/PakornBank/learn-go/internal/storage"
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// Router binds the API handlers to their routes. The repositories and
+// services behind the handlers are built once, by package app, and shared
+// through Dependencies.
 type Router struct {
+	engine *gin.Engine
 	group  *gin.RouterGroup
-	db     *gorm.DB
-	config *config.Config
+	Dependencies
 }
 
-func NewRouter(r *gin.Engine, db *gorm.DB, config *config.Config) *Router {
-	return &Router{
-		group:  r.Group("/api"),
-		db:     db,
-		config: config,
+// Dependencies are the components the routes are served by.
+type Dependencies struct {
+	Config *config.Config
+	// Clock tells the time to the services and the token checks of the
+	// routes alike, so that they agree on it.
+	Clock service.Clock
+	// Live holds the settings that can change at runtime, which the routes
+	// read on every request instead of taking them from Config.
+	Live *config.Live
+
+	Users         UserRepository
+	LoginEvents   *repository.LoginEventRepository
+	RefreshTokens *repository.RefreshTokenRepository
+	LoginAlerts   *repository.LoginAlertRepository
+	EmailChanges  *repository.EmailChangeRepository
+	AuditLog      *repository.AuditRepository
+	EmailQueue    *repository.EmailQueueRepository
+	Recoveries    *repository.RecoveryRepository
+	Announcements *repository.AnnouncementRepository
+	SecurityLog   *repository.SecurityEventRepository
+	Identities    *repository.IdentityRepository
+
+	AuthService *service.AuthService
+	Deletion    *service.AccountDeletionService
+	UserStats   *service.UserStatsService
+	Imports     *service.UserImportService
+	Audit       *service.AuditWriter
+	Mailer      mailer.Mailer
+	Emails      *mailer.Templates
+	EmailWorker *emailqueue.Worker
+	Events      *events.Hub
+	Avatars     storage.BlobStorage
+	Passwords   *password.Pool
+	Jobs        *jobs.Scheduler
+	Outbox      *outbox.Poller
+	Diagnostics *service.DiagnosticsService
+	Recovery    *service.RecoveryService
+	RoleGrants  *service.RoleGrantService
+	// IdentityProviders are the OAuth providers users can link accounts
+	// of, keyed by the name used in the identity routes.
+	IdentityProviders map[string]service.IdentityProvider
+
+	RateLimiter  middleware.RateLimiter
+	Maintenance  *middleware.MaintenanceMode
+	Drain        *middleware.DrainMode
+	Unavailable  *middleware.Unavailable
+	Concurrency  *middleware.ConcurrencyLimiter
+	ClaimsCompat *middleware.ClaimsCompat
+	AlgorithmPin *middleware.AlgorithmPin
+	Blocklist    *disposable.Blocklist
+	Flags        *featureflag.Static
+	Redactor     *redact.Redactor
+	Metrics      *prometheus.Registry
+	// Preflight is what the startup checks of the dependencies found.
+	Preflight preflight.Report
+}
+
+// Routes served outside the API group.
+const (
+	healthPath  = "/healthz"
+	readyPath   = "/readyz"
+	metricsPath = "/metrics"
+)
+
+// UserRepository is the user persistence shared by the auth and admin routes.
+type UserRepository interface {
+	service.Repository
+	service.AdminRepository
+	service.EmailChangeUserRepository
+	service.AccountDeletionRepository
+	service.MetadataRepository
+	service.AvatarRepository
+	service.UsernameRepository
+	service.UserStatsRepository
+	service.NotificationPreferencesRepository
+	service.UserImportRepository
+	service.UserExportRepository
+	service.RecoveryUserRepository
+	service.AdminBootstrapRepository
+	service.TokenRevocationRepository
+}
+
+// NewRouter creates a Router serving the API on r with deps.
+func NewRouter(r *gin.Engine, deps Dependencies) *Router {
+	router := &Router{
+		engine:       r,
+		Dependencies: deps,
 	}
+	// Requests are turned away while draining before they can queue for a slot.
+	r.Use(
+		middleware.Drain(deps.Drain, deps.Unavailable, metricsPath),
+		middleware.ConcurrencyLimit(deps.Concurrency, deps.Unavailable, healthPath, readyPath, metricsPath, eventsPath, eventsSSEPath),
+	)
+	// A group copies the engine's middleware when it is created, so it is
+	// created after them.
+	router.group = r.Group("/api")
+	router.group.Use(
+		middleware.Maintenance(deps.Maintenance, deps.Unavailable, maintenancePath, announcementsPath),
+		middleware.FeatureFlags(deps.Flags),
+	)
+	return router
 }
 
 func (r *Router) SetupRoutes() {
+	r.engine.GET(healthPath, handler.Health(r.degraded))
+	r.engine.GET(readyPath, handler.Ready(r.Preflight.Degraded))
+	r.engine.GET(metricsPath, gin.WrapH(promhttp.HandlerFor(r.Metrics, promhttp.HandlerOpts{})))
+	r.engine.Static(r.Config.AvatarRoute, r.Config.AvatarDir)
 	r.setupAuthRoutes()
+	r.setupAdminRoutes()
+	r.setupFlagRoutes()
+	r.setupAnnouncementRoutes()
+	r.setupGraphQLRoutes()
+	r.setupDevRoutes()
+}
+
+// degraded returns the cause for which API requests are being turned away,
+// or an empty string if they are all served.
+func (r *Router) degraded() string {
+	switch {
+	case r.Maintenance.Active():
+		return middleware.CauseMaintenance
+	case r.Concurrency.Saturated():
+		return middleware.CauseOverloaded
+	default:
+		return ""
+	}
+}
+
+// requireAuth returns the AuthMiddleware of a route, tolerating the
+// configured clock skew and accepting only the configured algorithm.
+func (r *Router) requireAuth(opts ...middleware.AuthOption) gin.HandlerFunc {
+	return middleware.AuthMiddleware(r.Config.JWTSecret, r.AuthService, r.ClaimsCompat, r.authOptions(opts)...)
+}
+
+// protect makes group require an access token, audit requests made while
+// impersonating, and turn users away until they have accepted the terms and
+// changed an expired password, where those are required. opts are passed to
+// requireAuth.
+func (r *Router) protect(group *gin.RouterGroup, opts ...middleware.AuthOption) {
+	group.Use(
+		r.requireAuth(opts...),
+		middleware.FeatureFlags(r.Flags),
+		middleware.AuditImpersonation(r.Audit),
+	)
+	if r.Config.TOSRequired {
+		group.Use(middleware.RequireTOS(r.AuthService, tosAcceptPath))
+	}
+	if r.Config.RestrictExpiredPasswords() {
+		// Accepting the terms stays reachable too, or users who must do both
+		// could do neither.
+		group.Use(middleware.RequireUnexpiredPassword(r.AuthService, passwordChangePath, tosAcceptPath))
+	}
+}
+
+// optionalAuth returns the OptionalAuth of a route, tolerating the
+// configured clock skew and accepting only the configured algorithm.
+func (r *Router) optionalAuth(opts ...middleware.AuthOption) gin.HandlerFunc {
+	return middleware.OptionalAuth(r.Config.JWTSecret, r.AuthService, r.ClaimsCompat, r.authOptions(opts)...)
+}
+
+func (r *Router) authOptions(opts []middleware.AuthOption) []middleware.AuthOption {
+	return append([]middleware.AuthOption{
+		middleware.WithClock(r.Clock),
+		middleware.WithClockSkew(r.Config.JWTClockSkew),
+		middleware.WithAlgorithmPin(r.AlgorithmPin),
+	}, opts...)
 }
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/storage"
+	"github.com/google/uuid"
+)
+
+// DefaultPurgeBatchSize is how many accounts are erased per transaction.
+const DefaultPurgeBatchSize = 100
+
+var ErrNotDeleted = errors.New("account is not scheduled for deletion")
+
+type AccountDeletionRepository interface {
+	RequestDeletion(ctx context.Context, id uuid.UUID, at time.Time) error
+	RestoreDeleted(ctx context.Context, id uuid.UUID) error
+	PurgeDeletionRequestedBefore(ctx context.Context, before time.Time, limit int) ([]repository.PurgedUser, error)
+}
+
+type SessionRevoker interface {
+	RevokeAllForUser(ctx context.Context, userID uuid.UUID) error
+}
+
+// AccountDeletionService erases accounts at their owners' request. Erasure is
+// delayed by a grace period during which logging in again cancels it; once the
+// period has passed the account, its related data and its avatar are purged
+// for good.
+type AccountDeletionService struct {
+	userRepo    AccountDeletionRepository
+	sessions    SessionRevoker
+	avatars     storage.BlobStorage
+	gracePeriod time.Duration
+	batchSize   int
+	now         func() time.Time
+}
+
+func NewAccountDeletionService(userRepo AccountDeletionRepository, sessions SessionRevoker, avatars storage.BlobStorage, gracePeriod time.Duration) *AccountDeletionService {
+	return &AccountDeletionService{
+		userRepo:    userRepo,
+		sessions:    sessions,
+		avatars:     avatars,
+		gracePeriod: gracePeriod,
+		batchSize:   DefaultPurgeBatchSize,
+		now:         time.Now,
+	}
+}
+
+// RequestErasure marks the account of userID for deletion and revokes all of
+// its sessions, so the user is logged out everywhere. It returns the time after
+// which the account will be purged.
+func (s *AccountDeletionService) RequestErasure(ctx context.Context, userID string) (time.Time, error) {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return time.Time{}, ErrInvalidUserID
+	}
+
+	requestedAt := s.now()
+	if err := s.userRepo.RequestDeletion(ctx, id, requestedAt); err != nil {
+		return time.Time{}, err
+	}
+	if err := s.sessions.RevokeAllForUser(ctx, id); err != nil {
+		return time.Time{}, err
+	}
+
+	return requestedAt.Add(s.gracePeriod), nil
+}
+
+// Restore cancels the deletion of the account of userID on behalf of its
+// owner, for accounts deleted by mistake. It returns ErrNotDeleted if the
+// account is not scheduled for deletion, including when it was already purged.
+// Sessions revoked when the deletion was requested stay revoked.
+func (s *AccountDeletionService) Restore(ctx context.Context, userID string) error {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return ErrInvalidUserID
+	}
+
+	if err := s.userRepo.RestoreDeleted(ctx, id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return fmt.Errorf("%w: %w", ErrNotDeleted, err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// PurgeExpired erases every account whose grace period has passed, one batch
+// at a time, and returns how many were erased. The avatars of the erased
+// accounts are deleted once their batch is committed.
+func (s *AccountDeletionService) PurgeExpired(ctx context.Context) (int, error) {
+	before := s.now().Add(-s.gracePeriod)
+
+	purged := 0
+	for {
+		users, err := s.userRepo.PurgeDeletionRequestedBefore(ctx, before, s.batchSize)
+		if err != nil {
+			return purged, err
+		}
+		s.deleteAvatars(ctx, users)
+		purged += len(users)
+		if len(users) < s.batchSize {
+			return purged, nil
+		}
+	}
+}
+
+// deleteAvatars deletes the avatars of purged users. The accounts are already
+// gone, so failures, which leave an orphaned file, are only logged.
+func (s *AccountDeletionService) deleteAvatars(ctx context.Context, users []repository.PurgedUser) {
+	for _, user := range users {
+		if user.AvatarURL == nil {
+			continue
+		}
+		if err := s.avatars.Delete(ctx, *user.AvatarURL); err != nil {
+			slog.WarnContext(ctx, "failed to delete avatar of purged account", "user_id", user.ID, "url", *user.AvatarURL, "error", err)
+		}
+	}
+}
+
+// RunPurge purges expired accounts, as a job run periodically, and logs how
+// many were erased.
+func (s *AccountDeletionService) RunPurge(ctx context.Context) error {
+	purged, err := s.PurgeExpired(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to purge deleted accounts: %w", err)
+	}
+	if purged > 0 {
+		slog.InfoContext(ctx, "purged deleted accounts", "count", purged)
+	}
+	return nil
+}
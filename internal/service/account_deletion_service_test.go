@@ -0,0 +1,357 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testGracePeriod = 14 * 24 * time.Hour
+
+// fakeClock is a manually advanced clock.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+// memoryAccountStore keeps deletion requests and sessions in memory, the way
+// the user and refresh token repositories keep them in the database.
+type memoryAccountStore struct {
+	mu          sync.Mutex
+	users       map[uuid.UUID]*time.Time
+	sessions    map[uuid.UUID]bool
+	avatars     map[uuid.UUID]string
+	requestErr  error
+	purgeErr    error
+	purgeCalls  int
+	revokeCalls int
+}
+
+func newMemoryAccountStore(ids ...uuid.UUID) *memoryAccountStore {
+	store := &memoryAccountStore{users: map[uuid.UUID]*time.Time{}, sessions: map[uuid.UUID]bool{}, avatars: map[uuid.UUID]string{}}
+	for _, id := range ids {
+		store.users[id] = nil
+		store.sessions[id] = true
+	}
+	return store
+}
+
+func (s *memoryAccountStore) RequestDeletion(_ context.Context, id uuid.UUID, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.requestErr != nil {
+		return s.requestErr
+	}
+	s.users[id] = &at
+	return nil
+}
+
+// CancelDeletion is what a successful login does during the grace period.
+func (s *memoryAccountStore) CancelDeletion(_ context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[id]; ok {
+		s.users[id] = nil
+	}
+	return nil
+}
+
+func (s *memoryAccountStore) RestoreDeleted(_ context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if requestedAt, ok := s.users[id]; !ok || requestedAt == nil {
+		return repository.ErrNotFound
+	}
+	s.users[id] = nil
+	return nil
+}
+
+func (s *memoryAccountStore) PurgeDeletionRequestedBefore(_ context.Context, before time.Time, limit int) ([]repository.PurgedUser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.purgeCalls++
+	if s.purgeErr != nil {
+		return nil, s.purgeErr
+	}
+
+	var ids []uuid.UUID
+	for id, requestedAt := range s.users {
+		if requestedAt != nil && requestedAt.Before(before) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].String() < ids[j].String() })
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	purged := make([]repository.PurgedUser, len(ids))
+	for i, id := range ids {
+		purged[i].ID = id
+		if avatarURL, ok := s.avatars[id]; ok {
+			purged[i].AvatarURL = &avatarURL
+		}
+		delete(s.users, id)
+		delete(s.sessions, id)
+		delete(s.avatars, id)
+	}
+	return purged, nil
+}
+
+func (s *memoryAccountStore) RevokeAllForUser(_ context.Context, userID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revokeCalls++
+	s.sessions[userID] = false
+	return nil
+}
+
+func (s *memoryAccountStore) exists(id uuid.UUID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.users[id]
+	return ok
+}
+
+// recordingBlobStorage records the objects deleted from it.
+type recordingBlobStorage struct {
+	deleted   []string
+	deleteErr error
+}
+
+func (s *recordingBlobStorage) Put(_ context.Context, key string, _ []byte, _ string) (string, error) {
+	return "/avatars/" + key, nil
+}
+
+func (s *recordingBlobStorage) Delete(_ context.Context, url string) error {
+	s.deleted = append(s.deleted, url)
+	return s.deleteErr
+}
+
+func setupAccountDeletionTest(ids ...uuid.UUID) (*AccountDeletionService, *memoryAccountStore, *fakeClock) {
+	store := newMemoryAccountStore(ids...)
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	s := NewAccountDeletionService(store, store, &recordingBlobStorage{}, testGracePeriod)
+	s.now = clock.Now
+	return s, store, clock
+}
+
+func TestAccountDeletionService_RequestErasure(t *testing.T) {
+	userID := uuid.New()
+	s, store, clock := setupAccountDeletionTest(userID)
+
+	purgeAt, err := s.RequestErasure(context.Background(), userID.String())
+
+	require.NoError(t, err)
+	assert.Equal(t, clock.Now().Add(testGracePeriod), purgeAt)
+	assert.Equal(t, clock.Now(), *store.users[userID])
+	assert.False(t, store.sessions[userID], "sessions must be revoked immediately")
+}
+
+func TestAccountDeletionService_RequestErasureErrors(t *testing.T) {
+	t.Run("invalid user id", func(t *testing.T) {
+		s, store, _ := setupAccountDeletionTest()
+
+		_, err := s.RequestErasure(context.Background(), "not-a-uuid")
+
+		assert.ErrorIs(t, err, ErrInvalidUserID)
+		assert.Zero(t, store.revokeCalls)
+	})
+
+	t.Run("sessions are kept when the request fails", func(t *testing.T) {
+		userID := uuid.New()
+		s, store, _ := setupAccountDeletionTest(userID)
+		errReset := errors.New("connection reset")
+		store.requestErr = errReset
+
+		_, err := s.RequestErasure(context.Background(), userID.String())
+
+		assert.ErrorIs(t, err, errReset)
+		assert.True(t, store.sessions[userID])
+	})
+}
+
+func TestAccountDeletionService_PurgeAfterGracePeriod(t *testing.T) {
+	userID := uuid.New()
+	s, store, clock := setupAccountDeletionTest(userID)
+	ctx := context.Background()
+
+	_, err := s.RequestErasure(ctx, userID.String())
+	require.NoError(t, err)
+
+	clock.Advance(testGracePeriod - time.Hour)
+	purged, err := s.PurgeExpired(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, purged)
+	assert.True(t, store.exists(userID), "account must survive the grace period")
+
+	clock.Advance(2 * time.Hour)
+	purged, err = s.PurgeExpired(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	assert.False(t, store.exists(userID))
+}
+
+func TestAccountDeletionService_PurgeCutoff(t *testing.T) {
+	atCutoff, pastCutoff := uuid.New(), uuid.New()
+	s, store, clock := setupAccountDeletionTest(atCutoff, pastCutoff)
+	ctx := context.Background()
+
+	_, err := s.RequestErasure(ctx, pastCutoff.String())
+	require.NoError(t, err)
+	clock.Advance(time.Nanosecond)
+	_, err = s.RequestErasure(ctx, atCutoff.String())
+	require.NoError(t, err)
+	clock.Advance(testGracePeriod)
+
+	purged, err := s.PurgeExpired(ctx)
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	assert.True(t, store.exists(atCutoff), "an account deleted exactly one grace period ago is kept")
+	assert.False(t, store.exists(pastCutoff))
+}
+
+func TestAccountDeletionService_Restore(t *testing.T) {
+	userID := uuid.New()
+	s, store, clock := setupAccountDeletionTest(userID)
+	ctx := context.Background()
+
+	_, err := s.RequestErasure(ctx, userID.String())
+	require.NoError(t, err)
+
+	require.NoError(t, s.Restore(ctx, userID.String()))
+	assert.Nil(t, store.users[userID])
+	assert.False(t, store.sessions[userID], "sessions stay revoked")
+
+	clock.Advance(testGracePeriod + time.Minute)
+	purged, err := s.PurgeExpired(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, purged)
+	assert.True(t, store.exists(userID))
+}
+
+func TestAccountDeletionService_RestoreErrors(t *testing.T) {
+	neverDeleted := uuid.New()
+	purged := uuid.New()
+	s, store, clock := setupAccountDeletionTest(neverDeleted, purged)
+	ctx := context.Background()
+	_, err := s.RequestErasure(ctx, purged.String())
+	require.NoError(t, err)
+	clock.Advance(testGracePeriod + time.Minute)
+	_, err = s.PurgeExpired(ctx)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		userID  string
+		wantErr error
+	}{
+		{name: "invalid user id", userID: "not-a-uuid", wantErr: ErrInvalidUserID},
+		{name: "never deleted", userID: neverDeleted.String(), wantErr: ErrNotDeleted},
+		{name: "already purged", userID: purged.String(), wantErr: ErrNotDeleted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, s.Restore(ctx, tt.userID), tt.wantErr)
+		})
+	}
+	assert.Nil(t, store.users[neverDeleted])
+}
+
+func TestAccountDeletionService_LoginCancelsDeletion(t *testing.T) {
+	userID := uuid.New()
+	s, store, clock := setupAccountDeletionTest(userID)
+	ctx := context.Background()
+
+	_, err := s.RequestErasure(ctx, userID.String())
+	require.NoError(t, err)
+
+	clock.Advance(testGracePeriod / 2)
+	require.NoError(t, store.CancelDeletion(ctx, userID))
+
+	clock.Advance(testGracePeriod)
+	purged, err := s.PurgeExpired(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, purged)
+	assert.True(t, store.exists(userID))
+}
+
+func TestAccountDeletionService_PurgeInBatches(t *testing.T) {
+	ids := []uuid.UUID{uuid.New(), uuid.New(), uuid.New(), uuid.New(), uuid.New()}
+	s, store, clock := setupAccountDeletionTest(ids...)
+	s.batchSize = 2
+	ctx := context.Background()
+
+	for _, id := range ids {
+		_, err := s.RequestErasure(ctx, id.String())
+		require.NoError(t, err)
+	}
+	clock.Advance(testGracePeriod + time.Minute)
+
+	purged, err := s.PurgeExpired(ctx)
+
+	require.NoError(t, err)
+	assert.Equal(t, len(ids), purged)
+	assert.Equal(t, 3, store.purgeCalls)
+}
+
+func TestAccountDeletionService_PurgeDeletesAvatars(t *testing.T) {
+	withAvatar, withoutAvatar := uuid.New(), uuid.New()
+	s, store, clock := setupAccountDeletionTest(withAvatar, withoutAvatar)
+	avatars := &recordingBlobStorage{deleteErr: errors.New("permission denied")}
+	s.avatars = avatars
+	store.avatars[withAvatar] = "/avatars/" + withAvatar.String() + ".png"
+	ctx := context.Background()
+
+	for _, id := range []uuid.UUID{withAvatar, withoutAvatar} {
+		_, err := s.RequestErasure(ctx, id.String())
+		require.NoError(t, err)
+	}
+	clock.Advance(testGracePeriod + time.Minute)
+
+	purged, err := s.PurgeExpired(ctx)
+
+	require.NoError(t, err, "a file left behind does not fail the purge")
+	assert.Equal(t, 2, purged)
+	assert.Equal(t, []string{"/avatars/" + withAvatar.String() + ".png"}, avatars.deleted)
+}
+
+func TestAccountDeletionService_PurgeError(t *testing.T) {
+	s, store, _ := setupAccountDeletionTest()
+	errReset := errors.New("connection reset")
+	store.purgeErr = errReset
+
+	purged, err := s.PurgeExpired(context.Background())
+
+	assert.ErrorIs(t, err, errReset)
+	assert.Zero(t, purged)
+}
+
+func TestAccountDeletionService_RunPurge(t *testing.T) {
+	userID := uuid.New()
+	s, store, clock := setupAccountDeletionTest(userID)
+	ctx := context.Background()
+
+	_, err := s.RequestErasure(ctx, userID.String())
+	require.NoError(t, err)
+	clock.Advance(testGracePeriod + time.Minute)
+
+	require.NoError(t, s.RunPurge(ctx))
+	assert.False(t, store.exists(userID))
+
+	errReset := errors.New("connection reset")
+	store.purgeErr = errReset
+	assert.ErrorIs(t, s.RunPurge(ctx), errReset)
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/PakornBank/learn-go/internal/database"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+)
+
+// BootstrapAdminName is the full name of an admin created by AdminBootstrapService.
+const BootstrapAdminName = "Administrator"
+
+type AdminBootstrapRepository interface {
+	FindByRole(ctx context.Context, role string) ([]model.User, error)
+	BootstrapAdmin(ctx context.Context, user *model.User) (repository.AdminBootstrap, error)
+}
+
+// NewPasswordHasher hashes passwords chosen outside the forms that bind them,
+// after checking them against the password policy. AuthService implements it.
+type NewPasswordHasher interface {
+	HashNewPassword(ctx context.Context, password string) (string, error)
+}
+
+// AdminBootstrapService gives a fresh deployment its first admin, who can
+// then reach the admin endpoints to manage everyone else.
+type AdminBootstrapService struct {
+	userRepo  AdminBootstrapRepository
+	passwords NewPasswordHasher
+}
+
+// NewAdminBootstrapService creates an AdminBootstrapService that checks and
+// hashes the password of a new admin with passwords.
+func NewAdminBootstrapService(userRepo AdminBootstrapRepository, passwords NewPasswordHasher) *AdminBootstrapService {
+	return &AdminBootstrapService{userRepo: userRepo, passwords: passwords}
+}
+
+// Bootstrap makes the account with email an admin if there is no admin yet,
+// creating it with password if it does not exist; an existing account keeps
+// its password. Once there is an admin, Bootstrap does nothing, so it can run
+// on every startup and on several replicas at once, as described on
+// repository.UserRepository.BootstrapAdmin. Either change is logged as a
+// warning, for operators to notice an account gaining admin rights.
+// password must meet the password policy even if it ends up unused.
+func (s *AdminBootstrapService) Bootstrap(ctx context.Context, email, password string) (repository.AdminBootstrap, error) {
+	// A replica lagging behind could miss an admin created moments ago.
+	ctx = database.WithPrimary(ctx)
+	admins, err := s.userRepo.FindByRole(ctx, model.RoleAdmin)
+	if err != nil {
+		return repository.AdminExists, err
+	}
+	if len(admins) > 0 {
+		return repository.AdminExists, nil
+	}
+
+	hashedPassword, err := s.passwords.HashNewPassword(ctx, password)
+	if err != nil {
+		return repository.AdminExists, err
+	}
+
+	user := &model.User{Email: email, PasswordHash: hashedPassword, FullName: BootstrapAdminName}
+	outcome, err := s.userRepo.BootstrapAdmin(ctx, user)
+	if err != nil {
+		return repository.AdminExists, err
+	}
+
+	switch outcome {
+	case repository.AdminCreated:
+		slog.WarnContext(ctx, "created the bootstrap admin account, change its password", "user_id", user.ID, "email", email)
+	case repository.AdminPromoted:
+		slog.WarnContext(ctx, "promoted an existing account to bootstrap admin", "user_id", user.ID, "email", email)
+	}
+	return outcome, nil
+}
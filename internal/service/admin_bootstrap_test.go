@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAdminBootstrapRepository keeps users in memory and bootstraps admins
+// like the real repository.
+type fakeAdminBootstrapRepository struct {
+	users      []*model.User
+	bootstraps int
+}
+
+func (r *fakeAdminBootstrapRepository) FindByRole(_ context.Context, role string) ([]model.User, error) {
+	var found []model.User
+	for _, user := range r.users {
+		if user.Role == role {
+			found = append(found, *user)
+		}
+	}
+	return found, nil
+}
+
+func (r *fakeAdminBootstrapRepository) BootstrapAdmin(_ context.Context, user *model.User) (repository.AdminBootstrap, error) {
+	r.bootstraps++
+	for _, existing := range r.users {
+		if existing.Role == model.RoleAdmin {
+			return repository.AdminExists, nil
+		}
+	}
+	for _, existing := range r.users {
+		if existing.Email == user.Email {
+			existing.Role = model.RoleAdmin
+			user.ID, user.Role = existing.ID, model.RoleAdmin
+			return repository.AdminPromoted, nil
+		}
+	}
+	user.ID, user.Role = uuid.New(), model.RoleAdmin
+	r.users = append(r.users, user)
+	return repository.AdminCreated, nil
+}
+
+func TestAdminBootstrapService_Bootstrap(t *testing.T) {
+	const email = "admin@example.com"
+
+	tests := []struct {
+		name         string
+		users        []*model.User
+		password     string
+		wantOutcome  repository.AdminBootstrap
+		wantErr      error
+		wantPassword string
+	}{
+		{
+			name:         "creates the admin",
+			users:        []*model.User{{ID: uuid.New(), Email: "user@example.com", Role: model.RoleUser}},
+			password:     "admin-password",
+			wantOutcome:  repository.AdminCreated,
+			wantPassword: testutil.FastHash("admin-password"),
+		},
+		{
+			name:         "promotes the existing account",
+			users:        []*model.User{{ID: uuid.New(), Email: email, Role: model.RoleUser, PasswordHash: testutil.FastHash("own-password")}},
+			password:     "admin-password",
+			wantOutcome:  repository.AdminPromoted,
+			wantPassword: testutil.FastHash("own-password"),
+		},
+		{
+			name:        "admin exists",
+			users:       []*model.User{{ID: uuid.New(), Email: "other@example.com", Role: model.RoleAdmin}},
+			password:    "short",
+			wantOutcome: repository.AdminExists,
+		},
+		{
+			name:     "password too short",
+			password: "short",
+			wantErr:  ErrPasswordTooShort,
+		},
+		{
+			name:     "password breached",
+			password: "password",
+			wantErr:  ErrPasswordBreached,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeAdminBootstrapRepository{users: tt.users}
+			checker := &fakeBreachChecker{}
+			if tt.password == "password" {
+				checker.count = 100
+			}
+			auth := newTestAuthService(new(MockRepository), new(MockTokenRepository), WithBreachChecker(checker, 10))
+			service := NewAdminBootstrapService(repo, auth)
+
+			outcome, err := service.Bootstrap(context.Background(), email, tt.password)
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.wantOutcome, outcome)
+			if tt.wantPassword == "" {
+				return
+			}
+			admins, err := repo.FindByRole(context.Background(), model.RoleAdmin)
+			require.NoError(t, err)
+			require.Len(t, admins, 1)
+			assert.Equal(t, email, admins[0].Email)
+			assert.Equal(t, tt.wantPassword, admins[0].PasswordHash)
+		})
+	}
+}
+
+func TestAdminBootstrapService_BootstrapTwice(t *testing.T) {
+	repo := &fakeAdminBootstrapRepository{}
+	service := NewAdminBootstrapService(repo, newTestAuthService(new(MockRepository), new(MockTokenRepository)))
+
+	first, err := service.Bootstrap(context.Background(), "admin@example.com", "admin-password")
+	require.NoError(t, err)
+	second, err := service.Bootstrap(context.Background(), "admin@example.com", "admin-password")
+	require.NoError(t, err)
+
+	assert.Equal(t, repository.AdminCreated, first)
+	assert.Equal(t, repository.AdminExists, second)
+	assert.Len(t, repo.users, 1)
+	assert.Equal(t, 1, repo.bootstraps, "a restart finds the admin without taking the lock")
+}
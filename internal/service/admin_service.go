@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultListLimit = 20
+	MaxListLimit     = 100
+
+	// RecentActivityLimit is the number of recent events in a UserDetail.
+	RecentActivityLimit = 5
+)
+
+var ErrInvalidLimit = errors.New("limit must be between 1 and 100")
+
+type AdminRepository interface {
+	ListPage(ctx context.Context, offset, limit int, sort string, desc bool) ([]model.User, int64, error)
+	FindByID(ctx context.Context, id string) (*model.User, error)
+	Activity(ctx context.Context, id uuid.UUID, limit int) (*repository.UserActivity, error)
+}
+
+// UserDetail is a user along with the activity on their account, for admins
+// looking into it.
+type UserDetail struct {
+	User     *model.User
+	Activity *repository.UserActivity
+}
+
+type AdminService struct {
+	userRepo AdminRepository
+}
+
+func NewAdminService(userRepo AdminRepository) *AdminService {
+	return &AdminService{userRepo: userRepo}
+}
+
+// ListUsers returns the users on page and the total number of users. It
+// returns ErrInvalidPage if the page number or size is out of range.
+func (s *AdminService) ListUsers(ctx context.Context, page PageRequest) ([]model.User, int64, error) {
+	if !page.valid() {
+		return nil, 0, ErrInvalidPage
+	}
+
+	return s.userRepo.ListPage(ctx, page.Offset(), page.PerPage, page.Sort, page.Desc)
+}
+
+// GetUser returns the user id along with their active session count, failed
+// logins since their last successful one and RecentActivityLimit most recent
+// events. The activity is read in a fixed number of queries, however much of
+// it there is. It returns ErrInvalidUserID if id is not a UUID, and
+// ErrUserNotFound if there is no such user.
+func (s *AdminService) GetUser(ctx context.Context, id string) (*UserDetail, error) {
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrInvalidUserID
+	}
+
+	user, err := s.userRepo.FindByID(ctx, userID.String())
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %w", ErrUserNotFound, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	activity, err := s.userRepo.Activity(ctx, userID, RecentActivityLimit)
+	if err != nil {
+		return nil, err
+	}
+	return &UserDetail{User: user, Activity: activity}, nil
+}
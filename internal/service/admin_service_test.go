@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestNewAdminService(t *testing.T) {
+	mockRepo := new(MockRepository)
+	adminService := NewAdminService(mockRepo)
+
+	assert.NotNil(t, adminService)
+	assert.Equal(t, mockRepo, adminService.userRepo)
+}
+
+func TestAdminService_ListUsers(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+
+	tests := []struct {
+		name      string
+		page      PageRequest
+		mockFn    func(*MockRepository)
+		wantUsers []model.User
+		wantTotal int64
+		wantErr   error
+	}{
+		{
+			name: "successful listing",
+			page: PageRequest{Page: 3, PerPage: 10, Sort: "email", Desc: true},
+			mockFn: func(repo *MockRepository) {
+				repo.On("ListPage", mock.Anything, 20, 10, "email", true).Return([]model.User{mockUser}, int64(21), nil)
+			},
+			wantUsers: []model.User{mockUser},
+			wantTotal: 21,
+		},
+		{
+			name:    "page too small",
+			page:    PageRequest{Page: 0, PerPage: 10, Sort: "created_at"},
+			wantErr: ErrInvalidPage,
+		},
+		{
+			name:    "per page too small",
+			page:    PageRequest{Page: 1, PerPage: 0, Sort: "created_at"},
+			wantErr: ErrInvalidPage,
+		},
+		{
+			name:    "per page too large",
+			page:    PageRequest{Page: 1, PerPage: MaxListLimit + 1, Sort: "created_at"},
+			wantErr: ErrInvalidPage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			if tt.mockFn != nil {
+				tt.mockFn(mockRepo)
+			}
+			adminService := NewAdminService(mockRepo)
+
+			users, total, err := adminService.ListUsers(context.Background(), tt.page)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, users)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantUsers, users)
+				assert.Equal(t, tt.wantTotal, total)
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAdminService_GetUser(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	activity := &repository.UserActivity{SessionCount: 2, FailedAttempts: 1, RecentEvents: []repository.ActivityEvent{}}
+
+	tests := []struct {
+		name    string
+		id      string
+		mockFn  func(*MockRepository)
+		want    *UserDetail
+		wantErr error
+	}{
+		{
+			name: "user with activity",
+			id:   mockUser.ID.String(),
+			mockFn: func(repo *MockRepository) {
+				repo.On("FindByID", mock.Anything, mockUser.ID.String()).Return(&mockUser, nil)
+				repo.On("Activity", mock.Anything, mockUser.ID, RecentActivityLimit).Return(activity, nil)
+			},
+			want: &UserDetail{User: &mockUser, Activity: activity},
+		},
+		{
+			name:    "malformed ID",
+			id:      "not-a-uuid",
+			wantErr: ErrInvalidUserID,
+		},
+		{
+			name: "unknown user",
+			id:   uuid.Nil.String(),
+			mockFn: func(repo *MockRepository) {
+				repo.On("FindByID", mock.Anything, uuid.Nil.String()).Return(nil, repository.ErrNotFound)
+			},
+			wantErr: ErrUserNotFound,
+		},
+		{
+			name: "activity query fails",
+			id:   mockUser.ID.String(),
+			mockFn: func(repo *MockRepository) {
+				repo.On("FindByID", mock.Anything, mockUser.ID.String()).Return(&mockUser, nil)
+				repo.On("Activity", mock.Anything, mockUser.ID, RecentActivityLimit).Return(nil, sql.ErrConnDone)
+			},
+			wantErr: sql.ErrConnDone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			if tt.mockFn != nil {
+				tt.mockFn(mockRepo)
+			}
+
+			got, err := NewAdminService(mockRepo).GetUser(context.Background(), tt.id)
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.want, got)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestPageRequest_Offset(t *testing.T) {
+	assert.Equal(t, 0, PageRequest{Page: 1, PerPage: 20}.Offset())
+	assert.Equal(t, 40, PageRequest{Page: 3, PerPage: 20}.Offset())
+}
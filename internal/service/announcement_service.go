@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidAnnouncementID     = errors.New("invalid announcement id")
+	ErrAnnouncementNotFound      = errors.New("announcement not found")
+	ErrInvalidAnnouncementWindow = errors.New("ends_at must be after starts_at")
+)
+
+// severityRanks orders the severities of announcements, the most urgent first.
+var severityRanks = map[string]int{
+	model.SeverityCritical: 0,
+	model.SeverityWarning:  1,
+	model.SeverityInfo:     2,
+}
+
+type AnnouncementRepository interface {
+	Create(ctx context.Context, announcement *model.Announcement) error
+	FindByID(ctx context.Context, id uuid.UUID) (*model.Announcement, error)
+	ListActive(ctx context.Context, at time.Time) ([]model.Announcement, error)
+	ListPage(ctx context.Context, offset, limit int, desc bool) ([]model.Announcement, int64, error)
+	Update(ctx context.Context, announcement *model.Announcement) error
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type AnnouncementInput struct {
+	Message  string    `json:"message" binding:"required,max=1000"`
+	Severity string    `json:"severity" binding:"required,oneof=info warning critical"`
+	StartsAt time.Time `json:"starts_at" binding:"required"`
+	EndsAt   time.Time `json:"ends_at" binding:"required"`
+}
+
+// AnnouncementService manages the announcements shown to every user, such as
+// maintenance notices, which admins schedule at runtime.
+type AnnouncementService struct {
+	repo  AnnouncementRepository
+	clock Clock
+}
+
+// NewAnnouncementService creates an AnnouncementService that tells active
+// announcements by the time of clock.
+func NewAnnouncementService(repo AnnouncementRepository, clock Clock) *AnnouncementService {
+	return &AnnouncementService{repo: repo, clock: clock}
+}
+
+// Active returns the announcements within their window now, the most severe
+// first and, among those of one severity, the latest to start first.
+func (s *AnnouncementService) Active(ctx context.Context) ([]model.Announcement, error) {
+	announcements, err := s.repo.ListActive(ctx, s.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+	slices.SortStableFunc(announcements, func(a, b model.Announcement) int {
+		return severityRanks[a.Severity] - severityRanks[b.Severity]
+	})
+	return announcements, nil
+}
+
+// List returns a page of every announcement, active or not, sorted by start
+// time, along with the total number of announcements.
+func (s *AnnouncementService) List(ctx context.Context, page PageRequest) ([]model.Announcement, int64, error) {
+	if !page.valid() {
+		return nil, 0, ErrInvalidPage
+	}
+	return s.repo.ListPage(ctx, page.Offset(), page.PerPage, page.Desc)
+}
+
+// Create schedules an announcement from input. It returns
+// ErrInvalidAnnouncementWindow if input ends before it starts.
+func (s *AnnouncementService) Create(ctx context.Context, input AnnouncementInput) (*model.Announcement, error) {
+	if !input.EndsAt.After(input.StartsAt) {
+		return nil, ErrInvalidAnnouncementWindow
+	}
+
+	announcement := &model.Announcement{
+		Message:  input.Message,
+		Severity: input.Severity,
+		StartsAt: input.StartsAt,
+		EndsAt:   input.EndsAt,
+	}
+	if err := s.repo.Create(ctx, announcement); err != nil {
+		return nil, err
+	}
+	return announcement, nil
+}
+
+// Update replaces the message, severity and window of the announcement id
+// with those of input. It returns ErrInvalidAnnouncementWindow if input ends
+// before it starts, and ErrAnnouncementNotFound if there is no such
+// announcement.
+func (s *AnnouncementService) Update(ctx context.Context, id string, input AnnouncementInput) (*model.Announcement, error) {
+	announcementID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrInvalidAnnouncementID
+	}
+	if !input.EndsAt.After(input.StartsAt) {
+		return nil, ErrInvalidAnnouncementWindow
+	}
+
+	announcement, err := s.repo.FindByID(ctx, announcementID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %w", ErrAnnouncementNotFound, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	announcement.Message = input.Message
+	announcement.Severity = input.Severity
+	announcement.StartsAt = input.StartsAt
+	announcement.EndsAt = input.EndsAt
+	err = s.repo.Update(ctx, announcement)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %w", ErrAnnouncementNotFound, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return announcement, nil
+}
+
+// Delete removes the announcement id. It returns ErrAnnouncementNotFound if
+// there is no such announcement.
+func (s *AnnouncementService) Delete(ctx context.Context, id string) error {
+	announcementID, err := uuid.Parse(id)
+	if err != nil {
+		return ErrInvalidAnnouncementID
+	}
+
+	err = s.repo.Delete(ctx, announcementID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return fmt.Errorf("%w: %w", ErrAnnouncementNotFound, err)
+	}
+	return err
+}
@@ -0,0 +1,292 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockAnnouncementRepository struct {
+	mock.Mock
+}
+
+func (r *MockAnnouncementRepository) Create(ctx context.Context, announcement *model.Announcement) error {
+	args := r.Called(ctx, announcement)
+	return args.Error(0)
+}
+
+func (r *MockAnnouncementRepository) FindByID(ctx context.Context, id uuid.UUID) (*model.Announcement, error) {
+	args := r.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Announcement), args.Error(1)
+}
+
+func (r *MockAnnouncementRepository) ListActive(ctx context.Context, at time.Time) ([]model.Announcement, error) {
+	args := r.Called(ctx, at)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.Announcement), args.Error(1)
+}
+
+func (r *MockAnnouncementRepository) ListPage(ctx context.Context, offset, limit int, desc bool) ([]model.Announcement, int64, error) {
+	args := r.Called(ctx, offset, limit, desc)
+	if args.Get(0) == nil {
+		return nil, 0, args.Error(2)
+	}
+	return args.Get(0).([]model.Announcement), args.Get(1).(int64), args.Error(2)
+}
+
+func (r *MockAnnouncementRepository) Update(ctx context.Context, announcement *model.Announcement) error {
+	args := r.Called(ctx, announcement)
+	return args.Error(0)
+}
+
+func (r *MockAnnouncementRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	args := r.Called(ctx, id)
+	return args.Error(0)
+}
+
+var announcementNow = time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+
+func TestAnnouncementService_Active(t *testing.T) {
+	info := model.Announcement{ID: uuid.New(), Severity: model.SeverityInfo}
+	warning := model.Announcement{ID: uuid.New(), Severity: model.SeverityWarning}
+	critical := model.Announcement{ID: uuid.New(), Severity: model.SeverityCritical}
+	olderInfo := model.Announcement{ID: uuid.New(), Severity: model.SeverityInfo}
+
+	mockRepo := new(MockAnnouncementRepository)
+	mockRepo.On("ListActive", mock.Anything, announcementNow).
+		Return([]model.Announcement{info, warning, olderInfo, critical}, nil)
+
+	got, err := NewAnnouncementService(mockRepo, testutil.NewFakeClock(announcementNow)).Active(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, []model.Announcement{critical, warning, info, olderInfo}, got,
+		"most severe first, keeping the latest start first within a severity")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAnnouncementService_Create(t *testing.T) {
+	input := AnnouncementInput{
+		Message:  "Maintenance tonight",
+		Severity: model.SeverityWarning,
+		StartsAt: announcementNow,
+		EndsAt:   announcementNow.Add(2 * time.Hour),
+	}
+
+	tests := []struct {
+		name    string
+		input   func(AnnouncementInput) AnnouncementInput
+		mockFn  func(*MockAnnouncementRepository)
+		wantErr error
+	}{
+		{
+			name: "scheduled",
+			mockFn: func(repo *MockAnnouncementRepository) {
+				repo.On("Create", mock.Anything, &model.Announcement{
+					Message:  input.Message,
+					Severity: input.Severity,
+					StartsAt: input.StartsAt,
+					EndsAt:   input.EndsAt,
+				}).Return(nil)
+			},
+		},
+		{
+			name: "ends before it starts",
+			input: func(in AnnouncementInput) AnnouncementInput {
+				in.EndsAt = in.StartsAt.Add(-time.Minute)
+				return in
+			},
+			wantErr: ErrInvalidAnnouncementWindow,
+		},
+		{
+			name: "ends as it starts",
+			input: func(in AnnouncementInput) AnnouncementInput {
+				in.EndsAt = in.StartsAt
+				return in
+			},
+			wantErr: ErrInvalidAnnouncementWindow,
+		},
+		{
+			name: "database timeout",
+			mockFn: func(repo *MockAnnouncementRepository) {
+				repo.On("Create", mock.Anything, mock.Anything).Return(repository.ErrTimeout)
+			},
+			wantErr: repository.ErrTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockAnnouncementRepository)
+			if tt.mockFn != nil {
+				tt.mockFn(mockRepo)
+			}
+			in := input
+			if tt.input != nil {
+				in = tt.input(in)
+			}
+
+			got, err := NewAnnouncementService(mockRepo, SystemClock{}).Create(context.Background(), in)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, got)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, input.Message, got.Message)
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAnnouncementService_Update(t *testing.T) {
+	id := uuid.New()
+	input := AnnouncementInput{
+		Message:  "Maintenance moved",
+		Severity: model.SeverityCritical,
+		StartsAt: announcementNow.Add(time.Hour),
+		EndsAt:   announcementNow.Add(3 * time.Hour),
+	}
+	existing := func() *model.Announcement {
+		return &model.Announcement{ID: id, Message: "Maintenance tonight", Severity: model.SeverityInfo, CreatedAt: announcementNow}
+	}
+
+	tests := []struct {
+		name    string
+		id      string
+		input   AnnouncementInput
+		mockFn  func(*MockAnnouncementRepository)
+		wantErr error
+	}{
+		{
+			name:  "updated",
+			id:    id.String(),
+			input: input,
+			mockFn: func(repo *MockAnnouncementRepository) {
+				repo.On("FindByID", mock.Anything, id).Return(existing(), nil)
+				repo.On("Update", mock.Anything, &model.Announcement{
+					ID:        id,
+					Message:   input.Message,
+					Severity:  input.Severity,
+					StartsAt:  input.StartsAt,
+					EndsAt:    input.EndsAt,
+					CreatedAt: announcementNow,
+				}).Return(nil)
+			},
+		},
+		{
+			name:    "invalid id",
+			id:      "not-a-uuid",
+			input:   input,
+			wantErr: ErrInvalidAnnouncementID,
+		},
+		{
+			name:    "ends before it starts",
+			id:      id.String(),
+			input:   AnnouncementInput{Message: "m", Severity: model.SeverityInfo, StartsAt: input.EndsAt, EndsAt: input.StartsAt},
+			wantErr: ErrInvalidAnnouncementWindow,
+		},
+		{
+			name:  "not found",
+			id:    id.String(),
+			input: input,
+			mockFn: func(repo *MockAnnouncementRepository) {
+				repo.On("FindByID", mock.Anything, id).Return(nil, repository.ErrNotFound)
+			},
+			wantErr: ErrAnnouncementNotFound,
+		},
+		{
+			name:  "deleted meanwhile",
+			id:    id.String(),
+			input: input,
+			mockFn: func(repo *MockAnnouncementRepository) {
+				repo.On("FindByID", mock.Anything, id).Return(existing(), nil)
+				repo.On("Update", mock.Anything, mock.Anything).Return(repository.ErrNotFound)
+			},
+			wantErr: ErrAnnouncementNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockAnnouncementRepository)
+			if tt.mockFn != nil {
+				tt.mockFn(mockRepo)
+			}
+
+			got, err := NewAnnouncementService(mockRepo, SystemClock{}).Update(context.Background(), tt.id, tt.input)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, got)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, input.Message, got.Message)
+				assert.Equal(t, announcementNow, got.CreatedAt)
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAnnouncementService_Delete(t *testing.T) {
+	id := uuid.New()
+	diskFull := errors.New("disk full")
+
+	tests := []struct {
+		name    string
+		id      string
+		repoErr error
+		wantErr error
+	}{
+		{name: "deleted", id: id.String()},
+		{name: "invalid id", id: "not-a-uuid", wantErr: ErrInvalidAnnouncementID},
+		{name: "not found", id: id.String(), repoErr: repository.ErrNotFound, wantErr: ErrAnnouncementNotFound},
+		{name: "database error", id: id.String(), repoErr: diskFull, wantErr: diskFull},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockAnnouncementRepository)
+			if tt.id == id.String() {
+				mockRepo.On("Delete", mock.Anything, id).Return(tt.repoErr)
+			}
+
+			err := NewAnnouncementService(mockRepo, SystemClock{}).Delete(context.Background(), tt.id)
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAnnouncementService_List(t *testing.T) {
+	mockRepo := new(MockAnnouncementRepository)
+	announcements := []model.Announcement{{ID: uuid.New()}}
+	mockRepo.On("ListPage", mock.Anything, 20, 20, true).Return(announcements, int64(21), nil)
+	svc := NewAnnouncementService(mockRepo, SystemClock{})
+
+	got, total, err := svc.List(context.Background(), PageRequest{Page: 2, PerPage: 20, Sort: "starts_at", Desc: true})
+
+	require.NoError(t, err)
+	assert.Equal(t, announcements, got)
+	assert.Equal(t, int64(21), total)
+
+	_, _, err = svc.List(context.Background(), PageRequest{Page: 0, PerPage: 20})
+	assert.ErrorIs(t, err, ErrInvalidPage)
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// asyncWriter persists items on a background goroutine so that recording one
+// never fails the operation it describes. Items are queued on a buffered
+// channel; when the buffer is full, Record waits up to enqueueTimeout for room
+// and then drops the item and logs an error. It is shared by the writers of
+// audit entries and login events, and by NewDeviceNotifier.
+type asyncWriter[T any] struct {
+	// kind names the items in logs, such as "audit entry".
+	kind  string
+	write func(ctx context.Context, item T) error
+	// attrs returns the log attributes identifying item.
+	attrs          func(item T) []any
+	enqueueTimeout time.Duration
+	items          chan T
+	done           chan struct{}
+	once           sync.Once
+	mu             sync.RWMutex
+	closed         bool
+}
+
+// newAsyncWriter creates an asyncWriter with room for bufferSize queued items,
+// each written with write, and starts its background writer. Call Close to
+// flush and stop it.
+func newAsyncWriter[T any](kind string, bufferSize int, enqueueTimeout time.Duration, write func(context.Context, T) error, attrs func(T) []any) *asyncWriter[T] {
+	w := &asyncWriter[T]{
+		kind:           kind,
+		write:          write,
+		attrs:          attrs,
+		enqueueTimeout: enqueueTimeout,
+		items:          make(chan T, bufferSize),
+		done:           make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Record queues item for writing. If the buffer is full, it blocks for up to
+// the enqueue timeout and then drops the item. Items recorded after Close are
+// dropped.
+func (w *asyncWriter[T]) Record(item T) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.closed {
+		return
+	}
+
+	select {
+	case w.items <- item:
+		return
+	default:
+	}
+	if w.enqueueTimeout > 0 {
+		timer := time.NewTimer(w.enqueueTimeout)
+		defer timer.Stop()
+		select {
+		case w.items <- item:
+			return
+		case <-timer.C:
+		}
+	}
+	slog.Error(w.kind+" buffer full, dropping it", w.attrs(item)...)
+}
+
+// Close stops accepting items and waits until every queued item has been written.
+func (w *asyncWriter[T]) Close() {
+	w.once.Do(func() {
+		w.mu.Lock()
+		w.closed = true
+		close(w.items)
+		w.mu.Unlock()
+	})
+	<-w.done
+}
+
+func (w *asyncWriter[T]) run() {
+	defer close(w.done)
+
+	for item := range w.items {
+		if err := w.write(context.Background(), item); err != nil {
+			slog.Warn("failed to write "+w.kind, append(w.attrs(item), "error", err)...)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/google/uuid"
+)
+
+var ErrInvalidTimeRange = errors.New("from must be before to")
+
+type AuditLogRepository interface {
+	List(ctx context.Context, filter repository.AuditFilter, cursor string, limit int) ([]model.AuditEntry, string, error)
+}
+
+// AuditQuery selects the audit entries to list. Zero fields select everything.
+type AuditQuery struct {
+	// ActorID is the ID of the user whose requests to list.
+	ActorID string
+	// From is the earliest time of the requests to list.
+	From time.Time
+	// To is the time the listed requests were made before.
+	To time.Time
+}
+
+type AuditLogService struct {
+	auditRepo AuditLogRepository
+}
+
+func NewAuditLogService(auditRepo AuditLogRepository) *AuditLogService {
+	return &AuditLogService{auditRepo: auditRepo}
+}
+
+// List returns a page of the audit entries selected by query, newest first,
+// along with the cursor for the next page.
+func (s *AuditLogService) List(ctx context.Context, query AuditQuery, cursor string, limit int) ([]model.AuditEntry, string, error) {
+	if limit < 1 || limit > MaxListLimit {
+		return nil, "", ErrInvalidLimit
+	}
+
+	filter := repository.AuditFilter{From: query.From, To: query.To}
+	if query.ActorID != "" {
+		id, err := uuid.Parse(query.ActorID)
+		if err != nil {
+			return nil, "", ErrInvalidUserID
+		}
+		filter.ActorID = &id
+	}
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		return nil, "", ErrInvalidTimeRange
+	}
+
+	return s.auditRepo.List(ctx, filter, cursor, limit)
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockAuditLogRepository struct {
+	mock.Mock
+}
+
+func (r *MockAuditLogRepository) List(ctx context.Context, filter repository.AuditFilter, cursor string, limit int) ([]model.AuditEntry, string, error) {
+	args := r.Called(ctx, filter, cursor, limit)
+	if args.Get(0) == nil {
+		return nil, args.String(1), args.Error(2)
+	}
+	return args.Get(0).([]model.AuditEntry), args.String(1), args.Error(2)
+}
+
+func TestAuditLogService_List(t *testing.T) {
+	actorID := uuid.New()
+	entry := model.AuditEntry{ID: uuid.New(), ActorID: actorID, Method: "GET", Path: "/api/admin/users", Status: 200}
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+
+	tests := []struct {
+		name           string
+		query          AuditQuery
+		limit          int
+		mockFn         func(*MockAuditLogRepository)
+		wantEntries    []model.AuditEntry
+		wantNextCursor string
+		wantErr        error
+	}{
+		{
+			name:  "filtered listing",
+			query: AuditQuery{ActorID: actorID.String(), From: from, To: to},
+			limit: 10,
+			mockFn: func(repo *MockAuditLogRepository) {
+				filter := repository.AuditFilter{ActorID: &actorID, From: from, To: to}
+				repo.On("List", mock.Anything, filter, "cursor", 10).Return([]model.AuditEntry{entry}, "next", nil)
+			},
+			wantEntries:    []model.AuditEntry{entry},
+			wantNextCursor: "next",
+		},
+		{
+			name:  "unfiltered listing",
+			limit: 10,
+			mockFn: func(repo *MockAuditLogRepository) {
+				repo.On("List", mock.Anything, repository.AuditFilter{}, "cursor", 10).Return([]model.AuditEntry{entry}, "", nil)
+			},
+			wantEntries: []model.AuditEntry{entry},
+		},
+		{
+			name:    "invalid actor id",
+			query:   AuditQuery{ActorID: "not-a-uuid"},
+			limit:   10,
+			wantErr: ErrInvalidUserID,
+		},
+		{
+			name:    "empty time range",
+			query:   AuditQuery{From: to, To: from},
+			limit:   10,
+			wantErr: ErrInvalidTimeRange,
+		},
+		{
+			name:    "limit out of range",
+			limit:   0,
+			wantErr: ErrInvalidLimit,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockAuditLogRepository)
+			if tt.mockFn != nil {
+				tt.mockFn(mockRepo)
+			}
+			auditService := NewAuditLogService(mockRepo)
+
+			entries, nextCursor, err := auditService.List(context.Background(), tt.query, "cursor", tt.limit)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, entries)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantEntries, entries)
+				assert.Equal(t, tt.wantNextCursor, nextCursor)
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+)
+
+const (
+	// DefaultAuditBuffer is the number of audit entries that can be queued
+	// before AuditWriter makes callers wait.
+	DefaultAuditBuffer = 256
+	// DefaultAuditEnqueueTimeout is how long AuditWriter waits for room in a
+	// full buffer before dropping an entry.
+	DefaultAuditEnqueueTimeout = 2 * time.Second
+)
+
+type AuditStore interface {
+	Create(ctx context.Context, entry *model.AuditEntry) error
+}
+
+// AuditWriter persists audit entries on a background goroutine so that
+// auditing a request never fails the request itself. Entries are queued on a
+// buffered channel. Since the audit log is the record of what admins did,
+// notably while impersonating users, a full buffer makes Record wait for the
+// writer to catch up rather than drop the entry at once; only if it cannot
+// within the enqueue timeout is the entry dropped and an error logged.
+type AuditWriter struct {
+	writer *asyncWriter[*model.AuditEntry]
+}
+
+// NewAuditWriter creates an AuditWriter with room for bufferSize queued
+// entries, whose Record waits up to enqueueTimeout for room, and starts its
+// background writer. Call Close to flush and stop it.
+func NewAuditWriter(store AuditStore, bufferSize int, enqueueTimeout time.Duration) *AuditWriter {
+	return &AuditWriter{
+		writer: newAsyncWriter("audit entry", bufferSize, enqueueTimeout, store.Create, func(entry *model.AuditEntry) []any {
+			return []any{"actor_id", entry.ActorID, "path", entry.Path}
+		}),
+	}
+}
+
+// Record queues entry for writing. If the buffer is full, it blocks for up to
+// the enqueue timeout and then drops the entry. Entries recorded after Close
+// are dropped.
+func (w *AuditWriter) Record(entry *model.AuditEntry) {
+	w.writer.Record(entry)
+}
+
+// Close stops accepting entries and waits until every queued entry has been written.
+func (w *AuditWriter) Close() {
+	w.writer.Close()
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeAuditStore struct {
+	mu      sync.Mutex
+	entries []*model.AuditEntry
+	err     error
+	release chan struct{}
+}
+
+func (s *fakeAuditStore) Create(_ context.Context, entry *model.AuditEntry) error {
+	if s.release != nil {
+		<-s.release
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, entry)
+	return s.err
+}
+
+func TestAuditWriter_CloseFlushesQueuedEntries(t *testing.T) {
+	store := &fakeAuditStore{}
+	writer := NewAuditWriter(store, 10, time.Second)
+
+	for _, path := range []string{"/a", "/b", "/c"} {
+		writer.Record(&model.AuditEntry{Path: path})
+	}
+	writer.Close()
+
+	assert.Len(t, store.entries, 3)
+	assert.Equal(t, "/a", store.entries[0].Path)
+	assert.Equal(t, "/c", store.entries[2].Path)
+}
+
+func TestAuditWriter_WaitsForRoomWhenBufferFull(t *testing.T) {
+	store := &fakeAuditStore{release: make(chan struct{})}
+	writer := NewAuditWriter(store, 1, 5*time.Second)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(store.release)
+	}()
+	// The store is blocked while the buffer fills, so some of these wait for
+	// the writer to catch up instead of being dropped.
+	for i := 0; i < 5; i++ {
+		writer.Record(&model.AuditEntry{Path: "/a"})
+	}
+	writer.Close()
+
+	assert.Len(t, store.entries, 5)
+}
+
+func TestAuditWriter_DropsAfterEnqueueTimeout(t *testing.T) {
+	store := &fakeAuditStore{release: make(chan struct{})}
+	writer := NewAuditWriter(store, 1, 10*time.Millisecond)
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		writer.Record(&model.AuditEntry{Path: "/a"})
+	}
+	elapsed := time.Since(start)
+	close(store.release)
+	writer.Close()
+
+	assert.GreaterOrEqual(t, elapsed, 10*time.Millisecond, "a full buffer makes Record wait")
+	assert.Less(t, elapsed, time.Second, "Record gives up after the timeout")
+	assert.LessOrEqual(t, len(store.entries), 2)
+	assert.NotEmpty(t, store.entries)
+}
+
+func TestAuditWriter_StoreErrorDoesNotStopWriter(t *testing.T) {
+	store := &fakeAuditStore{err: errors.New("database error")}
+	writer := NewAuditWriter(store, 10, time.Second)
+
+	writer.Record(&model.AuditEntry{Path: "/a"})
+	writer.Record(&model.AuditEntry{Path: "/b"})
+	writer.Close()
+
+	assert.Len(t, store.entries, 2)
+}
+
+func TestAuditWriter_RecordAfterClose(t *testing.T) {
+	store := &fakeAuditStore{}
+	writer := NewAuditWriter(store, 10, time.Second)
+	writer.Close()
+
+	assert.NotPanics(t, func() {
+		writer.Record(&model.AuditEntry{Path: "/a"})
+	})
+	assert.Empty(t, store.entries)
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/PakornBank/learn-go/internal/password"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// benchmarkHashers are the password hashers the auth benchmarks run with:
+// bcrypt at the production cost, which dominates the latency of the auth
+// paths, and FastHasher, which leaves only the service's own overhead.
+func benchmarkHashers() []struct {
+	name   string
+	hasher PasswordHasher
+} {
+	return []struct {
+		name   string
+		hasher PasswordHasher
+	}{
+		{name: "bcrypt", hasher: password.NewPool(bcrypt.DefaultCost, password.DefaultPoolSize())},
+		{name: "fast", hasher: testutil.FastHasher{}},
+	}
+}
+
+func BenchmarkAuthService_Login(b *testing.B) {
+	for _, bench := range benchmarkHashers() {
+		b.Run(bench.name, func(b *testing.B) {
+			mockUser := testutil.NewMockUser()
+			hash, err := bench.hasher.Hash(context.Background(), "password")
+			if err != nil {
+				b.Fatal(err)
+			}
+			mockUser.PasswordHash = hash
+
+			mockRepo := new(MockRepository)
+			mockRepo.On("FindByEmail", mock.Anything, mockUser.Email).Return(&mockUser, nil)
+			mockRepo.On("UpdateLastLogin", mock.Anything, mockUser.ID, mock.Anything).Return(nil)
+			mockTokenRepo := new(MockTokenRepository)
+			mockTokenRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+			service := NewAuthService(mockRepo, mockTokenRepo, newTestConfig(), WithPasswordHasher(bench.hasher))
+			input := LoginInput{Email: mockUser.Email, Password: "password"}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := service.Login(context.Background(), input); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkRegister(b *testing.B) {
+	for _, bench := range benchmarkHashers() {
+		b.Run(bench.name, func(b *testing.B) {
+			mockUser := testutil.NewMockUser()
+			mockRepo := new(MockRepository)
+			mockRepo.On("FindByEmail", mock.Anything, mockUser.Email).Return(nil, repository.ErrNotFound)
+			mockRepo.On("CreateWithOutbox", mock.Anything, mock.AnythingOfType("*model.User")).Return(nil)
+			service := NewAuthService(mockRepo, new(MockTokenRepository), newTestConfig(), WithPasswordHasher(bench.hasher))
+			input := RegisterInput{Email: mockUser.Email, Password: "password", FullName: mockUser.FullName}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := service.Register(context.Background(), input); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
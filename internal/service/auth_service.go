@@ -2,94 +2,1056 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
 	"time"
+	"unicode/utf8"
 
 	"github.com/PakornBank/learn-go/internal/config"
+	"github.com/PakornBank/learn-go/internal/database"
+	"github.com/PakornBank/learn-go/internal/device"
+	"github.com/PakornBank/learn-go/internal/events"
 	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/password"
+	"github.com/PakornBank/learn-go/internal/repository"
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
 	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/sync/singleflight"
 )
 
+// The errors AuthService returns for expected failures. They may wrap the
+// error that caused them, such as repository.ErrNotFound, so callers must
+// compare with errors.Is and should respond with the sentinel's own message.
+var (
+	ErrEmailTaken            = errors.New("email already registered")
+	ErrAccountRecoverable    = errors.New("an account with this email is scheduled for deletion and can still be recovered by logging in or contacting support")
+	ErrInvalidCredentials    = errors.New("invalid credentials")
+	ErrInvalidRefreshToken   = errors.New("invalid refresh token")
+	ErrTokenReuseDetected    = errors.New("refresh token reuse detected")
+	ErrRegistrationDisabled  = errors.New("registration is disabled")
+	ErrDisposableEmail       = errors.New("disposable email addresses are not allowed")
+	ErrEmailDomainNotAllowed = errors.New("email domain is not allowed")
+	ErrTokenRevoked          = errors.New("token has been revoked")
+	ErrUserNotFound          = errors.New("user not found")
+	ErrTokenInvalid          = errors.New("invalid access token")
+	ErrPasswordTooShort      = fmt.Errorf("password must be at least %d characters", MinPasswordLength)
+)
+
+// MinPasswordLength is the fewest characters a password may have, which the
+// min=8 binding of every input setting a password requires as well.
+const MinPasswordLength = 8
+
 type Repository interface {
 	Create(ctx context.Context, user *model.User) error
+	CreateWithOutbox(ctx context.Context, user *model.User, newEvent func(*model.User) (*model.OutboxEvent, error)) error
 	FindByEmail(ctx context.Context, email string) (*model.User, error)
+	FindByUsername(ctx context.Context, username string) (*model.User, error)
 	FindByID(ctx context.Context, id string) (*model.User, error)
+	FindByIDWithPassword(ctx context.Context, id string) (*model.User, error)
+	UpdateLastLogin(ctx context.Context, id uuid.UUID, at time.Time) error
+	UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string, changedAt time.Time) error
+	CancelDeletion(ctx context.Context, id uuid.UUID) error
+	IncrementTokenVersion(ctx context.Context, id uuid.UUID) error
+	AcceptTOS(ctx context.Context, id uuid.UUID, version int, at time.Time) error
+	UpdateLocale(ctx context.Context, id uuid.UUID, locale, timezone string) error
+}
+
+// PasswordHasher hashes passwords and checks them against their hashes.
+type PasswordHasher interface {
+	Hash(ctx context.Context, password string) (string, error)
+	Compare(ctx context.Context, hash, password string) error
+}
+
+// EmailBlocklist reports whether an email address belongs to a blocked domain.
+type EmailBlocklist interface {
+	Blocked(email string) bool
+}
+
+type TokenRepository interface {
+	Create(ctx context.Context, token *model.RefreshToken) error
+	FindByHash(ctx context.Context, tokenHash string) (*model.RefreshToken, error)
+	Rotate(ctx context.Context, oldID uuid.UUID, next *model.RefreshToken) error
+	RevokeFamily(ctx context.Context, familyID uuid.UUID) error
+	IsFamilyRevoked(ctx context.Context, familyID uuid.UUID) (bool, error)
+	RevokeAllForUser(ctx context.Context, userID uuid.UUID) error
 }
 
 type RegisterInput struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required,min=8"`
 	FullName string `json:"full_name" binding:"required"`
+	Username string `json:"username"`
+
+	// AcceptedTOS tells whether the user accepted the current terms of
+	// service, which is required when they are configured as such.
+	AcceptedTOS bool `json:"accepted_tos"`
+
+	// Locale and Timezone are the BCP 47 language tag and IANA time zone
+	// the user's clients render text and timestamps for. When Locale is
+	// empty, it is taken from AcceptLanguage, the Accept-Language header of
+	// the request, which is filled in by the handler.
+	Locale         string `json:"locale" binding:"omitempty,bcp47_language_tag"`
+	Timezone       string `json:"timezone" binding:"omitempty,timezone"`
+	AcceptLanguage string `json:"-"`
 }
 
+// LoginInput identifies the user by Identifier, which is either an email
+// address or a username. Email is still accepted in its place for clients
+// written before usernames existed.
 type LoginInput struct {
-	Email    string `json:"email" binding:"required,email"`
+	Identifier string `json:"identifier" binding:"required_without=Email"`
+	Email      string `json:"email" binding:"omitempty,email"`
+	Password   string `json:"password" binding:"required"`
+
+	// IPAddress and UserAgent describe the client making the attempt.
+	// They are filled in by the handler, not bound from the request body.
+	IPAddress string `json:"-"`
+	UserAgent string `json:"-"`
+}
+
+type ChangePasswordInput struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=8"`
+}
+
+type ReauthInput struct {
 	Password string `json:"password" binding:"required"`
 }
 
+type ScopedTokenInput struct {
+	Scopes []string `json:"scopes" binding:"required"`
+}
+
+type RefreshInput struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	// ExpiresIn is the lifetime of AccessToken, from which its exp claim was set.
+	ExpiresIn time.Duration
+	// RefreshExpiresIn is the lifetime of RefreshToken.
+	RefreshExpiresIn time.Duration
+	// User is the user the tokens were issued to.
+	User *model.User
+	// PasswordExpired reports whether the user logged in with a password
+	// older than the configured maximum age, which they should change.
+	PasswordExpired bool
+}
+
+// Introspection describes an access token as seen by the server.
+// When Active is false the remaining fields are empty, regardless of why the
+// token was rejected.
+type Introspection struct {
+	Active    bool
+	Subject   string
+	Email     string
+	ExpiresAt int64
+	IssuedAt  int64
+}
+
+// AuthService registers and authenticates users and manages their sessions.
+// It implements prometheus.Collector, counting registrations, failed logins
+// and lockouts, where a lockout is a session revoked because its refresh
+// token was reused, signing out whoever held it.
 type AuthService struct {
-	userRepo    Repository
-	jwtSecret   []byte
-	tokenExpiry time.Duration
+	userRepo      Repository
+	tokenRepo     TokenRepository
+	jwtSecret     []byte
+	signingMethod jwt.SigningMethod
+	tokenExpiry   time.Duration
+	refreshExpiry time.Duration
+	reauthMaxAge  time.Duration
+	impersonation time.Duration
+	loginRecorder LoginRecorder
+	loginNotifier LoginNotifier
+	events        events.Publisher
+	userLookups   singleflight.Group
+	passwords     PasswordHasher
+	clock         Clock
+	clockSkew     time.Duration
+
+	registrationEnabled func() bool
+	emailDomains        func() []string
+	breachChecker       BreachChecker
+	maxBreachCount      int
+	emailBlocklist      EmailBlocklist
+	tosRequired         bool
+	tosVersion          int
+	primaryLoginReads   bool
+	passwordMaxAge      time.Duration
+	passwordExpiryMode  string
+	failureDelay        time.Duration
+	failureJitter       time.Duration
+	sleep               func(ctx context.Context, d time.Duration)
+	random              func() float64
+
+	registrations prometheus.Counter
+	failedLogins  prometheus.Counter
+	lockouts      prometheus.Counter
+}
+
+// AuthOption configures optional collaborators of an AuthService.
+type AuthOption func(*AuthService)
+
+// WithLoginRecorder makes the service record every login attempt with recorder.
+func WithLoginRecorder(recorder LoginRecorder) AuthOption {
+	return func(s *AuthService) {
+		s.loginRecorder = recorder
+	}
 }
 
-func NewAuthService(userRepo Repository, config *config.Config) *AuthService {
-	return &AuthService{
-		userRepo:    userRepo,
-		jwtSecret:   []byte(config.JWTSecret),
-		tokenExpiry: config.TokenExpiryDur,
+// WithEventPublisher makes the service publish session events, such as a
+// session being revoked, to publisher.
+func WithEventPublisher(publisher events.Publisher) AuthOption {
+	return func(s *AuthService) {
+		s.events = publisher
 	}
 }
 
+// WithRegistrationDisabled makes Register reject every signup with ErrRegistrationDisabled.
+func WithRegistrationDisabled() AuthOption {
+	return WithRegistrationSwitch(func() bool { return false })
+}
+
+// WithRegistrationSwitch makes Register reject signups with
+// ErrRegistrationDisabled whenever enabled returns false. It is called on
+// every signup, so registration can be turned on and off while the server runs.
+func WithRegistrationSwitch(enabled func() bool) AuthOption {
+	return func(s *AuthService) {
+		s.registrationEnabled = enabled
+	}
+}
+
+// WithEmailDomainAllowlist makes Register reject emails outside the domains
+// returned by domains with ErrEmailDomainNotAllowed; see emailDomainAllowed
+// for how they match. It is called on every signup, so the allowlist can
+// change while the server runs. An empty allowlist allows every domain.
+func WithEmailDomainAllowlist(domains func() []string) AuthOption {
+	return func(s *AuthService) {
+		s.emailDomains = domains
+	}
+}
+
+// WithPasswordHasher makes the service hash and check passwords with hasher,
+// which is typically shared with the other services that check passwords.
+func WithPasswordHasher(hasher PasswordHasher) AuthOption {
+	return func(s *AuthService) {
+		s.passwords = hasher
+	}
+}
+
+// WithEmailBlocklist makes Register reject emails on blocklist with ErrDisposableEmail.
+func WithEmailBlocklist(blocklist EmailBlocklist) AuthOption {
+	return func(s *AuthService) {
+		s.emailBlocklist = blocklist
+	}
+}
+
+// WithPrimaryLoginReads makes Login look the user up on the primary database
+// even when reads go to a replica, so that a password or email address
+// changed moments before is what the login is checked against.
+func WithPrimaryLoginReads() AuthOption {
+	return func(s *AuthService) {
+		s.primaryLoginReads = true
+	}
+}
+
+// WithClock makes the service issue and validate access tokens at the time
+// told by clock rather than the system clock.
+func WithClock(clock Clock) AuthOption {
+	return func(s *AuthService) {
+		s.clock = clock
+	}
+}
+
+func NewAuthService(userRepo Repository, tokenRepo TokenRepository, config *config.Config, opts ...AuthOption) *AuthService {
+	s := &AuthService{
+		userRepo:      userRepo,
+		tokenRepo:     tokenRepo,
+		jwtSecret:     []byte(config.JWTSecret),
+		signingMethod: signingMethod(config.JWTAlgorithm),
+		tokenExpiry:   config.TokenExpiry,
+		refreshExpiry: config.RefreshExpiry,
+		reauthMaxAge:  config.ReauthMaxAge,
+		impersonation: config.ImpersonationExpiry,
+		clockSkew:     config.JWTClockSkew,
+		tosRequired:   config.TOSRequired,
+		tosVersion:    config.TOSVersion,
+
+		passwordMaxAge:     config.PasswordMaxAge,
+		passwordExpiryMode: config.PasswordExpiryMode,
+		failureDelay:       config.LoginFailureDelay,
+		failureJitter:      config.LoginFailureJitter,
+		sleep:              sleepContext,
+		random:             randomFraction,
+
+		loginRecorder: noopLoginRecorder{},
+		loginNotifier: noopLoginNotifier{},
+		events:        events.Discard,
+		passwords:     password.NewPool(bcrypt.DefaultCost, password.DefaultPoolSize()),
+		clock:         SystemClock{},
+		registrations: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "auth_registrations_total",
+			Help: "Users registered.",
+		}),
+		failedLogins: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "auth_failed_logins_total",
+			Help: "Login attempts rejected for an unknown user or a wrong password.",
+		}),
+		lockouts: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "auth_lockouts_total",
+			Help: "Sessions revoked because their refresh token was reused.",
+		}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Describe implements prometheus.Collector.
+func (s *AuthService) Describe(ch chan<- *prometheus.Desc) {
+	s.registrations.Describe(ch)
+	s.failedLogins.Describe(ch)
+	s.lockouts.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (s *AuthService) Collect(ch chan<- prometheus.Metric) {
+	s.registrations.Collect(ch)
+	s.failedLogins.Collect(ch)
+	s.lockouts.Collect(ch)
+}
+
 func (s *AuthService) Register(ctx context.Context, input RegisterInput) (*model.User, error) {
-	existingUser, _ := s.userRepo.FindByEmail(ctx, input.Email)
+	if s.registrationEnabled != nil && !s.registrationEnabled() {
+		return nil, ErrRegistrationDisabled
+	}
+	if s.emailDomains != nil && !emailDomainAllowed(input.Email, s.emailDomains()) {
+		return nil, ErrEmailDomainNotAllowed
+	}
+	if s.emailBlocklist != nil && s.emailBlocklist.Blocked(input.Email) {
+		return nil, ErrDisposableEmail
+	}
+	if s.tosRequired && !input.AcceptedTOS {
+		return nil, ErrTOSNotAccepted
+	}
+
+	locale, timezone, err := registrationLocale(input)
+	if err != nil {
+		return nil, err
+	}
+
+	var username *string
+	if input.Username != "" {
+		normalized := normalizeUsername(input.Username)
+		if err := validateUsername(normalized); err != nil {
+			return nil, err
+		}
+		username = &normalized
+	}
+
+	existingUser, err := s.userRepo.FindByEmail(ctx, input.Email)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return nil, err
+	}
+	if existingUser != nil && existingUser.DeletionRequestedAt != nil {
+		return nil, ErrAccountRecoverable
+	}
 	if existingUser != nil {
-		return nil, errors.New("email already registered")
+		return nil, ErrEmailTaken
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+	if username != nil {
+		if err := ensureUsernameAvailable(ctx, s.userRepo, *username); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.checkBreached(ctx, input.Password); err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := s.passwords.Hash(ctx, input.Password)
 	if err != nil {
-		return nil, errors.New("failed to hash password")
+		return nil, fmt.Errorf("failed to hash password: %w", err)
 	}
 
 	user := &model.User{
 		Email:        input.Email,
-		PasswordHash: string(hashedPassword),
+		PasswordHash: hashedPassword,
 		FullName:     input.FullName,
+		Username:     username,
+		Locale:       locale,
+		Timezone:     timezone,
+
+		PasswordChangedAt: s.clock.Now(),
+	}
+	if input.AcceptedTOS {
+		acceptedAt := s.clock.Now()
+		user.TOSAcceptedVersion = s.tosVersion
+		user.TOSAcceptedAt = &acceptedAt
 	}
 
-	if err := s.userRepo.Create(ctx, user); err != nil {
+	// The email address may have been taken since it was checked above.
+	err = s.userRepo.CreateWithOutbox(ctx, user, newUserRegisteredEvent)
+	if errors.Is(err, repository.ErrDuplicate) {
+		return nil, fmt.Errorf("%w: %w", ErrEmailTaken, err)
+	}
+	if err != nil {
 		return nil, err
 	}
+	s.registrations.Inc()
 
 	return user, nil
 }
 
-func (s *AuthService) Login(ctx context.Context, input LoginInput) (string, error) {
-	user, err := s.userRepo.FindByEmail(ctx, input.Email)
+// UserRegisteredPayload is the body of an EventUserRegistered outbox event.
+type UserRegisteredPayload struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Email     string    `json:"email"`
+	FullName  string    `json:"full_name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newUserRegisteredEvent(user *model.User) (*model.OutboxEvent, error) {
+	payload, err := json.Marshal(UserRegisteredPayload{
+		UserID:    user.ID,
+		Email:     user.Email,
+		FullName:  user.FullName,
+		CreatedAt: user.CreatedAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.OutboxEvent{EventType: model.EventUserRegistered, Payload: payload}, nil
+}
+
+func (s *AuthService) Login(ctx context.Context, input LoginInput) (*TokenPair, error) {
+	if s.primaryLoginReads {
+		ctx = database.WithPrimary(ctx)
+	}
+	user, err := s.findByIdentifier(ctx, input.identifier())
+	if errors.Is(err, repository.ErrNotFound) {
+		// Checking the password anyway makes the attempt take as long as
+		// one with a wrong password, so its timing does not reveal that
+		// the user does not exist.
+		_ = s.passwords.Compare(ctx, dummyPasswordHash, input.Password)
+		s.recordLogin(input, nil, false)
+		s.failedLogins.Inc()
+		s.delayFailure(ctx)
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.PasswordHash == "" {
+		// Imported and invited users have no password until they set one.
+		// Checking against the dummy hash keeps their attempts as slow as
+		// any other, so timing does not reveal which accounts they are.
+		_ = s.passwords.Compare(ctx, dummyPasswordHash, input.Password)
+		s.recordLogin(input, &user.ID, false)
+		s.failedLogins.Inc()
+		s.delayFailure(ctx)
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := checkPassword(ctx, s.passwords, user.PasswordHash, input.Password); err != nil {
+		if errors.Is(err, ErrInvalidCredentials) {
+			s.recordLogin(input, &user.ID, false)
+			s.failedLogins.Inc()
+			s.delayFailure(ctx)
+		}
+		return nil, err
+	}
+
+	// In strict mode an expired password no longer logs the user in, and
+	// they only get a token to change it with.
+	passwordExpired := s.passwordExpired(user)
+	if passwordExpired && s.passwordExpiryMode == config.PasswordExpiryStrict {
+		s.recordLogin(input, &user.ID, false)
+		s.failedLogins.Inc()
+		s.delayFailure(ctx)
+		return nil, s.passwordExpiredError(user)
+	}
+
+	// Logging in during the grace period of an erasure request cancels it.
+	if user.DeletionRequestedAt != nil {
+		if err := s.userRepo.CancelDeletion(ctx, user.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	familyID := uuid.New()
+	session := &model.RefreshToken{
+		FamilyID:    familyID,
+		DeviceLabel: device.Label(input.UserAgent),
+		IPAddress:   device.TruncateIP(input.IPAddress),
+		DeviceHash:  device.Fingerprint(input.UserAgent, input.IPAddress),
+	}
+	tokens, err := s.issueTokens(ctx, user, session, nil, s.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+	tokens.PasswordExpired = passwordExpired
+	s.recordLogin(input, &user.ID, true)
+	s.loginNotifier.NotifyLogin(*user, *session)
+	s.events.Publish(user.ID.String(), events.Event{
+		Type: events.TypeSessionCreated,
+		Data: map[string]string{"session_id": familyID.String()},
+	})
+
+	// Failing to record the login time must not fail an otherwise valid login.
+	if err := s.userRepo.UpdateLastLogin(ctx, user.ID, s.clock.Now()); err != nil {
+		slog.WarnContext(ctx, "failed to update last login time", "user_id", user.ID, "error", err)
+	}
+
+	return tokens, nil
+}
+
+// identifier returns the email address or username the login attempt names.
+func (input LoginInput) identifier() string {
+	if input.Identifier != "" {
+		return input.Identifier
+	}
+	return input.Email
+}
+
+// findByIdentifier looks a user up by email address or, if identifier is not
+// an email address, by username.
+func (s *AuthService) findByIdentifier(ctx context.Context, identifier string) (*model.User, error) {
+	if isEmailIdentifier(identifier) {
+		return s.userRepo.FindByEmail(ctx, identifier)
+	}
+	return s.userRepo.FindByUsername(ctx, normalizeUsername(identifier))
+}
+
+// ChangePassword replaces the password of the user with userID after checking
+// their current password, which must match or ErrInvalidCredentials is returned.
+// The new password is subject to the same breach check as registration.
+func (s *AuthService) ChangePassword(ctx context.Context, userID string, input ChangePasswordInput) error {
+	// The password is read from the primary, never from a cache or a
+	// replica that could still have the previous one.
+	user, err := s.userRepo.FindByIDWithPassword(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := checkPassword(ctx, s.passwords, user.PasswordHash, input.CurrentPassword); err != nil {
+		return err
+	}
+
+	if err := s.checkBreached(ctx, input.NewPassword); err != nil {
+		return err
+	}
+
+	hashedPassword, err := s.passwords.Hash(ctx, input.NewPassword)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	return s.userRepo.UpdatePassword(ctx, user.ID, hashedPassword, s.clock.Now())
+}
+
+// ResetPassword replaces the password of the user with userID without
+// checking their current one, for flows where the user proved who they are
+// some other way, and signs them out of every device. The new password is
+// subject to the same breach check as registration.
+func (s *AuthService) ResetPassword(ctx context.Context, userID uuid.UUID, newPassword string) error {
+	if err := s.checkBreached(ctx, newPassword); err != nil {
+		return err
+	}
+
+	hashedPassword, err := s.passwords.Hash(ctx, newPassword)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	if err := s.userRepo.UpdatePassword(ctx, userID, hashedPassword, s.clock.Now()); err != nil {
+		return err
+	}
+	return s.LogoutAll(ctx, userID.String())
+}
+
+// HashNewPassword checks that password meets the password policy, which
+// the bindings of forms that set a password enforce in part, and returns its
+// hash. It is for passwords chosen outside those forms, such as in the
+// configuration. It returns ErrPasswordTooShort for a password of fewer than
+// MinPasswordLength characters and ErrPasswordBreached for a breached one.
+func (s *AuthService) HashNewPassword(ctx context.Context, password string) (string, error) {
+	if utf8.RuneCountInString(password) < MinPasswordLength {
+		return "", ErrPasswordTooShort
+	}
+	if err := s.checkBreached(ctx, password); err != nil {
+		return "", err
+	}
+
+	hashedPassword, err := s.passwords.Hash(ctx, password)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return hashedPassword, nil
+}
+
+// checkPassword checks password against hash with hasher. It returns
+// ErrInvalidCredentials, wrapping the cause, if they do not match, and the
+// error of ctx unchanged if ctx is done before the check could run.
+func checkPassword(ctx context.Context, hasher PasswordHasher, hash, password string) error {
+	err := hasher.Compare(ctx, hash, password)
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+}
+
+// recordLogin hands the outcome of a login attempt to the login recorder.
+// userID is nil when the attempted identifier does not belong to any user.
+func (s *AuthService) recordLogin(input LoginInput, userID *uuid.UUID, success bool) {
+	s.loginRecorder.Record(&model.LoginEvent{
+		UserID:    userID,
+		Email:     input.identifier(),
+		Success:   success,
+		IPAddress: input.IPAddress,
+		UserAgent: input.UserAgent,
+		CreatedAt: s.clock.Now(),
+	})
+}
+
+// Refresh exchanges a refresh token for a new access token and a rotated refresh token.
+// The presented token is single-use: if it has already been rotated, it is being
+// replayed, most likely by someone who stole it, so its entire family is revoked,
+// forcing both the attacker and the legitimate user to log in again, and
+// ErrTokenReuseDetected is returned.
+func (s *AuthService) Refresh(ctx context.Context, input RefreshInput) (*TokenPair, error) {
+	current, err := s.tokenRepo.FindByHash(ctx, hashToken(input.RefreshToken))
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRefreshToken, err)
+	}
 	if err != nil {
-		return "", errors.New("invalid credentials")
+		return nil, err
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(input.Password)); err != nil {
-		return "", errors.New("invalid credentials")
+	if current.RevokedAt != nil || !s.clock.Now().Before(current.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
 	}
 
-	return s.generateToken(user)
+	if current.RotatedAt != nil {
+		return nil, s.revokeReusedFamily(ctx, current.UserID, current.FamilyID)
+	}
+
+	user, err := s.userRepo.FindByID(ctx, current.UserID.String())
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRefreshToken, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The refreshed access token does not carry the original login time, so
+	// sensitive operations need a fresh password check after a refresh.
+	// The rotated token keeps describing the device the session started on.
+	session := &model.RefreshToken{
+		FamilyID:    current.FamilyID,
+		DeviceLabel: current.DeviceLabel,
+		Name:        current.Name,
+		IPAddress:   current.IPAddress,
+		Location:    current.Location,
+		DeviceHash:  current.DeviceHash,
+	}
+	return s.issueTokens(ctx, user, session, current, time.Time{})
 }
 
-func (s *AuthService) generateToken(user *model.User) (string, error) {
+func (s *AuthService) revokeReusedFamily(ctx context.Context, userID, familyID uuid.UUID) error {
+	if err := s.tokenRepo.RevokeFamily(ctx, familyID); err != nil {
+		return err
+	}
+	s.lockouts.Inc()
+	s.events.Publish(userID.String(), events.Event{
+		Type: events.TypeSessionRevoked,
+		Data: map[string]string{"session_id": familyID.String()},
+	})
+	return ErrTokenReuseDetected
+}
+
+// issueTokens creates an access token and a refresh token for the session
+// described by record, which holds the family and device of the new token and
+// is completed and stored by issueTokens. When parent is nil the refresh
+// token starts a new family, otherwise parent is rotated into the new token.
+// authTime is when the user entered their password, or zero if they did not
+// just do so.
+func (s *AuthService) issueTokens(ctx context.Context, user *model.User, record *model.RefreshToken, parent *model.RefreshToken, authTime time.Time) (*TokenPair, error) {
+	refreshToken, err := generateOpaqueToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+
+	record.UserID = user.ID
+	record.TokenHash = hashToken(refreshToken)
+	record.ExpiresAt = s.clock.Now().Add(s.refreshExpiry)
+
+	if parent == nil {
+		err = s.tokenRepo.Create(ctx, record)
+	} else {
+		record.ParentID = &parent.ID
+		err = s.tokenRepo.Rotate(ctx, parent.ID, record)
+		if errors.Is(err, repository.ErrTokenAlreadyRotated) {
+			return nil, s.revokeReusedFamily(ctx, user.ID, record.FamilyID)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	opts := tokenOptions{
+		familyID: record.FamilyID,
+		scopes:   ScopesForRole(user.Role),
+		authTime: authTime,
+		expiry:   s.tokenExpiry,
+	}
+	accessToken, err := s.generateToken(user, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:      accessToken,
+		RefreshToken:     refreshToken,
+		ExpiresIn:        opts.expiry,
+		RefreshExpiresIn: s.refreshExpiry,
+		User:             user,
+	}, nil
+}
+
+// IssueScopedToken creates an access token for the user with userID that
+// carries only the scopes in input, for handing to scripts or other services
+// that should not hold the user's full access. Every requested scope must be
+// both in granted, the scopes of the token the caller authenticated with, and
+// in the scopes the user's role currently grants, so a scoped token can never
+// do more than the token it was created from. The token is not tied to a
+// session and cannot be refreshed.
+func (s *AuthService) IssueScopedToken(ctx context.Context, userID string, granted []string, input ScopedTokenInput) (string, error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	allowed := slices.DeleteFunc(ScopesForRole(user.Role), func(scope string) bool {
+		return !slices.Contains(granted, scope)
+	})
+	if err := ValidateScopes(input.Scopes, allowed); err != nil {
+		return "", err
+	}
+
+	scopes := slices.Clone(input.Scopes)
+	slices.Sort(scopes)
+	return s.generateToken(user, tokenOptions{scopes: slices.Compact(scopes)})
+}
+
+// Logout ends the session of the refresh token in input by revoking it and
+// every other refresh token from the same login. Access tokens already issued
+// stay valid until they expire. Logging out of a session that was already
+// revoked or has expired succeeds; an unknown token is ErrInvalidRefreshToken.
+func (s *AuthService) Logout(ctx context.Context, input RefreshInput) error {
+	current, err := s.tokenRepo.FindByHash(ctx, hashToken(input.RefreshToken))
+	if errors.Is(err, repository.ErrNotFound) {
+		return fmt.Errorf("%w: %w", ErrInvalidRefreshToken, err)
+	}
+	if err != nil {
+		return err
+	}
+	if current.RevokedAt != nil || !s.clock.Now().Before(current.ExpiresAt) {
+		return nil
+	}
+
+	if err := s.tokenRepo.RevokeFamily(ctx, current.FamilyID); err != nil {
+		return err
+	}
+	s.events.Publish(current.UserID.String(), events.Event{
+		Type: events.TypeSessionRevoked,
+		Data: map[string]string{"session_id": current.FamilyID.String()},
+	})
+	return nil
+}
+
+// LogoutAll signs the user with userID out of every device. Incrementing their
+// token version invalidates all access tokens issued so far, and revoking
+// their refresh tokens keeps those from being exchanged for new ones. The
+// session.revoked event it publishes has no session_id, as it covers them all.
+func (s *AuthService) LogoutAll(ctx context.Context, userID string) error {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return ErrInvalidUserID
+	}
+
+	if err := s.userRepo.IncrementTokenVersion(ctx, id); err != nil {
+		return err
+	}
+	if err := s.tokenRepo.RevokeAllForUser(ctx, id); err != nil {
+		return err
+	}
+	s.events.Publish(userID, events.Event{Type: events.TypeSessionRevoked})
+	return nil
+}
+
+// CheckTokenVersion returns ErrTokenRevoked unless version, the "ver" claim
+// of an access token, is the current token version of the user with userID.
+// Tokens of users that no longer exist are revoked as well.
+func (s *AuthService) CheckTokenVersion(ctx context.Context, userID string, version int) error {
+	// A replica lagging behind would accept tokens revoked moments ago.
+	user, err := s.userRepo.FindByID(database.WithPrimary(ctx), userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrTokenRevoked
+	}
+	if err != nil {
+		return err
+	}
+	if user.TokenVersion != version {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// Reauth checks the password of the user with userID again and returns an
+// elevated access token, which proves a recent password entry to routes
+// guarded by middleware.RequireRecentAuth. It carries the scopes in granted,
+// the scopes of the token the caller authenticated with, that the user's role
+// still grants. As it is not tied to a session, it expires after the
+// re-authentication window instead of the usual access token lifetime.
+func (s *AuthService) Reauth(ctx context.Context, userID string, granted []string, input ReauthInput) (string, error) {
+	// The password is read from the primary, never from a cache or a
+	// replica that could still have the previous one.
+	user, err := s.userRepo.FindByIDWithPassword(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	if err := checkPassword(ctx, s.passwords, user.PasswordHash, input.Password); err != nil {
+		return "", err
+	}
+
+	scopes := slices.DeleteFunc(ScopesForRole(user.Role), func(scope string) bool {
+		return !slices.Contains(granted, scope)
+	})
+	return s.generateToken(user, tokenOptions{
+		scopes:   scopes,
+		authTime: s.clock.Now(),
+		expiry:   s.reauthMaxAge,
+	})
+}
+
+// Impersonate creates an access token that lets the admin with actorID act as
+// the user with userID, so support staff can see what the user sees. The token
+// names the admin in its "act" claim, which gets every request made with it
+// audited and keeps it from admin and destructive routes. It is not tied to a
+// session, cannot be refreshed and expires after the impersonation expiry.
+func (s *AuthService) Impersonate(ctx context.Context, actorID, userID string) (string, error) {
+	if _, err := uuid.Parse(userID); err != nil {
+		return "", ErrInvalidUserID
+	}
+
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return "", fmt.Errorf("%w: %w", ErrUserNotFound, err)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return s.generateToken(user, tokenOptions{
+		scopes:  ScopesForRole(user.Role),
+		actorID: actorID,
+		expiry:  s.impersonation,
+	})
+}
+
+// tokenOptions describes an access token beyond the identity of its user.
+type tokenOptions struct {
+	// familyID is the session the token belongs to, or uuid.Nil for none.
+	familyID uuid.UUID
+	scopes   []string
+	// authTime is when the user last entered their password, or zero if the
+	// token does not prove a recent password entry.
+	authTime time.Time
+	// expiry is the token lifetime, or zero for the configured default.
+	expiry time.Duration
+	// actorID is the admin acting as the user, or empty if the user is
+	// acting themselves.
+	actorID string
+}
+
+// generateToken signs an access token for user as described by opts. The
+// locale and zoneinfo claims, named as in OpenID Connect, let clients format
+// dates for the user without fetching the profile.
+func (s *AuthService) generateToken(user *model.User, opts tokenOptions) (string, error) {
+	expiry := opts.expiry
+	if expiry == 0 {
+		expiry = s.tokenExpiry
+	}
+
+	now := s.clock.Now()
 	claims := jwt.MapClaims{
-		"user_id": user.ID.String(),
-		"email":   user.Email,
-		"exp":     time.Now().Add(s.tokenExpiry).Unix(),
+		"sub":      user.ID.String(),
+		"user_id":  user.ID.String(),
+		"email":    user.Email,
+		"role":     user.Role,
+		"ver":      user.TokenVersion,
+		"locale":   user.Locale,
+		"zoneinfo": user.Timezone,
+		"scopes":   opts.scopes,
+		"iat":      now.Unix(),
+		"exp":      now.Add(expiry).Unix(),
+	}
+	if opts.familyID != uuid.Nil {
+		claims["sid"] = opts.familyID.String()
+	}
+	if !opts.authTime.IsZero() {
+		claims["auth_time"] = opts.authTime.Unix()
+		claims["amr"] = []string{"pwd"}
+	}
+	if opts.actorID != "" {
+		claims["act"] = map[string]string{"sub": opts.actorID}
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token := jwt.NewWithClaims(s.signingMethod, claims)
 	return token.SignedString(s.jwtSecret)
 }
 
+// signingMethod returns the HMAC method access tokens are signed with for the
+// JWT_ALGORITHM alg, HS256 if alg is empty.
+func signingMethod(alg string) jwt.SigningMethod {
+	if method, ok := jwt.GetSigningMethod(alg).(*jwt.SigningMethodHMAC); ok {
+		return method
+	}
+	return jwt.SigningMethodHS256
+}
+
+// Introspect reports whether an access token is currently active and who it belongs to.
+// A token is inactive if it is malformed, has a bad signature, has expired, belongs
+// to a session whose refresh tokens were revoked, or was issued before its user
+// signed out everywhere. Errors are only returned when the revocation state cannot
+// be determined.
+func (s *AuthService) Introspect(ctx context.Context, tokenString string) (*Introspection, error) {
+	inactive := &Introspection{Active: false}
+
+	claims, err := s.parseToken(tokenString)
+	if err != nil {
+		slog.DebugContext(ctx, "introspected token is inactive", "error", err)
+		return inactive, nil
+	}
+
+	userID, _ := claims["user_id"].(string)
+	email, _ := claims["email"].(string)
+	if userID == "" || email == "" {
+		return inactive, nil
+	}
+
+	if sid, _ := claims["sid"].(string); sid != "" {
+		familyID, err := uuid.Parse(sid)
+		if err != nil {
+			return inactive, nil
+		}
+
+		revoked, err := s.tokenRepo.IsFamilyRevoked(ctx, familyID)
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			return inactive, nil
+		}
+	}
+
+	version, _ := claims["ver"].(float64)
+	err = s.CheckTokenVersion(ctx, userID, int(version))
+	if errors.Is(err, ErrTokenRevoked) {
+		return inactive, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	exp, _ := claims["exp"].(float64)
+	iat, _ := claims["iat"].(float64)
+
+	return &Introspection{
+		Active:    true,
+		Subject:   userID,
+		Email:     email,
+		ExpiresAt: int64(exp),
+		IssuedAt:  int64(iat),
+	}, nil
+}
+
+// parseToken verifies the signature and expiry of an access token, allowing
+// for the configured clock skew, and returns its claims. Only tokens signed
+// with the configured algorithm are accepted. Any failure is
+// returned as ErrTokenInvalid wrapping the reason.
+func (s *AuthService) parseToken(tokenString string) (jwt.MapClaims, error) {
+	parser := jwt.NewParser(jwt.WithoutClaimsValidation(), jwt.WithValidMethods([]string{s.signingMethod.Alg()}))
+	token, err := parser.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return s.jwtSecret, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrTokenInvalid, err)
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, ErrTokenInvalid
+	}
+	if err := CheckTokenTimes(claims, s.clock.Now(), s.clockSkew); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrTokenInvalid, err)
+	}
+	return claims, nil
+}
+
+// generateOpaqueToken returns a random URL-safe token, such as a refresh token
+// or an email confirmation token, for which only hashToken's output is stored.
+func generateOpaqueToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashToken returns the hex encoded SHA-256 hash of token.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GetUserByID looks up a user by ID. Concurrent lookups of the same ID share a
+// single repository call; each caller receives its own copy of the result.
+// The shared call is detached from any one caller's cancellation so that a
+// caller giving up does not fail the others, while each caller still returns
+// as soon as its own context is done.
 func (s *AuthService) GetUserByID(ctx context.Context, id string) (*model.User, error) {
-	return s.userRepo.FindByID(ctx, id)
+	result := s.userLookups.DoChan(id, func() (interface{}, error) {
+		return s.userRepo.FindByID(context.WithoutCancel(ctx), id)
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-result:
+		if errors.Is(res.Err, repository.ErrNotFound) {
+			return nil, fmt.Errorf("%w: %w", ErrUserNotFound, res.Err)
+		}
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(*model.User).Clone(), nil
+	}
 }
@@ -2,15 +2,25 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"testing"
 	"time"
 
 	"github.com/PakornBank/learn-go/internal/config"
+	"github.com/PakornBank/learn-go/internal/database"
+	"github.com/PakornBank/learn-go/internal/device"
+	"github.com/PakornBank/learn-go/internal/events"
 	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/password"
+	"github.com/PakornBank/learn-go/internal/repository"
 	"github.com/PakornBank/learn-go/internal/testutil"
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	promtest "github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
@@ -24,6 +34,17 @@ func (r *MockRepository) Create(ctx context.Context, user *model.User) error {
 	return args.Error(0)
 }
 
+// CreateWithOutbox records the call and, unless an error is configured, builds the
+// outbox event the way the real repository would after inserting the user.
+func (r *MockRepository) CreateWithOutbox(ctx context.Context, user *model.User, newEvent func(*model.User) (*model.OutboxEvent, error)) error {
+	args := r.Called(ctx, user)
+	if err := args.Error(0); err != nil {
+		return err
+	}
+	_, err := newEvent(user)
+	return err
+}
+
 func (r *MockRepository) FindByEmail(ctx context.Context, email string) (*model.User, error) {
 	args := r.Called(ctx, email)
 	if args.Get(0) == nil {
@@ -32,6 +53,14 @@ func (r *MockRepository) FindByEmail(ctx context.Context, email string) (*model.
 	return args.Get(0).(*model.User), args.Error(1)
 }
 
+func (r *MockRepository) FindByUsername(ctx context.Context, username string) (*model.User, error) {
+	args := r.Called(ctx, username)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
 func (r *MockRepository) FindByID(ctx context.Context, id string) (*model.User, error) {
 	args := r.Called(ctx, id)
 	if args.Get(0) == nil {
@@ -40,39 +69,194 @@ func (r *MockRepository) FindByID(ctx context.Context, id string) (*model.User,
 	return args.Get(0).(*model.User), args.Error(1)
 }
 
-func setupTest() (*AuthService, *MockRepository) {
-	mockRepo := new(MockRepository)
-	config := &config.Config{
-		JWTSecret:      "test-secret",
-		TokenExpiryDur: time.Hour * 24,
+func (r *MockRepository) FindByIDWithPassword(ctx context.Context, id string) (*model.User, error) {
+	args := r.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+func (r *MockRepository) UpdateLastLogin(ctx context.Context, id uuid.UUID, at time.Time) error {
+	args := r.Called(ctx, id, at)
+	return args.Error(0)
+}
+
+func (r *MockRepository) UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string, changedAt time.Time) error {
+	args := r.Called(ctx, id, passwordHash, changedAt)
+	return args.Error(0)
+}
+
+func (r *MockRepository) CancelDeletion(ctx context.Context, id uuid.UUID) error {
+	args := r.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (r *MockRepository) IncrementTokenVersion(ctx context.Context, id uuid.UUID) error {
+	args := r.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (r *MockRepository) AcceptTOS(ctx context.Context, id uuid.UUID, version int, at time.Time) error {
+	args := r.Called(ctx, id, version, at)
+	return args.Error(0)
+}
+
+func (r *MockRepository) UpdateLocale(ctx context.Context, id uuid.UUID, locale, timezone string) error {
+	args := r.Called(ctx, id, locale, timezone)
+	return args.Error(0)
+}
+
+func (r *MockRepository) UpdateEmail(ctx context.Context, id uuid.UUID, email string) error {
+	args := r.Called(ctx, id, email)
+	return args.Error(0)
+}
+
+func (r *MockRepository) ListPage(ctx context.Context, offset, limit int, sort string, desc bool) ([]model.User, int64, error) {
+	args := r.Called(ctx, offset, limit, sort, desc)
+	if args.Get(0) == nil {
+		return nil, args.Get(1).(int64), args.Error(2)
+	}
+	return args.Get(0).([]model.User), args.Get(1).(int64), args.Error(2)
+}
+
+func (r *MockRepository) Activity(ctx context.Context, id uuid.UUID, limit int) (*repository.UserActivity, error) {
+	args := r.Called(ctx, id, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.UserActivity), args.Error(1)
+}
+
+func (r *MockRepository) FindByRole(ctx context.Context, role string) ([]model.User, error) {
+	args := r.Called(ctx, role)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.User), args.Error(1)
+}
+
+type MockTokenRepository struct {
+	mock.Mock
+}
+
+func (r *MockTokenRepository) Create(ctx context.Context, token *model.RefreshToken) error {
+	args := r.Called(ctx, token)
+	return args.Error(0)
+}
+
+func (r *MockTokenRepository) FindByHash(ctx context.Context, tokenHash string) (*model.RefreshToken, error) {
+	args := r.Called(ctx, tokenHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.RefreshToken), args.Error(1)
+}
+
+func (r *MockTokenRepository) Rotate(ctx context.Context, oldID uuid.UUID, next *model.RefreshToken) error {
+	args := r.Called(ctx, oldID, next)
+	return args.Error(0)
+}
+
+func (r *MockTokenRepository) RevokeFamily(ctx context.Context, familyID uuid.UUID) error {
+	args := r.Called(ctx, familyID)
+	return args.Error(0)
+}
+
+func (r *MockTokenRepository) IsFamilyRevoked(ctx context.Context, familyID uuid.UUID) (bool, error) {
+	args := r.Called(ctx, familyID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (r *MockTokenRepository) RevokeAllForUser(ctx context.Context, userID uuid.UUID) error {
+	args := r.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func newTestConfig() *config.Config {
+	return &config.Config{
+		JWTSecret:     "test-secret",
+		JWTAlgorithm:  "HS256",
+		TokenExpiry:   time.Hour * 24,
+		RefreshExpiry: time.Hour * 24 * 7,
+		ReauthMaxAge:  time.Minute * 5,
+
+		ImpersonationExpiry: time.Minute * 15,
 	}
-	service := NewAuthService(mockRepo, config)
-	return service, mockRepo
+}
+
+// newTestAuthService creates an AuthService with the test configuration and
+// opts that hashes passwords with testutil.FastHasher rather than bcrypt.
+func newTestAuthService(userRepo Repository, tokenRepo TokenRepository, opts ...AuthOption) *AuthService {
+	opts = append([]AuthOption{WithPasswordHasher(testutil.FastHasher{})}, opts...)
+	return NewAuthService(userRepo, tokenRepo, newTestConfig(), opts...)
+}
+
+func setupTest() (*AuthService, *MockRepository, *MockTokenRepository) {
+	mockRepo := new(MockRepository)
+	mockTokenRepo := new(MockTokenRepository)
+	service := newTestAuthService(mockRepo, mockTokenRepo)
+	return service, mockRepo, mockTokenRepo
 }
 
 func TestNewAuthService(t *testing.T) {
 	mockRepo := new(MockRepository)
-	config := &config.Config{
-		JWTSecret:      "test-secret",
-		TokenExpiryDur: time.Hour * 24,
-	}
-	authService := NewAuthService(mockRepo, config)
+	mockTokenRepo := new(MockTokenRepository)
+	config := newTestConfig()
+	authService := NewAuthService(mockRepo, mockTokenRepo, config)
 
 	assert.NotNil(t, authService)
 	assert.Equal(t, mockRepo, authService.userRepo)
+	assert.Equal(t, mockTokenRepo, authService.tokenRepo)
 	assert.Equal(t, []byte(config.JWTSecret), authService.jwtSecret)
-	assert.Equal(t, config.TokenExpiryDur, authService.tokenExpiry)
+	assert.Equal(t, config.TokenExpiry, authService.tokenExpiry)
+	assert.Equal(t, config.RefreshExpiry, authService.refreshExpiry)
+	assert.Equal(t, config.ReauthMaxAge, authService.reauthMaxAge)
+	assert.Equal(t, config.ImpersonationExpiry, authService.impersonation)
+	assert.Equal(t, noopLoginRecorder{}, authService.loginRecorder)
+	assert.IsType(t, &password.Pool{}, authService.passwords)
+}
+
+func TestAuthService_PublishesSessionEvents(t *testing.T) {
+	mockUser := testutil.NewMockUser(testutil.WithPassword("password"))
+
+	mockRepo := new(MockRepository)
+	mockTokenRepo := new(MockTokenRepository)
+	hub := events.NewHub(events.DefaultBufferSize, events.DefaultReplaySize)
+	service := newTestAuthService(mockRepo, mockTokenRepo, WithEventPublisher(hub))
+	sub := hub.Subscribe(mockUser.ID.String())
+	defer sub.Close()
+
+	var familyID uuid.UUID
+	mockRepo.On("FindByEmail", mock.Anything, mockUser.Email).Return(&mockUser, nil)
+	mockRepo.On("UpdateLastLogin", mock.Anything, mockUser.ID, mock.Anything).Return(nil)
+	mockTokenRepo.On("Create", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		familyID = args.Get(1).(*model.RefreshToken).FamilyID
+	}).Return(nil)
+	_, err := service.Login(context.Background(), LoginInput{Email: mockUser.Email, Password: "password"})
+	require.NoError(t, err)
+
+	created := <-sub.Events()
+	assert.Equal(t, events.TypeSessionCreated, created.Type)
+	assert.Equal(t, map[string]string{"session_id": familyID.String()}, created.Data)
+
+	mockRepo.On("IncrementTokenVersion", mock.Anything, mockUser.ID).Return(nil)
+	mockTokenRepo.On("RevokeAllForUser", mock.Anything, mockUser.ID).Return(nil)
+	require.NoError(t, service.LogoutAll(context.Background(), mockUser.ID.String()))
+
+	revoked := <-sub.Events()
+	assert.Equal(t, events.TypeSessionRevoked, revoked.Type)
+	assert.Empty(t, revoked.Data, "every session was revoked")
 }
 
 func TestAuthService_Register(t *testing.T) {
 	mockUser := testutil.NewMockUser()
 
 	tests := []struct {
-		name        string
-		input       RegisterInput
-		mockFn      func(*MockRepository)
-		wantErr     bool
-		errContains string
+		name    string
+		input   RegisterInput
+		mockFn  func(*MockRepository)
+		wantErr error
 	}{
 		{
 			name: "successful registration",
@@ -82,10 +266,74 @@ func TestAuthService_Register(t *testing.T) {
 				FullName: mockUser.FullName,
 			},
 			mockFn: func(repo *MockRepository) {
-				repo.On("FindByEmail", mock.Anything, mockUser.Email).Return(nil, gorm.ErrRecordNotFound)
-				repo.On("Create", mock.Anything, mock.AnythingOfType("*model.User")).Return(nil)
+				repo.On("FindByEmail", mock.Anything, mockUser.Email).Return(nil, repository.ErrNotFound)
+				repo.On("CreateWithOutbox", mock.Anything, mock.AnythingOfType("*model.User")).Return(nil)
+			},
+		},
+		{
+			name: "successful registration with username",
+			input: RegisterInput{
+				Email:    mockUser.Email,
+				Password: "password",
+				FullName: mockUser.FullName,
+				Username: "Tester_1",
+			},
+			mockFn: func(repo *MockRepository) {
+				repo.On("FindByEmail", mock.Anything, mockUser.Email).Return(nil, repository.ErrNotFound)
+				repo.On("FindByUsername", mock.Anything, "tester_1").Return(nil, repository.ErrNotFound)
+				repo.On("CreateWithOutbox", mock.Anything, mock.MatchedBy(func(user *model.User) bool {
+					return user.Username != nil && *user.Username == "tester_1"
+				})).Return(nil)
+			},
+		},
+		{
+			name: "username taken",
+			input: RegisterInput{
+				Email:    mockUser.Email,
+				Password: "password",
+				FullName: mockUser.FullName,
+				Username: "tester",
+			},
+			mockFn: func(repo *MockRepository) {
+				repo.On("FindByEmail", mock.Anything, mockUser.Email).Return(nil, repository.ErrNotFound)
+				repo.On("FindByUsername", mock.Anything, "tester").Return(&mockUser, nil)
+			},
+			wantErr: ErrUsernameTaken,
+		},
+		{
+			name: "invalid username",
+			input: RegisterInput{
+				Email:    mockUser.Email,
+				Password: "password",
+				FullName: mockUser.FullName,
+				Username: "no spaces",
+			},
+			mockFn:  func(repo *MockRepository) {},
+			wantErr: ErrInvalidUsername,
+		},
+		{
+			name: "reserved username",
+			input: RegisterInput{
+				Email:    mockUser.Email,
+				Password: "password",
+				FullName: mockUser.FullName,
+				Username: "Admin",
+			},
+			mockFn:  func(repo *MockRepository) {},
+			wantErr: ErrUsernameReserved,
+		},
+		{
+			name: "transaction failure",
+			input: RegisterInput{
+				Email:    mockUser.Email,
+				Password: "password",
+				FullName: mockUser.FullName,
+			},
+			mockFn: func(repo *MockRepository) {
+				repo.On("FindByEmail", mock.Anything, mockUser.Email).Return(nil, repository.ErrNotFound)
+				repo.On("CreateWithOutbox", mock.Anything, mock.AnythingOfType("*model.User")).Return(gorm.ErrInvalidTransaction)
 			},
-			wantErr: false,
+			wantErr: gorm.ErrInvalidTransaction,
 		},
 		{
 			name: "email already exists",
@@ -97,54 +345,297 @@ func TestAuthService_Register(t *testing.T) {
 			mockFn: func(repo *MockRepository) {
 				repo.On("FindByEmail", mock.Anything, mockUser.Email).Return(&mockUser, nil)
 			},
-			wantErr:     true,
-			errContains: "email already registered",
+			wantErr: ErrEmailTaken,
+		},
+		{
+			name: "email of an account scheduled for deletion",
+			input: RegisterInput{
+				Email:    mockUser.Email,
+				Password: "password",
+				FullName: mockUser.FullName,
+			},
+			mockFn: func(repo *MockRepository) {
+				deleted := testutil.NewMockUser(testutil.WithID(mockUser.ID), testutil.WithDeleted(time.Now().Add(-time.Hour)))
+				repo.On("FindByEmail", mock.Anything, mockUser.Email).Return(&deleted, nil)
+			},
+			wantErr: ErrAccountRecoverable,
+		},
+		{
+			name: "database timeout",
+			input: RegisterInput{
+				Email:    mockUser.Email,
+				Password: "password",
+				FullName: mockUser.FullName,
+			},
+			mockFn: func(repo *MockRepository) {
+				repo.On("FindByEmail", mock.Anything, mockUser.Email).Return(nil, repository.ErrTimeout)
+			},
+			wantErr: repository.ErrTimeout,
+		},
+		{
+			name: "database unavailable",
+			input: RegisterInput{
+				Email:    mockUser.Email,
+				Password: "password",
+				FullName: mockUser.FullName,
+			},
+			mockFn: func(repo *MockRepository) {
+				repo.On("FindByEmail", mock.Anything, mockUser.Email).Return(nil, repository.ErrConn)
+			},
+			wantErr: repository.ErrConn,
+		},
+		{
+			name: "email registered concurrently",
+			input: RegisterInput{
+				Email:    mockUser.Email,
+				Password: "password",
+				FullName: mockUser.FullName,
+			},
+			mockFn: func(repo *MockRepository) {
+				repo.On("FindByEmail", mock.Anything, mockUser.Email).Return(nil, repository.ErrNotFound)
+				repo.On("CreateWithOutbox", mock.Anything, mock.AnythingOfType("*model.User")).Return(repository.ErrDuplicate)
+			},
+			wantErr: ErrEmailTaken,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			service, mockRepo := setupTest()
+			service, mockRepo, _ := setupTest()
 			tt.mockFn(mockRepo)
 			user, err := service.Register(context.Background(), tt.input)
 
-			if tt.wantErr {
-				assert.Error(t, err)
-				assert.Equal(t, tt.errContains, err.Error())
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
 				assert.Nil(t, user)
 			} else {
 				assert.NoError(t, err)
 				assert.NotNil(t, user)
 				assert.Equal(t, tt.input.Email, user.Email)
 				assert.Equal(t, tt.input.FullName, user.FullName)
+				assert.Nil(t, user.LastLoginAt)
 			}
 			mockRepo.AssertExpectations(t)
 		})
 	}
 }
 
+func TestAuthService_RegisterDisabled(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := newTestAuthService(mockRepo, new(MockTokenRepository), WithRegistrationDisabled())
+
+	user, err := service.Register(context.Background(), RegisterInput{
+		Email:    "test@example.com",
+		Password: "password",
+		FullName: "Test User",
+	})
+
+	assert.ErrorIs(t, err, ErrRegistrationDisabled)
+	assert.Nil(t, user)
+	mockRepo.AssertNotCalled(t, "FindByEmail", mock.Anything, mock.Anything)
+}
+
+func TestAuthService_RegisterSwitch(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockRepo.On("FindByEmail", mock.Anything, "test@example.com").Return(&model.User{}, nil)
+	enabled := false
+	service := newTestAuthService(mockRepo, new(MockTokenRepository),
+		WithRegistrationSwitch(func() bool { return enabled }))
+	input := RegisterInput{Email: "test@example.com", Password: "password", FullName: "Test User"}
+
+	_, err := service.Register(context.Background(), input)
+	assert.ErrorIs(t, err, ErrRegistrationDisabled)
+	mockRepo.AssertNotCalled(t, "FindByEmail", mock.Anything, mock.Anything)
+
+	enabled = true
+	_, err = service.Register(context.Background(), input)
+	assert.ErrorIs(t, err, ErrEmailTaken)
+	mockRepo.AssertExpectations(t)
+}
+
+type fakeBlocklist map[string]bool
+
+func (f fakeBlocklist) Blocked(email string) bool { return f[email] }
+
+func TestAuthService_RegisterBlockedEmail(t *testing.T) {
+	mockRepo := new(MockRepository)
+	blocklist := fakeBlocklist{"user@mailinator.com": true}
+	service := newTestAuthService(mockRepo, new(MockTokenRepository), WithEmailBlocklist(blocklist))
+
+	user, err := service.Register(context.Background(), RegisterInput{
+		Email:    "user@mailinator.com",
+		Password: "password",
+		FullName: "Test User",
+	})
+
+	assert.ErrorIs(t, err, ErrDisposableEmail)
+	assert.Nil(t, user)
+	mockRepo.AssertNotCalled(t, "FindByEmail", mock.Anything, mock.Anything)
+}
+
+func TestAuthService_RegisterEmailDomainAllowlist(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	mockRepo := new(MockRepository)
+	mockRepo.On("FindByEmail", mock.Anything, "user@corp.com").Return(nil, repository.ErrNotFound)
+	mockRepo.On("CreateWithOutbox", mock.Anything, mock.AnythingOfType("*model.User")).Return(nil)
+	allowed := []string{"corp.com"}
+	service := newTestAuthService(mockRepo, new(MockTokenRepository), WithEmailDomainAllowlist(func() []string { return allowed }))
+	input := func(email string) RegisterInput {
+		return RegisterInput{Email: email, Password: "password", FullName: mockUser.FullName}
+	}
+
+	_, err := service.Register(context.Background(), input("user@corp.com"))
+	require.NoError(t, err)
+
+	user, err := service.Register(context.Background(), input("user@gmail.com"))
+	assert.ErrorIs(t, err, ErrEmailDomainNotAllowed)
+	assert.Nil(t, user)
+	mockRepo.AssertNotCalled(t, "FindByEmail", mock.Anything, "user@gmail.com")
+
+	// A reloaded allowlist applies to the next signup.
+	allowed = nil
+	mockRepo.On("FindByEmail", mock.Anything, "user@gmail.com").Return(nil, repository.ErrNotFound)
+	_, err = service.Register(context.Background(), input("user@gmail.com"))
+	assert.NoError(t, err)
+}
+
+func TestNewUserRegisteredEvent(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+
+	event, err := newUserRegisteredEvent(&mockUser)
+
+	assert.NoError(t, err)
+	assert.Equal(t, model.EventUserRegistered, event.EventType)
+
+	var payload UserRegisteredPayload
+	assert.NoError(t, json.Unmarshal(event.Payload, &payload))
+	assert.Equal(t, mockUser.ID, payload.UserID)
+	assert.Equal(t, mockUser.Email, payload.Email)
+	assert.Equal(t, mockUser.FullName, payload.FullName)
+	assert.True(t, mockUser.CreatedAt.Equal(payload.CreatedAt))
+}
+
 func TestAuthService_Login(t *testing.T) {
 	mockUser := testutil.NewMockUser()
-	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.DefaultCost)
+	pendingDeletion := testutil.NewMockUser(
+		testutil.WithID(mockUser.ID),
+		testutil.WithPassword("password"),
+		testutil.WithDeleted(time.Now().Add(-time.Hour)),
+	)
 
 	tests := []struct {
 		name        string
 		input       LoginInput
 		mockFn      func(*MockRepository)
-		wantErr     bool
-		errContains string
+		tokenMockFn func(*MockTokenRepository)
+		wantErr     error
+		// wantCause is the underlying error wantErr must wrap, if any.
+		wantCause error
 	}{
 		{
 			name: "successful login",
+			input: LoginInput{
+				Email:     mockUser.Email,
+				Password:  "password",
+				IPAddress: "203.0.113.42",
+				UserAgent: "Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0",
+			},
+			mockFn: func(repo *MockRepository) {
+				mockUser.PasswordHash = testutil.FastHash("password")
+				repo.On("FindByEmail", mock.Anything, mockUser.Email).Return(&mockUser, nil)
+				repo.On("UpdateLastLogin", mock.Anything, mockUser.ID, mock.MatchedBy(func(at time.Time) bool {
+					return time.Since(at) < time.Minute
+				})).Return(nil)
+			},
+			tokenMockFn: func(repo *MockTokenRepository) {
+				repo.On("Create", mock.Anything, mock.MatchedBy(func(token *model.RefreshToken) bool {
+					return token.UserID == mockUser.ID && token.ParentID == nil && token.FamilyID != uuid.Nil &&
+						token.DeviceLabel == "Firefox on Linux" && token.IPAddress == "203.0.113.0"
+				})).Return(nil)
+			},
+		},
+		{
+			name: "successful login with username",
+			input: LoginInput{
+				Identifier: "Tester",
+				Password:   "password",
+			},
+			mockFn: func(repo *MockRepository) {
+				mockUser.PasswordHash = testutil.FastHash("password")
+				repo.On("FindByUsername", mock.Anything, "tester").Return(&mockUser, nil)
+				repo.On("UpdateLastLogin", mock.Anything, mockUser.ID, mock.Anything).Return(nil)
+			},
+			tokenMockFn: func(repo *MockTokenRepository) {
+				repo.On("Create", mock.Anything, mock.Anything).Return(nil)
+			},
+		},
+		{
+			name: "successful login with email identifier",
+			input: LoginInput{
+				Identifier: mockUser.Email,
+				Password:   "password",
+			},
+			mockFn: func(repo *MockRepository) {
+				mockUser.PasswordHash = testutil.FastHash("password")
+				repo.On("FindByEmail", mock.Anything, mockUser.Email).Return(&mockUser, nil)
+				repo.On("UpdateLastLogin", mock.Anything, mockUser.ID, mock.Anything).Return(nil)
+			},
+			tokenMockFn: func(repo *MockTokenRepository) {
+				repo.On("Create", mock.Anything, mock.Anything).Return(nil)
+			},
+		},
+		{
+			name: "unknown username",
+			input: LoginInput{
+				Identifier: "nobody",
+				Password:   "password",
+			},
+			mockFn: func(repo *MockRepository) {
+				repo.On("FindByUsername", mock.Anything, "nobody").Return(nil, repository.ErrNotFound)
+			},
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name: "last login update failure does not fail login",
 			input: LoginInput{
 				Email:    mockUser.Email,
 				Password: "password",
 			},
 			mockFn: func(repo *MockRepository) {
-				mockUser.PasswordHash = string(hashedPassword)
+				mockUser.PasswordHash = testutil.FastHash("password")
 				repo.On("FindByEmail", mock.Anything, mockUser.Email).Return(&mockUser, nil)
+				repo.On("UpdateLastLogin", mock.Anything, mockUser.ID, mock.Anything).Return(repository.ErrTimeout)
+			},
+			tokenMockFn: func(repo *MockTokenRepository) {
+				repo.On("Create", mock.Anything, mock.Anything).Return(nil)
+			},
+		},
+		{
+			name: "login cancels pending deletion",
+			input: LoginInput{
+				Email:    mockUser.Email,
+				Password: "password",
+			},
+			mockFn: func(repo *MockRepository) {
+				repo.On("FindByEmail", mock.Anything, mockUser.Email).Return(&pendingDeletion, nil)
+				repo.On("CancelDeletion", mock.Anything, mockUser.ID).Return(nil)
+				repo.On("UpdateLastLogin", mock.Anything, mockUser.ID, mock.Anything).Return(nil)
+			},
+			tokenMockFn: func(repo *MockTokenRepository) {
+				repo.On("Create", mock.Anything, mock.Anything).Return(nil)
+			},
+		},
+		{
+			name: "failing to cancel pending deletion fails login",
+			input: LoginInput{
+				Email:    mockUser.Email,
+				Password: "password",
+			},
+			mockFn: func(repo *MockRepository) {
+				repo.On("FindByEmail", mock.Anything, mockUser.Email).Return(&pendingDeletion, nil)
+				repo.On("CancelDeletion", mock.Anything, mockUser.ID).Return(repository.ErrTimeout)
 			},
-			wantErr: false,
+			wantErr: repository.ErrTimeout,
 		},
 		{
 			name: "invalid credentials",
@@ -153,11 +644,11 @@ func TestAuthService_Login(t *testing.T) {
 				Password: "wrongpassword",
 			},
 			mockFn: func(repo *MockRepository) {
-				mockUser.PasswordHash = string(hashedPassword)
+				mockUser.PasswordHash = testutil.FastHash("password")
 				repo.On("FindByEmail", mock.Anything, mockUser.Email).Return(&mockUser, nil)
 			},
-			wantErr:     true,
-			errContains: "invalid credentials",
+			wantErr:   ErrInvalidCredentials,
+			wantCause: bcrypt.ErrMismatchedHashAndPassword,
 		},
 		{
 			name: "user not found",
@@ -166,28 +657,309 @@ func TestAuthService_Login(t *testing.T) {
 				Password: "password",
 			},
 			mockFn: func(repo *MockRepository) {
-				repo.On("FindByEmail", mock.Anything, "nonexistent@example.com").Return(nil, gorm.ErrRecordNotFound)
+				repo.On("FindByEmail", mock.Anything, "nonexistent@example.com").Return(nil, repository.ErrNotFound)
 			},
-			wantErr:     true,
-			errContains: "invalid credentials",
+			wantErr:   ErrInvalidCredentials,
+			wantCause: repository.ErrNotFound,
 		},
-	}
+		{
+			name: "database timeout",
+			input: LoginInput{
+				Email:    mockUser.Email,
+				Password: "password",
+			},
+			mockFn: func(repo *MockRepository) {
+				repo.On("FindByEmail", mock.Anything, mockUser.Email).Return(nil, repository.ErrTimeout)
+			},
+			wantErr: repository.ErrTimeout,
+		},
+		{
+			name: "database unavailable is not reported as invalid credentials",
+			input: LoginInput{
+				Email:    mockUser.Email,
+				Password: "password",
+			},
+			mockFn: func(repo *MockRepository) {
+				repo.On("FindByEmail", mock.Anything, mockUser.Email).Return(nil, repository.ErrConn)
+			},
+			wantErr: repository.ErrConn,
+		},
+	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			service, mockRepo := setupTest()
+			service, mockRepo, mockTokenRepo := setupTest()
 			tt.mockFn(mockRepo)
-			token, err := service.Login(context.Background(), tt.input)
+			if tt.tokenMockFn != nil {
+				tt.tokenMockFn(mockTokenRepo)
+			}
+			tokens, err := service.Login(context.Background(), tt.input)
 
-			if tt.wantErr {
-				assert.Error(t, err)
-				assert.Equal(t, tt.errContains, err.Error())
-				assert.Empty(t, token)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				if tt.wantCause != nil {
+					assert.ErrorIs(t, err, tt.wantCause)
+				}
+				assert.Nil(t, tokens)
+			} else {
+				assert.NoError(t, err)
+				assert.NotEmpty(t, tokens.AccessToken)
+				assert.NotEmpty(t, tokens.RefreshToken)
+				assert.Equal(t, mockUser.ID, tokens.User.ID)
+				claims, err := service.parseToken(tokens.AccessToken)
+				require.NoError(t, err)
+				assert.Equal(t, service.tokenExpiry, tokens.ExpiresIn)
+				assert.Equal(t, tokens.ExpiresIn.Seconds(), claims["exp"].(float64)-claims["iat"].(float64), "the lifetime the exp claim was set from")
+			}
+			mockRepo.AssertExpectations(t)
+			mockTokenRepo.AssertExpectations(t)
+		})
+	}
+}
+
+type fakeLoginRecorder struct {
+	events []*model.LoginEvent
+}
+
+func (r *fakeLoginRecorder) Record(event *model.LoginEvent) {
+	r.events = append(r.events, event)
+}
+
+func TestAuthService_LoginRecordsEvents(t *testing.T) {
+	mockUser := testutil.NewMockUser(testutil.WithPassword("password"))
+
+	tests := []struct {
+		name        string
+		input       LoginInput
+		mockFn      func(*MockRepository, *MockTokenRepository)
+		wantUserID  *uuid.UUID
+		wantSuccess bool
+		wantEvents  int
+	}{
+		{
+			name:  "successful login",
+			input: LoginInput{Email: mockUser.Email, Password: "password", IPAddress: "10.0.0.1", UserAgent: "test-agent"},
+			mockFn: func(repo *MockRepository, tokenRepo *MockTokenRepository) {
+				repo.On("FindByEmail", mock.Anything, mockUser.Email).Return(&mockUser, nil)
+				repo.On("UpdateLastLogin", mock.Anything, mockUser.ID, mock.Anything).Return(nil)
+				tokenRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+			},
+			wantUserID:  &mockUser.ID,
+			wantSuccess: true,
+			wantEvents:  1,
+		},
+		{
+			name:  "wrong password",
+			input: LoginInput{Email: mockUser.Email, Password: "wrongpassword", IPAddress: "10.0.0.1", UserAgent: "test-agent"},
+			mockFn: func(repo *MockRepository, tokenRepo *MockTokenRepository) {
+				repo.On("FindByEmail", mock.Anything, mockUser.Email).Return(&mockUser, nil)
+			},
+			wantUserID: &mockUser.ID,
+			wantEvents: 1,
+		},
+		{
+			name:  "unknown email",
+			input: LoginInput{Email: "nonexistent@example.com", Password: "password", IPAddress: "10.0.0.1", UserAgent: "test-agent"},
+			mockFn: func(repo *MockRepository, tokenRepo *MockTokenRepository) {
+				repo.On("FindByEmail", mock.Anything, "nonexistent@example.com").Return(nil, repository.ErrNotFound)
+			},
+			wantEvents: 1,
+		},
+		{
+			name:  "unknown username",
+			input: LoginInput{Identifier: "nobody", Password: "password", IPAddress: "10.0.0.1", UserAgent: "test-agent"},
+			mockFn: func(repo *MockRepository, tokenRepo *MockTokenRepository) {
+				repo.On("FindByUsername", mock.Anything, "nobody").Return(nil, repository.ErrNotFound)
+			},
+			wantEvents: 1,
+		},
+		{
+			name:  "database timeout is not recorded",
+			input: LoginInput{Email: mockUser.Email, Password: "password"},
+			mockFn: func(repo *MockRepository, tokenRepo *MockTokenRepository) {
+				repo.On("FindByEmail", mock.Anything, mockUser.Email).Return(nil, repository.ErrTimeout)
+			},
+			wantEvents: 0,
+		},
+		{
+			name:  "database outage is not recorded",
+			input: LoginInput{Email: mockUser.Email, Password: "password"},
+			mockFn: func(repo *MockRepository, tokenRepo *MockTokenRepository) {
+				repo.On("FindByEmail", mock.Anything, mockUser.Email).Return(nil, repository.ErrConn)
+			},
+			wantEvents: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			mockTokenRepo := new(MockTokenRepository)
+			recorder := &fakeLoginRecorder{}
+			service := newTestAuthService(mockRepo, mockTokenRepo, WithLoginRecorder(recorder))
+			tt.mockFn(mockRepo, mockTokenRepo)
+
+			_, _ = service.Login(context.Background(), tt.input)
+
+			assert.Len(t, recorder.events, tt.wantEvents)
+			if tt.wantEvents > 0 {
+				event := recorder.events[0]
+				assert.Equal(t, tt.wantUserID, event.UserID)
+				assert.Equal(t, tt.input.identifier(), event.Email)
+				assert.Equal(t, tt.wantSuccess, event.Success)
+				assert.Equal(t, tt.input.IPAddress, event.IPAddress)
+				assert.Equal(t, tt.input.UserAgent, event.UserAgent)
+				assert.False(t, event.CreatedAt.IsZero())
+			}
+			mockRepo.AssertExpectations(t)
+			mockTokenRepo.AssertExpectations(t)
+		})
+	}
+}
+
+type fakeLoginNotifier struct {
+	users    []model.User
+	sessions []model.RefreshToken
+}
+
+func (n *fakeLoginNotifier) NotifyLogin(user model.User, session model.RefreshToken) {
+	n.users = append(n.users, user)
+	n.sessions = append(n.sessions, session)
+}
+
+func TestAuthService_LoginNotifiesLogins(t *testing.T) {
+	mockUser := testutil.NewMockUser(testutil.WithPassword("password"))
+	const userAgent = "Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0"
+
+	mockRepo := new(MockRepository)
+	mockTokenRepo := new(MockTokenRepository)
+	notifier := &fakeLoginNotifier{}
+	service := newTestAuthService(mockRepo, mockTokenRepo, WithLoginNotifier(notifier))
+	mockRepo.On("FindByEmail", mock.Anything, mockUser.Email).Return(&mockUser, nil)
+	mockRepo.On("UpdateLastLogin", mock.Anything, mockUser.ID, mock.Anything).Return(nil)
+	mockTokenRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+
+	_, err := service.Login(context.Background(), LoginInput{Email: mockUser.Email, Password: "password", IPAddress: "203.0.113.42", UserAgent: userAgent})
+	require.NoError(t, err)
+	_, err = service.Login(context.Background(), LoginInput{Email: mockUser.Email, Password: "wrongpassword", IPAddress: "203.0.113.42", UserAgent: userAgent})
+	require.ErrorIs(t, err, ErrInvalidCredentials)
+
+	require.Len(t, notifier.sessions, 1, "only successful logins are notified")
+	assert.Equal(t, mockUser.ID, notifier.users[0].ID)
+	assert.Equal(t, device.Fingerprint(userAgent, "203.0.113.42"), notifier.sessions[0].DeviceHash)
+	assert.NotEqual(t, uuid.Nil, notifier.sessions[0].FamilyID)
+}
+
+// onPrimary matches the contexts returned by database.WithPrimary.
+var onPrimary = mock.MatchedBy(database.PrimaryRequired)
+
+func TestAuthService_LoginReadsPrimary(t *testing.T) {
+	mockUser := testutil.NewMockUser(testutil.WithPassword("password"))
+
+	for _, primary := range []bool{true, false} {
+		mockRepo := new(MockRepository)
+		mockTokenRepo := new(MockTokenRepository)
+		var opts []AuthOption
+		if primary {
+			opts = append(opts, WithPrimaryLoginReads())
+		}
+		service := newTestAuthService(mockRepo, mockTokenRepo, opts...)
+		mockRepo.On("FindByEmail", mock.MatchedBy(func(ctx context.Context) bool {
+			return database.PrimaryRequired(ctx) == primary
+		}), mockUser.Email).Return(&mockUser, nil)
+		mockRepo.On("UpdateLastLogin", mock.Anything, mockUser.ID, mock.Anything).Return(nil)
+		mockTokenRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+
+		_, err := service.Login(context.Background(), LoginInput{Email: mockUser.Email, Password: "password"})
+
+		require.NoError(t, err, "primary reads: %v", primary)
+		mockRepo.AssertExpectations(t)
+	}
+}
+
+// busyHasher is a PasswordHasher that never has a worker free.
+type busyHasher struct{}
+
+func (busyHasher) Hash(ctx context.Context, _ string) (string, error) {
+	<-ctx.Done()
+	return "", ctx.Err()
+}
+
+func (busyHasher) Compare(ctx context.Context, _, _ string) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestAuthService_LoginPasswordHasherBusy(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	mockRepo := new(MockRepository)
+	recorder := &fakeLoginRecorder{}
+	service := newTestAuthService(mockRepo, new(MockTokenRepository),
+		WithPasswordHasher(busyHasher{}), WithLoginRecorder(recorder))
+	mockRepo.On("FindByEmail", mock.Anything, mockUser.Email).Return(&mockUser, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := service.Login(ctx, LoginInput{Email: mockUser.Email, Password: "password"})
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.NotErrorIs(t, err, ErrInvalidCredentials, "the password was never checked")
+	assert.Empty(t, recorder.events)
+}
+
+func TestAuthService_ResetPassword(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+
+	tests := []struct {
+		name    string
+		checker *fakeBreachChecker
+		mockFn  func(*MockRepository, *MockTokenRepository)
+		wantErr error
+	}{
+		{
+			name:    "password replaced and sessions revoked",
+			checker: &fakeBreachChecker{},
+			mockFn: func(repo *MockRepository, tokenRepo *MockTokenRepository) {
+				repo.On("UpdatePassword", mock.Anything, mockUser.ID, mock.MatchedBy(func(hash string) bool {
+					return hash == testutil.FastHash("new-password")
+				}), mock.Anything).Return(nil)
+				repo.On("IncrementTokenVersion", mock.Anything, mockUser.ID).Return(nil)
+				tokenRepo.On("RevokeAllForUser", mock.Anything, mockUser.ID).Return(nil)
+			},
+		},
+		{
+			name:    "breached password",
+			checker: &fakeBreachChecker{count: 100},
+			wantErr: ErrPasswordBreached,
+		},
+		{
+			name:    "database timeout",
+			checker: &fakeBreachChecker{},
+			mockFn: func(repo *MockRepository, tokenRepo *MockTokenRepository) {
+				repo.On("UpdatePassword", mock.Anything, mockUser.ID, mock.Anything, mock.Anything).Return(repository.ErrTimeout)
+			},
+			wantErr: repository.ErrTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			mockTokenRepo := new(MockTokenRepository)
+			service := newTestAuthService(mockRepo, mockTokenRepo, WithBreachChecker(tt.checker, 10))
+			if tt.mockFn != nil {
+				tt.mockFn(mockRepo, mockTokenRepo)
+			}
+
+			err := service.ResetPassword(context.Background(), mockUser.ID, "new-password")
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
 			} else {
 				assert.NoError(t, err)
-				assert.NotEmpty(t, token)
 			}
 			mockRepo.AssertExpectations(t)
+			mockTokenRepo.AssertExpectations(t)
 		})
 	}
 }
@@ -200,8 +972,7 @@ func TestAuthService_GetUserByID(t *testing.T) {
 		id      string
 		mockFn  func(*MockRepository)
 		want    *model.User
-		wantErr bool
-		errType error
+		wantErr error
 	}{
 		{
 			name: "user found",
@@ -209,29 +980,27 @@ func TestAuthService_GetUserByID(t *testing.T) {
 			mockFn: func(repo *MockRepository) {
 				repo.On("FindByID", mock.Anything, mockUser.ID.String()).Return(&mockUser, nil)
 			},
-			want:    &mockUser,
-			wantErr: false,
+			want: &mockUser,
 		},
 		{
 			name: "user not found",
 			id:   mockUser.ID.String(),
 			mockFn: func(repo *MockRepository) {
-				repo.On("FindByID", mock.Anything, mockUser.ID.String()).Return(nil, gorm.ErrRecordNotFound)
+				repo.On("FindByID", mock.Anything, mockUser.ID.String()).Return(nil, repository.ErrNotFound)
 			},
-			wantErr: true,
-			errType: gorm.ErrRecordNotFound,
+			wantErr: ErrUserNotFound,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			service, mockRepo := setupTest()
+			service, mockRepo, _ := setupTest()
 			tt.mockFn(mockRepo)
 			got, err := service.GetUserByID(context.Background(), tt.id)
 
-			if tt.wantErr {
-				assert.Error(t, err)
-				assert.Equal(t, tt.errType, err)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.ErrorIs(t, err, repository.ErrNotFound, "the cause stays visible")
 				assert.Nil(t, got)
 			} else {
 				assert.NoError(t, err)
@@ -243,14 +1012,22 @@ func TestAuthService_GetUserByID(t *testing.T) {
 }
 
 func TestGenerateToken(t *testing.T) {
-	service, _ := setupTest()
+	clock := testutil.NewFakeClock(time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC))
+	service := newTestAuthService(new(MockRepository), new(MockTokenRepository), WithClock(clock))
 	mockUser := testutil.NewMockUser()
+	familyID := uuid.New()
 
-	token, err := service.generateToken(&mockUser)
+	authTime := clock.Now().Add(-time.Minute)
+
+	token, err := service.generateToken(&mockUser, tokenOptions{
+		familyID: familyID,
+		scopes:   ScopesForRole(mockUser.Role),
+		authTime: authTime,
+	})
 	assert.NoError(t, err)
 	assert.NotEmpty(t, token)
 
-	parsedToken, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
+	parsedToken, err := jwt.NewParser(jwt.WithoutClaimsValidation()).Parse(token, func(token *jwt.Token) (interface{}, error) {
 		return []byte("test-secret"), nil
 	})
 
@@ -261,4 +1038,871 @@ func TestGenerateToken(t *testing.T) {
 	assert.True(t, ok)
 	assert.Equal(t, mockUser.ID.String(), claims["user_id"])
 	assert.Equal(t, mockUser.Email, claims["email"])
+	assert.Equal(t, mockUser.Role, claims["role"])
+	assert.Equal(t, familyID.String(), claims["sid"])
+	assert.Equal(t, []interface{}{ScopeProfileRead, ScopeProfileWrite}, claims["scopes"])
+	assert.Equal(t, mockUser.ID.String(), claims["sub"])
+	assert.Equal(t, float64(mockUser.TokenVersion), claims["ver"])
+	assert.NotContains(t, claims, "act")
+	assert.Equal(t, float64(authTime.Unix()), claims["auth_time"])
+	assert.Equal(t, []interface{}{"pwd"}, claims["amr"])
+	assert.Equal(t, float64(clock.Now().Unix()), claims["iat"])
+	assert.Equal(t, float64(clock.Now().Add(24*time.Hour).Unix()), claims["exp"])
+}
+
+func TestGenerateToken_ConfiguredAlgorithm(t *testing.T) {
+	config := newTestConfig()
+	config.JWTAlgorithm = "HS512"
+	service := NewAuthService(new(MockRepository), new(MockTokenRepository), config)
+	mockUser := testutil.NewMockUser()
+
+	token, err := service.generateToken(&mockUser, tokenOptions{scopes: ScopesForRole(mockUser.Role)})
+	require.NoError(t, err)
+
+	parsedToken, err := jwt.NewParser(jwt.WithValidMethods([]string{"HS512"})).Parse(token, func(token *jwt.Token) (interface{}, error) {
+		return []byte("test-secret"), nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "HS512", parsedToken.Header["alg"])
+
+	claims, err := service.parseToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, mockUser.ID.String(), claims["user_id"])
+
+	hs256, _ := testutil.NewTokenFactory("test-secret").Token()
+	_, err = service.parseToken(hs256)
+	assert.ErrorIs(t, err, ErrTokenInvalid, "tokens signed with another algorithm are rejected")
+}
+
+func TestAuthService_LogoutAll(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	errDatabase := errors.New("database error")
+
+	tests := []struct {
+		name    string
+		userID  string
+		mockFn  func(*MockRepository, *MockTokenRepository)
+		wantErr error
+	}{
+		{
+			name:   "success",
+			userID: mockUser.ID.String(),
+			mockFn: func(repo *MockRepository, tokenRepo *MockTokenRepository) {
+				repo.On("IncrementTokenVersion", mock.Anything, mockUser.ID).Return(nil)
+				tokenRepo.On("RevokeAllForUser", mock.Anything, mockUser.ID).Return(nil)
+			},
+		},
+		{
+			name:    "invalid user ID",
+			userID:  "not-a-uuid",
+			mockFn:  func(*MockRepository, *MockTokenRepository) {},
+			wantErr: ErrInvalidUserID,
+		},
+		{
+			name:   "version update fails",
+			userID: mockUser.ID.String(),
+			mockFn: func(repo *MockRepository, _ *MockTokenRepository) {
+				repo.On("IncrementTokenVersion", mock.Anything, mockUser.ID).Return(repository.ErrTimeout)
+			},
+			wantErr: repository.ErrTimeout,
+		},
+		{
+			name:   "refresh token revocation fails",
+			userID: mockUser.ID.String(),
+			mockFn: func(repo *MockRepository, tokenRepo *MockTokenRepository) {
+				repo.On("IncrementTokenVersion", mock.Anything, mockUser.ID).Return(nil)
+				tokenRepo.On("RevokeAllForUser", mock.Anything, mockUser.ID).Return(errDatabase)
+			},
+			wantErr: errDatabase,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, mockRepo, mockTokenRepo := setupTest()
+			tt.mockFn(mockRepo, mockTokenRepo)
+
+			err := service.LogoutAll(context.Background(), tt.userID)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			mockRepo.AssertExpectations(t)
+			mockTokenRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthService_LogoutAllSequence(t *testing.T) {
+	mockUser := testutil.NewMockUser(testutil.WithPassword("password"))
+
+	mockRepo := new(MockRepository)
+	mockRepo.On("FindByEmail", mock.Anything, mockUser.Email).Return(&mockUser, nil)
+	mockRepo.On("FindByID", mock.Anything, mockUser.ID.String()).Return(&mockUser, nil)
+	mockRepo.On("UpdateLastLogin", mock.Anything, mockUser.ID, mock.Anything).Return(nil)
+	mockRepo.On("IncrementTokenVersion", mock.Anything, mockUser.ID).
+		Run(func(mock.Arguments) { mockUser.TokenVersion++ }).
+		Return(nil)
+	service := newTestAuthService(mockRepo, newFakeTokenRepository())
+	ctx := context.Background()
+
+	old, err := service.Login(ctx, LoginInput{Email: mockUser.Email, Password: "password"})
+	require.NoError(t, err)
+	require.NoError(t, service.CheckTokenVersion(ctx, mockUser.ID.String(), tokenVersion(t, old.AccessToken)))
+
+	require.NoError(t, service.LogoutAll(ctx, mockUser.ID.String()))
+
+	assert.ErrorIs(t, service.CheckTokenVersion(ctx, mockUser.ID.String(), tokenVersion(t, old.AccessToken)), ErrTokenRevoked)
+	introspection, err := service.Introspect(ctx, old.AccessToken)
+	require.NoError(t, err)
+	assert.False(t, introspection.Active)
+	_, err = service.Refresh(ctx, RefreshInput{RefreshToken: old.RefreshToken})
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+
+	fresh, err := service.Login(ctx, LoginInput{Email: mockUser.Email, Password: "password"})
+	require.NoError(t, err)
+	assert.NoError(t, service.CheckTokenVersion(ctx, mockUser.ID.String(), tokenVersion(t, fresh.AccessToken)))
+}
+
+func TestAuthService_CheckTokenVersion(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	mockUser.TokenVersion = 2
+
+	tests := []struct {
+		name    string
+		version int
+		mockFn  func(*MockRepository)
+		wantErr error
+	}{
+		{
+			name:    "current version",
+			version: 2,
+			mockFn: func(repo *MockRepository) {
+				repo.On("FindByID", onPrimary, mockUser.ID.String()).Return(&mockUser, nil)
+			},
+		},
+		{
+			name:    "outdated version",
+			version: 1,
+			mockFn: func(repo *MockRepository) {
+				repo.On("FindByID", onPrimary, mockUser.ID.String()).Return(&mockUser, nil)
+			},
+			wantErr: ErrTokenRevoked,
+		},
+		{
+			name:    "user deleted",
+			version: 2,
+			mockFn: func(repo *MockRepository) {
+				repo.On("FindByID", onPrimary, mockUser.ID.String()).Return(nil, repository.ErrNotFound)
+			},
+			wantErr: ErrTokenRevoked,
+		},
+		{
+			name:    "database unavailable",
+			version: 2,
+			mockFn: func(repo *MockRepository) {
+				repo.On("FindByID", onPrimary, mockUser.ID.String()).Return(nil, repository.ErrConn)
+			},
+			wantErr: repository.ErrConn,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, mockRepo, _ := setupTest()
+			tt.mockFn(mockRepo)
+
+			err := service.CheckTokenVersion(context.Background(), mockUser.ID.String(), tt.version)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+// tokenVersion returns the "ver" claim of an access token.
+func tokenVersion(t *testing.T, token string) int {
+	t.Helper()
+	version, ok := parseTestClaims(t, token)["ver"].(float64)
+	require.True(t, ok)
+	return int(version)
+}
+
+// parseTestClaims returns the claims of an access token signed with the test secret.
+func parseTestClaims(t *testing.T, token string) jwt.MapClaims {
+	t.Helper()
+	parsed, err := jwt.Parse(token, func(*jwt.Token) (interface{}, error) {
+		return []byte("test-secret"), nil
+	})
+	require.NoError(t, err)
+	return parsed.Claims.(jwt.MapClaims)
+}
+
+func TestAuthService_Reauth(t *testing.T) {
+	mockUser := testutil.NewMockUser(testutil.WithPassword("password"))
+
+	tests := []struct {
+		name       string
+		password   string
+		granted    []string
+		findErr    error
+		wantErr    error
+		wantScopes []interface{}
+	}{
+		{
+			name:       "correct password",
+			password:   "password",
+			granted:    ScopesForRole(model.RoleUser),
+			wantScopes: []interface{}{ScopeProfileRead, ScopeProfileWrite},
+		},
+		{
+			name:       "keeps a scoped token's limits",
+			password:   "password",
+			granted:    []string{ScopeProfileRead, ScopeUsersAdmin},
+			wantScopes: []interface{}{ScopeProfileRead},
+		},
+		{
+			name:     "wrong password",
+			password: "wrong",
+			granted:  ScopesForRole(model.RoleUser),
+			wantErr:  ErrInvalidCredentials,
+		},
+		{
+			name:     "database timeout",
+			password: "password",
+			findErr:  repository.ErrTimeout,
+			wantErr:  repository.ErrTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, mockRepo, _ := setupTest()
+			if tt.findErr != nil {
+				mockRepo.On("FindByIDWithPassword", mock.Anything, mockUser.ID.String()).Return(nil, tt.findErr)
+			} else {
+				mockRepo.On("FindByIDWithPassword", mock.Anything, mockUser.ID.String()).Return(mockUser.Clone(), nil)
+			}
+
+			token, err := service.Reauth(context.Background(), mockUser.ID.String(), tt.granted, ReauthInput{Password: tt.password})
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Empty(t, token)
+				return
+			}
+			require.NoError(t, err)
+			claims := parseTestClaims(t, token)
+			assert.Equal(t, tt.wantScopes, claims["scopes"])
+			assert.InDelta(t, time.Now().Unix(), claims["auth_time"], 5)
+			assert.InDelta(t, time.Now().Add(5*time.Minute).Unix(), claims["exp"], 5, "elevated tokens are short-lived")
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthService_Impersonate(t *testing.T) {
+	target := testutil.NewMockUser()
+	actorID := uuid.New().String()
+
+	tests := []struct {
+		name    string
+		userID  string
+		mockFn  func(*MockRepository)
+		wantErr error
+	}{
+		{
+			name:   "success",
+			userID: target.ID.String(),
+			mockFn: func(repo *MockRepository) {
+				repo.On("FindByID", mock.Anything, target.ID.String()).Return(target.Clone(), nil)
+			},
+		},
+		{
+			name:    "invalid user ID",
+			userID:  "not-a-uuid",
+			mockFn:  func(*MockRepository) {},
+			wantErr: ErrInvalidUserID,
+		},
+		{
+			name:   "user not found",
+			userID: target.ID.String(),
+			mockFn: func(repo *MockRepository) {
+				repo.On("FindByID", mock.Anything, target.ID.String()).Return(nil, repository.ErrNotFound)
+			},
+			wantErr: ErrUserNotFound,
+		},
+		{
+			name:   "database timeout",
+			userID: target.ID.String(),
+			mockFn: func(repo *MockRepository) {
+				repo.On("FindByID", mock.Anything, target.ID.String()).Return(nil, repository.ErrTimeout)
+			},
+			wantErr: repository.ErrTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, mockRepo, _ := setupTest()
+			tt.mockFn(mockRepo)
+
+			token, err := service.Impersonate(context.Background(), actorID, tt.userID)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Empty(t, token)
+			} else {
+				require.NoError(t, err)
+				claims := parseTestClaims(t, token)
+				assert.Equal(t, target.ID.String(), claims["sub"])
+				assert.Equal(t, target.ID.String(), claims["user_id"])
+				assert.Equal(t, map[string]interface{}{"sub": actorID}, claims["act"])
+				assert.NotContains(t, claims, "sid", "impersonation tokens cannot be refreshed")
+				assert.NotContains(t, claims, "auth_time")
+				assert.InDelta(t, time.Now().Add(15*time.Minute).Unix(), claims["exp"], 5)
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthService_AuthTimeSurvivesOnlyLogin(t *testing.T) {
+	mockUser := testutil.NewMockUser(testutil.WithPassword("password"))
+
+	mockRepo := new(MockRepository)
+	mockRepo.On("FindByEmail", mock.Anything, mockUser.Email).Return(&mockUser, nil)
+	mockRepo.On("FindByID", mock.Anything, mockUser.ID.String()).Return(&mockUser, nil)
+	mockRepo.On("UpdateLastLogin", mock.Anything, mockUser.ID, mock.Anything).Return(nil)
+	service := newTestAuthService(mockRepo, newFakeTokenRepository())
+	ctx := context.Background()
+
+	login, err := service.Login(ctx, LoginInput{Email: mockUser.Email, Password: "password"})
+	require.NoError(t, err)
+	assert.InDelta(t, time.Now().Unix(), parseTestClaims(t, login.AccessToken)["auth_time"], 5)
+
+	refreshed, err := service.Refresh(ctx, RefreshInput{RefreshToken: login.RefreshToken})
+	require.NoError(t, err)
+	assert.NotContains(t, parseTestClaims(t, refreshed.AccessToken), "auth_time")
+}
+
+func TestAuthService_IssueScopedToken(t *testing.T) {
+	userID := uuid.New()
+	fullScopes := []string{ScopeProfileRead, ScopeProfileWrite}
+
+	tests := []struct {
+		name       string
+		role       string
+		granted    []string
+		scopes     []string
+		findErr    error
+		wantErr    error
+		wantScopes []interface{}
+	}{
+		{
+			name:       "subset of the role's scopes",
+			role:       model.RoleUser,
+			granted:    fullScopes,
+			scopes:     []string{ScopeProfileRead, ScopeProfileRead},
+			wantScopes: []interface{}{ScopeProfileRead},
+		},
+		{
+			name:       "admin scope for an admin",
+			role:       model.RoleAdmin,
+			granted:    ScopesForRole(model.RoleAdmin),
+			scopes:     []string{ScopeUsersAdmin, ScopeProfileRead},
+			wantScopes: []interface{}{ScopeProfileRead, ScopeUsersAdmin},
+		},
+		{
+			name:    "unknown scope",
+			role:    model.RoleUser,
+			granted: fullScopes,
+			scopes:  []string{"billing:read"},
+			wantErr: ErrUnknownScope,
+		},
+		{
+			name:    "scope beyond the role",
+			role:    model.RoleUser,
+			granted: ScopesForRole(model.RoleAdmin),
+			scopes:  []string{ScopeUsersAdmin},
+			wantErr: ErrScopeNotGranted,
+		},
+		{
+			name:    "scope beyond the presented token",
+			role:    model.RoleUser,
+			granted: []string{ScopeProfileRead},
+			scopes:  []string{ScopeProfileWrite},
+			wantErr: ErrScopeNotGranted,
+		},
+		{
+			name:    "no scopes",
+			role:    model.RoleUser,
+			granted: fullScopes,
+			scopes:  []string{},
+			wantErr: ErrNoScopes,
+		},
+		{
+			name:    "user not found",
+			granted: fullScopes,
+			scopes:  []string{ScopeProfileRead},
+			findErr: repository.ErrTimeout,
+			wantErr: repository.ErrTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, mockRepo, _ := setupTest()
+			user := testutil.NewMockUser(testutil.WithID(userID), testutil.WithRole(tt.role))
+			if tt.findErr != nil {
+				mockRepo.On("FindByID", mock.Anything, user.ID.String()).Return(nil, tt.findErr)
+			} else {
+				mockRepo.On("FindByID", mock.Anything, user.ID.String()).Return(&user, nil)
+			}
+
+			token, err := service.IssueScopedToken(context.Background(), user.ID.String(), tt.granted, ScopedTokenInput{Scopes: tt.scopes})
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Empty(t, token)
+				return
+			}
+			assert.NoError(t, err)
+			parsed, err := jwt.Parse(token, func(*jwt.Token) (interface{}, error) {
+				return []byte("test-secret"), nil
+			})
+			assert.NoError(t, err)
+			claims := parsed.Claims.(jwt.MapClaims)
+			assert.Equal(t, tt.wantScopes, claims["scopes"])
+			assert.NotContains(t, claims, "sid", "scoped tokens are not tied to a refresh family")
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+// fakeTokenRepository is an in-memory TokenRepository with the same rotation
+// semantics as the database-backed implementation.
+type fakeTokenRepository struct {
+	tokens map[uuid.UUID]*model.RefreshToken
+}
+
+func newFakeTokenRepository() *fakeTokenRepository {
+	return &fakeTokenRepository{tokens: map[uuid.UUID]*model.RefreshToken{}}
+}
+
+func (r *fakeTokenRepository) Create(_ context.Context, token *model.RefreshToken) error {
+	token.ID = uuid.New()
+	r.tokens[token.ID] = token
+	return nil
+}
+
+func (r *fakeTokenRepository) FindByHash(_ context.Context, tokenHash string) (*model.RefreshToken, error) {
+	for _, token := range r.tokens {
+		if token.TokenHash == tokenHash {
+			found := *token
+			return &found, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (r *fakeTokenRepository) Rotate(ctx context.Context, oldID uuid.UUID, next *model.RefreshToken) error {
+	old := r.tokens[oldID]
+	if old.RotatedAt != nil {
+		return repository.ErrTokenAlreadyRotated
+	}
+	now := time.Now()
+	old.RotatedAt = &now
+	return r.Create(ctx, next)
+}
+
+func (r *fakeTokenRepository) IsFamilyRevoked(_ context.Context, familyID uuid.UUID) (bool, error) {
+	for _, token := range r.tokens {
+		if token.FamilyID == familyID && token.RevokedAt != nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeTokenRepository) RevokeAllForUser(_ context.Context, userID uuid.UUID) error {
+	now := time.Now()
+	for _, token := range r.tokens {
+		if token.UserID == userID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *fakeTokenRepository) RevokeFamily(_ context.Context, familyID uuid.UUID) error {
+	now := time.Now()
+	for _, token := range r.tokens {
+		if token.FamilyID == familyID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func TestAuthService_RefreshReplaySequence(t *testing.T) {
+	mockUser := testutil.NewMockUser(testutil.WithPassword("password"))
+
+	mockRepo := new(MockRepository)
+	mockRepo.On("FindByEmail", mock.Anything, mockUser.Email).Return(&mockUser, nil)
+	mockRepo.On("FindByID", mock.Anything, mockUser.ID.String()).Return(&mockUser, nil)
+	mockRepo.On("UpdateLastLogin", mock.Anything, mockUser.ID, mock.Anything).Return(nil)
+	tokenRepo := newFakeTokenRepository()
+	service := newTestAuthService(mockRepo, tokenRepo)
+	ctx := context.Background()
+
+	login, err := service.Login(ctx, LoginInput{Email: mockUser.Email, Password: "password"})
+	assert.NoError(t, err)
+
+	// An unrelated session must survive the compromise of the first one.
+	otherLogin, err := service.Login(ctx, LoginInput{Email: mockUser.Email, Password: "password"})
+	assert.NoError(t, err)
+
+	// The legitimate client rotates its token.
+	rotated, err := service.Refresh(ctx, RefreshInput{RefreshToken: login.RefreshToken})
+	assert.NoError(t, err)
+	assert.NotEqual(t, login.RefreshToken, rotated.RefreshToken)
+
+	// An attacker replays the stolen, already rotated token.
+	replayed, err := service.Refresh(ctx, RefreshInput{RefreshToken: login.RefreshToken})
+	assert.ErrorIs(t, err, ErrTokenReuseDetected)
+	assert.Nil(t, replayed)
+
+	// The whole family is revoked, including the legitimate client's current token.
+	_, err = service.Refresh(ctx, RefreshInput{RefreshToken: rotated.RefreshToken})
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+
+	_, err = service.Refresh(ctx, RefreshInput{RefreshToken: otherLogin.RefreshToken})
+	assert.NoError(t, err)
+}
+
+func TestAuthService_Logout(t *testing.T) {
+	mockUser := testutil.NewMockUser(testutil.WithPassword("password"))
+
+	mockRepo := new(MockRepository)
+	mockRepo.On("FindByEmail", mock.Anything, mockUser.Email).Return(&mockUser, nil)
+	mockRepo.On("FindByID", mock.Anything, mockUser.ID.String()).Return(&mockUser, nil)
+	mockRepo.On("UpdateLastLogin", mock.Anything, mockUser.ID, mock.Anything).Return(nil)
+	service := newTestAuthService(mockRepo, newFakeTokenRepository())
+	ctx := context.Background()
+
+	login, err := service.Login(ctx, LoginInput{Email: mockUser.Email, Password: "password"})
+	require.NoError(t, err)
+	other, err := service.Login(ctx, LoginInput{Email: mockUser.Email, Password: "password"})
+	require.NoError(t, err)
+	assert.Equal(t, 7*24*time.Hour, login.RefreshExpiresIn)
+
+	require.NoError(t, service.Logout(ctx, RefreshInput{RefreshToken: login.RefreshToken}))
+	_, err = service.Refresh(ctx, RefreshInput{RefreshToken: login.RefreshToken})
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken, "the session is over")
+	assert.NoError(t, service.Logout(ctx, RefreshInput{RefreshToken: login.RefreshToken}), "logging out again succeeds")
+
+	_, err = service.Refresh(ctx, RefreshInput{RefreshToken: other.RefreshToken})
+	assert.NoError(t, err, "other sessions go on")
+
+	assert.ErrorIs(t, service.Logout(ctx, RefreshInput{RefreshToken: "unknown"}), ErrInvalidRefreshToken)
+}
+
+func TestAuthService_Metrics(t *testing.T) {
+	mockUser := testutil.NewMockUser(testutil.WithPassword("password"))
+
+	mockRepo := new(MockRepository)
+	mockRepo.On("FindByEmail", mock.Anything, "new@example.com").Return(nil, repository.ErrNotFound)
+	mockRepo.On("FindByEmail", mock.Anything, "unknown@example.com").Return(nil, repository.ErrNotFound)
+	mockRepo.On("FindByEmail", mock.Anything, mockUser.Email).Return(&mockUser, nil)
+	mockRepo.On("CreateWithOutbox", mock.Anything, mock.AnythingOfType("*model.User")).Return(nil)
+	mockRepo.On("FindByID", mock.Anything, mockUser.ID.String()).Return(&mockUser, nil)
+	mockRepo.On("UpdateLastLogin", mock.Anything, mockUser.ID, mock.Anything).Return(nil)
+	service := newTestAuthService(mockRepo, newFakeTokenRepository())
+	ctx := context.Background()
+
+	_, err := service.Register(ctx, RegisterInput{Email: "new@example.com", Password: "password", FullName: "New User"})
+	require.NoError(t, err)
+	_, err = service.Register(ctx, RegisterInput{Email: mockUser.Email, Password: "password", FullName: "Taken"})
+	require.ErrorIs(t, err, ErrEmailTaken)
+
+	_, err = service.Login(ctx, LoginInput{Email: "unknown@example.com", Password: "password"})
+	require.ErrorIs(t, err, ErrInvalidCredentials)
+	_, err = service.Login(ctx, LoginInput{Email: mockUser.Email, Password: "wrongpassword"})
+	require.ErrorIs(t, err, ErrInvalidCredentials)
+	login, err := service.Login(ctx, LoginInput{Email: mockUser.Email, Password: "password"})
+	require.NoError(t, err)
+
+	_, err = service.Refresh(ctx, RefreshInput{RefreshToken: login.RefreshToken})
+	require.NoError(t, err)
+	_, err = service.Refresh(ctx, RefreshInput{RefreshToken: login.RefreshToken})
+	require.ErrorIs(t, err, ErrTokenReuseDetected)
+
+	assert.Equal(t, float64(1), promtest.ToFloat64(service.registrations))
+	assert.Equal(t, float64(2), promtest.ToFloat64(service.failedLogins))
+	assert.Equal(t, float64(1), promtest.ToFloat64(service.lockouts))
+	assert.Equal(t, 3, promtest.CollectAndCount(service), "the service collects its three counters")
+}
+
+func TestAuthService_Refresh(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	familyID := uuid.New()
+	const refreshToken = "refresh-token"
+	now := time.Now()
+
+	newToken := func() *model.RefreshToken {
+		return &model.RefreshToken{
+			ID:        uuid.New(),
+			UserID:    mockUser.ID,
+			FamilyID:  familyID,
+			TokenHash: hashToken(refreshToken),
+			ExpiresAt: now.Add(time.Hour),
+
+			DeviceLabel: "Chrome on macOS",
+			Name:        "Work laptop",
+			IPAddress:   "203.0.113.0",
+		}
+	}
+
+	tests := []struct {
+		name    string
+		mockFn  func(*MockRepository, *MockTokenRepository)
+		wantErr error
+	}{
+		{
+			name: "successful rotation",
+			mockFn: func(repo *MockRepository, tokenRepo *MockTokenRepository) {
+				current := newToken()
+				tokenRepo.On("FindByHash", mock.Anything, hashToken(refreshToken)).Return(current, nil)
+				repo.On("FindByID", mock.Anything, mockUser.ID.String()).Return(&mockUser, nil)
+				tokenRepo.On("Rotate", mock.Anything, current.ID, mock.MatchedBy(func(next *model.RefreshToken) bool {
+					return next.FamilyID == familyID && *next.ParentID == current.ID && next.TokenHash != current.TokenHash &&
+						next.DeviceLabel == current.DeviceLabel && next.Name == current.Name && next.IPAddress == current.IPAddress
+				})).Return(nil)
+			},
+		},
+		{
+			name: "unknown token",
+			mockFn: func(repo *MockRepository, tokenRepo *MockTokenRepository) {
+				tokenRepo.On("FindByHash", mock.Anything, hashToken(refreshToken)).Return(nil, repository.ErrNotFound)
+			},
+			wantErr: ErrInvalidRefreshToken,
+		},
+		{
+			name: "expired token",
+			mockFn: func(repo *MockRepository, tokenRepo *MockTokenRepository) {
+				current := newToken()
+				current.ExpiresAt = now.Add(-time.Minute)
+				tokenRepo.On("FindByHash", mock.Anything, hashToken(refreshToken)).Return(current, nil)
+			},
+			wantErr: ErrInvalidRefreshToken,
+		},
+		{
+			name: "revoked token",
+			mockFn: func(repo *MockRepository, tokenRepo *MockTokenRepository) {
+				current := newToken()
+				current.RevokedAt = &now
+				tokenRepo.On("FindByHash", mock.Anything, hashToken(refreshToken)).Return(current, nil)
+			},
+			wantErr: ErrInvalidRefreshToken,
+		},
+		{
+			name: "already rotated token revokes family",
+			mockFn: func(repo *MockRepository, tokenRepo *MockTokenRepository) {
+				current := newToken()
+				current.RotatedAt = &now
+				tokenRepo.On("FindByHash", mock.Anything, hashToken(refreshToken)).Return(current, nil)
+				tokenRepo.On("RevokeFamily", mock.Anything, familyID).Return(nil)
+			},
+			wantErr: ErrTokenReuseDetected,
+		},
+		{
+			name: "concurrent rotation revokes family",
+			mockFn: func(repo *MockRepository, tokenRepo *MockTokenRepository) {
+				current := newToken()
+				tokenRepo.On("FindByHash", mock.Anything, hashToken(refreshToken)).Return(current, nil)
+				repo.On("FindByID", mock.Anything, mockUser.ID.String()).Return(&mockUser, nil)
+				tokenRepo.On("Rotate", mock.Anything, current.ID, mock.Anything).Return(repository.ErrTokenAlreadyRotated)
+				tokenRepo.On("RevokeFamily", mock.Anything, familyID).Return(nil)
+			},
+			wantErr: ErrTokenReuseDetected,
+		},
+		{
+			name: "user no longer exists",
+			mockFn: func(repo *MockRepository, tokenRepo *MockTokenRepository) {
+				tokenRepo.On("FindByHash", mock.Anything, hashToken(refreshToken)).Return(newToken(), nil)
+				repo.On("FindByID", mock.Anything, mockUser.ID.String()).Return(nil, repository.ErrNotFound)
+			},
+			wantErr: ErrInvalidRefreshToken,
+		},
+		{
+			name: "database timeout",
+			mockFn: func(repo *MockRepository, tokenRepo *MockTokenRepository) {
+				tokenRepo.On("FindByHash", mock.Anything, hashToken(refreshToken)).Return(nil, repository.ErrTimeout)
+			},
+			wantErr: repository.ErrTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, mockRepo, mockTokenRepo := setupTest()
+			tt.mockFn(mockRepo, mockTokenRepo)
+
+			tokens, err := service.Refresh(context.Background(), RefreshInput{RefreshToken: refreshToken})
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, tokens)
+			} else {
+				assert.NoError(t, err)
+				assert.NotEmpty(t, tokens.AccessToken)
+				assert.NotEqual(t, refreshToken, tokens.RefreshToken)
+			}
+			mockRepo.AssertExpectations(t)
+			mockTokenRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthService_Introspect(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	familyID := uuid.New()
+	current := mockUser.Clone()
+	current.TokenVersion = 2
+
+	tokens := testutil.NewTokenFactory("test-secret")
+	valid := func(opts ...testutil.TokenOption) func() (string, jwt.MapClaims) {
+		return func() (string, jwt.MapClaims) {
+			return tokens.Token(append([]testutil.TokenOption{
+				testutil.WithClaim("user_id", mockUser.ID.String()),
+				testutil.WithClaim("email", mockUser.Email),
+				testutil.WithClaim("sid", familyID.String()),
+				testutil.WithClaim("ver", 2),
+			}, opts...)...)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		token      func() (string, jwt.MapClaims)
+		mockFn     func(*MockRepository, *MockTokenRepository)
+		wantActive bool
+		wantErr    error
+	}{
+		{
+			name:  "valid token",
+			token: valid(),
+			mockFn: func(userRepo *MockRepository, repo *MockTokenRepository) {
+				repo.On("IsFamilyRevoked", mock.Anything, familyID).Return(false, nil)
+				userRepo.On("FindByID", mock.Anything, mockUser.ID.String()).Return(current, nil)
+			},
+			wantActive: true,
+		},
+		{
+			name:  "valid token without session",
+			token: valid(testutil.WithClaim("sid", nil)),
+			mockFn: func(userRepo *MockRepository, _ *MockTokenRepository) {
+				userRepo.On("FindByID", mock.Anything, mockUser.ID.String()).Return(current, nil)
+			},
+			wantActive: true,
+		},
+		{
+			name:  "signed out everywhere since",
+			token: valid(),
+			mockFn: func(userRepo *MockRepository, repo *MockTokenRepository) {
+				repo.On("IsFamilyRevoked", mock.Anything, familyID).Return(false, nil)
+				bumped := current.Clone()
+				bumped.TokenVersion++
+				userRepo.On("FindByID", mock.Anything, mockUser.ID.String()).Return(bumped, nil)
+			},
+		},
+		{
+			name:  "user lookup unavailable",
+			token: valid(),
+			mockFn: func(userRepo *MockRepository, repo *MockTokenRepository) {
+				repo.On("IsFamilyRevoked", mock.Anything, familyID).Return(false, nil)
+				userRepo.On("FindByID", mock.Anything, mockUser.ID.String()).Return(nil, repository.ErrTimeout)
+			},
+			wantErr: repository.ErrTimeout,
+		},
+		{
+			name:  "expired token",
+			token: valid(testutil.WithExpiry(-time.Hour)),
+		},
+		{
+			name:  "revoked session",
+			token: valid(),
+			mockFn: func(userRepo *MockRepository, repo *MockTokenRepository) {
+				repo.On("IsFamilyRevoked", mock.Anything, familyID).Return(true, nil)
+			},
+		},
+		{
+			name:  "wrong signature",
+			token: valid(testutil.WithSigningKey("other-secret")),
+		},
+		{
+			name:  "unsigned token",
+			token: valid(testutil.WithAlg(jwt.SigningMethodNone)),
+		},
+		{
+			name:  "RS256 header over an HMAC signature",
+			token: valid(testutil.WithHeaderAlg("RS256")),
+		},
+		{
+			name:  "another HMAC algorithm",
+			token: valid(testutil.WithAlg(jwt.SigningMethodHS512)),
+		},
+		{
+			name:  "malformed token",
+			token: func() (string, jwt.MapClaims) { return "garbage", nil },
+		},
+		{
+			name:  "missing claims",
+			token: valid(testutil.WithClaim("user_id", nil), testutil.WithClaim("email", nil)),
+		},
+		{
+			name:  "revocation store unavailable",
+			token: valid(),
+			mockFn: func(userRepo *MockRepository, repo *MockTokenRepository) {
+				repo.On("IsFamilyRevoked", mock.Anything, familyID).Return(false, repository.ErrTimeout)
+			},
+			wantErr: repository.ErrTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, mockRepo, mockTokenRepo := setupTest()
+			if tt.mockFn != nil {
+				tt.mockFn(mockRepo, mockTokenRepo)
+			}
+
+			token, claims := tt.token()
+			got, err := service.Introspect(context.Background(), token)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, got)
+			} else if tt.wantActive {
+				assert.NoError(t, err)
+				assert.True(t, got.Active)
+				assert.Equal(t, mockUser.ID.String(), got.Subject)
+				assert.Equal(t, mockUser.Email, got.Email)
+				assert.Equal(t, claims["exp"], got.ExpiresAt)
+				assert.Equal(t, claims["iat"], got.IssuedAt)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, &Introspection{Active: false}, got)
+			}
+			mockRepo.AssertExpectations(t)
+			mockTokenRepo.AssertExpectations(t)
+		})
+	}
 }
@@ -0,0 +1,193 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"log/slog"
+	"net/http"
+
+	"github.com/PakornBank/learn-go/internal/events"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/storage"
+	"github.com/google/uuid"
+	"golang.org/x/image/draw"
+)
+
+// Limits on uploaded avatars. Images larger than the configured maximum
+// dimension are scaled down; MaxAvatarPixels rejects images whose decoded
+// size would be unreasonable regardless of how small the upload is.
+const (
+	MaxAvatarBytes  = 2 << 20
+	MaxAvatarPixels = 4096 * 4096
+)
+
+var (
+	ErrAvatarTooLarge   = errors.New("avatar may be at most 2MB")
+	ErrUnsupportedImage = errors.New("avatar must be a PNG or JPEG image")
+)
+
+type AvatarRepository interface {
+	FindByID(ctx context.Context, id string) (*model.User, error)
+	UpdateAvatarURL(ctx context.Context, id uuid.UUID, url string) error
+}
+
+// avatarFormat is an image format accepted for avatars.
+type avatarFormat struct {
+	contentType  string
+	extension    string
+	decodeConfig func([]byte) (image.Config, error)
+	decode       func([]byte) (image.Image, error)
+	encode       func(*bytes.Buffer, image.Image) error
+}
+
+// avatarFormats are the accepted formats keyed by the content type that
+// http.DetectContentType sniffs from their magic bytes.
+var avatarFormats = map[string]avatarFormat{
+	"image/png": {
+		contentType:  "image/png",
+		extension:    ".png",
+		decodeConfig: func(b []byte) (image.Config, error) { return png.DecodeConfig(bytes.NewReader(b)) },
+		decode:       func(b []byte) (image.Image, error) { return png.Decode(bytes.NewReader(b)) },
+		encode:       func(w *bytes.Buffer, img image.Image) error { return png.Encode(w, img) },
+	},
+	"image/jpeg": {
+		contentType:  "image/jpeg",
+		extension:    ".jpg",
+		decodeConfig: func(b []byte) (image.Config, error) { return jpeg.DecodeConfig(bytes.NewReader(b)) },
+		decode:       func(b []byte) (image.Image, error) { return jpeg.Decode(bytes.NewReader(b)) },
+		encode:       func(w *bytes.Buffer, img image.Image) error { return jpeg.Encode(w, img, &jpeg.Options{Quality: 85}) },
+	},
+}
+
+// AvatarService stores the profile images users upload.
+type AvatarService struct {
+	userRepo     AvatarRepository
+	storage      storage.BlobStorage
+	maxDimension int
+	events       events.Publisher
+}
+
+// NewAvatarService creates an AvatarService that stores avatars in store,
+// scaled down so neither side exceeds maxDimension pixels, and publishes
+// profile updates to publisher.
+func NewAvatarService(userRepo AvatarRepository, store storage.BlobStorage, maxDimension int, publisher events.Publisher) *AvatarService {
+	return &AvatarService{userRepo: userRepo, storage: store, maxDimension: maxDimension, events: publisher}
+}
+
+// Upload replaces the avatar of the user with userID with the image in data
+// and returns the updated user. The format is detected from the image's magic
+// bytes rather than trusting a file name or declared content type, and the
+// image is re-encoded, which also strips metadata such as EXIF location data.
+// The previous avatar, if any, is deleted once the new one is saved.
+func (s *AvatarService) Upload(ctx context.Context, userID string, data []byte) (*model.User, error) {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, ErrInvalidUserID
+	}
+	if len(data) > MaxAvatarBytes {
+		return nil, ErrAvatarTooLarge
+	}
+
+	format, ok := avatarFormats[http.DetectContentType(data)]
+	if !ok {
+		return nil, ErrUnsupportedImage
+	}
+	encoded, err := s.process(format, data)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := avatarKey(id, format.extension)
+	if err != nil {
+		return nil, err
+	}
+	url, err := s.storage.Put(ctx, key, encoded, format.contentType)
+	if err != nil {
+		return nil, fmt.Errorf("store avatar: %w", err)
+	}
+
+	if err := s.userRepo.UpdateAvatarURL(ctx, id, url); err != nil {
+		s.deleteBlob(ctx, url)
+		return nil, err
+	}
+
+	if user.AvatarURL != nil {
+		s.deleteBlob(ctx, *user.AvatarURL)
+	}
+	user.AvatarURL = &url
+	s.events.Publish(userID, profileUpdated("avatar_url"))
+	return user, nil
+}
+
+// process decodes data, scales it down to the maximum dimension and encodes
+// it again in the same format.
+func (s *AvatarService) process(format avatarFormat, data []byte) ([]byte, error) {
+	config, err := format.decodeConfig(data)
+	if err != nil || config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > MaxAvatarPixels {
+		return nil, ErrUnsupportedImage
+	}
+
+	img, err := format.decode(data)
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	img = fitWithin(img, s.maxDimension)
+
+	var buf bytes.Buffer
+	if err := format.encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("encode avatar: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// fitWithin scales img down, keeping its aspect ratio, so that neither side
+// exceeds maxDimension. Smaller images are returned unchanged.
+func fitWithin(img image.Image, maxDimension int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if maxDimension <= 0 || (width <= maxDimension && height <= maxDimension) {
+		return img
+	}
+
+	if width >= height {
+		height = max(1, height*maxDimension/width)
+		width = maxDimension
+	} else {
+		width = max(1, width*maxDimension/height)
+		height = maxDimension
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	return dst
+}
+
+// avatarKey returns a new storage key for an avatar of the user. The random
+// part gives every upload its own URL, so caches never serve a replaced image.
+func avatarKey(id uuid.UUID, extension string) (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return id.String() + "-" + hex.EncodeToString(buf) + extension, nil
+}
+
+// deleteBlob deletes a stored avatar. Failures leave an orphaned file but do
+// not affect the user, so they are only logged.
+func (s *AvatarService) deleteBlob(ctx context.Context, url string) {
+	if err := s.storage.Delete(ctx, url); err != nil {
+		slog.WarnContext(ctx, "failed to delete avatar", "url", url, "error", err)
+	}
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PakornBank/learn-go/internal/events"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/storage"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockAvatarRepository struct {
+	mock.Mock
+}
+
+func (r *MockAvatarRepository) FindByID(ctx context.Context, id string) (*model.User, error) {
+	args := r.Called(ctx, id)
+	if user := args.Get(0); user != nil {
+		return user.(*model.User), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (r *MockAvatarRepository) UpdateAvatarURL(ctx context.Context, id uuid.UUID, url string) error {
+	return r.Called(ctx, id, url).Error(0)
+}
+
+func encodePNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	img.Set(0, 0, color.RGBA{R: 255, A: 255})
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func encodeJPEG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height)), nil))
+	return buf.Bytes()
+}
+
+func TestAvatarService_Upload(t *testing.T) {
+	errDatabase := errors.New("database error")
+	tests := []struct {
+		name     string
+		data     func(*testing.T) []byte
+		hasOld   bool
+		mockFn   func(*MockAvatarRepository, *model.User)
+		wantErr  error
+		wantExt  string
+		wantSize image.Point
+	}{
+		{
+			name: "png is scaled down",
+			data: func(t *testing.T) []byte { return encodePNG(t, 400, 200) },
+			mockFn: func(repo *MockAvatarRepository, user *model.User) {
+				repo.On("FindByID", mock.Anything, user.ID.String()).Return(user.Clone(), nil)
+				repo.On("UpdateAvatarURL", mock.Anything, user.ID, mock.AnythingOfType("string")).Return(nil)
+			},
+			wantExt:  ".png",
+			wantSize: image.Pt(100, 50),
+		},
+		{
+			name:   "jpeg replaces the previous avatar",
+			data:   func(t *testing.T) []byte { return encodeJPEG(t, 50, 80) },
+			hasOld: true,
+			mockFn: func(repo *MockAvatarRepository, user *model.User) {
+				repo.On("FindByID", mock.Anything, user.ID.String()).Return(user.Clone(), nil)
+				repo.On("UpdateAvatarURL", mock.Anything, user.ID, mock.AnythingOfType("string")).Return(nil)
+			},
+			wantExt:  ".jpg",
+			wantSize: image.Pt(50, 80),
+		},
+		{
+			name:    "too large",
+			data:    func(*testing.T) []byte { return make([]byte, MaxAvatarBytes+1) },
+			mockFn:  func(*MockAvatarRepository, *model.User) {},
+			wantErr: ErrAvatarTooLarge,
+		},
+		{
+			name:    "not an image",
+			data:    func(*testing.T) []byte { return []byte("GIF89a not really") },
+			mockFn:  func(*MockAvatarRepository, *model.User) {},
+			wantErr: ErrUnsupportedImage,
+		},
+		{
+			name: "truncated png",
+			data: func(t *testing.T) []byte {
+				data := encodePNG(t, 10, 10)
+				return data[:len(data)/2]
+			},
+			mockFn:  func(*MockAvatarRepository, *model.User) {},
+			wantErr: ErrUnsupportedImage,
+		},
+		{
+			name:    "too many pixels",
+			data:    func(t *testing.T) []byte { return encodePNG(t, 4097, 4096) },
+			mockFn:  func(*MockAvatarRepository, *model.User) {},
+			wantErr: ErrUnsupportedImage,
+		},
+		{
+			name: "update fails and the new blob is removed",
+			data: func(t *testing.T) []byte { return encodePNG(t, 10, 10) },
+			mockFn: func(repo *MockAvatarRepository, user *model.User) {
+				repo.On("FindByID", mock.Anything, user.ID.String()).Return(user.Clone(), nil)
+				repo.On("UpdateAvatarURL", mock.Anything, user.ID, mock.AnythingOfType("string")).Return(errDatabase)
+			},
+			wantErr: errDatabase,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			store := storage.NewLocal(dir, "/avatars")
+
+			user := testutil.NewMockUser()
+			var err error
+			var oldURL string
+			if tt.hasOld {
+				oldURL, err = store.Put(context.Background(), "old.png", []byte("old"), "image/png")
+				require.NoError(t, err)
+				user.AvatarURL = &oldURL
+			}
+
+			repo := new(MockAvatarRepository)
+			tt.mockFn(repo, &user)
+			s := NewAvatarService(repo, store, 100, events.Discard)
+
+			updated, err := s.Upload(context.Background(), user.ID.String(), tt.data(t))
+
+			entries, readErr := os.ReadDir(dir)
+			require.NoError(t, readErr)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, updated)
+				assert.Empty(t, entries)
+				repo.AssertExpectations(t)
+				return
+			}
+
+			require.NoError(t, err)
+			require.NotNil(t, updated.AvatarURL)
+			assert.True(t, strings.HasPrefix(*updated.AvatarURL, "/avatars/"+user.ID.String()+"-"))
+			assert.True(t, strings.HasSuffix(*updated.AvatarURL, tt.wantExt))
+			repo.AssertCalled(t, "UpdateAvatarURL", mock.Anything, user.ID, *updated.AvatarURL)
+
+			require.Len(t, entries, 1, "the previous avatar is deleted")
+			stored, err := os.ReadFile(filepath.Join(dir, entries[0].Name()))
+			require.NoError(t, err)
+			config, _, err := image.DecodeConfig(bytes.NewReader(stored))
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantSize, image.Pt(config.Width, config.Height))
+			repo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAvatarService_UploadInvalidUserID(t *testing.T) {
+	s := NewAvatarService(new(MockAvatarRepository), storage.NewLocal(t.TempDir(), "/avatars"), 100, events.Discard)
+
+	_, err := s.Upload(context.Background(), "not-a-uuid", encodePNG(t, 10, 10))
+
+	assert.ErrorIs(t, err, ErrInvalidUserID)
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+)
+
+var ErrPasswordBreached = errors.New("password has appeared in a data breach, choose a different one")
+
+// BreachChecker reports how many times a password appears in known data breaches.
+type BreachChecker interface {
+	BreachCount(ctx context.Context, password string) (int, error)
+}
+
+// WithBreachChecker makes registration and password changes reject passwords
+// that checker has seen in more than maxCount breaches.
+func WithBreachChecker(checker BreachChecker, maxCount int) AuthOption {
+	return func(s *AuthService) {
+		s.breachChecker = checker
+		s.maxBreachCount = maxCount
+	}
+}
+
+// checkBreached returns ErrPasswordBreached if password has been seen in too
+// many breaches. It fails open: when the checker cannot be reached the
+// password is accepted and a warning is logged, so an outage of the breach
+// corpus never blocks signups.
+func (s *AuthService) checkBreached(ctx context.Context, password string) error {
+	if s.breachChecker == nil {
+		return nil
+	}
+
+	count, err := s.breachChecker.BreachCount(ctx, password)
+	if err != nil {
+		slog.WarnContext(ctx, "breached password check failed, accepting password", "error", err)
+		return nil
+	}
+	if count > s.maxBreachCount {
+		return ErrPasswordBreached
+	}
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type fakeBreachChecker struct {
+	count int
+	err   error
+	calls []string
+}
+
+func (f *fakeBreachChecker) BreachCount(_ context.Context, password string) (int, error) {
+	f.calls = append(f.calls, password)
+	return f.count, f.err
+}
+
+func TestAuthService_RegisterBreachCheck(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	input := RegisterInput{Email: mockUser.Email, Password: "password", FullName: mockUser.FullName}
+
+	tests := []struct {
+		name    string
+		checker *fakeBreachChecker
+		wantErr error
+	}{
+		{name: "breached above threshold", checker: &fakeBreachChecker{count: 11}, wantErr: ErrPasswordBreached},
+		{name: "breached at threshold", checker: &fakeBreachChecker{count: 10}},
+		{name: "not breached", checker: &fakeBreachChecker{count: 0}},
+		{name: "checker unavailable fails open", checker: &fakeBreachChecker{err: errors.New("timeout")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			service := newTestAuthService(mockRepo, new(MockTokenRepository), WithBreachChecker(tt.checker, 10))
+			mockRepo.On("FindByEmail", mock.Anything, mockUser.Email).Return(nil, repository.ErrNotFound)
+			if tt.wantErr == nil {
+				mockRepo.On("CreateWithOutbox", mock.Anything, mock.AnythingOfType("*model.User")).Return(nil)
+			}
+
+			user, err := service.Register(context.Background(), input)
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.wantErr == nil, user != nil)
+			assert.Equal(t, []string{"password"}, tt.checker.calls)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthService_ChangePassword(t *testing.T) {
+	mockUser := testutil.NewMockUser(testutil.WithPassword("password"))
+	input := ChangePasswordInput{CurrentPassword: "password", NewPassword: "new-password"}
+
+	tests := []struct {
+		name    string
+		input   ChangePasswordInput
+		checker *fakeBreachChecker
+		mockFn  func(*MockRepository)
+		wantErr error
+	}{
+		{
+			name:    "password changed",
+			input:   input,
+			checker: &fakeBreachChecker{},
+			mockFn: func(repo *MockRepository) {
+				repo.On("FindByIDWithPassword", mock.Anything, mockUser.ID.String()).Return(&mockUser, nil)
+				repo.On("UpdatePassword", mock.Anything, mockUser.ID, mock.MatchedBy(func(hash string) bool {
+					return hash == testutil.FastHash("new-password")
+				}), mock.Anything).Return(nil)
+			},
+		},
+		{
+			name:    "wrong current password",
+			input:   ChangePasswordInput{CurrentPassword: "wrong", NewPassword: "new-password"},
+			checker: &fakeBreachChecker{},
+			mockFn: func(repo *MockRepository) {
+				repo.On("FindByIDWithPassword", mock.Anything, mockUser.ID.String()).Return(&mockUser, nil)
+			},
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name:    "breached new password",
+			input:   input,
+			checker: &fakeBreachChecker{count: 50},
+			mockFn: func(repo *MockRepository) {
+				repo.On("FindByIDWithPassword", mock.Anything, mockUser.ID.String()).Return(&mockUser, nil)
+			},
+			wantErr: ErrPasswordBreached,
+		},
+		{
+			name:    "user not found",
+			input:   input,
+			checker: &fakeBreachChecker{},
+			mockFn: func(repo *MockRepository) {
+				repo.On("FindByIDWithPassword", mock.Anything, mockUser.ID.String()).Return(nil, repository.ErrNotFound)
+			},
+			wantErr: repository.ErrNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			service := newTestAuthService(mockRepo, new(MockTokenRepository), WithBreachChecker(tt.checker, 0))
+			tt.mockFn(mockRepo)
+
+			err := service.ChangePassword(context.Background(), mockUser.ID.String(), tt.input)
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Clock tells the time tokens are issued and validated at, so that tests can
+// control it.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the Clock of the operating system.
+type SystemClock struct{}
+
+// Now returns time.Now().
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// ErrTokenNotValidYet is returned by CheckTokenTimes for a token whose "nbf"
+// or "iat" claim is in the future.
+var ErrTokenNotValidYet = errors.New("token is not valid yet")
+
+// CheckTokenTimes checks the "exp", "nbf" and "iat" claims of a token against
+// now, tolerating skew of difference between the clock that issued the token
+// and the one checking it: a token is accepted until skew after it expires,
+// and from skew before it becomes valid. A token that expires exactly at now
+// is expired when skew is zero. Missing claims are not checked. It returns
+// jwt.ErrTokenExpired for an expired token and ErrTokenNotValidYet for one
+// that is not valid yet.
+func CheckTokenTimes(claims jwt.MapClaims, now time.Time, skew time.Duration) error {
+	if !claims.VerifyExpiresAt(now.Add(-skew).Unix(), false) {
+		return jwt.ErrTokenExpired
+	}
+	if !claims.VerifyNotBefore(now.Add(skew).Unix(), false) {
+		return ErrTokenNotValidYet
+	}
+	if !claims.VerifyIssuedAt(now.Add(skew).Unix(), false) {
+		return ErrTokenNotValidYet
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckTokenTimes(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	// at returns a claim d from now, as a float64 like claims parsed from JSON.
+	at := func(d time.Duration) float64 { return float64(now.Add(d).Unix()) }
+
+	tests := []struct {
+		name    string
+		claims  jwt.MapClaims
+		skew    time.Duration
+		wantErr error
+	}{
+		{name: "valid", claims: jwt.MapClaims{"iat": at(0), "exp": at(time.Hour)}},
+		{name: "no time claims", claims: jwt.MapClaims{}},
+		{name: "expiring exactly now", claims: jwt.MapClaims{"exp": at(0)}, wantErr: jwt.ErrTokenExpired},
+		{name: "expiring exactly now within skew", claims: jwt.MapClaims{"exp": at(0)}, skew: time.Second},
+		{name: "expired at the skew", claims: jwt.MapClaims{"exp": at(-30 * time.Second)}, skew: 30 * time.Second, wantErr: jwt.ErrTokenExpired},
+		{name: "expired within skew", claims: jwt.MapClaims{"exp": at(-29 * time.Second)}, skew: 30 * time.Second},
+		{name: "not before in 1s", claims: jwt.MapClaims{"nbf": at(time.Second)}, wantErr: ErrTokenNotValidYet},
+		{name: "not before in 1s within skew", claims: jwt.MapClaims{"nbf": at(time.Second)}, skew: time.Second},
+		{name: "not before now", claims: jwt.MapClaims{"nbf": at(0)}},
+		{name: "issued in 1s", claims: jwt.MapClaims{"iat": at(time.Second)}, wantErr: ErrTokenNotValidYet},
+		{name: "issued in 1s within skew", claims: jwt.MapClaims{"iat": at(time.Second)}, skew: time.Second},
+		{name: "malformed exp", claims: jwt.MapClaims{"exp": "tomorrow"}, wantErr: jwt.ErrTokenExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckTokenTimes(tt.claims, now, tt.skew)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
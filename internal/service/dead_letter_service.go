@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidEmailID     = errors.New("invalid email id")
+	ErrDeadLetterNotFound = errors.New("dead-lettered email not found")
+)
+
+type DeadLetterRepository interface {
+	ListDead(ctx context.Context, cursor string, limit int) ([]model.QueuedEmail, string, error)
+	Retry(ctx context.Context, id uuid.UUID, at time.Time) error
+}
+
+// DeadLetterService lets admins inspect the emails that the email worker
+// gave up on and send them again.
+type DeadLetterService struct {
+	repo  DeadLetterRepository
+	clock Clock
+}
+
+func NewDeadLetterService(repo DeadLetterRepository, clock Clock) *DeadLetterService {
+	return &DeadLetterService{repo: repo, clock: clock}
+}
+
+// List returns a page of dead-lettered emails, newest first, along with the
+// cursor for the next page.
+func (s *DeadLetterService) List(ctx context.Context, cursor string, limit int) ([]model.QueuedEmail, string, error) {
+	if limit < 1 || limit > MaxListLimit {
+		return nil, "", ErrInvalidLimit
+	}
+	return s.repo.ListDead(ctx, cursor, limit)
+}
+
+// Retry queues the dead-lettered email identified by id again, with its
+// attempts reset, so the email worker sends it at its next run.
+func (s *DeadLetterService) Retry(ctx context.Context, id string) error {
+	emailID, err := uuid.Parse(id)
+	if err != nil {
+		return ErrInvalidEmailID
+	}
+
+	err = s.repo.Retry(ctx, emailID, s.clock.Now())
+	if errors.Is(err, repository.ErrNotFound) {
+		return fmt.Errorf("%w: %w", ErrDeadLetterNotFound, err)
+	}
+	return err
+}
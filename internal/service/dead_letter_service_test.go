@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockDeadLetterRepository struct {
+	mock.Mock
+}
+
+func (r *MockDeadLetterRepository) ListDead(ctx context.Context, cursor string, limit int) ([]model.QueuedEmail, string, error) {
+	args := r.Called(ctx, cursor, limit)
+	if args.Get(0) == nil {
+		return nil, args.String(1), args.Error(2)
+	}
+	return args.Get(0).([]model.QueuedEmail), args.String(1), args.Error(2)
+}
+
+func (r *MockDeadLetterRepository) Retry(ctx context.Context, id uuid.UUID, at time.Time) error {
+	args := r.Called(ctx, id, at)
+	return args.Error(0)
+}
+
+func TestDeadLetterService_List(t *testing.T) {
+	email := model.QueuedEmail{ID: uuid.New(), Recipient: "test@example.com", Status: model.EmailDead}
+
+	tests := []struct {
+		name           string
+		limit          int
+		mockFn         func(*MockDeadLetterRepository)
+		wantEmails     []model.QueuedEmail
+		wantNextCursor string
+		wantErr        error
+	}{
+		{
+			name:  "lists dead letters",
+			limit: 10,
+			mockFn: func(repo *MockDeadLetterRepository) {
+				repo.On("ListDead", mock.Anything, "cursor", 10).Return([]model.QueuedEmail{email}, "next", nil)
+			},
+			wantEmails:     []model.QueuedEmail{email},
+			wantNextCursor: "next",
+		},
+		{
+			name:    "limit out of range",
+			limit:   101,
+			wantErr: ErrInvalidLimit,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockDeadLetterRepository)
+			if tt.mockFn != nil {
+				tt.mockFn(mockRepo)
+			}
+
+			emails, nextCursor, err := NewDeadLetterService(mockRepo, SystemClock{}).List(context.Background(), "cursor", tt.limit)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, emails)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantEmails, emails)
+				assert.Equal(t, tt.wantNextCursor, nextCursor)
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestDeadLetterService_Retry(t *testing.T) {
+	emailID := uuid.New()
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		id      string
+		mockFn  func(*MockDeadLetterRepository)
+		wantErr error
+	}{
+		{
+			name: "requeues the email",
+			id:   emailID.String(),
+			mockFn: func(repo *MockDeadLetterRepository) {
+				repo.On("Retry", mock.Anything, emailID, now).Return(nil)
+			},
+		},
+		{
+			name:    "invalid id",
+			id:      "not-a-uuid",
+			wantErr: ErrInvalidEmailID,
+		},
+		{
+			name: "not a dead letter",
+			id:   emailID.String(),
+			mockFn: func(repo *MockDeadLetterRepository) {
+				repo.On("Retry", mock.Anything, emailID, mock.Anything).Return(repository.ErrNotFound)
+			},
+			wantErr: ErrDeadLetterNotFound,
+		},
+		{
+			name: "repository error",
+			id:   emailID.String(),
+			mockFn: func(repo *MockDeadLetterRepository) {
+				repo.On("Retry", mock.Anything, emailID, mock.Anything).Return(repository.ErrTimeout)
+			},
+			wantErr: repository.ErrTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockDeadLetterRepository)
+			if tt.mockFn != nil {
+				tt.mockFn(mockRepo)
+			}
+
+			err := NewDeadLetterService(mockRepo, testutil.NewFakeClock(now)).Retry(context.Background(), tt.id)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
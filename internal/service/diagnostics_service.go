@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Statuses of a subsystem in a DiagnosticsReport.
+const (
+	DiagnosticOK    = "ok"
+	DiagnosticError = "error"
+)
+
+// DiagnosticProvider reports the state of a subsystem for the diagnostics
+// endpoint. Diagnose must return quickly from what the subsystem already
+// holds in memory: it must not query the database or call other services.
+type DiagnosticProvider interface {
+	Diagnose(ctx context.Context) (any, error)
+}
+
+// DiagnosticFunc adapts a function to a DiagnosticProvider.
+type DiagnosticFunc func(ctx context.Context) (any, error)
+
+// Diagnose calls f.
+func (f DiagnosticFunc) Diagnose(ctx context.Context) (any, error) {
+	return f(ctx)
+}
+
+// SubsystemDiagnostics is the state a DiagnosticProvider reported. Details is
+// set when Status is DiagnosticOK and Error when it is DiagnosticError.
+type SubsystemDiagnostics struct {
+	Status  string `json:"status"`
+	Details any    `json:"details,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// DiagnosticsReport describes the process and, by name, each registered
+// subsystem.
+type DiagnosticsReport struct {
+	StartedAt      time.Time                       `json:"started_at"`
+	UptimeSeconds  int64                           `json:"uptime_seconds"`
+	GoVersion      string                          `json:"go_version"`
+	Goroutines     int                             `json:"goroutines"`
+	HeapInUseBytes uint64                          `json:"heap_in_use_bytes"`
+	Subsystems     map[string]SubsystemDiagnostics `json:"subsystems"`
+}
+
+// DiagnosticsService assembles the self-diagnostics of this replica from the
+// process and the providers registered by its subsystems.
+type DiagnosticsService struct {
+	startedAt time.Time
+	now       func() time.Time
+
+	mu        sync.RWMutex
+	providers map[string]DiagnosticProvider
+}
+
+// NewDiagnosticsService creates a DiagnosticsService for a process started at
+// startedAt, with no providers.
+func NewDiagnosticsService(startedAt time.Time) *DiagnosticsService {
+	return &DiagnosticsService{
+		startedAt: startedAt,
+		now:       time.Now,
+		providers: make(map[string]DiagnosticProvider),
+	}
+}
+
+// Register adds provider to the report under name. It panics if a provider
+// was already registered under name, as that is a wiring mistake.
+func (s *DiagnosticsService) Register(name string, provider DiagnosticProvider) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.providers[name]; ok {
+		panic(fmt.Sprintf("diagnostic provider %q registered twice", name))
+	}
+	s.providers[name] = provider
+}
+
+// Report returns the current diagnostics. A provider that fails or panics is
+// reported with its error rather than failing the report.
+func (s *DiagnosticsService) Report(ctx context.Context) DiagnosticsReport {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	report := DiagnosticsReport{
+		StartedAt:      s.startedAt.UTC(),
+		UptimeSeconds:  int64(s.now().Sub(s.startedAt).Seconds()),
+		GoVersion:      runtime.Version(),
+		Goroutines:     runtime.NumGoroutine(),
+		HeapInUseBytes: mem.HeapInuse,
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	report.Subsystems = make(map[string]SubsystemDiagnostics, len(s.providers))
+	for name, provider := range s.providers {
+		details, err := diagnose(ctx, provider)
+		if err != nil {
+			report.Subsystems[name] = SubsystemDiagnostics{Status: DiagnosticError, Error: err.Error()}
+			continue
+		}
+		report.Subsystems[name] = SubsystemDiagnostics{Status: DiagnosticOK, Details: details}
+	}
+	return report
+}
+
+// diagnose calls provider, turning a panic into an error.
+func diagnose(ctx context.Context, provider DiagnosticProvider) (details any, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("provider panicked: %v", recovered)
+		}
+	}()
+	return provider.Diagnose(ctx)
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiagnosticsService_Report(t *testing.T) {
+	startedAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	s := NewDiagnosticsService(startedAt)
+	s.now = func() time.Time { return startedAt.Add(90*time.Minute + 500*time.Millisecond) }
+
+	s.Register("cache", DiagnosticFunc(func(context.Context) (any, error) {
+		return map[string]float64{"hit_ratio": 0.75}, nil
+	}))
+	s.Register("broker", DiagnosticFunc(func(context.Context) (any, error) {
+		return nil, errors.New("connection refused")
+	}))
+	s.Register("flaky", DiagnosticFunc(func(context.Context) (any, error) {
+		panic("nil map")
+	}))
+
+	report := s.Report(context.Background())
+
+	assert.Equal(t, startedAt, report.StartedAt)
+	assert.Equal(t, int64(5400), report.UptimeSeconds)
+	assert.Equal(t, runtime.Version(), report.GoVersion)
+	assert.Positive(t, report.Goroutines)
+	assert.Positive(t, report.HeapInUseBytes)
+	assert.Equal(t, map[string]SubsystemDiagnostics{
+		"cache":  {Status: DiagnosticOK, Details: map[string]float64{"hit_ratio": 0.75}},
+		"broker": {Status: DiagnosticError, Error: "connection refused"},
+		"flaky":  {Status: DiagnosticError, Error: "provider panicked: nil map"},
+	}, report.Subsystems)
+}
+
+func TestDiagnosticsService_ReportWithoutProviders(t *testing.T) {
+	s := NewDiagnosticsService(time.Now())
+
+	body, err := json.Marshal(s.Report(context.Background()))
+	require.NoError(t, err)
+
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal(body, &decoded))
+	assert.Equal(t, map[string]any{}, decoded["subsystems"], "subsystems must be an object, never null")
+}
+
+func TestDiagnosticsService_RegisterTwicePanics(t *testing.T) {
+	s := NewDiagnosticsService(time.Now())
+	provider := DiagnosticFunc(func(context.Context) (any, error) { return nil, nil })
+	s.Register("jobs", provider)
+
+	assert.PanicsWithValue(t, `diagnostic provider "jobs" registered twice`, func() {
+		s.Register("jobs", provider)
+	})
+}
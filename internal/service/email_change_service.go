@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/events"
+	"github.com/PakornBank/learn-go/internal/mailer"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/google/uuid"
+)
+
+// EmailChangeExpiry is how long the confirmation link of an email change stays valid.
+const EmailChangeExpiry = 24 * time.Hour
+
+var (
+	ErrSameEmail               = errors.New("new email must differ from the current email")
+	ErrInvalidEmailChangeToken = errors.New("invalid or expired email change token")
+)
+
+type EmailChangeUserRepository interface {
+	FindByEmail(ctx context.Context, email string) (*model.User, error)
+	FindByID(ctx context.Context, id string) (*model.User, error)
+	FindByIDWithPassword(ctx context.Context, id string) (*model.User, error)
+	UpdateEmail(ctx context.Context, id uuid.UUID, email string) error
+}
+
+type EmailChangeRepository interface {
+	Replace(ctx context.Context, request *model.EmailChangeRequest) error
+	FindByHash(ctx context.Context, tokenHash string) (*model.EmailChangeRequest, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type EmailChangeInput struct {
+	NewEmail string `json:"new_email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+}
+
+type ConfirmEmailChangeInput struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// EmailChangeService changes users' email addresses in two steps: a change is
+// requested with the current password, and applied once the link sent to the
+// new address is followed. Until then the user keeps their old address.
+type EmailChangeService struct {
+	userRepo   EmailChangeUserRepository
+	changeRepo EmailChangeRepository
+	mailer     mailer.Mailer
+	templates  *mailer.Templates
+	events     events.Publisher
+	passwords  PasswordHasher
+	clock      Clock
+}
+
+// NewEmailChangeService creates an EmailChangeService that mails confirmation
+// links with m, publishes profile updates to publisher, checks passwords
+// with passwords and expires changes at the time told by clock.
+func NewEmailChangeService(userRepo EmailChangeUserRepository, changeRepo EmailChangeRepository, m mailer.Mailer, templates *mailer.Templates, publisher events.Publisher, passwords PasswordHasher, clock Clock) *EmailChangeService {
+	return &EmailChangeService{userRepo: userRepo, changeRepo: changeRepo, mailer: m, templates: templates, events: publisher, passwords: passwords, clock: clock}
+}
+
+// Request starts changing the email of the user identified by userID to
+// input.NewEmail. The user's password is checked, a confirmation token valid
+// for EmailChangeExpiry replaces any pending change, and a link carrying the
+// token is mailed to the new address.
+func (s *EmailChangeService) Request(ctx context.Context, userID string, input EmailChangeInput) error {
+	user, err := s.userRepo.FindByIDWithPassword(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := checkPassword(ctx, s.passwords, user.PasswordHash, input.Password); err != nil {
+		return err
+	}
+
+	if strings.EqualFold(input.NewEmail, user.Email) {
+		return ErrSameEmail
+	}
+	if err := s.ensureEmailAvailable(ctx, input.NewEmail, user.ID); err != nil {
+		return err
+	}
+
+	token, err := generateOpaqueToken()
+	if err != nil {
+		return fmt.Errorf("failed to generate email change token: %w", err)
+	}
+
+	err = s.changeRepo.Replace(ctx, &model.EmailChangeRequest{
+		UserID:    user.ID,
+		NewEmail:  input.NewEmail,
+		TokenHash: hashToken(token),
+		ExpiresAt: s.clock.Now().Add(EmailChangeExpiry),
+	})
+	if err != nil {
+		return err
+	}
+
+	msg, err := s.templates.EmailChange(user.FullName, token)
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, input.NewEmail, msg.Subject, msg.HTML, msg.Text)
+}
+
+// Confirm applies the email change identified by the confirmation token and
+// returns the updated user. The new address is checked for uniqueness again,
+// since another account may have taken it after the change was requested.
+func (s *EmailChangeService) Confirm(ctx context.Context, input ConfirmEmailChangeInput) (*model.User, error) {
+	request, err := s.changeRepo.FindByHash(ctx, hashToken(input.Token))
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidEmailChangeToken, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !s.clock.Now().Before(request.ExpiresAt) {
+		return nil, ErrInvalidEmailChangeToken
+	}
+
+	if err := s.ensureEmailAvailable(ctx, request.NewEmail, request.UserID); err != nil {
+		return nil, err
+	}
+
+	// The address may have been taken since it was checked above.
+	err = s.userRepo.UpdateEmail(ctx, request.UserID, request.NewEmail)
+	if errors.Is(err, repository.ErrDuplicate) {
+		return nil, fmt.Errorf("%w: %w", ErrEmailTaken, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.changeRepo.Delete(ctx, request.ID); err != nil {
+		return nil, err
+	}
+	s.events.Publish(request.UserID.String(), profileUpdated("email"))
+
+	return s.userRepo.FindByID(ctx, request.UserID.String())
+}
+
+// ensureEmailAvailable returns ErrEmailTaken if email belongs to a
+// user other than userID.
+func (s *EmailChangeService) ensureEmailAvailable(ctx context.Context, email string, userID uuid.UUID) error {
+	existing, err := s.userRepo.FindByEmail(ctx, email)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+	if existing != nil && existing.ID != userID {
+		return ErrEmailTaken
+	}
+	return nil
+}
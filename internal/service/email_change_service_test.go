@@ -0,0 +1,278 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/events"
+	"github.com/PakornBank/learn-go/internal/mailer"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockEmailChangeRepository struct {
+	mock.Mock
+}
+
+func (r *MockEmailChangeRepository) Replace(ctx context.Context, request *model.EmailChangeRequest) error {
+	args := r.Called(ctx, request)
+	return args.Error(0)
+}
+
+func (r *MockEmailChangeRepository) FindByHash(ctx context.Context, tokenHash string) (*model.EmailChangeRequest, error) {
+	args := r.Called(ctx, tokenHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.EmailChangeRequest), args.Error(1)
+}
+
+func (r *MockEmailChangeRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	args := r.Called(ctx, id)
+	return args.Error(0)
+}
+
+type sentEmail struct {
+	to, subject, html, text string
+}
+
+type fakeMailer struct {
+	sent []sentEmail
+	err  error
+}
+
+func (m *fakeMailer) Send(_ context.Context, to, subject, htmlBody, textBody string) error {
+	m.sent = append(m.sent, sentEmail{to: to, subject: subject, html: htmlBody, text: textBody})
+	return m.err
+}
+
+// tokenFromEmail extracts the token from the confirmation link in a sent email.
+func tokenFromEmail(t *testing.T, email sentEmail) string {
+	t.Helper()
+	for _, line := range strings.Split(email.text, "\n") {
+		if strings.HasPrefix(line, "http") {
+			link, err := url.Parse(strings.TrimSpace(line))
+			require.NoError(t, err)
+			return link.Query().Get("token")
+		}
+	}
+	t.Fatal("no link in email")
+	return ""
+}
+
+func setupEmailChangeTest() (*EmailChangeService, *MockRepository, *MockEmailChangeRepository, *fakeMailer) {
+	userRepo := new(MockRepository)
+	changeRepo := new(MockEmailChangeRepository)
+	m := &fakeMailer{}
+	s := NewEmailChangeService(userRepo, changeRepo, m, mailer.NewTemplates("https://app.example.com"), events.Discard, testutil.FastHasher{}, SystemClock{})
+	return s, userRepo, changeRepo, m
+}
+
+func TestEmailChangeService_Request(t *testing.T) {
+	mockUser := testutil.NewMockUser(testutil.WithPassword("password"))
+	const newEmail = "new@example.com"
+	errSMTP := errors.New("smtp unavailable")
+
+	tests := []struct {
+		name       string
+		input      EmailChangeInput
+		mockFn     func(*MockRepository, *MockEmailChangeRepository)
+		mailErr    error
+		wantErr    error
+		wantMailed bool
+	}{
+		{
+			name:  "change requested",
+			input: EmailChangeInput{NewEmail: newEmail, Password: "password"},
+			mockFn: func(userRepo *MockRepository, changeRepo *MockEmailChangeRepository) {
+				userRepo.On("FindByIDWithPassword", mock.Anything, mockUser.ID.String()).Return(&mockUser, nil)
+				userRepo.On("FindByEmail", mock.Anything, newEmail).Return(nil, repository.ErrNotFound)
+				changeRepo.On("Replace", mock.Anything, mock.MatchedBy(func(r *model.EmailChangeRequest) bool {
+					return r.UserID == mockUser.ID &&
+						r.NewEmail == newEmail &&
+						len(r.TokenHash) == 64 &&
+						time.Until(r.ExpiresAt) > EmailChangeExpiry-time.Minute
+				})).Return(nil)
+			},
+			wantMailed: true,
+		},
+		{
+			name:  "wrong password",
+			input: EmailChangeInput{NewEmail: newEmail, Password: "wrong-password"},
+			mockFn: func(userRepo *MockRepository, _ *MockEmailChangeRepository) {
+				userRepo.On("FindByIDWithPassword", mock.Anything, mockUser.ID.String()).Return(&mockUser, nil)
+			},
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name:  "same email",
+			input: EmailChangeInput{NewEmail: strings.ToUpper(mockUser.Email), Password: "password"},
+			mockFn: func(userRepo *MockRepository, _ *MockEmailChangeRepository) {
+				userRepo.On("FindByIDWithPassword", mock.Anything, mockUser.ID.String()).Return(&mockUser, nil)
+			},
+			wantErr: ErrSameEmail,
+		},
+		{
+			name:  "email taken",
+			input: EmailChangeInput{NewEmail: newEmail, Password: "password"},
+			mockFn: func(userRepo *MockRepository, _ *MockEmailChangeRepository) {
+				other := testutil.NewMockUser()
+				userRepo.On("FindByIDWithPassword", mock.Anything, mockUser.ID.String()).Return(&mockUser, nil)
+				userRepo.On("FindByEmail", mock.Anything, newEmail).Return(&other, nil)
+			},
+			wantErr: ErrEmailTaken,
+		},
+		{
+			name:  "database timeout",
+			input: EmailChangeInput{NewEmail: newEmail, Password: "password"},
+			mockFn: func(userRepo *MockRepository, _ *MockEmailChangeRepository) {
+				userRepo.On("FindByIDWithPassword", mock.Anything, mockUser.ID.String()).Return(nil, repository.ErrTimeout)
+			},
+			wantErr: repository.ErrTimeout,
+		},
+		{
+			name:  "mail failure",
+			input: EmailChangeInput{NewEmail: newEmail, Password: "password"},
+			mockFn: func(userRepo *MockRepository, changeRepo *MockEmailChangeRepository) {
+				userRepo.On("FindByIDWithPassword", mock.Anything, mockUser.ID.String()).Return(&mockUser, nil)
+				userRepo.On("FindByEmail", mock.Anything, newEmail).Return(nil, repository.ErrNotFound)
+				changeRepo.On("Replace", mock.Anything, mock.Anything).Return(nil)
+			},
+			mailErr:    errSMTP,
+			wantErr:    errSMTP,
+			wantMailed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, userRepo, changeRepo, m := setupEmailChangeTest()
+			m.err = tt.mailErr
+			tt.mockFn(userRepo, changeRepo)
+
+			err := s.Request(context.Background(), mockUser.ID.String(), tt.input)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			if tt.wantMailed {
+				require.Len(t, m.sent, 1)
+				assert.Equal(t, newEmail, m.sent[0].to)
+				assert.Equal(t, "Confirm your new email address", m.sent[0].subject)
+
+				replaced := changeRepo.Calls[0].Arguments.Get(1).(*model.EmailChangeRequest)
+				assert.Equal(t, replaced.TokenHash, hashToken(tokenFromEmail(t, m.sent[0])))
+			} else {
+				assert.Empty(t, m.sent)
+			}
+			userRepo.AssertExpectations(t)
+			changeRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestEmailChangeService_Confirm(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	const token = "confirmation-token"
+	newRequest := func(expiresAt time.Time) *model.EmailChangeRequest {
+		return &model.EmailChangeRequest{
+			ID:        uuid.New(),
+			UserID:    mockUser.ID,
+			NewEmail:  "new@example.com",
+			TokenHash: hashToken(token),
+			ExpiresAt: expiresAt,
+		}
+	}
+	valid := newRequest(time.Now().Add(time.Hour))
+	updated := mockUser
+	updated.Email = valid.NewEmail
+
+	tests := []struct {
+		name     string
+		mockFn   func(*MockRepository, *MockEmailChangeRepository)
+		wantUser *model.User
+		wantErr  error
+	}{
+		{
+			name: "change applied",
+			mockFn: func(userRepo *MockRepository, changeRepo *MockEmailChangeRepository) {
+				changeRepo.On("FindByHash", mock.Anything, hashToken(token)).Return(valid, nil)
+				userRepo.On("FindByEmail", mock.Anything, valid.NewEmail).Return(nil, repository.ErrNotFound)
+				userRepo.On("UpdateEmail", mock.Anything, mockUser.ID, valid.NewEmail).Return(nil)
+				changeRepo.On("Delete", mock.Anything, valid.ID).Return(nil)
+				userRepo.On("FindByID", mock.Anything, mockUser.ID.String()).Return(&updated, nil)
+			},
+			wantUser: &updated,
+		},
+		{
+			name: "unknown token",
+			mockFn: func(_ *MockRepository, changeRepo *MockEmailChangeRepository) {
+				changeRepo.On("FindByHash", mock.Anything, hashToken(token)).Return(nil, repository.ErrNotFound)
+			},
+			wantErr: ErrInvalidEmailChangeToken,
+		},
+		{
+			name: "expired token",
+			mockFn: func(_ *MockRepository, changeRepo *MockEmailChangeRepository) {
+				changeRepo.On("FindByHash", mock.Anything, hashToken(token)).Return(newRequest(time.Now().Add(-time.Minute)), nil)
+			},
+			wantErr: ErrInvalidEmailChangeToken,
+		},
+		{
+			name: "email taken since the request",
+			mockFn: func(userRepo *MockRepository, changeRepo *MockEmailChangeRepository) {
+				other := testutil.NewMockUser()
+				changeRepo.On("FindByHash", mock.Anything, hashToken(token)).Return(valid, nil)
+				userRepo.On("FindByEmail", mock.Anything, valid.NewEmail).Return(&other, nil)
+			},
+			wantErr: ErrEmailTaken,
+		},
+		{
+			name: "email taken since the check",
+			mockFn: func(userRepo *MockRepository, changeRepo *MockEmailChangeRepository) {
+				changeRepo.On("FindByHash", mock.Anything, hashToken(token)).Return(valid, nil)
+				userRepo.On("FindByEmail", mock.Anything, valid.NewEmail).Return(nil, repository.ErrNotFound)
+				userRepo.On("UpdateEmail", mock.Anything, mockUser.ID, valid.NewEmail).Return(repository.ErrDuplicate)
+			},
+			wantErr: ErrEmailTaken,
+		},
+		{
+			name: "database timeout",
+			mockFn: func(_ *MockRepository, changeRepo *MockEmailChangeRepository) {
+				changeRepo.On("FindByHash", mock.Anything, hashToken(token)).Return(nil, repository.ErrTimeout)
+			},
+			wantErr: repository.ErrTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, userRepo, changeRepo, _ := setupEmailChangeTest()
+			tt.mockFn(userRepo, changeRepo)
+
+			user, err := s.Confirm(context.Background(), ConfirmEmailChangeInput{Token: token})
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, user)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantUser, user)
+			}
+			userRepo.AssertExpectations(t)
+			changeRepo.AssertExpectations(t)
+		})
+	}
+}
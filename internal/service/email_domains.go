@@ -0,0 +1,35 @@
+package service
+
+import "strings"
+
+// emailDomainAllowed reports whether the domain of email is in allowed, or
+// allowed is empty. The domain is compared lowercased and without the
+// trailing dot of a fully qualified name, and must equal an entry of allowed
+// exactly: "example.com" allows user@example.com but not
+// user@mail.example.com. An entry starting with a dot, such as
+// ".example.com", allows the domain after the dot and any of its subdomains.
+// Entries are expected to be lowercase, as config.LoadConfig leaves them.
+func emailDomainAllowed(email string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(email[at+1:])), ".")
+	if domain == "" {
+		return false
+	}
+
+	for _, entry := range allowed {
+		if parent, ok := strings.CutPrefix(entry, "."); ok {
+			if domain == parent || strings.HasSuffix(domain, entry) {
+				return true
+			}
+		} else if domain == entry {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmailDomainAllowed(t *testing.T) {
+	tests := []struct {
+		name    string
+		email   string
+		allowed []string
+		want    bool
+	}{
+		{name: "empty list allows every domain", email: "user@anywhere.com", want: true},
+		{name: "exact match", email: "user@corp.com", allowed: []string{"corp.com"}, want: true},
+		{name: "exact match among several", email: "user@corp.com", allowed: []string{"other.com", "corp.com"}, want: true},
+		{name: "other domain", email: "user@gmail.com", allowed: []string{"corp.com"}},
+		{name: "subdomain of exact entry", email: "user@mail.corp.com", allowed: []string{"corp.com"}},
+		{name: "subdomain of dot entry", email: "user@mail.corp.com", allowed: []string{".corp.com"}, want: true},
+		{name: "nested subdomain of dot entry", email: "user@eu.mail.corp.com", allowed: []string{".corp.com"}, want: true},
+		{name: "domain of dot entry", email: "user@corp.com", allowed: []string{".corp.com"}, want: true},
+		{name: "lookalike of dot entry", email: "user@evilcorp.com", allowed: []string{".corp.com"}},
+		{name: "parent of entry", email: "user@corp.com", allowed: []string{"mail.corp.com"}},
+		{name: "uppercase email", email: "User@CORP.com", allowed: []string{"corp.com"}, want: true},
+		{name: "uppercase subdomain", email: "user@Mail.Corp.COM", allowed: []string{".corp.com"}, want: true},
+		{name: "trailing dot", email: "user@corp.com.", allowed: []string{"corp.com"}, want: true},
+		{name: "domain in local part", email: "corp.com@gmail.com", allowed: []string{"corp.com"}},
+		{name: "no domain", email: "user@", allowed: []string{"corp.com"}},
+		{name: "not an email", email: "corp.com", allowed: []string{"corp.com"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, emailDomainAllowed(tt.email, tt.allowed))
+		})
+	}
+}
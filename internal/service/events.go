@@ -0,0 +1,8 @@
+package service
+
+import "github.com/PakornBank/learn-go/internal/events"
+
+// profileUpdated returns the event published when field of a user's profile changes.
+func profileUpdated(field string) events.Event {
+	return events.Event{Type: events.TypeProfileUpdated, Data: map[string]string{"field": field}}
+}
@@ -0,0 +1,246 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/google/uuid"
+)
+
+// IdentityLinkExpiry is how long a user has to finish linking a provider
+// account once they started.
+const IdentityLinkExpiry = 10 * time.Minute
+
+// identityLinkPurpose is signed along with the state of a link, so that it
+// cannot be mistaken for anything else signed with the same secret.
+const identityLinkPurpose = "identity-link:"
+
+// linkNonceSize is the number of random bytes in the nonce that ties the
+// state of a link to the browser that started it.
+const linkNonceSize = 16
+
+var (
+	ErrUnknownProvider       = errors.New("unknown identity provider")
+	ErrInvalidLinkState      = errors.New("invalid or expired link state")
+	ErrIdentityTaken         = errors.New("this provider account is already linked to another user")
+	ErrProviderAlreadyLinked = errors.New("an account of this provider is already linked, unlink it first")
+	ErrProviderExchange      = errors.New("identity provider rejected the authorization")
+)
+
+// ProviderIdentity is the account at an IdentityProvider that granted an
+// authorization.
+type ProviderIdentity struct {
+	// ID is the ID the provider gives the account, which never changes.
+	ID string
+	// Email is the email of the account, or empty if the provider did not share it.
+	Email string
+}
+
+// IdentityProvider is an OAuth provider whose accounts users can link.
+type IdentityProvider interface {
+	// AuthCodeURL returns the URL of the provider's consent page, which
+	// redirects back to the link callback with a code and state.
+	AuthCodeURL(state string) string
+	// Exchange returns the account that granted code.
+	Exchange(ctx context.Context, code string) (ProviderIdentity, error)
+}
+
+type IdentityRepository interface {
+	Create(ctx context.Context, identity *model.Identity) error
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]model.Identity, error)
+	FindByProviderAccount(ctx context.Context, provider, providerUserID string) (*model.Identity, error)
+	Unlink(ctx context.Context, userID uuid.UUID, provider string) error
+}
+
+type CompleteLinkInput struct {
+	Code  string `form:"code" binding:"required"`
+	State string `form:"state" binding:"required"`
+	// Nonce is the value of the cookie set when the link was started.
+	Nonce string `form:"-"`
+}
+
+// IdentityService manages the OAuth identities users link to their accounts.
+// Linking goes through the provider's consent page: the user is sent there
+// with a signed state carrying who is linking, and the provider sends them
+// back with a code for their account.
+type IdentityService struct {
+	identityRepo IdentityRepository
+	providers    map[string]IdentityProvider
+	secret       []byte
+	clock        Clock
+}
+
+// NewIdentityService creates an IdentityService linking accounts of
+// providers, keyed by name, signing link states with secret and expiring
+// them at the time told by clock.
+func NewIdentityService(identityRepo IdentityRepository, providers map[string]IdentityProvider, secret string, clock Clock) *IdentityService {
+	return &IdentityService{identityRepo: identityRepo, providers: providers, secret: []byte(secret), clock: clock}
+}
+
+// List returns the identities linked to the user identified by userID.
+func (s *IdentityService) List(ctx context.Context, userID string) ([]model.Identity, error) {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidUserID, err)
+	}
+	return s.identityRepo.ListByUser(ctx, id)
+}
+
+// StartLink starts linking an account of provider to the user identified by
+// userID. It returns the URL of the provider's consent page, carrying a state
+// valid for IdentityLinkExpiry, and a nonce the state is bound to, which the
+// browser must present along with the state to finish linking, so that the
+// state of one user cannot be used to link an account to another.
+func (s *IdentityService) StartLink(userID, provider string) (authURL, nonce string, err error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return "", "", ErrUnknownProvider
+	}
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %w", ErrInvalidUserID, err)
+	}
+
+	n := make([]byte, linkNonceSize)
+	if _, err := rand.Read(n); err != nil {
+		return "", "", fmt.Errorf("failed to generate link nonce: %w", err)
+	}
+	expiresAt := binary.BigEndian.AppendUint64(nil, uint64(s.clock.Now().Add(IdentityLinkExpiry).Unix()))
+	payload := append(id[:], expiresAt...)
+	state := append(payload, s.sign(provider, payload, n)...)
+	return p.AuthCodeURL(base64.RawURLEncoding.EncodeToString(state)), base64.RawURLEncoding.EncodeToString(n), nil
+}
+
+// CompleteLink links the account of provider that granted input.Code to the
+// user who started linking it. It returns ErrInvalidLinkState if the state
+// was not issued by StartLink for provider and input.Nonce, or has expired,
+// ErrIdentityTaken if the account is linked to another user, and
+// ErrProviderAlreadyLinked if the user already linked another account of
+// provider. Linking the account a user already linked returns its identity.
+func (s *IdentityService) CompleteLink(ctx context.Context, provider string, input CompleteLinkInput) (*model.Identity, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	userID, err := s.verifyState(provider, input.State, input.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	account, err := p.Exchange(ctx, input.Code)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrProviderExchange, err)
+	}
+
+	existing, err := s.findLink(ctx, userID, provider, account.ID)
+	if err != nil || existing != nil {
+		return existing, err
+	}
+
+	identity := &model.Identity{
+		UserID:          userID,
+		Provider:        provider,
+		ProviderUserID:  account.ID,
+		EmailAtLinkTime: account.Email,
+		CreatedAt:       s.clock.Now(),
+	}
+	if err := s.identityRepo.Create(ctx, identity); err != nil {
+		if !errors.Is(err, repository.ErrDuplicate) {
+			return nil, err
+		}
+		// Linked by another request since the checks above. Checking again
+		// tells which of the account and the user's link to provider it took.
+		existing, checkErr := s.findLink(ctx, userID, provider, account.ID)
+		switch {
+		case checkErr == nil && existing != nil:
+			return existing, nil
+		case errors.Is(checkErr, ErrProviderAlreadyLinked):
+			return nil, fmt.Errorf("%w: %w", ErrProviderAlreadyLinked, err)
+		default:
+			return nil, fmt.Errorf("%w: %w", ErrIdentityTaken, err)
+		}
+	}
+	return identity, nil
+}
+
+// findLink returns the identity if the user userID already linked the
+// account accountID of provider. It returns ErrIdentityTaken if the account
+// is linked to another user, ErrProviderAlreadyLinked if the user linked
+// another account of provider, and neither an identity nor an error if the
+// account can be linked.
+func (s *IdentityService) findLink(ctx context.Context, userID uuid.UUID, provider, accountID string) (*model.Identity, error) {
+	existing, err := s.identityRepo.FindByProviderAccount(ctx, provider, accountID)
+	switch {
+	case err == nil && existing.UserID == userID:
+		return existing, nil
+	case err == nil:
+		return nil, ErrIdentityTaken
+	case !errors.Is(err, repository.ErrNotFound):
+		return nil, err
+	}
+
+	identities, err := s.identityRepo.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, identity := range identities {
+		if identity.Provider == provider {
+			return nil, ErrProviderAlreadyLinked
+		}
+	}
+	return nil, nil
+}
+
+// Unlink removes the identity of provider from the user identified by
+// userID. It returns repository.ErrNotFound if the user has not linked an
+// account of provider, and repository.ErrLastSignInMethod if the user has no
+// password and it is their last identity.
+func (s *IdentityService) Unlink(ctx context.Context, userID, provider string) error {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidUserID, err)
+	}
+	return s.identityRepo.Unlink(ctx, id, provider)
+}
+
+// verifyState returns the ID of the user a link state was issued to.
+func (s *IdentityService) verifyState(provider, state, nonce string) (uuid.UUID, error) {
+	const size = len(uuid.UUID{}) + 8
+	decoded, err := base64.RawURLEncoding.DecodeString(state)
+	if err != nil || len(decoded) != size+sha256.Size {
+		return uuid.Nil, ErrInvalidLinkState
+	}
+	n, err := base64.RawURLEncoding.DecodeString(nonce)
+	if err != nil || len(n) != linkNonceSize || !hmac.Equal(decoded[size:], s.sign(provider, decoded[:size], n)) {
+		return uuid.Nil, ErrInvalidLinkState
+	}
+	expiresAt := time.Unix(int64(binary.BigEndian.Uint64(decoded[len(uuid.UUID{}):size])), 0)
+	if !s.clock.Now().Before(expiresAt) {
+		return uuid.Nil, ErrInvalidLinkState
+	}
+	id, err := uuid.FromBytes(decoded[:len(uuid.UUID{})])
+	if err != nil {
+		return uuid.Nil, ErrInvalidLinkState
+	}
+	return id, nil
+}
+
+// sign returns the signature of the user ID and expiry of a link state for
+// provider, bound to nonce.
+func (s *IdentityService) sign(provider string, payload, nonce []byte) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(identityLinkPurpose))
+	mac.Write([]byte(provider + ":"))
+	mac.Write(nonce)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,272 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockIdentityRepository struct {
+	mock.Mock
+}
+
+func (r *MockIdentityRepository) Create(ctx context.Context, identity *model.Identity) error {
+	return r.Called(ctx, identity).Error(0)
+}
+
+func (r *MockIdentityRepository) ListByUser(ctx context.Context, userID uuid.UUID) ([]model.Identity, error) {
+	args := r.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.Identity), args.Error(1)
+}
+
+func (r *MockIdentityRepository) FindByProviderAccount(ctx context.Context, provider, providerUserID string) (*model.Identity, error) {
+	args := r.Called(ctx, provider, providerUserID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Identity), args.Error(1)
+}
+
+func (r *MockIdentityRepository) Unlink(ctx context.Context, userID uuid.UUID, provider string) error {
+	return r.Called(ctx, userID, provider).Error(0)
+}
+
+// fakeIdentityProvider is an IdentityProvider whose consent page is at
+// https://provider.example/authorize and whose codes are the IDs of accounts.
+type fakeIdentityProvider struct {
+	accounts map[string]ProviderIdentity
+}
+
+func (p fakeIdentityProvider) AuthCodeURL(state string) string {
+	return "https://provider.example/authorize?state=" + url.QueryEscape(state)
+}
+
+func (p fakeIdentityProvider) Exchange(_ context.Context, code string) (ProviderIdentity, error) {
+	account, ok := p.accounts[code]
+	if !ok {
+		return ProviderIdentity{}, errors.New("invalid_grant")
+	}
+	return account, nil
+}
+
+// newTestIdentityService creates an IdentityService with a "github" provider
+// that knows the account with ID "42", at a fixed time.
+func newTestIdentityService(repo IdentityRepository, now time.Time) *IdentityService {
+	return NewIdentityService(repo, map[string]IdentityProvider{
+		"github": fakeIdentityProvider{accounts: map[string]ProviderIdentity{"42": {ID: "42", Email: "octo@example.com"}}},
+		"gitlab": fakeIdentityProvider{},
+	}, "test-secret", testutil.NewFakeClock(now))
+}
+
+// startLink starts linking a github account for userID and returns the
+// state from the consent page URL along with the nonce.
+func startLink(t *testing.T, s *IdentityService, userID uuid.UUID) (string, string) {
+	t.Helper()
+	authURL, nonce, err := s.StartLink(userID.String(), "github")
+	require.NoError(t, err)
+	parsed, err := url.Parse(authURL)
+	require.NoError(t, err)
+	return parsed.Query().Get("state"), nonce
+}
+
+func TestIdentityService_StartLink(t *testing.T) {
+	s := newTestIdentityService(new(MockIdentityRepository), time.Now())
+
+	_, _, err := s.StartLink(uuid.NewString(), "myspace")
+	assert.ErrorIs(t, err, ErrUnknownProvider)
+
+	_, _, err = s.StartLink("not-a-uuid", "github")
+	assert.ErrorIs(t, err, ErrInvalidUserID)
+
+	first, firstNonce := startLink(t, s, uuid.New())
+	second, secondNonce := startLink(t, s, uuid.New())
+	assert.NotEqual(t, first, second)
+	assert.NotEqual(t, firstNonce, secondNonce)
+}
+
+func TestIdentityService_CompleteLink(t *testing.T) {
+	userID := uuid.New()
+	otherUserID := uuid.New()
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	linked := &model.Identity{ID: uuid.New(), UserID: userID, Provider: "github", ProviderUserID: "42"}
+
+	tests := []struct {
+		name         string
+		provider     string
+		code         string
+		tamper       func(state, nonce string) (string, string)
+		completeAt   time.Time
+		mockFn       func(*MockIdentityRepository)
+		wantIdentity *model.Identity
+		wantErr      error
+	}{
+		{
+			name: "links the account",
+			mockFn: func(repo *MockIdentityRepository) {
+				repo.On("FindByProviderAccount", mock.Anything, "github", "42").Return(nil, repository.ErrNotFound)
+				repo.On("ListByUser", mock.Anything, userID).Return([]model.Identity{{Provider: "gitlab"}}, nil)
+				repo.On("Create", mock.Anything, mock.MatchedBy(func(identity *model.Identity) bool {
+					return identity.UserID == userID && identity.Provider == "github" &&
+						identity.ProviderUserID == "42" && identity.EmailAtLinkTime == "octo@example.com"
+				})).Return(nil)
+			},
+			wantIdentity: &model.Identity{UserID: userID, Provider: "github", ProviderUserID: "42", EmailAtLinkTime: "octo@example.com", CreatedAt: now},
+		},
+		{
+			name: "account already linked to the user",
+			mockFn: func(repo *MockIdentityRepository) {
+				repo.On("FindByProviderAccount", mock.Anything, "github", "42").Return(linked, nil)
+			},
+			wantIdentity: linked,
+		},
+		{
+			name: "account linked to another user",
+			mockFn: func(repo *MockIdentityRepository) {
+				repo.On("FindByProviderAccount", mock.Anything, "github", "42").
+					Return(&model.Identity{UserID: otherUserID, Provider: "github", ProviderUserID: "42"}, nil)
+			},
+			wantErr: ErrIdentityTaken,
+		},
+		{
+			name: "account linked to another user since the check",
+			mockFn: func(repo *MockIdentityRepository) {
+				repo.On("FindByProviderAccount", mock.Anything, "github", "42").Return(nil, repository.ErrNotFound).Once()
+				repo.On("FindByProviderAccount", mock.Anything, "github", "42").
+					Return(&model.Identity{UserID: otherUserID, Provider: "github", ProviderUserID: "42"}, nil).Once()
+				repo.On("ListByUser", mock.Anything, userID).Return(nil, nil)
+				repo.On("Create", mock.Anything, mock.Anything).Return(repository.ErrDuplicate)
+			},
+			wantErr: ErrIdentityTaken,
+		},
+		{
+			name: "another account of the provider linked since the check",
+			mockFn: func(repo *MockIdentityRepository) {
+				repo.On("FindByProviderAccount", mock.Anything, "github", "42").Return(nil, repository.ErrNotFound)
+				repo.On("ListByUser", mock.Anything, userID).Return(nil, nil).Once()
+				repo.On("ListByUser", mock.Anything, userID).Return([]model.Identity{{Provider: "github", ProviderUserID: "7"}}, nil).Once()
+				repo.On("Create", mock.Anything, mock.Anything).Return(repository.ErrDuplicate)
+			},
+			wantErr: ErrProviderAlreadyLinked,
+		},
+		{
+			name: "another account of the provider already linked",
+			mockFn: func(repo *MockIdentityRepository) {
+				repo.On("FindByProviderAccount", mock.Anything, "github", "42").Return(nil, repository.ErrNotFound)
+				repo.On("ListByUser", mock.Anything, userID).Return([]model.Identity{{Provider: "github", ProviderUserID: "7"}}, nil)
+			},
+			wantErr: ErrProviderAlreadyLinked,
+		},
+		{
+			name:    "code rejected by the provider",
+			code:    "expired-code",
+			wantErr: ErrProviderExchange,
+		},
+		{
+			name:     "unknown provider",
+			provider: "myspace",
+			wantErr:  ErrUnknownProvider,
+		},
+		{
+			name:     "state of another provider",
+			provider: "gitlab",
+			wantErr:  ErrInvalidLinkState,
+		},
+		{
+			name:    "nonce of another browser",
+			tamper:  func(state, _ string) (string, string) { return state, "AAAAAAAAAAAAAAAAAAAAAA" },
+			wantErr: ErrInvalidLinkState,
+		},
+		{
+			name:    "missing nonce",
+			tamper:  func(state, _ string) (string, string) { return state, "" },
+			wantErr: ErrInvalidLinkState,
+		},
+		{
+			name:    "malformed state",
+			tamper:  func(_, nonce string) (string, string) { return "not-a-state", nonce },
+			wantErr: ErrInvalidLinkState,
+		},
+		{
+			name:       "expired state",
+			completeAt: now.Add(IdentityLinkExpiry),
+			wantErr:    ErrInvalidLinkState,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockIdentityRepository)
+			if tt.mockFn != nil {
+				tt.mockFn(mockRepo)
+			}
+			s := newTestIdentityService(mockRepo, now)
+			state, nonce := startLink(t, s, userID)
+			if tt.tamper != nil {
+				state, nonce = tt.tamper(state, nonce)
+			}
+			if !tt.completeAt.IsZero() {
+				s.clock = testutil.NewFakeClock(tt.completeAt)
+			}
+			provider, code := "github", "42"
+			if tt.provider != "" {
+				provider = tt.provider
+			}
+			if tt.code != "" {
+				code = tt.code
+			}
+
+			identity, err := s.CompleteLink(context.Background(), provider, CompleteLinkInput{Code: code, State: state, Nonce: nonce})
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, identity)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.wantIdentity, identity)
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestIdentityService_Unlink(t *testing.T) {
+	userID := uuid.New()
+	mockRepo := new(MockIdentityRepository)
+	mockRepo.On("Unlink", mock.Anything, userID, "github").Return(repository.ErrLastSignInMethod)
+	s := newTestIdentityService(mockRepo, time.Now())
+
+	err := s.Unlink(context.Background(), userID.String(), "github")
+	assert.ErrorIs(t, err, repository.ErrLastSignInMethod)
+
+	err = s.Unlink(context.Background(), "not-a-uuid", "github")
+	assert.ErrorIs(t, err, ErrInvalidUserID)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestIdentityService_List(t *testing.T) {
+	userID := uuid.New()
+	identities := []model.Identity{{Provider: "github", ProviderUserID: "42"}}
+	mockRepo := new(MockIdentityRepository)
+	mockRepo.On("ListByUser", mock.Anything, userID).Return(identities, nil)
+	s := newTestIdentityService(mockRepo, time.Now())
+
+	got, err := s.List(context.Background(), userID.String())
+	require.NoError(t, err)
+	assert.Equal(t, identities, got)
+
+	_, err = s.List(context.Background(), "not-a-uuid")
+	assert.ErrorIs(t, err, ErrInvalidUserID)
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/google/uuid"
+	"golang.org/x/text/language"
+)
+
+// The locale and time zone of users who did not choose one and whose client
+// did not send a usable Accept-Language header.
+const (
+	DefaultLocale   = "en"
+	DefaultTimezone = "UTC"
+)
+
+// anyLanguage is what the "*" of an Accept-Language header parses to.
+var anyLanguage = language.Make("mul")
+
+var (
+	ErrInvalidLocale   = errors.New("locale must be a BCP 47 language tag")
+	ErrInvalidTimezone = errors.New("timezone must be an IANA time zone name")
+)
+
+// UpdateProfileInput changes the settings of a user's profile. Fields left
+// nil keep their current value.
+type UpdateProfileInput struct {
+	Locale   *string `json:"locale" binding:"omitempty,bcp47_language_tag"`
+	Timezone *string `json:"timezone" binding:"omitempty,timezone"`
+}
+
+// UpdateProfile changes the locale and time zone of the user with userID and
+// returns the updated user. Access tokens issued before the change carry the
+// old values until they are refreshed.
+func (s *AuthService) UpdateProfile(ctx context.Context, userID string, input UpdateProfileInput) (*model.User, error) {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, ErrInvalidUserID
+	}
+
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %w", ErrUserNotFound, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	locale, timezone := user.Locale, user.Timezone
+	if input.Locale != nil {
+		if locale, err = normalizeLocale(*input.Locale); err != nil {
+			return nil, err
+		}
+	}
+	if input.Timezone != nil {
+		if err := validateTimezone(*input.Timezone); err != nil {
+			return nil, err
+		}
+		timezone = *input.Timezone
+	}
+
+	if locale == user.Locale && timezone == user.Timezone {
+		return user, nil
+	}
+	if err := s.userRepo.UpdateLocale(ctx, id, locale, timezone); err != nil {
+		return nil, err
+	}
+	user.Locale, user.Timezone = locale, timezone
+	s.events.Publish(userID, profileUpdated("locale"))
+	return user, nil
+}
+
+// registrationLocale returns the locale and time zone a user registers with:
+// the ones they chose, or else the preferred language of their client and
+// DefaultTimezone, since a language says nothing about where its speaker is.
+func registrationLocale(input RegisterInput) (locale, timezone string, err error) {
+	locale = localeFromAcceptLanguage(input.AcceptLanguage)
+	if input.Locale != "" {
+		if locale, err = normalizeLocale(input.Locale); err != nil {
+			return "", "", err
+		}
+	}
+
+	timezone = DefaultTimezone
+	if input.Timezone != "" {
+		if err := validateTimezone(input.Timezone); err != nil {
+			return "", "", err
+		}
+		timezone = input.Timezone
+	}
+
+	return locale, timezone, nil
+}
+
+// normalizeLocale returns locale in its canonical form, such as "en-US" for
+// "en-us", or ErrInvalidLocale if it is not a BCP 47 language tag.
+func normalizeLocale(locale string) (string, error) {
+	tag, err := language.Parse(locale)
+	if err != nil || tag == language.Und {
+		return "", ErrInvalidLocale
+	}
+	return tag.String(), nil
+}
+
+// validateTimezone returns ErrInvalidTimezone unless timezone names a zone of
+// the IANA time zone database. "Local" is rejected, as it means the zone of
+// the server rather than of the user.
+func validateTimezone(timezone string) error {
+	if timezone == "" || strings.EqualFold(timezone, "Local") {
+		return ErrInvalidTimezone
+	}
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return ErrInvalidTimezone
+	}
+	return nil
+}
+
+// localeFromAcceptLanguage returns the language the client prefers most
+// according to an Accept-Language header, or DefaultLocale if the header is
+// empty, malformed or accepts any language.
+func localeFromAcceptLanguage(header string) string {
+	tags, _, err := language.ParseAcceptLanguage(header)
+	if err != nil {
+		return DefaultLocale
+	}
+	for _, tag := range tags {
+		if tag != language.Und && tag != anyLanguage {
+			return tag.String()
+		}
+	}
+	return DefaultLocale
+}
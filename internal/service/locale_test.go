@@ -0,0 +1,265 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeLocale(t *testing.T) {
+	tests := []struct {
+		locale  string
+		want    string
+		wantErr error
+	}{
+		{locale: "en", want: "en"},
+		{locale: "en-us", want: "en-US"},
+		{locale: "th-TH", want: "th-TH"},
+		{locale: "zh-Hant-TW", want: "zh-Hant-TW"},
+		{locale: "", wantErr: ErrInvalidLocale},
+		{locale: "und", wantErr: ErrInvalidLocale},
+		{locale: "not a locale", wantErr: ErrInvalidLocale},
+		{locale: "english-please", wantErr: ErrInvalidLocale},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.locale, func(t *testing.T) {
+			locale, err := normalizeLocale(tt.locale)
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.want, locale)
+		})
+	}
+}
+
+func TestValidateTimezone(t *testing.T) {
+	tests := []struct {
+		timezone string
+		wantErr  error
+	}{
+		{timezone: "UTC"},
+		{timezone: "Asia/Bangkok"},
+		{timezone: "America/Argentina/Buenos_Aires"},
+		{timezone: "", wantErr: ErrInvalidTimezone},
+		{timezone: "Local", wantErr: ErrInvalidTimezone},
+		{timezone: "Mars/Olympus_Mons", wantErr: ErrInvalidTimezone},
+		{timezone: "../../etc/passwd", wantErr: ErrInvalidTimezone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.timezone, func(t *testing.T) {
+			assert.ErrorIs(t, validateTimezone(tt.timezone), tt.wantErr)
+		})
+	}
+}
+
+func TestLocaleFromAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{header: "th-TH,th;q=0.9,en;q=0.8", want: "th-TH"},
+		{header: "en;q=0.5, fr-CA", want: "fr-CA"},
+		{header: "*", want: DefaultLocale},
+		{header: "", want: DefaultLocale},
+		{header: "@@@", want: DefaultLocale},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			assert.Equal(t, tt.want, localeFromAcceptLanguage(tt.header))
+		})
+	}
+}
+
+func TestRegisterInput_LocaleBinding(t *testing.T) {
+	valid := RegisterInput{Email: "test@example.com", Password: "password", FullName: "Test User"}
+	require.NoError(t, binding.Validator.ValidateStruct(valid))
+
+	withLocale := valid
+	withLocale.Locale, withLocale.Timezone = "th-TH", "Asia/Bangkok"
+	assert.NoError(t, binding.Validator.ValidateStruct(withLocale))
+
+	badLocale := valid
+	badLocale.Locale = "not a locale"
+	assert.ErrorContains(t, binding.Validator.ValidateStruct(badLocale), "bcp47_language_tag")
+
+	badTimezone := valid
+	badTimezone.Timezone = "Mars/Olympus_Mons"
+	assert.ErrorContains(t, binding.Validator.ValidateStruct(badTimezone), "timezone")
+}
+
+func TestAuthService_RegisterLocale(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+
+	tests := []struct {
+		name           string
+		locale         string
+		timezone       string
+		acceptLanguage string
+		wantLocale     string
+		wantTimezone   string
+		wantErr        error
+	}{
+		{name: "chosen", locale: "th-th", timezone: "Asia/Bangkok", acceptLanguage: "fr", wantLocale: "th-TH", wantTimezone: "Asia/Bangkok"},
+		{name: "from Accept-Language", acceptLanguage: "de-CH,de;q=0.9", wantLocale: "de-CH", wantTimezone: DefaultTimezone},
+		{name: "defaults", wantLocale: DefaultLocale, wantTimezone: DefaultTimezone},
+		{name: "invalid locale", locale: "not a locale", wantErr: ErrInvalidLocale},
+		{name: "invalid timezone", timezone: "Mars/Olympus_Mons", wantErr: ErrInvalidTimezone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			service := newTestAuthService(mockRepo, new(MockTokenRepository))
+			if tt.wantErr == nil {
+				mockRepo.On("FindByEmail", mock.Anything, mockUser.Email).Return(nil, repository.ErrNotFound)
+				mockRepo.On("CreateWithOutbox", mock.Anything, mock.AnythingOfType("*model.User")).Return(nil)
+			}
+
+			user, err := service.Register(context.Background(), RegisterInput{
+				Email:          mockUser.Email,
+				Password:       "password",
+				FullName:       mockUser.FullName,
+				Locale:         tt.locale,
+				Timezone:       tt.timezone,
+				AcceptLanguage: tt.acceptLanguage,
+			})
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			if tt.wantErr == nil {
+				require.NotNil(t, user)
+				assert.Equal(t, tt.wantLocale, user.Locale)
+				assert.Equal(t, tt.wantTimezone, user.Timezone)
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthService_UpdateProfile(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	mockUser.Locale, mockUser.Timezone = "en", "UTC"
+	str := func(s string) *string { return &s }
+
+	tests := []struct {
+		name         string
+		userID       string
+		input        UpdateProfileInput
+		mockFn       func(*MockRepository)
+		wantLocale   string
+		wantTimezone string
+		wantErr      error
+	}{
+		{
+			name:   "both changed",
+			userID: mockUser.ID.String(),
+			input:  UpdateProfileInput{Locale: str("th-th"), Timezone: str("Asia/Bangkok")},
+			mockFn: func(repo *MockRepository) {
+				repo.On("UpdateLocale", mock.Anything, mockUser.ID, "th-TH", "Asia/Bangkok").Return(nil)
+			},
+			wantLocale:   "th-TH",
+			wantTimezone: "Asia/Bangkok",
+		},
+		{
+			name:   "only time zone changed",
+			userID: mockUser.ID.String(),
+			input:  UpdateProfileInput{Timezone: str("Europe/Berlin")},
+			mockFn: func(repo *MockRepository) {
+				repo.On("UpdateLocale", mock.Anything, mockUser.ID, "en", "Europe/Berlin").Return(nil)
+			},
+			wantLocale:   "en",
+			wantTimezone: "Europe/Berlin",
+		},
+		{
+			name:         "nothing changed",
+			userID:       mockUser.ID.String(),
+			input:        UpdateProfileInput{Locale: str("en")},
+			wantLocale:   "en",
+			wantTimezone: "UTC",
+		},
+		{
+			name:    "invalid locale",
+			userID:  mockUser.ID.String(),
+			input:   UpdateProfileInput{Locale: str("not a locale")},
+			wantErr: ErrInvalidLocale,
+		},
+		{
+			name:    "invalid timezone",
+			userID:  mockUser.ID.String(),
+			input:   UpdateProfileInput{Timezone: str("Local")},
+			wantErr: ErrInvalidTimezone,
+		},
+		{
+			name:   "database timeout",
+			userID: mockUser.ID.String(),
+			input:  UpdateProfileInput{Locale: str("th")},
+			mockFn: func(repo *MockRepository) {
+				repo.On("UpdateLocale", mock.Anything, mockUser.ID, "th", "UTC").Return(repository.ErrTimeout)
+			},
+			wantErr: repository.ErrTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			user := mockUser
+			mockRepo.On("FindByID", mock.Anything, mockUser.ID.String()).Return(&user, nil)
+			if tt.mockFn != nil {
+				tt.mockFn(mockRepo)
+			}
+
+			updated, err := newTestAuthService(mockRepo, new(MockTokenRepository)).UpdateProfile(context.Background(), tt.userID, tt.input)
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			if tt.wantErr == nil {
+				assert.Equal(t, tt.wantLocale, updated.Locale)
+				assert.Equal(t, tt.wantTimezone, updated.Timezone)
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthService_UpdateProfileErrors(t *testing.T) {
+	t.Run("invalid user id", func(t *testing.T) {
+		_, err := newTestAuthService(new(MockRepository), new(MockTokenRepository)).UpdateProfile(context.Background(), "not-a-uuid", UpdateProfileInput{})
+
+		assert.ErrorIs(t, err, ErrInvalidUserID)
+	})
+
+	t.Run("user not found", func(t *testing.T) {
+		mockUser := testutil.NewMockUser()
+		mockRepo := new(MockRepository)
+		mockRepo.On("FindByID", mock.Anything, mockUser.ID.String()).Return(nil, repository.ErrNotFound)
+
+		_, err := newTestAuthService(mockRepo, new(MockTokenRepository)).UpdateProfile(context.Background(), mockUser.ID.String(), UpdateProfileInput{})
+
+		assert.ErrorIs(t, err, ErrUserNotFound)
+	})
+}
+
+func TestGenerateToken_LocaleClaims(t *testing.T) {
+	service, _, _ := setupTest()
+	user := model.User{ID: testutil.NewMockUser().ID, Locale: "th-TH", Timezone: "Asia/Bangkok"}
+
+	token, err := service.generateToken(&user, tokenOptions{})
+	require.NoError(t, err)
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+		return []byte("test-secret"), nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "th-TH", claims["locale"])
+	assert.Equal(t, "Asia/Bangkok", claims["zoneinfo"])
+}
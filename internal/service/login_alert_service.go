@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/google/uuid"
+)
+
+// ErrInvalidLoginAlertToken is returned for the token of a login alert that
+// does not exist, has expired or was already used.
+var ErrInvalidLoginAlertToken = errors.New("invalid or expired login alert token")
+
+// SessionFamilyRevoker revokes the refresh tokens of a session. It is
+// implemented by repository.RefreshTokenRepository.
+type SessionFamilyRevoker interface {
+	RevokeFamily(ctx context.Context, familyID uuid.UUID) error
+}
+
+// PasswordResetter replaces a user's password without asking for the current
+// one. It is implemented by AuthService.
+type PasswordResetter interface {
+	ResetPassword(ctx context.Context, userID uuid.UUID, newPassword string) error
+}
+
+// ReportLoginInput is the token from the "this wasn't me" link of a new
+// device email and the password replacing the user's.
+type ReportLoginInput struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=8"`
+}
+
+// LoginAlertService handles users reporting that a login they were alerted
+// about by NewDeviceNotifier was not them.
+type LoginAlertService struct {
+	alerts    LoginAlertRepository
+	tokens    SessionFamilyRevoker
+	passwords PasswordResetter
+	clock     Clock
+}
+
+// NewLoginAlertService creates a LoginAlertService that revokes reported
+// sessions through tokens, replaces passwords through passwords and expires
+// links by clock.
+func NewLoginAlertService(alerts LoginAlertRepository, tokens SessionFamilyRevoker, passwords PasswordResetter, clock Clock) *LoginAlertService {
+	return &LoginAlertService{alerts: alerts, tokens: tokens, passwords: passwords, clock: clock}
+}
+
+// Report handles the "this wasn't me" link of a new device email. It revokes
+// the session the reported login started, and then replaces the user's
+// password with input.NewPassword, which signs them out everywhere. The link
+// proves access to the mailbox like a password reset link does, so the
+// current password is not needed. A link can be used until it succeeds once,
+// or until LoginAlertExpiry after the login. The alert is deleted before the
+// password is replaced, so that of several uses of the link at once only one
+// replaces it; if replacing it fails, the alert is restored.
+func (s *LoginAlertService) Report(ctx context.Context, input ReportLoginInput) error {
+	alert, err := s.alerts.FindByHash(ctx, hashToken(input.Token))
+	if errors.Is(err, repository.ErrNotFound) {
+		return fmt.Errorf("%w: %w", ErrInvalidLoginAlertToken, err)
+	}
+	if err != nil {
+		return err
+	}
+	if !s.clock.Now().Before(alert.ExpiresAt) {
+		return ErrInvalidLoginAlertToken
+	}
+
+	// The session is revoked first, so that it ends even if the new password
+	// is rejected and the user has to try again.
+	if err := s.tokens.RevokeFamily(ctx, alert.FamilyID); err != nil {
+		return err
+	}
+
+	err = s.alerts.Delete(ctx, alert.ID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return fmt.Errorf("%w: %w", ErrInvalidLoginAlertToken, err)
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.passwords.ResetPassword(ctx, alert.UserID, input.NewPassword); err != nil {
+		if err := s.alerts.Create(context.WithoutCancel(ctx), alert); err != nil {
+			slog.Warn("failed to restore a login alert", "alert_id", alert.ID, "error", err)
+		}
+		return err
+	}
+	return nil
+}
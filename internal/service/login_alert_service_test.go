@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockPasswordResetter struct {
+	mock.Mock
+}
+
+func (r *MockPasswordResetter) ResetPassword(ctx context.Context, userID uuid.UUID, newPassword string) error {
+	args := r.Called(ctx, userID, newPassword)
+	return args.Error(0)
+}
+
+func TestLoginAlertService_Report(t *testing.T) {
+	const token = "report-token"
+	input := ReportLoginInput{Token: token, NewPassword: "new-password"}
+	dbErr := errors.New("connection reset")
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	newAlert := func() *model.LoginAlert {
+		return &model.LoginAlert{
+			ID:        uuid.New(),
+			UserID:    uuid.New(),
+			FamilyID:  uuid.New(),
+			TokenHash: hashToken(token),
+			ExpiresAt: now.Add(time.Hour),
+		}
+	}
+
+	tests := []struct {
+		name    string
+		mockFn  func(*MockLoginAlertRepository, *MockDeviceHistoryRepository, *MockPasswordResetter)
+		wantErr error
+	}{
+		{
+			name: "session revoked and password reset",
+			mockFn: func(alerts *MockLoginAlertRepository, tokens *MockDeviceHistoryRepository, passwords *MockPasswordResetter) {
+				alert := newAlert()
+				alerts.On("FindByHash", mock.Anything, hashToken(token)).Return(alert, nil)
+				tokens.On("RevokeFamily", mock.Anything, alert.FamilyID).Return(nil)
+				alerts.On("Delete", mock.Anything, alert.ID).Return(nil)
+				passwords.On("ResetPassword", mock.Anything, alert.UserID, "new-password").Return(nil)
+			},
+		},
+		{
+			name: "unknown token",
+			mockFn: func(alerts *MockLoginAlertRepository, tokens *MockDeviceHistoryRepository, passwords *MockPasswordResetter) {
+				alerts.On("FindByHash", mock.Anything, hashToken(token)).Return(nil, repository.ErrNotFound)
+			},
+			wantErr: ErrInvalidLoginAlertToken,
+		},
+		{
+			name: "expired token",
+			mockFn: func(alerts *MockLoginAlertRepository, tokens *MockDeviceHistoryRepository, passwords *MockPasswordResetter) {
+				alert := newAlert()
+				alert.ExpiresAt = now
+				alerts.On("FindByHash", mock.Anything, hashToken(token)).Return(alert, nil)
+			},
+			wantErr: ErrInvalidLoginAlertToken,
+		},
+		{
+			name: "breached password keeps the link usable",
+			mockFn: func(alerts *MockLoginAlertRepository, tokens *MockDeviceHistoryRepository, passwords *MockPasswordResetter) {
+				alert := newAlert()
+				alerts.On("FindByHash", mock.Anything, hashToken(token)).Return(alert, nil)
+				tokens.On("RevokeFamily", mock.Anything, alert.FamilyID).Return(nil)
+				alerts.On("Delete", mock.Anything, alert.ID).Return(nil)
+				passwords.On("ResetPassword", mock.Anything, alert.UserID, "new-password").Return(ErrPasswordBreached)
+				alerts.On("Create", mock.Anything, alert).Return(nil)
+			},
+			wantErr: ErrPasswordBreached,
+		},
+		{
+			name: "link used concurrently",
+			mockFn: func(alerts *MockLoginAlertRepository, tokens *MockDeviceHistoryRepository, passwords *MockPasswordResetter) {
+				alert := newAlert()
+				alerts.On("FindByHash", mock.Anything, hashToken(token)).Return(alert, nil)
+				tokens.On("RevokeFamily", mock.Anything, alert.FamilyID).Return(nil)
+				alerts.On("Delete", mock.Anything, alert.ID).Return(repository.ErrNotFound)
+			},
+			wantErr: ErrInvalidLoginAlertToken,
+		},
+		{
+			name: "revocation fails",
+			mockFn: func(alerts *MockLoginAlertRepository, tokens *MockDeviceHistoryRepository, passwords *MockPasswordResetter) {
+				alert := newAlert()
+				alerts.On("FindByHash", mock.Anything, hashToken(token)).Return(alert, nil)
+				tokens.On("RevokeFamily", mock.Anything, alert.FamilyID).Return(repository.ErrTimeout)
+			},
+			wantErr: repository.ErrTimeout,
+		},
+		{
+			name: "database error",
+			mockFn: func(alerts *MockLoginAlertRepository, tokens *MockDeviceHistoryRepository, passwords *MockPasswordResetter) {
+				alerts.On("FindByHash", mock.Anything, hashToken(token)).Return(nil, dbErr)
+			},
+			wantErr: dbErr,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			alerts := new(MockLoginAlertRepository)
+			tokens := new(MockDeviceHistoryRepository)
+			passwords := new(MockPasswordResetter)
+			tt.mockFn(alerts, tokens, passwords)
+			service := NewLoginAlertService(alerts, tokens, passwords, testutil.NewFakeClock(now))
+
+			err := service.Report(context.Background(), input)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			alerts.AssertExpectations(t)
+			tokens.AssertExpectations(t)
+			passwords.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// dummyPasswordHash is a bcrypt hash, at the default cost, of a password no
+// account has. Logins naming an unknown user check their password against it,
+// so that they take as long as those with a wrong password and do not reveal
+// which accounts exist.
+const dummyPasswordHash = "$2a$10$EirC6xOjNVEWa42xt.I0ducKik2myhvFdcZP7w1BuhodKGjHhrKNO"
+
+// WithSleep makes the service wait out the delay of failed logins with sleep
+// rather than a timer, so that tests can advance a fake clock instead.
+func WithSleep(sleep func(ctx context.Context, d time.Duration)) AuthOption {
+	return func(s *AuthService) {
+		s.sleep = sleep
+	}
+}
+
+// delayFailure waits before a login fails for wrong credentials, making each
+// guess of a password cost the attacker at least that long. The delay is
+// LOGIN_FAILURE_DELAY, varied at random by up to LOGIN_FAILURE_JITTER either
+// way so that it cannot be told apart from other work. It returns early once
+// ctx is done, so that abandoned requests do not linger.
+func (s *AuthService) delayFailure(ctx context.Context) {
+	if s.failureDelay <= 0 {
+		return
+	}
+	delay := s.failureDelay
+	if s.failureJitter > 0 {
+		delay += time.Duration((2*s.random() - 1) * float64(s.failureJitter))
+	}
+	s.sleep(ctx, delay)
+}
+
+// sleepContext waits for d or until ctx is done, whichever comes first.
+func sleepContext(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+// randomFraction returns a number in [0, 1) to vary the delay of failed
+// logins by, which need not be unpredictable.
+func randomFraction() float64 {
+	return rand.Float64()
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/config"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// slowHasher is a testutil.FastHasher whose comparisons take cost on clock,
+// standing in for the time bcrypt takes.
+type slowHasher struct {
+	testutil.FastHasher
+	clock *testutil.FakeClock
+	cost  time.Duration
+}
+
+func (h slowHasher) Compare(ctx context.Context, hash, password string) error {
+	h.clock.Advance(h.cost)
+	return h.FastHasher.Compare(ctx, hash, password)
+}
+
+// recordingHasher is a testutil.FastHasher that records the hashes passwords
+// are compared against.
+type recordingHasher struct {
+	testutil.FastHasher
+	hashes *[]string
+}
+
+func (h recordingHasher) Compare(ctx context.Context, hash, password string) error {
+	*h.hashes = append(*h.hashes, hash)
+	return h.FastHasher.Compare(ctx, hash, password)
+}
+
+// fakeSleep returns a sleep for WithSleep that advances clock instead of
+// waiting, recording each delay in delays.
+func fakeSleep(clock *testutil.FakeClock, delays *[]time.Duration) func(context.Context, time.Duration) {
+	return func(_ context.Context, d time.Duration) {
+		*delays = append(*delays, d)
+		clock.Advance(d)
+	}
+}
+
+// newLoginDelayTestService creates an AuthService whose password checks take
+// 80ms on clock and whose failed logins wait delay, varied by up to jitter.
+func newLoginDelayTestService(userRepo Repository, clock *testutil.FakeClock, delay, jitter time.Duration, delays *[]time.Duration) *AuthService {
+	config := newTestConfig()
+	config.LoginFailureDelay = delay
+	config.LoginFailureJitter = jitter
+	return NewAuthService(userRepo, new(MockTokenRepository), config,
+		WithPasswordHasher(slowHasher{clock: clock, cost: 80 * time.Millisecond}),
+		WithClock(clock),
+		WithSleep(fakeSleep(clock, delays)),
+	)
+}
+
+func TestAuthService_LoginFailureTiming(t *testing.T) {
+	const tolerance = time.Millisecond
+	user := testutil.NewMockUser(testutil.WithPassword("password"))
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	expiredUser := testutil.NewMockUser(testutil.WithEmail("expired@example.com"), testutil.WithPassword("password"))
+	expiredUser.PasswordChangedAt = now.Add(-2 * passwordMaxAge)
+
+	elapsed := func(t *testing.T, email, password string, wantErr error) time.Duration {
+		clock := testutil.NewFakeClock(now)
+		mockRepo := new(MockRepository)
+		switch email {
+		case user.Email:
+			mockRepo.On("FindByEmail", mock.Anything, email).Return(&user, nil)
+		case expiredUser.Email:
+			mockRepo.On("FindByEmail", mock.Anything, email).Return(&expiredUser, nil)
+		default:
+			mockRepo.On("FindByEmail", mock.Anything, email).Return(nil, repository.ErrNotFound)
+		}
+		var delays []time.Duration
+		service := newLoginDelayTestService(mockRepo, clock, 500*time.Millisecond, 0, &delays)
+		service.passwordMaxAge = passwordMaxAge
+		service.passwordExpiryMode = config.PasswordExpiryStrict
+		start := clock.Now()
+
+		_, err := service.Login(context.Background(), LoginInput{Email: email, Password: password})
+
+		assert.ErrorIs(t, err, wantErr)
+		assert.Equal(t, []time.Duration{500 * time.Millisecond}, delays)
+		return clock.Now().Sub(start)
+	}
+
+	unknownUser := elapsed(t, "nobody@example.com", "wrong-password", ErrInvalidCredentials)
+	wrongPassword := elapsed(t, user.Email, "wrong-password", ErrInvalidCredentials)
+	expiredPassword := elapsed(t, expiredUser.Email, "password", ErrPasswordExpired)
+
+	assert.Equal(t, 580*time.Millisecond, wrongPassword, "the password check and the delay")
+	assert.InDelta(t, wrongPassword, unknownUser, float64(tolerance),
+		"an unknown user takes as long as a wrong password")
+	assert.InDelta(t, wrongPassword, expiredPassword, float64(tolerance),
+		"an expired password in strict mode takes as long as a wrong password")
+}
+
+func TestAuthService_LoginWithoutPassword(t *testing.T) {
+	user := testutil.NewMockUser()
+	user.PasswordHash = ""
+	mockRepo := new(MockRepository)
+	mockRepo.On("FindByEmail", mock.Anything, user.Email).Return(&user, nil)
+	var hashes []string
+	service := NewAuthService(mockRepo, new(MockTokenRepository), newTestConfig(),
+		WithPasswordHasher(recordingHasher{hashes: &hashes}),
+	)
+
+	_, err := service.Login(context.Background(), LoginInput{Email: user.Email, Password: "password"})
+
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	assert.Equal(t, []string{dummyPasswordHash}, hashes, "the password is checked against the dummy hash")
+}
+
+func TestAuthService_LoginFailureDelay(t *testing.T) {
+	user := testutil.NewMockUser(testutil.WithPassword("password"))
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+
+	t.Run("jitter either way", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		mockRepo.On("FindByEmail", mock.Anything, user.Email).Return(&user, nil)
+		var delays []time.Duration
+		service := newLoginDelayTestService(mockRepo, testutil.NewFakeClock(now), 500*time.Millisecond, 100*time.Millisecond, &delays)
+
+		for _, fraction := range []float64{0, 0.5, 0.75} {
+			service.random = func() float64 { return fraction }
+			_, err := service.Login(context.Background(), LoginInput{Email: user.Email, Password: "wrong-password"})
+			assert.ErrorIs(t, err, ErrInvalidCredentials)
+		}
+
+		assert.Equal(t, []time.Duration{400 * time.Millisecond, 500 * time.Millisecond, 550 * time.Millisecond}, delays)
+	})
+
+	t.Run("successful login", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		mockRepo.On("FindByEmail", mock.Anything, user.Email).Return(&user, nil)
+		mockRepo.On("UpdateLastLogin", mock.Anything, user.ID, mock.Anything).Return(nil)
+		var delays []time.Duration
+		service := newLoginDelayTestService(mockRepo, testutil.NewFakeClock(now), 500*time.Millisecond, 100*time.Millisecond, &delays)
+		tokenRepo := service.tokenRepo.(*MockTokenRepository)
+		tokenRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+
+		_, err := service.Login(context.Background(), LoginInput{Email: user.Email, Password: "password"})
+
+		require.NoError(t, err)
+		assert.Empty(t, delays, "only failures are delayed")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		mockRepo.On("FindByEmail", mock.Anything, user.Email).Return(nil, repository.ErrNotFound)
+		var delays []time.Duration
+		service := newLoginDelayTestService(mockRepo, testutil.NewFakeClock(now), 0, 0, &delays)
+
+		_, err := service.Login(context.Background(), LoginInput{Email: user.Email, Password: "password"})
+
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+		assert.Empty(t, delays)
+	})
+
+	t.Run("cancelled request", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		mockRepo.On("FindByEmail", mock.Anything, user.Email).Return(&user, nil)
+		config := newTestConfig()
+		config.LoginFailureDelay = time.Hour
+		service := NewAuthService(mockRepo, new(MockTokenRepository), config, WithPasswordHasher(testutil.FastHasher{}))
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		_, err := service.Login(ctx, LoginInput{Email: user.Email, Password: "wrong-password"})
+
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+		assert.Less(t, time.Since(start), time.Second, "the delay ends with the request")
+	})
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+
+	"github.com/PakornBank/learn-go/internal/model"
+)
+
+// DefaultLoginEventBuffer is the number of login events that can be queued
+// before LoginEventWriter starts dropping them.
+const DefaultLoginEventBuffer = 256
+
+// LoginRecorder records login attempts. Implementations must not block the caller.
+type LoginRecorder interface {
+	Record(event *model.LoginEvent)
+}
+
+type LoginEventStore interface {
+	Create(ctx context.Context, event *model.LoginEvent) error
+}
+
+type noopLoginRecorder struct{}
+
+func (noopLoginRecorder) Record(*model.LoginEvent) {}
+
+// LoginEventWriter persists login events on a background goroutine so that
+// recording an attempt never delays or fails the login itself. Events are
+// queued on a buffered channel; when the buffer is full, new events are
+// dropped and an error is logged.
+type LoginEventWriter struct {
+	writer *asyncWriter[*model.LoginEvent]
+}
+
+// NewLoginEventWriter creates a LoginEventWriter with room for bufferSize queued
+// events and starts its background writer. Call Close to flush and stop it.
+func NewLoginEventWriter(store LoginEventStore, bufferSize int) *LoginEventWriter {
+	return &LoginEventWriter{
+		writer: newAsyncWriter("login event", bufferSize, 0, store.Create, func(event *model.LoginEvent) []any {
+			return []any{"email", event.Email}
+		}),
+	}
+}
+
+// Record queues event for writing. It never blocks: if the buffer is full or
+// the writer has been closed, the event is dropped.
+func (w *LoginEventWriter) Record(event *model.LoginEvent) {
+	w.writer.Record(event)
+}
+
+// Close stops accepting events and waits until every queued event has been written.
+func (w *LoginEventWriter) Close() {
+	w.writer.Close()
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeLoginEventStore struct {
+	mu      sync.Mutex
+	events  []*model.LoginEvent
+	err     error
+	release chan struct{}
+}
+
+func (s *fakeLoginEventStore) Create(_ context.Context, event *model.LoginEvent) error {
+	if s.release != nil {
+		<-s.release
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+	return s.err
+}
+
+func TestLoginEventWriter_CloseFlushesQueuedEvents(t *testing.T) {
+	store := &fakeLoginEventStore{}
+	writer := NewLoginEventWriter(store, 10)
+
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		writer.Record(&model.LoginEvent{Email: email})
+	}
+	writer.Close()
+
+	assert.Len(t, store.events, 3)
+	assert.Equal(t, "a@example.com", store.events[0].Email)
+	assert.Equal(t, "c@example.com", store.events[2].Email)
+}
+
+func TestLoginEventWriter_DropsWhenBufferFull(t *testing.T) {
+	store := &fakeLoginEventStore{release: make(chan struct{})}
+	writer := NewLoginEventWriter(store, 1)
+
+	// The writer may pick up the first event before the buffer fills, so record
+	// enough events that some must be dropped while the store is blocked.
+	for i := 0; i < 5; i++ {
+		writer.Record(&model.LoginEvent{Email: "user@example.com"})
+	}
+	close(store.release)
+	writer.Close()
+
+	assert.LessOrEqual(t, len(store.events), 2)
+	assert.NotEmpty(t, store.events)
+}
+
+func TestLoginEventWriter_StoreErrorDoesNotStopWriter(t *testing.T) {
+	store := &fakeLoginEventStore{err: errors.New("database error")}
+	writer := NewLoginEventWriter(store, 10)
+
+	writer.Record(&model.LoginEvent{Email: "a@example.com"})
+	writer.Record(&model.LoginEvent{Email: "b@example.com"})
+	writer.Close()
+
+	assert.Len(t, store.events, 2)
+}
+
+func TestLoginEventWriter_RecordAfterClose(t *testing.T) {
+	store := &fakeLoginEventStore{}
+	writer := NewLoginEventWriter(store, 10)
+	writer.Close()
+
+	assert.NotPanics(t, func() {
+		writer.Record(&model.LoginEvent{Email: "a@example.com"})
+	})
+	assert.Empty(t, store.events)
+}
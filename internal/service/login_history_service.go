@@ -0,0 +1,38 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/google/uuid"
+)
+
+var ErrInvalidUserID = errors.New("invalid user id")
+
+type LoginEventRepository interface {
+	ListByUser(ctx context.Context, userID uuid.UUID, cursor string, limit int) ([]model.LoginEvent, string, error)
+}
+
+type LoginHistoryService struct {
+	eventRepo LoginEventRepository
+}
+
+func NewLoginHistoryService(eventRepo LoginEventRepository) *LoginHistoryService {
+	return &LoginHistoryService{eventRepo: eventRepo}
+}
+
+// ListForUser returns a page of the user's login events, newest first, along
+// with the cursor for the next page.
+func (s *LoginHistoryService) ListForUser(ctx context.Context, userID string, cursor string, limit int) ([]model.LoginEvent, string, error) {
+	if limit < 1 || limit > MaxListLimit {
+		return nil, "", ErrInvalidLimit
+	}
+
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, "", ErrInvalidUserID
+	}
+
+	return s.eventRepo.ListByUser(ctx, id, cursor, limit)
+}
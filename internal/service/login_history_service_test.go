@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockLoginEventRepository struct {
+	mock.Mock
+}
+
+func (r *MockLoginEventRepository) ListByUser(ctx context.Context, userID uuid.UUID, cursor string, limit int) ([]model.LoginEvent, string, error) {
+	args := r.Called(ctx, userID, cursor, limit)
+	if args.Get(0) == nil {
+		return nil, args.String(1), args.Error(2)
+	}
+	return args.Get(0).([]model.LoginEvent), args.String(1), args.Error(2)
+}
+
+func TestLoginHistoryService_ListForUser(t *testing.T) {
+	userID := uuid.New()
+	event := model.LoginEvent{ID: uuid.New(), UserID: &userID, Email: "test@example.com", Success: true}
+
+	tests := []struct {
+		name           string
+		userID         string
+		limit          int
+		mockFn         func(*MockLoginEventRepository)
+		wantEvents     []model.LoginEvent
+		wantNextCursor string
+		wantErr        error
+	}{
+		{
+			name:   "successful listing",
+			userID: userID.String(),
+			limit:  10,
+			mockFn: func(repo *MockLoginEventRepository) {
+				repo.On("ListByUser", mock.Anything, userID, "cursor", 10).Return([]model.LoginEvent{event}, "next", nil)
+			},
+			wantEvents:     []model.LoginEvent{event},
+			wantNextCursor: "next",
+		},
+		{
+			name:    "invalid user id",
+			userID:  "not-a-uuid",
+			limit:   10,
+			wantErr: ErrInvalidUserID,
+		},
+		{
+			name:    "limit out of range",
+			userID:  userID.String(),
+			limit:   MaxListLimit + 1,
+			wantErr: ErrInvalidLimit,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockLoginEventRepository)
+			if tt.mockFn != nil {
+				tt.mockFn(mockRepo)
+			}
+			historyService := NewLoginHistoryService(mockRepo)
+
+			events, nextCursor, err := historyService.ListForUser(context.Background(), tt.userID, "cursor", tt.limit)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, events)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantEvents, events)
+				assert.Equal(t, tt.wantNextCursor, nextCursor)
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/PakornBank/learn-go/internal/events"
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+// Limits on the metadata a user may store.
+const (
+	MaxMetadataKeys  = 16
+	MaxMetadataBytes = 4096
+)
+
+var (
+	ErrTooManyMetadataKeys  = errors.New("metadata may have at most 16 keys")
+	ErrMetadataTooLarge     = errors.New("metadata may be at most 4096 bytes")
+	ErrInvalidMetadataValue = errors.New("metadata values must be strings, numbers, booleans or arrays of those")
+)
+
+type MetadataRepository interface {
+	MergeMetadata(ctx context.Context, id uuid.UUID, patch map[string]json.RawMessage, validate func(map[string]json.RawMessage) error) (datatypes.JSON, error)
+}
+
+// MetadataService manages the small JSON object of preferences, such as theme
+// or locale, that clients may store on a user without schema changes.
+type MetadataService struct {
+	userRepo MetadataRepository
+	events   events.Publisher
+}
+
+// NewMetadataService creates a MetadataService that publishes profile updates to publisher.
+func NewMetadataService(userRepo MetadataRepository, publisher events.Publisher) *MetadataService {
+	return &MetadataService{userRepo: userRepo, events: publisher}
+}
+
+// Update merges patch into the metadata of the user with userID and returns
+// the result. Keys in patch replace existing ones and keys set to null are
+// removed. Values must be scalars or arrays of scalars, and the merged
+// metadata may have at most MaxMetadataKeys keys and MaxMetadataBytes bytes.
+func (s *MetadataService) Update(ctx context.Context, userID string, patch map[string]json.RawMessage) (datatypes.JSON, error) {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, ErrInvalidUserID
+	}
+
+	for _, value := range patch {
+		if !isMetadataValue(value) {
+			return nil, ErrInvalidMetadataValue
+		}
+	}
+
+	metadata, err := s.userRepo.MergeMetadata(ctx, id, patch, validateMetadataSize)
+	if err != nil {
+		return nil, err
+	}
+	s.events.Publish(userID, profileUpdated("metadata"))
+	return metadata, nil
+}
+
+// validateMetadataSize checks merged metadata against the size limits.
+func validateMetadataSize(metadata map[string]json.RawMessage) error {
+	if len(metadata) > MaxMetadataKeys {
+		return ErrTooManyMetadataKeys
+	}
+
+	encoded, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	if len(encoded) > MaxMetadataBytes {
+		return ErrMetadataTooLarge
+	}
+
+	return nil
+}
+
+// isMetadataValue reports whether raw is null, a scalar, or an array of
+// scalars. Objects and nested arrays are rejected.
+func isMetadataValue(raw json.RawMessage) bool {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return false
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return true
+	case []interface{}:
+		for _, elem := range v {
+			if !isScalar(elem) {
+				return false
+			}
+		}
+		return true
+	default:
+		return isScalar(v)
+	}
+}
+
+func isScalar(value interface{}) bool {
+	switch value.(type) {
+	case string, json.Number, bool:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/PakornBank/learn-go/internal/events"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/datatypes"
+)
+
+type MockMetadataRepository struct {
+	mock.Mock
+}
+
+// MergeMetadata records the call and, unless an error is configured, validates
+// the patch applied to an empty object the way the real repository would.
+func (r *MockMetadataRepository) MergeMetadata(ctx context.Context, id uuid.UUID, patch map[string]json.RawMessage, validate func(map[string]json.RawMessage) error) (datatypes.JSON, error) {
+	args := r.Called(ctx, id, patch)
+	if err := args.Error(1); err != nil {
+		return nil, err
+	}
+	if err := validate(patch); err != nil {
+		return nil, err
+	}
+	return args.Get(0).(datatypes.JSON), nil
+}
+
+func TestMetadataService_Update(t *testing.T) {
+	userID := uuid.New()
+	tooManyKeys := make(map[string]json.RawMessage)
+	for i := 0; i <= MaxMetadataKeys; i++ {
+		tooManyKeys[fmt.Sprintf("key%d", i)] = json.RawMessage(`true`)
+	}
+
+	tests := []struct {
+		name    string
+		userID  string
+		patch   map[string]json.RawMessage
+		mockFn  func(*MockMetadataRepository, map[string]json.RawMessage)
+		wantErr error
+	}{
+		{
+			name:   "scalars and arrays",
+			userID: userID.String(),
+			patch: map[string]json.RawMessage{
+				"theme":  json.RawMessage(`"dark"`),
+				"zoom":   json.RawMessage(`1.5`),
+				"beta":   json.RawMessage(`true`),
+				"tags":   json.RawMessage(`["a", 1, false]`),
+				"locale": json.RawMessage(`null`),
+			},
+			mockFn: func(repo *MockMetadataRepository, patch map[string]json.RawMessage) {
+				repo.On("MergeMetadata", mock.Anything, userID, patch).Return(datatypes.JSON(`{}`), nil)
+			},
+		},
+		{
+			name:    "invalid user id",
+			userID:  "not-a-uuid",
+			patch:   map[string]json.RawMessage{"theme": json.RawMessage(`"dark"`)},
+			wantErr: ErrInvalidUserID,
+		},
+		{
+			name:    "object value",
+			userID:  userID.String(),
+			patch:   map[string]json.RawMessage{"theme": json.RawMessage(`{"name":"dark"}`)},
+			wantErr: ErrInvalidMetadataValue,
+		},
+		{
+			name:    "nested array",
+			userID:  userID.String(),
+			patch:   map[string]json.RawMessage{"grid": json.RawMessage(`[[1,2],[3,4]]`)},
+			wantErr: ErrInvalidMetadataValue,
+		},
+		{
+			name:    "object in array",
+			userID:  userID.String(),
+			patch:   map[string]json.RawMessage{"tags": json.RawMessage(`[{"a":1}]`)},
+			wantErr: ErrInvalidMetadataValue,
+		},
+		{
+			name:   "too many keys",
+			userID: userID.String(),
+			patch:  tooManyKeys,
+			mockFn: func(repo *MockMetadataRepository, patch map[string]json.RawMessage) {
+				repo.On("MergeMetadata", mock.Anything, userID, patch).Return(datatypes.JSON(nil), nil)
+			},
+			wantErr: ErrTooManyMetadataKeys,
+		},
+		{
+			name:   "too large",
+			userID: userID.String(),
+			patch:  map[string]json.RawMessage{"bio": json.RawMessage(`"` + strings.Repeat("x", MaxMetadataBytes) + `"`)},
+			mockFn: func(repo *MockMetadataRepository, patch map[string]json.RawMessage) {
+				repo.On("MergeMetadata", mock.Anything, userID, patch).Return(datatypes.JSON(nil), nil)
+			},
+			wantErr: ErrMetadataTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(MockMetadataRepository)
+			if tt.mockFn != nil {
+				tt.mockFn(repo, tt.patch)
+			}
+			service := NewMetadataService(repo, events.Discard)
+
+			merged, err := service.Update(context.Background(), tt.userID, tt.patch)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, merged)
+			} else {
+				require.NoError(t, err)
+				assert.NotNil(t, merged)
+			}
+			repo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestValidateMetadataSize(t *testing.T) {
+	atLimit := make(map[string]json.RawMessage)
+	for i := 0; i < MaxMetadataKeys; i++ {
+		atLimit[fmt.Sprintf("key%d", i)] = json.RawMessage(`true`)
+	}
+
+	assert.NoError(t, validateMetadataSize(atLimit))
+	assert.NoError(t, validateMetadataSize(map[string]json.RawMessage{}))
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/mailer"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/google/uuid"
+)
+
+// LoginAlertExpiry is how long the link in a new device email can be used to
+// report the login.
+const LoginAlertExpiry = 7 * 24 * time.Hour
+
+// DefaultNewDeviceBuffer is the number of logins that can be queued before
+// NewDeviceNotifier starts dropping them.
+const DefaultNewDeviceBuffer = 64
+
+// LoginNotifier is told about successful logins. Implementations must not block the caller.
+type LoginNotifier interface {
+	NotifyLogin(user model.User, session model.RefreshToken)
+}
+
+type noopLoginNotifier struct{}
+
+func (noopLoginNotifier) NotifyLogin(model.User, model.RefreshToken) {}
+
+// WithLoginNotifier makes the service tell notifier about every successful login.
+func WithLoginNotifier(notifier LoginNotifier) AuthOption {
+	return func(s *AuthService) {
+		s.loginNotifier = notifier
+	}
+}
+
+type DeviceHistoryRepository interface {
+	DeviceHistory(ctx context.Context, userID, familyID uuid.UUID, deviceHash string) (hasOther, knownDevice bool, err error)
+}
+
+type LoginAlertRepository interface {
+	Create(ctx context.Context, alert *model.LoginAlert) error
+	FindByHash(ctx context.Context, tokenHash string) (*model.LoginAlert, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+// newLogin is a login waiting to be checked by NewDeviceNotifier.
+type newLogin struct {
+	user    model.User
+	session model.RefreshToken
+	at      time.Time
+}
+
+// NewDeviceNotifier emails users when they log in from a device, identified
+// by the DeviceHash of its session, that none of their earlier sessions was
+// started from. The email links to the report flow of LoginAlertService.
+// Nobody is emailed about their first login.
+//
+// Logins are checked on a background goroutine, so a login is never delayed
+// or failed by the check. They are queued on a buffered channel; when the
+// buffer is full, new logins are dropped and an error is logged.
+type NewDeviceNotifier struct {
+	tokens    DeviceHistoryRepository
+	alerts    LoginAlertRepository
+	mailer    mailer.Mailer
+	templates *mailer.Templates
+	clock     Clock
+
+	writer *asyncWriter[newLogin]
+}
+
+// NewNewDeviceNotifier creates a NewDeviceNotifier that mails alerts with m,
+// with room for bufferSize queued logins, and starts its background worker.
+// Logins are dated, and their alerts expire, at the time told by clock. Call
+// Close to flush and stop it.
+func NewNewDeviceNotifier(tokens DeviceHistoryRepository, alerts LoginAlertRepository, m mailer.Mailer, templates *mailer.Templates, bufferSize int, clock Clock) *NewDeviceNotifier {
+	n := &NewDeviceNotifier{
+		tokens:    tokens,
+		alerts:    alerts,
+		mailer:    m,
+		templates: templates,
+		clock:     clock,
+	}
+	n.writer = newAsyncWriter("new device login", bufferSize, 0, n.check, func(login newLogin) []any {
+		return []any{"user_id", login.user.ID}
+	})
+	return n
+}
+
+// NotifyLogin queues the login of user that started session for checking. It
+// never blocks: if the buffer is full or the notifier has been closed, the
+// login is dropped.
+func (n *NewDeviceNotifier) NotifyLogin(user model.User, session model.RefreshToken) {
+	n.writer.Record(newLogin{user: user, session: session, at: n.clock.Now()})
+}
+
+// Close stops accepting logins and waits until every queued login has been checked.
+func (n *NewDeviceNotifier) Close() {
+	n.writer.Close()
+}
+
+// check emails the user about login unless it is their first or comes from
+// a device they used before.
+func (n *NewDeviceNotifier) check(ctx context.Context, login newLogin) error {
+	hasOther, knownDevice, err := n.tokens.DeviceHistory(ctx, login.user.ID, login.session.FamilyID, login.session.DeviceHash)
+	if err != nil {
+		return err
+	}
+	if !hasOther || knownDevice {
+		return nil
+	}
+
+	token, err := generateOpaqueToken()
+	if err != nil {
+		return fmt.Errorf("failed to generate login alert token: %w", err)
+	}
+
+	err = n.alerts.Create(ctx, &model.LoginAlert{
+		UserID:    login.user.ID,
+		FamilyID:  login.session.FamilyID,
+		TokenHash: hashToken(token),
+		ExpiresAt: login.at.Add(LoginAlertExpiry),
+	})
+	if err != nil {
+		return err
+	}
+
+	msg, err := n.templates.NewDeviceLogin(login.user.FullName, mailer.LoginDetails{
+		Time:      login.at,
+		Device:    login.session.DeviceLabel,
+		IPAddress: login.session.IPAddress,
+	}, token)
+	if err != nil {
+		return err
+	}
+	return n.mailer.Send(ctx, login.user.Email, msg.Subject, msg.HTML, msg.Text)
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/mailer"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockDeviceHistoryRepository struct {
+	mock.Mock
+}
+
+func (r *MockDeviceHistoryRepository) DeviceHistory(ctx context.Context, userID, familyID uuid.UUID, deviceHash string) (bool, bool, error) {
+	args := r.Called(ctx, userID, familyID, deviceHash)
+	return args.Bool(0), args.Bool(1), args.Error(2)
+}
+
+func (r *MockDeviceHistoryRepository) RevokeFamily(ctx context.Context, familyID uuid.UUID) error {
+	args := r.Called(ctx, familyID)
+	return args.Error(0)
+}
+
+type MockLoginAlertRepository struct {
+	mock.Mock
+}
+
+func (r *MockLoginAlertRepository) Create(ctx context.Context, alert *model.LoginAlert) error {
+	args := r.Called(ctx, alert)
+	return args.Error(0)
+}
+
+func (r *MockLoginAlertRepository) FindByHash(ctx context.Context, tokenHash string) (*model.LoginAlert, error) {
+	args := r.Called(ctx, tokenHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.LoginAlert), args.Error(1)
+}
+
+func (r *MockLoginAlertRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	args := r.Called(ctx, id)
+	return args.Error(0)
+}
+
+func TestNewDeviceNotifier(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	mockUser := testutil.NewMockUser()
+	session := model.RefreshToken{
+		UserID:      mockUser.ID,
+		FamilyID:    uuid.New(),
+		DeviceLabel: "Firefox on Linux",
+		IPAddress:   "203.0.113.0",
+		DeviceHash:  "device-hash",
+	}
+
+	tests := []struct {
+		name       string
+		hasOther   bool
+		known      bool
+		historyErr error
+		wantEmail  bool
+	}{
+		{name: "first login", hasOther: false, known: false},
+		{name: "known device", hasOther: true, known: true},
+		{name: "new device", hasOther: true, known: false, wantEmail: true},
+		{name: "history unavailable", historyErr: errors.New("connection reset")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens := new(MockDeviceHistoryRepository)
+			alerts := new(MockLoginAlertRepository)
+			m := &fakeMailer{}
+			tokens.On("DeviceHistory", mock.Anything, mockUser.ID, session.FamilyID, "device-hash").Return(tt.hasOther, tt.known, tt.historyErr)
+			var created *model.LoginAlert
+			if tt.wantEmail {
+				alerts.On("Create", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+					created = args.Get(1).(*model.LoginAlert)
+				}).Return(nil)
+			}
+			notifier := NewNewDeviceNotifier(tokens, alerts, m, mailer.NewTemplates("https://app.example.com"), DefaultNewDeviceBuffer, testutil.NewFakeClock(now))
+
+			notifier.NotifyLogin(mockUser, session)
+			notifier.Close()
+
+			if tt.wantEmail {
+				require.Len(t, m.sent, 1)
+				assert.Equal(t, mockUser.Email, m.sent[0].to)
+				assert.Contains(t, m.sent[0].text, "Firefox on Linux")
+				assert.Contains(t, m.sent[0].text, "203.0.113.0")
+
+				require.NotNil(t, created)
+				assert.Equal(t, mockUser.ID, created.UserID)
+				assert.Equal(t, session.FamilyID, created.FamilyID)
+				assert.Equal(t, hashToken(tokenFromEmail(t, m.sent[0])), created.TokenHash, "the link carries the report token")
+				assert.Equal(t, now.Add(LoginAlertExpiry), created.ExpiresAt)
+			} else {
+				assert.Empty(t, m.sent)
+			}
+			tokens.AssertExpectations(t)
+			alerts.AssertExpectations(t)
+		})
+	}
+}
+
+func TestNewDeviceNotifier_NotifyAfterClose(t *testing.T) {
+	notifier := NewNewDeviceNotifier(new(MockDeviceHistoryRepository), new(MockLoginAlertRepository), &fakeMailer{}, mailer.NewTemplates(""), 1, SystemClock{})
+	notifier.Close()
+
+	assert.NotPanics(t, func() { notifier.NotifyLogin(testutil.NewMockUser(), model.RefreshToken{}) })
+}
+
+// blockingDeviceHistory blocks every lookup until release is closed.
+type blockingDeviceHistory struct {
+	release chan struct{}
+}
+
+func (b blockingDeviceHistory) DeviceHistory(context.Context, uuid.UUID, uuid.UUID, string) (bool, bool, error) {
+	<-b.release
+	return false, false, nil
+}
+
+func TestNewDeviceNotifier_NotifyDoesNotBlock(t *testing.T) {
+	history := blockingDeviceHistory{release: make(chan struct{})}
+	notifier := NewNewDeviceNotifier(history, new(MockLoginAlertRepository), &fakeMailer{}, mailer.NewTemplates(""), 1, SystemClock{})
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 10; i++ {
+			notifier.NotifyLogin(testutil.NewMockUser(), model.RefreshToken{})
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("NotifyLogin blocked while the worker was busy")
+	}
+	close(history.release)
+	notifier.Close()
+}
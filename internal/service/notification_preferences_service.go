@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/PakornBank/learn-go/internal/mailer"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrSecurityAlertsRequired  = errors.New("security alerts cannot be turned off")
+	ErrInvalidUnsubscribeToken = errors.New("invalid unsubscribe token")
+)
+
+// unsubscribePurpose is signed along with the user ID, so that an unsubscribe
+// token cannot be mistaken for anything else signed with the same secret.
+const unsubscribePurpose = "unsubscribe:"
+
+type NotificationPreferencesRepository interface {
+	FindByID(ctx context.Context, id string) (*model.User, error)
+	FindByEmail(ctx context.Context, email string) (*model.User, error)
+	UpdateNotificationPreferences(ctx context.Context, id uuid.UUID, preferences model.NotificationPreferences) error
+}
+
+// NotificationPreferencesService manages which kinds of email users receive.
+// Security alerts cannot be turned off. The newsletter can also be turned off
+// without logging in, with the unsubscribe token from the link in every issue.
+type NotificationPreferencesService struct {
+	userRepo NotificationPreferencesRepository
+	secret   []byte
+}
+
+// NewNotificationPreferencesService creates a NotificationPreferencesService
+// that signs unsubscribe tokens with secret.
+func NewNotificationPreferencesService(userRepo NotificationPreferencesRepository, secret string) *NotificationPreferencesService {
+	return &NotificationPreferencesService{userRepo: userRepo, secret: []byte(secret)}
+}
+
+// Get returns the notification preferences of the user with userID, or the
+// defaults if they never changed them.
+func (s *NotificationPreferencesService) Get(ctx context.Context, userID string) (*model.NotificationPreferences, error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %w", ErrUserNotFound, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	preferences := preferencesOf(user)
+	return &preferences, nil
+}
+
+// Update replaces the notification preferences of the user with userID. It
+// returns ErrSecurityAlertsRequired if preferences turn security alerts off.
+func (s *NotificationPreferencesService) Update(ctx context.Context, userID string, preferences model.NotificationPreferences) (*model.NotificationPreferences, error) {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, ErrInvalidUserID
+	}
+	if !preferences.SecurityAlerts {
+		return nil, ErrSecurityAlertsRequired
+	}
+
+	if err := s.userRepo.UpdateNotificationPreferences(ctx, id, preferences); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, fmt.Errorf("%w: %w", ErrUserNotFound, err)
+		}
+		return nil, err
+	}
+	return &preferences, nil
+}
+
+// UnsubscribeToken returns the token that lets the user with userID turn the
+// newsletter off without logging in. The token does not expire, so the link in
+// an old issue keeps working.
+func (s *NotificationPreferencesService) UnsubscribeToken(userID uuid.UUID) string {
+	token := append(userID[:], s.sign(userID)...)
+	return base64.RawURLEncoding.EncodeToString(token)
+}
+
+// Unsubscribe turns the newsletter off for the user the token was issued to.
+// It returns ErrInvalidUnsubscribeToken if the token was not issued by
+// UnsubscribeToken. Unsubscribing twice, or after the account was deleted,
+// succeeds.
+func (s *NotificationPreferencesService) Unsubscribe(ctx context.Context, token string) error {
+	decoded, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(decoded) != len(uuid.UUID{})+sha256.Size {
+		return ErrInvalidUnsubscribeToken
+	}
+	id, err := uuid.FromBytes(decoded[:len(uuid.UUID{})])
+	if err != nil || !hmac.Equal(decoded[len(uuid.UUID{}):], s.sign(id)) {
+		return ErrInvalidUnsubscribeToken
+	}
+
+	user, err := s.userRepo.FindByID(ctx, id.String())
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	preferences := preferencesOf(user)
+	if !preferences.Newsletter {
+		return nil
+	}
+	preferences.Newsletter = false
+	err = s.userRepo.UpdateNotificationPreferences(ctx, id, preferences)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// Allows reports whether the user with the email address to agreed to
+// receive emails of category. Nobody without an account receives emails
+// other than security alerts. It implements mailer.PreferenceChecker.
+func (s *NotificationPreferencesService) Allows(ctx context.Context, to string, category mailer.Category) (bool, error) {
+	if category == mailer.CategorySecurity {
+		return true, nil
+	}
+
+	user, err := s.userRepo.FindByEmail(ctx, to)
+	if errors.Is(err, repository.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	preferences := preferencesOf(user)
+	switch category {
+	case mailer.CategoryProductUpdates:
+		return preferences.ProductUpdates, nil
+	case mailer.CategoryNewsletter:
+		return preferences.Newsletter, nil
+	default:
+		return false, nil
+	}
+}
+
+// sign returns the signature of an unsubscribe token for userID.
+func (s *NotificationPreferencesService) sign(userID uuid.UUID) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(unsubscribePurpose))
+	mac.Write(userID[:])
+	return mac.Sum(nil)
+}
+
+// preferencesOf returns the notification preferences of user, with security
+// alerts on regardless of what is stored.
+func preferencesOf(user *model.User) model.NotificationPreferences {
+	preferences := model.DefaultNotificationPreferences()
+	if user.NotificationPreferences != nil {
+		preferences = *user.NotificationPreferences
+	}
+	preferences.SecurityAlerts = true
+	return preferences
+}
@@ -0,0 +1,250 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/PakornBank/learn-go/internal/mailer"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockNotificationPreferencesRepository struct {
+	mock.Mock
+}
+
+func (r *MockNotificationPreferencesRepository) FindByID(ctx context.Context, id string) (*model.User, error) {
+	args := r.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+func (r *MockNotificationPreferencesRepository) FindByEmail(ctx context.Context, email string) (*model.User, error) {
+	args := r.Called(ctx, email)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+func (r *MockNotificationPreferencesRepository) UpdateNotificationPreferences(ctx context.Context, id uuid.UUID, preferences model.NotificationPreferences) error {
+	args := r.Called(ctx, id, preferences)
+	return args.Error(0)
+}
+
+const testUnsubscribeSecret = "unsubscribe-secret"
+
+func TestNotificationPreferencesService_Get(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+
+	tests := []struct {
+		name    string
+		stored  *model.NotificationPreferences
+		findErr error
+		want    *model.NotificationPreferences
+		wantErr error
+	}{
+		{
+			name: "never changed",
+			want: &model.NotificationPreferences{SecurityAlerts: true},
+		},
+		{
+			name:   "stored",
+			stored: &model.NotificationPreferences{SecurityAlerts: true, Newsletter: true},
+			want:   &model.NotificationPreferences{SecurityAlerts: true, Newsletter: true},
+		},
+		{
+			name:   "security alerts are forced on",
+			stored: &model.NotificationPreferences{ProductUpdates: true},
+			want:   &model.NotificationPreferences{SecurityAlerts: true, ProductUpdates: true},
+		},
+		{
+			name:    "user not found",
+			findErr: repository.ErrNotFound,
+			wantErr: ErrUserNotFound,
+		},
+		{
+			name:    "database timeout",
+			findErr: repository.ErrTimeout,
+			wantErr: repository.ErrTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockNotificationPreferencesRepository)
+			if tt.findErr != nil {
+				mockRepo.On("FindByID", mock.Anything, mockUser.ID.String()).Return(nil, tt.findErr)
+			} else {
+				user := mockUser
+				user.NotificationPreferences = tt.stored
+				mockRepo.On("FindByID", mock.Anything, mockUser.ID.String()).Return(&user, nil)
+			}
+
+			preferences, err := NewNotificationPreferencesService(mockRepo, testUnsubscribeSecret).Get(context.Background(), mockUser.ID.String())
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.want, preferences)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestNotificationPreferencesService_Update(t *testing.T) {
+	userID := uuid.New()
+	allOn := model.NotificationPreferences{SecurityAlerts: true, ProductUpdates: true, Newsletter: true}
+
+	tests := []struct {
+		name        string
+		userID      string
+		preferences model.NotificationPreferences
+		updateErr   error
+		wantErr     error
+		wantUpdate  bool
+	}{
+		{name: "updated", userID: userID.String(), preferences: allOn, wantUpdate: true},
+		{
+			name:        "security alerts cannot be turned off",
+			userID:      userID.String(),
+			preferences: model.NotificationPreferences{ProductUpdates: true, Newsletter: true},
+			wantErr:     ErrSecurityAlertsRequired,
+		},
+		{name: "invalid user id", userID: "not-a-uuid", preferences: allOn, wantErr: ErrInvalidUserID},
+		{name: "user not found", userID: userID.String(), preferences: allOn, updateErr: repository.ErrNotFound, wantErr: ErrUserNotFound, wantUpdate: true},
+		{name: "database timeout", userID: userID.String(), preferences: allOn, updateErr: repository.ErrTimeout, wantErr: repository.ErrTimeout, wantUpdate: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockNotificationPreferencesRepository)
+			if tt.wantUpdate {
+				mockRepo.On("UpdateNotificationPreferences", mock.Anything, userID, tt.preferences).Return(tt.updateErr)
+			}
+
+			preferences, err := NewNotificationPreferencesService(mockRepo, testUnsubscribeSecret).Update(context.Background(), tt.userID, tt.preferences)
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			if tt.wantErr == nil {
+				assert.Equal(t, &tt.preferences, preferences)
+			} else {
+				assert.Nil(t, preferences)
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestNotificationPreferencesService_Unsubscribe(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	mockRepo := new(MockNotificationPreferencesRepository)
+	service := NewNotificationPreferencesService(mockRepo, testUnsubscribeSecret)
+	subscribed := mockUser
+	subscribed.NotificationPreferences = &model.NotificationPreferences{SecurityAlerts: true, ProductUpdates: true, Newsletter: true}
+	mockRepo.On("FindByID", mock.Anything, mockUser.ID.String()).Return(&subscribed, nil).Once()
+	mockRepo.On("UpdateNotificationPreferences", mock.Anything, mockUser.ID,
+		model.NotificationPreferences{SecurityAlerts: true, ProductUpdates: true}).Return(nil).Once()
+
+	token := service.UnsubscribeToken(mockUser.ID)
+	require.NoError(t, service.Unsubscribe(context.Background(), token))
+	mockRepo.AssertExpectations(t)
+
+	// Following the link again changes nothing.
+	unsubscribed := mockUser
+	unsubscribed.NotificationPreferences = &model.NotificationPreferences{SecurityAlerts: true, ProductUpdates: true}
+	mockRepo.On("FindByID", mock.Anything, mockUser.ID.String()).Return(&unsubscribed, nil).Once()
+	require.NoError(t, service.Unsubscribe(context.Background(), token))
+	mockRepo.AssertNumberOfCalls(t, "UpdateNotificationPreferences", 1)
+}
+
+func TestNotificationPreferencesService_UnsubscribeErrors(t *testing.T) {
+	userID := uuid.New()
+	service := NewNotificationPreferencesService(new(MockNotificationPreferencesRepository), testUnsubscribeSecret)
+	token := service.UnsubscribeToken(userID)
+	otherSecret := NewNotificationPreferencesService(nil, "other-secret").UnsubscribeToken(userID)
+	forged := NewNotificationPreferencesService(nil, testUnsubscribeSecret).UnsubscribeToken(uuid.New())
+	forged = token[:22] + forged[22:]
+
+	for name, token := range map[string]string{
+		"empty":             "",
+		"not base64":        "not a token!",
+		"truncated":         token[:len(token)-4],
+		"other secret":      otherSecret,
+		"signature swapped": forged,
+	} {
+		t.Run(name, func(t *testing.T) {
+			mockRepo := new(MockNotificationPreferencesRepository)
+
+			err := NewNotificationPreferencesService(mockRepo, testUnsubscribeSecret).Unsubscribe(context.Background(), token)
+
+			assert.ErrorIs(t, err, ErrInvalidUnsubscribeToken)
+			mockRepo.AssertNotCalled(t, "FindByID", mock.Anything, mock.Anything)
+		})
+	}
+
+	t.Run("account deleted", func(t *testing.T) {
+		mockRepo := new(MockNotificationPreferencesRepository)
+		mockRepo.On("FindByID", mock.Anything, userID.String()).Return(nil, repository.ErrNotFound)
+
+		err := NewNotificationPreferencesService(mockRepo, testUnsubscribeSecret).Unsubscribe(context.Background(), token)
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("database timeout", func(t *testing.T) {
+		mockRepo := new(MockNotificationPreferencesRepository)
+		mockRepo.On("FindByID", mock.Anything, userID.String()).Return(nil, repository.ErrTimeout)
+
+		err := NewNotificationPreferencesService(mockRepo, testUnsubscribeSecret).Unsubscribe(context.Background(), token)
+
+		assert.ErrorIs(t, err, repository.ErrTimeout)
+	})
+}
+
+func TestNotificationPreferencesService_Allows(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+
+	tests := []struct {
+		name     string
+		category mailer.Category
+		stored   *model.NotificationPreferences
+		findErr  error
+		want     bool
+		wantErr  error
+		noLookup bool
+	}{
+		{name: "security alerts", category: mailer.CategorySecurity, want: true, noLookup: true},
+		{name: "newsletter on", category: mailer.CategoryNewsletter, stored: &model.NotificationPreferences{Newsletter: true}, want: true},
+		{name: "newsletter off", category: mailer.CategoryNewsletter, stored: &model.NotificationPreferences{ProductUpdates: true}},
+		{name: "product updates by default", category: mailer.CategoryProductUpdates},
+		{name: "no account", category: mailer.CategoryProductUpdates, findErr: repository.ErrNotFound},
+		{name: "database timeout", category: mailer.CategoryNewsletter, findErr: repository.ErrTimeout, wantErr: repository.ErrTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockNotificationPreferencesRepository)
+			if !tt.noLookup {
+				if tt.findErr != nil {
+					mockRepo.On("FindByEmail", mock.Anything, mockUser.Email).Return(nil, tt.findErr)
+				} else {
+					user := mockUser
+					user.NotificationPreferences = tt.stored
+					mockRepo.On("FindByEmail", mock.Anything, mockUser.Email).Return(&user, nil)
+				}
+			}
+
+			allowed, err := NewNotificationPreferencesService(mockRepo, testUnsubscribeSecret).Allows(context.Background(), mockUser.Email, tt.category)
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.want, allowed)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package service
+
+import "errors"
+
+// ErrInvalidPage is returned when a page is asked for with a page number
+// below 1 or a page size outside 1 to MaxListLimit.
+var ErrInvalidPage = errors.New("page must be at least 1 and per_page between 1 and 100")
+
+// PageRequest asks for page Page, counting from 1, of a list split into
+// pages of PerPage items and sorted by the field Sort, in descending order if
+// Desc is set.
+type PageRequest struct {
+	Page    int
+	PerPage int
+	Sort    string
+	Desc    bool
+}
+
+// Offset returns the number of items before the page.
+func (p PageRequest) Offset() int {
+	return (p.Page - 1) * p.PerPage
+}
+
+// valid reports whether the page number and size are in range.
+func (p PageRequest) valid() bool {
+	return p.Page >= 1 && p.PerPage >= 1 && p.PerPage <= MaxListLimit
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+)
+
+var ErrPasswordExpired = errors.New("password has expired")
+
+// PasswordExpiredError is the ErrPasswordExpired that Login returns in strict
+// mode. The user cannot log in, but gets a token to change their password
+// with.
+type PasswordExpiredError struct {
+	// PasswordChangeToken is an access token with only the profile:write
+	// scope and no session to refresh. While the password has expired, the
+	// expiry check of the protected routes keeps it to changing the password
+	// and accepting the terms.
+	PasswordChangeToken string
+}
+
+func (e *PasswordExpiredError) Error() string { return ErrPasswordExpired.Error() }
+
+func (e *PasswordExpiredError) Unwrap() error { return ErrPasswordExpired }
+
+// CheckPasswordExpiry returns ErrPasswordExpired if passwords expire and the
+// password of the user with userID is older than the maximum password age.
+func (s *AuthService) CheckPasswordExpiry(ctx context.Context, userID string) error {
+	if s.passwordMaxAge == 0 {
+		return nil
+	}
+
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return fmt.Errorf("%w: %w", ErrUserNotFound, err)
+	}
+	if err != nil {
+		return err
+	}
+	if s.passwordExpired(user) {
+		return ErrPasswordExpired
+	}
+
+	return nil
+}
+
+// passwordExpired reports whether passwords expire and the maximum password
+// age has passed since user last set theirs. A password expires at the very
+// moment it reaches the maximum age.
+func (s *AuthService) passwordExpired(user *model.User) bool {
+	if s.passwordMaxAge == 0 {
+		return false
+	}
+	return !s.clock.Now().Before(user.PasswordChangedAt.Add(s.passwordMaxAge))
+}
+
+// passwordExpiredError returns the PasswordExpiredError for user, whose
+// password was just checked, with a short-lived token to change it.
+func (s *AuthService) passwordExpiredError(user *model.User) error {
+	token, err := s.generateToken(user, tokenOptions{
+		scopes:   []string{ScopeProfileWrite},
+		authTime: s.clock.Now(),
+		expiry:   s.reauthMaxAge,
+	})
+	if err != nil {
+		return err
+	}
+	return &PasswordExpiredError{PasswordChangeToken: token}
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/config"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const passwordMaxAge = 90 * 24 * time.Hour
+
+var passwordExpiryNow = time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+
+// newPasswordExpiryTestService creates an AuthService at passwordExpiryNow
+// whose passwords expire after maxAge in mode.
+func newPasswordExpiryTestService(userRepo Repository, tokenRepo TokenRepository, maxAge time.Duration, mode string, opts ...AuthOption) *AuthService {
+	config := newTestConfig()
+	config.PasswordMaxAge = maxAge
+	config.PasswordExpiryMode = mode
+	opts = append([]AuthOption{
+		WithPasswordHasher(testutil.FastHasher{}),
+		WithClock(testutil.NewFakeClock(passwordExpiryNow)),
+	}, opts...)
+	return NewAuthService(userRepo, tokenRepo, config, opts...)
+}
+
+func TestAuthService_LoginPasswordExpiry(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxAge      time.Duration
+		mode        string
+		age         time.Duration
+		wantExpired bool
+		wantErr     error
+	}{
+		{name: "a day before expiry", maxAge: passwordMaxAge, mode: config.PasswordExpiryFlag, age: passwordMaxAge - 24*time.Hour},
+		{name: "a second before expiry", maxAge: passwordMaxAge, mode: config.PasswordExpiryFlag, age: passwordMaxAge - time.Second},
+		{name: "on the day of expiry", maxAge: passwordMaxAge, mode: config.PasswordExpiryFlag, age: passwordMaxAge, wantExpired: true},
+		{name: "expired in restrict mode", maxAge: passwordMaxAge, mode: config.PasswordExpiryRestrict, age: passwordMaxAge + 24*time.Hour, wantExpired: true},
+		{name: "a second before expiry in strict mode", maxAge: passwordMaxAge, mode: config.PasswordExpiryStrict, age: passwordMaxAge - time.Second},
+		{name: "on the day of expiry in strict mode", maxAge: passwordMaxAge, mode: config.PasswordExpiryStrict, age: passwordMaxAge, wantErr: ErrPasswordExpired},
+		{name: "expiry disabled", mode: config.PasswordExpiryStrict, age: 10 * passwordMaxAge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := testutil.NewMockUser(testutil.WithPassword("password"))
+			user.PasswordChangedAt = passwordExpiryNow.Add(-tt.age)
+			mockRepo := new(MockRepository)
+			mockTokenRepo := new(MockTokenRepository)
+			recorder := &fakeLoginRecorder{}
+			mockRepo.On("FindByEmail", mock.Anything, user.Email).Return(&user, nil)
+			if tt.wantErr == nil {
+				mockRepo.On("UpdateLastLogin", mock.Anything, user.ID, mock.Anything).Return(nil)
+				mockTokenRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+			}
+			service := newPasswordExpiryTestService(mockRepo, mockTokenRepo, tt.maxAge, tt.mode, WithLoginRecorder(recorder))
+
+			tokens, err := service.Login(context.Background(), LoginInput{Email: user.Email, Password: "password"})
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, tokens)
+				require.Len(t, recorder.events, 1)
+				assert.False(t, recorder.events[0].Success, "no session was created")
+				var expired *PasswordExpiredError
+				require.ErrorAs(t, err, &expired)
+				claims := jwt.MapClaims{}
+				_, err = (&jwt.Parser{SkipClaimsValidation: true}).ParseWithClaims(expired.PasswordChangeToken, claims,
+					func(*jwt.Token) (interface{}, error) { return []byte("test-secret"), nil })
+				require.NoError(t, err)
+				assert.Equal(t, []interface{}{ScopeProfileWrite}, claims["scopes"], "the token only changes the password")
+				assert.NotContains(t, claims, "sid", "the token has no session to refresh")
+			} else {
+				require.NoError(t, err)
+				assert.NotEmpty(t, tokens.AccessToken)
+				assert.Equal(t, tt.wantExpired, tokens.PasswordExpired)
+			}
+			mockRepo.AssertExpectations(t)
+			mockTokenRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthService_CheckPasswordExpiry(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+
+	tests := []struct {
+		name    string
+		maxAge  time.Duration
+		age     time.Duration
+		findErr error
+		wantErr error
+	}{
+		{name: "within the maximum age", maxAge: passwordMaxAge, age: passwordMaxAge - time.Second},
+		{name: "reached the maximum age", maxAge: passwordMaxAge, age: passwordMaxAge, wantErr: ErrPasswordExpired},
+		{name: "expiry disabled", age: 10 * passwordMaxAge},
+		{name: "user not found", maxAge: passwordMaxAge, findErr: repository.ErrNotFound, wantErr: ErrUserNotFound},
+		{name: "database timeout", maxAge: passwordMaxAge, findErr: repository.ErrTimeout, wantErr: repository.ErrTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			service := newPasswordExpiryTestService(mockRepo, new(MockTokenRepository), tt.maxAge, config.PasswordExpiryRestrict)
+			if tt.maxAge > 0 {
+				user := mockUser
+				user.PasswordChangedAt = passwordExpiryNow.Add(-tt.age)
+				if tt.findErr != nil {
+					mockRepo.On("FindByID", mock.Anything, mockUser.ID.String()).Return(nil, tt.findErr)
+				} else {
+					mockRepo.On("FindByID", mock.Anything, mockUser.ID.String()).Return(&user, nil)
+				}
+			}
+
+			err := service.CheckPasswordExpiry(context.Background(), mockUser.ID.String())
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthService_PasswordChangeRestartsExpiry(t *testing.T) {
+	mockUser := testutil.NewMockUser(testutil.WithPassword("password"))
+	mockUser.PasswordChangedAt = passwordExpiryNow.Add(-2 * passwordMaxAge)
+
+	t.Run("register", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		mockRepo.On("FindByEmail", mock.Anything, "new@example.com").Return(nil, repository.ErrNotFound)
+		mockRepo.On("CreateWithOutbox", mock.Anything, mock.AnythingOfType("*model.User")).Return(nil)
+		service := newPasswordExpiryTestService(mockRepo, new(MockTokenRepository), passwordMaxAge, config.PasswordExpiryFlag)
+
+		user, err := service.Register(context.Background(), RegisterInput{Email: "new@example.com", Password: "password", FullName: "New User"})
+
+		require.NoError(t, err)
+		assert.Equal(t, passwordExpiryNow, user.PasswordChangedAt)
+	})
+
+	t.Run("change", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		mockRepo.On("FindByIDWithPassword", mock.Anything, mockUser.ID.String()).Return(&mockUser, nil)
+		mockRepo.On("UpdatePassword", mock.Anything, mockUser.ID, mock.Anything, passwordExpiryNow).Return(nil)
+		service := newPasswordExpiryTestService(mockRepo, new(MockTokenRepository), passwordMaxAge, config.PasswordExpiryFlag)
+
+		err := service.ChangePassword(context.Background(), mockUser.ID.String(), ChangePasswordInput{CurrentPassword: "password", NewPassword: "new-password"})
+
+		require.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		mockTokenRepo := new(MockTokenRepository)
+		mockRepo.On("UpdatePassword", mock.Anything, mockUser.ID, mock.Anything, passwordExpiryNow).Return(nil)
+		mockRepo.On("IncrementTokenVersion", mock.Anything, mockUser.ID).Return(nil)
+		mockTokenRepo.On("RevokeAllForUser", mock.Anything, mockUser.ID).Return(nil)
+		service := newPasswordExpiryTestService(mockRepo, mockTokenRepo, passwordMaxAge, config.PasswordExpiryStrict)
+
+		err := service.ResetPassword(context.Background(), mockUser.ID, "new-password")
+
+		require.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,378 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/mailer"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/google/uuid"
+)
+
+// RecoveryLinkExpiry is how long the link sent on approval of a recovery request stays valid.
+const RecoveryLinkExpiry = 24 * time.Hour
+
+var (
+	ErrInvalidRecoveryToken   = errors.New("invalid or expired recovery token")
+	ErrInvalidRecoveryStatus  = errors.New("status must be one of pending, approved, denied or completed")
+	ErrRecoveryStateConflict  = errors.New("recovery request cannot move to that state")
+	ErrRecoverySelfApproval   = errors.New("admins cannot approve the recovery of their own account")
+	ErrRecoveryRequestMissing = errors.New("recovery request not found")
+	ErrInvalidRecoveryID      = errors.New("invalid recovery request id")
+)
+
+// recoveryTransitions lists the states each state of a recovery request can
+// move to. Denied and completed requests are final.
+var recoveryTransitions = map[string][]string{
+	model.RecoveryPending:  {model.RecoveryApproved, model.RecoveryDenied},
+	model.RecoveryApproved: {model.RecoveryCompleted},
+}
+
+// canTransition reports whether a recovery request can move from the state
+// from to the state to.
+func canTransition(from, to string) bool {
+	return slices.Contains(recoveryTransitions[from], to)
+}
+
+type RecoveryUserRepository interface {
+	FindByEmail(ctx context.Context, email string) (*model.User, error)
+	FindByID(ctx context.Context, id string) (*model.User, error)
+	FindByRole(ctx context.Context, role string) ([]model.User, error)
+	UpdateEmail(ctx context.Context, id uuid.UUID, email string) error
+}
+
+type RecoveryRepository interface {
+	Create(ctx context.Context, request *model.RecoveryRequest) error
+	FindByID(ctx context.Context, id uuid.UUID) (*model.RecoveryRequest, error)
+	FindByHash(ctx context.Context, tokenHash string) (*model.RecoveryRequest, error)
+	ListPage(ctx context.Context, status string, offset, limit int, desc bool) ([]model.RecoveryRequest, int64, error)
+	Transition(ctx context.Context, request *model.RecoveryRequest, from string) error
+}
+
+type RecoveryRequestInput struct {
+	Email    string `json:"email" binding:"required,email"`
+	Evidence string `json:"evidence" binding:"required,max=4000"`
+}
+
+type ApproveRecoveryInput struct {
+	NewEmail string `json:"new_email" binding:"required,email"`
+}
+
+type CompleteRecoveryInput struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=8"`
+}
+
+// RecoveryService handles users who lost access to both their password and
+// their mailbox. They file a request with evidence of owning the account,
+// which an admin approves or denies. Approval binds the account to a new
+// email address and mails a one-time recovery link there, with which the
+// user chooses a new password.
+type RecoveryService struct {
+	userRepo  RecoveryUserRepository
+	requests  RecoveryRepository
+	mailer    mailer.Mailer
+	templates *mailer.Templates
+	passwords PasswordResetter
+	clock     Clock
+}
+
+// NewRecoveryService creates a RecoveryService that mails admins and
+// recovery links with m, replaces passwords through passwords and expires
+// recovery links at the time told by clock.
+func NewRecoveryService(userRepo RecoveryUserRepository, requests RecoveryRepository, m mailer.Mailer, templates *mailer.Templates, passwords PasswordResetter, clock Clock) *RecoveryService {
+	return &RecoveryService{userRepo: userRepo, requests: requests, mailer: m, templates: templates, passwords: passwords, clock: clock}
+}
+
+// Submit files a pending recovery request for the account of input.Email and
+// notifies every admin, returning the request. If no account has that email,
+// nothing is stored and Submit returns nil without an error, so that callers
+// cannot tell which addresses have accounts.
+func (s *RecoveryService) Submit(ctx context.Context, input RecoveryRequestInput) (*model.RecoveryRequest, error) {
+	user, err := s.userRepo.FindByEmail(ctx, input.Email)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	request := &model.RecoveryRequest{
+		UserID:   user.ID,
+		Email:    user.Email,
+		Evidence: input.Evidence,
+		Status:   model.RecoveryPending,
+	}
+	if err := s.requests.Create(ctx, request); err != nil {
+		return nil, err
+	}
+
+	s.notifyAdmins(ctx, request)
+	return request, nil
+}
+
+// notifyAdmins mails every admin about request. The request is already
+// stored and listed for review, so failures are logged rather than returned.
+func (s *RecoveryService) notifyAdmins(ctx context.Context, request *model.RecoveryRequest) {
+	admins, err := s.userRepo.FindByRole(ctx, model.RoleAdmin)
+	if err != nil {
+		slog.Warn("failed to find admins to notify of a recovery request", "request_id", request.ID, "error", err)
+		return
+	}
+
+	for _, admin := range admins {
+		msg, err := s.templates.RecoveryRequested(admin.FullName, request.Email, request.ID.String())
+		if err == nil {
+			err = s.mailer.Send(ctx, admin.Email, msg.Subject, msg.HTML, msg.Text)
+		}
+		if err != nil {
+			slog.Warn("failed to notify admin of a recovery request", "request_id", request.ID, "admin_id", admin.ID, "error", err)
+		}
+	}
+}
+
+// List returns the recovery requests on page, in the given status or in
+// every status if it is empty, along with the total number of such requests.
+// It returns ErrInvalidPage if the page number or size is out of range, and
+// ErrInvalidRecoveryStatus if status is not a state of a request.
+func (s *RecoveryService) List(ctx context.Context, status string, page PageRequest) ([]model.RecoveryRequest, int64, error) {
+	if !page.valid() {
+		return nil, 0, ErrInvalidPage
+	}
+	switch status {
+	case "", model.RecoveryPending, model.RecoveryApproved, model.RecoveryDenied, model.RecoveryCompleted:
+	default:
+		return nil, 0, ErrInvalidRecoveryStatus
+	}
+
+	return s.requests.ListPage(ctx, status, page.Offset(), page.PerPage, page.Desc)
+}
+
+// Approve approves the pending recovery request id on behalf of the admin
+// adminID, binding the account to input.NewEmail, and mails a recovery link
+// valid for RecoveryLinkExpiry to that address. An admin can never approve
+// the recovery of their own account, which returns ErrRecoverySelfApproval.
+// The new address must not belong to another account. If the link cannot be
+// mailed, the request is made pending again, so that it can be approved again.
+func (s *RecoveryService) Approve(ctx context.Context, adminID, id string, input ApproveRecoveryInput) (*model.RecoveryRequest, error) {
+	request, err := s.find(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if request.UserID.String() == adminID {
+		return nil, ErrRecoverySelfApproval
+	}
+	if !canTransition(request.Status, model.RecoveryApproved) {
+		return nil, ErrRecoveryStateConflict
+	}
+
+	user, err := s.userRepo.FindByID(ctx, request.UserID.String())
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(input.NewEmail, user.Email) {
+		return nil, ErrSameEmail
+	}
+	if err := s.ensureEmailAvailable(ctx, input.NewEmail, user.ID); err != nil {
+		return nil, err
+	}
+
+	token, err := generateOpaqueToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate recovery token: %w", err)
+	}
+
+	reviewerID, err := uuid.Parse(adminID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid admin ID: %w", err)
+	}
+	now := s.clock.Now()
+	expiresAt := now.Add(RecoveryLinkExpiry)
+	tokenHash := hashToken(token)
+	request.Status = model.RecoveryApproved
+	request.ReviewerID = &reviewerID
+	request.ReviewedAt = &now
+	request.NewEmail = &input.NewEmail
+	request.TokenHash = &tokenHash
+	request.ExpiresAt = &expiresAt
+	if err := s.transition(ctx, request, model.RecoveryPending); err != nil {
+		return nil, err
+	}
+
+	msg, err := s.templates.AccountRecovery(user.FullName, token)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mailer.Send(ctx, input.NewEmail, msg.Subject, msg.HTML, msg.Text); err != nil {
+		s.unapprove(ctx, request)
+		return nil, err
+	}
+	return request, nil
+}
+
+// Deny denies the pending recovery request id on behalf of the admin adminID.
+func (s *RecoveryService) Deny(ctx context.Context, adminID, id string) (*model.RecoveryRequest, error) {
+	request, err := s.find(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !canTransition(request.Status, model.RecoveryDenied) {
+		return nil, ErrRecoveryStateConflict
+	}
+
+	reviewerID, err := uuid.Parse(adminID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid admin ID: %w", err)
+	}
+	now := s.clock.Now()
+	request.Status = model.RecoveryDenied
+	request.ReviewerID = &reviewerID
+	request.ReviewedAt = &now
+	if err := s.transition(ctx, request, model.RecoveryPending); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// Complete handles the link of an approved recovery request. It completes the
+// request, after which the link no longer works, then moves the account to the
+// approved email address and replaces the user's password with
+// input.NewPassword, which signs them out everywhere. The request is completed
+// first so that of several uses of the link at once only one changes the
+// account. The email is moved before the password is replaced, since another
+// account may take the address after it was checked; that returns
+// ErrEmailTaken with the password unchanged. If the password cannot be
+// replaced, such as for a breached one, the account is moved back to its old
+// address. Either way the request is approved again, so the user can try
+// again.
+func (s *RecoveryService) Complete(ctx context.Context, input CompleteRecoveryInput) (*model.RecoveryRequest, error) {
+	request, err := s.requests.FindByHash(ctx, hashToken(input.Token))
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRecoveryToken, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !canTransition(request.Status, model.RecoveryCompleted) || request.ExpiresAt == nil || !s.clock.Now().Before(*request.ExpiresAt) {
+		return nil, ErrInvalidRecoveryToken
+	}
+
+	if err := s.ensureEmailAvailable(ctx, *request.NewEmail, request.UserID); err != nil {
+		return nil, err
+	}
+	user, err := s.userRepo.FindByID(ctx, request.UserID.String())
+	if err != nil {
+		return nil, err
+	}
+
+	tokenHash := request.TokenHash
+	now := s.clock.Now()
+	request.Status = model.RecoveryCompleted
+	request.TokenHash = nil
+	request.CompletedAt = &now
+	if err := s.transition(ctx, request, model.RecoveryApproved); err != nil {
+		if errors.Is(err, ErrRecoveryStateConflict) {
+			return nil, ErrInvalidRecoveryToken
+		}
+		return nil, err
+	}
+
+	err = s.userRepo.UpdateEmail(ctx, request.UserID, *request.NewEmail)
+	if errors.Is(err, repository.ErrDuplicate) {
+		err = fmt.Errorf("%w: %w", ErrEmailTaken, err)
+	}
+	if err != nil {
+		s.reopen(ctx, request, tokenHash)
+		return nil, err
+	}
+
+	if err := s.passwords.ResetPassword(ctx, request.UserID, input.NewPassword); err != nil {
+		s.restoreEmail(ctx, request.UserID, user.Email)
+		s.reopen(ctx, request, tokenHash)
+		return nil, err
+	}
+	return request, nil
+}
+
+// restoreEmail moves the account that Complete moved to a new address back to
+// email, after its password could not be replaced. A failure is logged, as
+// the user recovers the account at the new address either way.
+func (s *RecoveryService) restoreEmail(ctx context.Context, userID uuid.UUID, email string) {
+	if err := s.userRepo.UpdateEmail(context.WithoutCancel(ctx), userID, email); err != nil {
+		slog.Warn("failed to restore the email of a recovered account", "user_id", userID, "error", err)
+	}
+}
+
+// reopen approves the request that Complete completed again, with tokenHash
+// as the hash of its link, so that the link works again after the changes to
+// the account failed. A failure is logged, as the user then needs a new link
+// anyway.
+func (s *RecoveryService) reopen(ctx context.Context, request *model.RecoveryRequest, tokenHash *string) {
+	request.Status = model.RecoveryApproved
+	request.TokenHash = tokenHash
+	request.CompletedAt = nil
+	if err := s.transition(context.WithoutCancel(ctx), request, model.RecoveryCompleted); err != nil {
+		slog.Warn("failed to reopen a recovery request", "request_id", request.ID, "error", err)
+	}
+}
+
+// unapprove makes the request that Approve approved pending again, after its
+// link could not be mailed, so that an admin can approve it again. A failure
+// is logged, as the link was never received and the request must then be
+// filed again.
+func (s *RecoveryService) unapprove(ctx context.Context, request *model.RecoveryRequest) {
+	request.Status = model.RecoveryPending
+	request.ReviewerID = nil
+	request.ReviewedAt = nil
+	request.NewEmail = nil
+	request.TokenHash = nil
+	request.ExpiresAt = nil
+	if err := s.transition(context.WithoutCancel(ctx), request, model.RecoveryApproved); err != nil {
+		slog.Warn("failed to make a recovery request pending again", "request_id", request.ID, "error", err)
+	}
+}
+
+// find returns the recovery request id. It returns ErrInvalidRecoveryID if id
+// is not a UUID, and ErrRecoveryRequestMissing if there is no such request.
+func (s *RecoveryService) find(ctx context.Context, id string) (*model.RecoveryRequest, error) {
+	requestID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrInvalidRecoveryID
+	}
+
+	request, err := s.requests.FindByID(ctx, requestID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %w", ErrRecoveryRequestMissing, err)
+	}
+	return request, err
+}
+
+// transition saves request in its new state if it is still in the state
+// from, and returns ErrRecoveryStateConflict if another review or use of its
+// link moved it first.
+func (s *RecoveryService) transition(ctx context.Context, request *model.RecoveryRequest, from string) error {
+	err := s.requests.Transition(ctx, request, from)
+	if errors.Is(err, repository.ErrNotFound) {
+		return fmt.Errorf("%w: %w", ErrRecoveryStateConflict, err)
+	}
+	return err
+}
+
+// ensureEmailAvailable returns ErrEmailTaken if email belongs to a
+// user other than userID.
+func (s *RecoveryService) ensureEmailAvailable(ctx context.Context, email string, userID uuid.UUID) error {
+	existing, err := s.userRepo.FindByEmail(ctx, email)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+	if existing != nil && existing.ID != userID {
+		return ErrEmailTaken
+	}
+	return nil
+}
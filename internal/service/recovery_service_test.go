@@ -0,0 +1,467 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/mailer"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockRecoveryRepository struct {
+	mock.Mock
+}
+
+func (r *MockRecoveryRepository) Create(ctx context.Context, request *model.RecoveryRequest) error {
+	args := r.Called(ctx, request)
+	return args.Error(0)
+}
+
+func (r *MockRecoveryRepository) FindByID(ctx context.Context, id uuid.UUID) (*model.RecoveryRequest, error) {
+	args := r.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.RecoveryRequest), args.Error(1)
+}
+
+func (r *MockRecoveryRepository) FindByHash(ctx context.Context, tokenHash string) (*model.RecoveryRequest, error) {
+	args := r.Called(ctx, tokenHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.RecoveryRequest), args.Error(1)
+}
+
+func (r *MockRecoveryRepository) ListPage(ctx context.Context, status string, offset, limit int, desc bool) ([]model.RecoveryRequest, int64, error) {
+	args := r.Called(ctx, status, offset, limit, desc)
+	if args.Get(0) == nil {
+		return nil, args.Get(1).(int64), args.Error(2)
+	}
+	return args.Get(0).([]model.RecoveryRequest), args.Get(1).(int64), args.Error(2)
+}
+
+func (r *MockRecoveryRepository) Transition(ctx context.Context, request *model.RecoveryRequest, from string) error {
+	args := r.Called(ctx, request, from)
+	return args.Error(0)
+}
+
+func setupRecoveryTest() (*RecoveryService, *MockRepository, *MockRecoveryRepository, *MockPasswordResetter, *fakeMailer) {
+	userRepo := new(MockRepository)
+	requests := new(MockRecoveryRepository)
+	passwords := new(MockPasswordResetter)
+	m := &fakeMailer{}
+	s := NewRecoveryService(userRepo, requests, m, mailer.NewTemplates("https://app.example.com"), passwords, SystemClock{})
+	return s, userRepo, requests, passwords, m
+}
+
+func TestCanTransition(t *testing.T) {
+	states := []string{model.RecoveryPending, model.RecoveryApproved, model.RecoveryDenied, model.RecoveryCompleted}
+	allowed := map[[2]string]bool{
+		{model.RecoveryPending, model.RecoveryApproved}:   true,
+		{model.RecoveryPending, model.RecoveryDenied}:     true,
+		{model.RecoveryApproved, model.RecoveryCompleted}: true,
+	}
+
+	for _, from := range states {
+		for _, to := range states {
+			assert.Equal(t, allowed[[2]string{from, to}], canTransition(from, to), "%s -> %s", from, to)
+		}
+	}
+}
+
+func TestRecoveryService_Submit(t *testing.T) {
+	user := testutil.NewMockUser()
+	admin := testutil.NewMockUser(testutil.WithID(uuid.New()), testutil.WithEmail("admin@example.com"), testutil.WithRole(model.RoleAdmin))
+	input := RecoveryRequestInput{Email: user.Email, Evidence: "I signed up in 2020 from Bangkok"}
+
+	t.Run("files a pending request and notifies admins", func(t *testing.T) {
+		s, userRepo, requests, _, m := setupRecoveryTest()
+		userRepo.On("FindByEmail", mock.Anything, user.Email).Return(&user, nil)
+		requests.On("Create", mock.Anything, mock.MatchedBy(func(r *model.RecoveryRequest) bool {
+			return r.UserID == user.ID && r.Status == model.RecoveryPending && r.Evidence == input.Evidence
+		})).Return(nil)
+		userRepo.On("FindByRole", mock.Anything, model.RoleAdmin).Return([]model.User{admin}, nil)
+
+		request, err := s.Submit(context.Background(), input)
+
+		require.NoError(t, err)
+		assert.Equal(t, model.RecoveryPending, request.Status)
+		require.Len(t, m.sent, 1)
+		assert.Equal(t, admin.Email, m.sent[0].to)
+		assert.Contains(t, m.sent[0].text, user.Email)
+		userRepo.AssertExpectations(t)
+		requests.AssertExpectations(t)
+	})
+
+	t.Run("unknown email stores nothing", func(t *testing.T) {
+		s, userRepo, requests, _, m := setupRecoveryTest()
+		userRepo.On("FindByEmail", mock.Anything, user.Email).Return(nil, repository.ErrNotFound)
+
+		request, err := s.Submit(context.Background(), input)
+
+		assert.NoError(t, err)
+		assert.Nil(t, request)
+		assert.Empty(t, m.sent)
+		requests.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("failing to notify admins keeps the request", func(t *testing.T) {
+		s, userRepo, requests, _, _ := setupRecoveryTest()
+		userRepo.On("FindByEmail", mock.Anything, user.Email).Return(&user, nil)
+		requests.On("Create", mock.Anything, mock.Anything).Return(nil)
+		userRepo.On("FindByRole", mock.Anything, model.RoleAdmin).Return(nil, repository.ErrTimeout)
+
+		request, err := s.Submit(context.Background(), input)
+
+		assert.NoError(t, err)
+		assert.NotNil(t, request)
+	})
+}
+
+func TestRecoveryService_Approve(t *testing.T) {
+	user := testutil.NewMockUser()
+	adminID := uuid.New()
+	input := ApproveRecoveryInput{NewEmail: "new@example.com"}
+	newRequest := func(status string) *model.RecoveryRequest {
+		return &model.RecoveryRequest{ID: uuid.New(), UserID: user.ID, Email: user.Email, Status: status}
+	}
+
+	tests := []struct {
+		name     string
+		adminID  string
+		mockFn   func(*MockRepository, *MockRecoveryRepository, *model.RecoveryRequest)
+		status   string
+		wantErr  error
+		wantMail bool
+	}{
+		{
+			name:   "pending request approved",
+			status: model.RecoveryPending,
+			mockFn: func(userRepo *MockRepository, requests *MockRecoveryRepository, request *model.RecoveryRequest) {
+				userRepo.On("FindByID", mock.Anything, user.ID.String()).Return(&user, nil)
+				userRepo.On("FindByEmail", mock.Anything, input.NewEmail).Return(nil, repository.ErrNotFound)
+				requests.On("Transition", mock.Anything, request, model.RecoveryPending).Return(nil)
+			},
+			wantMail: true,
+		},
+		{
+			name:    "admin approving their own account",
+			adminID: user.ID.String(),
+			status:  model.RecoveryPending,
+			wantErr: ErrRecoverySelfApproval,
+		},
+		{
+			name:    "denied request",
+			status:  model.RecoveryDenied,
+			wantErr: ErrRecoveryStateConflict,
+		},
+		{
+			name:    "already approved",
+			status:  model.RecoveryApproved,
+			wantErr: ErrRecoveryStateConflict,
+		},
+		{
+			name:   "new email taken",
+			status: model.RecoveryPending,
+			mockFn: func(userRepo *MockRepository, requests *MockRecoveryRepository, request *model.RecoveryRequest) {
+				other := testutil.NewMockUser(testutil.WithID(uuid.New()), testutil.WithEmail(input.NewEmail))
+				userRepo.On("FindByID", mock.Anything, user.ID.String()).Return(&user, nil)
+				userRepo.On("FindByEmail", mock.Anything, input.NewEmail).Return(&other, nil)
+			},
+			wantErr: ErrEmailTaken,
+		},
+		{
+			name:   "reviewed concurrently",
+			status: model.RecoveryPending,
+			mockFn: func(userRepo *MockRepository, requests *MockRecoveryRepository, request *model.RecoveryRequest) {
+				userRepo.On("FindByID", mock.Anything, user.ID.String()).Return(&user, nil)
+				userRepo.On("FindByEmail", mock.Anything, input.NewEmail).Return(nil, repository.ErrNotFound)
+				requests.On("Transition", mock.Anything, request, model.RecoveryPending).Return(repository.ErrNotFound)
+			},
+			wantErr: ErrRecoveryStateConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, userRepo, requests, _, m := setupRecoveryTest()
+			request := newRequest(tt.status)
+			requests.On("FindByID", mock.Anything, request.ID).Return(request, nil)
+			if tt.mockFn != nil {
+				tt.mockFn(userRepo, requests, request)
+			}
+			actor := adminID.String()
+			if tt.adminID != "" {
+				actor = tt.adminID
+			}
+
+			got, err := s.Approve(context.Background(), actor, request.ID.String(), input)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Empty(t, m.sent)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, model.RecoveryApproved, got.Status)
+				assert.Equal(t, adminID, *got.ReviewerID)
+				assert.Equal(t, input.NewEmail, *got.NewEmail)
+				require.Len(t, m.sent, 1)
+				assert.Equal(t, input.NewEmail, m.sent[0].to)
+				assert.Equal(t, hashToken(tokenFromEmail(t, m.sent[0])), *got.TokenHash)
+			}
+			userRepo.AssertExpectations(t)
+			requests.AssertExpectations(t)
+		})
+	}
+
+	t.Run("link not mailed", func(t *testing.T) {
+		s, userRepo, requests, _, m := setupRecoveryTest()
+		m.err = errors.New("smtp unavailable")
+		request := newRequest(model.RecoveryPending)
+		requests.On("FindByID", mock.Anything, request.ID).Return(request, nil)
+		userRepo.On("FindByID", mock.Anything, user.ID.String()).Return(&user, nil)
+		userRepo.On("FindByEmail", mock.Anything, input.NewEmail).Return(nil, repository.ErrNotFound)
+		requests.On("Transition", mock.Anything, request, model.RecoveryPending).Return(nil).Once()
+		requests.On("Transition", mock.Anything, request, model.RecoveryApproved).Return(nil).Once()
+
+		_, err := s.Approve(context.Background(), adminID.String(), request.ID.String(), input)
+
+		assert.ErrorIs(t, err, m.err)
+		assert.Len(t, m.sent, 1)
+		assert.Equal(t, model.RecoveryPending, request.Status, "pending again, so it can be approved again")
+		assert.Nil(t, request.ReviewerID)
+		assert.Nil(t, request.TokenHash)
+		requests.AssertExpectations(t)
+	})
+
+	t.Run("unknown request", func(t *testing.T) {
+		s, _, requests, _, _ := setupRecoveryTest()
+		id := uuid.New()
+		requests.On("FindByID", mock.Anything, id).Return(nil, repository.ErrNotFound)
+
+		_, err := s.Approve(context.Background(), adminID.String(), id.String(), input)
+
+		assert.ErrorIs(t, err, ErrRecoveryRequestMissing)
+	})
+
+	t.Run("invalid request ID", func(t *testing.T) {
+		s, _, _, _, _ := setupRecoveryTest()
+
+		_, err := s.Approve(context.Background(), adminID.String(), "not-a-uuid", input)
+
+		assert.ErrorIs(t, err, ErrInvalidRecoveryID)
+	})
+}
+
+func TestRecoveryService_Deny(t *testing.T) {
+	adminID := uuid.New()
+
+	for _, status := range []string{model.RecoveryApproved, model.RecoveryDenied, model.RecoveryCompleted} {
+		t.Run("cannot deny "+status, func(t *testing.T) {
+			s, _, requests, _, _ := setupRecoveryTest()
+			request := &model.RecoveryRequest{ID: uuid.New(), UserID: uuid.New(), Status: status}
+			requests.On("FindByID", mock.Anything, request.ID).Return(request, nil)
+
+			_, err := s.Deny(context.Background(), adminID.String(), request.ID.String())
+
+			assert.ErrorIs(t, err, ErrRecoveryStateConflict)
+			requests.AssertNotCalled(t, "Transition", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+
+	t.Run("pending request denied", func(t *testing.T) {
+		s, _, requests, _, m := setupRecoveryTest()
+		request := &model.RecoveryRequest{ID: uuid.New(), UserID: uuid.New(), Status: model.RecoveryPending}
+		requests.On("FindByID", mock.Anything, request.ID).Return(request, nil)
+		requests.On("Transition", mock.Anything, request, model.RecoveryPending).Return(nil)
+
+		got, err := s.Deny(context.Background(), adminID.String(), request.ID.String())
+
+		require.NoError(t, err)
+		assert.Equal(t, model.RecoveryDenied, got.Status)
+		assert.Equal(t, adminID, *got.ReviewerID)
+		assert.Nil(t, got.TokenHash)
+		assert.Empty(t, m.sent)
+		requests.AssertExpectations(t)
+	})
+}
+
+func TestRecoveryService_Complete(t *testing.T) {
+	const token = "recovery-token"
+	input := CompleteRecoveryInput{Token: token, NewPassword: "new-password"}
+	newEmail := "new@example.com"
+	user := testutil.NewMockUser()
+	newRequest := func(status string, expiresIn time.Duration) *model.RecoveryRequest {
+		tokenHash := hashToken(token)
+		expiresAt := time.Now().Add(expiresIn)
+		return &model.RecoveryRequest{
+			ID:        uuid.New(),
+			UserID:    user.ID,
+			Status:    status,
+			NewEmail:  &newEmail,
+			TokenHash: &tokenHash,
+			ExpiresAt: &expiresAt,
+		}
+	}
+
+	tests := []struct {
+		name    string
+		request *model.RecoveryRequest
+		mockFn  func(*MockRepository, *MockRecoveryRepository, *MockPasswordResetter, *model.RecoveryRequest)
+		wantErr error
+		// wantPasswordKept is set when the password must not be replaced.
+		wantPasswordKept bool
+	}{
+		{
+			name:    "approved request completed",
+			request: newRequest(model.RecoveryApproved, time.Hour),
+			mockFn: func(userRepo *MockRepository, requests *MockRecoveryRepository, passwords *MockPasswordResetter, request *model.RecoveryRequest) {
+				userRepo.On("FindByEmail", mock.Anything, newEmail).Return(nil, repository.ErrNotFound)
+				userRepo.On("FindByID", mock.Anything, user.ID.String()).Return(&user, nil)
+				requests.On("Transition", mock.Anything, request, model.RecoveryApproved).Return(nil).Once()
+				userRepo.On("UpdateEmail", mock.Anything, request.UserID, newEmail).Return(nil)
+				passwords.On("ResetPassword", mock.Anything, request.UserID, "new-password").Return(nil)
+			},
+		},
+		{
+			name:    "expired link",
+			request: newRequest(model.RecoveryApproved, -time.Minute),
+			wantErr: ErrInvalidRecoveryToken,
+		},
+		{
+			name:    "completed request",
+			request: newRequest(model.RecoveryCompleted, time.Hour),
+			wantErr: ErrInvalidRecoveryToken,
+		},
+		{
+			name:    "breached password keeps the link usable and the old email",
+			request: newRequest(model.RecoveryApproved, time.Hour),
+			mockFn: func(userRepo *MockRepository, requests *MockRecoveryRepository, passwords *MockPasswordResetter, request *model.RecoveryRequest) {
+				userRepo.On("FindByEmail", mock.Anything, newEmail).Return(nil, repository.ErrNotFound)
+				userRepo.On("FindByID", mock.Anything, user.ID.String()).Return(&user, nil)
+				requests.On("Transition", mock.Anything, request, model.RecoveryApproved).Return(nil).Once()
+				userRepo.On("UpdateEmail", mock.Anything, request.UserID, newEmail).Return(nil).Once()
+				passwords.On("ResetPassword", mock.Anything, request.UserID, "new-password").Return(ErrPasswordBreached)
+				userRepo.On("UpdateEmail", mock.Anything, request.UserID, user.Email).Return(nil).Once()
+				requests.On("Transition", mock.Anything, mock.MatchedBy(func(r *model.RecoveryRequest) bool {
+					return r.Status == model.RecoveryApproved && r.TokenHash != nil && *r.TokenHash == hashToken(token) && r.CompletedAt == nil
+				}), model.RecoveryCompleted).Return(nil).Once()
+			},
+			wantErr: ErrPasswordBreached,
+		},
+		{
+			name:    "failed email change reopens the request",
+			request: newRequest(model.RecoveryApproved, time.Hour),
+			mockFn: func(userRepo *MockRepository, requests *MockRecoveryRepository, passwords *MockPasswordResetter, request *model.RecoveryRequest) {
+				userRepo.On("FindByEmail", mock.Anything, newEmail).Return(nil, repository.ErrNotFound)
+				userRepo.On("FindByID", mock.Anything, user.ID.String()).Return(&user, nil)
+				requests.On("Transition", mock.Anything, request, model.RecoveryApproved).Return(nil).Once()
+				userRepo.On("UpdateEmail", mock.Anything, request.UserID, newEmail).Return(repository.ErrTimeout)
+				requests.On("Transition", mock.Anything, request, model.RecoveryCompleted).Return(nil).Once()
+			},
+			wantErr: repository.ErrTimeout,
+		},
+		{
+			name:    "email taken since the check leaves the password unchanged",
+			request: newRequest(model.RecoveryApproved, time.Hour),
+			mockFn: func(userRepo *MockRepository, requests *MockRecoveryRepository, passwords *MockPasswordResetter, request *model.RecoveryRequest) {
+				userRepo.On("FindByEmail", mock.Anything, newEmail).Return(nil, repository.ErrNotFound)
+				userRepo.On("FindByID", mock.Anything, user.ID.String()).Return(&user, nil)
+				requests.On("Transition", mock.Anything, request, model.RecoveryApproved).Return(nil).Once()
+				userRepo.On("UpdateEmail", mock.Anything, request.UserID, newEmail).Return(repository.ErrDuplicate)
+				requests.On("Transition", mock.Anything, request, model.RecoveryCompleted).Return(nil).Once()
+			},
+			wantErr:          ErrEmailTaken,
+			wantPasswordKept: true,
+		},
+		{
+			name:    "link used concurrently changes nothing",
+			request: newRequest(model.RecoveryApproved, time.Hour),
+			mockFn: func(userRepo *MockRepository, requests *MockRecoveryRepository, passwords *MockPasswordResetter, request *model.RecoveryRequest) {
+				userRepo.On("FindByEmail", mock.Anything, newEmail).Return(nil, repository.ErrNotFound)
+				userRepo.On("FindByID", mock.Anything, user.ID.String()).Return(&user, nil)
+				requests.On("Transition", mock.Anything, request, model.RecoveryApproved).Return(repository.ErrNotFound)
+			},
+			wantErr: ErrInvalidRecoveryToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, userRepo, requests, passwords, _ := setupRecoveryTest()
+			requests.On("FindByHash", mock.Anything, hashToken(token)).Return(tt.request, nil)
+			if tt.mockFn != nil {
+				tt.mockFn(userRepo, requests, passwords, tt.request)
+			}
+
+			got, err := s.Complete(context.Background(), input)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, model.RecoveryCompleted, got.Status)
+				assert.Nil(t, got.TokenHash)
+				assert.NotNil(t, got.CompletedAt)
+			}
+			if tt.wantPasswordKept {
+				passwords.AssertNotCalled(t, "ResetPassword", mock.Anything, mock.Anything, mock.Anything)
+			}
+			userRepo.AssertExpectations(t)
+			requests.AssertExpectations(t)
+			passwords.AssertExpectations(t)
+		})
+	}
+
+	t.Run("unknown token", func(t *testing.T) {
+		s, _, requests, _, _ := setupRecoveryTest()
+		requests.On("FindByHash", mock.Anything, hashToken(token)).Return(nil, repository.ErrNotFound)
+
+		_, err := s.Complete(context.Background(), input)
+
+		assert.ErrorIs(t, err, ErrInvalidRecoveryToken)
+	})
+}
+
+func TestRecoveryService_List(t *testing.T) {
+	page := PageRequest{Page: 2, PerPage: 10, Sort: "created_at", Desc: true}
+
+	t.Run("lists a status", func(t *testing.T) {
+		s, _, requests, _, _ := setupRecoveryTest()
+		want := []model.RecoveryRequest{{ID: uuid.New(), Status: model.RecoveryPending}}
+		requests.On("ListPage", mock.Anything, model.RecoveryPending, 10, 10, true).Return(want, int64(11), nil)
+
+		got, total, err := s.List(context.Background(), model.RecoveryPending, page)
+
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+		assert.Equal(t, int64(11), total)
+	})
+
+	t.Run("unknown status", func(t *testing.T) {
+		s, _, _, _, _ := setupRecoveryTest()
+
+		_, _, err := s.List(context.Background(), "archived", page)
+
+		assert.ErrorIs(t, err, ErrInvalidRecoveryStatus)
+	})
+
+	t.Run("database error", func(t *testing.T) {
+		s, _, requests, _, _ := setupRecoveryTest()
+		dbErr := errors.New("connection reset")
+		requests.On("ListPage", mock.Anything, "", 10, 10, true).Return(nil, int64(0), dbErr)
+
+		_, _, err := s.List(context.Background(), "", page)
+
+		assert.ErrorIs(t, err, dbErr)
+	})
+}
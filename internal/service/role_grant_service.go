@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/google/uuid"
+)
+
+// MaxRoleGrantDuration is how long a temporary role grant can last at most.
+const MaxRoleGrantDuration = 7 * 24 * time.Hour
+
+// RoleGrantExpiryBatch is the number of expired grants ended per batch by
+// RoleGrantService.ExpireDue.
+const RoleGrantExpiryBatch = 100
+
+// AuditMethodExpire is the method of the audit entries recorded for grants
+// ended by RoleGrantService.ExpireDue, rather than by a request.
+const AuditMethodExpire = "EXPIRE"
+
+var (
+	ErrInvalidRoleGrantID = errors.New("invalid grant id")
+	ErrRoleGrantNotFound  = errors.New("grant not found")
+	ErrUnknownRole        = errors.New("unknown role")
+	ErrRoleNotHeld        = errors.New("cannot grant a role you do not hold")
+	ErrRoleAlreadyHeld    = errors.New("user already holds the role")
+	ErrGrantOutlastsActor = errors.New("grant cannot outlast your own grant of the role")
+	ErrInvalidGrantExpiry = errors.New("expires_at must be in the future and at most 7 days away")
+)
+
+type RoleGrantRepository interface {
+	Create(ctx context.Context, grant *model.RoleGrant) error
+	FindActive(ctx context.Context, userID uuid.UUID, role string, at time.Time) (*model.RoleGrant, error)
+	ListActive(ctx context.Context, at time.Time) ([]model.RoleGrant, error)
+	Revoke(ctx context.Context, id, revokedBy uuid.UUID, at time.Time) (*model.RoleGrant, error)
+	ExpireDue(ctx context.Context, at time.Time, limit int) ([]model.RoleGrant, error)
+}
+
+type RoleGrantUserRepository interface {
+	FindByID(ctx context.Context, id string) (*model.User, error)
+}
+
+// RoleGrantAuditor records the audit entries of the grants that expire.
+type RoleGrantAuditor interface {
+	Record(entry *model.AuditEntry)
+}
+
+type RoleGrantInput struct {
+	UserID    string    `json:"user_id" binding:"required"`
+	Role      string    `json:"role" binding:"required"`
+	ExpiresAt time.Time `json:"expires_at" binding:"required"`
+	Reason    string    `json:"reason" binding:"required,max=500"`
+}
+
+// RoleGrantService manages temporary role grants, which give a user a role on
+// top of their own until the grant expires or is revoked, such as admin access
+// for an afternoon.
+type RoleGrantService struct {
+	grants RoleGrantRepository
+	users  RoleGrantUserRepository
+	audit  RoleGrantAuditor
+	clock  Clock
+}
+
+// NewRoleGrantService creates a RoleGrantService that looks users up in
+// users, which should be cached as every admin request of a user without the
+// admin role checks their grants, records the grants that expire in audit,
+// and tells active grants by the time of clock.
+func NewRoleGrantService(grants RoleGrantRepository, users RoleGrantUserRepository, audit RoleGrantAuditor, clock Clock) *RoleGrantService {
+	return &RoleGrantService{grants: grants, users: users, audit: audit, clock: clock}
+}
+
+// HoldsRole reports whether the user userID holds role now, as their own role
+// or through an active grant. Unknown users hold no role.
+func (s *RoleGrantService) HoldsRole(ctx context.Context, userID, role string) (bool, error) {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return false, ErrInvalidUserID
+	}
+
+	_, held, err := s.heldUntil(ctx, id, role)
+	if errors.Is(err, ErrUserNotFound) {
+		return false, nil
+	}
+	return held, err
+}
+
+// heldUntil reports whether the user id holds role now and, if they hold it
+// through a grant rather than as their own role, when the grant expires. It
+// returns ErrUserNotFound if there is no such user.
+func (s *RoleGrantService) heldUntil(ctx context.Context, id uuid.UUID, role string) (time.Time, bool, error) {
+	user, err := s.users.FindByID(ctx, id.String())
+	if errors.Is(err, repository.ErrNotFound) {
+		return time.Time{}, false, fmt.Errorf("%w: %w", ErrUserNotFound, err)
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	if user.Role == role {
+		return time.Time{}, true, nil
+	}
+
+	grant, err := s.grants.FindActive(ctx, id, role, s.clock.Now())
+	if errors.Is(err, repository.ErrNotFound) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return grant.ExpiresAt, true, nil
+}
+
+// Create grants input.Role to the user input.UserID until input.ExpiresAt on
+// behalf of the admin actorID. Admins can only grant a role they hold
+// themselves, and only until their own grant of it expires if they hold it
+// through one; otherwise it returns ErrRoleNotHeld or ErrGrantOutlastsActor.
+// It returns ErrUnknownRole for a role outside the scope registry,
+// ErrInvalidGrantExpiry unless the grant expires within MaxRoleGrantDuration,
+// ErrUserNotFound if there is no such user, and ErrRoleAlreadyHeld if the
+// user already holds the role, which includes the admin granting it to
+// themselves.
+func (s *RoleGrantService) Create(ctx context.Context, actorID string, input RoleGrantInput) (*model.RoleGrant, error) {
+	if _, ok := roleScopes[input.Role]; !ok {
+		return nil, ErrUnknownRole
+	}
+	userID, err := uuid.Parse(input.UserID)
+	if err != nil {
+		return nil, ErrInvalidUserID
+	}
+	grantedBy, err := uuid.Parse(actorID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid admin ID: %w", err)
+	}
+	now := s.clock.Now()
+	if !input.ExpiresAt.After(now) || input.ExpiresAt.Sub(now) > MaxRoleGrantDuration {
+		return nil, ErrInvalidGrantExpiry
+	}
+
+	actorUntil, held, err := s.heldUntil(ctx, grantedBy, input.Role)
+	if err != nil {
+		return nil, err
+	}
+	if !held {
+		return nil, ErrRoleNotHeld
+	}
+	if !actorUntil.IsZero() && input.ExpiresAt.After(actorUntil) {
+		return nil, ErrGrantOutlastsActor
+	}
+
+	_, held, err = s.heldUntil(ctx, userID, input.Role)
+	if err != nil {
+		return nil, err
+	}
+	if held {
+		return nil, ErrRoleAlreadyHeld
+	}
+
+	grant := &model.RoleGrant{
+		UserID:    userID,
+		Role:      input.Role,
+		Reason:    input.Reason,
+		GrantedBy: grantedBy,
+		ExpiresAt: input.ExpiresAt,
+	}
+	if err := s.grants.Create(ctx, grant); err != nil {
+		return nil, err
+	}
+	return grant, nil
+}
+
+// Active returns the grants active now, the first to expire first.
+func (s *RoleGrantService) Active(ctx context.Context) ([]model.RoleGrant, error) {
+	return s.grants.ListActive(ctx, s.clock.Now())
+}
+
+// Revoke ends the active grant id now on behalf of the admin actorID and
+// returns it. It returns ErrRoleGrantNotFound if there is no such grant or it
+// has already expired or been revoked.
+func (s *RoleGrantService) Revoke(ctx context.Context, actorID, id string) (*model.RoleGrant, error) {
+	grantID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrInvalidRoleGrantID
+	}
+	revokedBy, err := uuid.Parse(actorID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid admin ID: %w", err)
+	}
+
+	grant, err := s.grants.Revoke(ctx, grantID, revokedBy, s.clock.Now())
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %w", ErrRoleGrantNotFound, err)
+	}
+	return grant, err
+}
+
+// ExpireDue ends every grant that has expired, in batches of
+// RoleGrantExpiryBatch, and records an audit entry for each about the user
+// demoted. The entries have no actor, the method AuditMethodExpire and the
+// path of the grant under the admin API. It is the role-grant-expiry job.
+// Expired grants stop counting as soon as they expire, whether or not the job
+// has run since.
+func (s *RoleGrantService) ExpireDue(ctx context.Context) error {
+	for {
+		now := s.clock.Now()
+		grants, err := s.grants.ExpireDue(ctx, now, RoleGrantExpiryBatch)
+		if err != nil {
+			return err
+		}
+		for i := range grants {
+			grant := &grants[i]
+			s.audit.Record(&model.AuditEntry{
+				SubjectID: &grant.UserID,
+				Method:    AuditMethodExpire,
+				Path:      "/api/admin/grants/" + grant.ID.String(),
+				Status:    http.StatusOK,
+				CreatedAt: now,
+			})
+		}
+		if len(grants) < RoleGrantExpiryBatch {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,386 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRoleGrants is a RoleGrantRepository that keeps grants in memory.
+type fakeRoleGrants struct {
+	grants []*model.RoleGrant
+	err    error
+}
+
+func (f *fakeRoleGrants) Create(_ context.Context, grant *model.RoleGrant) error {
+	if f.err != nil {
+		return f.err
+	}
+	grant.ID = uuid.New()
+	copied := *grant
+	f.grants = append(f.grants, &copied)
+	return nil
+}
+
+func (f *fakeRoleGrants) FindActive(_ context.Context, userID uuid.UUID, role string, at time.Time) (*model.RoleGrant, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	var found *model.RoleGrant
+	for _, grant := range f.grants {
+		if grant.UserID == userID && grant.Role == role && grant.ActiveAt(at) &&
+			(found == nil || grant.ExpiresAt.After(found.ExpiresAt)) {
+			found = grant
+		}
+	}
+	if found == nil {
+		return nil, repository.ErrNotFound
+	}
+	copied := *found
+	return &copied, nil
+}
+
+func (f *fakeRoleGrants) ListActive(_ context.Context, at time.Time) ([]model.RoleGrant, error) {
+	var active []model.RoleGrant
+	for _, grant := range f.grants {
+		if grant.ActiveAt(at) {
+			active = append(active, *grant)
+		}
+	}
+	return active, f.err
+}
+
+func (f *fakeRoleGrants) Revoke(_ context.Context, id, revokedBy uuid.UUID, at time.Time) (*model.RoleGrant, error) {
+	for _, grant := range f.grants {
+		if grant.ID == id && grant.ActiveAt(at) {
+			grant.RevokedAt, grant.RevokedBy, grant.EndedAt = &at, &revokedBy, &at
+			copied := *grant
+			return &copied, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (f *fakeRoleGrants) ExpireDue(_ context.Context, at time.Time, limit int) ([]model.RoleGrant, error) {
+	var ended []model.RoleGrant
+	for _, grant := range f.grants {
+		if len(ended) < limit && grant.EndedAt == nil && !at.Before(grant.ExpiresAt) {
+			grant.EndedAt = &at
+			ended = append(ended, *grant)
+		}
+	}
+	return ended, f.err
+}
+
+// fakeGrantUsers is a RoleGrantUserRepository of the users it holds by ID.
+type fakeGrantUsers map[uuid.UUID]*model.User
+
+func (f fakeGrantUsers) FindByID(_ context.Context, id string) (*model.User, error) {
+	user, ok := f[uuid.MustParse(id)]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return user, nil
+}
+
+type fakeAuditRecorder struct {
+	entries []*model.AuditEntry
+}
+
+func (r *fakeAuditRecorder) Record(entry *model.AuditEntry) {
+	r.entries = append(r.entries, entry)
+}
+
+var grantNow = time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+
+type roleGrantFixture struct {
+	service     *RoleGrantService
+	grants      *fakeRoleGrants
+	audit       *fakeAuditRecorder
+	clock       *testutil.FakeClock
+	admin, user *model.User
+}
+
+func setupRoleGrantTest() roleGrantFixture {
+	admin := &model.User{ID: uuid.New(), Role: model.RoleAdmin}
+	user := &model.User{ID: uuid.New(), Role: model.RoleUser}
+	f := roleGrantFixture{
+		grants: &fakeRoleGrants{},
+		audit:  &fakeAuditRecorder{},
+		clock:  testutil.NewFakeClock(grantNow),
+		admin:  admin,
+		user:   user,
+	}
+	users := fakeGrantUsers{admin.ID: admin, user.ID: user}
+	f.service = NewRoleGrantService(f.grants, users, f.audit, f.clock)
+	return f
+}
+
+func (f roleGrantFixture) grantAdmin(t *testing.T, to *model.User, d time.Duration) *model.RoleGrant {
+	t.Helper()
+	grant, err := f.service.Create(context.Background(), f.admin.ID.String(), RoleGrantInput{
+		UserID:    to.ID.String(),
+		Role:      model.RoleAdmin,
+		ExpiresAt: f.clock.Now().Add(d),
+		Reason:    "incident 42",
+	})
+	require.NoError(t, err)
+	return grant
+}
+
+func TestRoleGrantService_Create(t *testing.T) {
+	f := setupRoleGrantTest()
+
+	grant := f.grantAdmin(t, f.user, 4*time.Hour)
+
+	assert.Equal(t, f.user.ID, grant.UserID)
+	assert.Equal(t, model.RoleAdmin, grant.Role)
+	assert.Equal(t, f.admin.ID, grant.GrantedBy)
+	assert.Equal(t, grantNow.Add(4*time.Hour), grant.ExpiresAt)
+	assert.Equal(t, "incident 42", grant.Reason)
+	held, err := f.service.HoldsRole(context.Background(), f.user.ID.String(), model.RoleAdmin)
+	require.NoError(t, err)
+	assert.True(t, held)
+	active, err := f.service.Active(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, active, 1)
+}
+
+func TestRoleGrantService_CreateRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   func(f roleGrantFixture) RoleGrantInput
+		wantErr error
+	}{
+		{
+			name: "unknown role",
+			input: func(f roleGrantFixture) RoleGrantInput {
+				return RoleGrantInput{UserID: f.user.ID.String(), Role: "root", ExpiresAt: grantNow.Add(time.Hour)}
+			},
+			wantErr: ErrUnknownRole,
+		},
+		{
+			name: "invalid user id",
+			input: func(f roleGrantFixture) RoleGrantInput {
+				return RoleGrantInput{UserID: "not-a-uuid", Role: model.RoleAdmin, ExpiresAt: grantNow.Add(time.Hour)}
+			},
+			wantErr: ErrInvalidUserID,
+		},
+		{
+			name: "already expired",
+			input: func(f roleGrantFixture) RoleGrantInput {
+				return RoleGrantInput{UserID: f.user.ID.String(), Role: model.RoleAdmin, ExpiresAt: grantNow}
+			},
+			wantErr: ErrInvalidGrantExpiry,
+		},
+		{
+			name: "longer than the maximum",
+			input: func(f roleGrantFixture) RoleGrantInput {
+				return RoleGrantInput{UserID: f.user.ID.String(), Role: model.RoleAdmin, ExpiresAt: grantNow.Add(MaxRoleGrantDuration + time.Second)}
+			},
+			wantErr: ErrInvalidGrantExpiry,
+		},
+		{
+			name: "unknown user",
+			input: func(f roleGrantFixture) RoleGrantInput {
+				return RoleGrantInput{UserID: uuid.NewString(), Role: model.RoleAdmin, ExpiresAt: grantNow.Add(time.Hour)}
+			},
+			wantErr: ErrUserNotFound,
+		},
+		{
+			name: "user already has the role",
+			input: func(f roleGrantFixture) RoleGrantInput {
+				return RoleGrantInput{UserID: f.admin.ID.String(), Role: model.RoleAdmin, ExpiresAt: grantNow.Add(time.Hour)}
+			},
+			wantErr: ErrRoleAlreadyHeld,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := setupRoleGrantTest()
+
+			got, err := f.service.Create(context.Background(), f.admin.ID.String(), tt.input(f))
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Nil(t, got)
+			assert.Empty(t, f.grants.grants)
+		})
+	}
+}
+
+func TestRoleGrantService_SelfEscalationGuard(t *testing.T) {
+	t.Run("role the actor does not hold", func(t *testing.T) {
+		f := setupRoleGrantTest()
+		other := &model.User{ID: uuid.New(), Role: model.RoleUser}
+		f.service.users = fakeGrantUsers{f.admin.ID: f.admin, f.user.ID: f.user, other.ID: other}
+
+		for _, to := range []*model.User{other, f.user} {
+			_, err := f.service.Create(context.Background(), f.user.ID.String(), RoleGrantInput{
+				UserID:    to.ID.String(),
+				Role:      model.RoleAdmin,
+				ExpiresAt: grantNow.Add(time.Hour),
+			})
+			assert.ErrorIs(t, err, ErrRoleNotHeld, "neither to another user nor to themselves")
+		}
+		assert.Empty(t, f.grants.grants)
+	})
+
+	t.Run("grant outlasting the actor's own", func(t *testing.T) {
+		f := setupRoleGrantTest()
+		other := &model.User{ID: uuid.New(), Role: model.RoleUser}
+		f.service.users = fakeGrantUsers{f.admin.ID: f.admin, f.user.ID: f.user, other.ID: other}
+		f.grantAdmin(t, f.user, 2*time.Hour)
+
+		_, err := f.service.Create(context.Background(), f.user.ID.String(), RoleGrantInput{
+			UserID:    other.ID.String(),
+			Role:      model.RoleAdmin,
+			ExpiresAt: grantNow.Add(3 * time.Hour),
+		})
+		assert.ErrorIs(t, err, ErrGrantOutlastsActor)
+
+		_, err = f.service.Create(context.Background(), f.user.ID.String(), RoleGrantInput{
+			UserID:    f.user.ID.String(),
+			Role:      model.RoleAdmin,
+			ExpiresAt: grantNow.Add(time.Hour),
+		})
+		assert.ErrorIs(t, err, ErrRoleAlreadyHeld, "a grant cannot be extended by its holder")
+
+		grant, err := f.service.Create(context.Background(), f.user.ID.String(), RoleGrantInput{
+			UserID:    other.ID.String(),
+			Role:      model.RoleAdmin,
+			ExpiresAt: grantNow.Add(2 * time.Hour),
+		})
+		require.NoError(t, err, "a temporary admin can grant the role for as long as they hold it")
+		assert.Equal(t, f.user.ID, grant.GrantedBy)
+	})
+
+	t.Run("actor whose grant expired", func(t *testing.T) {
+		f := setupRoleGrantTest()
+		f.grantAdmin(t, f.user, time.Hour)
+		f.clock.Advance(time.Hour)
+
+		_, err := f.service.Create(context.Background(), f.user.ID.String(), RoleGrantInput{
+			UserID:    f.user.ID.String(),
+			Role:      model.RoleAdmin,
+			ExpiresAt: grantNow.Add(2 * time.Hour),
+		})
+		assert.ErrorIs(t, err, ErrRoleNotHeld)
+	})
+}
+
+func TestRoleGrantService_Expiry(t *testing.T) {
+	ctx := context.Background()
+	f := setupRoleGrantTest()
+	grant := f.grantAdmin(t, f.user, time.Hour)
+
+	f.clock.Advance(time.Hour - time.Second)
+	require.NoError(t, f.service.ExpireDue(ctx))
+	held, err := f.service.HoldsRole(ctx, f.user.ID.String(), model.RoleAdmin)
+	require.NoError(t, err)
+	assert.True(t, held, "until it expires")
+	assert.Empty(t, f.audit.entries)
+
+	f.clock.Advance(time.Second)
+	held, err = f.service.HoldsRole(ctx, f.user.ID.String(), model.RoleAdmin)
+	require.NoError(t, err)
+	assert.False(t, held, "as soon as it expires, before the job runs")
+	active, err := f.service.Active(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, active)
+
+	require.NoError(t, f.service.ExpireDue(ctx))
+	require.Len(t, f.audit.entries, 1)
+	entry := f.audit.entries[0]
+	assert.Equal(t, uuid.Nil, entry.ActorID)
+	assert.Equal(t, &f.user.ID, entry.SubjectID)
+	assert.Equal(t, AuditMethodExpire, entry.Method)
+	assert.Equal(t, "/api/admin/grants/"+grant.ID.String(), entry.Path)
+	assert.Equal(t, http.StatusOK, entry.Status)
+	assert.Equal(t, f.clock.Now(), entry.CreatedAt)
+
+	require.NoError(t, f.service.ExpireDue(ctx))
+	assert.Len(t, f.audit.entries, 1, "a grant is demoted once")
+	_, err = f.service.Revoke(ctx, f.admin.ID.String(), grant.ID.String())
+	assert.ErrorIs(t, err, ErrRoleGrantNotFound, "an expired grant cannot be revoked")
+}
+
+func TestRoleGrantService_ExpireDueInBatches(t *testing.T) {
+	f := setupRoleGrantTest()
+	for i := 0; i < RoleGrantExpiryBatch+1; i++ {
+		f.grants.grants = append(f.grants.grants, &model.RoleGrant{ID: uuid.New(), UserID: uuid.New(), Role: model.RoleAdmin, ExpiresAt: grantNow})
+	}
+
+	require.NoError(t, f.service.ExpireDue(context.Background()))
+
+	require.Len(t, f.audit.entries, RoleGrantExpiryBatch+1)
+	assert.NotEqual(t, f.audit.entries[0].SubjectID, f.audit.entries[1].SubjectID, "each entry is about its own user")
+}
+
+func TestRoleGrantService_ExpireDueFails(t *testing.T) {
+	f := setupRoleGrantTest()
+	f.grants.err = errors.New("connection refused")
+
+	err := f.service.ExpireDue(context.Background())
+
+	assert.EqualError(t, err, "connection refused")
+	assert.Empty(t, f.audit.entries)
+}
+
+func TestRoleGrantService_Revoke(t *testing.T) {
+	ctx := context.Background()
+	f := setupRoleGrantTest()
+	grant := f.grantAdmin(t, f.user, 4*time.Hour)
+	f.clock.Advance(time.Hour)
+
+	revoked, err := f.service.Revoke(ctx, f.admin.ID.String(), grant.ID.String())
+
+	require.NoError(t, err)
+	assert.Equal(t, &f.admin.ID, revoked.RevokedBy)
+	assert.Equal(t, f.clock.Now(), *revoked.RevokedAt)
+	held, err := f.service.HoldsRole(ctx, f.user.ID.String(), model.RoleAdmin)
+	require.NoError(t, err)
+	assert.False(t, held, "revoked before it expires")
+
+	_, err = f.service.Revoke(ctx, f.admin.ID.String(), grant.ID.String())
+	assert.ErrorIs(t, err, ErrRoleGrantNotFound)
+	_, err = f.service.Revoke(ctx, f.admin.ID.String(), "not-a-uuid")
+	assert.ErrorIs(t, err, ErrInvalidRoleGrantID)
+
+	f.clock.Advance(4 * time.Hour)
+	require.NoError(t, f.service.ExpireDue(ctx))
+	assert.Empty(t, f.audit.entries, "revoked grants are not demoted again")
+}
+
+func TestRoleGrantService_HoldsRole(t *testing.T) {
+	ctx := context.Background()
+	f := setupRoleGrantTest()
+
+	held, err := f.service.HoldsRole(ctx, f.admin.ID.String(), model.RoleAdmin)
+	require.NoError(t, err)
+	assert.True(t, held, "their own role")
+
+	held, err = f.service.HoldsRole(ctx, f.user.ID.String(), model.RoleAdmin)
+	require.NoError(t, err)
+	assert.False(t, held)
+
+	held, err = f.service.HoldsRole(ctx, uuid.NewString(), model.RoleAdmin)
+	require.NoError(t, err)
+	assert.False(t, held, "unknown users hold no role")
+
+	_, err = f.service.HoldsRole(ctx, "not-a-uuid", model.RoleAdmin)
+	assert.ErrorIs(t, err, ErrInvalidUserID)
+
+	f.grants.err = repository.ErrTimeout
+	_, err = f.service.HoldsRole(ctx, f.user.ID.String(), model.RoleAdmin)
+	assert.ErrorIs(t, err, repository.ErrTimeout)
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/PakornBank/learn-go/internal/model"
+)
+
+// Scopes an access token can carry in its "scopes" claim. Routes check them
+// with middleware.RequireScope.
+const (
+	ScopeProfileRead  = "profile:read"
+	ScopeProfileWrite = "profile:write"
+	ScopeUsersAdmin   = "users:admin"
+)
+
+var (
+	ErrUnknownScope    = errors.New("unknown scope")
+	ErrScopeNotGranted = errors.New("scope not granted")
+	ErrNoScopes        = errors.New("at least one scope is required")
+)
+
+// roleScopes is the scope registry: every known scope, and the full set that
+// password logins of each role receive.
+var roleScopes = map[string][]string{
+	model.RoleUser:  {ScopeProfileRead, ScopeProfileWrite},
+	model.RoleAdmin: {ScopeProfileRead, ScopeProfileWrite, ScopeUsersAdmin},
+}
+
+// ScopesForRole returns the scopes granted to users with role, or none for an
+// unknown role. The caller may modify the returned slice.
+func ScopesForRole(role string) []string {
+	return slices.Clone(roleScopes[role])
+}
+
+// IsKnownScope reports whether scope is in the registry.
+func IsKnownScope(scope string) bool {
+	for _, scopes := range roleScopes {
+		if slices.Contains(scopes, scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateScopes checks that scopes is not empty, that every scope in it is
+// in the registry, and that every scope is also in granted. The returned
+// error wraps ErrNoScopes, ErrUnknownScope or ErrScopeNotGranted and names the
+// offending scope.
+func ValidateScopes(scopes, granted []string) error {
+	if len(scopes) == 0 {
+		return ErrNoScopes
+	}
+	for _, scope := range scopes {
+		if !IsKnownScope(scope) {
+			return fmt.Errorf("%w: %s", ErrUnknownScope, scope)
+		}
+		if !slices.Contains(granted, scope) {
+			return fmt.Errorf("%w: %s", ErrScopeNotGranted, scope)
+		}
+	}
+	return nil
+}
+
+// GrantedScopes returns the scopes a grant of role adds for a user whose own
+// role is base: those of role that base does not have. The caller may modify
+// the returned slice.
+func GrantedScopes(base, role string) []string {
+	var added []string
+	for _, scope := range roleScopes[role] {
+		if !slices.Contains(roleScopes[base], scope) {
+			added = append(added, scope)
+		}
+	}
+	return added
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScopesForRole(t *testing.T) {
+	assert.Equal(t, []string{ScopeProfileRead, ScopeProfileWrite}, ScopesForRole(model.RoleUser))
+	assert.Equal(t, []string{ScopeProfileRead, ScopeProfileWrite, ScopeUsersAdmin}, ScopesForRole(model.RoleAdmin))
+	assert.Empty(t, ScopesForRole("guest"))
+
+	scopes := ScopesForRole(model.RoleUser)
+	scopes[0] = ScopeUsersAdmin
+	assert.Equal(t, ScopeProfileRead, ScopesForRole(model.RoleUser)[0], "the registry is not modified")
+}
+
+func TestValidateScopes(t *testing.T) {
+	granted := []string{ScopeProfileRead, ScopeProfileWrite}
+
+	tests := []struct {
+		name    string
+		scopes  []string
+		wantErr error
+		// wantScope is the scope the error must name.
+		wantScope string
+	}{
+		{name: "granted scopes", scopes: []string{ScopeProfileWrite, ScopeProfileRead}},
+		{name: "empty", scopes: nil, wantErr: ErrNoScopes},
+		{name: "unknown", scopes: []string{ScopeProfileRead, "profile:delete"}, wantErr: ErrUnknownScope, wantScope: "profile:delete"},
+		{name: "not granted", scopes: []string{ScopeUsersAdmin}, wantErr: ErrScopeNotGranted, wantScope: ScopeUsersAdmin},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateScopes(tt.scopes, granted)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.ErrorContains(t, err, tt.wantScope)
+			}
+		})
+	}
+}
+
+func TestGrantedScopes(t *testing.T) {
+	assert.Equal(t, []string{ScopeUsersAdmin}, GrantedScopes(model.RoleUser, model.RoleAdmin))
+	assert.Empty(t, GrantedScopes(model.RoleAdmin, model.RoleUser))
+	assert.Empty(t, GrantedScopes(model.RoleAdmin, model.RoleAdmin))
+	assert.Equal(t, ScopesForRole(model.RoleAdmin), GrantedScopes("", model.RoleAdmin), "a user without a role gains every scope of the grant")
+}
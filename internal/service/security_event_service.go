@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/device"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/google/uuid"
+)
+
+type SecurityEventRepository interface {
+	ListByUser(ctx context.Context, userID uuid.UUID, since time.Time, cursor string, limit int) ([]model.SecurityEvent, string, error)
+}
+
+type SecurityEventService struct {
+	eventRepo SecurityEventRepository
+}
+
+func NewSecurityEventService(eventRepo SecurityEventRepository) *SecurityEventService {
+	return &SecurityEventService{eventRepo: eventRepo}
+}
+
+// ListForUser returns a page of the user's security events that occurred after
+// since, or all of them if since is zero, newest first, along with the cursor
+// for the next page. The addresses of the events are given with their host
+// part zeroed, as for the user's sessions, whichever record they were read
+// from.
+func (s *SecurityEventService) ListForUser(ctx context.Context, userID string, since time.Time, cursor string, limit int) ([]model.SecurityEvent, string, error) {
+	if limit < 1 || limit > MaxListLimit {
+		return nil, "", ErrInvalidLimit
+	}
+
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, "", ErrInvalidUserID
+	}
+
+	events, nextCursor, err := s.eventRepo.ListByUser(ctx, id, since, cursor, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	for i := range events {
+		events[i].IPAddress = device.TruncateIP(events[i].IPAddress)
+	}
+	return events, nextCursor, nil
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockSecurityEventRepository struct {
+	mock.Mock
+}
+
+func (r *MockSecurityEventRepository) ListByUser(ctx context.Context, userID uuid.UUID, since time.Time, cursor string, limit int) ([]model.SecurityEvent, string, error) {
+	args := r.Called(ctx, userID, since, cursor, limit)
+	if args.Get(0) == nil {
+		return nil, args.String(1), args.Error(2)
+	}
+	return args.Get(0).([]model.SecurityEvent), args.String(1), args.Error(2)
+}
+
+func TestSecurityEventService_ListForUser(t *testing.T) {
+	userID := uuid.New()
+	since := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	changed := model.SecurityEvent{ID: uuid.New(), Type: model.SecurityEventPasswordChanged, IPAddress: "198.51.100.7"}
+	revoked := model.SecurityEvent{ID: uuid.New(), Type: model.SecurityEventSessionRevoked, IPAddress: "192.0.2.0"}
+	dbErr := errors.New("database error")
+
+	tests := []struct {
+		name           string
+		userID         string
+		limit          int
+		mockFn         func(*MockSecurityEventRepository)
+		wantEvents     []model.SecurityEvent
+		wantNextCursor string
+		wantErr        error
+	}{
+		{
+			name:   "successful listing",
+			userID: userID.String(),
+			limit:  10,
+			mockFn: func(repo *MockSecurityEventRepository) {
+				repo.On("ListByUser", mock.Anything, userID, since, "cursor", 10).Return([]model.SecurityEvent{changed, revoked}, "next", nil)
+			},
+			wantEvents: []model.SecurityEvent{
+				{ID: changed.ID, Type: changed.Type, IPAddress: "198.51.100.0"},
+				revoked,
+			},
+			wantNextCursor: "next",
+		},
+		{
+			name:   "repository error",
+			userID: userID.String(),
+			limit:  10,
+			mockFn: func(repo *MockSecurityEventRepository) {
+				repo.On("ListByUser", mock.Anything, userID, since, "cursor", 10).Return(nil, "", dbErr)
+			},
+			wantErr: dbErr,
+		},
+		{
+			name:    "invalid user id",
+			userID:  "not-a-uuid",
+			limit:   10,
+			wantErr: ErrInvalidUserID,
+		},
+		{
+			name:    "limit out of range",
+			userID:  userID.String(),
+			limit:   MaxListLimit + 1,
+			wantErr: ErrInvalidLimit,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockSecurityEventRepository)
+			if tt.mockFn != nil {
+				tt.mockFn(mockRepo)
+			}
+			eventService := NewSecurityEventService(mockRepo)
+
+			events, nextCursor, err := eventService.ListForUser(context.Background(), tt.userID, since, "cursor", tt.limit)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, events)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantEvents, events)
+				assert.Equal(t, tt.wantNextCursor, nextCursor)
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
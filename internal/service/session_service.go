@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/google/uuid"
+)
+
+// maxSessionName is the longest name a session can be given, in characters.
+const maxSessionName = 64
+
+var (
+	ErrInvalidSessionID   = errors.New("invalid session id")
+	ErrInvalidSessionName = fmt.Errorf("session name must be 1 to %d characters", maxSessionName)
+	ErrSessionNotFound    = errors.New("session not found")
+)
+
+type SessionRepository interface {
+	ListActiveForUser(ctx context.Context, userID uuid.UUID) ([]model.RefreshToken, error)
+	RenameFamily(ctx context.Context, userID, familyID uuid.UUID, name string) error
+}
+
+type RenameSessionInput struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// SessionService lists the sessions a user is logged in with and lets them
+// name the sessions to tell them apart. A session is a refresh token family.
+type SessionService struct {
+	tokenRepo SessionRepository
+}
+
+// NewSessionService creates a SessionService reading sessions from tokenRepo.
+func NewSessionService(tokenRepo SessionRepository) *SessionService {
+	return &SessionService{tokenRepo: tokenRepo}
+}
+
+// List returns the current refresh token of each active session of the user
+// with userID, most recently used first.
+func (s *SessionService) List(ctx context.Context, userID string) ([]model.RefreshToken, error) {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, ErrInvalidUserID
+	}
+
+	return s.tokenRepo.ListActiveForUser(ctx, id)
+}
+
+// Rename gives the session with sessionID of the user with userID the name in
+// input, with surrounding whitespace removed. It returns ErrSessionNotFound
+// if the user has no such session or it was revoked.
+func (s *SessionService) Rename(ctx context.Context, userID, sessionID string, input RenameSessionInput) error {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return ErrInvalidUserID
+	}
+	familyID, err := uuid.Parse(sessionID)
+	if err != nil {
+		return ErrInvalidSessionID
+	}
+
+	name := strings.TrimSpace(input.Name)
+	if name == "" || utf8.RuneCountInString(name) > maxSessionName || !utf8.ValidString(name) {
+		return ErrInvalidSessionName
+	}
+
+	err = s.tokenRepo.RenameFamily(ctx, id, familyID, name)
+	if errors.Is(err, repository.ErrNotFound) {
+		return fmt.Errorf("%w: %w", ErrSessionNotFound, err)
+	}
+	return err
+}
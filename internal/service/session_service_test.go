@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockSessionRepository struct {
+	mock.Mock
+}
+
+func (r *MockSessionRepository) ListActiveForUser(ctx context.Context, userID uuid.UUID) ([]model.RefreshToken, error) {
+	args := r.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.RefreshToken), args.Error(1)
+}
+
+func (r *MockSessionRepository) RenameFamily(ctx context.Context, userID, familyID uuid.UUID, name string) error {
+	args := r.Called(ctx, userID, familyID, name)
+	return args.Error(0)
+}
+
+func TestSessionService_List(t *testing.T) {
+	userID := uuid.New()
+	session := model.RefreshToken{ID: uuid.New(), UserID: userID, FamilyID: uuid.New(), DeviceLabel: "Chrome on macOS"}
+
+	tests := []struct {
+		name         string
+		userID       string
+		mockFn       func(*MockSessionRepository)
+		wantSessions []model.RefreshToken
+		wantErr      error
+	}{
+		{
+			name:   "successful listing",
+			userID: userID.String(),
+			mockFn: func(repo *MockSessionRepository) {
+				repo.On("ListActiveForUser", mock.Anything, userID).Return([]model.RefreshToken{session}, nil)
+			},
+			wantSessions: []model.RefreshToken{session},
+		},
+		{
+			name:    "invalid user id",
+			userID:  "not-a-uuid",
+			wantErr: ErrInvalidUserID,
+		},
+		{
+			name:   "repository error",
+			userID: userID.String(),
+			mockFn: func(repo *MockSessionRepository) {
+				repo.On("ListActiveForUser", mock.Anything, userID).Return(nil, repository.ErrTimeout)
+			},
+			wantErr: repository.ErrTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(MockSessionRepository)
+			if tt.mockFn != nil {
+				tt.mockFn(repo)
+			}
+			service := NewSessionService(repo)
+
+			got, err := service.List(context.Background(), tt.userID)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, got)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantSessions, got)
+			}
+			repo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestSessionService_Rename(t *testing.T) {
+	userID, familyID := uuid.New(), uuid.New()
+
+	tests := []struct {
+		name      string
+		userID    string
+		sessionID string
+		input     RenameSessionInput
+		mockFn    func(*MockSessionRepository)
+		wantErr   error
+	}{
+		{
+			name:      "successful rename",
+			userID:    userID.String(),
+			sessionID: familyID.String(),
+			input:     RenameSessionInput{Name: "  Work laptop "},
+			mockFn: func(repo *MockSessionRepository) {
+				repo.On("RenameFamily", mock.Anything, userID, familyID, "Work laptop").Return(nil)
+			},
+		},
+		{
+			name:      "invalid user id",
+			userID:    "not-a-uuid",
+			sessionID: familyID.String(),
+			input:     RenameSessionInput{Name: "Work laptop"},
+			wantErr:   ErrInvalidUserID,
+		},
+		{
+			name:      "invalid session id",
+			userID:    userID.String(),
+			sessionID: "not-a-uuid",
+			input:     RenameSessionInput{Name: "Work laptop"},
+			wantErr:   ErrInvalidSessionID,
+		},
+		{
+			name:      "blank name",
+			userID:    userID.String(),
+			sessionID: familyID.String(),
+			input:     RenameSessionInput{Name: "   "},
+			wantErr:   ErrInvalidSessionName,
+		},
+		{
+			name:      "name too long",
+			userID:    userID.String(),
+			sessionID: familyID.String(),
+			input:     RenameSessionInput{Name: strings.Repeat("a", maxSessionName+1)},
+			wantErr:   ErrInvalidSessionName,
+		},
+		{
+			name:      "session not found",
+			userID:    userID.String(),
+			sessionID: familyID.String(),
+			input:     RenameSessionInput{Name: "Work laptop"},
+			mockFn: func(repo *MockSessionRepository) {
+				repo.On("RenameFamily", mock.Anything, userID, familyID, "Work laptop").Return(repository.ErrNotFound)
+			},
+			wantErr: ErrSessionNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(MockSessionRepository)
+			if tt.mockFn != nil {
+				tt.mockFn(repo)
+			}
+			service := NewSessionService(repo)
+
+			err := service.Rename(context.Background(), tt.userID, tt.sessionID, tt.input)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			repo.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// SignupWindow is the span of registrations SignupAnomalyDetector compares
+// with the baseline, and so how often it should run.
+const SignupWindow = 5 * time.Minute
+
+// SignupBaselinePeriod is the span of registrations the baseline of a
+// SignupAnomalyDetector averages, starting from SignupBaselinePeriod before
+// its first run.
+const SignupBaselinePeriod = 24 * time.Hour
+
+// MinSignupSpike is the fewest registrations in a SignupWindow that can be an
+// anomaly, so that a handful of signups after a quiet night is not one.
+const MinSignupSpike = 10
+
+// SignupCounter counts the users created in a span of time.
+type SignupCounter interface {
+	// ctx: Bounds the query.
+	CountCreatedBetween(ctx context.Context, from, to time.Time) (int64, error)
+}
+
+// SignupAnomaly describes a SignupWindow with unusually many registrations.
+type SignupAnomaly struct {
+	// Signups were registered from From until To.
+	Signups int64     `json:"signups"`
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	// Baseline is the usual number of registrations in a SignupWindow, of
+	// which Signups exceeded Multiplier times.
+	Baseline   float64 `json:"baseline"`
+	Multiplier float64 `json:"multiplier"`
+}
+
+// AnomalyNotifier alerts operators to a SignupAnomaly.
+type AnomalyNotifier interface {
+	// ctx: Bounds the delivery of the alert.
+	NotifyAnomaly(ctx context.Context, anomaly SignupAnomaly) error
+}
+
+// SignupAnomalyDetector compares the registrations of the last SignupWindow
+// with a rolling baseline and notifies an AnomalyNotifier once per spike:
+// when they first exceed the baseline by its multiplier, and not again until
+// a window falls back under it. Windows within a spike are left out of the
+// baseline, so a sustained flood does not become the new normal.
+//
+// The baseline is kept in memory but seeded from the users created over the
+// SignupBaselinePeriod before the first run, so that a restart neither
+// forgets what is usual nor alerts on the traffic that was already there.
+// Run must not be called concurrently, as the scheduler guarantees for a job.
+type SignupAnomalyDetector struct {
+	users      SignupCounter
+	notifier   AnomalyNotifier
+	multiplier float64
+	now        func() time.Time
+
+	seeded   bool
+	baseline float64
+	spiking  bool
+}
+
+// NewSignupAnomalyDetector creates a SignupAnomalyDetector counting the users
+// of users and alerting notifier when a window has more than multiplier times
+// the baseline of registrations, and at least MinSignupSpike.
+func NewSignupAnomalyDetector(users SignupCounter, notifier AnomalyNotifier, multiplier float64) *SignupAnomalyDetector {
+	return &SignupAnomalyDetector{users: users, notifier: notifier, multiplier: multiplier, now: time.Now}
+}
+
+// Run checks the registrations of the last SignupWindow, seeding the
+// baseline first if this is the first run. It returns the error of counting
+// the users or of notifying, in which case the spike is notified again at the
+// next run.
+func (d *SignupAnomalyDetector) Run(ctx context.Context) error {
+	now := d.now()
+	from := now.Add(-SignupWindow)
+	if !d.seeded {
+		past, err := d.users.CountCreatedBetween(ctx, from.Add(-SignupBaselinePeriod), from)
+		if err != nil {
+			return err
+		}
+		d.baseline = float64(past) / float64(SignupBaselinePeriod/SignupWindow)
+		d.seeded = true
+	}
+
+	signups, err := d.users.CountCreatedBetween(ctx, from, now)
+	if err != nil {
+		return err
+	}
+
+	if float64(signups) <= max(d.baseline*d.multiplier, MinSignupSpike) {
+		d.spiking = false
+		d.baseline += (float64(signups) - d.baseline) / float64(SignupBaselinePeriod/SignupWindow)
+		return nil
+	}
+	if d.spiking {
+		return nil
+	}
+
+	err = d.notifier.NotifyAnomaly(ctx, SignupAnomaly{
+		Signups:    signups,
+		From:       from,
+		To:         now,
+		Baseline:   d.baseline,
+		Multiplier: d.multiplier,
+	})
+	d.spiking = err == nil
+	return err
+}
+
+// LogAnomalyNotifier notifies anomalies by logging them as warnings. It is
+// used when no webhook is configured.
+type LogAnomalyNotifier struct {
+	logger *slog.Logger
+}
+
+// NewLogAnomalyNotifier creates a LogAnomalyNotifier that writes to logger.
+func NewLogAnomalyNotifier(logger *slog.Logger) *LogAnomalyNotifier {
+	return &LogAnomalyNotifier{logger: logger}
+}
+
+// NotifyAnomaly logs the anomaly at warn level.
+func (n *LogAnomalyNotifier) NotifyAnomaly(ctx context.Context, anomaly SignupAnomaly) error {
+	n.logger.WarnContext(ctx, "signup anomaly",
+		"signups", anomaly.Signups,
+		"from", anomaly.From,
+		"to", anomaly.To,
+		"baseline", anomaly.Baseline,
+		"multiplier", anomaly.Multiplier,
+	)
+	return nil
+}
+
+// WebhookAnomalyNotifier notifies anomalies by POSTing them as JSON to a URL.
+// Any response other than 2xx is treated as a failure.
+type WebhookAnomalyNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookAnomalyNotifier creates a WebhookAnomalyNotifier that posts to url
+// with a 10 second timeout.
+func NewWebhookAnomalyNotifier(url string) *WebhookAnomalyNotifier {
+	return &WebhookAnomalyNotifier{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// NotifyAnomaly POSTs the anomaly to the webhook URL.
+func (n *WebhookAnomalyNotifier) NotifyAnomaly(ctx context.Context, anomaly SignupAnomaly) error {
+	body, err := json.Marshal(anomaly)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
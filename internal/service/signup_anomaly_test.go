@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSignupCounter counts synthetic registrations.
+type fakeSignupCounter struct {
+	created []time.Time
+	queries int
+}
+
+// add registers n users spread over the SignupWindow ending at end.
+func (c *fakeSignupCounter) add(end time.Time, n int) {
+	for i := 0; i < n; i++ {
+		c.created = append(c.created, end.Add(-time.Duration(i+1)*SignupWindow/time.Duration(n+1)))
+	}
+}
+
+func (c *fakeSignupCounter) CountCreatedBetween(_ context.Context, from, to time.Time) (int64, error) {
+	c.queries++
+	var count int64
+	for _, at := range c.created {
+		if !at.Before(from) && at.Before(to) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+type fakeAnomalyNotifier struct {
+	anomalies []SignupAnomaly
+	err       error
+}
+
+func (n *fakeAnomalyNotifier) NotifyAnomaly(_ context.Context, anomaly SignupAnomaly) error {
+	if n.err != nil {
+		return n.err
+	}
+	n.anomalies = append(n.anomalies, anomaly)
+	return nil
+}
+
+// anomalyTest drives a SignupAnomalyDetector through windows of synthetic
+// registrations, one run per window.
+type anomalyTest struct {
+	t        *testing.T
+	users    *fakeSignupCounter
+	notifier *fakeAnomalyNotifier
+	detector *SignupAnomalyDetector
+	now      time.Time
+}
+
+// newAnomalyTest returns an anomalyTest whose history has perWindow
+// registrations in every window of the baseline period.
+func newAnomalyTest(t *testing.T, perWindow int) *anomalyTest {
+	a := &anomalyTest{
+		t:        t,
+		users:    &fakeSignupCounter{},
+		notifier: &fakeAnomalyNotifier{},
+		now:      time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC),
+	}
+	for end := a.now.Add(-SignupBaselinePeriod); end.Before(a.now); end = end.Add(SignupWindow) {
+		a.users.add(end.Add(SignupWindow), perWindow)
+	}
+	a.restart()
+	return a
+}
+
+// restart replaces the detector with a new one, as a restart of the process does.
+func (a *anomalyTest) restart() {
+	a.detector = NewSignupAnomalyDetector(a.users, a.notifier, 3)
+	a.detector.now = func() time.Time { return a.now }
+}
+
+// window registers signups in each of the next windows, running the
+// detector at the end of each.
+func (a *anomalyTest) window(signups ...int) {
+	a.t.Helper()
+	for _, n := range signups {
+		a.now = a.now.Add(SignupWindow)
+		a.users.add(a.now, n)
+		require.NoError(a.t, a.detector.Run(context.Background()))
+	}
+}
+
+func TestSignupAnomalyDetector(t *testing.T) {
+	tests := []struct {
+		name       string
+		history    int
+		windows    []int
+		wantAlerts []int64
+	}{
+		{name: "steady traffic", history: 20, windows: []int{20, 25, 18, 30, 22}},
+		{name: "one window spike", history: 20, windows: []int{20, 100, 20}, wantAlerts: []int64{100}},
+		{name: "sustained spike", history: 20, windows: []int{20, 100, 150, 120, 100, 20}, wantAlerts: []int64{100}},
+		{name: "two spikes", history: 20, windows: []int{100, 100, 20, 20, 90, 90}, wantAlerts: []int64{100, 90}},
+		{name: "spike at the multiplier", history: 20, windows: []int{60}},
+		{name: "few signups after none", history: 0, windows: []int{0, 5, 10, 0}},
+		{name: "spike after none", history: 0, windows: []int{0, 11}, wantAlerts: []int64{11}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAnomalyTest(t, tt.history)
+
+			a.window(tt.windows...)
+
+			var alerts []int64
+			for _, anomaly := range a.notifier.anomalies {
+				alerts = append(alerts, anomaly.Signups)
+			}
+			assert.Equal(t, tt.wantAlerts, alerts)
+		})
+	}
+}
+
+func TestSignupAnomalyDetector_Anomaly(t *testing.T) {
+	a := newAnomalyTest(t, 20)
+
+	a.window(100)
+
+	require.Len(t, a.notifier.anomalies, 1)
+	assert.Equal(t, SignupAnomaly{
+		Signups:    100,
+		From:       a.now.Add(-SignupWindow),
+		To:         a.now,
+		Baseline:   20,
+		Multiplier: 3,
+	}, a.notifier.anomalies[0])
+}
+
+func TestSignupAnomalyDetector_SeedsBaseline(t *testing.T) {
+	a := newAnomalyTest(t, 20)
+	a.window(20, 20)
+	require.Equal(t, 1+2, a.users.queries, "the baseline is seeded once")
+
+	// A restarted detector seeds its baseline again, so the traffic it
+	// already knew is not a spike, while a flood during the restart is.
+	a.restart()
+	a.window(20)
+	assert.Empty(t, a.notifier.anomalies)
+
+	a.restart()
+	a.window(100, 100)
+	assert.Len(t, a.notifier.anomalies, 1)
+}
+
+func TestSignupAnomalyDetector_BaselineIgnoresSpikes(t *testing.T) {
+	a := newAnomalyTest(t, 20)
+
+	a.window(100, 100, 100)
+
+	assert.InDelta(t, 20, a.detector.baseline, 0.001)
+}
+
+func TestSignupAnomalyDetector_NotifyFails(t *testing.T) {
+	a := newAnomalyTest(t, 20)
+	a.notifier.err = errors.New("webhook down")
+
+	a.now = a.now.Add(SignupWindow)
+	a.users.add(a.now, 100)
+	assert.ErrorIs(t, a.detector.Run(context.Background()), a.notifier.err)
+
+	a.notifier.err = nil
+	a.window(100)
+	assert.Len(t, a.notifier.anomalies, 1, "the spike is notified once the notifier recovers")
+}
+
+func TestWebhookAnomalyNotifier(t *testing.T) {
+	anomaly := SignupAnomaly{
+		Signups:    100,
+		From:       time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC),
+		To:         time.Date(2026, 10, 17, 12, 5, 0, 0, time.UTC),
+		Baseline:   20,
+		Multiplier: 3,
+	}
+
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{name: "accepted", status: http.StatusNoContent},
+		{name: "rejected", status: http.StatusInternalServerError, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got SignupAnomaly
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, http.MethodPost, r.Method)
+				assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			err := NewWebhookAnomalyNotifier(server.URL).NotifyAnomaly(context.Background(), anomaly)
+
+			if tt.wantErr {
+				assert.ErrorContains(t, err, "status 500")
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, anomaly, got)
+		})
+	}
+}
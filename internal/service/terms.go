@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrTOSNotAccepted        = errors.New("the terms of service must be accepted")
+	ErrTOSReacceptanceNeeded = errors.New("the terms of service have changed and must be accepted again")
+)
+
+// TOSAcceptance is a user's recorded acceptance of the terms of service.
+type TOSAcceptance struct {
+	Version    int
+	AcceptedAt time.Time
+}
+
+// CheckTOS returns ErrTOSReacceptanceNeeded if accepting the terms of service
+// is required and the user with userID has not accepted the current version,
+// typically because it was published after they last accepted.
+func (s *AuthService) CheckTOS(ctx context.Context, userID string) error {
+	if !s.tosRequired {
+		return nil
+	}
+
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return fmt.Errorf("%w: %w", ErrUserNotFound, err)
+	}
+	if err != nil {
+		return err
+	}
+	if user.TOSAcceptedVersion < s.tosVersion {
+		return ErrTOSReacceptanceNeeded
+	}
+
+	return nil
+}
+
+// AcceptTOS records that the user with userID accepted the current version of
+// the terms of service, and returns the recorded acceptance.
+func (s *AuthService) AcceptTOS(ctx context.Context, userID string) (*TOSAcceptance, error) {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, ErrInvalidUserID
+	}
+
+	acceptance := &TOSAcceptance{Version: s.tosVersion, AcceptedAt: s.clock.Now()}
+	if err := s.userRepo.AcceptTOS(ctx, id, acceptance.Version, acceptance.AcceptedAt); err != nil {
+		return nil, err
+	}
+
+	return acceptance, nil
+}
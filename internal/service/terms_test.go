@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// newTOSTestService creates an AuthService requiring version of the terms of
+// service, or not requiring them at all if required is false.
+func newTOSTestService(userRepo Repository, required bool, version int, opts ...AuthOption) *AuthService {
+	config := newTestConfig()
+	config.TOSRequired = required
+	config.TOSVersion = version
+	opts = append([]AuthOption{WithPasswordHasher(testutil.FastHasher{})}, opts...)
+	return NewAuthService(userRepo, new(MockTokenRepository), config, opts...)
+}
+
+func TestAuthService_RegisterTOS(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	clock := testutil.NewFakeClock(time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		name        string
+		required    bool
+		acceptedTOS bool
+		wantErr     error
+		wantVersion int
+	}{
+		{name: "required and accepted", required: true, acceptedTOS: true, wantVersion: 2},
+		{name: "required but not accepted", required: true, wantErr: ErrTOSNotAccepted},
+		{name: "not required and accepted", acceptedTOS: true, wantVersion: 2},
+		{name: "not required and not accepted"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			service := newTOSTestService(mockRepo, tt.required, 2, WithClock(clock))
+			if tt.wantErr == nil {
+				mockRepo.On("FindByEmail", mock.Anything, mockUser.Email).Return(nil, repository.ErrNotFound)
+				mockRepo.On("CreateWithOutbox", mock.Anything, mock.AnythingOfType("*model.User")).Return(nil)
+			}
+
+			user, err := service.Register(context.Background(), RegisterInput{
+				Email:       mockUser.Email,
+				Password:    "password",
+				FullName:    mockUser.FullName,
+				AcceptedTOS: tt.acceptedTOS,
+			})
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			if tt.wantErr == nil {
+				require.NotNil(t, user)
+				assert.Equal(t, tt.wantVersion, user.TOSAcceptedVersion)
+				assert.Equal(t, tt.acceptedTOS, user.TOSAcceptedAt != nil)
+				if user.TOSAcceptedAt != nil {
+					assert.Equal(t, clock.Now(), *user.TOSAcceptedAt, "acceptance is timed by the service clock")
+				}
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthService_CheckTOS(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+
+	tests := []struct {
+		name     string
+		required bool
+		version  int
+		accepted int
+		findErr  error
+		wantErr  error
+	}{
+		{name: "current version accepted", required: true, version: 2, accepted: 2},
+		{name: "version bumped since acceptance", required: true, version: 3, accepted: 2, wantErr: ErrTOSReacceptanceNeeded},
+		{name: "never accepted", required: true, version: 1, accepted: 0, wantErr: ErrTOSReacceptanceNeeded},
+		{name: "not required", version: 3, accepted: 0},
+		{name: "user not found", required: true, version: 1, findErr: repository.ErrNotFound, wantErr: ErrUserNotFound},
+		{name: "database timeout", required: true, version: 1, findErr: repository.ErrTimeout, wantErr: repository.ErrTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			service := newTOSTestService(mockRepo, tt.required, tt.version)
+			if tt.required {
+				user := mockUser
+				user.TOSAcceptedVersion = tt.accepted
+				if tt.findErr != nil {
+					mockRepo.On("FindByID", mock.Anything, mockUser.ID.String()).Return(nil, tt.findErr)
+				} else {
+					mockRepo.On("FindByID", mock.Anything, mockUser.ID.String()).Return(&user, nil)
+				}
+			}
+
+			err := service.CheckTOS(context.Background(), mockUser.ID.String())
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthService_AcceptTOS(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	mockRepo := new(MockRepository)
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	service := newTOSTestService(mockRepo, true, 3, WithClock(testutil.NewFakeClock(now)))
+	var recorded time.Time
+	mockRepo.On("AcceptTOS", mock.Anything, mockUser.ID, 3, mock.AnythingOfType("time.Time")).
+		Run(func(args mock.Arguments) { recorded = args.Get(3).(time.Time) }).
+		Return(nil)
+
+	acceptance, err := service.AcceptTOS(context.Background(), mockUser.ID.String())
+
+	require.NoError(t, err)
+	assert.Equal(t, 3, acceptance.Version)
+	assert.Equal(t, recorded, acceptance.AcceptedAt)
+	assert.Equal(t, now, acceptance.AcceptedAt)
+	mockRepo.AssertExpectations(t)
+
+	// After accepting, the gate lets the user through until the next version.
+	accepted := mockUser
+	accepted.TOSAcceptedVersion = acceptance.Version
+	mockRepo.On("FindByID", mock.Anything, mockUser.ID.String()).Return(&accepted, nil).Once()
+	assert.NoError(t, service.CheckTOS(context.Background(), mockUser.ID.String()))
+
+	bumped := newTOSTestService(mockRepo, true, 4)
+	mockRepo.On("FindByID", mock.Anything, mockUser.ID.String()).Return(&accepted, nil).Once()
+	assert.ErrorIs(t, bumped.CheckTOS(context.Background(), mockUser.ID.String()), ErrTOSReacceptanceNeeded)
+}
+
+func TestAuthService_AcceptTOSErrors(t *testing.T) {
+	t.Run("invalid user id", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+
+		_, err := newTOSTestService(mockRepo, true, 1).AcceptTOS(context.Background(), "not-a-uuid")
+
+		assert.ErrorIs(t, err, ErrInvalidUserID)
+		mockRepo.AssertNotCalled(t, "AcceptTOS", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("database timeout", func(t *testing.T) {
+		mockUser := testutil.NewMockUser()
+		mockRepo := new(MockRepository)
+		mockRepo.On("AcceptTOS", mock.Anything, mockUser.ID, 1, mock.Anything).Return(repository.ErrTimeout)
+
+		acceptance, err := newTOSTestService(mockRepo, true, 1).AcceptTOS(context.Background(), mockUser.ID.String())
+
+		assert.ErrorIs(t, err, repository.ErrTimeout)
+		assert.Nil(t, acceptance)
+	})
+}
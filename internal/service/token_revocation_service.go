@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/PakornBank/learn-go/internal/events"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/google/uuid"
+)
+
+// TokenRevocationRepository is the user persistence TokenRevocationService needs.
+type TokenRevocationRepository interface {
+	RevokeAllTokens(ctx context.Context, id uuid.UUID) (*repository.TokenRevocation, error)
+}
+
+// TokenRevocationService lets admins sign a user out everywhere at once, as
+// when the user reports their account compromised.
+type TokenRevocationService struct {
+	repo   TokenRevocationRepository
+	events events.Publisher
+}
+
+// NewTokenRevocationService creates a TokenRevocationService that revokes
+// tokens in repo and publishes the session.revoked events to publisher.
+func NewTokenRevocationService(repo TokenRevocationRepository, publisher events.Publisher) *TokenRevocationService {
+	return &TokenRevocationService{repo: repo, events: publisher}
+}
+
+// RevokeAll invalidates every access token of the user with the given ID,
+// revokes all their refresh tokens and clears their cache entry, whether or
+// not their deletion was requested, and returns what was revoked. It returns
+// ErrInvalidUserID if id is not a UUID and ErrUserNotFound if there is no such
+// user. If the tokens were revoked but the cache entry was not cleared, it
+// returns what was revoked along with repository.ErrCacheNotCleared.
+func (s *TokenRevocationService) RevokeAll(ctx context.Context, id string) (*repository.TokenRevocation, error) {
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrInvalidUserID
+	}
+
+	revocation, err := s.repo.RevokeAllTokens(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %w", ErrUserNotFound, err)
+	}
+	if revocation != nil {
+		s.events.Publish(userID.String(), events.Event{Type: events.TypeSessionRevoked})
+	}
+	return revocation, err
+}
@@ -0,0 +1,224 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PakornBank/learn-go/internal/cache"
+	"github.com/PakornBank/learn-go/internal/events"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockTokenRevocationRepository struct {
+	mock.Mock
+}
+
+func (m *MockTokenRevocationRepository) RevokeAllTokens(ctx context.Context, id uuid.UUID) (*repository.TokenRevocation, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.TokenRevocation), args.Error(1)
+}
+
+func TestTokenRevocationService_RevokeAll(t *testing.T) {
+	userID := uuid.New()
+	revocation := &repository.TokenRevocation{TokenVersion: 4, Sessions: 2, RefreshTokens: 3, CacheEntries: 1}
+
+	tests := []struct {
+		name        string
+		id          string
+		mockFn      func(*MockTokenRevocationRepository)
+		want        *repository.TokenRevocation
+		wantErr     error
+		wantPublish bool
+	}{
+		{
+			name: "revokes and signs the user out",
+			id:   userID.String(),
+			mockFn: func(m *MockTokenRevocationRepository) {
+				m.On("RevokeAllTokens", mock.Anything, userID).Return(revocation, nil)
+			},
+			want:        revocation,
+			wantPublish: true,
+		},
+		{
+			name: "cache entry not cleared",
+			id:   userID.String(),
+			mockFn: func(m *MockTokenRevocationRepository) {
+				m.On("RevokeAllTokens", mock.Anything, userID).Return(revocation, repository.ErrCacheNotCleared)
+			},
+			want:        revocation,
+			wantErr:     repository.ErrCacheNotCleared,
+			wantPublish: true,
+		},
+		{
+			name: "user not found",
+			id:   userID.String(),
+			mockFn: func(m *MockTokenRevocationRepository) {
+				m.On("RevokeAllTokens", mock.Anything, userID).Return(nil, repository.ErrNotFound)
+			},
+			wantErr: ErrUserNotFound,
+		},
+		{
+			name: "database error",
+			id:   userID.String(),
+			mockFn: func(m *MockTokenRevocationRepository) {
+				m.On("RevokeAllTokens", mock.Anything, userID).Return(nil, repository.ErrTimeout)
+			},
+			wantErr: repository.ErrTimeout,
+		},
+		{
+			name:    "invalid user ID",
+			id:      "not-a-uuid",
+			mockFn:  func(m *MockTokenRevocationRepository) {},
+			wantErr: ErrInvalidUserID,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(MockTokenRevocationRepository)
+			tt.mockFn(repo)
+			hub := events.NewHub(events.DefaultBufferSize, events.DefaultReplaySize)
+			sub := hub.Subscribe(userID.String())
+			defer sub.Close()
+			service := NewTokenRevocationService(repo, hub)
+
+			got, err := service.RevokeAll(context.Background(), tt.id)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, got)
+			if tt.wantPublish {
+				assert.Equal(t, events.TypeSessionRevoked, (<-sub.Events()).Type)
+			} else {
+				assert.Empty(t, sub.Events())
+			}
+			repo.AssertExpectations(t)
+		})
+	}
+}
+
+// TestTokenRevocationService_RevokeAllStores revokes through the real cached
+// user repository, checking that the users table, the refresh tokens table
+// and the user cache are all hit, and that a failure part way through rolls
+// the database back and is reported instead of leaving some tokens valid.
+func TestTokenRevocationService_RevokeAllStores(t *testing.T) {
+	user := testutil.NewMockUser(testutil.WithDeleted(time.Now()))
+
+	expectCachedUser := func(sqlMock sqlmock.Sqlmock) {
+		sqlMock.ExpectQuery(`SELECT \* FROM "users" WHERE id = \$1`).
+			WithArgs(user.ID, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "email", "token_version"}).AddRow(user.ID, user.Email, 3))
+	}
+	expectRevocation := func(sqlMock sqlmock.Sqlmock) {
+		sqlMock.ExpectBegin()
+		sqlMock.ExpectQuery(`SELECT "token_version" FROM "users" WHERE id = \$1 .*FOR UPDATE`).
+			WithArgs(user.ID, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"token_version"}).AddRow(3))
+		sqlMock.ExpectExec(`UPDATE "users" SET "token_version"=token_version \+ 1`).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		sqlMock.ExpectQuery(`SELECT COUNT\(DISTINCT\("family_id"\)\) FROM "refresh_tokens"`).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	}
+
+	t.Run("all stores", func(t *testing.T) {
+		sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+		defer sqlDB.Close()
+		store := cache.NewMemory()
+		users := repository.NewCachedUserRepository(repository.NewUserRepository(gormDB, time.Second), repository.NewEncodedUserCache(store, time.Minute))
+		service := NewTokenRevocationService(users, events.Discard)
+
+		expectCachedUser(sqlMock)
+		_, err := users.FindByID(context.Background(), user.ID.String())
+		require.NoError(t, err)
+
+		expectRevocation(sqlMock)
+		sqlMock.ExpectExec(`UPDATE "refresh_tokens" SET "revoked_at"=\$1 WHERE user_id = \$2 AND revoked_at IS NULL`).
+			WithArgs(sqlmock.AnyArg(), user.ID).
+			WillReturnResult(sqlmock.NewResult(0, 3))
+		sqlMock.ExpectCommit()
+
+		revocation, err := service.RevokeAll(context.Background(), user.ID.String())
+
+		require.NoError(t, err)
+		assert.Equal(t, &repository.TokenRevocation{TokenVersion: 4, Sessions: 2, RefreshTokens: 3, CacheEntries: 1}, revocation)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+
+		// The next lookup reads the new token version from the database.
+		sqlMock.ExpectQuery(`SELECT \* FROM "users" WHERE id = \$1`).
+			WithArgs(user.ID, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "email", "token_version"}).AddRow(user.ID, user.Email, 4))
+		found, err := users.FindByID(context.Background(), user.ID.String())
+		require.NoError(t, err)
+		assert.Equal(t, 4, found.TokenVersion)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("failure part way through", func(t *testing.T) {
+		sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+		defer sqlDB.Close()
+		store := cache.NewMemory()
+		users := repository.NewCachedUserRepository(repository.NewUserRepository(gormDB, time.Second), repository.NewEncodedUserCache(store, time.Minute))
+		service := NewTokenRevocationService(users, events.Discard)
+
+		expectCachedUser(sqlMock)
+		_, err := users.FindByID(context.Background(), user.ID.String())
+		require.NoError(t, err)
+
+		expectRevocation(sqlMock)
+		sqlMock.ExpectExec(`UPDATE "refresh_tokens" SET "revoked_at"`).WillReturnError(sql.ErrConnDone)
+		sqlMock.ExpectRollback()
+
+		revocation, err := service.RevokeAll(context.Background(), user.ID.String())
+
+		assert.ErrorIs(t, err, repository.ErrConn)
+		assert.Nil(t, revocation, "nothing is reported revoked after a rollback")
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("cache not cleared", func(t *testing.T) {
+		sqlDB, gormDB, sqlMock := testutil.DbMock(t)
+		defer sqlDB.Close()
+		users := repository.NewCachedUserRepository(repository.NewUserRepository(gormDB, time.Second), repository.NewEncodedUserCache(unavailableCache{}, time.Minute))
+		service := NewTokenRevocationService(users, events.Discard)
+
+		expectRevocation(sqlMock)
+		sqlMock.ExpectExec(`UPDATE "refresh_tokens" SET "revoked_at"`).WillReturnResult(sqlmock.NewResult(0, 3))
+		sqlMock.ExpectCommit()
+
+		revocation, err := service.RevokeAll(context.Background(), user.ID.String())
+
+		assert.ErrorIs(t, err, repository.ErrCacheNotCleared)
+		assert.Equal(t, &repository.TokenRevocation{TokenVersion: 4, Sessions: 2, RefreshTokens: 3}, revocation)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+}
+
+// unavailableCache is a cache.Cache whose every operation fails.
+type unavailableCache struct{}
+
+func (unavailableCache) Get(context.Context, string) ([]byte, bool, error) {
+	return nil, false, errors.New("cache unavailable")
+}
+
+func (unavailableCache) Set(context.Context, string, []byte, time.Duration) error {
+	return errors.New("cache unavailable")
+}
+
+func (unavailableCache) Delete(context.Context, ...string) error {
+	return errors.New("cache unavailable")
+}
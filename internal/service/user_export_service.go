@@ -0,0 +1,35 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+)
+
+// ExportBatchSize is the number of users read per query by an export.
+const ExportBatchSize = 500
+
+type UserExportRepository interface {
+	EachBatch(ctx context.Context, createdAfter time.Time, batchSize int, fn func([]model.User) error) error
+}
+
+// UserExportService reads users in bulk for admins to export, such as to
+// migrate them to another system.
+type UserExportService struct {
+	userRepo UserExportRepository
+}
+
+// NewUserExportService creates a UserExportService reading from userRepo.
+func NewUserExportService(userRepo UserExportRepository) *UserExportService {
+	return &UserExportService{userRepo: userRepo}
+}
+
+// Export calls fn with every user created after createdAfter, or every user
+// if it is zero, oldest first, in batches of up to ExportBatchSize. Only one
+// batch is held at a time, so exporting a large table uses as much memory as
+// a small one. It stops with the error of fn if fn fails, and with the error
+// of ctx, such as when the client disconnects, before reading the next batch.
+func (s *UserExportService) Export(ctx context.Context, createdAfter time.Time, fn func([]model.User) error) error {
+	return s.userRepo.EachBatch(ctx, createdAfter, ExportBatchSize, fn)
+}
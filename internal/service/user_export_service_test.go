@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockUserExportRepository struct {
+	mock.Mock
+}
+
+// EachBatch records the call and, unless an error is configured, passes the
+// configured batches to fn.
+func (r *MockUserExportRepository) EachBatch(ctx context.Context, createdAfter time.Time, batchSize int, fn func([]model.User) error) error {
+	args := r.Called(ctx, createdAfter, batchSize)
+	if err := args.Error(1); err != nil {
+		return err
+	}
+	for _, batch := range args.Get(0).([][]model.User) {
+		if err := fn(batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestNewUserExportService(t *testing.T) {
+	mockRepo := new(MockUserExportRepository)
+	exportService := NewUserExportService(mockRepo)
+
+	assert.NotNil(t, exportService)
+	assert.Equal(t, mockRepo, exportService.userRepo)
+}
+
+func TestUserExportService_Export(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	createdAfter := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	errWrite := errors.New("client went away")
+
+	tests := []struct {
+		name        string
+		mockFn      func(*MockUserExportRepository)
+		writeErr    error
+		wantBatches int
+		wantErr     error
+	}{
+		{
+			name: "batches passed on",
+			mockFn: func(repo *MockUserExportRepository) {
+				repo.On("EachBatch", mock.Anything, createdAfter, ExportBatchSize).
+					Return([][]model.User{{mockUser}, {mockUser}}, nil)
+			},
+			wantBatches: 2,
+		},
+		{
+			name: "write fails",
+			mockFn: func(repo *MockUserExportRepository) {
+				repo.On("EachBatch", mock.Anything, createdAfter, ExportBatchSize).
+					Return([][]model.User{{mockUser}, {mockUser}}, nil)
+			},
+			writeErr:    errWrite,
+			wantBatches: 1,
+			wantErr:     errWrite,
+		},
+		{
+			name: "client disconnected",
+			mockFn: func(repo *MockUserExportRepository) {
+				repo.On("EachBatch", mock.Anything, createdAfter, ExportBatchSize).Return(nil, context.Canceled)
+			},
+			wantErr: context.Canceled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockUserExportRepository)
+			tt.mockFn(mockRepo)
+			exportService := NewUserExportService(mockRepo)
+
+			batches := 0
+			err := exportService.Export(context.Background(), createdAfter, func([]model.User) error {
+				batches++
+				return tt.writeErr
+			})
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.wantBatches, batches)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,388 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/mailer"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ImportBatchSize is the number of users inserted per transaction by an import.
+const ImportBatchSize = 500
+
+// InviteExpiry is how long the link in an invite email can be used to choose
+// a password.
+const InviteExpiry = 7 * 24 * time.Hour
+
+// invitePurpose is signed along with the user ID and expiry, so that an invite
+// token cannot be mistaken for anything else signed with the same secret.
+const invitePurpose = "invite:"
+
+// The statuses of a row in an ImportReport.
+const (
+	ImportCreated          = "created"
+	ImportSkippedDuplicate = "skipped_duplicate"
+	ImportInvalid          = "invalid"
+)
+
+var (
+	ErrInvalidImportHeader = errors.New("CSV header must include the email and full_name columns")
+	ErrInvalidInviteToken  = errors.New("invalid or expired invite token")
+)
+
+type UserImportRepository interface {
+	FindExistingEmails(ctx context.Context, emails []string) (map[string]bool, error)
+	CreateBatch(ctx context.Context, users []*model.User) error
+	SetInitialPassword(ctx context.Context, id uuid.UUID, passwordHash string, changedAt time.Time) (bool, error)
+}
+
+// ImportOptions control how UserImportService.Import treats a file. With
+// DryRun, rows are validated and checked for duplicates but nothing is stored
+// and nobody is emailed. With Invite, rows without a password hash are
+// accepted and their users are emailed a link to choose a password.
+type ImportOptions struct {
+	DryRun bool
+	Invite bool
+}
+
+// ImportRowResult is the outcome of one data row of an import. Row is the
+// line of the file the row starts on, counting the header as line 1.
+type ImportRowResult struct {
+	Row     int
+	Email   string
+	Status  string
+	Reason  string
+	Invited bool
+}
+
+// ImportReport lists the outcome of every data row of an import, in file
+// order, along with how many rows ended up in each status.
+type ImportReport struct {
+	DryRun           bool
+	Created          int
+	SkippedDuplicate int
+	Invalid          int
+	Rows             []ImportRowResult
+}
+
+type AcceptInviteInput struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8"`
+}
+
+// UserImportService creates users in bulk from CSV files, such as exports of
+// a legacy system, and lets users created without a password choose one
+// through the link in their invite email.
+type UserImportService struct {
+	userRepo  UserImportRepository
+	mailer    mailer.Mailer
+	templates *mailer.Templates
+	passwords NewPasswordHasher
+	secret    []byte
+	now       func() time.Time
+}
+
+// NewUserImportService creates a UserImportService that mails invites with m,
+// signs invite tokens with secret and checks and hashes chosen passwords with
+// passwords.
+func NewUserImportService(userRepo UserImportRepository, m mailer.Mailer, templates *mailer.Templates, passwords NewPasswordHasher, secret string) *UserImportService {
+	return &UserImportService{
+		userRepo:  userRepo,
+		mailer:    m,
+		templates: templates,
+		passwords: passwords,
+		secret:    []byte(secret),
+		now:       time.Now,
+	}
+}
+
+// pendingUser is a valid row waiting to be inserted with the rest of its batch.
+type pendingUser struct {
+	result *ImportRowResult
+	user   *model.User
+}
+
+// Import reads users from the CSV in r, one row at a time, and inserts the
+// valid ones in transactions of ImportBatchSize. The header row names the
+// columns: email and full_name are required, and password_hash, a bcrypt
+// hash the user logs in with, is optional. Other columns are ignored.
+//
+// A row that cannot be parsed or fails validation is reported as invalid
+// with the reason, and a row whose email is already registered, or appears
+// earlier in the file, is reported as a skipped duplicate; either way the
+// import carries on with the next row. Only a missing header, a file that
+// cannot be read, or a failing repository stop the import, in which case
+// the batches already inserted are kept.
+func (s *UserImportService) Import(ctx context.Context, r io.Reader, opts ImportOptions) (*ImportReport, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, ErrInvalidImportHeader
+		}
+		return nil, fmt.Errorf("%w: %w", ErrInvalidImportHeader, err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["email"]; !ok {
+		return nil, ErrInvalidImportHeader
+	}
+	if _, ok := columns["full_name"]; !ok {
+		return nil, ErrInvalidImportHeader
+	}
+	reader.FieldsPerRecord = len(header)
+
+	var results []*ImportRowResult
+	var batch []pendingUser
+	seen := make(map[string]int)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		result := &ImportRowResult{}
+		results = append(results, result)
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			result.Row = parseErr.StartLine
+			result.Status = ImportInvalid
+			result.Reason = parseErr.Err.Error()
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		result.Row, _ = reader.FieldPos(0)
+
+		user, reason := importedUser(record, columns, opts)
+		result.Email = user.Email
+		if reason != "" {
+			result.Status = ImportInvalid
+			result.Reason = reason
+			continue
+		}
+		if row, ok := seen[user.Email]; ok {
+			result.Status = ImportSkippedDuplicate
+			result.Reason = fmt.Sprintf("email appears earlier in the file, on line %d", row)
+			continue
+		}
+		seen[user.Email] = result.Row
+
+		batch = append(batch, pendingUser{result: result, user: user})
+		if len(batch) == ImportBatchSize {
+			if err := s.importBatch(ctx, batch, opts); err != nil {
+				return nil, err
+			}
+			batch = batch[:0]
+		}
+	}
+	if err := s.importBatch(ctx, batch, opts); err != nil {
+		return nil, err
+	}
+
+	report := &ImportReport{DryRun: opts.DryRun, Rows: make([]ImportRowResult, 0, len(results))}
+	for _, result := range results {
+		switch result.Status {
+		case ImportCreated:
+			report.Created++
+		case ImportSkippedDuplicate:
+			report.SkippedDuplicate++
+		case ImportInvalid:
+			report.Invalid++
+		}
+		report.Rows = append(report.Rows, *result)
+	}
+	return report, nil
+}
+
+// importedUser builds the user described by record, or returns why the row
+// is invalid. The email of the returned user is set either way.
+func importedUser(record []string, columns map[string]int, opts ImportOptions) (*model.User, string) {
+	field := func(name string) string {
+		i, ok := columns[name]
+		if !ok {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	user := &model.User{
+		Email:        field("email"),
+		FullName:     field("full_name"),
+		PasswordHash: field("password_hash"),
+		Role:         model.RoleUser,
+		Locale:       DefaultLocale,
+		Timezone:     DefaultTimezone,
+	}
+
+	address, err := mail.ParseAddress(user.Email)
+	if err != nil || address.Address != user.Email {
+		return user, "invalid email address"
+	}
+	if user.FullName == "" {
+		return user, "full_name is required"
+	}
+	if user.PasswordHash == "" && !opts.Invite {
+		return user, "password_hash is required unless invites are sent"
+	}
+	if user.PasswordHash != "" {
+		if _, err := bcrypt.Cost([]byte(user.PasswordHash)); err != nil {
+			return user, "password_hash is not a bcrypt hash"
+		}
+	}
+	return user, ""
+}
+
+// importBatch inserts the users of batch whose email is not registered yet,
+// and sets the status of every row accordingly. If an email is registered
+// between the check and the insert, the batch is checked and inserted again.
+func (s *UserImportService) importBatch(ctx context.Context, batch []pendingUser, opts ImportOptions) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	var users []pendingUser
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		users, err = s.skipRegistered(ctx, batch)
+		if err != nil {
+			return err
+		}
+		if opts.DryRun {
+			break
+		}
+
+		created := make([]*model.User, len(users))
+		for i, pending := range users {
+			created[i] = pending.user
+		}
+		err = s.userRepo.CreateBatch(ctx, created)
+		if !errors.Is(err, repository.ErrDuplicate) {
+			break
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, pending := range users {
+		pending.result.Status = ImportCreated
+		if opts.DryRun || pending.user.PasswordHash != "" {
+			continue
+		}
+		if err := s.invite(ctx, pending.user); err != nil {
+			slog.WarnContext(ctx, "failed to send invite", "user_id", pending.user.ID, "error", err)
+			continue
+		}
+		pending.result.Invited = true
+	}
+	return nil
+}
+
+// skipRegistered marks the rows of batch whose email is already registered as
+// skipped duplicates, and returns the others.
+func (s *UserImportService) skipRegistered(ctx context.Context, batch []pendingUser) ([]pendingUser, error) {
+	emails := make([]string, len(batch))
+	for i, pending := range batch {
+		emails[i] = pending.user.Email
+	}
+	existing, err := s.userRepo.FindExistingEmails(ctx, emails)
+	if err != nil {
+		return nil, err
+	}
+
+	remaining := make([]pendingUser, 0, len(batch))
+	for _, pending := range batch {
+		if existing[pending.user.Email] {
+			pending.result.Status = ImportSkippedDuplicate
+			pending.result.Reason = "email already registered"
+			continue
+		}
+		remaining = append(remaining, pending)
+	}
+	return remaining, nil
+}
+
+// invite mails user a link to choose their password.
+func (s *UserImportService) invite(ctx context.Context, user *model.User) error {
+	msg, err := s.templates.Invite(user.FullName, s.InviteToken(user.ID))
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, user.Email, msg.Subject, msg.HTML, msg.Text)
+}
+
+// InviteToken returns the token that lets the user with userID choose their
+// first password, valid for InviteExpiry. It is not stored: it carries the
+// user ID and expiry, signed with the service's secret.
+func (s *UserImportService) InviteToken(userID uuid.UUID) string {
+	expiresAt := binary.BigEndian.AppendUint64(nil, uint64(s.now().Add(InviteExpiry).Unix()))
+	token := append(userID[:], expiresAt...)
+	token = append(token, s.sign(token)...)
+	return base64.RawURLEncoding.EncodeToString(token)
+}
+
+// AcceptInvite sets the password of the user the invite token was issued to,
+// once it passed the same policy and breach checks as at registration.
+// It returns ErrInvalidInviteToken if the token was not issued by InviteToken,
+// has expired, or was already used, since a user can only be given their
+// first password this way, and ErrPasswordTooShort or ErrPasswordBreached if
+// the password is rejected.
+func (s *UserImportService) AcceptInvite(ctx context.Context, input AcceptInviteInput) error {
+	const size = len(uuid.UUID{}) + 8
+	decoded, err := base64.RawURLEncoding.DecodeString(input.Token)
+	if err != nil || len(decoded) != size+sha256.Size || !hmac.Equal(decoded[size:], s.sign(decoded[:size])) {
+		return ErrInvalidInviteToken
+	}
+	id, err := uuid.FromBytes(decoded[:len(uuid.UUID{})])
+	if err != nil {
+		return ErrInvalidInviteToken
+	}
+	expiresAt := time.Unix(int64(binary.BigEndian.Uint64(decoded[len(uuid.UUID{}):size])), 0)
+	if !s.now().Before(expiresAt) {
+		return ErrInvalidInviteToken
+	}
+
+	hashedPassword, err := s.passwords.HashNewPassword(ctx, input.Password)
+	if err != nil {
+		return err
+	}
+
+	set, err := s.userRepo.SetInitialPassword(ctx, id, hashedPassword, s.now())
+	if err != nil {
+		return err
+	}
+	if !set {
+		return ErrInvalidInviteToken
+	}
+	return nil
+}
+
+// sign returns the signature of the user ID and expiry of an invite token.
+func (s *UserImportService) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(invitePurpose))
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,443 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/mailer"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// fakeImportRepository keeps the users an import creates in memory. Emails in
+// registered count as taken, and createErrs are returned by the first calls to
+// CreateBatch, in order.
+type fakeImportRepository struct {
+	registered   map[string]bool
+	batches      [][]*model.User
+	createErrs   []error
+	findErr      error
+	passwordSets map[uuid.UUID]string
+}
+
+func newFakeImportRepository(registered ...string) *fakeImportRepository {
+	repo := &fakeImportRepository{registered: make(map[string]bool), passwordSets: make(map[uuid.UUID]string)}
+	for _, email := range registered {
+		repo.registered[email] = true
+	}
+	return repo
+}
+
+func (r *fakeImportRepository) FindExistingEmails(_ context.Context, emails []string) (map[string]bool, error) {
+	if r.findErr != nil {
+		return nil, r.findErr
+	}
+	existing := make(map[string]bool)
+	for _, email := range emails {
+		if r.registered[email] {
+			existing[email] = true
+		}
+	}
+	return existing, nil
+}
+
+func (r *fakeImportRepository) CreateBatch(_ context.Context, users []*model.User) error {
+	if len(r.createErrs) > 0 {
+		err := r.createErrs[0]
+		r.createErrs = r.createErrs[1:]
+		return err
+	}
+	for _, user := range users {
+		user.ID = uuid.New()
+		r.registered[user.Email] = true
+	}
+	r.batches = append(r.batches, users)
+	return nil
+}
+
+func (r *fakeImportRepository) SetInitialPassword(_ context.Context, id uuid.UUID, passwordHash string, _ time.Time) (bool, error) {
+	if _, ok := r.passwordSets[id]; ok {
+		return false, nil
+	}
+	r.passwordSets[id] = passwordHash
+	return true, nil
+}
+
+func (r *fakeImportRepository) created() []*model.User {
+	var users []*model.User
+	for _, batch := range r.batches {
+		users = append(users, batch...)
+	}
+	return users
+}
+
+func setupUserImportTest(repo *fakeImportRepository, opts ...AuthOption) (*UserImportService, *fakeMailer) {
+	m := &fakeMailer{}
+	auth := newTestAuthService(new(MockRepository), new(MockTokenRepository), opts...)
+	s := NewUserImportService(repo, m, mailer.NewTemplates("https://app.example.com"), auth, "secret")
+	return s, m
+}
+
+func bcryptHash(t *testing.T) string {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	require.NoError(t, err)
+	return string(hash)
+}
+
+func TestNewUserImportService(t *testing.T) {
+	repo := newFakeImportRepository()
+	s, m := setupUserImportTest(repo)
+
+	assert.Equal(t, repo, s.userRepo)
+	assert.Equal(t, m, s.mailer)
+	assert.Equal(t, []byte("secret"), s.secret)
+}
+
+func TestUserImportService_Import(t *testing.T) {
+	hash := bcryptHash(t)
+
+	tests := []struct {
+		name        string
+		csv         string
+		registered  []string
+		opts        ImportOptions
+		wantRows    []ImportRowResult
+		wantCreated []string
+		wantMailed  []string
+	}{
+		{
+			name: "valid rows",
+			csv: "email,full_name,password_hash\n" +
+				"ada@example.com,Ada Lovelace," + hash + "\n" +
+				"alan@example.com,Alan Turing," + hash + "\n",
+			wantRows: []ImportRowResult{
+				{Row: 2, Email: "ada@example.com", Status: ImportCreated},
+				{Row: 3, Email: "alan@example.com", Status: ImportCreated},
+			},
+			wantCreated: []string{"ada@example.com", "alan@example.com"},
+		},
+		{
+			name: "malformed rows mid-file",
+			csv: "email,full_name,password_hash\n" +
+				"ada@example.com,Ada Lovelace," + hash + "\n" +
+				"too,many,fields,here\n" +
+				"bad\"quote@example.com,Grace Hopper," + hash + "\n" +
+				"alan@example.com,Alan Turing," + hash + "\n",
+			wantRows: []ImportRowResult{
+				{Row: 2, Email: "ada@example.com", Status: ImportCreated},
+				{Row: 3, Status: ImportInvalid, Reason: "wrong number of fields"},
+				{Row: 4, Status: ImportInvalid, Reason: `bare " in non-quoted-field`},
+				{Row: 5, Email: "alan@example.com", Status: ImportCreated},
+			},
+			wantCreated: []string{"ada@example.com", "alan@example.com"},
+		},
+		{
+			name: "invalid rows",
+			csv: "email,full_name,password_hash\n" +
+				"not-an-email,Ada Lovelace," + hash + "\n" +
+				"Alan <alan@example.com>,Alan Turing," + hash + "\n" +
+				"grace@example.com,," + hash + "\n" +
+				"linus@example.com,Linus Torvalds,plaintext\n" +
+				"ken@example.com,Ken Thompson,\n",
+			wantRows: []ImportRowResult{
+				{Row: 2, Email: "not-an-email", Status: ImportInvalid, Reason: "invalid email address"},
+				{Row: 3, Email: "Alan <alan@example.com>", Status: ImportInvalid, Reason: "invalid email address"},
+				{Row: 4, Email: "grace@example.com", Status: ImportInvalid, Reason: "full_name is required"},
+				{Row: 5, Email: "linus@example.com", Status: ImportInvalid, Reason: "password_hash is not a bcrypt hash"},
+				{Row: 6, Email: "ken@example.com", Status: ImportInvalid, Reason: "password_hash is required unless invites are sent"},
+			},
+		},
+		{
+			name: "duplicates",
+			csv: "email,full_name,password_hash\n" +
+				"ada@example.com,Ada Lovelace," + hash + "\n" +
+				"taken@example.com,Someone Else," + hash + "\n" +
+				"ada@example.com,Ada Again," + hash + "\n",
+			registered: []string{"taken@example.com"},
+			wantRows: []ImportRowResult{
+				{Row: 2, Email: "ada@example.com", Status: ImportCreated},
+				{Row: 3, Email: "taken@example.com", Status: ImportSkippedDuplicate, Reason: "email already registered"},
+				{Row: 4, Email: "ada@example.com", Status: ImportSkippedDuplicate, Reason: "email appears earlier in the file, on line 2"},
+			},
+			wantCreated: []string{"ada@example.com"},
+		},
+		{
+			name: "password column is optional with invites",
+			csv: "full_name,email\n" +
+				"Ada Lovelace,ada@example.com\n",
+			opts: ImportOptions{Invite: true},
+			wantRows: []ImportRowResult{
+				{Row: 2, Email: "ada@example.com", Status: ImportCreated, Invited: true},
+			},
+			wantCreated: []string{"ada@example.com"},
+			wantMailed:  []string{"ada@example.com"},
+		},
+		{
+			name: "only users without a password are invited",
+			csv: "email,full_name,password_hash\n" +
+				"ada@example.com,Ada Lovelace," + hash + "\n" +
+				"alan@example.com,Alan Turing,\n",
+			opts: ImportOptions{Invite: true},
+			wantRows: []ImportRowResult{
+				{Row: 2, Email: "ada@example.com", Status: ImportCreated},
+				{Row: 3, Email: "alan@example.com", Status: ImportCreated, Invited: true},
+			},
+			wantCreated: []string{"ada@example.com", "alan@example.com"},
+			wantMailed:  []string{"alan@example.com"},
+		},
+		{
+			name: "dry run",
+			csv: "email,full_name,password_hash\n" +
+				"ada@example.com,Ada Lovelace,\n" +
+				"taken@example.com,Someone Else,\n" +
+				"bad,Alan Turing,\n",
+			registered: []string{"taken@example.com"},
+			opts:       ImportOptions{DryRun: true, Invite: true},
+			wantRows: []ImportRowResult{
+				{Row: 2, Email: "ada@example.com", Status: ImportCreated},
+				{Row: 3, Email: "taken@example.com", Status: ImportSkippedDuplicate, Reason: "email already registered"},
+				{Row: 4, Email: "bad", Status: ImportInvalid, Reason: "invalid email address"},
+			},
+		},
+		{
+			name: "header only",
+			csv:  "email,full_name\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeImportRepository(tt.registered...)
+			s, m := setupUserImportTest(repo)
+
+			report, err := s.Import(context.Background(), strings.NewReader(tt.csv), tt.opts)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.opts.DryRun, report.DryRun)
+			if tt.wantRows == nil {
+				assert.Empty(t, report.Rows)
+			} else {
+				assert.Equal(t, tt.wantRows, report.Rows)
+			}
+
+			var created []string
+			for _, user := range repo.created() {
+				created = append(created, user.Email)
+				assert.Equal(t, model.RoleUser, user.Role)
+				assert.Equal(t, DefaultLocale, user.Locale)
+				assert.Equal(t, DefaultTimezone, user.Timezone)
+			}
+			assert.Equal(t, tt.wantCreated, created)
+
+			var mailed []string
+			for _, email := range m.sent {
+				mailed = append(mailed, email.to)
+			}
+			assert.Equal(t, tt.wantMailed, mailed)
+		})
+	}
+}
+
+func TestUserImportService_ImportCounts(t *testing.T) {
+	hash := bcryptHash(t)
+	s, _ := setupUserImportTest(newFakeImportRepository("taken@example.com"))
+	csv := "email,full_name,password_hash\n" +
+		"ada@example.com,Ada Lovelace," + hash + "\n" +
+		"taken@example.com,Someone Else," + hash + "\n" +
+		"bad,Alan Turing," + hash + "\n"
+
+	report, err := s.Import(context.Background(), strings.NewReader(csv), ImportOptions{})
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Created)
+	assert.Equal(t, 1, report.SkippedDuplicate)
+	assert.Equal(t, 1, report.Invalid)
+}
+
+func TestUserImportService_ImportBatches(t *testing.T) {
+	hash := bcryptHash(t)
+	var csv strings.Builder
+	csv.WriteString("email,full_name,password_hash\n")
+	for i := 0; i < 2*ImportBatchSize+1; i++ {
+		fmt.Fprintf(&csv, "user%d@example.com,User %d,%s\n", i, i, hash)
+	}
+	repo := newFakeImportRepository()
+	s, _ := setupUserImportTest(repo)
+
+	report, err := s.Import(context.Background(), strings.NewReader(csv.String()), ImportOptions{})
+
+	require.NoError(t, err)
+	assert.Equal(t, 2*ImportBatchSize+1, report.Created)
+	require.Len(t, repo.batches, 3)
+	assert.Len(t, repo.batches[0], ImportBatchSize)
+	assert.Len(t, repo.batches[1], ImportBatchSize)
+	assert.Len(t, repo.batches[2], 1)
+}
+
+func TestUserImportService_ImportRetriesRegisteredMeanwhile(t *testing.T) {
+	hash := bcryptHash(t)
+	repo := newFakeImportRepository()
+	// Another request registers alan@example.com between the check and the insert.
+	repo.createErrs = []error{repository.ErrDuplicate}
+	s, _ := setupUserImportTest(repo)
+	s.userRepo = &registeringRepository{fakeImportRepository: repo, email: "alan@example.com"}
+	csv := "email,full_name,password_hash\n" +
+		"ada@example.com,Ada Lovelace," + hash + "\n" +
+		"alan@example.com,Alan Turing," + hash + "\n"
+
+	report, err := s.Import(context.Background(), strings.NewReader(csv), ImportOptions{})
+
+	require.NoError(t, err)
+	assert.Equal(t, []ImportRowResult{
+		{Row: 2, Email: "ada@example.com", Status: ImportCreated},
+		{Row: 3, Email: "alan@example.com", Status: ImportSkippedDuplicate, Reason: "email already registered"},
+	}, report.Rows)
+	require.Len(t, repo.batches, 1)
+	assert.Len(t, repo.batches[0], 1)
+}
+
+// registeringRepository registers email the first time CreateBatch is called.
+type registeringRepository struct {
+	*fakeImportRepository
+	email string
+}
+
+func (r *registeringRepository) CreateBatch(ctx context.Context, users []*model.User) error {
+	r.registered[r.email] = true
+	return r.fakeImportRepository.CreateBatch(ctx, users)
+}
+
+func TestUserImportService_ImportErrors(t *testing.T) {
+	hash := bcryptHash(t)
+	rows := "email,full_name,password_hash\nada@example.com,Ada Lovelace," + hash + "\n"
+
+	tests := []struct {
+		name    string
+		csv     string
+		setupFn func(*fakeImportRepository)
+		wantErr error
+	}{
+		{name: "empty file", csv: "", wantErr: ErrInvalidImportHeader},
+		{name: "missing email column", csv: "full_name,password_hash\nAda,x\n", wantErr: ErrInvalidImportHeader},
+		{name: "missing full_name column", csv: "email\nada@example.com\n", wantErr: ErrInvalidImportHeader},
+		{
+			name:    "lookup fails",
+			csv:     rows,
+			setupFn: func(repo *fakeImportRepository) { repo.findErr = repository.ErrTimeout },
+			wantErr: repository.ErrTimeout,
+		},
+		{
+			name:    "insert fails",
+			csv:     rows,
+			setupFn: func(repo *fakeImportRepository) { repo.createErrs = []error{repository.ErrConn} },
+			wantErr: repository.ErrConn,
+		},
+		{
+			name: "still duplicate after retry",
+			csv:  rows,
+			setupFn: func(repo *fakeImportRepository) {
+				repo.createErrs = []error{repository.ErrDuplicate, repository.ErrDuplicate}
+			},
+			wantErr: repository.ErrDuplicate,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeImportRepository()
+			if tt.setupFn != nil {
+				tt.setupFn(repo)
+			}
+			s, _ := setupUserImportTest(repo)
+
+			report, err := s.Import(context.Background(), strings.NewReader(tt.csv), ImportOptions{})
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Nil(t, report)
+		})
+	}
+}
+
+func TestUserImportService_ImportInviteMailFailure(t *testing.T) {
+	repo := newFakeImportRepository()
+	s, m := setupUserImportTest(repo)
+	m.err = errors.New("smtp unavailable")
+
+	report, err := s.Import(context.Background(), strings.NewReader("email,full_name\nada@example.com,Ada\n"), ImportOptions{Invite: true})
+
+	require.NoError(t, err)
+	assert.Equal(t, []ImportRowResult{{Row: 2, Email: "ada@example.com", Status: ImportCreated}}, report.Rows)
+	assert.Len(t, repo.created(), 1)
+}
+
+func TestUserImportService_AcceptInvite(t *testing.T) {
+	repo := newFakeImportRepository()
+	s, m := setupUserImportTest(repo)
+	_, err := s.Import(context.Background(), strings.NewReader("email,full_name\nada@example.com,Ada\n"), ImportOptions{Invite: true})
+	require.NoError(t, err)
+	require.Len(t, m.sent, 1)
+	token := tokenFromEmail(t, m.sent[0])
+	userID := repo.created()[0].ID
+
+	require.NoError(t, s.AcceptInvite(context.Background(), AcceptInviteInput{Token: token, Password: "new-password"}))
+	assert.Equal(t, testutil.FastHash("new-password"), repo.passwordSets[userID])
+
+	err = s.AcceptInvite(context.Background(), AcceptInviteInput{Token: token, Password: "other-password"})
+	assert.ErrorIs(t, err, ErrInvalidInviteToken)
+	assert.Equal(t, testutil.FastHash("new-password"), repo.passwordSets[userID])
+}
+
+func TestUserImportService_AcceptInviteBreachedPassword(t *testing.T) {
+	repo := newFakeImportRepository()
+	s, m := setupUserImportTest(repo, WithBreachChecker(&fakeBreachChecker{count: 100}, 10))
+	_, err := s.Import(context.Background(), strings.NewReader("email,full_name\nada@example.com,Ada\n"), ImportOptions{Invite: true})
+	require.NoError(t, err)
+	token := tokenFromEmail(t, m.sent[0])
+
+	err = s.AcceptInvite(context.Background(), AcceptInviteInput{Token: token, Password: "password"})
+
+	assert.ErrorIs(t, err, ErrPasswordBreached)
+	assert.Empty(t, repo.passwordSets, "the invite can still be used")
+}
+
+func TestUserImportService_AcceptInviteInvalidToken(t *testing.T) {
+	userID := uuid.New()
+	s, _ := setupUserImportTest(newFakeImportRepository())
+	valid := s.InviteToken(userID)
+	tampered := []byte(valid)
+	tampered[0] ^= 1
+	expired := s.InviteToken(userID)
+	s.now = func() time.Time { return time.Now().Add(InviteExpiry + time.Minute) }
+	otherSecret, _ := setupUserImportTest(newFakeImportRepository())
+	otherSecret.secret = []byte("other")
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "not base64", token: "!!!"},
+		{name: "truncated", token: valid[:len(valid)-2]},
+		{name: "tampered", token: string(tampered)},
+		{name: "signed with another secret", token: otherSecret.InviteToken(userID)},
+		{name: "unsubscribe token", token: NewNotificationPreferencesService(nil, "secret").UnsubscribeToken(userID)},
+		{name: "expired", token: expired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.AcceptInvite(context.Background(), AcceptInviteInput{Token: tt.token, Password: "new-password"})
+
+			assert.ErrorIs(t, err, ErrInvalidInviteToken)
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingRepository is a Repository whose FindByID counts its calls and
+// simulates a slow query, either by sleeping for delay or by holding each
+// call open until release is closed.
+type countingRepository struct {
+	MockRepository
+	user    model.User
+	calls   atomic.Int32
+	delay   time.Duration
+	release chan struct{}
+}
+
+func (r *countingRepository) FindByID(_ context.Context, _ string) (*model.User, error) {
+	r.calls.Add(1)
+	time.Sleep(r.delay)
+	if r.release != nil {
+		<-r.release
+	}
+	user := r.user
+	return &user, nil
+}
+
+func TestAuthService_GetUserByIDSharesConcurrentLookups(t *testing.T) {
+	const callers = 100
+
+	repo := &countingRepository{user: testutil.NewMockUser(), release: make(chan struct{})}
+	service := newTestAuthService(repo, new(MockTokenRepository))
+
+	var ready, done sync.WaitGroup
+	ready.Add(callers)
+	done.Add(callers)
+	results := make([]*model.User, callers)
+
+	for i := 0; i < callers; i++ {
+		go func(i int) {
+			defer done.Done()
+			ready.Done()
+			user, err := service.GetUserByID(context.Background(), repo.user.ID.String())
+			assert.NoError(t, err)
+			results[i] = user
+		}(i)
+	}
+
+	ready.Wait()
+	// Give every caller time to join the in-flight lookup before it completes.
+	time.Sleep(50 * time.Millisecond)
+	close(repo.release)
+	done.Wait()
+
+	assert.Equal(t, int32(1), repo.calls.Load())
+
+	for _, user := range results {
+		require.NotNil(t, user)
+		assert.Equal(t, repo.user.ID, user.ID)
+	}
+	results[0].Email = "mutated@example.com"
+	assert.Equal(t, repo.user.Email, results[1].Email)
+}
+
+func TestAuthService_GetUserByIDCallerCancellation(t *testing.T) {
+	repo := &countingRepository{user: testutil.NewMockUser(), release: make(chan struct{})}
+	service := newTestAuthService(repo, new(MockTokenRepository))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	impatient := make(chan error)
+	go func() {
+		_, err := service.GetUserByID(ctx, repo.user.ID.String())
+		impatient <- err
+	}()
+
+	patient := make(chan *model.User)
+	go func() {
+		user, _ := service.GetUserByID(context.Background(), repo.user.ID.String())
+		patient <- user
+	}()
+
+	assert.Eventually(t, func() bool { return repo.calls.Load() == 1 }, time.Second, time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-impatient, context.Canceled)
+
+	close(repo.release)
+	user := <-patient
+	require.NotNil(t, user)
+	assert.Equal(t, repo.user.ID, user.ID)
+}
+
+func BenchmarkAuthService_GetUserByID(b *testing.B) {
+	repo := &countingRepository{user: testutil.NewMockUser(), delay: time.Millisecond}
+	service := newTestAuthService(repo, new(MockTokenRepository))
+	id := repo.user.ID.String()
+
+	b.SetParallelism(100)
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := service.GetUserByID(context.Background(), id); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.ReportMetric(float64(repo.calls.Load())/float64(b.N), "repo-calls/op")
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/repository"
+)
+
+// SignupSeriesDays is the number of days, today included, that UserStats
+// counts signups for.
+const SignupSeriesDays = 30
+
+type UserStatsRepository interface {
+	Count(ctx context.Context) (int64, error)
+	CountCreatedBetween(ctx context.Context, from, to time.Time) (int64, error)
+	CountCreatedByDay(ctx context.Context, from, to time.Time) ([]repository.DailyCount, error)
+}
+
+// UserStats summarizes the users for dashboards. Signups are counted from
+// From, midnight UTC SignupSeriesDays-1 days ago, until To, midnight UTC
+// tomorrow. DailySignups leaves out the days without signups.
+type UserStats struct {
+	TotalUsers   int64
+	Signups      int64
+	DailySignups []repository.DailyCount
+	From         time.Time
+	To           time.Time
+}
+
+// UserStatsService counts users for dashboards and metrics.
+type UserStatsService struct {
+	userRepo UserStatsRepository
+	now      func() time.Time
+}
+
+// NewUserStatsService creates a UserStatsService counting the users in userRepo.
+func NewUserStatsService(userRepo UserStatsRepository) *UserStatsService {
+	return &UserStatsService{userRepo: userRepo, now: time.Now}
+}
+
+// Stats returns the total number of users and the signups of the last
+// SignupSeriesDays days, in total and per day.
+func (s *UserStatsService) Stats(ctx context.Context) (*UserStats, error) {
+	now := s.now().UTC()
+	to := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	from := to.AddDate(0, 0, -SignupSeriesDays)
+
+	total, err := s.userRepo.Count(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	signups, err := s.userRepo.CountCreatedBetween(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	daily, err := s.userRepo.CountCreatedByDay(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserStats{TotalUsers: total, Signups: signups, DailySignups: daily, From: from, To: to}, nil
+}
+
+// TotalUsers returns the number of users.
+func (s *UserStatsService) TotalUsers(ctx context.Context) (int64, error) {
+	return s.userRepo.Count(ctx)
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockUserStatsRepository struct {
+	mock.Mock
+}
+
+func (r *MockUserStatsRepository) Count(ctx context.Context) (int64, error) {
+	args := r.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (r *MockUserStatsRepository) CountCreatedBetween(ctx context.Context, from, to time.Time) (int64, error) {
+	args := r.Called(ctx, from, to)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (r *MockUserStatsRepository) CountCreatedByDay(ctx context.Context, from, to time.Time) ([]repository.DailyCount, error) {
+	args := r.Called(ctx, from, to)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]repository.DailyCount), args.Error(1)
+}
+
+func TestUserStatsService_Stats(t *testing.T) {
+	// 01:30 on October 18 at UTC+7 is still October 17 in UTC, which days are counted in.
+	now := time.Date(2026, 10, 18, 1, 30, 0, 0, time.FixedZone("ICT", 7*60*60))
+	wantTo := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	wantFrom := time.Date(2026, 9, 18, 0, 0, 0, 0, time.UTC)
+	daily := []repository.DailyCount{{Day: wantFrom, Count: 2}, {Day: wantTo.AddDate(0, 0, -1), Count: 3}}
+
+	tests := []struct {
+		name    string
+		mockFn  func(*MockUserStatsRepository)
+		want    *UserStats
+		wantErr error
+	}{
+		{
+			name: "counts users and recent signups",
+			mockFn: func(repo *MockUserStatsRepository) {
+				repo.On("Count", mock.Anything).Return(int64(120), nil)
+				repo.On("CountCreatedBetween", mock.Anything, wantFrom, wantTo).Return(int64(5), nil)
+				repo.On("CountCreatedByDay", mock.Anything, wantFrom, wantTo).Return(daily, nil)
+			},
+			want: &UserStats{TotalUsers: 120, Signups: 5, DailySignups: daily, From: wantFrom, To: wantTo},
+		},
+		{
+			name: "count fails",
+			mockFn: func(repo *MockUserStatsRepository) {
+				repo.On("Count", mock.Anything).Return(int64(0), repository.ErrTimeout)
+			},
+			wantErr: repository.ErrTimeout,
+		},
+		{
+			name: "daily series fails",
+			mockFn: func(repo *MockUserStatsRepository) {
+				repo.On("Count", mock.Anything).Return(int64(120), nil)
+				repo.On("CountCreatedBetween", mock.Anything, wantFrom, wantTo).Return(int64(5), nil)
+				repo.On("CountCreatedByDay", mock.Anything, wantFrom, wantTo).Return(nil, repository.ErrConn)
+			},
+			wantErr: repository.ErrConn,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(MockUserStatsRepository)
+			tt.mockFn(repo)
+			service := NewUserStatsService(repo)
+			service.now = func() time.Time { return now }
+
+			got, err := service.Stats(context.Background())
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.want, got)
+			repo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUserStatsService_TotalUsers(t *testing.T) {
+	repo := new(MockUserStatsRepository)
+	repo.On("Count", mock.Anything).Return(int64(42), nil)
+
+	got, err := NewUserStatsService(repo).TotalUsers(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), got)
+}
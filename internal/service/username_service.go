@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/PakornBank/learn-go/internal/events"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidUsername    = errors.New("username must be 3 to 30 characters of a-z, 0-9 or _")
+	ErrUsernameReserved   = errors.New("username is reserved")
+	ErrUsernameTaken      = errors.New("username already taken")
+	ErrUsernameAlreadySet = errors.New("username is already set")
+)
+
+// usernamePattern matches a username after it has been lowercased.
+var usernamePattern = regexp.MustCompile(`^[a-z0-9_]{3,30}$`)
+
+// reservedUsernames cannot be chosen, so that no user can pass themselves off
+// as the service or claim a name that looks like a route.
+var reservedUsernames = map[string]struct{}{
+	"admin": {}, "administrator": {}, "root": {}, "system": {}, "api": {},
+	"auth": {}, "login": {}, "logout": {}, "register": {}, "signup": {},
+	"profile": {}, "settings": {}, "me": {}, "support": {}, "help": {},
+	"security": {}, "staff": {}, "moderator": {}, "official": {},
+	"null": {}, "undefined": {}, "anonymous": {}, "www": {}, "mail": {},
+}
+
+// normalizeUsername returns username in the lowercase form it is stored and
+// looked up in, which makes usernames unique regardless of case.
+func normalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// validateUsername checks a normalized username against the allowed
+// characters and length and the reserved names.
+func validateUsername(username string) error {
+	if !usernamePattern.MatchString(username) {
+		return ErrInvalidUsername
+	}
+	if _, reserved := reservedUsernames[username]; reserved {
+		return ErrUsernameReserved
+	}
+	return nil
+}
+
+// isEmailIdentifier reports whether a login identifier is an email address
+// rather than a username. Usernames cannot contain "@".
+func isEmailIdentifier(identifier string) bool {
+	return strings.Contains(identifier, "@")
+}
+
+type UsernameRepository interface {
+	FindByID(ctx context.Context, id string) (*model.User, error)
+	FindByUsername(ctx context.Context, username string) (*model.User, error)
+	SetUsername(ctx context.Context, id uuid.UUID, username string) (bool, error)
+}
+
+type SetUsernameInput struct {
+	Username string `json:"username" binding:"required"`
+}
+
+// UsernameService lets users who registered without a username choose one.
+type UsernameService struct {
+	userRepo UsernameRepository
+	events   events.Publisher
+}
+
+// NewUsernameService creates a UsernameService that publishes profile updates to publisher.
+func NewUsernameService(userRepo UsernameRepository, publisher events.Publisher) *UsernameService {
+	return &UsernameService{userRepo: userRepo, events: publisher}
+}
+
+// Set gives the user with userID the requested username and returns the
+// updated user. A username can only be set once; ErrUsernameAlreadySet is
+// returned if the user already has one.
+func (s *UsernameService) Set(ctx context.Context, userID string, input SetUsernameInput) (*model.User, error) {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, ErrInvalidUserID
+	}
+
+	username := normalizeUsername(input.Username)
+	if err := validateUsername(username); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Username != nil {
+		return nil, ErrUsernameAlreadySet
+	}
+
+	if err := ensureUsernameAvailable(ctx, s.userRepo, username); err != nil {
+		return nil, err
+	}
+
+	set, err := s.userRepo.SetUsername(ctx, id, username)
+	if err != nil {
+		return nil, err
+	}
+	if !set {
+		return nil, ErrUsernameAlreadySet
+	}
+
+	user.Username = &username
+	s.events.Publish(userID, profileUpdated("username"))
+	return user, nil
+}
+
+// usernameFinder looks users up by username; both the auth and the username
+// repositories provide it.
+type usernameFinder interface {
+	FindByUsername(ctx context.Context, username string) (*model.User, error)
+}
+
+// ensureUsernameAvailable returns ErrUsernameTaken if a user already has username.
+func ensureUsernameAvailable(ctx context.Context, repo usernameFinder, username string) error {
+	existing, err := repo.FindByUsername(ctx, username)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+	if existing != nil {
+		return ErrUsernameTaken
+	}
+	return nil
+}
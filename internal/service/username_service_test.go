@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/PakornBank/learn-go/internal/events"
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockUsernameRepository struct {
+	mock.Mock
+}
+
+func (r *MockUsernameRepository) FindByID(ctx context.Context, id string) (*model.User, error) {
+	args := r.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+func (r *MockUsernameRepository) FindByUsername(ctx context.Context, username string) (*model.User, error) {
+	args := r.Called(ctx, username)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+func (r *MockUsernameRepository) SetUsername(ctx context.Context, id uuid.UUID, username string) (bool, error) {
+	args := r.Called(ctx, id, username)
+	return args.Bool(0), args.Error(1)
+}
+
+func TestValidateUsername(t *testing.T) {
+	tests := []struct {
+		username string
+		wantErr  error
+	}{
+		{username: "abc"},
+		{username: "user_123"},
+		{username: "abcdefghijklmnopqrstuvwxyz0123"},
+		{username: "ab", wantErr: ErrInvalidUsername},
+		{username: "abcdefghijklmnopqrstuvwxyz01234", wantErr: ErrInvalidUsername},
+		{username: "has-dash", wantErr: ErrInvalidUsername},
+		{username: "user@example.com", wantErr: ErrInvalidUsername},
+		{username: "Upper", wantErr: ErrInvalidUsername},
+		{username: "admin", wantErr: ErrUsernameReserved},
+		{username: "root", wantErr: ErrUsernameReserved},
+		{username: "api", wantErr: ErrUsernameReserved},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.username, func(t *testing.T) {
+			err := validateUsername(tt.username)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUsernameService_Set(t *testing.T) {
+	mockUser := testutil.NewMockUser()
+	withUsername := testutil.NewMockUser(testutil.WithID(mockUser.ID), testutil.WithUsername("taken"))
+
+	tests := []struct {
+		name    string
+		userID  string
+		input   SetUsernameInput
+		mockFn  func(*MockUsernameRepository)
+		wantErr error
+	}{
+		{
+			name:   "set",
+			userID: mockUser.ID.String(),
+			input:  SetUsernameInput{Username: " New_Name "},
+			mockFn: func(repo *MockUsernameRepository) {
+				repo.On("FindByID", mock.Anything, mockUser.ID.String()).Return(mockUser.Clone(), nil)
+				repo.On("FindByUsername", mock.Anything, "new_name").Return(nil, repository.ErrNotFound)
+				repo.On("SetUsername", mock.Anything, mockUser.ID, "new_name").Return(true, nil)
+			},
+		},
+		{
+			name:    "invalid user id",
+			userID:  "not-a-uuid",
+			input:   SetUsernameInput{Username: "new_name"},
+			mockFn:  func(repo *MockUsernameRepository) {},
+			wantErr: ErrInvalidUserID,
+		},
+		{
+			name:    "reserved",
+			userID:  mockUser.ID.String(),
+			input:   SetUsernameInput{Username: "Root"},
+			mockFn:  func(repo *MockUsernameRepository) {},
+			wantErr: ErrUsernameReserved,
+		},
+		{
+			name:   "already set",
+			userID: mockUser.ID.String(),
+			input:  SetUsernameInput{Username: "new_name"},
+			mockFn: func(repo *MockUsernameRepository) {
+				repo.On("FindByID", mock.Anything, mockUser.ID.String()).Return(withUsername.Clone(), nil)
+			},
+			wantErr: ErrUsernameAlreadySet,
+		},
+		{
+			name:   "set concurrently",
+			userID: mockUser.ID.String(),
+			input:  SetUsernameInput{Username: "new_name"},
+			mockFn: func(repo *MockUsernameRepository) {
+				repo.On("FindByID", mock.Anything, mockUser.ID.String()).Return(mockUser.Clone(), nil)
+				repo.On("FindByUsername", mock.Anything, "new_name").Return(nil, repository.ErrNotFound)
+				repo.On("SetUsername", mock.Anything, mockUser.ID, "new_name").Return(false, nil)
+			},
+			wantErr: ErrUsernameAlreadySet,
+		},
+		{
+			name:   "taken",
+			userID: mockUser.ID.String(),
+			input:  SetUsernameInput{Username: "Taken"},
+			mockFn: func(repo *MockUsernameRepository) {
+				repo.On("FindByID", mock.Anything, mockUser.ID.String()).Return(mockUser.Clone(), nil)
+				repo.On("FindByUsername", mock.Anything, "taken").Return(&withUsername, nil)
+			},
+			wantErr: ErrUsernameTaken,
+		},
+		{
+			name:   "database timeout",
+			userID: mockUser.ID.String(),
+			input:  SetUsernameInput{Username: "new_name"},
+			mockFn: func(repo *MockUsernameRepository) {
+				repo.On("FindByID", mock.Anything, mockUser.ID.String()).Return(mockUser.Clone(), nil)
+				repo.On("FindByUsername", mock.Anything, "new_name").Return(nil, repository.ErrTimeout)
+			},
+			wantErr: repository.ErrTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(MockUsernameRepository)
+			tt.mockFn(repo)
+			hub := events.NewHub(events.DefaultBufferSize, events.DefaultReplaySize)
+			sub := hub.Subscribe(tt.userID)
+			defer sub.Close()
+			s := NewUsernameService(repo, hub)
+
+			user, err := s.Set(context.Background(), tt.userID, tt.input)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, user)
+				assert.Empty(t, sub.Events())
+			} else {
+				assert.NoError(t, err)
+				if assert.NotNil(t, user.Username) {
+					assert.Equal(t, "new_name", *user.Username)
+				}
+				if assert.Len(t, sub.Events(), 1) {
+					event := <-sub.Events()
+					assert.Equal(t, events.TypeProfileUpdated, event.Type)
+					assert.Equal(t, map[string]string{"field": "username"}, event.Data)
+				}
+			}
+			repo.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,92 @@
+// Package storage stores binary objects such as uploaded images.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ErrInvalidKey is returned for keys or URLs that do not name an object in the store.
+var ErrInvalidKey = errors.New("invalid blob key")
+
+// BlobStorage stores objects under a key and serves them from a URL.
+type BlobStorage interface {
+	// Put stores data under key, replacing any object already there, and
+	// returns the URL the object is served from.
+	Put(ctx context.Context, key string, data []byte, contentType string) (string, error)
+
+	// Delete removes the object served from url. Deleting an object that does
+	// not exist is not an error.
+	Delete(ctx context.Context, url string) error
+}
+
+// Local is a BlobStorage that writes objects as files in a directory, which
+// the application serves under a URL prefix.
+type Local struct {
+	dir       string
+	urlPrefix string
+}
+
+// NewLocal creates a Local storage writing to dir for objects served under
+// urlPrefix. The directory is created on the first Put.
+func NewLocal(dir, urlPrefix string) *Local {
+	return &Local{dir: dir, urlPrefix: strings.TrimSuffix(urlPrefix, "/")}
+}
+
+// Put writes data to a file named key. The file is written under a temporary
+// name and renamed into place, so readers never see a partial object.
+func (l *Local) Put(_ context.Context, key string, data []byte, _ string) (string, error) {
+	if !validKey(key) {
+		return "", ErrInvalidKey
+	}
+	if err := os.MkdirAll(l.dir, 0o755); err != nil {
+		return "", fmt.Errorf("create storage directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(l.dir, ".upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(l.dir, key)); err != nil {
+		return "", err
+	}
+
+	return l.urlPrefix + "/" + key, nil
+}
+
+// Delete removes the file behind url, which must have been returned by Put.
+func (l *Local) Delete(_ context.Context, url string) error {
+	key, ok := strings.CutPrefix(url, l.urlPrefix+"/")
+	if !ok || !validKey(key) {
+		return ErrInvalidKey
+	}
+
+	err := os.Remove(filepath.Join(l.dir, key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// validKey reports whether key is a plain file name, so it cannot escape the
+// storage directory or name a hidden temporary file.
+func validKey(key string) bool {
+	return key != "" && !strings.HasPrefix(key, ".") && path.Base(key) == key && !strings.ContainsAny(key, `/\`)
+}
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocal_PutAndDelete(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "avatars")
+	store := NewLocal(dir, "/avatars/")
+	ctx := context.Background()
+
+	url, err := store.Put(ctx, "user.png", []byte("first"), "image/png")
+	require.NoError(t, err)
+	assert.Equal(t, "/avatars/user.png", url)
+
+	_, err = store.Put(ctx, "user.png", []byte("second"), "image/png")
+	require.NoError(t, err)
+	data, err := os.ReadFile(filepath.Join(dir, "user.png"))
+	require.NoError(t, err)
+	assert.Equal(t, "second", string(data))
+
+	require.NoError(t, store.Delete(ctx, url))
+	_, err = os.Stat(filepath.Join(dir, "user.png"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	assert.NoError(t, store.Delete(ctx, url), "deleting a missing object is not an error")
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "no temporary files are left behind")
+}
+
+func TestLocal_InvalidKeys(t *testing.T) {
+	dir := t.TempDir()
+	store := NewLocal(dir, "/avatars")
+	ctx := context.Background()
+
+	for _, key := range []string{"", "../escape.png", "nested/user.png", ".upload-123", `..\escape.png`} {
+		_, err := store.Put(ctx, key, []byte("data"), "image/png")
+		assert.ErrorIs(t, err, ErrInvalidKey, key)
+	}
+
+	for _, url := range []string{"/other/user.png", "/avatars/../config.env", "/avatars/"} {
+		assert.ErrorIs(t, store.Delete(ctx, url), ErrInvalidKey, url)
+	}
+}
@@ -0,0 +1,167 @@
+// Package testrepo holds the behavior every user repository must share, as a
+// contract test suite, and an in-memory fake that satisfies it. Running the
+// suite against both the real repository and the fake makes any divergence
+// between them a test failure, so tests using the fake exercise the same
+// semantics as production.
+package testrepo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/PakornBank/learn-go/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Repository is the part of repository.UserRepository the contract covers.
+type Repository interface {
+	Create(ctx context.Context, user *model.User) error
+	FindByEmail(ctx context.Context, email string) (*model.User, error)
+	FindByID(ctx context.Context, id string) (*model.User, error)
+	UpdateEmail(ctx context.Context, id uuid.UUID, email string) error
+}
+
+// Factory returns a repository with no users for a single contract test.
+type Factory func(t *testing.T) Repository
+
+// RunRepositoryContract runs the contract tests against repositories returned
+// by factory, one for each test.
+func RunRepositoryContract(t *testing.T, factory Factory) {
+	ctx := context.Background()
+
+	t.Run("create then find", func(t *testing.T) {
+		repo := factory(t)
+		user := testutil.Persist(t, repo, testutil.WithUsername("tester"))
+
+		byID, err := repo.FindByID(ctx, user.ID.String())
+		require.NoError(t, err)
+		assertSameUser(t, &user, byID)
+
+		byEmail, err := repo.FindByEmail(ctx, user.Email)
+		require.NoError(t, err)
+		assertSameUser(t, &user, byEmail)
+	})
+
+	t.Run("create assigns an ID", func(t *testing.T) {
+		repo := factory(t)
+		user := testutil.NewMockUser(testutil.WithID(uuid.Nil))
+
+		require.NoError(t, repo.Create(ctx, &user))
+		require.NotEqual(t, uuid.Nil, user.ID)
+
+		found, err := repo.FindByID(ctx, user.ID.String())
+		require.NoError(t, err)
+		assert.Equal(t, user.Email, found.Email)
+	})
+
+	t.Run("create duplicate email", func(t *testing.T) {
+		repo := factory(t)
+		existing := testutil.Persist(t, repo)
+		user := testutil.NewMockUser(testutil.WithEmail(existing.Email))
+
+		assert.ErrorIs(t, repo.Create(ctx, &user), repository.ErrDuplicate)
+
+		_, err := repo.FindByID(ctx, user.ID.String())
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+	})
+
+	t.Run("create duplicate username", func(t *testing.T) {
+		repo := factory(t)
+		testutil.Persist(t, repo, testutil.WithEmail("first@example.com"), testutil.WithUsername("tester"))
+		user := testutil.NewMockUser(testutil.WithEmail("second@example.com"), testutil.WithUsername("tester"))
+
+		assert.ErrorIs(t, repo.Create(ctx, &user), repository.ErrDuplicate)
+	})
+
+	t.Run("users without a username", func(t *testing.T) {
+		repo := factory(t)
+		testutil.Persist(t, repo, testutil.WithEmail("first@example.com"))
+		testutil.Persist(t, repo, testutil.WithEmail("second@example.com"))
+	})
+
+	t.Run("find by email not found", func(t *testing.T) {
+		repo := factory(t)
+		testutil.Persist(t, repo)
+
+		_, err := repo.FindByEmail(ctx, "missing@example.com")
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+	})
+
+	t.Run("find by ID not found", func(t *testing.T) {
+		repo := factory(t)
+		testutil.Persist(t, repo)
+
+		_, err := repo.FindByID(ctx, uuid.NewString())
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+	})
+
+	t.Run("find by invalid ID", func(t *testing.T) {
+		repo := factory(t)
+
+		_, err := repo.FindByID(ctx, "not-a-uuid")
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+	})
+
+	t.Run("update email", func(t *testing.T) {
+		repo := factory(t)
+		user := testutil.Persist(t, repo)
+
+		require.NoError(t, repo.UpdateEmail(ctx, user.ID, "new@example.com"))
+
+		found, err := repo.FindByEmail(ctx, "new@example.com")
+		require.NoError(t, err)
+		assert.Equal(t, user.ID, found.ID)
+		_, err = repo.FindByEmail(ctx, user.Email)
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+	})
+
+	t.Run("update email duplicate", func(t *testing.T) {
+		repo := factory(t)
+		first := testutil.Persist(t, repo, testutil.WithEmail("first@example.com"))
+		second := testutil.Persist(t, repo, testutil.WithEmail("second@example.com"))
+
+		assert.ErrorIs(t, repo.UpdateEmail(ctx, second.ID, first.Email), repository.ErrDuplicate)
+
+		found, err := repo.FindByID(ctx, second.ID.String())
+		require.NoError(t, err)
+		assert.Equal(t, second.Email, found.Email)
+	})
+
+	t.Run("update email of missing user", func(t *testing.T) {
+		repo := factory(t)
+
+		require.NoError(t, repo.UpdateEmail(ctx, uuid.New(), "new@example.com"))
+
+		_, err := repo.FindByEmail(ctx, "new@example.com")
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+	})
+
+	t.Run("found users are copies", func(t *testing.T) {
+		repo := factory(t)
+		user := testutil.Persist(t, repo)
+
+		found, err := repo.FindByID(ctx, user.ID.String())
+		require.NoError(t, err)
+		found.Email = "changed@example.com"
+
+		found, err = repo.FindByID(ctx, user.ID.String())
+		require.NoError(t, err)
+		assert.Equal(t, user.Email, found.Email)
+	})
+}
+
+// assertSameUser checks that got holds the stored fields of want. Timestamps
+// are left out, since databases may store them at a lower precision.
+func assertSameUser(t *testing.T, want, got *model.User) {
+	t.Helper()
+	assert.Equal(t, want.ID, got.ID)
+	assert.Equal(t, want.Email, got.Email)
+	assert.Equal(t, want.PasswordHash, got.PasswordHash)
+	assert.Equal(t, want.FullName, got.FullName)
+	assert.Equal(t, want.Role, got.Role)
+	assert.Equal(t, want.Username, got.Username)
+}
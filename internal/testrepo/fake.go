@@ -0,0 +1,97 @@
+package testrepo
+
+import (
+	"context"
+	"sync"
+
+	"github.com/PakornBank/learn-go/internal/model"
+	"github.com/PakornBank/learn-go/internal/repository"
+	"github.com/google/uuid"
+)
+
+// FakeUserRepository is an in-memory Repository for tests that need stored
+// users without a database. It enforces the same unique email and username
+// constraints and returns the same sentinel errors as the real repository,
+// which RunRepositoryContract checks. It is safe for concurrent use.
+type FakeUserRepository struct {
+	mu    sync.Mutex
+	users map[uuid.UUID]*model.User
+}
+
+// NewFakeUserRepository creates a FakeUserRepository with no users.
+func NewFakeUserRepository() *FakeUserRepository {
+	return &FakeUserRepository{users: make(map[uuid.UUID]*model.User)}
+}
+
+// Create stores a copy of user, assigning it a new ID if it has none. It
+// returns ErrDuplicate if another user has the same email or username.
+func (r *FakeUserRepository) Create(_ context.Context, user *model.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if user.ID == uuid.Nil {
+		user.ID = uuid.New()
+	}
+	for _, existing := range r.users {
+		if existing.ID == user.ID || existing.Email == user.Email ||
+			(existing.Username != nil && user.Username != nil && *existing.Username == *user.Username) {
+			return repository.ErrDuplicate
+		}
+	}
+
+	r.users[user.ID] = user.Clone()
+	return nil
+}
+
+// FindByEmail returns a copy of the user with the given email, or
+// ErrNotFound if there is none.
+func (r *FakeUserRepository) FindByEmail(_ context.Context, email string) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, user := range r.users {
+		if user.Email == email {
+			return user.Clone(), nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+// FindByID returns a copy of the user with the given ID, or ErrNotFound if
+// there is none or id is not a valid UUID.
+func (r *FakeUserRepository) FindByID(_ context.Context, id string) (*model.User, error) {
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return nil, repository.ErrNotFound
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[uid]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return user.Clone(), nil
+}
+
+// UpdateEmail changes the email of the user with the given ID, doing nothing
+// if there is no such user. It returns ErrDuplicate if another user already
+// has the address.
+func (r *FakeUserRepository) UpdateEmail(_ context.Context, id uuid.UUID, email string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return nil
+	}
+	for _, existing := range r.users {
+		if existing.ID != id && existing.Email == email {
+			return repository.ErrDuplicate
+		}
+	}
+
+	user.Email = email
+	return nil
+}
@@ -0,0 +1,9 @@
+package testrepo
+
+import "testing"
+
+func TestFakeUserRepository_Contract(t *testing.T) {
+	RunRepositoryContract(t, func(t *testing.T) Repository {
+		return NewFakeUserRepository()
+	})
+}
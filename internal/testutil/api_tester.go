@@ -0,0 +1,152 @@
+package testutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// APITester serves requests for a test with a gin engine in test mode. Routes
+// are registered on the embedded engine after the middleware it was created
+// with, so they run behind it.
+type APITester struct {
+	*gin.Engine
+	t *testing.T
+}
+
+// NewAPITester creates an APITester whose engine runs middleware, in order,
+// before every route registered on it. Nil middleware is skipped, so tables
+// of test cases can leave it unset.
+func NewAPITester(t *testing.T, middleware ...gin.HandlerFunc) *APITester {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	engine := gin.New()
+	for _, m := range middleware {
+		if m != nil {
+			engine.Use(m)
+		}
+	}
+	return &APITester{Engine: engine, t: t}
+}
+
+// RequestOption changes a request before an APITester sends it.
+type RequestOption func(*http.Request)
+
+// WithHeader sets the header key of a request to value.
+func WithHeader(key, value string) RequestOption {
+	return func(req *http.Request) {
+		req.Header.Set(key, value)
+	}
+}
+
+// Do sends a request for path to the engine and returns its response. A
+// string, []byte or io.Reader body is sent as it is, and any other non-nil
+// body is encoded as JSON. Requests with a body are sent as JSON unless an
+// option sets another Content-Type.
+func (a *APITester) Do(method, path string, body any, opts ...RequestOption) *APIResponse {
+	a.t.Helper()
+
+	var reader io.Reader
+	switch b := body.(type) {
+	case nil:
+	case string:
+		reader = strings.NewReader(b)
+	case []byte:
+		reader = bytes.NewReader(b)
+	case io.Reader:
+		reader = b
+	default:
+		data, err := json.Marshal(b)
+		require.NoError(a.t, err)
+		reader = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	if reader != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for _, opt := range opts {
+		opt(req)
+	}
+
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, req)
+	return &APIResponse{ResponseRecorder: w, t: a.t}
+}
+
+// APIResponse is a response recorded by an APITester. Its assertions report
+// to the test that sent the request and return the response, so they can be
+// chained.
+type APIResponse struct {
+	*httptest.ResponseRecorder
+	t *testing.T
+}
+
+// AssertStatus checks the status code of the response.
+func (r *APIResponse) AssertStatus(code int) *APIResponse {
+	r.t.Helper()
+	assert.Equal(r.t, code, r.Code, "response body: %s", r.Body.String())
+	return r
+}
+
+// AssertJSON checks that the body is the JSON document want, ignoring
+// formatting and the order of object keys.
+func (r *APIResponse) AssertJSON(want string) *APIResponse {
+	r.t.Helper()
+	assert.JSONEq(r.t, want, r.Body.String())
+	return r
+}
+
+// AssertGolden checks that the body is exactly the content of the golden
+// file testdata/name. See AssertGolden.
+func (r *APIResponse) AssertGolden(name string) *APIResponse {
+	r.t.Helper()
+	AssertGolden(r.t, name, r.Body.Bytes())
+	return r
+}
+
+// Decode decodes the JSON body into v, failing the test if it cannot.
+func (r *APIResponse) Decode(v any) {
+	r.t.Helper()
+	require.NoError(r.t, json.Unmarshal(r.Body.Bytes(), v), "response body: %s", r.Body.String())
+}
+
+// JSON decodes the body as a JSON object.
+func (r *APIResponse) JSON() map[string]any {
+	r.t.Helper()
+	var body map[string]any
+	r.Decode(&body)
+	return body
+}
+
+// AssertGolden compares got with the golden file testdata/name, relative to
+// the package under test, byte for byte. When the tests run with -update the
+// file is rewritten with got instead, so changes to it can be reviewed in the
+// diff.
+func AssertGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, got, 0o644))
+	}
+
+	want, err := os.ReadFile(path)
+	require.NoError(t, err, "missing golden file, run the tests with -update to create it")
+	assert.Equal(t, string(want), string(got), "response differs from %s; if the change is intended, run the tests with -update", path)
+}
@@ -1,19 +1,100 @@
 package testutil
 
 import (
+	"context"
+	"testing"
 	"time"
 
 	"github.com/PakornBank/learn-go/internal/model"
 	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
 )
 
-func NewMockUser() model.User {
-	return model.User{
+// UserOption changes a user built by NewMockUser.
+type UserOption func(*model.User)
+
+// WithID sets the ID of the user.
+func WithID(id uuid.UUID) UserOption {
+	return func(u *model.User) {
+		u.ID = id
+	}
+}
+
+// WithEmail sets the email address of the user.
+func WithEmail(email string) UserOption {
+	return func(u *model.User) {
+		u.Email = email
+	}
+}
+
+// WithRole sets the role of the user, such as model.RoleAdmin.
+func WithRole(role string) UserOption {
+	return func(u *model.User) {
+		u.Role = role
+	}
+}
+
+// WithPassword sets the password hash of the user to the FastHash of
+// password, for services checking passwords with FastHasher.
+func WithPassword(password string) UserOption {
+	return func(u *model.User) {
+		u.PasswordHash = FastHash(password)
+	}
+}
+
+// WithUsername sets the username of the user.
+func WithUsername(username string) UserOption {
+	return func(u *model.User) {
+		u.Username = &username
+	}
+}
+
+// WithDeleted marks the user as having asked at requestedAt for their account
+// to be erased, leaving it in the grace period before it is purged.
+func WithDeleted(requestedAt time.Time) UserOption {
+	return func(u *model.User) {
+		u.DeletionRequestedAt = &requestedAt
+	}
+}
+
+// WithCreatedAt sets when the user was created, and last updated, to t.
+func WithCreatedAt(t time.Time) UserOption {
+	return func(u *model.User) {
+		u.CreatedAt = t
+		u.UpdatedAt = t
+	}
+}
+
+// NewMockUser returns a user with a new random ID and the email
+// "test@example.com", created now, changed by opts in order.
+func NewMockUser(opts ...UserOption) model.User {
+	user := model.User{
 		ID:           uuid.New(),
 		Email:        "test@example.com",
 		PasswordHash: "hashedpassword",
 		FullName:     "Test User",
+		Role:         model.RoleUser,
 		CreatedAt:    time.Now(),
 		UpdatedAt:    time.Now(),
 	}
+	for _, opt := range opts {
+		opt(&user)
+	}
+	return user
+}
+
+// UserCreator inserts users, as repository.UserRepository does.
+type UserCreator interface {
+	Create(ctx context.Context, user *model.User) error
+}
+
+// Persist builds a user like NewMockUser and inserts it through repo, failing
+// the test if it cannot. It returns the user as inserted, with any values the
+// database filled in.
+func Persist(t testing.TB, repo UserCreator, opts ...UserOption) model.User {
+	t.Helper()
+
+	user := NewMockUser(opts...)
+	require.NoError(t, repo.Create(context.Background(), &user))
+	return user
 }
@@ -0,0 +1,153 @@
+package testutil
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// The subject of the tokens a TokenFactory mints unless WithClaim changes it.
+const (
+	TokenUserID = "3f6c1d2e-8a4b-4c9d-b7e1-0a2b4c6d8e9f"
+	TokenEmail  = "test@email.com"
+)
+
+// TokenFactory mints access tokens for tests, signed with HS256 and its
+// secret like the tokens AuthService issues. Tokens are issued at the time of
+// its clock, the system clock unless At sets another. Tests that need broken
+// tokens get them through options: an expired one with a negative WithExpiry,
+// one with a wrong signature with WithSigningKey, one missing a claim with a
+// nil WithClaim, an unsigned one with WithAlg(jwt.SigningMethodNone), and
+// one whose header lies about its algorithm with WithHeaderAlg.
+type TokenFactory struct {
+	secret []byte
+	clock  interface{ Now() time.Time }
+}
+
+// NewTokenFactory creates a TokenFactory signing with secret, which must be
+// the secret the code under test validates tokens with.
+func NewTokenFactory(secret string) *TokenFactory {
+	return &TokenFactory{secret: []byte(secret)}
+}
+
+// At returns a copy of the factory that issues its tokens at the time clock
+// shows, such as that of a FakeClock, so tests can check expiry against the
+// same clock deterministically.
+func (f *TokenFactory) At(clock interface{ Now() time.Time }) *TokenFactory {
+	return &TokenFactory{secret: f.secret, clock: clock}
+}
+
+func (f *TokenFactory) now() time.Time {
+	if f.clock == nil {
+		return time.Now()
+	}
+	return f.clock.Now()
+}
+
+// TokenOption changes a token minted by a TokenFactory.
+type TokenOption func(*tokenOptions)
+
+type tokenOptions struct {
+	now       time.Time
+	claims    jwt.MapClaims
+	alg       jwt.SigningMethod
+	headerAlg string
+	key       []byte
+}
+
+// WithExpiry makes the token expire d after it is issued; a negative d mints
+// a token that has already expired.
+func WithExpiry(d time.Duration) TokenOption {
+	return func(o *tokenOptions) {
+		o.claims["exp"] = o.now.Add(d).Unix()
+	}
+}
+
+// WithNotBefore makes the token valid from d after it is issued, setting its
+// "nbf" claim.
+func WithNotBefore(d time.Duration) TokenOption {
+	return func(o *tokenOptions) {
+		o.claims["nbf"] = o.now.Add(d).Unix()
+	}
+}
+
+// WithClaim sets the claim key of the token to value, or removes it if value
+// is nil.
+func WithClaim(key string, value any) TokenOption {
+	return func(o *tokenOptions) {
+		if value == nil {
+			delete(o.claims, key)
+			return
+		}
+		o.claims[key] = value
+	}
+}
+
+// WithAlg signs the token with alg instead of HS256. Only HMAC algorithms and
+// jwt.SigningMethodNone, which leaves the token unsigned, are supported.
+func WithAlg(alg jwt.SigningMethod) TokenOption {
+	return func(o *tokenOptions) {
+		o.alg = alg
+	}
+}
+
+// WithHeaderAlg names alg as the algorithm in the token's header while still
+// signing it with the factory's secret, as an attacker trying to have an HMAC
+// signature verified as, say, an RS256 one would.
+func WithHeaderAlg(alg string) TokenOption {
+	return func(o *tokenOptions) {
+		o.headerAlg = alg
+	}
+}
+
+// WithSigningKey signs the token with secret instead of the secret of the
+// factory, so its signature does not verify.
+func WithSigningKey(secret string) TokenOption {
+	return func(o *tokenOptions) {
+		o.key = []byte(secret)
+	}
+}
+
+// Token mints a token for TokenUserID and TokenEmail, issued at the time of
+// the factory's clock and expiring an hour later, changed by opts in order.
+// It returns the token along with its claims, for assertions on what the
+// code under test read from it. It panics
+// if the token cannot be signed, which only an unsupported WithAlg causes.
+func (f *TokenFactory) Token(opts ...TokenOption) (string, jwt.MapClaims) {
+	now := f.now()
+	o := tokenOptions{
+		now: now,
+		claims: jwt.MapClaims{
+			"user_id": TokenUserID,
+			"email":   TokenEmail,
+			"iat":     now.Unix(),
+			"exp":     now.Add(time.Hour).Unix(),
+		},
+		alg: jwt.SigningMethodHS256,
+		key: f.secret,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var key any = o.key
+	if o.alg == jwt.SigningMethodNone {
+		key = jwt.UnsafeAllowNoneSignatureType
+	}
+	token := jwt.NewWithClaims(o.alg, o.claims)
+	if o.headerAlg != "" {
+		token.Header["alg"] = o.headerAlg
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		panic("testutil: signing token: " + err.Error())
+	}
+	return signed, o.claims
+}
+
+// Bearer mints a token like Token and returns it as the value of an
+// "Authorization" header.
+func (f *TokenFactory) Bearer(opts ...TokenOption) string {
+	token, _ := f.Token(opts...)
+	return "Bearer " + token
+}
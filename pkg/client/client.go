@@ -0,0 +1,299 @@
+// Package client is a Go client for the authentication API, for services that
+// call it over HTTP. It depends on the standard library only.
+//
+// A Client attaches the access token of its last Login, or the one given with
+// WithToken, to every request that needs one, and turns error responses into
+// an *Error that matches sentinels such as ErrEmailTaken with errors.Is.
+// Requests failing with a 5xx status are retried with jittered exponential
+// backoff.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults of a Client created without options.
+const (
+	DefaultTimeout    = 10 * time.Second
+	DefaultMaxRetries = 2
+	DefaultBackoff    = 200 * time.Millisecond
+)
+
+// maxBackoff caps the wait before a retry, including one asked for with
+// Retry-After.
+const maxBackoff = 5 * time.Second
+
+// Client calls the API at a base URL. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	maxRetries int
+	backoff    time.Duration
+
+	mu    sync.RWMutex
+	token string
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sends requests with httpClient instead of a new http.Client
+// with DefaultTimeout. WithTimeout changes the timeout of httpClient.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithTimeout bounds each attempt at a request, including reading the
+// response, by timeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.httpClient.Timeout = timeout
+	}
+}
+
+// WithRetries retries requests failing with a 5xx status or a network error
+// up to maxRetries times, waiting about backoff before the first retry and
+// twice as long before each next one. A maxRetries of 0 turns retries off.
+func WithRetries(maxRetries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.backoff = backoff
+	}
+}
+
+// WithToken authenticates requests with the access token token, as if it was
+// returned by Login.
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// New creates a Client for the API served at baseURL, such as
+// "https://auth.example.com".
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: DefaultTimeout},
+		maxRetries: DefaultMaxRetries,
+		backoff:    DefaultBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Token returns the access token the client authenticates with, or an empty
+// string if it has none.
+func (c *Client) Token() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.token
+}
+
+// SetToken makes the client authenticate with the access token token.
+func (c *Client) SetToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = token
+}
+
+// User is a user as the API returns it.
+type User struct {
+	ID          string          `json:"id"`
+	Email       string          `json:"email"`
+	FullName    string          `json:"full_name"`
+	Role        string          `json:"role"`
+	LastLoginAt *time.Time      `json:"last_login_at"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	Metadata    json.RawMessage `json:"metadata"`
+	AvatarURL   Avatar          `json:"avatar_url"`
+	Username    *string         `json:"username"`
+	Locale      string          `json:"locale"`
+	Timezone    string          `json:"timezone"`
+}
+
+// Avatar is the avatar of a user. URL is the image to show, either the
+// user's upload or their Gravatar, which answers 404 if they have none. If
+// the server is configured to send initials instead, URL is empty and the
+// avatar is drawn with Initials on a background of Color.
+type Avatar struct {
+	URL      string
+	Initials string `json:"initials"`
+	Color    string `json:"color"`
+}
+
+// UnmarshalJSON decodes an avatar sent either as a URL or as an object with
+// initials and a color.
+func (a *Avatar) UnmarshalJSON(data []byte) error {
+	*a = Avatar{}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		type drawn Avatar
+		return json.Unmarshal(data, (*drawn)(a))
+	}
+	var url *string
+	if err := json.Unmarshal(data, &url); err != nil {
+		return err
+	}
+	if url != nil {
+		a.URL = *url
+	}
+	return nil
+}
+
+// RegisterInput is the account to create with Register. Email, Password and
+// FullName are required.
+type RegisterInput struct {
+	Email       string `json:"email"`
+	Password    string `json:"password"`
+	FullName    string `json:"full_name"`
+	Username    string `json:"username,omitempty"`
+	AcceptedTOS bool   `json:"accepted_tos,omitempty"`
+	Locale      string `json:"locale,omitempty"`
+	Timezone    string `json:"timezone,omitempty"`
+}
+
+// Tokens are the tokens returned by Login.
+type Tokens struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Register creates an account and returns the new user.
+func (c *Client) Register(ctx context.Context, input RegisterInput) (*User, error) {
+	var user User
+	if err := c.do(ctx, http.MethodPost, "/api/auth/register", input, false, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Login authenticates with identifier, an email address or username, and
+// password. From then on the client authenticates with the returned access
+// token.
+func (c *Client) Login(ctx context.Context, identifier, password string) (*Tokens, error) {
+	input := struct {
+		Identifier string `json:"identifier"`
+		Password   string `json:"password"`
+	}{identifier, password}
+
+	var tokens Tokens
+	if err := c.do(ctx, http.MethodPost, "/api/auth/login", input, false, &tokens); err != nil {
+		return nil, err
+	}
+	c.SetToken(tokens.AccessToken)
+	return &tokens, nil
+}
+
+// GetProfile returns the user the client is authenticated as.
+func (c *Client) GetProfile(ctx context.Context) (*User, error) {
+	var user User
+	if err := c.do(ctx, http.MethodGet, "/api/auth/profile", nil, true, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// do sends a request, retrying it as configured, and decodes a successful
+// response into out. The access token is attached if authenticated is true.
+func (c *Client) do(ctx context.Context, method, path string, in any, authenticated bool, out any) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return fmt.Errorf("client: encoding request: %w", err)
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		retryAfter, err := c.attempt(ctx, method, path, body, authenticated, out)
+		if err == nil || attempt >= c.maxRetries || !retryable(err) {
+			return err
+		}
+
+		wait := c.backoffFor(attempt)
+		if retryAfter > 0 {
+			wait = min(retryAfter, maxBackoff)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// attempt sends a request once. It returns how long the server asked to wait
+// before retrying, if it did.
+func (c *Client) attempt(ctx context.Context, method, path string, body []byte, authenticated bool, out any) (time.Duration, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return 0, fmt.Errorf("client: building request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token := c.Token(); authenticated && token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, &networkError{err: err}
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, &networkError{err: err}
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return parseRetryAfter(resp.Header.Get("Retry-After")), decodeError(resp.StatusCode, data)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return 0, fmt.Errorf("client: decoding %s %s response: %w", method, path, err)
+	}
+	return 0, nil
+}
+
+// backoffFor returns the wait before retry attempt+1: the backoff doubled
+// attempt times, capped at maxBackoff, with up to half of it taken off at
+// random so that clients failing together do not retry together.
+func (c *Client) backoffFor(attempt int) time.Duration {
+	wait := c.backoff << attempt
+	if wait <= 0 || wait > maxBackoff {
+		wait = maxBackoff
+	}
+	return wait - time.Duration(rand.Int63n(int64(wait)/2+1))
+}
+
+// parseRetryAfter returns the delay of a Retry-After header in seconds, or 0
+// if it is missing or not a number of seconds.
+func parseRetryAfter(value string) time.Duration {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
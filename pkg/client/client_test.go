@@ -0,0 +1,335 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recorded returns a response body recorded by the handler tests, which
+// check it against the handlers on every run. Replaying them here makes a
+// change to the responses of the server that the client does not follow fail
+// these tests.
+func recorded(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("..", "..", "internal", "handler", "testdata", name))
+	require.NoError(t, err)
+	return data
+}
+
+// replay returns a server that responds to every request with status and
+// body, passing the request to check first if it is not nil.
+func replay(t *testing.T, status int, body []byte, check func(*http.Request)) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if check != nil {
+			check(r)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestClient_Register(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		recorded string
+		wantErr  error
+		wantCode string
+	}{
+		{
+			name:     "success",
+			status:   http.StatusCreated,
+			recorded: "user_response.json",
+		},
+		{
+			name:     "email taken",
+			status:   http.StatusBadRequest,
+			recorded: "auth/register_email_taken.json",
+			wantErr:  ErrEmailTaken,
+			wantCode: "EMAIL_TAKEN",
+		},
+		{
+			name:     "account recoverable",
+			status:   http.StatusBadRequest,
+			recorded: "auth/register_account_recoverable.json",
+			wantErr:  ErrAccountRecoverable,
+			wantCode: "ACCOUNT_RECOVERABLE",
+		},
+		{
+			name:     "invalid email",
+			status:   http.StatusBadRequest,
+			recorded: "auth/register_invalid_email.json",
+			wantErr:  ErrInvalidInput,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := RegisterInput{Email: "golden@example.com", Password: "password123", FullName: "Golden"}
+			server := replay(t, tt.status, recorded(t, tt.recorded), func(r *http.Request) {
+				assert.Equal(t, http.MethodPost, r.Method)
+				assert.Equal(t, "/api/auth/register", r.URL.Path)
+				assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+				assert.Empty(t, r.Header.Get("Authorization"))
+				var got RegisterInput
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+				assert.Equal(t, input, got)
+			})
+
+			user, err := New(server.URL).Register(context.Background(), input)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				var apiErr *Error
+				require.ErrorAs(t, err, &apiErr)
+				assert.Equal(t, tt.status, apiErr.StatusCode)
+				assert.Equal(t, tt.wantCode, apiErr.Code)
+				assert.NotEmpty(t, apiErr.Message)
+				assert.Nil(t, user)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "6f1c2a8e-3b7d-4c55-9a0e-2f4d8b1e7c90", user.ID)
+			assert.Equal(t, "golden@example.com", user.Email)
+			assert.Equal(t, "user", user.Role)
+			assert.JSONEq(t, `{}`, string(user.Metadata))
+		})
+	}
+}
+
+func TestClient_Login(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		server := replay(t, http.StatusOK, recorded(t, "auth/login.json"), func(r *http.Request) {
+			assert.Equal(t, "/api/auth/login", r.URL.Path)
+			body, _ := io.ReadAll(r.Body)
+			assert.JSONEq(t, `{"identifier":"golden@example.com","password":"password123"}`, string(body))
+		})
+		c := New(server.URL)
+
+		tokens, err := c.Login(context.Background(), "golden@example.com", "password123")
+
+		require.NoError(t, err)
+		assert.Equal(t, &Tokens{AccessToken: "test-token", RefreshToken: "test-refresh-token"}, tokens)
+		assert.Equal(t, "test-token", c.Token())
+	})
+
+	t.Run("invalid credentials", func(t *testing.T) {
+		server := replay(t, http.StatusBadRequest, recorded(t, "auth/login_invalid_credentials.json"), nil)
+		c := New(server.URL, WithToken("old-token"))
+
+		_, err := c.Login(context.Background(), "golden@example.com", "wrong")
+
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+		assert.Equal(t, "old-token", c.Token())
+	})
+}
+
+func TestClient_GetProfile(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		recorded string
+		wantErr  error
+	}{
+		{name: "success", status: http.StatusOK, recorded: "user_response_last_login.json"},
+		{name: "unauthorized", status: http.StatusUnauthorized, recorded: "auth/profile_unauthorized.json", wantErr: ErrUnauthorized},
+		{name: "not found", status: http.StatusNotFound, recorded: "auth/profile_not_found.json", wantErr: ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := replay(t, tt.status, recorded(t, tt.recorded), func(r *http.Request) {
+				assert.Equal(t, http.MethodGet, r.Method)
+				assert.Equal(t, "/api/auth/profile", r.URL.Path)
+				assert.Equal(t, "Bearer test-token", r.Header.Get("Authorization"))
+			})
+
+			user, err := New(server.URL, WithToken("test-token")).GetProfile(context.Background())
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "golden_user", *user.Username)
+			assert.Equal(t, "Asia/Bangkok", user.Timezone)
+			assert.Equal(t, "/avatars/6f1c2a8e-3b7d-4c55-9a0e-2f4d8b1e7c90-0123456789abcdef.png", user.AvatarURL.URL)
+			require.NotNil(t, user.LastLoginAt)
+			assert.True(t, user.LastLoginAt.Equal(time.Date(2024, 3, 4, 5, 6, 7, 890000000, time.UTC)))
+		})
+	}
+}
+
+func TestAvatar_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want Avatar
+	}{
+		{name: "url", data: `"https://www.gravatar.com/avatar/abc?d=404"`, want: Avatar{URL: "https://www.gravatar.com/avatar/abc?d=404"}},
+		{name: "initials", data: `{"initials":"TU","color":"#4f46e5"}`, want: Avatar{Initials: "TU", Color: "#4f46e5"}},
+		{name: "null", data: `null`, want: Avatar{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Avatar
+			require.NoError(t, json.Unmarshal([]byte(tt.data), &got))
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestClient_LoginAttachesToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/auth/login" {
+			w.Write(recorded(t, "auth/login.json"))
+			return
+		}
+		assert.Equal(t, "Bearer test-token", r.Header.Get("Authorization"))
+		w.Write(recorded(t, "user_response.json"))
+	}))
+	defer server.Close()
+	c := New(server.URL)
+
+	_, err := c.Login(context.Background(), "golden@example.com", "password123")
+	require.NoError(t, err)
+	_, err = c.GetProfile(context.Background())
+	require.NoError(t, err)
+}
+
+func TestClient_ProblemDetails(t *testing.T) {
+	server := replay(t, http.StatusBadRequest, []byte(`{"type":"about:blank","title":"Bad Request","status":400,"detail":"email already registered","code":"EMAIL_TAKEN"}`), nil)
+
+	_, err := New(server.URL).Register(context.Background(), RegisterInput{})
+
+	assert.ErrorIs(t, err, ErrEmailTaken)
+	assert.EqualError(t, err, "client: 400 EMAIL_TAKEN: email already registered")
+}
+
+func TestClient_Retries(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		retries      int
+		wantAttempts int32
+		wantErr      error
+	}{
+		{name: "recovers from 5xx", statuses: []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK}, retries: 2, wantAttempts: 3},
+		{name: "gives up after retries", statuses: []int{http.StatusInternalServerError}, retries: 2, wantAttempts: 3, wantErr: ErrServer},
+		{name: "retries off", statuses: []int{http.StatusServiceUnavailable}, retries: 0, wantAttempts: 1, wantErr: ErrServer},
+		{name: "4xx not retried", statuses: []int{http.StatusUnauthorized}, retries: 2, wantAttempts: 1, wantErr: ErrUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := int(attempts.Add(1)) - 1
+				status := tt.statuses[min(n, len(tt.statuses)-1)]
+				w.WriteHeader(status)
+				if status == http.StatusOK {
+					w.Write(recorded(t, "user_response.json"))
+					return
+				}
+				w.Write([]byte(`{"error":"failed"}`))
+			}))
+			defer server.Close()
+
+			_, err := New(server.URL, WithRetries(tt.retries, time.Millisecond)).GetProfile(context.Background())
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantAttempts, attempts.Load())
+		})
+	}
+}
+
+func TestClient_RegistrationDisabledNotRetried(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"code":"REGISTRATION_DISABLED","error":"registration is currently disabled"}`))
+	}))
+	defer server.Close()
+
+	_, err := New(server.URL, WithRetries(2, time.Millisecond)).Register(context.Background(), RegisterInput{})
+
+	assert.ErrorIs(t, err, ErrRegistrationDisabled)
+	assert.Equal(t, int32(1), attempts.Load())
+}
+
+func TestClient_ContextCancelStopsRetries(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		cancel()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	_, err := New(server.URL, WithRetries(5, time.Hour)).GetProfile(ctx)
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, int32(1), attempts.Load())
+}
+
+func TestClient_Timeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	_, err := New(server.URL, WithTimeout(10*time.Millisecond), WithRetries(0, 0)).GetProfile(context.Background())
+
+	var netErr interface{ Timeout() bool }
+	require.True(t, errors.As(err, &netErr), "error %v is not a timeout", err)
+	assert.True(t, netErr.Timeout())
+}
+
+func TestClient_Backoff(t *testing.T) {
+	c := New("http://example.com", WithRetries(10, 100*time.Millisecond))
+
+	for _, tt := range []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{attempt: 0, want: 100 * time.Millisecond},
+		{attempt: 1, want: 200 * time.Millisecond},
+		{attempt: 2, want: 400 * time.Millisecond},
+		{attempt: 6, want: maxBackoff},
+		{attempt: 60, want: maxBackoff},
+	} {
+		wait := c.backoffFor(tt.attempt)
+		assert.LessOrEqual(t, wait, tt.want, "attempt %d", tt.attempt)
+		assert.GreaterOrEqual(t, wait, tt.want/2, "attempt %d", tt.attempt)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	assert.Equal(t, 3*time.Second, parseRetryAfter("3"))
+	assert.Zero(t, parseRetryAfter(""))
+	assert.Zero(t, parseRetryAfter("-1"))
+	assert.Zero(t, parseRetryAfter("Wed, 21 Oct 2015 07:28:00 GMT"))
+}
@@ -0,0 +1,125 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Errors an *Error returned by a Client matches with errors.Is, decided by
+// the code of the error response or, for responses without one, its status.
+var (
+	ErrEmailTaken            = errors.New("email already registered")
+	ErrAccountRecoverable    = errors.New("account scheduled for deletion can be recovered")
+	ErrDisposableEmail       = errors.New("disposable email addresses are not allowed")
+	ErrEmailDomainNotAllowed = errors.New("email domain is not allowed")
+	ErrTOSNotAccepted        = errors.New("terms of service not accepted")
+	ErrRegistrationDisabled  = errors.New("registration is disabled")
+	ErrInvalidCredentials    = errors.New("invalid credentials")
+	ErrInvalidInput          = errors.New("invalid input")
+	ErrUnauthorized          = errors.New("unauthorized")
+	ErrForbidden             = errors.New("forbidden")
+	ErrNotFound              = errors.New("not found")
+	ErrRateLimited           = errors.New("rate limited")
+	ErrServer                = errors.New("server error")
+)
+
+// codeErrors maps the codes of error responses to their sentinels.
+var codeErrors = map[string]error{
+	"EMAIL_TAKEN":              ErrEmailTaken,
+	"ACCOUNT_RECOVERABLE":      ErrAccountRecoverable,
+	"DISPOSABLE_EMAIL":         ErrDisposableEmail,
+	"EMAIL_DOMAIN_NOT_ALLOWED": ErrEmailDomainNotAllowed,
+	"TOS_NOT_ACCEPTED":         ErrTOSNotAccepted,
+	"REGISTRATION_DISABLED":    ErrRegistrationDisabled,
+	"INVALID_CREDENTIALS":      ErrInvalidCredentials,
+}
+
+// Error is an error response of the API.
+type Error struct {
+	// StatusCode is the HTTP status of the response.
+	StatusCode int
+	// Code is the stable identifier of the error, or empty if it has none.
+	Code string
+	// Message is the human readable description of the error.
+	Message string
+
+	kind error
+}
+
+func (e *Error) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("client: %d %s: %s", e.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Sprintf("client: %d: %s", e.StatusCode, e.Message)
+}
+
+// Unwrap returns the sentinel the error matches, such as ErrEmailTaken.
+func (e *Error) Unwrap() error {
+	return e.kind
+}
+
+// decodeError turns an error response into an *Error. Both the plain
+// {"error", "code"} envelope and problem details are understood.
+func decodeError(status int, body []byte) *Error {
+	var envelope struct {
+		Error  string `json:"error"`
+		Detail string `json:"detail"`
+		Code   string `json:"code"`
+	}
+	_ = json.Unmarshal(body, &envelope)
+
+	e := &Error{StatusCode: status, Code: envelope.Code, Message: envelope.Error}
+	if e.Message == "" {
+		e.Message = envelope.Detail
+	}
+	if e.Message == "" {
+		e.Message = http.StatusText(status)
+	}
+
+	if kind, ok := codeErrors[e.Code]; ok {
+		e.kind = kind
+		return e
+	}
+	switch {
+	case status == http.StatusBadRequest:
+		e.kind = ErrInvalidInput
+	case status == http.StatusUnauthorized:
+		e.kind = ErrUnauthorized
+	case status == http.StatusForbidden:
+		e.kind = ErrForbidden
+	case status == http.StatusNotFound:
+		e.kind = ErrNotFound
+	case status == http.StatusTooManyRequests:
+		e.kind = ErrRateLimited
+	case status >= http.StatusInternalServerError:
+		e.kind = ErrServer
+	}
+	return e
+}
+
+// networkError is a request that got no response, which is worth retrying.
+type networkError struct {
+	err error
+}
+
+func (e *networkError) Error() string {
+	return "client: " + e.err.Error()
+}
+
+func (e *networkError) Unwrap() error {
+	return e.err
+}
+
+// retryable reports whether a request that failed with err may succeed if
+// sent again: it got a 5xx response, other than for registration being
+// turned off, or no response at all.
+func retryable(err error) bool {
+	var netErr *networkError
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode >= http.StatusInternalServerError && apiErr.kind != ErrRegistrationDisabled
+}
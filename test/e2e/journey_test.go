@@ -0,0 +1,90 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// userKeys are the fields of every user the API responds with.
+var userKeys = []string{"id", "email", "full_name", "role", "last_login_at", "created_at", "updated_at", "metadata", "avatar_url", "username", "locale", "timezone"}
+
+func assertUser(t *testing.T, body map[string]any, email string) {
+	t.Helper()
+	for _, key := range userKeys {
+		assert.Contains(t, body, key)
+	}
+	assert.Equal(t, email, body["email"])
+	assert.Equal(t, "E2E User", body["full_name"])
+	assert.Equal(t, "user", body["role"])
+}
+
+func TestUserJourney(t *testing.T) {
+	s := newStack(t)
+	email := uniqueEmail(t)
+	const password = "correct-horse-battery"
+
+	resp := s.do(http.MethodPost, "/api/auth/register", map[string]any{
+		"email":     email,
+		"password":  password,
+		"full_name": "E2E User",
+	}, "")
+	require.Equal(t, http.StatusCreated, resp.status, "register: %s", resp.body)
+	registered := resp.json(t)
+	assertUser(t, registered, email)
+
+	resp = s.do(http.MethodPost, "/api/auth/register", map[string]any{
+		"email":     email,
+		"password":  password,
+		"full_name": "E2E User",
+	}, "")
+	require.Equal(t, http.StatusBadRequest, resp.status, "register again: %s", resp.body)
+	assert.Contains(t, resp.json(t), "error")
+
+	resp = s.do(http.MethodPost, "/api/auth/login", map[string]any{"email": email, "password": "wrong-password"}, "")
+	require.Equal(t, http.StatusBadRequest, resp.status, "login with wrong password: %s", resp.body)
+
+	resp = s.do(http.MethodPost, "/api/auth/login", map[string]any{"email": email, "password": password}, "")
+	require.Equal(t, http.StatusOK, resp.status, "login: %s", resp.body)
+	tokens := resp.json(t)
+	token, _ := tokens["token"].(string)
+	refreshToken, _ := tokens["refresh_token"].(string)
+	require.NotEmpty(t, token)
+	require.NotEmpty(t, refreshToken)
+
+	resp = s.do(http.MethodGet, "/api/auth/profile", nil, token)
+	require.Equal(t, http.StatusOK, resp.status, "profile: %s", resp.body)
+	profile := resp.json(t)
+	assertUser(t, profile, email)
+	assert.Equal(t, registered["id"], profile["id"])
+
+	resp = s.do(http.MethodPatch, "/api/auth/profile", map[string]any{"locale": "th-TH", "timezone": "Asia/Bangkok"}, token)
+	require.Equal(t, http.StatusOK, resp.status, "update profile: %s", resp.body)
+	updated := resp.json(t)
+	assertUser(t, updated, email)
+	assert.Equal(t, "th-TH", updated["locale"])
+	assert.Equal(t, "Asia/Bangkok", updated["timezone"])
+
+	resp = s.do(http.MethodPost, "/api/auth/logout-all", nil, token)
+	require.Equal(t, http.StatusOK, resp.status, "logout: %s", resp.body)
+
+	resp = s.do(http.MethodGet, "/api/auth/profile", nil, token)
+	assert.Equal(t, http.StatusUnauthorized, resp.status, "profile after logout: %s", resp.body)
+	assert.Contains(t, resp.json(t), "error")
+
+	resp = s.do(http.MethodPost, "/api/auth/refresh", map[string]any{"refresh_token": refreshToken}, "")
+	assert.Equal(t, http.StatusUnauthorized, resp.status, "refresh after logout: %s", resp.body)
+}
+
+func TestProfileWithoutToken(t *testing.T) {
+	s := newStack(t)
+
+	resp := s.do(http.MethodGet, "/api/auth/profile", nil, "")
+
+	assert.Equal(t, http.StatusUnauthorized, resp.status)
+	assert.Contains(t, resp.json(t), "error")
+}
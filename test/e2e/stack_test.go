@@ -0,0 +1,105 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/PakornBank/learn-go/internal/app"
+	"github.com/PakornBank/learn-go/internal/config"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stack is the API server running in process for a test.
+type stack struct {
+	t      *testing.T
+	server *httptest.Server
+}
+
+// newStack boots the server cmd/api runs against the database at
+// TEST_DATABASE_URL, and shuts it down when the test ends.
+// The test is skipped if TEST_DATABASE_URL is not set.
+func newStack(t *testing.T) *stack {
+	t.Helper()
+
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	t.Setenv("APP_ENV", config.EnvTest)
+	t.Setenv("DATABASE_URL", url)
+	t.Setenv("AVATAR_DIR", t.TempDir())
+
+	cfg, err := config.LoadConfig()
+	require.NoError(t, err)
+	gin.SetMode(gin.TestMode)
+	api, err := app.New(cfg, "")
+	require.NoError(t, err)
+
+	server := httptest.NewServer(api.Handler())
+	t.Cleanup(func() {
+		server.Close()
+		assert.NoError(t, api.Shutdown(context.Background()))
+	})
+	return &stack{t: t, server: server}
+}
+
+// response is a response of the stack, with its body read.
+type response struct {
+	status int
+	body   []byte
+}
+
+// json decodes the body as a JSON object, failing the test if it is not one.
+func (r response) json(t *testing.T) map[string]any {
+	t.Helper()
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(r.body, &body), "response body: %s", r.body)
+	return body
+}
+
+// do sends a request for path, with body encoded as JSON unless it is nil and
+// with token as the bearer token unless it is empty.
+func (s *stack) do(method, path string, body any, token string) response {
+	s.t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		require.NoError(s.t, err)
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, s.server.URL+path, reader)
+	require.NoError(s.t, err)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := s.server.Client().Do(req)
+	require.NoError(s.t, err)
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(s.t, err)
+	return response{status: resp.StatusCode, body: data}
+}
+
+// uniqueEmail returns an email address no earlier run has registered, so the
+// suite can run again against the same database.
+func uniqueEmail(t *testing.T) string {
+	name := strings.ToLower(strings.NewReplacer("/", "-", " ", "-").Replace(t.Name()))
+	return name + "-" + uuid.NewString()[:8] + "@example.com"
+}
@@ -0,0 +1,9 @@
+//go:build tools
+
+// Package tools records the code generators the module runs, so that go mod
+// tidy keeps their dependencies.
+package tools
+
+import (
+	_ "github.com/99designs/gqlgen"
+)